@@ -0,0 +1,138 @@
+package billing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// HoursPerMonth is the average month estimates are projected over
+const HoursPerMonth = 730
+
+// PriceSheet prices worker resources. Regions multiplies the base prices per
+// main region; when it is non-empty, regions not listed are not priced. An
+// empty region uses the base prices.
+type PriceSheet struct {
+	Currency      string             `json:"currency"`
+	CPUCoreHour   float64            `json:"cpu_core_hour"`
+	MemoryGiBHour float64            `json:"memory_gib_hour"`
+	DiskGiBMonth  float64            `json:"disk_gib_month"`
+	Regions       map[string]float64 `json:"regions,omitempty"`
+}
+
+// Sheet is the price sheet estimates use (env PRICE_SHEET, the path of a JSON
+// file in the PriceSheet format)
+var Sheet = PriceSheet{
+	Currency:      "USD",
+	CPUCoreHour:   0.03,
+	MemoryGiBHour: 0.004,
+	DiskGiBMonth:  0.10,
+}
+
+// LoadPriceSheet reads a PriceSheet from a JSON file
+func LoadPriceSheet(path string) (PriceSheet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PriceSheet{}, err
+	}
+	var s PriceSheet
+	if err := json.Unmarshal(data, &s); err != nil {
+		return PriceSheet{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Currency == "" {
+		return PriceSheet{}, fmt.Errorf("%s: currency is required", path)
+	}
+	if s.CPUCoreHour < 0 || s.MemoryGiBHour < 0 || s.DiskGiBMonth < 0 {
+		return PriceSheet{}, fmt.Errorf("%s: prices must not be negative", path)
+	}
+	for r, m := range s.Regions {
+		if m <= 0 {
+			return PriceSheet{}, fmt.Errorf("%s: multiplier of region %s must be positive", path, r)
+		}
+	}
+	return s, nil
+}
+
+// Resources is what a worker is priced on; CPU, memory and disk are per replica
+type Resources struct {
+	CPUCores    float64
+	MemoryBytes float64
+	DiskBytes   float64
+	Replicas    int
+	Region      string
+}
+
+// CostItem is the monthly cost of one resource over all replicas
+type CostItem struct {
+	Resource  string  `json:"resource"` // cpu, memory, disk
+	Quantity  float64 `json:"quantity"` // per replica, in Unit
+	Unit      string  `json:"unit"`
+	UnitPrice float64 `json:"unit_price"`
+	Monthly   float64 `json:"monthly"`
+}
+
+// CostEstimate is the projected monthly cost of a worker
+type CostEstimate struct {
+	Currency         string     `json:"currency"`
+	Region           string     `json:"region,omitempty"`
+	RegionMultiplier float64    `json:"region_multiplier"`
+	Replicas         int        `json:"replicas"`
+	HoursPerMonth    int        `json:"hours_per_month"`
+	Items            []CostItem `json:"items"`
+	Monthly          float64    `json:"monthly"`
+}
+
+// ErrUnpricedRegion is returned for a region the price sheet does not list
+type ErrUnpricedRegion string
+
+func (e ErrUnpricedRegion) Error() string {
+	return fmt.Sprintf("region %s has no price", string(e))
+}
+
+// Estimate projects the monthly cost of r on the sheet, running all replicas
+// the whole month
+func (s PriceSheet) Estimate(r Resources) (*CostEstimate, error) {
+	mult := 1.0
+	if r.Region != "" && len(s.Regions) > 0 {
+		m, ok := s.Regions[r.Region]
+		if !ok {
+			return nil, ErrUnpricedRegion(r.Region)
+		}
+		mult = m
+	}
+	replicas := max(r.Replicas, 1)
+	const gib = 1 << 30
+
+	est := &CostEstimate{
+		Currency:         s.Currency,
+		Region:           r.Region,
+		RegionMultiplier: mult,
+		Replicas:         replicas,
+		HoursPerMonth:    HoursPerMonth,
+	}
+	add := func(resource, unit string, quantity, unitPrice, perMonth float64) {
+		unitPrice *= mult
+		monthly := cents(quantity * unitPrice * perMonth * float64(replicas))
+		est.Items = append(est.Items, CostItem{Resource: resource, Quantity: quantity, Unit: unit, UnitPrice: unitPrice, Monthly: monthly})
+		est.Monthly += monthly
+	}
+	add("cpu", "core-hour", r.CPUCores, s.CPUCoreHour, HoursPerMonth)
+	add("memory", "GiB-hour", r.MemoryBytes/gib, s.MemoryGiBHour, HoursPerMonth)
+	add("disk", "GiB-month", r.DiskBytes/gib, s.DiskGiBMonth, 1)
+	est.Monthly = cents(est.Monthly)
+	return est, nil
+}
+
+func cents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// UsageCost prices metered usage (see dblayer.UsageMetrics) at the base
+// prices: CPU core-seconds, memory byte-seconds and storage byte-seconds
+func (s PriceSheet) UsageCost(cpuCoreSeconds, memoryByteSeconds, storageByteSeconds float64) float64 {
+	const gib = 1 << 30
+	return cents(cpuCoreSeconds/3600*s.CPUCoreHour +
+		memoryByteSeconds/gib/3600*s.MemoryGiBHour +
+		storageByteSeconds/gib/(HoursPerMonth*3600)*s.DiskGiBMonth)
+}
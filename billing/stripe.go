@@ -0,0 +1,299 @@
+// Package billing talks to Stripe: Checkout sessions for subscriptions and
+// verification and decoding of webhook events. It uses the REST API directly,
+// form encoded as Stripe expects, so there is no SDK dependency. It also
+// prices worker specs against a configurable price sheet.
+package billing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// SecretKey authenticates API calls (env STRIPE_SECRET_KEY); billing is
+	// disabled without it
+	SecretKey string
+	// WebhookSecret verifies the Stripe-Signature of webhook events (env STRIPE_WEBHOOK_SECRET)
+	WebhookSecret string
+	// Prices maps plans to Stripe price IDs (env STRIPE_PRICES, "pro=price_1,team=price_2")
+	Prices = map[string]string{}
+	// ReturnURL is where Checkout sends the user back to, with
+	// ?checkout=success or ?checkout=cancel appended (env BILLING_RETURN_URL)
+	ReturnURL string
+
+	apiBase = "https://api.stripe.com/v1"
+	client  = &http.Client{Timeout: 15 * time.Second}
+)
+
+// Envs are the environment variables read by SetEnv
+var Envs = []string{"STRIPE_SECRET_KEY", "STRIPE_WEBHOOK_SECRET", "STRIPE_PRICES", "BILLING_RETURN_URL", "PRICE_SHEET"}
+
+// SetEnv applies one of Envs
+func SetEnv(env, value string) error {
+	switch env {
+	case "STRIPE_SECRET_KEY":
+		SecretKey = value
+	case "STRIPE_WEBHOOK_SECRET":
+		WebhookSecret = value
+	case "STRIPE_PRICES":
+		Prices = map[string]string{}
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			plan, price, ok := strings.Cut(pair, "=")
+			plan, price = strings.TrimSpace(plan), strings.TrimSpace(price)
+			if !ok || plan == "" || price == "" {
+				return fmt.Errorf("%s: %q is not plan=price", env, pair)
+			}
+			Prices[plan] = price
+		}
+	case "BILLING_RETURN_URL":
+		if _, err := url.ParseRequestURI(value); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		ReturnURL = value
+	case "PRICE_SHEET":
+		s, err := LoadPriceSheet(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		Sheet = s
+	default:
+		return fmt.Errorf("unknown billing variable %s", env)
+	}
+	return nil
+}
+
+// Enabled reports whether Stripe is configured
+func Enabled() bool {
+	return SecretKey != ""
+}
+
+// PlanForPrice returns the plan sold at price, empty when it is none of Prices
+func PlanForPrice(price string) string {
+	for plan, p := range Prices {
+		if p == price {
+			return plan
+		}
+	}
+	return ""
+}
+
+// Error is an error response of the Stripe API
+type Error struct {
+	Status  int
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("stripe %d %s: %s", e.Status, e.Type, e.Message)
+}
+
+// post calls a Stripe API endpoint with form parameters and decodes the response into out
+func post(ctx context.Context, path string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiBase+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(SecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("stripe %s: %w", path, err)
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error *Error `json:"error"`
+		}
+		if json.Unmarshal(body, &e) != nil || e.Error == nil {
+			return fmt.Errorf("stripe %s returned %s", path, resp.Status)
+		}
+		e.Error.Status = resp.StatusCode
+		return e.Error
+	}
+	return json.Unmarshal(body, out)
+}
+
+// CheckoutSession is a Stripe Checkout session, or the object of a
+// checkout.session.* event
+type CheckoutSession struct {
+	ID                string `json:"id"`
+	URL               string `json:"url"`
+	Customer          string `json:"customer"`
+	Subscription      string `json:"subscription"`
+	ClientReferenceID string `json:"client_reference_id"`
+}
+
+// CreateCheckoutSession starts a subscription to plan for userUID. An
+// existing customer is reused so one account keeps one Stripe customer;
+// otherwise Checkout creates one for email.
+func CreateCheckoutSession(ctx context.Context, userUID, email, customerID, plan string) (*CheckoutSession, error) {
+	price, ok := Prices[plan]
+	if !ok {
+		return nil, fmt.Errorf("plan %s has no price", plan)
+	}
+	form := url.Values{
+		"mode":                                  {"subscription"},
+		"line_items[0][price]":                  {price},
+		"line_items[0][quantity]":               {"1"},
+		"client_reference_id":                   {userUID},
+		"metadata[user_uid]":                    {userUID},
+		"subscription_data[metadata][user_uid]": {userUID},
+		"success_url":                           {returnURL("success")},
+		"cancel_url":                            {returnURL("cancel")},
+	}
+	if customerID != "" {
+		form.Set("customer", customerID)
+	} else {
+		form.Set("customer_email", email)
+	}
+	var s CheckoutSession
+	if err := post(ctx, "/checkout/sessions", form, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func returnURL(result string) string {
+	sep := "?"
+	if strings.Contains(ReturnURL, "?") {
+		sep = "&"
+	}
+	return ReturnURL + sep + "checkout=" + result
+}
+
+// Subscription is the object of customer.subscription.* events
+type Subscription struct {
+	ID               string            `json:"id"`
+	Customer         string            `json:"customer"`
+	Status           string            `json:"status"` // active, trialing, past_due, unpaid, canceled, incomplete, ...
+	CurrentPeriodEnd int64             `json:"current_period_end"`
+	Metadata         map[string]string `json:"metadata"`
+	Items            struct {
+		Data []struct {
+			CurrentPeriodEnd int64 `json:"current_period_end"`
+			Price            struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// PriceID is the price of the subscription's first item
+func (s *Subscription) PriceID() string {
+	if len(s.Items.Data) == 0 {
+		return ""
+	}
+	return s.Items.Data[0].Price.ID
+}
+
+// PeriodEnd is the end of the current billing period; newer API versions
+// only report it per item
+func (s *Subscription) PeriodEnd() time.Time {
+	end := s.CurrentPeriodEnd
+	if end == 0 && len(s.Items.Data) > 0 {
+		end = s.Items.Data[0].CurrentPeriodEnd
+	}
+	if end == 0 {
+		return time.Time{}
+	}
+	return time.Unix(end, 0)
+}
+
+// Entitled reports whether the subscription still grants its plan. past_due
+// keeps it during the dunning grace period.
+func (s *Subscription) Entitled() bool {
+	switch s.Status {
+	case "active", "trialing", "past_due":
+		return true
+	}
+	return false
+}
+
+// Invoice is the object of invoice.* events
+type Invoice struct {
+	ID           string `json:"id"`
+	Customer     string `json:"customer"`
+	Subscription string `json:"subscription"`
+	AttemptCount int    `json:"attempt_count"`
+}
+
+// Event is a webhook event; Object is decoded according to Type
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// signatureTolerance is how old a signed webhook may be, against replays
+const signatureTolerance = 5 * time.Minute
+
+var ErrBadSignature = errors.New("invalid stripe signature")
+
+// ParseWebhook verifies the Stripe-Signature header of a webhook payload
+// against WebhookSecret and decodes the event
+func ParseWebhook(payload []byte, header string, now time.Time) (*Event, error) {
+	if WebhookSecret == "" {
+		return nil, fmt.Errorf("%w: STRIPE_WEBHOOK_SECRET is not set", ErrBadSignature)
+	}
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return nil, fmt.Errorf("%w: malformed header", ErrBadSignature)
+	}
+	if d := now.Sub(time.Unix(t, 0)); d > signatureTolerance || d < -signatureTolerance {
+		return nil, fmt.Errorf("%w: timestamp outside tolerance", ErrBadSignature)
+	}
+
+	mac := hmac.New(sha256.New, []byte(WebhookSecret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(payload)
+	want := mac.Sum(nil)
+	ok := false
+	for _, s := range sigs {
+		if got, err := hex.DecodeString(s); err == nil && hmac.Equal(got, want) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return nil, ErrBadSignature
+	}
+
+	var ev Event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, fmt.Errorf("decode event: %w", err)
+	}
+	return &ev, nil
+}
@@ -0,0 +1,116 @@
+// Package client is a typed Go client for the outer console API. The
+// methods in operations.gen.go are generated from handlers.OuterOperations,
+// so request and response types are the ones the handlers bind and render.
+package client
+
+//go:generate go run ./gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/handlers"
+	"jabberwocky238/console/handlers/openapi"
+)
+
+// Client calls the API at BaseURL (e.g. https://console.example.com).
+// Token authenticates bearer routes; UserID and SecretKey sign the
+// signature routes (worker deploy).
+type Client struct {
+	BaseURL   string
+	Token     string
+	UserID    string
+	SecretKey string
+	HTTP      *http.Client
+}
+
+// New returns a client for baseURL with a 30s request timeout
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is returned for non-2xx responses. Response holds the decoded
+// handlers.ErrorResponse, Body the raw body for routes that add fields
+// (e.g. step_up on login).
+type Error struct {
+	Status   int
+	Response handlers.ErrorResponse
+	Body     []byte
+}
+
+func (e *Error) Error() string {
+	msg := e.Response.String()
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	return fmt.Sprintf("%d: %s", e.Status, msg)
+}
+
+// call sends one request; body is JSON encoded unless nil, and a 2xx
+// response is decoded into out unless out is nil
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out any, security string) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch security {
+	case openapi.SecurityBearer:
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+	case openapi.SecuritySignature:
+		// same scheme as the web console: HMAC over body + timestamp
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		req.Header.Set("X-Combinator-Signature", handlers.GenerateHMACSignature(c.SecretKey, append(payload, timestamp...)))
+		req.Header.Set("X-Combinator-User-ID", c.UserID)
+		req.Header.Set("X-Combinator-Timestamp", timestamp)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{Status: resp.StatusCode, Body: data}
+		json.Unmarshal(data, &apiErr.Response)
+		return apiErr
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+// Command gen writes client/operations.gen.go from handlers.OuterOperations.
+// Run it with go generate ./client after changing the outer routes.
+package main
+
+import (
+	"log"
+	"os"
+
+	"jabberwocky238/console/handlers"
+	"jabberwocky238/console/handlers/openapi"
+)
+
+func main() {
+	src, err := openapi.GoClient("client", handlers.OuterOperations, handlers.LegacyAPIPrefix, handlers.APIPrefixV1)
+	if err != nil {
+		log.Fatalf("generate client: %v", err)
+	}
+	if err := os.WriteFile("operations.gen.go", src, 0644); err != nil {
+		log.Fatalf("write client: %v", err)
+	}
+}
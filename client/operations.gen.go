@@ -0,0 +1,1103 @@
+// Code generated by client/gen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"jabberwocky238/console/billing"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+)
+
+// GetHealth calls GET /health: Health check
+func (c *Client) GetHealth(ctx context.Context) (out handlers.HealthResponse, err error) {
+	err = c.call(ctx, "GET", "/health", nil, nil, &out, "")
+	return
+}
+
+// GetReady calls GET /ready: Readiness check, 503 while draining for shutdown
+func (c *Client) GetReady(ctx context.Context) (out handlers.HealthResponse, err error) {
+	err = c.call(ctx, "GET", "/ready", nil, nil, &out, "")
+	return
+}
+
+// PostAuthRegister calls POST /api/v1/auth/register: Register a new user; session=cookie sets an HttpOnly session cookie and returns csrf_token instead of token. locale (en, zh) is the language of the account's mail, from Accept-Language when omitted. invite_code is required when signup.mode is invite (403 without one, or when it is invalid, expired, used up or bound to another email); in waitlist mode a registration without one consumes the verification code and returns 202 with the waitlist status instead of an account
+func (c *Client) PostAuthRegister(ctx context.Context, req handlers.RegisterRequest) (out handlers.RegisterResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/auth/register", nil, req, &out, "")
+	return
+}
+
+// GetAuthSignup calls GET /api/v1/auth/signup: How registration works right now: open, invite (invitation code required) or waitlist
+func (c *Client) GetAuthSignup(ctx context.Context) (out handlers.SignupInfoResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/auth/signup", nil, nil, &out, "")
+	return
+}
+
+// PostAuthLogin calls POST /api/v1/auth/login: Log in and obtain a JWT; session=cookie sets an HttpOnly session cookie and returns csrf_token, to send as X-CSRF-Token on state-changing requests. A risky login (failed attempts, new device, new country or network) returns 401 with step_up and emails a code to resubmit as code; 429 after too many failures
+func (c *Client) PostAuthLogin(ctx context.Context, req handlers.LoginRequest) (out handlers.LoginResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/auth/login", nil, req, &out, "")
+	return
+}
+
+// PostAuthLogout calls POST /api/v1/auth/logout: Clear the session cookies
+func (c *Client) PostAuthLogout(ctx context.Context) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/auth/logout", nil, nil, &out, "bearer")
+	return
+}
+
+// PostAuthSendCode calls POST /api/v1/auth/send-code: Send an email verification code, in locale or else the language of the existing account or Accept-Language
+func (c *Client) PostAuthSendCode(ctx context.Context, req handlers.SendCodeRequest) (out handlers.SendCodeResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/auth/send-code", nil, req, &out, "")
+	return
+}
+
+// PostAuthResetPassword calls POST /api/v1/auth/reset-password: Reset password with a verification code
+func (c *Client) PostAuthResetPassword(ctx context.Context, req handlers.ResetPasswordRequest) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/auth/reset-password", nil, req, &out, "")
+	return
+}
+
+// GetRdb calls GET /api/v1/rdb: List RDB resources (status pending_apply until the dedicated combinator has loaded them)
+// (query: limit, cursor, sort, order, status, type, tag, environment)
+func (c *Client) GetRdb(ctx context.Context, query url.Values) (out handlers.ListRDBsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/rdb", query, nil, &out, "bearer")
+	return
+}
+
+// GetRdbId calls GET /api/v1/rdb/:id: Get an RDB resource (sets ETag/Last-Modified, honours If-None-Match)
+func (c *Client) GetRdbId(ctx context.Context, id string) (out handlers.RDBDetailResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/rdb/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PostRdb calls POST /api/v1/rdb: Create an RDB resource (403 once the RDB storage of the plan is used up)
+func (c *Client) PostRdb(ctx context.Context, req handlers.CreateRDBRequest) (out handlers.ResourceCreatedResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/rdb", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteRdbId calls DELETE /api/v1/rdb/:id: Delete an RDB resource (412 if If-Match is stale)
+func (c *Client) DeleteRdbId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/rdb/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PutRdbIdTags calls PUT /api/v1/rdb/:id/tags: Replace the tags of an RDB resource (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutRdbIdTags(ctx context.Context, id string, req handlers.SetTagsRequest) (out handlers.TagsResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/rdb/"+url.PathEscape(id)+"/tags", nil, req, &out, "bearer")
+	return
+}
+
+// PutRdbIdEnvironment calls PUT /api/v1/rdb/:id/environment: Move an RDB resource to another environment (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutRdbIdEnvironment(ctx context.Context, id string, req handlers.SetEnvironmentRequest) (out handlers.SetEnvironmentResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/rdb/"+url.PathEscape(id)+"/environment", nil, req, &out, "bearer")
+	return
+}
+
+// GetRdbIdTasks calls GET /api/v1/rdb/:id/tasks: List the scheduled SQL tasks of an RDB resource (filter with status=pending|succeeded|failed|timeout, the result of the last run)
+// (query: limit, cursor, sort, order, status)
+func (c *Client) GetRdbIdTasks(ctx context.Context, id string, query url.Values) (out handlers.ListRDBTasksResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/rdb/"+url.PathEscape(id)+"/tasks", query, nil, &out, "bearer")
+	return
+}
+
+// PostRdbIdTasks calls POST /api/v1/rdb/:id/tasks: Create a scheduled SQL task: the statements run in the RDB's schema as the user's database role on a cron schedule in timezone, stopped after timeout_seconds. The first failure after a success is notified (email unless notify_email is false). 409 if the RDB is not active, the name is taken or the user has 20 tasks; 400 if the schedule fires more than once a minute or never
+func (c *Client) PostRdbIdTasks(ctx context.Context, id string, req handlers.RDBTaskRequest) (out dblayer.RDBTask, err error) {
+	err = c.call(ctx, "POST", "/api/v1/rdb/"+url.PathEscape(id)+"/tasks", nil, req, &out, "bearer")
+	return
+}
+
+// GetRdbIdTasksTask calls GET /api/v1/rdb/:id/tasks/:task: Get a scheduled SQL task with its last result and next run
+func (c *Client) GetRdbIdTasksTask(ctx context.Context, id string, task string) (out dblayer.RDBTask, err error) {
+	err = c.call(ctx, "GET", "/api/v1/rdb/"+url.PathEscape(id)+"/tasks/"+url.PathEscape(task), nil, nil, &out, "bearer")
+	return
+}
+
+// PutRdbIdTasksTask calls PUT /api/v1/rdb/:id/tasks/:task: Replace a scheduled SQL task; the next run follows the new schedule
+func (c *Client) PutRdbIdTasksTask(ctx context.Context, id string, task string, req handlers.RDBTaskRequest) (out dblayer.RDBTask, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/rdb/"+url.PathEscape(id)+"/tasks/"+url.PathEscape(task), nil, req, &out, "bearer")
+	return
+}
+
+// DeleteRdbIdTasksTask calls DELETE /api/v1/rdb/:id/tasks/:task: Delete a scheduled SQL task and its run history; a run in progress finishes
+func (c *Client) DeleteRdbIdTasksTask(ctx context.Context, id string, task string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/rdb/"+url.PathEscape(id)+"/tasks/"+url.PathEscape(task), nil, nil, &out, "bearer")
+	return
+}
+
+// PostRdbIdTasksTaskRun calls POST /api/v1/rdb/:id/tasks/:task/run: Run a task once now, even when disabled; it starts within 30 seconds, after a run still in progress
+func (c *Client) PostRdbIdTasksTaskRun(ctx context.Context, id string, task string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/rdb/"+url.PathEscape(id)+"/tasks/"+url.PathEscape(task)+"/run", nil, nil, &out, "bearer")
+	return
+}
+
+// GetRdbIdTasksTaskRuns calls GET /api/v1/rdb/:id/tasks/:task/runs: Run history of a task, kept 30 days (filter with status=running|succeeded|failed|timeout)
+// (query: limit, cursor, sort, order, status)
+func (c *Client) GetRdbIdTasksTaskRuns(ctx context.Context, id string, task string, query url.Values) (out handlers.ListRDBTaskRunsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/rdb/"+url.PathEscape(id)+"/tasks/"+url.PathEscape(task)+"/runs", query, nil, &out, "bearer")
+	return
+}
+
+// GetKv calls GET /api/v1/kv: List KV resources (status pending_apply until the dedicated combinator has loaded them)
+// (query: limit, cursor, sort, order, status, type, tag, environment)
+func (c *Client) GetKv(ctx context.Context, query url.Values) (out handlers.ListKVsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/kv", query, nil, &out, "bearer")
+	return
+}
+
+// PostKv calls POST /api/v1/kv: Create a KV resource; the body is optional (environment defaults to production)
+func (c *Client) PostKv(ctx context.Context, req handlers.CreateKVRequest) (out handlers.ResourceCreatedResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/kv", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteKvId calls DELETE /api/v1/kv/:id: Delete a KV resource (412 if If-Match is stale)
+func (c *Client) DeleteKvId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/kv/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PutKvIdTags calls PUT /api/v1/kv/:id/tags: Replace the tags of a KV resource (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutKvIdTags(ctx context.Context, id string, req handlers.SetTagsRequest) (out handlers.TagsResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/kv/"+url.PathEscape(id)+"/tags", nil, req, &out, "bearer")
+	return
+}
+
+// PutKvIdEnvironment calls PUT /api/v1/kv/:id/environment: Move a KV resource to another environment (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutKvIdEnvironment(ctx context.Context, id string, req handlers.SetEnvironmentRequest) (out handlers.SetEnvironmentResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/kv/"+url.PathEscape(id)+"/environment", nil, req, &out, "bearer")
+	return
+}
+
+// GetBucket calls GET /api/v1/bucket: List object storage buckets, with the total measured size
+// (query: limit, cursor, sort, order, status, type, tag, environment)
+func (c *Client) GetBucket(ctx context.Context, query url.Values) (out handlers.ListBucketsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/bucket", query, nil, &out, "bearer")
+	return
+}
+
+// GetBucketId calls GET /api/v1/bucket/:id: Get a bucket: its name on the object store, lifecycle rules, last measured size and object count, and when its credentials expire
+func (c *Client) GetBucketId(ctx context.Context, id string) (out handlers.BucketDetailResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/bucket/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PostBucket calls POST /api/v1/bucket: Create an object storage bucket; the body is optional. Attach it to a worker to get scoped credentials (403 once the bucket storage of the plan is used up)
+func (c *Client) PostBucket(ctx context.Context, req handlers.CreateBucketRequest) (out handlers.ResourceCreatedResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/bucket", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteBucketId calls DELETE /api/v1/bucket/:id: Delete a bucket and every object in it (412 if If-Match is stale)
+func (c *Client) DeleteBucketId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/bucket/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PutBucketIdTags calls PUT /api/v1/bucket/:id/tags: Replace the tags of a bucket (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutBucketIdTags(ctx context.Context, id string, req handlers.SetTagsRequest) (out handlers.TagsResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/bucket/"+url.PathEscape(id)+"/tags", nil, req, &out, "bearer")
+	return
+}
+
+// PutBucketIdEnvironment calls PUT /api/v1/bucket/:id/environment: Move a bucket to another environment (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutBucketIdEnvironment(ctx context.Context, id string, req handlers.SetEnvironmentRequest) (out handlers.SetEnvironmentResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/bucket/"+url.PathEscape(id)+"/environment", nil, req, &out, "bearer")
+	return
+}
+
+// GetQueue calls GET /api/v1/queue: List message queue resources (status pending_apply until the dedicated combinator has loaded them)
+// (query: limit, cursor, sort, order, status, type, tag, environment)
+func (c *Client) GetQueue(ctx context.Context, query url.Values) (out handlers.ListQueuesResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/queue", query, nil, &out, "bearer")
+	return
+}
+
+// GetQueueId calls GET /api/v1/queue/:id: Get a queue resource with its live depth (ready and unacknowledged messages), consumers and message rates from the broker; stats are omitted until the queue is active or while the broker is unreachable
+func (c *Client) GetQueueId(ctx context.Context, id string) (out handlers.QueueDetailResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/queue/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PostQueue calls POST /api/v1/queue: Create a durable queue, named after the resource ID, in the user's vhost on the shared RabbitMQ; the body is optional. Attach it to a worker to get AMQP_URL
+func (c *Client) PostQueue(ctx context.Context, req handlers.CreateQueueRequest) (out handlers.ResourceCreatedResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/queue", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteQueueId calls DELETE /api/v1/queue/:id: Delete a queue and its messages (412 if If-Match is stale)
+func (c *Client) DeleteQueueId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/queue/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PutQueueIdTags calls PUT /api/v1/queue/:id/tags: Replace the tags of a queue resource (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutQueueIdTags(ctx context.Context, id string, req handlers.SetTagsRequest) (out handlers.TagsResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/queue/"+url.PathEscape(id)+"/tags", nil, req, &out, "bearer")
+	return
+}
+
+// PutQueueIdEnvironment calls PUT /api/v1/queue/:id/environment: Move a queue resource to another environment (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutQueueIdEnvironment(ctx context.Context, id string, req handlers.SetEnvironmentRequest) (out handlers.SetEnvironmentResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/queue/"+url.PathEscape(id)+"/environment", nil, req, &out, "bearer")
+	return
+}
+
+// PutBucketIdLifecycle calls PUT /api/v1/bucket/:id/lifecycle: Replace the lifecycle rules of a bucket: expire objects under a prefix and clean up incomplete uploads after some days; an empty list removes them (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutBucketIdLifecycle(ctx context.Context, id string, req handlers.SetBucketLifecycleRequest) (out handlers.BucketLifecycleResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/bucket/"+url.PathEscape(id)+"/lifecycle", nil, req, &out, "bearer")
+	return
+}
+
+// GetCombinatorApp calls GET /api/v1/combinator/app: Status of the user's dedicated combinator (phase Absent when the user has none or COMBINATOR_APPS is off)
+func (c *Client) GetCombinatorApp(ctx context.Context) (out controller.CombinatorAppStatus, err error) {
+	err = c.call(ctx, "GET", "/api/v1/combinator/app", nil, nil, &out, "bearer")
+	return
+}
+
+// PutCombinatorAppProfile calls PUT /api/v1/combinator/app/profile: Set the sizing profile (small, medium, large; empty for the plan default) of the dedicated combinator and roll its pod; 403 above the plan
+func (c *Client) PutCombinatorAppProfile(ctx context.Context, req handlers.SetCombinatorProfileRequest) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/combinator/app/profile", nil, req, &out, "bearer")
+	return
+}
+
+// GetCombinatorStatus calls GET /api/v1/combinator/status: Pods of the user's dedicated combinator with phase, readiness, restart count and waiting reason (e.g. CrashLoopBackOff), and the latest config revision next to the one the pods have loaded. dedicated is false and pods empty under the shared combinator
+func (c *Client) GetCombinatorStatus(ctx context.Context) (out controller.CombinatorRuntimeStatus, err error) {
+	err = c.call(ctx, "GET", "/api/v1/combinator/status", nil, nil, &out, "bearer")
+	return
+}
+
+// GetCombinatorLogs calls GET /api/v1/combinator/logs: Last tail lines (default 100, at most 5000) of each pod of the dedicated combinator; previous=true reads the container that ran before the last restart
+// (query: tail, previous)
+func (c *Client) GetCombinatorLogs(ctx context.Context, query url.Values) (out handlers.CombinatorLogsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/combinator/logs", query, nil, &out, "bearer")
+	return
+}
+
+// PostCombinatorRestart calls POST /api/v1/combinator/restart: Roll the pods of the dedicated combinator, 202 once the restart is queued; 409 when none is deployed
+func (c *Client) PostCombinatorRestart(ctx context.Context) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/combinator/restart", nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorker calls GET /api/v1/worker: List workers
+// (query: limit, cursor, sort, order, status, type, tag, environment)
+func (c *Client) GetWorker(ctx context.Context, query url.Values) (out []handlers.WorkerSummary, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker", query, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerId calls GET /api/v1/worker/:id: Get a worker with recent deploy versions (sets ETag/Last-Modified, honours If-None-Match)
+// (query: offset)
+func (c *Client) GetWorkerId(ctx context.Context, id string, query url.Values) (out handlers.WorkerDetailResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id), query, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerPools calls GET /api/v1/worker/pools: List the node pools a worker can select (pool), with architectures, capacity and price discovered from node labels
+func (c *Client) GetWorkerPools(ctx context.Context) (out handlers.NodePoolsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/pools", nil, nil, &out, "bearer")
+	return
+}
+
+// GetRegions calls GET /api/v1/regions: List the regions main_region can select, discovered from node labels, with free capacity and the largest pod that still fits, plus the account's default region; deploys into an unknown region are rejected
+func (c *Client) GetRegions(ctx context.Context) (out handlers.RegionsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/regions", nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorker calls POST /api/v1/worker: Create a worker (403 above the plan limits: worker count, replicas, CPU/memory, run_as_root, writable_root_fs). kind static serves files deployed with POST /worker/:id/static instead of an image
+func (c *Client) PostWorker(ctx context.Context, req handlers.CreateWorkerRequest) (out handlers.CreateWorkerResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteWorkerId calls DELETE /api/v1/worker/:id: Delete a worker (412 if If-Match is stale)
+func (c *Client) DeleteWorkerId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/worker/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorkerIdClone calls POST /api/v1/worker/:id/clone: Copy a worker into a new one: spec, env, env groups, egress and route rules, edge limits, error pages (maintenance off) and attachments (sharing the same resources), deployed from the source's active image. main_region or cluster moves the copy, tags are merged over the source's (e.g. env: staging). Worker secret values are only copied with copy_secrets. 403 above the plan limits, 422/402 when the deploy is refused
+func (c *Client) PostWorkerIdClone(ctx context.Context, id string, req handlers.CloneWorkerRequest) (out handlers.CloneWorkerResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/clone", nil, req, &out, "bearer")
+	return
+}
+
+// PostWorkerIdStatic calls POST /api/v1/worker/:id/static: Deploy the files of a static worker, served by the static site image with the same deploy versions as image deploys: a gzipped tarball as the application/gzip request body (up to 64 MiB, dir picks the site root inside it), or a public GitHub repo as JSON (ref defaults to main and is pinned to its commit at deploy). 409 for container workers, 412 if If-Match is stale, 422/402 when the deploy is refused
+// (query: dir)
+func (c *Client) PostWorkerIdStatic(ctx context.Context, id string, req handlers.StaticRepoRequest, query url.Values) (out handlers.DeployWorkerResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/static", query, req, &out, "bearer")
+	return
+}
+
+// PostWorkerIdPromote calls POST /api/v1/worker/:id/promote: Deploy the image, port and commit of a version of this worker (default its active version) to target_id in another environment, e.g. staging to production; the target keeps its own env and config. Static workers promote their files to static workers. 400 if both are in the same environment or of different kinds, 412 if If-Match on the target is stale, 422/402 when the deploy is refused
+func (c *Client) PostWorkerIdPromote(ctx context.Context, id string, req handlers.PromoteWorkerRequest) (out handlers.PromoteWorkerResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/promote", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdEnv calls GET /api/v1/worker/:id/env: Get worker environment variables; values of sensitive keys and secret-shaped values are masked
+func (c *Client) GetWorkerIdEnv(ctx context.Context, id string) (out map[string]string, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/env", nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorkerIdEnv calls POST /api/v1/worker/:id/env: Set or delete a worker environment variable, returns the masked env (412 if If-Match is stale, 409 if expected_version is stale or an attachment injects the key)
+func (c *Client) PostWorkerIdEnv(ctx context.Context, id string, req handlers.WorkerKeyValueRequest) (out map[string]string, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/env", nil, req, &out, "bearer")
+	return
+}
+
+// PostWorkerIdEnvReveal calls POST /api/v1/worker/:id/env/reveal: Reveal the unmasked value of one environment variable; recorded as a secret_revealed security event
+func (c *Client) PostWorkerIdEnvReveal(ctx context.Context, id string, req handlers.RevealEnvRequest) (out handlers.RevealEnvResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/env/reveal", nil, req, &out, "bearer")
+	return
+}
+
+// PostWorkerIdEnvImport calls POST /api/v1/worker/:id/env/import: Import a .env file, sent as the raw request body or as content in JSON. Each variable is stored as a worker secret or plain env, guessed from its name and value; secret and plain (repeatable) override the guess and existing worker secrets stay secrets. Saved in one update and synced to the worker (412 if If-Match is stale); dry_run only returns the classification
+// (query: secret, plain, dry_run)
+func (c *Client) PostWorkerIdEnvImport(ctx context.Context, id string, req handlers.ImportEnvRequest, query url.Values) (out handlers.ImportEnvResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/env/import", query, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdSecret calls GET /api/v1/worker/:id/secret: List worker secret keys
+func (c *Client) GetWorkerIdSecret(ctx context.Context, id string) (out []string, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/secret", nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorkerIdSecret calls POST /api/v1/worker/:id/secret: Set or delete a worker secret (412 if If-Match is stale, 409 if expected_version is stale or an attachment injects the key)
+func (c *Client) PostWorkerIdSecret(ctx context.Context, id string, req handlers.WorkerKeyValueRequest) (out []string, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/secret", nil, req, &out, "bearer")
+	return
+}
+
+// PutWorkerIdTags calls PUT /api/v1/worker/:id/tags: Replace the tags of a worker and sync them to its K8s labels (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutWorkerIdTags(ctx context.Context, id string, req handlers.SetTagsRequest) (out handlers.TagsResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/tags", nil, req, &out, "bearer")
+	return
+}
+
+// PutWorkerIdEnvironment calls PUT /api/v1/worker/:id/environment: Move a worker to another environment; its env re-syncs with the new environment's variables and the worker restarts (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutWorkerIdEnvironment(ctx context.Context, id string, req handlers.SetEnvironmentRequest) (out handlers.SetEnvironmentResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/environment", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdEgress calls GET /api/v1/worker/:id/egress: Get the outbound rules of a worker
+func (c *Client) GetWorkerIdEgress(ctx context.Context, id string) (out dblayer.EgressPolicy, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/egress", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdEgress calls PUT /api/v1/worker/:id/egress: Replace the outbound rules of a worker: allow, or deny all but the listed CIDRs and domains (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutWorkerIdEgress(ctx context.Context, id string, req handlers.SetEgressRequest) (out dblayer.EgressPolicy, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/egress", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdRoutes calls GET /api/v1/worker/:id/routes: Get the extra route rules of a worker
+func (c *Client) GetWorkerIdRoutes(ctx context.Context, id string) (out dblayer.WorkerRoutes, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/routes", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdRoutes calls PUT /api/v1/worker/:id/routes: Replace the extra route rules of a worker: hosts, path prefixes and headers to match, highest priority first (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutWorkerIdRoutes(ctx context.Context, id string, req handlers.SetRoutesRequest) (out dblayer.WorkerRoutes, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/routes", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdEdgeLimits calls GET /api/v1/worker/:id/edge-limits: Get the request rate limit and body size limit of a worker, 0 when unlimited
+func (c *Client) GetWorkerIdEdgeLimits(ctx context.Context, id string) (out dblayer.EdgeLimits, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/edge-limits", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdEdgeLimits calls PUT /api/v1/worker/:id/edge-limits: Replace the request rate limit (req/s and burst, shared by all clients) and body size limit enforced at the ingress (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutWorkerIdEdgeLimits(ctx context.Context, id string, req handlers.SetEdgeLimitsRequest) (out dblayer.EdgeLimits, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/edge-limits", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdErrorPages calls GET /api/v1/worker/:id/error-pages: Get the maintenance toggle and custom 502/503 pages of a worker
+func (c *Client) GetWorkerIdErrorPages(ctx context.Context, id string) (out dblayer.ErrorPages, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/error-pages", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdErrorPages calls PUT /api/v1/worker/:id/error-pages: Replace the maintenance toggle and custom 502/503 HTML pages of a worker, served by the ingress in place of its errors (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutWorkerIdErrorPages(ctx context.Context, id string, req handlers.SetErrorPagesRequest) (out dblayer.ErrorPages, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/error-pages", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdRequests calls GET /api/v1/worker/:id/requests: Requests the ingress routed to a worker with status, latency, path (without query) and client IP, newest first, kept 7 days (since: RFC3339 or a duration like 6h, default 24h; status: a class like 5xx or a status code)
+// (query: since, status, limit, cursor, sort, order)
+func (c *Client) GetWorkerIdRequests(ctx context.Context, id string, query url.Values) (out handlers.WorkerRequestsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/requests", query, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdRequestsHourly calls GET /api/v1/worker/:id/requests/hourly: Requests per hour of a worker by status class with average and maximum latency, kept 90 days (since: RFC3339 or a duration like 6h, default 24h)
+// (query: since)
+func (c *Client) GetWorkerIdRequestsHourly(ctx context.Context, id string, query url.Values) (out handlers.WorkerRequestHoursResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/requests/hourly", query, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdEnvGroups calls GET /api/v1/worker/:id/env-groups: Env groups attached to a worker, lowest precedence first, and the effective env after merging its environment's variables, the groups and its own env in that order (values masked)
+func (c *Client) GetWorkerIdEnvGroups(ctx context.Context, id string) (out handlers.WorkerEnvGroupsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/env-groups", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdEnvGroups calls PUT /api/v1/worker/:id/env-groups: Replace the env groups attached to a worker in precedence order (later groups override earlier ones, the worker env overrides all); the env re-syncs and the worker restarts (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutWorkerIdEnvGroups(ctx context.Context, id string, req handlers.SetWorkerEnvGroupsRequest) (out handlers.WorkerEnvGroupsResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/env-groups", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdAttachments calls GET /api/v1/worker/:id/attachments: Managed resources attached to a worker and the env names their connection strings are injected as
+func (c *Client) GetWorkerIdAttachments(ctx context.Context, id string) (out []*dblayer.WorkerAttachment, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/attachments", nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorkerIdAttachTypeRid calls POST /api/v1/worker/:id/attach/:type/:rid: Attach an rdb, kv, queue or bucket resource: its connection string is injected into the worker Secret as DATABASE_URL, REDIS_URL or AMQP_URL (env renames it) and kept up to date. A bucket's env is a prefix (default S3) for _ENDPOINT, _BUCKET, _REGION, _ACCESS_KEY_ID, _SECRET_ACCESS_KEY and _SESSION_TOKEN, with credentials limited to the bucket and rotated before they expire; 409 if a name is taken
+// (query: env)
+func (c *Client) PostWorkerIdAttachTypeRid(ctx context.Context, id string, type_ string, rid string, query url.Values) (out []*dblayer.WorkerAttachment, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/attach/"+url.PathEscape(type_)+"/"+url.PathEscape(rid), query, nil, &out, "bearer")
+	return
+}
+
+// DeleteWorkerIdAttachTypeRid calls DELETE /api/v1/worker/:id/attach/:type/:rid: Detach a resource and remove its env from the worker Secret
+func (c *Client) DeleteWorkerIdAttachTypeRid(ctx context.Context, id string, type_ string, rid string) (out []*dblayer.WorkerAttachment, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/worker/"+url.PathEscape(id)+"/attach/"+url.PathEscape(type_)+"/"+url.PathEscape(rid), nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdPreview calls GET /api/v1/worker/:id/preview: Pull request preview config of a worker and its open previews with their URLs; 404 when previews are not configured
+func (c *Client) GetWorkerIdPreview(ctx context.Context, id string) (out handlers.PreviewsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/preview", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdPreview calls PUT /api/v1/worker/:id/preview: Link a GitHub repository: each open pull request gets a copy of the worker deployed from image_template ({pr}, {sha}, {short_sha}, {branch}), with a fresh RDB as DATABASE_URL when with_db is set. 201 with the webhook secret on first setup, which is not returned again
+func (c *Client) PutWorkerIdPreview(ctx context.Context, id string, req handlers.SetPreviewConfigRequest) (out handlers.PreviewConfigResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/preview", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteWorkerIdPreview calls DELETE /api/v1/worker/:id/preview: Disable previews and delete every preview worker and RDB
+func (c *Client) DeleteWorkerIdPreview(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/worker/"+url.PathEscape(id)+"/preview", nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdGithub calls GET /api/v1/worker/:id/github: Repository and branch the worker auto-deploys from; 404 when unbound
+func (c *Client) GetWorkerIdGithub(ctx context.Context, id string) (out handlers.GitHubBindingResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/github", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdGithub calls PUT /api/v1/worker/:id/github: Bind a GitHub repository branch: a push to it (or, with workflow, a successful run of that workflow on it) deploys image_template ({sha}, {short_sha}, {branch}) on the current port and records the commit; with github_token the result is posted as a commit status. 201 with the webhook secret on first setup, which is not returned again
+func (c *Client) PutWorkerIdGithub(ctx context.Context, id string, req handlers.SetGitHubBindingRequest) (out handlers.GitHubBindingResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/github", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteWorkerIdGithub calls DELETE /api/v1/worker/:id/github: Unbind the worker from its repository
+func (c *Client) DeleteWorkerIdGithub(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/worker/"+url.PathEscape(id)+"/github", nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdLogsHistory calls GET /api/v1/worker/:id/logs/history: Query retained worker logs beyond pod lifetime, newest first (since/until: RFC3339 or a duration like 6h; query: substring filter)
+// (query: since, until, query, limit)
+func (c *Client) GetWorkerIdLogsHistory(ctx context.Context, id string, query url.Values) (out handlers.WorkerLogHistoryResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/logs/history", query, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdStatus calls GET /api/v1/worker/:id/status: Runtime status of a worker (replicas, pods, routing and the latest controller events) served from the control plane's informer cache
+func (c *Client) GetWorkerIdStatus(ctx context.Context, id string) (out controller.WorkerRuntimeStatus, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/status", nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorkerIdUnpause calls POST /api/v1/worker/:id/unpause: Resume a free-tier worker the idle reaper paused for lack of traffic and restart its idle timer (409 if it is not paused for inactivity)
+func (c *Client) PostWorkerIdUnpause(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/unpause", nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdVersions calls GET /api/v1/worker/:id/versions: Deploy history of a worker with image, port, commit, initiator (user, github, preview, import, apply) and status, newest first; filter with status
+// (query: limit, cursor, sort, order, status)
+func (c *Client) GetWorkerIdVersions(ctx context.Context, id string, query url.Values) (out handlers.DeployVersionsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/versions", query, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdVersionsADiffB calls GET /api/v1/worker/:id/versions/:a/diff/:b: What changed from version a to version b: image, port, commit, env keys (values are compared by digest and never returned), secret keys and resources, from the snapshot taken at deploy time. partial when a version predates snapshots
+func (c *Client) GetWorkerIdVersionsADiffB(ctx context.Context, id string, a string, b string) (out handlers.DeployDiffResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/versions/"+url.PathEscape(a)+"/diff/"+url.PathEscape(b), nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorkerIdRestart calls POST /api/v1/worker/:id/restart: Rolling restart of all pods of a worker; the reason is kept in the restart audit log and the returned restart id is the handle to poll for progress (202, 409 if the worker is paused)
+func (c *Client) PostWorkerIdRestart(ctx context.Context, id string, req handlers.RestartWorkerRequest) (out handlers.WorkerRestartResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/restart", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdRestarts calls GET /api/v1/worker/:id/restarts: Restart audit log of a worker: who asked, when, why and the outcome, newest first
+func (c *Client) GetWorkerIdRestarts(ctx context.Context, id string) (out handlers.WorkerRestartsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/restarts", nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdRestartsRid calls GET /api/v1/worker/:id/restarts/:rid: One restart and the progress of its rollout; complete once all replicas run the restarted template
+func (c *Client) GetWorkerIdRestartsRid(ctx context.Context, id string, rid string) (out handlers.WorkerRestartResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/restarts/"+url.PathEscape(rid), nil, nil, &out, "bearer")
+	return
+}
+
+// PatchWorkerIdResources calls PATCH /api/v1/worker/:id/resources: Change CPU, memory or disk of a worker within the plan limits; the running pods roll without a new deploy version (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PatchWorkerIdResources(ctx context.Context, id string, req handlers.ResizeWorkerRequest) (out handlers.ResizeWorkerResponse, err error) {
+	err = c.call(ctx, "PATCH", "/api/v1/worker/"+url.PathEscape(id)+"/resources", nil, req, &out, "bearer")
+	return
+}
+
+// GetWorkerIdResourcesChanges calls GET /api/v1/worker/:id/resources/changes: Resource changes of a worker and whether they reached the cluster, newest first
+func (c *Client) GetWorkerIdResourcesChanges(ctx context.Context, id string) (out handlers.WorkerResourceChangesResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/resources/changes", nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdRemediation calls GET /api/v1/worker/:id/remediation: Automatic remediation policy of a worker; defaults (all off) when none is set
+func (c *Client) GetWorkerIdRemediation(ctx context.Context, id string) (out dblayer.WorkerRemediationPolicy, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/remediation", nil, nil, &out, "bearer")
+	return
+}
+
+// PutWorkerIdRemediation calls PUT /api/v1/worker/:id/remediation: Replace the remediation policy: on repeated OOM kills notify with a suggested resize or bump memory within the plan, on crash looping notify or pause the worker
+func (c *Client) PutWorkerIdRemediation(ctx context.Context, id string, req handlers.SetRemediationPolicyRequest) (out dblayer.WorkerRemediationPolicy, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/worker/"+url.PathEscape(id)+"/remediation", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteWorkerIdRemediation calls DELETE /api/v1/worker/:id/remediation: Turn automatic remediation off; the history is kept
+func (c *Client) DeleteWorkerIdRemediation(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/worker/"+url.PathEscape(id)+"/remediation", nil, nil, &out, "bearer")
+	return
+}
+
+// GetWorkerIdRemediations calls GET /api/v1/worker/:id/remediations: What remediation did or suggested for a worker, newest first
+func (c *Client) GetWorkerIdRemediations(ctx context.Context, id string) (out handlers.WorkerRemediationsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/worker/"+url.PathEscape(id)+"/remediations", nil, nil, &out, "bearer")
+	return
+}
+
+// PostWorkerIdRemediationsRidApply calls POST /api/v1/worker/:id/remediations/:rid/apply: Apply a suggested resize and sync it to the cluster (409 if already applied or the worker memory changed since)
+func (c *Client) PostWorkerIdRemediationsRidApply(ctx context.Context, id string, rid string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/"+url.PathEscape(id)+"/remediations/"+url.PathEscape(rid)+"/apply", nil, nil, &out, "bearer")
+	return
+}
+
+// GetAlertsRules calls GET /api/v1/alerts/rules: List alert rules (filter with worker_id, status=ok|firing)
+// (query: worker_id, limit, cursor, sort, order, status)
+func (c *Client) GetAlertsRules(ctx context.Context, query url.Values) (out handlers.ListAlertRulesResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/alerts/rules", query, nil, &out, "bearer")
+	return
+}
+
+// PostAlertsRules calls POST /api/v1/alerts/rules: Create an alert rule on a worker (restarts, oom_kills, crash_loop, http_5xx_rate in %, p95_latency in seconds); evaluated every minute
+func (c *Client) PostAlertsRules(ctx context.Context, req handlers.AlertRuleRequest) (out dblayer.AlertRule, err error) {
+	err = c.call(ctx, "POST", "/api/v1/alerts/rules", nil, req, &out, "bearer")
+	return
+}
+
+// GetAlertsRulesId calls GET /api/v1/alerts/rules/:id: Get an alert rule
+func (c *Client) GetAlertsRulesId(ctx context.Context, id string) (out dblayer.AlertRule, err error) {
+	err = c.call(ctx, "GET", "/api/v1/alerts/rules/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PutAlertsRulesId calls PUT /api/v1/alerts/rules/:id: Replace an alert rule
+func (c *Client) PutAlertsRulesId(ctx context.Context, id string, req handlers.AlertRuleRequest) (out dblayer.AlertRule, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/alerts/rules/"+url.PathEscape(id), nil, req, &out, "bearer")
+	return
+}
+
+// DeleteAlertsRulesId calls DELETE /api/v1/alerts/rules/:id: Delete an alert rule (its history is kept)
+func (c *Client) DeleteAlertsRulesId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/alerts/rules/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// GetAlertsHistory calls GET /api/v1/alerts/history: List alert history: one entry per firing/resolved transition, also pushed as worker.alert events
+// (query: rule_id, worker_id, limit, cursor, sort, order, status)
+func (c *Client) GetAlertsHistory(ctx context.Context, query url.Values) (out handlers.ListAlertEventsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/alerts/history", query, nil, &out, "bearer")
+	return
+}
+
+// GetUptimeChecks calls GET /api/v1/uptime/checks: List uptime checks (filter with status=up|down|unknown)
+// (query: limit, cursor, sort, order, status)
+func (c *Client) GetUptimeChecks(ctx context.Context, query url.Values) (out handlers.ListUptimeChecksResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/uptime/checks", query, nil, &out, "bearer")
+	return
+}
+
+// PostUptimeChecks calls POST /api/v1/uptime/checks: Create an HTTP uptime check on a worker or a verified custom domain
+func (c *Client) PostUptimeChecks(ctx context.Context, req handlers.UptimeCheckRequest) (out dblayer.UptimeCheck, err error) {
+	err = c.call(ctx, "POST", "/api/v1/uptime/checks", nil, req, &out, "bearer")
+	return
+}
+
+// GetUptimeChecksId calls GET /api/v1/uptime/checks/:id: Get an uptime check with 24h and 7d uptime
+func (c *Client) GetUptimeChecksId(ctx context.Context, id string) (out handlers.UptimeCheckDetailResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/uptime/checks/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PutUptimeChecksId calls PUT /api/v1/uptime/checks/:id: Replace the settings of an uptime check (target and path are fixed)
+func (c *Client) PutUptimeChecksId(ctx context.Context, id string, req handlers.UpdateUptimeCheckRequest) (out dblayer.UptimeCheck, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/uptime/checks/"+url.PathEscape(id), nil, req, &out, "bearer")
+	return
+}
+
+// DeleteUptimeChecksId calls DELETE /api/v1/uptime/checks/:id: Delete an uptime check with its results and incidents
+func (c *Client) DeleteUptimeChecksId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/uptime/checks/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// GetUptimeChecksIdResults calls GET /api/v1/uptime/checks/:id/results: Probe results and response times, newest first (since: RFC3339 or a duration like 6h)
+// (query: since, limit)
+func (c *Client) GetUptimeChecksIdResults(ctx context.Context, id string, query url.Values) (out handlers.UptimeResultsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/uptime/checks/"+url.PathEscape(id)+"/results", query, nil, &out, "bearer")
+	return
+}
+
+// GetUptimeIncidents calls GET /api/v1/uptime/incidents: List downtime incidents (filter with check_id, status=open|resolved)
+// (query: check_id, limit, cursor, sort, order, status)
+func (c *Client) GetUptimeIncidents(ctx context.Context, query url.Values) (out handlers.ListUptimeIncidentsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/uptime/incidents", query, nil, &out, "bearer")
+	return
+}
+
+// GetUptimeStatusPage calls GET /api/v1/uptime/status-page: Get the status page settings
+func (c *Client) GetUptimeStatusPage(ctx context.Context) (out dblayer.StatusPage, err error) {
+	err = c.call(ctx, "GET", "/api/v1/uptime/status-page", nil, nil, &out, "bearer")
+	return
+}
+
+// PutUptimeStatusPage calls PUT /api/v1/uptime/status-page: Create or update the public status page
+func (c *Client) PutUptimeStatusPage(ctx context.Context, req handlers.StatusPageRequest) (out dblayer.StatusPage, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/uptime/status-page", nil, req, &out, "bearer")
+	return
+}
+
+// GetStatusSlug calls GET /api/v1/status/:slug: Public status page JSON listing the public uptime checks (no auth, CORS open)
+func (c *Client) GetStatusSlug(ctx context.Context, slug string) (out handlers.PublicStatusPageResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/status/"+url.PathEscape(slug), nil, nil, &out, "")
+	return
+}
+
+// PostWorkerEstimate calls POST /api/v1/worker/estimate: Projected monthly cost of a worker spec on the price sheet (unset resources use the deploy defaults, 400 for an unpriced region)
+func (c *Client) PostWorkerEstimate(ctx context.Context, req handlers.EstimateWorkerRequest) (out billing.CostEstimate, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/estimate", nil, req, &out, "bearer")
+	return
+}
+
+// PostWorkerBulk calls POST /api/v1/worker/bulk: Restart, pause, resume, set env on or delete many workers at once (402 on resume while workers are suspended for an overdue payment; workers paused by the spending cap are not resumed; body up to 8 MiB)
+func (c *Client) PostWorkerBulk(ctx context.Context, req handlers.BulkWorkerRequest) (out handlers.BulkWorkerResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/bulk", nil, req, &out, "bearer")
+	return
+}
+
+// PostWorkerDeploy calls POST /api/v1/worker/deploy: Deploy a new worker version with its projected monthly cost, 422 with the policy, dry-run and capacity violations when refused, 402 while workers are suspended for an overdue payment, 409 for static workers
+func (c *Client) PostWorkerDeploy(ctx context.Context, req handlers.DeployWorkerRequest) (out handlers.DeployWorkerResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/worker/deploy", nil, req, &out, "signature")
+	return
+}
+
+// GetDomain calls GET /api/v1/domain: List custom domains
+// (query: limit, cursor, sort, order, status, type, tag, environment)
+func (c *Client) GetDomain(ctx context.Context, query url.Values) (out handlers.ListCustomDomainsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/domain", query, nil, &out, "bearer")
+	return
+}
+
+// GetDomainId calls GET /api/v1/domain/:id: Get a custom domain (sets ETag/Last-Modified, honours If-None-Match)
+func (c *Client) GetDomainId(ctx context.Context, id string) (out k8s.CustomDomain, err error) {
+	err = c.call(ctx, "GET", "/api/v1/domain/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PostDomain calls POST /api/v1/domain: Add a custom domain and start verification (403 above the custom domain count of the plan). Once DNS verifies, a domain pointing to a worker is routed only when the worker has ready replicas (up to domain.ready_timeout); force routes it right away
+func (c *Client) PostDomain(ctx context.Context, req handlers.AddCustomDomainRequest) (out handlers.AddCustomDomainResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/domain", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteDomainId calls DELETE /api/v1/domain/:id: Delete a custom domain (412 if If-Match is stale)
+func (c *Client) DeleteDomainId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/domain/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PutDomainIdTags calls PUT /api/v1/domain/:id/tags: Replace the tags of a custom domain and sync them to its K8s labels (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutDomainIdTags(ctx context.Context, id string, req handlers.SetTagsRequest) (out handlers.TagsResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/domain/"+url.PathEscape(id)+"/tags", nil, req, &out, "bearer")
+	return
+}
+
+// PutDomainIdEnvironment calls PUT /api/v1/domain/:id/environment: Move a custom domain to another environment (412 if If-Match is stale, 409 if expected_version is stale)
+func (c *Client) PutDomainIdEnvironment(ctx context.Context, id string, req handlers.SetEnvironmentRequest) (out handlers.SetEnvironmentResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/domain/"+url.PathEscape(id)+"/environment", nil, req, &out, "bearer")
+	return
+}
+
+// GetUsage calls GET /api/v1/usage: Metered usage per metric and per resource for a period (YYYY-MM, the default current month, or YYYY-MM-DD, UTC)
+// (query: period)
+func (c *Client) GetUsage(ctx context.Context, query url.Values) (out handlers.UsageResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/usage", query, nil, &out, "bearer")
+	return
+}
+
+// GetBillingSubscription calls GET /api/v1/billing/subscription: Current Stripe subscription, plan and dunning state (past_due_since, workers_suspended)
+func (c *Client) GetBillingSubscription(ctx context.Context) (out dblayer.Subscription, err error) {
+	err = c.call(ctx, "GET", "/api/v1/billing/subscription", nil, nil, &out, "bearer")
+	return
+}
+
+// PostBillingCheckout calls POST /api/v1/billing/checkout: Create a Stripe Checkout session for a plan (503 when billing is not configured); redirect the user to url
+func (c *Client) PostBillingCheckout(ctx context.Context, req handlers.CheckoutRequest) (out handlers.CheckoutResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/billing/checkout", nil, req, &out, "bearer")
+	return
+}
+
+// PostBillingWebhook calls POST /api/v1/billing/webhook: Stripe webhook (Stripe-Signature required): subscription changes sync the plan, failed payments pause workers after the grace period
+func (c *Client) PostBillingWebhook(ctx context.Context) (out map[string]bool, err error) {
+	err = c.call(ctx, "POST", "/api/v1/billing/webhook", nil, nil, &out, "")
+	return
+}
+
+// PostPreviewGithubId calls POST /api/v1/preview/github/:id: GitHub webhook for a worker's previews (X-Hub-Signature-256 required): pull_request opened/reopened/synchronize deploys the preview, closed deletes it; other events are acknowledged
+func (c *Client) PostPreviewGithubId(ctx context.Context, id string) (out handlers.PreviewEnvironmentResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/preview/github/"+url.PathEscape(id), nil, nil, &out, "")
+	return
+}
+
+// PostWebhooksGithub calls POST /api/v1/webhooks/github: GitHub push and workflow_run webhook shared by all bindings (X-Hub-Signature-256 checked against each binding of the repository, 401 if none matches); deploys every matching worker and reports each result
+func (c *Client) PostWebhooksGithub(ctx context.Context) (out handlers.GitHubWebhookResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/webhooks/github", nil, nil, &out, "")
+	return
+}
+
+// GetSpendCap calls GET /api/v1/spend-cap: Monthly spending cap with the cost accrued this month (UTC) and whether it is enforced or overridden; 404 when unset
+func (c *Client) GetSpendCap(ctx context.Context) (out handlers.SpendCapResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/spend-cap", nil, nil, &out, "bearer")
+	return
+}
+
+// PutSpendCap calls PUT /api/v1/spend-cap: Set the monthly spending cap; once metered cost reaches it, workers not tagged essential=true are paused and the user is notified
+func (c *Client) PutSpendCap(ctx context.Context, req handlers.SetSpendCapRequest) (out handlers.SpendCapResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/spend-cap", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteSpendCap calls DELETE /api/v1/spend-cap: Remove the spending cap and resume the workers it paused
+func (c *Client) DeleteSpendCap(ctx context.Context) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/spend-cap", nil, nil, &out, "bearer")
+	return
+}
+
+// PostSpendCapOverride calls POST /api/v1/spend-cap/override: Stop enforcing the cap for the rest of this month and resume the workers it paused
+func (c *Client) PostSpendCapOverride(ctx context.Context) (out handlers.SpendCapResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/spend-cap/override", nil, nil, &out, "bearer")
+	return
+}
+
+// GetSpendCapActions calls GET /api/v1/spend-cap/actions: Audit of spending cap changes and enforcement (suspend/resume with the affected workers), newest first
+// (query: limit)
+func (c *Client) GetSpendCapActions(ctx context.Context, query url.Values) (out handlers.SpendCapActionsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/spend-cap/actions", query, nil, &out, "bearer")
+	return
+}
+
+// GetSecrets calls GET /api/v1/secrets: Metadata of the user's application secrets; values are never returned
+func (c *Client) GetSecrets(ctx context.Context) (out handlers.SecretsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/secrets", nil, nil, &out, "bearer")
+	return
+}
+
+// PostSecrets calls POST /api/v1/secrets: Create a secret; the value is sealed with the platform key as version 1. Reference it from worker env as secretref://name; 503 when sealing is not configured
+func (c *Client) PostSecrets(ctx context.Context, req handlers.CreateSecretRequest) (out handlers.SecretResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/secrets", nil, req, &out, "bearer")
+	return
+}
+
+// GetSecretsName calls GET /api/v1/secrets/:name: Secret metadata, its versions and the workers referencing it
+func (c *Client) GetSecretsName(ctx context.Context, name string) (out handlers.SecretResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/secrets/"+url.PathEscape(name), nil, nil, &out, "bearer")
+	return
+}
+
+// DeleteSecretsName calls DELETE /api/v1/secrets/:name: Delete a secret and all its versions; 409 while a worker env still references it
+func (c *Client) DeleteSecretsName(ctx context.Context, name string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/secrets/"+url.PathEscape(name), nil, nil, &out, "bearer")
+	return
+}
+
+// PostSecretsNameRotate calls POST /api/v1/secrets/:name/rotate: Store a new version and make it current; referencing workers re-sync their env and restart
+func (c *Client) PostSecretsNameRotate(ctx context.Context, name string, req handlers.RotateSecretRequest) (out handlers.SecretResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/secrets/"+url.PathEscape(name)+"/rotate", nil, req, &out, "bearer")
+	return
+}
+
+// GetSecretsNameAudit calls GET /api/v1/secrets/:name/audit: Access audit of a secret (create, rotate, delete and resolve by a worker), newest first, kept after deletion
+// (query: limit)
+func (c *Client) GetSecretsNameAudit(ctx context.Context, name string, query url.Values) (out handlers.SecretAccessResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/secrets/"+url.PathEscape(name)+"/audit", query, nil, &out, "bearer")
+	return
+}
+
+// GetEnvGroups calls GET /api/v1/env-groups: The user's env groups: named sets of variables shared by several workers (values masked)
+func (c *Client) GetEnvGroups(ctx context.Context) (out handlers.EnvGroupsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/env-groups", nil, nil, &out, "bearer")
+	return
+}
+
+// PostEnvGroups calls POST /api/v1/env-groups: Create an env group; values may be secretref://name. 409 if the name is taken
+func (c *Client) PostEnvGroups(ctx context.Context, req handlers.CreateEnvGroupRequest) (out handlers.EnvGroupResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/env-groups", nil, req, &out, "bearer")
+	return
+}
+
+// GetEnvGroupsName calls GET /api/v1/env-groups/:name: An env group (values masked) and the workers it is attached to
+func (c *Client) GetEnvGroupsName(ctx context.Context, name string) (out handlers.EnvGroupResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/env-groups/"+url.PathEscape(name), nil, nil, &out, "bearer")
+	return
+}
+
+// PutEnvGroupsName calls PUT /api/v1/env-groups/:name: Replace the variables of an env group; attached workers re-sync their env and restart (409 if expected_version is stale)
+func (c *Client) PutEnvGroupsName(ctx context.Context, name string, req handlers.SetEnvGroupVarsRequest) (out handlers.EnvGroupResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/env-groups/"+url.PathEscape(name), nil, req, &out, "bearer")
+	return
+}
+
+// DeleteEnvGroupsName calls DELETE /api/v1/env-groups/:name: Delete an env group; it is detached from its workers, which re-sync their env
+func (c *Client) DeleteEnvGroupsName(ctx context.Context, name string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/env-groups/"+url.PathEscape(name), nil, nil, &out, "bearer")
+	return
+}
+
+// GetMail calls GET /api/v1/mail: SMTP relay host and port (enabled is false when the platform has no relay) and the daily quota: recipients accepted in the last 24 hours against max_emails_per_day
+func (c *Client) GetMail(ctx context.Context) (out handlers.MailOverviewResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/mail", nil, nil, &out, "bearer")
+	return
+}
+
+// GetMailDomains calls GET /api/v1/mail/domains: The user's mail domains with the DNS records each needs (ownership TXT, SPF, DKIM) and whether they were found
+func (c *Client) GetMailDomains(ctx context.Context) (out handlers.ListMailDomainsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/mail/domains", nil, nil, &out, "bearer")
+	return
+}
+
+// PostMailDomains calls POST /api/v1/mail/domains: Add a sending domain and generate its DKIM key; the ownership TXT record of a custom domain with the same name is reused. Mail from it is refused until all three records verify. 409 if added already, 403 above 20 domains, 503 without a relay
+func (c *Client) PostMailDomains(ctx context.Context, req handlers.AddMailDomainRequest) (out handlers.MailDomainResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/mail/domains", nil, req, &out, "bearer")
+	return
+}
+
+// GetMailDomainsId calls GET /api/v1/mail/domains/:id: A mail domain and its DNS records
+func (c *Client) GetMailDomainsId(ctx context.Context, id string) (out handlers.MailDomainResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/mail/domains/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PostMailDomainsIdVerify calls POST /api/v1/mail/domains/:id/verify: Look the DNS records of a mail domain up now instead of waiting for the periodic check (every 15 minutes)
+func (c *Client) PostMailDomainsIdVerify(ctx context.Context, id string) (out handlers.MailDomainResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/mail/domains/"+url.PathEscape(id)+"/verify", nil, nil, &out, "bearer")
+	return
+}
+
+// DeleteMailDomainsId calls DELETE /api/v1/mail/domains/:id: Delete a mail domain; mail from it is refused from then on
+func (c *Client) DeleteMailDomainsId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/mail/domains/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// GetMailCredentials calls GET /api/v1/mail/credentials: The user's SMTP credentials (without passwords)
+func (c *Client) GetMailCredentials(ctx context.Context) (out handlers.ListMailCredentialsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/mail/credentials", nil, nil, &out, "bearer")
+	return
+}
+
+// PostMailCredentials calls POST /api/v1/mail/credentials: Create SMTP credentials for the relay; the password is only returned here. 409 if the name is taken, 403 above 20 credentials
+func (c *Client) PostMailCredentials(ctx context.Context, req handlers.CreateMailCredentialRequest) (out handlers.CreateMailCredentialResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/mail/credentials", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteMailCredentialsId calls DELETE /api/v1/mail/credentials/:id: Delete SMTP credentials; the relay refuses them right away
+func (c *Client) DeleteMailCredentialsId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/mail/credentials/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// GetMailSuppressions calls GET /api/v1/mail/suppressions: List suppressed recipients (filter with reason=bounce|complaint|manual). Permanent bounces and complaints are added automatically; mail to them is rejected
+// (query: reason, limit, cursor, sort, order)
+func (c *Client) GetMailSuppressions(ctx context.Context, query url.Values) (out handlers.ListMailSuppressionsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/mail/suppressions", query, nil, &out, "bearer")
+	return
+}
+
+// PostMailSuppressions calls POST /api/v1/mail/suppressions: Suppress an address by hand; an address already suppressed keeps its reason
+func (c *Client) PostMailSuppressions(ctx context.Context, req handlers.AddMailSuppressionRequest) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/mail/suppressions", nil, req, &out, "bearer")
+	return
+}
+
+// DeleteMailSuppressionsAddress calls DELETE /api/v1/mail/suppressions/:address: Remove an address from the suppression list so mail to it is accepted again
+func (c *Client) DeleteMailSuppressionsAddress(ctx context.Context, address string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/mail/suppressions/"+url.PathEscape(address), nil, nil, &out, "bearer")
+	return
+}
+
+// GetMailLogs calls GET /api/v1/mail/logs: Send log, one entry per recipient (status queued, delivered, deferred, bounced, complained or rejected), kept 30 days; filter with status and recipient
+// (query: recipient, limit, cursor, sort, order, status)
+func (c *Client) GetMailLogs(ctx context.Context, query url.Values) (out handlers.ListMailMessagesResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/mail/logs", query, nil, &out, "bearer")
+	return
+}
+
+// GetEnvironments calls GET /api/v1/environments: The user's environments: every name used by a worker, RDB/KV or custom domain or given variables, with resource counts and variables (values masked)
+func (c *Client) GetEnvironments(ctx context.Context) (out handlers.EnvironmentsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/environments", nil, nil, &out, "bearer")
+	return
+}
+
+// GetEnvironmentsName calls GET /api/v1/environments/:name: An environment (values masked) and its workers
+func (c *Client) GetEnvironmentsName(ctx context.Context, name string) (out handlers.EnvironmentResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/environments/"+url.PathEscape(name), nil, nil, &out, "bearer")
+	return
+}
+
+// PutEnvironmentsName calls PUT /api/v1/environments/:name: Replace the variables of an environment, merged under env groups and each worker's own env; values may be secretref://name. Its workers re-sync their env and restart (409 if expected_version is stale)
+func (c *Client) PutEnvironmentsName(ctx context.Context, name string, req handlers.SetEnvironmentVarsRequest) (out handlers.EnvironmentResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/environments/"+url.PathEscape(name), nil, req, &out, "bearer")
+	return
+}
+
+// DeleteEnvironmentsName calls DELETE /api/v1/environments/:name: Delete the variables of an environment; 409 while workers, RDB/KV resources or domains are still in it
+func (c *Client) DeleteEnvironmentsName(ctx context.Context, name string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/environments/"+url.PathEscape(name), nil, nil, &out, "bearer")
+	return
+}
+
+// GetSecurityEvents calls GET /api/v1/security/events: List sign-in security events of the account (login_success, login_failed, step_up_required, step_up_failed, throttled, register, device_forgotten, secret_revealed) with their risk score and reasons
+// (query: limit, cursor, sort, order, event)
+func (c *Client) GetSecurityEvents(ctx context.Context, query url.Values) (out handlers.ListAuthEventsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/security/events", query, nil, &out, "bearer")
+	return
+}
+
+// GetSecurityDevices calls GET /api/v1/security/devices: List the devices the account signed in from
+func (c *Client) GetSecurityDevices(ctx context.Context) (out []dblayer.UserDevice, err error) {
+	err = c.call(ctx, "GET", "/api/v1/security/devices", nil, nil, &out, "bearer")
+	return
+}
+
+// DeleteSecurityDevicesId calls DELETE /api/v1/security/devices/:id: Forget a device; the next sign-in from it counts as a new device
+func (c *Client) DeleteSecurityDevicesId(ctx context.Context, id string) (out handlers.MessageResponse, err error) {
+	err = c.call(ctx, "DELETE", "/api/v1/security/devices/"+url.PathEscape(id), nil, nil, &out, "bearer")
+	return
+}
+
+// PostAccountSecretKeyReveal calls POST /api/v1/account/secret-key/reveal: Reveal the account secret key after re-entering the password; recorded as a secret_revealed security event, wrong passwords count as failed sign-ins
+func (c *Client) PostAccountSecretKeyReveal(ctx context.Context, req handlers.RevealSecretKeyRequest) (out handlers.RevealSecretKeyResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/account/secret-key/reveal", nil, req, &out, "bearer")
+	return
+}
+
+// GetAccount calls GET /api/v1/account: Account profile (display name, default region, timezone, mail language) and the email/webhook switches of every notification kind
+func (c *Client) GetAccount(ctx context.Context) (out handlers.AccountResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/account", nil, nil, &out, "bearer")
+	return
+}
+
+// PatchAccount calls PATCH /api/v1/account: Update the fields present in the body; notifications turns email or webhook delivery of a notification kind on or off, the notification center always gets it. default_region applies to new workers without main_region or cluster, timezone to new scheduled SQL tasks without one
+func (c *Client) PatchAccount(ctx context.Context, req handlers.UpdateAccountRequest) (out handlers.AccountResponse, err error) {
+	err = c.call(ctx, "PATCH", "/api/v1/account", nil, req, &out, "bearer")
+	return
+}
+
+// GetAccountPreferences calls GET /api/v1/account/preferences: Account preferences: the language of outbound mail and the supported languages
+func (c *Client) GetAccountPreferences(ctx context.Context) (out handlers.PreferencesResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/account/preferences", nil, nil, &out, "bearer")
+	return
+}
+
+// PutAccountPreferences calls PUT /api/v1/account/preferences: Set the language (en, zh) every mail to the account is written in
+func (c *Client) PutAccountPreferences(ctx context.Context, req handlers.SetPreferencesRequest) (out handlers.PreferencesResponse, err error) {
+	err = c.call(ctx, "PUT", "/api/v1/account/preferences", nil, req, &out, "bearer")
+	return
+}
+
+// GetNotifications calls GET /api/v1/notifications: List in-app notifications (deploy finished or failed, domain verified or failed, certificate expiring, quota warnings, alerts), newest first; unread=true|false filters, unread in the response counts all unread
+// (query: limit, cursor, sort, order, unread)
+func (c *Client) GetNotifications(ctx context.Context, query url.Values) (out handlers.ListNotificationsResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/notifications", query, nil, &out, "bearer")
+	return
+}
+
+// PostNotificationsRead calls POST /api/v1/notifications/read: Mark notifications read by id; an empty ids list marks all of them read
+func (c *Client) PostNotificationsRead(ctx context.Context, req handlers.MarkNotificationsReadRequest) (out handlers.MarkNotificationsReadResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/notifications/read", nil, req, &out, "bearer")
+	return
+}
+
+// GetFeatures calls GET /api/v1/features: Feature flags evaluated for the current user and the maintenance mode status; in maintenance mode mutating requests return 503 with maintenance=true
+func (c *Client) GetFeatures(ctx context.Context) (out handlers.FeaturesResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/features", nil, nil, &out, "bearer")
+	return
+}
+
+// GetSearch calls GET /api/v1/search: Search workers, domains, RDB/KV resources and deploy versions (type is a comma separated filter)
+// (query: q, type, limit)
+func (c *Client) GetSearch(ctx context.Context, query url.Values) (out handlers.SearchResponse, err error) {
+	err = c.call(ctx, "GET", "/api/v1/search", query, nil, &out, "bearer")
+	return
+}
+
+// GetExport calls GET /api/v1/export: Export all resources as a declarative document (application/yaml, or JSON with format=json)
+// (query: format)
+func (c *Client) GetExport(ctx context.Context, query url.Values) (out handlers.ConfigDocument, err error) {
+	err = c.call(ctx, "GET", "/api/v1/export", query, nil, &out, "bearer")
+	return
+}
+
+// PostImport calls POST /api/v1/import: Diff a declarative document (YAML or JSON, up to 8 MiB) against current resources and apply it idempotently
+// (query: dry_run, prune)
+func (c *Client) PostImport(ctx context.Context, req handlers.ConfigDocument, query url.Values) (out handlers.ImportConfigResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/import", query, req, &out, "bearer")
+	return
+}
+
+// PostApply calls POST /api/v1/apply: Apply a console.yaml worker manifest (YAML or JSON): create or update the worker, set its env, attach RDB/KV by name (created if missing), add domains routed to it and deploy the image; idempotent. prune removes env, attachments and domains of the worker missing from the manifest
+// (query: dry_run, prune)
+func (c *Client) PostApply(ctx context.Context, req handlers.WorkerManifest, query url.Values) (out handlers.ApplyManifestResponse, err error) {
+	err = c.call(ctx, "POST", "/api/v1/apply", query, req, &out, "bearer")
+	return
+}
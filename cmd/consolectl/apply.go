@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"jabberwocky238/console/handlers"
+
+	"sigs.k8s.io/yaml"
+)
+
+// apply sends console.yaml to POST /apply. A manifest with build and no
+// image is built and pushed with the local docker first; the tag is the git
+// commit of the build context (with a timestamp when the tree is dirty).
+func (c *cli) apply(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	file := fs.String("f", "console.yaml", "Manifest file")
+	dryRun := fs.Bool("dry-run", false, "Only show the changes (skips the image build)")
+	prune := fs.Bool("prune", false, "Also remove env, attachments and domains of the worker that are not in the manifest")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var m handlers.WorkerManifest
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+
+	if m.Image == "" && m.Build == nil {
+		return errNoManifestImage
+	}
+	if m.Image == "" {
+		if *dryRun {
+			m.Image = m.Build.Image + ":dry-run"
+		} else if m.Image, err = build(ctx, filepath.Dir(*file), m.Build); err != nil {
+			return err
+		}
+	}
+
+	query := url.Values{}
+	if *dryRun {
+		query.Set("dry_run", "true")
+	}
+	if *prune {
+		query.Set("prune", "true")
+	}
+	resp, err := c.client.PostApply(ctx, m, query)
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w *tabwriter.Writer) {
+		if len(resp.Changes) == 0 {
+			fmt.Fprintln(w, "no changes")
+		} else {
+			fmt.Fprintln(w, "ACTION\tTYPE\tNAME\tDETAIL\tRESULT")
+		}
+		for _, ch := range resp.Changes {
+			result := "ok"
+			switch {
+			case ch.Error != "":
+				result = "error: " + ch.Error
+			case resp.DryRun:
+				result = "planned"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ch.Action, ch.Type, ch.Name, ch.Detail, result)
+		}
+		for _, warning := range resp.Warnings {
+			fmt.Fprintln(w, "warning:", warning)
+		}
+		if resp.URL != "" {
+			fmt.Fprintf(w, "\nworker %s: %s\n", resp.WorkerID, resp.URL)
+		}
+	})
+}
+
+// build runs docker build and docker push and returns the pushed image
+func build(ctx context.Context, dir string, b *handlers.ManifestBuild) (string, error) {
+	contextDir := filepath.Join(dir, b.Context)
+	dockerfile := b.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	image := b.Image + ":" + buildTag(ctx, contextDir)
+
+	for _, args := range [][]string{
+		{"build", "-t", image, "-f", filepath.Join(contextDir, dockerfile), contextDir},
+		{"push", image},
+	} {
+		cmd := exec.CommandContext(ctx, "docker", args...)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("docker %s: %w", args[0], err)
+		}
+	}
+	return image, nil
+}
+
+func buildTag(ctx context.Context, dir string) string {
+	stamp := time.Now().UTC().Format("20060102150405")
+	git := func(args ...string) (string, error) {
+		out, err := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...).Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	sha, err := git("rev-parse", "--short=12", "HEAD")
+	if err != nil {
+		return stamp
+	}
+	if status, err := git("status", "--porcelain"); err != nil || status != "" {
+		return sha + "-dirty-" + stamp
+	}
+	return sha
+}
+
+var errNoManifestImage = errors.New("the manifest needs image or build")
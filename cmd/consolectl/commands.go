@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers"
+	"jabberwocky238/console/k8s"
+)
+
+func (c *cli) workerList(ctx context.Context, args []string) error {
+	if _, err := parse(flag.NewFlagSet("worker list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	workers, err := c.client.GetWorker(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return c.print(workers, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tSTATUS\tVERSION\tURL")
+		for _, wk := range workers {
+			version := "-"
+			if wk.ActiveVersionID != nil {
+				version = fmt.Sprint(*wk.ActiveVersionID)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", wk.WorkerID, wk.WorkerName, wk.Status, version, wk.URL)
+		}
+	})
+}
+
+func (c *cli) workerGet(ctx context.Context, args []string) error {
+	rest, err := parse(flag.NewFlagSet("worker get", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	detail, err := c.client.GetWorkerId(ctx, rest[0], nil)
+	if err != nil {
+		return err
+	}
+	return c.print(detail, func(w *tabwriter.Writer) {
+		wk := detail.Worker
+		fmt.Fprintf(w, "ID\t%s\nNAME\t%s\nSTATUS\t%s\nURL\t%s\nRESOURCES\tcpu=%s memory=%s disk=%s replicas=%d\n\n",
+			wk.WID, wk.WorkerName, wk.Status, detail.URL, wk.AssignedCPU, wk.AssignedMemory, wk.AssignedDisk, wk.MaxReplicas)
+		fmt.Fprintln(w, "VERSION\tIMAGE\tPORT\tSTATUS\tCOMMIT\tCREATED")
+		for _, v := range detail.Versions {
+			active := ""
+			if wk.ActiveVersionID != nil && *wk.ActiveVersionID == v.ID {
+				active = " *"
+			}
+			fmt.Fprintf(w, "%d%s\t%s\t%d\t%s\t%s\t%s\n", v.ID, active, v.Image, v.Port, v.Status, v.CommitSHA, v.CreatedAt.Local().Format(time.DateTime))
+		}
+	})
+}
+
+// workerDeploy signs the deploy with the stored secret key; without -port
+// the port of the active version is kept
+func (c *cli) workerDeploy(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("worker deploy", flag.ContinueOnError)
+	image := fs.String("image", "", "Image to deploy")
+	port := fs.Int("port", 0, "Container port (default: the port of the active version)")
+	commit := fs.String("commit", "", "Source commit recorded on the version")
+	rest, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *image == "" {
+		return errors.New("worker deploy requires -image")
+	}
+	if c.cfg.SecretKey == "" || c.cfg.UserID == "" {
+		return errors.New("no secret key stored, run consolectl login")
+	}
+	if *port == 0 {
+		detail, err := c.client.GetWorkerId(ctx, rest[0], nil)
+		if err != nil {
+			return err
+		}
+		for _, v := range detail.Versions {
+			if detail.Worker.ActiveVersionID != nil && *detail.Worker.ActiveVersionID == v.ID {
+				*port = v.Port
+			}
+		}
+		if *port == 0 {
+			return errors.New("the worker has no active version, pass -port")
+		}
+	}
+
+	resp, err := c.client.PostWorkerDeploy(ctx, handlers.DeployWorkerRequest{
+		UserUID:   c.cfg.UserID,
+		WorkerID:  rest[0],
+		Image:     *image,
+		Port:      *port,
+		CommitSHA: *commit,
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "deploying %s version %d (%s)\n", resp.WorkerID, resp.VersionID, resp.Status)
+	})
+}
+
+// workerRestart requests a rolling restart and, with -wait, polls its
+// progress until every replica runs the restarted template
+func (c *cli) workerRestart(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("worker restart", flag.ContinueOnError)
+	reason := fs.String("reason", "", "Why the worker is restarted, kept in its restart log")
+	wait := fs.Duration("wait", 0, "Poll until the rollout completes or fails, at most this long")
+	rest, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *reason == "" {
+		return errors.New("worker restart requires -reason")
+	}
+	resp, err := c.client.PostWorkerIdRestart(ctx, rest[0], handlers.RestartWorkerRequest{Reason: *reason})
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(*wait)
+	for {
+		r := resp.Restart
+		done := resp.Complete || r.Status == dblayer.WorkerRestartFailed
+		if done || !time.Now().Before(deadline) {
+			if err := c.print(resp, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "restart %d of %s\t%s\n", r.ID, r.WorkerID, r.Status)
+				if ro := resp.Rollout; ro != nil {
+					fmt.Fprintf(w, "rollout\t%d/%d updated, %d available\n", ro.UpdatedReplicas, ro.Replicas, ro.AvailableReplicas)
+				}
+			}); err != nil {
+				return err
+			}
+			if r.Status == dblayer.WorkerRestartFailed {
+				return errors.New("restart failed: " + r.Error)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+		if resp, err = c.client.GetWorkerIdRestartsRid(ctx, rest[0], fmt.Sprint(r.ID)); err != nil {
+			return err
+		}
+	}
+}
+
+// workerLogs prints retained logs oldest first
+func (c *cli) workerLogs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("worker logs", flag.ContinueOnError)
+	since := fs.String("since", "1h", "Start, RFC3339 or a duration like 6h")
+	until := fs.String("until", "", "End, RFC3339 or a duration (default: now)")
+	text := fs.String("query", "", "Only lines containing this text")
+	limit := fs.Int("limit", 0, "Maximum number of lines (default: server limit)")
+	rest, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"since": {*since}}
+	if *until != "" {
+		query.Set("until", *until)
+	}
+	if *text != "" {
+		query.Set("query", *text)
+	}
+	if *limit > 0 {
+		query.Set("limit", fmt.Sprint(*limit))
+	}
+	logs, err := c.client.GetWorkerIdLogsHistory(ctx, rest[0], query)
+	if err != nil {
+		return err
+	}
+	slices.Reverse(logs.Entries)
+	return c.print(logs, func(w *tabwriter.Writer) {
+		for _, e := range logs.Entries {
+			fmt.Fprintf(w, "%s %s %s\n", e.Time.Local().Format(time.RFC3339), e.Pod, e.Line)
+		}
+	})
+}
+
+func (c *cli) envList(ctx context.Context, args []string) error {
+	rest, err := parse(flag.NewFlagSet("env list", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	env, err := c.client.GetWorkerIdEnv(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return c.printEnv(env)
+}
+
+func (c *cli) envSet(ctx context.Context, args []string) error {
+	rest, err := parse(flag.NewFlagSet("env set", flag.ContinueOnError), args, -1)
+	if err != nil {
+		return err
+	}
+	if len(rest) < 2 {
+		return errors.New("env set expects WORKER_ID KEY=VALUE...")
+	}
+	var reqs []handlers.WorkerKeyValueRequest
+	for _, kv := range rest[1:] {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("%q is not KEY=VALUE", kv)
+		}
+		reqs = append(reqs, handlers.WorkerKeyValueRequest{Key: key, Value: value})
+	}
+	return c.updateEnv(ctx, rest[0], reqs)
+}
+
+func (c *cli) envUnset(ctx context.Context, args []string) error {
+	rest, err := parse(flag.NewFlagSet("env unset", flag.ContinueOnError), args, -1)
+	if err != nil {
+		return err
+	}
+	if len(rest) < 2 {
+		return errors.New("env unset expects WORKER_ID KEY...")
+	}
+	var reqs []handlers.WorkerKeyValueRequest
+	for _, key := range rest[1:] {
+		reqs = append(reqs, handlers.WorkerKeyValueRequest{Key: key, Delete: true})
+	}
+	return c.updateEnv(ctx, rest[0], reqs)
+}
+
+// updateEnv applies the changes one request at a time (the API takes one
+// key per call) and prints the resulting masked env
+func (c *cli) updateEnv(ctx context.Context, workerID string, reqs []handlers.WorkerKeyValueRequest) error {
+	var env map[string]string
+	for _, req := range reqs {
+		var err error
+		if env, err = c.client.PostWorkerIdEnv(ctx, workerID, req); err != nil {
+			return fmt.Errorf("%s: %w", req.Key, err)
+		}
+	}
+	return c.printEnv(env)
+}
+
+func (c *cli) printEnv(env map[string]string) error {
+	return c.print(env, func(w *tabwriter.Writer) {
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s=%s\n", k, env[k])
+		}
+	})
+}
+
+func (c *cli) domainList(ctx context.Context, args []string) error {
+	if _, err := parse(flag.NewFlagSet("domain list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	list, err := c.client.GetDomain(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return c.print(list, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tDOMAIN\tTARGET\tSTATUS")
+		for _, d := range list.Domains {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.CDID, d.Domain, d.Target, d.Status)
+		}
+	})
+}
+
+func (c *cli) domainAdd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("domain add", flag.ContinueOnError)
+	target := fs.String("target", "", "Host the domain is a CNAME of")
+	worker := fs.String("worker", "", "Route the domain to this worker (sets -target to its host)")
+	challenge := fs.String("challenge", "", "ACME challenge: http01 or dns01 (default: server default)")
+	rest, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *worker != "" {
+		detail, err := c.client.GetWorkerId(ctx, *worker, nil)
+		if err != nil {
+			return err
+		}
+		*target = strings.TrimPrefix(detail.URL, "https://")
+	}
+	if *target == "" {
+		return errors.New("domain add requires -worker or -target")
+	}
+	resp, err := c.client.PostDomain(ctx, handlers.AddCustomDomainRequest{Domain: rest[0], Target: *target, Challenge: *challenge})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "added %s (%s), create this DNS record to verify it:\n\n", resp.Domain, resp.Status)
+		fmt.Fprintf(w, "  TXT\t%s\t%s\n\n", resp.TXTName, resp.TXTValue)
+		fmt.Fprintln(w, "then run consolectl domain verify with the ID from consolectl domain list")
+	})
+}
+
+// domainVerify shows the verification state; with -wait it polls until
+// the domain is verified or failed
+func (c *cli) domainVerify(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("domain verify", flag.ContinueOnError)
+	wait := fs.Duration("wait", 0, "Poll until verified or failed, at most this long")
+	rest, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(*wait)
+	for {
+		d, err := c.client.GetDomainId(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		if d.Status != k8s.DomainStatusPending || !time.Now().Before(deadline) {
+			if err := c.print(d, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "%s\t%s\n", d.Domain, d.Status)
+				if d.Status == k8s.DomainStatusPending {
+					fmt.Fprintf(w, "TXT\t%s\t%s\n", d.TXTName, d.TXTValue)
+				}
+			}); err != nil {
+				return err
+			}
+			if d.Status == k8s.DomainStatusError {
+				return errors.New("verification failed")
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (c *cli) rdbList(ctx context.Context, args []string) error {
+	if _, err := parse(flag.NewFlagSet("rdb list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	list, err := c.client.GetRdb(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return c.print(list, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tSTATUS\tCREATED")
+		for _, r := range list.RDBs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ResourceID, r.Name, r.Status, r.CreatedAt.Local().Format(time.DateTime))
+		}
+	})
+}
+
+func (c *cli) rdbCreate(ctx context.Context, args []string) error {
+	rest, err := parse(flag.NewFlagSet("rdb create", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.PostRdb(ctx, handlers.CreateRDBRequest{Name: rest[0]})
+	if err != nil {
+		return err
+	}
+	return c.printCreated(resp)
+}
+
+func (c *cli) rdbDelete(ctx context.Context, args []string) error {
+	rest, err := parse(flag.NewFlagSet("rdb delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.DeleteRdbId(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return c.printMessage(resp)
+}
+
+func (c *cli) kvList(ctx context.Context, args []string) error {
+	if _, err := parse(flag.NewFlagSet("kv list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	list, err := c.client.GetKv(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return c.print(list, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tSTATUS\tCREATED")
+		for _, r := range list.KVs {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.ResourceID, r.Status, r.CreatedAt.Local().Format(time.DateTime))
+		}
+	})
+}
+
+func (c *cli) kvCreate(ctx context.Context, args []string) error {
+	if _, err := parse(flag.NewFlagSet("kv create", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	resp, err := c.client.PostKv(ctx, handlers.CreateKVRequest{})
+	if err != nil {
+		return err
+	}
+	return c.printCreated(resp)
+}
+
+func (c *cli) kvDelete(ctx context.Context, args []string) error {
+	rest, err := parse(flag.NewFlagSet("kv delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.DeleteKvId(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return c.printMessage(resp)
+}
+
+func (c *cli) printCreated(resp handlers.ResourceCreatedResponse) error {
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "created %s (%s)\n", resp.ID, resp.Status)
+	})
+}
+
+func (c *cli) printMessage(resp handlers.MessageResponse) error {
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, resp.Message)
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"jabberwocky238/console/client"
+	"jabberwocky238/console/handlers"
+
+	"golang.org/x/term"
+)
+
+// config is stored with mode 0600 in <user config dir>/consolectl/config.json.
+// SecretKey signs worker deploys, which do not accept the bearer token.
+type config struct {
+	URL       string `json:"url"`
+	UserID    string `json:"user_id,omitempty"`
+	Token     string `json:"token,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "consolectl", "config.json"), nil
+}
+
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(cfg, "", "  ")
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// login exchanges email and password for a bearer token, then reveals the
+// account secret key with the same password so deploys can be signed.
+// A risky login asks for the code the server emails (or takes -code).
+func (c *cli) login(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	email := fs.String("email", "", "Account email")
+	code := fs.String("code", "", "Verification code from the step-up email")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if c.cfg.URL == "" {
+		return errors.New("no API URL, pass -url")
+	}
+	if *email == "" {
+		return errors.New("login requires -email")
+	}
+	password, err := readSecret("Password: ")
+	if err != nil {
+		return err
+	}
+
+	req := handlers.LoginRequest{Email: *email, Password: password, Code: *code}
+	resp, err := c.client.PostAuthLogin(ctx, req)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.Status == 401 && req.Code == "" {
+		var stepUp handlers.StepUpRequiredResponse
+		if json.Unmarshal(apiErr.Body, &stepUp) == nil && stepUp.StepUp {
+			if req.Code, err = readLine("A verification code was sent to " + *email + ". Code: "); err != nil {
+				return err
+			}
+			resp, err = c.client.PostAuthLogin(ctx, req)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	c.client.Token = resp.Token
+	key, err := c.client.PostAccountSecretKeyReveal(ctx, handlers.RevealSecretKeyRequest{Password: password})
+	if err != nil {
+		return fmt.Errorf("reveal secret key: %w", err)
+	}
+	c.cfg.UserID = resp.UserID
+	c.cfg.Token = resp.Token
+	c.cfg.SecretKey = key.SecretKey
+	if err := c.cfg.save(); err != nil {
+		return err
+	}
+	fmt.Println("logged in as", resp.UserID)
+	return nil
+}
+
+// logout forgets the credentials and keeps the URL
+func (c *cli) logout() error {
+	*c.cfg = config{URL: c.cfg.URL}
+	return c.cfg.save()
+}
+
+// readSecret reads CONSOLE_PASSWORD, or prompts without echo on a terminal,
+// or reads one line from piped stdin
+func readSecret(prompt string) (string, error) {
+	if v := os.Getenv("CONSOLE_PASSWORD"); v != "" {
+		return v, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readLine("")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readLine(prompt string) (string, error) {
+	if prompt != "" {
+		fmt.Fprint(os.Stderr, prompt)
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,167 @@
+// consolectl manages workers, environment variables, custom domains and
+// RDB/KV resources from the command line through the outer API, using the
+// typed client generated from the handlers. apply deploys a console.yaml
+// manifest.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"jabberwocky238/console/client"
+)
+
+const usage = `usage: consolectl [-url URL] [-o table|json] <command> [args]
+
+commands:
+  login -email EMAIL [-code CODE]       log in and store the token and secret key
+  logout                                forget the stored credentials
+  apply [-f console.yaml] [-dry-run] [-prune]
+                                        build (if needed) and apply a worker manifest
+  worker list
+  worker get WORKER_ID
+  worker deploy WORKER_ID -image IMAGE [-port PORT] [-commit SHA]
+  worker restart WORKER_ID -reason TEXT [-wait 10m]
+  worker logs WORKER_ID [-since 1h] [-until T] [-query TEXT] [-limit N]
+  env list WORKER_ID
+  env set WORKER_ID KEY=VALUE...
+  env unset WORKER_ID KEY...
+  domain list
+  domain add DOMAIN (-worker WORKER_ID | -target HOST) [-challenge http01|dns01]
+  domain verify DOMAIN_ID [-wait 10m]
+  rdb list | rdb create NAME | rdb delete RDB_ID
+  kv list  | kv create       | kv delete KV_ID
+
+The password of login is read from CONSOLE_PASSWORD, the terminal or stdin.
+CONSOLE_URL and CONSOLE_TOKEN override the stored configuration.
+`
+
+// cli is the state shared by the subcommands
+type cli struct {
+	cfg    *config
+	client *client.Client
+	json   bool
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	baseURL := flag.String("url", "", "API base URL, e.g. https://console.example.com (default: stored by login)")
+	output := flag.String("o", "table", "Output format: table or json")
+	flag.Parse()
+	if flag.NArg() == 0 || (*output != "table" && *output != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal(err)
+	}
+	if v := os.Getenv("CONSOLE_URL"); v != "" {
+		cfg.URL = v
+	}
+	if v := os.Getenv("CONSOLE_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	if *baseURL != "" {
+		cfg.URL = *baseURL
+	}
+
+	c := &cli{cfg: cfg, client: client.New(cfg.URL), json: *output == "json"}
+	c.client.Token = cfg.Token
+	c.client.UserID = cfg.UserID
+	c.client.SecretKey = cfg.SecretKey
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := c.run(ctx, flag.Args()); err != nil {
+		fatal(err)
+	}
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	commands := map[string]map[string]func(context.Context, []string) error{
+		"worker": {"list": c.workerList, "get": c.workerGet, "deploy": c.workerDeploy, "restart": c.workerRestart, "logs": c.workerLogs},
+		"env":    {"list": c.envList, "set": c.envSet, "unset": c.envUnset},
+		"domain": {"list": c.domainList, "add": c.domainAdd, "verify": c.domainVerify},
+		"rdb":    {"list": c.rdbList, "create": c.rdbCreate, "delete": c.rdbDelete},
+		"kv":     {"list": c.kvList, "create": c.kvCreate, "delete": c.kvDelete},
+	}
+	switch args[0] {
+	case "login":
+		return c.login(ctx, args[1:])
+	case "logout":
+		return c.logout()
+	case "apply":
+		if c.cfg.URL == "" {
+			return errNoURL
+		}
+		return c.apply(ctx, args[1:])
+	}
+	group, ok := commands[args[0]]
+	if !ok {
+		return errUsage
+	}
+	if len(args) < 2 || group[args[1]] == nil {
+		return errUsage
+	}
+	if c.cfg.URL == "" {
+		return errNoURL
+	}
+	return group[args[1]](ctx, args[2:])
+}
+
+var (
+	errUsage = errors.New("unknown command, see consolectl -h")
+	errNoURL = errors.New("no API URL, run consolectl -url URL login first")
+)
+
+// parse parses the flags of a subcommand, which may come before or after
+// the positional arguments, and checks the positional count
+func parse(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if positional >= 0 && len(rest) != positional {
+		return nil, fmt.Errorf("%s expects %d argument(s)", fs.Name(), positional)
+	}
+	return rest, nil
+}
+
+// print writes v as indented JSON with -o json, otherwise calls table
+func (c *cli) print(v any, table func(w *tabwriter.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func fatal(err error) {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.Status == 401 && !strings.Contains(string(apiErr.Body), "step_up") {
+		fmt.Fprintln(os.Stderr, "consolectl:", err, "(run consolectl login)")
+	} else {
+		fmt.Fprintln(os.Stderr, "consolectl:", err)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"jabberwocky238/console/handlers"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerProbes mounts /health, /ready and /metrics. main registers them
+// before the logging and metrics middleware so probes stay out of both.
+func registerProbes(router *gin.Engine) {
+	router.GET("/health", handlers.HealthInner)
+	router.GET("/ready", handlers.Ready)
+	router.GET("/metrics", handlers.Metrics())
+}
+
+// apiRoutes returns the routes of the inner API, mounted under each API prefix
+func apiRoutes(proc *k8s.Processor, cron *k8s.CronScheduler) handlers.APIRoutes {
+	wh := handlers.NewWorkerHandler()
+	cih := handlers.NewCombinatorInternalHandler(proc)
+	th := handlers.NewTaskHandler(proc, cron)
+
+	return handlers.APIRoutes{Prefix: handlers.APIPrefixV1, Register: func(api *gin.RouterGroup) {
+		// Internal routes, only accessible from the cluster; under mTLS only
+		// the outer gateway may deploy and submit tasks
+		outer := api.Group("", handlers.AllowCallers(false, k8s.ComponentOuter))
+		outer.POST("/worker/deploy", wh.DeployWorker)
+		outer.GET("/worker/logs", handlers.Streaming(), wh.StreamWorkerLogs)
+		outer.GET("/worker/status", wh.WorkerStatus)
+		outer.GET("/worker/validate", wh.ValidateWorkerDeploy)
+		outer.GET("/worker/pools", wh.WorkerNodePools)
+		outer.GET("/regions", wh.WorkerRegions)
+		outer.GET("/combinator/app", handlers.CombinatorAppStatus)
+		outer.GET("/combinator/status", handlers.CombinatorRuntime)
+		outer.GET("/combinator/logs", handlers.CombinatorPodLogs)
+		outer.GET("/queue/stats", handlers.QueueStats)
+		outer.POST("/acceptTask", handlers.VerifyTaskSignature(), th.AcceptTask)
+		// Workers may fetch their own owner's secrets (checked by the handler)
+		api.GET("/combinator/retrieveSecretByID", handlers.AllowCallers(true, k8s.ComponentCombinator), cih.RetrieveSecretByID)
+		api.POST("/combinator/reportUsage", handlers.AllowCallers(false, k8s.ComponentCombinator), cih.ReportUsage)
+		api.POST("/combinator/reportConfig", handlers.AllowCallers(false, k8s.ComponentCombinator), cih.ReportConfig)
+		// Traefik access logs shipped by the log agent on the ingress nodes
+		api.POST("/access-logs", handlers.AllowCallers(false, k8s.ComponentLogAgent), handlers.IngestAccessLogs)
+		api.POST("/mail/authorize", handlers.AllowCallers(false, k8s.ComponentMailRelay), handlers.MailAuthorize)
+		api.POST("/mail/events", handlers.AllowCallers(false, k8s.ComponentMailRelay), handlers.MailEvents)
+		// Signed with the worker's RAYSAIL_UID / RAYSAIL_SECRET_KEY
+		api.GET("/combinator/resolveDSN", handlers.AllowCallers(true), handlers.VerifyWorkerSignature(), cih.ResolveDSN)
+	}}
+}
+
+// registerAdmin mounts the /admin routes, only for the admin component
+func registerAdmin(router *gin.Engine) {
+	admin := router.Group("/admin", handlers.AllowCallers(false, k8s.ComponentAdmin))
+	admin.GET("/drift", handlers.DriftReport)
+	admin.GET("/consistency", handlers.ConsistencyReport)
+	admin.POST("/consistency/repair", handlers.RepairConsistency)
+	admin.GET("/capacity", handlers.CapacityDashboard)
+	admin.GET("/overview", handlers.AdminOverview)
+	admin.GET("/tenants", handlers.AdminTenants)
+	admin.GET("/failures", handlers.AdminFailures)
+	admin.GET("/jobs/stats", handlers.AdminJobStats)
+	admin.GET("/jobs/leases", handlers.AdminJobLeases)
+	admin.GET("/accounts/:uid/limits", handlers.GetAccountLimits)
+	admin.PUT("/accounts/:uid/plan", handlers.SetAccountPlan)
+	admin.PUT("/accounts/:uid/limits", handlers.SetAccountLimits)
+	admin.GET("/accounts/:uid/features", handlers.GetAccountFeatures)
+	admin.GET("/accounts/:uid/job-limits", handlers.GetAccountJobLimits)
+	admin.PUT("/accounts/:uid/job-limits/:type", handlers.SetAccountJobLimit)
+	admin.DELETE("/accounts/:uid/job-limits/:type", handlers.DeleteAccountJobLimit)
+	admin.GET("/settings", handlers.ListSettings)
+	admin.PUT("/settings/:key", handlers.SetSetting)
+	admin.DELETE("/settings/:key", handlers.DeleteSetting)
+	admin.GET("/invitations", handlers.ListInvitations)
+	admin.POST("/invitations", handlers.CreateInvitation)
+	admin.DELETE("/invitations/:id", handlers.DeleteInvitation)
+	admin.GET("/waitlist", handlers.ListWaitlist)
+	admin.POST("/waitlist/:id/approve", handlers.ApproveWaitlist)
+	admin.POST("/waitlist/:id/reject", handlers.RejectWaitlist)
+	admin.GET("/flags", handlers.ListFeatureFlags)
+	admin.PUT("/flags/:key", handlers.SetFeatureFlag)
+	admin.DELETE("/flags/:key", handlers.DeleteFeatureFlag)
+	admin.PUT("/flags/:key/users/:uid", handlers.SetFeatureFlagUser)
+	admin.DELETE("/flags/:key/users/:uid", handlers.DeleteFeatureFlagUser)
+	admin.GET("/email-templates", handlers.ListEmailTemplates)
+	admin.GET("/email-templates/:name/:locale", handlers.GetEmailTemplate)
+	admin.PUT("/email-templates/:name/:locale", handlers.SetEmailTemplate)
+	admin.DELETE("/email-templates/:name/:locale", handlers.DeleteEmailTemplate)
+	admin.POST("/email-templates/:name/:locale/preview", handlers.PreviewEmailTemplate)
+	admin.POST("/email-templates/:name/:locale/test", handlers.TestEmailTemplate)
+}
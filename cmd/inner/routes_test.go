@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"jabberwocky238/console/handlers"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestOpenAPIMatchesRoutes fails when a route is added without documenting it
+// in handlers.InnerOperations, or an operation is documented but not routed
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerProbes(router)
+	proc := k8s.NewProcessor(1, 1)
+	v1 := apiRoutes(proc, k8s.NewCronScheduler(proc))
+	handlers.RegisterAPI(router, &v1, v1)
+	registerAdmin(router)
+
+	for _, p := range handlers.NewOpenAPIHandler("Console Inner API", handlers.InnerOperations).Verify(router) {
+		t.Error(p)
+	}
+}
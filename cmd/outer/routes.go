@@ -0,0 +1,251 @@
+package main
+
+import (
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerProbes mounts /health, /ready and /metrics. main registers them
+// before the logging and metrics middleware so probes stay out of both.
+func registerProbes(router *gin.Engine) {
+	router.GET("/health", handlers.HealthOuter)
+	router.GET("/ready", handlers.Ready)
+	router.GET("/metrics", handlers.Metrics())
+}
+
+// apiRoutes returns the routes of the outer API, mounted under each API prefix
+func apiRoutes(bus *dblayer.EventBus, cors handlers.CORSConfig) handlers.APIRoutes {
+	wh := handlers.NewWorkerHandler()
+	ch := handlers.NewCombinatorHandler()
+	eh := handlers.NewEventsHandler(bus)
+	ah := handlers.NewAlertsHandler()
+	uh := handlers.NewUptimeHandler()
+	ph := handlers.NewPreviewHandler()
+	gh := handlers.NewGitHubHandler()
+	wsh := handlers.NewWSHandler(bus, cors)
+
+	return handlers.APIRoutes{Prefix: handlers.APIPrefixV1, Register: func(api *gin.RouterGroup) {
+		// Public routes
+		authBody := handlers.MaxBodySize(handlers.AuthBodyLimit)
+		api.POST("/auth/register", authBody, handlers.Register)
+		api.GET("/auth/signup", handlers.SignupInfo)
+		api.POST("/auth/login", authBody, handlers.Login)
+		api.POST("/auth/send-code", authBody, handlers.SendCode)
+		api.POST("/auth/reset-password", authBody, handlers.ResetPassword)
+		api.GET("/status/:slug", handlers.PublicStatusPage)
+		api.POST("/billing/webhook", handlers.MaxBodySize(handlers.DefaultBodyLimit), handlers.StripeWebhook)
+		api.POST("/preview/github/:id", handlers.MaxBodySize(handlers.DefaultBodyLimit), ph.GitHubWebhook)
+		api.POST("/webhooks/github", handlers.MaxBodySize(handlers.DefaultBodyLimit), gh.Webhook)
+
+		// Protected routes (auth required)
+		protected := api.Group("")
+		protected.Use(handlers.AuthMiddleware(), handlers.CSRFMiddleware(), handlers.MaxBodySize(handlers.DefaultBodyLimit))
+		{
+			protected.POST("/auth/logout", handlers.Logout)
+
+			protected.GET("/rdb", ch.ListRDBs)
+			protected.GET("/rdb/:id", ch.GetRDB)
+			protected.POST("/rdb", ch.CreateRDB)
+			protected.DELETE("/rdb/:id", ch.DeleteRDB)
+			protected.PUT("/rdb/:id/tags", ch.SetRDBTags)
+			protected.PUT("/rdb/:id/environment", ch.SetRDBEnvironment)
+			protected.GET("/rdb/:id/tasks", ch.ListRDBTasks)
+			protected.POST("/rdb/:id/tasks", ch.CreateRDBTask)
+			protected.GET("/rdb/:id/tasks/:task", ch.GetRDBTask)
+			protected.PUT("/rdb/:id/tasks/:task", ch.UpdateRDBTask)
+			protected.DELETE("/rdb/:id/tasks/:task", ch.DeleteRDBTask)
+			protected.POST("/rdb/:id/tasks/:task/run", ch.RunRDBTask)
+			protected.GET("/rdb/:id/tasks/:task/runs", ch.ListRDBTaskRuns)
+
+			protected.GET("/kv", ch.ListKVs)
+			protected.POST("/kv", ch.CreateKV)
+			protected.DELETE("/kv/:id", ch.DeleteKV)
+			protected.PUT("/kv/:id/tags", ch.SetKVTags)
+			protected.PUT("/kv/:id/environment", ch.SetKVEnvironment)
+
+			protected.GET("/bucket", ch.ListBuckets)
+			protected.GET("/bucket/:id", ch.GetBucket)
+			protected.POST("/bucket", ch.CreateBucket)
+			protected.DELETE("/bucket/:id", ch.DeleteBucket)
+			protected.PUT("/bucket/:id/tags", ch.SetBucketTags)
+			protected.PUT("/bucket/:id/environment", ch.SetBucketEnvironment)
+			protected.PUT("/bucket/:id/lifecycle", ch.SetBucketLifecycle)
+
+			protected.GET("/queue", ch.ListQueues)
+			protected.GET("/queue/:id", ch.GetQueue)
+			protected.POST("/queue", ch.CreateQueue)
+			protected.DELETE("/queue/:id", ch.DeleteQueue)
+			protected.PUT("/queue/:id/tags", ch.SetQueueTags)
+			protected.PUT("/queue/:id/environment", ch.SetQueueEnvironment)
+
+			protected.GET("/mail", handlers.GetMailOverview)
+			protected.GET("/mail/domains", handlers.ListMailDomains)
+			protected.POST("/mail/domains", handlers.AddMailDomain)
+			protected.GET("/mail/domains/:id", handlers.GetMailDomain)
+			protected.POST("/mail/domains/:id/verify", handlers.VerifyMailDomain)
+			protected.DELETE("/mail/domains/:id", handlers.DeleteMailDomain)
+			protected.GET("/mail/credentials", handlers.ListMailCredentials)
+			protected.POST("/mail/credentials", handlers.CreateMailCredential)
+			protected.DELETE("/mail/credentials/:id", handlers.DeleteMailCredential)
+			protected.GET("/mail/suppressions", handlers.ListMailSuppressions)
+			protected.POST("/mail/suppressions", handlers.AddMailSuppression)
+			protected.DELETE("/mail/suppressions/:address", handlers.DeleteMailSuppression)
+			protected.GET("/mail/logs", handlers.ListMailLogs)
+			protected.GET("/combinator/app", ch.GetAppStatus)
+			protected.PUT("/combinator/app/profile", ch.SetAppProfile)
+			protected.GET("/combinator/status", ch.GetCombinatorStatus)
+			protected.GET("/combinator/logs", ch.GetCombinatorLogs)
+			protected.POST("/combinator/restart", ch.RestartCombinator)
+
+			protected.GET("/worker", wh.ListWorkers)
+			protected.GET("/worker/:id", wh.GetWorker)
+			protected.GET("/worker/pools", wh.ListNodePools)
+			protected.GET("/regions", wh.ListRegions)
+			protected.POST("/worker", wh.CreateWorker)
+			protected.DELETE("/worker/:id", wh.DeleteWorker)
+			protected.POST("/worker/:id/clone", wh.CloneWorker)
+			protected.POST("/worker/:id/promote", wh.PromoteWorker)
+			protected.POST("/worker/estimate", wh.EstimateWorker)
+			protected.POST("/worker/:id/unpause", wh.UnpauseWorker)
+			protected.POST("/worker/:id/restart", wh.RestartWorker)
+			protected.GET("/worker/:id/restarts", wh.ListWorkerRestarts)
+			protected.GET("/worker/:id/restarts/:rid", wh.GetWorkerRestart)
+			protected.PATCH("/worker/:id/resources", wh.ResizeWorker)
+			protected.GET("/worker/:id/resources/changes", wh.ListWorkerResourceChanges)
+			protected.GET("/worker/:id/remediation", wh.GetWorkerRemediation)
+			protected.PUT("/worker/:id/remediation", wh.SetWorkerRemediation)
+			protected.DELETE("/worker/:id/remediation", wh.DeleteWorkerRemediation)
+			protected.GET("/worker/:id/remediations", wh.ListWorkerRemediations)
+			protected.POST("/worker/:id/remediations/:rid/apply", wh.ApplyWorkerRemediation)
+			protected.GET("/worker/:id/versions", wh.ListWorkerVersions)
+			protected.GET("/worker/:id/versions/:a/diff/:b", wh.DiffWorkerVersions)
+
+			protected.GET("/worker/:id/env", wh.GetWorkerEnv)
+			protected.POST("/worker/:id/env", wh.SetWorkerEnv)
+			protected.POST("/worker/:id/env/reveal", wh.RevealWorkerEnv)
+			protected.POST("/worker/:id/env/import", wh.ImportWorkerEnv)
+			protected.GET("/worker/:id/secret", wh.GetWorkerSecrets)
+			protected.POST("/worker/:id/secret", wh.SetWorkerSecrets)
+			protected.PUT("/worker/:id/tags", wh.SetWorkerTags)
+			protected.PUT("/worker/:id/environment", wh.SetWorkerEnvironment)
+			protected.GET("/worker/:id/egress", wh.GetWorkerEgress)
+			protected.PUT("/worker/:id/egress", wh.SetWorkerEgress)
+			protected.GET("/worker/:id/routes", wh.GetWorkerRoutes)
+			protected.PUT("/worker/:id/routes", wh.SetWorkerRoutes)
+			protected.GET("/worker/:id/edge-limits", wh.GetWorkerEdgeLimits)
+			protected.PUT("/worker/:id/edge-limits", wh.SetWorkerEdgeLimits)
+			protected.GET("/worker/:id/error-pages", wh.GetWorkerErrorPages)
+			protected.PUT("/worker/:id/error-pages", wh.SetWorkerErrorPages)
+			protected.GET("/worker/:id/requests", wh.ListWorkerRequests)
+			protected.GET("/worker/:id/requests/hourly", wh.WorkerRequestHours)
+			protected.GET("/worker/:id/env-groups", wh.GetWorkerEnvGroups)
+			protected.PUT("/worker/:id/env-groups", wh.SetWorkerEnvGroups)
+			protected.GET("/worker/:id/attachments", wh.ListAttachments)
+			protected.POST("/worker/:id/attach/:type/:rid", wh.AttachResource)
+			protected.DELETE("/worker/:id/attach/:type/:rid", wh.DetachResource)
+			protected.GET("/worker/:id/preview", ph.GetPreviews)
+			protected.PUT("/worker/:id/preview", ph.SetConfig)
+			protected.DELETE("/worker/:id/preview", ph.DeleteConfig)
+			protected.GET("/worker/:id/github", gh.GetBinding)
+			protected.PUT("/worker/:id/github", gh.SetBinding)
+			protected.DELETE("/worker/:id/github", gh.DeleteBinding)
+			protected.GET("/worker/:id/logs/history", wh.WorkerLogHistory)
+			protected.GET("/worker/:id/status", wh.GetWorkerStatus)
+
+			protected.GET("/alerts/rules", ah.ListRules)
+			protected.POST("/alerts/rules", ah.CreateRule)
+			protected.GET("/alerts/rules/:id", ah.GetRule)
+			protected.PUT("/alerts/rules/:id", ah.UpdateRule)
+			protected.DELETE("/alerts/rules/:id", ah.DeleteRule)
+			protected.GET("/alerts/history", ah.ListHistory)
+
+			protected.GET("/uptime/checks", uh.ListChecks)
+			protected.POST("/uptime/checks", uh.CreateCheck)
+			protected.GET("/uptime/checks/:id", uh.GetCheck)
+			protected.PUT("/uptime/checks/:id", uh.UpdateCheck)
+			protected.DELETE("/uptime/checks/:id", uh.DeleteCheck)
+			protected.GET("/uptime/checks/:id/results", uh.CheckResults)
+			protected.GET("/uptime/incidents", uh.ListIncidents)
+			protected.GET("/uptime/status-page", uh.GetStatusPage)
+			protected.PUT("/uptime/status-page", uh.SetStatusPage)
+
+			protected.GET("/domain", handlers.ListCustomDomains)
+			protected.GET("/domain/:id", handlers.GetCustomDomain)
+			protected.POST("/domain", handlers.AddCustomDomain)
+			protected.DELETE("/domain/:id", handlers.DeleteCustomDomain)
+			protected.PUT("/domain/:id/tags", handlers.SetCustomDomainTags)
+			protected.PUT("/domain/:id/environment", handlers.SetCustomDomainEnvironment)
+
+			protected.GET("/usage", handlers.GetUsage)
+			protected.GET("/billing/subscription", handlers.GetBillingSubscription)
+			protected.POST("/billing/checkout", handlers.CreateCheckout)
+			protected.GET("/spend-cap", handlers.GetSpendCap)
+			protected.PUT("/spend-cap", handlers.SetSpendCap)
+			protected.DELETE("/spend-cap", handlers.DeleteSpendCap)
+			protected.POST("/spend-cap/override", handlers.OverrideSpendCap)
+			protected.GET("/spend-cap/actions", handlers.ListSpendCapActions)
+
+			protected.GET("/secrets", handlers.ListSecrets)
+			protected.POST("/secrets", handlers.CreateSecret)
+			protected.GET("/secrets/:name", handlers.GetSecret)
+			protected.DELETE("/secrets/:name", handlers.DeleteSecret)
+			protected.POST("/secrets/:name/rotate", handlers.RotateSecret)
+			protected.GET("/secrets/:name/audit", handlers.ListSecretAccess)
+			protected.GET("/env-groups", handlers.ListEnvGroups)
+			protected.POST("/env-groups", handlers.CreateEnvGroup)
+			protected.GET("/env-groups/:name", handlers.GetEnvGroup)
+			protected.PUT("/env-groups/:name", handlers.SetEnvGroupVars)
+			protected.DELETE("/env-groups/:name", handlers.DeleteEnvGroup)
+
+			protected.GET("/environments", handlers.ListEnvironments)
+			protected.GET("/environments/:name", handlers.GetEnvironment)
+			protected.PUT("/environments/:name", handlers.SetEnvironmentVars)
+			protected.DELETE("/environments/:name", handlers.DeleteEnvironment)
+
+			protected.GET("/security/events", handlers.ListAuthEvents)
+			protected.GET("/security/devices", handlers.ListDevices)
+			protected.DELETE("/security/devices/:id", handlers.ForgetDevice)
+			protected.POST("/account/secret-key/reveal", handlers.RevealSecretKey)
+			protected.GET("/account", handlers.GetAccount)
+			protected.PATCH("/account", handlers.UpdateAccount)
+			protected.GET("/account/preferences", handlers.GetPreferences)
+			protected.PUT("/account/preferences", handlers.SetPreferences)
+
+			protected.GET("/features", handlers.GetFeatures)
+			protected.GET("/notifications", handlers.ListNotifications)
+			protected.POST("/notifications/read", handlers.MarkNotificationsRead)
+			protected.GET("/search", handlers.Search)
+			protected.GET("/export", handlers.ExportConfig)
+
+			protected.GET("/events/stream", handlers.Streaming(), eh.Stream)
+			protected.GET("/ws", handlers.Streaming(), wsh.Serve)
+		}
+
+		// Bulk routes: protected, with a larger body limit and a longer timeout
+		bulk := api.Group("")
+		bulk.Use(handlers.AuthMiddleware(), handlers.CSRFMiddleware(),
+			handlers.MaxBodySize(handlers.BulkBodyLimit), handlers.RequestTimeout(handlers.BulkRequestTimeout))
+		{
+			bulk.POST("/worker/bulk", wh.BulkWorkers)
+			bulk.POST("/import", handlers.ImportConfig)
+			bulk.POST("/apply", handlers.ApplyManifest)
+		}
+
+		// Upload routes: protected, with the largest body limit
+		upload := api.Group("")
+		upload.Use(handlers.AuthMiddleware(), handlers.CSRFMiddleware(),
+			handlers.MaxBodySize(handlers.UploadBodyLimit), handlers.RequestTimeout(handlers.BulkRequestTimeout))
+		{
+			upload.POST("/worker/:id/static", wh.DeployStaticSite)
+		}
+
+		// Sensitive routes (signature required)
+		sensitive := api.Group("")
+		sensitive.Use(handlers.MaxBodySize(handlers.DefaultBodyLimit), handlers.SignatureMiddleware())
+		{
+			sensitive.POST("/worker/deploy", wh.DeployWorker)
+		}
+	}}
+}
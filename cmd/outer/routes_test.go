@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestOpenAPIMatchesRoutes fails when a route is added without documenting it
+// in handlers.OuterOperations, or an operation is documented but not routed
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerProbes(router)
+	v1 := apiRoutes(dblayer.NewEventBus(), handlers.CORSConfig{})
+	handlers.RegisterAPI(router, &v1, v1)
+
+	for _, p := range handlers.NewOpenAPIHandler("Console Outer API", handlers.OuterOperations).Verify(router) {
+		t.Error(p)
+	}
+}
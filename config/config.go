@@ -0,0 +1,132 @@
+// Package config loads the startup configuration of the gateways from an
+// optional YAML file, the environment and the command line, in increasing
+// order of precedence.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"jabberwocky238/console/k8s"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the typed configuration shared by both gateways. Settings not
+// covered by a field go in Flags (any command line flag by name) or Env
+// (any environment variable the gateways read), so one file describes a
+// whole environment.
+type Config struct {
+	Listen   string     `json:"listen,omitempty"`
+	Database Database   `json:"database"`
+	Auth     Auth       `json:"auth"`
+	K8s      k8s.Config `json:"k8s"`
+
+	Flags map[string]string `json:"flags,omitempty"`
+	Env   map[string]string `json:"env,omitempty"`
+}
+
+// Database is the control plane Postgres
+type Database struct {
+	DSN string `json:"dsn,omitempty"`
+}
+
+// Auth holds the secrets of user authentication
+type Auth struct {
+	JWTSecret string `json:"jwt_secret,omitempty"`
+}
+
+// Envs are the environment variables that override fields of the file
+var Envs = []string{
+	"DATABASE_URL", "JWT_SECRET", "DOMAIN",
+	"CONTROL_PLANE_NAMESPACE", "COMBINATOR_NAMESPACE", "INGRESS_NAMESPACE", "WORKER_NAMESPACE", "RDB_NAMESPACE",
+	"COCKROACHDB_HOST", "COCKROACHDB_PORT", "COCKROACHDB_ADMIN_DSN",
+}
+
+// field returns the field env overrides
+func (c *Config) field(env string) *string {
+	switch env {
+	case "DATABASE_URL":
+		return &c.Database.DSN
+	case "JWT_SECRET":
+		return &c.Auth.JWTSecret
+	case "DOMAIN":
+		return &c.K8s.Domain
+	case "CONTROL_PLANE_NAMESPACE":
+		return &c.K8s.Namespaces.ControlPlane
+	case "COMBINATOR_NAMESPACE":
+		return &c.K8s.Namespaces.Combinator
+	case "INGRESS_NAMESPACE":
+		return &c.K8s.Namespaces.Ingress
+	case "WORKER_NAMESPACE":
+		return &c.K8s.Namespaces.Worker
+	case "RDB_NAMESPACE":
+		return &c.K8s.Namespaces.RDB
+	case "COCKROACHDB_HOST":
+		return &c.K8s.CockroachDB.Host
+	case "COCKROACHDB_PORT":
+		return &c.K8s.CockroachDB.Port
+	case "COCKROACHDB_ADMIN_DSN":
+		return &c.K8s.CockroachDB.AdminDSN
+	}
+	return nil
+}
+
+// Load reads the YAML file at path (none when empty) and applies Envs over
+// it. The file's env entries are exported first, without replacing variables
+// already set, so code reading the environment later sees them too.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for env, value := range cfg.Env {
+		if _, ok := os.LookupEnv(env); !ok {
+			os.Setenv(env, value)
+		}
+	}
+	for _, env := range Envs {
+		if v := os.Getenv(env); v != "" {
+			*cfg.field(env) = v
+		}
+	}
+	return cfg, nil
+}
+
+// ApplyFlags resolves the command line against the file: the file's flags
+// entries set the flags not given on the command line, then every field in
+// bind (by flag name) takes its flag's value when the flag was given or the
+// field is still empty, so the flag default comes last.
+func (c *Config) ApplyFlags(fs *flag.FlagSet, bind map[string]*string) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	for name, value := range c.Flags {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("flags: unknown flag -%s", name)
+		}
+		if given[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("flags: -%s: %w", name, err)
+		}
+	}
+	for name, field := range bind {
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("bound flag -%s is not defined", name)
+		}
+		if given[name] || *field == "" {
+			*field = f.Value.String()
+		}
+	}
+	return nil
+}
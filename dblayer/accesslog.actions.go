@@ -0,0 +1,242 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WorkerRequest ingress 访问日志里路由到 worker 的一次请求
+type WorkerRequest struct {
+	ID         int       `json:"id"`
+	WID        string    `json:"wid"`
+	Method     string    `json:"method"`
+	Host       string    `json:"host"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	DurationMs int       `json:"duration_ms"`
+	ClientIP   string    `json:"client_ip"`
+	CreatedAt  time.Time `json:"created_at"` // 请求开始的时间
+}
+
+// WorkerRequestHour worker 一个小时的请求汇总
+type WorkerRequestHour struct {
+	Hour          time.Time `json:"hour"`
+	Requests      int       `json:"requests"`
+	Status2xx     int       `json:"status_2xx"`
+	Status3xx     int       `json:"status_3xx"`
+	Status4xx     int       `json:"status_4xx"`
+	Status5xx     int       `json:"status_5xx"`
+	AvgDurationMs float64   `json:"avg_duration_ms"`
+	MaxDurationMs int       `json:"max_duration_ms"`
+}
+
+// RequestFilter 请求列表的过滤条件，StatusMin 为 0 时不按状态码过滤
+type RequestFilter struct {
+	Since     time.Time
+	StatusMin int
+	StatusMax int
+}
+
+// requestInsertBatch 一条 INSERT 最多写入的请求数
+const requestInsertBatch = 1000
+
+type requestHourKey struct {
+	wid  string
+	hour time.Time
+}
+
+// requestHourSum 一批请求在某个小时里的累加值
+type requestHourSum struct {
+	requests    int
+	byClass     [4]int // 2xx 3xx 4xx 5xx
+	durationSum int64
+	durationMax int
+}
+
+// InsertWorkerRequests 写入一批访问日志并累加到小时汇总，不存在的 worker（已删除）的请求被丢弃，返回写入的条数
+func InsertWorkerRequests(ctx context.Context, reqs []WorkerRequest) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if len(reqs) == 0 {
+		return 0, nil
+	}
+
+	wids := make([]string, 0, len(reqs))
+	for _, r := range reqs {
+		wids = append(wids, r.WID)
+	}
+	rows, err := DB.QueryContext(ctx, `SELECT wid FROM workers WHERE wid = ANY($1)`, pq.Array(wids))
+	if err != nil {
+		return 0, err
+	}
+	known := map[string]bool{}
+	for rows.Next() {
+		var wid string
+		if err := rows.Scan(&wid); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		known[wid] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var params []any // 每条请求 8 个参数
+	hours := map[requestHourKey]*requestHourSum{}
+	var order []requestHourKey
+	for _, r := range reqs {
+		if !known[r.WID] {
+			continue
+		}
+		params = append(params, r.WID, r.Method, r.Host, r.Path, r.Status, r.DurationMs, r.ClientIP, r.CreatedAt)
+
+		key := requestHourKey{r.WID, r.CreatedAt.Truncate(time.Hour)}
+		h := hours[key]
+		if h == nil {
+			h = &requestHourSum{}
+			hours[key] = h
+			order = append(order, key)
+		}
+		h.requests++
+		if class := r.Status/100 - 2; class >= 0 && class < len(h.byClass) {
+			h.byClass[class]++
+		}
+		h.durationSum += int64(r.DurationMs)
+		h.durationMax = max(h.durationMax, r.DurationMs)
+	}
+	if len(params) == 0 {
+		return 0, nil
+	}
+
+	err = WithTx(ctx, func(tx *sql.Tx) error {
+		// 分批插入，不超过 Postgres 单条语句的参数个数上限
+		for values := range slices.Chunk(params, requestInsertBatch*8) {
+			query := `INSERT INTO worker_requests (wid, method, host, path, status, duration_ms, client_ip, created_at) VALUES `
+			for i := 0; i < len(values); i += 8 {
+				if i > 0 {
+					query += ", "
+				}
+				query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i+1, i+2, i+3, i+4, i+5, i+6, i+7, i+8)
+			}
+			if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+				return err
+			}
+		}
+		for _, key := range order {
+			h := hours[key]
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO worker_request_hourly
+				 (wid, hour, requests, status_2xx, status_3xx, status_4xx, status_5xx, duration_ms_sum, duration_ms_max)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				 ON CONFLICT (wid, hour) DO UPDATE SET
+				   requests = worker_request_hourly.requests + EXCLUDED.requests,
+				   status_2xx = worker_request_hourly.status_2xx + EXCLUDED.status_2xx,
+				   status_3xx = worker_request_hourly.status_3xx + EXCLUDED.status_3xx,
+				   status_4xx = worker_request_hourly.status_4xx + EXCLUDED.status_4xx,
+				   status_5xx = worker_request_hourly.status_5xx + EXCLUDED.status_5xx,
+				   duration_ms_sum = worker_request_hourly.duration_ms_sum + EXCLUDED.duration_ms_sum,
+				   duration_ms_max = GREATEST(worker_request_hourly.duration_ms_max, EXCLUDED.duration_ms_max)`,
+				key.wid, key.hour, h.requests, h.byClass[0], h.byClass[1], h.byClass[2], h.byClass[3],
+				h.durationSum, h.durationMax,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(params) / 8, nil
+}
+
+// ListWorkerRequests worker 自 filter.Since 起的请求，支持状态码过滤和游标分页（按名称排序即按路径）
+func ListWorkerRequests(ctx context.Context, wid string, filter RequestFilter, opts ListOptions) ([]*WorkerRequest, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT id, wid, method, host, path, status, duration_ms, client_ip, created_at
+		FROM worker_requests WHERE wid = $1 AND created_at >= $2`
+	args := []any{wid, filter.Since}
+	if filter.StatusMin > 0 {
+		args = append(args, filter.StatusMin, filter.StatusMax)
+		base += fmt.Sprintf(" AND status BETWEEN $%d AND $%d", len(args)-1, len(args))
+	}
+	// status 已经按状态码处理，不是 listQuery 的 status 列过滤
+	opts.Status = ""
+	query, args, err := opts.listQuery(base, args, "path")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []*WorkerRequest{}
+	for rows.Next() {
+		var r WorkerRequest
+		if err := rows.Scan(&r.ID, &r.WID, &r.Method, &r.Host, &r.Path, &r.Status, &r.DurationMs, &r.ClientIP, &r.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		items = append(items, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	items, next := trimPage(items, &opts, func(r *WorkerRequest) (string, int) {
+		return opts.cursorValue(r.CreatedAt, r.Path), r.ID
+	})
+	return items, next, nil
+}
+
+// ListWorkerRequestHours worker 自 since 起每小时的请求汇总，按时间先后，没有请求的小时不在结果里
+func ListWorkerRequestHours(ctx context.Context, wid string, since time.Time) ([]*WorkerRequestHour, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT hour, requests, status_2xx, status_3xx, status_4xx, status_5xx, duration_ms_sum, duration_ms_max
+		 FROM worker_request_hourly WHERE wid = $1 AND hour >= $2 ORDER BY hour`,
+		wid, since.Truncate(time.Hour),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := []*WorkerRequestHour{}
+	for rows.Next() {
+		var h WorkerRequestHour
+		var sum int64
+		if err := rows.Scan(&h.Hour, &h.Requests, &h.Status2xx, &h.Status3xx, &h.Status4xx, &h.Status5xx, &sum, &h.MaxDurationMs); err != nil {
+			return nil, err
+		}
+		if h.Requests > 0 {
+			h.AvgDurationMs = float64(sum) / float64(h.Requests)
+		}
+		hours = append(hours, &h)
+	}
+	return hours, rows.Err()
+}
+
+// PruneWorkerRequests 删除 before 之前的请求和 hourlyBefore 之前的小时汇总，返回删除的请求数
+func PruneWorkerRequests(ctx context.Context, before, hourlyBefore time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM worker_requests WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := DB.ExecContext(ctx, `DELETE FROM worker_request_hourly WHERE hour < $1`, hourlyBefore); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,147 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// AccountProfile 用户的资料和偏好；Locale 为空表示默认语言
+type AccountProfile struct {
+	UID           string `json:"uid"`
+	Email         string `json:"email"`
+	DisplayName   string `json:"display_name"`
+	DefaultRegion string `json:"default_region"`
+	Timezone      string `json:"timezone"`
+	Locale        string `json:"locale"`
+}
+
+// AccountProfileUpdate 要修改的资料，nil 的字段保持不变
+type AccountProfileUpdate struct {
+	DisplayName   *string
+	DefaultRegion *string
+	Timezone      *string
+	Locale        *string
+}
+
+// NotificationPreference 一种通知是否发邮件、是否调 webhook
+type NotificationPreference struct {
+	Email   bool `json:"email"`
+	Webhook bool `json:"webhook"`
+}
+
+// NotificationPreferenceUpdate 要修改的通知偏好，nil 的开关保持不变
+type NotificationPreferenceUpdate struct {
+	Email   *bool
+	Webhook *bool
+}
+
+// GetAccountProfile 读取用户资料，用户不存在时返回 ErrNotFound
+func GetAccountProfile(ctx context.Context, uid string) (*AccountProfile, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	p := AccountProfile{UID: uid}
+	err := DB.QueryRowContext(ctx,
+		`SELECT email, display_name, default_region, timezone, locale FROM users WHERE uid = $1`, uid,
+	).Scan(&p.Email, &p.DisplayName, &p.DefaultRegion, &p.Timezone, &p.Locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdateAccountProfile 在一个事务里修改资料和通知偏好，用户不存在时返回 ErrNotFound
+func UpdateAccountProfile(ctx context.Context, uid string, u AccountProfileUpdate, prefs map[string]NotificationPreferenceUpdate) error {
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE users SET display_name = COALESCE($2, display_name), default_region = COALESCE($3, default_region),
+			     timezone = COALESCE($4, timezone), locale = COALESCE($5, locale)
+			 WHERE uid = $1`,
+			uid, u.DisplayName, u.DefaultRegion, u.Timezone, u.Locale,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		for kind, p := range prefs {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO notification_preferences (user_uid, kind, email, webhook)
+				 VALUES ($1, $2, COALESCE($3, TRUE), COALESCE($4, TRUE))
+				 ON CONFLICT (user_uid, kind) DO UPDATE SET
+				     email = COALESCE($3, notification_preferences.email),
+				     webhook = COALESCE($4, notification_preferences.webhook)`,
+				uid, kind, p.Email, p.Webhook,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetNotificationPreferences 用户每种通知的偏好，NotificationKinds 里没有设置过的类型两个渠道都打开
+func GetNotificationPreferences(ctx context.Context, uid string) (map[string]NotificationPreference, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT kind, email, webhook FROM notification_preferences WHERE user_uid = $1`, uid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	prefs := make(map[string]NotificationPreference, len(NotificationKinds))
+	for _, kind := range NotificationKinds {
+		prefs[kind] = NotificationPreference{Email: true, Webhook: true}
+	}
+	for rows.Next() {
+		var kind string
+		var p NotificationPreference
+		if err := rows.Scan(&kind, &p.Email, &p.Webhook); err != nil {
+			return nil, err
+		}
+		prefs[kind] = p
+	}
+	return prefs, rows.Err()
+}
+
+// GetNotificationPreference 用户对一种通知的偏好，没有设置过或读取失败时两个渠道都打开
+func GetNotificationPreference(ctx context.Context, uid, kind string) (NotificationPreference, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var p NotificationPreference
+	err := DB.QueryRowContext(ctx,
+		`SELECT email, webhook FROM notification_preferences WHERE user_uid = $1 AND kind = $2`, uid, kind,
+	).Scan(&p.Email, &p.Webhook)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		return NotificationPreference{Email: true, Webhook: true}, err
+	}
+	return p, nil
+}
+
+// GetUserDefaultRegion 用户的默认区域，没有设置时为空字符串
+func GetUserDefaultRegion(ctx context.Context, uid string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var region string
+	err := DB.QueryRowContext(ctx, `SELECT default_region FROM users WHERE uid = $1`, uid).Scan(&region)
+	return region, err
+}
+
+// GetUserTimezone 用户的时区（IANA 名称）
+func GetUserTimezone(ctx context.Context, uid string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var tz string
+	err := DB.QueryRowContext(ctx, `SELECT timezone FROM users WHERE uid = $1`, uid).Scan(&tz)
+	return tz, err
+}
@@ -1,6 +1,8 @@
 package dblayer
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"time"
 )
@@ -8,10 +10,12 @@ import (
 // ========== User Actions ==========
 
 // GetVerificationCode 获取验证码
-func GetVerificationCode(email, code string) (int, time.Time, error) {
+func GetVerificationCode(ctx context.Context, email, code string) (int, time.Time, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var codeID int
 	var expiresAt time.Time
-	err := DB.QueryRow(
+	err := DB.QueryRowContext(ctx,
 		"SELECT id, expires_at FROM verification_codes WHERE email = $1 AND code = $2 AND used = false",
 		email, code,
 	).Scan(&codeID, &expiresAt)
@@ -19,37 +23,66 @@ func GetVerificationCode(email, code string) (int, time.Time, error) {
 }
 
 // MarkCodeUsed 标记验证码已使用
-func MarkCodeUsed(codeID int) error {
-	_, err := DB.Exec("UPDATE verification_codes SET used = true WHERE id = $1", codeID)
+func MarkCodeUsed(ctx context.Context, codeID int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx, "UPDATE verification_codes SET used = true WHERE id = $1", codeID)
 	return err
 }
 
+// ConsumeVerificationCode 把未使用的验证码标为已使用，已被使用（并发注册）时返回 ErrNotFound
+func ConsumeVerificationCode(ctx context.Context, q Querier, codeID int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := q.ExecContext(ctx, "UPDATE verification_codes SET used = true WHERE id = $1 AND used = false", codeID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // CreateUser 创建用户
-func CreateUser(uid, email, passwordHash, secretKey string) (string, error) {
+func CreateUser(ctx context.Context, q Querier, uid, email, passwordHash, secretKey, locale string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	sealedKey, err := sealColumn(colUserSecretKey, secretKey)
+	if err != nil {
+		return "", err
+	}
 	var userUID string
-	err := DB.QueryRow(
-		"INSERT INTO users (uid, email, password_hash, secret_key) VALUES ($1, $2, $3, $4) RETURNING uid",
-		uid, email, passwordHash, secretKey,
+	err = q.QueryRowContext(ctx,
+		"INSERT INTO users (uid, email, password_hash, secret_key, locale) VALUES ($1, $2, $3, $4, $5) RETURNING uid",
+		uid, email, passwordHash, sealedKey, locale,
 	).Scan(&userUID)
 	return userUID, err
 }
 
 // GetUserByEmail 通过邮箱获取用户
-func GetUserByEmail(email string) (*User, error) {
+func GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var user User
-	err := DB.QueryRow(
+	err := DB.QueryRowContext(ctx,
 		"SELECT uid, email, password_hash, secret_key FROM users WHERE email = $1",
 		email,
 	).Scan(&user.UID, &user.Email, &user.PasswordHash, &user.SecretKey)
 	if err != nil {
 		return nil, err
 	}
+	if user.SecretKey, err = openColumn(colUserSecretKey, user.SecretKey); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
 // SaveVerificationCode 保存验证码
-func SaveVerificationCode(email, code string, expiresAt time.Time) error {
-	_, err := DB.Exec(
+func SaveVerificationCode(ctx context.Context, email, code string, expiresAt time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
 		"INSERT INTO verification_codes (email, code, expires_at) VALUES ($1, $2, $3)",
 		email, code, expiresAt,
 	)
@@ -57,8 +90,10 @@ func SaveVerificationCode(email, code string, expiresAt time.Time) error {
 }
 
 // UpdateUserPassword 更新用户密码
-func UpdateUserPassword(email, passwordHash string) error {
-	_, err := DB.Exec(
+func UpdateUserPassword(ctx context.Context, email, passwordHash string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
 		"UPDATE users SET password_hash = $1 WHERE email = $2",
 		passwordHash, email,
 	)
@@ -66,18 +101,49 @@ func UpdateUserPassword(email, passwordHash string) error {
 }
 
 // GetUserSecretKey 通过 UID 获取用户密钥
-func GetUserSecretKey(uid string) (string, error) {
+func GetUserSecretKey(ctx context.Context, uid string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var secretKey string
-	err := DB.QueryRow(
+	err := DB.QueryRowContext(ctx,
 		"SELECT secret_key FROM users WHERE uid = $1",
 		uid,
 	).Scan(&secretKey)
-	return secretKey, err
+	if err != nil {
+		return "", err
+	}
+	return openColumn(colUserSecretKey, secretKey)
+}
+
+// GetUserEmail 通过 UID 获取用户邮箱
+func GetUserEmail(ctx context.Context, uid string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var email string
+	err := DB.QueryRowContext(ctx,
+		"SELECT email FROM users WHERE uid = $1",
+		uid,
+	).Scan(&email)
+	return email, err
+}
+
+// GetUserPlan 获取用户套餐，决定租户 namespace 的配额
+func GetUserPlan(ctx context.Context, uid string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var plan string
+	err := DB.QueryRowContext(ctx,
+		"SELECT plan FROM users WHERE uid = $1",
+		uid,
+	).Scan(&plan)
+	return plan, err
 }
 
 // ListUserUIDsPaged 分页获取所有用户 UID
-func ListUserUIDsPaged(limit, offset int) ([]string, error) {
-	rows, err := DB.Query(
+func ListUserUIDsPaged(ctx context.Context, limit, offset int) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
 		`SELECT uid FROM users ORDER BY uid LIMIT $1 OFFSET $2`,
 		limit, offset,
 	)
@@ -99,56 +165,80 @@ func ListUserUIDsPaged(limit, offset int) ([]string, error) {
 
 // ========== CustomDomain Actions ==========
 
-// CreateCustomDomain 创建自定义域名
-func CreateCustomDomain(cdid, userUID, domain, target, txtName, txtValue, status string) error {
-	_, err := DB.Exec(
-		`INSERT INTO custom_domains (cdid, user_uid, domain, target, txt_name, txt_value, status)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		cdid, userUID, domain, target, txtName, txtValue, status,
+// CreateCustomDomain 创建自定义域名，environment 为空时属于 DefaultEnvironment
+func CreateCustomDomain(ctx context.Context, cdid, userUID, domain, target, txtName, txtValue, status, issuer, challenge string, tags Tags, environment string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	txtValue, err := sealColumn(colDomainTXTValue, txtValue)
+	if err != nil {
+		return err
+	}
+	_, err = DB.ExecContext(ctx,
+		`INSERT INTO custom_domains (cdid, user_uid, domain, target, txt_name, txt_value, status, cert_issuer, cert_challenge, tags, environment)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		cdid, userUID, domain, target, txtName, txtValue, status, issuer, challenge, tags, cmp.Or(environment, DefaultEnvironment),
 	)
 	return err
 }
 
 // GetCustomDomain 获取自定义域名
-func GetCustomDomain(cdid string) (*CustomDomain, error) {
+func GetCustomDomain(ctx context.Context, cdid string) (*CustomDomain, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var cd CustomDomain
-	err := DB.QueryRow(
-		`SELECT id, cdid, user_uid, domain, target, txt_name, txt_value, status, created_at
+	err := DB.QueryRowContext(ctx,
+		`SELECT id, cdid, user_uid, domain, target, txt_name, txt_value, status, cert_issuer, cert_challenge, tags, environment, created_at, updated_at, version
 		 FROM custom_domains WHERE cdid = $1`,
 		cdid,
-	).Scan(&cd.ID, &cd.CDID, &cd.UserUID, &cd.Domain, &cd.Target, &cd.TXTName, &cd.TXTValue, &cd.Status, &cd.CreatedAt)
+	).Scan(&cd.ID, &cd.CDID, &cd.UserUID, &cd.Domain, &cd.Target, &cd.TXTName, &cd.TXTValue, &cd.Status, &cd.Issuer, &cd.Challenge, &cd.Tags, &cd.Environment, &cd.CreatedAt, &cd.UpdatedAt, &cd.Version)
 	if err != nil {
 		return nil, err
 	}
+	if err := cd.decryptColumns(); err != nil {
+		return nil, err
+	}
 	return &cd, nil
 }
 
-// ListCustomDomains 获取用户的所有自定义域名
-func ListCustomDomains(userUID string) ([]*CustomDomain, error) {
-	rows, err := DB.Query(
-		`SELECT id, cdid, user_uid, domain, target, txt_name, txt_value, status, created_at
-		 FROM custom_domains WHERE user_uid = $1`,
-		userUID,
+// ListCustomDomains 分页获取用户的自定义域名，返回下一页游标
+func ListCustomDomains(ctx context.Context, userUID string, opts ListOptions) ([]*CustomDomain, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	query, args, err := opts.listQuery(
+		`SELECT id, cdid, user_uid, domain, target, txt_name, txt_value, status, cert_issuer, cert_challenge, tags, environment, created_at, updated_at, version
+		 FROM custom_domains WHERE user_uid = $1`, []any{userUID}, "domain",
 	)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var domains []*CustomDomain
 	for rows.Next() {
 		var cd CustomDomain
-		if err := rows.Scan(&cd.ID, &cd.CDID, &cd.UserUID, &cd.Domain, &cd.Target, &cd.TXTName, &cd.TXTValue, &cd.Status, &cd.CreatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&cd.ID, &cd.CDID, &cd.UserUID, &cd.Domain, &cd.Target, &cd.TXTName, &cd.TXTValue, &cd.Status, &cd.Issuer, &cd.Challenge, &cd.Tags, &cd.Environment, &cd.CreatedAt, &cd.UpdatedAt, &cd.Version); err != nil {
+			return nil, "", err
+		}
+		if err := cd.decryptColumns(); err != nil {
+			return nil, "", err
 		}
 		domains = append(domains, &cd)
 	}
-	return domains, nil
+	domains, next := trimPage(domains, &opts, func(cd *CustomDomain) (string, int) {
+		return opts.cursorValue(cd.CreatedAt, cd.Domain), cd.ID
+	})
+	return domains, next, nil
 }
 
 // UpdateCustomDomainStatus 更新自定义域名状态
-func UpdateCustomDomainStatus(cdid, status string) error {
-	_, err := DB.Exec(
+func UpdateCustomDomainStatus(ctx context.Context, cdid, status string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
 		`UPDATE custom_domains SET status = $1 WHERE cdid = $2`,
 		status, cdid,
 	)
@@ -156,15 +246,19 @@ func UpdateCustomDomainStatus(cdid, status string) error {
 }
 
 // DeleteCustomDomain 删除自定义域名
-func DeleteCustomDomain(cdid string) error {
-	_, err := DB.Exec(`DELETE FROM custom_domains WHERE cdid = $1`, cdid)
+func DeleteCustomDomain(ctx context.Context, cdid string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx, `DELETE FROM custom_domains WHERE cdid = $1`, cdid)
 	return err
 }
 
 // ListAllSuccessDomains 获取所有成功状态的域名（用于定期检查）
-func ListAllSuccessDomains() ([]*CustomDomain, error) {
-	rows, err := DB.Query(
-		`SELECT id, cdid, user_uid, domain, target, txt_name, txt_value, status, created_at
+func ListAllSuccessDomains(ctx context.Context) ([]*CustomDomain, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT id, cdid, user_uid, domain, target, txt_name, txt_value, status, cert_issuer, cert_challenge, tags, environment, created_at, updated_at, version
 		 FROM custom_domains WHERE status = 'success'`,
 	)
 	if err != nil {
@@ -175,7 +269,10 @@ func ListAllSuccessDomains() ([]*CustomDomain, error) {
 	var domains []*CustomDomain
 	for rows.Next() {
 		var cd CustomDomain
-		if err := rows.Scan(&cd.ID, &cd.CDID, &cd.UserUID, &cd.Domain, &cd.Target, &cd.TXTName, &cd.TXTValue, &cd.Status, &cd.CreatedAt); err != nil {
+		if err := rows.Scan(&cd.ID, &cd.CDID, &cd.UserUID, &cd.Domain, &cd.Target, &cd.TXTName, &cd.TXTValue, &cd.Status, &cd.Issuer, &cd.Challenge, &cd.Tags, &cd.Environment, &cd.CreatedAt, &cd.UpdatedAt, &cd.Version); err != nil {
+			return nil, err
+		}
+		if err := cd.decryptColumns(); err != nil {
 			return nil, err
 		}
 		domains = append(domains, &cd)
@@ -185,37 +282,78 @@ func ListAllSuccessDomains() ([]*CustomDomain, error) {
 
 // ========== CombinatorResource Actions ==========
 
-// CreateCombinatorResource 创建 combinator 资源记录
-func CreateCombinatorResource(userUID, resourceType, resourceID string) error {
+// CreateCombinatorResource 创建 combinator 资源记录，environment 为空时属于 DefaultEnvironment
+func CreateCombinatorResource(ctx context.Context, q Querier, userUID, resourceType, resourceID, name string, tags Tags, environment string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var newID int
-	err := DB.QueryRow(
-		`INSERT INTO combinator_resources (user_uid, resource_type, resource_id)
-		 VALUES ($1, $2, $3) RETURNING id`,
-		userUID, resourceType, resourceID,
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO combinator_resources (user_uid, resource_type, resource_id, name, tags, environment)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		userUID, resourceType, resourceID, name, tags, cmp.Or(environment, DefaultEnvironment),
 	).Scan(&newID)
 	return err
 }
 
 // GetCombinatorResource 获取单个资源
-func GetCombinatorResource(userUID, resourceType, resourceID string) (*CombinatorResource, error) {
+func GetCombinatorResource(ctx context.Context, userUID, resourceType, resourceID string) (*CombinatorResource, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var cr CombinatorResource
-	err := DB.QueryRow(
-		`SELECT id, user_uid, resource_type, resource_id, status, msg, created_at
+	err := DB.QueryRowContext(ctx,
+		`SELECT id, user_uid, resource_type, resource_id, name, status, msg, tags, environment, created_at, updated_at, version
 		 FROM combinator_resources WHERE user_uid = $1 AND resource_type = $2 AND resource_id = $3`,
 		userUID, resourceType, resourceID,
-	).Scan(&cr.ID, &cr.UserUID, &cr.ResourceType, &cr.ResourceID, &cr.Status, &cr.Msg, &cr.CreatedAt)
+	).Scan(&cr.ID, &cr.UserUID, &cr.ResourceType, &cr.ResourceID, &cr.Name, &cr.Status, &cr.Msg, &cr.Tags, &cr.Environment, &cr.CreatedAt, &cr.UpdatedAt, &cr.Version)
 	if err != nil {
 		return nil, err
 	}
 	return &cr, nil
 }
 
-// ListCombinatorResources 获取用户某类型的所有资源
-func ListCombinatorResources(userUID, resourceType string) ([]*CombinatorResource, error) {
-	rows, err := DB.Query(
-		`SELECT id, user_uid, resource_type, resource_id, status, msg, created_at
-		 FROM combinator_resources WHERE user_uid = $1 AND resource_type = $2`,
-		userUID, resourceType,
+// ListCombinatorResources 分页获取用户某类型的资源，返回下一页游标
+func ListCombinatorResources(ctx context.Context, userUID string, opts ListOptions) ([]*CombinatorResource, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT id, user_uid, resource_type, resource_id, name, status, msg, tags, environment, created_at, updated_at, version
+		 FROM combinator_resources WHERE user_uid = $1`
+	args := []any{userUID}
+	if opts.Type != "" {
+		args = append(args, opts.Type)
+		base += " AND resource_type = $2"
+	}
+	query, args, err := opts.listQuery(base, args, "name")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var resources []*CombinatorResource
+	for rows.Next() {
+		var cr CombinatorResource
+		if err := rows.Scan(&cr.ID, &cr.UserUID, &cr.ResourceType, &cr.ResourceID, &cr.Name, &cr.Status, &cr.Msg, &cr.Tags, &cr.Environment, &cr.CreatedAt, &cr.UpdatedAt, &cr.Version); err != nil {
+			return nil, "", err
+		}
+		resources = append(resources, &cr)
+	}
+	resources, next := trimPage(resources, &opts, func(cr *CombinatorResource) (string, int) {
+		return opts.cursorValue(cr.CreatedAt, cr.Name), cr.ID
+	})
+	return resources, next, nil
+}
+
+// ListActiveCombinatorResources 获取用户所有 active 状态、由 combinator 提供的资源（RDB/KV/队列，不含 bucket）
+func ListActiveCombinatorResources(ctx context.Context, userUID string) ([]*CombinatorResource, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT id, user_uid, resource_type, resource_id, name, status, msg, tags, environment, created_at, updated_at, version
+		 FROM combinator_resources WHERE user_uid = $1 AND status = 'active' AND resource_type <> 'bucket'`,
+		userUID,
 	)
 	if err != nil {
 		return nil, err
@@ -225,7 +363,7 @@ func ListCombinatorResources(userUID, resourceType string) ([]*CombinatorResourc
 	var resources []*CombinatorResource
 	for rows.Next() {
 		var cr CombinatorResource
-		if err := rows.Scan(&cr.ID, &cr.UserUID, &cr.ResourceType, &cr.ResourceID, &cr.Status, &cr.Msg, &cr.CreatedAt); err != nil {
+		if err := rows.Scan(&cr.ID, &cr.UserUID, &cr.ResourceType, &cr.ResourceID, &cr.Name, &cr.Status, &cr.Msg, &cr.Tags, &cr.Environment, &cr.CreatedAt, &cr.UpdatedAt, &cr.Version); err != nil {
 			return nil, err
 		}
 		resources = append(resources, &cr)
@@ -233,12 +371,14 @@ func ListCombinatorResources(userUID, resourceType string) ([]*CombinatorResourc
 	return resources, nil
 }
 
-// ListActiveCombinatorResources 获取用户所有 active 状态的资源
-func ListActiveCombinatorResources(userUID string) ([]*CombinatorResource, error) {
-	rows, err := DB.Query(
-		`SELECT id, user_uid, resource_type, resource_id, status, msg, created_at
-		 FROM combinator_resources WHERE user_uid = $1 AND status = 'active'`,
-		userUID,
+// ListAllActiveCombinatorResources 获取所有用户某类 active 状态的资源（用于定期比对）
+func ListAllActiveCombinatorResources(ctx context.Context, resourceType string) ([]*CombinatorResource, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT id, user_uid, resource_type, resource_id, name, status, msg, tags, environment, created_at, updated_at, version
+		 FROM combinator_resources WHERE resource_type = $1 AND status = 'active'`,
+		resourceType,
 	)
 	if err != nil {
 		return nil, err
@@ -248,17 +388,19 @@ func ListActiveCombinatorResources(userUID string) ([]*CombinatorResource, error
 	var resources []*CombinatorResource
 	for rows.Next() {
 		var cr CombinatorResource
-		if err := rows.Scan(&cr.ID, &cr.UserUID, &cr.ResourceType, &cr.ResourceID, &cr.Status, &cr.Msg, &cr.CreatedAt); err != nil {
+		if err := rows.Scan(&cr.ID, &cr.UserUID, &cr.ResourceType, &cr.ResourceID, &cr.Name, &cr.Status, &cr.Msg, &cr.Tags, &cr.Environment, &cr.CreatedAt, &cr.UpdatedAt, &cr.Version); err != nil {
 			return nil, err
 		}
 		resources = append(resources, &cr)
 	}
-	return resources, nil
+	return resources, rows.Err()
 }
 
 // UpdateCombinatorResourceStatus 更新资源状态
-func UpdateCombinatorResourceStatus(userUID, resourceType, resourceID, status, msg string) error {
-	_, err := DB.Exec(
+func UpdateCombinatorResourceStatus(ctx context.Context, userUID, resourceType, resourceID, status, msg string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
 		`UPDATE combinator_resources SET status = $1, msg = $2
 		 WHERE user_uid = $3 AND resource_type = $4 AND resource_id = $5`,
 		status, msg, userUID, resourceType, resourceID,
@@ -267,16 +409,20 @@ func UpdateCombinatorResourceStatus(userUID, resourceType, resourceID, status, m
 }
 
 // DeleteCombinatorResource 删除资源记录
-func DeleteCombinatorResource(userUID, resourceType, resourceID string) error {
-	_, err := DB.Exec(
+func DeleteCombinatorResource(ctx context.Context, q Querier, userUID, resourceType, resourceID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := q.ExecContext(ctx,
 		`DELETE FROM combinator_resources WHERE user_uid = $1 AND resource_type = $2 AND resource_id = $3`,
 		userUID, resourceType, resourceID,
 	)
 	return err
 }
 
-func SaveCombinatorResourceReport(report *CombinatorResourceReport) error {
-	_, err := DB.Exec(
+func SaveCombinatorResourceReport(ctx context.Context, report *CombinatorResourceReport) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
 		`INSERT INTO combinator_resource_reports (
 		user_uid, resource_id, datachange, record_start, record_end)
 		 VALUES ($1, $2, $3, $4, $5)`,
@@ -286,7 +432,9 @@ func SaveCombinatorResourceReport(report *CombinatorResourceReport) error {
 	return err
 }
 
-func BatchSaveCombinatorResourceReports(reports []CombinatorResourceReport) error {
+func BatchSaveCombinatorResourceReports(ctx context.Context, reports []CombinatorResourceReport) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	if len(reports) == 0 {
 		return nil
 	}
@@ -304,13 +452,15 @@ func BatchSaveCombinatorResourceReports(reports []CombinatorResourceReport) erro
 		values = append(values, report.UserID, report.ResourceID, report.DataChange, report.TimespanStart, report.TimespanEnd)
 	}
 
-	_, err := DB.Exec(query, values...)
+	_, err := DB.ExecContext(ctx, query, values...)
 	return err
 }
 
-func CalculateDataChangeSum(userID, resourceType string, resourceID string) (int, error) {
+func CalculateDataChangeSum(ctx context.Context, userID, resourceType string, resourceID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var sum int
-	err := DB.QueryRow(
+	err := DB.QueryRowContext(ctx,
 		`SELECT COALESCE(SUM(datachange), 0) FROM combinator_resource_reports
 		 WHERE user_id = $1 AND resource_type = $2 AND resource_id = $3`,
 		userID, resourceType, resourceID,
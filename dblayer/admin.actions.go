@@ -0,0 +1,232 @@
+package dblayer
+
+import (
+	"context"
+	"time"
+)
+
+// TenantOverview 一个用户的资源数量，运维面板用
+type TenantOverview struct {
+	UserUID       string    `json:"user_uid"`
+	Email         string    `json:"email"`
+	Plan          string    `json:"plan"`
+	Workers       int       `json:"workers"`
+	ActiveWorkers int       `json:"active_workers"`
+	RDBs          int       `json:"rdbs"`
+	KVs           int       `json:"kvs"`
+	CustomDomains int       `json:"custom_domains"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TenantSorts ListTenantOverviews 可用的排序，都是降序
+var TenantSorts = map[string]string{
+	"workers":        "workers",
+	"custom_domains": "custom_domains",
+	"resources":      "rdbs + kvs",
+	"created_at":     "created_at",
+}
+
+// ListTenantOverviews 按 sort（TenantSorts 的键）降序列出用户及其资源数量
+func ListTenantOverviews(ctx context.Context, sort string, limit, offset int) ([]TenantOverview, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	order, ok := TenantSorts[sort]
+	if !ok {
+		order = TenantSorts["workers"]
+	}
+	rows, err := readRows(ctx,
+		`SELECT * FROM (
+		   SELECT u.uid, u.email, u.plan, COALESCE(u.created_at, CURRENT_TIMESTAMP) AS created_at,
+		     (SELECT COUNT(*) FROM workers w WHERE w.user_uid = u.uid) AS workers,
+		     (SELECT COUNT(*) FROM workers w WHERE w.user_uid = u.uid AND w.status = 'active') AS active_workers,
+		     (SELECT COUNT(*) FROM combinator_resources r WHERE r.user_uid = u.uid AND r.resource_type = 'rdb') AS rdbs,
+		     (SELECT COUNT(*) FROM combinator_resources r WHERE r.user_uid = u.uid AND r.resource_type = 'kv') AS kvs,
+		     (SELECT COUNT(*) FROM custom_domains d WHERE d.user_uid = u.uid) AS custom_domains
+		   FROM users u
+		 ) t
+		 ORDER BY `+order+` DESC, uid
+		 LIMIT $1 OFFSET $2`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tenants := []TenantOverview{}
+	for rows.Next() {
+		var t TenantOverview
+		if err := rows.Scan(&t.UserUID, &t.Email, &t.Plan, &t.CreatedAt, &t.Workers, &t.ActiveWorkers, &t.RDBs, &t.KVs, &t.CustomDomains); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// ResourceTotals 全平台的资源数量，按状态分组
+type ResourceTotals struct {
+	Users         int                       `json:"users"`
+	UsersByPlan   map[string]int            `json:"users_by_plan"`
+	Workers       map[string]int            `json:"workers"`   // 按状态
+	Resources     map[string]map[string]int `json:"resources"` // 类型 -> 状态 -> 数量
+	CustomDomains map[string]int            `json:"custom_domains"`
+}
+
+// GetResourceTotals 统计所有用户的资源
+func GetResourceTotals(ctx context.Context) (*ResourceTotals, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	t := &ResourceTotals{
+		UsersByPlan:   map[string]int{},
+		Workers:       map[string]int{},
+		Resources:     map[string]map[string]int{},
+		CustomDomains: map[string]int{},
+	}
+	count := func(query string, add func(key, sub string, n int)) error {
+		rows, err := readRows(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var key, sub string
+			var n int
+			if err := rows.Scan(&key, &sub, &n); err != nil {
+				return err
+			}
+			add(key, sub, n)
+		}
+		return rows.Err()
+	}
+	if err := count(`SELECT plan, '', COUNT(*) FROM users GROUP BY plan`, func(plan, _ string, n int) {
+		t.UsersByPlan[plan] = n
+		t.Users += n
+	}); err != nil {
+		return nil, err
+	}
+	if err := count(`SELECT status, '', COUNT(*) FROM workers GROUP BY status`, func(status, _ string, n int) {
+		t.Workers[status] = n
+	}); err != nil {
+		return nil, err
+	}
+	if err := count(`SELECT resource_type, status, COUNT(*) FROM combinator_resources GROUP BY resource_type, status`, func(typ, status string, n int) {
+		if t.Resources[typ] == nil {
+			t.Resources[typ] = map[string]int{}
+		}
+		t.Resources[typ][status] = n
+	}); err != nil {
+		return nil, err
+	}
+	if err := count(`SELECT status, '', COUNT(*) FROM custom_domains GROUP BY status`, func(status, _ string, n int) {
+		t.CustomDomains[status] = n
+	}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// UsageConsumer 一个用户在一段时间内某项指标的用量
+type UsageConsumer struct {
+	UserUID  string  `json:"user_uid"`
+	Quantity float64 `json:"quantity"`
+}
+
+// TopUsageConsumers 每项计量指标用量最多的 limit 个用户
+func TopUsageConsumers(ctx context.Context, from, to time.Time, limit int) (map[string][]UsageConsumer, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT metric, user_uid, total FROM (
+		   SELECT metric, user_uid, SUM(quantity) AS total,
+		     ROW_NUMBER() OVER (PARTITION BY metric ORDER BY SUM(quantity) DESC) AS rank
+		   FROM usage_samples
+		   WHERE period_start >= $1 AND period_start < $2
+		   GROUP BY metric, user_uid
+		 ) t
+		 WHERE rank <= $3
+		 ORDER BY metric, rank`,
+		from, to, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	top := map[string][]UsageConsumer{}
+	for rows.Next() {
+		var metric string
+		var c UsageConsumer
+		if err := rows.Scan(&metric, &c.UserUID, &c.Quantity); err != nil {
+			return nil, err
+		}
+		top[metric] = append(top[metric], c)
+	}
+	return top, rows.Err()
+}
+
+// DeployFailure 一次失败的部署
+type DeployFailure struct {
+	WorkerID  string    `json:"worker_id"`
+	UserUID   string    `json:"user_uid"`
+	VersionID int       `json:"version_id"`
+	Image     string    `json:"image"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListRecentDeployFailures 最近失败的部署，新的在前
+func ListRecentDeployFailures(ctx context.Context, limit int) ([]DeployFailure, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT w.wid, w.user_uid, v.id, v.image, v.msg, COALESCE(v.created_at, CURRENT_TIMESTAMP)
+		 FROM worker_deploy_versions v JOIN workers w ON w.id = v.worker_id
+		 WHERE v.status = 'error'
+		 ORDER BY v.id DESC LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	failures := []DeployFailure{}
+	for rows.Next() {
+		var f DeployFailure
+		if err := rows.Scan(&f.WorkerID, &f.UserUID, &f.VersionID, &f.Image, &f.Message, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// DomainFailure 验证或配置失败的自定义域名
+type DomainFailure struct {
+	CDID      string    `json:"cdid"`
+	UserUID   string    `json:"user_uid"`
+	Domain    string    `json:"domain"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListRecentDomainFailures 最近失败的自定义域名，新的在前
+func ListRecentDomainFailures(ctx context.Context, limit int) ([]DomainFailure, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT cdid, user_uid, domain, updated_at FROM custom_domains
+		 WHERE status = 'error' ORDER BY updated_at DESC LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	failures := []DomainFailure{}
+	for rows.Next() {
+		var f DomainFailure
+		if err := rows.Scan(&f.CDID, &f.UserUID, &f.Domain, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
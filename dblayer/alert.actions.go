@@ -0,0 +1,262 @@
+package dblayer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 告警指标：restarts/oom_kills 统计窗口内的次数，crash_loop 是当前处于 CrashLoopBackOff 的容器数，
+// http_5xx_rate 是窗口内 5xx 占比（百分比），p95_latency 是窗口内 p95 延迟（秒）
+const (
+	AlertMetricRestarts   = "restarts"
+	AlertMetricOOMKills   = "oom_kills"
+	AlertMetricCrashLoop  = "crash_loop"
+	AlertMetric5xxRate    = "http_5xx_rate"
+	AlertMetricP95Latency = "p95_latency"
+)
+
+// 规则状态为 ok/firing，历史记录状态为 firing/resolved
+const (
+	AlertStatusOK       = "ok"
+	AlertStatusFiring   = "firing"
+	AlertStatusResolved = "resolved"
+)
+
+// AlertRule 用户为某个 worker 配置的告警规则：Metric 的值大于 Threshold 时触发
+type AlertRule struct {
+	ID            int        `json:"id"`
+	UserUID       string     `json:"user_uid"`
+	WorkerID      string     `json:"worker_id"`
+	Name          string     `json:"name"`
+	Metric        string     `json:"metric"`
+	Threshold     float64    `json:"threshold"`
+	WindowSeconds int        `json:"window_seconds"`
+	NotifyEmail   bool       `json:"notify_email"`
+	WebhookURL    string     `json:"webhook_url"`
+	WebhookSecret string     `json:"-"`
+	Enabled       bool       `json:"enabled"`
+	Status        string     `json:"status"`
+	LastFiredAt   *time.Time `json:"last_fired_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Window 规则的统计窗口
+func (r *AlertRule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// AlertEvent 告警历史：规则每次进入 firing 或恢复为 resolved 记一条，规则删除后保留（RuleID 置空）
+type AlertEvent struct {
+	ID        int       `json:"id"`
+	RuleID    *int      `json:"rule_id"`
+	UserUID   string    `json:"user_uid"`
+	WorkerID  string    `json:"worker_id"`
+	RuleName  string    `json:"rule_name"`
+	Metric    string    `json:"metric"`
+	Status    string    `json:"status"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const alertRuleColumns = `id, user_uid, worker_id, name, metric, threshold, window_seconds, notify_email,
+	webhook_url, webhook_secret, enabled, status, last_fired_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAlertRule(row rowScanner) (*AlertRule, error) {
+	var r AlertRule
+	err := row.Scan(&r.ID, &r.UserUID, &r.WorkerID, &r.Name, &r.Metric, &r.Threshold, &r.WindowSeconds, &r.NotifyEmail,
+		&r.WebhookURL, &r.WebhookSecret, &r.Enabled, &r.Status, &r.LastFiredAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if r.WebhookSecret, err = openColumn(colAlertWebhookSecret, r.WebhookSecret); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateAlertRule 创建规则，回填 ID、状态与时间
+func CreateAlertRule(ctx context.Context, r *AlertRule) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	webhookSecret, err := sealColumn(colAlertWebhookSecret, r.WebhookSecret)
+	if err != nil {
+		return err
+	}
+	return DB.QueryRowContext(ctx,
+		`INSERT INTO alert_rules (user_uid, worker_id, name, metric, threshold, window_seconds, notify_email, webhook_url, webhook_secret, enabled)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING id, status, created_at, updated_at`,
+		r.UserUID, r.WorkerID, r.Name, r.Metric, r.Threshold, r.WindowSeconds, r.NotifyEmail, r.WebhookURL, webhookSecret, r.Enabled,
+	).Scan(&r.ID, &r.Status, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// GetAlertRuleByOwner 获取单条规则，验证归属
+func GetAlertRuleByOwner(ctx context.Context, id int, userUID string) (*AlertRule, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanAlertRule(DB.QueryRowContext(ctx,
+		`SELECT `+alertRuleColumns+` FROM alert_rules WHERE id = $1 AND user_uid = $2`, id, userUID,
+	))
+}
+
+// ListAlertRules 分页获取用户的规则，workerID 非空时只看该 worker
+func ListAlertRules(ctx context.Context, userUID, workerID string, opts ListOptions) ([]*AlertRule, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE user_uid = $1`
+	args := []any{userUID}
+	if workerID != "" {
+		args = append(args, workerID)
+		base += fmt.Sprintf(" AND worker_id = $%d", len(args))
+	}
+	query, args, err := opts.listQuery(base, args, "name")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var rules []*AlertRule
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	rules, next := trimPage(rules, &opts, func(r *AlertRule) (string, int) {
+		return opts.cursorValue(r.CreatedAt, r.Name), r.ID
+	})
+	return rules, next, nil
+}
+
+// UpdateAlertRuleByOwner 更新规则配置，验证归属；停用时状态重置为 ok
+func UpdateAlertRuleByOwner(ctx context.Context, r *AlertRule) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	webhookSecret, err := sealColumn(colAlertWebhookSecret, r.WebhookSecret)
+	if err != nil {
+		return err
+	}
+	return DB.QueryRowContext(ctx,
+		`UPDATE alert_rules SET worker_id = $1, name = $2, metric = $3, threshold = $4, window_seconds = $5, notify_email = $6,
+		        webhook_url = $7, webhook_secret = $8, enabled = $9,
+		        status = CASE WHEN $9 THEN status ELSE 'ok' END
+		 WHERE id = $10 AND user_uid = $11
+		 RETURNING status, last_fired_at, created_at, updated_at`,
+		r.WorkerID, r.Name, r.Metric, r.Threshold, r.WindowSeconds, r.NotifyEmail, r.WebhookURL, webhookSecret, r.Enabled, r.ID, r.UserUID,
+	).Scan(&r.Status, &r.LastFiredAt, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// DeleteAlertRuleByOwner 删除规则，验证归属；历史记录保留
+func DeleteAlertRuleByOwner(ctx context.Context, id int, userUID string) error {
+	return execOwned(ctx, `DELETE FROM alert_rules WHERE id = $1 AND user_uid = $2`, id, userUID)
+}
+
+// ListEnabledAlertRules 所有启用的规则，供 inner 定期评估
+func ListEnabledAlertRules(ctx context.Context) ([]*AlertRule, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules WHERE enabled ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*AlertRule
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// TransitionAlertRule 把规则从 from 切换到 to，只有状态确实是 from 时才成功。
+// 多个 inner 实例同时评估时只有一个会拿到 true，由它负责记录历史和发通知
+func TransitionAlertRule(ctx context.Context, id int, from, to string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx,
+		`UPDATE alert_rules SET status = $1,
+		        last_fired_at = CASE WHEN $1 = 'firing' THEN CURRENT_TIMESTAMP ELSE last_fired_at END
+		 WHERE id = $2 AND status = $3 AND enabled`,
+		to, id, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CreateAlertEvent 记录一次告警状态变化
+func CreateAlertEvent(ctx context.Context, ev *AlertEvent) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return DB.QueryRowContext(ctx,
+		`INSERT INTO alert_events (rule_id, user_uid, worker_id, rule_name, metric, status, value, threshold, message)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id, created_at`,
+		ev.RuleID, ev.UserUID, ev.WorkerID, ev.RuleName, ev.Metric, ev.Status, ev.Value, ev.Threshold, ev.Message,
+	).Scan(&ev.ID, &ev.CreatedAt)
+}
+
+// ListAlertEvents 分页获取用户的告警历史，ruleID > 0 或 workerID 非空时按其过滤
+func ListAlertEvents(ctx context.Context, userUID string, ruleID int, workerID string, opts ListOptions) ([]*AlertEvent, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT id, rule_id, user_uid, worker_id, rule_name, metric, status, value, threshold, message, created_at
+		 FROM alert_events WHERE user_uid = $1`
+	args := []any{userUID}
+	if ruleID > 0 {
+		args = append(args, ruleID)
+		base += fmt.Sprintf(" AND rule_id = $%d", len(args))
+	}
+	if workerID != "" {
+		args = append(args, workerID)
+		base += fmt.Sprintf(" AND worker_id = $%d", len(args))
+	}
+	query, args, err := opts.listQuery(base, args, "rule_name")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var events []*AlertEvent
+	for rows.Next() {
+		var ev AlertEvent
+		if err := rows.Scan(&ev.ID, &ev.RuleID, &ev.UserUID, &ev.WorkerID, &ev.RuleName, &ev.Metric, &ev.Status,
+			&ev.Value, &ev.Threshold, &ev.Message, &ev.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		events = append(events, &ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	events, next := trimPage(events, &opts, func(ev *AlertEvent) (string, int) {
+		return opts.cursorValue(ev.CreatedAt, ev.RuleName), ev.ID
+	})
+	return events, next, nil
+}
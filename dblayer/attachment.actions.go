@@ -0,0 +1,137 @@
+package dblayer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrAttachmentExists 资源已挂载到该 worker，或 env 名已被另一个挂载占用
+var ErrAttachmentExists = errors.New("attachment already exists")
+
+// WorkerAttachment 挂载到 worker 的托管资源，连接串以 EnvName 注入 worker 的 Secret
+type WorkerAttachment struct {
+	WorkerID     string    `json:"worker_id"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   string    `json:"resource_id"`
+	EnvName      string    `json:"env_name"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AttachWorkerResource 把用户的资源挂载到 worker；worker 或资源不存在、不属于该用户时返回 ErrNotFound。
+// q 可以是事务，以便和同步任务一起写入
+func AttachWorkerResource(ctx context.Context, q Querier, workerID, userUID, resourceType, resourceID, envName string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := q.ExecContext(ctx,
+		`INSERT INTO worker_attachments (worker_id, resource_id, env_name)
+		 SELECT w.wid, r.id, $5 FROM workers w
+		 JOIN combinator_resources r ON r.user_uid = w.user_uid AND r.resource_type = $3 AND r.resource_id = $4
+		 WHERE w.wid = $1 AND w.user_uid = $2`,
+		workerID, userUID, resourceType, resourceID, envName,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrAttachmentExists
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DetachWorkerResource 取消挂载，没有该挂载时返回 ErrNotFound
+func DetachWorkerResource(ctx context.Context, q Querier, workerID, userUID, resourceType, resourceID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := q.ExecContext(ctx,
+		`DELETE FROM worker_attachments a USING combinator_resources r
+		 WHERE a.resource_id = r.id AND a.worker_id = $1
+		   AND r.user_uid = $2 AND r.resource_type = $3 AND r.resource_id = $4`,
+		workerID, userUID, resourceType, resourceID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListWorkerAttachments 获取 worker 的挂载，按创建时间排序
+func ListWorkerAttachments(ctx context.Context, workerID, userUID string) ([]*WorkerAttachment, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT a.worker_id, r.resource_type, r.resource_id, a.env_name, a.created_at
+		 FROM worker_attachments a JOIN combinator_resources r ON r.id = a.resource_id
+		 WHERE a.worker_id = $1 AND r.user_uid = $2
+		 ORDER BY a.created_at, a.env_name`,
+		workerID, userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attachments := []*WorkerAttachment{}
+	for rows.Next() {
+		var a WorkerAttachment
+		if err := rows.Scan(&a.WorkerID, &a.ResourceType, &a.ResourceID, &a.EnvName, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, &a)
+	}
+	return attachments, rows.Err()
+}
+
+// ListAttachedWorkers 获取挂载了某个资源的 worker ID
+func ListAttachedWorkers(ctx context.Context, userUID, resourceType, resourceID string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT a.worker_id FROM worker_attachments a JOIN combinator_resources r ON r.id = a.resource_id
+		 WHERE r.user_uid = $1 AND r.resource_type = $2 AND r.resource_id = $3 ORDER BY a.worker_id`,
+		userUID, resourceType, resourceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	wids := []string{}
+	for rows.Next() {
+		var wid string
+		if err := rows.Scan(&wid); err != nil {
+			return nil, err
+		}
+		wids = append(wids, wid)
+	}
+	return wids, rows.Err()
+}
+
+// ListAttachmentOwners 获取所有有挂载的 worker，返回 worker ID → 所属用户（启动时刷新连接串）
+func ListAttachmentOwners(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT DISTINCT w.wid, w.user_uid FROM worker_attachments a JOIN workers w ON w.wid = a.worker_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	owners := map[string]string{}
+	for rows.Next() {
+		var wid, uid string
+		if err := rows.Scan(&wid, &uid); err != nil {
+			return nil, err
+		}
+		owners[wid] = uid
+	}
+	return owners, rows.Err()
+}
@@ -0,0 +1,209 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 账号安全事件类型
+const (
+	AuthEventLoginSuccess    = "login_success"
+	AuthEventLoginFailed     = "login_failed"
+	AuthEventStepUpRequired  = "step_up_required"
+	AuthEventStepUpFailed    = "step_up_failed"
+	AuthEventThrottled       = "throttled"
+	AuthEventRegister        = "register"
+	AuthEventDeviceForgotten = "device_forgotten"
+	AuthEventSecretRevealed  = "secret_revealed"
+)
+
+// AuthEvent 一次账号安全事件（登录、设备、密钥查看）
+type AuthEvent struct {
+	ID        int       `json:"id"`
+	UserUID   *string   `json:"-"`
+	Email     string    `json:"-"`
+	Event     string    `json:"event"`
+	Subject   string    `json:"subject,omitempty"` // 设备 ID，或 reveal 的对象
+	IP        string    `json:"ip"`
+	Country   string    `json:"country,omitempty"`
+	UserAgent string    `json:"user_agent"`
+	Risk      int       `json:"risk"`
+	Reasons   []string  `json:"reasons"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserDevice 用户登录过的设备
+type UserDevice struct {
+	ID          string    `json:"id"` // 设备 cookie 的 sha256
+	UserAgent   string    `json:"user_agent"`
+	LastIP      string    `json:"last_ip"`
+	LastCountry string    `json:"last_country,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// RecordAuthEvent 记录安全事件，userUID 为空表示邮箱不存在
+func RecordAuthEvent(ctx context.Context, ev *AuthEvent) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if ev.Reasons == nil {
+		ev.Reasons = []string{}
+	}
+	return DB.QueryRowContext(ctx,
+		`INSERT INTO auth_events (user_uid, email, event, subject, ip, country, user_agent, risk, reasons)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`,
+		ev.UserUID, ev.Email, ev.Event, ev.Subject, ev.IP, ev.Country, ev.UserAgent, ev.Risk, pq.Array(ev.Reasons),
+	).Scan(&ev.ID, &ev.CreatedAt)
+}
+
+// CountAuthFailures 统计 since 之后该邮箱和该 IP 的登录失败次数
+func CountAuthFailures(ctx context.Context, email, ip string, since time.Time) (byEmail, byIP int, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err = DB.QueryRowContext(ctx,
+		`SELECT
+		     (SELECT COUNT(*) FROM auth_events WHERE email = $1 AND event IN ($3, $4) AND created_at > $5),
+		     (SELECT COUNT(*) FROM auth_events WHERE ip = $2 AND event IN ($3, $4) AND created_at > $5)`,
+		email, ip, AuthEventLoginFailed, AuthEventStepUpFailed, since,
+	).Scan(&byEmail, &byIP)
+	return byEmail, byIP, err
+}
+
+// LastLogin 用户最近一次成功登录，没有时返回 nil
+func LastLogin(ctx context.Context, userUID string) (*AuthEvent, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var ev AuthEvent
+	err := DB.QueryRowContext(ctx,
+		`SELECT id, ip, country, user_agent, created_at FROM auth_events
+		 WHERE user_uid = $1 AND event = $2 ORDER BY id DESC LIMIT 1`,
+		userUID, AuthEventLoginSuccess,
+	).Scan(&ev.ID, &ev.IP, &ev.Country, &ev.UserAgent, &ev.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// ListAuthEvents 分页列出用户的安全事件，event 非空时只列该类型
+func ListAuthEvents(ctx context.Context, userUID, event string, opts ListOptions) ([]*AuthEvent, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT id, event, subject, ip, country, user_agent, risk, reasons, created_at
+		 FROM auth_events WHERE user_uid = $1`
+	args := []any{userUID}
+	if event != "" {
+		args = append(args, event)
+		base += fmt.Sprintf(" AND event = $%d", len(args))
+	}
+	query, args, err := opts.listQuery(base, args, "event")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var events []*AuthEvent
+	for rows.Next() {
+		var ev AuthEvent
+		if err := rows.Scan(&ev.ID, &ev.Event, &ev.Subject, &ev.IP, &ev.Country, &ev.UserAgent, &ev.Risk,
+			pq.Array(&ev.Reasons), &ev.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		events = append(events, &ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	events, next := trimPage(events, &opts, func(ev *AuthEvent) (string, int) {
+		return opts.cursorValue(ev.CreatedAt, ev.Event), ev.ID
+	})
+	return events, next, nil
+}
+
+// GetUserDevice 获取用户的设备，不存在时返回 ErrNotFound
+func GetUserDevice(ctx context.Context, userUID, device string) (*UserDevice, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var d UserDevice
+	err := DB.QueryRowContext(ctx,
+		`SELECT device, user_agent, last_ip, last_country, first_seen, last_seen
+		 FROM user_devices WHERE user_uid = $1 AND device = $2`,
+		userUID, device,
+	).Scan(&d.ID, &d.UserAgent, &d.LastIP, &d.LastCountry, &d.FirstSeen, &d.LastSeen)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return &d, err
+}
+
+// CountUserDevices 用户记录过的设备数
+func CountUserDevices(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_devices WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
+
+// TouchUserDevice 记录一次从该设备的登录
+func TouchUserDevice(ctx context.Context, userUID, device, userAgent, ip, country string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO user_devices (user_uid, device, user_agent, last_ip, last_country)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (user_uid, device) DO UPDATE SET user_agent = EXCLUDED.user_agent,
+		     last_ip = EXCLUDED.last_ip, last_country = EXCLUDED.last_country, last_seen = CURRENT_TIMESTAMP`,
+		userUID, device, userAgent, ip, country,
+	)
+	return err
+}
+
+// ListUserDevices 列出用户的设备，最近使用的在前
+func ListUserDevices(ctx context.Context, userUID string) ([]UserDevice, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT device, user_agent, last_ip, last_country, first_seen, last_seen
+		 FROM user_devices WHERE user_uid = $1 ORDER BY last_seen DESC`,
+		userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []UserDevice{}
+	for rows.Next() {
+		var d UserDevice
+		if err := rows.Scan(&d.ID, &d.UserAgent, &d.LastIP, &d.LastCountry, &d.FirstSeen, &d.LastSeen); err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// DeleteUserDevice 忘记设备，之后从它登录视为新设备；不存在时返回 ErrNotFound
+func DeleteUserDevice(ctx context.Context, userUID, device string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM user_devices WHERE user_uid = $1 AND device = $2`, userUID, device)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Subscription 用户的 Stripe 订阅。Plan 同步到 users.plan，决定套餐限制
+type Subscription struct {
+	UserUID          string     `json:"-"`
+	CustomerID       string     `json:"customer_id,omitempty"`
+	SubscriptionID   string     `json:"subscription_id,omitempty"`
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"` // Stripe 订阅状态，没有订阅时为空
+	CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
+	PastDueSince     *time.Time `json:"past_due_since,omitempty"` // 第一次扣款失败的时间，付清后清空
+	WorkersSuspended bool       `json:"workers_suspended"`        // 宽限期过后 worker 被暂停
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+const subscriptionColumns = `user_uid, stripe_customer_id, stripe_subscription_id, plan, status, current_period_end, past_due_since, workers_suspended, updated_at`
+
+func scanSubscription(row interface{ Scan(...any) error }) (*Subscription, error) {
+	var s Subscription
+	err := row.Scan(&s.UserUID, &s.CustomerID, &s.SubscriptionID, &s.Plan, &s.Status, &s.CurrentPeriodEnd, &s.PastDueSince, &s.WorkersSuspended, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetSubscription 用户的订阅，没有时返回 ErrNotFound
+func GetSubscription(ctx context.Context, userUID string) (*Subscription, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanSubscription(DB.QueryRowContext(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE user_uid = $1`, userUID))
+}
+
+// SubscriptionUserByCustomer Stripe customer 对应的用户
+func SubscriptionUserByCustomer(ctx context.Context, customerID string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var uid string
+	err := DB.QueryRowContext(ctx, `SELECT user_uid FROM subscriptions WHERE stripe_customer_id = $1`, customerID).Scan(&uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return uid, err
+}
+
+// LinkStripeCustomer Checkout 完成后记下用户的 customer 和订阅
+func LinkStripeCustomer(ctx context.Context, userUID, customerID, subscriptionID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO subscriptions (user_uid, stripe_customer_id, stripe_subscription_id)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (user_uid) DO UPDATE SET
+		   stripe_customer_id = EXCLUDED.stripe_customer_id,
+		   stripe_subscription_id = EXCLUDED.stripe_subscription_id,
+		   updated_at = CURRENT_TIMESTAMP`,
+		userUID, customerID, subscriptionID,
+	)
+	return err
+}
+
+// SyncSubscription 记录订阅状态并把套餐同步到 users.plan（同一事务）
+func SyncSubscription(ctx context.Context, userUID, customerID, subscriptionID, plan, status string, periodEnd *time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO subscriptions (user_uid, stripe_customer_id, stripe_subscription_id, plan, status, current_period_end)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (user_uid) DO UPDATE SET
+		   stripe_customer_id = EXCLUDED.stripe_customer_id,
+		   stripe_subscription_id = EXCLUDED.stripe_subscription_id,
+		   plan = EXCLUDED.plan, status = EXCLUDED.status,
+		   current_period_end = EXCLUDED.current_period_end,
+		   updated_at = CURRENT_TIMESTAMP`,
+		userUID, customerID, subscriptionID, plan, status, periodEnd,
+	)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE users SET plan = $1 WHERE uid = $2`, plan, userUID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// MarkPastDue 扣款失败，记下第一次失败的时间；返回对应的用户
+func MarkPastDue(ctx context.Context, customerID string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var uid string
+	err := DB.QueryRowContext(ctx,
+		`UPDATE subscriptions SET past_due_since = COALESCE(past_due_since, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		 WHERE stripe_customer_id = $1 RETURNING user_uid`,
+		customerID,
+	).Scan(&uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return uid, err
+}
+
+// ClearPastDue 付款成功，清除欠费；返回对应的用户
+func ClearPastDue(ctx context.Context, customerID string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var uid string
+	err := DB.QueryRowContext(ctx,
+		`UPDATE subscriptions SET past_due_since = NULL, updated_at = CURRENT_TIMESTAMP
+		 WHERE stripe_customer_id = $1 RETURNING user_uid`,
+		customerID,
+	).Scan(&uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return uid, err
+}
+
+// ListDunning 需要暂停（欠费超过宽限期且还没暂停）和需要恢复（已暂停但欠费已清）的用户
+func ListDunning(ctx context.Context, grace time.Duration) (suspend, restore []string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT user_uid, workers_suspended FROM subscriptions
+		 WHERE (NOT workers_suspended AND past_due_since < $1)
+		    OR (workers_suspended AND past_due_since IS NULL)`,
+		time.Now().Add(-grace),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uid string
+		var suspended bool
+		if err := rows.Scan(&uid, &suspended); err != nil {
+			return nil, nil, err
+		}
+		if suspended {
+			restore = append(restore, uid)
+		} else {
+			suspend = append(suspend, uid)
+		}
+	}
+	return suspend, restore, rows.Err()
+}
+
+// SetWorkersSuspended 记录用户的 worker 是否因欠费被暂停
+func SetWorkersSuspended(ctx context.Context, userUID string, suspended bool) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE subscriptions SET workers_suspended = $1, updated_at = CURRENT_TIMESTAMP WHERE user_uid = $2`,
+		suspended, userUID,
+	)
+	return err
+}
+
+// WorkersSuspended 用户的 worker 是否因欠费被暂停，没有订阅的用户不会被暂停
+func WorkersSuspended(ctx context.Context, userUID string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var suspended bool
+	err := DB.QueryRowContext(ctx, `SELECT workers_suspended FROM subscriptions WHERE user_uid = $1`, userUID).Scan(&suspended)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return suspended, err
+}
+
+// ListWorkerIDsByStatus 用户处于某状态的 worker
+func ListWorkerIDsByStatus(ctx context.Context, userUID, status string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT wid FROM workers WHERE user_uid = $1 AND status = $2 ORDER BY id`, userUID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// StripeEventProcessed webhook 事件是否已处理过（Stripe 会重发）
+func StripeEventProcessed(ctx context.Context, eventID string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var exists bool
+	err := DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM stripe_events WHERE id = $1)`, eventID).Scan(&exists)
+	return exists, err
+}
+
+// MarkStripeEventProcessed 记下已处理的 webhook 事件
+func MarkStripeEventProcessed(ctx context.Context, eventID, eventType string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO stripe_events (id, type) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`,
+		eventID, eventType,
+	)
+	return err
+}
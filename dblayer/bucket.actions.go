@@ -0,0 +1,218 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 对象存储 bucket：combinator_resources 里 resource_type = 'bucket' 的资源，
+// 存储侧的信息（实际的 bucket 名、临时凭据、生命周期规则、最近一次测量的用量）在 object_buckets
+
+// BucketLifecycleRule bucket 的一条生命周期规则，至少要有一个动作
+type BucketLifecycleRule struct {
+	ID                        string `json:"id" binding:"required,max=255"`
+	Prefix                    string `json:"prefix,omitempty" binding:"max=1024"`                             // 为空时作用于整个 bucket
+	ExpirationDays            int    `json:"expiration_days,omitempty" binding:"min=0,max=36500"`             // 对象创建多少天后删除
+	AbortIncompleteUploadDays int    `json:"abort_incomplete_upload_days,omitempty" binding:"min=0,max=3650"` // 未完成的分片上传多少天后清理
+}
+
+// ObjectBucket 一个 bucket 资源在存储侧的状态，凭据只在 inner 注入 worker 时读取
+type ObjectBucket struct {
+	ResourceID   string                `json:"resource_id"`
+	BucketName   string                `json:"bucket_name"` // 创建完成前为空
+	Lifecycle    []BucketLifecycleRule `json:"lifecycle"`
+	SizeBytes    int64                 `json:"size_bytes"`
+	ObjectCount  int64                 `json:"object_count"`
+	MeasuredAt   *time.Time            `json:"measured_at,omitempty"`
+	KeyExpiresAt *time.Time            `json:"key_expires_at,omitempty"`
+
+	AccessKeyID     string `json:"-"`
+	SecretAccessKey string `json:"-"`
+	SessionToken    string `json:"-"`
+}
+
+// BucketRef 定期任务（用量测量、凭据轮换）处理的 bucket
+type BucketRef struct {
+	UserUID    string
+	ResourceID string
+	BucketName string
+}
+
+// CreateObjectBucket 和 bucket 资源记录一起写入存储侧的行，q 是创建资源的事务
+func CreateObjectBucket(ctx context.Context, q Querier, userUID, resourceID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO object_buckets (resource_id)
+		 SELECT id FROM combinator_resources WHERE user_uid = $1 AND resource_type = 'bucket' AND resource_id = $2`,
+		userUID, resourceID,
+	)
+	return err
+}
+
+// GetObjectBucket 获取 bucket 的存储侧状态（凭据已解密），没有时返回 ErrNotFound
+func GetObjectBucket(ctx context.Context, userUID, resourceID string) (*ObjectBucket, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	b := ObjectBucket{ResourceID: resourceID}
+	var name sql.NullString
+	var lifecycle []byte
+	var measuredAt, keyExpiresAt sql.NullTime
+	err := DB.QueryRowContext(ctx,
+		`SELECT b.bucket_name, b.lifecycle, b.access_key_id, b.secret_access_key, b.session_token,
+		        b.key_expires_at, b.size_bytes, b.object_count, b.measured_at
+		 FROM object_buckets b JOIN combinator_resources r ON r.id = b.resource_id
+		 WHERE r.user_uid = $1 AND r.resource_type = 'bucket' AND r.resource_id = $2`,
+		userUID, resourceID,
+	).Scan(&name, &lifecycle, &b.AccessKeyID, &b.SecretAccessKey, &b.SessionToken,
+		&keyExpiresAt, &b.SizeBytes, &b.ObjectCount, &measuredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.BucketName = name.String
+	if err := json.Unmarshal(lifecycle, &b.Lifecycle); err != nil {
+		return nil, err
+	}
+	if measuredAt.Valid {
+		b.MeasuredAt = &measuredAt.Time
+	}
+	if keyExpiresAt.Valid {
+		b.KeyExpiresAt = &keyExpiresAt.Time
+	}
+	if b.SecretAccessKey, err = openColumn(colBucketSecretKey, b.SecretAccessKey); err != nil {
+		return nil, err
+	}
+	if b.SessionToken, err = openColumn(colBucketSessionToken, b.SessionToken); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SetObjectBucketName 记下 inner 创建的 bucket 名
+func SetObjectBucketName(ctx context.Context, userUID, resourceID, name string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE object_buckets b SET bucket_name = $3 FROM combinator_resources r
+		 WHERE r.id = b.resource_id AND r.user_uid = $1 AND r.resource_type = 'bucket' AND r.resource_id = $2`,
+		userUID, resourceID, name,
+	)
+	return err
+}
+
+// SetBucketLifecycle 在 tx 里替换 bucket 的生命周期规则，expectedVersion 为 0 时不检查版本，返回资源的新版本
+func SetBucketLifecycle(ctx context.Context, tx *sql.Tx, userUID, resourceID string, rules []BucketLifecycleRule, expectedVersion int) (int, error) {
+	if rules == nil {
+		rules = []BucketLifecycleRule{}
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return 0, err
+	}
+	version, err := updateVersioned(ctx, tx,
+		`UPDATE combinator_resources SET version = version + 1
+		 WHERE user_uid = $1 AND resource_type = 'bucket' AND resource_id = $2 AND ($3 = 0 OR version = $3) RETURNING version`,
+		[]any{userUID, resourceID, expectedVersion},
+		`SELECT version FROM combinator_resources WHERE user_uid = $1 AND resource_type = 'bucket' AND resource_id = $2`,
+		userUID, resourceID)
+	if err != nil {
+		return version, err
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err = tx.ExecContext(ctx,
+		`UPDATE object_buckets b SET lifecycle = $3 FROM combinator_resources r
+		 WHERE r.id = b.resource_id AND r.user_uid = $1 AND r.resource_type = 'bucket' AND r.resource_id = $2`,
+		userUID, resourceID, string(data),
+	)
+	return version, err
+}
+
+// SetBucketCredentials 保存 bucket 新签发的临时凭据
+func SetBucketCredentials(ctx context.Context, userUID, resourceID, accessKeyID, secretAccessKey, sessionToken string, expiresAt time.Time) error {
+	sealedSecret, err := sealColumn(colBucketSecretKey, secretAccessKey)
+	if err != nil {
+		return err
+	}
+	sealedToken, err := sealColumn(colBucketSessionToken, sessionToken)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err = DB.ExecContext(ctx,
+		`UPDATE object_buckets b SET access_key_id = $3, secret_access_key = $4, session_token = $5, key_expires_at = $6
+		 FROM combinator_resources r
+		 WHERE r.id = b.resource_id AND r.user_uid = $1 AND r.resource_type = 'bucket' AND r.resource_id = $2`,
+		userUID, resourceID, accessKeyID, sealedSecret, sealedToken, expiresAt,
+	)
+	return err
+}
+
+// SetBucketUsage 记下测量到的已用字节和对象数
+func SetBucketUsage(ctx context.Context, userUID, resourceID string, sizeBytes, objectCount int64) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE object_buckets b SET size_bytes = $3, object_count = $4, measured_at = CURRENT_TIMESTAMP
+		 FROM combinator_resources r
+		 WHERE r.id = b.resource_id AND r.user_uid = $1 AND r.resource_type = 'bucket' AND r.resource_id = $2`,
+		userUID, resourceID, sizeBytes, objectCount,
+	)
+	return err
+}
+
+// ListActiveBuckets 所有已创建的 active bucket
+func ListActiveBuckets(ctx context.Context) ([]BucketRef, error) {
+	return listBuckets(ctx, `TRUE`)
+}
+
+// ListBucketsForKeyRotation 凭据在 before 之前过期（或还没有凭据）的 active bucket
+func ListBucketsForKeyRotation(ctx context.Context, before time.Time) ([]BucketRef, error) {
+	return listBuckets(ctx, `(b.key_expires_at IS NULL OR b.key_expires_at < $1)`, before)
+}
+
+func listBuckets(ctx context.Context, cond string, args ...any) ([]BucketRef, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT r.user_uid, r.resource_id, b.bucket_name
+		 FROM object_buckets b JOIN combinator_resources r ON r.id = b.resource_id
+		 WHERE r.status = 'active' AND b.bucket_name IS NOT NULL AND `+cond+`
+		 ORDER BY r.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BucketRef
+	for rows.Next() {
+		var b BucketRef
+		if err := rows.Scan(&b.UserUID, &b.ResourceID, &b.BucketName); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// SumBucketBytes 用户所有 bucket 最近一次测量的已用字节之和
+func SumBucketBytes(ctx context.Context, userUID string) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int64
+	err := DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(b.size_bytes), 0)
+		 FROM object_buckets b JOIN combinator_resources r ON r.id = b.resource_id
+		 WHERE r.user_uid = $1`,
+		userUID,
+	).Scan(&n)
+	return n, err
+}
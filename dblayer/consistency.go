@@ -0,0 +1,189 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// 一致性检查：找出外键约束（0005_foreign_keys）加上之前留下的孤儿行。
+// 只有纯数据的问题会被修复；孤儿 worker、域名、combinator 资源背后还有集群对象，
+// 要先由删除任务清理，这里只报告不删除。某个约束的孤儿清空后把它 VALIDATE
+
+// 一致性问题的类型
+const (
+	InconsistencyOrphanWorker      = "orphan_worker"
+	InconsistencyOrphanDomain      = "orphan_domain"
+	InconsistencyOrphanResource    = "orphan_combinator_resource"
+	InconsistencyOrphanReport      = "orphan_resource_report"
+	InconsistencyDanglingActiveVer = "dangling_active_version"
+)
+
+// Inconsistency.Repair 的取值
+const (
+	RepairManual    = "manual"     // 背后有集群对象，先用删除任务清理
+	RepairAvailable = "repairable" // 修复时会自动处理
+	RepairDone      = "repaired"
+)
+
+const (
+	// 所属用户不存在，%s 是表的别名
+	unknownOwnerFilter = `NOT EXISTS (SELECT 1 FROM users u WHERE u.uid = %s.user_uid)`
+	// active 版本不存在或属于别的 worker
+	danglingVersionFilter = `w.active_version_id IS NOT NULL AND NOT EXISTS (
+		SELECT 1 FROM worker_deploy_versions v WHERE v.id = w.active_version_id AND v.worker_id = w.id)`
+)
+
+// Inconsistency 一行不一致的数据
+type Inconsistency struct {
+	Check   string `json:"check"`
+	ID      string `json:"id"` // wid、cdid、resource_id 或报告的行号
+	UserUID string `json:"user_uid"`
+	Repair  string `json:"repair"` // manual、repairable、repaired
+}
+
+type consistencyCheck struct {
+	name       string
+	table      string
+	constraint string // 这类问题清空后可以 VALIDATE 的约束
+	find       string // $1 是 LIMIT，返回 id, user_uid
+	repair     string // 修复语句，RETURNING id, user_uid；空表示只能人工处理
+}
+
+var consistencyChecks = []consistencyCheck{
+	{
+		name:       InconsistencyOrphanWorker,
+		table:      "workers",
+		constraint: "fk_workers_user",
+		find:       `SELECT w.wid, w.user_uid FROM workers w WHERE ` + fmt.Sprintf(unknownOwnerFilter, "w") + ` ORDER BY w.id LIMIT $1`,
+	},
+	{
+		name:       InconsistencyOrphanDomain,
+		table:      "custom_domains",
+		constraint: "fk_custom_domains_user",
+		find:       `SELECT d.cdid, d.user_uid FROM custom_domains d WHERE ` + fmt.Sprintf(unknownOwnerFilter, "d") + ` ORDER BY d.id LIMIT $1`,
+	},
+	{
+		name:       InconsistencyOrphanResource,
+		table:      "combinator_resources",
+		constraint: "fk_combinator_resources_user",
+		find:       `SELECT r.resource_id, r.user_uid FROM combinator_resources r WHERE ` + fmt.Sprintf(unknownOwnerFilter, "r") + ` ORDER BY r.id LIMIT $1`,
+	},
+	{
+		name:       InconsistencyOrphanReport,
+		table:      "combinator_resource_reports",
+		constraint: "fk_combinator_resource_reports_user",
+		find:       `SELECT r.id::text, r.user_uid FROM combinator_resource_reports r WHERE ` + fmt.Sprintf(unknownOwnerFilter, "r") + ` ORDER BY r.id LIMIT $1`,
+		repair:     `DELETE FROM combinator_resource_reports r WHERE ` + fmt.Sprintf(unknownOwnerFilter, "r") + ` RETURNING r.id::text, r.user_uid`,
+	},
+	{
+		// 指向不存在或别的 worker 的版本，清空后 worker 回到未部署状态，重新部署即可
+		name:       InconsistencyDanglingActiveVer,
+		table:      "workers",
+		constraint: "fk_workers_active_version",
+		find:       `SELECT w.wid, w.user_uid FROM workers w WHERE ` + danglingVersionFilter + ` ORDER BY w.id LIMIT $1`,
+		repair:     `UPDATE workers w SET active_version_id = NULL WHERE ` + danglingVersionFilter + ` RETURNING w.wid, w.user_uid`,
+	},
+}
+
+// FindInconsistencies 每类问题最多返回 limit 行，truncated 里是超出 limit 的类型
+func FindInconsistencies(ctx context.Context, limit int) (items []Inconsistency, truncated []string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	items = []Inconsistency{}
+	for _, check := range consistencyChecks {
+		repair := RepairManual
+		if check.repair != "" {
+			repair = RepairAvailable
+		}
+		found, err := scanInconsistencies(ctx, DB, check.name, repair, check.find, limit+1)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", check.name, err)
+		}
+		if len(found) > limit {
+			found = found[:limit]
+			truncated = append(truncated, check.name)
+		}
+		items = append(items, found...)
+	}
+	return items, truncated, nil
+}
+
+// RepairInconsistencies 在一个事务里修复所有可以自动修复的问题，返回修复的行
+func RepairInconsistencies(ctx context.Context) ([]Inconsistency, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	repaired := []Inconsistency{}
+	err := WithTx(ctx, func(tx *sql.Tx) error {
+		for _, check := range consistencyChecks {
+			if check.repair == "" {
+				continue
+			}
+			found, err := scanInconsistencies(ctx, tx, check.name, RepairDone, check.repair)
+			if err != nil {
+				return fmt.Errorf("%s: %w", check.name, err)
+			}
+			repaired = append(repaired, found...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repaired, nil
+}
+
+// ValidateForeignKeys VALIDATE 还是 NOT VALID、且对应问题已经清空的约束，
+// dirty 是仍有问题的检查类型；返回这次验证的约束和仍未验证的约束
+func ValidateForeignKeys(ctx context.Context, dirty map[string]bool) (validated, pending []string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	for _, check := range consistencyChecks {
+		var valid bool
+		err := DB.QueryRowContext(ctx,
+			`SELECT convalidated FROM pg_constraint WHERE conname = $1 AND conrelid = $2::regclass`,
+			check.constraint, check.table,
+		).Scan(&valid)
+		if err == sql.ErrNoRows {
+			continue // 迁移还没跑到 0005
+		}
+		if err != nil {
+			return validated, pending, fmt.Errorf("%s: %w", check.constraint, err)
+		}
+		if valid {
+			continue
+		}
+		if dirty[check.name] {
+			pending = append(pending, check.constraint)
+			continue
+		}
+		if _, err := DB.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s VALIDATE CONSTRAINT %s`,
+			pq.QuoteIdentifier(check.table), pq.QuoteIdentifier(check.constraint))); err != nil {
+			// 检查和验证之间又出现了孤儿行，下次再试
+			pending = append(pending, check.constraint)
+			eventsLog.Warn("validate constraint failed", "constraint", check.constraint, "error", err)
+			continue
+		}
+		validated = append(validated, check.constraint)
+	}
+	return validated, pending, nil
+}
+
+func scanInconsistencies(ctx context.Context, q Querier, check, repair, query string, args ...any) ([]Inconsistency, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Inconsistency
+	for rows.Next() {
+		item := Inconsistency{Check: check, Repair: repair}
+		if err := rows.Scan(&item.ID, &item.UserUID); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
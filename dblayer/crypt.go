@@ -0,0 +1,169 @@
+package dblayer
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/secrets"
+)
+
+// 敏感列的应用层信封加密：写入前 sealColumn，读出后 openColumn。
+// 未配置密钥时照旧明文写入；明文的旧数据照常读取，由 EncryptColumns 迁移。
+
+// columnKeys 敏感列的加密密钥，第一个用于加密，其余只用于解密轮换前的数据
+var columnKeys *secrets.Keyring
+
+// SetColumnKeys 设置敏感列的加密密钥（DB_ENCRYPTION_KEYS，格式见 secrets.ParseKeyring）
+func SetColumnKeys(value string) error {
+	k, err := secrets.ParseKeyring(value)
+	if err != nil {
+		return err
+	}
+	columnKeys = k
+	return nil
+}
+
+// 敏感列的全名（table.column）
+const (
+	colUserSecretKey        = "users.secret_key"
+	colDomainTXTValue       = "custom_domains.txt_value"
+	colAlertWebhookSecret   = "alert_rules.webhook_secret"
+	colPreviewWebhookSecret = "preview_configs.webhook_secret"
+	colPreviewGitHubToken   = "preview_configs.github_token"
+	colBindingWebhookSecret = "github_bindings.webhook_secret"
+	colBindingGitHubToken   = "github_bindings.github_token"
+	colBucketSecretKey      = "object_buckets.secret_access_key"
+	colBucketSessionToken   = "object_buckets.session_token"
+	colMailDKIMPrivateKey   = "mail_domains.dkim_private_key"
+)
+
+// sensitiveColumn 需要加密的列，Key 是迁移时定位行的唯一列
+type sensitiveColumn struct {
+	Table, Column, Key string
+}
+
+var sensitiveColumns = []sensitiveColumn{
+	{"users", "secret_key", "uid"},
+	{"custom_domains", "txt_value", "cdid"},
+	{"alert_rules", "webhook_secret", "id"},
+	{"preview_configs", "webhook_secret", "worker_id"},
+	{"preview_configs", "github_token", "worker_id"},
+	{"github_bindings", "webhook_secret", "worker_id"},
+	{"github_bindings", "github_token", "worker_id"},
+	{"object_buckets", "secret_access_key", "resource_id"},
+	{"object_buckets", "session_token", "resource_id"},
+	{"mail_domains", "dkim_private_key", "mdid"},
+}
+
+// Name 列的全名，同时作为密文的附加数据，密文挪到其他列后无法解密
+func (c sensitiveColumn) Name() string {
+	return c.Table + "." + c.Column
+}
+
+// sealColumn 加密要写入 column（table.column）的值，空值不加密
+func sealColumn(column, value string) (string, error) {
+	if columnKeys == nil || value == "" {
+		return value, nil
+	}
+	sealed, err := columnKeys.SealEnvelope([]byte(value), []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("encrypt %s: %w", column, err)
+	}
+	return sealed, nil
+}
+
+// openColumn 解密从 column 读出的值，明文原样返回
+func openColumn(column, value string) (string, error) {
+	if !secrets.IsEnvelope(value) {
+		return value, nil
+	}
+	plain, err := columnKeys.OpenEnvelope(value, []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: %w", column, err)
+	}
+	return string(plain), nil
+}
+
+// decryptColumns 解密读出的自定义域名中的敏感列
+func (cd *CustomDomain) decryptColumns() error {
+	var err error
+	cd.TXTValue, err = openColumn(colDomainTXTValue, cd.TXTValue)
+	return err
+}
+
+// ColumnMigration 一列的迁移结果
+type ColumnMigration struct {
+	Column    string `json:"column"`
+	Encrypted int    `json:"encrypted"` // 明文加密
+	Rewrapped int    `json:"rewrapped"` // 换成当前密钥
+	Current   int    `json:"current"`   // 已是当前密钥
+}
+
+// EncryptColumns 加密所有敏感列中的明文，并把旧密钥加密的数据换成当前密钥。
+// 按原值条件更新，和并发写入冲突的行跳过，重复执行即可；dryRun 时只统计不写入
+func EncryptColumns(ctx context.Context, dryRun bool) ([]ColumnMigration, error) {
+	if columnKeys == nil {
+		return nil, fmt.Errorf("DB_ENCRYPTION_KEYS is not set")
+	}
+	var report []ColumnMigration
+	for _, col := range sensitiveColumns {
+		m, err := encryptColumn(ctx, col, dryRun)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", col.Name(), err)
+		}
+		report = append(report, m)
+	}
+	return report, nil
+}
+
+// encryptColumn 整表扫描，不加 QueryTimeout，由调用方 ctx 决定上限
+func encryptColumn(ctx context.Context, col sensitiveColumn, dryRun bool) (ColumnMigration, error) {
+	m := ColumnMigration{Column: col.Name()}
+	rows, err := DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s::text, %s FROM %s WHERE %s <> ''`, col.Key, col.Column, col.Table, col.Column))
+	if err != nil {
+		return m, err
+	}
+	type row struct{ key, value string }
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.key, &r.value); err != nil {
+			rows.Close()
+			return m, err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return m, err
+	}
+
+	update := fmt.Sprintf(`UPDATE %s SET %s = $1 WHERE %s::text = $2 AND %s = $3`, col.Table, col.Column, col.Key, col.Column)
+	for _, r := range pending {
+		var next string
+		if secrets.IsEnvelope(r.value) {
+			rewrapped, changed, err := columnKeys.Rewrap(r.value)
+			if err != nil {
+				return m, fmt.Errorf("row %s: %w", r.key, err)
+			}
+			if !changed {
+				m.Current++
+				continue
+			}
+			next = rewrapped
+			m.Rewrapped++
+		} else {
+			if next, err = sealColumn(col.Name(), r.value); err != nil {
+				return m, fmt.Errorf("row %s: %w", r.key, err)
+			}
+			m.Encrypted++
+		}
+		if dryRun {
+			continue
+		}
+		if _, err := DB.ExecContext(ctx, update, next, r.key, r.value); err != nil {
+			return m, fmt.Errorf("row %s: %w", r.key, err)
+		}
+	}
+	return m, nil
+}
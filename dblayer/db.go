@@ -5,27 +5,68 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"jabberwocky238/console/metrics"
+
 	_ "github.com/lib/pq"
 )
 
 var ErrNotFound = errors.New("not found")
 
+// ErrVersionConflict 带 expected_version 的更新时，资源版本已被别人改过
+var ErrVersionConflict = errors.New("version conflict")
+
 // DB connection
 var DB *sql.DB
 
+// StatementTimeout 每条 SQL 在 Postgres 端的执行上限（statement_timeout），
+// 请求超时放弃后慢查询不会继续占着连接池；DSN 里已设置或为 0 时不追加
+var StatementTimeout = 15 * time.Second
+
+// QueryTimeout 每个 dblayer 调用在客户端的上限（含等待连接池），调用方 ctx 的截止时间更早时以它为准；
+// 0 表示只跟随调用方 ctx
+var QueryTimeout = 20 * time.Second
+
+// withQueryTimeout 给 ctx 加上 QueryTimeout，cancel 时记录这次调用的耗时
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	observe := observeQuery()
+	var cancel context.CancelFunc
+	if QueryTimeout <= 0 {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, QueryTimeout)
+	}
+	return ctx, func() {
+		cancel()
+		observe()
+	}
+}
+
+// InitDB 连接 dsn；embedded: DSN 先在本机拉起内嵌 Postgres（见 embedded.go）
 func InitDB(dsn string) error {
+	if err := checkDSN(dsn); err != nil {
+		return err
+	}
 	var err error
-	DB, err = sql.Open("postgres", dsn)
+	if strings.HasPrefix(dsn, embeddedScheme+":") {
+		if dsn, err = startEmbedded(dsn); err != nil {
+			return err
+		}
+	}
+	DB, err = sql.Open("postgres", withStatementTimeout(dsn, StatementTimeout))
 	if err != nil {
 		return fmt.Errorf("Connection err: %s", err.Error())
 	}
 
-	// Set connection pool limits
-	DB.SetMaxOpenConns(25)
-	DB.SetMaxIdleConns(5)
+	// Set connection pool limits, RunPoolMonitor applies later changes
+	DB.SetMaxOpenConns(MaxOpenConns.Get())
+	DB.SetMaxIdleConns(MaxIdleConns.Get())
 	DB.SetConnMaxLifetime(5 * time.Minute)
+	metrics.RegisterDB(DB, "console")
 
 	// Ping with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -33,3 +74,47 @@ func InitDB(dsn string) error {
 
 	return DB.PingContext(ctx)
 }
+
+// CloseDB 关闭连接池，InitDB 拉起了内嵌 Postgres 时一并停止
+func CloseDB() {
+	if DB != nil {
+		DB.Close()
+	}
+	stopEmbedded()
+}
+
+// checkDSN 拒绝 SQLite 一类的 DSN：schema 依赖 Postgres（JSONB、LISTEN/NOTIFY、advisory lock），
+// 本地开发用 embedded: 内嵌 Postgres，直接报清楚比 lib/pq 的解析错误好懂
+func checkDSN(dsn string) error {
+	scheme, _, ok := strings.Cut(dsn, ":")
+	if !ok || strings.ContainsAny(scheme, "= ") {
+		return nil // key=value 格式
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql", embeddedScheme:
+		return nil
+	case "sqlite", "sqlite3", "file":
+		return fmt.Errorf("%s DSNs are not supported, the schema needs Postgres (JSONB, LISTEN/NOTIFY, advisory locks); "+
+			"for local development use an embedded one, e.g. -d %s:.console-pg", scheme, embeddedScheme)
+	}
+	return fmt.Errorf("unsupported DSN scheme %q, want postgres:// or %s:", scheme, embeddedScheme)
+}
+
+// withStatementTimeout 把 statement_timeout 作为连接参数加进 DSN，URL 和 key=value 两种格式都支持
+func withStatementTimeout(dsn string, d time.Duration) string {
+	if d <= 0 || strings.Contains(dsn, "statement_timeout") {
+		return dsn
+	}
+	ms := strconv.FormatInt(d.Milliseconds(), 10)
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		q := u.Query()
+		q.Set("statement_timeout", ms)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return strings.TrimSpace(dsn + " statement_timeout=" + ms)
+}
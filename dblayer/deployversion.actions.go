@@ -0,0 +1,241 @@
+package dblayer
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+)
+
+// 部署的发起方
+const (
+	DeployInitiatorUser    = "user"    // REST、gRPC、consolectl
+	DeployInitiatorGitHub  = "github"  // push-to-deploy
+	DeployInitiatorPreview = "preview" // pull request 预览
+	DeployInitiatorImport  = "import"  // POST /import
+	DeployInitiatorApply   = "apply"   // POST /apply (console.yaml)
+	DeployInitiatorClone   = "clone"   // POST /worker/:id/clone
+	DeployInitiatorPromote = "promote" // POST /worker/:id/promote
+)
+
+// DeploySnapshot 部署时 worker 的配置快照。env 只保存值的摘要，能看出改没改，但不留明文
+type DeploySnapshot struct {
+	Env       map[string]string `json:"env"` // key → 值的 sha256 前 16 位
+	Secrets   []string          `json:"secrets"`
+	Resources DeployResources   `json:"resources"`
+}
+
+// DeployResources 快照里的资源和调度配置
+type DeployResources struct {
+	CPU            string `json:"cpu"`
+	Memory         string `json:"memory"`
+	Disk           string `json:"disk"`
+	MaxReplicas    int    `json:"max_replicas"`
+	Region         string `json:"region"`
+	Cluster        string `json:"cluster"`
+	Pool           string `json:"pool"`
+	Arch           string `json:"arch"`
+	RunAsRoot      bool   `json:"run_as_root"`
+	WritableRootFS bool   `json:"writable_root_fs"`
+}
+
+// DeployChange 两个版本之间的一处差异；env 的值不返回，只标记 changed
+type DeployChange struct {
+	Field  string `json:"field"`  // image, port, commit_sha, env.KEY, secret.KEY, resources.cpu ...
+	Change string `json:"change"` // added, removed, changed
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// DeployDiff 两个版本之间的变化。任一版本早于快照（0015）时 Partial 为 true，
+// 只比较了镜像、端口、提交和静态文件来源
+type DeployDiff struct {
+	Partial bool
+	Changes []DeployChange
+}
+
+// takeDeploySnapshot 从 workers 表当前的列生成快照
+func takeDeploySnapshot(w *Worker) (*DeploySnapshot, error) {
+	s := &DeploySnapshot{
+		Env:     map[string]string{},
+		Secrets: []string{},
+		Resources: DeployResources{
+			CPU:            w.AssignedCPU,
+			Memory:         w.AssignedMemory,
+			Disk:           w.AssignedDisk,
+			MaxReplicas:    w.MaxReplicas,
+			Region:         w.MainRegion,
+			Cluster:        w.Cluster,
+			Pool:           w.Pool,
+			Arch:           w.Arch,
+			RunAsRoot:      w.RunAsRoot,
+			WritableRootFS: w.WritableRootFS,
+		},
+	}
+	if w.EnvJSON != "" {
+		var env map[string]string
+		if err := json.Unmarshal([]byte(w.EnvJSON), &env); err != nil {
+			return nil, err
+		}
+		for k, v := range env {
+			sum := sha256.Sum256([]byte(v))
+			s.Env[k] = hex.EncodeToString(sum[:8])
+		}
+	}
+	if w.SecretsJSON != "" {
+		if err := json.Unmarshal([]byte(w.SecretsJSON), &s.Secrets); err != nil {
+			return nil, err
+		}
+		slices.Sort(s.Secrets)
+	}
+	return s, nil
+}
+
+const deployVersionColumns = `id, worker_id, image, port, status, msg, commit_sha, initiator, created_at, snapshot, static_source`
+
+func scanDeployVersion(row rowScanner) (*WorkerDeployVersion, error) {
+	var v WorkerDeployVersion
+	var snapshot, static []byte
+	if err := row.Scan(&v.ID, &v.WorkerID, &v.Image, &v.Port, &v.Status, &v.Msg, &v.CommitSHA, &v.Initiator, &v.CreatedAt, &snapshot, &static); err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		v.Snapshot = &DeploySnapshot{}
+		if err := json.Unmarshal(snapshot, v.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if v.Static, err = unmarshalStaticSource(static); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListWorkerDeployVersions worker 的部署历史，支持 status 过滤和游标分页（按名称排序即按镜像）
+func ListWorkerDeployVersions(ctx context.Context, workerID int, opts ListOptions) ([]*WorkerDeployVersion, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT ` + deployVersionColumns + ` FROM worker_deploy_versions WHERE worker_id = $1`
+	query, args, err := opts.listQuery(base, []any{workerID}, "image")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []*WorkerDeployVersion{}
+	for rows.Next() {
+		v, err := scanDeployVersion(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	items, next := trimPage(items, &opts, func(v *WorkerDeployVersion) (string, int) {
+		return opts.cursorValue(v.CreatedAt, v.Image), v.ID
+	})
+	return items, next, nil
+}
+
+// GetWorkerDeployVersion 读取属于 worker 的一个版本（含快照），不属于时 ErrNotFound
+func GetWorkerDeployVersion(ctx context.Context, workerID, versionID int) (*WorkerDeployVersion, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	v, err := scanDeployVersion(readRow(ctx,
+		`SELECT `+deployVersionColumns+` FROM worker_deploy_versions WHERE id = $1 AND worker_id = $2`,
+		versionID, workerID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+// DiffDeployVersions 比较两个版本：镜像、端口、提交、静态文件来源，以及两边都有快照时的 env、secret 和资源
+func DiffDeployVersions(from, to *WorkerDeployVersion) DeployDiff {
+	d := DeployDiff{Changes: []DeployChange{}}
+	d.changed("image", from.Image, to.Image)
+	d.changed("port", strconv.Itoa(from.Port), strconv.Itoa(to.Port))
+	d.changed("commit_sha", from.CommitSHA, to.CommitSHA)
+	d.changed("static", from.Static.String(), to.Static.String())
+	if from.Snapshot == nil || to.Snapshot == nil {
+		d.Partial = true
+		return d
+	}
+
+	a, b := from.Snapshot, to.Snapshot
+	for _, k := range unionKeys(a.Env, b.Env) {
+		va, inA := a.Env[k]
+		vb, inB := b.Env[k]
+		switch {
+		case !inA:
+			d.Changes = append(d.Changes, DeployChange{Field: "env." + k, Change: "added"})
+		case !inB:
+			d.Changes = append(d.Changes, DeployChange{Field: "env." + k, Change: "removed"})
+		case va != vb:
+			d.Changes = append(d.Changes, DeployChange{Field: "env." + k, Change: "changed"})
+		}
+	}
+	for _, k := range a.Secrets {
+		if !slices.Contains(b.Secrets, k) {
+			d.Changes = append(d.Changes, DeployChange{Field: "secret." + k, Change: "removed"})
+		}
+	}
+	for _, k := range b.Secrets {
+		if !slices.Contains(a.Secrets, k) {
+			d.Changes = append(d.Changes, DeployChange{Field: "secret." + k, Change: "added"})
+		}
+	}
+
+	ra, rb := a.Resources, b.Resources
+	d.changed("resources.cpu", ra.CPU, rb.CPU)
+	d.changed("resources.memory", ra.Memory, rb.Memory)
+	d.changed("resources.disk", ra.Disk, rb.Disk)
+	d.changed("resources.max_replicas", strconv.Itoa(ra.MaxReplicas), strconv.Itoa(rb.MaxReplicas))
+	d.changed("resources.region", ra.Region, rb.Region)
+	d.changed("resources.cluster", ra.Cluster, rb.Cluster)
+	d.changed("resources.pool", ra.Pool, rb.Pool)
+	d.changed("resources.arch", ra.Arch, rb.Arch)
+	d.changed("resources.run_as_root", strconv.FormatBool(ra.RunAsRoot), strconv.FormatBool(rb.RunAsRoot))
+	d.changed("resources.writable_root_fs", strconv.FormatBool(ra.WritableRootFS), strconv.FormatBool(rb.WritableRootFS))
+	return d
+}
+
+// changed 值不同时记一条差异，空值一侧记为 added/removed
+func (d *DeployDiff) changed(field, from, to string) {
+	if from == to {
+		return
+	}
+	c := DeployChange{Field: field, Change: "changed", From: from, To: to}
+	if from == "" {
+		c.Change = "added"
+	} else if to == "" {
+		c.Change = "removed"
+	}
+	d.Changes = append(d.Changes, c)
+}
+
+func unionKeys(a, b map[string]string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// EdgeLimits worker 入口的限流和请求体大小，0 表示不限制
+type EdgeLimits struct {
+	RateLimit    int        `json:"rate_limit"`     // 每秒请求数，按 worker 的域名统计
+	Burst        int        `json:"burst"`          // 允许瞬间超出的请求数，0 时等于 rate_limit
+	MaxBodyBytes int64      `json:"max_body_bytes"` // 超过时返回 413
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+// GetWorkerEdgeLimits 获取 worker 入口的限制，没有设置过的全为 0
+func GetWorkerEdgeLimits(ctx context.Context, wid string) (EdgeLimits, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	l := EdgeLimits{}
+	var updatedAt time.Time
+	err := DB.QueryRowContext(ctx,
+		`SELECT rate_limit, burst, max_body_bytes, updated_at FROM worker_edge_limits WHERE wid = $1`, wid,
+	).Scan(&l.RateLimit, &l.Burst, &l.MaxBodyBytes, &updatedAt)
+	if err == sql.ErrNoRows {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	l.UpdatedAt = &updatedAt
+	return l, nil
+}
+
+// SetWorkerEdgeLimits 在 tx 里替换 worker 入口的限制并把 worker 版本加一，expectedVersion 为 0 时不检查版本，返回新版本；
+// worker 不存在或不属于该用户时返回 ErrNotFound
+func SetWorkerEdgeLimits(ctx context.Context, tx *sql.Tx, wid, userUID string, l EdgeLimits, expectedVersion int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	version, err := updateVersioned(ctx, tx,
+		`UPDATE workers SET version = version + 1
+		 WHERE wid = $1 AND user_uid = $2 AND ($3 = 0 OR version = $3) RETURNING version`,
+		[]any{wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+	if err != nil {
+		return version, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO worker_edge_limits (wid, rate_limit, burst, max_body_bytes) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (wid) DO UPDATE SET rate_limit = EXCLUDED.rate_limit, burst = EXCLUDED.burst,
+		     max_body_bytes = EXCLUDED.max_body_bytes, updated_at = CURRENT_TIMESTAMP`,
+		wid, l.RateLimit, l.Burst, l.MaxBodyBytes,
+	)
+	return version, err
+}
@@ -0,0 +1,75 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// worker 出站规则的模式
+const (
+	EgressAllow = "allow" // 不限制，平台屏蔽的地址除外
+	EgressDeny  = "deny"  // 只放行 CIDRs 和 Domains
+)
+
+// EgressPolicy worker 的出站规则，没有设置过的 worker 为 allow
+type EgressPolicy struct {
+	Mode      string     `json:"mode"`
+	CIDRs     []string   `json:"cidrs"`
+	Domains   []string   `json:"domains"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// GetWorkerEgress 获取 worker 的出站规则
+func GetWorkerEgress(ctx context.Context, wid string) (EgressPolicy, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	p := EgressPolicy{}
+	var updatedAt time.Time
+	err := DB.QueryRowContext(ctx,
+		`SELECT mode, cidrs, domains, updated_at FROM worker_egress WHERE wid = $1`, wid,
+	).Scan(&p.Mode, pq.Array(&p.CIDRs), pq.Array(&p.Domains), &updatedAt)
+	if err == sql.ErrNoRows {
+		return EgressPolicy{Mode: EgressAllow, CIDRs: []string{}, Domains: []string{}}, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	p.UpdatedAt = &updatedAt
+	return p, nil
+}
+
+// SetWorkerEgress 替换 worker 的出站规则并把 worker 版本加一，expectedVersion 为 0 时不检查版本，返回新版本；
+// worker 不存在或不属于该用户时返回 ErrNotFound
+func SetWorkerEgress(ctx context.Context, wid, userUID string, p EgressPolicy, expectedVersion int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var version int
+	err := WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		version, err = SetWorkerEgressTx(ctx, tx, wid, userUID, p, expectedVersion)
+		return err
+	})
+	return version, err
+}
+
+// SetWorkerEgressTx 同 SetWorkerEgress，在调用方的事务 tx 里执行
+func SetWorkerEgressTx(ctx context.Context, tx *sql.Tx, wid, userUID string, p EgressPolicy, expectedVersion int) (int, error) {
+	version, err := updateVersioned(ctx, tx,
+		`UPDATE workers SET version = version + 1
+		 WHERE wid = $1 AND user_uid = $2 AND ($3 = 0 OR version = $3) RETURNING version`,
+		[]any{wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+	if err != nil {
+		return version, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO worker_egress (wid, mode, cidrs, domains) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (wid) DO UPDATE SET mode = EXCLUDED.mode, cidrs = EXCLUDED.cidrs,
+		     domains = EXCLUDED.domains, updated_at = CURRENT_TIMESTAMP`,
+		wid, p.Mode, pq.Array(p.CIDRs), pq.Array(p.Domains),
+	)
+	return version, err
+}
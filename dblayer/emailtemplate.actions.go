@@ -0,0 +1,118 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// EmailTemplate email_templates 表里对一个内置邮件模板某个语言的覆盖
+type EmailTemplate struct {
+	Name      string    `json:"name"`
+	Locale    string    `json:"locale"`
+	Source    string    `json:"source"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListEmailTemplates 所有覆盖，按 name、locale 排序
+func ListEmailTemplates(ctx context.Context) ([]EmailTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT name, locale, source, updated_by, updated_at FROM email_templates ORDER BY name, locale`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []EmailTemplate{}
+	for rows.Next() {
+		var t EmailTemplate
+		if err := rows.Scan(&t.Name, &t.Locale, &t.Source, &t.UpdatedBy, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// GetEmailTemplate 模板某个语言的覆盖，没有时返回 ErrNotFound。
+// 每封邮件渲染前都查一次，改动不需要通知其他实例
+func GetEmailTemplate(ctx context.Context, name, locale string) (*EmailTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	t := EmailTemplate{Name: name, Locale: locale}
+	err := DB.QueryRowContext(ctx,
+		`SELECT source, updated_by, updated_at FROM email_templates WHERE name = $1 AND locale = $2`, name, locale,
+	).Scan(&t.Source, &t.UpdatedBy, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SetEmailTemplate 新建或更新覆盖
+func SetEmailTemplate(ctx context.Context, name, locale, source, updatedBy string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO email_templates (name, locale, source, updated_by) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (name, locale) DO UPDATE SET source = EXCLUDED.source, updated_by = EXCLUDED.updated_by,
+		     updated_at = CURRENT_TIMESTAMP`,
+		name, locale, source, updatedBy,
+	)
+	return err
+}
+
+// DeleteEmailTemplate 删除覆盖（回到内置模板），没有覆盖时返回 ErrNotFound
+func DeleteEmailTemplate(ctx context.Context, name, locale string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM email_templates WHERE name = $1 AND locale = $2`, name, locale)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUserContact 用户的邮箱和邮件语言（空字符串表示默认语言）
+func GetUserContact(ctx context.Context, uid string) (email, locale string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err = DB.QueryRowContext(ctx, `SELECT email, locale FROM users WHERE uid = $1`, uid).Scan(&email, &locale)
+	return email, locale, err
+}
+
+// GetLocaleByEmail 按邮箱查用户的邮件语言，还没注册时返回空字符串
+func GetLocaleByEmail(ctx context.Context, email string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var locale string
+	err := DB.QueryRowContext(ctx, `SELECT locale FROM users WHERE email = $1`, email).Scan(&locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return locale, err
+}
+
+// SetUserLocale 修改用户的邮件语言，用户不存在时返回 ErrNotFound
+func SetUserLocale(ctx context.Context, uid, locale string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `UPDATE users SET locale = $1 WHERE uid = $2`, locale, uid)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package dblayer
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"jabberwocky238/console/logging"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// 本地开发用的内嵌 Postgres：DSN 写成 embedded:<数据目录>[?port=5433]，InitDB 在本机拉起一个 Postgres
+// （首次运行时下载二进制并 initdb，数据留在目录里，下次启动接着用），迁移照常在它上面执行。
+// 两个网关用同一个 DSN 时先启动的拉起 Postgres，后启动的发现端口上已经有它就直接连接
+
+const (
+	embeddedScheme      = "embedded"
+	defaultEmbeddedDir  = ".console-pg"
+	defaultEmbeddedPort = 5433
+	// embeddedStartTimeout 首次启动要 initdb，比默认的 15 秒宽一些
+	embeddedStartTimeout = time.Minute
+)
+
+var embeddedLog = logging.Component("embedded-postgres")
+
+// embeddedServer 本进程拉起的内嵌 Postgres，CloseDB 时停止；连接的是别的进程拉起的则为 nil
+var embeddedServer *embeddedpostgres.EmbeddedPostgres
+
+// embeddedConfig 解析 embedded: DSN，返回数据目录（绝对路径）和端口
+func embeddedConfig(dsn string) (string, int, error) {
+	rest, ok := strings.CutPrefix(dsn, embeddedScheme+":")
+	if !ok {
+		return "", 0, fmt.Errorf("not an %s DSN", embeddedScheme)
+	}
+	dir, query, _ := strings.Cut(strings.TrimPrefix(rest, "//"), "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid %s DSN: %w", embeddedScheme, err)
+	}
+	port := defaultEmbeddedPort
+	if p := params.Get("port"); p != "" {
+		if port, err = strconv.Atoi(p); err != nil || port <= 0 || port > 65535 {
+			return "", 0, fmt.Errorf("invalid %s DSN port %q", embeddedScheme, p)
+		}
+	}
+	dir, err = filepath.Abs(cmp.Or(dir, defaultEmbeddedDir))
+	if err != nil {
+		return "", 0, err
+	}
+	return dir, port, nil
+}
+
+// startEmbedded 拉起或复用 embedded: DSN 指定的 Postgres，返回连接它的 postgres:// DSN
+func startEmbedded(dsn string) (string, error) {
+	dir, port, err := embeddedConfig(dsn)
+	if err != nil {
+		return "", err
+	}
+	const database, user, password = "console", "postgres", "postgres"
+	conn := fmt.Sprintf("postgres://%s:%s@localhost:%d/%s?sslmode=disable", user, password, port, database)
+	// 两个网关同时启动时只让一个拉起，另一个等它起来后直接连接
+	unlock, err := lockEmbeddedDir(dir)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if embeddedRunning(conn) {
+		embeddedLog.Info("using the embedded postgres already running", "port", port)
+		return conn, nil
+	}
+
+	embeddedLog.Info("starting embedded postgres, the first run downloads it", "dir", dir, "port", port)
+	pg := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(uint32(port)).
+		Database(database).
+		Username(user).
+		Password(password).
+		// Start 会清空 runtime 目录，数据放在它外面
+		DataPath(filepath.Join(dir, "data")).
+		RuntimePath(filepath.Join(dir, "runtime")).
+		StartTimeout(embeddedStartTimeout).
+		Logger(io.Discard))
+	if err := pg.Start(); err != nil {
+		return "", fmt.Errorf("start embedded postgres in %s: %w", dir, err)
+	}
+	embeddedServer = pg
+	return conn, nil
+}
+
+// lockEmbeddedDir 在数据目录上加文件锁，返回解锁函数；Start 会清空无效的数据目录后重新 initdb，不能两个进程同时做
+func lockEmbeddedDir(dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create embedded postgres dir: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock embedded postgres dir: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// embeddedRunning 端口上是否已经有能连上的内嵌 Postgres（另一个网关拉起的，或上次没有正常停止的）
+func embeddedRunning(conn string) bool {
+	db, err := sql.Open("postgres", conn)
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return db.PingContext(ctx) == nil
+}
+
+// stopEmbedded 停止本进程拉起的内嵌 Postgres
+func stopEmbedded() {
+	if embeddedServer == nil {
+		return
+	}
+	if err := embeddedServer.Stop(); err != nil {
+		embeddedLog.Warn("stop embedded postgres failed", "error", err)
+	}
+	embeddedServer = nil
+}
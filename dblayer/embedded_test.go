@@ -0,0 +1,67 @@
+package dblayer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedConfig(t *testing.T) {
+	abs := func(p string) string {
+		p, _ = filepath.Abs(p)
+		return p
+	}
+	tests := []struct {
+		dsn      string
+		wantDir  string
+		wantPort int
+		wantErr  bool
+	}{
+		{"embedded:", abs(defaultEmbeddedDir), defaultEmbeddedPort, false},
+		{"embedded:.dev/pg", abs(".dev/pg"), defaultEmbeddedPort, false},
+		{"embedded:///var/lib/console-pg?port=6543", "/var/lib/console-pg", 6543, false},
+		{"embedded:?port=5500", abs(defaultEmbeddedDir), 5500, false},
+		{"embedded:pg?port=0", "", 0, true},
+		{"embedded:pg?port=http", "", 0, true},
+		{"postgres://localhost/console", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			dir, port, err := embeddedConfig(tt.dsn)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %s:%d, want an error", dir, port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if dir != tt.wantDir || port != tt.wantPort {
+				t.Errorf("got %s:%d, want %s:%d", dir, port, tt.wantDir, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestCheckDSN(t *testing.T) {
+	for _, dsn := range []string{
+		"postgres://u:p@localhost/console",
+		"postgresql://u:p@localhost/console?sslmode=disable",
+		"host=localhost dbname=console",
+		"embedded:.console-pg",
+	} {
+		if err := checkDSN(dsn); err != nil {
+			t.Errorf("checkDSN(%q) = %v", dsn, err)
+		}
+	}
+	// SQLite 一类的 DSN 提示改用内嵌 Postgres
+	for _, dsn := range []string{"sqlite:///tmp/console.db", "file:console.db"} {
+		if err := checkDSN(dsn); err == nil || !strings.Contains(err.Error(), "embedded:") {
+			t.Errorf("checkDSN(%q) = %v, want a hint to use embedded:", dsn, err)
+		}
+	}
+	if err := checkDSN("mysql://localhost/console"); err == nil {
+		t.Error("mysql DSN accepted")
+	}
+}
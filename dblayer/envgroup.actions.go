@@ -0,0 +1,257 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrEnvGroupExists 同名 env 组已存在
+var ErrEnvGroupExists = errors.New("env group already exists")
+
+// UnknownEnvGroupError SetWorkerEnvGroups 引用了不存在的 env 组
+type UnknownEnvGroupError struct {
+	Name string
+}
+
+func (e *UnknownEnvGroupError) Error() string {
+	return "unknown env group " + e.Name
+}
+
+// EnvGroup 用户的一组共享环境变量，可以挂到多个 worker 上
+type EnvGroup struct {
+	Name      string            `json:"name"`
+	Vars      map[string]string `json:"vars"`
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+const envGroupColumns = `name, vars, version, created_at, updated_at`
+
+func scanEnvGroup(row interface{ Scan(...any) error }) (*EnvGroup, error) {
+	var g EnvGroup
+	var vars []byte
+	if err := row.Scan(&g.Name, &vars, &g.Version, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(vars, &g.Vars); err != nil {
+		return nil, err
+	}
+	if g.Vars == nil {
+		g.Vars = map[string]string{}
+	}
+	return &g, nil
+}
+
+// ListEnvGroups 用户的所有 env 组，按名称排序
+func ListEnvGroups(ctx context.Context, userUID string) ([]*EnvGroup, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT `+envGroupColumns+` FROM env_groups WHERE user_uid = $1 ORDER BY name`, userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := []*EnvGroup{}
+	for rows.Next() {
+		g, err := scanEnvGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// CountEnvGroups 用户 env 组的数量
+func CountEnvGroups(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM env_groups WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
+
+// GetEnvGroup 读取用户的一个 env 组，不存在时返回 ErrNotFound
+func GetEnvGroup(ctx context.Context, userUID, name string) (*EnvGroup, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	g, err := scanEnvGroup(DB.QueryRowContext(ctx,
+		`SELECT `+envGroupColumns+` FROM env_groups WHERE user_uid = $1 AND name = $2`, userUID, name,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return g, err
+}
+
+// CreateEnvGroup 创建 env 组，同名时返回 ErrEnvGroupExists
+func CreateEnvGroup(ctx context.Context, userUID, name string, vars map[string]string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return err
+	}
+	_, err = DB.ExecContext(ctx,
+		`INSERT INTO env_groups (user_uid, name, vars) VALUES ($1, $2, $3)`, userUID, name, data,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEnvGroupExists
+	}
+	return err
+}
+
+// SetEnvGroupVars 在 q 里替换 env 组的变量并把组版本加一，expectedVersion 为 0 时不检查版本，返回新版本；
+// 不存在时返回 ErrNotFound
+func SetEnvGroupVars(ctx context.Context, q Querier, userUID, name string, vars map[string]string, expectedVersion int) (int, error) {
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return 0, err
+	}
+	return updateVersioned(ctx, q,
+		`UPDATE env_groups SET vars = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE user_uid = $2 AND name = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		[]any{data, userUID, name, expectedVersion},
+		`SELECT version FROM env_groups WHERE user_uid = $1 AND name = $2`, userUID, name)
+}
+
+// DeleteEnvGroup 在 tx 里删除 env 组并从所有 worker 上摘掉，返回之前挂着它的 worker；不存在时返回 ErrNotFound
+func DeleteEnvGroup(ctx context.Context, tx *sql.Tx, userUID, name string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var id int
+	err := tx.QueryRowContext(ctx,
+		`SELECT id FROM env_groups WHERE user_uid = $1 AND name = $2 FOR UPDATE`, userUID, name,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	wids, err := envGroupWorkers(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM env_groups WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	return wids, nil
+}
+
+// ListEnvGroupWorkers 在 q 里查挂着该 env 组的 worker
+func ListEnvGroupWorkers(ctx context.Context, q Querier, userUID, name string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var id int
+	err := q.QueryRowContext(ctx,
+		`SELECT id FROM env_groups WHERE user_uid = $1 AND name = $2`, userUID, name,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return envGroupWorkers(ctx, q, id)
+}
+
+func envGroupWorkers(ctx context.Context, q Querier, groupID int) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT wid FROM worker_env_groups WHERE group_id = $1 ORDER BY wid`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	wids := []string{}
+	for rows.Next() {
+		var wid string
+		if err := rows.Scan(&wid); err != nil {
+			return nil, err
+		}
+		wids = append(wids, wid)
+	}
+	return wids, rows.Err()
+}
+
+// GetWorkerEnvGroups worker 挂的 env 组，按优先级从低到高（后面的覆盖前面的）
+func GetWorkerEnvGroups(ctx context.Context, wid string) ([]*EnvGroup, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT g.name, g.vars, g.version, g.created_at, g.updated_at
+		 FROM worker_env_groups weg JOIN env_groups g ON g.id = weg.group_id
+		 WHERE weg.wid = $1 ORDER BY weg.position`, wid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := []*EnvGroup{}
+	for rows.Next() {
+		g, err := scanEnvGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// SetWorkerEnvGroups 在 q 里按给定顺序替换 worker 挂的 env 组并把 worker 版本加一，expectedVersion 为 0 时不检查版本，
+// 返回新版本；worker 不存在或不属于该用户时返回 ErrNotFound，有组不存在时返回 *UnknownEnvGroupError。
+// 先删后插，q 应当是事务
+func SetWorkerEnvGroups(ctx context.Context, q Querier, wid, userUID string, names []string, expectedVersion int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	ids := make([]int, len(names))
+	for i, name := range names {
+		err := q.QueryRowContext(ctx,
+			`SELECT id FROM env_groups WHERE user_uid = $1 AND name = $2`, userUID, name,
+		).Scan(&ids[i])
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, &UnknownEnvGroupError{Name: name}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	version, err := updateVersioned(ctx, q,
+		`UPDATE workers SET version = version + 1
+		 WHERE wid = $1 AND user_uid = $2 AND ($3 = 0 OR version = $3) RETURNING version`,
+		[]any{wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+	if err != nil {
+		return version, err
+	}
+	if _, err := q.ExecContext(ctx, `DELETE FROM worker_env_groups WHERE wid = $1`, wid); err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if _, err := q.ExecContext(ctx,
+			`INSERT INTO worker_env_groups (wid, group_id, position) VALUES ($1, $2, $3)`, wid, id, i,
+		); err != nil {
+			return 0, err
+		}
+	}
+	return version, nil
+}
+
+// MergeEnvGroups 把 env 组按顺序合并到 worker 自己的 env 之下：后面的组覆盖前面的，worker 自己的 env 覆盖所有组
+func MergeEnvGroups(groups []*EnvGroup, env map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, g := range groups {
+		maps.Copy(merged, g.Vars)
+	}
+	maps.Copy(merged, env)
+	return merged
+}
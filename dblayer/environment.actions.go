@@ -0,0 +1,195 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DefaultEnvironment 没有指定环境的 worker、RDB/KV 和自定义域名都属于它
+const DefaultEnvironment = "production"
+
+// ErrEnvironmentInUse 环境下还有资源，不能删除
+var ErrEnvironmentInUse = errors.New("environment still has resources")
+
+// Environment 用户的一个环境：用到它的资源数量和该环境的共享变量。
+// 环境不需要先创建，资源用到的名字就是环境；只有设置过变量的才有 environments 行（Version > 0）
+type Environment struct {
+	Name      string            `json:"name"`
+	Vars      map[string]string `json:"vars"`
+	Version   int               `json:"version"`
+	Workers   int               `json:"workers"`
+	RDBs      int               `json:"rdbs"`
+	KVs       int               `json:"kvs"`
+	Domains   int               `json:"domains"`
+	UpdatedAt *time.Time        `json:"updated_at,omitempty"`
+}
+
+// environmentUsage 用户各资源所在的环境，kind 为 worker、rdb、kv、domain
+const environmentUsage = `SELECT environment AS name, 'worker' AS kind FROM workers WHERE user_uid = $1
+	UNION ALL SELECT environment, resource_type FROM combinator_resources WHERE user_uid = $1
+	UNION ALL SELECT environment, 'domain' FROM custom_domains WHERE user_uid = $1`
+
+// ListEnvironments 用户的所有环境（资源用到的和设置过变量的），按名称排序
+func ListEnvironments(ctx context.Context, userUID string) ([]*Environment, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`WITH used AS (`+environmentUsage+`),
+		 names AS (SELECT name FROM used UNION SELECT name FROM environments WHERE user_uid = $1)
+		 SELECT n.name, COALESCE(e.vars, '{}'), COALESCE(e.version, 0), e.updated_at,
+		        COUNT(u.kind) FILTER (WHERE u.kind = 'worker'), COUNT(u.kind) FILTER (WHERE u.kind = 'rdb'),
+		        COUNT(u.kind) FILTER (WHERE u.kind = 'kv'), COUNT(u.kind) FILTER (WHERE u.kind = 'domain')
+		 FROM names n
+		 LEFT JOIN environments e ON e.user_uid = $1 AND e.name = n.name
+		 LEFT JOIN used u ON u.name = n.name
+		 GROUP BY n.name, e.vars, e.version, e.updated_at
+		 ORDER BY n.name`, userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	envs := []*Environment{}
+	for rows.Next() {
+		var e Environment
+		var vars []byte
+		if err := rows.Scan(&e.Name, &vars, &e.Version, &e.UpdatedAt, &e.Workers, &e.RDBs, &e.KVs, &e.Domains); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(vars, &e.Vars); err != nil {
+			return nil, err
+		}
+		if e.Vars == nil {
+			e.Vars = map[string]string{}
+		}
+		envs = append(envs, &e)
+	}
+	return envs, rows.Err()
+}
+
+// SetEnvironmentVars 替换环境的变量，没有 environments 行时创建；expectedVersion 为 0 时不检查版本，返回新版本。
+// expectedVersion 不为 0 而环境没有设置过变量时返回 ErrNotFound
+func SetEnvironmentVars(ctx context.Context, userUID, name string, vars map[string]string, expectedVersion int) (int, error) {
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return 0, err
+	}
+	if expectedVersion == 0 {
+		ctx, cancel := withQueryTimeout(ctx)
+		defer cancel()
+		var version int
+		err := DB.QueryRowContext(ctx,
+			`INSERT INTO environments (user_uid, name, vars) VALUES ($1, $2, $3)
+			 ON CONFLICT (user_uid, name) DO UPDATE
+			 SET vars = EXCLUDED.vars, version = environments.version + 1, updated_at = CURRENT_TIMESTAMP
+			 RETURNING version`, userUID, name, data,
+		).Scan(&version)
+		return version, err
+	}
+	return updateVersioned(ctx, DB,
+		`UPDATE environments SET vars = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE user_uid = $2 AND name = $3 AND version = $4 RETURNING version`,
+		[]any{data, userUID, name, expectedVersion},
+		`SELECT version FROM environments WHERE user_uid = $1 AND name = $2`, userUID, name)
+}
+
+// DeleteEnvironment 删除环境的变量；环境下还有资源时返回 ErrEnvironmentInUse，没有设置过变量时返回 ErrNotFound
+func DeleteEnvironment(ctx context.Context, userUID, name string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		var inUse bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM (`+environmentUsage+`) used WHERE name = $2)`, userUID, name,
+		).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return ErrEnvironmentInUse
+		}
+		res, err := tx.ExecContext(ctx, `DELETE FROM environments WHERE user_uid = $1 AND name = $2`, userUID, name)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// ListEnvironmentWorkers 环境下的 worker
+func ListEnvironmentWorkers(ctx context.Context, userUID, name string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT wid FROM workers WHERE user_uid = $1 AND environment = $2 ORDER BY wid`, userUID, name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	wids := []string{}
+	for rows.Next() {
+		var wid string
+		if err := rows.Scan(&wid); err != nil {
+			return nil, err
+		}
+		wids = append(wids, wid)
+	}
+	return wids, rows.Err()
+}
+
+// GetWorkerEnvironmentVars worker 所在环境的变量，环境没有设置过变量时为空
+func GetWorkerEnvironmentVars(ctx context.Context, wid string) (map[string]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var data []byte
+	err := DB.QueryRowContext(ctx,
+		`SELECT COALESCE(e.vars, '{}') FROM workers w
+		 LEFT JOIN environments e ON e.user_uid = w.user_uid AND e.name = w.environment
+		 WHERE w.wid = $1`, wid,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]string{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// SetWorkerEnvironment 在 q 里把 worker 移到另一个环境，expectedVersion 为 0 时不检查版本，返回新版本
+func SetWorkerEnvironment(ctx context.Context, q Querier, wid, userUID, environment string, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, q,
+		`UPDATE workers SET environment = $1, version = version + 1
+		 WHERE wid = $2 AND user_uid = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		[]any{environment, wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+}
+
+// SetCombinatorResourceEnvironment 把 combinator 资源移到另一个环境，expectedVersion 为 0 时不检查版本，返回新版本
+func SetCombinatorResourceEnvironment(ctx context.Context, userUID, resourceType, resourceID, environment string, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, DB,
+		`UPDATE combinator_resources SET environment = $1, version = version + 1
+		 WHERE user_uid = $2 AND resource_type = $3 AND resource_id = $4 AND ($5 = 0 OR version = $5) RETURNING version`,
+		[]any{environment, userUID, resourceType, resourceID, expectedVersion},
+		`SELECT version FROM combinator_resources WHERE user_uid = $1 AND resource_type = $2 AND resource_id = $3`,
+		userUID, resourceType, resourceID)
+}
+
+// SetCustomDomainEnvironment 把自定义域名移到另一个环境，expectedVersion 为 0 时不检查版本，返回新版本
+func SetCustomDomainEnvironment(ctx context.Context, cdid, userUID, environment string, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, DB,
+		`UPDATE custom_domains SET environment = $1, version = version + 1
+		 WHERE cdid = $2 AND user_uid = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		[]any{environment, cdid, userUID, expectedVersion},
+		`SELECT version FROM custom_domains WHERE cdid = $1 AND user_uid = $2`, cdid, userUID)
+}
@@ -0,0 +1,81 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ErrorPages worker 的维护开关和自定义错误页（HTML），空页面用平台默认的
+type ErrorPages struct {
+	Maintenance bool       `json:"maintenance"` // 打开后所有请求都返回维护页（503）
+	Page502     string     `json:"page_502"`
+	Page503     string     `json:"page_503"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// Statuses 有自定义页面的状态码
+func (p ErrorPages) Statuses() []string {
+	var statuses []string
+	if p.Page502 != "" {
+		statuses = append(statuses, "502")
+	}
+	if p.Page503 != "" {
+		statuses = append(statuses, "503")
+	}
+	return statuses
+}
+
+// Page 状态码对应的自定义页面，没有时为空
+func (p ErrorPages) Page(status string) string {
+	switch status {
+	case "502":
+		return p.Page502
+	case "503":
+		return p.Page503
+	}
+	return ""
+}
+
+// GetWorkerErrorPages 获取 worker 的维护开关和错误页，worker 不属于该用户时按没有设置处理
+func GetWorkerErrorPages(ctx context.Context, wid, userUID string) (ErrorPages, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	p := ErrorPages{}
+	var updatedAt time.Time
+	err := DB.QueryRowContext(ctx,
+		`SELECT p.maintenance, p.page_502, p.page_503, p.updated_at
+		 FROM worker_error_pages p JOIN workers w ON w.wid = p.wid
+		 WHERE p.wid = $1 AND w.user_uid = $2`, wid, userUID,
+	).Scan(&p.Maintenance, &p.Page502, &p.Page503, &updatedAt)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	p.UpdatedAt = &updatedAt
+	return p, nil
+}
+
+// SetWorkerErrorPages 在 tx 里替换 worker 的维护开关和错误页并把 worker 版本加一，expectedVersion 为 0 时不检查版本，返回新版本；
+// worker 不存在或不属于该用户时返回 ErrNotFound
+func SetWorkerErrorPages(ctx context.Context, tx *sql.Tx, wid, userUID string, p ErrorPages, expectedVersion int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	version, err := updateVersioned(ctx, tx,
+		`UPDATE workers SET version = version + 1
+		 WHERE wid = $1 AND user_uid = $2 AND ($3 = 0 OR version = $3) RETURNING version`,
+		[]any{wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+	if err != nil {
+		return version, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO worker_error_pages (wid, maintenance, page_502, page_503) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (wid) DO UPDATE SET maintenance = EXCLUDED.maintenance, page_502 = EXCLUDED.page_502,
+		     page_503 = EXCLUDED.page_503, updated_at = CURRENT_TIMESTAMP`,
+		wid, p.Maintenance, p.Page502, p.Page503,
+	)
+	return version, err
+}
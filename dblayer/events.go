@@ -0,0 +1,157 @@
+package dblayer
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/logging"
+
+	"github.com/lib/pq"
+)
+
+var eventsLog = logging.Component("events")
+
+// EventChannel Postgres NOTIFY 频道名
+const EventChannel = "console_events"
+
+// 事件类型
+const (
+	EventWorkerDeploy    = "worker.deploy"
+	EventWorkerPhase     = "worker.phase"
+	EventWorkerBulk      = "worker.bulk"
+	EventDomainVerify    = "domain.verify"
+	EventResourceStatus  = "resource.status"
+	EventJobFinished     = "job.finished"
+	EventWorkerAlert     = "worker.alert"
+	EventUptimeStatus    = "uptime.status"
+	EventBillingPayment  = "billing.payment"
+	EventBillingDunning  = "billing.dunning"
+	EventSpendCap        = "billing.spend_cap"
+	EventWorkerIdle      = "worker.idle"
+	EventWorkerRestart   = "worker.restart"
+	EventWorkerResize    = "worker.resize"
+	EventCombinatorPhase = "combinator.phase"
+	EventNotification    = "notification"
+)
+
+// Event 资源状态变化事件，inner/outer 之间通过 pg_notify 传递
+type Event struct {
+	UserUID      string    `json:"user_uid"`
+	Kind         string    `json:"kind"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   string    `json:"resource_id"`
+	Status       string    `json:"status"`
+	Message      string    `json:"message,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// PublishEvent 广播事件，失败只记日志（事件是尽力而为的通知）
+func PublishEvent(ctx context.Context, ev Event) {
+	if DB == nil {
+		return
+	}
+	PublishEventTx(ctx, DB, ev)
+}
+
+// PublishEventTx 在事务 q 里广播事件，事务提交后才会送达，回滚则不送达
+func PublishEventTx(ctx context.Context, q Querier, ev Event) {
+	if ev.UserUID == "" {
+		return
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	// NOTIFY payload 上限 8000 字节
+	if len(ev.Message) > 1024 {
+		ev.Message = ev.Message[:1024]
+	}
+	payload, _ := json.Marshal(ev)
+	if _, err := q.ExecContext(ctx, `SELECT pg_notify($1, $2)`, EventChannel, string(payload)); err != nil {
+		eventsLog.Error("publish failed", "kind", ev.Kind, "user_id", ev.UserUID, "error", err)
+	}
+}
+
+// EventBus 进程内的事件分发，按用户订阅
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[chan Event]string
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]string)}
+}
+
+// Subscribe 订阅某个用户的事件
+func (b *EventBus) Subscribe(userUID string) chan Event {
+	ch := make(chan Event, 32)
+	b.mu.Lock()
+	b.subs[ch] = userUID
+	b.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe 取消订阅并关闭 channel
+func (b *EventBus) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+	b.mu.Unlock()
+}
+
+// Dispatch 把事件投递给该用户的所有订阅者，慢消费者直接丢弃
+func (b *EventBus) Dispatch(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch, uid := range b.subs {
+		if uid != ev.UserUID {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Listen 在独立连接上 LISTEN 事件频道，并把收到的事件分发给订阅者
+func (b *EventBus) Listen(dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			eventsLog.Warn("listener", "error", err)
+		}
+	})
+	if err := listener.Listen(EventChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case n, ok := <-listener.Notify:
+				if !ok {
+					return
+				}
+				if n == nil { // reconnected, events in between are lost
+					continue
+				}
+				var ev Event
+				if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+					eventsLog.Warn("bad payload", "error", err)
+					continue
+				}
+				b.Dispatch(ev)
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+	eventsLog.Info("listening", "channel", EventChannel)
+	return nil
+}
@@ -0,0 +1,155 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FeatureFlag feature_flags 表里的一个开关，Users 是按用户的覆盖（uid -> 开/关）
+type FeatureFlag struct {
+	Key            string          `json:"key"`
+	Description    string          `json:"description"`
+	Enabled        bool            `json:"enabled"`
+	RolloutPercent int             `json:"rollout_percent"`
+	Users          map[string]bool `json:"users"`
+	UpdatedBy      string          `json:"updated_by"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ListFeatureFlags 所有开关及其按用户的覆盖，按 key 排序
+func ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT key, description, enabled, rollout_percent, updated_by, updated_at FROM feature_flags ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []FeatureFlag{}
+	for rows.Next() {
+		f := FeatureFlag{Users: map[string]bool{}}
+		if err := rows.Scan(&f.Key, &f.Description, &f.Enabled, &f.RolloutPercent, &f.UpdatedBy, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*FeatureFlag, len(out))
+	for i := range out {
+		byKey[out[i].Key] = &out[i]
+	}
+
+	users, err := DB.QueryContext(ctx, `SELECT flag_key, user_uid, enabled FROM feature_flag_users`)
+	if err != nil {
+		return nil, err
+	}
+	defer users.Close()
+	for users.Next() {
+		var key, uid string
+		var enabled bool
+		if err := users.Scan(&key, &uid, &enabled); err != nil {
+			return nil, err
+		}
+		if f := byKey[key]; f != nil {
+			f.Users[uid] = enabled
+		}
+	}
+	return out, users.Err()
+}
+
+// SetFeatureFlag 新建或更新开关（按用户的覆盖保留），并通知所有实例重新加载
+func SetFeatureFlag(ctx context.Context, key, description string, enabled bool, rolloutPercent int, updatedBy string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO feature_flags (key, description, enabled, rollout_percent, updated_by) VALUES ($1, $2, $3, $4, $5)
+			 ON CONFLICT (key) DO UPDATE SET description = EXCLUDED.description, enabled = EXCLUDED.enabled,
+			     rollout_percent = EXCLUDED.rollout_percent, updated_by = EXCLUDED.updated_by, updated_at = CURRENT_TIMESTAMP`,
+			key, description, enabled, rolloutPercent, updatedBy,
+		); err != nil {
+			return err
+		}
+		return notifyFeatureFlag(ctx, tx, key)
+	})
+}
+
+// DeleteFeatureFlag 删除开关和它的用户覆盖，没有该开关时返回 ErrNotFound
+func DeleteFeatureFlag(ctx context.Context, key string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM feature_flags WHERE key = $1`, key)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return notifyFeatureFlag(ctx, tx, key)
+	})
+}
+
+// SetFeatureFlagUser 对单个用户打开或关闭开关，开关或用户不存在时返回 ErrNotFound
+func SetFeatureFlagUser(ctx context.Context, key, userUID string, enabled bool, updatedBy string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO feature_flag_users (flag_key, user_uid, enabled) VALUES ($1, $2, $3)
+			 ON CONFLICT (flag_key, user_uid) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = CURRENT_TIMESTAMP`,
+			key, userUID, enabled,
+		)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := touchFeatureFlag(ctx, tx, key, updatedBy); err != nil {
+			return err
+		}
+		return notifyFeatureFlag(ctx, tx, key)
+	})
+}
+
+// DeleteFeatureFlagUser 删除单个用户的覆盖（回到 enabled/rollout_percent），没有覆盖时返回 ErrNotFound
+func DeleteFeatureFlagUser(ctx context.Context, key, userUID, updatedBy string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM feature_flag_users WHERE flag_key = $1 AND user_uid = $2`, key, userUID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		if err := touchFeatureFlag(ctx, tx, key, updatedBy); err != nil {
+			return err
+		}
+		return notifyFeatureFlag(ctx, tx, key)
+	})
+}
+
+// touchFeatureFlag 用户覆盖变化也记在开关的 updated_by/updated_at 上
+func touchFeatureFlag(ctx context.Context, tx *sql.Tx, key, updatedBy string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE feature_flags SET updated_by = $2, updated_at = CURRENT_TIMESTAMP WHERE key = $1`, key, updatedBy)
+	return err
+}
+
+// notifyFeatureFlag 开关和运行时配置共用 SettingsChannel，payload 带 "flag:" 前缀；通知随事务提交送达
+func notifyFeatureFlag(ctx context.Context, tx *sql.Tx, key string) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, SettingsChannel, "flag:"+key)
+	return err
+}
@@ -0,0 +1,123 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// GitHubBinding worker 绑定的 GitHub 仓库分支：push 到 Branch（Workflow 非空时改为该 workflow 在 Branch 上成功运行）
+// 后按 ImageTemplate 部署该提交。WebhookSecret 校验投递，GitHubToken 非空时把部署结果写成 commit status，
+// 两者都加密存储、不返回给前端
+type GitHubBinding struct {
+	WorkerID       string    `json:"worker_id"`
+	UserUID        string    `json:"user_uid"`
+	Repo           string    `json:"repo"`
+	Branch         string    `json:"branch"`
+	ImageTemplate  string    `json:"image_template"`
+	Workflow       string    `json:"workflow,omitempty"`
+	WebhookSecret  string    `json:"-"`
+	GitHubToken    string    `json:"-"`
+	HasGitHubToken bool      `json:"has_github_token"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+const githubBindingColumns = `worker_id, user_uid, repo, branch, image_template, workflow, webhook_secret, github_token, created_at, updated_at`
+
+func scanGitHubBinding(row rowScanner) (*GitHubBinding, error) {
+	var b GitHubBinding
+	err := row.Scan(&b.WorkerID, &b.UserUID, &b.Repo, &b.Branch, &b.ImageTemplate, &b.Workflow, &b.WebhookSecret, &b.GitHubToken, &b.CreatedAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.WebhookSecret, err = openColumn(colBindingWebhookSecret, b.WebhookSecret); err != nil {
+		return nil, err
+	}
+	if b.GitHubToken, err = openColumn(colBindingGitHubToken, b.GitHubToken); err != nil {
+		return nil, err
+	}
+	b.HasGitHubToken = b.GitHubToken != ""
+	return &b, nil
+}
+
+// UpsertGitHubBinding 创建或更新 worker 的仓库绑定，worker 不存在或不属于该用户时返回 ErrNotFound。
+// 已有绑定时保留原 WebhookSecret，GitHubToken 为空表示不修改；回填解密后的 WebhookSecret，created 表示是新建的
+func UpsertGitHubBinding(ctx context.Context, b *GitHubBinding) (created bool, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	webhookSecret, err := sealColumn(colBindingWebhookSecret, b.WebhookSecret)
+	if err != nil {
+		return false, err
+	}
+	token, err := sealColumn(colBindingGitHubToken, b.GitHubToken)
+	if err != nil {
+		return false, err
+	}
+	err = DB.QueryRowContext(ctx,
+		`INSERT INTO github_bindings (worker_id, user_uid, repo, branch, image_template, workflow, webhook_secret, github_token)
+		 SELECT wid, user_uid, $3, $4, $5, $6, $7, $8 FROM workers WHERE wid = $1 AND user_uid = $2
+		 ON CONFLICT (worker_id) DO UPDATE SET
+		   repo = EXCLUDED.repo, branch = EXCLUDED.branch, image_template = EXCLUDED.image_template, workflow = EXCLUDED.workflow,
+		   github_token = CASE WHEN EXCLUDED.github_token = '' THEN github_bindings.github_token ELSE EXCLUDED.github_token END,
+		   updated_at = CURRENT_TIMESTAMP
+		 RETURNING webhook_secret, github_token <> '', created_at, updated_at, xmax = 0`,
+		b.WorkerID, b.UserUID, b.Repo, b.Branch, b.ImageTemplate, b.Workflow, webhookSecret, token,
+	).Scan(&webhookSecret, &b.HasGitHubToken, &b.CreatedAt, &b.UpdatedAt, &created)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	b.WebhookSecret, err = openColumn(colBindingWebhookSecret, webhookSecret)
+	return created, err
+}
+
+// GetGitHubBinding 获取 worker 的仓库绑定（部署任务写 commit status 用，不验证归属）
+func GetGitHubBinding(ctx context.Context, workerID string) (*GitHubBinding, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanGitHubBinding(DB.QueryRowContext(ctx,
+		`SELECT `+githubBindingColumns+` FROM github_bindings WHERE worker_id = $1`, workerID,
+	))
+}
+
+// GetGitHubBindingByOwner 获取 worker 的仓库绑定，验证归属
+func GetGitHubBindingByOwner(ctx context.Context, workerID, userUID string) (*GitHubBinding, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanGitHubBinding(DB.QueryRowContext(ctx,
+		`SELECT `+githubBindingColumns+` FROM github_bindings WHERE worker_id = $1 AND user_uid = $2`, workerID, userUID,
+	))
+}
+
+// ListGitHubBindingsByRepo 获取绑定了某个仓库（owner/name，不区分大小写）的全部 worker
+func ListGitHubBindingsByRepo(ctx context.Context, repo string) ([]*GitHubBinding, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+githubBindingColumns+` FROM github_bindings WHERE LOWER(repo) = LOWER($1) ORDER BY worker_id`, repo,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	bindings := []*GitHubBinding{}
+	for rows.Next() {
+		b, err := scanGitHubBinding(rows)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, rows.Err()
+}
+
+// DeleteGitHubBinding 解除 worker 的仓库绑定
+func DeleteGitHubBinding(ctx context.Context, workerID, userUID string) error {
+	return execOwned(ctx, `DELETE FROM github_bindings WHERE worker_id = $1 AND user_uid = $2`, workerID, userUID)
+}
@@ -0,0 +1,72 @@
+package dblayer
+
+import (
+	"context"
+	"time"
+)
+
+// IdleWorker 空闲回收的候选：free 套餐用户已部署的 active 或因空闲暂停的 worker
+type IdleWorker struct {
+	WID           string
+	UserUID       string
+	Status        string
+	LastTrafficAt *time.Time // 最近一次观察到入站流量，开始跟踪前为 nil
+	WarnedAt      *time.Time
+	PausedAt      *time.Time
+}
+
+// ListIdleCandidates 空闲回收检查的 worker，idleStatus 为因空闲暂停的状态
+func ListIdleCandidates(ctx context.Context, idleStatus string) ([]IdleWorker, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT w.wid, w.user_uid, w.status, i.last_traffic_at, i.warned_at, i.paused_at
+		 FROM workers w
+		 JOIN users u ON u.uid = w.user_uid
+		 LEFT JOIN worker_idle i ON i.wid = w.wid
+		 WHERE u.plan = $1 AND w.active_version_id IS NOT NULL AND w.status IN ('active', $2)
+		 ORDER BY w.id`,
+		DefaultPlan, idleStatus,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var workers []IdleWorker
+	for rows.Next() {
+		var w IdleWorker
+		if err := rows.Scan(&w.WID, &w.UserUID, &w.Status, &w.LastTrafficAt, &w.WarnedAt, &w.PausedAt); err != nil {
+			return nil, err
+		}
+		workers = append(workers, w)
+	}
+	return workers, rows.Err()
+}
+
+// TouchWorkerTraffic 记录 worker 在 at 有流量，清除空闲警告和暂停记录
+func TouchWorkerTraffic(ctx context.Context, wid string, at time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO worker_idle (wid, last_traffic_at) VALUES ($1, $2)
+		 ON CONFLICT (wid) DO UPDATE SET last_traffic_at = EXCLUDED.last_traffic_at, warned_at = NULL, paused_at = NULL`,
+		wid, at,
+	)
+	return err
+}
+
+// MarkWorkerIdleWarned 已发送空闲警告
+func MarkWorkerIdleWarned(ctx context.Context, wid string, at time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx, `UPDATE worker_idle SET warned_at = $1 WHERE wid = $2`, at, wid)
+	return err
+}
+
+// MarkWorkerIdlePaused 已因空闲暂停
+func MarkWorkerIdlePaused(ctx context.Context, wid string, at time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx, `UPDATE worker_idle SET paused_at = $1 WHERE wid = $2`, at, wid)
+	return err
+}
@@ -0,0 +1,217 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 候补名单的状态
+const (
+	WaitlistPending  = "pending"
+	WaitlistApproved = "approved"
+	WaitlistRejected = "rejected"
+)
+
+// ErrInvitationExists 邀请码已存在
+var ErrInvitationExists = errors.New("invitation code already exists")
+
+// Invitation 管理员生成的邀请码；MaxUses 为 0 表示不限次数，Email 非空时只有该邮箱能用
+type Invitation struct {
+	ID        int        `json:"id"`
+	Code      string     `json:"code"`
+	Email     string     `json:"email,omitempty"`
+	Note      string     `json:"note"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// WaitlistEntry 候补名单里的一次注册申请；批准时生成的邀请码记在 InvitationID
+type WaitlistEntry struct {
+	ID           int        `json:"id"`
+	Email        string     `json:"email"`
+	Locale       string     `json:"locale"`
+	Status       string     `json:"status"`
+	InvitationID *int       `json:"invitation_id"`
+	DecidedBy    string     `json:"decided_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DecidedAt    *time.Time `json:"decided_at"`
+}
+
+const invitationColumns = `id, code, email, note, max_uses, uses, expires_at, created_by, created_at`
+
+func scanInvitation(row rowScanner) (*Invitation, error) {
+	var inv Invitation
+	err := row.Scan(&inv.ID, &inv.Code, &inv.Email, &inv.Note, &inv.MaxUses, &inv.Uses, &inv.ExpiresAt, &inv.CreatedBy, &inv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// CreateInvitation 在 q 里新建邀请码，回填 ID、Uses 和 CreatedAt；q 可以是事务，以便和候补名单的批准一起写入
+func CreateInvitation(ctx context.Context, q Querier, inv *Invitation) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO invitations (code, email, note, max_uses, expires_at, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING id, uses, created_at`,
+		inv.Code, inv.Email, inv.Note, inv.MaxUses, inv.ExpiresAt, inv.CreatedBy,
+	).Scan(&inv.ID, &inv.Uses, &inv.CreatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrInvitationExists
+	}
+	return err
+}
+
+// ListInvitations 所有邀请码，新的在前；active 时只列出未过期且还有剩余次数的
+func ListInvitations(ctx context.Context, active bool) ([]*Invitation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+invitationColumns+` FROM invitations
+		 WHERE NOT $1 OR ((expires_at IS NULL OR expires_at > $2) AND (max_uses = 0 OR uses < max_uses))
+		 ORDER BY id DESC`,
+		active, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
+
+// DeleteInvitation 作废邀请码，已用它注册的用户不受影响
+func DeleteInvitation(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM invitations WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RedeemInvitation 在注册事务里用掉一次邀请码。码不存在、已过期、次数用完或绑定了别的邮箱时返回 ErrNotFound
+func RedeemInvitation(ctx context.Context, q Querier, code, email string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var id int
+	err := q.QueryRowContext(ctx,
+		`UPDATE invitations SET uses = uses + 1
+		 WHERE code = $1 AND (email = '' OR lower(email) = lower($2))
+		   AND (expires_at IS NULL OR expires_at > $3) AND (max_uses = 0 OR uses < max_uses)
+		 RETURNING id`,
+		code, email, time.Now().UTC(),
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
+
+const waitlistColumns = `id, email, locale, status, invitation_id, decided_by, created_at, decided_at`
+
+func scanWaitlistEntry(row rowScanner) (*WaitlistEntry, error) {
+	var e WaitlistEntry
+	var invitationID sql.NullInt64
+	err := row.Scan(&e.ID, &e.Email, &e.Locale, &e.Status, &invitationID, &e.DecidedBy, &e.CreatedAt, &e.DecidedAt)
+	if err != nil {
+		return nil, err
+	}
+	if invitationID.Valid {
+		id := int(invitationID.Int64)
+		e.InvitationID = &id
+	}
+	return &e, nil
+}
+
+// JoinWaitlist 把注册申请加入候补名单。同一邮箱只有一条：还在等待时只更新语言，
+// 被拒绝过的重新变成待审，已批准的原样返回
+func JoinWaitlist(ctx context.Context, q Querier, email, locale string) (*WaitlistEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanWaitlistEntry(q.QueryRowContext(ctx,
+		`INSERT INTO signup_waitlist (email, locale) VALUES ($1, $2)
+		 ON CONFLICT (email) DO UPDATE SET
+		     locale = CASE WHEN signup_waitlist.status = 'approved' THEN signup_waitlist.locale ELSE EXCLUDED.locale END,
+		     status = CASE WHEN signup_waitlist.status = 'rejected' THEN 'pending' ELSE signup_waitlist.status END,
+		     created_at = CASE WHEN signup_waitlist.status = 'rejected' THEN CURRENT_TIMESTAMP ELSE signup_waitlist.created_at END,
+		     decided_at = CASE WHEN signup_waitlist.status = 'rejected' THEN NULL ELSE signup_waitlist.decided_at END
+		 RETURNING `+waitlistColumns,
+		email, locale,
+	))
+}
+
+// ListWaitlist 候补名单，先申请的在前；status 为空时列出全部
+func ListWaitlist(ctx context.Context, status string, limit int) ([]*WaitlistEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+waitlistColumns+` FROM signup_waitlist
+		 WHERE $1 OR status = $2
+		 ORDER BY id LIMIT $3`,
+		status == "", status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*WaitlistEntry{}
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// GetWaitlistEntry 按 ID 读取一条申请
+func GetWaitlistEntry(ctx context.Context, id int) (*WaitlistEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	e, err := scanWaitlistEntry(DB.QueryRowContext(ctx,
+		`SELECT `+waitlistColumns+` FROM signup_waitlist WHERE id = $1`, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return e, err
+}
+
+// DecideWaitlistEntry 把待审的申请标为批准或拒绝，invitationID 是批准时生成的邀请码（拒绝时为 nil）。
+// 申请不存在或已经处理过时返回 ErrNotFound
+func DecideWaitlistEntry(ctx context.Context, q Querier, id int, status string, invitationID *int, decidedBy string) (*WaitlistEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	e, err := scanWaitlistEntry(q.QueryRowContext(ctx,
+		`UPDATE signup_waitlist SET status = $2, invitation_id = $3, decided_by = $4, decided_at = CURRENT_TIMESTAMP
+		 WHERE id = $1 AND status = 'pending'
+		 RETURNING `+waitlistColumns,
+		id, status, invitationID, decidedBy,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return e, err
+}
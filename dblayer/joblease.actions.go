@@ -0,0 +1,173 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// 任务租约的状态：running 正在某个实例上执行；requeued 租约过期后被重新提交，等待再次开始；
+// abandoned 超过最大尝试次数，不再重试
+const (
+	JobLeaseRunning   = "running"
+	JobLeaseRequeued  = "requeued"
+	JobLeaseAbandoned = "abandoned"
+)
+
+// JobLease 一次执行中（或卡住）的任务。每次执行一行：任务 ID 在并发执行之间并不唯一，
+// 重新提交的任务按行 ID 接手原来的行
+type JobLease struct {
+	ID          int64     `json:"id"`
+	JobType     string    `json:"job_type"`
+	JobID       string    `json:"job_id"`
+	UserUID     string    `json:"user_uid,omitempty"`
+	Payload     string    `json:"-"`
+	Owner       string    `json:"owner"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	LeaseUntil  time.Time `json:"lease_until"`
+}
+
+const jobLeaseColumns = `id, job_type, job_id, user_uid, payload, owner, status, attempts, last_error, started_at, heartbeat_at, lease_until`
+
+func scanJobLease(row rowScanner) (*JobLease, error) {
+	var l JobLease
+	err := row.Scan(&l.ID, &l.JobType, &l.JobID, &l.UserUID, &l.Payload, &l.Owner, &l.Status, &l.Attempts, &l.LastError,
+		&l.StartedAt, &l.HeartbeatAt, &l.LeaseUntil)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// AcquireJobLease 任务开始时新建一行租约，回填 ID、Status、Attempts 和时间
+func AcquireJobLease(ctx context.Context, l *JobLease, until time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return DB.QueryRowContext(ctx,
+		`INSERT INTO job_leases (job_type, job_id, user_uid, payload, owner, lease_until)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING id, status, attempts, started_at, heartbeat_at`,
+		l.JobType, l.JobID, l.UserUID, l.Payload, l.Owner, until,
+	).Scan(&l.ID, &l.Status, &l.Attempts, &l.StartedAt, &l.HeartbeatAt)
+}
+
+// ResumeJobLease 重新提交的任务开始时接手 id 那一行（沿用尝试次数），回填其余字段。
+// 那一行已不是 requeued（又过期被回收了）时返回 ErrNotFound
+func ResumeJobLease(ctx context.Context, id int64, owner string, until time.Time) (*JobLease, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	l, err := scanJobLease(DB.QueryRowContext(ctx,
+		`UPDATE job_leases SET owner = $2, status = 'running', last_error = '', started_at = CURRENT_TIMESTAMP,
+		     heartbeat_at = CURRENT_TIMESTAMP, lease_until = $3
+		 WHERE id = $1 AND status = 'requeued'
+		 RETURNING `+jobLeaseColumns,
+		id, owner, until,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return l, err
+}
+
+// RenewJobLease 心跳：把租约延到 until。租约已不属于 owner（被其他实例接手）时返回 ErrNotFound
+func RenewJobLease(ctx context.Context, id int64, owner string, until time.Time) error {
+	return execOwned(ctx,
+		`UPDATE job_leases SET heartbeat_at = CURRENT_TIMESTAMP, lease_until = $3
+		 WHERE id = $1 AND owner = $2 AND status = 'running'`,
+		id, owner, until,
+	)
+}
+
+// ReleaseJobLease 任务结束，删除租约
+func ReleaseJobLease(ctx context.Context, id int64, owner string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx, `DELETE FROM job_leases WHERE id = $1 AND owner = $2 AND status = 'running'`, id, owner)
+	return err
+}
+
+// ExpireJobLease 任务 panic、超时或随停机中断：记下原因并让租约立即过期，交给回收
+func ExpireJobLease(ctx context.Context, id int64, owner, cause string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE job_leases SET lease_until = CURRENT_TIMESTAMP, last_error = $3
+		 WHERE id = $1 AND owner = $2 AND status = 'running'`,
+		id, owner, cause,
+	)
+	return err
+}
+
+// ReapJobLeases 认领最多 limit 个租约已过期的任务：尝试次数不到 maxAttempts 的标为 requeued、
+// 次数加一、归 owner，并把租约延到 until，等待重新提交后再次开始（没开始就会再次过期）；
+// 其余标为 abandoned。多个实例同时回收时行锁保证每个任务只被一个拿到
+func ReapJobLeases(ctx context.Context, owner string, maxAttempts int, until time.Time, limit int) ([]*JobLease, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`UPDATE job_leases
+		 SET status = CASE WHEN attempts < $2 THEN 'requeued' ELSE 'abandoned' END,
+		     attempts = CASE WHEN attempts < $2 THEN attempts + 1 ELSE attempts END,
+		     last_error = CASE WHEN last_error = '' THEN 'lease expired' ELSE last_error END,
+		     owner = $1, lease_until = $3
+		 WHERE id IN (
+		     SELECT id FROM job_leases WHERE status <> 'abandoned' AND lease_until < CURRENT_TIMESTAMP
+		     ORDER BY lease_until LIMIT $4
+		     FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+jobLeaseColumns,
+		owner, maxAttempts, until, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*JobLease
+	for rows.Next() {
+		l, err := scanJobLease(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
+
+// ListJobLeases 租约列表，status 为空时列出全部，最早开始的在前
+func ListJobLeases(ctx context.Context, status string, limit int) ([]*JobLease, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+jobLeaseColumns+` FROM job_leases WHERE $1 OR status = $2 ORDER BY started_at LIMIT $3`,
+		status == "", status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*JobLease{}
+	for rows.Next() {
+		l, err := scanJobLease(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
+
+// PruneJobLeases 删除 before 之前放弃的任务
+func PruneJobLeases(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM job_leases WHERE status = 'abandoned' AND lease_until < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
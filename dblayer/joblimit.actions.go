@@ -0,0 +1,88 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// JobLimitAll tenant_job_limits.job_type 取这个值时限制该租户全部任务的总数
+const JobLimitAll = "*"
+
+// TenantJobLimit 一个租户某类任务（或全部任务）同时执行的上限
+type TenantJobLimit struct {
+	UserUID    string    `json:"user_uid"`
+	JobType    string    `json:"job_type"`
+	MaxRunning int       `json:"max_running"`
+	UpdatedBy  string    `json:"updated_by"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ListTenantJobLimits 所有租户的任务并发上限，按用户和类型排序
+func ListTenantJobLimits(ctx context.Context) ([]*TenantJobLimit, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT user_uid, job_type, max_running, updated_by, updated_at FROM tenant_job_limits ORDER BY user_uid, job_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*TenantJobLimit{}
+	for rows.Next() {
+		var l TenantJobLimit
+		if err := rows.Scan(&l.UserUID, &l.JobType, &l.MaxRunning, &l.UpdatedBy, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &l)
+	}
+	return list, rows.Err()
+}
+
+// SetTenantJobLimit 新建或更新上限，回填 UpdatedAt，并通知所有实例重新加载；用户不存在时返回 ErrNotFound
+func SetTenantJobLimit(ctx context.Context, l *TenantJobLimit) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO tenant_job_limits (user_uid, job_type, max_running, updated_by) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (user_uid, job_type) DO UPDATE SET max_running = EXCLUDED.max_running,
+			     updated_by = EXCLUDED.updated_by, updated_at = CURRENT_TIMESTAMP
+			 RETURNING updated_at`,
+			l.UserUID, l.JobType, l.MaxRunning, l.UpdatedBy,
+		).Scan(&l.UpdatedAt)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return notifyTenantJobLimit(ctx, tx, l.UserUID)
+	})
+}
+
+// DeleteTenantJobLimit 删除上限并通知所有实例重新加载，没有时返回 ErrNotFound
+func DeleteTenantJobLimit(ctx context.Context, userUID, jobType string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM tenant_job_limits WHERE user_uid = $1 AND job_type = $2`, userUID, jobType)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return notifyTenantJobLimit(ctx, tx, userUID)
+	})
+}
+
+// notifyTenantJobLimit 和运行时配置共用 SettingsChannel，payload 带 "joblimit:" 前缀；通知随事务提交送达
+func notifyTenantJobLimit(ctx context.Context, tx *sql.Tx, userUID string) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, SettingsChannel, "joblimit:"+userUID)
+	return err
+}
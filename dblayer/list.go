@@ -0,0 +1,154 @@
+package dblayer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+
+	SortByCreatedAt = "created_at"
+	SortByName      = "name"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions 列表接口的分页、排序、过滤参数
+type ListOptions struct {
+	Limit  int
+	Cursor string
+	Sort   string // created_at | name
+	Asc    bool   // 默认倒序
+	Status string
+	Type   string
+	Tags   []TagFilter // 全部满足（AND）
+
+	Environment string // 只有 workers、combinator_resources、custom_domains 有 environment 列
+}
+
+// listCursor keyset 分页游标：排序方式 + 排序列的值 + id
+type listCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+const cursorTimeLayout = "2006-01-02T15:04:05.999999"
+
+func (o *ListOptions) normalize() {
+	if o.Limit <= 0 {
+		o.Limit = DefaultListLimit
+	}
+	if o.Limit > MaxListLimit {
+		o.Limit = MaxListLimit
+	}
+	if o.Sort != SortByName {
+		o.Sort = SortByCreatedAt
+	}
+}
+
+// listQuery 在 base（已含 WHERE 条件）后拼接状态过滤、keyset 条件、排序和 LIMIT。
+// nameCol 是该表用于 name 排序的列。
+func (o *ListOptions) listQuery(base string, args []any, nameCol string) (string, []any, error) {
+	o.normalize()
+
+	var b strings.Builder
+	b.WriteString(base)
+
+	if o.Status != "" {
+		args = append(args, o.Status)
+		fmt.Fprintf(&b, " AND status = $%d", len(args))
+	}
+	if o.Environment != "" {
+		args = append(args, o.Environment)
+		fmt.Fprintf(&b, " AND environment = $%d", len(args))
+	}
+	for _, t := range o.Tags {
+		if t.HasValue {
+			args = append(args, Tags{t.Key: t.Value})
+			fmt.Fprintf(&b, " AND tags @> $%d::jsonb", len(args))
+		} else {
+			args = append(args, t.Key)
+			fmt.Fprintf(&b, " AND tags ? $%d", len(args))
+		}
+	}
+
+	col := "created_at"
+	if o.Sort == SortByName {
+		col = nameCol
+	}
+	dir, cmp := "DESC", "<"
+	if o.Asc {
+		dir, cmp = "ASC", ">"
+	}
+
+	if o.Cursor != "" {
+		cur, err := decodeCursor(o.Cursor, o.Sort)
+		if err != nil {
+			return "", nil, err
+		}
+		if o.Sort == SortByCreatedAt {
+			args = append(args, cur.Value, cur.ID)
+			fmt.Fprintf(&b, " AND (created_at, id) %s ($%d::timestamp, $%d)", cmp, len(args)-1, len(args))
+		} else {
+			args = append(args, cur.Value, cur.ID)
+			fmt.Fprintf(&b, " AND (%s, id) %s ($%d, $%d)", col, cmp, len(args)-1, len(args))
+		}
+	}
+
+	// 多取一条用于判断是否还有下一页
+	args = append(args, o.Limit+1)
+	fmt.Fprintf(&b, " ORDER BY %s %s, id %s LIMIT $%d", col, dir, dir, len(args))
+	return b.String(), args, nil
+}
+
+// cursorValue 返回某条记录在当前排序下的游标值
+func (o *ListOptions) cursorValue(createdAt time.Time, name string) string {
+	if o.Sort == SortByName {
+		return name
+	}
+	return createdAt.Format(cursorTimeLayout)
+}
+
+// trimPage 截掉多取的一条，并生成下一页游标（没有下一页时为空）
+func trimPage[T any](items []T, o *ListOptions, key func(T) (string, int)) ([]T, string) {
+	if len(items) <= o.Limit {
+		return items, ""
+	}
+	items = items[:o.Limit]
+	v, id := key(items[len(items)-1])
+	return items, encodeCursor(listCursor{Sort: o.Sort, Value: v, ID: id})
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor 解析游标并检查它是按 sort 排序时生成的；换了排序方式或时间戳格式不对时返回 ErrInvalidCursor，
+// 不让坏的值落到 SQL 里变成类型转换错误
+func decodeCursor(s, sort string) (listCursor, error) {
+	var c listCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.Sort != sort {
+		return c, ErrInvalidCursor
+	}
+	if sort == SortByCreatedAt {
+		if _, err := time.Parse(cursorTimeLayout, c.Value); err != nil {
+			return c, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,63 @@
+package dblayer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListQueryCursor(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	byTime := encodeCursor(listCursor{Sort: SortByCreatedAt, Value: created.Format(cursorTimeLayout), ID: 7})
+	byName := encodeCursor(listCursor{Sort: SortByName, Value: "api", ID: 7})
+
+	tests := []struct {
+		name    string
+		opts    ListOptions
+		wantErr bool
+	}{
+		{"created_at cursor", ListOptions{Cursor: byTime}, false},
+		{"name cursor", ListOptions{Cursor: byName, Sort: SortByName}, false},
+		{"name cursor with created_at sort", ListOptions{Cursor: byName}, true},
+		{"created_at cursor with name sort", ListOptions{Cursor: byTime, Sort: SortByName}, true},
+		{"bad timestamp", ListOptions{Cursor: encodeCursor(listCursor{Sort: SortByCreatedAt, Value: "yesterday", ID: 7})}, true},
+		{"no sort key", ListOptions{Cursor: encodeCursor(listCursor{Value: "api", ID: 7})}, true},
+		{"not base64", ListOptions{Cursor: "!!"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := tt.opts.listQuery("SELECT id FROM workers WHERE user_uid = $1", []any{"u"}, "worker_name")
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Errorf("err = %v, want ErrInvalidCursor", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if !strings.Contains(query, "id) <") {
+				t.Errorf("query has no keyset condition: %s", query)
+			}
+		})
+	}
+}
+
+// trimPage 生成的游标能按同样的排序取下一页
+func TestTrimPageCursorRoundTrip(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, sort := range []string{SortByCreatedAt, SortByName} {
+		o := ListOptions{Limit: 1, Sort: sort}
+		o.normalize()
+		_, next := trimPage([]int{1, 2}, &o, func(id int) (string, int) {
+			return o.cursorValue(created, "api"), id
+		})
+		if next == "" {
+			t.Fatalf("%s: no next cursor", sort)
+		}
+		o.Cursor = next
+		if _, _, err := o.listQuery("SELECT id FROM workers WHERE user_uid = $1", []any{"u"}, "worker_name"); err != nil {
+			t.Errorf("%s: err = %v", sort, err)
+		}
+	}
+}
@@ -0,0 +1,561 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 平台 SMTP 中继：用户验证发信域名、创建 SMTP 凭据；中继每封信先问 inner 能不能发（凭据、
+// 发信域名、退订名单、每日配额），再上报投递结果。mail_messages 是按收件人记的发送日志
+
+// 发信域名状态：三条 DNS 记录都通过才是 verified，之后定期复查，记录丢了变成 error
+const (
+	MailDomainPending  = "pending"
+	MailDomainVerified = "verified"
+	MailDomainError    = "error"
+)
+
+// 发送日志里每个收件人的状态
+const (
+	MailStatusQueued     = "queued"     // 中继已接收
+	MailStatusDelivered  = "delivered"  // 对方服务器已接收
+	MailStatusDeferred   = "deferred"   // 暂时失败，中继会重试
+	MailStatusBounced    = "bounced"    // 退信
+	MailStatusComplained = "complained" // 收件人投诉为垃圾邮件
+	MailStatusRejected   = "rejected"   // 没有发出：在退订名单里或超出配额
+)
+
+// 退订名单的来源
+const (
+	MailSuppressBounce    = "bounce"
+	MailSuppressComplaint = "complaint"
+	MailSuppressManual    = "manual"
+)
+
+var (
+	// ErrMailDomainExists 用户已添加过这个发信域名
+	ErrMailDomainExists = errors.New("mail domain already exists")
+	// ErrMailCredentialExists 同名 SMTP 凭据已存在
+	ErrMailCredentialExists = errors.New("mail credential already exists")
+)
+
+// MailDomain 用户的发信域名。TXT 记录证明域名归属（和自定义域名同一套 _combinator-verify 记录），
+// 另外要求 SPF 包含中继、DKIM 公钥发布在 <selector>._domainkey 下；私钥只在 inner 交给中继签名时读取
+type MailDomain struct {
+	ID                int        `json:"-"`
+	MDID              string     `json:"id"`
+	UserUID           string     `json:"-"`
+	Domain            string     `json:"domain"`
+	TXTName           string     `json:"txt_name"`
+	TXTValue          string     `json:"txt_value"`
+	DKIMSelector      string     `json:"dkim_selector"`
+	DKIMPublicKey     string     `json:"dkim_public_key"` // base64 DER，即 DKIM 记录的 p=
+	OwnershipVerified bool       `json:"ownership_verified"`
+	SPFVerified       bool       `json:"spf_verified"`
+	DKIMVerified      bool       `json:"dkim_verified"`
+	Status            string     `json:"status"`
+	Msg               string     `json:"msg,omitempty"`
+	CheckedAt         *time.Time `json:"checked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+
+	DKIMPrivateKey string `json:"-"` // PEM
+}
+
+// MailCredential 一组 SMTP 凭据，密码只在创建时返回一次
+type MailCredential struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Username   string     `json:"username"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// MailSuppression 退订名单里的一个地址，发给它的信直接拒绝
+type MailSuppression struct {
+	ID        int       `json:"id"`
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason"` // bounce | complaint | manual
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MailMessage 发送日志的一行：一封信的一个收件人
+type MailMessage struct {
+	ID        int       `json:"id"`
+	QueueID   string    `json:"queue_id"`
+	MessageID string    `json:"message_id,omitempty"`
+	Username  string    `json:"username"`
+	From      string    `json:"from"`
+	Recipient string    `json:"recipient"`
+	Subject   string    `json:"subject,omitempty"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ========== 发信域名 ==========
+
+const mailDomainColumns = `id, mdid, user_uid, domain, txt_name, txt_value, dkim_selector, dkim_public_key, dkim_private_key,
+	ownership_verified, spf_verified, dkim_verified, status, msg, checked_at, created_at, updated_at`
+
+func scanMailDomain(row rowScanner) (*MailDomain, error) {
+	var d MailDomain
+	err := row.Scan(&d.ID, &d.MDID, &d.UserUID, &d.Domain, &d.TXTName, &d.TXTValue, &d.DKIMSelector, &d.DKIMPublicKey, &d.DKIMPrivateKey,
+		&d.OwnershipVerified, &d.SPFVerified, &d.DKIMVerified, &d.Status, &d.Msg, &d.CheckedAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if d.DKIMPrivateKey, err = openColumn(colMailDKIMPrivateKey, d.DKIMPrivateKey); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func scanMailDomains(rows *sql.Rows) ([]*MailDomain, error) {
+	defer rows.Close()
+	domains := []*MailDomain{}
+	for rows.Next() {
+		d, err := scanMailDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// DomainVerifyRecord 用户同名自定义域名的归属 TXT 记录，添加发信域名时沿用，DNS 里不用再加一条；
+// 没有时返回 ErrNotFound
+func DomainVerifyRecord(ctx context.Context, userUID, domain string) (name, value string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err = DB.QueryRowContext(ctx,
+		`SELECT txt_name, txt_value FROM custom_domains WHERE user_uid = $1 AND domain = $2
+		 ORDER BY (status = 'success') DESC, id LIMIT 1`,
+		userUID, domain,
+	).Scan(&name, &value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+	value, err = openColumn(colDomainTXTValue, value)
+	return name, value, err
+}
+
+// CreateMailDomain 添加发信域名，回填 ID 和时间；已添加过时返回 ErrMailDomainExists
+func CreateMailDomain(ctx context.Context, d *MailDomain) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	key, err := sealColumn(colMailDKIMPrivateKey, d.DKIMPrivateKey)
+	if err != nil {
+		return err
+	}
+	err = DB.QueryRowContext(ctx,
+		`INSERT INTO mail_domains (mdid, user_uid, domain, txt_name, txt_value, dkim_selector, dkim_public_key, dkim_private_key)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id, status, created_at, updated_at`,
+		d.MDID, d.UserUID, d.Domain, d.TXTName, d.TXTValue, d.DKIMSelector, d.DKIMPublicKey, key,
+	).Scan(&d.ID, &d.Status, &d.CreatedAt, &d.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrMailDomainExists
+	}
+	return err
+}
+
+// GetMailDomain 读取用户的一个发信域名，不存在时返回 ErrNotFound
+func GetMailDomain(ctx context.Context, userUID, mdid string) (*MailDomain, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	d, err := scanMailDomain(DB.QueryRowContext(ctx,
+		`SELECT `+mailDomainColumns+` FROM mail_domains WHERE user_uid = $1 AND mdid = $2`, userUID, mdid,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+// GetVerifiedMailDomain 用户已验证的发信域名（含私钥），没有时返回 ErrNotFound
+func GetVerifiedMailDomain(ctx context.Context, userUID, domain string) (*MailDomain, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	d, err := scanMailDomain(DB.QueryRowContext(ctx,
+		`SELECT `+mailDomainColumns+` FROM mail_domains WHERE user_uid = $1 AND domain = $2 AND status = 'verified'`, userUID, domain,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+// ListMailDomains 用户的所有发信域名，按域名排序
+func ListMailDomains(ctx context.Context, userUID string) ([]*MailDomain, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx, `SELECT `+mailDomainColumns+` FROM mail_domains WHERE user_uid = $1 ORDER BY domain`, userUID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMailDomains(rows)
+}
+
+// ListAllMailDomains 所有用户的发信域名（定期检查 DNS 用）
+func ListAllMailDomains(ctx context.Context) ([]*MailDomain, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT `+mailDomainColumns+` FROM mail_domains ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanMailDomains(rows)
+}
+
+// CountMailDomains 用户已添加的发信域名数
+func CountMailDomains(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM mail_domains WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
+
+// SetMailDomainCheck 记录一次 DNS 检查的结果
+func SetMailDomainCheck(ctx context.Context, d *MailDomain) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return DB.QueryRowContext(ctx,
+		`UPDATE mail_domains SET ownership_verified = $2, spf_verified = $3, dkim_verified = $4, status = $5, msg = $6,
+		   checked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1
+		 RETURNING checked_at, updated_at`,
+		d.ID, d.OwnershipVerified, d.SPFVerified, d.DKIMVerified, d.Status, d.Msg,
+	).Scan(&d.CheckedAt, &d.UpdatedAt)
+}
+
+// DeleteMailDomain 删除发信域名，不存在时返回 ErrNotFound
+func DeleteMailDomain(ctx context.Context, userUID, mdid string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM mail_domains WHERE user_uid = $1 AND mdid = $2`, userUID, mdid)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ========== SMTP 凭据 ==========
+
+// CreateMailCredential 创建 SMTP 凭据，passwordHash 是密码的 SHA-256（hex）；同名时返回 ErrMailCredentialExists
+func CreateMailCredential(ctx context.Context, userUID, name, username, passwordHash string) (*MailCredential, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	cred := MailCredential{Name: name, Username: username}
+	err := DB.QueryRowContext(ctx,
+		`INSERT INTO mail_credentials (user_uid, name, username, password_hash) VALUES ($1, $2, $3, $4)
+		 RETURNING id, created_at`,
+		userUID, name, username, passwordHash,
+	).Scan(&cred.ID, &cred.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrMailCredentialExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// ListMailCredentials 用户的 SMTP 凭据，按名称排序
+func ListMailCredentials(ctx context.Context, userUID string) ([]*MailCredential, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT id, name, username, created_at, last_used_at FROM mail_credentials WHERE user_uid = $1 ORDER BY name`, userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	creds := []*MailCredential{}
+	for rows.Next() {
+		var c MailCredential
+		if err := rows.Scan(&c.ID, &c.Name, &c.Username, &c.CreatedAt, &c.LastUsedAt); err != nil {
+			return nil, err
+		}
+		creds = append(creds, &c)
+	}
+	return creds, rows.Err()
+}
+
+// CountMailCredentials 用户的 SMTP 凭据数
+func CountMailCredentials(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM mail_credentials WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
+
+// DeleteMailCredential 删除 SMTP 凭据，立即失效；不存在时返回 ErrNotFound
+func DeleteMailCredential(ctx context.Context, userUID string, id int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM mail_credentials WHERE user_uid = $1 AND id = $2`, userUID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetMailCredentialHash 按用户名取凭据所属用户和密码哈希，不存在时返回 ErrNotFound。读主库，刚创建的凭据马上能用
+func GetMailCredentialHash(ctx context.Context, username string) (userUID, passwordHash string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err = DB.QueryRowContext(ctx,
+		`SELECT user_uid, password_hash FROM mail_credentials WHERE username = $1`, username,
+	).Scan(&userUID, &passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrNotFound
+	}
+	return userUID, passwordHash, err
+}
+
+// TouchMailCredential 记录凭据最近一次使用，一分钟内只写一次
+func TouchMailCredential(ctx context.Context, username string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE mail_credentials SET last_used_at = CURRENT_TIMESTAMP
+		 WHERE username = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')`,
+		username,
+	)
+	return err
+}
+
+// ========== 退订名单 ==========
+
+// ListMailSuppressions 分页列出退订名单，reason 不为空时只看这个来源；name 排序按地址
+func ListMailSuppressions(ctx context.Context, userUID, reason string, opts ListOptions) ([]*MailSuppression, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT id, address, reason, detail, created_at FROM mail_suppressions WHERE user_uid = $1`
+	args := []any{userUID}
+	if reason != "" {
+		args = append(args, reason)
+		base += fmt.Sprintf(" AND reason = $%d", len(args))
+	}
+	query, args, err := opts.listQuery(base, args, "address")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var list []*MailSuppression
+	for rows.Next() {
+		var s MailSuppression
+		if err := rows.Scan(&s.ID, &s.Address, &s.Reason, &s.Detail, &s.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		list = append(list, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	list, next := trimPage(list, &opts, func(s *MailSuppression) (string, int) {
+		return opts.cursorValue(s.CreatedAt, s.Address), s.ID
+	})
+	return list, next, nil
+}
+
+// AddMailSuppression 把地址加入退订名单，已在名单里时保留原来的来源，返回是否新加
+func AddMailSuppression(ctx context.Context, userUID, address, reason, detail string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx,
+		`INSERT INTO mail_suppressions (user_uid, address, reason, detail) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_uid, address) DO NOTHING`,
+		userUID, address, reason, detail,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteMailSuppression 把地址移出退订名单，不在名单里时返回 ErrNotFound
+func DeleteMailSuppression(ctx context.Context, userUID, address string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM mail_suppressions WHERE user_uid = $1 AND address = $2`, userUID, address)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SuppressedAddresses addresses 中在用户退订名单里的地址
+func SuppressedAddresses(ctx context.Context, userUID string, addresses []string) (map[string]bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT address FROM mail_suppressions WHERE user_uid = $1 AND address = ANY($2)`, userUID, pq.Array(addresses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	suppressed := map[string]bool{}
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		suppressed[a] = true
+	}
+	return suppressed, rows.Err()
+}
+
+// ========== 发送日志 ==========
+
+// InsertMailMessages 记录一封信的所有收件人。build 拿到用户 since 之后已交给中继的收件人数，按剩余配额定好每个收件人的状态；
+// 计数和写入在同一个按用户加 advisory lock 的事务里，同一用户的并发请求不会一起超出每日配额
+func InsertMailMessages(ctx context.Context, userUID string, since time.Time, build func(sent int) []MailMessage) error {
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		ctx, cancel := withQueryTimeout(ctx)
+		defer cancel()
+		// 事务结束时自动释放
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "mail_quota/"+userUID); err != nil {
+			return err
+		}
+		sent, err := countMailSent(ctx, tx, userUID, since)
+		if err != nil {
+			return err
+		}
+		msgs := build(sent)
+		if len(msgs) == 0 {
+			return nil
+		}
+		stmt, err := tx.PrepareContext(ctx,
+			`INSERT INTO mail_messages (user_uid, queue_id, message_id, username, from_addr, recipient, subject, status, reason)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, m := range msgs {
+			if _, err := stmt.ExecContext(ctx, userUID, m.QueueID, m.MessageID, m.Username, m.From, m.Recipient, m.Subject, m.Status, m.Reason); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CountMailSent 用户 since 之后交给中继的收件人数（不含被拒绝的），每日配额按它算
+func CountMailSent(ctx context.Context, userUID string, since time.Time) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return countMailSent(ctx, DB, userUID, since)
+}
+
+func countMailSent(ctx context.Context, q Querier, userUID string, since time.Time) (int, error) {
+	var n int
+	err := q.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM mail_messages WHERE user_uid = $1 AND created_at >= $2 AND status <> 'rejected'`,
+		userUID, since,
+	).Scan(&n)
+	return n, err
+}
+
+// ListMailMessages 分页列出发送日志，recipient 不为空时只看这个收件人；?status= 按状态过滤，name 排序按收件人
+func ListMailMessages(ctx context.Context, userUID, recipient string, opts ListOptions) ([]*MailMessage, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT id, queue_id, message_id, username, from_addr, recipient, subject, status, reason, created_at, updated_at
+		 FROM mail_messages WHERE user_uid = $1`
+	args := []any{userUID}
+	if recipient != "" {
+		args = append(args, recipient)
+		base += fmt.Sprintf(" AND recipient = $%d", len(args))
+	}
+	query, args, err := opts.listQuery(base, args, "recipient")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var msgs []*MailMessage
+	for rows.Next() {
+		var m MailMessage
+		if err := rows.Scan(&m.ID, &m.QueueID, &m.MessageID, &m.Username, &m.From, &m.Recipient, &m.Subject,
+			&m.Status, &m.Reason, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, "", err
+		}
+		msgs = append(msgs, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	msgs, next := trimPage(msgs, &opts, func(m *MailMessage) (string, int) {
+		return opts.cursorValue(m.CreatedAt, m.Recipient), m.ID
+	})
+	return msgs, next, nil
+}
+
+// UpdateMailMessageStatus 记录中继上报的投递结果，返回这封信所属的用户；没有这条记录时返回 ErrNotFound。
+// 已经是退信或投诉的不再被之后的事件改回去
+func UpdateMailMessageStatus(ctx context.Context, queueID, recipient, status, reason string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var userUID string
+	err := DB.QueryRowContext(ctx,
+		`UPDATE mail_messages SET status = $3, reason = $4, updated_at = CURRENT_TIMESTAMP
+		 WHERE queue_id = $1 AND recipient = $2 AND status NOT IN ('bounced', 'complained', 'rejected')
+		 RETURNING user_uid`,
+		queueID, recipient, status, reason,
+	).Scan(&userUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return userUID, err
+}
+
+// PruneMailMessages 删除 before 之前的发送日志
+func PruneMailMessages(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM mail_messages WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,233 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 数据库迁移：migrations/ 下的 NNNN_name.up.sql / NNNN_name.down.sql 编进二进制，
+// 两个网关启动时（或 -migrate-only）按版本号顺序执行未执行过的 up，记录在 schema_migrations。
+// 每个迁移连同它的记录在一个事务里执行，失败整体回滚，不会留下半个迁移；
+// 所以迁移里不能有 CREATE INDEX CONCURRENTLY 这类不能在事务里执行的语句。
+// 多副本同时启动时用 advisory lock 串行，后拿到锁的发现已经是最新版本直接返回。
+// 新的表结构变更只加新文件，不修改已发布的迁移。
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey pg_advisory_lock 的 key，所有副本相同
+const migrationLockKey int64 = 0x636f6e736f6c65 // "console"
+
+// migrationLockTimeout 等待其他副本执行迁移的上限
+const migrationLockTimeout = 10 * time.Minute
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // 为空表示不能回滚
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migrations 编进二进制的全部迁移，按版本号排序
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := migrationFiles.ReadFile("migrations/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Migrate 执行所有未执行的迁移，返回本次执行的版本
+func Migrate() ([]int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var applied []int
+	err = withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if done[m.Version] {
+				continue
+			}
+			start := time.Now()
+			err := inTx(ctx, conn, m.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("migration applied", "version", m.Version, "name", m.Name, "duration", time.Since(start).String())
+			applied = append(applied, m.Version)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown 从最新版本开始依次执行 down，直到只剩 version 及之前的迁移，返回回滚的版本。
+// version 为 0 时全部回滚
+func MigrateDown(version int) ([]int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var reverted []int
+	err = withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if m.Version <= version || !done[m.Version] {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+			err := inTx(ctx, conn, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			if err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("migration reverted", "version", m.Version, "name", m.Name)
+			reverted = append(reverted, m.Version)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// withMigrationLock 在一条独立连接上拿到 advisory lock 后执行 fn。
+// 迁移可能很慢，这条连接上取消 statement_timeout
+func withMigrationLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationLockTimeout)
+	defer cancel()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SET statement_timeout = 0`); err != nil {
+		return err
+	}
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockKey).Scan(&locked); err != nil {
+			return err
+		}
+		if locked {
+			break
+		}
+		slog.Info("waiting for another instance to finish migrations")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for migration lock: %w", ctx.Err())
+		case <-time.After(2 * time.Second):
+		}
+	}
+	// 连接关闭时锁也会释放，这里显式释放，连接放回连接池后不再持有锁
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return err
+	}
+	return fn(ctx, conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	done := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		done[v] = true
+	}
+	return done, rows.Err()
+}
+
+// inTx 在一个事务里执行迁移脚本和记录语句
+func inTx(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// 没有参数时走 simple query，一次执行整个脚本
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationVersion 当前已执行的最新版本，还没有执行过迁移时为 0
+func MigrationVersion(ctx context.Context) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var v sql.NullInt64
+	err := DB.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&v)
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64), nil
+}
@@ -0,0 +1,33 @@
+-- Drops everything 0001_baseline created; the pg_trgm extension is left in place
+
+DROP TABLE IF EXISTS
+    user_devices,
+    auth_events,
+    worker_egress,
+    secret_access_log,
+    user_secret_versions,
+    user_secrets,
+    worker_idle,
+    spend_cap_actions,
+    spend_caps,
+    stripe_events,
+    subscriptions,
+    account_limits,
+    usage_samples,
+    status_pages,
+    uptime_incidents,
+    uptime_results,
+    uptime_checks,
+    alert_events,
+    alert_rules,
+    console_tasks,
+    combinator_resource_reports,
+    combinator_resources,
+    worker_deploy_versions,
+    workers,
+    custom_domains,
+    verification_codes,
+    users
+    CASCADE;
+
+DROP FUNCTION IF EXISTS set_updated_at();
@@ -0,0 +1,461 @@
+-- Control Plane Database Schema
+-- Baseline: the schema databases used to be created with (scripts/init.sql).
+-- Every statement is idempotent, so it also applies cleanly to those databases.
+
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    uid VARCHAR(64) UNIQUE NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    secret_key VARCHAR(256) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Verification codes table
+CREATE TABLE IF NOT EXISTS verification_codes (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    code VARCHAR(6) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used BOOLEAN DEFAULT false
+);
+
+-- Custom domains table
+CREATE TABLE IF NOT EXISTS custom_domains (
+    id SERIAL PRIMARY KEY,
+    cdid VARCHAR(64) UNIQUE NOT NULL,
+    user_uid VARCHAR(64) NOT NULL,
+    domain VARCHAR(255) UNIQUE NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    txt_name VARCHAR(255) NOT NULL,
+    txt_value VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    tags JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Workers table
+CREATE TABLE IF NOT EXISTS workers (
+    id SERIAL PRIMARY KEY,
+    wid VARCHAR(64) UNIQUE NOT NULL,
+    user_uid VARCHAR(64) NOT NULL,
+    worker_name VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'unloaded',
+    active_version_id INTEGER,
+    env_json TEXT NOT NULL DEFAULT '{}',
+    secrets_json TEXT NOT NULL DEFAULT '[]',
+    assigned_cpu VARCHAR(32) NOT NULL DEFAULT '1',
+    assigned_memory VARCHAR(32) NOT NULL DEFAULT '500Mi',
+    assigned_disk VARCHAR(32) NOT NULL DEFAULT '2Gi',
+    max_replicas INTEGER NOT NULL DEFAULT 1,
+    main_region VARCHAR(64) NOT NULL DEFAULT 'global',
+    tags JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workers_user_uid ON workers(user_uid);
+CREATE INDEX IF NOT EXISTS idx_workers_wid ON workers(wid);
+
+-- Worker deploy versions table
+CREATE TABLE IF NOT EXISTS worker_deploy_versions (
+    id SERIAL PRIMARY KEY,
+    worker_id INTEGER NOT NULL REFERENCES workers(id) ON DELETE CASCADE,
+    image VARCHAR(512) NOT NULL,
+    port INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'loading',
+    msg TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_wdv_worker_id ON worker_deploy_versions(worker_id);
+
+-- Combinator resources table
+CREATE TABLE IF NOT EXISTS combinator_resources (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL,
+    resource_type VARCHAR(32) NOT NULL,
+    resource_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'loading',
+    msg TEXT NOT NULL DEFAULT '',
+    tags JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE combinator_resources ADD COLUMN IF NOT EXISTS name VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_combinator_resources_user_uid ON combinator_resources(user_uid);
+
+-- Combinator KV reports table
+CREATE TABLE IF NOT EXISTS combinator_resource_reports (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL,
+    resource_id INTEGER NOT NULL REFERENCES combinator_resources(id) ON DELETE CASCADE,
+    datachange INTEGER NOT NULL,
+    record_start TIMESTAMP NOT NULL,
+    record_end TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_combinator_resource_reports_user_uid ON combinator_resource_reports(user_uid);
+CREATE INDEX IF NOT EXISTS idx_combinator_resource_reports_resource_id ON combinator_resource_reports(resource_id);
+
+-- Console tasks table
+CREATE TABLE IF NOT EXISTS console_tasks (
+    id SERIAL PRIMARY KEY,
+    task_type TEXT NOT NULL,
+    task_status TEXT NOT NULL,
+    task_detailed_status TEXT NOT NULL,
+    task_info TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_console_tasks_status ON console_tasks(task_status);
+CREATE INDEX IF NOT EXISTS idx_console_tasks_type ON console_tasks(task_type);
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+CREATE INDEX IF NOT EXISTS idx_verification_codes_email ON verification_codes(email);
+CREATE INDEX IF NOT EXISTS idx_custom_domains_user_uid ON custom_domains(user_uid);
+CREATE INDEX IF NOT EXISTS idx_custom_domains_domain ON custom_domains(domain);
+
+-- List pagination (keyset on (sort column, id)), status included for filtering
+CREATE INDEX IF NOT EXISTS idx_workers_list_created ON workers(user_uid, created_at, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_workers_list_name ON workers(user_uid, worker_name, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_combinator_resources_list_created ON combinator_resources(user_uid, resource_type, created_at, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_combinator_resources_list_name ON combinator_resources(user_uid, resource_type, name, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_custom_domains_list_created ON custom_domains(user_uid, created_at, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_custom_domains_list_name ON custom_domains(user_uid, domain, id) INCLUDE (status);
+
+-- Resource tags (key/value), filtered with ?tag=key:value
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE combinator_resources ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE custom_domains ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_workers_tags ON workers USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_combinator_resources_tags ON combinator_resources USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_custom_domains_tags ON custom_domains USING GIN (tags);
+
+-- Global search (/api/v1/search): trigram indexes back both ILIKE '%q%' and similarity (%)
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_workers_name_trgm ON workers USING GIN (worker_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_workers_wid_trgm ON workers USING GIN (wid gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_custom_domains_domain_trgm ON custom_domains USING GIN (domain gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_combinator_resources_name_trgm ON combinator_resources USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_combinator_resources_rid_trgm ON combinator_resources USING GIN (resource_id gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_wdv_image_trgm ON worker_deploy_versions USING GIN (image gin_trgm_ops);
+
+-- Resource versions for ETag / Last-Modified, bumped on every row update
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE custom_domains ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE combinator_resources ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    -- clock_timestamp() so that two updates in one transaction still get distinct versions
+    NEW.updated_at = clock_timestamp();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_workers_updated_at ON workers;
+CREATE TRIGGER trg_workers_updated_at BEFORE UPDATE ON workers
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+DROP TRIGGER IF EXISTS trg_custom_domains_updated_at ON custom_domains;
+CREATE TRIGGER trg_custom_domains_updated_at BEFORE UPDATE ON custom_domains
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+DROP TRIGGER IF EXISTS trg_combinator_resources_updated_at ON combinator_resources;
+CREATE TRIGGER trg_combinator_resources_updated_at BEFORE UPDATE ON combinator_resources
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Alert rules per worker, evaluated every minute by inner; status is ok | firing
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL,
+    worker_id VARCHAR(64) NOT NULL REFERENCES workers(wid) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    metric VARCHAR(32) NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    window_seconds INTEGER NOT NULL,
+    notify_email BOOLEAN NOT NULL DEFAULT FALSE,
+    webhook_url TEXT NOT NULL DEFAULT '',
+    webhook_secret TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    status VARCHAR(16) NOT NULL DEFAULT 'ok',
+    last_fired_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_list_created ON alert_rules(user_uid, created_at, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_list_name ON alert_rules(user_uid, name, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_enabled ON alert_rules(enabled) WHERE enabled;
+
+-- Alert history: one row per firing/resolved transition, kept after the rule is deleted
+CREATE TABLE IF NOT EXISTS alert_events (
+    id SERIAL PRIMARY KEY,
+    rule_id INTEGER REFERENCES alert_rules(id) ON DELETE SET NULL,
+    user_uid VARCHAR(64) NOT NULL,
+    worker_id VARCHAR(64) NOT NULL,
+    rule_name VARCHAR(255) NOT NULL,
+    metric VARCHAR(32) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_list_created ON alert_events(user_uid, created_at, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_alert_events_rule_id ON alert_events(rule_id);
+
+DROP TRIGGER IF EXISTS trg_alert_rules_updated_at ON alert_rules;
+CREATE TRIGGER trg_alert_rules_updated_at BEFORE UPDATE ON alert_rules
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Uptime checks: inner probes the public URL of a worker or a verified custom domain.
+-- Exactly one target is set; url is derived from it, never supplied by the user.
+CREATE TABLE IF NOT EXISTS uptime_checks (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    worker_id VARCHAR(64) REFERENCES workers(wid) ON DELETE CASCADE,
+    domain_id VARCHAR(64) REFERENCES custom_domains(cdid) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    interval_seconds INTEGER NOT NULL,
+    timeout_ms INTEGER NOT NULL,
+    expected_status INTEGER NOT NULL DEFAULT 0,
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    status VARCHAR(16) NOT NULL DEFAULT 'unknown',
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    last_checked_at TIMESTAMP,
+    last_latency_ms INTEGER,
+    next_check_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((worker_id IS NULL) <> (domain_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_uptime_checks_list_created ON uptime_checks(user_uid, created_at, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_uptime_checks_list_name ON uptime_checks(user_uid, name, id) INCLUDE (status);
+CREATE INDEX IF NOT EXISTS idx_uptime_checks_due ON uptime_checks(next_check_at) WHERE enabled;
+
+-- One row per probe, pruned after 30 days
+CREATE TABLE IF NOT EXISTS uptime_results (
+    id BIGSERIAL PRIMARY KEY,
+    check_id INTEGER NOT NULL REFERENCES uptime_checks(id) ON DELETE CASCADE,
+    ok BOOLEAN NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    latency_ms INTEGER NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_uptime_results_check ON uptime_results(check_id, checked_at);
+CREATE INDEX IF NOT EXISTS idx_uptime_results_checked_at ON uptime_results(checked_at);
+
+-- Downtime incidents: opened when a check goes down, resolved when it is up again
+CREATE TABLE IF NOT EXISTS uptime_incidents (
+    id SERIAL PRIMARY KEY,
+    check_id INTEGER NOT NULL REFERENCES uptime_checks(id) ON DELETE CASCADE,
+    user_uid VARCHAR(64) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_uptime_incidents_user ON uptime_incidents(user_uid, started_at, id);
+CREATE INDEX IF NOT EXISTS idx_uptime_incidents_open ON uptime_incidents(check_id) WHERE resolved_at IS NULL;
+
+-- Public status page per user (GET /api/v1/status/:slug), lists the public checks
+CREATE TABLE IF NOT EXISTS status_pages (
+    user_uid VARCHAR(64) PRIMARY KEY,
+    slug VARCHAR(40) UNIQUE NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS trg_status_pages_updated_at ON status_pages;
+CREATE TRIGGER trg_status_pages_updated_at BEFORE UPDATE ON status_pages
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- User plan, sizes the ResourceQuota/LimitRange of the tenant namespace (k8s.TenantPlans)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan VARCHAR(32) NOT NULL DEFAULT 'free';
+
+-- Target cluster a worker is placed on (k8s.Clusters); empty until its first deploy
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS cluster VARCHAR(63) NOT NULL DEFAULT '';
+
+-- Node pool (k8s.PoolLabel) and CPU architecture a worker's pods are scheduled on; empty = any
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS pool VARCHAR(63) NOT NULL DEFAULT '';
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS arch VARCHAR(16) NOT NULL DEFAULT '';
+
+-- Per-domain cert-manager issuer override (k8s.AllowedIssuers, "Kind/name"; empty = configured default) and ACME challenge type
+ALTER TABLE custom_domains ADD COLUMN IF NOT EXISTS cert_issuer VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE custom_domains ADD COLUMN IF NOT EXISTS cert_challenge VARCHAR(16) NOT NULL DEFAULT 'http01';
+
+-- Worker securityContext opt-outs (k8s.TenantPlan.AllowRunAsRoot/AllowWritableRootFS); default is the restricted profile
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS run_as_root BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS writable_root_fs BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Metered usage (jobs.usageSampleJob), the basis for invoicing; quantity is integrated over [period_start, period_end)
+CREATE TABLE IF NOT EXISTS usage_samples (
+    id BIGSERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL,
+    resource_type VARCHAR(32) NOT NULL,
+    resource_id VARCHAR(64) NOT NULL,
+    metric VARCHAR(32) NOT NULL,
+    quantity DOUBLE PRECISION NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_samples_user ON usage_samples(user_uid, period_start);
+
+-- Per-account overrides of the plan limits (dblayer.Plans); NULL keeps the plan's value
+CREATE TABLE IF NOT EXISTS account_limits (
+    user_uid VARCHAR(64) PRIMARY KEY REFERENCES users(uid) ON DELETE CASCADE,
+    max_workers INTEGER,
+    max_replicas INTEGER,
+    max_cpu VARCHAR(16),
+    max_memory VARCHAR(16),
+    max_custom_domains INTEGER,
+    max_rdb_bytes BIGINT,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Stripe subscription per user (billing); plan is synced to users.plan, past_due_since drives dunning (jobs.dunningJob)
+CREATE TABLE IF NOT EXISTS subscriptions (
+    user_uid VARCHAR(64) PRIMARY KEY REFERENCES users(uid) ON DELETE CASCADE,
+    stripe_customer_id VARCHAR(255) NOT NULL DEFAULT '',
+    stripe_subscription_id VARCHAR(255) NOT NULL DEFAULT '',
+    plan VARCHAR(32) NOT NULL DEFAULT 'free',
+    status VARCHAR(32) NOT NULL DEFAULT '',
+    current_period_end TIMESTAMP,
+    past_due_since TIMESTAMP,
+    workers_suspended BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_customer ON subscriptions(stripe_customer_id);
+
+-- Stripe webhook events already processed (Stripe retries deliveries)
+CREATE TABLE IF NOT EXISTS stripe_events (
+    id VARCHAR(255) PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Monthly spending cap per user (jobs.enforceSpendCaps); enforced_period/override_period are YYYY-MM
+CREATE TABLE IF NOT EXISTS spend_caps (
+    user_uid VARCHAR(64) PRIMARY KEY REFERENCES users(uid) ON DELETE CASCADE,
+    monthly_cap DOUBLE PRECISION NOT NULL,
+    currency VARCHAR(8) NOT NULL,
+    enforced_period VARCHAR(7) NOT NULL DEFAULT '',
+    override_period VARCHAR(7) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Audit of spending cap changes and enforcement, append only
+CREATE TABLE IF NOT EXISTS spend_cap_actions (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    period VARCHAR(7) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor VARCHAR(16) NOT NULL,
+    accrued DOUBLE PRECISION NOT NULL DEFAULT 0,
+    cap DOUBLE PRECISION NOT NULL DEFAULT 0,
+    worker_ids TEXT[] NOT NULL DEFAULT '{}',
+    message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_spend_cap_actions_user ON spend_cap_actions(user_uid, id);
+
+-- Idle tracking of free-tier workers (jobs.idleReaperJob): warned, then paused, then deleted
+CREATE TABLE IF NOT EXISTS worker_idle (
+    wid VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    last_traffic_at TIMESTAMP NOT NULL,
+    warned_at TIMESTAMP,
+    paused_at TIMESTAMP
+);
+
+-- User application secrets (secrets package); values are sealed with a platform key in user_secret_versions
+CREATE TABLE IF NOT EXISTS user_secrets (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    current_version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_uid, name)
+);
+
+CREATE TABLE IF NOT EXISTS user_secret_versions (
+    secret_id INTEGER NOT NULL REFERENCES user_secrets(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    sealed BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (secret_id, version)
+);
+
+-- Access audit of user secrets, append only, kept after the secret is deleted
+CREATE TABLE IF NOT EXISTS secret_access_log (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    version INTEGER NOT NULL DEFAULT 0,
+    action VARCHAR(16) NOT NULL,
+    actor VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_secret_access_log_user ON secret_access_log(user_uid, name, id);
+
+-- Sensitive columns hold envelopes (enc1:...) once DB_ENCRYPTION_KEYS is set, longer than the plain values
+ALTER TABLE users ALTER COLUMN secret_key TYPE TEXT;
+ALTER TABLE custom_domains ALTER COLUMN txt_value TYPE TEXT;
+
+-- Outbound rules per worker, rendered as a NetworkPolicy; workers without a row allow all egress
+CREATE TABLE IF NOT EXISTS worker_egress (
+    wid VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    mode VARCHAR(8) NOT NULL DEFAULT 'allow',
+    cidrs TEXT[] NOT NULL DEFAULT '{}',
+    domains TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login security events (failures, new devices, step-up verification); user_uid is NULL for unknown emails
+CREATE TABLE IF NOT EXISTS auth_events (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) REFERENCES users(uid) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    country VARCHAR(8) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    risk INTEGER NOT NULL DEFAULT 0,
+    reasons TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_events_list_created ON auth_events(user_uid, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_auth_events_email ON auth_events(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_events_ip ON auth_events(ip, created_at);
+
+-- Devices a user has logged in from, keyed by the sha256 of the device cookie
+CREATE TABLE IF NOT EXISTS user_devices (
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    device VARCHAR(64) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    last_ip VARCHAR(64) NOT NULL DEFAULT '',
+    last_country VARCHAR(8) NOT NULL DEFAULT '',
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_uid, device)
+);
+
+-- What a security event is about: a device, or a revealed secret (secret_key, worker/<wid>/env/<KEY>)
+ALTER TABLE auth_events ADD COLUMN IF NOT EXISTS subject VARCHAR(255) NOT NULL DEFAULT '';
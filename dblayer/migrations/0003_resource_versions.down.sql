@@ -0,0 +1,3 @@
+ALTER TABLE combinator_resources DROP COLUMN IF EXISTS version;
+ALTER TABLE custom_domains DROP COLUMN IF EXISTS version;
+ALTER TABLE workers DROP COLUMN IF EXISTS version;
@@ -0,0 +1,6 @@
+-- Optimistic concurrency: bumped by every user-initiated update (tags, env,
+-- secrets, spec, egress) but not by job status writes, so a client holding an
+-- expected_version only conflicts with other users' changes
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE custom_domains ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE combinator_resources ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+-- Runtime setting overrides (see the settings package); a row replaces the
+-- built-in/env default until it is deleted. Changes are announced on the
+-- console_settings NOTIFY channel so every instance reloads the table
+CREATE TABLE IF NOT EXISTS settings (
+    key VARCHAR(128) PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE workers DROP CONSTRAINT IF EXISTS fk_workers_active_version;
+ALTER TABLE combinator_resource_reports DROP CONSTRAINT IF EXISTS fk_combinator_resource_reports_user;
+ALTER TABLE combinator_resources DROP CONSTRAINT IF EXISTS fk_combinator_resources_user;
+ALTER TABLE custom_domains DROP CONSTRAINT IF EXISTS fk_custom_domains_user;
+ALTER TABLE workers DROP CONSTRAINT IF EXISTS fk_workers_user;
@@ -0,0 +1,18 @@
+-- Ownership foreign keys. Rows that back cluster objects (workers, custom
+-- domains, combinator resources) restrict deleting their user: the delete jobs
+-- have to tear down the K8s objects and schemas first. Pure data cascades.
+-- NOT VALID so orphans left from before don't block the migration; new writes
+-- are checked right away, and the consistency check (/admin/consistency)
+-- reports the orphans and validates the constraints once they are gone.
+ALTER TABLE workers ADD CONSTRAINT fk_workers_user
+    FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE RESTRICT NOT VALID;
+ALTER TABLE custom_domains ADD CONSTRAINT fk_custom_domains_user
+    FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE RESTRICT NOT VALID;
+ALTER TABLE combinator_resources ADD CONSTRAINT fk_combinator_resources_user
+    FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE RESTRICT NOT VALID;
+ALTER TABLE combinator_resource_reports ADD CONSTRAINT fk_combinator_resource_reports_user
+    FOREIGN KEY (user_uid) REFERENCES users(uid) ON DELETE CASCADE NOT VALID;
+
+-- A worker's active version goes away with its versions
+ALTER TABLE workers ADD CONSTRAINT fk_workers_active_version
+    FOREIGN KEY (active_version_id) REFERENCES worker_deploy_versions(id) ON DELETE SET NULL NOT VALID;
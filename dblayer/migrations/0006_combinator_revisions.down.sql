@@ -0,0 +1,2 @@
+ALTER TABLE combinator_resources DROP COLUMN IF EXISTS config_revision;
+DROP TABLE IF EXISTS combinator_configs;
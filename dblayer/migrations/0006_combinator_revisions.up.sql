@@ -0,0 +1,15 @@
+-- Config revisions of dedicated combinators (COMBINATOR_APPS): revision is
+-- bumped whenever the user's combinator config changes, applied_revision is
+-- the highest revision a combinator pod reported as loaded. A resource whose
+-- config_revision is above applied_revision is shown as pending_apply
+CREATE TABLE IF NOT EXISTS combinator_configs (
+    user_uid VARCHAR(64) PRIMARY KEY REFERENCES users(uid) ON DELETE CASCADE,
+    revision BIGINT NOT NULL DEFAULT 0,
+    applied_revision BIGINT NOT NULL DEFAULT 0,
+    applied_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Revision of the config that first served the resource, 0 when it was
+-- never pushed to a dedicated combinator
+ALTER TABLE combinator_resources ADD COLUMN IF NOT EXISTS config_revision BIGINT NOT NULL DEFAULT 0;
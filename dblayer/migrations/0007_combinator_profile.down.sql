@@ -0,0 +1 @@
+ALTER TABLE combinator_configs DROP COLUMN IF EXISTS profile;
@@ -0,0 +1,3 @@
+-- Sizing profile of the user's dedicated combinator (k8s.CombinatorProfiles);
+-- empty means the default of their plan
+ALTER TABLE combinator_configs ADD COLUMN IF NOT EXISTS profile VARCHAR(32) NOT NULL DEFAULT '';
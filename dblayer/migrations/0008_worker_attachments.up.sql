@@ -0,0 +1,13 @@
+-- Managed resources attached to workers: the worker's Secret gets env_name
+-- set to the resource's connection string. Detaching, deleting the worker or
+-- deleting the resource removes the row
+CREATE TABLE IF NOT EXISTS worker_attachments (
+    worker_id VARCHAR(64) NOT NULL REFERENCES workers(wid) ON DELETE CASCADE,
+    resource_id INTEGER NOT NULL REFERENCES combinator_resources(id) ON DELETE CASCADE,
+    env_name VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (worker_id, resource_id),
+    UNIQUE (worker_id, env_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_attachments_resource ON worker_attachments(resource_id);
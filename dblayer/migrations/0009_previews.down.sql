@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS preview_environments;
+DROP TABLE IF EXISTS preview_configs;
@@ -0,0 +1,31 @@
+-- Pull request previews: a worker linked to a GitHub repository gets a
+-- temporary copy per open pull request, deployed from image_template and
+-- removed when the pull request closes. webhook_secret verifies GitHub's
+-- deliveries, github_token (optional) comments the preview URL back; both are
+-- sealed like the other sensitive columns
+CREATE TABLE IF NOT EXISTS preview_configs (
+    worker_id VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    repo VARCHAR(255) NOT NULL,
+    image_template VARCHAR(512) NOT NULL,
+    with_db BOOLEAN NOT NULL DEFAULT FALSE,
+    webhook_secret TEXT NOT NULL,
+    github_token TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One row per open pull request. preview_worker_id and rdb_id are not foreign
+-- keys: teardown deletes the row last, after the worker and the RDB
+CREATE TABLE IF NOT EXISTS preview_environments (
+    id SERIAL PRIMARY KEY,
+    base_worker_id VARCHAR(64) NOT NULL REFERENCES preview_configs(worker_id) ON DELETE CASCADE,
+    user_uid VARCHAR(64) NOT NULL,
+    pr_number INTEGER NOT NULL,
+    preview_worker_id VARCHAR(64) NOT NULL,
+    rdb_id VARCHAR(64) NOT NULL DEFAULT '',
+    head_sha VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (base_worker_id, pr_number)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS github_bindings;
+ALTER TABLE worker_deploy_versions DROP COLUMN IF EXISTS commit_sha;
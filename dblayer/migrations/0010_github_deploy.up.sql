@@ -0,0 +1,23 @@
+-- Commit a deploy version was built from, set by push-to-deploy and previews
+-- (or by API callers); empty for manual deploys
+ALTER TABLE worker_deploy_versions ADD COLUMN IF NOT EXISTS commit_sha VARCHAR(64) NOT NULL DEFAULT '';
+
+-- Push-to-deploy: a push to branch (or, when workflow is set, a successful run
+-- of that workflow on it) deploys image_template rendered with the commit.
+-- Deliveries for repo are checked against each binding's webhook_secret;
+-- github_token (optional) posts the deploy result as a commit status. Both are
+-- sealed like the other sensitive columns
+CREATE TABLE IF NOT EXISTS github_bindings (
+    worker_id VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    repo VARCHAR(255) NOT NULL,
+    branch VARCHAR(255) NOT NULL DEFAULT 'main',
+    image_template VARCHAR(512) NOT NULL,
+    workflow VARCHAR(255) NOT NULL DEFAULT '',
+    webhook_secret TEXT NOT NULL,
+    github_token TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_bindings_repo ON github_bindings(LOWER(repo));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS feature_flag_users;
+DROP TABLE IF EXISTS feature_flags;
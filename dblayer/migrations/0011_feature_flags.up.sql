@@ -0,0 +1,22 @@
+-- Feature flags (see the features package): a flag is on for everyone when
+-- enabled, otherwise for rollout_percent of the users; feature_flag_users
+-- turns it on or off for single users regardless. Changes are announced on
+-- the console_settings NOTIFY channel like the settings
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    rollout_percent INT NOT NULL DEFAULT 0 CHECK (rollout_percent BETWEEN 0 AND 100),
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS feature_flag_users (
+    flag_key VARCHAR(64) NOT NULL REFERENCES feature_flags(key) ON DELETE CASCADE,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (flag_key, user_uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_feature_flag_users_user ON feature_flag_users(user_uid);
@@ -0,0 +1,20 @@
+-- In-app notifications (see the notify package), written by system events:
+-- deploys, domain verification, expiring certificates, quota warnings and
+-- alerts. A dedupe_key makes a recurring check notify once per key
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    kind VARCHAR(64) NOT NULL,
+    severity VARCHAR(16) NOT NULL DEFAULT 'info',
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    resource_type VARCHAR(32) NOT NULL DEFAULT '',
+    resource_id VARCHAR(64) NOT NULL DEFAULT '',
+    dedupe_key VARCHAR(255),
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_uid, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_uid) WHERE read_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_dedupe ON notifications(user_uid, dedupe_key) WHERE dedupe_key IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Outbound mail is rendered from templates built into the notify package;
+-- a row here overrides one template in one locale until it is deleted.
+-- users.locale picks the locale of a user's mail, '' for the default
+CREATE TABLE IF NOT EXISTS email_templates (
+    name VARCHAR(64) NOT NULL,
+    locale VARCHAR(8) NOT NULL,
+    source TEXT NOT NULL,
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (name, locale)
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(8) NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+-- Rollout restarts requested through POST /api/worker/:id/restart: who asked,
+-- when and why, and whether the restart was applied. Append only and kept
+-- after the worker is deleted
+CREATE TABLE IF NOT EXISTS worker_restarts (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    wid VARCHAR(64) NOT NULL,
+    actor VARCHAR(128) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    restarted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_restarts_worker ON worker_restarts(user_uid, wid, id);
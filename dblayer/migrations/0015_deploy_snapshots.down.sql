@@ -0,0 +1,2 @@
+ALTER TABLE worker_deploy_versions DROP COLUMN IF EXISTS snapshot;
+ALTER TABLE worker_deploy_versions DROP COLUMN IF EXISTS initiator;
@@ -0,0 +1,6 @@
+-- Who started each deploy and a snapshot of the worker's configuration at
+-- that moment (env keys with value digests, secret keys, resources), so
+-- GET /api/worker/:id/versions/:a/diff/:b can show what changed. Versions
+-- created before this migration have no snapshot
+ALTER TABLE worker_deploy_versions ADD COLUMN IF NOT EXISTS initiator VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE worker_deploy_versions ADD COLUMN IF NOT EXISTS snapshot JSONB;
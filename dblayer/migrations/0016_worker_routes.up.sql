@@ -0,0 +1,8 @@
+-- Extra routing rules per worker (additional hosts, path prefixes, header
+-- matches, priority), rendered into the worker's route next to its own host.
+-- Workers without a row only serve their own host
+CREATE TABLE IF NOT EXISTS worker_routes (
+    wid VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    rules JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
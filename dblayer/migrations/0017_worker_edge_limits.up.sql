@@ -0,0 +1,9 @@
+-- Request rate limit and body size per worker, enforced by the ingress in
+-- front of it (Traefik Middlewares). Workers without a row are unlimited
+CREATE TABLE IF NOT EXISTS worker_edge_limits (
+    wid VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    rate_limit INTEGER NOT NULL DEFAULT 0,
+    burst INTEGER NOT NULL DEFAULT 0,
+    max_body_bytes BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
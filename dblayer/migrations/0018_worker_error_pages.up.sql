@@ -0,0 +1,10 @@
+-- Maintenance toggle and custom 502/503 pages per worker, served by the
+-- outer gateway at /error-pages for the ingress errors middleware. Workers
+-- without a row use the ingress defaults
+CREATE TABLE IF NOT EXISTS worker_error_pages (
+    wid VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    maintenance BOOLEAN NOT NULL DEFAULT FALSE,
+    page_502 TEXT NOT NULL DEFAULT '',
+    page_503 TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS worker_request_hourly;
+DROP TABLE IF EXISTS worker_requests;
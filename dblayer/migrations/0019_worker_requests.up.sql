@@ -0,0 +1,30 @@
+-- Access logs of the ingress, one row per request routed to a worker, and
+-- hourly aggregates kept longer than the rows. Filled by the log agent
+-- through inner's /api/v1/access-logs and pruned by the access_log.prune job
+CREATE TABLE IF NOT EXISTS worker_requests (
+    id BIGSERIAL PRIMARY KEY,
+    wid VARCHAR(64) NOT NULL REFERENCES workers(wid) ON DELETE CASCADE,
+    method VARCHAR(16) NOT NULL,
+    host VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    status INT NOT NULL,
+    duration_ms INT NOT NULL,
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_worker_requests_wid_created ON worker_requests(wid, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_worker_requests_created ON worker_requests(created_at);
+
+CREATE TABLE IF NOT EXISTS worker_request_hourly (
+    wid VARCHAR(64) NOT NULL REFERENCES workers(wid) ON DELETE CASCADE,
+    hour TIMESTAMP NOT NULL,
+    requests INT NOT NULL DEFAULT 0,
+    status_2xx INT NOT NULL DEFAULT 0,
+    status_3xx INT NOT NULL DEFAULT 0,
+    status_4xx INT NOT NULL DEFAULT 0,
+    status_5xx INT NOT NULL DEFAULT 0,
+    duration_ms_sum BIGINT NOT NULL DEFAULT 0,
+    duration_ms_max INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (wid, hour)
+);
+CREATE INDEX IF NOT EXISTS idx_worker_request_hourly_hour ON worker_request_hourly(hour);
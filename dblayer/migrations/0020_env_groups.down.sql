@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS worker_env_groups;
+DROP TABLE IF EXISTS env_groups;
@@ -0,0 +1,21 @@
+-- Env groups: named sets of env vars a user manages once and attaches to
+-- several workers. At sync time the groups are merged under the worker's own
+-- env in position order, later groups overriding earlier ones
+CREATE TABLE IF NOT EXISTS env_groups (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    vars JSONB NOT NULL DEFAULT '{}',
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_uid, name)
+);
+
+CREATE TABLE IF NOT EXISTS worker_env_groups (
+    wid VARCHAR(64) NOT NULL REFERENCES workers(wid) ON DELETE CASCADE,
+    group_id INTEGER NOT NULL REFERENCES env_groups(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (wid, group_id)
+);
+CREATE INDEX IF NOT EXISTS idx_worker_env_groups_group ON worker_env_groups(group_id);
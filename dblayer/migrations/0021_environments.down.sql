@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS environments;
+DROP INDEX IF EXISTS idx_workers_user_environment;
+ALTER TABLE custom_domains DROP COLUMN IF EXISTS environment;
+ALTER TABLE combinator_resources DROP COLUMN IF EXISTS environment;
+ALTER TABLE workers DROP COLUMN IF EXISTS environment;
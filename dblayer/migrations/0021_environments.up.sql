@@ -0,0 +1,18 @@
+-- Environments: every worker, RDB/KV and custom domain belongs to one
+-- (production unless set otherwise). environments holds the optional
+-- per-environment env vars merged under env groups and the worker's own env
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS environment VARCHAR(32) NOT NULL DEFAULT 'production';
+ALTER TABLE combinator_resources ADD COLUMN IF NOT EXISTS environment VARCHAR(32) NOT NULL DEFAULT 'production';
+ALTER TABLE custom_domains ADD COLUMN IF NOT EXISTS environment VARCHAR(32) NOT NULL DEFAULT 'production';
+CREATE INDEX IF NOT EXISTS idx_workers_user_environment ON workers(user_uid, environment);
+
+CREATE TABLE IF NOT EXISTS environments (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    name VARCHAR(32) NOT NULL,
+    vars JSONB NOT NULL DEFAULT '{}',
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_uid, name)
+);
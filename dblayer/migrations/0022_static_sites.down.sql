@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS static_artifacts;
+ALTER TABLE worker_deploy_versions DROP COLUMN IF EXISTS static_source;
+ALTER TABLE workers DROP COLUMN IF EXISTS kind;
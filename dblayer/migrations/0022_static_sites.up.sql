@@ -0,0 +1,15 @@
+-- Static site workers: kind 'static' serves files with the static site image
+-- instead of running a user image. Each deploy version records where its
+-- files come from (an uploaded tarball or a GitHub repo); uploaded tarballs
+-- are kept per user by sha256 and pruned once no version refers to them
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'container';
+ALTER TABLE worker_deploy_versions ADD COLUMN IF NOT EXISTS static_source JSONB;
+
+CREATE TABLE IF NOT EXISTS static_artifacts (
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    sha256 CHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_uid, sha256)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE account_limits DROP COLUMN IF EXISTS max_bucket_bytes;
+DROP TABLE IF EXISTS object_buckets;
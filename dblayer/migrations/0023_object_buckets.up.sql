@@ -0,0 +1,18 @@
+-- Object storage buckets: resource_type 'bucket' in combinator_resources,
+-- provisioned on an S3-compatible store by the inner gateway. Each bucket
+-- has scoped temporary credentials (rotated before key_expires_at), its
+-- lifecycle rules and the last measured size for usage metering
+CREATE TABLE IF NOT EXISTS object_buckets (
+    resource_id INTEGER PRIMARY KEY REFERENCES combinator_resources(id) ON DELETE CASCADE,
+    bucket_name VARCHAR(63) UNIQUE,
+    lifecycle JSONB NOT NULL DEFAULT '[]',
+    access_key_id TEXT NOT NULL DEFAULT '',
+    secret_access_key TEXT NOT NULL DEFAULT '',
+    session_token TEXT NOT NULL DEFAULT '',
+    key_expires_at TIMESTAMP,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    object_count BIGINT NOT NULL DEFAULT 0,
+    measured_at TIMESTAMP
+);
+
+ALTER TABLE account_limits ADD COLUMN IF NOT EXISTS max_bucket_bytes BIGINT;
@@ -0,0 +1,5 @@
+ALTER TABLE account_limits DROP COLUMN IF EXISTS max_emails_per_day;
+DROP TABLE IF EXISTS mail_messages;
+DROP TABLE IF EXISTS mail_suppressions;
+DROP TABLE IF EXISTS mail_credentials;
+DROP TABLE IF EXISTS mail_domains;
@@ -0,0 +1,72 @@
+-- Outbound email through the platform SMTP relay. Users verify the domains
+-- they send from (ownership TXT, SPF and DKIM records, checked by the
+-- mail.check_domains job) and create SMTP credentials; the relay asks inner's
+-- /api/v1/mail/authorize before accepting each message and reports delivery
+-- results to /api/v1/mail/events. Hard bounces and complaints suppress the
+-- recipient; the send log is pruned by mail.prune
+CREATE TABLE IF NOT EXISTS mail_domains (
+    id SERIAL PRIMARY KEY,
+    mdid VARCHAR(16) NOT NULL UNIQUE,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    domain VARCHAR(255) NOT NULL,
+    txt_name VARCHAR(320) NOT NULL,
+    txt_value TEXT NOT NULL,
+    dkim_selector VARCHAR(63) NOT NULL,
+    dkim_public_key TEXT NOT NULL,
+    dkim_private_key TEXT NOT NULL,
+    ownership_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    spf_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    dkim_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    msg TEXT NOT NULL DEFAULT '',
+    checked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_uid, domain)
+);
+CREATE INDEX IF NOT EXISTS idx_mail_domains_domain ON mail_domains(domain) WHERE status = 'verified';
+
+-- The password is random, only its SHA-256 is kept
+CREATE TABLE IF NOT EXISTS mail_credentials (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    username VARCHAR(64) NOT NULL UNIQUE,
+    password_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    UNIQUE (user_uid, name)
+);
+
+CREATE TABLE IF NOT EXISTS mail_suppressions (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    address VARCHAR(320) NOT NULL,
+    reason VARCHAR(16) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_uid, address)
+);
+
+-- One row per recipient of a message. queue_id is handed to the relay by
+-- authorize and identifies the message in its events; message_id is the
+-- Message-ID header, chosen by the sender
+CREATE TABLE IF NOT EXISTS mail_messages (
+    id BIGSERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    queue_id VARCHAR(32) NOT NULL,
+    message_id VARCHAR(255) NOT NULL DEFAULT '',
+    username VARCHAR(64) NOT NULL,
+    from_addr VARCHAR(320) NOT NULL,
+    recipient VARCHAR(320) NOT NULL,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_mail_messages_user_created ON mail_messages(user_uid, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_mail_messages_queue ON mail_messages(queue_id, recipient);
+CREATE INDEX IF NOT EXISTS idx_mail_messages_created ON mail_messages(created_at);
+
+ALTER TABLE account_limits ADD COLUMN IF NOT EXISTS max_emails_per_day INTEGER;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rdb_task_runs;
+DROP TABLE IF EXISTS rdb_tasks;
@@ -0,0 +1,43 @@
+-- Scheduled SQL tasks: statements a user runs on one of their RDB resources
+-- on a cron schedule. The rdb.run_tasks job claims due tasks (next_run_at,
+-- or run_requested for "run now"), runs them as the user's own database role
+-- with a statement timeout and records each run in rdb_task_runs, pruned by
+-- rdb.prune_task_runs. running keeps a task from overlapping its last run
+CREATE TABLE IF NOT EXISTS rdb_tasks (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    resource_id INTEGER NOT NULL REFERENCES combinator_resources(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    schedule VARCHAR(128) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    sql TEXT NOT NULL,
+    timeout_seconds INTEGER NOT NULL,
+    notify_email BOOLEAN NOT NULL DEFAULT TRUE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_run_at TIMESTAMP,
+    next_run_at TIMESTAMP,
+    run_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    running BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (resource_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_rdb_tasks_user_uid ON rdb_tasks(user_uid);
+CREATE INDEX IF NOT EXISTS idx_rdb_tasks_due ON rdb_tasks(next_run_at) WHERE enabled;
+
+CREATE TABLE IF NOT EXISTS rdb_task_runs (
+    id BIGSERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES rdb_tasks(id) ON DELETE CASCADE,
+    trigger VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    rows_affected BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_rdb_task_runs_task ON rdb_task_runs(task_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_rdb_task_runs_created ON rdb_task_runs(created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS signup_waitlist;
+DROP TABLE IF EXISTS invitations;
@@ -0,0 +1,29 @@
+-- Invite-only and waitlist sign-ups (the signup.mode setting). Admins create
+-- invitation codes with a usage limit and expiry; registering with one counts
+-- a use in the same transaction as the new user. In waitlist mode a sign-up
+-- without a code is stored in signup_waitlist, and approving it sends a
+-- single-use invitation bound to that email
+CREATE TABLE IF NOT EXISTS invitations (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(64) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL DEFAULT '', -- only this address may use it when set
+    note TEXT NOT NULL DEFAULT '',
+    max_uses INT NOT NULL DEFAULT 1 CHECK (max_uses >= 0), -- 0 is unlimited
+    uses INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS signup_waitlist (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    locale VARCHAR(8) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    invitation_id INTEGER REFERENCES invitations(id) ON DELETE SET NULL,
+    decided_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_signup_waitlist_status ON signup_waitlist(status, id);
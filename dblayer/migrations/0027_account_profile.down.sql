@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notification_preferences;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+ALTER TABLE users DROP COLUMN IF EXISTS default_region;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,16 @@
+-- Account profile shown in the dashboard. default_region is used for new
+-- workers that do not name one; timezone for new scheduled tasks
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS default_region VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Per notification kind, whether its email and webhook go out. A kind
+-- without a row delivers on every channel; the notification center always
+-- gets the notification
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    kind VARCHAR(64) NOT NULL,
+    email BOOLEAN NOT NULL DEFAULT TRUE,
+    webhook BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (user_uid, kind)
+);
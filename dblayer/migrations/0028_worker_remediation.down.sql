@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS worker_remediations;
+DROP TABLE IF EXISTS worker_remediation_policies;
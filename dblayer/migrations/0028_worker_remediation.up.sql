@@ -0,0 +1,33 @@
+-- Automatic remediation of crashing workers, evaluated by inner when the pod
+-- watcher sees an OOM kill or a container entering CrashLoopBackOff.
+-- oom_action: off | notify (suggest a resize) | bump (raise memory within
+-- the plan); crash_action: off | notify | pause. A worker without a row is
+-- left alone
+CREATE TABLE IF NOT EXISTS worker_remediation_policies (
+    wid VARCHAR(64) PRIMARY KEY REFERENCES workers(wid) ON DELETE CASCADE,
+    user_uid VARCHAR(64) NOT NULL,
+    oom_action VARCHAR(16) NOT NULL DEFAULT 'off',
+    oom_threshold INTEGER NOT NULL DEFAULT 3,
+    max_memory VARCHAR(32) NOT NULL DEFAULT '',
+    crash_action VARCHAR(16) NOT NULL DEFAULT 'off',
+    crash_threshold INTEGER NOT NULL DEFAULT 5,
+    window_seconds INTEGER NOT NULL DEFAULT 900,
+    last_action_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- What remediation did or suggested. A suggested resize keeps its memory
+-- so the user can apply it with one request; kept after the worker is deleted
+CREATE TABLE IF NOT EXISTS worker_remediations (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL,
+    wid VARCHAR(64) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    from_memory VARCHAR(32) NOT NULL DEFAULT '',
+    to_memory VARCHAR(32) NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    applied_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_remediations_worker ON worker_remediations(wid, id DESC);
@@ -0,0 +1,22 @@
+-- Vertical resizes through PATCH /api/worker/:id/resources: the CPU, memory
+-- and disk before and after, and whether the change reached the cluster. A
+-- resize rolls the running pods without a new deploy version. Append only and
+-- kept after the worker is deleted
+CREATE TABLE IF NOT EXISTS worker_resource_changes (
+    id SERIAL PRIMARY KEY,
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    wid VARCHAR(64) NOT NULL,
+    actor VARCHAR(128) NOT NULL,
+    from_cpu VARCHAR(32) NOT NULL DEFAULT '',
+    to_cpu VARCHAR(32) NOT NULL DEFAULT '',
+    from_memory VARCHAR(32) NOT NULL DEFAULT '',
+    to_memory VARCHAR(32) NOT NULL DEFAULT '',
+    from_disk VARCHAR(32) NOT NULL DEFAULT '',
+    to_disk VARCHAR(32) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    applied_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_resource_changes_worker ON worker_resource_changes(user_uid, wid, id);
@@ -0,0 +1,22 @@
+-- Jobs running on an inner instance. The instance renews lease_until while the
+-- job runs and deletes the row when it finishes; a row whose lease expired
+-- belongs to a job that hung, panicked or lost its instance. The lease reaper
+-- requeues it up to jobs.max_attempts times, then marks it abandoned and
+-- resets the resources it left in progress. One row per run: job IDs are
+-- not unique across concurrent runs, so a requeued run takes over its row by id
+CREATE TABLE IF NOT EXISTS job_leases (
+    id BIGSERIAL PRIMARY KEY,
+    job_type VARCHAR(64) NOT NULL,
+    job_id VARCHAR(255) NOT NULL,
+    user_uid VARCHAR(64) NOT NULL DEFAULT '',
+    payload TEXT NOT NULL,
+    owner VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    heartbeat_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    lease_until TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_leases_expired ON job_leases(lease_until) WHERE status <> 'abandoned';
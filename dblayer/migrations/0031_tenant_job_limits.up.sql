@@ -0,0 +1,12 @@
+-- Caps on the background jobs of one tenant that may run at once, enforced
+-- by the processor of each inner instance; job_type '*' caps all jobs of the
+-- tenant together. Changes are announced on the console_settings NOTIFY
+-- channel like the settings
+CREATE TABLE IF NOT EXISTS tenant_job_limits (
+    user_uid VARCHAR(64) NOT NULL REFERENCES users(uid) ON DELETE CASCADE,
+    job_type VARCHAR(64) NOT NULL,
+    max_running INT NOT NULL CHECK (max_running > 0),
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_uid, job_type)
+);
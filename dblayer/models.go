@@ -23,15 +23,21 @@ type VerificationCode struct {
 
 // CustomDomain model
 type CustomDomain struct {
-	ID        int       `json:"id"`
-	CDID      string    `json:"cdid"`
-	UserUID   string    `json:"user_uid"`
-	Domain    string    `json:"domain"`
-	Target    string    `json:"target"`
-	TXTName   string    `json:"txt_name"`
-	TXTValue  string    `json:"txt_value"`
-	Status    string    `json:"status"` // pending, success, error
-	CreatedAt time.Time `json:"created_at"`
+	ID          int       `json:"id"`
+	CDID        string    `json:"cdid"`
+	UserUID     string    `json:"user_uid"`
+	Domain      string    `json:"domain"`
+	Target      string    `json:"target"`
+	TXTName     string    `json:"txt_name"`
+	TXTValue    string    `json:"txt_value"`
+	Status      string    `json:"status"`    // pending, success, error
+	Issuer      string    `json:"issuer"`    // cert-manager issuer override "Kind/name", empty for the default
+	Challenge   string    `json:"challenge"` // http01, dns01
+	Tags        Tags      `json:"tags"`
+	Environment string    `json:"environment"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"` // bumped on every user change, for expected_version
 }
 
 // Worker model
@@ -49,7 +55,17 @@ type Worker struct {
 	AssignedDisk   string    `json:"assigned_disk"`   // e.g. "2Gi"
 	MaxReplicas     int       `json:"max_replicas"`
 	MainRegion      string    `json:"main_region"`
+	Cluster         string    `json:"cluster"`          // target cluster, empty until first deploy
+	Pool            string    `json:"pool"`             // node pool, empty = any
+	Arch            string    `json:"arch"`             // CPU architecture, empty = any
+	RunAsRoot       bool      `json:"run_as_root"`      // security opt-out, allowed by plan
+	WritableRootFS  bool      `json:"writable_root_fs"` // security opt-out, allowed by plan
+	Tags            Tags      `json:"tags"`
+	Environment     string    `json:"environment"`
+	Kind            string    `json:"kind"` // container, static
 	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Version         int       `json:"version"` // bumped on every user change, for expected_version
 }
 
 // WorkerDeployVersion model
@@ -60,7 +76,12 @@ type WorkerDeployVersion struct {
 	Port      int       `json:"port"`
 	Status    string    `json:"status"` // loading, success, error
 	Msg       string    `json:"msg"`
+	CommitSHA string    `json:"commit_sha,omitempty"` // source commit, empty for manual deploys
+	Initiator string    `json:"initiator,omitempty"`  // DeployInitiator*, empty before 0015
 	CreatedAt time.Time `json:"created_at"`
+
+	Snapshot *DeploySnapshot `json:"-"`                // worker config at deploy time, nil before 0015
+	Static   *StaticSource   `json:"static,omitempty"` // files of a static worker, nil for container workers
 }
 
 // CombinatorResource model
@@ -69,9 +90,14 @@ type CombinatorResource struct {
 	UserUID      string    `json:"user_uid"`
 	ResourceType string    `json:"resource_type"` // rdb, kv
 	ResourceID   string    `json:"resource_id"`
-	Status       string    `json:"status"` // loading, error, active
+	Name         string    `json:"name"`
+	Status       string    `json:"status"` // loading, error, active; pending_apply in responses (MarkPendingApply)
 	Msg          string    `json:"msg"`
+	Tags         Tags      `json:"tags"`
+	Environment  string    `json:"environment"`
 	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Version      int       `json:"version"` // bumped on every user change, for expected_version
 }
 
 // CombinatorResourceUsage model
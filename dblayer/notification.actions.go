@@ -0,0 +1,151 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 通知类型
+const (
+	NotificationDeployFinished  = "deploy.finished"
+	NotificationDeployFailed    = "deploy.failed"
+	NotificationDomainVerified  = "domain.verified"
+	NotificationDomainFailed    = "domain.failed"
+	NotificationCertExpiring    = "certificate.expiring"
+	NotificationQuotaWarning    = "quota.warning"
+	NotificationAlert           = "alert"
+	NotificationTaskFailed      = "task.failed"
+	NotificationTaskRecovered   = "task.recovered"
+	NotificationRemediation     = "worker.remediation"
+	NotificationSeverityInfo    = "info"
+	NotificationSeverityWarning = "warning"
+	NotificationSeverityError   = "error"
+)
+
+// NotificationKinds 所有通知类型，用户可以按类型关掉邮件和 webhook
+var NotificationKinds = []string{
+	NotificationDeployFinished, NotificationDeployFailed, NotificationDomainVerified, NotificationDomainFailed,
+	NotificationCertExpiring, NotificationQuotaWarning, NotificationAlert, NotificationTaskFailed, NotificationTaskRecovered,
+	NotificationRemediation,
+}
+
+// Notification 一条站内通知；DedupeKey 非空时同一用户同一个 key 只保存一次
+type Notification struct {
+	ID           int        `json:"id"`
+	UserUID      string     `json:"user_uid"`
+	Kind         string     `json:"kind"`
+	Severity     string     `json:"severity"`
+	Title        string     `json:"title"`
+	Message      string     `json:"message"`
+	ResourceType string     `json:"resource_type,omitempty"`
+	ResourceID   string     `json:"resource_id,omitempty"`
+	DedupeKey    string     `json:"-"`
+	ReadAt       *time.Time `json:"read_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CreateNotification 保存通知并回填 ID 和 CreatedAt；DedupeKey 已存在时不保存，返回 false
+func CreateNotification(ctx context.Context, n *Notification) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if n.Severity == "" {
+		n.Severity = NotificationSeverityInfo
+	}
+	err := DB.QueryRowContext(ctx,
+		`INSERT INTO notifications (user_uid, kind, severity, title, message, resource_type, resource_id, dedupe_key)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+		 ON CONFLICT (user_uid, dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING
+		 RETURNING id, created_at`,
+		n.UserUID, n.Kind, n.Severity, n.Title, n.Message, n.ResourceType, n.ResourceID, n.DedupeKey,
+	).Scan(&n.ID, &n.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListNotifications 用户的通知，unread 非 nil 时只列未读（true）或已读（false）。
+// 标记已读后马上会重新拉列表，所以读主库而不是只读副本
+func ListNotifications(ctx context.Context, userUID string, unread *bool, opts ListOptions) ([]*Notification, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := `SELECT id, user_uid, kind, severity, title, message, resource_type, resource_id, read_at, created_at
+		 FROM notifications WHERE user_uid = $1`
+	if unread != nil && *unread {
+		base += " AND read_at IS NULL"
+	} else if unread != nil {
+		base += " AND read_at IS NOT NULL"
+	}
+	query, args, err := opts.listQuery(base, []any{userUID}, "title")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []*Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.UserUID, &n.Kind, &n.Severity, &n.Title, &n.Message,
+			&n.ResourceType, &n.ResourceID, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		items = append(items, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	items, next := trimPage(items, &opts, func(n *Notification) (string, int) {
+		return opts.cursorValue(n.CreatedAt, n.Title), n.ID
+	})
+	return items, next, nil
+}
+
+// CountUnreadNotifications 用户的未读通知数
+func CountUnreadNotifications(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notifications WHERE user_uid = $1 AND read_at IS NULL`, userUID,
+	).Scan(&n)
+	return n, err
+}
+
+// MarkNotificationsRead 把用户的通知标为已读，ids 为空时标记全部；返回这次新标记的条数
+func MarkNotificationsRead(ctx context.Context, userUID string, ids []int) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	query := `UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_uid = $1 AND read_at IS NULL`
+	args := []any{userUID}
+	if len(ids) > 0 {
+		query += ` AND id = ANY($2)`
+		args = append(args, pq.Array(ids))
+	}
+	res, err := DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PruneNotifications 删除 before 之前的通知
+func PruneNotifications(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM notifications WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,90 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// OutboxTask 待投递给 inner 的任务
+type OutboxTask struct {
+	ID       int64
+	TaskType string
+	Payload  string
+	Attempts int
+}
+
+// outboxMaxBackoff 投递失败后重试间隔的上限
+const outboxMaxBackoff = 10 * time.Minute
+
+// EnqueueOutbox 写入一条待投递任务，和业务写入放在同一个事务里，提交后才会被投递
+func EnqueueOutbox(ctx context.Context, q Querier, taskType string, payload []byte) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var id int64
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO outbox (task_type, payload) VALUES ($1, $2) RETURNING id`,
+		taskType, string(payload),
+	).Scan(&id)
+	return id, err
+}
+
+// DeliverOutbox 取出最多 limit 条到期的待投递任务逐条 send：成功标记已发送，失败按指数退避重试。
+// 行锁（SKIP LOCKED）保证多个实例不会同时投递同一条
+func DeliverOutbox(ctx context.Context, limit int, send func(OutboxTask) error) (sent, failed int, err error) {
+	err = WithTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			`SELECT id, task_type, payload, attempts FROM outbox
+			 WHERE sent_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP
+			 ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`, limit)
+		if err != nil {
+			return err
+		}
+		var tasks []OutboxTask
+		for rows.Next() {
+			var t OutboxTask
+			if err := rows.Scan(&t.ID, &t.TaskType, &t.Payload, &t.Attempts); err != nil {
+				rows.Close()
+				return err
+			}
+			tasks = append(tasks, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, t := range tasks {
+			if sendErr := send(t); sendErr != nil {
+				backoff := min(time.Duration(1<<min(t.Attempts, 10))*5*time.Second, outboxMaxBackoff)
+				if _, err := tx.ExecContext(ctx,
+					`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`,
+					t.ID, sendErr.Error(), time.Now().Add(backoff),
+				); err != nil {
+					return err
+				}
+				failed++
+				continue
+			}
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE outbox SET attempts = attempts + 1, last_error = '', sent_at = CURRENT_TIMESTAMP WHERE id = $1`, t.ID,
+			); err != nil {
+				return err
+			}
+			sent++
+		}
+		return nil
+	})
+	return sent, failed, err
+}
+
+// PruneOutbox 删除 before 之前已发送的任务
+func PruneOutbox(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at IS NOT NULL AND sent_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
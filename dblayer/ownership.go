@@ -0,0 +1,49 @@
+package dblayer
+
+import (
+	"context"
+	"fmt"
+)
+
+// 资源类型，AuthorizeResource 的 resourceType
+const (
+	ResourceWorker = "worker"
+	ResourceDomain = "domain"
+	ResourceRDB    = "rdb"
+	ResourceKV     = "kv"
+	ResourceBucket = "bucket"
+	ResourceQueue  = "queue"
+)
+
+// ownershipQueries 每种资源的归属查询，$1 是用户，$2 是资源 ID
+var ownershipQueries = map[string]string{
+	ResourceWorker: `SELECT EXISTS (SELECT 1 FROM workers WHERE user_uid = $1 AND wid = $2)`,
+	ResourceDomain: `SELECT EXISTS (SELECT 1 FROM custom_domains WHERE user_uid = $1 AND cdid = $2)`,
+	ResourceRDB:    `SELECT EXISTS (SELECT 1 FROM combinator_resources WHERE user_uid = $1 AND resource_type = 'rdb' AND resource_id = $2)`,
+	ResourceKV:     `SELECT EXISTS (SELECT 1 FROM combinator_resources WHERE user_uid = $1 AND resource_type = 'kv' AND resource_id = $2)`,
+	ResourceBucket: `SELECT EXISTS (SELECT 1 FROM combinator_resources WHERE user_uid = $1 AND resource_type = 'bucket' AND resource_id = $2)`,
+	ResourceQueue:  `SELECT EXISTS (SELECT 1 FROM combinator_resources WHERE user_uid = $1 AND resource_type = 'queue' AND resource_id = $2)`,
+}
+
+// AuthorizeResource 确认资源属于 userUID，handler 和 job 共用的唯一归属检查。
+// 资源不存在和属于别人一样返回 ErrNotFound，不向调用方泄露其他租户的资源是否存在；
+// 总是读主库，刚创建的资源不会因为复制延迟被拒绝
+func AuthorizeResource(ctx context.Context, userUID, resourceType, id string) error {
+	query, ok := ownershipQueries[resourceType]
+	if !ok {
+		return fmt.Errorf("authorize: unknown resource type %q", resourceType)
+	}
+	if userUID == "" || id == "" {
+		return ErrNotFound
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var owned bool
+	if err := DB.QueryRowContext(ctx, query, userUID, id).Scan(&owned); err != nil {
+		return err
+	}
+	if !owned {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// DefaultPlan 没有套餐或套餐名未知时使用
+const DefaultPlan = "free"
+
+// PlanLimits 套餐对一个账户的限制，账户可以单独覆盖任意一项（LimitOverrides）
+type PlanLimits struct {
+	Plan             string `json:"plan"`
+	MaxWorkers       int    `json:"max_workers"`
+	MaxReplicas      int    `json:"max_replicas"` // 单个 worker
+	MaxCPU           string `json:"max_cpu"`      // 单个 worker 每个副本
+	MaxMemory        string `json:"max_memory"`   // 单个 worker 每个副本
+	MaxCustomDomains int    `json:"max_custom_domains"`
+	MaxRDBBytes      int64  `json:"max_rdb_bytes"`      // 用户所有 RDB 合计
+	MaxBucketBytes   int64  `json:"max_bucket_bytes"`   // 用户所有 bucket 合计
+	MaxEmailsPerDay  int    `json:"max_emails_per_day"` // 经 SMTP 中继发出的收件人数，按最近 24 小时计
+}
+
+// Plans users.plan 可选的套餐。租户 namespace 的配额见 k8s.TenantPlans
+var Plans = map[string]PlanLimits{
+	"free": {
+		Plan: "free", MaxWorkers: 3, MaxReplicas: 3, MaxCPU: "2", MaxMemory: "2Gi",
+		MaxCustomDomains: 1, MaxRDBBytes: 512 << 20, MaxBucketBytes: 1 << 30,
+		MaxEmailsPerDay: 100,
+	},
+	"pro": {
+		Plan: "pro", MaxWorkers: 20, MaxReplicas: 10, MaxCPU: "4", MaxMemory: "8Gi",
+		MaxCustomDomains: 10, MaxRDBBytes: 10 << 30, MaxBucketBytes: 50 << 30,
+		MaxEmailsPerDay: 10000,
+	},
+	"team": {
+		Plan: "team", MaxWorkers: 100, MaxReplicas: 50, MaxCPU: "8", MaxMemory: "16Gi",
+		MaxCustomDomains: 50, MaxRDBBytes: 100 << 30, MaxBucketBytes: 500 << 30,
+		MaxEmailsPerDay: 100000,
+	},
+}
+
+// PlanLimitsFor 套餐的默认限制，未知套餐按 DefaultPlan
+func PlanLimitsFor(plan string) PlanLimits {
+	if l, ok := Plans[plan]; ok {
+		return l
+	}
+	return Plans[DefaultPlan]
+}
+
+// LimitOverrides 账户级覆盖，nil 表示沿用套餐的值
+type LimitOverrides struct {
+	MaxWorkers       *int    `json:"max_workers,omitempty" binding:"omitempty,min=0"`
+	MaxReplicas      *int    `json:"max_replicas,omitempty" binding:"omitempty,min=1"`
+	MaxCPU           *string `json:"max_cpu,omitempty" binding:"omitempty,quantity"`
+	MaxMemory        *string `json:"max_memory,omitempty" binding:"omitempty,quantity"`
+	MaxCustomDomains *int    `json:"max_custom_domains,omitempty" binding:"omitempty,min=0"`
+	MaxRDBBytes      *int64  `json:"max_rdb_bytes,omitempty" binding:"omitempty,min=0"`
+	MaxBucketBytes   *int64  `json:"max_bucket_bytes,omitempty" binding:"omitempty,min=0"`
+	MaxEmailsPerDay  *int    `json:"max_emails_per_day,omitempty" binding:"omitempty,min=0"`
+}
+
+// Apply 覆盖后的限制
+func (o LimitOverrides) Apply(l PlanLimits) PlanLimits {
+	if o.MaxWorkers != nil {
+		l.MaxWorkers = *o.MaxWorkers
+	}
+	if o.MaxReplicas != nil {
+		l.MaxReplicas = *o.MaxReplicas
+	}
+	if o.MaxCPU != nil {
+		l.MaxCPU = *o.MaxCPU
+	}
+	if o.MaxMemory != nil {
+		l.MaxMemory = *o.MaxMemory
+	}
+	if o.MaxCustomDomains != nil {
+		l.MaxCustomDomains = *o.MaxCustomDomains
+	}
+	if o.MaxRDBBytes != nil {
+		l.MaxRDBBytes = *o.MaxRDBBytes
+	}
+	if o.MaxBucketBytes != nil {
+		l.MaxBucketBytes = *o.MaxBucketBytes
+	}
+	if o.MaxEmailsPerDay != nil {
+		l.MaxEmailsPerDay = *o.MaxEmailsPerDay
+	}
+	return l
+}
+
+// GetAccountLimits 用户的套餐名和账户级覆盖，用户不存在时返回 ErrNotFound
+func GetAccountLimits(ctx context.Context, uid string) (string, LimitOverrides, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var plan string
+	var o LimitOverrides
+	var maxWorkers, maxReplicas, maxDomains, maxEmails sql.NullInt32
+	var maxCPU, maxMemory sql.NullString
+	var maxRDB, maxBucket sql.NullInt64
+	err := DB.QueryRowContext(ctx,
+		`SELECT u.plan, l.max_workers, l.max_replicas, l.max_cpu, l.max_memory, l.max_custom_domains, l.max_rdb_bytes, l.max_bucket_bytes, l.max_emails_per_day
+		 FROM users u LEFT JOIN account_limits l ON l.user_uid = u.uid
+		 WHERE u.uid = $1`,
+		uid,
+	).Scan(&plan, &maxWorkers, &maxReplicas, &maxCPU, &maxMemory, &maxDomains, &maxRDB, &maxBucket, &maxEmails)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", o, ErrNotFound
+	}
+	if err != nil {
+		return "", o, err
+	}
+	if maxWorkers.Valid {
+		v := int(maxWorkers.Int32)
+		o.MaxWorkers = &v
+	}
+	if maxReplicas.Valid {
+		v := int(maxReplicas.Int32)
+		o.MaxReplicas = &v
+	}
+	if maxCPU.Valid {
+		o.MaxCPU = &maxCPU.String
+	}
+	if maxMemory.Valid {
+		o.MaxMemory = &maxMemory.String
+	}
+	if maxDomains.Valid {
+		v := int(maxDomains.Int32)
+		o.MaxCustomDomains = &v
+	}
+	if maxRDB.Valid {
+		o.MaxRDBBytes = &maxRDB.Int64
+	}
+	if maxBucket.Valid {
+		o.MaxBucketBytes = &maxBucket.Int64
+	}
+	if maxEmails.Valid {
+		v := int(maxEmails.Int32)
+		o.MaxEmailsPerDay = &v
+	}
+	return plan, o, nil
+}
+
+// GetUserLimits 用户生效的限制：套餐默认值加账户级覆盖
+func GetUserLimits(ctx context.Context, uid string) (PlanLimits, error) {
+	plan, o, err := GetAccountLimits(ctx, uid)
+	if err != nil {
+		return PlanLimits{}, err
+	}
+	return o.Apply(PlanLimitsFor(plan)), nil
+}
+
+// SetUserPlan 修改用户套餐，用户不存在时返回 ErrNotFound
+func SetUserPlan(ctx context.Context, uid, plan string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `UPDATE users SET plan = $1 WHERE uid = $2`, plan, uid)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetLimitOverrides 整体替换账户级覆盖，全为 nil 时删除
+func SetLimitOverrides(ctx context.Context, uid string, o LimitOverrides) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if o == (LimitOverrides{}) {
+		_, err := DB.ExecContext(ctx, `DELETE FROM account_limits WHERE user_uid = $1`, uid)
+		return err
+	}
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO account_limits (user_uid, max_workers, max_replicas, max_cpu, max_memory, max_custom_domains, max_rdb_bytes, max_bucket_bytes, max_emails_per_day)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (user_uid) DO UPDATE SET
+		   max_workers = EXCLUDED.max_workers, max_replicas = EXCLUDED.max_replicas,
+		   max_cpu = EXCLUDED.max_cpu, max_memory = EXCLUDED.max_memory,
+		   max_custom_domains = EXCLUDED.max_custom_domains, max_rdb_bytes = EXCLUDED.max_rdb_bytes,
+		   max_bucket_bytes = EXCLUDED.max_bucket_bytes, max_emails_per_day = EXCLUDED.max_emails_per_day,
+		   updated_at = CURRENT_TIMESTAMP`,
+		uid, o.MaxWorkers, o.MaxReplicas, o.MaxCPU, o.MaxMemory, o.MaxCustomDomains, o.MaxRDBBytes, o.MaxBucketBytes, o.MaxEmailsPerDay,
+	)
+	return err
+}
+
+// CountWorkers 用户已有的 worker 数
+func CountWorkers(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM workers WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
+
+// CountCustomDomains 用户已添加的自定义域名数（含未验证的）
+func CountCustomDomains(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM custom_domains WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,166 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/metrics"
+	"jabberwocky238/console/settings"
+)
+
+// 主库连接池：大小由运行时配置决定（env 给默认值，settings 表覆盖），RunPoolMonitor 定期把变更应用到 DB，
+// 并在取连接的平均等待超过 db.pool_wait_alert 时告警（日志 + console_db_pool_wait_alerts_total）。
+// 连接池的原始统计由 metrics.RegisterDB 导出，每个 dblayer 函数的耗时记在 console_db_query_duration_seconds
+
+var (
+	MaxOpenConns = settings.NewInt("db.max_open_conns", 25, 1, 1000,
+		"Maximum open connections to the primary database per instance")
+	MaxIdleConns = settings.NewInt("db.max_idle_conns", 5, 0, 1000,
+		"Idle connections kept to the primary database per instance (capped at db.max_open_conns)")
+	PoolWaitAlert = settings.NewDuration("db.pool_wait_alert", 100*time.Millisecond, time.Millisecond, time.Minute,
+		"Average wait for a pooled connection over a monitor interval above which the pool is reported as saturated")
+)
+
+// PoolMonitorInterval 连接池检查的间隔
+const PoolMonitorInterval = 30 * time.Second
+
+// PoolEnvs SetPoolEnv 读取的环境变量
+var PoolEnvs = []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_POOL_WAIT_ALERT"}
+
+// SetPoolEnv 应用 PoolEnvs 之一，作为对应配置的默认值，须在 InitDB 之前调用
+func SetPoolEnv(env, value string) error {
+	switch env {
+	case "DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || (n == 0 && env == "DB_MAX_OPEN_CONNS") {
+			return fmt.Errorf("%s: %q is not a valid connection count", env, value)
+		}
+		if env == "DB_MAX_OPEN_CONNS" {
+			MaxOpenConns.SetDefault(n)
+		} else {
+			MaxIdleConns.SetDefault(n)
+		}
+	case "DB_POOL_WAIT_ALERT":
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("DB_POOL_WAIT_ALERT: %q is not a positive duration", value)
+		}
+		PoolWaitAlert.SetDefault(d)
+	}
+	return nil
+}
+
+// poolMonitor 上一次检查时的状态
+type poolMonitor struct {
+	maxOpen, maxIdle int
+	last             sql.DBStats
+}
+
+// applySize 把当前配置的连接池大小应用到 DB，没有变化时什么都不做
+func (m *poolMonitor) applySize() {
+	open, idle := MaxOpenConns.Get(), MaxIdleConns.Get()
+	if open == m.maxOpen && idle == m.maxIdle {
+		return
+	}
+	DB.SetMaxOpenConns(open)
+	DB.SetMaxIdleConns(idle)
+	if m.maxOpen != 0 {
+		eventsLog.Info("connection pool resized", "max_open", open, "max_idle", idle)
+	}
+	m.maxOpen, m.maxIdle = open, idle
+}
+
+// checkWait 比较两次检查之间取连接的等待，平均等待超过阈值时告警
+func (m *poolMonitor) checkWait() {
+	stats := DB.Stats()
+	waits := stats.WaitCount - m.last.WaitCount
+	waited := stats.WaitDuration - m.last.WaitDuration
+	m.last = stats
+	if waits <= 0 {
+		return
+	}
+	avg := waited / time.Duration(waits)
+	if avg < PoolWaitAlert.Get() {
+		return
+	}
+	metrics.DBPoolWaitAlerts.Inc()
+	eventsLog.Warn("connection pool saturated",
+		"waits", waits, "avg_wait", avg.String(), "in_use", stats.InUse, "open", stats.OpenConnections, "max_open", stats.MaxOpenConnections)
+}
+
+// RunPoolMonitor 定期应用连接池大小的变更并检查等待，ctx 取消时返回
+func RunPoolMonitor(ctx context.Context) {
+	m := &poolMonitor{maxOpen: MaxOpenConns.Get(), maxIdle: MaxIdleConns.Get(), last: DB.Stats()}
+	ticker := time.NewTicker(PoolMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.applySize()
+			m.checkWait()
+		}
+	}
+}
+
+// observeQuery 返回记录调用耗时的函数，标签是调用栈上最近的导出 dblayer 函数，
+// 内部辅助函数（readRows、updateVersioned 等）的耗时算在调用它的函数上
+func observeQuery() func() {
+	name := queryName()
+	start := time.Now()
+	return func() {
+		metrics.DBQueryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}
+}
+
+// queryName 调用栈上最近的导出 dblayer 函数名，找不到时用 withQueryTimeout 的直接调用方
+func queryName() string {
+	var pcs [8]uintptr
+	// 跳过 runtime.Callers、queryName、observeQuery、withQueryTimeout
+	n := runtime.Callers(4, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	first := ""
+	for {
+		frame, more := frames.Next()
+		name, ok := dblayerFunc(frame.Function)
+		if !ok {
+			break
+		}
+		if first == "" {
+			first = name
+		}
+		if name[0] >= 'A' && name[0] <= 'Z' {
+			return name
+		}
+		if !more {
+			break
+		}
+	}
+	if first == "" {
+		return "unknown"
+	}
+	return first
+}
+
+// dblayerFunc 从 "jabberwocky238/console/dblayer.SetSetting.func1" 取出 "SetSetting"，
+// 不是 dblayer 的函数返回 false
+func dblayerFunc(full string) (string, bool) {
+	rest, ok := strings.CutPrefix(full[strings.LastIndex(full, "/")+1:], "dblayer.")
+	if !ok || rest == "" {
+		return "", false
+	}
+	// 方法形如 (*T).Name
+	if strings.HasPrefix(rest, "(") {
+		if i := strings.Index(rest, ")."); i >= 0 {
+			rest = rest[i+2:]
+		}
+	}
+	name, _, _ := strings.Cut(rest, ".")
+	return name, name != ""
+}
@@ -0,0 +1,199 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrPreviewExists 该 PR 已有预览（并发的 webhook 投递）
+var ErrPreviewExists = errors.New("preview already exists")
+
+// PreviewConfig worker 的 PR 预览配置：Repo（owner/name）的每个打开的 PR 按 ImageTemplate 部署一个临时 worker。
+// WebhookSecret 校验 GitHub 的投递，GitHubToken 非空时把预览地址评论回 PR，两者都加密存储、不返回给前端
+type PreviewConfig struct {
+	WorkerID       string    `json:"worker_id"`
+	UserUID        string    `json:"user_uid"`
+	Repo           string    `json:"repo"`
+	ImageTemplate  string    `json:"image_template"`
+	WithDB         bool      `json:"with_db"`
+	WebhookSecret  string    `json:"-"`
+	GitHubToken    string    `json:"-"`
+	HasGitHubToken bool      `json:"has_github_token"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PreviewEnvironment 一个打开的 PR 的预览：临时 worker，WithDB 时还有一个新建的 RDB
+type PreviewEnvironment struct {
+	ID              int       `json:"id"`
+	BaseWorkerID    string    `json:"base_worker_id"`
+	UserUID         string    `json:"user_uid"`
+	PRNumber        int       `json:"pr_number"`
+	PreviewWorkerID string    `json:"preview_worker_id"`
+	RDBID           string    `json:"rdb_id,omitempty"`
+	HeadSHA         string    `json:"head_sha"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+const previewConfigColumns = `worker_id, user_uid, repo, image_template, with_db, webhook_secret, github_token, created_at, updated_at`
+
+func scanPreviewConfig(row rowScanner) (*PreviewConfig, error) {
+	var c PreviewConfig
+	err := row.Scan(&c.WorkerID, &c.UserUID, &c.Repo, &c.ImageTemplate, &c.WithDB, &c.WebhookSecret, &c.GitHubToken, &c.CreatedAt, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.WebhookSecret, err = openColumn(colPreviewWebhookSecret, c.WebhookSecret); err != nil {
+		return nil, err
+	}
+	if c.GitHubToken, err = openColumn(colPreviewGitHubToken, c.GitHubToken); err != nil {
+		return nil, err
+	}
+	c.HasGitHubToken = c.GitHubToken != ""
+	return &c, nil
+}
+
+// UpsertPreviewConfig 创建或更新 worker 的预览配置，worker 不存在或不属于该用户时返回 ErrNotFound。
+// 已有配置时保留原 WebhookSecret，GitHubToken 为空表示不修改；回填解密后的 WebhookSecret，created 表示是新建的
+func UpsertPreviewConfig(ctx context.Context, c *PreviewConfig) (created bool, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	webhookSecret, err := sealColumn(colPreviewWebhookSecret, c.WebhookSecret)
+	if err != nil {
+		return false, err
+	}
+	token, err := sealColumn(colPreviewGitHubToken, c.GitHubToken)
+	if err != nil {
+		return false, err
+	}
+	err = DB.QueryRowContext(ctx,
+		`INSERT INTO preview_configs (worker_id, user_uid, repo, image_template, with_db, webhook_secret, github_token)
+		 SELECT wid, user_uid, $3, $4, $5, $6, $7 FROM workers WHERE wid = $1 AND user_uid = $2
+		 ON CONFLICT (worker_id) DO UPDATE SET
+		   repo = EXCLUDED.repo, image_template = EXCLUDED.image_template, with_db = EXCLUDED.with_db,
+		   github_token = CASE WHEN EXCLUDED.github_token = '' THEN preview_configs.github_token ELSE EXCLUDED.github_token END,
+		   updated_at = CURRENT_TIMESTAMP
+		 RETURNING webhook_secret, github_token <> '', created_at, updated_at, xmax = 0`,
+		c.WorkerID, c.UserUID, c.Repo, c.ImageTemplate, c.WithDB, webhookSecret, token,
+	).Scan(&webhookSecret, &c.HasGitHubToken, &c.CreatedAt, &c.UpdatedAt, &created)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	c.WebhookSecret, err = openColumn(colPreviewWebhookSecret, webhookSecret)
+	return created, err
+}
+
+// GetPreviewConfig 获取 worker 的预览配置（webhook 和后台任务用，不验证归属）
+func GetPreviewConfig(ctx context.Context, workerID string) (*PreviewConfig, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanPreviewConfig(DB.QueryRowContext(ctx,
+		`SELECT `+previewConfigColumns+` FROM preview_configs WHERE worker_id = $1`, workerID,
+	))
+}
+
+// GetPreviewConfigByOwner 获取 worker 的预览配置，验证归属
+func GetPreviewConfigByOwner(ctx context.Context, workerID, userUID string) (*PreviewConfig, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanPreviewConfig(DB.QueryRowContext(ctx,
+		`SELECT `+previewConfigColumns+` FROM preview_configs WHERE worker_id = $1 AND user_uid = $2`, workerID, userUID,
+	))
+}
+
+// DeletePreviewConfig 删除预览配置，预览记录随之删除（调用方应先清理预览 worker 和 RDB）
+func DeletePreviewConfig(ctx context.Context, workerID, userUID string) error {
+	return execOwned(ctx, `DELETE FROM preview_configs WHERE worker_id = $1 AND user_uid = $2`, workerID, userUID)
+}
+
+const previewEnvironmentColumns = `id, base_worker_id, user_uid, pr_number, preview_worker_id, rdb_id, head_sha, created_at, updated_at`
+
+func scanPreviewEnvironment(row rowScanner) (*PreviewEnvironment, error) {
+	var e PreviewEnvironment
+	err := row.Scan(&e.ID, &e.BaseWorkerID, &e.UserUID, &e.PRNumber, &e.PreviewWorkerID, &e.RDBID, &e.HeadSHA, &e.CreatedAt, &e.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CreatePreviewEnvironment 记录一个 PR 的预览，回填 ID 与时间；同一个 PR 已有记录时返回 ErrPreviewExists
+func CreatePreviewEnvironment(ctx context.Context, e *PreviewEnvironment) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err := DB.QueryRowContext(ctx,
+		`INSERT INTO preview_environments (base_worker_id, user_uid, pr_number, preview_worker_id, rdb_id, head_sha)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (base_worker_id, pr_number) DO NOTHING
+		 RETURNING id, created_at, updated_at`,
+		e.BaseWorkerID, e.UserUID, e.PRNumber, e.PreviewWorkerID, e.RDBID, e.HeadSHA,
+	).Scan(&e.ID, &e.CreatedAt, &e.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrPreviewExists
+	}
+	return err
+}
+
+// GetPreviewEnvironment 获取 PR 的预览，没有时返回 ErrNotFound
+func GetPreviewEnvironment(ctx context.Context, baseWorkerID string, prNumber int) (*PreviewEnvironment, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanPreviewEnvironment(DB.QueryRowContext(ctx,
+		`SELECT `+previewEnvironmentColumns+` FROM preview_environments WHERE base_worker_id = $1 AND pr_number = $2`,
+		baseWorkerID, prNumber,
+	))
+}
+
+// ListPreviewEnvironments 获取 worker 的全部预览，按 PR 编号排序
+func ListPreviewEnvironments(ctx context.Context, baseWorkerID, userUID string) ([]*PreviewEnvironment, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+previewEnvironmentColumns+` FROM preview_environments
+		 WHERE base_worker_id = $1 AND user_uid = $2 ORDER BY pr_number`,
+		baseWorkerID, userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	envs := []*PreviewEnvironment{}
+	for rows.Next() {
+		e, err := scanPreviewEnvironment(rows)
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, e)
+	}
+	return envs, rows.Err()
+}
+
+// SetPreviewHead 记录预览最近一次部署的提交
+func SetPreviewHead(ctx context.Context, id int, headSHA string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE preview_environments SET head_sha = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, headSHA, id,
+	)
+	return err
+}
+
+// DeletePreviewEnvironment 删除预览记录
+func DeletePreviewEnvironment(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx, `DELETE FROM preview_environments WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,395 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 定时 SQL 任务：用户给自己的 RDB 资源配置 cron 计划和一段 SQL，由 inner 的 rdb.run_tasks 按计划
+// 认领执行，每次执行记一条 rdb_task_runs
+
+// 任务状态，即最近一次执行的结果；还没执行过为 pending
+const (
+	RDBTaskPending   = "pending"
+	RDBTaskSucceeded = "succeeded"
+	RDBTaskFailed    = "failed"
+	RDBTaskTimeout   = "timeout"
+)
+
+// 单次执行的状态：running，结束后是 succeeded / failed / timeout 之一
+const RDBTaskRunning = "running"
+
+// 执行的触发方式
+const (
+	RDBTaskTriggerSchedule = "schedule"
+	RDBTaskTriggerManual   = "manual"
+)
+
+// ErrRDBTaskExists 同一个 RDB 下已有同名任务
+var ErrRDBTaskExists = errors.New("task name already used on this database")
+
+// RDBTask 一个定时 SQL 任务。NextRunAt 为空表示停用或计划不再触发
+type RDBTask struct {
+	ID                  int        `json:"id"`
+	UserUID             string     `json:"user_uid"`
+	ResourceID          int        `json:"-"` // combinator_resources.id
+	RDBID               string     `json:"rdb_id"`
+	Name                string     `json:"name"`
+	Schedule            string     `json:"schedule"`
+	Timezone            string     `json:"timezone"`
+	SQL                 string     `json:"sql"`
+	TimeoutSeconds      int        `json:"timeout_seconds"`
+	NotifyEmail         bool       `json:"notify_email"`
+	Enabled             bool       `json:"enabled"`
+	Status              string     `json:"status"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastRunAt           *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt           *time.Time `json:"next_run_at,omitempty"`
+	RunRequested        bool       `json:"run_requested"`
+	Running             bool       `json:"running"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// RDBTaskRun 任务的一次执行；RowsAffected 是最后一条语句影响的行数
+type RDBTaskRun struct {
+	ID           int        `json:"id"`
+	TaskID       int        `json:"task_id"`
+	Trigger      string     `json:"trigger"`
+	Status       string     `json:"status"`
+	RowsAffected int64      `json:"rows_affected"`
+	Error        string     `json:"error,omitempty"`
+	DurationMs   int        `json:"duration_ms"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// RDBTaskClaim 认领到的一次执行，Run 已经以 running 状态写入
+type RDBTaskClaim struct {
+	Task *RDBTask
+	Run  *RDBTaskRun
+}
+
+// rdbTaskQuery 任务带上所属 RDB 的资源 ID，包成子查询以便复用 listQuery
+const rdbTaskQuery = `SELECT id, user_uid, resource_id, rdb_id, name, schedule, timezone, sql, timeout_seconds, notify_email,
+	enabled, status, consecutive_failures, last_error, last_run_at, next_run_at, run_requested, running, created_at, updated_at
+	FROM (
+		SELECT t.*, cr.resource_id AS rdb_id
+		FROM rdb_tasks t JOIN combinator_resources cr ON cr.id = t.resource_id
+	) t`
+
+func scanRDBTask(row rowScanner) (*RDBTask, error) {
+	var t RDBTask
+	err := row.Scan(&t.ID, &t.UserUID, &t.ResourceID, &t.RDBID, &t.Name, &t.Schedule, &t.Timezone, &t.SQL, &t.TimeoutSeconds, &t.NotifyEmail,
+		&t.Enabled, &t.Status, &t.ConsecutiveFailures, &t.LastError, &t.LastRunAt, &t.NextRunAt, &t.RunRequested, &t.Running, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func scanRDBTasks(rows *sql.Rows) ([]*RDBTask, error) {
+	defer rows.Close()
+	var tasks []*RDBTask
+	for rows.Next() {
+		t, err := scanRDBTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// CountRDBTasks 用户已有的定时任务数
+func CountRDBTasks(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM rdb_tasks WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
+
+// CreateRDBTask 创建定时任务，回填 ID、状态与时间；同一个 RDB 下重名时返回 ErrRDBTaskExists
+func CreateRDBTask(ctx context.Context, t *RDBTask) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err := DB.QueryRowContext(ctx,
+		`INSERT INTO rdb_tasks (user_uid, resource_id, name, schedule, timezone, sql, timeout_seconds, notify_email, enabled, next_run_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING id, status, created_at, updated_at`,
+		t.UserUID, t.ResourceID, t.Name, t.Schedule, t.Timezone, t.SQL, t.TimeoutSeconds, t.NotifyEmail, t.Enabled, t.NextRunAt,
+	).Scan(&t.ID, &t.Status, &t.CreatedAt, &t.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrRDBTaskExists
+	}
+	return err
+}
+
+// GetRDBTask 读取用户某个 RDB 下的一个任务，不存在时返回 ErrNotFound
+func GetRDBTask(ctx context.Context, userUID, rdbID string, id int) (*RDBTask, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	t, err := scanRDBTask(DB.QueryRowContext(ctx,
+		rdbTaskQuery+` WHERE id = $1 AND user_uid = $2 AND rdb_id = $3`, id, userUID, rdbID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// ListRDBTasks 分页获取用户某个 RDB 下的任务，Status 过滤最近一次的结果
+func ListRDBTasks(ctx context.Context, userUID, rdbID string, opts ListOptions) ([]*RDBTask, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	query, args, err := opts.listQuery(rdbTaskQuery+` WHERE user_uid = $1 AND rdb_id = $2`, []any{userUID, rdbID}, "name")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	tasks, err := scanRDBTasks(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	tasks, next := trimPage(tasks, &opts, func(t *RDBTask) (string, int) {
+		return opts.cursorValue(t.CreatedAt, t.Name), t.ID
+	})
+	return tasks, next, nil
+}
+
+// UpdateRDBTask 整体替换任务配置（所属 RDB 不可改），验证归属。
+// 停用后再启用时连续失败数清零
+func UpdateRDBTask(ctx context.Context, t *RDBTask) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx,
+		`UPDATE rdb_tasks SET name = $1, schedule = $2, timezone = $3, sql = $4, timeout_seconds = $5, notify_email = $6,
+		        consecutive_failures = CASE WHEN $7 AND NOT enabled THEN 0 ELSE consecutive_failures END,
+		        enabled = $7, next_run_at = $8, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $9 AND user_uid = $10`,
+		t.Name, t.Schedule, t.Timezone, t.SQL, t.TimeoutSeconds, t.NotifyEmail, t.Enabled, t.NextRunAt, t.ID, t.UserUID,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrRDBTaskExists
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteRDBTask 删除任务及其执行记录，验证归属；正在执行的语句会跑完
+func DeleteRDBTask(ctx context.Context, userUID string, id int) error {
+	return execOwned(ctx, `DELETE FROM rdb_tasks WHERE id = $1 AND user_uid = $2`, id, userUID)
+}
+
+// RequestRDBTaskRun 让任务在下一轮立即执行一次（停用的任务也会执行），验证归属
+func RequestRDBTaskRun(ctx context.Context, userUID string, id int) error {
+	return execOwned(ctx, `UPDATE rdb_tasks SET run_requested = TRUE WHERE id = $1 AND user_uid = $2`, id, userUID)
+}
+
+// ClaimDueRDBTasks 认领到期（或请求了立即执行）且没在执行的任务，标记为执行中并写入 running 的执行记录。
+// 按计划到期的任务用 next 算出下次时间，nil 表示不再触发。多个 inner 实例同时认领时每个任务只会被一个实例拿到
+func ClaimDueRDBTasks(ctx context.Context, now time.Time, limit int, next func(*RDBTask) *time.Time) ([]*RDBTaskClaim, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var claims []*RDBTaskClaim
+	err := WithTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			`SELECT id FROM rdb_tasks
+			 WHERE NOT running AND (run_requested OR (enabled AND next_run_at <= $1))
+			 ORDER BY next_run_at NULLS FIRST
+			 LIMIT $2
+			 FOR UPDATE SKIP LOCKED`, now, limit,
+		)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(ids) == 0 {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, rdbTaskQuery+` WHERE id = ANY($1)`, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		tasks, err := scanRDBTasks(rows)
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			run := &RDBTaskRun{TaskID: t.ID, Trigger: RDBTaskTriggerManual, Status: RDBTaskRunning}
+			nextRun := t.NextRunAt
+			if t.Enabled && t.NextRunAt != nil && !t.NextRunAt.After(now) {
+				run.Trigger = RDBTaskTriggerSchedule
+				nextRun = next(t)
+			}
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE rdb_tasks SET running = TRUE, run_requested = FALSE, last_run_at = $1, next_run_at = $2 WHERE id = $3`,
+				now, nextRun, t.ID,
+			); err != nil {
+				return err
+			}
+			if err := tx.QueryRowContext(ctx,
+				`INSERT INTO rdb_task_runs (task_id, trigger, created_at) VALUES ($1, $2, $3) RETURNING id, created_at`,
+				t.ID, run.Trigger, now,
+			).Scan(&run.ID, &run.CreatedAt); err != nil {
+				return err
+			}
+			t.Running, t.RunRequested, t.LastRunAt, t.NextRunAt = true, false, &now, nextRun
+			claims = append(claims, &RDBTaskClaim{Task: t, Run: run})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// FinishRDBTaskRun 记录一次执行的结果并更新任务状态，返回执行前的任务状态，由调用方决定是否通知
+func FinishRDBTaskRun(ctx context.Context, claim *RDBTaskClaim) (from string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	run := claim.Run
+	err = WithTx(ctx, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx,
+			`UPDATE rdb_task_runs SET status = $1, rows_affected = $2, error = $3, duration_ms = $4, finished_at = $5
+			 WHERE id = $6 RETURNING finished_at`,
+			run.Status, run.RowsAffected, run.Error, run.DurationMs, time.Now().UTC(), run.ID,
+		).Scan(&run.FinishedAt); err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, `SELECT status FROM rdb_tasks WHERE id = $1 FOR UPDATE`, run.TaskID).Scan(&from); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx,
+			`UPDATE rdb_tasks SET running = FALSE, status = $1, last_error = $2,
+			        consecutive_failures = CASE WHEN $3 THEN 0 ELSE consecutive_failures + 1 END
+			 WHERE id = $4
+			 RETURNING consecutive_failures`,
+			run.Status, run.Error, run.Status == RDBTaskSucceeded, run.TaskID,
+		).Scan(&claim.Task.ConsecutiveFailures)
+	})
+	if err == sql.ErrNoRows {
+		// 执行期间任务被删除了
+		return "", ErrNotFound
+	}
+	claim.Task.Status, claim.Task.LastError, claim.Task.Running = run.Status, run.Error, false
+	return from, err
+}
+
+// FailStaleRDBTaskRuns 结束超时后 grace 还在 running 的执行（执行它的 inner 实例退出了），
+// 让任务可以再被认领；返回结束的条数
+func FailStaleRDBTaskRuns(ctx context.Context, now time.Time, grace time.Duration) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := WithTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			`UPDATE rdb_task_runs r SET status = 'failed', error = 'interrupted: the gateway running it stopped', finished_at = $1
+			 FROM rdb_tasks t
+			 WHERE r.task_id = t.id AND r.status = 'running'
+			   AND r.created_at < $1 - (t.timeout_seconds + $2) * INTERVAL '1 second'
+			 RETURNING r.task_id`,
+			now, int(grace.Seconds()),
+		)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(ids) == 0 {
+			return err
+		}
+		n = len(ids)
+		_, err = tx.ExecContext(ctx,
+			`UPDATE rdb_tasks SET running = FALSE, status = 'failed', last_error = 'interrupted: the gateway running it stopped',
+			        consecutive_failures = consecutive_failures + 1
+			 WHERE id = ANY($1)`, pq.Array(ids),
+		)
+		return err
+	})
+	return n, err
+}
+
+// ListRDBTaskRuns 分页获取任务的执行记录，Status 过滤执行结果
+func ListRDBTaskRuns(ctx context.Context, taskID int, opts ListOptions) ([]*RDBTaskRun, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	query, args, err := opts.listQuery(
+		`SELECT id, task_id, trigger, status, rows_affected, error, duration_ms, created_at, finished_at
+		 FROM rdb_task_runs WHERE task_id = $1`, []any{taskID}, "status",
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var runs []*RDBTaskRun
+	for rows.Next() {
+		var r RDBTaskRun
+		if err := rows.Scan(&r.ID, &r.TaskID, &r.Trigger, &r.Status, &r.RowsAffected, &r.Error, &r.DurationMs, &r.CreatedAt, &r.FinishedAt); err != nil {
+			return nil, "", err
+		}
+		runs = append(runs, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	runs, next := trimPage(runs, &opts, func(r *RDBTaskRun) (string, int) {
+		return opts.cursorValue(r.CreatedAt, r.Status), r.ID
+	})
+	return runs, next, nil
+}
+
+// PruneRDBTaskRuns 删除 before 之前结束的执行记录，每个任务最近一次执行总是保留
+func PruneRDBTaskRuns(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx,
+		`DELETE FROM rdb_task_runs r WHERE r.status <> 'running' AND r.created_at < $1
+		   AND r.id <> (SELECT MAX(id) FROM rdb_task_runs WHERE task_id = r.task_id)`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,228 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// 自动处理的动作：OOM 时 notify 只建议扩容，bump 在套餐上限内直接加内存；
+// CrashLoopBackOff 时 notify 只通知，pause 暂停 worker
+const (
+	RemediationOff    = "off"
+	RemediationNotify = "notify"
+	RemediationBump   = "bump"
+	RemediationPause  = "pause"
+)
+
+// 处理记录的类型
+const (
+	RemediationMemoryBumped    = "memory_bumped"
+	RemediationResizeSuggested = "resize_suggested"
+	RemediationPaused          = "paused"
+	RemediationCrashLooping    = "crash_looping"
+)
+
+// WorkerRemediationPolicy 一个 worker 的自动处理策略：WindowSeconds 内 OOM 次数达到 OOMThreshold、
+// 或者有容器处于 CrashLoopBackOff 且重启次数达到 CrashThreshold 时执行对应动作。
+// 两次动作之间至少隔一个窗口
+type WorkerRemediationPolicy struct {
+	WorkerID       string     `json:"worker_id"`
+	UserUID        string     `json:"-"`
+	OOMAction      string     `json:"oom_action"`
+	OOMThreshold   int        `json:"oom_threshold"`
+	MaxMemory      string     `json:"max_memory"` // 自动加内存的上限，空表示套餐上限
+	CrashAction    string     `json:"crash_action"`
+	CrashThreshold int        `json:"crash_threshold"`
+	WindowSeconds  int        `json:"window_seconds"`
+	LastActionAt   *time.Time `json:"last_action_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Window 策略的统计窗口
+func (p *WorkerRemediationPolicy) Window() time.Duration {
+	return time.Duration(p.WindowSeconds) * time.Second
+}
+
+// DefaultRemediationPolicy 没有配置过的 worker 的策略：两个动作都关闭
+func DefaultRemediationPolicy(wid, userUID string) *WorkerRemediationPolicy {
+	return &WorkerRemediationPolicy{
+		WorkerID: wid, UserUID: userUID,
+		OOMAction: RemediationOff, OOMThreshold: 3,
+		CrashAction: RemediationOff, CrashThreshold: 5,
+		WindowSeconds: 900,
+	}
+}
+
+// WorkerRemediation 一次自动处理或扩容建议；建议的扩容被采纳后 AppliedAt 非空
+type WorkerRemediation struct {
+	ID         int        `json:"id"`
+	UserUID    string     `json:"-"`
+	WorkerID   string     `json:"worker_id"`
+	Action     string     `json:"action"`
+	FromMemory string     `json:"from_memory,omitempty"`
+	ToMemory   string     `json:"to_memory,omitempty"`
+	Message    string     `json:"message"`
+	AppliedAt  *time.Time `json:"applied_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+const remediationPolicyColumns = `wid, user_uid, oom_action, oom_threshold, max_memory, crash_action, crash_threshold,
+	window_seconds, last_action_at, updated_at`
+
+func scanRemediationPolicy(row rowScanner) (*WorkerRemediationPolicy, error) {
+	var p WorkerRemediationPolicy
+	err := row.Scan(&p.WorkerID, &p.UserUID, &p.OOMAction, &p.OOMThreshold, &p.MaxMemory, &p.CrashAction, &p.CrashThreshold,
+		&p.WindowSeconds, &p.LastActionAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetRemediationPolicy 读取 worker 的策略，没有配置过时返回 ErrNotFound
+func GetRemediationPolicy(ctx context.Context, wid, userUID string) (*WorkerRemediationPolicy, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	p, err := scanRemediationPolicy(DB.QueryRowContext(ctx,
+		`SELECT `+remediationPolicyColumns+` FROM worker_remediation_policies WHERE wid = $1 AND user_uid = $2`,
+		wid, userUID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, err
+}
+
+// SetRemediationPolicy 新建或整体替换 worker 的策略，回填 UpdatedAt；LastActionAt 保留
+func SetRemediationPolicy(ctx context.Context, p *WorkerRemediationPolicy) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return DB.QueryRowContext(ctx,
+		`INSERT INTO worker_remediation_policies (wid, user_uid, oom_action, oom_threshold, max_memory, crash_action, crash_threshold, window_seconds)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (wid) DO UPDATE SET oom_action = EXCLUDED.oom_action, oom_threshold = EXCLUDED.oom_threshold,
+		     max_memory = EXCLUDED.max_memory, crash_action = EXCLUDED.crash_action, crash_threshold = EXCLUDED.crash_threshold,
+		     window_seconds = EXCLUDED.window_seconds, updated_at = CURRENT_TIMESTAMP
+		 RETURNING last_action_at, updated_at`,
+		p.WorkerID, p.UserUID, p.OOMAction, p.OOMThreshold, p.MaxMemory, p.CrashAction, p.CrashThreshold, p.WindowSeconds,
+	).Scan(&p.LastActionAt, &p.UpdatedAt)
+}
+
+// DeleteRemediationPolicy 删除策略，worker 回到不自动处理
+func DeleteRemediationPolicy(ctx context.Context, wid, userUID string) error {
+	return execOwned(ctx, `DELETE FROM worker_remediation_policies WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+}
+
+// ClaimRemediation 记下 now 为策略最近一次动作的时间；距上次动作不到一个窗口时返回 false。
+// 多个 inner 实例同时看到同一次 OOM 时只有一个执行
+func ClaimRemediation(ctx context.Context, wid string, now time.Time) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx,
+		`UPDATE worker_remediation_policies SET last_action_at = $2
+		 WHERE wid = $1 AND (last_action_at IS NULL OR last_action_at <= $2 - window_seconds * INTERVAL '1 second')`,
+		wid, now,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+const remediationColumns = `id, user_uid, wid, action, from_memory, to_memory, message, applied_at, created_at`
+
+func scanRemediation(row rowScanner) (*WorkerRemediation, error) {
+	var r WorkerRemediation
+	err := row.Scan(&r.ID, &r.UserUID, &r.WorkerID, &r.Action, &r.FromMemory, &r.ToMemory, &r.Message, &r.AppliedAt, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateRemediation 记录一次处理，回填 ID 和 CreatedAt
+func CreateRemediation(ctx context.Context, r *WorkerRemediation) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return DB.QueryRowContext(ctx,
+		`INSERT INTO worker_remediations (user_uid, wid, action, from_memory, to_memory, message)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		r.UserUID, r.WorkerID, r.Action, r.FromMemory, r.ToMemory, r.Message,
+	).Scan(&r.ID, &r.CreatedAt)
+}
+
+// ListRemediations worker 最近的处理记录，新的在前
+func ListRemediations(ctx context.Context, wid, userUID string, limit int) ([]*WorkerRemediation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+remediationColumns+` FROM worker_remediations WHERE wid = $1 AND user_uid = $2 ORDER BY id DESC LIMIT $3`,
+		wid, userUID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*WorkerRemediation{}
+	for rows.Next() {
+		r, err := scanRemediation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// GetRemediation 按 ID 读取 worker 的一条处理记录
+func GetRemediation(ctx context.Context, id int, wid, userUID string) (*WorkerRemediation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	r, err := scanRemediation(DB.QueryRowContext(ctx,
+		`SELECT `+remediationColumns+` FROM worker_remediations WHERE id = $1 AND wid = $2 AND user_uid = $3`,
+		id, wid, userUID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+// ApplyRemediationResize 在 tx 里把建议的内存写进 worker 并标记建议已采纳，调用方在同一个事务里提交同步任务。
+// current 是调用方读到的 assigned_memory（可能为空，即默认内存）；
+// 建议已被采纳过、或 worker 的内存在此期间被改过时返回 ErrNotFound
+func ApplyRemediationResize(ctx context.Context, tx *sql.Tx, r *WorkerRemediation, current string) error {
+	res, err := tx.ExecContext(ctx,
+		`UPDATE worker_remediations SET applied_at = CURRENT_TIMESTAMP
+		 WHERE id = $1 AND applied_at IS NULL`, r.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	res, err = tx.ExecContext(ctx,
+		`UPDATE workers SET assigned_memory = $1, version = version + 1
+		 WHERE wid = $2 AND user_uid = $3 AND assigned_memory = $4`,
+		r.ToMemory, r.WorkerID, r.UserUID, current,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetWorkerMemory 自动扩容：只在内存仍是 from 时改成 to，用户同时改过配置时返回 ErrNotFound
+func SetWorkerMemory(ctx context.Context, wid, userUID, from, to string) error {
+	return execOwned(ctx,
+		`UPDATE workers SET assigned_memory = $1, version = version + 1 WHERE wid = $2 AND user_uid = $3 AND assigned_memory = $4`,
+		to, wid, userUID, from,
+	)
+}
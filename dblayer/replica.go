@@ -0,0 +1,209 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"jabberwocky238/console/logging"
+	"jabberwocky238/console/metrics"
+
+	"github.com/lib/pq"
+)
+
+// 只读副本：InitReplicas 之后，控制台列表类的只读查询（readRows/readRow）轮询发到健康的副本，
+// 其他查询和所有写入仍走 DB（主库）。副本由 RunReplicaHealthCheck 定期检查，连不上或复制延迟
+// 超过 MaxReplicaLag 时摘掉，恢复后重新加入；没有健康副本时读主库。
+// 单次查询在副本上失败（不是调用方取消）也会立即摘掉该副本并在主库上重试。
+// 刚写入就要读到的场景用 WithPrimary(ctx) 强制读主库。
+
+var replicaLog = logging.Component("replica")
+
+// MaxReplicaLag 复制延迟超过它的副本不再接收读请求
+var MaxReplicaLag = 5 * time.Second
+
+// ReplicaCheckInterval 副本健康检查的间隔
+const ReplicaCheckInterval = 5 * time.Second
+
+type replica struct {
+	name    string // host:port，用于日志和指标，不含密码
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+var (
+	replicas    []*replica
+	replicaNext atomic.Uint64
+)
+
+// InitReplicas 连接只读副本，连接池比主库小一些。启动时连不上的副本先标记为不健康，
+// 由健康检查在它恢复后加入
+func InitReplicas(dsns []string) error {
+	for i, dsn := range dsns {
+		db, err := sql.Open("postgres", withStatementTimeout(dsn, StatementTimeout))
+		if err != nil {
+			return fmt.Errorf("replica %d: %w", i, err)
+		}
+		db.SetMaxOpenConns(15)
+		db.SetMaxIdleConns(5)
+		db.SetConnMaxLifetime(5 * time.Minute)
+		r := &replica{name: replicaName(dsn, i), db: db}
+		metrics.RegisterDB(db, "console_replica_"+r.name)
+		replicas = append(replicas, r)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	CheckReplicas(ctx)
+	return nil
+}
+
+// replicaName DSN 里的 host:port，解析不出来时用序号
+func replicaName(dsn string, i int) string {
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		return u.Host
+	}
+	var host, port string
+	for _, kv := range strings.Fields(dsn) {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "host":
+			host = v
+		case "port":
+			port = v
+		}
+	}
+	if host == "" {
+		return fmt.Sprintf("replica-%d", i)
+	}
+	if port != "" {
+		return host + ":" + port
+	}
+	return host
+}
+
+// CloseReplicas 关闭副本连接
+func CloseReplicas() {
+	for _, r := range replicas {
+		r.db.Close()
+	}
+}
+
+// replicaLagQuery 副本回放落后主库的秒数；已经回放完收到的 WAL 时为 0（主库空闲时
+// pg_last_xact_replay_timestamp 会很旧，不能直接当作延迟）
+const replicaLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// CheckReplicas 检查每个副本的连通性和复制延迟，更新是否接收读请求
+func CheckReplicas(ctx context.Context) {
+	for _, r := range replicas {
+		var lag float64
+		err := r.db.QueryRowContext(ctx, replicaLagQuery).Scan(&lag)
+		healthy := err == nil && time.Duration(lag*float64(time.Second)) <= MaxReplicaLag
+		metrics.DBReplicaLag.WithLabelValues(r.name).Set(lag)
+		if healthy {
+			metrics.DBReplicaHealthy.WithLabelValues(r.name).Set(1)
+		} else {
+			metrics.DBReplicaHealthy.WithLabelValues(r.name).Set(0)
+		}
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		switch {
+		case healthy:
+			replicaLog.Info("replica takes reads", "replica", r.name, "lag", lag)
+		case err != nil:
+			replicaLog.Warn("replica unavailable, reading from primary", "replica", r.name, "error", err)
+		default:
+			replicaLog.Warn("replica lagging, reading from primary", "replica", r.name, "lag", lag, "max_lag", MaxReplicaLag.Seconds())
+		}
+	}
+}
+
+// RunReplicaHealthCheck 定期 CheckReplicas，直到 ctx 取消；没有副本时直接返回
+func RunReplicaHealthCheck(ctx context.Context) {
+	if len(replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(ReplicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, ReplicaCheckInterval)
+			CheckReplicas(checkCtx)
+			cancel()
+		}
+	}
+}
+
+type primaryKey struct{}
+
+// WithPrimary 让 ctx 上的只读查询也走主库，用于写入后紧接着的读取
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// readReplica 轮询挑一个健康的副本，没有或 ctx 要求主库时返回 nil
+func readReplica(ctx context.Context) *replica {
+	if len(replicas) == 0 || ctx.Value(primaryKey{}) != nil {
+		return nil
+	}
+	start := replicaNext.Add(1)
+	for i := range uint64(len(replicas)) {
+		r := replicas[(start+i)%uint64(len(replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// replicaFailed 查询在副本上失败时返回是否应该在主库重试。连接类错误同时摘掉副本；
+// 副本返回的 SQL 错误（比如和 WAL 回放冲突被取消）只重试这一次；调用方取消或超时不重试
+func replicaFailed(ctx context.Context, r *replica, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		metrics.DBReplicaFallbacks.Inc()
+		return true
+	}
+	if r.healthy.Swap(false) {
+		metrics.DBReplicaHealthy.WithLabelValues(r.name).Set(0)
+		replicaLog.Warn("replica query failed, reading from primary", "replica", r.name, "error", err)
+	}
+	metrics.DBReplicaFallbacks.Inc()
+	return true
+}
+
+// readRows 只读的多行查询，优先走副本
+func readRows(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if r := readReplica(ctx); r != nil {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err == nil || !replicaFailed(ctx, r, err) {
+			return rows, err
+		}
+	}
+	return DB.QueryContext(ctx, query, args...)
+}
+
+// readRow 只读的单行查询，优先走副本
+func readRow(ctx context.Context, query string, args ...any) *sql.Row {
+	if r := readReplica(ctx); r != nil {
+		row := r.db.QueryRowContext(ctx, query, args...)
+		if err := row.Err(); err == nil || !replicaFailed(ctx, r, err) {
+			return row
+		}
+	}
+	return DB.QueryRowContext(ctx, query, args...)
+}
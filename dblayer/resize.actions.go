@@ -0,0 +1,128 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// worker 资源调整的状态
+const (
+	WorkerResizePending = "pending"
+	WorkerResizeApplied = "applied"
+	WorkerResizeFailed  = "failed"
+)
+
+// WorkerResourceChange 一次不重新部署的资源调整（CPU/内存/磁盘）：调整前后的值，以及是否已同步到集群。
+// 只追加，worker 删除后保留；不产生部署版本
+type WorkerResourceChange struct {
+	ID         int        `json:"id"`
+	UserUID    string     `json:"-"`
+	WorkerID   string     `json:"worker_id"`
+	Actor      string     `json:"actor"` // user
+	FromCPU    string     `json:"from_cpu"`
+	ToCPU      string     `json:"to_cpu"`
+	FromMemory string     `json:"from_memory"`
+	ToMemory   string     `json:"to_memory"`
+	FromDisk   string     `json:"from_disk"`
+	ToDisk     string     `json:"to_disk"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AppliedAt  *time.Time `json:"applied_at"`
+}
+
+const workerResourceChangeColumns = `id, user_uid, wid, actor, from_cpu, to_cpu, from_memory, to_memory, from_disk, to_disk,
+	status, error, created_at, applied_at`
+
+func scanWorkerResourceChange(row rowScanner) (*WorkerResourceChange, error) {
+	var r WorkerResourceChange
+	err := row.Scan(&r.ID, &r.UserUID, &r.WorkerID, &r.Actor, &r.FromCPU, &r.ToCPU, &r.FromMemory, &r.ToMemory, &r.FromDisk, &r.ToDisk,
+		&r.Status, &r.Error, &r.CreatedAt, &r.AppliedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ResizeWorker 在 q 里改 worker 的 CPU/内存/磁盘并记录这次调整，回填 ID、Status 和 CreatedAt，返回 worker 的新版本。
+// expectedVersion 为 0 时不检查版本；版本不符时返回当前版本和 ErrVersionConflict
+func ResizeWorker(ctx context.Context, q Querier, r *WorkerResourceChange, expectedVersion int) (int, error) {
+	version, err := updateVersioned(ctx, q,
+		`UPDATE workers SET assigned_cpu = $1, assigned_memory = $2, assigned_disk = $3, version = version + 1
+		 WHERE wid = $4 AND user_uid = $5 AND ($6 = 0 OR version = $6) RETURNING version`,
+		[]any{r.ToCPU, r.ToMemory, r.ToDisk, r.WorkerID, r.UserUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, r.WorkerID, r.UserUID)
+	if err != nil {
+		return version, err
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err = q.QueryRowContext(ctx,
+		`INSERT INTO worker_resource_changes (user_uid, wid, actor, from_cpu, to_cpu, from_memory, to_memory, from_disk, to_disk)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING status, id, created_at`,
+		r.UserUID, r.WorkerID, r.Actor, r.FromCPU, r.ToCPU, r.FromMemory, r.ToMemory, r.FromDisk, r.ToDisk,
+	).Scan(&r.Status, &r.ID, &r.CreatedAt)
+	return version, err
+}
+
+// GetWorkerResourceChange 按 ID 读取资源调整记录
+func GetWorkerResourceChange(ctx context.Context, id int) (*WorkerResourceChange, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	r, err := scanWorkerResourceChange(DB.QueryRowContext(ctx,
+		`SELECT `+workerResourceChangeColumns+` FROM worker_resource_changes WHERE id = $1`, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+// ListWorkerResourceChanges worker 的资源调整记录，新的在前
+func ListWorkerResourceChanges(ctx context.Context, workerID, userUID string, limit int) ([]*WorkerResourceChange, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+workerResourceChangeColumns+` FROM worker_resource_changes
+		 WHERE user_uid = $1 AND wid = $2
+		 ORDER BY id DESC LIMIT $3`,
+		userUID, workerID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*WorkerResourceChange{}
+	for rows.Next() {
+		r, err := scanWorkerResourceChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// FinishWorkerResourceChange 记录同步结果，cause 为 nil 表示已写入 WorkerApp CR。
+// 只更新 pending 的记录，返回是否更新了
+func FinishWorkerResourceChange(ctx context.Context, id int, cause error) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	status, msg := WorkerResizeApplied, ""
+	if cause != nil {
+		status, msg = WorkerResizeFailed, cause.Error()
+	}
+	res, err := DB.ExecContext(ctx,
+		`UPDATE worker_resource_changes
+		 SET status = $2, error = $3, applied_at = CASE WHEN $4 THEN CURRENT_TIMESTAMP END
+		 WHERE id = $1 AND status = 'pending'`,
+		id, status, msg, cause == nil,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,110 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// worker 滚动重启的状态
+const (
+	WorkerRestartPending   = "pending"
+	WorkerRestartRestarted = "restarted"
+	WorkerRestartFailed    = "failed"
+)
+
+// WorkerRestart 一次滚动重启的审计记录：谁、什么时候、为什么，以及是否已执行。只追加，worker 删除后保留
+type WorkerRestart struct {
+	ID          int        `json:"id"`
+	UserUID     string     `json:"-"`
+	WorkerID    string     `json:"worker_id"`
+	Actor       string     `json:"actor"` // user
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RestartedAt *time.Time `json:"restarted_at"`
+}
+
+const workerRestartColumns = `id, user_uid, wid, actor, reason, status, error, created_at, restarted_at`
+
+func scanWorkerRestart(row rowScanner) (*WorkerRestart, error) {
+	var r WorkerRestart
+	err := row.Scan(&r.ID, &r.UserUID, &r.WorkerID, &r.Actor, &r.Reason, &r.Status, &r.Error, &r.CreatedAt, &r.RestartedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateWorkerRestart 在 q 里记录一次待执行的重启，回填 ID、Status 和 CreatedAt
+func CreateWorkerRestart(ctx context.Context, q Querier, r *WorkerRestart) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return q.QueryRowContext(ctx,
+		`INSERT INTO worker_restarts (user_uid, wid, actor, reason) VALUES ($1, $2, $3, $4)
+		 RETURNING status, id, created_at`,
+		r.UserUID, r.WorkerID, r.Actor, r.Reason,
+	).Scan(&r.Status, &r.ID, &r.CreatedAt)
+}
+
+// GetWorkerRestart 按 ID 读取重启记录。刚提交就会被查询进度，所以读主库
+func GetWorkerRestart(ctx context.Context, id int) (*WorkerRestart, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	r, err := scanWorkerRestart(DB.QueryRowContext(ctx,
+		`SELECT `+workerRestartColumns+` FROM worker_restarts WHERE id = $1`, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+// ListWorkerRestarts worker 的重启记录，新的在前
+func ListWorkerRestarts(ctx context.Context, workerID, userUID string, limit int) ([]*WorkerRestart, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+workerRestartColumns+` FROM worker_restarts
+		 WHERE user_uid = $1 AND wid = $2
+		 ORDER BY id DESC LIMIT $3`,
+		userUID, workerID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*WorkerRestart{}
+	for rows.Next() {
+		r, err := scanWorkerRestart(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// FinishWorkerRestart 记录重启结果，cause 为 nil 表示已打上重启注解。
+// 只更新 pending 的记录，返回是否更新了
+func FinishWorkerRestart(ctx context.Context, id int, cause error) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	status, msg := WorkerRestartRestarted, ""
+	if cause != nil {
+		status, msg = WorkerRestartFailed, cause.Error()
+	}
+	res, err := DB.ExecContext(ctx,
+		`UPDATE worker_restarts
+		 SET status = $2, error = $3, restarted_at = CASE WHEN $4 THEN CURRENT_TIMESTAMP END
+		 WHERE id = $1 AND status = 'pending'`,
+		id, status, msg, cause == nil,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
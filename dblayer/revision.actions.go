@@ -0,0 +1,152 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// 独立 combinator（COMBINATOR_APPS）的配置版本握手：配置每次变化 revision 加一并写进 CombinatorApp，
+// pod 加载后通过 /api/combinator/reportConfig 回报 applied_revision。
+// config_revision 高于 applied_revision 的 active 资源对用户显示为 pending_apply
+
+// ResourceStatusPendingApply 资源已创建，但用户的 combinator 还没加载包含它的配置（只用于展示，不写入数据库）
+const ResourceStatusPendingApply = "pending_apply"
+
+// CombinatorConfig 用户 combinator 配置的目标版本、已加载版本和规格
+type CombinatorConfig struct {
+	UserUID         string     `json:"user_uid"`
+	Revision        int64      `json:"revision"`
+	AppliedRevision int64      `json:"applied_revision"`
+	AppliedAt       *time.Time `json:"applied_at"`
+	Profile         string     `json:"profile"` // 空表示套餐默认
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// BumpCombinatorRevision 用户的配置版本加一并返回新版本；resourceID 非空时把该资源的 config_revision 设为新版本
+func BumpCombinatorRevision(ctx context.Context, userUID, resourceType, resourceID string) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var revision int64
+	err := WithTx(ctx, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx,
+			`INSERT INTO combinator_configs (user_uid, revision) VALUES ($1, 1)
+			 ON CONFLICT (user_uid) DO UPDATE SET revision = combinator_configs.revision + 1, updated_at = CURRENT_TIMESTAMP
+			 RETURNING revision`,
+			userUID,
+		).Scan(&revision); err != nil {
+			return err
+		}
+		if resourceID == "" {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx,
+			`UPDATE combinator_resources SET config_revision = $1
+			 WHERE user_uid = $2 AND resource_type = $3 AND resource_id = $4`,
+			revision, userUID, resourceType, resourceID,
+		)
+		return err
+	})
+	return revision, err
+}
+
+// SetCombinatorAppliedRevision 记录 pod 加载的版本，只会前进；版本超过目标版本或用户没有配置时返回 ErrNotFound。
+// 这次变为已加载的资源会 touch 一下，让 updated_at（ETag）跟着状态变化
+func SetCombinatorAppliedRevision(ctx context.Context, userUID string, revision int64) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		var desired, applied int64
+		err := tx.QueryRowContext(ctx,
+			`SELECT revision, applied_revision FROM combinator_configs WHERE user_uid = $1 FOR UPDATE`,
+			userUID,
+		).Scan(&desired, &applied)
+		if err == sql.ErrNoRows || (err == nil && revision > desired) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if revision <= applied {
+			return nil // 重复或过期的回报
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE combinator_configs SET applied_revision = $2, applied_at = CURRENT_TIMESTAMP WHERE user_uid = $1`,
+			userUID, revision,
+		); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE combinator_resources SET config_revision = config_revision
+			 WHERE user_uid = $1 AND config_revision > $2 AND config_revision <= $3`,
+			userUID, applied, revision,
+		)
+		return err
+	})
+}
+
+// GetCombinatorConfig 获取用户的配置版本，没有时返回零值
+func GetCombinatorConfig(ctx context.Context, userUID string) (*CombinatorConfig, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	cfg := CombinatorConfig{UserUID: userUID}
+	err := DB.QueryRowContext(ctx,
+		`SELECT revision, applied_revision, applied_at, profile, updated_at FROM combinator_configs WHERE user_uid = $1`,
+		userUID,
+	).Scan(&cfg.Revision, &cfg.AppliedRevision, &cfg.AppliedAt, &cfg.Profile, &cfg.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// SetCombinatorProfile 设置用户 combinator 的规格，空表示套餐默认；q 可以是事务，以便和同步任务一起写入
+func SetCombinatorProfile(ctx context.Context, q Querier, userUID, profile string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO combinator_configs (user_uid, profile) VALUES ($1, $2)
+		 ON CONFLICT (user_uid) DO UPDATE SET profile = EXCLUDED.profile, updated_at = CURRENT_TIMESTAMP`,
+		userUID, profile,
+	)
+	return err
+}
+
+// MarkPendingApply 把 combinator 还没加载的 active 资源的 Status 改为 pending_apply
+func MarkPendingApply(ctx context.Context, userUID string, resources []*CombinatorResource) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT r.resource_type, r.resource_id FROM combinator_resources r
+		 JOIN combinator_configs c ON c.user_uid = r.user_uid
+		 WHERE r.user_uid = $1 AND r.status = 'active' AND r.config_revision > c.applied_revision`,
+		userUID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	pending := map[[2]string]bool{}
+	for rows.Next() {
+		var key [2]string
+		if err := rows.Scan(&key[0], &key[1]); err != nil {
+			return err
+		}
+		pending[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, r := range resources {
+		if r.Status == "active" && pending[[2]string{r.ResourceType, r.ResourceID}] {
+			r.Status = ResourceStatusPendingApply
+		}
+	}
+	return nil
+}
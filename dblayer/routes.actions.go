@@ -0,0 +1,70 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// RouteRule worker 的一条额外路由规则，条件之间是"且"，空的条件不参与匹配
+type RouteRule struct {
+	Hosts      []string          `json:"hosts,omitempty"` // 为空时是 worker 自己的域名
+	PathPrefix string            `json:"path_prefix,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"` // 请求头的值精确匹配
+	Priority   int               `json:"priority"`          // 越大越先匹配
+}
+
+// WorkerRoutes worker 的额外路由规则，没有设置过的 worker 只有自己的域名
+type WorkerRoutes struct {
+	Rules     []RouteRule `json:"rules"`
+	UpdatedAt *time.Time  `json:"updated_at,omitempty"`
+}
+
+// GetWorkerRoutes 获取 worker 的额外路由规则
+func GetWorkerRoutes(ctx context.Context, wid string) (WorkerRoutes, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	r := WorkerRoutes{Rules: []RouteRule{}}
+	var rules []byte
+	var updatedAt time.Time
+	err := DB.QueryRowContext(ctx,
+		`SELECT rules, updated_at FROM worker_routes WHERE wid = $1`, wid,
+	).Scan(&rules, &updatedAt)
+	if err == sql.ErrNoRows {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(rules, &r.Rules); err != nil {
+		return r, err
+	}
+	r.UpdatedAt = &updatedAt
+	return r, nil
+}
+
+// SetWorkerRoutes 在 tx 里替换 worker 的额外路由规则并把 worker 版本加一，expectedVersion 为 0 时不检查版本，返回新版本；
+// worker 不存在或不属于该用户时返回 ErrNotFound
+func SetWorkerRoutes(ctx context.Context, tx *sql.Tx, wid, userUID string, rules []RouteRule, expectedVersion int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return 0, err
+	}
+	version, err := updateVersioned(ctx, tx,
+		`UPDATE workers SET version = version + 1
+		 WHERE wid = $1 AND user_uid = $2 AND ($3 = 0 OR version = $3) RETURNING version`,
+		[]any{wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+	if err != nil {
+		return version, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO worker_routes (wid, rules) VALUES ($1, $2)
+		 ON CONFLICT (wid) DO UPDATE SET rules = EXCLUDED.rules, updated_at = CURRENT_TIMESTAMP`,
+		wid, data,
+	)
+	return version, err
+}
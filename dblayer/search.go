@@ -0,0 +1,137 @@
+package dblayer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 搜索结果类型
+const (
+	SearchTypeWorker  = "worker"
+	SearchTypeDomain  = "domain"
+	SearchTypeRDB     = "rdb"
+	SearchTypeKV      = "kv"
+	SearchTypeBucket  = "bucket"
+	SearchTypeQueue   = "queue"
+	SearchTypeVersion = "version"
+)
+
+// SearchTypes 全部可搜索的资源类型
+var SearchTypes = []string{SearchTypeWorker, SearchTypeDomain, SearchTypeRDB, SearchTypeKV, SearchTypeBucket, SearchTypeQueue, SearchTypeVersion}
+
+const (
+	MinSearchQueryLen  = 2 // 更短的词 trigram 没有区分度
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// SearchResult 一条搜索命中
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`                  // wid / cdid / resource_id / version id
+	Name      string    `json:"name"`                // worker 名、域名、资源名、镜像
+	ParentID  string    `json:"parent_id,omitempty"` // version 所属 worker 的 wid
+	Status    string    `json:"status"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// searchMatches 用户所有资源中匹配关键词的行。
+// $1 user_uid, $2 小写关键词, $3 包含匹配的 LIKE 模式, $4 前缀匹配的 LIKE 模式。
+// 得分 = trigram 相似度，完全相等 +1，前缀 +0.5；ILIKE 与 % 都走 gin_trgm_ops 索引。
+const searchMatches = `WITH matches AS (
+	SELECT 'worker' AS type, wid AS id, worker_name AS name, '' AS parent_id, status, created_at,
+		GREATEST(similarity(worker_name, $2), similarity(wid, $2))
+		+ CASE WHEN lower(worker_name) = $2 OR wid = $2 THEN 1 WHEN worker_name ILIKE $4 OR wid ILIKE $4 THEN 0.5 ELSE 0 END AS score
+	FROM workers
+	WHERE user_uid = $1 AND (worker_name ILIKE $3 OR wid ILIKE $3 OR worker_name % $2)
+	UNION ALL
+	SELECT 'domain', cdid, domain, '', status, created_at,
+		similarity(domain, $2)
+		+ CASE WHEN lower(domain) = $2 THEN 1 WHEN domain ILIKE $4 THEN 0.5 ELSE 0 END
+	FROM custom_domains
+	WHERE user_uid = $1 AND (domain ILIKE $3 OR domain % $2)
+	UNION ALL
+	SELECT resource_type, resource_id, name, '', status, created_at,
+		GREATEST(similarity(name, $2), similarity(resource_id, $2))
+		+ CASE WHEN lower(name) = $2 OR resource_id = $2 THEN 1 WHEN name ILIKE $4 OR resource_id ILIKE $4 THEN 0.5 ELSE 0 END
+	FROM combinator_resources
+	WHERE user_uid = $1 AND (name ILIKE $3 OR resource_id ILIKE $3 OR name % $2)
+	UNION ALL
+	SELECT 'version', v.id::text, v.image, w.wid, v.status, v.created_at,
+		similarity(v.image, $2)
+		+ CASE WHEN lower(v.image) = $2 THEN 1 WHEN v.image ILIKE $4 THEN 0.5 ELSE 0 END
+	FROM worker_deploy_versions v JOIN workers w ON w.id = v.worker_id
+	WHERE w.user_uid = $1 AND (v.image ILIKE $3 OR v.image % $2)
+)
+`
+
+// Search 在用户的 worker、域名、RDB/KV/bucket/队列、部署版本中搜索，按得分排序。
+// types 为空时不过滤类型；facets 是各类型的命中总数，不受 types 和 limit 影响。
+func Search(ctx context.Context, userUID, q string, types []string, limit int) ([]*SearchResult, map[string]int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+	q = strings.ToLower(strings.TrimSpace(q))
+	pattern := escapeLike(q)
+	args := []any{userUID, q, "%" + pattern + "%", pattern + "%"}
+
+	facets := make(map[string]int, len(SearchTypes))
+	for _, t := range SearchTypes {
+		facets[t] = 0
+	}
+	rows, err := readRows(ctx, searchMatches+`SELECT type, count(*) FROM matches GROUP BY type`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t string
+		var n int
+		if err := rows.Scan(&t, &n); err != nil {
+			return nil, nil, err
+		}
+		facets[t] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	query := searchMatches + `SELECT type, id, name, parent_id, status, created_at, score FROM matches`
+	if len(types) > 0 {
+		args = append(args, pq.Array(types))
+		query += fmt.Sprintf(" WHERE type = ANY($%d)", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY score DESC, created_at DESC LIMIT $%d", len(args))
+
+	rows, err = readRows(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.Type, &r.ID, &r.Name, &r.ParentID, &r.Status, &r.CreatedAt, &r.Score); err != nil {
+			return nil, nil, err
+		}
+		results = append(results, &r)
+	}
+	return results, facets, rows.Err()
+}
+
+// escapeLike 转义 LIKE 的通配符，关键词按字面匹配
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,279 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrSecretExists 同名 secret 已存在
+var ErrSecretExists = errors.New("secret already exists")
+
+// UserSecret 用户应用 secret 的元数据，值加密后按版本存在 user_secret_versions
+type UserSecret struct {
+	Name           string    `json:"name"`
+	CurrentVersion int       `json:"current_version"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// SecretVersion 一个版本的元数据，不含值
+type SecretVersion struct {
+	Version   int       `json:"version"`
+	KeyID     string    `json:"key_id"` // 加密用的平台密钥
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SealedSecret 加密后的 secret 值，只在 inner 解密
+type SealedSecret struct {
+	Version int
+	KeyID   string
+	Sealed  []byte
+}
+
+// 访问记录的 action
+const (
+	SecretActionCreate  = "create"
+	SecretActionRotate  = "rotate"
+	SecretActionDelete  = "delete"
+	SecretActionResolve = "resolve"
+)
+
+// SecretAccess 一条 secret 访问记录，只追加，secret 删除后保留
+type SecretAccess struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"` // user 或 worker:<wid>
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListUserSecrets 用户的所有 secret，按名称排序
+func ListUserSecrets(ctx context.Context, userUID string) ([]UserSecret, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT name, current_version, created_at, updated_at FROM user_secrets WHERE user_uid = $1 ORDER BY name`,
+		userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []UserSecret{}
+	for rows.Next() {
+		var s UserSecret
+		if err := rows.Scan(&s.Name, &s.CurrentVersion, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// GetUserSecret secret 元数据及所有版本（新的在前），不存在时返回 ErrNotFound
+func GetUserSecret(ctx context.Context, userUID, name string) (*UserSecret, []SecretVersion, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var s UserSecret
+	var id int
+	err := DB.QueryRowContext(ctx,
+		`SELECT id, name, current_version, created_at, updated_at FROM user_secrets WHERE user_uid = $1 AND name = $2`,
+		userUID, name,
+	).Scan(&id, &s.Name, &s.CurrentVersion, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := DB.QueryContext(ctx,
+		`SELECT version, key_id, created_at FROM user_secret_versions WHERE secret_id = $1 ORDER BY version DESC`, id,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	versions := []SecretVersion{}
+	for rows.Next() {
+		var v SecretVersion
+		if err := rows.Scan(&v.Version, &v.KeyID, &v.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+		versions = append(versions, v)
+	}
+	return &s, versions, rows.Err()
+}
+
+// CreateUserSecret 创建 secret 及其第 1 个版本，同名时返回 ErrSecretExists
+func CreateUserSecret(ctx context.Context, userUID, name, keyID string, sealed []byte) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO user_secrets (user_uid, name) VALUES ($1, $2) RETURNING id`, userUID, name,
+	).Scan(&id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrSecretExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO user_secret_versions (secret_id, version, key_id, sealed) VALUES ($1, 1, $2, $3)`, id, keyID, sealed,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RotateUserSecret 追加一个新版本并设为当前版本，返回新版本号。seal 按新版本号加密值，
+// 在锁住该 secret 的事务里调用，并发轮换不会拿到同一个版本号
+func RotateUserSecret(ctx context.Context, userUID, name string, seal func(version int) (keyID string, sealed []byte, err error)) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id, version int
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, current_version FROM user_secrets WHERE user_uid = $1 AND name = $2 FOR UPDATE`, userUID, name,
+	).Scan(&id, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	version++
+	keyID, sealed, err := seal(version)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO user_secret_versions (secret_id, version, key_id, sealed) VALUES ($1, $2, $3, $4)`, id, version, keyID, sealed,
+	); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE user_secrets SET current_version = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, version, id,
+	); err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// DeleteUserSecret 删除 secret 及所有版本，访问记录保留
+func DeleteUserSecret(ctx context.Context, userUID, name string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM user_secrets WHERE user_uid = $1 AND name = $2`, userUID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetSealedSecret secret 当前版本的密文，不存在时返回 ErrNotFound
+func GetSealedSecret(ctx context.Context, userUID, name string) (*SealedSecret, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var s SealedSecret
+	err := DB.QueryRowContext(ctx,
+		`SELECT v.version, v.key_id, v.sealed
+		 FROM user_secrets s JOIN user_secret_versions v ON v.secret_id = s.id AND v.version = s.current_version
+		 WHERE s.user_uid = $1 AND s.name = $2`,
+		userUID, name,
+	).Scan(&s.Version, &s.KeyID, &s.Sealed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSecretReferrers env 里引用了 ref（secretref://name）的 worker，包括通过挂的 env 组和所在环境的变量引用的
+func ListSecretReferrers(ctx context.Context, userUID, ref string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	// env_json 和 vars 的文本形式都是 JSON，按带引号的值匹配
+	rows, err := DB.QueryContext(ctx,
+		`SELECT wid FROM workers WHERE user_uid = $1 AND strpos(env_json, $2) > 0
+		 UNION
+		 SELECT weg.wid FROM worker_env_groups weg JOIN env_groups g ON g.id = weg.group_id
+		 WHERE g.user_uid = $1 AND strpos(g.vars::text, $2) > 0
+		 UNION
+		 SELECT w.wid FROM workers w JOIN environments e ON e.user_uid = w.user_uid AND e.name = w.environment
+		 WHERE e.user_uid = $1 AND strpos(e.vars::text, $2) > 0
+		 ORDER BY wid`,
+		userUID, `"`+ref+`"`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	wids := []string{}
+	for rows.Next() {
+		var wid string
+		if err := rows.Scan(&wid); err != nil {
+			return nil, err
+		}
+		wids = append(wids, wid)
+	}
+	return wids, rows.Err()
+}
+
+// RecordSecretAccess 记录一次 secret 访问
+func RecordSecretAccess(ctx context.Context, userUID string, a SecretAccess) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO secret_access_log (user_uid, name, version, action, actor) VALUES ($1, $2, $3, $4, $5)`,
+		userUID, a.Name, a.Version, a.Action, a.Actor,
+	)
+	return err
+}
+
+// ListSecretAccess secret 的访问记录，新的在前
+func ListSecretAccess(ctx context.Context, userUID, name string, limit int) ([]SecretAccess, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT id, name, version, action, actor, created_at FROM secret_access_log
+		 WHERE user_uid = $1 AND name = $2
+		 ORDER BY id DESC LIMIT $3`,
+		userUID, name, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []SecretAccess{}
+	for rows.Next() {
+		var a SecretAccess
+		if err := rows.Scan(&a.ID, &a.Name, &a.Version, &a.Action, &a.Actor, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
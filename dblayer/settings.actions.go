@@ -0,0 +1,111 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SettingsChannel 运行时配置和功能开关变更的 NOTIFY 频道，payload 是变更的 key
+// （开关带 "flag:" 前缀，租户任务上限为 "joblimit:<uid>"）
+const SettingsChannel = "console_settings"
+
+// SettingRow settings 表里的一条覆盖值
+type SettingRow struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListSettings 所有覆盖值，按 key 排序
+func ListSettings(ctx context.Context) ([]SettingRow, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT key, value, updated_by, updated_at FROM settings ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SettingRow
+	for rows.Next() {
+		var s SettingRow
+		if err := rows.Scan(&s.Key, &s.Value, &s.UpdatedBy, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// SetSetting 写入覆盖值并通知所有实例重新加载，通知随事务提交送达
+func SetSetting(ctx context.Context, key, value, updatedBy string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO settings (key, value, updated_by) VALUES ($1, $2, $3)
+			 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by,
+			     updated_at = CURRENT_TIMESTAMP`,
+			key, value, updatedBy,
+		); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, SettingsChannel, key)
+		return err
+	})
+}
+
+// DeleteSetting 删除覆盖值（回到默认值）并通知所有实例，没有覆盖时返回 ErrNotFound
+func DeleteSetting(ctx context.Context, key string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return WithTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM settings WHERE key = $1`, key)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, SettingsChannel, key)
+		return err
+	})
+}
+
+// ListenSettings 在独立连接上 LISTEN 配置变更，收到通知时调用 reload；
+// 断线重连后也调用一次，补上断线期间错过的变更。ctx 取消时关闭连接
+func ListenSettings(ctx context.Context, dsn string, reload func()) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			eventsLog.Warn("settings listener", "error", err)
+		}
+	})
+	if err := listener.Listen(SettingsChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-listener.Notify:
+				if !ok {
+					return
+				}
+				// nil 表示重连，和普通通知一样整表重新加载
+				reload()
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+	eventsLog.Info("listening", "channel", SettingsChannel)
+	return nil
+}
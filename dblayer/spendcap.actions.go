@@ -0,0 +1,198 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SpendCap 用户的每月花费上限。本月累计花费达到上限后暂停非关键 worker，直到下个月、上限调高或用户本月放行
+type SpendCap struct {
+	MonthlyCap     float64   `json:"monthly_cap"`
+	Currency       string    `json:"currency"`
+	EnforcedPeriod string    `json:"enforced_period,omitempty"` // 已暂停 worker 的月份（YYYY-MM），未执行时为空
+	OverridePeriod string    `json:"override_period,omitempty"` // 用户放行的月份，该月不再暂停
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// 执行记录的 action
+const (
+	SpendCapActionSet      = "set_cap"
+	SpendCapActionClear    = "clear_cap"
+	SpendCapActionOverride = "override"
+	SpendCapActionSuspend  = "suspend"
+	SpendCapActionResume   = "resume"
+)
+
+// SpendCapAction 花费上限的设置和执行记录，只追加
+type SpendCapAction struct {
+	ID        int       `json:"id"`
+	Period    string    `json:"period"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"` // user 或 system
+	Accrued   float64   `json:"accrued"`
+	Cap       float64   `json:"cap"`
+	WorkerIDs []string  `json:"worker_ids"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetSpendCap 用户的花费上限，没有设置时返回 ErrNotFound
+func GetSpendCap(ctx context.Context, userUID string) (*SpendCap, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var c SpendCap
+	err := DB.QueryRowContext(ctx,
+		`SELECT monthly_cap, currency, enforced_period, override_period, updated_at FROM spend_caps WHERE user_uid = $1`,
+		userUID,
+	).Scan(&c.MonthlyCap, &c.Currency, &c.EnforcedPeriod, &c.OverridePeriod, &c.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SetSpendCap 设置或修改花费上限，保留执行和放行状态
+func SetSpendCap(ctx context.Context, userUID string, monthlyCap float64, currency string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO spend_caps (user_uid, monthly_cap, currency) VALUES ($1, $2, $3)
+		 ON CONFLICT (user_uid) DO UPDATE SET
+		   monthly_cap = EXCLUDED.monthly_cap, currency = EXCLUDED.currency, updated_at = CURRENT_TIMESTAMP`,
+		userUID, monthlyCap, currency,
+	)
+	return err
+}
+
+// DeleteSpendCap 取消花费上限，没有设置时返回 ErrNotFound
+func DeleteSpendCap(ctx context.Context, userUID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM spend_caps WHERE user_uid = $1`, userUID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SpendCapOf ListSpendCaps 中的一条
+type SpendCapOf struct {
+	UserUID string
+	SpendCap
+}
+
+// ListSpendCaps 所有设置了花费上限的用户
+func ListSpendCaps(ctx context.Context) ([]SpendCapOf, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT user_uid, monthly_cap, currency, enforced_period, override_period, updated_at FROM spend_caps ORDER BY user_uid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var caps []SpendCapOf
+	for rows.Next() {
+		var c SpendCapOf
+		if err := rows.Scan(&c.UserUID, &c.MonthlyCap, &c.Currency, &c.EnforcedPeriod, &c.OverridePeriod, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		caps = append(caps, c)
+	}
+	return caps, rows.Err()
+}
+
+// SetSpendCapEnforced 记录已暂停 worker 的月份，空字符串表示已恢复
+func SetSpendCapEnforced(ctx context.Context, userUID, period string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx, `UPDATE spend_caps SET enforced_period = $1, updated_at = CURRENT_TIMESTAMP WHERE user_uid = $2`, period, userUID)
+	return err
+}
+
+// SetSpendCapOverride 用户放行某个月，没有设置上限时返回 ErrNotFound
+func SetSpendCapOverride(ctx context.Context, userUID, period string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `UPDATE spend_caps SET override_period = $1, updated_at = CURRENT_TIMESTAMP WHERE user_uid = $2`, period, userUID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListCappableWorkerIDs 花费上限会暂停的 worker：运行中且没有打 essential=true 标签
+func ListCappableWorkerIDs(ctx context.Context, userUID string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT wid FROM workers
+		 WHERE user_uid = $1 AND status = 'active' AND COALESCE(tags->>'essential', '') <> 'true'
+		 ORDER BY id`,
+		userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// RecordSpendCapAction 追加一条设置或执行记录
+func RecordSpendCapAction(ctx context.Context, userUID string, a SpendCapAction) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	if a.WorkerIDs == nil {
+		a.WorkerIDs = []string{}
+	}
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO spend_cap_actions (user_uid, period, action, actor, accrued, cap, worker_ids, message)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		userUID, a.Period, a.Action, a.Actor, a.Accrued, a.Cap, pq.Array(a.WorkerIDs), a.Message,
+	)
+	return err
+}
+
+// ListSpendCapActions 用户的设置和执行记录，新的在前
+func ListSpendCapActions(ctx context.Context, userUID string, limit int) ([]SpendCapAction, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT id, period, action, actor, accrued, cap, worker_ids, message, created_at
+		 FROM spend_cap_actions WHERE user_uid = $1 ORDER BY id DESC LIMIT $2`,
+		userUID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	actions := []SpendCapAction{}
+	for rows.Next() {
+		var a SpendCapAction
+		if err := rows.Scan(&a.ID, &a.Period, &a.Action, &a.Actor, &a.Accrued, &a.Cap, pq.Array(&a.WorkerIDs), &a.Message, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
@@ -0,0 +1,104 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// worker 的类型
+const (
+	WorkerKindContainer = "container" // 运行用户的镜像
+	WorkerKindStatic    = "static"    // 用静态站点镜像托管上传的文件或仓库
+)
+
+// ErrWorkerKind 部署方式和 worker 类型不符：static worker 只能部署文件，container worker 只能部署镜像
+var ErrWorkerKind = errors.New("deploy does not match the worker kind")
+
+// StaticSource static worker 一个部署版本的文件来源：上传的 tarball（Artifact）或 GitHub 仓库（Repo、Ref）
+type StaticSource struct {
+	Artifact string `json:"artifact,omitempty"` // static_artifacts 里的 sha256
+	Repo     string `json:"repo,omitempty"`     // owner/name，只支持公开仓库
+	Ref      string `json:"ref,omitempty"`      // 分支、tag 或提交，部署时解析为提交记在 commit_sha 上
+	Dir      string `json:"dir,omitempty"`      // 作为站点根目录的子目录，为空时是整个 tarball 或仓库
+}
+
+// String 用于部署版本的 diff
+func (s *StaticSource) String() string {
+	if s == nil {
+		return ""
+	}
+	src := "artifact:" + s.Artifact
+	if s.Repo != "" {
+		src = s.Repo + "@" + s.Ref
+	}
+	if s.Dir != "" {
+		src += "/" + s.Dir
+	}
+	return src
+}
+
+// unmarshalStaticSource 读 static_source 列，container worker 的版本为 NULL
+func unmarshalStaticSource(data []byte) (*StaticSource, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var s StaticSource
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// PutStaticArtifact 保存用户上传的 tarball，相同内容只存一份
+func PutStaticArtifact(ctx context.Context, userUID, sha256 string, content []byte) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO static_artifacts (user_uid, sha256, size, content) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_uid, sha256) DO NOTHING`,
+		userUID, sha256, len(content), content,
+	)
+	return err
+}
+
+// GetStaticArtifact 获取用户上传的 tarball，没有时返回 ErrNotFound
+func GetStaticArtifact(ctx context.Context, userUID, sha256 string) ([]byte, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var content []byte
+	err := DB.QueryRowContext(ctx,
+		`SELECT content FROM static_artifacts WHERE user_uid = $1 AND sha256 = $2`, userUID, sha256,
+	).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return content, err
+}
+
+// PruneStaticArtifacts 删除 before 之前上传、没有部署版本引用的 tarball（worker 删除后它的版本也没了）
+func PruneStaticArtifacts(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx,
+		`DELETE FROM static_artifacts a WHERE a.created_at < $1 AND NOT EXISTS (
+		     SELECT 1 FROM worker_deploy_versions v JOIN workers w ON w.id = v.worker_id
+		     WHERE w.user_uid = a.user_uid AND v.static_source->>'artifact' = a.sha256)`, before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SetDeployVersionCommit 记下部署时仓库 ref 解析出的提交
+func SetDeployVersionCommit(ctx context.Context, versionID int, commitSHA string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE worker_deploy_versions SET commit_sha = $1 WHERE id = $2`, commitSHA, versionID,
+	)
+	return err
+}
@@ -0,0 +1,126 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Tags 资源上的 key/value 标签，存为 JSONB
+type Tags map[string]string
+
+// Value 实现 driver.Valuer，nil 存为 {}
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+// Scan 实现 sql.Scanner
+func (t *Tags) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*t = Tags{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("tags: unsupported type %T", src)
+	}
+	m := Tags{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*t = m
+	return nil
+}
+
+// TagFilter 列表过滤条件：key:value 精确匹配，只有 key 时匹配存在该标签的资源
+type TagFilter struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// ParseTagFilter 解析 ?tag=env:prod 或 ?tag=env
+func ParseTagFilter(s string) (TagFilter, error) {
+	key, value, hasValue := strings.Cut(s, ":")
+	if key == "" {
+		return TagFilter{}, fmt.Errorf("invalid tag filter %q, expected key or key:value", s)
+	}
+	return TagFilter{Key: key, Value: value, HasValue: hasValue}, nil
+}
+
+// SetWorkerTags 替换 worker 的全部标签，expectedVersion 为 0 时不检查版本，返回新版本
+func SetWorkerTags(ctx context.Context, wid, userUID string, tags Tags, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, DB,
+		`UPDATE workers SET tags = $1, version = version + 1
+		 WHERE wid = $2 AND user_uid = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		[]any{tags, wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+}
+
+// SetCombinatorResourceTags 替换 combinator 资源的全部标签，expectedVersion 为 0 时不检查版本，返回新版本
+func SetCombinatorResourceTags(ctx context.Context, userUID, resourceType, resourceID string, tags Tags, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, DB,
+		`UPDATE combinator_resources SET tags = $1, version = version + 1
+		 WHERE user_uid = $2 AND resource_type = $3 AND resource_id = $4 AND ($5 = 0 OR version = $5) RETURNING version`,
+		[]any{tags, userUID, resourceType, resourceID, expectedVersion},
+		`SELECT version FROM combinator_resources WHERE user_uid = $1 AND resource_type = $2 AND resource_id = $3`,
+		userUID, resourceType, resourceID)
+}
+
+// SetCustomDomainTags 替换自定义域名的全部标签，expectedVersion 为 0 时不检查版本，返回新版本
+func SetCustomDomainTags(ctx context.Context, cdid, userUID string, tags Tags, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, DB,
+		`UPDATE custom_domains SET tags = $1, version = version + 1
+		 WHERE cdid = $2 AND user_uid = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		[]any{tags, cdid, userUID, expectedVersion},
+		`SELECT version FROM custom_domains WHERE cdid = $1 AND user_uid = $2`, cdid, userUID)
+}
+
+// updateVersioned 执行带版本条件的 UPDATE ... RETURNING version，返回新版本。
+// 没有命中行时用 lookup 查当前版本：查不到返回 ErrNotFound，查到则返回当前版本和 ErrVersionConflict
+func updateVersioned(ctx context.Context, q Querier, update string, args []any, lookup string, lookupArgs ...any) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var version int
+	err := q.QueryRowContext(ctx, update, args...).Scan(&version)
+	if err != sql.ErrNoRows {
+		return version, err
+	}
+	err = q.QueryRowContext(ctx, lookup, lookupArgs...).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return version, ErrVersionConflict
+}
+
+// execOwned 执行带归属条件的更新，没有命中行时返回 ErrNotFound
+func execOwned(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
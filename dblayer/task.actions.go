@@ -1,12 +1,15 @@
 package dblayer
 
 import (
+	"context"
+	"sort"
 	"time"
 )
 
 type TaskStatusType string
 
 const (
+	TaskStatusPending    = "pending"
 	TaskStatusProcessing = "processing"
 	TaskStatusFinished   = "finished"
 )
@@ -21,7 +24,9 @@ type ConsoleTask struct {
 }
 
 // CreateTask creates a new console task
-func CreateTask(taskType, detailedStatus, taskInfo string, status TaskStatusType) (*ConsoleTask, error) {
+func CreateTask(ctx context.Context, taskType, detailedStatus, taskInfo string, status TaskStatusType) (*ConsoleTask, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	query := `
 		INSERT INTO console_tasks (task_type, task_status, task_detailed_status, task_info)
 		VALUES ($1, $2, $3, $4)
@@ -29,7 +34,7 @@ func CreateTask(taskType, detailedStatus, taskInfo string, status TaskStatusType
 	`
 
 	task := &ConsoleTask{}
-	err := DB.QueryRow(query, taskType, status, detailedStatus, taskInfo).Scan(
+	err := DB.QueryRowContext(ctx, query, taskType, status, detailedStatus, taskInfo).Scan(
 		&task.ID,
 		&task.TaskType,
 		&task.TaskStatus,
@@ -46,19 +51,23 @@ func CreateTask(taskType, detailedStatus, taskInfo string, status TaskStatusType
 }
 
 // UpdateTaskStatus updates the status and detailed status of a task
-func UpdateTaskStatus(taskID int, status TaskStatusType, detailedStatus string) error {
+func UpdateTaskStatus(ctx context.Context, taskID int, status TaskStatusType, detailedStatus string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	query := `
 		UPDATE console_tasks
 		SET task_status = $1, task_detailed_status = $2
 		WHERE id = $3
 	`
 
-	_, err := DB.Exec(query, status, detailedStatus, taskID)
+	_, err := DB.ExecContext(ctx, query, status, detailedStatus, taskID)
 	return err
 }
 
 // GetAllPendingTasks retrieves all tasks with pending status
-func GetAllPendingTasks() ([]ConsoleTask, error) {
+func GetAllPendingTasks(ctx context.Context) ([]ConsoleTask, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	query := `
 		SELECT id, task_type, task_status, task_detailed_status, task_info, created_at
 		FROM console_tasks
@@ -66,7 +75,7 @@ func GetAllPendingTasks() ([]ConsoleTask, error) {
 		ORDER BY created_at ASC
 	`
 
-	rows, err := DB.Query(query)
+	rows, err := DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -95,3 +104,49 @@ func GetAllPendingTasks() ([]ConsoleTask, error) {
 
 	return tasks, nil
 }
+
+// ClaimPendingTasks 原子地把所有 pending 任务标为 processing 并返回，多个实例同时启动也只会有一个拿到
+func ClaimPendingTasks(ctx context.Context, detailedStatus string) ([]ConsoleTask, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	query := `
+		UPDATE console_tasks
+		SET task_status = $1, task_detailed_status = $2
+		WHERE id IN (
+			SELECT id FROM console_tasks WHERE task_status = $3
+			ORDER BY created_at ASC
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, task_type, task_status, task_detailed_status, task_info, created_at
+	`
+
+	rows, err := DB.QueryContext(ctx, query, TaskStatusProcessing, detailedStatus, TaskStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []ConsoleTask
+	for rows.Next() {
+		var task ConsoleTask
+		err := rows.Scan(
+			&task.ID,
+			&task.TaskType,
+			&task.TaskStatus,
+			&task.TaskDetailedStatus,
+			&task.TaskInfo,
+			&task.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING 不保证顺序
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
@@ -0,0 +1,37 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Querier *sql.DB 和 *sql.Tx 都满足；需要和其他写入放进同一个事务的 dblayer 函数接受它，
+// 不需要事务时传 DB
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// WithTx 在一个事务里执行 fn：fn 返回错误或 panic 时回滚，否则提交。
+// 事务内不要做 HTTP、K8s 调用这类外部副作用，用 EnqueueOutbox 在提交后投递
+func WithTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,472 @@
+package dblayer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 拨测状态：还没探测过为 unknown，连续失败 UptimeFailureThreshold 次才算 down，避免偶发失败产生事故
+const (
+	UptimeStatusUnknown = "unknown"
+	UptimeStatusUp      = "up"
+	UptimeStatusDown    = "down"
+
+	UptimeFailureThreshold = 2
+)
+
+// ErrSlugTaken 状态页 slug 已被其他用户占用
+var ErrSlugTaken = errors.New("slug already taken")
+
+// UptimeCheck 对 worker 或已验证自定义域名公网地址的 HTTP 拨测，WorkerID 与 DomainID 有且只有一个
+type UptimeCheck struct {
+	ID                  int        `json:"id"`
+	UserUID             string     `json:"user_uid"`
+	Name                string     `json:"name"`
+	WorkerID            *string    `json:"worker_id,omitempty"`
+	DomainID            *string    `json:"domain_id,omitempty"`
+	URL                 string     `json:"url"`
+	IntervalSeconds     int        `json:"interval_seconds"`
+	TimeoutMs           int        `json:"timeout_ms"`
+	ExpectedStatus      int        `json:"expected_status"` // 0 表示任意 2xx/3xx
+	Public              bool       `json:"public"`
+	Enabled             bool       `json:"enabled"`
+	Status              string     `json:"status"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastCheckedAt       *time.Time `json:"last_checked_at,omitempty"`
+	LastLatencyMs       *int       `json:"last_latency_ms,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// UptimeResult 单次探测结果
+type UptimeResult struct {
+	ID         int64     `json:"id"`
+	CheckID    int       `json:"check_id"`
+	OK         bool      `json:"ok"`
+	StatusCode int       `json:"status_code"`
+	LatencyMs  int       `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// UptimeIncident 一次宕机：check 进入 down 时开始，恢复 up 时结束
+type UptimeIncident struct {
+	ID         int        `json:"id"`
+	CheckID    int        `json:"check_id"`
+	CheckName  string     `json:"check_name"`
+	Status     string     `json:"status"` // open | resolved
+	Reason     string     `json:"reason"`
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// UptimeStats 一段时间内的可用率与平均延迟（只算成功的探测）
+type UptimeStats struct {
+	Probes        int     `json:"probes"`
+	UptimePercent float64 `json:"uptime_percent"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+}
+
+// StatusPage 用户的公开状态页设置
+type StatusPage struct {
+	UserUID   string    `json:"-"`
+	Slug      string    `json:"slug"`
+	Title     string    `json:"title"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const uptimeCheckColumns = `id, user_uid, name, worker_id, domain_id, url, interval_seconds, timeout_ms, expected_status,
+	public, enabled, status, consecutive_failures, last_checked_at, last_latency_ms, created_at`
+
+func scanUptimeCheck(row rowScanner) (*UptimeCheck, error) {
+	var c UptimeCheck
+	err := row.Scan(&c.ID, &c.UserUID, &c.Name, &c.WorkerID, &c.DomainID, &c.URL, &c.IntervalSeconds, &c.TimeoutMs, &c.ExpectedStatus,
+		&c.Public, &c.Enabled, &c.Status, &c.ConsecutiveFailures, &c.LastCheckedAt, &c.LastLatencyMs, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func scanUptimeChecks(rows *sql.Rows) ([]*UptimeCheck, error) {
+	defer rows.Close()
+	var checks []*UptimeCheck
+	for rows.Next() {
+		c, err := scanUptimeCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// CountUptimeChecks 用户已有的拨测数
+func CountUptimeChecks(ctx context.Context, userUID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM uptime_checks WHERE user_uid = $1`, userUID).Scan(&n)
+	return n, err
+}
+
+// CreateUptimeCheck 创建拨测，回填 ID、状态与创建时间；创建后下一轮就会探测
+func CreateUptimeCheck(ctx context.Context, c *UptimeCheck) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return DB.QueryRowContext(ctx,
+		`INSERT INTO uptime_checks (user_uid, name, worker_id, domain_id, url, interval_seconds, timeout_ms, expected_status, public, enabled)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING id, status, created_at`,
+		c.UserUID, c.Name, c.WorkerID, c.DomainID, c.URL, c.IntervalSeconds, c.TimeoutMs, c.ExpectedStatus, c.Public, c.Enabled,
+	).Scan(&c.ID, &c.Status, &c.CreatedAt)
+}
+
+// GetUptimeCheckByOwner 获取单个拨测，验证归属
+func GetUptimeCheckByOwner(ctx context.Context, id int, userUID string) (*UptimeCheck, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return scanUptimeCheck(DB.QueryRowContext(ctx,
+		`SELECT `+uptimeCheckColumns+` FROM uptime_checks WHERE id = $1 AND user_uid = $2`, id, userUID,
+	))
+}
+
+// ListUptimeChecks 分页获取用户的拨测
+func ListUptimeChecks(ctx context.Context, userUID string, opts ListOptions) ([]*UptimeCheck, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	query, args, err := opts.listQuery(
+		`SELECT `+uptimeCheckColumns+` FROM uptime_checks WHERE user_uid = $1`, []any{userUID}, "name",
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	checks, err := scanUptimeChecks(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	checks, next := trimPage(checks, &opts, func(c *UptimeCheck) (string, int) {
+		return opts.cursorValue(c.CreatedAt, c.Name), c.ID
+	})
+	return checks, next, nil
+}
+
+// UpdateUptimeCheckByOwner 更新拨测配置（目标不可改），验证归属。
+// 停用时状态回到 unknown 并结束进行中的事故
+func UpdateUptimeCheckByOwner(ctx context.Context, c *UptimeCheck) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx,
+		`UPDATE uptime_checks SET name = $1, interval_seconds = $2, timeout_ms = $3, expected_status = $4, public = $5, enabled = $6,
+		        status = CASE WHEN $6 THEN status ELSE 'unknown' END,
+		        consecutive_failures = CASE WHEN $6 THEN consecutive_failures ELSE 0 END,
+		        next_check_at = CASE WHEN $6 AND NOT enabled THEN CURRENT_TIMESTAMP ELSE next_check_at END
+		 WHERE id = $7 AND user_uid = $8
+		 RETURNING `+uptimeCheckColumns,
+		c.Name, c.IntervalSeconds, c.TimeoutMs, c.ExpectedStatus, c.Public, c.Enabled, c.ID, c.UserUID,
+	)
+	updated, err := scanUptimeCheck(row)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !updated.Enabled {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE uptime_incidents SET resolved_at = CURRENT_TIMESTAMP WHERE check_id = $1 AND resolved_at IS NULL`, c.ID,
+		); err != nil {
+			return err
+		}
+	}
+	*c = *updated
+	return tx.Commit()
+}
+
+// DeleteUptimeCheckByOwner 删除拨测及其结果和事故，验证归属
+func DeleteUptimeCheckByOwner(ctx context.Context, id int, userUID string) error {
+	return execOwned(ctx, `DELETE FROM uptime_checks WHERE id = $1 AND user_uid = $2`, id, userUID)
+}
+
+// ClaimDueUptimeChecks 取出到期的拨测并把下次时间推后一个周期，
+// 多个 inner 实例同时拨测时每个 check 只会被一个实例拿到
+func ClaimDueUptimeChecks(ctx context.Context, limit int) ([]*UptimeCheck, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`UPDATE uptime_checks SET next_check_at = CURRENT_TIMESTAMP + interval_seconds * INTERVAL '1 second'
+		 WHERE id IN (
+			SELECT id FROM uptime_checks WHERE enabled AND next_check_at <= CURRENT_TIMESTAMP
+			ORDER BY next_check_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+uptimeCheckColumns, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanUptimeChecks(rows)
+}
+
+// RecordUptimeResult 保存一次探测结果并更新 check 状态，状态变为 down 时开始事故、恢复 up 时结束事故。
+// 返回变化前后的状态，由调用方决定是否通知
+func RecordUptimeResult(ctx context.Context, c *UptimeCheck, r *UptimeResult) (from, to string, err error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO uptime_results (check_id, ok, status_code, latency_ms, error)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id, checked_at`,
+		c.ID, r.OK, r.StatusCode, r.LatencyMs, r.Error,
+	).Scan(&r.ID, &r.CheckedAt); err != nil {
+		return "", "", err
+	}
+
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM uptime_checks WHERE id = $1 FOR UPDATE`, c.ID).Scan(&from); err != nil {
+		return "", "", err
+	}
+	if err := tx.QueryRowContext(ctx,
+		`UPDATE uptime_checks SET
+		        consecutive_failures = CASE WHEN $1 THEN 0 ELSE consecutive_failures + 1 END,
+		        status = CASE WHEN $1 THEN 'up'
+		                      WHEN consecutive_failures + 1 >= $2 THEN 'down'
+		                      ELSE status END,
+		        last_checked_at = $3, last_latency_ms = $4
+		 WHERE id = $5
+		 RETURNING status`,
+		r.OK, UptimeFailureThreshold, r.CheckedAt, r.LatencyMs, c.ID,
+	).Scan(&to); err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case to == UptimeStatusDown && from != UptimeStatusDown:
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO uptime_incidents (check_id, user_uid, reason, started_at) VALUES ($1, $2, $3, $4)`,
+			c.ID, c.UserUID, r.Error, r.CheckedAt,
+		)
+	case to == UptimeStatusUp && from == UptimeStatusDown:
+		_, err = tx.ExecContext(ctx,
+			`UPDATE uptime_incidents SET resolved_at = $1 WHERE check_id = $2 AND resolved_at IS NULL`,
+			r.CheckedAt, c.ID,
+		)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return from, to, tx.Commit()
+}
+
+// ListUptimeResults 某个 check 自 since 起的探测结果，最新的在前
+func ListUptimeResults(ctx context.Context, checkID int, since time.Time, limit int) ([]*UptimeResult, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT id, check_id, ok, status_code, latency_ms, error, checked_at
+		 FROM uptime_results WHERE check_id = $1 AND checked_at >= $2
+		 ORDER BY checked_at DESC LIMIT $3`,
+		checkID, since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*UptimeResult
+	for rows.Next() {
+		var r UptimeResult
+		if err := rows.Scan(&r.ID, &r.CheckID, &r.OK, &r.StatusCode, &r.LatencyMs, &r.Error, &r.CheckedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, &r)
+	}
+	return results, rows.Err()
+}
+
+// UptimeStatsSince 按 check 汇总自 since 起的可用率与平均延迟，没有探测的 check 不在结果里
+func UptimeStatsSince(ctx context.Context, checkIDs []int, since time.Time) (map[int]UptimeStats, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	ids := make([]int64, len(checkIDs))
+	for i, id := range checkIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := DB.QueryContext(ctx,
+		`SELECT check_id, COUNT(*), COUNT(*) FILTER (WHERE ok), COALESCE(AVG(latency_ms) FILTER (WHERE ok), 0)
+		 FROM uptime_results WHERE check_id = ANY($1) AND checked_at >= $2
+		 GROUP BY check_id`,
+		pq.Array(ids), since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[int]UptimeStats, len(checkIDs))
+	for rows.Next() {
+		var id, total, ok int
+		var avg float64
+		if err := rows.Scan(&id, &total, &ok, &avg); err != nil {
+			return nil, err
+		}
+		stats[id] = UptimeStats{Probes: total, UptimePercent: 100 * float64(ok) / float64(total), AvgLatencyMs: avg}
+	}
+	return stats, rows.Err()
+}
+
+// incidentQuery 事故带上 check 名和 open/resolved 状态，包成子查询以便复用 listQuery（created_at 即开始时间）
+const incidentQuery = `SELECT id, check_id, check_name, status, reason, started_at, resolved_at FROM (
+	SELECT i.id, i.check_id, c.name AS check_name, i.user_uid, c.public,
+	       CASE WHEN i.resolved_at IS NULL THEN 'open' ELSE 'resolved' END AS status,
+	       i.reason, i.started_at, i.resolved_at, i.started_at AS created_at
+	FROM uptime_incidents i JOIN uptime_checks c ON c.id = i.check_id
+) t WHERE user_uid = $1`
+
+func scanIncidents(rows *sql.Rows) ([]*UptimeIncident, error) {
+	defer rows.Close()
+	var incidents []*UptimeIncident
+	for rows.Next() {
+		var in UptimeIncident
+		if err := rows.Scan(&in.ID, &in.CheckID, &in.CheckName, &in.Status, &in.Reason, &in.StartedAt, &in.ResolvedAt); err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, &in)
+	}
+	return incidents, rows.Err()
+}
+
+// ListUptimeIncidents 分页获取用户的事故，checkID > 0 时只看该 check；status 过滤 open/resolved
+func ListUptimeIncidents(ctx context.Context, userUID string, checkID int, opts ListOptions) ([]*UptimeIncident, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	base := incidentQuery
+	args := []any{userUID}
+	if checkID > 0 {
+		args = append(args, checkID)
+		base += fmt.Sprintf(" AND check_id = $%d", len(args))
+	}
+	query, args, err := opts.listQuery(base, args, "check_name")
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	incidents, err := scanIncidents(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	incidents, next := trimPage(incidents, &opts, func(in *UptimeIncident) (string, int) {
+		return opts.cursorValue(in.StartedAt, in.CheckName), in.ID
+	})
+	return incidents, next, nil
+}
+
+// ListPublicUptimeChecks 用户已启用且公开的拨测，用于状态页
+func ListPublicUptimeChecks(ctx context.Context, userUID string) ([]*UptimeCheck, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT `+uptimeCheckColumns+` FROM uptime_checks WHERE user_uid = $1 AND public AND enabled ORDER BY name, id`, userUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanUptimeChecks(rows)
+}
+
+// ListPublicUptimeIncidents 公开拨测自 since 起的事故，最新的在前
+func ListPublicUptimeIncidents(ctx context.Context, userUID string, since time.Time, limit int) ([]*UptimeIncident, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		incidentQuery+` AND public AND (resolved_at IS NULL OR resolved_at >= $2) ORDER BY started_at DESC, id DESC LIMIT $3`,
+		userUID, since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanIncidents(rows)
+}
+
+// PruneUptimeResults 删除 before 之前的探测结果和已结束的事故
+func PruneUptimeResults(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := DB.ExecContext(ctx, `DELETE FROM uptime_results WHERE checked_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := DB.ExecContext(ctx, `DELETE FROM uptime_incidents WHERE resolved_at < $1`, before); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetStatusPage 获取用户的状态页设置
+func GetStatusPage(ctx context.Context, userUID string) (*StatusPage, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var p StatusPage
+	err := DB.QueryRowContext(ctx,
+		`SELECT user_uid, slug, title, enabled, created_at, updated_at FROM status_pages WHERE user_uid = $1`, userUID,
+	).Scan(&p.UserUID, &p.Slug, &p.Title, &p.Enabled, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetStatusPageBySlug 按 slug 获取已启用的状态页
+func GetStatusPageBySlug(ctx context.Context, slug string) (*StatusPage, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var p StatusPage
+	err := readRow(ctx,
+		`SELECT user_uid, slug, title, enabled, created_at, updated_at FROM status_pages WHERE slug = $1 AND enabled`, slug,
+	).Scan(&p.UserUID, &p.Slug, &p.Title, &p.Enabled, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpsertStatusPage 创建或更新用户的状态页，slug 冲突时返回 ErrSlugTaken
+func UpsertStatusPage(ctx context.Context, p *StatusPage) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err := DB.QueryRowContext(ctx,
+		`INSERT INTO status_pages (user_uid, slug, title, enabled) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_uid) DO UPDATE SET slug = EXCLUDED.slug, title = EXCLUDED.title, enabled = EXCLUDED.enabled
+		 RETURNING created_at, updated_at`,
+		p.UserUID, p.Slug, p.Title, p.Enabled,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrSlugTaken
+	}
+	return err
+}
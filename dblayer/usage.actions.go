@@ -0,0 +1,138 @@
+package dblayer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 计量指标。资源占用类的量都按采样间隔积分，单位里带 seconds
+const (
+	UsageCPUSeconds         = "cpu_core_seconds"      // worker 就绪副本的 CPU request × 秒
+	UsageMemoryByteSeconds  = "memory_byte_seconds"   // worker 就绪副本的内存 request × 秒
+	UsageStorageByteSeconds = "storage_byte_seconds"  // RDB/KV/bucket 已用字节 × 秒
+	UsageEgressBytes        = "egress_bytes"          // worker 出流量（Traefik 响应字节数）
+	UsageDomainSeconds      = "custom_domain_seconds" // 已验证自定义域名 × 秒
+)
+
+// UsageMetrics 所有计量指标，汇总时按这个顺序输出
+var UsageMetrics = []string{UsageCPUSeconds, UsageMemoryByteSeconds, UsageStorageByteSeconds, UsageEgressBytes, UsageDomainSeconds}
+
+// UsageSample 一个资源在一个采样区间内的用量
+type UsageSample struct {
+	UserUID      string
+	ResourceType string // worker | rdb | kv | bucket | domain
+	ResourceID   string
+	Metric       string
+	Quantity     float64
+}
+
+// UsageTotal 一段时间内某个资源某项指标的合计
+type UsageTotal struct {
+	ResourceType string  `json:"resource_type"`
+	ResourceID   string  `json:"resource_id"`
+	Metric       string  `json:"metric"`
+	Quantity     float64 `json:"quantity"`
+}
+
+// StorageUsage RDB/KV/bucket 资源当前的已用字节（RDB/KV 是 combinator 上报的 datachange 之和，bucket 是最近一次测量的大小）
+type StorageUsage struct {
+	UserUID      string
+	ResourceType string
+	ResourceID   string
+	Bytes        int64
+}
+
+// RecordUsage 批量写入 [start, end) 区间的用量，数量为 0 的不写
+func RecordUsage(ctx context.Context, start, end time.Time, samples []UsageSample) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	const batch = 500
+	for len(samples) > 0 {
+		n := min(len(samples), batch)
+		var sb strings.Builder
+		sb.WriteString(`INSERT INTO usage_samples (user_uid, resource_type, resource_id, metric, quantity, period_start, period_end) VALUES `)
+		values := make([]any, 0, n*5+2)
+		values = append(values, start, end)
+		rows := 0
+		for _, s := range samples[:n] {
+			if s.Quantity == 0 {
+				continue
+			}
+			if rows > 0 {
+				sb.WriteString(", ")
+			}
+			p := len(values)
+			fmt.Fprintf(&sb, "($%d, $%d, $%d, $%d, $%d, $1, $2)", p+1, p+2, p+3, p+4, p+5)
+			values = append(values, s.UserUID, s.ResourceType, s.ResourceID, s.Metric, s.Quantity)
+			rows++
+		}
+		if rows > 0 {
+			if _, err := DB.ExecContext(ctx, sb.String(), values...); err != nil {
+				return err
+			}
+		}
+		samples = samples[n:]
+	}
+	return nil
+}
+
+// UsageTotals 用户在 [from, to) 内按资源和指标汇总的用量，按采样区间的开始时间归属
+func UsageTotals(ctx context.Context, userUID string, from, to time.Time) ([]UsageTotal, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT resource_type, resource_id, metric, SUM(quantity)
+		 FROM usage_samples
+		 WHERE user_uid = $1 AND period_start >= $2 AND period_start < $3
+		 GROUP BY resource_type, resource_id, metric
+		 ORDER BY resource_type, resource_id, metric`,
+		userUID, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []UsageTotal{}
+	for rows.Next() {
+		var t UsageTotal
+		if err := rows.Scan(&t.ResourceType, &t.ResourceID, &t.Metric, &t.Quantity); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// ListStorageUsage 所有 active 的 RDB/KV/bucket 资源及其已用字节
+func ListStorageUsage(ctx context.Context) ([]StorageUsage, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT r.user_uid, r.resource_type, r.resource_id, GREATEST(COALESCE(SUM(rep.datachange), 0), 0)
+		 FROM combinator_resources r
+		 LEFT JOIN combinator_resource_reports rep ON rep.resource_id = r.id
+		 WHERE r.status = 'active' AND r.resource_type <> 'bucket'
+		 GROUP BY r.id
+		 UNION ALL
+		 SELECT r.user_uid, r.resource_type, r.resource_id, b.size_bytes
+		 FROM object_buckets b JOIN combinator_resources r ON r.id = b.resource_id
+		 WHERE r.status = 'active'`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StorageUsage
+	for rows.Next() {
+		var s StorageUsage
+		if err := rows.Scan(&s.UserUID, &s.ResourceType, &s.ResourceID, &s.Bytes); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -1,26 +1,42 @@
 package dblayer
 
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
 // ========== Worker 基础操作 ==========
 
-// CreateWorker 创建 worker 记录
-func CreateWorker(wid, userUID, workerName, assignedCPU, assignedMemory, assignedDisk string, maxReplicas int, mainRegion string) error {
+// CreateWorker 在 q 里创建 worker 记录，environment 为空时属于 DefaultEnvironment，kind 为空时是 container
+func CreateWorker(ctx context.Context, q Querier, wid, userUID, workerName, assignedCPU, assignedMemory, assignedDisk string, maxReplicas int, mainRegion, cluster, pool, arch string, runAsRoot, writableRootFS bool, tags Tags, environment, kind string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var id int
-	return DB.QueryRow(
-		`INSERT INTO workers (wid, user_uid, worker_name, assigned_cpu, assigned_memory, assigned_disk, max_replicas, main_region)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
-		wid, userUID, workerName, assignedCPU, assignedMemory, assignedDisk, maxReplicas, mainRegion,
+	return q.QueryRowContext(ctx,
+		`INSERT INTO workers (wid, user_uid, worker_name, assigned_cpu, assigned_memory, assigned_disk, max_replicas, main_region, cluster, pool, arch, run_as_root, writable_root_fs, tags, environment, kind)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`,
+		wid, userUID, workerName, assignedCPU, assignedMemory, assignedDisk, maxReplicas, mainRegion, cluster, pool, arch, runAsRoot, writableRootFS, tags, cmp.Or(environment, DefaultEnvironment), cmp.Or(kind, WorkerKindContainer),
 	).Scan(&id)
 }
 
-// ListWorkersByUser 获取用户的所有 worker
-func ListWorkersByUser(userUID string) ([]*Worker, error) {
-	rows, err := DB.Query(
+// ListWorkersByUser 分页获取用户的 worker，返回下一页游标
+func ListWorkersByUser(ctx context.Context, userUID string, opts ListOptions) ([]*Worker, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	query, args, err := opts.listQuery(
 		`SELECT id, wid, user_uid, worker_name, status, active_version_id, env_json, secrets_json,
-		        assigned_cpu, assigned_memory, assigned_disk, max_replicas, main_region, created_at
-		 FROM workers WHERE user_uid = $1 ORDER BY created_at DESC`, userUID,
+		        assigned_cpu, assigned_memory, assigned_disk, max_replicas, main_region, cluster, pool, arch, run_as_root, writable_root_fs, tags, environment, kind, created_at, updated_at, version
+		 FROM workers WHERE user_uid = $1`, []any{userUID}, "worker_name",
 	)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	rows, err := readRows(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
 
@@ -28,19 +44,123 @@ func ListWorkersByUser(userUID string) ([]*Worker, error) {
 	for rows.Next() {
 		var w Worker
 		if err := rows.Scan(&w.ID, &w.WID, &w.UserUID, &w.WorkerName, &w.Status, &w.ActiveVersionID, &w.EnvJSON, &w.SecretsJSON,
-			&w.AssignedCPU, &w.AssignedMemory, &w.AssignedDisk, &w.MaxReplicas, &w.MainRegion, &w.CreatedAt); err != nil {
+			&w.AssignedCPU, &w.AssignedMemory, &w.AssignedDisk, &w.MaxReplicas, &w.MainRegion, &w.Cluster, &w.Pool, &w.Arch, &w.RunAsRoot, &w.WritableRootFS, &w.Tags, &w.Environment, &w.Kind, &w.CreatedAt, &w.UpdatedAt, &w.Version); err != nil {
+			return nil, "", err
+		}
+		workers = append(workers, &w)
+	}
+	workers, next := trimPage(workers, &opts, func(w *Worker) (string, int) {
+		return opts.cursorValue(w.CreatedAt, w.WorkerName), w.ID
+	})
+	return workers, next, nil
+}
+
+// UpdateWorkerSpecByOwner 更新 worker 的资源配置，验证归属
+func UpdateWorkerSpecByOwner(ctx context.Context, wid, userUID, assignedCPU, assignedMemory, assignedDisk string, maxReplicas int, mainRegion, pool, arch string, runAsRoot, writableRootFS bool) error {
+	return execOwned(ctx,
+		`UPDATE workers SET assigned_cpu = $1, assigned_memory = $2, assigned_disk = $3, max_replicas = $4, main_region = $5, pool = $6, arch = $7,
+		        run_as_root = $8, writable_root_fs = $9, version = version + 1
+		 WHERE wid = $10 AND user_uid = $11`,
+		assignedCPU, assignedMemory, assignedDisk, maxReplicas, mainRegion, pool, arch, runAsRoot, writableRootFS, wid, userUID,
+	)
+}
+
+// SetWorkerCluster 记录 worker 被调度到的集群，只在尚未调度时写入，返回最终生效的集群
+func SetWorkerCluster(ctx context.Context, wid, userUID, cluster string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var placed string
+	err := DB.QueryRowContext(ctx,
+		`UPDATE workers SET cluster = CASE WHEN cluster = '' THEN $1 ELSE cluster END
+		 WHERE wid = $2 AND user_uid = $3 RETURNING cluster`,
+		cluster, wid, userUID,
+	).Scan(&placed)
+	return placed, err
+}
+
+// CountWorkersByCluster 统计每个集群上已调度的 worker 数
+func CountWorkersByCluster(ctx context.Context) (map[string]int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT cluster, COUNT(*) FROM workers WHERE cluster <> '' GROUP BY cluster`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var cluster string
+		var n int
+		if err := rows.Scan(&cluster, &n); err != nil {
+			return nil, err
+		}
+		counts[cluster] = n
+	}
+	return counts, rows.Err()
+}
+
+// DeployedWorker 已部署的 worker 及其当前版本，用于和集群中的实际资源比对
+type DeployedWorker struct {
+	WID       string
+	UserUID   string
+	Cluster   string
+	VersionID int
+	Image     string
+	Port      int
+}
+
+// ListDeployedWorkers 列出所有有 active 版本的 worker
+func ListDeployedWorkers(ctx context.Context) ([]*DeployedWorker, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT w.wid, w.user_uid, w.cluster, v.id, v.image, v.port
+		 FROM workers w
+		 JOIN worker_deploy_versions v ON v.id = w.active_version_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workers []*DeployedWorker
+	for rows.Next() {
+		var w DeployedWorker
+		if err := rows.Scan(&w.WID, &w.UserUID, &w.Cluster, &w.VersionID, &w.Image, &w.Port); err != nil {
 			return nil, err
 		}
 		workers = append(workers, &w)
 	}
-	return workers, nil
+	return workers, rows.Err()
 }
 
 // ========== DeployVersion 操作 ==========
 
+// GetDeployVersion 获取单个部署版本
+func GetDeployVersion(ctx context.Context, versionID int) (*WorkerDeployVersion, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	var v WorkerDeployVersion
+	var static []byte
+	err := DB.QueryRowContext(ctx,
+		`SELECT id, worker_id, image, port, status, msg, commit_sha, created_at, static_source FROM worker_deploy_versions WHERE id = $1`,
+		versionID,
+	).Scan(&v.ID, &v.WorkerID, &v.Image, &v.Port, &v.Status, &v.Msg, &v.CommitSHA, &v.CreatedAt, &static)
+	if err != nil {
+		return nil, err
+	}
+	if v.Static, err = unmarshalStaticSource(static); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
 // UpdateDeployVersionStatus 更新部署版本状态和消息
-func UpdateDeployVersionStatus(versionID int, status, msg string) error {
-	_, err := DB.Exec(
+func UpdateDeployVersionStatus(ctx context.Context, versionID int, status, msg string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
 		`UPDATE worker_deploy_versions SET status = $1, msg = $2 WHERE id = $3`,
 		status, msg, versionID,
 	)
@@ -48,9 +168,11 @@ func UpdateDeployVersionStatus(versionID int, status, msg string) error {
 }
 
 // ListDeployVersions 获取 worker 的部署版本，支持分页
-func ListDeployVersions(workerID int, limit, offset int) ([]*WorkerDeployVersion, error) {
-	rows, err := DB.Query(
-		`SELECT id, worker_id, image, port, status, msg, created_at
+func ListDeployVersions(ctx context.Context, workerID int, limit, offset int) ([]*WorkerDeployVersion, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	rows, err := readRows(ctx,
+		`SELECT `+deployVersionColumns+`
 		 FROM worker_deploy_versions WHERE worker_id = $1
 		 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
 		workerID, limit, offset,
@@ -62,11 +184,11 @@ func ListDeployVersions(workerID int, limit, offset int) ([]*WorkerDeployVersion
 
 	var versions []*WorkerDeployVersion
 	for rows.Next() {
-		var v WorkerDeployVersion
-		if err := rows.Scan(&v.ID, &v.WorkerID, &v.Image, &v.Port, &v.Status, &v.Msg, &v.CreatedAt); err != nil {
+		v, err := scanDeployVersion(rows)
+		if err != nil {
 			return nil, err
 		}
-		versions = append(versions, &v)
+		versions = append(versions, v)
 	}
 	return versions, nil
 }
@@ -74,75 +196,80 @@ func ListDeployVersions(workerID int, limit, offset int) ([]*WorkerDeployVersion
 // ========== Worker 组合查询 ==========
 
 // GetWorkerByOwner 验证 worker 归属并返回，单次查询
-func GetWorkerByOwner(wid, userUID string) (*Worker, error) {
+func GetWorkerByOwner(ctx context.Context, wid, userUID string) (*Worker, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var w Worker
-	err := DB.QueryRow(
+	err := DB.QueryRowContext(ctx,
 		`SELECT id, wid, user_uid, worker_name, status, active_version_id, env_json, secrets_json,
-		        assigned_cpu, assigned_memory, assigned_disk, max_replicas, main_region, created_at
+		        assigned_cpu, assigned_memory, assigned_disk, max_replicas, main_region, cluster, pool, arch, run_as_root, writable_root_fs, tags, environment, kind, created_at, updated_at, version
 		 FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID,
 	).Scan(&w.ID, &w.WID, &w.UserUID, &w.WorkerName, &w.Status, &w.ActiveVersionID, &w.EnvJSON, &w.SecretsJSON,
-		&w.AssignedCPU, &w.AssignedMemory, &w.AssignedDisk, &w.MaxReplicas, &w.MainRegion, &w.CreatedAt)
+		&w.AssignedCPU, &w.AssignedMemory, &w.AssignedDisk, &w.MaxReplicas, &w.MainRegion, &w.Cluster, &w.Pool, &w.Arch, &w.RunAsRoot, &w.WritableRootFS, &w.Tags, &w.Environment, &w.Kind, &w.CreatedAt, &w.UpdatedAt, &w.Version)
 	if err != nil {
 		return nil, err
 	}
 	return &w, nil
 }
 
-// GetWorkerEnvByOwner 验证归属并返回 env_json，单次查询
-func GetWorkerEnvByOwner(wid, userUID string) (string, error) {
+// GetWorkerEnvByOwner 验证归属并返回 env_json 和当前版本，单次查询
+func GetWorkerEnvByOwner(ctx context.Context, wid, userUID string) (string, int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var envJSON string
-	err := DB.QueryRow(
-		`SELECT env_json FROM workers WHERE wid = $1 AND user_uid = $2`,
+	var version int
+	err := DB.QueryRowContext(ctx,
+		`SELECT env_json, version FROM workers WHERE wid = $1 AND user_uid = $2`,
 		wid, userUID,
-	).Scan(&envJSON)
-	return envJSON, err
+	).Scan(&envJSON, &version)
+	return envJSON, version, err
 }
 
-// SetWorkerEnvByOwner 验证归属并更新 env_json，单次操作
-func SetWorkerEnvByOwner(wid, userUID, envJSON string) error {
-	res, err := DB.Exec(
-		`UPDATE workers SET env_json = $1, status = 'loading' WHERE wid = $2 AND user_uid = $3`,
-		envJSON, wid, userUID,
-	)
-	if err != nil {
-		return err
-	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return ErrNotFound
-	}
-	return nil
+// SetWorkerEnvByOwner 在 q 里验证归属并更新 env_json，expectedVersion 为 0 时不检查版本，返回新版本
+func SetWorkerEnvByOwner(ctx context.Context, q Querier, wid, userUID, envJSON string, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, q,
+		`UPDATE workers SET env_json = $1, status = 'loading', version = version + 1
+		 WHERE wid = $2 AND user_uid = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		[]any{envJSON, wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
 }
 
-// GetWorkerSecretsByOwner 验证归属并返回 secrets_json，单次查询
-func GetWorkerSecretsByOwner(wid, userUID string) (string, error) {
+// GetWorkerSecretsByOwner 验证归属并返回 secrets_json 和当前版本，单次查询
+func GetWorkerSecretsByOwner(ctx context.Context, wid, userUID string) (string, int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var secretsJSON string
-	err := DB.QueryRow(
-		`SELECT secrets_json FROM workers WHERE wid = $1 AND user_uid = $2`,
+	var version int
+	err := DB.QueryRowContext(ctx,
+		`SELECT secrets_json, version FROM workers WHERE wid = $1 AND user_uid = $2`,
 		wid, userUID,
-	).Scan(&secretsJSON)
-	return secretsJSON, err
+	).Scan(&secretsJSON, &version)
+	return secretsJSON, version, err
 }
 
-// SetWorkerSecretsByOwner 验证归属并更新 secrets_json，单次操作
-func SetWorkerSecretsByOwner(wid, userUID, secretsJSON string) error {
-	res, err := DB.Exec(
-		`UPDATE workers SET secrets_json = $1, status = 'loading' WHERE wid = $2 AND user_uid = $3`,
-		secretsJSON, wid, userUID,
-	)
-	if err != nil {
-		return err
-	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return ErrNotFound
-	}
-	return nil
+// SetWorkerSecretsByOwner 在 q 里验证归属并更新 secrets_json，expectedVersion 为 0 时不检查版本，返回新版本
+func SetWorkerSecretsByOwner(ctx context.Context, q Querier, wid, userUID, secretsJSON string, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, q,
+		`UPDATE workers SET secrets_json = $1, status = 'loading', version = version + 1
+		 WHERE wid = $2 AND user_uid = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		[]any{secretsJSON, wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
 }
 
-// DeleteWorkerByOwner 验证归属并删除 worker，单次操作
-func DeleteWorkerByOwner(wid, userUID string) error {
-	res, err := DB.Exec(
+// SetWorkerEnvAndSecretsByOwner 在 q 里验证归属并同时更新 env_json 和 secrets_json，只加一次版本，版本处理同 SetWorkerEnvByOwner
+func SetWorkerEnvAndSecretsByOwner(ctx context.Context, q Querier, wid, userUID, envJSON, secretsJSON string, expectedVersion int) (int, error) {
+	return updateVersioned(ctx, q,
+		`UPDATE workers SET env_json = $1, secrets_json = $2, status = 'loading', version = version + 1
+		 WHERE wid = $3 AND user_uid = $4 AND ($5 = 0 OR version = $5) RETURNING version`,
+		[]any{envJSON, secretsJSON, wid, userUID, expectedVersion},
+		`SELECT version FROM workers WHERE wid = $1 AND user_uid = $2`, wid, userUID)
+}
+
+// DeleteWorkerByOwner 在 q 里验证归属并删除 worker，单次操作
+func DeleteWorkerByOwner(ctx context.Context, q Querier, wid, userUID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	res, err := q.ExecContext(ctx,
 		`DELETE FROM workers WHERE wid = $1 AND user_uid = $2`,
 		wid, userUID,
 	)
@@ -156,70 +283,97 @@ func DeleteWorkerByOwner(wid, userUID string) error {
 	return nil
 }
 
-// CreateDeployVersionForOwner 验证 worker 归属后创建部署版本，返回 version id
-func CreateDeployVersionForOwner(wid, userUID, image string, port int) (int, error) {
-	tx, err := DB.Begin()
+// CreateDeployVersionForOwner 验证 worker 归属后创建部署版本，返回 version id；commitSHA 可为空。
+// 同时保存 worker 此刻配置的快照，供版本之间比较
+func CreateDeployVersionForOwner(ctx context.Context, tx *sql.Tx, wid, userUID, image string, port int, commitSHA, initiator string, static *StaticSource) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	// 验证归属并设 status=loading，同时获取 worker id 和快照需要的列
+	var w Worker
+	err := tx.QueryRowContext(ctx,
+		`UPDATE workers SET status = 'loading' WHERE wid = $1 AND user_uid = $2
+		 RETURNING id, env_json, secrets_json, assigned_cpu, assigned_memory, assigned_disk, max_replicas,
+		           main_region, cluster, pool, arch, run_as_root, writable_root_fs, kind`,
+		wid, userUID,
+	).Scan(&w.ID, &w.EnvJSON, &w.SecretsJSON, &w.AssignedCPU, &w.AssignedMemory, &w.AssignedDisk, &w.MaxReplicas,
+		&w.MainRegion, &w.Cluster, &w.Pool, &w.Arch, &w.RunAsRoot, &w.WritableRootFS, &w.Kind)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
 	if err != nil {
 		return 0, err
 	}
-	defer tx.Rollback()
-
-	// 验证归属并设 status=loading，同时获取 worker id
-	var workerID int
-	err = tx.QueryRow(
-		`UPDATE workers SET status = 'loading' WHERE wid = $1 AND user_uid = $2 RETURNING id`,
-		wid, userUID,
-	).Scan(&workerID)
+	if (w.Kind == WorkerKindStatic) != (static != nil) {
+		return 0, ErrWorkerKind
+	}
+	snapshot, err := takeDeploySnapshot(&w)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("snapshot worker config: %w", err)
 	}
-
-	var id int
-	err = tx.QueryRow(
-		`INSERT INTO worker_deploy_versions (worker_id, image, port, status)
-		 VALUES ($1, $2, $3, 'loading') RETURNING id`,
-		workerID, image, port,
-	).Scan(&id)
+	data, err := json.Marshal(snapshot)
 	if err != nil {
 		return 0, err
 	}
+	var staticData []byte
+	if static != nil {
+		if staticData, err = json.Marshal(static); err != nil {
+			return 0, err
+		}
+	}
 
-	return id, tx.Commit()
+	var id int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO worker_deploy_versions (worker_id, image, port, status, commit_sha, initiator, snapshot, static_source)
+		 VALUES ($1, $2, $3, 'loading', $4, $5, $6, $7) RETURNING id`,
+		w.ID, image, port, commitSHA, initiator, data, staticData,
+	).Scan(&id)
+	return id, err
 }
 
 // GetDeployVersionWithWorker 获取部署版本及其关联的 worker，两表 JOIN 单次查询
-func GetDeployVersionWithWorker(versionID int) (*WorkerDeployVersion, *Worker, string, error) {
+func GetDeployVersionWithWorker(ctx context.Context, versionID int) (*WorkerDeployVersion, *Worker, string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 	var v WorkerDeployVersion
 	var w Worker
 	var userSK string
-	err := DB.QueryRow(
-		`SELECT v.id, v.worker_id, v.image, v.port, v.status, v.msg, v.created_at, u.secret_key,
+	var static []byte
+	err := DB.QueryRowContext(ctx,
+		`SELECT v.id, v.worker_id, v.image, v.port, v.status, v.msg, v.commit_sha, v.created_at, v.static_source, u.secret_key,
 		        w.id, w.wid, w.user_uid, w.worker_name, w.status, w.active_version_id, w.env_json, w.secrets_json,
-		        w.assigned_cpu, w.assigned_memory, w.assigned_disk, w.max_replicas, w.main_region, w.created_at
+		        w.assigned_cpu, w.assigned_memory, w.assigned_disk, w.max_replicas, w.main_region, w.cluster, w.pool, w.arch, w.run_as_root, w.writable_root_fs, w.tags, w.environment, w.kind, w.created_at, w.updated_at, w.version
 		 FROM worker_deploy_versions v
 		 JOIN workers w ON w.id = v.worker_id
 		 JOIN users u ON u.uid = w.user_uid
 		 WHERE v.id = $1`, versionID,
 	).Scan(
-		&v.ID, &v.WorkerID, &v.Image, &v.Port, &v.Status, &v.Msg, &v.CreatedAt, &userSK,
+		&v.ID, &v.WorkerID, &v.Image, &v.Port, &v.Status, &v.Msg, &v.CommitSHA, &v.CreatedAt, &static, &userSK,
 		&w.ID, &w.WID, &w.UserUID, &w.WorkerName, &w.Status, &w.ActiveVersionID, &w.EnvJSON, &w.SecretsJSON,
-		&w.AssignedCPU, &w.AssignedMemory, &w.AssignedDisk, &w.MaxReplicas, &w.MainRegion, &w.CreatedAt,
+		&w.AssignedCPU, &w.AssignedMemory, &w.AssignedDisk, &w.MaxReplicas, &w.MainRegion, &w.Cluster, &w.Pool, &w.Arch, &w.RunAsRoot, &w.WritableRootFS, &w.Tags, &w.Environment, &w.Kind, &w.CreatedAt, &w.UpdatedAt, &w.Version,
 	)
 	if err != nil {
 		return nil, nil, "", err
 	}
+	if userSK, err = openColumn(colUserSecretKey, userSK); err != nil {
+		return nil, nil, "", err
+	}
+	if v.Static, err = unmarshalStaticSource(static); err != nil {
+		return nil, nil, "", err
+	}
 	return &v, &w, userSK, nil
 }
 
 // DeployVersionSuccess 部署成功：更新 version status + 设置 active_version_id，单次事务
-func DeployVersionSuccess(versionID, workerID int) error {
-	tx, err := DB.Begin()
+func DeployVersionSuccess(ctx context.Context, versionID, workerID int) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		`UPDATE worker_deploy_versions SET status = 'success', msg = '' WHERE id = $1`,
 		versionID,
 	)
@@ -227,7 +381,7 @@ func DeployVersionSuccess(versionID, workerID int) error {
 		return err
 	}
 
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		`UPDATE workers SET active_version_id = $1, status = 'active' WHERE id = $2`,
 		versionID, workerID,
 	)
@@ -239,8 +393,10 @@ func DeployVersionSuccess(versionID, workerID int) error {
 }
 
 // UpdateWorkerStatus 更新 worker 状态
-func UpdateWorkerStatus(wid, status string) error {
-	_, err := DB.Exec(
+func UpdateWorkerStatus(ctx context.Context, wid, status string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	_, err := DB.ExecContext(ctx,
 		`UPDATE workers SET status = $1 WHERE wid = $2`,
 		status, wid,
 	)
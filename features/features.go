@@ -0,0 +1,73 @@
+// Package features evaluates feature flags per user. The flags live in the
+// feature_flags table; every instance keeps a snapshot loaded with Load and
+// replaced on each change notification, so Enabled never hits the database.
+package features
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// Flag is one feature flag. Users overrides everything for the users it
+// lists; otherwise the flag is on for everyone when Enabled, or for the
+// RolloutPercent of users whose bucket falls below it.
+type Flag struct {
+	Key            string
+	Enabled        bool
+	RolloutPercent int
+	Users          map[string]bool
+}
+
+var snapshot atomic.Pointer[map[string]Flag]
+
+// Load replaces the snapshot of all flags
+func Load(flags []Flag) {
+	m := make(map[string]Flag, len(flags))
+	for _, f := range flags {
+		m[f.Key] = f
+	}
+	snapshot.Store(&m)
+}
+
+func current() map[string]Flag {
+	if m := snapshot.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// Enabled reports whether key is on for uid; unknown flags are off
+func Enabled(key, uid string) bool {
+	f, ok := current()[key]
+	return ok && f.enabledFor(uid)
+}
+
+// For evaluates every flag for uid, by key
+func For(uid string) map[string]bool {
+	flags := current()
+	out := make(map[string]bool, len(flags))
+	for key, f := range flags {
+		out[key] = f.enabledFor(uid)
+	}
+	return out
+}
+
+func (f Flag) enabledFor(uid string) bool {
+	if on, ok := f.Users[uid]; ok {
+		return on
+	}
+	if f.Enabled {
+		return true
+	}
+	return uid != "" && Bucket(f.Key, uid) < f.RolloutPercent
+}
+
+// Bucket places uid in [0, 100) for key. It is stable, so raising the
+// rollout percent only adds users, and independent between flags.
+func Bucket(key, uid string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(uid))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 访问日志：Traefik 把 JSON 访问日志写到 ingress 节点上，日志 agent 按行批量 POST 给 inner，
+// inner 按路由器名找到 worker 写库；outer 按 worker 查询请求明细和小时汇总
+
+const (
+	// DefaultRequestsWindow 请求明细和小时汇总默认看多久
+	DefaultRequestsWindow = 24 * time.Hour
+
+	maxAccessLogPath = 2048
+)
+
+// traefikAccessLog Traefik JSON 访问日志里用到的字段
+type traefikAccessLog struct {
+	RouterName       string `json:"RouterName"`
+	StartUTC         string `json:"StartUTC"`
+	Duration         int64  `json:"Duration"` // 纳秒
+	DownstreamStatus int    `json:"DownstreamStatus"`
+	RequestMethod    string `json:"RequestMethod"`
+	RequestHost      string `json:"RequestHost"`
+	RequestPath      string `json:"RequestPath"`
+	ClientHost       string `json:"ClientHost"`
+}
+
+// routerWorker 从 Traefik 路由器名解析出 worker ID。worker 的路由在 IngressNamespace 下名为
+// w-<wid>-<uid>，路由器名形如 <namespace>-w-<wid>-<uid>-<hash>@kubernetescrd；
+// 自定义域名和控制台自己的路由不属于某个 worker，返回 false
+func routerWorker(router string) (string, bool) {
+	router, _, _ = strings.Cut(router, "@")
+	rest, ok := strings.CutPrefix(router, k8s.IngressNamespace+"-w-")
+	if !ok {
+		return "", false
+	}
+	wid, _, ok := strings.Cut(rest, "-")
+	return wid, ok && wid != ""
+}
+
+// IngestAccessLogs POST /api/v1/access-logs (inner) 日志 agent 上报 Traefik 访问日志，
+// 请求体是每行一条的 JSON，大小受 DefaultBodyLimit 限制，不属于 worker 或无法解析的行被跳过
+func IngestAccessLogs(c *gin.Context) {
+	var reqs []dblayer.WorkerRequest
+	skipped := 0
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 64<<10), int(DefaultBodyLimit))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var l traefikAccessLog
+		if err := json.Unmarshal(line, &l); err != nil {
+			skipped++
+			continue
+		}
+		wid, ok := routerWorker(l.RouterName)
+		start, err := time.Parse(time.RFC3339Nano, l.StartUTC)
+		if !ok || err != nil || l.DownstreamStatus == 0 {
+			skipped++
+			continue
+		}
+		// 查询串里常有 token，只保留路径
+		path, _, _ := strings.Cut(l.RequestPath, "?")
+		reqs = append(reqs, dblayer.WorkerRequest{
+			WID:        wid,
+			Method:     clip(l.RequestMethod, 16),
+			Host:       clip(l.RequestHost, 255),
+			Path:       clip(path, maxAccessLogPath),
+			Status:     l.DownstreamStatus,
+			DurationMs: int(time.Duration(l.Duration).Milliseconds()),
+			ClientIP:   clip(l.ClientHost, 64),
+			CreatedAt:  start.UTC(),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		c.JSON(400, gin.H{"error": "invalid body: " + err.Error()})
+		return
+	}
+
+	n, err := dblayer.InsertWorkerRequests(c.Request.Context(), reqs)
+	if err != nil {
+		RequestLog(c).Error("insert access logs failed", "lines", len(reqs), "error", err)
+		c.JSON(500, gin.H{"error": "failed to store access logs"})
+		return
+	}
+	c.JSON(200, IngestAccessLogsResponse{Stored: n, Skipped: skipped + len(reqs) - n})
+}
+
+// clip 截断到列宽，日志字段来自客户端请求
+func clip(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// parseStatusFilter 解析 ?status=：状态码类别 2xx..5xx 或者一个具体的状态码
+func parseStatusFilter(v string) (lo, hi int, err error) {
+	if v == "" {
+		return 0, 0, nil
+	}
+	if len(v) == 3 && strings.HasSuffix(v, "xx") && v[0] >= '1' && v[0] <= '5' {
+		lo = int(v[0]-'0') * 100
+		return lo, lo + 99, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 100 || n > 599 {
+		return 0, 0, fmt.Errorf("status must be a class like 5xx or a status code")
+	}
+	return n, n, nil
+}
+
+// requestsSince 解析 ?since=，不能早于 retention
+func requestsSince(c *gin.Context, retention time.Duration) (time.Time, bool) {
+	now := time.Now().UTC()
+	since, err := parseLogTime(c.Query("since"), now, now.Add(-DefaultRequestsWindow))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid since: " + err.Error()})
+		return since, false
+	}
+	if now.Sub(since) > retention {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("since must be within %s", retention)})
+		return since, false
+	}
+	return since.UTC(), true
+}
+
+// ListWorkerRequests GET /api/worker/:id/requests 路由到 worker 的请求，最新的在前；
+// ?since= 为 RFC3339 时间或时长（默认 24h），?status= 为 5xx 这样的类别或具体状态码
+func (h *WorkerHandler) ListWorkerRequests(c *gin.Context) {
+	ctx := c.Request.Context()
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	filter := dblayer.RequestFilter{}
+	if filter.StatusMin, filter.StatusMax, err = parseStatusFilter(opts.Status); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if filter.Since, ok = requestsSince(c, jobs.AccessLogRetention); !ok {
+		return
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, c.Param("id")) {
+		return
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+
+	requests, next, err := dblayer.ListWorkerRequests(ctx, w.WID, filter, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("list worker requests failed", "worker_id", w.WID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to list requests"})
+		return
+	}
+	setNextCursor(c, next)
+	c.JSON(200, WorkerRequestsResponse{Requests: requests, NextCursor: next})
+}
+
+// WorkerRequestHours GET /api/worker/:id/requests/hourly 每小时的请求数、各类状态码和延迟，
+// ?since= 同上，默认 24h，最多到小时汇总的保留期
+func (h *WorkerHandler) WorkerRequestHours(c *gin.Context) {
+	ctx := c.Request.Context()
+	since, ok := requestsSince(c, jobs.AccessLogHourlyRetention)
+	if !ok {
+		return
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, c.Param("id")) {
+		return
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+
+	hours, err := dblayer.ListWorkerRequestHours(ctx, w.WID, since)
+	if err != nil {
+		RequestLog(c).Error("list worker request hours failed", "worker_id", w.WID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to load hourly requests"})
+		return
+	}
+	c.JSON(200, WorkerRequestHoursResponse{Since: since, Hours: hours})
+}
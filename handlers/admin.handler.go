@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// adminTopConsumers 总览中每项指标列出的用户数
+	adminTopConsumers = 10
+	// MaxAdminList /admin/tenants 和 /admin/failures 的 ?limit= 上限，默认 100
+	MaxAdminList = 500
+)
+
+// adminLimit 解析 ?limit=，默认 100
+func adminLimit(c *gin.Context) (int, bool) {
+	v := c.Query("limit")
+	if v == "" {
+		return 100, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > MaxAdminList {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", MaxAdminList)})
+		return 0, false
+	}
+	return n, true
+}
+
+// AdminOverview GET /admin/overview 全平台资源数量、本集群 worker 当前占用（informer 缓存）和本月用量最多的用户。
+// 缓存未同步时 cluster 为空并给出 cluster_error
+func AdminOverview(c *gin.Context) {
+	totals, err := dblayer.GetResourceTotals(c.Request.Context())
+	if err != nil {
+		RequestLog(c).Error("resource totals failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get resource totals"})
+		return
+	}
+	period, from, to, _ := parseUsagePeriod("", time.Now())
+	top, err := dblayer.TopUsageConsumers(c.Request.Context(), from, to, adminTopConsumers)
+	if err != nil {
+		RequestLog(c).Error("top consumers failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get top consumers"})
+		return
+	}
+
+	resp := AdminOverviewResponse{GeneratedAt: time.Now(), Totals: totals, Period: period, TopConsumers: top}
+	resp.Cluster, err = controller.CachedConsumption(adminTopConsumers)
+	if err != nil {
+		resp.ClusterError = err.Error()
+	}
+	c.JSON(200, resp)
+}
+
+// AdminTenants GET /admin/tenants 每个用户的资源数量，?sort= 见 dblayer.TenantSorts（降序）
+func AdminTenants(c *gin.Context) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(400, gin.H{"error": "invalid offset"})
+		return
+	}
+	sort := c.DefaultQuery("sort", "workers")
+	if _, ok := dblayer.TenantSorts[sort]; !ok {
+		c.JSON(400, gin.H{"error": "invalid sort"})
+		return
+	}
+	tenants, err := dblayer.ListTenantOverviews(c.Request.Context(), sort, limit, offset)
+	if err != nil {
+		RequestLog(c).Error("list tenants failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list tenants"})
+		return
+	}
+	c.JSON(200, AdminTenantsResponse{Tenants: tenants})
+}
+
+// AdminFailures GET /admin/failures 最近失败的任务（本实例启动以来）、部署和自定义域名
+func AdminFailures(c *gin.Context) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+	deploys, err := dblayer.ListRecentDeployFailures(c.Request.Context(), limit)
+	if err != nil {
+		RequestLog(c).Error("list deploy failures failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list failures"})
+		return
+	}
+	domains, err := dblayer.ListRecentDomainFailures(c.Request.Context(), limit)
+	if err != nil {
+		RequestLog(c).Error("list domain failures failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list failures"})
+		return
+	}
+	c.JSON(200, AdminFailuresResponse{Jobs: jobs.RecentJobFailures(limit), Deploys: deploys, Domains: domains})
+}
+
+// AdminJobStats GET /admin/jobs/stats 按任务类型统计本实例最近 ?window=（默认 1h，最多 24h）的执行情况
+func AdminJobStats(c *gin.Context) {
+	window := time.Hour
+	if v := c.Query("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > jobs.JobStatsRetention {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("window must be a duration up to %s", jobs.JobStatsRetention)})
+			return
+		}
+		window = d
+	}
+	c.JSON(200, AdminJobStatsResponse{Window: window.String(), Types: jobs.JobStats(window)})
+}
+
+// AdminJobLeases GET /admin/jobs/leases 正在执行、等待重新开始和已放弃的任务，?status= 过滤，最早开始的在前
+func AdminJobLeases(c *gin.Context) {
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", dblayer.JobLeaseRunning, dblayer.JobLeaseRequeued, dblayer.JobLeaseAbandoned:
+	default:
+		c.JSON(400, gin.H{"error": "invalid status"})
+		return
+	}
+	leases, err := dblayer.ListJobLeases(c.Request.Context(), status, limit)
+	if err != nil {
+		RequestLog(c).Error("list job leases failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list job leases"})
+		return
+	}
+	c.JSON(200, AdminJobLeasesResponse{Leases: leases})
+}
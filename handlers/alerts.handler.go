@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertsHandler 告警规则的增删改查与告警历史。规则只写数据库，由 inner 每分钟评估
+type AlertsHandler struct{}
+
+func NewAlertsHandler() *AlertsHandler {
+	return &AlertsHandler{}
+}
+
+// ListRules 分页列出告警规则，?worker_id= 只看某个 worker，?status=firing 只看正在告警的
+func (h *AlertsHandler) ListRules(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, next, err := dblayer.ListAlertRules(c.Request.Context(), userUID, c.Query("worker_id"), opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list alert rules"})
+		return
+	}
+	if rules == nil {
+		rules = []*dblayer.AlertRule{}
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListAlertRulesResponse{Rules: rules, NextCursor: next})
+}
+
+// CreateRule 为自己的 worker 创建告警规则
+func (h *AlertsHandler) CreateRule(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, req.WorkerID) {
+		return
+	}
+
+	rule := alertRuleFromRequest(userUID, &req)
+	if err := dblayer.CreateAlertRule(c.Request.Context(), rule); err != nil {
+		RequestLog(c).Error("create alert rule failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create alert rule"})
+		return
+	}
+
+	c.JSON(200, rule)
+}
+
+// GetRule 获取单条告警规则
+func (h *AlertsHandler) GetRule(c *gin.Context) {
+	rule, ok := h.ownedRule(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, rule)
+}
+
+// UpdateRule 整体替换告警规则（webhook_secret 留空表示不签名）
+func (h *AlertsHandler) UpdateRule(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	existing, ok := h.ownedRule(c)
+	if !ok {
+		return
+	}
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, req.WorkerID) {
+		return
+	}
+
+	rule := alertRuleFromRequest(userUID, &req)
+	rule.ID = existing.ID
+	if err := dblayer.UpdateAlertRuleByOwner(c.Request.Context(), rule); err != nil {
+		RequestLog(c).Error("update alert rule failed", "rule_id", rule.ID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to update alert rule"})
+		return
+	}
+
+	c.JSON(200, rule)
+}
+
+// DeleteRule 删除告警规则，已有的告警历史保留
+func (h *AlertsHandler) DeleteRule(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "alert rule not found"})
+		return
+	}
+	if err := dblayer.DeleteAlertRuleByOwner(c.Request.Context(), id, userUID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "alert rule not found"})
+		} else {
+			c.JSON(500, gin.H{"error": "failed to delete alert rule"})
+		}
+		return
+	}
+
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// ListHistory 分页列出告警历史（firing / resolved），可按 rule_id、worker_id、status 过滤
+func (h *AlertsHandler) ListHistory(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	ruleID := 0
+	if v := c.Query("rule_id"); v != "" {
+		if ruleID, err = strconv.Atoi(v); err != nil || ruleID <= 0 {
+			c.JSON(400, gin.H{"error": "rule_id must be a positive integer"})
+			return
+		}
+	}
+
+	events, next, err := dblayer.ListAlertEvents(c.Request.Context(), userUID, ruleID, c.Query("worker_id"), opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list alert history"})
+		return
+	}
+	if events == nil {
+		events = []*dblayer.AlertEvent{}
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListAlertEventsResponse{Events: events, NextCursor: next})
+}
+
+// ownedRule 按 :id 取当前用户的规则，不存在时已写好 404
+func (h *AlertsHandler) ownedRule(c *gin.Context) (*dblayer.AlertRule, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err == nil {
+		if rule, err := dblayer.GetAlertRuleByOwner(c.Request.Context(), id, c.GetString("user_id")); err == nil {
+			return rule, true
+		}
+	}
+	c.JSON(404, gin.H{"error": "alert rule not found"})
+	return nil, false
+}
+
+func alertRuleFromRequest(userUID string, req *AlertRuleRequest) *dblayer.AlertRule {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return &dblayer.AlertRule{
+		UserUID:       userUID,
+		WorkerID:      req.WorkerID,
+		Name:          req.Name,
+		Metric:        req.Metric,
+		Threshold:     *req.Threshold,
+		WindowSeconds: req.WindowSeconds,
+		NotifyEmail:   req.NotifyEmail,
+		WebhookURL:    req.WebhookURL,
+		WebhookSecret: req.WebhookSecret,
+		Enabled:       enabled,
+	}
+}
@@ -0,0 +1,1482 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+
+	"jabberwocky238/console/billing"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/notify"
+	"jabberwocky238/console/settings"
+)
+
+// 请求/响应类型：handler 绑定与 OpenAPI 文档共用同一份定义
+
+// ErrorResponse 所有错误响应的格式，请求校验失败时 Fields 给出每个字段的提示
+type ErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// MessageResponse 只包含一条消息的响应
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// HealthResponse /health 响应
+type HealthResponse struct {
+	Status        string `json:"status"`
+	Timestamp     int64  `json:"timestamp"`
+	Database      string `json:"database"`
+	DatabaseError string `json:"database_error,omitempty"`
+	Kubernetes    string `json:"kubernetes,omitempty"`
+}
+
+// ========== Auth ==========
+
+type RegisterRequest struct {
+	Email      string `json:"email" binding:"required"`
+	Password   string `json:"password" binding:"required,min=2"`
+	Code       string `json:"code" binding:"required"`
+	Session    string `json:"session" binding:"omitempty,oneof=bearer cookie"` // 默认 bearer，见 session.go
+	Locale     string `json:"locale" binding:"omitempty,oneof=en zh"`          // 邮件语言（notify.Locales），默认按 Accept-Language
+	InviteCode string `json:"invite_code" binding:"omitempty,max=64"`          // signup.mode 为 invite 时必填，waitlist 时跳过候补名单
+}
+
+// RegisterResponse cookie 会话时 token 为空，改为返回 csrf_token
+type RegisterResponse struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Token     string `json:"token,omitempty"`
+	CSRFToken string `json:"csrf_token,omitempty"`
+	SecretKey string `json:"secret_key"`
+}
+
+// SignupWaitlistedResponse 候补名单模式下没有邀请码的注册，202；status 为 approved 时邀请码已经发到邮箱
+type SignupWaitlistedResponse struct {
+	Waitlisted bool   `json:"waitlisted"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+}
+
+// SignupInfoResponse GET /api/auth/signup：注册页据此决定是否要求邀请码
+type SignupInfoResponse struct {
+	Mode string `json:"mode"` // open、invite 或 waitlist
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Session  string `json:"session" binding:"omitempty,oneof=bearer cookie"` // 默认 bearer，见 session.go
+	Code     string `json:"code"`                                            // 要求二次验证时邮件里的验证码
+}
+
+// StepUpRequiredResponse 登录风险较高，需要带上邮件里的验证码重新登录（401）
+type StepUpRequiredResponse struct {
+	Error   string   `json:"error"`
+	StepUp  bool     `json:"step_up"`
+	Reasons []string `json:"reasons"`
+}
+
+// LoginResponse cookie 会话时 token 为空，改为返回 csrf_token
+type LoginResponse struct {
+	UserID    string `json:"user_id"`
+	Token     string `json:"token,omitempty"`
+	CSRFToken string `json:"csrf_token,omitempty"`
+}
+
+type SendCodeRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Locale string `json:"locale" binding:"omitempty,oneof=en zh"` // 默认用已注册用户的设置，再按 Accept-Language
+}
+
+type SendCodeResponse struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+	Misc    any    `json:"misc"`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	Code        string `json:"code" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// ========== Tags ==========
+
+// SetTagsRequest 整体替换资源标签，空对象表示清空；带 expected_version 时版本不一致返回 409
+type SetTagsRequest struct {
+	Tags            map[string]string `json:"tags" binding:"tags"`
+	ExpectedVersion int               `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+type TagsResponse struct {
+	Tags    map[string]string `json:"tags"`
+	Version int               `json:"version"`
+}
+
+// ========== Egress ==========
+
+// SetEgressRequest 整体替换 worker 的出站规则：allow 不限制（不能带规则），
+// deny 只放行 cidrs 和 domains（domains 经平台的出站代理）
+type SetEgressRequest struct {
+	Mode            string   `json:"mode" binding:"required,oneof=allow deny"`
+	CIDRs           []string `json:"cidrs"`
+	Domains         []string `json:"domains"`
+	ExpectedVersion int      `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// ========== Routes ==========
+
+// SetRoutesRequest 整体替换 worker 的额外路由规则，空列表只保留 worker 自己的域名。
+// 域名只能是 worker 自己的、<label>-<worker 域名> 或通配的 *-<worker 域名>
+type SetRoutesRequest struct {
+	Rules           []dblayer.RouteRule `json:"rules" binding:"required"`
+	ExpectedVersion int                 `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// ========== Edge limits ==========
+
+// SetEdgeLimitsRequest 整体替换 worker 入口的限制，0 表示不限制。
+// rate_limit 按 worker 的域名统计（所有客户端共用），burst 为 0 时等于 rate_limit
+type SetEdgeLimitsRequest struct {
+	RateLimit       int   `json:"rate_limit" binding:"min=0"`
+	Burst           int   `json:"burst" binding:"min=0"`
+	MaxBodyBytes    int64 `json:"max_body_bytes" binding:"min=0"`
+	ExpectedVersion int   `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// ========== Error pages ==========
+
+// SetErrorPagesRequest 整体替换 worker 的维护开关和自定义 502/503 页面（HTML，各 64 KiB 以内），
+// 空页面用默认的；维护中所有请求返回 503 页面
+type SetErrorPagesRequest struct {
+	Maintenance     bool   `json:"maintenance"`
+	Page502         string `json:"page_502"`
+	Page503         string `json:"page_503"`
+	ExpectedVersion int    `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// ========== Config export/import ==========
+
+const (
+	ConfigAPIVersion = "console.app238.com/v1"
+	ConfigKind       = "Config"
+)
+
+// ConfigDocument 用户全部资源的声明式描述：GET /export 导出，POST /import 应用。
+// 资源按名字（域名）对应；省略的字段表示不管理，保持现状。
+type ConfigDocument struct {
+	APIVersion string           `json:"api_version" binding:"required,eq=console.app238.com/v1"`
+	Kind       string           `json:"kind" binding:"required,eq=Config"`
+	Workers    []WorkerConfig   `json:"workers,omitempty" binding:"dive"`
+	Domains    []DomainConfig   `json:"domains,omitempty" binding:"dive"`
+	RDBs       []ResourceConfig `json:"rdbs,omitempty" binding:"dive"`
+	KVs        []ResourceConfig `json:"kvs,omitempty" binding:"dive"`
+}
+
+type WorkerConfig struct {
+	Name           string            `json:"name" binding:"required,max=63"`
+	AssignedCPU    string            `json:"assigned_cpu,omitempty" binding:"omitempty,quantity"`
+	AssignedMemory string            `json:"assigned_memory,omitempty" binding:"omitempty,quantity"`
+	AssignedDisk   string            `json:"assigned_disk,omitempty" binding:"omitempty,quantity"`
+	MaxReplicas    int               `json:"max_replicas,omitempty" binding:"min=0,max=100"`
+	MainRegion     string            `json:"main_region,omitempty"`
+	Cluster        string            `json:"cluster,omitempty" binding:"omitempty,slug"` // 只在创建时生效
+	Pool           string            `json:"pool,omitempty" binding:"omitempty,slug"`
+	Arch           string            `json:"arch,omitempty" binding:"omitempty,oneof=amd64 arm64"`
+	RunAsRoot      *bool             `json:"run_as_root,omitempty"` // 不写保持现值
+	WritableRootFS *bool             `json:"writable_root_fs,omitempty"`
+	Image          string            `json:"image,omitempty" binding:"omitempty,image"`
+	Port           int               `json:"port,omitempty" binding:"required_with=Image,omitempty,min=1,max=65535"`
+	Env            []string          `json:"env,omitempty"`     // 只有 key，值不导出
+	Secrets        []string          `json:"secrets,omitempty"` // 只有 key
+	Tags           map[string]string `json:"tags,omitempty" binding:"omitempty,tags"`
+	Environment    string            `json:"environment,omitempty" binding:"omitempty,environment"`     // 只在创建时生效
+	Kind           string            `json:"kind,omitempty" binding:"omitempty,oneof=container static"` // 只在创建时生效；static 不导出 image，文件另行部署
+}
+
+type DomainConfig struct {
+	Domain      string            `json:"domain" binding:"required,domain"`
+	Target      string            `json:"target" binding:"required"`
+	Issuer      string            `json:"issuer,omitempty"` // 只在创建时生效
+	Challenge   string            `json:"challenge,omitempty" binding:"omitempty,oneof=http01 dns01"`
+	Tags        map[string]string `json:"tags,omitempty" binding:"omitempty,tags"`
+	Environment string            `json:"environment,omitempty" binding:"omitempty,environment"` // 只在创建时生效
+}
+
+// ResourceConfig RDB/KV
+type ResourceConfig struct {
+	Name        string            `json:"name" binding:"required,max=63"`
+	Tags        map[string]string `json:"tags,omitempty" binding:"omitempty,tags"`
+	Environment string            `json:"environment,omitempty" binding:"omitempty,environment"` // 只在创建时生效
+}
+
+// ConfigChange import 计划中的一项变更，执行失败时带 error
+type ConfigChange struct {
+	Action string `json:"action"` // create | update | deploy | delete
+	Type   string `json:"type"`   // worker | domain | rdb | kv，apply 还有 env | attachment
+	Name   string `json:"name"`
+	ID     string `json:"id,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportConfigResponse struct {
+	DryRun   bool           `json:"dry_run"`
+	Changes  []ConfigChange `json:"changes"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// ========== Manifest (console.yaml) ==========
+
+// WorkerManifest 单个 worker 的声明式描述（console.yaml），POST /apply 应用：worker 按名字对应，
+// 省略的资源字段保持现状。env 带值；domains 指向该 worker；attach 按名字引用 RDB/KV，不存在时创建
+type WorkerManifest struct {
+	APIVersion string            `json:"api_version" binding:"required,eq=console.app238.com/v1"`
+	Kind       string            `json:"kind" binding:"required,eq=Worker"`
+	Name       string            `json:"name" binding:"required,max=63"`
+	Image      string            `json:"image,omitempty" binding:"omitempty,image"`
+	Build      *ManifestBuild    `json:"build,omitempty"` // 由 consolectl apply 构建推送后填入 image，服务端不构建
+	Port       int               `json:"port" binding:"required,min=1,max=65535"`
+	Resources  ManifestResources `json:"resources,omitempty"`
+	MainRegion string            `json:"main_region,omitempty"`
+	Pool       string            `json:"pool,omitempty" binding:"omitempty,slug"`
+	Arch       string            `json:"arch,omitempty" binding:"omitempty,oneof=amd64 arm64"`
+	Env        map[string]string `json:"env,omitempty"` // 值可以是 secretref://name
+	Domains    []ManifestDomain  `json:"domains,omitempty" binding:"dive"`
+	Attach     []ManifestAttach  `json:"attach,omitempty" binding:"dive"`
+	Tags       map[string]string `json:"tags,omitempty" binding:"omitempty,tags"`
+}
+
+// ManifestBuild 镜像的构建上下文，Image 是推送的仓库（不带 tag）
+type ManifestBuild struct {
+	Context    string `json:"context,omitempty"`    // 相对 console.yaml 所在目录，默认 .
+	Dockerfile string `json:"dockerfile,omitempty"` // 相对 context，默认 Dockerfile
+	Image      string `json:"image" binding:"required"`
+}
+
+type ManifestResources struct {
+	CPU         string `json:"cpu,omitempty" binding:"omitempty,quantity"`
+	Memory      string `json:"memory,omitempty" binding:"omitempty,quantity"`
+	Disk        string `json:"disk,omitempty" binding:"omitempty,quantity"`
+	MaxReplicas int    `json:"max_replicas,omitempty" binding:"min=0,max=100"`
+}
+
+// ManifestDomain 指向该 worker 的自定义域名，issuer/challenge 只在添加时生效
+type ManifestDomain struct {
+	Domain    string `json:"domain" binding:"required,domain"`
+	Issuer    string `json:"issuer,omitempty"`
+	Challenge string `json:"challenge,omitempty" binding:"omitempty,oneof=http01 dns01"`
+}
+
+// ManifestAttach 挂载到 worker 的 RDB/KV，Env 默认 DATABASE_URL / REDIS_URL
+type ManifestAttach struct {
+	Type string `json:"type" binding:"required,oneof=rdb kv"`
+	Name string `json:"name" binding:"required,max=63"`
+	Env  string `json:"env,omitempty"`
+}
+
+// ApplyManifestResponse 变更计划和执行结果，新建 worker 的 ID 在 dry run 时为空
+type ApplyManifestResponse struct {
+	WorkerID string `json:"worker_id,omitempty"`
+	URL      string `json:"url,omitempty"`
+	ImportConfigResponse
+}
+
+// ========== Search ==========
+
+// SearchResponse 搜索结果按得分排序；facets 为各类型命中总数，不受 type/limit 过滤影响
+type SearchResponse struct {
+	Query   string                  `json:"query"`
+	Results []*dblayer.SearchResult `json:"results"`
+	Facets  map[string]int          `json:"facets"`
+}
+
+// ========== Combinator ==========
+
+type CreateRDBRequest struct {
+	Name        string            `json:"name" binding:"required,max=63"`
+	Tags        map[string]string `json:"tags" binding:"omitempty,tags"`
+	Environment string            `json:"environment,omitempty" binding:"omitempty,environment"` // 为空时 production
+}
+
+// CreateKVRequest 请求体可以省略
+type CreateKVRequest struct {
+	Environment string `json:"environment,omitempty" binding:"omitempty,environment"` // 为空时 production
+}
+
+// ResourceCreatedResponse RDB/KV/bucket/队列创建后的响应
+type ResourceCreatedResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type ListRDBsResponse struct {
+	RDBs         []*dblayer.CombinatorResource `json:"rdbs"`
+	DatabaseSize int64                         `json:"database_size"`
+	NextCursor   string                        `json:"next_cursor,omitempty"`
+}
+
+type RDBDetailResponse struct {
+	ID         int       `json:"id"`
+	ResourceID string    `json:"resource_id"`
+	Status     string    `json:"status"`
+	Msg        string    `json:"msg"`
+	CreatedAt  time.Time `json:"created_at"`
+	SchemaSize int64     `json:"schema_size"`
+}
+
+type ListKVsResponse struct {
+	KVs        []*dblayer.CombinatorResource `json:"kvs"`
+	NextCursor string                        `json:"next_cursor,omitempty"`
+}
+
+type CreateBucketRequest struct {
+	Name        string                        `json:"name,omitempty" binding:"max=63"` // 为空时用资源 ID
+	Tags        map[string]string             `json:"tags" binding:"omitempty,tags"`
+	Environment string                        `json:"environment,omitempty" binding:"omitempty,environment"` // 为空时 production
+	Lifecycle   []dblayer.BucketLifecycleRule `json:"lifecycle,omitempty" binding:"max=100,dive"`
+}
+
+type ListBucketsResponse struct {
+	Buckets    []*dblayer.CombinatorResource `json:"buckets"`
+	TotalBytes int64                         `json:"total_bytes"` // 所有 bucket 最近一次测量的大小之和
+	NextCursor string                        `json:"next_cursor,omitempty"`
+}
+
+// BucketDetailResponse 大小和对象数是最近一次测量的结果（measured_at），凭据只注入挂载了它的 worker
+type BucketDetailResponse struct {
+	ID           int                           `json:"id"`
+	ResourceID   string                        `json:"resource_id"`
+	Name         string                        `json:"name"`
+	Status       string                        `json:"status"`
+	Msg          string                        `json:"msg"`
+	CreatedAt    time.Time                     `json:"created_at"`
+	BucketName   string                        `json:"bucket_name,omitempty"`
+	Lifecycle    []dblayer.BucketLifecycleRule `json:"lifecycle"`
+	SizeBytes    int64                         `json:"size_bytes"`
+	ObjectCount  int64                         `json:"object_count"`
+	MeasuredAt   *time.Time                    `json:"measured_at,omitempty"`
+	KeyExpiresAt *time.Time                    `json:"key_expires_at,omitempty"`
+}
+
+// SetBucketLifecycleRequest 整体替换生命周期规则，空列表删除全部规则
+type SetBucketLifecycleRequest struct {
+	Rules           []dblayer.BucketLifecycleRule `json:"rules" binding:"max=100,dive"`
+	ExpectedVersion int                           `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+type BucketLifecycleResponse struct {
+	Rules   []dblayer.BucketLifecycleRule `json:"rules"`
+	Version int                           `json:"version"`
+}
+
+type CreateQueueRequest struct {
+	Name        string            `json:"name,omitempty" binding:"max=63"` // 为空时用资源 ID
+	Tags        map[string]string `json:"tags" binding:"omitempty,tags"`
+	Environment string            `json:"environment,omitempty" binding:"omitempty,environment"` // 为空时 production
+}
+
+type ListQueuesResponse struct {
+	Queues     []*dblayer.CombinatorResource `json:"queues"`
+	NextCursor string                        `json:"next_cursor,omitempty"`
+}
+
+// QueueDetailResponse stats 是 broker 上的实时状态（队列深度、消费者数、速率），队列未就绪或 broker 不可用时为空
+type QueueDetailResponse struct {
+	ID         int             `json:"id"`
+	ResourceID string          `json:"resource_id"`
+	Name       string          `json:"name"`
+	Status     string          `json:"status"`
+	Msg        string          `json:"msg"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Stats      *k8s.QueueStats `json:"stats,omitempty"`
+}
+
+// ResourceRef combinator 拉取配置时看到的资源引用
+type ResourceRef struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+}
+
+// RetrieveSecretResponse secret_key 只返回给 combinator，worker 调用时为空
+type RetrieveSecretResponse struct {
+	Resources []ResourceRef `json:"resources"`
+	SecretKey string        `json:"secret_key,omitempty"`
+}
+
+type ReportUsageResponse struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// ReportConfigRequest the config revision a dedicated combinator pod has loaded
+type ReportConfigRequest struct {
+	UserUID  string `json:"user_uid" binding:"required"`
+	Revision int64  `json:"revision" binding:"required,min=1"`
+}
+
+// ResolvedDSN live connection string of a resource; KV is served by the combinator,
+// so its dsn is the endpoint of the combinator serving it
+type ResolvedDSN struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	DSN          string `json:"dsn"`
+}
+
+// ResolveDSNResponse signed with the owner's secret key (see dsnSignature), valid until expires_at
+type ResolveDSNResponse struct {
+	UserUID   string        `json:"user_uid"`
+	Resources []ResolvedDSN `json:"resources"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	Signature string        `json:"signature"`
+}
+
+// SetCombinatorProfileRequest sizing profile of the dedicated combinator, empty for the plan default
+type SetCombinatorProfileRequest struct {
+	Profile string `json:"profile" binding:"omitempty,max=32"`
+}
+
+// CombinatorLogsResponse 最近的 combinator 日志，按 pod 创建顺序；共享 combinator 时 dedicated 为 false、没有日志
+type CombinatorLogsResponse struct {
+	Dedicated bool                 `json:"dedicated"`
+	Lines     []controller.LogLine `json:"lines"`
+}
+
+// ========== Worker ==========
+
+type CreateWorkerRequest struct {
+	WorkerName     string            `json:"worker_name" binding:"required,max=63"`
+	AssignedCPU    string            `json:"assigned_cpu" binding:"omitempty,quantity"`
+	AssignedMemory string            `json:"assigned_memory" binding:"omitempty,quantity"`
+	AssignedDisk   string            `json:"assigned_disk" binding:"omitempty,quantity"`
+	MaxReplicas    int               `json:"max_replicas" binding:"min=0,max=100"`
+	MainRegion     string            `json:"main_region"`
+	Cluster        string            `json:"cluster" binding:"omitempty,slug"` // 目标集群，为空时首次部署自动调度
+	Pool           string            `json:"pool" binding:"omitempty,slug"`    // 节点池，见 GET /api/worker/pools
+	Arch           string            `json:"arch" binding:"omitempty,oneof=amd64 arm64"`
+	RunAsRoot      bool              `json:"run_as_root"`      // 放宽 runAsNonRoot，需套餐允许
+	WritableRootFS bool              `json:"writable_root_fs"` // 放宽 readOnlyRootFilesystem，需套餐允许
+	Tags           map[string]string `json:"tags" binding:"omitempty,tags"`
+	Environment    string            `json:"environment,omitempty" binding:"omitempty,environment"`     // 为空时 production
+	Kind           string            `json:"kind,omitempty" binding:"omitempty,oneof=container static"` // 为空时 container；static 用 POST /worker/:id/static 部署
+}
+
+// CloneWorkerRequest POST /api/worker/:id/clone。main_region 为空时沿用源 worker 的区域和集群，
+// 换区域时不指定 cluster 则重新调度；tags 覆盖在源 worker 的标签上（如 env: staging）；
+// environment 为空时和源 worker 相同；copy_secrets 表示同意把 worker secret 的值也复制过去
+type CloneWorkerRequest struct {
+	WorkerName  string            `json:"worker_name" binding:"required,max=63"`
+	MainRegion  string            `json:"main_region"`
+	Cluster     string            `json:"cluster" binding:"omitempty,slug"`
+	Tags        map[string]string `json:"tags" binding:"omitempty,tags"`
+	Environment string            `json:"environment,omitempty" binding:"omitempty,environment"`
+	CopySecrets bool              `json:"copy_secrets"`
+}
+
+// CloneWorkerResponse 克隆出的 worker；源 worker 部署过时 version_id 是克隆的首次部署
+type CloneWorkerResponse struct {
+	WorkerID   string   `json:"worker_id"`
+	WorkerName string   `json:"worker_name"`
+	SourceID   string   `json:"source_id"`
+	VersionID  int      `json:"version_id,omitempty"`
+	Secrets    []string `json:"secrets,omitempty"` // 复制了值的 secret key
+}
+
+type CreateWorkerResponse struct {
+	WorkerID   string `json:"worker_id"`
+	WorkerName string `json:"worker_name"`
+}
+
+// WorkerSummary ListWorkers 中的单条记录
+type WorkerSummary struct {
+	WorkerID        string `json:"worker_id"`
+	WorkerName      string `json:"worker_name"`
+	Status          string `json:"status"`
+	ActiveVersionID *int   `json:"active_version_id"`
+	Environment     string `json:"environment"`
+	Kind            string `json:"kind"`
+	URL             string `json:"url"`
+}
+
+type WorkerDetailResponse struct {
+	Worker   *dblayer.Worker                `json:"worker"`
+	Versions []*dblayer.WorkerDeployVersion `json:"versions"`
+	URL      string                         `json:"url"`
+}
+
+// NodePoolsResponse GET /api/worker/pools
+type NodePoolsResponse struct {
+	Pools []k8s.NodePool `json:"pools"`
+}
+
+// RegionsResponse GET /api/regions，main_region 可选的区域及其容量；default_region 是账号的默认区域（inner 不返回）
+type RegionsResponse struct {
+	Regions       []k8s.RegionCapacity `json:"regions"`
+	DefaultRegion string               `json:"default_region"`
+}
+
+type DeployWorkerRequest struct {
+	UserUID   string                `json:"user_uid" binding:"required"`
+	WorkerID  string                `json:"worker_id" binding:"required"`
+	Image     string                `json:"image" binding:"required,image"`
+	Port      int                   `json:"port" binding:"required,min=1,max=65535"`
+	CommitSHA string                `json:"commit_sha" binding:"omitempty,max=64"` // 镜像对应的提交，记录在部署版本上
+	Initiator string                `json:"-"`                                     // 记录在部署版本上，默认 user（dblayer.DeployInitiator*）
+	Static    *dblayer.StaticSource `json:"-"`                                     // static worker 的文件来源，只由 POST /worker/:id/static、克隆和提升设置
+}
+
+// StaticRepoRequest POST /api/worker/:id/static 的 JSON 请求体：从公开的 GitHub 仓库部署。
+// 上传 tarball 时请求体是 application/gzip，子目录用 ?dir= 指定
+type StaticRepoRequest struct {
+	Repo string `json:"repo" binding:"required,max=255"` // owner/name
+	Ref  string `json:"ref" binding:"omitempty,max=255"` // 分支、tag 或提交，为空时 main
+	Dir  string `json:"dir" binding:"omitempty,max=255"`
+}
+
+type DeployWorkerResponse struct {
+	WorkerID  string                `json:"worker_id"`
+	VersionID int                   `json:"version_id"`
+	Status    string                `json:"status"`
+	Estimate  *billing.CostEstimate `json:"estimate,omitempty"` // 按 worker 当前配置估算的每月费用
+}
+
+// EstimateWorkerRequest POST /api/worker/estimate，字段同 CreateWorkerRequest，未填的资源按默认值计
+type EstimateWorkerRequest struct {
+	AssignedCPU    string `json:"assigned_cpu" binding:"omitempty,quantity"`
+	AssignedMemory string `json:"assigned_memory" binding:"omitempty,quantity"`
+	AssignedDisk   string `json:"assigned_disk" binding:"omitempty,quantity"`
+	MaxReplicas    int    `json:"max_replicas" binding:"min=0,max=100"`
+	MainRegion     string `json:"main_region"`
+}
+
+// DeployRejectedResponse 部署前校验不通过（422）
+type DeployRejectedResponse struct {
+	Error      string          `json:"error"`
+	Violations []k8s.Violation `json:"violations"`
+}
+
+// ValidateWorkerResponse GET /api/worker/validate (inner)，没有违规项时 violations 为空
+type ValidateWorkerResponse struct {
+	Violations []k8s.Violation `json:"violations"`
+}
+
+// WorkerKeyValueRequest 设置/删除单条 env 或 secret；带 expected_version 时 worker 版本不一致返回 409
+type WorkerKeyValueRequest struct {
+	Key             string `json:"key" binding:"required"`
+	Value           string `json:"value"`
+	Delete          bool   `json:"delete"`
+	ExpectedVersion int    `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// ImportEnvRequest POST /api/worker/:id/env/import 的 JSON 形式，也可以直接用 .env 原文作请求体
+type ImportEnvRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// ImportedEnvVar 导入的一个变量怎么保存，reason 见 EnvReason*，空表示默认按普通 env
+type ImportedEnvVar struct {
+	Key    string `json:"key"`
+	Line   int    `json:"line"`
+	Secret bool   `json:"secret"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ImportEnvResponse POST /api/worker/:id/env/import，dry_run 时只有 vars
+type ImportEnvResponse struct {
+	DryRun  bool              `json:"dry_run"`
+	Vars    []ImportedEnvVar  `json:"vars"`
+	Env     map[string]string `json:"env,omitempty"`     // 导入后的完整 env（打码）
+	Secrets []string          `json:"secrets,omitempty"` // 导入后的 secret key
+}
+
+// BulkWorkerRequest 对多个 worker 执行同一操作，Env 只在 action=set_env 时需要
+type BulkWorkerRequest struct {
+	Action    string                 `json:"action" binding:"required,oneof=restart pause resume set_env delete"`
+	WorkerIDs []string               `json:"worker_ids" binding:"required,min=1,max=100,dive,required"`
+	Env       *WorkerKeyValueRequest `json:"env" binding:"required_if=Action set_env"`
+}
+
+// BulkWorkerResult 单个 worker 的结果：queued 表示已进入任务队列，执行结果通过事件流推送
+type BulkWorkerResult struct {
+	WorkerID string `json:"worker_id"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+type BulkWorkerResponse struct {
+	Action  string             `json:"action"`
+	Queued  int                `json:"queued"`
+	Results []BulkWorkerResult `json:"results"`
+}
+
+// DeployVersionsResponse GET /api/worker/:id/versions，默认新的在前
+type DeployVersionsResponse struct {
+	Versions []*dblayer.WorkerDeployVersion `json:"versions"`
+}
+
+// WorkerRequestsResponse GET /api/worker/:id/requests，最新的在前
+type WorkerRequestsResponse struct {
+	Requests   []*dblayer.WorkerRequest `json:"requests"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// WorkerRequestHoursResponse GET /api/worker/:id/requests/hourly，按时间先后，没有请求的小时不列出
+type WorkerRequestHoursResponse struct {
+	Since time.Time                    `json:"since"`
+	Hours []*dblayer.WorkerRequestHour `json:"hours"`
+}
+
+// IngestAccessLogsResponse POST /api/v1/access-logs (inner)
+type IngestAccessLogsResponse struct {
+	Stored  int `json:"stored"`
+	Skipped int `json:"skipped"` // 不属于 worker、无法解析或 worker 已删除的行
+}
+
+// DeployDiffResponse GET /api/worker/:id/versions/:a/diff/:b，从 a 到 b 的变化。
+// partial 表示有版本早于部署快照，只比较了镜像、端口和提交
+type DeployDiffResponse struct {
+	From    *dblayer.WorkerDeployVersion `json:"from"`
+	To      *dblayer.WorkerDeployVersion `json:"to"`
+	Partial bool                         `json:"partial"`
+	Changes []dblayer.DeployChange       `json:"changes"`
+}
+
+// RestartWorkerRequest POST /api/worker/:id/restart，reason 写入审计记录
+type RestartWorkerRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// WorkerRestartResponse 一次重启及其滚动进度：status 为 restarted 后 rollout 的 updated/available
+// 副本数追上 replicas 即 complete。rollout 只对最近一次重启给出，inner 不可用时为空
+type WorkerRestartResponse struct {
+	Restart  *dblayer.WorkerRestart          `json:"restart"`
+	Rollout  *controller.WorkerRuntimeStatus `json:"rollout,omitempty"`
+	Complete bool                            `json:"complete"`
+}
+
+// WorkerRestartsResponse GET /api/worker/:id/restarts，新的在前
+type WorkerRestartsResponse struct {
+	Restarts []*dblayer.WorkerRestart `json:"restarts"`
+}
+
+// ResizeWorkerRequest PATCH /api/worker/:id/resources，只改给出的字段，空串恢复默认
+type ResizeWorkerRequest struct {
+	AssignedCPU     *string `json:"assigned_cpu,omitempty" binding:"omitempty,quantity"`
+	AssignedMemory  *string `json:"assigned_memory,omitempty" binding:"omitempty,quantity"`
+	AssignedDisk    *string `json:"assigned_disk,omitempty" binding:"omitempty,quantity"`
+	ExpectedVersion int     `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// ResizeWorkerResponse 调整记录（status 为 pending，同步后变成 applied 或 failed）和 worker 的新版本
+type ResizeWorkerResponse struct {
+	Change  *dblayer.WorkerResourceChange `json:"change"`
+	Version int                           `json:"version"`
+}
+
+// WorkerResourceChangesResponse GET /api/worker/:id/resources/changes，新的在前
+type WorkerResourceChangesResponse struct {
+	Changes []*dblayer.WorkerResourceChange `json:"changes"`
+}
+
+// SetRemediationPolicyRequest PUT /api/worker/:id/remediation。window_seconds 最多 1 小时，
+// inner 只保留这么久的 pod 信号；max_memory 为空时自动加内存到套餐上限为止
+type SetRemediationPolicyRequest struct {
+	OOMAction      string `json:"oom_action" binding:"required,oneof=off notify bump"`
+	OOMThreshold   int    `json:"oom_threshold" binding:"required,min=1,max=100"`
+	MaxMemory      string `json:"max_memory" binding:"omitempty,quantity"`
+	CrashAction    string `json:"crash_action" binding:"required,oneof=off notify pause"`
+	CrashThreshold int    `json:"crash_threshold" binding:"required,min=1,max=100"`
+	WindowSeconds  int    `json:"window_seconds" binding:"required,min=60,max=3600"`
+}
+
+// WorkerRemediationsResponse GET /api/worker/:id/remediations，新的在前
+type WorkerRemediationsResponse struct {
+	Remediations []*dblayer.WorkerRemediation `json:"remediations"`
+}
+
+// WorkerLogHistoryResponse 日志存储里保留的 worker 日志，按时间倒序
+type WorkerLogHistoryResponse struct {
+	WorkerID string               `json:"worker_id"`
+	Since    time.Time            `json:"since"`
+	Until    time.Time            `json:"until"`
+	Entries  []k8s.WorkerLogEntry `json:"entries"`
+}
+
+// ========== Alerts ==========
+
+// AlertRuleRequest 创建/整体替换告警规则：Metric 的值在 WindowSeconds 内大于 Threshold 时触发，
+// 状态变化时发邮件（NotifyEmail）和/或 POST 到 WebhookURL
+type AlertRuleRequest struct {
+	WorkerID      string   `json:"worker_id" binding:"required"`
+	Name          string   `json:"name" binding:"required,max=255"`
+	Metric        string   `json:"metric" binding:"required,oneof=restarts oom_kills crash_loop http_5xx_rate p95_latency"`
+	Threshold     *float64 `json:"threshold" binding:"required,min=0"`
+	WindowSeconds int      `json:"window_seconds" binding:"required,min=60,max=3600"`
+	NotifyEmail   bool     `json:"notify_email"`
+	WebhookURL    string   `json:"webhook_url" binding:"omitempty,webhook"`
+	WebhookSecret string   `json:"webhook_secret" binding:"max=256"`
+	Enabled       *bool    `json:"enabled"`
+}
+
+type ListAlertRulesResponse struct {
+	Rules      []*dblayer.AlertRule `json:"rules"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type ListAlertEventsResponse struct {
+	Events     []*dblayer.AlertEvent `json:"events"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// ========== Account security ==========
+
+type ListAuthEventsResponse struct {
+	Events     []*dblayer.AuthEvent `json:"events"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// RevealSecretKeyRequest 查看 secret key 需要重新输入密码
+type RevealSecretKeyRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type RevealSecretKeyResponse struct {
+	SecretKey string `json:"secret_key"`
+}
+
+// RevealEnvRequest 查看一条打码的 worker env
+type RevealEnvRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+type RevealEnvResponse struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ========== Uptime ==========
+
+// UptimeCheckRequest 创建拨测：worker_id 与 domain_id 二选一，探测该目标公网地址 + path。
+// 不填时 interval 60s、timeout 10s、expected_status 0（任意 2xx/3xx）
+type UptimeCheckRequest struct {
+	Name            string `json:"name" binding:"required,max=255"`
+	WorkerID        string `json:"worker_id" binding:"required_without=DomainID,excluded_with=DomainID"`
+	DomainID        string `json:"domain_id"`
+	Path            string `json:"path" binding:"omitempty,startswith=/,max=1024"`
+	IntervalSeconds int    `json:"interval_seconds" binding:"omitempty,min=30,max=3600"`
+	TimeoutMs       int    `json:"timeout_ms" binding:"omitempty,min=1000,max=30000"`
+	ExpectedStatus  int    `json:"expected_status" binding:"omitempty,min=100,max=599"`
+	Public          bool   `json:"public"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+// UpdateUptimeCheckRequest 整体替换拨测配置，目标和 path 不能改（删掉重建）
+type UpdateUptimeCheckRequest struct {
+	Name            string `json:"name" binding:"required,max=255"`
+	IntervalSeconds int    `json:"interval_seconds" binding:"omitempty,min=30,max=3600"`
+	TimeoutMs       int    `json:"timeout_ms" binding:"omitempty,min=1000,max=30000"`
+	ExpectedStatus  int    `json:"expected_status" binding:"omitempty,min=100,max=599"`
+	Public          bool   `json:"public"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+type ListUptimeChecksResponse struct {
+	Checks     []*dblayer.UptimeCheck `json:"checks"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+type UptimeCheckDetailResponse struct {
+	Check    *dblayer.UptimeCheck `json:"check"`
+	Stats24h dblayer.UptimeStats  `json:"stats_24h"`
+	Stats7d  dblayer.UptimeStats  `json:"stats_7d"`
+}
+
+// UptimeResultsResponse 探测结果（响应时间曲线），最新的在前
+type UptimeResultsResponse struct {
+	CheckID int                     `json:"check_id"`
+	Since   time.Time               `json:"since"`
+	Stats   dblayer.UptimeStats     `json:"stats"`
+	Results []*dblayer.UptimeResult `json:"results"`
+}
+
+type ListUptimeIncidentsResponse struct {
+	Incidents  []*dblayer.UptimeIncident `json:"incidents"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+// StatusPageRequest 设置公开状态页，访问地址为 /api/v1/status/:slug
+type StatusPageRequest struct {
+	Slug    string `json:"slug" binding:"required,slug"`
+	Title   string `json:"title" binding:"max=255"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// PublicStatusCheck 状态页上的一个拨测，不暴露目标地址
+type PublicStatusCheck struct {
+	Name            string     `json:"name"`
+	Status          string     `json:"status"`
+	Uptime24h       *float64   `json:"uptime_24h,omitempty"`
+	Uptime7d        *float64   `json:"uptime_7d,omitempty"`
+	AvgLatencyMs24h *float64   `json:"avg_latency_ms_24h,omitempty"`
+	LastCheckedAt   *time.Time `json:"last_checked_at,omitempty"`
+}
+
+// PublicStatusPageResponse 公开状态页：status 为 operational / partial_outage / major_outage / unknown
+type PublicStatusPageResponse struct {
+	Title       string                    `json:"title"`
+	Status      string                    `json:"status"`
+	Checks      []PublicStatusCheck       `json:"checks"`
+	Incidents   []*dblayer.UptimeIncident `json:"incidents"`
+	GeneratedAt time.Time                 `json:"generated_at"`
+}
+
+// ========== CustomDomain ==========
+
+type AddCustomDomainRequest struct {
+	Domain      string            `json:"domain" binding:"required,domain"`
+	Target      string            `json:"target" binding:"required"`
+	Issuer      string            `json:"issuer,omitempty"` // 自带的 cert-manager issuer，须在 CERT_ISSUERS_ALLOWED 中
+	Challenge   string            `json:"challenge,omitempty" binding:"omitempty,oneof=http01 dns01"`
+	Tags        map[string]string `json:"tags" binding:"omitempty,tags"`
+	Force       bool              `json:"force,omitempty"`                                       // DNS 验证通过后立刻创建路由，不等目标 worker 就绪
+	Environment string            `json:"environment,omitempty" binding:"omitempty,environment"` // 为空时 production
+}
+
+type AddCustomDomainResponse struct {
+	ID       int              `json:"id"`
+	Domain   string           `json:"domain"`
+	Target   string           `json:"target"`
+	TXTName  string           `json:"txt_name"`
+	TXTValue string           `json:"txt_value"`
+	Status   k8s.DomainStatus `json:"status"`
+}
+
+type ListCustomDomainsResponse struct {
+	Domains    []*k8s.CustomDomain `json:"domains"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// ========== Jobs ==========
+
+type AcceptTaskResponse struct {
+	Message    string      `json:"message"`
+	TaskType   k8s.JobType `json:"task_type"`
+	Timestamp  int64       `json:"timestamp"`
+	ReceivedAt int64       `json:"received_at"`
+}
+
+// ========== Usage ==========
+
+// UsageMetricTotal 一项指标在整个周期内的合计
+type UsageMetricTotal struct {
+	Metric   string  `json:"metric"`
+	Quantity float64 `json:"quantity"`
+}
+
+// UsageResponse GET /api/usage：周期内按指标和按资源汇总的用量，*_seconds 指标为占用量 × 秒
+type UsageResponse struct {
+	Period    string               `json:"period"`
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Totals    []UsageMetricTotal   `json:"totals"`
+	Resources []dblayer.UsageTotal `json:"resources"`
+}
+
+// ========== Plans ==========
+
+// SetAccountPlanRequest PUT /admin/accounts/:uid/plan
+type SetAccountPlanRequest struct {
+	Plan string `json:"plan" binding:"required,oneof=free pro team"`
+}
+
+// AccountLimitsResponse 用户的套餐、账户级覆盖和两者合并后生效的限制
+type AccountLimitsResponse struct {
+	UserUID   string                 `json:"user_uid"`
+	Plan      string                 `json:"plan"`
+	Limits    dblayer.PlanLimits     `json:"limits"`
+	Overrides dblayer.LimitOverrides `json:"overrides"`
+}
+
+// ========== Billing ==========
+
+// CheckoutRequest POST /api/billing/checkout
+type CheckoutRequest struct {
+	Plan string `json:"plan" binding:"required,oneof=pro team"`
+}
+
+// CheckoutResponse 跳转到 URL 完成付款，订阅生效由 webhook 同步
+type CheckoutResponse struct {
+	SessionID string `json:"session_id"`
+	URL       string `json:"url"`
+}
+
+// ========== Spending cap ==========
+
+// SetSpendCapRequest PUT /api/spend-cap，金额按价格表的币种
+type SetSpendCapRequest struct {
+	MonthlyCap float64 `json:"monthly_cap" binding:"required,gt=0"`
+}
+
+// SpendCapResponse 花费上限和本月累计花费
+type SpendCapResponse struct {
+	dblayer.SpendCap
+	Period     string  `json:"period"`
+	Accrued    float64 `json:"accrued"`
+	Enforced   bool    `json:"enforced"`   // 本月已暂停非关键 worker
+	Overridden bool    `json:"overridden"` // 本月已放行
+}
+
+// SpendCapActionsResponse GET /api/spend-cap/actions
+type SpendCapActionsResponse struct {
+	Actions []dblayer.SpendCapAction `json:"actions"`
+}
+
+// ========== Secrets ==========
+
+// CreateSecretRequest POST /api/secrets，值只写不读
+type CreateSecretRequest struct {
+	Name  string `json:"name" binding:"required,slug"`
+	Value string `json:"value" binding:"required,max=65536"`
+}
+
+// RotateSecretRequest POST /api/secrets/:name/rotate
+type RotateSecretRequest struct {
+	Value string `json:"value" binding:"required,max=65536"`
+}
+
+// SecretsResponse GET /api/secrets
+type SecretsResponse struct {
+	Secrets []dblayer.UserSecret `json:"secrets"`
+}
+
+// SecretResponse secret 元数据，不含值；workers 是 env 里用 secretref://name 引用它的 worker
+type SecretResponse struct {
+	dblayer.UserSecret
+	Versions []dblayer.SecretVersion `json:"versions"`
+	Workers  []string                `json:"workers"`
+}
+
+// SecretAccessResponse GET /api/secrets/:name/audit
+type SecretAccessResponse struct {
+	Access []dblayer.SecretAccess `json:"access"`
+}
+
+// ========== Env groups ==========
+
+// CreateEnvGroupRequest POST /api/env-groups，值可以是 secretref://name
+type CreateEnvGroupRequest struct {
+	Name string            `json:"name" binding:"required,slug"`
+	Vars map[string]string `json:"vars" binding:"required"`
+}
+
+// SetEnvGroupVarsRequest PUT /api/env-groups/:name 整体替换变量；带 expected_version 时组版本不一致返回 409
+type SetEnvGroupVarsRequest struct {
+	Vars            map[string]string `json:"vars" binding:"required"`
+	ExpectedVersion int               `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// EnvGroupsResponse GET /api/env-groups，变量值按 env 的规则打码
+type EnvGroupsResponse struct {
+	Groups []*dblayer.EnvGroup `json:"groups"`
+}
+
+// EnvGroupResponse 一个 env 组，workers 是挂着它的 worker
+type EnvGroupResponse struct {
+	dblayer.EnvGroup
+	Workers []string `json:"workers"`
+}
+
+// SetWorkerEnvGroupsRequest PUT /api/worker/:id/env-groups 按优先级从低到高列出要挂的组，空列表全部摘掉
+type SetWorkerEnvGroupsRequest struct {
+	Groups          []string `json:"groups" binding:"required,dive,slug"`
+	ExpectedVersion int      `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// WorkerEnvGroupsResponse worker 挂的 env 组（后面的覆盖前面的）和合并上 worker 自己 env 之后生效的变量（打码）
+type WorkerEnvGroupsResponse struct {
+	Groups    []string          `json:"groups"`
+	Effective map[string]string `json:"effective"`
+}
+
+// ========== Environments ==========
+
+// EnvironmentsResponse GET /api/environments，变量值按 env 的规则打码
+type EnvironmentsResponse struct {
+	Environments []*dblayer.Environment `json:"environments"`
+}
+
+// SetEnvironmentVarsRequest PUT /api/environments/:name 整体替换环境的变量，值可以是 secretref://name；
+// 带 expected_version 时版本不一致返回 409
+type SetEnvironmentVarsRequest struct {
+	Vars            map[string]string `json:"vars" binding:"required"`
+	ExpectedVersion int               `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+// EnvironmentResponse 一个环境（变量打码）和它下面的 worker
+type EnvironmentResponse struct {
+	dblayer.Environment
+	WorkerIDs []string `json:"worker_ids"`
+}
+
+// SetEnvironmentRequest PUT /api/worker/:id/environment、/rdb/:id/environment 等，把资源移到另一个环境
+type SetEnvironmentRequest struct {
+	Environment     string `json:"environment" binding:"required,environment"`
+	ExpectedVersion int    `json:"expected_version,omitempty" binding:"min=0"`
+}
+
+type SetEnvironmentResponse struct {
+	Environment string `json:"environment"`
+	Version     int    `json:"version"`
+}
+
+// PromoteWorkerRequest POST /api/worker/:id/promote 把源 worker 的一个版本（默认当前版本）部署到另一个环境的 target_id
+type PromoteWorkerRequest struct {
+	TargetID  string `json:"target_id" binding:"required"`
+	VersionID int    `json:"version_id,omitempty" binding:"min=0"`
+}
+
+// PromoteWorkerResponse version_id 是目标 worker 上新建的部署版本
+type PromoteWorkerResponse struct {
+	WorkerID      string `json:"worker_id"`
+	Environment   string `json:"environment"`
+	SourceID      string `json:"source_id"`
+	SourceVersion int    `json:"source_version"`
+	VersionID     int    `json:"version_id"`
+	Image         string `json:"image"`
+}
+
+// ========== Preferences ==========
+
+// PreferencesResponse GET /api/account/preferences，locales 是可选的邮件语言
+type PreferencesResponse struct {
+	Locale  string   `json:"locale"`
+	Locales []string `json:"locales"`
+}
+
+// SetPreferencesRequest PUT /api/account/preferences
+type SetPreferencesRequest struct {
+	Locale string `json:"locale" binding:"required,oneof=en zh"`
+}
+
+// ========== Account ==========
+
+// AccountResponse GET /api/account：资料、偏好和每种通知的渠道开关
+type AccountResponse struct {
+	dblayer.AccountProfile
+	Locales       []string                                  `json:"locales"`
+	Notifications map[string]dblayer.NotificationPreference `json:"notifications"`
+}
+
+// UpdateAccountRequest PATCH /api/account，不写的字段保持不变；
+// notifications 按通知类型（dblayer.NotificationKinds）修改邮件和 webhook 开关
+type UpdateAccountRequest struct {
+	DisplayName   *string                                  `json:"display_name" binding:"omitempty,max=64"`
+	DefaultRegion *string                                  `json:"default_region" binding:"omitempty,max=64"` // 空字符串表示不指定
+	Timezone      *string                                  `json:"timezone" binding:"omitempty,max=64,timezone"`
+	Locale        *string                                  `json:"locale" binding:"omitempty,oneof=en zh"`
+	Notifications map[string]NotificationPreferenceRequest `json:"notifications"`
+}
+
+// NotificationPreferenceRequest 一种通知的渠道开关，不写的保持不变
+type NotificationPreferenceRequest struct {
+	Email   *bool `json:"email"`
+	Webhook *bool `json:"webhook"`
+}
+
+// ========== Notifications ==========
+
+// ListNotificationsResponse GET /api/notifications，unread 是全部未读数，不受筛选和分页影响
+type ListNotificationsResponse struct {
+	Notifications []*dblayer.Notification `json:"notifications"`
+	Unread        int                     `json:"unread"`
+	NextCursor    string                  `json:"next_cursor,omitempty"`
+}
+
+// MarkNotificationsReadRequest POST /api/notifications/read，ids 为空时全部标为已读
+type MarkNotificationsReadRequest struct {
+	IDs []int `json:"ids" binding:"max=500"`
+}
+
+// MarkNotificationsReadResponse marked 是这次新标为已读的条数
+type MarkNotificationsReadResponse struct {
+	Marked int64 `json:"marked"`
+	Unread int   `json:"unread"`
+}
+
+// ========== Admin ==========
+
+// AdminOverviewResponse GET /admin/overview
+type AdminOverviewResponse struct {
+	GeneratedAt  time.Time                          `json:"generated_at"`
+	Totals       *dblayer.ResourceTotals            `json:"totals"`
+	Cluster      *controller.ClusterConsumption     `json:"cluster,omitempty"`
+	ClusterError string                             `json:"cluster_error,omitempty"`
+	Period       string                             `json:"period"`        // 本月，UTC
+	TopConsumers map[string][]dblayer.UsageConsumer `json:"top_consumers"` // 计量指标 -> 用量最多的用户
+}
+
+// AdminTenantsResponse GET /admin/tenants
+type AdminTenantsResponse struct {
+	Tenants []dblayer.TenantOverview `json:"tenants"`
+}
+
+// AdminFailuresResponse GET /admin/failures，jobs 只含本实例启动以来的失败
+type AdminFailuresResponse struct {
+	Jobs    []jobs.JobFailure       `json:"jobs"`
+	Deploys []dblayer.DeployFailure `json:"deploys"`
+	Domains []dblayer.DomainFailure `json:"domains"`
+}
+
+// AdminJobStatsResponse GET /admin/jobs/stats，只含本实例启动以来执行过的类型；耗时单位为秒
+type AdminJobStatsResponse struct {
+	Window string              `json:"window"`
+	Types  []jobs.JobTypeStats `json:"types"`
+}
+
+// AdminJobLeasesResponse GET /admin/jobs/leases
+type AdminJobLeasesResponse struct {
+	Leases []*dblayer.JobLease `json:"leases"`
+}
+
+// SettingResponse 一项运行时配置：value 是本实例当前生效的值，覆盖过时带 updated_by/updated_at
+type SettingResponse struct {
+	settings.Info
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// SettingsResponse GET /admin/settings
+type SettingsResponse struct {
+	Settings []SettingResponse `json:"settings"`
+}
+
+// SetSettingRequest PUT /admin/settings/:key
+type SetSettingRequest struct {
+	Value *string `json:"value" binding:"required"`
+}
+
+// SetPreviewConfigRequest PUT /api/worker/:id/preview：image_template 可用 {pr}、{sha}、{short_sha}、{branch} 占位，
+// github_token 为空表示不修改
+type SetPreviewConfigRequest struct {
+	Repo          string `json:"repo" binding:"required,max=255"`
+	ImageTemplate string `json:"image_template" binding:"required,max=512"`
+	WithDB        bool   `json:"with_db"`
+	GitHubToken   string `json:"github_token" binding:"max=255"`
+}
+
+// PreviewConfigResponse 预览配置，webhook_secret 只在首次创建时返回
+type PreviewConfigResponse struct {
+	*dblayer.PreviewConfig
+	WebhookURL    string `json:"webhook_url"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// PreviewEnvironmentResponse 一个 PR 的预览及其地址
+type PreviewEnvironmentResponse struct {
+	*dblayer.PreviewEnvironment
+	URL string `json:"url"`
+}
+
+// PreviewsResponse GET /api/worker/:id/preview
+type PreviewsResponse struct {
+	Config       PreviewConfigResponse        `json:"config"`
+	Environments []PreviewEnvironmentResponse `json:"environments"`
+}
+
+// SetGitHubBindingRequest PUT /api/worker/:id/github：image_template 可用 {sha}、{short_sha}、{branch} 占位；
+// workflow 非空时等该 workflow 在分支上成功运行后再部署，github_token 为空表示不修改
+type SetGitHubBindingRequest struct {
+	Repo          string `json:"repo" binding:"required,max=255"`
+	Branch        string `json:"branch" binding:"max=255"`
+	ImageTemplate string `json:"image_template" binding:"required,max=512"`
+	Workflow      string `json:"workflow" binding:"max=255"`
+	GitHubToken   string `json:"github_token" binding:"max=255"`
+}
+
+// GitHubBindingResponse 仓库绑定，webhook_secret 只在首次创建时返回
+type GitHubBindingResponse struct {
+	*dblayer.GitHubBinding
+	WebhookURL    string `json:"webhook_url"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// GitHubDeployResult 一次投递对一个绑定 worker 的处理结果
+type GitHubDeployResult struct {
+	WorkerID  string `json:"worker_id"`
+	CommitSHA string `json:"commit_sha"`
+	VersionID int    `json:"version_id,omitempty"`
+	Skipped   string `json:"skipped,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// GitHubWebhookResponse POST /api/webhooks/github
+type GitHubWebhookResponse struct {
+	Deploys []GitHubDeployResult `json:"deploys"`
+}
+
+// FeatureFlagsResponse GET /admin/flags
+type FeatureFlagsResponse struct {
+	Flags []dblayer.FeatureFlag `json:"flags"`
+}
+
+// SetFeatureFlagRequest PUT /admin/flags/:key：enabled 对所有人打开，否则按 rollout_percent 放量
+type SetFeatureFlagRequest struct {
+	Description    string `json:"description" binding:"max=500"`
+	Enabled        bool   `json:"enabled"`
+	RolloutPercent int    `json:"rollout_percent" binding:"min=0,max=100"`
+}
+
+// SetFeatureFlagUserRequest PUT /admin/flags/:key/users/:uid
+type SetFeatureFlagUserRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// SetJobLimitRequest PUT /admin/accounts/:uid/job-limits/:type
+type SetJobLimitRequest struct {
+	MaxRunning int `json:"max_running" binding:"required,min=1,max=100"`
+}
+
+// AccountJobLimitsResponse GET /admin/accounts/:uid/job-limits：running 是本实例上按类型正在执行的任务数
+type AccountJobLimitsResponse struct {
+	Limits  []*dblayer.TenantJobLimit `json:"limits"`
+	Running map[string]int            `json:"running"`
+}
+
+// CreateInvitationRequest POST /admin/invitations：code 为空时随机生成；max_uses 默认 1，0 表示不限次数
+type CreateInvitationRequest struct {
+	Code      string     `json:"code" binding:"omitempty,min=6,max=64"`
+	Email     string     `json:"email" binding:"omitempty,email,max=255"`
+	Note      string     `json:"note" binding:"max=500"`
+	MaxUses   *int       `json:"max_uses" binding:"omitempty,min=0,max=100000"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// InvitationsResponse GET /admin/invitations
+type InvitationsResponse struct {
+	Invitations []*dblayer.Invitation `json:"invitations"`
+}
+
+// WaitlistResponse GET /admin/waitlist
+type WaitlistResponse struct {
+	Entries []*dblayer.WaitlistEntry `json:"entries"`
+}
+
+// ApproveWaitlistRequest POST /admin/waitlist/:id/approve：邀请码的有效期，默认 7 天后
+type ApproveWaitlistRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ApproveWaitlistResponse emailed 为 false 时邀请邮件没有发出去，需要把 invitation.code 手动转交
+type ApproveWaitlistResponse struct {
+	Entry      *dblayer.WaitlistEntry `json:"entry"`
+	Invitation *dblayer.Invitation    `json:"invitation"`
+	Emailed    bool                   `json:"emailed"`
+}
+
+// MaintenanceStatus 维护模式状态，关闭时没有 message
+type MaintenanceStatus struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// FeaturesResponse GET /api/features 和 GET /admin/accounts/:uid/features：按用户评估的开关
+type FeaturesResponse struct {
+	Flags       map[string]bool   `json:"flags"`
+	Maintenance MaintenanceStatus `json:"maintenance"`
+}
+
+// MaintenanceErrorResponse 维护模式下写请求的 503
+type MaintenanceErrorResponse struct {
+	Error       string `json:"error"`
+	Maintenance bool   `json:"maintenance"`
+}
+
+// EmailTemplateSummary GET /admin/email-templates 中的一项，overrides 是有覆盖的语言
+type EmailTemplateSummary struct {
+	notify.TemplateInfo
+	Overrides []string `json:"overrides"`
+}
+
+// EmailTemplatesResponse GET /admin/email-templates
+type EmailTemplatesResponse struct {
+	Templates []EmailTemplateSummary `json:"templates"`
+	Locales   []string               `json:"locales"`
+}
+
+// EmailTemplateResponse GET /admin/email-templates/:name/:locale：生效的源码和内置源码
+type EmailTemplateResponse struct {
+	Name       string     `json:"name"`
+	Locale     string     `json:"locale"`
+	Source     string     `json:"source"`
+	Overridden bool       `json:"overridden"`
+	Builtin    string     `json:"builtin"`
+	UpdatedBy  string     `json:"updated_by,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	Sample     any        `json:"sample"`
+}
+
+// SetEmailTemplateRequest PUT /admin/email-templates/:name/:locale，保存前用示例数据渲染一遍
+type SetEmailTemplateRequest struct {
+	Source string `json:"source" binding:"required,max=65536"`
+}
+
+// PreviewEmailTemplateRequest POST /admin/email-templates/:name/:locale/preview；
+// source 为空时用生效的模板，data 为空时用示例数据
+type PreviewEmailTemplateRequest struct {
+	Source string          `json:"source" binding:"max=65536"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// TestEmailTemplateRequest POST /admin/email-templates/:name/:locale/test 渲染后发到 to
+type TestEmailTemplateRequest struct {
+	PreviewEmailTemplateRequest
+	To string `json:"to" binding:"required,email"`
+}
+
+// TestEmailTemplateResponse 测试邮件的渲染结果和发送 id
+type TestEmailTemplateResponse struct {
+	notify.Message
+	ID string `json:"id"`
+}
+
+// MailOverviewResponse GET /api/mail：SMTP 中继的连接方式和最近 24 小时的配额用量
+type MailOverviewResponse struct {
+	Enabled     bool   `json:"enabled"` // 没有配置 MAIL_RELAY_HOST 时为 false
+	Host        string `json:"host,omitempty"`
+	Port        int    `json:"port,omitempty"`
+	DailyQuota  int    `json:"daily_quota"`
+	SentLast24h int    `json:"sent_last_24h"`
+}
+
+// AddMailDomainRequest POST /api/mail/domains
+type AddMailDomainRequest struct {
+	Domain string `json:"domain" binding:"required,domain"`
+}
+
+// MailDomainResponse 发信域名和需要发布的 DNS 记录
+type MailDomainResponse struct {
+	*dblayer.MailDomain
+	Records []k8s.MailDNSRecord `json:"records"`
+}
+
+// ListMailDomainsResponse GET /api/mail/domains
+type ListMailDomainsResponse struct {
+	Domains []MailDomainResponse `json:"domains"`
+}
+
+// CreateMailCredentialRequest POST /api/mail/credentials
+type CreateMailCredentialRequest struct {
+	Name string `json:"name" binding:"required,slug"`
+}
+
+// CreateMailCredentialResponse 新凭据，password 只返回这一次
+type CreateMailCredentialResponse struct {
+	*dblayer.MailCredential
+	Password string `json:"password"`
+}
+
+// ListMailCredentialsResponse GET /api/mail/credentials
+type ListMailCredentialsResponse struct {
+	Credentials []*dblayer.MailCredential `json:"credentials"`
+}
+
+// AddMailSuppressionRequest POST /api/mail/suppressions 手动加入退订名单
+type AddMailSuppressionRequest struct {
+	Address string `json:"address" binding:"required,email,max=320"`
+	Detail  string `json:"detail,omitempty" binding:"max=1024"`
+}
+
+// ListMailSuppressionsResponse GET /api/mail/suppressions
+type ListMailSuppressionsResponse struct {
+	Suppressions []*dblayer.MailSuppression `json:"suppressions"`
+	NextCursor   string                     `json:"next_cursor,omitempty"`
+}
+
+// ListMailMessagesResponse GET /api/mail/logs
+type ListMailMessagesResponse struct {
+	Messages   []*dblayer.MailMessage `json:"messages"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// MailAuthorizeRequest POST /api/v1/mail/authorize (inner)：中继接收一封信之前带着 SMTP 凭据和信封来问；
+// 没有收件人时只检查凭据（SMTP AUTH 阶段）
+type MailAuthorizeRequest struct {
+	Username   string   `json:"username" binding:"required,max=64"`
+	Password   string   `json:"password" binding:"required,max=256"`
+	From       string   `json:"from" binding:"omitempty,email,max=320"`
+	Recipients []string `json:"recipients" binding:"max=100,dive,email,max=320"`
+	MessageID  string   `json:"message_id,omitempty" binding:"max=255"`
+	Subject    string   `json:"subject,omitempty"`
+}
+
+// MailRejection 一个不能发的收件人和原因
+type MailRejection struct {
+	Recipient string `json:"recipient"`
+	Reason    string `json:"reason"`
+}
+
+// MailDKIMKey 中继签名用的 DKIM 私钥
+type MailDKIMKey struct {
+	Domain     string `json:"domain"`
+	Selector   string `json:"selector"`
+	PrivateKey string `json:"private_key"` // PEM
+}
+
+// MailAuthorizeResponse 可以发的收件人、被拒绝的收件人和签名密钥；queue_id 用于上报投递结果
+type MailAuthorizeResponse struct {
+	UserUID  string          `json:"user_uid"`
+	QueueID  string          `json:"queue_id,omitempty"`
+	Accepted []string        `json:"accepted"`
+	Rejected []MailRejection `json:"rejected"`
+	DKIM     *MailDKIMKey    `json:"dkim,omitempty"`
+}
+
+// MailEvent 中继上报的一个收件人的投递结果
+type MailEvent struct {
+	QueueID   string `json:"queue_id" binding:"required,max=32"`
+	Recipient string `json:"recipient" binding:"required,max=320"`
+	Status    string `json:"status" binding:"required,oneof=delivered deferred bounced complained"`
+	Reason    string `json:"reason,omitempty"`
+	Permanent bool   `json:"permanent,omitempty"` // 退信是否是永久性的（5xx），永久退信的地址加入退订名单
+}
+
+// MailEventsRequest POST /api/v1/mail/events (inner)
+type MailEventsRequest struct {
+	Events []MailEvent `json:"events" binding:"required,max=1000,dive"`
+}
+
+// MailEventsResponse 更新了多少条发送日志，新加入退订名单的地址数
+type MailEventsResponse struct {
+	Updated    int `json:"updated"`
+	Suppressed int `json:"suppressed"`
+}
+
+// ========== Scheduled RDB tasks ==========
+
+// RDBTaskRequest 创建或整体替换定时 SQL 任务。schedule 是 cron 表达式，按 timezone（默认账号的时区）解释；
+// timeout_seconds 默认 300，最多 900；notify_email、enabled 默认 true
+type RDBTaskRequest struct {
+	Name           string `json:"name" binding:"required,slug"`
+	Schedule       string `json:"schedule" binding:"required,max=128,cron"`
+	Timezone       string `json:"timezone" binding:"omitempty,max=64,timezone"`
+	SQL            string `json:"sql" binding:"required,max=65536"`
+	TimeoutSeconds int    `json:"timeout_seconds" binding:"omitempty,min=1,max=900"`
+	NotifyEmail    *bool  `json:"notify_email"`
+	Enabled        *bool  `json:"enabled"`
+}
+
+type ListRDBTasksResponse struct {
+	Tasks      []*dblayer.RDBTask `json:"tasks"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// ListRDBTaskRunsResponse 执行记录，最新的在前
+type ListRDBTaskRunsResponse struct {
+	Runs       []*dblayer.RDBTaskRun `json:"runs"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 把托管的 RDB/KV/队列挂载到 worker：连接串以标准 env 名（DATABASE_URL、REDIS_URL、AMQP_URL，可用 ?env= 改名）
+// 写进 worker 的 Secret，由 inner 的 worker.sync_attachments 任务维护。
+// bucket 的 env 名是前缀（默认 S3），注入 S3_ENDPOINT、S3_BUCKET 等一组 key（controller.AttachmentKeys）
+
+// defaultAttachEnv 各类资源默认注入的 env 名
+var defaultAttachEnv = map[string]string{
+	"rdb":    "DATABASE_URL",
+	"kv":     "REDIS_URL",
+	"bucket": "S3",
+	"queue":  "AMQP_URL",
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Z_][A-Z0-9_]{0,127}$`)
+
+var errAttachedEnvKey = errors.New("key is managed by a resource attachment, detach it first")
+
+// AttachResource 把资源挂载到 worker；env 名已被 worker 的 env、secret 或其他挂载占用时返回 409
+func (h *WorkerHandler) AttachResource(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	resourceType, resourceID := c.Param("type"), c.Param("rid")
+	ctx := c.Request.Context()
+
+	envName := c.DefaultQuery("env", defaultAttachEnv[resourceType])
+	if envName == "" {
+		c.JSON(400, gin.H{"error": "resource type must be rdb, kv, bucket or queue"})
+		return
+	}
+	keys := controller.AttachmentKeys(resourceType, envName)
+	if !envNamePattern.MatchString(envName) || slices.ContainsFunc(keys, func(k string) bool { return slices.Contains(controller.ReservedEnvKeys, k) }) {
+		c.JSON(400, gin.H{"error": "invalid env name " + envName})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+	for _, key := range keys {
+		taken, err := workerEnvKeyTaken(ctx, workerID, userUID, key)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "worker not found"})
+			return
+		}
+		if taken {
+			c.JSON(409, gin.H{"error": "worker already has an env or secret named " + key})
+			return
+		}
+		if attached, err := attachedEnvKey(ctx, workerID, userUID, key); err != nil || attached {
+			c.JSON(409, gin.H{"error": key + " is used by another attachment"})
+			return
+		}
+	}
+
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.AttachWorkerResource(ctx, tx, workerID, userUID, resourceType, resourceID, envName); err != nil {
+			return err
+		}
+		return enqueueTask(ctx, tx, jobs.NewSyncAttachmentsJob(workerID, userUID))
+	})
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker or resource not found"})
+		return
+	case err == dblayer.ErrAttachmentExists:
+		c.JSON(409, gin.H{"error": "resource is already attached or " + envName + " is used by another attachment"})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": errEnqueueSync.Error()})
+		return
+	}
+	KickOutbox()
+	listAttachments(c, workerID, userUID, 201)
+}
+
+// DetachResource 取消挂载，注入的 env 随下次同步从 Secret 中删除
+func (h *WorkerHandler) DetachResource(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.DetachWorkerResource(ctx, tx, workerID, userUID, c.Param("type"), c.Param("rid")); err != nil {
+			return err
+		}
+		return enqueueTask(ctx, tx, jobs.NewSyncAttachmentsJob(workerID, userUID))
+	})
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "attachment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": errEnqueueSync.Error()})
+		return
+	}
+	KickOutbox()
+	listAttachments(c, workerID, userUID, 200)
+}
+
+// ListAttachments 获取 worker 挂载的资源和注入的 env 名
+func (h *WorkerHandler) ListAttachments(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	if _, err := dblayer.GetWorkerByOwner(c.Request.Context(), workerID, userUID); err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	listAttachments(c, workerID, userUID, 200)
+}
+
+func listAttachments(c *gin.Context, workerID, userUID string, status int) {
+	attachments, err := dblayer.ListWorkerAttachments(c.Request.Context(), workerID, userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list attachments"})
+		return
+	}
+	c.JSON(status, attachments)
+}
+
+// workerEnvKeyTaken worker 的 env 或 secret 中是否已有该 key；worker 不存在时返回错误
+func workerEnvKeyTaken(ctx context.Context, workerID, userUID, key string) (bool, error) {
+	envJSON, _, err := dblayer.GetWorkerEnvByOwner(ctx, workerID, userUID)
+	if err != nil {
+		return false, err
+	}
+	var env map[string]string
+	json.Unmarshal([]byte(envJSON), &env)
+	if _, ok := env[key]; ok {
+		return true, nil
+	}
+	secretsJSON, _, err := dblayer.GetWorkerSecretsByOwner(ctx, workerID, userUID)
+	if err != nil {
+		return false, err
+	}
+	var keys []string
+	json.Unmarshal([]byte(secretsJSON), &keys)
+	return slices.Contains(keys, key), nil
+}
+
+// attachedEnvKey key 是否是 worker 某个挂载注入的 env
+func attachedEnvKey(ctx context.Context, workerID, userUID, key string) (bool, error) {
+	attachments, err := dblayer.ListWorkerAttachments(ctx, workerID, userUID)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(attachments, func(a *dblayer.WorkerAttachment) bool {
+		return slices.Contains(controller.AttachmentKeys(a.ResourceType, a.EnvName), key)
+	}), nil
+}
@@ -2,38 +2,35 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
 	"io"
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/handlers/jobs"
-	"log"
+	"jabberwocky238/console/notify"
 	"strings"
 	"time"
 
-	"github.com/resend/resend-go/v3"
-
 	"github.com/gin-gonic/gin"
 )
 
 var SPECIAL_CODE = "701213"
-var RESEND_API_KEY string
-var ResendClient *resend.Client
+
+var errEmailExists = errors.New("email already exists")
 
 // Register handles user registration
 func Register(c *gin.Context) {
-	var req struct {
-		Email    string `json:"email" binding:"required"`
-		Password string `json:"password" binding:"required,min=2"`
-		Code     string `json:"code" binding:"required"`
-	}
+	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, bindError(err))
 		return
 	}
 
 	// Verify code
 	var codeID int
 	if req.Code != SPECIAL_CODE {
-		id, expiresAt, err := dblayer.GetVerificationCode(req.Email, req.Code)
+		id, expiresAt, err := dblayer.GetVerificationCode(c.Request.Context(), req.Email, req.Code)
 		if err != nil {
 			c.JSON(400, gin.H{"error": "invalid code: " + err.Error()})
 			return
@@ -46,6 +43,19 @@ func Register(c *gin.Context) {
 		}
 	}
 
+	// 邀请制和候补名单，见 signup.handler.go
+	req.InviteCode = normalizeInvitationCode(req.InviteCode)
+	if req.InviteCode == "" {
+		switch signupMode.Get() {
+		case SignupInvite:
+			c.JSON(403, gin.H{"error": "registration is by invitation only, an invite_code is required"})
+			return
+		case SignupWaitlist:
+			joinWaitlist(c, &req, codeID)
+			return
+		}
+	}
+
 	hash, err := HashPassword(req.Password)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to hash password: " + err.Error()})
@@ -55,70 +65,171 @@ func Register(c *gin.Context) {
 	// Generate secret key for HMAC
 	secretKey := GenerateSecretKey()
 
-	userUID, err := dblayer.CreateUser(GenerateUID(req.Email), req.Email, hash, secretKey)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "email already exists: " + err.Error()})
+	// 建用户、消费验证码、投递注册任务在同一个事务里，任何一步失败都不会留下半注册的用户
+	var userUID string
+	err = dblayer.WithTx(c.Request.Context(), func(tx *sql.Tx) error {
+		var err error
+		if userUID, err = dblayer.CreateUser(c.Request.Context(), tx, GenerateUID(req.Email), req.Email, hash, secretKey, requestLocale(c, req.Locale)); err != nil {
+			return fmt.Errorf("%w: %w", errEmailExists, err)
+		}
+		if req.Code != SPECIAL_CODE {
+			if err := dblayer.ConsumeVerificationCode(c.Request.Context(), tx, codeID); err != nil {
+				return err
+			}
+		}
+		if req.InviteCode != "" {
+			if _, err := dblayer.RedeemInvitation(c.Request.Context(), tx, req.InviteCode, req.Email); err == dblayer.ErrNotFound {
+				return errInvalidInvitation
+			} else if err != nil {
+				return err
+			}
+		}
+		// Enqueue userUID for post-registration setup
+		return enqueueTask(c.Request.Context(), tx, jobs.NewRegisterUserJob(userUID))
+	})
+	switch {
+	case errors.Is(err, errEmailExists):
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errInvalidInvitation):
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	case err == dblayer.ErrNotFound:
+		c.JSON(400, gin.H{"error": "code already used"})
+		return
+	case err != nil:
+		RequestLog(c).Error("register user failed", "email", req.Email, "error", err)
+		c.JSON(500, gin.H{"error": "failed to register user"})
 		return
 	}
+	KickOutbox()
 
-	// Mark code as used
-	if req.Code != SPECIAL_CODE {
-		dblayer.MarkCodeUsed(codeID)
-	}
-
-	// Enqueue userUID for post-registration setup
-	if err := SendTask(jobs.NewRegisterUserJob(userUID)); err != nil {
-		log.Printf("Failed to send register user task: %v", err)
-		c.JSON(500, gin.H{"error": "failed to enqueue registration task"})
-		return
+	// 注册时的设备作为第一个已知设备
+	lc := newLoginContext(c, req.Session)
+	if err := dblayer.TouchUserDevice(c.Request.Context(), userUID, lc.Device, lc.UserAgent, lc.IP, lc.Country); err != nil {
+		RequestLog(c).Error("record device failed", "user_id", userUID, "error", err)
 	}
+	recordAuthEvent(c, lc.event(&userUID, req.Email, dblayer.AuthEventRegister))
 
 	token, _ := GenerateToken(userUID, req.Email)
-	c.JSON(200, gin.H{
-		"user_id":    userUID,
-		"email":      req.Email,
-		"token":      token,
-		"secret_key": secretKey,
+	token, csrf := issueSession(c, req.Session, token)
+	c.JSON(200, RegisterResponse{
+		UserID:    userUID,
+		Email:     req.Email,
+		Token:     token,
+		CSRFToken: csrf,
+		SecretKey: secretKey,
 	})
 }
 
-// Login handles user login
+// Login handles user login. Failed attempts are throttled per IP and per
+// email, and a risky login (see authrisk.go) needs the email code sent on
+// the first attempt, resubmitted as code.
 func Login(c *gin.Context) {
-	var req struct {
-		Email    string `json:"email" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, bindError(err))
 		return
 	}
 
-	user, err := dblayer.GetUserByEmail(req.Email)
+	lc := newLoginContext(c, req.Session)
+	byEmail, byIP, err := dblayer.CountAuthFailures(c.Request.Context(), req.Email, lc.IP, time.Now().Add(-authFailureWindow))
 	if err != nil {
-		c.JSON(401, gin.H{"error": "invalid credentials"})
+		c.JSON(500, gin.H{"error": "failed to check sign-in attempts"})
+		return
+	}
+	if throttleLogin(c, lc, req.Email, byEmail, byIP) {
 		return
 	}
 
-	if !CheckPassword(req.Password, user.PasswordHash) {
+	user, err := dblayer.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil || !CheckPassword(req.Password, user.PasswordHash) {
+		var userUID *string
+		if user != nil {
+			userUID = &user.UID
+		}
+		ev := lc.event(userUID, req.Email, dblayer.AuthEventLoginFailed)
+		recordAuthEvent(c, ev)
+		if byEmail+1 == stepUpFailures {
+			notifySecurity(c, ev, jobs.SecurityNoticeFailedLogins)
+		}
 		c.JSON(401, gin.H{"error": "invalid credentials"})
 		return
 	}
 
+	risk, err := assessLogin(c.Request.Context(), user.UID, lc, byEmail)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to assess sign-in"})
+		return
+	}
+	if risk.Score >= StepUpRiskThreshold {
+		if req.Code == "" {
+			if err := sendStepUpCode(c.Request.Context(), user.Email); err != nil {
+				RequestLog(c).Error("send step-up code failed", "user_id", user.UID, "error", err)
+				c.JSON(500, gin.H{"error": "failed to send verification code"})
+				return
+			}
+			ev := lc.event(&user.UID, req.Email, dblayer.AuthEventStepUpRequired)
+			ev.Risk, ev.Reasons = risk.Score, risk.Reasons
+			recordAuthEvent(c, ev)
+			c.JSON(401, StepUpRequiredResponse{
+				Error:   "verification required, a code was sent to your email",
+				StepUp:  true,
+				Reasons: risk.Reasons,
+			})
+			return
+		}
+		if !checkStepUpCode(c.Request.Context(), user.Email, req.Code) {
+			ev := lc.event(&user.UID, req.Email, dblayer.AuthEventStepUpFailed)
+			ev.Risk, ev.Reasons = risk.Score, risk.Reasons
+			recordAuthEvent(c, ev)
+			c.JSON(401, gin.H{"error": "invalid verification code"})
+			return
+		}
+		risk.Reasons = append(risk.Reasons, riskReasonStepUpPassed)
+	}
+
+	if err := dblayer.TouchUserDevice(c.Request.Context(), user.UID, lc.Device, lc.UserAgent, lc.IP, lc.Country); err != nil {
+		RequestLog(c).Error("record device failed", "user_id", user.UID, "error", err)
+	}
+	ev := lc.event(&user.UID, req.Email, dblayer.AuthEventLoginSuccess)
+	ev.Risk, ev.Reasons = risk.Score, risk.Reasons
+	recordAuthEvent(c, ev)
+	if risk.NewDevice {
+		notifySecurity(c, ev, jobs.SecurityNoticeNewDevice)
+	}
+
 	token, _ := GenerateToken(user.UID, user.Email)
-	c.JSON(200, gin.H{"user_id": user.UID, "token": token})
+	token, csrf := issueSession(c, req.Session, token)
+	c.JSON(200, LoginResponse{UserID: user.UID, Token: token, CSRFToken: csrf})
 }
 
-// AuthMiddleware validates JWT token
+// AuthMiddleware validates JWT token.
+// Browser EventSource/WebSocket clients cannot set headers, so a `token`
+// query parameter is accepted when the Authorization header is absent.
+// Without either, the cookie session (SessionCookieName) is used and
+// CSRFMiddleware checks its state-changing requests.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
-		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if auth == "" {
+			token = c.Query("token")
+			if token == "" {
+				if cookie, err := c.Cookie(SessionCookieName); err == nil && cookie != "" {
+					token = cookie
+					c.Set(sessionCookieAuthKey, true)
+				}
+			}
+		} else if !strings.HasPrefix(auth, "Bearer ") {
+			token = ""
+		}
+		if token == "" {
 			c.JSON(401, gin.H{"error": "unauthorized"})
 			c.Abort()
 			return
 		}
 
-		token := strings.TrimPrefix(auth, "Bearer ")
 		userID, err := ValidateToken(token)
 		if err != nil {
 			c.JSON(401, gin.H{"error": "invalid token"})
@@ -156,7 +267,7 @@ func SignatureMiddleware() gin.HandlerFunc {
 		}
 
 		// Get user's secret key
-		secretKey, err := dblayer.GetUserSecretKey(userID)
+		secretKey, err := dblayer.GetUserSecretKey(c.Request.Context(), userID)
 		if err != nil {
 			c.JSON(401, gin.H{"error": "invalid user"})
 			c.Abort()
@@ -189,53 +300,44 @@ func SignatureMiddleware() gin.HandlerFunc {
 
 // SendCode sends verification code to email
 func SendCode(c *gin.Context) {
-	var req struct {
-		Email string `json:"email" binding:"required,email"`
-	}
+	var req SendCodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, bindError(err))
 		return
 	}
 
 	code := GenerateCode()
 	expiresAt := time.Now().Add(10 * time.Minute)
-	params := &resend.SendEmailRequest{
-		From:    "Combinator <combinator@enzyme.cloud>",
-		To:      []string{req.Email},
-		Html:    "<strong>Your verification code is: " + code + "</strong>",
-		Subject: code + " is your verification code for Combinator Console",
-		// Cc:      []string{"cc@example.com"},
-		// Bcc:     []string{"bcc@example.com"},
-		// ReplyTo: "replyto@example.com",
+	locale := req.Locale
+	if locale == "" {
+		// 重置密码时用户已经存在，按用户的设置
+		locale, _ = dblayer.GetLocaleByEmail(c.Request.Context(), req.Email)
 	}
-	sent, err := ResendClient.Emails.Send(params)
+	sent, err := notify.SendMailTo(c.Request.Context(), req.Email, requestLocale(c, locale),
+		notify.Mail{Template: notify.MailVerificationCode, Data: notify.CodeData{Code: code}})
 	if err != nil {
-		log.Printf("failed to send email: %v", err)
+		RequestLog(c).Error("send email failed", "error", err)
 		c.JSON(500, gin.H{"error": "failed to send email, " + err.Error()})
 		return
 	}
 
-	if err := dblayer.SaveVerificationCode(req.Email, code, expiresAt); err != nil {
+	if err := dblayer.SaveVerificationCode(c.Request.Context(), req.Email, code, expiresAt); err != nil {
 		c.JSON(500, gin.H{"error": "failed to save code"})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "code sent", "code": code, "misc": sent})
+	c.JSON(200, SendCodeResponse{Message: "code sent", Code: code, Misc: gin.H{"id": sent}})
 }
 
 // ResetPassword resets password with verification code
 func ResetPassword(c *gin.Context) {
-	var req struct {
-		Email       string `json:"email" binding:"required,email"`
-		Code        string `json:"code" binding:"required"`
-		NewPassword string `json:"new_password" binding:"required,min=8"`
-	}
+	var req ResetPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, bindError(err))
 		return
 	}
 
-	codeID, expiresAt, err := dblayer.GetVerificationCode(req.Email, req.Code)
+	codeID, expiresAt, err := dblayer.GetVerificationCode(c.Request.Context(), req.Email, req.Code)
 	if err != nil {
 		c.JSON(400, gin.H{"error": "invalid code"})
 		return
@@ -252,11 +354,11 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := dblayer.UpdateUserPassword(req.Email, hash); err != nil {
+	if err := dblayer.UpdateUserPassword(c.Request.Context(), req.Email, hash); err != nil {
 		c.JSON(500, gin.H{"error": "failed to update password"})
 		return
 	}
 
-	dblayer.MarkCodeUsed(codeID)
-	c.JSON(200, gin.H{"message": "password reset successfully"})
+	dblayer.MarkCodeUsed(c.Request.Context(), codeID)
+	c.JSON(200, MessageResponse{Message: "password reset successfully"})
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -138,7 +139,12 @@ func ValidateToken(tokenString string) (string, error) {
 
 // GenerateCode generates a 6-digit verification code
 func GenerateCode() string {
-	return fmt.Sprintf("%06d", time.Now().UnixNano()%1000000)
+	// Codes also gate step-up logins, so they must not follow from the clock
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%06d", n.Int64())
 }
 
 // GenerateSecretKey generates a 32-byte secret key for HMAC
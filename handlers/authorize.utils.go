@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resourceNames 404 消息里的资源名，和各 handler 原有的提示保持一致
+var resourceNames = map[string]string{
+	dblayer.ResourceWorker: "worker",
+	dblayer.ResourceDomain: "domain",
+	dblayer.ResourceRDB:    "resource",
+	dblayer.ResourceKV:     "resource",
+}
+
+// authorizeResource 用 dblayer.AuthorizeResource 确认当前用户拥有该资源，
+// 不满足时已写好 404（不存在和属于别人不做区分）或 500，并返回 false
+func authorizeResource(c *gin.Context, resourceType, id string) bool {
+	err := dblayer.AuthorizeResource(c.Request.Context(), c.GetString("user_id"), resourceType, id)
+	switch {
+	case err == nil:
+		return true
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": resourceNames[resourceType] + " not found"})
+	default:
+		RequestLog(c).Error("authorize resource failed", "resource_type", resourceType, "resource_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to check resource ownership"})
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 登录风控：每次登录记录 auth_events，按最近的失败次数、是否新设备、IP/国家是否变化打分，
+// 分数达到 StepUpRiskThreshold 时需要邮箱验证码（二次验证）才能登录。
+// 同一 IP 失败过多直接 429（撞库），同一邮箱失败过多暂时锁定，新设备登录和连续失败发通知邮件。
+// 设备用 DeviceCookieName 识别（cookie 会话时下发），没有 cookie 的 API 客户端按 User-Agent 识别。
+
+// DeviceCookieName 长期有效的设备 cookie，只用于识别设备，不是凭证
+const DeviceCookieName = "console_device"
+
+const (
+	authFailureWindow    = 15 * time.Minute // 统计失败次数的时间窗口
+	stepUpFailures       = 5                // 邮箱失败次数达到后必须二次验证，并通知用户
+	lockoutFailures      = 20               // 邮箱失败次数达到后窗口内拒绝登录
+	ipFailureLimit       = 30               // 同一 IP 失败次数达到后窗口内拒绝登录
+	stepUpCodeTTL        = 10 * time.Minute
+	deviceCookieLifetime = 365 * 24 * time.Hour
+)
+
+// StepUpRiskThreshold 需要二次验证的风险分
+const StepUpRiskThreshold = 60
+
+// 风险分的组成
+const (
+	riskReasonFailedLogins   = "failed_logins"   // 窗口内失败次数达到 stepUpFailures
+	riskReasonRecentFailures = "recent_failures" // 窗口内有失败，每次 +10，最多 +40
+	riskReasonNewDevice      = "new_device"      // +40，第一个设备不算
+	riskReasonNewCountry     = "new_country"     // 与上次登录的国家不同 +35
+	riskReasonNewNetwork     = "new_network"     // 与上次登录的网段（IPv4 /24、IPv6 /48）不同 +15
+	riskReasonStepUpPassed   = "step_up_passed"
+)
+
+// GeoCountryHeader 反向代理/CDN 写入的客户端国家代码请求头（如 CF-IPCountry，env GEO_COUNTRY_HEADER），
+// 未设置时不比较国家
+var GeoCountryHeader string
+
+// loginContext 登录请求的来源
+type loginContext struct {
+	IP        string
+	Country   string
+	UserAgent string
+	Device    string // 设备标识（sha256）
+}
+
+// newLoginContext 读取请求来源；cookie 会话且没有设备 cookie 时下发一个
+func newLoginContext(c *gin.Context, session string) loginContext {
+	lc := loginContext{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	if len(lc.UserAgent) > 512 {
+		lc.UserAgent = lc.UserAgent[:512]
+	}
+	if GeoCountryHeader != "" {
+		if country := strings.ToUpper(c.GetHeader(GeoCountryHeader)); len(country) == 2 {
+			lc.Country = country
+		}
+	}
+
+	device, err := c.Cookie(DeviceCookieName)
+	if (err != nil || device == "") && session == SessionCookie {
+		b := make([]byte, 16)
+		rand.Read(b)
+		device = hex.EncodeToString(b)
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     DeviceCookieName,
+			Value:    device,
+			Path:     "/",
+			Domain:   sessionCookieConfig.Domain,
+			MaxAge:   int(deviceCookieLifetime / time.Second),
+			Secure:   sessionCookieConfig.Secure,
+			HttpOnly: true,
+			SameSite: sessionCookieConfig.SameSite,
+		})
+	}
+	if device == "" {
+		device = "ua\n" + lc.UserAgent
+	}
+	sum := sha256.Sum256([]byte(device))
+	lc.Device = hex.EncodeToString(sum[:])
+	return lc
+}
+
+// event 以该来源构造一个安全事件
+func (lc loginContext) event(userUID *string, email, event string) *dblayer.AuthEvent {
+	return &dblayer.AuthEvent{
+		UserUID:   userUID,
+		Email:     email,
+		Event:     event,
+		IP:        lc.IP,
+		Country:   lc.Country,
+		UserAgent: lc.UserAgent,
+	}
+}
+
+// loginRisk 一次登录的风险评估
+type loginRisk struct {
+	Score     int
+	Reasons   []string
+	NewDevice bool
+}
+
+func (r *loginRisk) add(score int, reason string) {
+	r.Score += score
+	r.Reasons = append(r.Reasons, reason)
+}
+
+// assessLogin 对密码正确的登录打分，failures 为窗口内该邮箱的失败次数
+func assessLogin(ctx context.Context, userUID string, lc loginContext, failures int) (loginRisk, error) {
+	var risk loginRisk
+	if failures >= stepUpFailures {
+		risk.add(StepUpRiskThreshold, riskReasonFailedLogins)
+	} else if failures > 0 {
+		risk.add(min(failures*10, 40), riskReasonRecentFailures)
+	}
+
+	if _, err := dblayer.GetUserDevice(ctx, userUID, lc.Device); err == dblayer.ErrNotFound {
+		n, err := dblayer.CountUserDevices(ctx, userUID)
+		if err != nil {
+			return risk, err
+		}
+		if n > 0 {
+			risk.add(40, riskReasonNewDevice)
+			risk.NewDevice = true
+		}
+	} else if err != nil {
+		return risk, err
+	}
+
+	last, err := dblayer.LastLogin(ctx, userUID)
+	if err != nil {
+		return risk, err
+	}
+	if last != nil {
+		if last.Country != "" && lc.Country != "" && last.Country != lc.Country {
+			risk.add(35, riskReasonNewCountry)
+		} else if ipNetwork(last.IP) != ipNetwork(lc.IP) {
+			risk.add(15, riskReasonNewNetwork)
+		}
+	}
+	return risk, nil
+}
+
+// ipNetwork IPv4 取 /24、IPv6 取 /48，无法解析时原样返回
+func ipNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// recordAuthEvent 记录安全事件，失败只打日志，不影响登录结果
+func recordAuthEvent(c *gin.Context, ev *dblayer.AuthEvent) {
+	if err := dblayer.RecordAuthEvent(c.Request.Context(), ev); err != nil {
+		RequestLog(c).Error("record auth event failed", "event", ev.Event, "error", err)
+	}
+}
+
+// notifySecurity 投递安全通知邮件任务，失败只打日志
+func notifySecurity(c *gin.Context, ev *dblayer.AuthEvent, kind string) {
+	if ev.UserUID == nil || ev.ID == 0 {
+		return
+	}
+	job := jobs.NewSecurityNoticeJob(*ev.UserUID, ev.ID, kind, ev.IP, ev.Country, ev.UserAgent, ev.CreatedAt)
+	if err := SendTask(job); err != nil {
+		RequestLog(c).Error("enqueue security notice failed", "kind", kind, "error", err)
+	}
+}
+
+// throttleLogin 窗口内同一 IP 或同一邮箱失败过多时写好 429 并返回 true
+func throttleLogin(c *gin.Context, lc loginContext, email string, byEmail, byIP int) bool {
+	if byIP < ipFailureLimit && byEmail < lockoutFailures {
+		return false
+	}
+	recordAuthEvent(c, lc.event(nil, email, dblayer.AuthEventThrottled))
+	c.Header("Retry-After", strconv.Itoa(int(authFailureWindow/time.Second)))
+	c.JSON(429, gin.H{"error": "too many failed sign-ins, try again later"})
+	return true
+}
+
+// sendStepUpCode 给邮箱发二次验证的验证码
+func sendStepUpCode(ctx context.Context, email string) error {
+	code := GenerateCode()
+	if err := dblayer.SaveVerificationCode(ctx, email, code, time.Now().Add(stepUpCodeTTL)); err != nil {
+		return err
+	}
+	if notify.ResendClient == nil {
+		return nil
+	}
+	locale, err := dblayer.GetLocaleByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	_, err = notify.SendMailTo(ctx, email, locale, notify.Mail{Template: notify.MailStepUpCode, Data: notify.CodeData{Code: code}})
+	return err
+}
+
+// checkStepUpCode 校验并消耗二次验证的验证码
+func checkStepUpCode(ctx context.Context, email, code string) bool {
+	codeID, expiresAt, err := dblayer.GetVerificationCode(ctx, email, code)
+	if err != nil || time.Now().After(expiresAt) {
+		return false
+	}
+	dblayer.MarkCodeUsed(ctx, codeID)
+	return true
+}
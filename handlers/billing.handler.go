@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"jabberwocky238/console/billing"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+var billingLog = logging.Component("billing")
+
+// CreateCheckout 为用户创建 Stripe Checkout 会话，前端跳转到返回的 URL 付款
+func CreateCheckout(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	if !billing.Enabled() {
+		c.JSON(503, gin.H{"error": "billing is not configured"})
+		return
+	}
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if _, ok := billing.Prices[req.Plan]; !ok {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("plan %s is not for sale", req.Plan)})
+		return
+	}
+
+	email, err := dblayer.GetUserEmail(c.Request.Context(), userUID)
+	if err != nil {
+		RequestLog(c).Error("get user email failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create checkout session"})
+		return
+	}
+	// 已有 customer 的用户沿用，一个账户只对应一个 Stripe customer
+	var customerID string
+	if sub, err := dblayer.GetSubscription(c.Request.Context(), userUID); err == nil {
+		customerID = sub.CustomerID
+	} else if err != dblayer.ErrNotFound {
+		RequestLog(c).Error("get subscription failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create checkout session"})
+		return
+	}
+
+	session, err := billing.CreateCheckoutSession(c.Request.Context(), userUID, email, customerID, req.Plan)
+	if err != nil {
+		RequestLog(c).Error("create checkout session failed", "plan", req.Plan, "error", err)
+		c.JSON(502, gin.H{"error": "failed to create checkout session"})
+		return
+	}
+	c.JSON(200, CheckoutResponse{SessionID: session.ID, URL: session.URL})
+}
+
+// GetBillingSubscription 用户当前的订阅；没有订阅时返回 users.plan 对应的空订阅
+func GetBillingSubscription(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	sub, err := dblayer.GetSubscription(c.Request.Context(), userUID)
+	if err == dblayer.ErrNotFound {
+		plan, _, err := dblayer.GetAccountLimits(c.Request.Context(), userUID)
+		if err != nil {
+			RequestLog(c).Error("get account plan failed", "error", err)
+			c.JSON(500, gin.H{"error": "failed to get subscription"})
+			return
+		}
+		c.JSON(200, dblayer.Subscription{Plan: plan})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get subscription failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get subscription"})
+		return
+	}
+	c.JSON(200, sub)
+}
+
+// StripeWebhook 接收 Stripe 事件：订阅变化同步套餐，扣款失败开始宽限期，付款成功解除欠费。
+// 处理失败返回 500，Stripe 会重发；已处理过的事件直接返回 200
+func StripeWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	ev, err := billing.ParseWebhook(payload, c.GetHeader("Stripe-Signature"), time.Now())
+	if errors.Is(err, billing.ErrBadSignature) {
+		RequestLog(c).Warn("stripe webhook rejected", "error", err)
+		c.JSON(400, gin.H{"error": "invalid signature"})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	done, err := dblayer.StripeEventProcessed(c.Request.Context(), ev.ID)
+	if err != nil {
+		RequestLog(c).Error("check stripe event failed", "event", ev.ID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to process event"})
+		return
+	}
+	if done {
+		c.JSON(200, gin.H{"received": true})
+		return
+	}
+
+	if err := handleStripeEvent(c.Request.Context(), ev); err != nil {
+		RequestLog(c).Error("process stripe event failed", "event", ev.ID, "type", ev.Type, "error", err)
+		c.JSON(500, gin.H{"error": "failed to process event"})
+		return
+	}
+	if err := dblayer.MarkStripeEventProcessed(c.Request.Context(), ev.ID, ev.Type); err != nil {
+		RequestLog(c).Warn("mark stripe event failed", "event", ev.ID, "error", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// handleStripeEvent 按事件类型更新订阅；不关心的事件忽略
+func handleStripeEvent(ctx context.Context, ev *billing.Event) error {
+	switch ev.Type {
+	case "checkout.session.completed":
+		var s billing.CheckoutSession
+		if err := json.Unmarshal(ev.Data.Object, &s); err != nil {
+			return err
+		}
+		if s.ClientReferenceID == "" || s.Customer == "" {
+			return nil
+		}
+		return dblayer.LinkStripeCustomer(ctx, s.ClientReferenceID, s.Customer, s.Subscription)
+
+	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
+		var s billing.Subscription
+		if err := json.Unmarshal(ev.Data.Object, &s); err != nil {
+			return err
+		}
+		return syncSubscription(ctx, &s, ev.Type == "customer.subscription.deleted")
+
+	case "invoice.payment_failed":
+		var inv billing.Invoice
+		if err := json.Unmarshal(ev.Data.Object, &inv); err != nil {
+			return err
+		}
+		userUID, err := dblayer.MarkPastDue(ctx, inv.Customer)
+		if err == dblayer.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dblayer.PublishEvent(ctx, dblayer.Event{
+			UserUID:      userUID,
+			Kind:         dblayer.EventBillingPayment,
+			ResourceType: "subscription",
+			ResourceID:   inv.Subscription,
+			Status:       "failed",
+			Message:      fmt.Sprintf("payment failed (attempt %d), workers will be paused after %s unless it is settled", inv.AttemptCount, jobs.DunningGracePeriod.Get()),
+		})
+		return nil
+
+	case "invoice.paid", "invoice.payment_succeeded":
+		var inv billing.Invoice
+		if err := json.Unmarshal(ev.Data.Object, &inv); err != nil {
+			return err
+		}
+		userUID, err := dblayer.ClearPastDue(ctx, inv.Customer)
+		if err == dblayer.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// 已暂停的 worker 由 dunning 任务恢复，这里立即触发一次，不等下一个周期
+		if suspended, _ := dblayer.WorkersSuspended(ctx, userUID); suspended {
+			if err := SendTask(jobs.NewDunningJob()); err != nil {
+				billingLog.Warn("enqueue dunning task failed", "user_id", userUID, "error", err)
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// syncSubscription 把订阅对应的套餐写入 users.plan，并让 inner 按新套餐更新租户配额。
+// 订阅失效（取消、unpaid 等）时降回默认套餐
+func syncSubscription(ctx context.Context, s *billing.Subscription, deleted bool) error {
+	userUID := s.Metadata["user_uid"]
+	if userUID == "" {
+		uid, err := dblayer.SubscriptionUserByCustomer(ctx, s.Customer)
+		if err == dblayer.ErrNotFound {
+			billingLog.Warn("subscription of unknown customer", "customer", s.Customer, "subscription", s.ID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		userUID = uid
+	}
+
+	plan := billing.PlanForPrice(s.PriceID())
+	if plan == "" || deleted || !s.Entitled() {
+		plan = dblayer.DefaultPlan
+	}
+	var periodEnd *time.Time
+	if t := s.PeriodEnd(); !t.IsZero() {
+		periodEnd = &t
+	}
+	if err := dblayer.SyncSubscription(ctx, userUID, s.Customer, s.ID, plan, s.Status, periodEnd); err != nil {
+		return err
+	}
+	if err := SendTask(jobs.NewSyncPlanJob(userUID)); err != nil {
+		billingLog.Warn("enqueue plan sync failed", "user_id", userUID, "error", err)
+	}
+	return nil
+}
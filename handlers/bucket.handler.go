@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 对象存储 bucket 和 RDB/KV 一样记在 combinator_resources，由 inner 在 S3 兼容存储上创建；
+// 挂载到 worker 后以 env 前缀注入 endpoint、bucket 名和只能访问这个 bucket 的临时凭据
+
+// CreateBucket creates a bucket resource record and submits async job; the
+// lifecycle rules in the request are applied once the bucket exists
+func (h *CombinatorHandler) CreateBucket(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	var req CreateBucketRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, bindError(err))
+			return
+		}
+	}
+	if msg := checkLifecycleRules(req.Lifecycle); msg != "" {
+		c.JSON(400, gin.H{"error": msg})
+		return
+	}
+	ctx := c.Request.Context()
+	if err := checkBucketLimit(ctx, userUID); err != nil {
+		limitResponse(c, err)
+		return
+	}
+
+	resourceID, err := createCombinatorResource(ctx, userUID, "bucket", req.Name, req.Tags, req.Environment)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Lifecycle) > 0 {
+		if _, err := setBucketLifecycle(ctx, userUID, resourceID, req.Lifecycle, 0); err != nil {
+			RequestLog(c).Error("save bucket lifecycle failed", "resource_id", resourceID, "error", err)
+			c.JSON(500, gin.H{"error": "bucket created, but saving its lifecycle rules failed"})
+			return
+		}
+	}
+
+	c.JSON(200, ResourceCreatedResponse{ID: resourceID, Status: "loading"})
+}
+
+// ListBuckets lists bucket resources for user from database, one page at a time
+func (h *CombinatorHandler) ListBuckets(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Type = "bucket"
+
+	resources, next, err := dblayer.ListCombinatorResources(c.Request.Context(), userUID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list resources: " + err.Error()})
+		return
+	}
+
+	total, err := dblayer.SumBucketBytes(c.Request.Context(), userUID)
+	if err != nil {
+		RequestLog(c).Warn("sum bucket size failed", "error", err)
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListBucketsResponse{Buckets: resources, TotalBytes: total, NextCursor: next})
+}
+
+// GetBucket returns detail of a single bucket including its measured size and lifecycle rules
+func (h *CombinatorHandler) GetBucket(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	resourceID := c.Param("id")
+	ctx := c.Request.Context()
+
+	cr, err := dblayer.GetCombinatorResource(ctx, userUID, "bucket", resourceID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "resource not found"})
+		return
+	}
+	b, err := dblayer.GetObjectBucket(ctx, userUID, resourceID)
+	if err != nil {
+		RequestLog(c).Error("get bucket failed", "resource_id", resourceID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get bucket"})
+		return
+	}
+
+	c.JSON(200, BucketDetailResponse{
+		ID:           cr.ID,
+		ResourceID:   cr.ResourceID,
+		Name:         cr.Name,
+		Status:       cr.Status,
+		Msg:          cr.Msg,
+		CreatedAt:    cr.CreatedAt,
+		BucketName:   b.BucketName,
+		Lifecycle:    b.Lifecycle,
+		SizeBytes:    b.SizeBytes,
+		ObjectCount:  b.ObjectCount,
+		MeasuredAt:   b.MeasuredAt,
+		KeyExpiresAt: b.KeyExpiresAt,
+	})
+}
+
+// DeleteBucket deletes a bucket resource record and submits async job, which
+// deletes every object in it
+func (h *CombinatorHandler) DeleteBucket(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	resourceID := c.Param("id")
+
+	if !checkResourcePrecondition(c, userUID, "bucket", resourceID) {
+		return
+	}
+	if err := deleteCombinatorResource(c.Request.Context(), userUID, "bucket", resourceID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "resource not found"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// SetBucketTags replaces the tags of a bucket resource
+func (h *CombinatorHandler) SetBucketTags(c *gin.Context) {
+	h.setTags(c, "bucket")
+}
+
+// SetBucketEnvironment moves a bucket resource to another environment
+func (h *CombinatorHandler) SetBucketEnvironment(c *gin.Context) {
+	h.setEnvironment(c, "bucket")
+}
+
+// SetBucketLifecycle replaces the lifecycle rules of a bucket
+func (h *CombinatorHandler) SetBucketLifecycle(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	resourceID := c.Param("id")
+
+	var req SetBucketLifecycleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if msg := checkLifecycleRules(req.Rules); msg != "" {
+		c.JSON(400, gin.H{"error": msg})
+		return
+	}
+	if !checkResourcePrecondition(c, userUID, "bucket", resourceID) {
+		return
+	}
+
+	version, err := setBucketLifecycle(c.Request.Context(), userUID, resourceID, req.Rules, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "resource not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Rules == nil {
+		req.Rules = []dblayer.BucketLifecycleRule{}
+	}
+	c.JSON(200, BucketLifecycleResponse{Rules: req.Rules, Version: version})
+}
+
+// setBucketLifecycle 写库并在同一个事务里投递应用规则的任务
+func setBucketLifecycle(ctx context.Context, userUID, resourceID string, rules []dblayer.BucketLifecycleRule, expectedVersion int) (int, error) {
+	var version int
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		version, err = dblayer.SetBucketLifecycle(ctx, tx, userUID, resourceID, rules, expectedVersion)
+		if err != nil {
+			return err
+		}
+		if err := enqueueTask(ctx, tx, jobs.NewBucketLifecycleJob(userUID, resourceID)); err != nil {
+			return errEnqueueSync
+		}
+		return nil
+	})
+	if err != nil {
+		return version, err
+	}
+	KickOutbox()
+	return version, nil
+}
+
+// checkLifecycleRules 规则 ID 不能重复，每条规则至少有一个动作；通过时返回空串
+func checkLifecycleRules(rules []dblayer.BucketLifecycleRule) string {
+	seen := map[string]bool{}
+	for _, r := range rules {
+		if seen[r.ID] {
+			return "duplicate lifecycle rule id " + r.ID
+		}
+		seen[r.ID] = true
+		if r.ExpirationDays == 0 && r.AbortIncompleteUploadDays == 0 {
+			return "lifecycle rule " + r.ID + " needs expiration_days or abort_incomplete_upload_days"
+		}
+	}
+	return ""
+}
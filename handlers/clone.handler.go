@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// errInvalidClone 克隆请求本身的问题，Error() 直接返回给调用方
+var errInvalidClone = errors.New("invalid clone")
+
+// CloneWorker POST /api/worker/:id/clone 复制 worker 的配置、env、env 组、出站和路由规则、入口限制、错误页和挂载到新 worker，
+// 源 worker 部署过时用同一个镜像部署。worker secret 只有 copy_secrets 时才复制，值由 inner 从源 worker 的 Secret 读出
+func (h *WorkerHandler) CloneWorker(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	sourceID := c.Param("id")
+
+	var req CloneWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	resp, err := cloneWorker(c.Request.Context(), userUID, sourceID, req)
+	var rejected *DeployRejectedError
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+	case errors.Is(err, errPlanLimit):
+		limitResponse(c, err)
+	case errors.Is(err, errInvalidClone):
+		c.JSON(400, gin.H{"error": strings.TrimPrefix(err.Error(), errInvalidClone.Error()+": ")})
+	case errors.As(err, &rejected):
+		c.JSON(422, DeployRejectedResponse{Error: "deploy rejected by policy", Violations: rejected.Violations})
+	case err == errAccountSuspended:
+		c.JSON(402, gin.H{"error": err.Error()})
+	case err != nil:
+		RequestLog(c).Error("clone worker failed", "worker_id", sourceID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to clone worker"})
+	default:
+		c.JSON(200, resp)
+	}
+}
+
+// cloneWorker 创建克隆并部署。克隆的各行和部署、复制任务在一个事务里提交，任何一步失败都不留下克隆
+func cloneWorker(ctx context.Context, userUID, sourceID string, req CloneWorkerRequest) (CloneWorkerResponse, error) {
+	src, err := dblayer.GetWorkerByOwner(ctx, sourceID, userUID)
+	if err != nil {
+		return CloneWorkerResponse{}, dblayer.ErrNotFound
+	}
+	if err := checkWorkerLimits(ctx, userUID, true, workerSpec{
+		CPU: src.AssignedCPU, Memory: src.AssignedMemory, MaxReplicas: src.MaxReplicas,
+		RunAsRoot: src.RunAsRoot, WritableRootFS: src.WritableRootFS,
+	}); err != nil {
+		return CloneWorkerResponse{}, err
+	}
+
+	tags := maps.Clone(src.Tags)
+	if tags == nil {
+		tags = dblayer.Tags{}
+	}
+	maps.Copy(tags, req.Tags)
+	if !isTagSet(tags) {
+		return CloneWorkerResponse{}, fmt.Errorf("%w: at most %d tags together with the source worker's", errInvalidClone, maxTags)
+	}
+	// 换了区域又没指定集群时重新调度，源 worker 的集群不一定在那个区域
+	region, cluster := src.MainRegion, src.Cluster
+	if req.MainRegion != "" && req.MainRegion != src.MainRegion {
+		region, cluster = req.MainRegion, ""
+	}
+	cluster = cmp.Or(req.Cluster, cluster)
+
+	resp := CloneWorkerResponse{WorkerID: uuid.New().String()[:8], WorkerName: req.WorkerName, SourceID: src.WID}
+
+	// 部署前的检查在事务之外做；克隆的资源配置和源 worker 一样，按源 worker 校验
+	var deploy *DeployWorkerRequest
+	if src.ActiveVersionID != nil {
+		v, err := dblayer.GetDeployVersion(ctx, *src.ActiveVersionID)
+		if err != nil {
+			return CloneWorkerResponse{}, fmt.Errorf("get active version: %w", err)
+		}
+		deploy = &DeployWorkerRequest{
+			UserUID: userUID, WorkerID: resp.WorkerID, Image: v.Image, Port: v.Port, CommitSHA: v.CommitSHA,
+			Initiator: dblayer.DeployInitiatorClone, Static: v.Static,
+		}
+		if err := checkDeploy(ctx, *deploy, src.WID); err != nil {
+			return CloneWorkerResponse{}, err
+		}
+	}
+
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.CreateWorker(ctx, tx, resp.WorkerID, userUID, req.WorkerName,
+			src.AssignedCPU, src.AssignedMemory, src.AssignedDisk, src.MaxReplicas, region, cluster, src.Pool, src.Arch,
+			src.RunAsRoot, src.WritableRootFS, tags, cmp.Or(req.Environment, src.Environment), src.Kind); err != nil {
+			return fmt.Errorf("create worker: %w", err)
+		}
+		if err := setupClone(ctx, tx, src, req, &resp); err != nil {
+			return err
+		}
+		if deploy == nil {
+			return nil
+		}
+		versionID, err := createDeploy(ctx, tx, *deploy)
+		if err != nil {
+			return err
+		}
+		resp.VersionID = versionID
+		// 新 worker 的 ConfigMap 和 Secret 在部署时才建好，env 和 secret 值由 inner 等它们建好后写入
+		if err := enqueueTask(ctx, tx, jobs.NewCloneWorkerJob(src.WID, resp.WorkerID, userUID, req.CopySecrets)); err != nil {
+			return errEnqueueSync
+		}
+		return nil
+	})
+	if err != nil {
+		return CloneWorkerResponse{}, err
+	}
+	KickOutbox()
+	return resp, nil
+}
+
+// setupClone 在 tx 里复制源 worker 的设置到刚建好的克隆，都在首次部署之前写库，部署任务会一并带上
+func setupClone(ctx context.Context, tx *sql.Tx, src *dblayer.Worker, req CloneWorkerRequest, resp *CloneWorkerResponse) error {
+	wid, userUID := resp.WorkerID, src.UserUID
+
+	if _, err := dblayer.SetWorkerEnvByOwner(ctx, tx, wid, userUID, src.EnvJSON, 0); err != nil {
+		return fmt.Errorf("copy env: %w", err)
+	}
+	if req.CopySecrets {
+		if _, err := dblayer.SetWorkerSecretsByOwner(ctx, tx, wid, userUID, src.SecretsJSON, 0); err != nil {
+			return fmt.Errorf("copy secret keys: %w", err)
+		}
+		json.Unmarshal([]byte(src.SecretsJSON), &resp.Secrets)
+	}
+
+	groups, err := dblayer.GetWorkerEnvGroups(ctx, src.WID)
+	if err != nil {
+		return fmt.Errorf("get env groups: %w", err)
+	}
+	if len(groups) > 0 {
+		names := make([]string, len(groups))
+		for i, g := range groups {
+			names[i] = g.Name
+		}
+		if _, err := dblayer.SetWorkerEnvGroups(ctx, tx, wid, userUID, names, 0); err != nil {
+			return fmt.Errorf("copy env groups: %w", err)
+		}
+	}
+
+	egress, err := dblayer.GetWorkerEgress(ctx, src.WID)
+	if err != nil {
+		return fmt.Errorf("get egress: %w", err)
+	}
+	if _, err := dblayer.SetWorkerEgressTx(ctx, tx, wid, userUID, egress, 0); err != nil {
+		return fmt.Errorf("copy egress: %w", err)
+	}
+
+	routes, err := dblayer.GetWorkerRoutes(ctx, src.WID)
+	if err != nil {
+		return fmt.Errorf("get routes: %w", err)
+	}
+	if len(routes.Rules) > 0 {
+		rules, err := k8s.NormalizeRouteRules(cloneRouteRules(routes.Rules, src.WID, wid, userUID), controller.WorkerHost(wid, userUID))
+		if err != nil {
+			return fmt.Errorf("copy routes: %w", err)
+		}
+		if _, err := dblayer.SetWorkerRoutes(ctx, tx, wid, userUID, rules, 0); err != nil {
+			return fmt.Errorf("copy routes: %w", err)
+		}
+	}
+
+	limits, err := dblayer.GetWorkerEdgeLimits(ctx, src.WID)
+	if err != nil {
+		return fmt.Errorf("get edge limits: %w", err)
+	}
+	if _, err := dblayer.SetWorkerEdgeLimits(ctx, tx, wid, userUID, limits, 0); err != nil {
+		return fmt.Errorf("copy edge limits: %w", err)
+	}
+
+	pages, err := dblayer.GetWorkerErrorPages(ctx, src.WID, userUID)
+	if err != nil {
+		return fmt.Errorf("get error pages: %w", err)
+	}
+	// 错误页照抄，维护开关不抄：副本一般是拿来测试的
+	pages.Maintenance = false
+	if _, err := dblayer.SetWorkerErrorPages(ctx, tx, wid, userUID, pages, 0); err != nil {
+		return fmt.Errorf("copy error pages: %w", err)
+	}
+
+	// 挂载共用同一个资源，连接串由 controller 建 Secret 时写入
+	attachments, err := dblayer.ListWorkerAttachments(ctx, src.WID, userUID)
+	if err != nil {
+		return fmt.Errorf("list attachments: %w", err)
+	}
+	for _, a := range attachments {
+		if err := dblayer.AttachWorkerResource(ctx, tx, wid, userUID, a.ResourceType, a.ResourceID, a.EnvName); err != nil {
+			return fmt.Errorf("copy attachment %s/%s: %w", a.ResourceType, a.ResourceID, err)
+		}
+	}
+	return nil
+}
+
+// cloneRouteRules 把规则里源 worker 的主机名（<wid>-<uid>.worker...及其兄弟主机）换成克隆的
+func cloneRouteRules(rules []dblayer.RouteRule, sourceID, workerID, userUID string) []dblayer.RouteRule {
+	from, to := controller.WorkerHost(sourceID, userUID), controller.WorkerHost(workerID, userUID)
+	out := make([]dblayer.RouteRule, len(rules))
+	for i, r := range rules {
+		out[i] = r
+		out[i].Hosts = make([]string, len(r.Hosts))
+		for j, h := range r.Hosts {
+			if prefix, ok := strings.CutSuffix(h, from); ok {
+				h = prefix + to
+			}
+			out[i].Hosts[j] = h
+		}
+	}
+	return out
+}
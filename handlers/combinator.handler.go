@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/handlers/jobs"
 	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,101 +25,141 @@ func NewCombinatorHandler() *CombinatorHandler {
 // CreateRDB creates a new RDB resource record and submits async job
 func (h *CombinatorHandler) CreateRDB(c *gin.Context) {
 	userUID := c.GetString("user_id")
-	var req struct {
-		Name string `json:"name" binding:"required"`
-	}
+	var req CreateRDBRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, bindError(err))
 		return
 	}
-
-	resourceID := GenerateResourceUID()
-	if err := dblayer.CreateCombinatorResource(userUID, "rdb", resourceID); err != nil {
-		c.JSON(500, gin.H{"error": "failed to create resource: " + err.Error()})
+	if err := checkRDBLimit(c.Request.Context(), userUID); err != nil {
+		limitResponse(c, err)
 		return
 	}
 
-	if err := SendTask(jobs.NewCreateRDBJob(userUID, req.Name, resourceID)); err != nil {
-		c.JSON(500, gin.H{"error": "failed to enqueue create task"})
+	resourceID, err := createCombinatorResource(c.Request.Context(), userUID, "rdb", req.Name, req.Tags, req.Environment)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"id": resourceID, "status": "loading"})
+	c.JSON(200, ResourceCreatedResponse{ID: resourceID, Status: "loading"})
 }
 
-// ListRDBs lists all RDB resources for user from database
+// ListRDBs lists RDB resources for user from database, one page at a time
 func (h *CombinatorHandler) ListRDBs(c *gin.Context) {
 	userUID := c.GetString("user_id")
 
-	resources, err := dblayer.ListCombinatorResources(userUID, "rdb")
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Type = "rdb"
+
+	resources, next, err := dblayer.ListCombinatorResources(c.Request.Context(), userUID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to list resources: " + err.Error()})
 		return
 	}
 
+	if err := dblayer.MarkPendingApply(c.Request.Context(), userUID, resources); err != nil {
+		RequestLog(c).Warn("read config revision failed", "error", err)
+	}
+
 	var dbSize int64
 	if k8s.RDBManager != nil {
 		dbSize, _ = k8s.RDBManager.DatabaseSize(userUID)
 	}
 
-	c.JSON(200, gin.H{"rdbs": resources, "database_size": dbSize})
+	setNextCursor(c, next)
+	c.JSON(200, ListRDBsResponse{RDBs: resources, DatabaseSize: dbSize, NextCursor: next})
 }
 
 // GetRDB returns detail of a single RDB resource including schema size
 func (h *CombinatorHandler) GetRDB(c *gin.Context) {
 	userUID := c.GetString("user_id")
 	resourceID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceRDB, resourceID) {
+		return
+	}
 
-	cr, err := dblayer.GetCombinatorResource(userUID, "rdb", resourceID)
+	cr, err := dblayer.GetCombinatorResource(c.Request.Context(), userUID, "rdb", resourceID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "resource not found"})
 		return
 	}
+	if notModified(c, cr.UpdatedAt) {
+		return
+	}
+	if err := dblayer.MarkPendingApply(c.Request.Context(), userUID, []*dblayer.CombinatorResource{cr}); err != nil {
+		RequestLog(c).Warn("read config revision failed", "error", err)
+	}
 
 	var schemaSize int64
 	if k8s.RDBManager != nil {
 		schemaSize, _ = k8s.RDBManager.SchemaSize(userUID, cr.ResourceID)
 	}
 
-	c.JSON(200, gin.H{
-		"id":          cr.ID,
-		"resource_id": cr.ResourceID,
-		"status":      cr.Status,
-		"msg":         cr.Msg,
-		"created_at":  cr.CreatedAt,
-		"schema_size": schemaSize,
+	c.JSON(200, RDBDetailResponse{
+		ID:         cr.ID,
+		ResourceID: cr.ResourceID,
+		Status:     cr.Status,
+		Msg:        cr.Msg,
+		CreatedAt:  cr.CreatedAt,
+		SchemaSize: schemaSize,
 	})
 }
 
 // CreateKV creates a new KV resource record and submits async job
 func (h *CombinatorHandler) CreateKV(c *gin.Context) {
 	userUID := c.GetString("user_id")
-
-	resourceID := GenerateResourceUID()
-	if err := dblayer.CreateCombinatorResource(userUID, "kv", resourceID); err != nil {
-		c.JSON(500, gin.H{"error": "failed to create resource: " + err.Error()})
-		return
+	var req CreateKVRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, bindError(err))
+			return
+		}
 	}
 
-	if err := SendTask(jobs.NewCreateKVJob(userUID, resourceID)); err != nil {
-		c.JSON(500, gin.H{"error": "failed to enqueue create task"})
+	resourceID, err := createCombinatorResource(c.Request.Context(), userUID, "kv", "", nil, req.Environment)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"id": resourceID, "status": "loading"})
+	c.JSON(200, ResourceCreatedResponse{ID: resourceID, Status: "loading"})
 }
 
-// ListKVs lists all KV resources for user from database
+// ListKVs lists KV resources for user from database, one page at a time
 func (h *CombinatorHandler) ListKVs(c *gin.Context) {
 	userUID := c.GetString("user_id")
 
-	resources, err := dblayer.ListCombinatorResources(userUID, "kv")
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Type = "kv"
+
+	resources, next, err := dblayer.ListCombinatorResources(c.Request.Context(), userUID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to list resources: " + err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"kvs": resources})
+	if err := dblayer.MarkPendingApply(c.Request.Context(), userUID, resources); err != nil {
+		RequestLog(c).Warn("read config revision failed", "error", err)
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListKVsResponse{KVs: resources, NextCursor: next})
 }
 
 // DeleteRDB deletes an RDB resource record and submits async job
@@ -119,45 +167,272 @@ func (h *CombinatorHandler) DeleteRDB(c *gin.Context) {
 	userUID := c.GetString("user_id")
 	resourceID := c.Param("id")
 
-	cr, err := dblayer.GetCombinatorResource(userUID, "rdb", resourceID)
-	if err != nil {
-		c.JSON(404, gin.H{"error": "resource not found"})
+	if !checkResourcePrecondition(c, userUID, "rdb", resourceID) {
 		return
 	}
-
-	if err := dblayer.DeleteCombinatorResource(userUID, "rdb", resourceID); err != nil {
-		c.JSON(500, gin.H{"error": "failed to delete resource: " + err.Error()})
+	if err := deleteCombinatorResource(c.Request.Context(), userUID, "rdb", resourceID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "resource not found"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	if err := SendTask(jobs.NewDeleteRDBJob(userUID, cr.ResourceID)); err != nil {
-		c.JSON(500, gin.H{"error": "failed to enqueue delete task"})
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// DeleteKV deletes a KV resource record and submits async job
+func (h *CombinatorHandler) DeleteKV(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	resourceID := c.Param("id")
+
+	if !checkResourcePrecondition(c, userUID, "kv", resourceID) {
+		return
+	}
+	if err := deleteCombinatorResource(c.Request.Context(), userUID, "kv", resourceID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "resource not found"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "deleted"})
+	c.JSON(200, MessageResponse{Message: "deleted"})
 }
 
-// DeleteKV deletes a KV resource record and submits async job
-func (h *CombinatorHandler) DeleteKV(c *gin.Context) {
+// SetRDBTags replaces the tags of an RDB resource
+func (h *CombinatorHandler) SetRDBTags(c *gin.Context) {
+	h.setTags(c, "rdb")
+}
+
+// SetKVTags replaces the tags of a KV resource
+func (h *CombinatorHandler) SetKVTags(c *gin.Context) {
+	h.setTags(c, "kv")
+}
+
+// setTags RDB/KV 没有对应的 K8s 对象，只写库
+func (h *CombinatorHandler) setTags(c *gin.Context, resourceType string) {
 	userUID := c.GetString("user_id")
 	resourceID := c.Param("id")
 
-	cr, err := dblayer.GetCombinatorResource(userUID, "kv", resourceID)
+	var req SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !checkResourcePrecondition(c, userUID, resourceType, resourceID) {
+		return
+	}
+
+	version, err := dblayer.SetCombinatorResourceTags(c.Request.Context(), userUID, resourceType, resourceID, req.Tags, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "resource not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": "failed to set tags"})
+		return
+	}
+
+	c.JSON(200, TagsResponse{Tags: req.Tags, Version: version})
+}
+
+// checkResourcePrecondition 写 RDB/KV 前校验 If-Match，不满足时已写好响应并返回 false
+func checkResourcePrecondition(c *gin.Context, userUID, resourceType, resourceID string) bool {
+	if !hasPrecondition(c) {
+		return true
+	}
+	cr, err := dblayer.GetCombinatorResource(c.Request.Context(), userUID, resourceType, resourceID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "resource not found"})
+		return false
+	}
+	return !preconditionFailed(c, cr.UpdatedAt)
+}
+
+// CombinatorAppStatus (inner) reads the user's CombinatorApp CR and its Deployment
+func CombinatorAppStatus(c *gin.Context) {
+	userUID := c.Query("user_uid")
+	if userUID == "" {
+		c.JSON(400, gin.H{"error": "user_uid is required"})
+		return
+	}
+	if k8s.DynamicClient == nil {
+		c.JSON(503, gin.H{"error": "k8s client not available"})
 		return
 	}
+	status, err := controller.GetCombinatorAppStatus(c.Request.Context(), k8s.DynamicClient, userUID)
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, status)
+}
+
+// GetAppStatus (outer) returns the status of the user's dedicated combinator from inner
+func (h *CombinatorHandler) GetAppStatus(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	q := url.Values{"user_uid": {userUID}}
+	status, err := getInner[controller.CombinatorAppStatus](c.Request.Context(), "/combinator/app", q)
+	if err != nil {
+		RequestLog(c).Warn("fetch combinator app status failed", "error", err)
+		c.JSON(503, gin.H{"error": "combinator status unavailable"})
+		return
+	}
+	c.JSON(200, status)
+}
+
+// SetAppProfile (outer) changes the sizing profile of the user's dedicated
+// combinator; the sync job re-renders its pod, which rolls out the Deployment
+func (h *CombinatorHandler) SetAppProfile(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	var req SetCombinatorProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	ctx := c.Request.Context()
+	plan, err := k8s.PlanForUser(ctx, userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get plan: " + err.Error()})
+		return
+	}
+	profile, err := k8s.ResolveCombinatorProfile(plan, req.Profile)
+	if errors.Is(err, k8s.ErrCombinatorProfile) {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.SetCombinatorProfile(ctx, tx, userUID, req.Profile); err != nil {
+			return err
+		}
+		return enqueueTask(ctx, tx, jobs.NewCombinatorSyncJob(userUID))
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to set profile: " + err.Error()})
+		return
+	}
+	KickOutbox()
+	c.JSON(200, MessageResponse{Message: "combinator profile set to " + profile.Name})
+}
+
+// CombinatorRuntime (inner) reads the pods of the user's dedicated combinator
+func CombinatorRuntime(c *gin.Context) {
+	userUID := c.Query("user_uid")
+	if userUID == "" {
+		c.JSON(400, gin.H{"error": "user_uid is required"})
+		return
+	}
+	if k8s.K8sClient == nil || k8s.DynamicClient == nil {
+		c.JSON(503, gin.H{"error": "k8s client not available"})
+		return
+	}
+	status, err := controller.GetCombinatorRuntime(c.Request.Context(), userUID)
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, status)
+}
+
+// CombinatorPodLogs (inner) returns the recent logs of the user's dedicated
+// combinator; empty with dedicated false under the shared combinator
+func CombinatorPodLogs(c *gin.Context) {
+	userUID := c.Query("user_uid")
+	if userUID == "" {
+		c.JSON(400, gin.H{"error": "user_uid is required"})
+		return
+	}
+	tail, err := parseLogTail(c.Query("tail"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !controller.CombinatorApps {
+		c.JSON(200, CombinatorLogsResponse{Lines: []controller.LogLine{}})
+		return
+	}
+	if k8s.K8sClient == nil {
+		c.JSON(503, gin.H{"error": "k8s client not available"})
+		return
+	}
+	lines, err := controller.CombinatorLogs(c.Request.Context(), userUID, tail, c.Query("previous") == "true")
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, CombinatorLogsResponse{Dedicated: true, Lines: lines})
+}
 
-	if err := dblayer.DeleteCombinatorResource(userUID, "kv", resourceID); err != nil {
-		c.JSON(500, gin.H{"error": "failed to delete resource: " + err.Error()})
+// GetCombinatorStatus (outer) returns pod phase, restarts and the loaded
+// config revision of the user's dedicated combinator from inner
+func (h *CombinatorHandler) GetCombinatorStatus(c *gin.Context) {
+	status, err := fetchCombinatorRuntime(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		RequestLog(c).Warn("fetch combinator runtime failed", "error", err)
+		c.JSON(503, gin.H{"error": "combinator status unavailable"})
 		return
 	}
+	c.JSON(200, status)
+}
 
-	if err := SendTask(jobs.NewDeleteKVJob(userUID, cr.ResourceID)); err != nil {
-		c.JSON(500, gin.H{"error": "failed to enqueue delete task"})
+// GetCombinatorLogs (outer) returns the last ?tail= lines (default 100) of each
+// combinator pod; ?previous=true reads the container before the last restart
+func (h *CombinatorHandler) GetCombinatorLogs(c *gin.Context) {
+	tail, err := parseLogTail(c.Query("tail"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	q := url.Values{
+		"user_uid": {c.GetString("user_id")},
+		"tail":     {strconv.FormatInt(tail, 10)},
+		"previous": {strconv.FormatBool(c.Query("previous") == "true")},
+	}
+	logs, err := getInnerTimeout[CombinatorLogsResponse](c.Request.Context(), "/combinator/logs", q, 15*time.Second)
+	if err != nil {
+		RequestLog(c).Warn("fetch combinator logs failed", "error", err)
+		c.JSON(503, gin.H{"error": "combinator logs unavailable"})
+		return
+	}
+	c.JSON(200, logs)
+}
+
+// RestartCombinator (outer) rolls the pods of the user's dedicated combinator;
+// 409 while the user has none
+func (h *CombinatorHandler) RestartCombinator(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	ctx := c.Request.Context()
+	status, err := fetchCombinatorRuntime(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Warn("fetch combinator runtime failed", "error", err)
+		c.JSON(503, gin.H{"error": "combinator status unavailable"})
+		return
+	}
+	if !status.Dedicated || !status.Deployed {
+		c.JSON(409, gin.H{"error": "no dedicated combinator is deployed"})
+		return
+	}
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		return enqueueTask(ctx, tx, jobs.NewCombinatorRestartJob(userUID))
+	})
+	if err != nil {
+		RequestLog(c).Error("enqueue combinator restart failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to enqueue restart task"})
+		return
+	}
+	KickOutbox()
+	RequestLog(c).Info("combinator restart requested")
+	c.JSON(202, MessageResponse{Message: "restart requested"})
+}
 
-	c.JSON(200, gin.H{"message": "deleted"})
+// fetchCombinatorRuntime (outer) calls inner's /combinator/status
+func fetchCombinatorRuntime(ctx context.Context, userUID string) (*controller.CombinatorRuntimeStatus, error) {
+	q := url.Values{"user_uid": {userUID}}
+	return getInner[controller.CombinatorRuntimeStatus](ctx, "/combinator/status", q)
 }
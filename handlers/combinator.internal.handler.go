@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"strings"
+	"time"
+
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
-	"log"
+	"jabberwocky238/console/k8s/controller"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,50 +20,34 @@ func NewCombinatorInternalHandler(proc *k8s.Processor) *CombinatorInternalHandle
 	return &CombinatorInternalHandler{proc: proc}
 }
 
-// RetrieveSecretByID retrieves all active combinator resources and their secrets for a user
+// RetrieveSecretByID retrieves all active combinator resources of a user, plus
+// the user's secret key when the caller is not a worker
 func (h *CombinatorInternalHandler) RetrieveSecretByID(c *gin.Context) {
 	userUID := c.Query("user_id")
-
-	// Get user secret key
-	secretKey, err := dblayer.GetUserSecretKey(userUID)
-	if err != nil {
-		log.Printf("failed to get secret key for user %s: %v", userUID, err)
-		c.JSON(500, gin.H{"error": "failed to get user secret: " + err.Error()})
+	// worker 只能取自己 owner 的资源
+	if owner := callerOwner(c); owner != "" && owner != userUID {
+		RequestLog(c).Warn("worker asked for another user's secrets", "owner", owner, "user_id", userUID)
+		c.JSON(403, gin.H{"error": "client certificate does not belong to this user"})
 		return
 	}
 
-	// Get all active resources
-	resources, err := dblayer.ListActiveCombinatorResources(userUID)
+	resp, err := retrieveSecret(c.Request.Context(), userUID)
 	if err != nil {
-		log.Printf("failed to list combinator resources for user %s: %v", userUID, err)
-		c.JSON(500, gin.H{"error": "failed to list resources: " + err.Error()})
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Build response with secrets
-	type ResourceWithSecret struct {
-		ResourceType string `json:"resource_type"`
-		ResourceID   string `json:"resource_id"`
-	}
-
-	var result []ResourceWithSecret
-	for _, res := range resources {
-		item := ResourceWithSecret{
-			ResourceType: res.ResourceType,
-			ResourceID:   res.ResourceID,
-		}
-
-		result = append(result, item)
+	// secret key 只给 combinator 校验签名用，worker 已经在 env 里有自己的
+	if callerOwner(c) != "" {
+		resp.SecretKey = ""
 	}
-
-	c.JSON(200, gin.H{"resources": result, "secret_key": secretKey})
+	c.JSON(200, resp)
 }
 
 // ReportUsage handles batch usage reporting from combinators
 func (h *CombinatorInternalHandler) ReportUsage(c *gin.Context) {
 	var reports []dblayer.CombinatorResourceReport
 	if err := c.ShouldBindJSON(&reports); err != nil {
-		c.JSON(400, gin.H{"error": "invalid request body: " + err.Error()})
+		c.JSON(400, bindError(err))
 		return
 	}
 
@@ -70,11 +57,81 @@ func (h *CombinatorInternalHandler) ReportUsage(c *gin.Context) {
 	}
 
 	// Batch insert all reports
-	err := dblayer.BatchSaveCombinatorResourceReports(reports)
+	err := dblayer.BatchSaveCombinatorResourceReports(c.Request.Context(), reports)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to create reports: " + err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "reports processed successfully", "count": len(reports)})
+	c.JSON(200, ReportUsageResponse{Message: "reports processed successfully", Count: len(reports)})
+}
+
+// ReportConfig records the config revision a dedicated combinator pod has
+// loaded; resources of later revisions stay pending_apply until then
+func (h *CombinatorInternalHandler) ReportConfig(c *gin.Context) {
+	var req ReportConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	err := dblayer.SetCombinatorAppliedRevision(c.Request.Context(), req.UserUID, req.Revision)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "unknown user or revision"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to record revision: " + err.Error()})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "revision recorded"})
+}
+
+// ResolveDSN returns the live DSNs of the active resources of the worker
+// owner that signed the request (VerifyWorkerSignature), limited to the
+// resource=type:id query values when any are given. The response is signed
+// with the owner's secret key and expires after DSNResponseTTL, so workers
+// pick up new resources without an env change and redeploy.
+func (h *CombinatorInternalHandler) ResolveDSN(c *gin.Context) {
+	userUID := c.GetString(workerUIDKey)
+	wanted := map[ResourceRef]bool{}
+	for _, v := range c.QueryArray("resource") {
+		typ, id, ok := strings.Cut(v, ":")
+		if !ok || (typ != "rdb" && typ != "kv" && typ != "queue") || id == "" {
+			c.JSON(400, gin.H{"error": "resource must be rdb:<id>, kv:<id> or queue:<id>, got " + v})
+			return
+		}
+		wanted[ResourceRef{ResourceType: typ, ResourceID: id}] = true
+	}
+
+	resources, err := dblayer.ListActiveCombinatorResources(c.Request.Context(), userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": errListResources.Error()})
+		return
+	}
+	resp := ResolveDSNResponse{
+		UserUID:   userUID,
+		Resources: []ResolvedDSN{},
+		ExpiresAt: time.Now().Add(DSNResponseTTL).UTC().Truncate(time.Second),
+	}
+	found := 0
+	for _, r := range resources {
+		ref := ResourceRef{ResourceType: r.ResourceType, ResourceID: r.ResourceID}
+		if len(wanted) > 0 && !wanted[ref] {
+			continue
+		}
+		found++
+		dsn, err := controller.ResourceDSN(c.Request.Context(), userUID, r.ResourceType, r.ResourceID)
+		if err != nil {
+			c.JSON(503, gin.H{"error": err.Error()})
+			return
+		}
+		resp.Resources = append(resp.Resources, ResolvedDSN{ResourceType: r.ResourceType, ResourceID: r.ResourceID, DSN: dsn})
+	}
+	if found < len(wanted) {
+		c.JSON(404, gin.H{"error": "some requested resources do not exist or are not active"})
+		return
+	}
+	resp.Signature = dsnSignature(c.GetString(workerSecretKeyKey), &resp)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(200, resp)
 }
@@ -0,0 +1,566 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"sigs.k8s.io/yaml"
+)
+
+// userState 用户当前的全部资源
+type userState struct {
+	workers []*dblayer.Worker
+	domains []*dblayer.CustomDomain
+	rdbs    []*dblayer.CombinatorResource
+	kvs     []*dblayer.CombinatorResource
+}
+
+// listAll 翻完所有分页
+func listAll[T any](list func(opts dblayer.ListOptions) ([]T, string, error)) ([]T, error) {
+	var all []T
+	opts := dblayer.ListOptions{Limit: dblayer.MaxListLimit, Sort: dblayer.SortByName, Asc: true}
+	for {
+		page, next, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" {
+			return all, nil
+		}
+		opts.Cursor = next
+	}
+}
+
+func loadUserState(ctx context.Context, userUID string) (*userState, error) {
+	var s userState
+	var err error
+	if s.workers, err = listAll(func(o dblayer.ListOptions) ([]*dblayer.Worker, string, error) {
+		return dblayer.ListWorkersByUser(ctx, userUID, o)
+	}); err != nil {
+		return nil, err
+	}
+	if s.domains, err = listAll(func(o dblayer.ListOptions) ([]*dblayer.CustomDomain, string, error) {
+		return dblayer.ListCustomDomains(ctx, userUID, o)
+	}); err != nil {
+		return nil, err
+	}
+	if s.rdbs, err = listAll(func(o dblayer.ListOptions) ([]*dblayer.CombinatorResource, string, error) {
+		o.Type = "rdb"
+		return dblayer.ListCombinatorResources(ctx, userUID, o)
+	}); err != nil {
+		return nil, err
+	}
+	if s.kvs, err = listAll(func(o dblayer.ListOptions) ([]*dblayer.CombinatorResource, string, error) {
+		o.Type = "kv"
+		return dblayer.ListCombinatorResources(ctx, userUID, o)
+	}); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// workerEnvKeys env 的 key（去掉系统注入的），排序
+func workerEnvKeys(w *dblayer.Worker) []string {
+	var env map[string]string
+	json.Unmarshal([]byte(w.EnvJSON), &env)
+	keys := slices.Sorted(maps.Keys(env))
+	return slices.DeleteFunc(keys, func(k string) bool { return slices.Contains(controller.ReservedEnvKeys, k) })
+}
+
+func workerSecretKeys(w *dblayer.Worker) []string {
+	var keys []string
+	json.Unmarshal([]byte(w.SecretsJSON), &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// activeVersion 当前生效的部署版本，没有部署过时为 nil
+func activeVersion(ctx context.Context, w *dblayer.Worker) *dblayer.WorkerDeployVersion {
+	if w.ActiveVersionID == nil {
+		return nil
+	}
+	v, err := dblayer.GetDeployVersion(ctx, *w.ActiveVersionID)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// ExportConfig 导出当前用户的声明式配置，默认 YAML，?format=json 返回 JSON
+func ExportConfig(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	state, err := loadUserState(c.Request.Context(), userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load resources: " + err.Error()})
+		return
+	}
+
+	doc := ConfigDocument{APIVersion: ConfigAPIVersion, Kind: ConfigKind}
+	for _, w := range state.workers {
+		wc := WorkerConfig{
+			Name:           w.WorkerName,
+			AssignedCPU:    w.AssignedCPU,
+			AssignedMemory: w.AssignedMemory,
+			AssignedDisk:   w.AssignedDisk,
+			MaxReplicas:    w.MaxReplicas,
+			MainRegion:     w.MainRegion,
+			Cluster:        w.Cluster,
+			Pool:           w.Pool,
+			Arch:           w.Arch,
+			RunAsRoot:      trueOrNil(w.RunAsRoot),
+			WritableRootFS: trueOrNil(w.WritableRootFS),
+			Env:            workerEnvKeys(w),
+			Secrets:        workerSecretKeys(w),
+			Tags:           w.Tags,
+			Environment:    w.Environment,
+		}
+		if w.Kind == dblayer.WorkerKindStatic {
+			wc.Kind = w.Kind
+		} else if v := activeVersion(c.Request.Context(), w); v != nil {
+			wc.Image, wc.Port = v.Image, v.Port
+		}
+		doc.Workers = append(doc.Workers, wc)
+	}
+	for _, d := range state.domains {
+		doc.Domains = append(doc.Domains, DomainConfig{Domain: d.Domain, Target: d.Target, Issuer: d.Issuer, Challenge: d.Challenge, Tags: d.Tags, Environment: d.Environment})
+	}
+	for _, r := range state.rdbs {
+		doc.RDBs = append(doc.RDBs, ResourceConfig{Name: r.Name, Tags: r.Tags, Environment: r.Environment})
+	}
+	for _, r := range state.kvs {
+		doc.KVs = append(doc.KVs, ResourceConfig{Name: r.Name, Tags: r.Tags, Environment: r.Environment})
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(200, doc)
+		return
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to encode document: " + err.Error()})
+		return
+	}
+	c.Data(200, "application/yaml; charset=utf-8", out)
+}
+
+// ImportConfig 把声明式配置与当前资源对比并应用：缺少的创建，不同的更新。
+// ?dry_run=true 只返回变更计划；?prune=true 同时删除文档中没有的资源。
+// 重复导入同一文档不会产生变更。env/secret 只有 key，缺少的值以 warning 提示。
+func ImportConfig(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	// 大小上限由路由上的 MaxBodySize(BulkBodyLimit) 限制
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read document: " + err.Error()})
+		return
+	}
+	// YAML 是 JSON 的超集，两种格式都接受
+	var doc ConfigDocument
+	if err := yaml.UnmarshalStrict(body, &doc); err != nil {
+		c.JSON(400, gin.H{"error": "invalid document: " + err.Error()})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(doc); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if err := doc.checkDuplicates(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 导入按当前状态计算增删，不能基于落后的副本
+	state, err := loadUserState(dblayer.WithPrimary(c.Request.Context()), userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load resources: " + err.Error()})
+		return
+	}
+
+	p := &configPlan{
+		ctx:     c.Request.Context(),
+		userUID: userUID,
+		prune:   c.Query("prune") == "true",
+		resp:    ImportConfigResponse{DryRun: c.Query("dry_run") == "true", Changes: []ConfigChange{}},
+	}
+	p.workers(doc.Workers, state.workers)
+	p.domains(doc.Domains, state.domains)
+	p.resources("rdb", doc.RDBs, state.rdbs)
+	p.resources("kv", doc.KVs, state.kvs)
+
+	c.JSON(200, p.resp)
+}
+
+// checkDuplicates 文档内同类资源名字不能重复，否则无法一一对应
+func (doc *ConfigDocument) checkDuplicates() error {
+	check := func(kind string, names []string) error {
+		seen := make(map[string]bool, len(names))
+		for _, n := range names {
+			if seen[n] {
+				return fmt.Errorf("duplicate %s %q", kind, n)
+			}
+			seen[n] = true
+		}
+		return nil
+	}
+	var workers, domains, rdbs, kvs []string
+	for _, w := range doc.Workers {
+		workers = append(workers, w.Name)
+	}
+	for _, d := range doc.Domains {
+		domains = append(domains, d.Domain)
+	}
+	for _, r := range doc.RDBs {
+		rdbs = append(rdbs, r.Name)
+	}
+	for _, r := range doc.KVs {
+		kvs = append(kvs, r.Name)
+	}
+	for _, err := range []error{check("worker", workers), check("domain", domains), check("rdb", rdbs), check("kv", kvs)} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configPlan 收集变更，非 dry run 时逐项执行，单项失败不影响其余项
+type configPlan struct {
+	ctx     context.Context // 请求的 context，部署前的 dry run 随请求超时取消
+	userUID string
+	prune   bool
+	resp    ImportConfigResponse
+}
+
+func (p *configPlan) add(ch ConfigChange, apply func() (string, error)) {
+	if !p.resp.DryRun {
+		id, err := apply()
+		if id != "" {
+			ch.ID = id
+		}
+		if err != nil {
+			ch.Error = err.Error()
+		}
+	}
+	p.resp.Changes = append(p.resp.Changes, ch)
+}
+
+func (p *configPlan) warn(format string, args ...any) {
+	p.resp.Warnings = append(p.resp.Warnings, fmt.Sprintf(format, args...))
+}
+
+// tagsChanged 文档里没写 tags 时不管理标签
+func tagsChanged(want map[string]string, have dblayer.Tags) bool {
+	return want != nil && !maps.Equal(want, have)
+}
+
+// specDiff 文档里写了且与现状不同的资源字段
+func specDiff(want WorkerConfig, w *dblayer.Worker) []string {
+	var diff []string
+	field := func(name, want, have string) {
+		if want != "" && want != have {
+			diff = append(diff, fmt.Sprintf("%s %q -> %q", name, have, want))
+		}
+	}
+	field("assigned_cpu", want.AssignedCPU, w.AssignedCPU)
+	field("assigned_memory", want.AssignedMemory, w.AssignedMemory)
+	field("assigned_disk", want.AssignedDisk, w.AssignedDisk)
+	field("main_region", want.MainRegion, w.MainRegion)
+	field("pool", want.Pool, w.Pool)
+	field("arch", want.Arch, w.Arch)
+	flag := func(name string, want *bool, have bool) {
+		if want != nil && *want != have {
+			diff = append(diff, fmt.Sprintf("%s %t -> %t", name, have, *want))
+		}
+	}
+	flag("run_as_root", want.RunAsRoot, w.RunAsRoot)
+	flag("writable_root_fs", want.WritableRootFS, w.WritableRootFS)
+	if want.MaxReplicas > 0 && want.MaxReplicas != w.MaxReplicas {
+		diff = append(diff, fmt.Sprintf("max_replicas %d -> %d", w.MaxReplicas, want.MaxReplicas))
+	}
+	return diff
+}
+
+// orDefault 文档里没写的字段保持现值
+func orDefault[T comparable](want, have T) T {
+	var zero T
+	if want == zero {
+		return have
+	}
+	return want
+}
+
+// orKeep 文档里没写的开关（或字段）保持现值
+func orKeep[T any](want *T, have T) T {
+	if want == nil {
+		return have
+	}
+	return *want
+}
+
+// trueOrNil 导出时只写打开的开关
+func trueOrNil(v bool) *bool {
+	if !v {
+		return nil
+	}
+	return &v
+}
+
+func (p *configPlan) workers(want []WorkerConfig, have []*dblayer.Worker) {
+	byName := make(map[string]*dblayer.Worker, len(have))
+	for _, w := range have {
+		if _, dup := byName[w.WorkerName]; dup {
+			p.warn("worker %q: several workers share this name, only %s is managed", w.WorkerName, byName[w.WorkerName].WID)
+			continue
+		}
+		byName[w.WorkerName] = w
+	}
+
+	for _, wc := range want {
+		w := byName[wc.Name]
+		delete(byName, wc.Name)
+
+		// static worker 的文件不在文档里，只能另行部署
+		if wc.Image != "" && (wc.Kind == dblayer.WorkerKindStatic || w != nil && w.Kind == dblayer.WorkerKindStatic) {
+			p.warn("worker %q is static, image ignored; deploy its files with POST %s/worker/:id/static", wc.Name, APIPrefixV1)
+			wc.Image, wc.Port = "", 0
+		}
+
+		if w == nil {
+			detail := ""
+			if wc.Image != "" {
+				detail = fmt.Sprintf("deploy %s port %d", wc.Image, wc.Port)
+			}
+			p.add(ConfigChange{Action: "create", Type: "worker", Name: wc.Name, Detail: detail}, func() (string, error) {
+				return p.createWorker(wc)
+			})
+			p.missingKeys(wc, nil)
+			continue
+		}
+
+		p.updateWorker(wc, w)
+		if wc.Image != "" {
+			p.deploy(wc.Name, w, wc.Image, wc.Port)
+		}
+		p.missingKeys(wc, w)
+	}
+
+	// 剩下的是文档里没有的，按原顺序删除
+	if p.prune {
+		for _, w := range have {
+			if byName[w.WorkerName] != w {
+				continue
+			}
+			p.add(ConfigChange{Action: "delete", Type: "worker", Name: w.WorkerName, ID: w.WID}, func() (string, error) {
+				return "", deleteWorker(p.ctx, w.WID, p.userUID)
+			})
+		}
+	}
+}
+
+// updateWorker 文档里写了且与现状不同的资源字段和标签
+func (p *configPlan) updateWorker(wc WorkerConfig, w *dblayer.Worker) {
+	var changed []string
+	diff := specDiff(wc, w)
+	changed = append(changed, diff...)
+	retag := tagsChanged(wc.Tags, w.Tags)
+	if retag {
+		changed = append(changed, "tags")
+	}
+	if len(changed) == 0 {
+		return
+	}
+	p.add(ConfigChange{Action: "update", Type: "worker", Name: wc.Name, ID: w.WID, Detail: strings.Join(changed, ", ")}, func() (string, error) {
+		if len(diff) > 0 {
+			runAsRoot, writableRootFS := orKeep(wc.RunAsRoot, w.RunAsRoot), orKeep(wc.WritableRootFS, w.WritableRootFS)
+			cpu, memory, replicas := orDefault(wc.AssignedCPU, w.AssignedCPU), orDefault(wc.AssignedMemory, w.AssignedMemory), orDefault(wc.MaxReplicas, w.MaxReplicas)
+			if err := checkWorkerLimits(p.ctx, p.userUID, false, workerSpec{
+				CPU: cpu, Memory: memory, MaxReplicas: replicas, RunAsRoot: runAsRoot, WritableRootFS: writableRootFS,
+			}); err != nil {
+				return "", err
+			}
+			err := dblayer.UpdateWorkerSpecByOwner(p.ctx, w.WID, p.userUID,
+				cpu, memory, orDefault(wc.AssignedDisk, w.AssignedDisk), replicas,
+				orDefault(wc.MainRegion, w.MainRegion), orDefault(wc.Pool, w.Pool), orDefault(wc.Arch, w.Arch),
+				runAsRoot, writableRootFS)
+			if err != nil {
+				return "", err
+			}
+			if err := SendTask(jobs.NewSyncSpecJob(w.WID, p.userUID)); err != nil {
+				return "", errEnqueueSync
+			}
+		}
+		if retag {
+			_, err := setWorkerTags(p.ctx, w.WID, p.userUID, wc.Tags, 0)
+			return "", err
+		}
+		return "", nil
+	})
+}
+
+// deploy 当前版本不是该镜像和端口时部署
+func (p *configPlan) deploy(name string, w *dblayer.Worker, image string, port int) {
+	if v := activeVersion(p.ctx, w); v != nil && v.Image == image && v.Port == port {
+		return
+	}
+	p.add(ConfigChange{Action: "deploy", Type: "worker", Name: name, ID: w.WID, Detail: fmt.Sprintf("%s port %d", image, port)}, func() (string, error) {
+		_, err := deployWorker(p.ctx, DeployWorkerRequest{UserUID: p.userUID, WorkerID: w.WID, Image: image, Port: port, Initiator: dblayer.DeployInitiatorImport})
+		return "", err
+	})
+}
+
+// createWorker 创建 worker，文档里带了镜像时接着部署
+func (p *configPlan) createWorker(wc WorkerConfig) (string, error) {
+	runAsRoot, writableRootFS := orKeep(wc.RunAsRoot, false), orKeep(wc.WritableRootFS, false)
+	if err := checkWorkerLimits(p.ctx, p.userUID, true, workerSpec{
+		CPU: wc.AssignedCPU, Memory: wc.AssignedMemory, MaxReplicas: wc.MaxReplicas, RunAsRoot: runAsRoot, WritableRootFS: writableRootFS,
+	}); err != nil {
+		return "", err
+	}
+	workerID := uuid.New().String()[:8]
+	if err := dblayer.CreateWorker(p.ctx, dblayer.DB, workerID, p.userUID, wc.Name, wc.AssignedCPU, wc.AssignedMemory, wc.AssignedDisk, wc.MaxReplicas, wc.MainRegion, wc.Cluster, wc.Pool, wc.Arch, runAsRoot, writableRootFS, wc.Tags, wc.Environment, wc.Kind); err != nil {
+		return "", fmt.Errorf("failed to create worker: %w", err)
+	}
+	if wc.Image == "" {
+		return workerID, nil
+	}
+	_, err := deployWorker(p.ctx, DeployWorkerRequest{UserUID: p.userUID, WorkerID: workerID, Image: wc.Image, Port: wc.Port, Initiator: dblayer.DeployInitiatorImport})
+	return workerID, err
+}
+
+// missingKeys env/secret 的值不在文档里，只能提示缺少的 key 需要另行设置
+func (p *configPlan) missingKeys(wc WorkerConfig, w *dblayer.Worker) {
+	var env, secrets []string
+	if w != nil {
+		env, secrets = workerEnvKeys(w), workerSecretKeys(w)
+	}
+	for _, k := range wc.Env {
+		if !slices.Contains(env, k) {
+			p.warn("worker %q: env %s has no value, set it with POST %s/worker/:id/env", wc.Name, k, APIPrefixV1)
+		}
+	}
+	for _, k := range wc.Secrets {
+		if !slices.Contains(secrets, k) {
+			p.warn("worker %q: secret %s has no value, set it with POST %s/worker/:id/secret", wc.Name, k, APIPrefixV1)
+		}
+	}
+}
+
+func (p *configPlan) domains(want []DomainConfig, have []*dblayer.CustomDomain) {
+	byDomain := make(map[string]*dblayer.CustomDomain, len(have))
+	for _, d := range have {
+		byDomain[d.Domain] = d
+	}
+
+	for _, dc := range want {
+		d := byDomain[dc.Domain]
+		delete(byDomain, dc.Domain)
+
+		if d == nil {
+			p.add(ConfigChange{Action: "create", Type: "domain", Name: dc.Domain, Detail: "target " + dc.Target}, func() (string, error) {
+				return p.createDomain(dc)
+			})
+			continue
+		}
+		if dc.Target != d.Target {
+			p.warn("domain %q: target %q differs from %q, delete and add the domain again to change it", dc.Domain, d.Target, dc.Target)
+		}
+		if issuer, err := k8s.IssuerOverride(dc.Issuer); err == nil && issuer != d.Issuer {
+			p.warn("domain %q: issuer %q differs from %q, delete and add the domain again to change it", dc.Domain, d.Issuer, issuer)
+		}
+		if dc.Challenge != "" && dc.Challenge != d.Challenge {
+			p.warn("domain %q: challenge %q differs from %q, delete and add the domain again to change it", dc.Domain, d.Challenge, dc.Challenge)
+		}
+		if tagsChanged(dc.Tags, d.Tags) {
+			p.add(ConfigChange{Action: "update", Type: "domain", Name: dc.Domain, ID: d.CDID, Detail: "tags"}, func() (string, error) {
+				_, err := setCustomDomainTags(p.ctx, d.CDID, p.userUID, dc.Tags, 0)
+				return "", err
+			})
+		}
+	}
+
+	if p.prune {
+		for _, d := range have {
+			if byDomain[d.Domain] != d {
+				continue
+			}
+			p.add(ConfigChange{Action: "delete", Type: "domain", Name: d.Domain, ID: d.CDID}, func() (string, error) {
+				return "", k8s.DeleteCustomDomain(p.ctx, d.CDID)
+			})
+		}
+	}
+}
+
+// createDomain 添加域名并开始验证
+func (p *configPlan) createDomain(dc DomainConfig) (string, error) {
+	if err := checkDomainLimit(p.ctx, p.userUID); err != nil {
+		return "", err
+	}
+	issuer, err := k8s.IssuerOverride(dc.Issuer)
+	if err != nil {
+		return "", err
+	}
+	cd, err := k8s.NewCustomDomain(p.ctx, p.userUID, dc.Domain, dc.Target, issuer, dc.Challenge, dc.Tags, dc.Environment)
+	if err != nil {
+		return "", err
+	}
+	cd.StartVerification(false)
+	return cd.CDID, nil
+}
+
+// resources RDB/KV 按名字对应
+func (p *configPlan) resources(resourceType string, want []ResourceConfig, have []*dblayer.CombinatorResource) {
+	byName := make(map[string]*dblayer.CombinatorResource, len(have))
+	for _, r := range have {
+		if _, dup := byName[r.Name]; dup {
+			p.warn("%s %q: several resources share this name, only %s is managed", resourceType, r.Name, byName[r.Name].ResourceID)
+			continue
+		}
+		byName[r.Name] = r
+	}
+
+	for _, rc := range want {
+		r := byName[rc.Name]
+		delete(byName, rc.Name)
+
+		if r == nil {
+			p.add(ConfigChange{Action: "create", Type: resourceType, Name: rc.Name}, func() (string, error) {
+				return createCombinatorResource(p.ctx, p.userUID, resourceType, rc.Name, rc.Tags, rc.Environment)
+			})
+			continue
+		}
+		if tagsChanged(rc.Tags, r.Tags) {
+			p.add(ConfigChange{Action: "update", Type: resourceType, Name: rc.Name, ID: r.ResourceID, Detail: "tags"}, func() (string, error) {
+				_, err := dblayer.SetCombinatorResourceTags(p.ctx, p.userUID, resourceType, r.ResourceID, rc.Tags, 0)
+				return "", err
+			})
+		}
+	}
+
+	if p.prune {
+		for _, r := range have {
+			if byName[r.Name] != r {
+				continue
+			}
+			p.add(ConfigChange{Action: "delete", Type: resourceType, Name: r.Name, ID: r.ResourceID}, func() (string, error) {
+				return "", deleteCombinatorResource(p.ctx, p.userUID, resourceType, r.ResourceID)
+			})
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig 跨域配置，控制面板与 API 不同源部署时使用
+type CORSConfig struct {
+	AllowedOrigins   []string // "*" 表示任意来源
+	AllowCredentials bool
+	MaxAge           int // 预检结果缓存秒数，0 表示不设置
+}
+
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Origin, Content-Type, Accept, Authorization, X-Combinator-Signature, X-Combinator-User-ID, X-Combinator-Timestamp, If-Match, If-None-Match, If-Modified-Since, If-Unmodified-Since, X-Request-ID, X-CSRF-Token"
+	corsExposeHeaders = "X-Next-Cursor, Deprecation, Link, ETag, Last-Modified, X-Request-ID"
+)
+
+// DefaultCORSMaxAge 预检缓存默认 10 分钟
+const DefaultCORSMaxAge = 600
+
+// CORSConfigFromEnv 在 flag 值基础上读取环境变量：
+// CORS_ALLOWED_ORIGINS（逗号分隔）、CORS_ALLOW_CREDENTIALS、CORS_MAX_AGE，环境变量优先
+func CORSConfigFromEnv(origins string, credentials bool, maxAge int) CORSConfig {
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		origins = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS")); err == nil {
+		credentials = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CORS_MAX_AGE")); err == nil && v >= 0 {
+		maxAge = v
+	}
+
+	cfg := CORSConfig{AllowCredentials: credentials, MaxAge: maxAge}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, o)
+		}
+	}
+	return cfg
+}
+
+func (cfg CORSConfig) allows(origin string) bool {
+	return slices.Contains(cfg.AllowedOrigins, "*") || slices.Contains(cfg.AllowedOrigins, origin)
+}
+
+// CORSMiddleware 处理跨域请求与预检请求。
+// 没有 Origin 的请求（同源、服务端调用）直接放行；来源不在白名单的预检返回 403，
+// 普通请求照常处理但不带 CORS 头，由浏览器拦截。预检请求在这里直接返回 204，
+// 必须在注册路由之前 Use，才能覆盖未注册 OPTIONS 的路径。
+func CORSMiddleware(cfg CORSConfig) gin.HandlerFunc {
+	maxAge := ""
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+	wildcard := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != ""
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !cfg.allows(origin) {
+			if preflight {
+				c.AbortWithStatus(403)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// 携带凭证时规范不允许 "*"，回显具体来源
+		if wildcard && !cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			if maxAge != "" {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
+		c.Next()
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func corsRouter(cfg CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORSMiddleware(cfg))
+	r.GET("/api/workers", func(c *gin.Context) { c.String(200, "ok") })
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, origin string, preflight bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/workers", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflight {
+		req.Header.Set("Access-Control-Request-Method", "GET")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflightAllowed(t *testing.T) {
+	r := corsRouter(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: DefaultCORSMaxAge})
+	w := corsRequest(r, http.MethodOptions, "https://app.example.com", true)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	h := w.Header()
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Allow-Origin = %q", got)
+	}
+	if got := h.Get("Access-Control-Allow-Methods"); got != corsAllowMethods {
+		t.Errorf("Allow-Methods = %q", got)
+	}
+	if got := h.Get("Access-Control-Allow-Headers"); got != corsAllowHeaders {
+		t.Errorf("Allow-Headers = %q", got)
+	}
+	if got := h.Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Max-Age = %q, want 600", got)
+	}
+	if got := h.Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Allow-Credentials = %q without credentials", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("preflight reached the handler: %q", w.Body.String())
+	}
+}
+
+func TestCORSPreflightDenied(t *testing.T) {
+	r := corsRouter(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	w := corsRequest(r, http.MethodOptions, "https://evil.example.com", true)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Allow-Origin = %q for a denied origin", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+}
+
+func TestCORSSimpleRequestDeniedOrigin(t *testing.T) {
+	r := corsRouter(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	w := corsRequest(r, http.MethodGet, "https://evil.example.com", false)
+
+	// 照常处理，不带 CORS 头，由浏览器拦截
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Allow-Origin = %q for a denied origin", got)
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials bool
+		wantOrigin  string
+	}{
+		{"without credentials", false, "*"},
+		{"with credentials echoes origin", true, "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := corsRouter(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: tt.credentials})
+			for _, preflight := range []bool{true, false} {
+				method := http.MethodGet
+				if preflight {
+					method = http.MethodOptions
+				}
+				w := corsRequest(r, method, "https://app.example.com", preflight)
+				if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+					t.Errorf("preflight=%v: Allow-Origin = %q, want %q", preflight, got, tt.wantOrigin)
+				}
+				wantCreds := ""
+				if tt.credentials {
+					wantCreds = "true"
+				}
+				if got := w.Header().Get("Access-Control-Allow-Credentials"); got != wantCreds {
+					t.Errorf("preflight=%v: Allow-Credentials = %q, want %q", preflight, got, wantCreds)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSMaxAgeUnset(t *testing.T) {
+	r := corsRouter(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	w := corsRequest(r, http.MethodOptions, "https://app.example.com", true)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Max-Age = %q with MaxAge 0", got)
+	}
+}
+
+func TestCORSActualRequestExposesHeaders(t *testing.T) {
+	r := corsRouter(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	w := corsRequest(r, http.MethodGet, "https://app.example.com", false)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != corsExposeHeaders {
+		t.Errorf("Expose-Headers = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("Allow-Methods = %q on a non-preflight request", got)
+	}
+}
+
+func TestCORSNoOrigin(t *testing.T) {
+	r := corsRouter(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: DefaultCORSMaxAge})
+
+	w := corsRequest(r, http.MethodGet, "", false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want 200", w.Code)
+	}
+	for _, h := range []string{"Access-Control-Allow-Origin", "Access-Control-Expose-Headers", "Vary"} {
+		if got := w.Header().Get(h); got != "" {
+			t.Errorf("%s = %q without Origin", h, got)
+		}
+	}
+
+	// 没有 Origin 的 OPTIONS 不是预检，交给路由（这里没有注册 OPTIONS，返回 404）
+	w = corsRequest(r, http.MethodOptions, "", true)
+	if w.Code == http.StatusNoContent || w.Code == http.StatusForbidden {
+		t.Fatalf("OPTIONS without Origin handled as preflight: %d", w.Code)
+	}
+}
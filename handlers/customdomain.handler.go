@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"context"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
 
 	"github.com/gin-gonic/gin"
@@ -9,56 +13,153 @@ import (
 // AddCustomDomain handles adding a new custom domain
 func AddCustomDomain(c *gin.Context) {
 	userUID := c.GetString("user_id")
-	var req struct {
-		Domain string `json:"domain" binding:"required"`
-		Target string `json:"target" binding:"required"`
-	}
+	var req AddCustomDomainRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	issuer, err := k8s.IssuerOverride(req.Issuer)
+	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if err := checkDomainLimit(c.Request.Context(), userUID); err != nil {
+		limitResponse(c, err)
+		return
+	}
 
-	cd, err := k8s.NewCustomDomain(userUID, req.Domain, req.Target)
+	cd, err := k8s.NewCustomDomain(c.Request.Context(), userUID, req.Domain, req.Target, issuer, req.Challenge, req.Tags, req.Environment)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	cd.StartVerification()
+	cd.StartVerification(req.Force)
 
-	c.JSON(200, gin.H{
-		"id":        cd.ID,
-		"domain":    cd.Domain,
-		"target":    cd.Target,
-		"txt_name":  cd.TXTName,
-		"txt_value": cd.TXTValue,
-		"status":    cd.Status,
+	c.JSON(200, AddCustomDomainResponse{
+		ID:       cd.ID,
+		Domain:   cd.Domain,
+		Target:   cd.Target,
+		TXTName:  cd.TXTName,
+		TXTValue: cd.TXTValue,
+		Status:   cd.Status,
 	})
 }
 
-// ListCustomDomains lists all custom domains for user
+// WorkerReadiness is k8s.WorkerReadiness for the outer gateway: target is a
+// worker of userUID when it is that worker's host, and ready when the inner
+// reports ready replicas
+func WorkerReadiness(ctx context.Context, userUID, target string) (ready, ok bool, err error) {
+	host := strings.TrimSuffix(strings.ToLower(target), ".")
+	prefix, found := strings.CutSuffix(host, ".worker."+k8s.Domain)
+	if !found {
+		return false, false, nil
+	}
+	workerID, found := strings.CutSuffix(prefix, "-"+userUID)
+	if !found {
+		return false, false, nil
+	}
+	if err := dblayer.AuthorizeResource(ctx, userUID, dblayer.ResourceWorker, workerID); err == dblayer.ErrNotFound {
+		return false, false, nil
+	} else if err != nil {
+		return false, true, err
+	}
+	status, err := fetchWorkerStatus(ctx, workerID, userUID)
+	if err != nil {
+		return false, true, err
+	}
+	return status.ReadyReplicas > 0, true, nil
+}
+
+// ListCustomDomains lists custom domains for user, one page at a time
 func ListCustomDomains(c *gin.Context) {
 	userUID := c.GetString("user_id")
-	domains := k8s.ListCustomDomains(userUID)
-	c.JSON(200, gin.H{"domains": domains})
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	domains, next, err := k8s.ListCustomDomains(c.Request.Context(), userUID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list domains: " + err.Error()})
+		return
+	}
+	setNextCursor(c, next)
+	c.JSON(200, ListCustomDomainsResponse{Domains: domains, NextCursor: next})
 }
 
 // GetCustomDomain gets a custom domain by ID
 func GetCustomDomain(c *gin.Context) {
-	cdid := c.Param("id")
-	cd, err := k8s.GetCustomDomain(cdid)
-	if err != nil {
-		c.JSON(404, gin.H{"error": "domain not found: " + err.Error()})
+	cd, ok := ownedCustomDomain(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if notModified(c, cd.UpdatedAt) {
 		return
 	}
 	c.JSON(200, cd)
 }
 
+// SetCustomDomainTags replaces the tags of a custom domain and syncs them to its K8s labels
+func SetCustomDomainTags(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	cdid := c.Param("id")
+
+	var req SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if hasPrecondition(c) {
+		cd, ok := ownedCustomDomain(c, cdid)
+		if !ok || preconditionFailed(c, cd.UpdatedAt) {
+			return
+		}
+	}
+
+	version, err := setCustomDomainTags(c.Request.Context(), cdid, userUID, req.Tags, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "domain not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, TagsResponse{Tags: req.Tags, Version: version})
+}
+
 // DeleteCustomDomain deletes a custom domain
 func DeleteCustomDomain(c *gin.Context) {
 	cdid := c.Param("id")
-	if err := k8s.DeleteCustomDomain(cdid); err != nil {
+	cd, ok := ownedCustomDomain(c, cdid)
+	if !ok || preconditionFailed(c, cd.UpdatedAt) {
+		return
+	}
+	if err := k8s.DeleteCustomDomain(c.Request.Context(), cdid); err != nil {
 		c.JSON(404, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"message": "deleted"})
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// ownedCustomDomain loads a domain owned by the caller; on failure the 404 has already been written
+func ownedCustomDomain(c *gin.Context, cdid string) (*k8s.CustomDomain, bool) {
+	if !authorizeResource(c, dblayer.ResourceDomain, cdid) {
+		return nil, false
+	}
+	cd, err := k8s.GetCustomDomain(c.Request.Context(), cdid)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "domain not found"})
+		return nil, false
+	}
+	return cd, true
 }
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// push 部署：worker 绑定 GitHub 仓库的一个分支后，push 到该分支（或绑定的 workflow 在该分支上成功运行）
+// 时按 image_template 部署这次提交，镜像由用户自己的 CI 构建。所有仓库共用 /webhooks/github，
+// 投递按仓库找到绑定，逐个用绑定的 secret 校验签名，只部署签名匹配的 worker。
+// 版本上记录 commit，配置了 github_token 时部署任务把结果写成 commit status
+
+// githubDeployEvent push 和 workflow_run 事件中用到的字段
+type githubDeployEvent struct {
+	Ref         string `json:"ref"`
+	After       string `json:"after"`
+	Deleted     bool   `json:"deleted"`
+	Action      string `json:"action"`
+	WorkflowRun struct {
+		Name       string `json:"name"`
+		Event      string `json:"event"`
+		HeadBranch string `json:"head_branch"`
+		HeadSHA    string `json:"head_sha"`
+		Conclusion string `json:"conclusion"`
+	} `json:"workflow_run"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// commitFor 事件要部署到绑定 worker 的提交，不触发该绑定时返回空：
+// 没有 workflow 的绑定看 push，有 workflow 的绑定看该 workflow 由 push 触发的成功运行
+func (ev *githubDeployEvent) commitFor(event string, b *dblayer.GitHubBinding) string {
+	switch {
+	case event == "push" && b.Workflow == "":
+		if ev.Deleted || ev.Ref != "refs/heads/"+b.Branch {
+			return ""
+		}
+		return ev.After
+	case event == "workflow_run" && b.Workflow != "":
+		r := ev.WorkflowRun
+		if ev.Action != "completed" || r.Conclusion != "success" || r.Event != "push" ||
+			r.HeadBranch != b.Branch || r.Name != b.Workflow {
+			return ""
+		}
+		return r.HeadSHA
+	}
+	return ""
+}
+
+type GitHubHandler struct{}
+
+func NewGitHubHandler() *GitHubHandler {
+	return &GitHubHandler{}
+}
+
+// SetBinding 创建或更新 worker 的仓库绑定；首次创建时生成并返回 webhook secret，之后不再返回
+func (h *GitHubHandler) SetBinding(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetGitHubBindingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !repoPattern.MatchString(req.Repo) {
+		c.JSON(400, gin.H{"error": "repo must be owner/name"})
+		return
+	}
+	if req.Branch == "" {
+		req.Branch = "main"
+	}
+	if strings.Contains(req.ImageTemplate, "{pr}") || !validImageTemplate(req.ImageTemplate) {
+		c.JSON(400, gin.H{"error": "image_template does not render to a valid image reference"})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	b := &dblayer.GitHubBinding{
+		WorkerID:      workerID,
+		UserUID:       userUID,
+		Repo:          req.Repo,
+		Branch:        req.Branch,
+		ImageTemplate: req.ImageTemplate,
+		Workflow:      req.Workflow,
+		WebhookSecret: "whsec_" + hex.EncodeToString(secret),
+		GitHubToken:   req.GitHubToken,
+	}
+	created, err := dblayer.UpsertGitHubBinding(c.Request.Context(), b)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to save github binding"})
+		return
+	}
+
+	resp := GitHubBindingResponse{GitHubBinding: b, WebhookURL: githubWebhookURL("/webhooks/github")}
+	status := 200
+	if created {
+		resp.WebhookSecret = b.WebhookSecret
+		status = 201
+	}
+	c.JSON(status, resp)
+}
+
+// GetBinding 获取 worker 的仓库绑定
+func (h *GitHubHandler) GetBinding(c *gin.Context) {
+	b, err := dblayer.GetGitHubBindingByOwner(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "worker is not bound to a repository"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get github binding"})
+		return
+	}
+	c.JSON(200, GitHubBindingResponse{GitHubBinding: b, WebhookURL: githubWebhookURL("/webhooks/github")})
+}
+
+// DeleteBinding 解除绑定，之后的投递不再部署该 worker
+func (h *GitHubHandler) DeleteBinding(c *gin.Context) {
+	err := dblayer.DeleteGitHubBinding(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "worker is not bound to a repository"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to delete github binding"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "github binding deleted"})
+}
+
+// Webhook 接收 GitHub 的 push / workflow_run 投递（公开路由）。没有任何绑定的 secret 能校验签名时返回 401；
+// 签名匹配的绑定逐个部署，结果（含跳过原因和错误）在响应里，GitHub 的投递记录可以看到
+func (h *GitHubHandler) Webhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var ev githubDeployEvent
+	if err := json.Unmarshal(payload, &ev); err != nil || ev.Repository.FullName == "" {
+		c.JSON(400, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	bindings, err := dblayer.ListGitHubBindingsByRepo(ctx, ev.Repository.FullName)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list github bindings"})
+		return
+	}
+	signature := c.GetHeader(GitHubSignatureHeader)
+	var matched []*dblayer.GitHubBinding
+	for _, b := range bindings {
+		if validGitHubSignature(b.WebhookSecret, payload, signature) {
+			matched = append(matched, b)
+		}
+	}
+	if len(matched) == 0 {
+		// 和未绑定的仓库不作区分
+		RequestLog(c).Warn("github webhook rejected", "repo", ev.Repository.FullName)
+		c.JSON(401, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	event := c.GetHeader("X-GitHub-Event")
+	resp := GitHubWebhookResponse{Deploys: []GitHubDeployResult{}}
+	for _, b := range matched {
+		sha := ev.commitFor(event, b)
+		if sha == "" {
+			continue
+		}
+		result := deployCommit(ctx, b, sha)
+		if result.Error != "" {
+			RequestLog(c).Warn("github deploy failed", "worker_id", b.WorkerID, "sha", sha, "error", result.Error)
+		}
+		resp.Deploys = append(resp.Deploys, result)
+	}
+	c.JSON(200, resp)
+}
+
+// deployCommit 把提交部署到绑定的 worker，端口沿用当前版本；当前版本已是该提交时跳过（重复投递）
+func deployCommit(ctx context.Context, b *dblayer.GitHubBinding, sha string) GitHubDeployResult {
+	result := GitHubDeployResult{WorkerID: b.WorkerID, CommitSHA: sha}
+	w, err := dblayer.GetWorkerByOwner(ctx, b.WorkerID, b.UserUID)
+	if err != nil {
+		result.Error = "worker not found"
+		return result
+	}
+	base, err := webhookBaseVersion(ctx, w)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if base.CommitSHA == sha {
+		result.Skipped = "commit is already deployed"
+		return result
+	}
+
+	resp, err := deployWorker(ctx, DeployWorkerRequest{
+		UserUID:   b.UserUID,
+		WorkerID:  b.WorkerID,
+		Image:     renderImageTemplate(b.ImageTemplate, 0, sha, b.Branch),
+		Port:      base.Port,
+		CommitSHA: sha,
+		Initiator: dblayer.DeployInitiatorGitHub,
+	})
+	var rejected *DeployRejectedError
+	switch {
+	case errors.As(err, &rejected), err == errAccountSuspended, err == errCreateDeployVersion, err == errEnqueueDeploy:
+		result.Error = err.Error()
+	case err != nil:
+		result.Error = "failed to deploy"
+	default:
+		result.VersionID = resp.VersionID
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListWorkerVersions GET /api/worker/:id/versions 部署历史：镜像、端口、时间、发起方和状态
+func (h *WorkerHandler) ListWorkerVersions(c *gin.Context) {
+	ctx := c.Request.Context()
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, c.Param("id")) {
+		return
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	versions, next, err := dblayer.ListWorkerDeployVersions(ctx, w.ID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("list deploy versions failed", "worker_id", w.WID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to list versions"})
+		return
+	}
+	setNextCursor(c, next)
+	c.JSON(200, DeployVersionsResponse{Versions: versions})
+}
+
+// DiffWorkerVersions GET /api/worker/:id/versions/:a/diff/:b 从版本 a 到版本 b 改了什么
+// （镜像、env key、secret、资源），依据部署时保存的快照
+func (h *WorkerHandler) DiffWorkerVersions(c *gin.Context) {
+	ctx := c.Request.Context()
+	a, errA := strconv.Atoi(c.Param("a"))
+	b, errB := strconv.Atoi(c.Param("b"))
+	if errA != nil || errB != nil {
+		c.JSON(400, gin.H{"error": "invalid version id"})
+		return
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, c.Param("id")) {
+		return
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	var versions [2]*dblayer.WorkerDeployVersion
+	for i, id := range []int{a, b} {
+		v, err := dblayer.GetWorkerDeployVersion(ctx, w.ID, id)
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "version " + strconv.Itoa(id) + " not found"})
+			return
+		}
+		if err != nil {
+			RequestLog(c).Error("get deploy version failed", "worker_id", w.WID, "version_id", id, "error", err)
+			c.JSON(500, gin.H{"error": "failed to get version"})
+			return
+		}
+		versions[i] = v
+	}
+	diff := dblayer.DiffDeployVersions(versions[0], versions[1])
+	c.JSON(200, DeployDiffResponse{From: versions[0], To: versions[1], Partial: diff.Partial, Changes: diff.Changes})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+
+	"jabberwocky238/console/secrets"
+)
+
+// dotenvVar .env 文件里的一条变量，Line 是它开始的行号
+type dotenvVar struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// parseDotenv 解析 .env 文件：# 开头的行和空行跳过，可带 export 前缀；
+// 双引号值支持 \n \t \" \\ 等转义，单引号值原样保留，两种引号都可以跨行；
+// 不带引号的值去掉空白后的 # 注释。同一个 key 出现多次时后面的生效，顺序按第一次出现
+func parseDotenv(src string) ([]dotenvVar, error) {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var vars []dotenvVar
+	index := map[string]int{}
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		text := strings.TrimLeft(lines[i], " \t")
+		if strings.TrimSpace(text) == "" || text[0] == '#' {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(text, "export "); ok {
+			text = strings.TrimLeft(rest, " \t")
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if !envNamePattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid env name %q", lineNo, key)
+		}
+		raw := value
+		value = strings.TrimLeft(value, " \t")
+
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			body := value[1:]
+			end := closingQuote(body, quote)
+			for end < 0 {
+				i++
+				if i == len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated %c quote", lineNo, quote)
+				}
+				body += "\n" + lines[i]
+				end = closingQuote(body, quote)
+			}
+			if rest := strings.TrimSpace(body[end+1:]); rest != "" && rest[0] != '#' {
+				return nil, fmt.Errorf("line %d: unexpected %q after quoted value", lineNo, rest)
+			}
+			value = body[:end]
+			if quote == '"' {
+				value = unescapeDotenv(value)
+			}
+		} else {
+			// 不带引号时 # 前面有空白才是注释，a#b 是值的一部分
+			value = raw
+			for j := 1; j < len(value); j++ {
+				if value[j] == '#' && (value[j-1] == ' ' || value[j-1] == '\t') {
+					value = value[:j]
+					break
+				}
+			}
+			value = strings.TrimSpace(value)
+		}
+
+		if j, ok := index[key]; ok {
+			vars[j].Value = value
+			vars[j].Line = lineNo
+			continue
+		}
+		index[key] = len(vars)
+		vars = append(vars, dotenvVar{Key: key, Value: value, Line: lineNo})
+	}
+	return vars, nil
+}
+
+// closingQuote 返回结束引号的位置，没有时返回 -1；双引号里 \ 转义下一个字符
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeDotenv 处理双引号值里的转义，不认识的转义保留反斜杠
+func unescapeDotenv(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$', '`':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// 导入 env 时分类的原因
+const (
+	EnvReasonOverride  = "override"  // 用户用 ?secret= / ?plain= 指定
+	EnvReasonExisting  = "existing"  // 已经是 worker secret
+	EnvReasonName      = "name"      // 名字像 secret（TOKEN、PASSWORD、API_KEY……）
+	EnvReasonPublic    = "public"    // 名字标明可以公开（PUBLIC、PUBLISHABLE）
+	EnvReasonValue     = "value"     // 值像 secret（带密码的 URL、私钥、已知 token 前缀、高熵字符串）
+	EnvReasonSecretRef = "secretref" // secretref:// 引用，同步时才解密，本身按普通 env 保存
+)
+
+// secretNameWords 变量名里按 _ 分开后出现这些词时按 secret 处理
+var secretNameWords = []string{
+	"SECRET", "SECRETS", "TOKEN", "TOKENS", "PASSWORD", "PASSWD", "PASS", "PWD", "KEY", "APIKEY",
+	"PRIVATE", "CREDENTIAL", "CREDENTIALS", "AUTH", "SALT", "DSN", "SIGNATURE",
+}
+
+// publicNameWords 变量名里出现这些词时即使带 KEY 也按普通 env 处理，如 STRIPE_PUBLISHABLE_KEY
+var publicNameWords = []string{"PUBLIC", "PUBLISHABLE"}
+
+// secretValuePrefixes 常见 token 的前缀
+var secretValuePrefixes = []string{
+	"sk_live_", "sk_test_", "rk_live_", "whsec_", "ghp_", "gho_", "ghs_", "github_pat_",
+	"glpat-", "xoxb-", "xoxp-", "AKIA", "AIza", "eyJ",
+}
+
+// classifyEnv 按名字和值猜一个变量是否应该存为 secret，返回结果和原因
+func classifyEnv(key, value string) (bool, string) {
+	if _, ok := secrets.ParseRef(value); ok {
+		return false, EnvReasonSecretRef
+	}
+	words := strings.Split(key, "_")
+	if slices.ContainsFunc(words, func(w string) bool { return slices.Contains(publicNameWords, w) }) {
+		return false, EnvReasonPublic
+	}
+	if slices.ContainsFunc(words, func(w string) bool { return slices.Contains(secretNameWords, w) }) {
+		return true, EnvReasonName
+	}
+	if secretLikeValue(value) {
+		return true, EnvReasonValue
+	}
+	return false, ""
+}
+
+// secretLikeValue 值本身看起来是凭据
+func secretLikeValue(v string) bool {
+	if strings.Contains(v, "-----BEGIN ") {
+		return true
+	}
+	if slices.ContainsFunc(secretValuePrefixes, func(p string) bool { return strings.HasPrefix(v, p) && len(v) > len(p)+8 }) {
+		return true
+	}
+	if strings.Contains(v, "://") {
+		u, err := url.Parse(v)
+		if err != nil || u.User == nil {
+			return false
+		}
+		_, hasPassword := u.User.Password()
+		return hasPassword
+	}
+	return highEntropy(v)
+}
+
+// highEntropy 24 个字符以上、不含空白、同时有字母和数字、每字符熵不低于 4 bit 的字符串，像随机生成的 key
+func highEntropy(v string) bool {
+	if len(v) < 24 || strings.ContainsAny(v, " \t\n") {
+		return false
+	}
+	if !strings.ContainsAny(v, "0123456789") || !strings.ContainsAny(strings.ToLower(v), "abcdefghijklmnopqrstuvwxyz") {
+		return false
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range v {
+		counts[r]++
+		n++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= 4
+}
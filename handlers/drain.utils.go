@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 停机流程：先 StartDraining 让 /ready 返回 503，等负载均衡摘掉流量，
+// 再关闭监听、等待在途请求结束。SSE/WebSocket/日志流这类长连接收到 Draining() 后主动断开，
+// 否则 http.Server.Shutdown 会一直等到超时。
+var (
+	draining  atomic.Bool
+	drainCh   = make(chan struct{})
+	drainOnce sync.Once
+)
+
+// StartDraining 进入停机流程，可重复调用
+func StartDraining() {
+	drainOnce.Do(func() {
+		draining.Store(true)
+		close(drainCh)
+	})
+}
+
+// Draining 停机开始时关闭
+func Draining() <-chan struct{} {
+	return drainCh
+}
+
+// Ready readinessProbe：停机中或数据库不可用时返回 503，存活检查仍用 /health
+func Ready(c *gin.Context) {
+	status := HealthResponse{
+		Status:    "ok",
+		Timestamp: time.Now().Unix(),
+		Database:  "not_initialized",
+	}
+	if draining.Load() {
+		status.Status = "draining"
+		c.JSON(503, status)
+		return
+	}
+	if dblayer.DB != nil {
+		if err := dblayer.DB.Ping(); err != nil {
+			status.Status = "unavailable"
+			status.Database = "unhealthy"
+			status.DatabaseError = err.Error()
+			c.JSON(503, status)
+			return
+		}
+		status.Database = "healthy"
+	}
+	c.JSON(200, status)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 邮件模板：内置模板编进 notify 包，email_templates 表按模板、语言覆盖。
+// 每封邮件渲染时读覆盖，所以这里的修改不需要通知其他实例
+
+// ListEmailTemplates GET /admin/email-templates 所有模板和有覆盖的语言
+func ListEmailTemplates(c *gin.Context) {
+	rows, err := dblayer.ListEmailTemplates(c.Request.Context())
+	if err != nil {
+		RequestLog(c).Error("list email templates failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list email templates"})
+		return
+	}
+	overrides := map[string][]string{}
+	for _, r := range rows {
+		overrides[r.Name] = append(overrides[r.Name], r.Locale)
+	}
+	out := make([]EmailTemplateSummary, 0, len(notify.Templates()))
+	for _, t := range notify.Templates() {
+		locales := overrides[t.Name]
+		if locales == nil {
+			locales = []string{}
+		}
+		out = append(out, EmailTemplateSummary{TemplateInfo: t, Overrides: locales})
+	}
+	c.JSON(200, EmailTemplatesResponse{Templates: out, Locales: notify.Locales})
+}
+
+// GetEmailTemplate GET /admin/email-templates/:name/:locale 生效的源码（覆盖或内置）和内置源码
+func GetEmailTemplate(c *gin.Context) {
+	info, locale, ok := emailTemplateParams(c)
+	if !ok {
+		return
+	}
+	resp := EmailTemplateResponse{Name: info.Name, Locale: locale, Sample: info.Sample}
+	resp.Builtin, _ = notify.Builtin(info.Name, locale)
+	t, err := dblayer.GetEmailTemplate(c.Request.Context(), info.Name, locale)
+	switch {
+	case err == nil:
+		resp.Source, resp.Overridden = t.Source, true
+		resp.UpdatedBy, resp.UpdatedAt = t.UpdatedBy, &t.UpdatedAt
+	case err == dblayer.ErrNotFound:
+		resp.Source, _, _ = notify.Source(c.Request.Context(), info.Name, locale)
+	default:
+		RequestLog(c).Error("get email template failed", "template", info.Name, "locale", locale, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get email template"})
+		return
+	}
+	c.JSON(200, resp)
+}
+
+// SetEmailTemplate PUT /admin/email-templates/:name/:locale 覆盖模板；用示例数据渲染不通过时 400
+func SetEmailTemplate(c *gin.Context) {
+	info, locale, ok := emailTemplateParams(c)
+	if !ok {
+		return
+	}
+	var req SetEmailTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if _, err := notify.RenderSource(req.Source, info.Sample); err != nil {
+		c.JSON(400, gin.H{"error": "template does not render with the sample data: " + err.Error()})
+		return
+	}
+	if err := dblayer.SetEmailTemplate(c.Request.Context(), info.Name, locale, req.Source, internalCaller(c)); err != nil {
+		RequestLog(c).Error("set email template failed", "template", info.Name, "locale", locale, "error", err)
+		c.JSON(500, gin.H{"error": "failed to save email template"})
+		return
+	}
+	RequestLog(c).Info("email template overridden", "template", info.Name, "locale", locale)
+	GetEmailTemplate(c)
+}
+
+// DeleteEmailTemplate DELETE /admin/email-templates/:name/:locale 删除覆盖，回到内置模板
+func DeleteEmailTemplate(c *gin.Context) {
+	info, locale, ok := emailTemplateParams(c)
+	if !ok {
+		return
+	}
+	if err := dblayer.DeleteEmailTemplate(c.Request.Context(), info.Name, locale); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "no override of " + info.Name + " in " + locale})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("delete email template failed", "template", info.Name, "locale", locale, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete email template"})
+		return
+	}
+	RequestLog(c).Info("email template override removed", "template", info.Name, "locale", locale)
+	GetEmailTemplate(c)
+}
+
+// PreviewEmailTemplate POST /admin/email-templates/:name/:locale/preview 渲染但不发送
+func PreviewEmailTemplate(c *gin.Context) {
+	info, locale, ok := emailTemplateParams(c)
+	if !ok {
+		return
+	}
+	var req PreviewEmailTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	msg, ok := renderEmailPreview(c, info, locale, req)
+	if !ok {
+		return
+	}
+	c.JSON(200, msg)
+}
+
+// TestEmailTemplate POST /admin/email-templates/:name/:locale/test 渲染后发一封测试邮件
+func TestEmailTemplate(c *gin.Context) {
+	info, locale, ok := emailTemplateParams(c)
+	if !ok {
+		return
+	}
+	var req TestEmailTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	msg, ok := renderEmailPreview(c, info, locale, req.PreviewEmailTemplateRequest)
+	if !ok {
+		return
+	}
+	id, err := notify.Deliver(req.To, msg)
+	if err != nil {
+		RequestLog(c).Error("send test email failed", "template", info.Name, "locale", locale, "error", err)
+		c.JSON(502, gin.H{"error": "failed to send test email: " + err.Error()})
+		return
+	}
+	RequestLog(c).Info("test email sent", "template", info.Name, "locale", locale, "to", req.To)
+	c.JSON(200, TestEmailTemplateResponse{Message: msg, ID: id})
+}
+
+// emailTemplateParams 校验 :name 和 :locale，不合法时已写好 404
+func emailTemplateParams(c *gin.Context) (notify.TemplateInfo, string, bool) {
+	info, ok := notify.Lookup(c.Param("name"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "email template " + c.Param("name") + " not found"})
+		return info, "", false
+	}
+	locale := c.Param("locale")
+	if !notify.SupportedLocale(locale) {
+		c.JSON(404, gin.H{"error": "locale " + locale + " is not supported"})
+		return info, "", false
+	}
+	return info, locale, true
+}
+
+// renderEmailPreview 用请求里的 source/data 渲染，缺省时用生效的模板和示例数据。
+// data 按示例数据的类型解析，字段写错时 400
+func renderEmailPreview(c *gin.Context, info notify.TemplateInfo, locale string, req PreviewEmailTemplateRequest) (notify.Message, bool) {
+	data := info.Sample
+	if len(req.Data) > 0 {
+		v := reflect.New(reflect.TypeOf(info.Sample))
+		dec := json.NewDecoder(bytes.NewReader(req.Data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v.Interface()); err != nil {
+			c.JSON(400, gin.H{"error": "invalid data: " + err.Error()})
+			return notify.Message{}, false
+		}
+		data = v.Elem().Interface()
+	}
+	src := req.Source
+	if src == "" {
+		src, _, _ = notify.Source(c.Request.Context(), info.Name, locale)
+	}
+	msg, err := notify.RenderSource(src, data)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return notify.Message{}, false
+	}
+	return msg, true
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/secrets"
+
+	"github.com/gin-gonic/gin"
+)
+
+// env 组：用户维护一次、挂到多个 worker 上的共享变量。inner 同步 worker env 时按挂的顺序合并，
+// 后面的组覆盖前面的，worker 自己的 env 覆盖所有组；组变更后挂着它的 worker 各投递一个 env 同步任务
+
+// env 组的限制
+const (
+	MaxEnvGroups       = 50
+	MaxEnvGroupVars    = 200
+	MaxWorkerEnvGroups = 10
+	maxEnvGroupValue   = 65536
+)
+
+var errEnvGroupLimit = fmt.Errorf("at most %d env groups", MaxEnvGroups)
+
+// validateEnvGroupVars 检查组变量的名字、大小和 secretref:// 引用。返回 errSetEnv 表示查库失败，其余是请求错误
+func validateEnvGroupVars(ctx context.Context, userUID string, vars map[string]string) error {
+	if len(vars) > MaxEnvGroupVars {
+		return fmt.Errorf("at most %d vars per env group", MaxEnvGroupVars)
+	}
+	for k, v := range vars {
+		if !envNamePattern.MatchString(k) || slices.Contains(controller.ReservedEnvKeys, k) {
+			return fmt.Errorf("invalid env name %s", k)
+		}
+		if len(v) > maxEnvGroupValue {
+			return fmt.Errorf("value of %s is over %d bytes", k, maxEnvGroupValue)
+		}
+		if name, ok := secrets.ParseRef(v); ok {
+			if _, _, err := dblayer.GetUserSecret(ctx, userUID, name); err == dblayer.ErrNotFound {
+				return fmt.Errorf("%s references unknown secret %q", k, name)
+			} else if err != nil {
+				return errSetEnv
+			}
+		}
+	}
+	return nil
+}
+
+// envGroupVarsError 按 validateEnvGroupVars 的结果回应，没有错误时返回 true
+func envGroupVarsError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case err == errSetEnv:
+		RequestLog(c).Error("validate env group failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to validate env group"})
+	default:
+		c.JSON(400, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// maskEnvGroup 返回变量打码后的副本
+func maskEnvGroup(g *dblayer.EnvGroup) *dblayer.EnvGroup {
+	masked := *g
+	masked.Vars = maskEnv(g.Vars)
+	return &masked
+}
+
+// ListEnvGroups GET /api/env-groups 用户的所有 env 组
+func ListEnvGroups(c *gin.Context) {
+	groups, err := dblayer.ListEnvGroups(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		RequestLog(c).Error("list env groups failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list env groups"})
+		return
+	}
+	for i, g := range groups {
+		groups[i] = maskEnvGroup(g)
+	}
+	c.JSON(200, EnvGroupsResponse{Groups: groups})
+}
+
+// CreateEnvGroup POST /api/env-groups 创建 env 组
+func CreateEnvGroup(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+
+	var req CreateEnvGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !envGroupVarsError(c, validateEnvGroupVars(ctx, userUID, req.Vars)) {
+		return
+	}
+	n, err := dblayer.CountEnvGroups(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("count env groups failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create env group"})
+		return
+	}
+	if n >= MaxEnvGroups {
+		c.JSON(403, gin.H{"error": errEnvGroupLimit.Error()})
+		return
+	}
+	if err := dblayer.CreateEnvGroup(ctx, userUID, req.Name, req.Vars); err != nil {
+		if err == dblayer.ErrEnvGroupExists {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		RequestLog(c).Error("create env group failed", "group", req.Name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to create env group"})
+		return
+	}
+	writeEnvGroup(c, 201, userUID, req.Name)
+}
+
+// GetEnvGroup GET /api/env-groups/:name env 组和挂着它的 worker
+func GetEnvGroup(c *gin.Context) {
+	writeEnvGroup(c, 200, c.GetString("user_id"), c.Param("name"))
+}
+
+// writeEnvGroup 返回 env 组（打码）和挂着它的 worker
+func writeEnvGroup(c *gin.Context, code int, userUID, name string) {
+	g, err := dblayer.GetEnvGroup(c.Request.Context(), userUID, name)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "env group not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get env group failed", "group", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get env group"})
+		return
+	}
+	workers, err := dblayer.ListEnvGroupWorkers(c.Request.Context(), dblayer.DB, userUID, name)
+	if err != nil {
+		RequestLog(c).Error("list env group workers failed", "group", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get env group"})
+		return
+	}
+	c.JSON(code, EnvGroupResponse{EnvGroup: *maskEnvGroup(g), Workers: workers})
+}
+
+// SetEnvGroupVars PUT /api/env-groups/:name 替换 env 组的变量，挂着它的 worker 重新同步 env
+func SetEnvGroupVars(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	name := c.Param("name")
+
+	var req SetEnvGroupVarsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !envGroupVarsError(c, validateEnvGroupVars(ctx, userUID, req.Vars)) {
+		return
+	}
+	var version int
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		version, err = dblayer.SetEnvGroupVars(ctx, tx, userUID, name, req.Vars, req.ExpectedVersion)
+		if err != nil {
+			return err
+		}
+		workers, err := dblayer.ListEnvGroupWorkers(ctx, tx, userUID, name)
+		if err != nil {
+			return fmt.Errorf("list env group workers: %w", err)
+		}
+		return resyncWorkerEnv(ctx, tx, userUID, workers)
+	})
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "env group not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		RequestLog(c).Error("set env group vars failed", "group", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to set env group"})
+		return
+	}
+	KickOutbox()
+	writeEnvGroup(c, 200, userUID, name)
+}
+
+// DeleteEnvGroup DELETE /api/env-groups/:name 删除 env 组，挂着它的 worker 摘掉后重新同步 env
+func DeleteEnvGroup(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	name := c.Param("name")
+
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		workers, err := dblayer.DeleteEnvGroup(ctx, tx, userUID, name)
+		if err != nil {
+			return err
+		}
+		return resyncWorkerEnv(ctx, tx, userUID, workers)
+	})
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "env group not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("delete env group failed", "group", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete env group"})
+		return
+	}
+	KickOutbox()
+	c.JSON(200, MessageResponse{Message: "env group deleted"})
+}
+
+// resyncWorkerEnv 在 tx 里投递这些 worker 的 env 同步任务，让它们拿到 env 组、环境或 secret 的新值
+func resyncWorkerEnv(ctx context.Context, tx dblayer.Querier, userUID string, workers []string) error {
+	for _, workerID := range workers {
+		env, err := getWorkerEnv(ctx, workerID, userUID)
+		if err != nil {
+			continue
+		}
+		if err := enqueueTask(ctx, tx, jobs.NewSyncEnvJob(workerID, userUID, env)); err != nil {
+			return errEnqueueSync
+		}
+	}
+	return nil
+}
+
+// GetWorkerEnvGroups GET /api/worker/:id/env-groups worker 挂的 env 组和合并上所在环境的变量后生效的变量
+func (h *WorkerHandler) GetWorkerEnvGroups(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	env, err := getWorkerEnv(ctx, workerID, userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	groups, err := dblayer.GetWorkerEnvGroups(ctx, workerID)
+	if err != nil {
+		RequestLog(c).Error("get worker env groups failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get env groups"})
+		return
+	}
+	base, err := dblayer.GetWorkerEnvironmentVars(ctx, workerID)
+	if err != nil {
+		RequestLog(c).Error("get worker environment vars failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get env groups"})
+		return
+	}
+	names := make([]string, len(groups))
+	for i, g := range groups {
+		names[i] = g.Name
+	}
+	effective := dblayer.MergeEnvGroups(append([]*dblayer.EnvGroup{{Vars: base}}, groups...), env)
+	c.JSON(200, WorkerEnvGroupsResponse{Groups: names, Effective: maskEnv(effective)})
+}
+
+// SetWorkerEnvGroups PUT /api/worker/:id/env-groups 按顺序替换 worker 挂的 env 组，env 由 inner 重新同步
+func (h *WorkerHandler) SetWorkerEnvGroups(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetWorkerEnvGroupsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if len(req.Groups) > MaxWorkerEnvGroups {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("at most %d env groups per worker", MaxWorkerEnvGroups)})
+		return
+	}
+	if len(slices.Compact(slices.Sorted(slices.Values(req.Groups)))) != len(req.Groups) {
+		c.JSON(400, gin.H{"error": "env groups must not repeat"})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	version, err := setWorkerEnvGroups(c.Request.Context(), workerID, userUID, req.Groups, req.ExpectedVersion)
+	var unknown *dblayer.UnknownEnvGroupError
+	switch {
+	case errors.As(err, &unknown):
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.GetWorkerEnvGroups(c)
+}
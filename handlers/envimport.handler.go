@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxImportEnvVars 一次 .env 导入最多的变量数
+const MaxImportEnvVars = 500
+
+// ImportWorkerEnv POST /api/worker/:id/env/import 导入 .env 文件。
+// 每个变量按名字和值猜是否为 secret，?secret=KEY / ?plain=KEY（可重复）指定，已经是 worker secret 的仍存为 secret；
+// 普通变量合并进 env，secret 从 env 移到 worker Secret，一次保存后经 secret 和 env 同步任务下发。
+// ?dry_run=true 只返回分类
+func (h *WorkerHandler) ImportWorkerEnv(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	// 请求体是 .env 原文；JSON 时文件内容放在 content 里（生成的客户端用这种）
+	var content string
+	if c.ContentType() == "application/json" {
+		var req ImportEnvRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, bindError(err))
+			return
+		}
+		content = req.Content
+	} else {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "failed to read body: " + err.Error()})
+			return
+		}
+		content = string(body)
+	}
+	vars, err := parseDotenv(content)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid .env file: " + err.Error()})
+		return
+	}
+	if len(vars) == 0 {
+		c.JSON(400, gin.H{"error": "no variables in the file"})
+		return
+	}
+	if len(vars) > MaxImportEnvVars {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("at most %d variables per import", MaxImportEnvVars)})
+		return
+	}
+
+	secretKeys, plainKeys := c.QueryArray("secret"), c.QueryArray("plain")
+	for _, k := range append(slices.Clone(secretKeys), plainKeys...) {
+		if !slices.ContainsFunc(vars, func(v dotenvVar) bool { return v.Key == k }) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("%s is not in the file", k)})
+			return
+		}
+		if slices.Contains(secretKeys, k) && slices.Contains(plainKeys, k) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("%s is both secret and plain", k)})
+			return
+		}
+	}
+
+	secretsJSON, _, err := dblayer.GetWorkerSecretsByOwner(ctx, workerID, userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	var existing []string
+	json.Unmarshal([]byte(secretsJSON), &existing)
+
+	resp := ImportEnvResponse{DryRun: c.Query("dry_run") == "true", Vars: []ImportedEnvVar{}}
+	plain, secretVals := map[string]string{}, map[string]string{}
+	for _, v := range vars {
+		secret, reason := classifyEnv(v.Key, v.Value)
+		switch {
+		case slices.Contains(secretKeys, v.Key):
+			secret, reason = true, EnvReasonOverride
+		case slices.Contains(plainKeys, v.Key):
+			secret, reason = false, EnvReasonOverride
+		case slices.Contains(existing, v.Key):
+			secret, reason = true, EnvReasonExisting
+		}
+		resp.Vars = append(resp.Vars, ImportedEnvVar{Key: v.Key, Line: v.Line, Secret: secret, Reason: reason})
+		if secret {
+			secretVals[v.Key] = v.Value
+		} else {
+			plain[v.Key] = v.Value
+		}
+	}
+	if resp.DryRun {
+		c.JSON(200, resp)
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	envMap, keys, version, err := importWorkerEnv(ctx, workerID, userUID, plain, secretVals, 0)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case errors.Is(err, errReservedEnvKey), errors.Is(err, errUnknownSecret):
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errAttachedEnvKey), errors.Is(err, errSecretEnvKey):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp.Env = maskEnv(envMap)
+	resp.Secrets = keys
+	c.JSON(200, resp)
+}
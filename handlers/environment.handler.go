@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 环境（production、staging……）：每个 worker、RDB/KV 和自定义域名属于一个环境，默认 production，
+// 列表接口可以用 ?environment= 过滤。环境可以有自己的变量，inner 同步 worker env 时垫在 env 组和
+// worker 自己的 env 下面；promote 把一个环境里 worker 的镜像版本部署到另一个环境的 worker 上
+
+// MaxEnvironmentVars 一个环境最多的变量数
+const MaxEnvironmentVars = MaxEnvGroupVars
+
+var (
+	// errInvalidPromote promote 请求本身的问题，Error() 直接返回给调用方
+	errInvalidPromote = errors.New("invalid promote")
+	// errPromoteTarget 目标 worker 不存在
+	errPromoteTarget = errors.New("target worker not found")
+)
+
+// ListEnvironments GET /api/environments 用户的所有环境、各自的资源数量和变量（打码）
+func ListEnvironments(c *gin.Context) {
+	envs, err := dblayer.ListEnvironments(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		RequestLog(c).Error("list environments failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list environments"})
+		return
+	}
+	for _, e := range envs {
+		e.Vars = maskEnv(e.Vars)
+	}
+	c.JSON(200, EnvironmentsResponse{Environments: envs})
+}
+
+// GetEnvironment GET /api/environments/:name 一个环境和它下面的 worker
+func GetEnvironment(c *gin.Context) {
+	writeEnvironment(c, c.GetString("user_id"), c.Param("name"))
+}
+
+// writeEnvironment 返回环境（变量打码）和它下面的 worker；没有资源也没有变量的环境返回 404
+func writeEnvironment(c *gin.Context, userUID, name string) {
+	ctx := c.Request.Context()
+	envs, err := dblayer.ListEnvironments(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("list environments failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get environment"})
+		return
+	}
+	var env *dblayer.Environment
+	for _, e := range envs {
+		if e.Name == name {
+			env = e
+			break
+		}
+	}
+	if env == nil {
+		c.JSON(404, gin.H{"error": "environment not found"})
+		return
+	}
+	workers, err := dblayer.ListEnvironmentWorkers(ctx, userUID, name)
+	if err != nil {
+		RequestLog(c).Error("list environment workers failed", "environment", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get environment"})
+		return
+	}
+	env.Vars = maskEnv(env.Vars)
+	c.JSON(200, EnvironmentResponse{Environment: *env, WorkerIDs: workers})
+}
+
+// SetEnvironmentVars PUT /api/environments/:name 替换环境的变量，环境下的 worker 重新同步 env
+func SetEnvironmentVars(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	name := c.Param("name")
+
+	if !environmentPattern.MatchString(name) {
+		c.JSON(400, gin.H{"error": "invalid environment name"})
+		return
+	}
+	var req SetEnvironmentVarsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if len(req.Vars) > MaxEnvironmentVars {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("at most %d vars per environment", MaxEnvironmentVars)})
+		return
+	}
+	if !envGroupVarsError(c, validateEnvGroupVars(ctx, userUID, req.Vars)) {
+		return
+	}
+	version, err := dblayer.SetEnvironmentVars(ctx, userUID, name, req.Vars, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "environment has no vars yet, omit expected_version"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		RequestLog(c).Error("set environment vars failed", "environment", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to set environment vars"})
+		return
+	}
+	workers, err := dblayer.ListEnvironmentWorkers(ctx, userUID, name)
+	if err != nil {
+		RequestLog(c).Error("list environment workers failed", "environment", name, "error", err)
+	}
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		return resyncWorkerEnv(ctx, tx, userUID, workers)
+	})
+	if err != nil {
+		RequestLog(c).Warn("enqueue env sync failed", "environment", name, "error", err)
+	} else {
+		KickOutbox()
+	}
+	writeEnvironment(c, userUID, name)
+}
+
+// DeleteEnvironment DELETE /api/environments/:name 删除环境的变量，环境下还有资源时返回 409
+func DeleteEnvironment(c *gin.Context) {
+	err := dblayer.DeleteEnvironment(c.Request.Context(), c.GetString("user_id"), c.Param("name"))
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "environment not found"})
+	case err == dblayer.ErrEnvironmentInUse:
+		c.JSON(409, gin.H{"error": "environment still has workers, databases or domains, move them first"})
+	case err != nil:
+		RequestLog(c).Error("delete environment failed", "environment", c.Param("name"), "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete environment"})
+	default:
+		c.JSON(200, MessageResponse{Message: "environment deleted"})
+	}
+}
+
+// SetWorkerEnvironment PUT /api/worker/:id/environment 把 worker 移到另一个环境，env 由 inner 按新环境重新同步
+func (h *WorkerHandler) SetWorkerEnvironment(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	version, err := setWorkerEnvironment(c.Request.Context(), workerID, userUID, req.Environment, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, SetEnvironmentResponse{Environment: req.Environment, Version: version})
+}
+
+// SetRDBEnvironment moves an RDB resource to another environment
+func (h *CombinatorHandler) SetRDBEnvironment(c *gin.Context) {
+	h.setEnvironment(c, "rdb")
+}
+
+// SetKVEnvironment moves a KV resource to another environment
+func (h *CombinatorHandler) SetKVEnvironment(c *gin.Context) {
+	h.setEnvironment(c, "kv")
+}
+
+// setEnvironment RDB/KV 的环境只记在库里
+func (h *CombinatorHandler) setEnvironment(c *gin.Context, resourceType string) {
+	userUID := c.GetString("user_id")
+	resourceID := c.Param("id")
+
+	var req SetEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !checkResourcePrecondition(c, userUID, resourceType, resourceID) {
+		return
+	}
+
+	version, err := dblayer.SetCombinatorResourceEnvironment(c.Request.Context(), userUID, resourceType, resourceID, req.Environment, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "resource not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": errSetEnvironment.Error()})
+		return
+	}
+
+	c.JSON(200, SetEnvironmentResponse{Environment: req.Environment, Version: version})
+}
+
+// SetCustomDomainEnvironment moves a custom domain to another environment
+func SetCustomDomainEnvironment(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	cdid := c.Param("id")
+
+	var req SetEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if hasPrecondition(c) {
+		cd, ok := ownedCustomDomain(c, cdid)
+		if !ok || preconditionFailed(c, cd.UpdatedAt) {
+			return
+		}
+	}
+
+	version, err := dblayer.SetCustomDomainEnvironment(c.Request.Context(), cdid, userUID, req.Environment, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "domain not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": errSetEnvironment.Error()})
+		return
+	}
+
+	c.JSON(200, SetEnvironmentResponse{Environment: req.Environment, Version: version})
+}
+
+// PromoteWorker POST /api/worker/:id/promote 把 worker 的一个版本（默认当前版本）的镜像部署到另一个环境的
+// target_id 上，比如 staging 验证过的版本上 production。只复制镜像、端口和提交，目标 worker 的 env 和配置不变
+func (h *WorkerHandler) PromoteWorker(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	sourceID := c.Param("id")
+
+	var req PromoteWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	// If-Match 针对被部署的目标 worker
+	if !checkWorkerPrecondition(c, req.TargetID, userUID) {
+		return
+	}
+
+	resp, err := promoteWorker(c.Request.Context(), userUID, sourceID, req)
+	var rejected *DeployRejectedError
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+	case err == errPromoteTarget:
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidPromote):
+		c.JSON(400, gin.H{"error": strings.TrimPrefix(err.Error(), errInvalidPromote.Error()+": ")})
+	case errors.As(err, &rejected):
+		c.JSON(422, DeployRejectedResponse{Error: "deploy rejected by policy", Violations: rejected.Violations})
+	case err == errAccountSuspended:
+		c.JSON(402, gin.H{"error": err.Error()})
+	case err != nil:
+		RequestLog(c).Error("promote worker failed", "worker_id", sourceID, "target_id", req.TargetID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to promote worker"})
+	default:
+		c.JSON(200, resp)
+	}
+}
+
+// promoteWorker 校验源和目标不在同一个环境、版本属于源 worker，然后按该版本部署目标 worker
+func promoteWorker(ctx context.Context, userUID, sourceID string, req PromoteWorkerRequest) (PromoteWorkerResponse, error) {
+	if err := dblayer.AuthorizeResource(ctx, userUID, dblayer.ResourceWorker, sourceID); err != nil {
+		return PromoteWorkerResponse{}, err
+	}
+	if err := dblayer.AuthorizeResource(ctx, userUID, dblayer.ResourceWorker, req.TargetID); err == dblayer.ErrNotFound {
+		return PromoteWorkerResponse{}, errPromoteTarget
+	} else if err != nil {
+		return PromoteWorkerResponse{}, err
+	}
+	src, err := dblayer.GetWorkerByOwner(ctx, sourceID, userUID)
+	if err != nil {
+		return PromoteWorkerResponse{}, dblayer.ErrNotFound
+	}
+	if req.TargetID == src.WID {
+		return PromoteWorkerResponse{}, fmt.Errorf("%w: target is the source worker", errInvalidPromote)
+	}
+	dst, err := dblayer.GetWorkerByOwner(ctx, req.TargetID, userUID)
+	if err != nil {
+		return PromoteWorkerResponse{}, errPromoteTarget
+	}
+	if dst.Environment == src.Environment {
+		return PromoteWorkerResponse{}, fmt.Errorf("%w: both workers are in environment %s", errInvalidPromote, src.Environment)
+	}
+	if dst.Kind != src.Kind {
+		return PromoteWorkerResponse{}, fmt.Errorf("%w: source is a %s worker, target a %s worker", errInvalidPromote, src.Kind, dst.Kind)
+	}
+
+	versionID := req.VersionID
+	if versionID == 0 {
+		if src.ActiveVersionID == nil {
+			return PromoteWorkerResponse{}, fmt.Errorf("%w: source worker has no active version", errInvalidPromote)
+		}
+		versionID = *src.ActiveVersionID
+	}
+	v, err := dblayer.GetDeployVersion(ctx, versionID)
+	if err != nil || v.WorkerID != src.ID {
+		return PromoteWorkerResponse{}, fmt.Errorf("%w: version %d not found on the source worker", errInvalidPromote, versionID)
+	}
+
+	deployed, err := deployWorker(ctx, DeployWorkerRequest{
+		UserUID: userUID, WorkerID: dst.WID, Image: v.Image, Port: v.Port, CommitSHA: v.CommitSHA,
+		Initiator: dblayer.DeployInitiatorPromote, Static: v.Static,
+	})
+	if err != nil {
+		return PromoteWorkerResponse{}, err
+	}
+	return PromoteWorkerResponse{
+		WorkerID:      dst.WID,
+		Environment:   dst.Environment,
+		SourceID:      src.WID,
+		SourceVersion: v.ID,
+		VersionID:     deployed.VersionID,
+		Image:         v.Image,
+	}, nil
+}
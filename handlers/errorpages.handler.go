@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 维护页和自定义错误页：用户的 HTML 存在库里，由 outer 的 /error-pages 提供给 ingress。
+// inner 只在 WorkerApp CR 上记录维护开关和有哪些页面，controller 据此挂 errors 中间件或把路由切到这里
+
+const defaultMaintenancePage = `<!doctype html>
+<html><head><meta charset="utf-8"><title>Under maintenance</title></head>
+<body><h1>Under maintenance</h1><p>This service is temporarily down for maintenance. Please try again later.</p></body></html>
+`
+
+// GetWorkerErrorPages GET /api/worker/:id/error-pages 维护开关和自定义错误页
+func (h *WorkerHandler) GetWorkerErrorPages(c *gin.Context) {
+	workerID := c.Param("id")
+
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	p, err := dblayer.GetWorkerErrorPages(c.Request.Context(), workerID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get error pages"})
+		return
+	}
+	c.JSON(200, p)
+}
+
+// SetWorkerErrorPages PUT /api/worker/:id/error-pages 替换维护开关和错误页，路由由 inner 同步到 K8s
+func (h *WorkerHandler) SetWorkerErrorPages(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetErrorPagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if len(req.Page502) > k8s.MaxErrorPageBytes || len(req.Page503) > k8s.MaxErrorPageBytes {
+		c.JSON(400, gin.H{"error": "error pages are limited to 64 KiB each"})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	p := dblayer.ErrorPages{Maintenance: req.Maintenance, Page502: req.Page502, Page503: req.Page503}
+	version, err := setWorkerErrorPages(c.Request.Context(), workerID, userUID, p, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, p)
+}
+
+// WorkerErrorPage GET /error-pages/:name/:status 公开，ingress 的错误页服务。
+// name 是 worker 的资源名 w-<wid>-<uid>，status 为 502、503 或 maintenance（维护页，503）
+func WorkerErrorPage(c *gin.Context) {
+	status := c.Param("status")
+	code := 503
+	switch status {
+	case "502":
+		code = 502
+	case "503", "maintenance":
+	default:
+		c.String(404, "not found")
+		return
+	}
+	wid, uid, ok := strings.Cut(strings.TrimPrefix(c.Param("name"), "w-"), "-")
+	if !ok {
+		c.String(404, "not found")
+		return
+	}
+	p, err := dblayer.GetWorkerErrorPages(c.Request.Context(), wid, uid)
+	if err != nil {
+		RequestLog(c).Error("get worker error pages failed", "worker_id", wid, "error", err)
+		c.String(code, "")
+		return
+	}
+	page := p.Page(status)
+	if status == "maintenance" {
+		page = p.Page("503")
+		if page == "" {
+			page = defaultMaintenancePage
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(code, "text/html; charset=utf-8", []byte(page))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 条件请求：资源版本取自行的 updated_at（每次 UPDATE 由触发器刷新）。
+// GET 返回 ETag/Last-Modified，带 If-None-Match/If-Modified-Since 时可能返回 304；
+// 写操作带 If-Match/If-Unmodified-Since 时，版本不一致返回 412，供 IaC 工具做安全的读-改-写。
+// 检查和写入不是原子的；需要严格的读-改-写时在请求体里带 expected_version（行上的 version 列），
+// 由 UPDATE 的条件保证，冲突返回 409。
+
+// resourceETag 强 ETag：updated_at 的微秒时间戳
+func resourceETag(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.UnixMicro(), 36) + `"`
+}
+
+// setVersionHeaders 写 ETag 和 Last-Modified
+func setVersionHeaders(c *gin.Context, updatedAt time.Time) {
+	c.Header("ETag", resourceETag(updatedAt))
+	c.Header("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+}
+
+// notModified 用于 GET：客户端缓存仍然有效时写 304 并返回 true
+func notModified(c *gin.Context, updatedAt time.Time) bool {
+	setVersionHeaders(c, updatedAt)
+	etag := resourceETag(updatedAt)
+
+	// 有 If-None-Match 时忽略 If-Modified-Since（RFC 9110 13.2.2）
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if !etagListMatches(inm, etag, false) {
+			return false
+		}
+	} else if t, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err != nil || updatedAt.Truncate(time.Second).After(t) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// preconditionFailed 用于写操作：If-Match / If-Unmodified-Since 不满足时写 412 并返回 true。
+// 没有带这两个头的请求不做检查。
+func preconditionFailed(c *gin.Context, updatedAt time.Time) bool {
+	ok := true
+	if im := c.GetHeader("If-Match"); im != "" {
+		ok = etagListMatches(im, resourceETag(updatedAt), true)
+	} else if t, err := http.ParseTime(c.GetHeader("If-Unmodified-Since")); err == nil {
+		ok = !updatedAt.Truncate(time.Second).After(t)
+	}
+	if ok {
+		return false
+	}
+	setVersionHeaders(c, updatedAt)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "precondition failed: resource has been modified"})
+	return true
+}
+
+// versionConflict 写 409，带上资源当前版本，客户端重新读取后再改
+func versionConflict(c *gin.Context, current int) {
+	c.JSON(http.StatusConflict, gin.H{"error": "version conflict: resource has been modified", "version": current})
+}
+
+// etagListMatches 判断逗号分隔的 ETag 列表是否命中；strong 时弱 ETag 永不匹配
+func etagListMatches(header, etag string, strong bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		if weak, ok := strings.CutPrefix(t, "W/"); ok {
+			if strong {
+				continue
+			}
+			t = weak
+		}
+		if t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrecondition 请求是否带写前置条件，没有时调用方可以省掉一次读取当前版本
+func hasPrecondition(c *gin.Context) bool {
+	return c.GetHeader("If-Match") != "" || c.GetHeader("If-Unmodified-Since") != ""
+}
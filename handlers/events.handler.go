@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"io"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventsHandler 通过 SSE 推送资源状态变化
+type EventsHandler struct {
+	bus *dblayer.EventBus
+}
+
+func NewEventsHandler(bus *dblayer.EventBus) *EventsHandler {
+	return &EventsHandler{bus: bus}
+}
+
+// Stream 推送当前用户的事件流（部署进度、域名验证结果、任务完成）
+func (h *EventsHandler) Stream(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	ch := h.bus.Subscribe(userUID)
+	defer h.bus.Unsubscribe(ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// 定期心跳，防止代理断开空闲连接
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+
+	c.SSEvent("ready", gin.H{"user_id": userUID})
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent(ev.Kind, ev)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		case <-Draining():
+			// 停机：断开让客户端重连到其他实例
+			return false
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/features"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 功能开关：存在 feature_flags 表，各实例在内存里保留一份快照（features.Load），
+// 和运行时配置一样随 SettingsChannel 的通知重新加载。按用户评估：用户覆盖 > enabled > rollout_percent
+
+var flagKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// reloadFeatureFlags 从 feature_flags 表重新加载本实例的快照
+func reloadFeatureFlags(ctx context.Context) ([]dblayer.FeatureFlag, error) {
+	rows, err := dblayer.ListFeatureFlags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]features.Flag, 0, len(rows))
+	for _, r := range rows {
+		flags = append(flags, features.Flag{Key: r.Key, Enabled: r.Enabled, RolloutPercent: r.RolloutPercent, Users: r.Users})
+	}
+	features.Load(flags)
+	return rows, nil
+}
+
+// ListFeatureFlags GET /admin/flags 所有开关和按用户的覆盖（顺带重新加载本实例）
+func ListFeatureFlags(c *gin.Context) {
+	rows, err := reloadFeatureFlags(c.Request.Context())
+	if err != nil {
+		RequestLog(c).Error("list feature flags failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list feature flags"})
+		return
+	}
+	c.JSON(200, FeatureFlagsResponse{Flags: rows})
+}
+
+// SetFeatureFlag PUT /admin/flags/:key 新建或更新开关，用户覆盖保留
+func SetFeatureFlag(c *gin.Context) {
+	key := c.Param("key")
+	if !flagKeyPattern.MatchString(key) {
+		c.JSON(400, gin.H{"error": "flag key must be 1-64 lowercase letters, digits, '.', '_' or '-'"})
+		return
+	}
+	var req SetFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if err := dblayer.SetFeatureFlag(c.Request.Context(), key, req.Description, req.Enabled, req.RolloutPercent, internalCaller(c)); err != nil {
+		RequestLog(c).Error("set feature flag failed", "key", key, "error", err)
+		c.JSON(500, gin.H{"error": "failed to set feature flag"})
+		return
+	}
+	RequestLog(c).Info("feature flag changed", "key", key, "enabled", req.Enabled, "rollout_percent", req.RolloutPercent)
+	respondFeatureFlag(c, key)
+}
+
+// DeleteFeatureFlag DELETE /admin/flags/:key 删除开关，之后对所有人都是关闭
+func DeleteFeatureFlag(c *gin.Context) {
+	key := c.Param("key")
+	if err := dblayer.DeleteFeatureFlag(c.Request.Context(), key); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "feature flag " + key + " not found"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("delete feature flag failed", "key", key, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete feature flag"})
+		return
+	}
+	RequestLog(c).Info("feature flag deleted", "key", key)
+	if _, err := reloadFeatureFlags(c.Request.Context()); err != nil {
+		RequestLog(c).Warn("reload feature flags failed", "error", err)
+	}
+	c.JSON(200, MessageResponse{Message: "feature flag deleted"})
+}
+
+// SetFeatureFlagUser PUT /admin/flags/:key/users/:uid 对单个用户打开或关闭开关
+func SetFeatureFlagUser(c *gin.Context) {
+	key, uid := c.Param("key"), c.Param("uid")
+	var req SetFeatureFlagUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if err := dblayer.SetFeatureFlagUser(c.Request.Context(), key, uid, *req.Enabled, internalCaller(c)); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "feature flag or user not found"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("set feature flag user failed", "key", key, "user_id", uid, "error", err)
+		c.JSON(500, gin.H{"error": "failed to set feature flag"})
+		return
+	}
+	RequestLog(c).Info("feature flag user override changed", "key", key, "user_id", uid, "enabled", *req.Enabled)
+	respondFeatureFlag(c, key)
+}
+
+// DeleteFeatureFlagUser DELETE /admin/flags/:key/users/:uid 删除用户覆盖，回到 enabled/rollout_percent
+func DeleteFeatureFlagUser(c *gin.Context) {
+	key, uid := c.Param("key"), c.Param("uid")
+	if err := dblayer.DeleteFeatureFlagUser(c.Request.Context(), key, uid, internalCaller(c)); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "user override not found"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("delete feature flag user failed", "key", key, "user_id", uid, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete user override"})
+		return
+	}
+	RequestLog(c).Info("feature flag user override removed", "key", key, "user_id", uid)
+	respondFeatureFlag(c, key)
+}
+
+// GetAccountFeatures GET /admin/accounts/:uid/features 按当前快照评估该用户的所有开关
+func GetAccountFeatures(c *gin.Context) {
+	if _, err := reloadFeatureFlags(c.Request.Context()); err != nil {
+		RequestLog(c).Error("reload feature flags failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to load feature flags"})
+		return
+	}
+	c.JSON(200, featuresResponse(c.Param("uid")))
+}
+
+// GetFeatures GET /api/features 当前用户的开关和维护模式状态，前端据此隐藏功能或显示维护提示
+func GetFeatures(c *gin.Context) {
+	c.JSON(200, featuresResponse(c.GetString("user_id")))
+}
+
+func featuresResponse(uid string) FeaturesResponse {
+	return FeaturesResponse{Flags: features.For(uid), Maintenance: currentMaintenance()}
+}
+
+// respondFeatureFlag 不等通知，直接重新加载后返回该开关
+func respondFeatureFlag(c *gin.Context, key string) {
+	rows, err := reloadFeatureFlags(c.Request.Context())
+	if err != nil {
+		RequestLog(c).Error("reload feature flags failed", "error", err)
+		c.JSON(500, gin.H{"error": "saved, but failed to reload feature flags"})
+		return
+	}
+	for _, r := range rows {
+		if r.Key == key {
+			c.JSON(200, r)
+			return
+		}
+	}
+	c.JSON(404, gin.H{"error": "feature flag " + key + " not found"})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+// PR 预览和 push 部署共用的 GitHub webhook 处理
+
+// GitHubSignatureHeader GitHub webhook 请求体的 HMAC-SHA256 签名（sha256=<hex>）
+const GitHubSignatureHeader = "X-Hub-Signature-256"
+
+var (
+	repoPattern      = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+	branchTagInvalid = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+	errNeverDeployed = errors.New("the worker has never been deployed, deploy it once so webhook deploys can reuse its port")
+)
+
+// githubWebhookURL webhook 的公开地址（outer 的 console.<domain>）
+func githubWebhookURL(path string) string {
+	return "https://console." + k8s.Domain + "/api" + path
+}
+
+// validGitHubSignature 校验 X-Hub-Signature-256
+func validGitHubSignature(secret string, payload []byte, header string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(header))
+}
+
+// renderImageTemplate 替换镜像模板的占位符 {pr}、{sha}、{short_sha}、{branch}；
+// branch 中镜像 tag 不允许的字符换成 -
+func renderImageTemplate(template string, pr int, sha, branch string) string {
+	shortSHA := sha
+	if len(shortSHA) > 7 {
+		shortSHA = shortSHA[:7]
+	}
+	branch = strings.Trim(branchTagInvalid.ReplaceAllString(branch, "-"), "-.")
+	return strings.NewReplacer(
+		"{pr}", strconv.Itoa(pr),
+		"{sha}", sha,
+		"{short_sha}", shortSHA,
+		"{branch}", branch,
+	).Replace(template)
+}
+
+// validImageTemplate 模板用示例值渲染后是否是合法的镜像地址
+func validImageTemplate(template string) bool {
+	return isImageReference(renderImageTemplate(template, 1, strings.Repeat("0", 40), "main"))
+}
+
+// webhookBaseVersion webhook 触发的部署沿用 worker 当前版本的端口，从未部署过时返回 errNeverDeployed
+func webhookBaseVersion(ctx context.Context, w *dblayer.Worker) (*dblayer.WorkerDeployVersion, error) {
+	if w.ActiveVersionID == nil {
+		return nil, errNeverDeployed
+	}
+	v, err := dblayer.GetDeployVersion(ctx, *w.ActiveVersionID)
+	if err != nil {
+		return nil, fmt.Errorf("get active version: %w", err)
+	}
+	return v, nil
+}
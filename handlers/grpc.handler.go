@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/logging"
+	consolev1 "jabberwocky238/console/proto/console/v1"
+
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var grpcLogger = logging.Component("grpc")
+
+// GRPCOptions gRPC server 的认证配置：Token 与 mTLS 至少配置一个
+type GRPCOptions struct {
+	Token    string // 共享 token，客户端通过 authorization: Bearer <token> 传递
+	CertFile string // server 证书，配置后启用 TLS
+	KeyFile  string
+	CAFile   string // 客户端证书 CA，配置后启用 mTLS，持有合法证书的调用方无需 token
+}
+
+// GRPCServer 实现 consolev1.ConsoleInternalServer，业务逻辑与 HTTP handler 共用
+type GRPCServer struct {
+	consolev1.UnimplementedConsoleInternalServer
+}
+
+// NewGRPCServer 创建带认证的 gRPC server 并注册 ConsoleInternal 服务
+func NewGRPCServer(opts GRPCOptions) (*grpc.Server, error) {
+	mtls := opts.CAFile != ""
+	if opts.Token == "" && !mtls {
+		return nil, errors.New("grpc: either a token or a client CA is required")
+	}
+
+	var serverOpts []grpc.ServerOption
+	if opts.CertFile != "" {
+		tlsConfig, err := serverTLSConfig(opts.CertFile, opts.KeyFile, opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("grpc: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if mtls {
+		return nil, errors.New("grpc: client CA requires a server certificate")
+	}
+	serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(grpcLogInterceptor, grpcAuthInterceptor(opts.Token, mtls)))
+
+	srv := grpc.NewServer(serverOpts...)
+	consolev1.RegisterConsoleInternalServer(srv, &GRPCServer{})
+	return srv, nil
+}
+
+// grpcAuthInterceptor 放行已验证客户端证书或携带正确 token 的调用
+func grpcAuthInterceptor(token string, mtls bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if mtls && hasVerifiedClientCert(ctx) {
+			return handler(ctx, req)
+		}
+		if token != "" {
+			md, _ := metadata.FromIncomingContext(ctx)
+			for _, v := range md.Get("authorization") {
+				got, ok := strings.CutPrefix(v, "Bearer ")
+				if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+					return handler(ctx, req)
+				}
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid credentials")
+	}
+}
+
+// grpcLogInterceptor 与 HTTP 的 RequestLogger 相同：分配 request_id（沿用 x-request-id 元数据）、
+// 把 logger 放进 ctx、调用结束记一条日志
+func grpcLogInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if v := md.Get(strings.ToLower(RequestIDHeader)); len(v) > 0 && requestIDPattern.MatchString(v[0]) {
+		id = v[0]
+	} else {
+		id = newRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+
+	l := grpcLogger.With("request_id", id, "method", info.FullMethod)
+	resp, err := handler(logging.WithLogger(ctx, l), req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.NotFound, codes.InvalidArgument, codes.AlreadyExists, codes.FailedPrecondition:
+	case codes.Unauthenticated, codes.PermissionDenied:
+		level = slog.LevelWarn
+	default:
+		level = slog.LevelError
+	}
+	l.Log(ctx, level, "rpc", "code", code.String(), "latency_ms", float64(time.Since(start).Microseconds())/1000)
+	return resp, err
+}
+
+func hasVerifiedClientCert(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0
+}
+
+// GetWorker 获取 worker
+func (s *GRPCServer) GetWorker(ctx context.Context, req *consolev1.GetWorkerRequest) (*consolev1.Worker, error) {
+	if err := dblayer.AuthorizeResource(ctx, req.GetUserUid(), dblayer.ResourceWorker, req.GetWorkerId()); err == dblayer.ErrNotFound {
+		return nil, status.Error(codes.NotFound, "worker not found")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "failed to check worker ownership")
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, req.GetWorkerId(), req.GetUserUid())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "worker not found")
+	}
+
+	resp := &consolev1.Worker{
+		WorkerId:   w.WID,
+		WorkerName: w.WorkerName,
+		Status:     w.Status,
+		Url:        workerURL(w.WID, w.UserUID),
+	}
+	if w.ActiveVersionID != nil {
+		resp.ActiveVersionId = int64(*w.ActiveVersionID)
+	}
+	return resp, nil
+}
+
+// DeployWorker 触发 worker 部署
+func (s *GRPCServer) DeployWorker(ctx context.Context, req *consolev1.DeployWorkerRequest) (*consolev1.DeployWorkerResponse, error) {
+	in := DeployWorkerRequest{
+		UserUID:  req.GetUserUid(),
+		WorkerID: req.GetWorkerId(),
+		Image:    req.GetImage(),
+		Port:     int(req.GetPort()),
+	}
+	if err := binding.Validator.ValidateStruct(in); err != nil {
+		return nil, status.Error(codes.InvalidArgument, bindError(err).String())
+	}
+
+	resp, err := deployWorker(ctx, in)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &consolev1.DeployWorkerResponse{
+		WorkerId:  resp.WorkerID,
+		VersionId: int64(resp.VersionID),
+		Status:    resp.Status,
+	}, nil
+}
+
+// GetWorkerEnv 获取 worker 环境变量
+func (s *GRPCServer) GetWorkerEnv(ctx context.Context, req *consolev1.GetWorkerEnvRequest) (*consolev1.WorkerEnv, error) {
+	env, err := getWorkerEnv(ctx, req.GetWorkerId(), req.GetUserUid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &consolev1.WorkerEnv{Env: env}, nil
+}
+
+// RetrieveSecret 获取用户 secret key 与 active 资源
+func (s *GRPCServer) RetrieveSecret(ctx context.Context, req *consolev1.RetrieveSecretRequest) (*consolev1.RetrieveSecretResponse, error) {
+	if req.GetUserUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_uid is required")
+	}
+
+	resp, err := retrieveSecret(ctx, req.GetUserUid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	out := &consolev1.RetrieveSecretResponse{SecretKey: resp.SecretKey}
+	for _, r := range resp.Resources {
+		out.Resources = append(out.Resources, &consolev1.ResourceRef{
+			ResourceType: r.ResourceType,
+			ResourceId:   r.ResourceID,
+		})
+	}
+	return out, nil
+}
+
+// grpcError 把 service 层错误映射为 gRPC status
+func grpcError(err error) error {
+	if err == dblayer.ErrNotFound {
+		return status.Error(codes.NotFound, "worker not found")
+	}
+	var rejected *DeployRejectedError
+	if errors.As(err, &rejected) || err == errAccountSuspended {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
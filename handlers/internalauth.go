@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inner 内部路由的访问限制，叠加在 mTLS（ClientIdentity/AllowCallers）之上：
+// INTERNAL_ALLOWED_CIDRS 只放行来自这些网段的连接（按 TCP 对端地址，不看 X-Forwarded-For）；
+// INTERNAL_SERVICE_ACCOUNTS 要求 Authorization: Bearer 带列出的 ServiceAccount 的 token，
+// 由 K8s TokenReview 校验，持有内部 CA 客户端证书的调用方（如 worker）不需要 token。
+// 都不设置时不限制，和之前一样依赖集群网络隔离。
+
+// InternalAccessEnvs SetInternalAccessEnv 读取的环境变量
+var InternalAccessEnvs = []string{"INTERNAL_ALLOWED_CIDRS", "INTERNAL_SERVICE_ACCOUNTS", "INTERNAL_TOKEN_AUDIENCES"}
+
+var (
+	// internalCIDRs 允许访问内部路由的网段，空表示不限制
+	internalCIDRs []*net.IPNet
+	// internalServiceAccounts 允许的 ServiceAccount（namespace/name，name 可以是 *），空表示不要求 token
+	internalServiceAccounts []string
+	// internalTokenAudiences token 必须签发给其中之一，空时用 API server 的默认 audience
+	internalTokenAudiences []string
+)
+
+// SetInternalAccessEnv 应用 InternalAccessEnvs 之一，都是逗号分隔的列表
+func SetInternalAccessEnv(env, value string) error {
+	var items []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			items = append(items, v)
+		}
+	}
+	switch env {
+	case "INTERNAL_ALLOWED_CIDRS":
+		cidrs, err := parseCIDRs(items)
+		if err != nil {
+			return fmt.Errorf("INTERNAL_ALLOWED_CIDRS: %w", err)
+		}
+		internalCIDRs = cidrs
+	case "INTERNAL_SERVICE_ACCOUNTS":
+		for _, sa := range items {
+			ns, name, ok := strings.Cut(sa, "/")
+			if !ok || ns == "" || name == "" {
+				return fmt.Errorf("INTERNAL_SERVICE_ACCOUNTS: %q must be namespace/name", sa)
+			}
+		}
+		internalServiceAccounts = items
+	case "INTERNAL_TOKEN_AUDIENCES":
+		internalTokenAudiences = items
+	}
+	return nil
+}
+
+// parseCIDRs 解析网段，单个 IP 视为 /32 或 /128
+func parseCIDRs(items []string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, n)
+	}
+	return cidrs, nil
+}
+
+// InternalAllowlist 只放行来自 INTERNAL_ALLOWED_CIDRS 的连接，其他 403
+func InternalAllowlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(internalCIDRs) == 0 {
+			c.Next()
+			return
+		}
+		ip := net.ParseIP(c.RemoteIP())
+		if ip != nil && slices.ContainsFunc(internalCIDRs, func(n *net.IPNet) bool { return n.Contains(ip) }) {
+			c.Next()
+			return
+		}
+		RequestLog(c).Warn("internal request from a disallowed address", "remote_ip", c.RemoteIP(), "path", c.Request.URL.Path)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "address not allowed"})
+	}
+}
+
+const serviceAccountKey = "service_account"
+
+// tokenReviewTTL TokenReview 结果的缓存时间；被拒绝的 token 缓存得短一些
+const (
+	tokenReviewTTL         = time.Minute
+	tokenReviewRejectedTTL = 10 * time.Second
+	tokenReviewCacheSize   = 1024
+)
+
+type tokenReviewResult struct {
+	serviceAccount string
+	err            error
+	expires        time.Time
+}
+
+// tokenReviews 按 token 的 sha256 缓存 TokenReview 结果，避免每个请求都打 API server
+var tokenReviews = struct {
+	mu      sync.Mutex
+	results map[[32]byte]tokenReviewResult
+}{results: map[[32]byte]tokenReviewResult{}}
+
+// reviewToken 校验 token（带缓存），返回 namespace/name
+func reviewToken(ctx context.Context, token string) (string, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	tokenReviews.mu.Lock()
+	r, ok := tokenReviews.results[key]
+	tokenReviews.mu.Unlock()
+	if ok && now.Before(r.expires) {
+		return r.serviceAccount, r.err
+	}
+
+	sa, err := k8s.ReviewServiceAccountToken(ctx, token, internalTokenAudiences)
+	r = tokenReviewResult{serviceAccount: sa, err: err, expires: now.Add(tokenReviewTTL)}
+	if err != nil {
+		r.expires = now.Add(tokenReviewRejectedTTL)
+	}
+	tokenReviews.mu.Lock()
+	defer tokenReviews.mu.Unlock()
+	if len(tokenReviews.results) >= tokenReviewCacheSize {
+		for k, v := range tokenReviews.results {
+			if now.After(v.expires) {
+				delete(tokenReviews.results, k)
+			}
+		}
+		if len(tokenReviews.results) >= tokenReviewCacheSize {
+			clear(tokenReviews.results)
+		}
+	}
+	tokenReviews.results[key] = r
+	return sa, err
+}
+
+// serviceAccountAllowed sa（namespace/name）是否在 INTERNAL_SERVICE_ACCOUNTS 里
+func serviceAccountAllowed(sa string) bool {
+	ns, _, _ := strings.Cut(sa, "/")
+	return slices.Contains(internalServiceAccounts, sa) || slices.Contains(internalServiceAccounts, ns+"/*")
+}
+
+// internalCaller 调用方的客户端证书身份或 ServiceAccount，都没有时返回 ""
+func internalCaller(c *gin.Context) string {
+	if v, ok := c.Get(clientIdentityKey); ok {
+		return v.(k8s.Identity).String()
+	}
+	return c.GetString(serviceAccountKey)
+}
+
+// ServiceAccountAuth 设置了 INTERNAL_SERVICE_ACCOUNTS 时，没有客户端证书身份的请求
+// 必须带允许的 ServiceAccount token，否则 401/403。放在 ClientIdentity 之后
+func ServiceAccountAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(internalServiceAccounts) == 0 {
+			c.Next()
+			return
+		}
+		if _, ok := c.Get(clientIdentityKey); ok {
+			c.Next()
+			return
+		}
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "a service account token is required"})
+			return
+		}
+		sa, err := reviewToken(c.Request.Context(), token)
+		if err != nil {
+			RequestLog(c).Warn("service account token rejected", "path", c.Request.URL.Path, "error", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid service account token"})
+			return
+		}
+		if !serviceAccountAllowed(sa) {
+			RequestLog(c).Warn("service account not allowed", "service_account", sa, "path", c.Request.URL.Path)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "service account " + sa + " is not allowed"})
+			return
+		}
+		c.Set(serviceAccountKey, sa)
+		c.Next()
+	}
+}
+
+// SetInnerServiceAccountToken (outer) 调用 inner 时带上该文件里的 ServiceAccount token，
+// 每次请求重新读取，kubelet 轮换后自动生效
+func SetInnerServiceAccountToken(tokenFile string) error {
+	if _, err := os.ReadFile(tokenFile); err != nil {
+		return fmt.Errorf("read service account token: %w", err)
+	}
+	base := innerClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	innerClient = &http.Client{Transport: &tokenTransport{file: tokenFile, base: base}, Timeout: innerClient.Timeout}
+	return nil
+}
+
+// tokenTransport 给请求加上 Authorization: Bearer <文件内容>
+type tokenTransport struct {
+	file string
+	base http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := os.ReadFile(t.file)
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	return t.base.RoundTrip(req)
+}
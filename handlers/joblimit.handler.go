@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 租户任务并发上限的管理接口，上限在 inner 的 Processor 里生效（见 jobs.EnableJobLimits）
+
+// reloadJobLimits 从 tenant_job_limits 重新加载本实例的上限，返回全部上限
+func reloadJobLimits(ctx context.Context) ([]*dblayer.TenantJobLimit, error) {
+	limits, err := dblayer.ListTenantJobLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs.LoadJobLimits(limits)
+	return limits, nil
+}
+
+// GetAccountJobLimits GET /admin/accounts/:uid/job-limits 用户的任务并发上限和本实例上正在执行的任务数（顺带重新加载本实例）
+func GetAccountJobLimits(c *gin.Context) {
+	uid := c.Param("uid")
+	limits, err := reloadJobLimits(c.Request.Context())
+	if err != nil {
+		RequestLog(c).Error("list job limits failed", "user_id", uid, "error", err)
+		c.JSON(500, gin.H{"error": "failed to list job limits"})
+		return
+	}
+	resp := AccountJobLimitsResponse{Limits: []*dblayer.TenantJobLimit{}, Running: jobs.RunningJobs(uid)}
+	for _, l := range limits {
+		if l.UserUID == uid {
+			resp.Limits = append(resp.Limits, l)
+		}
+	}
+	c.JSON(200, resp)
+}
+
+// SetAccountJobLimit PUT /admin/accounts/:uid/job-limits/:type 设置用户某类任务同时执行的上限，type 为 * 时限制全部任务
+func SetAccountJobLimit(c *gin.Context) {
+	uid, typ := c.Param("uid"), c.Param("type")
+	var req SetJobLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if typ != dblayer.JobLimitAll && !jobs.IsTenantJobType(k8s.JobType(typ)) {
+		c.JSON(400, gin.H{"error": "unknown job type " + typ})
+		return
+	}
+	l := &dblayer.TenantJobLimit{UserUID: uid, JobType: typ, MaxRunning: req.MaxRunning, UpdatedBy: internalCaller(c)}
+	if err := dblayer.SetTenantJobLimit(c.Request.Context(), l); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "user not found"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("set job limit failed", "user_id", uid, "job_type", typ, "error", err)
+		c.JSON(500, gin.H{"error": "failed to set job limit"})
+		return
+	}
+	RequestLog(c).Info("job limit changed", "user_id", uid, "job_type", typ, "max_running", req.MaxRunning)
+	if _, err := reloadJobLimits(c.Request.Context()); err != nil {
+		RequestLog(c).Warn("reload job limits failed", "error", err)
+	}
+	c.JSON(200, l)
+}
+
+// DeleteAccountJobLimit DELETE /admin/accounts/:uid/job-limits/:type 取消上限，没有时返回 404
+func DeleteAccountJobLimit(c *gin.Context) {
+	uid, typ := c.Param("uid"), c.Param("type")
+	if err := dblayer.DeleteTenantJobLimit(c.Request.Context(), uid, typ); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "job limit not found"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("delete job limit failed", "user_id", uid, "job_type", typ, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete job limit"})
+		return
+	}
+	RequestLog(c).Info("job limit removed", "user_id", uid, "job_type", typ)
+	if _, err := reloadJobLimits(c.Request.Context()); err != nil {
+		RequestLog(c).Warn("reload job limits failed", "error", err)
+	}
+	c.JSON(200, MessageResponse{Message: "job limit deleted"})
+}
@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"jabberwocky238/console/dblayer"
@@ -16,51 +19,51 @@ import (
 
 // Health handles health check endpoint
 func HealthInner(c *gin.Context) {
-	status := gin.H{
-		"status":    "ok",
-		"timestamp": time.Now().Unix(),
+	status := HealthResponse{
+		Status:    "ok",
+		Timestamp: time.Now().Unix(),
 	}
 
 	// Check database connection
 	if dblayer.DB != nil {
 		if err := dblayer.DB.Ping(); err != nil {
-			status["database"] = "unhealthy"
-			status["database_error"] = err.Error()
+			status.Database = "unhealthy"
+			status.DatabaseError = err.Error()
 			c.JSON(503, status)
 			return
 		}
-		status["database"] = "healthy"
+		status.Database = "healthy"
 	} else {
-		status["database"] = "not_initialized"
+		status.Database = "not_initialized"
 	}
 
 	// Check K8s client
 	if k8s.K8sClient != nil {
-		status["kubernetes"] = "healthy"
+		status.Kubernetes = "healthy"
 	} else {
-		status["kubernetes"] = "not_initialized"
+		status.Kubernetes = "not_initialized"
 	}
 
 	c.JSON(200, status)
 }
 
 func HealthOuter(c *gin.Context) {
-	status := gin.H{
-		"status":    "ok",
-		"timestamp": time.Now().Unix(),
+	status := HealthResponse{
+		Status:    "ok",
+		Timestamp: time.Now().Unix(),
 	}
 
 	// Check database connection
 	if dblayer.DB != nil {
 		if err := dblayer.DB.Ping(); err != nil {
-			status["database"] = "unhealthy"
-			status["database_error"] = err.Error()
+			status.Database = "unhealthy"
+			status.DatabaseError = err.Error()
 			c.JSON(503, status)
 			return
 		}
-		status["database"] = "healthy"
+		status.Database = "healthy"
 	} else {
-		status["database"] = "not_initialized"
+		status.Database = "not_initialized"
 	}
 
 	c.JSON(200, status)
@@ -87,7 +90,7 @@ func NewTaskHandler(proc *k8s.Processor, cron *k8s.CronScheduler) *JobsHandler {
 func (h *JobsHandler) AcceptTask(c *gin.Context) {
 	var req AcceptTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, bindError(err))
 		return
 	}
 
@@ -104,30 +107,71 @@ func (h *JobsHandler) AcceptTask(c *gin.Context) {
 		return
 	}
 
-	// 提交到 processor
-	h.processor.Submit(job)
+	// 提交到 processor；停机中拒绝，outer 收到非 200 会报错而不是丢任务
+	if err := h.processor.Submit(job); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	RequestLog(c).Info("task accepted", "job_type", job.Type(), "job_id", job.ID())
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":     "task accepted",
-		"task_type":   req.TaskType,
-		"timestamp":   req.Timestamp,
-		"received_at": time.Now().Unix(),
+	c.JSON(http.StatusOK, AcceptTaskResponse{
+		Message:    "task accepted",
+		TaskType:   req.TaskType,
+		Timestamp:  req.Timestamp,
+		ReceivedAt: time.Now().Unix(),
 	})
 }
 
+// DriftReport GET /admin/drift 最近一次库与集群资源的比对结果，第一次比对完成前返回 503
+func DriftReport(c *gin.Context) {
+	report := jobs.LatestDriftReport()
+	if report == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "drift audit has not run yet"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ConsistencyReport 最近一次数据一致性检查的结果
+func ConsistencyReport(c *gin.Context) {
+	report := jobs.LatestConsistencyReport()
+	if report == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "consistency check has not run yet"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RepairConsistency 修复可以自动修复的问题后重新检查，返回修复报告
+func RepairConsistency(c *gin.Context) {
+	report, err := jobs.RunConsistencyCheck(c.Request.Context(), true)
+	if err != nil {
+		RequestLog(c).Error("consistency repair failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "consistency repair failed"})
+		return
+	}
+	RequestLog(c).Info("consistency repaired", "items", len(report.Items), "validated", report.Validated, "by", internalCaller(c))
+	c.JSON(http.StatusOK, report)
+}
+
+// sendTaskTimeout SendTask 等待 inner 接收任务的上限
+const sendTaskTimeout = 10 * time.Second
+
 // SendTask sends a task to the inner control plane endpoint
 // Uses Kubernetes internal service: control-plane-inner.console.svc.cluster.local
 func SendTask(job k8s.Job) error {
-	endpoint := fmt.Sprintf("%s/api/acceptTask", k8s.ControlPlaneInnerEndpoint)
-
-	var jobData []byte
-	var err error
-	jobData, err = json.Marshal(job)
+	jobData, err := json.Marshal(job)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job: %w", err)
 	}
+	return sendTaskData(k8s.JobType(job.Type()), jobData)
+}
+
+// sendTaskData 把序列化好的任务签名后提交给 inner
+func sendTaskData(taskType k8s.JobType, jobData []byte) error {
+	endpoint := fmt.Sprintf("%s%s/acceptTask", k8s.ControlPlaneInnerEndpoint, APIPrefixV1)
 	req := AcceptTaskRequest{
-		TaskType:  k8s.JobType(job.Type()),
+		TaskType:  taskType,
 		Timestamp: time.Now().Unix(),
 		Data:      jobData,
 	}
@@ -136,15 +180,69 @@ func SendTask(job k8s.Job) error {
 		return fmt.Errorf("failed to marshal task: %w", err)
 	}
 
-	resp, err := http.Post(endpoint, "application/json", bytes.NewBuffer(jsonData))
+	// inner 卡住时不能把调用方的请求一起拖住
+	ctx, cancel := context.WithTimeout(context.Background(), sendTaskTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to build task request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	signTaskRequest(httpReq, jsonData)
+	resp, err := innerClient.Do(httpReq)
 	if err != nil {
 		return fmt.Errorf("failed to send task: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		var body ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		if body.Error != "" {
+			return fmt.Errorf("task rejected with status %d: %s", resp.StatusCode, body.Error)
+		}
 		return fmt.Errorf("task rejected with status: %d", resp.StatusCode)
 	}
 
 	return nil
 }
+
+// getInner (outer) 以 GET 调用 inner 的只读接口并解析 JSON 响应，5 秒超时；
+// 非 200 时返回 inner 给出的错误
+func getInner[T any](ctx context.Context, path string, q url.Values) (*T, error) {
+	return getInnerTimeout[T](ctx, path, q, 5*time.Second)
+}
+
+// getInnerTimeout 同 getInner，用于 inner 需要更长时间的接口
+func getInnerTimeout[T any](ctx context.Context, path string, q url.Values, timeout time.Duration) (*T, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	endpoint := k8s.ControlPlaneInnerEndpoint + APIPrefixV1 + path
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := innerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return nil, errors.New(body.Error)
+	}
+	var out T
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
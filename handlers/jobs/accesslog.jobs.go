@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+const (
+	// AccessLogRetention 请求明细的保留期
+	AccessLogRetention = 7 * 24 * time.Hour
+	// AccessLogHourlyRetention 每小时请求汇总的保留期
+	AccessLogHourlyRetention = 90 * 24 * time.Hour
+)
+
+// accessLogPruneJob 清理超过保留期的请求明细和小时汇总
+type accessLogPruneJob struct{}
+
+func NewAccessLogPruneJob() k8s.Job {
+	return &accessLogPruneJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeAccessLogPrune, NewAccessLogPruneJob)
+}
+
+func (j *accessLogPruneJob) Type() k8s.JobType { return JobTypeAccessLogPrune }
+func (j *accessLogPruneJob) ID() string        { return "periodic" }
+
+func (j *accessLogPruneJob) Do(ctx context.Context) error {
+	now := time.Now().UTC()
+	n, err := dblayer.PruneWorkerRequests(ctx, now.Add(-AccessLogRetention), now.Add(-AccessLogHourlyRetention))
+	if err != nil {
+		return err
+	}
+	jobLog(j).Info("pruned worker requests", "requests", n)
+	return nil
+}
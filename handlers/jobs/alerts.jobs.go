@@ -0,0 +1,181 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/notify"
+)
+
+var alertLog = jobsLog.With("job_type", JobTypeAlertEvaluate)
+
+// alertEvalJob 定期评估所有启用的告警规则，状态变化（firing / resolved）时记录历史并通知
+type alertEvalJob struct{}
+
+func NewAlertEvalJob() k8s.Job {
+	return &alertEvalJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeAlertEvaluate, NewAlertEvalJob)
+}
+
+func (j *alertEvalJob) Type() k8s.JobType { return JobTypeAlertEvaluate }
+func (j *alertEvalJob) ID() string        { return "periodic" }
+
+func (j *alertEvalJob) Do(ctx context.Context) error {
+	rules, err := dblayer.ListEnabledAlertRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 一轮评估要在下一次 tick 之前结束
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Second)
+	defer cancel()
+
+	changed := 0
+	for _, r := range rules {
+		value, ok, err := measureAlert(ctx, r)
+		if err != nil {
+			alertLog.Warn("measure failed", "rule_id", r.ID, "metric", r.Metric, "error", err)
+			continue
+		}
+		if !ok {
+			continue // 没有数据（例如窗口内没有请求），保持当前状态
+		}
+		firing := value > r.Threshold
+		switch {
+		case firing && r.Status != dblayer.AlertStatusFiring:
+			if transitionAlert(ctx, r, dblayer.AlertStatusOK, dblayer.AlertStatusFiring, value) {
+				changed++
+			}
+		case !firing && r.Status == dblayer.AlertStatusFiring:
+			if transitionAlert(ctx, r, dblayer.AlertStatusFiring, dblayer.AlertStatusOK, value) {
+				changed++
+			}
+		}
+	}
+
+	alertLog.Debug("evaluated alert rules", "rules", len(rules), "changed", changed)
+	return nil
+}
+
+// measureAlert 取规则指标的当前值，ok=false 表示没有数据
+func measureAlert(ctx context.Context, r *dblayer.AlertRule) (float64, bool, error) {
+	switch r.Metric {
+	case dblayer.AlertMetricRestarts, dblayer.AlertMetricOOMKills, dblayer.AlertMetricCrashLoop:
+		s, err := controller.WorkerPodSignals(r.WorkerID, r.UserUID, r.Window())
+		if err != nil {
+			return 0, false, err
+		}
+		switch r.Metric {
+		case dblayer.AlertMetricRestarts:
+			return float64(s.Restarts), true, nil
+		case dblayer.AlertMetricOOMKills:
+			return float64(s.OOMKills), true, nil
+		default:
+			return float64(s.CrashLooping), true, nil
+		}
+
+	case dblayer.AlertMetric5xxRate:
+		sel := k8s.WorkerTraefikService(controller.WorkerName(r.WorkerID, r.UserUID))
+		// 没有 5xx 时分子为空，用 vector(0) 补齐；没有请求时分母为空，整体无数据
+		q := fmt.Sprintf(`100 * (sum(rate(traefik_service_requests_total{%s,code=~"5.."}[%ds])) or vector(0))`+
+			` / sum(rate(traefik_service_requests_total{%s}[%ds]))`, sel, r.WindowSeconds, sel, r.WindowSeconds)
+		return k8s.QueryPromScalar(ctx, q)
+
+	case dblayer.AlertMetricP95Latency:
+		sel := k8s.WorkerTraefikService(controller.WorkerName(r.WorkerID, r.UserUID))
+		q := fmt.Sprintf(`histogram_quantile(0.95, sum by (le) (rate(traefik_service_request_duration_seconds_bucket{%s}[%ds])))`,
+			sel, r.WindowSeconds)
+		return k8s.QueryPromScalar(ctx, q)
+	}
+	return 0, false, fmt.Errorf("unknown metric %q", r.Metric)
+}
+
+// transitionAlert 切换规则状态；切换成功的实例负责记录历史、推送事件和发通知
+func transitionAlert(ctx context.Context, r *dblayer.AlertRule, from, to string, value float64) bool {
+	ok, err := dblayer.TransitionAlertRule(ctx, r.ID, from, to)
+	if err != nil {
+		alertLog.Error("update alert status failed", "rule_id", r.ID, "error", err)
+		return false
+	}
+	if !ok {
+		return false // 规则已被其他实例切换、停用或删除
+	}
+
+	ev := &dblayer.AlertEvent{
+		RuleID:    &r.ID,
+		UserUID:   r.UserUID,
+		WorkerID:  r.WorkerID,
+		RuleName:  r.Name,
+		Metric:    r.Metric,
+		Status:    dblayer.AlertStatusFiring,
+		Value:     value,
+		Threshold: r.Threshold,
+	}
+	if to == dblayer.AlertStatusFiring {
+		ev.Message = fmt.Sprintf("%s: %s, above %g over the last %s", r.Name, describeAlertValue(r.Metric, value), r.Threshold, r.Window())
+	} else {
+		ev.Status = dblayer.AlertStatusResolved
+		ev.Message = fmt.Sprintf("%s resolved: %s", r.Name, describeAlertValue(r.Metric, value))
+	}
+	if err := dblayer.CreateAlertEvent(ctx, ev); err != nil {
+		alertLog.Error("save alert event failed", "rule_id", r.ID, "error", err)
+	}
+	alertLog.Info("alert "+ev.Status, "rule_id", r.ID, "user_id", r.UserUID, "worker_id", r.WorkerID, "metric", r.Metric, "value", value)
+
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      r.UserUID,
+		Kind:         dblayer.EventWorkerAlert,
+		ResourceType: "worker",
+		ResourceID:   r.WorkerID,
+		Status:       ev.Status,
+		Message:      ev.Message,
+	})
+
+	n := &dblayer.Notification{
+		UserUID:      r.UserUID,
+		Kind:         dblayer.NotificationAlert,
+		Severity:     dblayer.NotificationSeverityWarning,
+		Title:        fmt.Sprintf("[%s] %s on worker %s", strings.ToUpper(ev.Status), r.Name, r.WorkerID),
+		Message:      ev.Message,
+		ResourceType: "worker",
+		ResourceID:   r.WorkerID,
+	}
+	if ev.Status == dblayer.AlertStatusResolved {
+		n.Severity = dblayer.NotificationSeverityInfo
+	}
+	ch := notify.Channels{}
+	if r.NotifyEmail {
+		ch.Email = &notify.Mail{Template: notify.MailAlert, Data: notify.AlertData{
+			Status: ev.Status, Rule: r.Name, WorkerID: r.WorkerID, Message: ev.Message,
+		}}
+	}
+	if r.WebhookURL != "" {
+		ch.Webhook = &notify.Webhook{URL: r.WebhookURL, Secret: r.WebhookSecret, Body: ev}
+	}
+	notify.Send(ctx, n, ch)
+	return true
+}
+
+func describeAlertValue(metric string, v float64) string {
+	switch metric {
+	case dblayer.AlertMetricRestarts:
+		return fmt.Sprintf("%.0f restarts", v)
+	case dblayer.AlertMetricOOMKills:
+		return fmt.Sprintf("%.0f OOM kills", v)
+	case dblayer.AlertMetricCrashLoop:
+		return fmt.Sprintf("%.0f containers in CrashLoopBackOff", v)
+	case dblayer.AlertMetric5xxRate:
+		return fmt.Sprintf("%.1f%% of responses were 5xx", v)
+	case dblayer.AlertMetricP95Latency:
+		return fmt.Sprintf("p95 latency %.3fs", v)
+	}
+	return fmt.Sprintf("%s = %g", metric, v)
+}
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// 挂载到 worker 的托管资源：连接串（controller.AttachmentEnv）以挂载的 env 名写进 worker 的 Secret，
+// 挂载、取消挂载、资源删除后同步，inner 启动时全部刷新一次（连接串随 mTLS、COMBINATOR_APPS 变化）。
+// controller 创建和 reconcile worker 的 Secret 时也会写入，首次部署前的挂载靠它生效
+
+// RefreshAttachments 为所有有挂载的 worker 提交同步任务，只有连接串变了的 worker 会更新 Secret
+func RefreshAttachments(ctx context.Context, proc *k8s.Processor) {
+	owners, err := dblayer.ListAttachmentOwners(ctx)
+	if err != nil {
+		jobsLog.Error("list attachments failed", "error", err)
+		return
+	}
+	for workerID, userUID := range owners {
+		if err := proc.Submit(NewSyncAttachmentsJob(workerID, userUID)); err != nil {
+			jobsLog.Warn("submit attachment sync failed", "worker_id", workerID, "error", err)
+		}
+	}
+}
+
+type syncAttachmentsJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func NewSyncAttachmentsJob(workerID, userUID string) *syncAttachmentsJob {
+	return &syncAttachmentsJob{
+		WorkerID: workerID,
+		UserUID:  userUID,
+	}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerSyncAttach, func() k8s.Job {
+		return &syncAttachmentsJob{}
+	})
+}
+
+func (j *syncAttachmentsJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *syncAttachmentsJob) Type() k8s.JobType {
+	return JobTypeWorkerSyncAttach
+}
+
+func (j *syncAttachmentsJob) ID() string {
+	return j.WorkerID
+}
+
+func (j *syncAttachmentsJob) Do(ctx context.Context) error {
+	if k8s.K8sClient == nil {
+		return nil
+	}
+	cluster, err := controller.ClusterOf(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return nil // 已删除，或所在集群已注销
+	}
+	env, err := controller.AttachmentEnv(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return err
+	}
+	secretName := controller.WorkerName(j.WorkerID, j.UserUID) + "-secret"
+	changed, err := applyAttachments(ctx, cluster.Kube.CoreV1().Secrets(k8s.TenantNamespace(j.UserUID)), secretName, env)
+	if err != nil {
+		dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "error")
+		return fmt.Errorf("sync attachments: %w", err)
+	}
+	if !changed {
+		return nil
+	}
+	if err := restartRemoteWorker(ctx, cluster, j.WorkerID, j.UserUID); err != nil {
+		return err
+	}
+	jobLog(j).Info("attachments synced", "env", len(env))
+	return nil
+}
+
+// syncResourceAttachments 资源的连接信息变化后（创建完成、凭据轮换）同步挂载了它的 worker
+func syncResourceAttachments(ctx context.Context, userUID, resourceType, resourceID string) error {
+	workers, err := dblayer.ListAttachedWorkers(ctx, userUID, resourceType, resourceID)
+	if err != nil {
+		return fmt.Errorf("list attached workers: %w", err)
+	}
+	for _, workerID := range workers {
+		if err := NewSyncAttachmentsJob(workerID, userUID).Do(ctx); err != nil {
+			return fmt.Errorf("sync worker %s: %w", workerID, err)
+		}
+	}
+	return nil
+}
+
+// applyAttachments 把连接串写进 worker 的 Secret；没有变化时不更新，避免多余的重启
+func applyAttachments(ctx context.Context, client typedcorev1.SecretInterface, name string, env map[string]string) (bool, error) {
+	sec, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil // 还没部署，controller 创建 Secret 时会写入
+	}
+	if err != nil {
+		return false, err
+	}
+	if !controller.ApplyAttachmentEnv(sec, env) {
+		return false, nil
+	}
+	_, err = client.Update(ctx, sec, metav1.UpdateOptions{})
+	return err == nil, err
+}
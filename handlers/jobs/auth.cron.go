@@ -3,7 +3,6 @@ package jobs
 import (
 	"context"
 	"fmt"
-	"log"
 
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
@@ -15,6 +14,8 @@ import (
 
 const userPageSize = 1000
 
+var auditLog = jobsLog.With("job_type", JobTypeAuthUserAudit)
+
 // UserAuditJob 定期审计：检查用户初始化状态 + 清理孤儿服务
 type userAuditJob struct{}
 
@@ -31,20 +32,18 @@ func init() {
 func (j *userAuditJob) Type() k8s.JobType { return JobTypeAuthUserAudit }
 func (j *userAuditJob) ID() string        { return "periodic" }
 
-func (j *userAuditJob) Do() error {
+func (j *userAuditJob) Do(ctx context.Context) error {
 	if k8s.K8sClient == nil || k8s.DynamicClient == nil {
-		log.Println("[audit] k8s client not initialized, skip")
+		auditLog.Warn("k8s client not initialized, skip")
 		return nil
 	}
 
 	// 1. 分页扫描所有用户 UID，构建 set
-	userSet, err := loadAllUserUIDs()
+	userSet, err := loadAllUserUIDs(ctx)
 	if err != nil {
 		return err
 	}
-	log.Printf("[audit] loaded %d users from database", len(userSet))
-
-	ctx := context.Background()
+	auditLog.Info("loaded users from database", "users", len(userSet))
 
 	workerCRs, err := k8s.DynamicClient.Resource(controller.WorkerAppGVR).
 		Namespace(k8s.WorkerNamespace).
@@ -52,14 +51,14 @@ func (j *userAuditJob) Do() error {
 	if err != nil {
 		return fmt.Errorf("list worker CRs: %w", err)
 	}
-	log.Printf("[audit] found %d worker CRs", len(workerCRs.Items))
+	auditLog.Info("found worker CRs", "worker_crs", len(workerCRs.Items))
 
 	// 3. 一次性拉取所有 db_ 数据库列表
 	var existingDBs []string
 	if k8s.RDBManager != nil {
 		existingDBs, err = k8s.RDBManager.RootListUserDatabases()
 		if err != nil {
-			log.Printf("[audit] list cockroachdb databases failed: %v", err)
+			auditLog.Error("list cockroachdb databases failed", "error", err)
 			existingDBs = nil
 		}
 	}
@@ -70,20 +69,23 @@ func (j *userAuditJob) Do() error {
 	}
 
 	// 5. 清理孤儿 CR（删 CR → controller onDelete 级联清理子资源）
-	cleanOrphanWorkers(userSet, workerCRs)
+	cleanOrphanWorkers(ctx, userSet, workerCRs)
 	if existingDBs != nil {
 		cleanOrphanRDBs(userSet, existingDBs)
 	}
+	if k8s.QueueBroker != nil {
+		cleanOrphanQueueAccounts(ctx, userSet)
+	}
 
-	log.Println("[audit] user audit completed")
+	auditLog.Info("user audit completed")
 	return nil
 }
 
 // loadAllUserUIDs 分页加载所有用户 UID，返回 set
-func loadAllUserUIDs() (map[string]struct{}, error) {
+func loadAllUserUIDs(ctx context.Context) (map[string]struct{}, error) {
 	userSet := make(map[string]struct{})
 	for offset := 0; ; offset += userPageSize {
-		uids, err := dblayer.ListUserUIDsPaged(userPageSize, offset)
+		uids, err := dblayer.ListUserUIDsPaged(ctx, userPageSize, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -98,7 +100,7 @@ func loadAllUserUIDs() (map[string]struct{}, error) {
 }
 
 // cleanOrphanWorkers 删除 owner 不存在的 WorkerApp CR
-func cleanOrphanWorkers(userSet map[string]struct{}, crList *unstructured.UnstructuredList) {
+func cleanOrphanWorkers(ctx context.Context, userSet map[string]struct{}, crList *unstructured.UnstructuredList) {
 	for _, item := range crList.Items {
 		spec, _ := item.Object["spec"].(map[string]interface{})
 		if spec == nil {
@@ -112,9 +114,9 @@ func cleanOrphanWorkers(userSet map[string]struct{}, crList *unstructured.Unstru
 			continue
 		}
 		name := item.GetName()
-		log.Printf("[audit] orphan worker CR %s (owner %s), deleting", name, ownerID)
-		if err := controller.DeleteWorkerAppCR(k8s.DynamicClient, name); err != nil {
-			log.Printf("[audit] delete worker CR %s failed: %v", name, err)
+		auditLog.Warn("orphan worker CR, deleting", "name", name, "owner", ownerID)
+		if err := controller.DeleteWorkerAppCR(ctx, k8s.DynamicClient, name); err != nil {
+			auditLog.Error("delete worker CR failed", "name", name, "error", err)
 		}
 	}
 }
@@ -130,9 +132,9 @@ func checkUserRDBInitialization(userSet map[string]struct{}, existingDBs []strin
 		if _, ok := dbSet[k8s.RDBManager.DatabaseName(uid)]; ok {
 			continue
 		}
-		log.Printf("[audit] user %s missing RDB, initializing", uid)
+		auditLog.Warn("user missing RDB, initializing", "user_id", uid)
 		if err := k8s.RDBManager.InitUserRDB(uid); err != nil {
-			log.Printf("[audit] init RDB for %s failed: %v", uid, err)
+			auditLog.Error("init RDB failed", "user_id", uid, "error", err)
 		}
 	}
 }
@@ -149,10 +151,28 @@ func cleanOrphanRDBs(userSet map[string]struct{}, existingDBs []string) {
 		if _, ok := validDBs[dbName]; ok {
 			continue
 		}
-		log.Printf("[audit] orphan database %s, dropping", dbName)
+		auditLog.Warn("orphan database, dropping", "database", dbName)
 		// 反解 uid 不可靠，直接用 admin 连接 DROP
 		if err := k8s.RDBManager.DropDatabase(dbName); err != nil {
-			log.Printf("[audit] drop database %s failed: %v", dbName, err)
+			auditLog.Error("drop database failed", "database", dbName, "error", err)
+		}
+	}
+}
+
+// cleanOrphanQueueAccounts 删除 owner 不存在的队列 vhost、broker 用户和密码 Secret
+func cleanOrphanQueueAccounts(ctx context.Context, userSet map[string]struct{}) {
+	owners, err := k8s.QueueBroker.ListAccountOwners(ctx)
+	if err != nil {
+		auditLog.Error("list queue vhosts failed", "error", err)
+		return
+	}
+	for _, owner := range owners {
+		if _, ok := userSet[owner]; ok {
+			continue
+		}
+		auditLog.Warn("orphan queue vhost, deleting", "owner", owner)
+		if err := k8s.QueueBroker.DeleteAccount(ctx, owner); err != nil {
+			auditLog.Error("delete queue vhost failed", "owner", owner, "error", err)
 		}
 	}
 }
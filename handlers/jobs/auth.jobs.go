@@ -1,9 +1,13 @@
 package jobs
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
 
 	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/notify"
 )
 
 // --- Auth Job types (implement k8s.Job) ---
@@ -16,12 +20,19 @@ func init() {
 	RegisterJobType(JobTypeAuthRegisterUser, func() k8s.Job {
 		return &registerUserJob{}
 	})
+	RegisterJobType(JobTypeAuthSecurityNotice, func() k8s.Job {
+		return &securityNoticeJob{}
+	})
 }
 
 func NewRegisterUserJob(userUID string) *registerUserJob {
 	return &registerUserJob{UserUID: userUID}
 }
 
+func (j *registerUserJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *registerUserJob) Type() k8s.JobType {
 	return JobTypeAuthRegisterUser
 }
@@ -30,15 +41,69 @@ func (j *registerUserJob) ID() string {
 	return j.UserUID
 }
 
-func (j *registerUserJob) Do() error {
+func (j *registerUserJob) Do(ctx context.Context) error {
 	if k8s.RDBManager != nil {
 		if err := k8s.RDBManager.InitUserRDB(j.UserUID); err != nil {
-			log.Printf("Warning: Failed to init RDB for user %s: %v", j.UserUID, err)
+			jobLog(j).Warn("init RDB failed", "error", err)
 		} else {
-			log.Printf("RDB initialized for user %s", j.UserUID)
+			jobLog(j).Info("RDB initialized")
 		}
 	} else {
-		log.Printf("Warning: RDBManager not initialized, skip RDB init for user %s", j.UserUID)
+		jobLog(j).Warn("RDBManager not initialized, skip RDB init")
+	}
+	return nil
+}
+
+// 安全通知的类型
+const (
+	SecurityNoticeNewDevice    = "new_device"
+	SecurityNoticeFailedLogins = "failed_logins"
+)
+
+// securityNoticeJob 给用户发一封账号安全通知邮件（新设备登录、连续登录失败）
+type securityNoticeJob struct {
+	UserUID   string    `json:"user_uid"`
+	EventID   int       `json:"event_id"`
+	Kind      string    `json:"kind"`
+	IP        string    `json:"ip"`
+	Country   string    `json:"country"`
+	UserAgent string    `json:"user_agent"`
+	At        time.Time `json:"at"`
+}
+
+func NewSecurityNoticeJob(userUID string, eventID int, kind, ip, country, userAgent string, at time.Time) k8s.Job {
+	return &securityNoticeJob{
+		UserUID:   userUID,
+		EventID:   eventID,
+		Kind:      kind,
+		IP:        ip,
+		Country:   country,
+		UserAgent: userAgent,
+		At:        at,
+	}
+}
+
+func (j *securityNoticeJob) OwnerUID() string  { return j.UserUID }
+func (j *securityNoticeJob) Type() k8s.JobType { return JobTypeAuthSecurityNotice }
+func (j *securityNoticeJob) ID() string        { return strconv.Itoa(j.EventID) }
+
+func (j *securityNoticeJob) Do(ctx context.Context) error {
+	from := j.IP
+	if j.Country != "" {
+		from += " (" + j.Country + ")"
+	}
+	var template string
+	switch j.Kind {
+	case SecurityNoticeNewDevice:
+		template = notify.MailSecurityNewDevice
+	case SecurityNoticeFailedLogins:
+		template = notify.MailSecurityFailedLogins
+	default:
+		return fmt.Errorf("unknown security notice %q", j.Kind)
+	}
+	data := notify.SecurityNoticeData{When: j.At.UTC().Format(time.RFC1123), From: from, UserAgent: j.UserAgent}
+	if err := notify.SendMail(ctx, j.UserUID, notify.Mail{Template: template, Data: data}); err != nil {
+		return fmt.Errorf("send security notice: %w", err)
 	}
 	return nil
 }
@@ -1,23 +1,97 @@
 package jobs
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+
+	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/logging"
 )
 
+var jobsLog = logging.Component("jobs")
+
+// jobLog 带 job_type/job_id 的 logger，属于用户的 Job 额外带 user_id
+func jobLog(j k8s.Job) *slog.Logger {
+	l := jobsLog.With("job_type", j.Type(), "job_id", j.ID())
+	if owned, ok := j.(ownedJob); ok {
+		l = l.With("user_id", owned.OwnerUID())
+	}
+	return l
+}
+
+// owned 任务开始前用 dblayer.AuthorizeResource 确认资源仍属于任务里的用户。
+// 资源已删除或不属于该用户时返回 false，任务直接结束（后者只可能来自伪造或错乱的任务，所以记警告）
+func owned(ctx context.Context, j k8s.Job, userUID, resourceType, id string) (bool, error) {
+	err := dblayer.AuthorizeResource(ctx, userUID, resourceType, id)
+	if err == dblayer.ErrNotFound {
+		jobLog(j).Warn("resource missing or not owned by the job's user, skipped", "resource_type", resourceType, "resource_id", id)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("authorize %s %s: %w", resourceType, id, err)
+	}
+	return true, nil
+}
+
 const (
-	JobTypeAuthRegisterUser     k8s.JobType = "auth.register_user"
-	JobTypeAuthUserAudit        k8s.JobType = "auth.user_audit"
-	JobTypeWorkerDeployWorker   k8s.JobType = "worker.deploy_worker"
-	JobTypeWorkerDeleteWorkerCR k8s.JobType = "worker.delete_worker_cr"
-	JobTypeWorkerSyncEnv        k8s.JobType = "worker.sync_env"
-	JobTypeWorkerSyncSecret     k8s.JobType = "worker.sync_secret"
-	JobTypeCombinatorCreateRDB  k8s.JobType = "combinator.create_rdb"
-	JobTypeCombinatorDeleteRDB  k8s.JobType = "combinator.delete_rdb"
-	JobTypeCombinatorCreateKV   k8s.JobType = "combinator.create_kv"
-	JobTypeCombinatorDeleteKV   k8s.JobType = "combinator.delete_kv"
-	JobTypeDomainCheck          k8s.JobType = "domain.check"
+	JobTypeAuthRegisterUser      k8s.JobType = "auth.register_user"
+	JobTypeAuthUserAudit         k8s.JobType = "auth.user_audit"
+	JobTypeAuthSecurityNotice    k8s.JobType = "auth.security_notice"
+	JobTypeWorkerDeployWorker    k8s.JobType = "worker.deploy_worker"
+	JobTypeWorkerDeleteWorkerCR  k8s.JobType = "worker.delete_worker_cr"
+	JobTypeWorkerSyncEnv         k8s.JobType = "worker.sync_env"
+	JobTypeWorkerSyncSecret      k8s.JobType = "worker.sync_secret"
+	JobTypeWorkerBulk            k8s.JobType = "worker.bulk"
+	JobTypeWorkerSyncTags        k8s.JobType = "worker.sync_tags"
+	JobTypeWorkerSyncSpec        k8s.JobType = "worker.sync_spec"
+	JobTypeWorkerSyncEgress      k8s.JobType = "worker.sync_egress"
+	JobTypeWorkerSyncRoutes      k8s.JobType = "worker.sync_routes"
+	JobTypeWorkerSyncEdgeLimits  k8s.JobType = "worker.sync_edge_limits"
+	JobTypeWorkerSyncErrorPages  k8s.JobType = "worker.sync_error_pages"
+	JobTypeWorkerSyncAttach      k8s.JobType = "worker.sync_attachments"
+	JobTypeWorkerRestart         k8s.JobType = "worker.restart"
+	JobTypeWorkerResize          k8s.JobType = "worker.resize"
+	JobTypeWorkerClone           k8s.JobType = "worker.clone"
+	JobTypeWorkerRemediate       k8s.JobType = "worker.remediate"
+	JobTypeCombinatorCreateRDB   k8s.JobType = "combinator.create_rdb"
+	JobTypeCombinatorDeleteRDB   k8s.JobType = "combinator.delete_rdb"
+	JobTypeCombinatorCreateKV    k8s.JobType = "combinator.create_kv"
+	JobTypeCombinatorDeleteKV    k8s.JobType = "combinator.delete_kv"
+	JobTypeCombinatorCreateQueue k8s.JobType = "combinator.create_queue"
+	JobTypeCombinatorDeleteQueue k8s.JobType = "combinator.delete_queue"
+	JobTypeCombinatorSync        k8s.JobType = "combinator.sync"
+	JobTypeCombinatorRestart     k8s.JobType = "combinator.restart"
+	JobTypeDomainCheck           k8s.JobType = "domain.check"
+	JobTypeDomainSyncTags        k8s.JobType = "domain.sync_tags"
+	JobTypeAlertEvaluate         k8s.JobType = "alert.evaluate"
+	JobTypeUptimeProbe           k8s.JobType = "uptime.probe"
+	JobTypeUptimePrune           k8s.JobType = "uptime.prune"
+	JobTypeAccessLogPrune        k8s.JobType = "access_log.prune"
+	JobTypeClusterRefresh        k8s.JobType = "cluster.refresh"
+	JobTypeDriftAudit            k8s.JobType = "drift.audit"
+	JobTypeConsistencyCheck      k8s.JobType = "db.consistency_check"
+	JobTypeUsageSample           k8s.JobType = "usage.sample"
+	JobTypeBillingDunning        k8s.JobType = "billing.dunning"
+	JobTypeBillingSyncPlan       k8s.JobType = "billing.sync_plan"
+	JobTypeSpendCapEnforce       k8s.JobType = "spendcap.enforce"
+	JobTypeIdleReaper            k8s.JobType = "worker.idle_reaper"
+	JobTypePreviewComment        k8s.JobType = "preview.comment"
+	JobTypeQuotaCheck            k8s.JobType = "quota.check"
+	JobTypeNotificationPrune     k8s.JobType = "notification.prune"
+	JobTypeStaticArtifactPrune   k8s.JobType = "static_artifact.prune"
+	JobTypeBucketCreate          k8s.JobType = "bucket.create"
+	JobTypeBucketDelete          k8s.JobType = "bucket.delete"
+	JobTypeBucketLifecycle       k8s.JobType = "bucket.lifecycle"
+	JobTypeBucketUsage           k8s.JobType = "bucket.usage"
+	JobTypeBucketRotateKeys      k8s.JobType = "bucket.rotate_keys"
+	JobTypeMailCheckDomains      k8s.JobType = "mail.check_domains"
+	JobTypeMailPrune             k8s.JobType = "mail.prune"
+	JobTypeRDBRunTasks           k8s.JobType = "rdb.run_tasks"
+	JobTypeRDBPruneTaskRuns      k8s.JobType = "rdb.prune_task_runs"
+	JobTypeLeaseReap             k8s.JobType = "job.reap_leases"
 )
 
 type ObjectBuilder func() k8s.Job
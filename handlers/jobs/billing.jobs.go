@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/settings"
+)
+
+const (
+	// DunningInterval 检查欠费用户的间隔；付款事件到达时 outer 还会立即投递一次
+	DunningInterval = 15 * time.Minute
+
+	// WorkerStatusSuspended 因欠费被暂停的 worker，和用户自己暂停的（paused）区分开，付清后只恢复这些
+	WorkerStatusSuspended = "suspended"
+)
+
+// DunningGracePeriod 第一次扣款失败后多久暂停 worker，默认值来自 BILLING_GRACE_PERIOD，运行时可覆盖
+var DunningGracePeriod = settings.NewDuration("billing.grace_period", 7*24*time.Hour, 0, 90*24*time.Hour,
+	"How long after the first failed payment the workers of a user are paused")
+
+// dunningJob 欠费超过宽限期的用户暂停所有运行中的 worker，付清后恢复
+type dunningJob struct{}
+
+func NewDunningJob() k8s.Job {
+	return &dunningJob{}
+}
+
+// syncPlanJob 订阅变化改了套餐后，按新套餐更新用户的租户 namespace 配额
+type syncPlanJob struct {
+	UserUID string `json:"user_uid"`
+}
+
+func NewSyncPlanJob(userUID string) k8s.Job {
+	return &syncPlanJob{UserUID: userUID}
+}
+
+func init() {
+	RegisterJobType(JobTypeBillingDunning, NewDunningJob)
+	RegisterJobType(JobTypeBillingSyncPlan, func() k8s.Job {
+		return &syncPlanJob{}
+	})
+}
+
+func (j *dunningJob) Type() k8s.JobType { return JobTypeBillingDunning }
+func (j *dunningJob) ID() string        { return "periodic" }
+
+// dunningMu 周期任务和付款事件触发的任务不同时改同一批 worker
+var dunningMu sync.Mutex
+
+func (j *dunningJob) Do(ctx context.Context) error {
+	dunningMu.Lock()
+	defer dunningMu.Unlock()
+
+	suspend, restore, err := dblayer.ListDunning(ctx, DunningGracePeriod.Get())
+	if err != nil {
+		return fmt.Errorf("list dunning: %w", err)
+	}
+	var errs []error
+	for _, uid := range suspend {
+		if err := setWorkersSuspended(ctx, uid, true); err != nil {
+			errs = append(errs, fmt.Errorf("suspend workers of %s: %w", uid, err))
+		}
+	}
+	for _, uid := range restore {
+		if err := setWorkersSuspended(ctx, uid, false); err != nil {
+			errs = append(errs, fmt.Errorf("restore workers of %s: %w", uid, err))
+		}
+	}
+	if len(suspend)+len(restore) > 0 {
+		jobLog(j).Info("dunning applied", "suspended", len(suspend), "restored", len(restore))
+	}
+	return errors.Join(errs...)
+}
+
+// setWorkersSuspended 暂停用户所有 active 的 worker，或恢复所有 suspended 的；全部成功后才记为已暂停/已恢复，失败的下一轮重试
+func setWorkersSuspended(ctx context.Context, userUID string, suspended bool) error {
+	from, to := "active", WorkerStatusSuspended
+	if !suspended {
+		from, to = WorkerStatusSuspended, "active"
+	}
+	ids, err := dblayer.ListWorkerIDsByStatus(ctx, userUID, from)
+	if err != nil {
+		return err
+	}
+	if _, err := setWorkersStatus(ctx, userUID, ids, suspended, to); err != nil {
+		return err
+	}
+	if err := dblayer.SetWorkersSuspended(ctx, userUID, suspended); err != nil {
+		return err
+	}
+
+	msg := "payment received, workers resumed"
+	if suspended {
+		msg = fmt.Sprintf("payment overdue for more than %s, workers paused", DunningGracePeriod.Get())
+	}
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      userUID,
+		Kind:         dblayer.EventBillingDunning,
+		ResourceType: "subscription",
+		ResourceID:   userUID,
+		Status:       to,
+		Message:      msg,
+	})
+	return nil
+}
+
+func (j *syncPlanJob) OwnerUID() string  { return j.UserUID }
+func (j *syncPlanJob) Type() k8s.JobType { return JobTypeBillingSyncPlan }
+func (j *syncPlanJob) ID() string        { return j.UserUID }
+
+func (j *syncPlanJob) Do(ctx context.Context) error {
+	if k8s.K8sClient == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	return controller.SyncTenantPlan(ctx, j.UserUID)
+}
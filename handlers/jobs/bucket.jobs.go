@@ -0,0 +1,269 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+// 对象存储 bucket：创建、删除、生命周期规则在 k8s.ObjectStore 上执行；
+// 定期测量每个 bucket 的大小用于计量，并在临时凭据过期前重新签发，挂载了它的 worker 随之更新 Secret
+
+const (
+	BucketUsageInterval     = 15 * time.Minute
+	BucketRotateKeyInterval = time.Hour
+)
+
+// issueBucketKey 为 bucket 签发新的临时凭据并入库
+func issueBucketKey(ctx context.Context, userUID, resourceID, bucket string) error {
+	creds, err := k8s.ObjectStore.IssueBucketCredentials(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("issue credentials: %w", err)
+	}
+	return dblayer.SetBucketCredentials(ctx, userUID, resourceID, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, creds.Expiration)
+}
+
+// --- CreateBucketJob ---
+
+type createBucketJob struct {
+	UserUID    string `json:"user_uid"`
+	ResourceID string `json:"resource_id"`
+}
+
+func init() {
+	RegisterJobType(JobTypeBucketCreate, func() k8s.Job {
+		return &createBucketJob{}
+	})
+}
+
+func NewCreateBucketJob(userUID, resourceID string) *createBucketJob {
+	return &createBucketJob{UserUID: userUID, ResourceID: resourceID}
+}
+
+func (j *createBucketJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *createBucketJob) Type() k8s.JobType { return JobTypeBucketCreate }
+func (j *createBucketJob) ID() string {
+	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
+}
+
+// Abandon 多次卡住或 panic 后放弃，资源不再停在 pending
+func (j *createBucketJob) Abandon(ctx context.Context, reason string) {
+	setResourceStatus(ctx, j.UserUID, dblayer.ResourceBucket, j.ResourceID, "error", reason)
+}
+
+// Do 创建 bucket、应用生命周期规则、签发凭据，然后才标记 active。
+// 规则在记下 bucket 名之后读：之前保存的规则在这里应用，之后保存的由 bucket.lifecycle 任务应用
+func (j *createBucketJob) Do(ctx context.Context) error {
+	fail := func(step string, err error) error {
+		setResourceStatus(ctx, j.UserUID, dblayer.ResourceBucket, j.ResourceID, "error", err.Error())
+		return fmt.Errorf("%s: %w", step, err)
+	}
+	if k8s.ObjectStore == nil {
+		return fail("create bucket", fmt.Errorf("object storage not available"))
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceBucket, j.ResourceID); !ok {
+		return err
+	}
+	name := k8s.ObjectStore.BucketName(j.ResourceID)
+	if err := k8s.ObjectStore.CreateBucket(ctx, name); err != nil {
+		return fail("create bucket", err)
+	}
+	if err := dblayer.SetObjectBucketName(ctx, j.UserUID, j.ResourceID, name); err != nil {
+		return fmt.Errorf("record bucket name: %w", err)
+	}
+	b, err := dblayer.GetObjectBucket(ctx, j.UserUID, j.ResourceID)
+	if err == dblayer.ErrNotFound {
+		return nil // 刚被删除，删除任务会清理
+	}
+	if err != nil {
+		return fmt.Errorf("get bucket: %w", err)
+	}
+	if err := k8s.ObjectStore.PutLifecycle(ctx, name, b.Lifecycle); err != nil {
+		return fail("apply lifecycle", err)
+	}
+	if err := issueBucketKey(ctx, j.UserUID, j.ResourceID, name); err != nil {
+		return fail("issue key", err)
+	}
+
+	setResourceStatus(ctx, j.UserUID, dblayer.ResourceBucket, j.ResourceID, "active", "")
+	if err := syncResourceAttachments(ctx, j.UserUID, dblayer.ResourceBucket, j.ResourceID); err != nil {
+		jobLog(j).Error("sync attachments failed", "error", err)
+	}
+	jobLog(j).Info("bucket created", "bucket", name)
+	return nil
+}
+
+// --- DeleteBucketJob ---
+
+type deleteBucketJob struct {
+	UserUID    string `json:"user_uid"`
+	ResourceID string `json:"resource_id"`
+	BucketName string `json:"bucket_name"` // 资源行已删除，由 outer 带过来；创建完成前为空
+}
+
+func init() {
+	RegisterJobType(JobTypeBucketDelete, func() k8s.Job {
+		return &deleteBucketJob{}
+	})
+}
+
+func NewDeleteBucketJob(userUID, resourceID, bucketName string) *deleteBucketJob {
+	return &deleteBucketJob{UserUID: userUID, ResourceID: resourceID, BucketName: bucketName}
+}
+
+func (j *deleteBucketJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *deleteBucketJob) Type() k8s.JobType { return JobTypeBucketDelete }
+func (j *deleteBucketJob) ID() string {
+	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
+}
+
+// Do 清空并删除 bucket；创建任务可能已经建好了 bucket 却没来得及记名字，所以名字为空时按规则推出来
+func (j *deleteBucketJob) Do(ctx context.Context) error {
+	if k8s.ObjectStore == nil {
+		return fmt.Errorf("object storage not available")
+	}
+	name := j.BucketName
+	if name == "" {
+		name = k8s.ObjectStore.BucketName(j.ResourceID)
+	}
+	if err := k8s.ObjectStore.DeleteBucket(ctx, name); err != nil {
+		return fmt.Errorf("delete bucket: %w", err)
+	}
+	jobLog(j).Info("bucket deleted", "bucket", name)
+	return nil
+}
+
+// --- BucketLifecycleJob ---
+
+type bucketLifecycleJob struct {
+	UserUID    string `json:"user_uid"`
+	ResourceID string `json:"resource_id"`
+}
+
+func init() {
+	RegisterJobType(JobTypeBucketLifecycle, func() k8s.Job {
+		return &bucketLifecycleJob{}
+	})
+}
+
+func NewBucketLifecycleJob(userUID, resourceID string) *bucketLifecycleJob {
+	return &bucketLifecycleJob{UserUID: userUID, ResourceID: resourceID}
+}
+
+func (j *bucketLifecycleJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *bucketLifecycleJob) Type() k8s.JobType { return JobTypeBucketLifecycle }
+func (j *bucketLifecycleJob) ID() string {
+	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
+}
+
+// Do 把库里当前的规则写到 bucket 上；bucket 还没建好时由创建任务应用
+func (j *bucketLifecycleJob) Do(ctx context.Context) error {
+	if k8s.ObjectStore == nil {
+		return fmt.Errorf("object storage not available")
+	}
+	b, err := dblayer.GetObjectBucket(ctx, j.UserUID, j.ResourceID)
+	if err == dblayer.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get bucket: %w", err)
+	}
+	if b.BucketName == "" {
+		return nil
+	}
+	if err := k8s.ObjectStore.PutLifecycle(ctx, b.BucketName, b.Lifecycle); err != nil {
+		return fmt.Errorf("apply lifecycle: %w", err)
+	}
+	jobLog(j).Info("bucket lifecycle applied", "rules", len(b.Lifecycle))
+	return nil
+}
+
+// --- BucketUsageJob ---
+
+// bucketUsageJob 测量所有 bucket 的大小和对象数，usage.sample 按最近一次测量计量存储
+type bucketUsageJob struct{}
+
+func NewBucketUsageJob() k8s.Job {
+	return &bucketUsageJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeBucketUsage, NewBucketUsageJob)
+}
+
+func (j *bucketUsageJob) Type() k8s.JobType { return JobTypeBucketUsage }
+func (j *bucketUsageJob) ID() string        { return "periodic" }
+
+func (j *bucketUsageJob) Do(ctx context.Context) error {
+	if k8s.ObjectStore == nil {
+		return nil
+	}
+	buckets, err := dblayer.ListActiveBuckets(ctx)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, b := range buckets {
+		size, objects, err := k8s.ObjectStore.BucketUsage(ctx, b.BucketName)
+		if err == nil {
+			err = dblayer.SetBucketUsage(ctx, b.UserUID, b.ResourceID, size, objects)
+		}
+		if err != nil {
+			jobLog(j).Warn("measure bucket failed", "user_id", b.UserUID, "resource_id", b.ResourceID, "error", err)
+			failed++
+		}
+	}
+	jobLog(j).Debug("buckets measured", "buckets", len(buckets), "failed", failed)
+	return nil
+}
+
+// --- BucketRotateKeysJob ---
+
+// bucketRotateKeysJob 凭据剩余有效期不足 KeyTTL 的三分之一时重新签发，留出重试和 worker 滚动更新的时间
+type bucketRotateKeysJob struct{}
+
+func NewBucketRotateKeysJob() k8s.Job {
+	return &bucketRotateKeysJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeBucketRotateKeys, NewBucketRotateKeysJob)
+}
+
+func (j *bucketRotateKeysJob) Type() k8s.JobType { return JobTypeBucketRotateKeys }
+func (j *bucketRotateKeysJob) ID() string        { return "periodic" }
+
+func (j *bucketRotateKeysJob) Do(ctx context.Context) error {
+	if k8s.ObjectStore == nil {
+		return nil
+	}
+	buckets, err := dblayer.ListBucketsForKeyRotation(ctx, time.Now().UTC().Add(k8s.ObjectStore.KeyTTL/3))
+	if err != nil {
+		return err
+	}
+	for _, b := range buckets {
+		if err := issueBucketKey(ctx, b.UserUID, b.ResourceID, b.BucketName); err != nil {
+			jobLog(j).Error("rotate bucket key failed", "user_id", b.UserUID, "resource_id", b.ResourceID, "error", err)
+			continue
+		}
+		if err := syncResourceAttachments(ctx, b.UserUID, dblayer.ResourceBucket, b.ResourceID); err != nil {
+			jobLog(j).Error("sync attachments failed", "user_id", b.UserUID, "resource_id", b.ResourceID, "error", err)
+		}
+	}
+	if len(buckets) > 0 {
+		jobLog(j).Info("bucket keys rotated", "buckets", len(buckets))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+)
+
+// 批量操作支持的动作
+const (
+	BulkActionRestart = "restart"
+	BulkActionPause   = "pause"
+	BulkActionResume  = "resume"
+	BulkActionSetEnv  = "set_env"
+	BulkActionDelete  = "delete"
+)
+
+// bulkConcurrency 单个批量任务内同时操作的 worker 数
+const bulkConcurrency = 4
+
+// BulkWorkerItem 批量任务中的一个 worker，Env 只在 set_env 时使用（合并后的完整 env）
+type BulkWorkerItem struct {
+	WorkerID string            `json:"worker_id"`
+	Env      map[string]string `json:"env,omitempty"`
+}
+
+type bulkWorkerJob struct {
+	UserUID string           `json:"user_uid"`
+	Action  string           `json:"action"`
+	Items   []BulkWorkerItem `json:"items"`
+}
+
+func NewBulkWorkerJob(userUID, action string, items []BulkWorkerItem) k8s.Job {
+	return &bulkWorkerJob{
+		UserUID: userUID,
+		Action:  action,
+		Items:   items,
+	}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerBulk, func() k8s.Job {
+		return &bulkWorkerJob{}
+	})
+}
+
+func (j *bulkWorkerJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *bulkWorkerJob) Type() k8s.JobType {
+	return JobTypeWorkerBulk
+}
+
+func (j *bulkWorkerJob) ID() string {
+	return fmt.Sprintf("%s-%s-%d", j.UserUID, j.Action, len(j.Items))
+}
+
+// Do 并发（上限 bulkConcurrency）执行每个 worker 的操作，每个结果单独推送事件
+func (j *bulkWorkerJob) Do(ctx context.Context) error {
+	sem := make(chan struct{}, bulkConcurrency)
+	errs := make([]error, len(j.Items))
+	var wg sync.WaitGroup
+	for i, item := range j.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := j.do(ctx, item)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s %s: %w", j.Action, item.WorkerID, err)
+			}
+			j.publish(ctx, item.WorkerID, err)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (j *bulkWorkerJob) do(ctx context.Context, item BulkWorkerItem) error {
+	name := controller.WorkerName(item.WorkerID, j.UserUID)
+	switch j.Action {
+	case BulkActionRestart:
+		cluster, err := controller.ClusterOf(ctx, item.WorkerID, j.UserUID)
+		if err != nil {
+			return err
+		}
+		return controller.RestartWorkerDeployment(ctx, cluster.Kube, k8s.TenantNamespace(j.UserUID), name)
+	case BulkActionPause, BulkActionResume:
+		paused := j.Action == BulkActionPause
+		if err := controller.SetWorkerAppPaused(ctx, k8s.DynamicClient, name, paused); err != nil {
+			return err
+		}
+		status := "active"
+		if paused {
+			status = "paused"
+		}
+		return dblayer.UpdateWorkerStatus(ctx, item.WorkerID, status)
+	case BulkActionSetEnv:
+		return NewSyncEnvJob(item.WorkerID, j.UserUID, item.Env).Do(ctx)
+	case BulkActionDelete:
+		return NewDeleteWorkerCRJob(item.WorkerID, j.UserUID).Do(ctx)
+	default:
+		return fmt.Errorf("unknown bulk action: %s", j.Action)
+	}
+}
+
+func (j *bulkWorkerJob) publish(ctx context.Context, workerID string, err error) {
+	ev := dblayer.Event{
+		UserUID:      j.UserUID,
+		Kind:         dblayer.EventWorkerBulk,
+		ResourceType: "worker",
+		ResourceID:   workerID,
+		Status:       "success",
+		Message:      j.Action,
+	}
+	if err != nil {
+		ev.Status = "error"
+		ev.Message = j.Action + ": " + err.Error()
+	}
+	dblayer.PublishEvent(ctx, ev)
+}
@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// cloneReadyTimeout 等克隆出的 worker 首次部署建好 ConfigMap 和 Secret 的最长时间
+	cloneReadyTimeout = 3 * time.Minute
+	cloneReadyPoll    = 2 * time.Second
+)
+
+// cloneWorkerJob 克隆的 worker 首次部署后把 env 同步进它的 ConfigMap；CopySecrets 时把源 worker Secret 里
+// 用户设置的 secret 值复制过去（值只存在 K8s 里，outer 拿不到）
+type cloneWorkerJob struct {
+	SourceID    string `json:"source_id"`
+	WorkerID    string `json:"worker_id"`
+	UserUID     string `json:"user_uid"`
+	CopySecrets bool   `json:"copy_secrets"`
+}
+
+func NewCloneWorkerJob(sourceID, workerID, userUID string, copySecrets bool) k8s.Job {
+	return &cloneWorkerJob{
+		SourceID:    sourceID,
+		WorkerID:    workerID,
+		UserUID:     userUID,
+		CopySecrets: copySecrets,
+	}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerClone, func() k8s.Job {
+		return &cloneWorkerJob{}
+	})
+}
+
+func (j *cloneWorkerJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *cloneWorkerJob) Type() k8s.JobType {
+	return JobTypeWorkerClone
+}
+
+func (j *cloneWorkerJob) ID() string {
+	return j.WorkerID
+}
+
+func (j *cloneWorkerJob) Do(ctx context.Context) error {
+	if k8s.K8sClient == nil {
+		return nil
+	}
+	if ok, err := owned(ctx, j, j.UserUID, "worker", j.WorkerID); !ok {
+		return err
+	}
+	if err := j.waitReady(ctx); err != nil {
+		return err
+	}
+
+	if j.CopySecrets {
+		data, err := j.sourceSecrets(ctx)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			if err := NewSyncSecretJob(j.WorkerID, j.UserUID, data).Do(ctx); err != nil {
+				return fmt.Errorf("copy secrets: %w", err)
+			}
+		}
+	}
+
+	envJSON, _, err := dblayer.GetWorkerEnvByOwner(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return fmt.Errorf("get env: %w", err)
+	}
+	var env map[string]string
+	json.Unmarshal([]byte(envJSON), &env)
+	return NewSyncEnvJob(j.WorkerID, j.UserUID, env).Do(ctx)
+}
+
+// waitReady 等克隆 worker 的 ConfigMap 和 Secret 由 controller 建好，之前的同步任务遇到它们不存在会直接跳过
+func (j *cloneWorkerJob) waitReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, cloneReadyTimeout)
+	defer cancel()
+	name := controller.WorkerName(j.WorkerID, j.UserUID)
+	ns := k8s.TenantNamespace(j.UserUID)
+	for {
+		// 首次部署才确定集群
+		if cluster, err := controller.ClusterOf(ctx, j.WorkerID, j.UserUID); err == nil {
+			_, cmErr := cluster.Kube.CoreV1().ConfigMaps(ns).Get(ctx, name+"-env", metav1.GetOptions{})
+			_, secErr := cluster.Kube.CoreV1().Secrets(ns).Get(ctx, name+"-secret", metav1.GetOptions{})
+			if cmErr == nil && secErr == nil {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("worker %s not ready after %s: %w", j.WorkerID, cloneReadyTimeout, ctx.Err())
+		case <-time.After(cloneReadyPoll):
+		}
+	}
+}
+
+// sourceSecrets 读出源 worker Secret 里用户设置的 key（secrets_json 里列出的），源 worker 没部署过时为空
+func (j *cloneWorkerJob) sourceSecrets(ctx context.Context) (map[string]string, error) {
+	secretsJSON, _, err := dblayer.GetWorkerSecretsByOwner(ctx, j.SourceID, j.UserUID)
+	if err != nil {
+		jobLog(j).Warn("source worker gone, secrets not copied", "source_id", j.SourceID)
+		return nil, nil
+	}
+	var keys []string
+	json.Unmarshal([]byte(secretsJSON), &keys)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	cluster, err := controller.ClusterOf(ctx, j.SourceID, j.UserUID)
+	if err != nil {
+		return nil, nil
+	}
+	name := controller.WorkerName(j.SourceID, j.UserUID) + "-secret"
+	sec, err := cluster.Kube.CoreV1().Secrets(k8s.TenantNamespace(j.UserUID)).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get source secret: %w", err)
+	}
+	data := map[string]string{}
+	for _, k := range keys {
+		if v, ok := sec.Data[k]; ok {
+			data[k] = string(v)
+		}
+	}
+	return data, nil
+}
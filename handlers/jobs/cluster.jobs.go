@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"jabberwocky238/console/k8s"
+)
+
+// ClusterRefreshInterval 重新读取集群注册 Secret 的间隔
+const ClusterRefreshInterval = 5 * time.Minute
+
+// clusterRefreshJob 按注册 Secret 刷新 k8s.Clusters 的客户端池
+type clusterRefreshJob struct{}
+
+func NewClusterRefreshJob() k8s.Job {
+	return &clusterRefreshJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeClusterRefresh, NewClusterRefreshJob)
+}
+
+func (j *clusterRefreshJob) Type() k8s.JobType { return JobTypeClusterRefresh }
+func (j *clusterRefreshJob) ID() string        { return "periodic" }
+
+func (j *clusterRefreshJob) Do(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := k8s.RefreshClusters(ctx); err != nil {
+		return err
+	}
+	jobLog(j).Debug("clusters refreshed", "clusters", len(k8s.Clusters.List()))
+	return nil
+}
@@ -5,16 +5,64 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// syncCombinatorApp 把用户所有 active 的资源写入其 CombinatorApp，没有资源时删除。
+// 每次同步配置版本加一，resourceID 是这次变化的资源（删除时行已不存在），在 pod 回报加载前显示为 pending_apply。
+// 规格取用户选择的 profile，超出当前套餐时降到套餐上限
+func syncCombinatorApp(ctx context.Context, userUID, resourceType, resourceID string) error {
+	if !controller.CombinatorApps {
+		return nil
+	}
+	if k8s.DynamicClient == nil {
+		return fmt.Errorf("k8s client not available")
+	}
+	resources, err := dblayer.ListActiveCombinatorResources(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("list active resources: %w", err)
+	}
+	var rdb, kv, queue []string
+	for _, r := range resources {
+		switch r.ResourceType {
+		case "rdb":
+			rdb = append(rdb, r.ResourceID)
+		case "kv":
+			kv = append(kv, r.ResourceID)
+		case "queue":
+			queue = append(queue, r.ResourceID)
+		}
+	}
+	revision, err := dblayer.BumpCombinatorRevision(ctx, userUID, resourceType, resourceID)
+	if err != nil {
+		return fmt.Errorf("bump config revision: %w", err)
+	}
+	cfg, err := dblayer.GetCombinatorConfig(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("get combinator config: %w", err)
+	}
+	plan, err := k8s.PlanForUser(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("get plan: %w", err)
+	}
+	return controller.SyncCombinatorAppCR(ctx, k8s.DynamicClient, controller.CombinatorAppSpec{
+		OwnerID:  userUID,
+		RDB:      rdb,
+		KV:       kv,
+		Queue:    queue,
+		Revision: revision,
+		Profile:  k8s.ClampCombinatorProfile(plan, cfg.Profile).Name,
+	})
+}
+
 // notifyAllCombinatorPods 向所有 combinator pod 发送删除通知
 func notifyAllCombinatorPods(userUID, resourceID, resourceType string) error {
 	if k8s.K8sClient == nil {
@@ -57,19 +105,19 @@ func notifyAllCombinatorPods(userUID, resourceID, resourceType string) error {
 		url := fmt.Sprintf("http://%s:8890/webhook", podIP)
 		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 		if err != nil {
-			log.Printf("[combinator] failed to create request for pod %s: %v", pod.Name, err)
+			jobsLog.Error("create notify request failed", "pod", pod.Name, "error", err)
 			continue
 		}
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := client.Do(req)
 		if err != nil {
-			log.Printf("[combinator] failed to notify pod %s: %v", pod.Name, err)
+			jobsLog.Error("notify pod failed", "pod", pod.Name, "error", err)
 			continue
 		}
 		resp.Body.Close()
 
-		log.Printf("[combinator] notified pod %s about deletion of %s/%s", pod.Name, resourceType, resourceID)
+		jobsLog.Info("notified pod about deletion", "pod", pod.Name, "resource_type", resourceType, "resource_id", resourceID)
 	}
 
 	return nil
@@ -97,27 +145,39 @@ func NewCreateRDBJob(userUID, name, resourceID string) *createRDBJob {
 	}
 }
 
+func (j *createRDBJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *createRDBJob) Type() k8s.JobType { return JobTypeCombinatorCreateRDB }
 func (j *createRDBJob) ID() string {
 	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
 }
 
-func (j *createRDBJob) Do() error {
+// Abandon 多次卡住或 panic 后放弃，资源不再停在 pending
+func (j *createRDBJob) Abandon(ctx context.Context, reason string) {
+	setResourceStatus(ctx, j.UserUID, "rdb", j.ResourceID, "error", reason)
+}
+
+func (j *createRDBJob) Do(ctx context.Context) error {
 	if k8s.RDBManager == nil {
-		dblayer.UpdateCombinatorResourceStatus(j.UserUID, "rdb", j.ResourceID, "error", "cockroachdb not available")
+		setResourceStatus(ctx, j.UserUID, "rdb", j.ResourceID, "error", "cockroachdb not available")
 		return fmt.Errorf("cockroachdb not available")
 	}
 	if err := k8s.RDBManager.InitUserRDB(j.UserUID); err != nil {
-		dblayer.UpdateCombinatorResourceStatus(j.UserUID, "rdb", j.ResourceID, "error", err.Error())
+		setResourceStatus(ctx, j.UserUID, "rdb", j.ResourceID, "error", err.Error())
 		return fmt.Errorf("init user rdb: %w", err)
 	}
 	if err := k8s.RDBManager.CreateSchema(j.UserUID, j.ResourceID); err != nil {
-		dblayer.UpdateCombinatorResourceStatus(j.UserUID, "rdb", j.ResourceID, "error", err.Error())
+		setResourceStatus(ctx, j.UserUID, "rdb", j.ResourceID, "error", err.Error())
 		return fmt.Errorf("create schema: %w", err)
 	}
 
-	dblayer.UpdateCombinatorResourceStatus(j.UserUID, "rdb", j.ResourceID, "active", "")
-	log.Printf("[combinator] RDB %s created for user %s", j.ResourceID, j.UserUID)
+	setResourceStatus(ctx, j.UserUID, "rdb", j.ResourceID, "active", "")
+	if err := syncCombinatorApp(ctx, j.UserUID, "rdb", j.ResourceID); err != nil {
+		jobLog(j).Error("sync combinator app failed", "error", err)
+	}
+	jobLog(j).Info("RDB created")
 	return nil
 }
 
@@ -138,24 +198,31 @@ func NewDeleteRDBJob(userUID, resourceID string) *deleteRDBJob {
 	return &deleteRDBJob{UserUID: userUID, ResourceID: resourceID}
 }
 
+func (j *deleteRDBJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *deleteRDBJob) Type() k8s.JobType { return JobTypeCombinatorDeleteRDB }
 func (j *deleteRDBJob) ID() string {
 	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
 }
 
-func (j *deleteRDBJob) Do() error {
+func (j *deleteRDBJob) Do(ctx context.Context) error {
 	if k8s.RDBManager != nil {
 		if err := k8s.RDBManager.DeleteSchema(j.UserUID, j.ResourceID); err != nil {
-			log.Printf("[combinator] delete schema %s failed: %v", j.ResourceID, err)
+			jobLog(j).Error("delete schema failed", "error", err)
 		}
 	}
 
 	// 通知所有 combinator pod
 	if err := notifyAllCombinatorPods(j.UserUID, j.ResourceID, "rdb"); err != nil {
-		log.Printf("[combinator] failed to notify pods about RDB deletion: %v", err)
+		jobLog(j).Error("notify pods about RDB deletion failed", "error", err)
+	}
+	if err := syncCombinatorApp(ctx, j.UserUID, "rdb", j.ResourceID); err != nil {
+		jobLog(j).Error("sync combinator app failed", "error", err)
 	}
 
-	log.Printf("[combinator] RDB %s deleted for user %s", j.ResourceID, j.UserUID)
+	jobLog(j).Info("RDB deleted")
 	return nil
 }
 
@@ -179,14 +246,26 @@ func NewCreateKVJob(userUID, resourceID string) *createKVJob {
 	}
 }
 
+func (j *createKVJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *createKVJob) Type() k8s.JobType { return JobTypeCombinatorCreateKV }
 func (j *createKVJob) ID() string {
 	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
 }
 
-func (j *createKVJob) Do() error {
-	dblayer.UpdateCombinatorResourceStatus(j.UserUID, "kv", j.ResourceID, "active", "")
-	log.Printf("[combinator] KV %s created for user %s", j.ResourceID, j.UserUID)
+// Abandon 多次卡住或 panic 后放弃，资源不再停在 pending
+func (j *createKVJob) Abandon(ctx context.Context, reason string) {
+	setResourceStatus(ctx, j.UserUID, "kv", j.ResourceID, "error", reason)
+}
+
+func (j *createKVJob) Do(ctx context.Context) error {
+	setResourceStatus(ctx, j.UserUID, "kv", j.ResourceID, "active", "")
+	if err := syncCombinatorApp(ctx, j.UserUID, "kv", j.ResourceID); err != nil {
+		jobLog(j).Error("sync combinator app failed", "error", err)
+	}
+	jobLog(j).Info("KV created")
 	return nil
 }
 
@@ -207,17 +286,204 @@ func NewDeleteKVJob(userUID, resourceID string) *deleteKVJob {
 	return &deleteKVJob{UserUID: userUID, ResourceID: resourceID}
 }
 
+func (j *deleteKVJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *deleteKVJob) Type() k8s.JobType { return JobTypeCombinatorDeleteKV }
 func (j *deleteKVJob) ID() string {
 	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
 }
 
-func (j *deleteKVJob) Do() error {
+func (j *deleteKVJob) Do(ctx context.Context) error {
 	// 通知所有 combinator pod
 	if err := notifyAllCombinatorPods(j.UserUID, j.ResourceID, "kv"); err != nil {
-		log.Printf("[combinator] failed to notify pods about KV deletion: %v", err)
+		jobLog(j).Error("notify pods about KV deletion failed", "error", err)
 	}
+	if err := syncCombinatorApp(ctx, j.UserUID, "kv", j.ResourceID); err != nil {
+		jobLog(j).Error("sync combinator app failed", "error", err)
+	}
+
+	jobLog(j).Info("KV deleted")
+	return nil
+}
+
+// --- CreateQueueJob ---
+
+type createQueueJob struct {
+	UserUID    string `json:"user_uid"`
+	ResourceID string `json:"resource_id"`
+}
+
+func init() {
+	RegisterJobType(JobTypeCombinatorCreateQueue, func() k8s.Job {
+		return &createQueueJob{}
+	})
+}
+
+func NewCreateQueueJob(userUID, resourceID string) *createQueueJob {
+	return &createQueueJob{UserUID: userUID, ResourceID: resourceID}
+}
+
+func (j *createQueueJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *createQueueJob) Type() k8s.JobType { return JobTypeCombinatorCreateQueue }
+func (j *createQueueJob) ID() string {
+	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
+}
+
+// Abandon 多次卡住或 panic 后放弃，资源不再停在 pending
+func (j *createQueueJob) Abandon(ctx context.Context, reason string) {
+	setResourceStatus(ctx, j.UserUID, dblayer.ResourceQueue, j.ResourceID, "error", reason)
+}
+
+// Do 在用户的 vhost 里声明队列（首个队列时创建 vhost、broker 用户和保存密码的 Secret），
+// 之后连接信息随 combinator 配置和挂载下发
+func (j *createQueueJob) Do(ctx context.Context) error {
+	if k8s.QueueBroker == nil {
+		setResourceStatus(ctx, j.UserUID, dblayer.ResourceQueue, j.ResourceID, "error", "queue broker not available")
+		return fmt.Errorf("queue broker not available")
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceQueue, j.ResourceID); !ok {
+		return err
+	}
+	if err := k8s.QueueBroker.CreateQueue(ctx, j.UserUID, j.ResourceID); err != nil {
+		setResourceStatus(ctx, j.UserUID, dblayer.ResourceQueue, j.ResourceID, "error", err.Error())
+		return fmt.Errorf("create queue: %w", err)
+	}
+
+	setResourceStatus(ctx, j.UserUID, dblayer.ResourceQueue, j.ResourceID, "active", "")
+	if err := syncCombinatorApp(ctx, j.UserUID, dblayer.ResourceQueue, j.ResourceID); err != nil {
+		jobLog(j).Error("sync combinator app failed", "error", err)
+	}
+	if err := syncResourceAttachments(ctx, j.UserUID, dblayer.ResourceQueue, j.ResourceID); err != nil {
+		jobLog(j).Error("sync attachments failed", "error", err)
+	}
+	jobLog(j).Info("queue created")
+	return nil
+}
 
-	log.Printf("[combinator] KV %s deleted for user %s", j.ResourceID, j.UserUID)
+// --- DeleteQueueJob ---
+
+type deleteQueueJob struct {
+	UserUID    string `json:"user_uid"`
+	ResourceID string `json:"resource_id"`
+}
+
+func init() {
+	RegisterJobType(JobTypeCombinatorDeleteQueue, func() k8s.Job {
+		return &deleteQueueJob{}
+	})
+}
+
+func NewDeleteQueueJob(userUID, resourceID string) *deleteQueueJob {
+	return &deleteQueueJob{UserUID: userUID, ResourceID: resourceID}
+}
+
+func (j *deleteQueueJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *deleteQueueJob) Type() k8s.JobType { return JobTypeCombinatorDeleteQueue }
+func (j *deleteQueueJob) ID() string {
+	return string(j.Type()) + fmt.Sprintf("%s_%s", j.UserUID, j.ResourceID)
+}
+
+// Do 删除队列和其中的消息；vhost 和 broker 用户保留给用户的其他队列，用户注销后由审计任务清理
+func (j *deleteQueueJob) Do(ctx context.Context) error {
+	if k8s.QueueBroker == nil {
+		return fmt.Errorf("queue broker not available")
+	}
+	if err := k8s.QueueBroker.DeleteQueue(ctx, j.UserUID, j.ResourceID); err != nil {
+		return fmt.Errorf("delete queue: %w", err)
+	}
+
+	if err := notifyAllCombinatorPods(j.UserUID, j.ResourceID, dblayer.ResourceQueue); err != nil {
+		jobLog(j).Error("notify pods about queue deletion failed", "error", err)
+	}
+	if err := syncCombinatorApp(ctx, j.UserUID, dblayer.ResourceQueue, j.ResourceID); err != nil {
+		jobLog(j).Error("sync combinator app failed", "error", err)
+	}
+
+	jobLog(j).Info("queue deleted")
+	return nil
+}
+
+// --- CombinatorSyncJob ---
+
+// combinatorSyncJob 重新同步用户的 CombinatorApp（如修改 profile 后），规格变化会滚动重启 pod
+type combinatorSyncJob struct {
+	UserUID string `json:"user_uid"`
+}
+
+func init() {
+	RegisterJobType(JobTypeCombinatorSync, func() k8s.Job {
+		return &combinatorSyncJob{}
+	})
+}
+
+func NewCombinatorSyncJob(userUID string) *combinatorSyncJob {
+	return &combinatorSyncJob{UserUID: userUID}
+}
+
+func (j *combinatorSyncJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *combinatorSyncJob) Type() k8s.JobType { return JobTypeCombinatorSync }
+func (j *combinatorSyncJob) ID() string {
+	return string(j.Type()) + j.UserUID
+}
+
+func (j *combinatorSyncJob) Do(ctx context.Context) error {
+	if err := syncCombinatorApp(ctx, j.UserUID, "", ""); err != nil {
+		return err
+	}
+	jobLog(j).Info("combinator app synced")
+	return nil
+}
+
+// --- CombinatorRestartJob ---
+
+// combinatorRestartJob 滚动重启用户的专属 combinator；RequestedAt 区分多次重启，重复投递只会多滚动一次
+type combinatorRestartJob struct {
+	UserUID     string `json:"user_uid"`
+	RequestedAt int64  `json:"requested_at"`
+}
+
+func init() {
+	RegisterJobType(JobTypeCombinatorRestart, func() k8s.Job {
+		return &combinatorRestartJob{}
+	})
+}
+
+func NewCombinatorRestartJob(userUID string) *combinatorRestartJob {
+	return &combinatorRestartJob{UserUID: userUID, RequestedAt: time.Now().Unix()}
+}
+
+func (j *combinatorRestartJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *combinatorRestartJob) Type() k8s.JobType { return JobTypeCombinatorRestart }
+func (j *combinatorRestartJob) ID() string {
+	return string(j.Type()) + fmt.Sprintf("%s_%d", j.UserUID, j.RequestedAt)
+}
+
+func (j *combinatorRestartJob) Do(ctx context.Context) error {
+	if k8s.K8sClient == nil {
+		return fmt.Errorf("k8s client not available")
+	}
+	err := controller.RestartCombinatorApp(ctx, j.UserUID)
+	if apierrors.IsNotFound(err) {
+		jobLog(j).Warn("combinator is not deployed, nothing to restart")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("restart combinator: %w", err)
+	}
+	jobLog(j).Info("combinator restarted")
 	return nil
 }
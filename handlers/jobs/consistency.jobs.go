@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/metrics"
+)
+
+// ConsistencyCheckInterval 数据一致性检查的间隔
+const ConsistencyCheckInterval = 6 * time.Hour
+
+// consistencyItemLimit 报告里每类问题最多列出的行数
+const consistencyItemLimit = 500
+
+// ConsistencyReport 一次一致性检查的结果；Items 里 repaired 的行已经修复，其余的还在
+type ConsistencyReport struct {
+	StartedAt  time.Time               `json:"started_at"`
+	DurationMS int64                   `json:"duration_ms"`
+	Repair     bool                    `json:"repair"`
+	Summary    map[string]int          `json:"summary"` // 每类剩下的问题数
+	Items      []dblayer.Inconsistency `json:"items"`
+	Truncated  []string                `json:"truncated,omitempty"` // 超出上限没有列全的类型
+	Validated  []string                `json:"validated,omitempty"` // 这次 VALIDATE 的约束
+	Pending    []string                `json:"unvalidated_constraints,omitempty"`
+}
+
+var (
+	latestConsistency atomic.Pointer[ConsistencyReport]
+	consistencyMu     sync.Mutex // 定时检查和管理员触发的修复不并发
+)
+
+// LatestConsistencyReport 最近一次检查结果，还没跑过时为 nil
+func LatestConsistencyReport() *ConsistencyReport {
+	return latestConsistency.Load()
+}
+
+// RunConsistencyCheck 检查（repair 时先修复可以自动修复的问题）并验证孤儿已清空的约束，
+// 结果同时作为最新报告保存
+func RunConsistencyCheck(ctx context.Context, repair bool) (*ConsistencyReport, error) {
+	consistencyMu.Lock()
+	defer consistencyMu.Unlock()
+
+	report := &ConsistencyReport{StartedAt: time.Now(), Repair: repair, Summary: map[string]int{}}
+	if repair {
+		repaired, err := dblayer.RepairInconsistencies(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("repair: %w", err)
+		}
+		report.Items = repaired
+	}
+	found, truncated, err := dblayer.FindInconsistencies(ctx, consistencyItemLimit)
+	if err != nil {
+		return nil, fmt.Errorf("check: %w", err)
+	}
+	report.Items = append(report.Items, found...)
+	report.Truncated = truncated
+
+	dirty := map[string]bool{}
+	for _, item := range found {
+		dirty[item.Check] = true
+		report.Summary[item.Check]++
+	}
+	report.Validated, report.Pending, err = dblayer.ValidateForeignKeys(ctx, dirty)
+	if err != nil {
+		return nil, fmt.Errorf("validate constraints: %w", err)
+	}
+
+	metrics.DBInconsistencies.Reset()
+	for check, n := range report.Summary {
+		metrics.DBInconsistencies.WithLabelValues(check).Set(float64(n))
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+	latestConsistency.Store(report)
+	return report, nil
+}
+
+// consistencyCheckJob 定期检查孤儿行和失效的引用，只报告不修复
+type consistencyCheckJob struct{}
+
+func NewConsistencyCheckJob() k8s.Job {
+	return &consistencyCheckJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeConsistencyCheck, NewConsistencyCheckJob)
+}
+
+func (j *consistencyCheckJob) Type() k8s.JobType { return JobTypeConsistencyCheck }
+func (j *consistencyCheckJob) ID() string        { return "periodic" }
+
+func (j *consistencyCheckJob) Do(ctx context.Context) error {
+	report, err := RunConsistencyCheck(ctx, false)
+	if err != nil {
+		return err
+	}
+	issues := 0
+	for _, n := range report.Summary {
+		issues += n
+	}
+	if issues > 0 {
+		jobLog(j).Warn("consistency check found issues", "summary", report.Summary, "unvalidated_constraints", report.Pending)
+	} else {
+		jobLog(j).Info("consistency check completed", "validated", report.Validated)
+	}
+	return nil
+}
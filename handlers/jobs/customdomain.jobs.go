@@ -1,13 +1,22 @@
 package jobs
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"net"
+	"time"
 
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/metrics"
+	"jabberwocky238/console/notify"
+	"jabberwocky238/console/settings"
 )
 
+// certExpiryWarning 证书剩余有效期低于它时提醒用户（cert-manager 正常会在到期前 30 天续期）
+var certExpiryWarning = settings.NewDuration("domain.cert_expiry_warning", 14*24*time.Hour, 0, 60*24*time.Hour,
+	"Notify the owner when a custom domain certificate expires within this long; 0 disables")
+
 type domainCheckJob struct{}
 
 func NewDomainCheckJob() k8s.Job {
@@ -21,8 +30,8 @@ func init() {
 func (j *domainCheckJob) Type() k8s.JobType { return JobTypeDomainCheck }
 func (j *domainCheckJob) ID() string   { return "periodic" }
 
-func (j *domainCheckJob) Do() error {
-	domains, err := dblayer.ListAllSuccessDomains()
+func (j *domainCheckJob) Do(ctx context.Context) error {
+	domains, err := dblayer.ListAllSuccessDomains(ctx)
 	if err != nil {
 		return err
 	}
@@ -30,8 +39,8 @@ func (j *domainCheckJob) Do() error {
 	for _, cd := range domains {
 		records, err := net.LookupTXT(cd.TXTName)
 		if err != nil {
-			dblayer.UpdateCustomDomainStatus(cd.CDID, "error")
-			log.Printf("[domain-check] DNS lookup failed for %s: %v", cd.TXTName, err)
+			domainCheckFailed(ctx, cd, err.Error())
+			jobLog(j).Warn("DNS lookup failed", "domain", cd.Domain, "txt_name", cd.TXTName, "error", err)
 			continue
 		}
 		found := false
@@ -42,11 +51,76 @@ func (j *domainCheckJob) Do() error {
 			}
 		}
 		if !found {
-			dblayer.UpdateCustomDomainStatus(cd.CDID, "error")
-			log.Printf("[domain-check] TXT record missing for %s", cd.Domain)
+			domainCheckFailed(ctx, cd, "TXT record missing")
+			jobLog(j).Warn("TXT record missing", "domain", cd.Domain)
+			continue
 		}
+		metrics.DomainChecks.WithLabelValues("ok").Inc()
+		checkCertExpiry(ctx, cd)
 	}
 
-	log.Printf("[domain-check] checked %d domains", len(domains))
+	jobLog(j).Info("checked domains", "domains", len(domains))
 	return nil
 }
+
+// domainCheckFailed 标记域名失效并通知所属用户
+func domainCheckFailed(ctx context.Context, cd *dblayer.CustomDomain, msg string) {
+	metrics.DomainChecks.WithLabelValues("failed").Inc()
+	dblayer.UpdateCustomDomainStatus(ctx, cd.CDID, "error")
+	k8s.RecordDomainWarning(ctx, cd.CDID, k8s.EventReasonVerifyFailed, "Domain %s failed periodic check: %s", cd.Domain, msg)
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      cd.UserUID,
+		Kind:         dblayer.EventDomainVerify,
+		ResourceType: "domain",
+		ResourceID:   cd.CDID,
+		Status:       "error",
+		Message:      msg,
+	})
+	notify.Send(ctx, &dblayer.Notification{
+		UserUID:      cd.UserUID,
+		Kind:         dblayer.NotificationDomainFailed,
+		Severity:     dblayer.NotificationSeverityError,
+		Title:        fmt.Sprintf("Domain %s failed its periodic check", cd.Domain),
+		Message:      msg,
+		ResourceType: "domain",
+		ResourceID:   cd.CDID,
+	}, notify.Channels{Email: &notify.Mail{
+		Template: notify.MailDomainCheckFailed,
+		Data:     notify.DomainCheckData{Domain: cd.Domain, Reason: msg},
+	}})
+}
+
+// checkCertExpiry 证书快到期（续期失败）时提醒用户；按证书的 notAfter 去重，续期后的新证书会再提醒
+func checkCertExpiry(ctx context.Context, cd *dblayer.CustomDomain) {
+	warn := certExpiryWarning.Get()
+	if warn <= 0 {
+		return
+	}
+	notAfter, ok, err := k8s.DomainCertificateExpiry(ctx, cd.CDID)
+	if err != nil {
+		jobsLog.Warn("read certificate failed", "domain", cd.Domain, "error", err)
+		return
+	}
+	left := time.Until(notAfter)
+	if !ok || left > warn {
+		return
+	}
+	date := notAfter.UTC().Format("2006-01-02")
+	msg := fmt.Sprintf("The TLS certificate of %s expires on %s and has not been renewed yet.", cd.Domain, date)
+	if left <= 0 {
+		msg = fmt.Sprintf("The TLS certificate of %s expired on %s.", cd.Domain, date)
+	}
+	notify.Send(ctx, &dblayer.Notification{
+		UserUID:      cd.UserUID,
+		Kind:         dblayer.NotificationCertExpiring,
+		Severity:     dblayer.NotificationSeverityWarning,
+		Title:        fmt.Sprintf("Certificate for %s is expiring", cd.Domain),
+		Message:      msg,
+		ResourceType: "domain",
+		ResourceID:   cd.CDID,
+		DedupeKey:    fmt.Sprintf("cert:%s:%d", cd.CDID, notAfter.Unix()),
+	}, notify.Channels{Email: &notify.Mail{
+		Template: notify.MailCertificateExpiring,
+		Data:     notify.CertificateData{Domain: cd.Domain, NotAfter: date, Expired: left <= 0},
+	}})
+}
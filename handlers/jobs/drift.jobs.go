@@ -0,0 +1,228 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/metrics"
+)
+
+// DriftAuditInterval 库中期望状态与集群实际资源比对的间隔
+const DriftAuditInterval = 15 * time.Minute
+
+// DriftAutoHeal 比对后自动修复缺失和被改动的资源（DRIFT_AUTO_HEAL），多出来的资源只报告不删除
+var DriftAutoHeal bool
+
+// DriftReport 一次比对的结果
+type DriftReport struct {
+	StartedAt  time.Time             `json:"started_at"`
+	DurationMS int64                 `json:"duration_ms"`
+	AutoHeal   bool                  `json:"auto_heal"`
+	Summary    map[k8s.DriftKind]int `json:"summary"`
+	Items      []k8s.Drift           `json:"items"`
+	Errors     []string              `json:"errors,omitempty"` // 比对失败的来源，这些来源的结果不完整
+}
+
+var latestDrift atomic.Pointer[DriftReport]
+
+// LatestDriftReport 最近一次比对结果，还没跑过时为 nil
+func LatestDriftReport() *DriftReport {
+	return latestDrift.Load()
+}
+
+// driftAuditJob 比对 worker、自定义域名、RDB 在库中和集群中的状态
+type driftAuditJob struct{}
+
+func NewDriftAuditJob() k8s.Job {
+	return &driftAuditJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeDriftAudit, NewDriftAuditJob)
+}
+
+func (j *driftAuditJob) Type() k8s.JobType { return JobTypeDriftAudit }
+func (j *driftAuditJob) ID() string        { return "periodic" }
+
+func (j *driftAuditJob) Do(ctx context.Context) error {
+	if k8s.K8sClient == nil || k8s.DynamicClient == nil {
+		jobLog(j).Warn("k8s client not initialized, skip")
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	report := &DriftReport{StartedAt: time.Now(), AutoHeal: DriftAutoHeal, Items: []k8s.Drift{}}
+	collect := func(source string, drift []k8s.Drift, err error) {
+		report.Items = append(report.Items, drift...)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", source, err))
+			jobLog(j).Error("drift check failed", "source", source, "error", err)
+		}
+	}
+
+	versions := map[string]int{} // 修复缺失的 CR 时按 active 版本重新部署
+	workers, err := dblayer.ListDeployedWorkers(ctx)
+	if err == nil {
+		for _, w := range workers {
+			versions[w.WID+"/"+w.UserUID] = w.VersionID
+		}
+		drift, err := controller.WorkerDrift(ctx, workers)
+		collect(k8s.DriftSourceWorker, drift, err)
+	} else {
+		collect(k8s.DriftSourceWorker, nil, fmt.Errorf("list deployed workers: %w", err))
+	}
+
+	domains, err := dblayer.ListAllSuccessDomains(ctx)
+	if err == nil {
+		drift, err := k8s.DomainDrift(ctx, domains)
+		collect(k8s.DriftSourceDomain, drift, err)
+	} else {
+		collect(k8s.DriftSourceDomain, nil, fmt.Errorf("list domains: %w", err))
+	}
+
+	drift, err := rdbDrift(ctx)
+	collect(k8s.DriftSourceRDB, drift, err)
+
+	sort.SliceStable(report.Items, func(a, b int) bool {
+		x, y := report.Items[a], report.Items[b]
+		if x.Source != y.Source {
+			return x.Source < y.Source
+		}
+		if x.SourceID != y.SourceID {
+			return x.SourceID < y.SourceID
+		}
+		return x.Resource < y.Resource
+	})
+	if DriftAutoHeal {
+		healDrift(ctx, report.Items, versions)
+	}
+
+	metrics.DriftObjects.Reset()
+	report.Summary = map[k8s.DriftKind]int{k8s.DriftMissing: 0, k8s.DriftExtra: 0, k8s.DriftModified: 0}
+	for _, d := range report.Items {
+		report.Summary[d.Kind]++
+		metrics.DriftObjects.WithLabelValues(d.Source, string(d.Kind)).Inc()
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+	latestDrift.Store(report)
+
+	jobLog(j).Info("drift audit completed",
+		"missing", report.Summary[k8s.DriftMissing],
+		"extra", report.Summary[k8s.DriftExtra],
+		"modified", report.Summary[k8s.DriftModified],
+		"auto_heal", DriftAutoHeal)
+	return nil
+}
+
+// rdbDrift 比对 active 的 RDB 资源和用户库里的 schema
+func rdbDrift(ctx context.Context) ([]k8s.Drift, error) {
+	if k8s.RDBManager == nil {
+		return nil, fmt.Errorf("cockroachdb not available")
+	}
+	resources, err := dblayer.ListAllActiveCombinatorResources(ctx, "rdb")
+	if err != nil {
+		return nil, fmt.Errorf("list rdb resources: %w", err)
+	}
+	byUser := map[string]map[string]bool{}
+	for _, r := range resources {
+		if byUser[r.UserUID] == nil {
+			byUser[r.UserUID] = map[string]bool{}
+		}
+		byUser[r.UserUID][r.ResourceID] = true
+	}
+
+	var drift []k8s.Drift
+	var errs []error
+	for userUID, want := range byUser {
+		dbName := k8s.RDBManager.DatabaseName(userUID)
+		add := func(kind k8s.DriftKind, schemaID string) {
+			drift = append(drift, k8s.Drift{
+				Kind: kind, Source: k8s.DriftSourceRDB, SourceID: schemaID, OwnerID: userUID,
+				Resource: "Schema", Namespace: dbName, Name: "schema_" + schemaID,
+			})
+		}
+		schemas, err := k8s.RDBManager.ListSchemas(userUID)
+		if err != nil {
+			// 连不上用户库时跳过这个用户，不把它的 schema 都算成缺失
+			errs = append(errs, fmt.Errorf("list schemas of %s: %w", userUID, err))
+			continue
+		}
+		have := map[string]bool{}
+		for _, s := range schemas {
+			have[s] = true
+			if want[s] {
+				continue
+			}
+			// 创建中或失败的资源也可能已有 schema，只有库里没有记录的才算多余
+			if _, err := dblayer.GetCombinatorResource(ctx, userUID, "rdb", s); errors.Is(err, sql.ErrNoRows) {
+				add(k8s.DriftExtra, s)
+			}
+		}
+		for id := range want {
+			if !have[id] {
+				add(k8s.DriftMissing, id)
+			}
+		}
+	}
+	return drift, errors.Join(errs...)
+}
+
+// healDrift 按来源逐个修复缺失和被改动的资源，同一来源只修一次，结果写回每一项
+func healDrift(ctx context.Context, items []k8s.Drift, versions map[string]int) {
+	type target struct{ source, id, owner string }
+	var order []target
+	groups := map[target][]int{}
+	for i, d := range items {
+		if d.Kind == k8s.DriftExtra {
+			continue
+		}
+		t := target{d.Source, d.SourceID, d.OwnerID}
+		if _, ok := groups[t]; !ok {
+			order = append(order, t)
+		}
+		groups[t] = append(groups[t], i)
+	}
+
+	for _, t := range order {
+		var err error
+		switch t.source {
+		case k8s.DriftSourceWorker:
+			crDrifted := false
+			for _, i := range groups[t] {
+				crDrifted = crDrifted || items[i].Resource == "WorkerApp"
+			}
+			if crDrifted {
+				// CR 丢失或镜像不是 active 版本：按 active 版本重新部署
+				err = NewDeployWorkerJob(t.id, t.owner, versions[t.id+"/"+t.owner]).Do(ctx)
+			} else {
+				err = controller.EnsureWorker(ctx, t.id, t.owner)
+			}
+		case k8s.DriftSourceDomain:
+			err = k8s.EnsureDomain(ctx, t.id)
+		case k8s.DriftSourceRDB:
+			if err = k8s.RDBManager.InitUserRDB(t.owner); err == nil {
+				err = k8s.RDBManager.CreateSchema(t.owner, t.id)
+			}
+		}
+		for _, i := range groups[t] {
+			items[i].Healed = err == nil
+			if err != nil {
+				items[i].HealError = err.Error()
+			}
+		}
+		if err != nil {
+			jobsLog.Warn("heal drift failed", "job_type", JobTypeDriftAudit, "source", t.source, "id", t.id, "owner", t.owner, "error", err)
+			continue
+		}
+		jobsLog.Info("drift healed", "job_type", JobTypeDriftAudit, "source", t.source, "id", t.id, "owner", t.owner)
+	}
+}
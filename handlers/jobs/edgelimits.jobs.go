@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// syncWorkerEdgeLimitsJob 把 worker 的入口限制写到 WorkerApp CR，由 controller 生成 Middleware
+type syncWorkerEdgeLimitsJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func NewSyncWorkerEdgeLimitsJob(workerID, userUID string) k8s.Job {
+	return &syncWorkerEdgeLimitsJob{WorkerID: workerID, UserUID: userUID}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerSyncEdgeLimits, func() k8s.Job {
+		return &syncWorkerEdgeLimitsJob{}
+	})
+}
+
+func (j *syncWorkerEdgeLimitsJob) OwnerUID() string  { return j.UserUID }
+func (j *syncWorkerEdgeLimitsJob) Type() k8s.JobType { return JobTypeWorkerSyncEdgeLimits }
+func (j *syncWorkerEdgeLimitsJob) ID() string        { return j.WorkerID }
+
+func (j *syncWorkerEdgeLimitsJob) Do(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceWorker, j.WorkerID); !ok {
+		return err
+	}
+	// 以库里的设置为准，避免多次修改时乱序覆盖
+	l, err := dblayer.GetWorkerEdgeLimits(ctx, j.WorkerID)
+	if err != nil {
+		return fmt.Errorf("get worker edge limits: %w", err)
+	}
+	err = controller.SetWorkerAppEdgeLimits(ctx, k8s.DynamicClient, controller.WorkerName(j.WorkerID, j.UserUID), l)
+	// 还没部署过：首次部署时会从库里带上设置
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sync worker edge limits: %w", err)
+	}
+	return nil
+}
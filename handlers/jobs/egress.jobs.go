@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// syncWorkerEgressJob 把 worker 的出站规则写到 WorkerApp CR，由 controller 生成 NetworkPolicy
+type syncWorkerEgressJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func NewSyncWorkerEgressJob(workerID, userUID string) k8s.Job {
+	return &syncWorkerEgressJob{WorkerID: workerID, UserUID: userUID}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerSyncEgress, func() k8s.Job {
+		return &syncWorkerEgressJob{}
+	})
+}
+
+func (j *syncWorkerEgressJob) OwnerUID() string  { return j.UserUID }
+func (j *syncWorkerEgressJob) Type() k8s.JobType { return JobTypeWorkerSyncEgress }
+func (j *syncWorkerEgressJob) ID() string        { return j.WorkerID }
+
+func (j *syncWorkerEgressJob) Do(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceWorker, j.WorkerID); !ok {
+		return err
+	}
+	// 以库里的规则为准，避免多次修改时乱序覆盖
+	p, err := dblayer.GetWorkerEgress(ctx, j.WorkerID)
+	if err != nil {
+		return fmt.Errorf("get worker egress: %w", err)
+	}
+	err = controller.SetWorkerAppEgress(ctx, k8s.DynamicClient, controller.WorkerName(j.WorkerID, j.UserUID), p)
+	// 还没部署过：首次部署时会从库里带上规则
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sync worker egress: %w", err)
+	}
+	return nil
+}
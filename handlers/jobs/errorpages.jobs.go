@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// syncWorkerErrorPagesJob 把 worker 的维护开关和有哪些错误页写到 WorkerApp CR，由 controller 改路由；页面内容由 outer 直接从库里读
+type syncWorkerErrorPagesJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func NewSyncWorkerErrorPagesJob(workerID, userUID string) k8s.Job {
+	return &syncWorkerErrorPagesJob{WorkerID: workerID, UserUID: userUID}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerSyncErrorPages, func() k8s.Job {
+		return &syncWorkerErrorPagesJob{}
+	})
+}
+
+func (j *syncWorkerErrorPagesJob) OwnerUID() string  { return j.UserUID }
+func (j *syncWorkerErrorPagesJob) Type() k8s.JobType { return JobTypeWorkerSyncErrorPages }
+func (j *syncWorkerErrorPagesJob) ID() string        { return j.WorkerID }
+
+func (j *syncWorkerErrorPagesJob) Do(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceWorker, j.WorkerID); !ok {
+		return err
+	}
+	// 以库里的设置为准，避免多次修改时乱序覆盖
+	p, err := dblayer.GetWorkerErrorPages(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return fmt.Errorf("get worker error pages: %w", err)
+	}
+	err = controller.SetWorkerAppErrorPages(ctx, k8s.DynamicClient, controller.WorkerName(j.WorkerID, j.UserUID),
+		k8s.RouteErrorPages{Maintenance: p.Maintenance, Statuses: p.Statuses()})
+	// 还没部署过：首次部署时会从库里带上设置
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sync worker error pages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+// ownedJob 属于某个用户的 Job，完成事件会推送给该用户
+type ownedJob interface {
+	OwnerUID() string
+}
+
+// PublishJobEvent Processor 完成回调：把任务结果作为事件推送给任务所属用户
+func PublishJobEvent(job k8s.Job, err error) {
+	// 任务的 ctx 可能已随停机取消，事件照常发出
+	ctx := context.Background()
+	owned, ok := job.(ownedJob)
+	if !ok {
+		return
+	}
+	ev := dblayer.Event{
+		UserUID:      owned.OwnerUID(),
+		Kind:         dblayer.EventJobFinished,
+		ResourceType: string(job.Type()),
+		ResourceID:   job.ID(),
+		Status:       "success",
+	}
+	if err != nil {
+		ev.Status = "error"
+		ev.Message = err.Error()
+	}
+	dblayer.PublishEvent(ctx, ev)
+}
+
+// setResourceStatus 更新 combinator 资源状态并推送事件
+func setResourceStatus(ctx context.Context, userUID, resourceType, resourceID, status, msg string) {
+	dblayer.UpdateCombinatorResourceStatus(ctx, userUID, resourceType, resourceID, status, msg)
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      userUID,
+		Kind:         dblayer.EventResourceStatus,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Status:       status,
+		Message:      msg,
+	})
+}
+
+// publishDeployEvent 推送 worker 部署进度
+func publishDeployEvent(ctx context.Context, userUID, workerID, status, msg string) {
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      userUID,
+		Kind:         dblayer.EventWorkerDeploy,
+		ResourceType: "worker",
+		ResourceID:   workerID,
+		Status:       status,
+		Message:      msg,
+	})
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"jabberwocky238/console/k8s"
+)
+
+// maxJobFailures 内存中保留的最近失败任务数
+const maxJobFailures = 200
+
+// JobFailure 一次失败的任务执行
+type JobFailure struct {
+	Type    k8s.JobType `json:"type"`
+	ID      string      `json:"id"`
+	UserUID string      `json:"user_uid,omitempty"`
+	Error   string      `json:"error"`
+	Time    time.Time   `json:"time"`
+}
+
+var (
+	failuresMu  sync.Mutex
+	jobFailures []JobFailure // 环形缓冲，next 为下一个写入位置
+	nextFailure int
+)
+
+// RecordJobFailure Processor.OnFinish 的回调，记录失败的任务供运维面板查看；重启后清空
+func RecordJobFailure(job k8s.Job, err error) {
+	if err == nil {
+		return
+	}
+	f := JobFailure{Type: job.Type(), ID: job.ID(), Error: err.Error(), Time: time.Now()}
+	if owned, ok := job.(ownedJob); ok {
+		f.UserUID = owned.OwnerUID()
+	}
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	if len(jobFailures) < maxJobFailures {
+		jobFailures = append(jobFailures, f)
+	} else {
+		jobFailures[nextFailure] = f
+	}
+	nextFailure = (nextFailure + 1) % maxJobFailures
+}
+
+// RecentJobFailures 最近的 limit 个失败任务，新的在前
+func RecentJobFailures(limit int) []JobFailure {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	n := min(limit, len(jobFailures))
+	out := make([]JobFailure, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, jobFailures[(nextFailure-i+len(jobFailures))%len(jobFailures)])
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s/controller"
+)
+
+// GitHubAPI GitHub REST API 地址（GitHub Enterprise 时改为 https://<host>/api/v3）
+var GitHubAPI = "https://api.github.com"
+
+// CommitStatusContext 部署结果在 commit status 中的 context
+const CommitStatusContext = "console/deploy"
+
+var githubClient = &http.Client{Timeout: 10 * time.Second}
+
+// githubPost 用用户的 token POST JSON 到 GitHub API 的 path
+func githubPost(ctx context.Context, token, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, GitHubAPI+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "console")
+
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("github returned %s", resp.Status)
+	}
+	return nil
+}
+
+// commitStatus 把部署结果写到绑定仓库的提交上；worker 没有绑定、没有 token 或版本没有 commit 时为 nil
+type commitStatus struct {
+	repo, token, sha, targetURL string
+}
+
+func deployCommitStatus(ctx context.Context, workerID, userUID string, versionID int) *commitStatus {
+	b, err := dblayer.GetGitHubBinding(ctx, workerID)
+	if err != nil || b.GitHubToken == "" {
+		return nil
+	}
+	v, err := dblayer.GetDeployVersion(ctx, versionID)
+	if err != nil || v.CommitSHA == "" {
+		return nil
+	}
+	return &commitStatus{
+		repo:      b.Repo,
+		token:     b.GitHubToken,
+		sha:       v.CommitSHA,
+		targetURL: "https://" + controller.WorkerHost(workerID, userUID),
+	}
+}
+
+// post 写入 state（pending/success/failure/error），失败只记日志，不影响部署
+func (s *commitStatus) post(ctx context.Context, state, description string) {
+	if s == nil {
+		return
+	}
+	if len(description) > 140 { // GitHub 的上限
+		description = description[:137] + "..."
+	}
+	err := githubPost(ctx, s.token, fmt.Sprintf("/repos/%s/statuses/%s", s.repo, s.sha), map[string]string{
+		"state":       state,
+		"target_url":  s.targetURL,
+		"description": description,
+		"context":     CommitStatusContext,
+	})
+	if err != nil {
+		jobsLog.Warn("post commit status failed", "repo", s.repo, "sha", s.sha, "state", state, "error", err)
+	}
+}
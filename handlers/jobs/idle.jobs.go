@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/notify"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// IdleCheckInterval 空闲回收检查的间隔
+	IdleCheckInterval = time.Hour
+
+	// WorkerStatusIdle 因长期没有流量被暂停的 worker，POST /api/worker/:id/unpause 恢复
+	WorkerStatusIdle = "idle"
+)
+
+// IdlePolicy free 套餐 worker 的空闲回收：After 内没有入站流量先发警告邮件，
+// 警告后 PauseGrace 内仍没有流量则暂停（缩到 0），暂停后 DeleteGrace 内没有恢复则删除
+type IdlePolicy struct {
+	Enabled     bool          // IDLE_REAPER
+	After       time.Duration // IDLE_AFTER
+	PauseGrace  time.Duration // IDLE_PAUSE_GRACE
+	DeleteGrace time.Duration // IDLE_DELETE_GRACE
+}
+
+// IdleReaper 空闲回收策略，默认关闭；流量来自 Traefik 的 Prometheus 指标，只在 traefik ingress 下生效
+var IdleReaper = IdlePolicy{
+	After:       7 * 24 * time.Hour,
+	PauseGrace:  3 * 24 * time.Hour,
+	DeleteGrace: 30 * 24 * time.Hour,
+}
+
+type idleReaperJob struct{}
+
+func NewIdleReaperJob() k8s.Job {
+	return &idleReaperJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeIdleReaper, NewIdleReaperJob)
+}
+
+func (j *idleReaperJob) Type() k8s.JobType { return JobTypeIdleReaper }
+func (j *idleReaperJob) ID() string        { return "periodic" }
+
+var (
+	idleMu        sync.Mutex
+	lastIdleCheck time.Time
+)
+
+func (j *idleReaperJob) Do(ctx context.Context) error {
+	if !IdleReaper.Enabled {
+		return nil
+	}
+	if k8s.Ingress.Name() != k8s.IngressProviderTraefik || k8s.PrometheusEndpoint == "" {
+		jobLog(j).Warn("idle reaper needs the traefik ingress provider and PROMETHEUS_URL, skipped")
+		return nil
+	}
+	idleMu.Lock()
+	defer idleMu.Unlock()
+
+	// 查询窗口覆盖上次检查以来的时间，重启后按一个间隔算
+	now := time.Now()
+	window := now.Sub(lastIdleCheck)
+	if lastIdleCheck.IsZero() || window < IdleCheckInterval {
+		window = IdleCheckInterval
+	}
+	window = min(window, 24*time.Hour)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	// 查不到流量时不能当作空闲，整轮跳过
+	requests, err := k8s.WorkerRequests(ctx, window)
+	if err != nil {
+		return fmt.Errorf("query worker requests: %w", err)
+	}
+	workers, err := dblayer.ListIdleCandidates(ctx, WorkerStatusIdle)
+	if err != nil {
+		return fmt.Errorf("list idle candidates: %w", err)
+	}
+
+	var errs []error
+	for _, w := range workers {
+		if err := reapIdleWorker(ctx, w, requests[controller.WorkerName(w.WID, w.UserUID)] > 0, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", w.WID, err))
+		}
+	}
+	lastIdleCheck = now
+	return errors.Join(errs...)
+}
+
+// reapIdleWorker 按空闲时长推进一个 worker：警告、暂停、删除
+func reapIdleWorker(ctx context.Context, w dblayer.IdleWorker, hadTraffic bool, now time.Time) error {
+	if w.Status == WorkerStatusIdle {
+		// 已缩到 0，不会再有流量；只等恢复或删除
+		if w.PausedAt == nil || now.Sub(*w.PausedAt) < IdleReaper.DeleteGrace {
+			return nil
+		}
+		if err := controller.DeleteWorkerAppCR(ctx, k8s.DynamicClient, controller.WorkerName(w.WID, w.UserUID)); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err := dblayer.DeleteWorkerByOwner(ctx, dblayer.DB, w.WID, w.UserUID); err != nil && err != dblayer.ErrNotFound {
+			return err
+		}
+		notifyIdle(ctx, w, notify.WorkerIdleData{Status: "deleted", Idle: IdleReaper.DeleteGrace.String()})
+		return nil
+	}
+
+	// 开始跟踪、有流量，或暂停后被用户用其他方式恢复了，都从现在重新计时
+	if hadTraffic || w.LastTrafficAt == nil || w.PausedAt != nil {
+		return dblayer.TouchWorkerTraffic(ctx, w.WID, now)
+	}
+
+	switch {
+	case w.WarnedAt != nil && now.Sub(*w.WarnedAt) >= IdleReaper.PauseGrace:
+		if err := controller.SetWorkerAppPaused(ctx, k8s.DynamicClient, controller.WorkerName(w.WID, w.UserUID), true); err != nil {
+			return err
+		}
+		if err := dblayer.UpdateWorkerStatus(ctx, w.WID, WorkerStatusIdle); err != nil {
+			return err
+		}
+		if err := dblayer.MarkWorkerIdlePaused(ctx, w.WID, now); err != nil {
+			return err
+		}
+		notifyIdle(ctx, w, notify.WorkerIdleData{Status: WorkerStatusIdle,
+			Idle: now.Sub(*w.LastTrafficAt).Round(time.Hour).String(), Date: now.Add(IdleReaper.DeleteGrace).UTC().Format("2006-01-02")})
+	case w.WarnedAt == nil && now.Sub(*w.LastTrafficAt) >= IdleReaper.After:
+		if err := dblayer.MarkWorkerIdleWarned(ctx, w.WID, now); err != nil {
+			return err
+		}
+		notifyIdle(ctx, w, notify.WorkerIdleData{Status: "warned",
+			Idle: now.Sub(*w.LastTrafficAt).Round(time.Hour).String(), Date: now.Add(IdleReaper.PauseGrace).UTC().Format("2006-01-02")})
+	}
+	return nil
+}
+
+// notifyIdle 推送事件并发邮件（模板 worker_idle），邮件失败只记日志
+func notifyIdle(ctx context.Context, w dblayer.IdleWorker, d notify.WorkerIdleData) {
+	d.WorkerID = w.WID
+	var msg string
+	switch d.Status {
+	case "warned":
+		msg = fmt.Sprintf("worker %s has had no traffic for %s and will be paused on %s unless it receives requests", w.WID, d.Idle, d.Date)
+	case WorkerStatusIdle:
+		msg = fmt.Sprintf("worker %s was paused after %s without traffic and will be deleted on %s unless you unpause it", w.WID, d.Idle, d.Date)
+	default:
+		msg = fmt.Sprintf("worker %s was deleted after being paused for %s without traffic", w.WID, d.Idle)
+	}
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      w.UserUID,
+		Kind:         dblayer.EventWorkerIdle,
+		ResourceType: "worker",
+		ResourceID:   w.WID,
+		Status:       d.Status,
+		Message:      msg,
+	})
+	if err := notify.SendMail(ctx, w.UserUID, notify.Mail{Template: notify.MailWorkerIdle, Data: d}); err != nil {
+		jobsLog.Warn("idle reaper email failed", "worker_id", w.WID, "error", err)
+	}
+}
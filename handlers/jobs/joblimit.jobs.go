@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"sync"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+// 租户任务并发上限：管理员可以限制一个租户某类任务（或全部任务）同时执行的数量，例如同时最多 2 个部署，
+// 避免一个租户的自动化占满 controller 的吞吐。上限存在 tenant_job_limits，随配置变更通知在所有实例上重新加载；
+// 每个 inner 实例各自计数，超过上限的任务在 Processor 里等该租户的其他任务结束后再开始
+
+// tenantGate k8s.Gate 的实现，只限制属于用户的任务（ownedJob）
+type tenantGate struct {
+	mu      sync.Mutex
+	limits  map[string]map[k8s.JobType]int // uid -> 类型（dblayer.JobLimitAll 为全部）-> 上限
+	running map[string]map[k8s.JobType]int // uid -> 类型 -> 本实例上正在执行的数量
+}
+
+var jobGate = &tenantGate{
+	limits:  map[string]map[k8s.JobType]int{},
+	running: map[string]map[k8s.JobType]int{},
+}
+
+// EnableJobLimits 在 proc 上按租户限制并发；须在 proc.Start 之前调用
+func EnableJobLimits(proc *k8s.Processor) {
+	proc.SetGate(jobGate)
+}
+
+// LoadJobLimits 整体替换上限；已经在执行的任务不受影响，只影响之后开始的
+func LoadJobLimits(limits []*dblayer.TenantJobLimit) {
+	byUser := map[string]map[k8s.JobType]int{}
+	for _, l := range limits {
+		if byUser[l.UserUID] == nil {
+			byUser[l.UserUID] = map[k8s.JobType]int{}
+		}
+		byUser[l.UserUID][k8s.JobType(l.JobType)] = l.MaxRunning
+	}
+	jobGate.mu.Lock()
+	jobGate.limits = byUser
+	jobGate.mu.Unlock()
+}
+
+// RunningJobs 用户在本实例上按类型正在执行的任务数
+func RunningJobs(userUID string) map[string]int {
+	jobGate.mu.Lock()
+	defer jobGate.mu.Unlock()
+	out := map[string]int{}
+	for typ, n := range jobGate.running[userUID] {
+		out[string(typ)] = n
+	}
+	return out
+}
+
+// IsTenantJobType typ 是否是属于用户、能设置上限的任务类型
+func IsTenantJobType(typ k8s.JobType) bool {
+	build, ok := globalFactory.objectBuilders[typ]
+	if !ok {
+		return false
+	}
+	_, ok = build().(ownedJob)
+	return ok
+}
+
+func (g *tenantGate) Admit(job k8s.Job) bool {
+	owned, ok := job.(ownedJob)
+	if !ok || owned.OwnerUID() == "" {
+		return true
+	}
+	uid, typ := owned.OwnerUID(), job.Type()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	running := g.running[uid]
+	if limits := g.limits[uid]; limits != nil {
+		if max, ok := limits[typ]; ok && running[typ] >= max {
+			return false
+		}
+		if max, ok := limits[dblayer.JobLimitAll]; ok {
+			total := 0
+			for _, n := range running {
+				total += n
+			}
+			if total >= max {
+				return false
+			}
+		}
+	}
+	if running == nil {
+		running = map[k8s.JobType]int{}
+		g.running[uid] = running
+	}
+	running[typ]++
+	return true
+}
+
+func (g *tenantGate) Done(job k8s.Job) {
+	owned, ok := job.(ownedJob)
+	if !ok || owned.OwnerUID() == "" {
+		return
+	}
+	uid, typ := owned.OwnerUID(), job.Type()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	running := g.running[uid]
+	if running == nil {
+		return
+	}
+	if running[typ]--; running[typ] <= 0 {
+		delete(running, typ)
+	}
+	if len(running) == 0 {
+		delete(g.running, uid)
+	}
+}
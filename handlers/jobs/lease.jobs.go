@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/metrics"
+	"jabberwocky238/console/settings"
+)
+
+// 任务租约：属于用户的任务开始时在 job_leases 登记，执行期间每 JobLeaseHeartbeat 续一次，结束时删除。
+// 实例崩溃、任务卡住超过 jobs.max_run_time 或 panic 时租约过期，任一实例上的 job.reap_leases 把它重新提交；
+// 超过 jobs.max_attempts 次后放弃，并把任务留在进行中的资源状态改回来（abandonableJob）
+
+var (
+	jobMaxRunTime = settings.NewDuration("jobs.max_run_time", 30*time.Minute, time.Minute, 24*time.Hour,
+		"Run time after which a job is cancelled and its lease is left to expire, so that it is requeued")
+	jobMaxAttempts = settings.NewInt("jobs.max_attempts", 3, 1, 20,
+		"Runs of a job whose lease expired (hung, panicked or lost its instance) before it is abandoned")
+)
+
+const (
+	// JobLeaseHeartbeat 执行中任务的续约间隔
+	JobLeaseHeartbeat = 30 * time.Second
+	// jobLeaseTTL 每次续约延长的时间，错过几次心跳才算过期
+	jobLeaseTTL = 2 * time.Minute
+	// jobRequeueGrace 重新提交后等待任务开始的时间，队列积压时也够用；过了还没开始就再次回收
+	jobRequeueGrace = 10 * time.Minute
+	// LeaseReapInterval job.reap_leases 的间隔
+	LeaseReapInterval = time.Minute
+	// abandonedLeaseRetention 放弃的任务在 GET /admin/jobs/leases 里保留的时长
+	abandonedLeaseRetention = 7 * 24 * time.Hour
+	// maxLeaseReap 每次回收的任务数上限
+	maxLeaseReap = 100
+)
+
+// leaseOwner 本实例在 job_leases.owner 里的名字
+var leaseOwner = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}()
+
+// leaseProc 回收的任务重新提交到这里，由 EnableJobLeases 设置
+var leaseProc *k8s.Processor
+
+// requeuedLeases 本实例重新提交、还没开始的任务 -> 它们的租约行。按任务对象区分：
+// 同一类型同一 ID 的任务可以同时有几个在执行
+var (
+	requeuedMu     sync.Mutex
+	requeuedLeases = map[k8s.Job]int64{}
+)
+
+// abandonableJob 放弃时把任务留在进行中的资源状态改回来，reason 写进状态说明
+type abandonableJob interface {
+	Abandon(ctx context.Context, reason string)
+}
+
+// EnableJobLeases 给 proc 上执行的任务加租约，回收的任务也提交给 proc；须在 proc.Start 之前调用
+func EnableJobLeases(proc *k8s.Processor) {
+	leaseProc = proc
+	proc.SetLeaser(jobLeaser{}, JobLeaseHeartbeat)
+}
+
+type jobLeaser struct{}
+
+// Acquire 只给能重新创建的用户任务加租约；周期任务下一轮自然会再跑。登记失败时照常执行，只是没有租约
+func (jobLeaser) Acquire(ctx context.Context, job k8s.Job) k8s.Lease {
+	owned, ok := job.(ownedJob)
+	if !ok {
+		return nil
+	}
+	if _, ok := globalFactory.objectBuilders[job.Type()]; !ok {
+		return nil
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil
+	}
+	until := time.Now().Add(jobLeaseTTL)
+	requeuedMu.Lock()
+	id, requeued := requeuedLeases[job]
+	delete(requeuedLeases, job)
+	requeuedMu.Unlock()
+	if requeued {
+		row, err := dblayer.ResumeJobLease(ctx, id, leaseOwner, until)
+		if err == nil {
+			return &jobLease{row: row, started: time.Now()}
+		}
+		// 又被回收了的话由回收它的实例再提交一次；这次照常执行，换一行新的租约
+		if err != dblayer.ErrNotFound {
+			jobLog(job).Warn("resume job lease failed", "lease_id", id, "error", err)
+		}
+	}
+	row := &dblayer.JobLease{
+		JobType: string(job.Type()), JobID: job.ID(), UserUID: owned.OwnerUID(), Payload: string(data), Owner: leaseOwner,
+	}
+	if err := dblayer.AcquireJobLease(ctx, row, until); err != nil {
+		jobLog(job).Warn("acquire job lease failed, running without one", "error", err)
+		return nil
+	}
+	return &jobLease{row: row, started: time.Now()}
+}
+
+type jobLease struct {
+	row     *dblayer.JobLease
+	started time.Time
+}
+
+func (l *jobLease) Renew(ctx context.Context) error {
+	if max := jobMaxRunTime.Get(); time.Since(l.started) > max {
+		return fmt.Errorf("running for more than jobs.max_run_time (%s)", max)
+	}
+	err := dblayer.RenewJobLease(ctx, l.row.ID, leaseOwner, time.Now().Add(jobLeaseTTL))
+	if err == dblayer.ErrNotFound {
+		return errors.New("lease taken over by another instance")
+	}
+	if err != nil {
+		// 数据库暂时不可用不算丢了租约，下次心跳再试
+		jobsLog.Warn("renew job lease failed", "job_type", l.row.JobType, "job_id", l.row.JobID, "error", err)
+	}
+	return nil
+}
+
+// Release 正常结束（包括普通的失败，任务自己写了状态）删除租约；panic、超时或随停机中断的让租约立即过期，交给回收
+func (l *jobLease) Release(ctx context.Context, err error) {
+	var panicked *k8s.PanicError
+	if errors.As(err, &panicked) || errors.Is(err, k8s.ErrJobTimedOut) || errors.Is(err, context.Canceled) {
+		err = dblayer.ExpireJobLease(ctx, l.row.ID, leaseOwner, err.Error())
+	} else {
+		err = dblayer.ReleaseJobLease(ctx, l.row.ID, leaseOwner)
+	}
+	if err != nil {
+		jobsLog.Error("release job lease failed", "job_type", l.row.JobType, "job_id", l.row.JobID, "error", err)
+	}
+}
+
+// leaseReapJob 周期任务：重新提交租约过期的任务，放弃超过 jobs.max_attempts 的
+type leaseReapJob struct{}
+
+func NewLeaseReapJob() k8s.Job {
+	return &leaseReapJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeLeaseReap, NewLeaseReapJob)
+}
+
+func (j *leaseReapJob) Type() k8s.JobType { return JobTypeLeaseReap }
+func (j *leaseReapJob) ID() string        { return "periodic" }
+
+func (j *leaseReapJob) Do(ctx context.Context) error {
+	if leaseProc == nil {
+		return nil
+	}
+	leases, err := dblayer.ReapJobLeases(ctx, leaseOwner, jobMaxAttempts.Get(), time.Now().Add(jobRequeueGrace), maxLeaseReap)
+	if err != nil {
+		return fmt.Errorf("reap job leases: %w", err)
+	}
+	for _, l := range leases {
+		job, err := CreateJob(k8s.JobType(l.JobType), []byte(l.Payload))
+		if err != nil {
+			jobsLog.Error("stuck job cannot be recreated", "job_type", l.JobType, "job_id", l.JobID, "error", err)
+			continue
+		}
+		if l.Status == dblayer.JobLeaseAbandoned {
+			metrics.JobsAbandoned.WithLabelValues(l.JobType).Inc()
+			jobLog(job).Error("stuck job abandoned", "attempts", l.Attempts, "last_error", l.LastError)
+			if a, ok := job.(abandonableJob); ok {
+				a.Abandon(ctx, fmt.Sprintf("gave up after %d attempts: %s", l.Attempts, l.LastError))
+			}
+			continue
+		}
+		metrics.JobsRequeued.WithLabelValues(l.JobType).Inc()
+		jobLog(job).Warn("stuck job requeued", "attempt", l.Attempts, "last_error", l.LastError)
+		requeuedMu.Lock()
+		requeuedLeases[job] = l.ID
+		requeuedMu.Unlock()
+		// Submit 在队列满时阻塞，而本任务就在队列的消费者里执行
+		go func() {
+			if err := leaseProc.Submit(job); err != nil {
+				// 正在停机，租约行过期后由其他实例再次回收
+				requeuedMu.Lock()
+				delete(requeuedLeases, job)
+				requeuedMu.Unlock()
+			}
+		}()
+	}
+
+	if n, err := dblayer.PruneJobLeases(ctx, time.Now().Add(-abandonedLeaseRetention)); err != nil {
+		jobsLog.Warn("prune abandoned jobs failed", "error", err)
+	} else if n > 0 {
+		jobsLog.Info("pruned abandoned jobs", "deleted", n)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/notify"
+)
+
+const (
+	// MailDomainCheckInterval 发信域名 DNS 记录的检查间隔：待验证的域名等用户加记录，已验证的确认记录还在
+	MailDomainCheckInterval = 15 * time.Minute
+	// MailLogRetention 发送日志的保留期
+	MailLogRetention = 30 * 24 * time.Hour
+)
+
+// --- MailDomainCheckJob ---
+
+// mailDomainCheckJob 检查所有发信域名的归属、SPF、DKIM 记录；验证通过或记录丢失时通知用户
+type mailDomainCheckJob struct{}
+
+func NewMailDomainCheckJob() k8s.Job {
+	return &mailDomainCheckJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeMailCheckDomains, NewMailDomainCheckJob)
+}
+
+func (j *mailDomainCheckJob) Type() k8s.JobType { return JobTypeMailCheckDomains }
+func (j *mailDomainCheckJob) ID() string        { return "periodic" }
+
+func (j *mailDomainCheckJob) Do(ctx context.Context) error {
+	if k8s.MailRelay == nil {
+		return nil
+	}
+	domains, err := dblayer.ListAllMailDomains(ctx)
+	if err != nil {
+		return err
+	}
+	changed := 0
+	for _, d := range domains {
+		before := d.Status
+		k8s.MailRelay.CheckMailDomain(d)
+		if err := dblayer.SetMailDomainCheck(ctx, d); err != nil {
+			jobLog(j).Error("save mail domain check failed", "domain", d.Domain, "error", err)
+			continue
+		}
+		if d.Status == before {
+			continue
+		}
+		changed++
+		switch d.Status {
+		case dblayer.MailDomainVerified:
+			notifyMailDomain(ctx, d, dblayer.NotificationDomainVerified, dblayer.NotificationSeverityInfo,
+				fmt.Sprintf("Mail domain %s verified", d.Domain), "Mail from this domain can now be sent through the SMTP relay.")
+		case dblayer.MailDomainError:
+			notifyMailDomain(ctx, d, dblayer.NotificationDomainFailed, dblayer.NotificationSeverityError,
+				fmt.Sprintf("Mail domain %s failed its DNS check", d.Domain), d.Msg+". Mail from this domain is refused until the records are back.")
+		}
+	}
+	jobLog(j).Info("checked mail domains", "domains", len(domains), "changed", changed)
+	return nil
+}
+
+// notifyMailDomain 通知发信域名的所有者
+func notifyMailDomain(ctx context.Context, d *dblayer.MailDomain, kind, severity, title, message string) {
+	notify.Send(ctx, &dblayer.Notification{
+		UserUID:      d.UserUID,
+		Kind:         kind,
+		Severity:     severity,
+		Title:        title,
+		Message:      message,
+		ResourceType: "mail_domain",
+		ResourceID:   d.MDID,
+	}, notify.Channels{})
+}
+
+// --- MailPruneJob ---
+
+// mailPruneJob 清理超过保留期的发送日志
+type mailPruneJob struct{}
+
+func NewMailPruneJob() k8s.Job {
+	return &mailPruneJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeMailPrune, NewMailPruneJob)
+}
+
+func (j *mailPruneJob) Type() k8s.JobType { return JobTypeMailPrune }
+func (j *mailPruneJob) ID() string        { return "periodic" }
+
+func (j *mailPruneJob) Do(ctx context.Context) error {
+	n, err := dblayer.PruneMailMessages(ctx, time.Now().UTC().Add(-MailLogRetention))
+	if err != nil {
+		return err
+	}
+	jobLog(j).Info("pruned mail messages", "messages", n)
+	return nil
+}
@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/notify"
+	"jabberwocky238/console/settings"
+)
+
+const (
+	QuotaCheckInterval    = 24 * time.Hour
+	quotaCheckPageSize    = 500
+	notificationRetention = 90 * 24 * time.Hour
+)
+
+// quotaWarnPercent 用量达到套餐上限的这个百分比时提醒用户
+var quotaWarnPercent = settings.NewInt("quota.warn_percent", 80, 1, 100,
+	"Notify users when their workers, custom domains or RDB storage reach this percent of the plan limit")
+
+// quotaCheckJob 每天检查所有用户的用量，接近套餐上限时发通知。
+// 同一资源、同一上限每月只提醒一次，多个 inner 实例重复检查也靠去重只发一条
+type quotaCheckJob struct{}
+
+func NewQuotaCheckJob() k8s.Job {
+	return &quotaCheckJob{}
+}
+
+// notificationPruneJob 清理超过保留期的通知
+type notificationPruneJob struct{}
+
+func NewNotificationPruneJob() k8s.Job {
+	return &notificationPruneJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeQuotaCheck, NewQuotaCheckJob)
+	RegisterJobType(JobTypeNotificationPrune, NewNotificationPruneJob)
+}
+
+func (j *quotaCheckJob) Type() k8s.JobType { return JobTypeQuotaCheck }
+func (j *quotaCheckJob) ID() string        { return "periodic" }
+
+func (j *quotaCheckJob) Do(ctx context.Context) error {
+	percent := int64(quotaWarnPercent.Get())
+	warned := 0
+	for offset := 0; ; offset += quotaCheckPageSize {
+		tenants, err := dblayer.ListTenantOverviews(ctx, "created_at", quotaCheckPageSize, offset)
+		if err != nil {
+			return err
+		}
+		for _, t := range tenants {
+			limits, err := dblayer.GetUserLimits(ctx, t.UserUID)
+			if err != nil {
+				jobLog(j).Warn("get user limits failed", "user_id", t.UserUID, "error", err)
+				continue
+			}
+			if warnQuota(ctx, t.UserUID, "workers", int64(t.Workers), int64(limits.MaxWorkers), percent, limits.Plan) {
+				warned++
+			}
+			if warnQuota(ctx, t.UserUID, "custom domains", int64(t.CustomDomains), int64(limits.MaxCustomDomains), percent, limits.Plan) {
+				warned++
+			}
+			if t.RDBs > 0 && k8s.RDBManager != nil {
+				size, err := k8s.RDBManager.DatabaseSize(t.UserUID)
+				if err != nil {
+					jobLog(j).Warn("measure RDB size failed", "user_id", t.UserUID, "error", err)
+				} else if warnQuota(ctx, t.UserUID, "RDB storage", size, limits.MaxRDBBytes, percent, limits.Plan) {
+					warned++
+				}
+			}
+			if size, err := dblayer.SumBucketBytes(ctx, t.UserUID); err != nil {
+				jobLog(j).Warn("sum bucket size failed", "user_id", t.UserUID, "error", err)
+			} else if warnQuota(ctx, t.UserUID, "bucket storage", size, limits.MaxBucketBytes, percent, limits.Plan) {
+				warned++
+			}
+		}
+		if len(tenants) < quotaCheckPageSize {
+			break
+		}
+	}
+	jobLog(j).Info("checked quotas", "warnings", warned)
+	return nil
+}
+
+// warnQuota used 达到 limit 的 percent% 时通知用户，返回是否发出了新通知
+func warnQuota(ctx context.Context, uid, resource string, used, limit, percent int64, plan string) bool {
+	if limit <= 0 || used*100 < limit*percent {
+		return false
+	}
+	data := notify.QuotaData{
+		Resource: resource,
+		Percent:  min(used*100/limit, 100),
+		Used:     quotaAmount(resource, used),
+		Limit:    quotaAmount(resource, limit),
+		Plan:     plan,
+		Reached:  used >= limit,
+	}
+	title := fmt.Sprintf("You are using %d%% of your %s quota", data.Percent, resource)
+	msg := fmt.Sprintf("Plan %s allows %s %s; you are using %s.", plan, data.Limit, resource, data.Used)
+	severity := dblayer.NotificationSeverityWarning
+	if data.Reached {
+		title = fmt.Sprintf("You have reached your %s quota", resource)
+		msg += " New ones are rejected until you free some up or upgrade."
+		severity = dblayer.NotificationSeverityError
+	}
+	return notify.Send(ctx, &dblayer.Notification{
+		UserUID:      uid,
+		Kind:         dblayer.NotificationQuotaWarning,
+		Severity:     severity,
+		Title:        title,
+		Message:      msg,
+		ResourceType: "account",
+		ResourceID:   uid,
+		DedupeKey:    fmt.Sprintf("quota:%s:%d:%s:%s", resource, limit, severity, time.Now().UTC().Format("2006-01")),
+	}, notify.Channels{Email: &notify.Mail{Template: notify.MailQuotaWarning, Data: data}})
+}
+
+func quotaAmount(resource string, n int64) string {
+	if strings.HasSuffix(resource, " storage") {
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+func (j *notificationPruneJob) Type() k8s.JobType { return JobTypeNotificationPrune }
+func (j *notificationPruneJob) ID() string        { return "periodic" }
+
+func (j *notificationPruneJob) Do(ctx context.Context) error {
+	n, err := dblayer.PruneNotifications(ctx, time.Now().Add(-notificationRetention))
+	if err != nil {
+		return err
+	}
+	jobLog(j).Info("pruned notifications", "notifications", n)
+	return nil
+}
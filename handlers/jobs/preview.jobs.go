@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+// previewCommentJob 把预览地址评论到 PR 上；token 在执行时从预览配置读取，不进任务表。
+// 没有配置 token 或配置已删除时跳过
+type previewCommentJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+	PRNumber int    `json:"pr_number"`
+	Body     string `json:"body"`
+}
+
+func NewPreviewCommentJob(workerID, userUID string, prNumber int, body string) *previewCommentJob {
+	return &previewCommentJob{
+		WorkerID: workerID,
+		UserUID:  userUID,
+		PRNumber: prNumber,
+		Body:     body,
+	}
+}
+
+func init() {
+	RegisterJobType(JobTypePreviewComment, func() k8s.Job {
+		return &previewCommentJob{}
+	})
+}
+
+func (j *previewCommentJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *previewCommentJob) Type() k8s.JobType {
+	return JobTypePreviewComment
+}
+
+func (j *previewCommentJob) ID() string {
+	return j.WorkerID + "#" + strconv.Itoa(j.PRNumber)
+}
+
+func (j *previewCommentJob) Do(ctx context.Context) error {
+	cfg, err := dblayer.GetPreviewConfig(ctx, j.WorkerID)
+	if err == dblayer.ErrNotFound || (err == nil && cfg.GitHubToken == "") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get preview config: %w", err)
+	}
+
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", cfg.Repo, j.PRNumber)
+	if err := githubPost(ctx, cfg.GitHubToken, path, map[string]string{"body": j.Body}); err != nil {
+		return fmt.Errorf("comment on pull request: %w", err)
+	}
+	jobLog(j).Info("preview comment posted", "repo", cfg.Repo)
+	return nil
+}
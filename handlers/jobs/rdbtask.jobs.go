@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/notify"
+)
+
+const (
+	// RDBTaskTick 定时 SQL 任务的认领间隔，也是任务实际开始时间的误差上限
+	RDBTaskTick = 30 * time.Second
+	// RDBTaskRunRetention 执行记录的保留期
+	RDBTaskRunRetention = 30 * 24 * time.Hour
+
+	// rdbTaskConcurrency 每个 inner 实例同时执行的任务数上限
+	rdbTaskConcurrency = 8
+	// rdbTaskStaleGrace 超时之后再过这么久还是 running 的执行，视为执行它的实例已经退出
+	rdbTaskStaleGrace = 2 * time.Minute
+	// rdbTaskMaxError 执行记录里错误信息的最大字节数
+	rdbTaskMaxError = 2000
+)
+
+// rdbTaskSlots 正在执行的任务占用的名额。任务可能跑好几分钟，不在 Processor 的 worker 里等它们结束
+var rdbTaskSlots = make(chan struct{}, rdbTaskConcurrency)
+
+// --- RDBRunTasksJob ---
+
+// rdbRunTasksJob 认领到期的定时 SQL 任务，在后台以用户自己的数据库角色执行；
+// 开始失败或恢复成功时通知用户
+type rdbRunTasksJob struct{}
+
+func NewRDBRunTasksJob() k8s.Job {
+	return &rdbRunTasksJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeRDBRunTasks, NewRDBRunTasksJob)
+}
+
+func (j *rdbRunTasksJob) Type() k8s.JobType { return JobTypeRDBRunTasks }
+func (j *rdbRunTasksJob) ID() string        { return "periodic" }
+
+func (j *rdbRunTasksJob) Do(ctx context.Context) error {
+	if k8s.RDBManager == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	if n, err := dblayer.FailStaleRDBTaskRuns(ctx, now, rdbTaskStaleGrace); err != nil {
+		jobLog(j).Warn("fail stale task runs failed", "error", err)
+	} else if n > 0 {
+		jobLog(j).Warn("failed interrupted task runs", "runs", n)
+	}
+
+	free := cap(rdbTaskSlots) - len(rdbTaskSlots)
+	if free == 0 {
+		return nil
+	}
+	claims, err := dblayer.ClaimDueRDBTasks(ctx, now, free, func(t *dblayer.RDBTask) *time.Time {
+		return NextRDBTaskRun(t.Schedule, t.Timezone, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, claim := range claims {
+		rdbTaskSlots <- struct{}{}
+		go func(claim *dblayer.RDBTaskClaim) {
+			defer func() { <-rdbTaskSlots }()
+			runRDBTask(ctx, claim)
+		}(claim)
+	}
+	if len(claims) > 0 {
+		jobLog(j).Debug("started rdb tasks", "tasks", len(claims))
+	}
+	return nil
+}
+
+// NextRDBTaskRun 按任务的时区算出 after 之后下一次执行的时间（UTC），计划无效或不再触发时为 nil
+func NextRDBTaskRun(schedule, timezone string, after time.Time) *time.Time {
+	sched, err := k8s.ParseCronSchedule(schedule)
+	if err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil
+	}
+	next := sched.Next(after.In(loc))
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// runRDBTask 执行一次认领到的任务并记录结果。结果用不会被取消的 ctx 写入，
+// inner 退出时被打断的执行也能记下来
+func runRDBTask(ctx context.Context, claim *dblayer.RDBTaskClaim) {
+	t, run := claim.Task, claim.Run
+	start := time.Now()
+	rows, err := k8s.RDBManager.ExecAsUser(ctx, t.UserUID, t.RDBID, t.SQL, time.Duration(t.TimeoutSeconds)*time.Second)
+	run.DurationMs = int(time.Since(start).Milliseconds())
+	switch {
+	case err == nil:
+		run.Status, run.RowsAffected = dblayer.RDBTaskSucceeded, rows
+	case errors.Is(err, k8s.ErrStatementTimeout):
+		run.Status = dblayer.RDBTaskTimeout
+		run.Error = fmt.Sprintf("stopped after the %ds timeout", t.TimeoutSeconds)
+	default:
+		run.Status = dblayer.RDBTaskFailed
+		run.Error = strings.ToValidUTF8(clipString(err.Error(), rdbTaskMaxError), "")
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	from, err := dblayer.FinishRDBTaskRun(ctx, claim)
+	if err == dblayer.ErrNotFound {
+		return
+	}
+	if err != nil {
+		jobsLog.Error("save task run failed", "job_type", JobTypeRDBRunTasks, "task_id", t.ID, "run_id", run.ID, "error", err)
+		return
+	}
+	jobsLog.Info("rdb task finished", "task_id", t.ID, "user_id", t.UserUID, "status", run.Status, "trigger", run.Trigger, "duration_ms", run.DurationMs)
+
+	wasFailing := from == dblayer.RDBTaskFailed || from == dblayer.RDBTaskTimeout
+	switch {
+	case run.Status != dblayer.RDBTaskSucceeded && !wasFailing:
+		notifyRDBTask(ctx, t, dblayer.NotificationTaskFailed, dblayer.NotificationSeverityError,
+			fmt.Sprintf("Scheduled task %s failed", t.Name), run.Error, t.NotifyEmail)
+	case run.Status == dblayer.RDBTaskSucceeded && wasFailing:
+		notifyRDBTask(ctx, t, dblayer.NotificationTaskRecovered, dblayer.NotificationSeverityInfo,
+			fmt.Sprintf("Scheduled task %s succeeded again", t.Name), "The last run of the task succeeded.", false)
+	}
+}
+
+// notifyRDBTask 通知任务的所有者；email 时失败通知也发邮件
+func notifyRDBTask(ctx context.Context, t *dblayer.RDBTask, kind, severity, title, message string, email bool) {
+	ch := notify.Channels{}
+	if email {
+		ch.Email = &notify.Mail{Template: notify.MailRDBTaskFailed, Data: notify.RDBTaskData{
+			Task: t.Name, Database: t.RDBID, Error: message,
+		}}
+	}
+	notify.Send(ctx, &dblayer.Notification{
+		UserUID:      t.UserUID,
+		Kind:         kind,
+		Severity:     severity,
+		Title:        title,
+		Message:      message,
+		ResourceType: "rdb_task",
+		ResourceID:   strconv.Itoa(t.ID),
+	}, ch)
+}
+
+func clipString(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// --- RDBPruneTaskRunsJob ---
+
+// rdbPruneTaskRunsJob 清理超过保留期的执行记录
+type rdbPruneTaskRunsJob struct{}
+
+func NewRDBPruneTaskRunsJob() k8s.Job {
+	return &rdbPruneTaskRunsJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeRDBPruneTaskRuns, NewRDBPruneTaskRunsJob)
+}
+
+func (j *rdbPruneTaskRunsJob) Type() k8s.JobType { return JobTypeRDBPruneTaskRuns }
+func (j *rdbPruneTaskRunsJob) ID() string        { return "periodic" }
+
+func (j *rdbPruneTaskRunsJob) Do(ctx context.Context) error {
+	n, err := dblayer.PruneRDBTaskRuns(ctx, time.Now().UTC().Add(-RDBTaskRunRetention))
+	if err != nil {
+		return err
+	}
+	jobLog(j).Info("pruned rdb task runs", "runs", n)
+	return nil
+}
@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/notify"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// WorkerStatusCrashPaused 因反复崩溃被自动处理策略暂停的 worker，POST /api/worker/:id/unpause 恢复
+const WorkerStatusCrashPaused = "crash_paused"
+
+// remediateWorkerJob 按 worker 的自动处理策略处理 OOM 和 CrashLoopBackOff。
+// 由 pod 监听在容器被 OOM kill 或进入 CrashLoopBackOff 时提交，不经过 outbox
+type remediateWorkerJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func NewRemediateWorkerJob(workerID, userUID string) k8s.Job {
+	return &remediateWorkerJob{WorkerID: workerID, UserUID: userUID}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerRemediate, func() k8s.Job {
+		return &remediateWorkerJob{}
+	})
+}
+
+func (j *remediateWorkerJob) OwnerUID() string  { return j.UserUID }
+func (j *remediateWorkerJob) Type() k8s.JobType { return JobTypeWorkerRemediate }
+func (j *remediateWorkerJob) ID() string        { return j.WorkerID }
+
+func (j *remediateWorkerJob) Do(ctx context.Context) error {
+	p, err := dblayer.GetRemediationPolicy(ctx, j.WorkerID, j.UserUID)
+	if err == dblayer.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if p.OOMAction == dblayer.RemediationOff && p.CrashAction == dblayer.RemediationOff {
+		return nil
+	}
+	s, err := controller.WorkerPodSignals(j.WorkerID, j.UserUID, p.Window())
+	if err != nil {
+		return err
+	}
+
+	oom := p.OOMAction != dblayer.RemediationOff && s.OOMKills >= p.OOMThreshold
+	crash := p.CrashAction != dblayer.RemediationOff && s.CrashLooping > 0 && s.Restarts >= p.CrashThreshold
+	if !oom && !crash {
+		return nil
+	}
+	// 一个窗口内只处理一次，给新的 Pod 留出时间
+	ok, err := dblayer.ClaimRemediation(ctx, j.WorkerID, time.Now().UTC())
+	if err != nil || !ok {
+		return err
+	}
+
+	w, err := dblayer.GetWorkerByOwner(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return nil // 已删除
+	}
+	// OOM 优先：加了内存之后往往就不再崩溃
+	if oom {
+		return j.remediateOOM(ctx, p, w, s)
+	}
+	return j.remediateCrash(ctx, p, s)
+}
+
+// remediateOOM 内存翻倍，不超过套餐和策略的上限；bump 直接改配置并同步，notify 只记下建议
+func (j *remediateWorkerJob) remediateOOM(ctx context.Context, p *dblayer.WorkerRemediationPolicy, w *dblayer.Worker, s controller.PodSignals) error {
+	limits, err := dblayer.GetUserLimits(ctx, j.UserUID)
+	if err != nil {
+		return fmt.Errorf("get user limits: %w", err)
+	}
+	from := cmp.Or(w.AssignedMemory, k8s.DefaultWorkerMemory)
+	r := &dblayer.WorkerRemediation{
+		UserUID: j.UserUID, WorkerID: j.WorkerID, Action: dblayer.RemediationResizeSuggested, FromMemory: from,
+		Message: fmt.Sprintf("%d OOM kills in the last %s", s.OOMKills, p.Window()),
+	}
+	ceiling := limits.MaxMemory
+	if p.OOMAction == dblayer.RemediationBump && p.MaxMemory != "" {
+		ceiling = minQuantity(ceiling, p.MaxMemory)
+	}
+	to, ok := doubledMemory(from, ceiling)
+	switch {
+	case ok && p.OOMAction == dblayer.RemediationBump:
+		if err := dblayer.SetWorkerMemory(ctx, j.WorkerID, j.UserUID, w.AssignedMemory, to); err == dblayer.ErrNotFound {
+			return nil // 用户刚改过配置
+		} else if err != nil {
+			return err
+		}
+		if err := NewSyncSpecJob(j.WorkerID, j.UserUID).Do(ctx); err != nil {
+			return err
+		}
+		r.Action, r.ToMemory = dblayer.RemediationMemoryBumped, to
+		r.Message += fmt.Sprintf(", memory raised from %s to %s", from, to)
+	case ok:
+		r.ToMemory = to
+		r.Message += fmt.Sprintf(", raising memory from %s to %s should help", from, to)
+	default:
+		r.Message += fmt.Sprintf(", memory %s is already at the %s limit", from, ceiling)
+	}
+	j.record(ctx, r, dblayer.NotificationSeverityWarning)
+	return nil
+}
+
+// remediateCrash pause 把 worker 缩到 0 并告警，notify 只告警
+func (j *remediateWorkerJob) remediateCrash(ctx context.Context, p *dblayer.WorkerRemediationPolicy, s controller.PodSignals) error {
+	r := &dblayer.WorkerRemediation{
+		UserUID: j.UserUID, WorkerID: j.WorkerID, Action: dblayer.RemediationCrashLooping,
+		Message: fmt.Sprintf("%d restarts in the last %s, %d containers in CrashLoopBackOff", s.Restarts, p.Window(), s.CrashLooping),
+	}
+	if p.CrashAction == dblayer.RemediationPause {
+		err := controller.SetWorkerAppPaused(ctx, k8s.DynamicClient, controller.WorkerName(j.WorkerID, j.UserUID), true)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := dblayer.UpdateWorkerStatus(ctx, j.WorkerID, WorkerStatusCrashPaused); err != nil {
+			return err
+		}
+		r.Action = dblayer.RemediationPaused
+		r.Message += ", worker paused"
+	}
+	j.record(ctx, r, dblayer.NotificationSeverityError)
+	return nil
+}
+
+// record 保存处理记录并通知用户（站内通知和邮件）
+func (j *remediateWorkerJob) record(ctx context.Context, r *dblayer.WorkerRemediation, severity string) {
+	if err := dblayer.CreateRemediation(ctx, r); err != nil {
+		jobLog(j).Error("save remediation failed", "worker_id", j.WorkerID, "error", err)
+	}
+	jobLog(j).Info("worker remediated", "worker_id", j.WorkerID, "action", r.Action, "to_memory", r.ToMemory)
+	notify.Send(ctx, &dblayer.Notification{
+		UserUID:      j.UserUID,
+		Kind:         dblayer.NotificationRemediation,
+		Severity:     severity,
+		Title:        fmt.Sprintf("Worker %s: %s", j.WorkerID, remediationTitles[r.Action]),
+		Message:      r.Message,
+		ResourceType: "worker",
+		ResourceID:   j.WorkerID,
+	}, notify.Channels{Email: &notify.Mail{Template: notify.MailWorkerRemediation, Data: notify.RemediationData{
+		WorkerID: j.WorkerID, Action: r.Action, Message: r.Message, FromMemory: r.FromMemory, ToMemory: r.ToMemory,
+	}}})
+}
+
+var remediationTitles = map[string]string{
+	dblayer.RemediationMemoryBumped:    "memory raised after OOM kills",
+	dblayer.RemediationResizeSuggested: "running out of memory",
+	dblayer.RemediationPaused:          "paused after crash looping",
+	dblayer.RemediationCrashLooping:    "crash looping",
+}
+
+// doubledMemory 翻倍后的内存，不超过 ceiling（空表示不限）；已经到上限时 ok=false
+func doubledMemory(from, ceiling string) (string, bool) {
+	q, err := resource.ParseQuantity(from)
+	if err != nil {
+		return "", false
+	}
+	to := q.DeepCopy()
+	to.Add(q)
+	if c, err := resource.ParseQuantity(ceiling); err == nil && to.Cmp(c) > 0 {
+		to = c
+	}
+	if to.Cmp(q) <= 0 {
+		return "", false
+	}
+	return to.String(), true
+}
+
+// minQuantity 两个数量中较小的，空或无法解析的一方不算
+func minQuantity(a, b string) string {
+	qa, errA := resource.ParseQuantity(a)
+	qb, errB := resource.ParseQuantity(b)
+	switch {
+	case errA != nil:
+		return b
+	case errB != nil:
+		return a
+	case qb.Cmp(qa) < 0:
+		return b
+	}
+	return a
+}
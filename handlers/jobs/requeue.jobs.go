@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+// SaveQueuedJob Processor 停机时的 requeue 回调：还没开始的任务写入 console_tasks，
+// 下次启动由 ResumeQueuedJobs 重新投递
+func SaveQueuedJob(job k8s.Job) {
+	// 停机时 Processor 的 ctx 已取消
+	ctx := context.Background()
+	data, err := json.Marshal(job)
+	if err != nil {
+		jobLog(job).Error("cannot persist queued job", "error", err)
+		return
+	}
+	if _, err := dblayer.CreateTask(ctx, string(job.Type()), "queued at shutdown", string(data), dblayer.TaskStatusPending); err != nil {
+		jobLog(job).Error("cannot persist queued job", "error", err)
+	}
+}
+
+// ResumeQueuedJobs 启动时认领上次停机留下的任务并重新提交
+func ResumeQueuedJobs(ctx context.Context, proc *k8s.Processor) {
+	tasks, err := dblayer.ClaimPendingTasks(ctx, "resumed")
+	if err != nil {
+		jobsLog.Error("load queued jobs failed", "error", err)
+		return
+	}
+	for _, task := range tasks {
+		job, err := CreateJob(k8s.JobType(task.TaskType), []byte(task.TaskInfo))
+		if err != nil {
+			dblayer.UpdateTaskStatus(ctx, task.ID, dblayer.TaskStatusFinished, "discarded: "+err.Error())
+			continue
+		}
+		if err := proc.Submit(job); err != nil {
+			dblayer.UpdateTaskStatus(ctx, task.ID, dblayer.TaskStatusPending, "queued at shutdown")
+			continue
+		}
+		dblayer.UpdateTaskStatus(ctx, task.ID, dblayer.TaskStatusFinished, "resubmitted")
+	}
+	if len(tasks) > 0 {
+		jobsLog.Info("resumed queued jobs", "jobs", len(tasks))
+	}
+}
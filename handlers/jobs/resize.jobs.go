@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// resizeWorkerJob 把调整后的资源写进 WorkerApp CR，controller 更新 Deployment 后按滚动更新换掉 pod，
+// 结果写回调整记录 ChangeID。不经过部署，不产生部署版本
+type resizeWorkerJob struct {
+	ChangeID int    `json:"change_id"`
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func NewResizeWorkerJob(changeID int, workerID, userUID string) k8s.Job {
+	return &resizeWorkerJob{
+		ChangeID: changeID,
+		WorkerID: workerID,
+		UserUID:  userUID,
+	}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerResize, func() k8s.Job {
+		return &resizeWorkerJob{}
+	})
+}
+
+func (j *resizeWorkerJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *resizeWorkerJob) Type() k8s.JobType {
+	return JobTypeWorkerResize
+}
+
+func (j *resizeWorkerJob) ID() string {
+	return fmt.Sprintf("%s-resize-%d", j.WorkerID, j.ChangeID)
+}
+
+// Do outbox 至少投递一次，记录已不是 pending 时说明重复投递，不再同步
+func (j *resizeWorkerJob) Do(ctx context.Context) error {
+	r, err := dblayer.GetWorkerResourceChange(ctx, j.ChangeID)
+	if err != nil {
+		return fmt.Errorf("get resource change %d: %w", j.ChangeID, err)
+	}
+	if r.Status != dblayer.WorkerResizePending {
+		return nil
+	}
+	cause := j.resize(ctx)
+	if ok, err := dblayer.FinishWorkerResourceChange(ctx, j.ChangeID, cause); err != nil {
+		return errors.Join(cause, fmt.Errorf("record resource change %d: %w", j.ChangeID, err))
+	} else if !ok {
+		return cause
+	}
+	ev := dblayer.Event{
+		UserUID:      j.UserUID,
+		Kind:         dblayer.EventWorkerResize,
+		ResourceType: "worker",
+		ResourceID:   j.WorkerID,
+		Status:       dblayer.WorkerResizeApplied,
+		Message:      fmt.Sprintf("resize %d: cpu %s, memory %s, disk %s", j.ChangeID, r.ToCPU, r.ToMemory, r.ToDisk),
+	}
+	if cause != nil {
+		ev.Status = dblayer.WorkerResizeFailed
+		ev.Message += ": " + cause.Error()
+	}
+	dblayer.PublishEvent(ctx, ev)
+	return cause
+}
+
+// Abandon 多次卡住或 panic 后放弃，记录不再停在 pending
+func (j *resizeWorkerJob) Abandon(ctx context.Context, reason string) {
+	if ok, err := dblayer.FinishWorkerResourceChange(ctx, j.ChangeID, errors.New(reason)); err != nil || !ok {
+		return
+	}
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      j.UserUID,
+		Kind:         dblayer.EventWorkerResize,
+		ResourceType: "worker",
+		ResourceID:   j.WorkerID,
+		Status:       dblayer.WorkerResizeFailed,
+		Message:      fmt.Sprintf("resize %d: %s", j.ChangeID, reason),
+	})
+}
+
+// resize 按库里的当前配置写 CR（和 sync_spec 一样），之后的调整已经包含在内。
+// 还没部署过的 worker 没有 CR，首次部署时带上新配置
+func (j *resizeWorkerJob) resize(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return errors.New("worker not found")
+	}
+	runAsRoot, writableRootFS := securityOptOuts(ctx, w)
+	err = controller.SetWorkerAppResources(ctx, k8s.DynamicClient, controller.WorkerName(j.WorkerID, j.UserUID),
+		w.AssignedCPU, w.AssignedMemory, w.AssignedDisk, w.MaxReplicas, w.MainRegion, w.Pool, w.Arch, runAsRoot, writableRootFS)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// restartWorkerJob 给 worker 的 Deployment 打重启注解触发滚动重启，结果写回审计记录 RestartID
+type restartWorkerJob struct {
+	RestartID int    `json:"restart_id"`
+	WorkerID  string `json:"worker_id"`
+	UserUID   string `json:"user_uid"`
+}
+
+func NewRestartWorkerJob(restartID int, workerID, userUID string) k8s.Job {
+	return &restartWorkerJob{
+		RestartID: restartID,
+		WorkerID:  workerID,
+		UserUID:   userUID,
+	}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerRestart, func() k8s.Job {
+		return &restartWorkerJob{}
+	})
+}
+
+func (j *restartWorkerJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *restartWorkerJob) Type() k8s.JobType {
+	return JobTypeWorkerRestart
+}
+
+func (j *restartWorkerJob) ID() string {
+	return fmt.Sprintf("%s-restart-%d", j.WorkerID, j.RestartID)
+}
+
+// Do outbox 至少投递一次，记录已不是 pending 时说明重复投递，不再重启
+func (j *restartWorkerJob) Do(ctx context.Context) error {
+	r, err := dblayer.GetWorkerRestart(ctx, j.RestartID)
+	if err != nil {
+		return fmt.Errorf("get restart %d: %w", j.RestartID, err)
+	}
+	if r.Status != dblayer.WorkerRestartPending {
+		return nil
+	}
+	cause := j.restart(ctx)
+	if ok, err := dblayer.FinishWorkerRestart(ctx, j.RestartID, cause); err != nil {
+		return errors.Join(cause, fmt.Errorf("record restart %d: %w", j.RestartID, err))
+	} else if !ok {
+		return cause
+	}
+	ev := dblayer.Event{
+		UserUID:      j.UserUID,
+		Kind:         dblayer.EventWorkerRestart,
+		ResourceType: "worker",
+		ResourceID:   j.WorkerID,
+		Status:       dblayer.WorkerRestartRestarted,
+		Message:      fmt.Sprintf("restart %d", j.RestartID),
+	}
+	if cause != nil {
+		ev.Status = dblayer.WorkerRestartFailed
+		ev.Message += ": " + cause.Error()
+	}
+	dblayer.PublishEvent(ctx, ev)
+	return cause
+}
+
+// Abandon 多次卡住或 panic 后放弃，记录不再停在 pending
+func (j *restartWorkerJob) Abandon(ctx context.Context, reason string) {
+	if ok, err := dblayer.FinishWorkerRestart(ctx, j.RestartID, errors.New(reason)); err != nil || !ok {
+		return
+	}
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      j.UserUID,
+		Kind:         dblayer.EventWorkerRestart,
+		ResourceType: "worker",
+		ResourceID:   j.WorkerID,
+		Status:       dblayer.WorkerRestartFailed,
+		Message:      fmt.Sprintf("restart %d: %s", j.RestartID, reason),
+	})
+}
+
+func (j *restartWorkerJob) restart(ctx context.Context) error {
+	cluster, err := controller.ClusterOf(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return err
+	}
+	err = controller.RestartWorkerDeployment(ctx, cluster.Kube, k8s.TenantNamespace(j.UserUID), controller.WorkerName(j.WorkerID, j.UserUID))
+	if apierrors.IsNotFound(err) {
+		return errors.New("worker is not deployed")
+	}
+	return err
+}
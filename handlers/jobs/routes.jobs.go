@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// syncWorkerRoutesJob 把 worker 的额外路由规则写到 WorkerApp CR，由 controller 渲染进 worker 的路由
+type syncWorkerRoutesJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func NewSyncWorkerRoutesJob(workerID, userUID string) k8s.Job {
+	return &syncWorkerRoutesJob{WorkerID: workerID, UserUID: userUID}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerSyncRoutes, func() k8s.Job {
+		return &syncWorkerRoutesJob{}
+	})
+}
+
+func (j *syncWorkerRoutesJob) OwnerUID() string  { return j.UserUID }
+func (j *syncWorkerRoutesJob) Type() k8s.JobType { return JobTypeWorkerSyncRoutes }
+func (j *syncWorkerRoutesJob) ID() string        { return j.WorkerID }
+
+func (j *syncWorkerRoutesJob) Do(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceWorker, j.WorkerID); !ok {
+		return err
+	}
+	// 以库里的规则为准，避免多次修改时乱序覆盖
+	r, err := dblayer.GetWorkerRoutes(ctx, j.WorkerID)
+	if err != nil {
+		return fmt.Errorf("get worker routes: %w", err)
+	}
+	err = controller.SetWorkerAppRoutes(ctx, k8s.DynamicClient, controller.WorkerName(j.WorkerID, j.UserUID), r.Rules)
+	// 还没部署过：首次部署时会从库里带上规则
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sync worker routes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// resolveSecretRefs 拆分 worker env：普通值进 ConfigMap，secretref:// 的值解密当前版本后进 Secret。
+// 每次解密记一条访问记录
+func resolveSecretRefs(ctx context.Context, workerID, userUID string, env map[string]string) (plain, resolved map[string]string, err error) {
+	plain, resolved = map[string]string{}, map[string]string{}
+	for k, v := range env {
+		name, ok := secrets.ParseRef(v)
+		if !ok {
+			plain[k] = v
+			continue
+		}
+		sealed, err := dblayer.GetSealedSecret(ctx, userUID, name)
+		if err == dblayer.ErrNotFound {
+			return nil, nil, fmt.Errorf("env %s references unknown secret %q", k, name)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("get secret %s: %w", name, err)
+		}
+		value, err := secrets.Open(sealed.KeyID, sealed.Sealed, secrets.AAD(userUID, name, sealed.Version))
+		if err != nil {
+			return nil, nil, fmt.Errorf("open secret %s: %w", name, err)
+		}
+		resolved[k] = string(value)
+		if err := dblayer.RecordSecretAccess(ctx, userUID, dblayer.SecretAccess{
+			Name: name, Version: sealed.Version, Action: dblayer.SecretActionResolve, Actor: "worker:" + workerID,
+		}); err != nil {
+			jobsLog.Warn("record secret access failed", "worker_id", workerID, "secret", name, "error", err)
+		}
+	}
+	return plain, resolved, nil
+}
+
+// applySecretRefs 把解密后的值写进 worker 的 Secret，删掉不再引用的 key；没有变化时不更新，避免多余的重启
+func applySecretRefs(ctx context.Context, client typedcorev1.SecretInterface, name string, resolved map[string]string) error {
+	sec, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	before := maps.Clone(sec.Data)
+	if sec.Data == nil {
+		sec.Data = map[string][]byte{}
+	}
+	for _, k := range secretRefKeys(sec) {
+		if _, ok := resolved[k]; !ok {
+			delete(sec.Data, k)
+		}
+	}
+	for k, v := range resolved {
+		if !slices.Contains(controller.ReservedEnvKeys, k) {
+			sec.Data[k] = []byte(v)
+		}
+	}
+	keys := slices.Sorted(maps.Keys(resolved))
+	annotation := strings.Join(keys, ",")
+	if maps.EqualFunc(before, sec.Data, slices.Equal) && sec.Annotations[controller.SecretRefsAnnotation] == annotation {
+		return nil
+	}
+	setSecretRefKeys(sec, keys)
+	_, err = client.Update(ctx, sec, metav1.UpdateOptions{})
+	return err
+}
+
+// secretRefKeys Secret 中来自 secretref:// 的 key
+func secretRefKeys(sec *corev1.Secret) []string {
+	v := sec.Annotations[controller.SecretRefsAnnotation]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+func setSecretRefKeys(sec *corev1.Secret, keys []string) {
+	if len(keys) == 0 {
+		delete(sec.Annotations, controller.SecretRefsAnnotation)
+		return
+	}
+	if sec.Annotations == nil {
+		sec.Annotations = map[string]string{}
+	}
+	sec.Annotations[controller.SecretRefsAnnotation] = strings.Join(keys, ",")
+}
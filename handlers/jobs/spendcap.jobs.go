@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/billing"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/notify"
+)
+
+// WorkerStatusCapped 因达到花费上限被暂停的 worker，恢复时只恢复这些
+const WorkerStatusCapped = "capped"
+
+// AccruedCost 用户在 [from, to) 内的计量用量按价格表折算的花费
+func AccruedCost(ctx context.Context, userUID string, from, to time.Time) (float64, error) {
+	totals, err := dblayer.UsageTotals(ctx, userUID, from, to)
+	if err != nil {
+		return 0, err
+	}
+	sums := map[string]float64{}
+	for _, t := range totals {
+		sums[t.Metric] += t.Quantity
+	}
+	return billing.Sheet.UsageCost(sums[dblayer.UsageCPUSeconds], sums[dblayer.UsageMemoryByteSeconds], sums[dblayer.UsageStorageByteSeconds]), nil
+}
+
+// SpendCapPeriod 花费上限按 UTC 自然月计算
+func SpendCapPeriod(now time.Time) (string, time.Time) {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start.Format("2006-01"), start
+}
+
+// spendCapJob 检查用户的花费上限：超出时暂停非关键 worker，换月、调高上限、放行或取消上限后恢复。
+// 计量采样后对所有用户执行一次；用户修改上限或放行后 outer 只对该用户投递一次
+type spendCapJob struct {
+	UserUID string `json:"user_uid"`
+}
+
+func NewSpendCapJob(userUID string) k8s.Job {
+	return &spendCapJob{UserUID: userUID}
+}
+
+func init() {
+	RegisterJobType(JobTypeSpendCapEnforce, func() k8s.Job {
+		return &spendCapJob{}
+	})
+}
+
+func (j *spendCapJob) OwnerUID() string  { return j.UserUID }
+func (j *spendCapJob) Type() k8s.JobType { return JobTypeSpendCapEnforce }
+func (j *spendCapJob) ID() string        { return j.UserUID }
+
+func (j *spendCapJob) Do(ctx context.Context) error {
+	return enforceSpendCaps(ctx, j.UserUID)
+}
+
+var spendCapMu sync.Mutex
+
+// enforceSpendCaps userUID 为空时检查所有设置了上限的用户
+func enforceSpendCaps(ctx context.Context, userUID string) error {
+	spendCapMu.Lock()
+	defer spendCapMu.Unlock()
+
+	var caps []dblayer.SpendCapOf
+	if userUID == "" {
+		all, err := dblayer.ListSpendCaps(ctx)
+		if err != nil {
+			return fmt.Errorf("list spend caps: %w", err)
+		}
+		caps = all
+	} else {
+		c, err := dblayer.GetSpendCap(ctx, userUID)
+		if err == dblayer.ErrNotFound {
+			// 上限已取消，恢复之前被暂停的 worker
+			return resumeCappedWorkers(ctx, userUID, 0, 0, "spending cap removed")
+		}
+		if err != nil {
+			return err
+		}
+		caps = []dblayer.SpendCapOf{{UserUID: userUID, SpendCap: *c}}
+	}
+
+	period, start := SpendCapPeriod(time.Now())
+	var errs []error
+	for _, c := range caps {
+		if err := enforceSpendCap(ctx, c, period, start); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.UserUID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func enforceSpendCap(ctx context.Context, c dblayer.SpendCapOf, period string, start time.Time) error {
+	accrued, err := AccruedCost(ctx, c.UserUID, start, time.Now())
+	if err != nil {
+		return err
+	}
+	overridden := c.OverridePeriod == period
+	breached := accrued >= c.MonthlyCap
+
+	if c.EnforcedPeriod != "" {
+		var reason string
+		switch {
+		case c.EnforcedPeriod != period:
+			reason = "new billing period"
+		case overridden:
+			reason = "overridden for " + period
+		case !breached:
+			reason = fmt.Sprintf("cap raised to %.2f %s", c.MonthlyCap, c.Currency)
+		default:
+			return nil
+		}
+		if err := resumeCappedWorkers(ctx, c.UserUID, accrued, c.MonthlyCap, reason); err != nil {
+			return err
+		}
+		return dblayer.SetSpendCapEnforced(ctx, c.UserUID, "")
+	}
+	if !breached || overridden {
+		return nil
+	}
+
+	ids, err := dblayer.ListCappableWorkerIDs(ctx, c.UserUID)
+	if err != nil {
+		return err
+	}
+	paused, err := setWorkersStatus(ctx, c.UserUID, ids, true, WorkerStatusCapped)
+	msg := fmt.Sprintf("spent %.2f of the %.2f %s monthly cap, %d workers paused", accrued, c.MonthlyCap, c.Currency, len(paused))
+	if len(paused) > 0 || err == nil {
+		recordSpendCapAction(ctx, c.UserUID, dblayer.SpendCapAction{
+			Period: period, Action: dblayer.SpendCapActionSuspend, Actor: "system",
+			Accrued: accrued, Cap: c.MonthlyCap, WorkerIDs: paused, Message: msg,
+		})
+	}
+	if err != nil {
+		// 没暂停成功的下一轮重试
+		return err
+	}
+	if err := dblayer.SetSpendCapEnforced(ctx, c.UserUID, period); err != nil {
+		return err
+	}
+
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      c.UserUID,
+		Kind:         dblayer.EventSpendCap,
+		ResourceType: "spend_cap",
+		ResourceID:   c.UserUID,
+		Status:       dblayer.SpendCapActionSuspend,
+		Message:      msg,
+	})
+	err = notify.SendMail(ctx, c.UserUID, notify.Mail{Template: notify.MailSpendCapReached, Data: notify.SpendCapData{
+		Accrued: fmt.Sprintf("%.2f", accrued), Cap: fmt.Sprintf("%.2f", c.MonthlyCap), Currency: c.Currency, Paused: len(paused),
+	}})
+	if err != nil {
+		jobsLog.Warn("spend cap email failed", "user_id", c.UserUID, "error", err)
+	}
+	return nil
+}
+
+// resumeCappedWorkers 恢复因花费上限暂停的 worker
+func resumeCappedWorkers(ctx context.Context, userUID string, accrued, monthlyCap float64, reason string) error {
+	ids, err := dblayer.ListWorkerIDsByStatus(ctx, userUID, WorkerStatusCapped)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	resumed, err := setWorkersStatus(ctx, userUID, ids, false, "active")
+	period, _ := SpendCapPeriod(time.Now())
+	msg := fmt.Sprintf("%s, %d workers resumed", reason, len(resumed))
+	if len(resumed) > 0 {
+		recordSpendCapAction(ctx, userUID, dblayer.SpendCapAction{
+			Period: period, Action: dblayer.SpendCapActionResume, Actor: "system",
+			Accrued: accrued, Cap: monthlyCap, WorkerIDs: resumed, Message: msg,
+		})
+		dblayer.PublishEvent(ctx, dblayer.Event{
+			UserUID:      userUID,
+			Kind:         dblayer.EventSpendCap,
+			ResourceType: "spend_cap",
+			ResourceID:   userUID,
+			Status:       dblayer.SpendCapActionResume,
+			Message:      msg,
+		})
+	}
+	return err
+}
+
+// setWorkersStatus 暂停或恢复 worker 并写入新状态，返回成功的 worker
+func setWorkersStatus(ctx context.Context, userUID string, ids []string, paused bool, status string) ([]string, error) {
+	done := make([]string, 0, len(ids))
+	var errs []error
+	for _, id := range ids {
+		err := controller.SetWorkerAppPaused(ctx, k8s.DynamicClient, controller.WorkerName(id, userUID), paused)
+		if err == nil {
+			err = dblayer.UpdateWorkerStatus(ctx, id, status)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		done = append(done, id)
+	}
+	return done, errors.Join(errs...)
+}
+
+func recordSpendCapAction(ctx context.Context, userUID string, a dblayer.SpendCapAction) {
+	if err := dblayer.RecordSpendCapAction(ctx, userUID, a); err != nil {
+		jobsLog.Error("record spend cap action failed", "user_id", userUID, "action", a.Action, "error", err)
+	}
+}
@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+)
+
+// StaticArtifactGrace 上传后还没被部署版本引用的 tarball 保留这么久，给部署留出时间
+const StaticArtifactGrace = 24 * time.Hour
+
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// staticSite 部署版本的静态文件在 CR 里的来源，container worker 为 nil。
+// 仓库的 ref 解析成提交并记在版本上，之后 pod 重启拿到的都是同一份文件
+func staticSite(ctx context.Context, v *dblayer.WorkerDeployVersion, userUID, ownerSK string) (*k8s.StaticSite, error) {
+	s := v.Static
+	if s == nil {
+		return nil, nil
+	}
+	if s.Artifact != "" {
+		return &k8s.StaticSite{URL: k8s.StaticArtifactFetchURL(ownerSK, userUID, s.Artifact), SHA256: s.Artifact, Dir: s.Dir}, nil
+	}
+	sha := v.CommitSHA
+	if sha == "" {
+		var err error
+		if sha, err = resolveGitHubRef(ctx, s.Repo, s.Ref); err != nil {
+			return nil, fmt.Errorf("resolve %s@%s: %w", s.Repo, s.Ref, err)
+		}
+		if err := dblayer.SetDeployVersionCommit(ctx, v.ID, sha); err != nil {
+			return nil, fmt.Errorf("record commit: %w", err)
+		}
+	}
+	return &k8s.StaticSite{URL: fmt.Sprintf("%s/repos/%s/tarball/%s", GitHubAPI, s.Repo, sha), Dir: s.Dir, Strip: true}, nil
+}
+
+// resolveGitHubRef 公开仓库的分支、tag 或提交对应的完整提交 sha
+func resolveGitHubRef(ctx context.Context, repo, ref string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/repos/%s/commits/%s", GitHubAPI, repo, ref), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	req.Header.Set("User-Agent", "console")
+
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 128))
+	if err != nil {
+		return "", err
+	}
+	sha := strings.TrimSpace(string(body))
+	if !commitSHAPattern.MatchString(sha) {
+		return "", fmt.Errorf("github returned an invalid commit %q", sha)
+	}
+	return sha, nil
+}
+
+// staticArtifactPruneJob 清理没有部署版本引用的上传 tarball
+type staticArtifactPruneJob struct{}
+
+func NewStaticArtifactPruneJob() k8s.Job {
+	return &staticArtifactPruneJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeStaticArtifactPrune, NewStaticArtifactPruneJob)
+}
+
+func (j *staticArtifactPruneJob) Type() k8s.JobType { return JobTypeStaticArtifactPrune }
+func (j *staticArtifactPruneJob) ID() string        { return "periodic" }
+
+func (j *staticArtifactPruneJob) Do(ctx context.Context) error {
+	n, err := dblayer.PruneStaticArtifacts(ctx, time.Now().UTC().Add(-StaticArtifactGrace))
+	if err != nil {
+		return err
+	}
+	jobLog(j).Info("pruned static artifacts", "artifacts", n)
+	return nil
+}
@@ -0,0 +1,257 @@
+package jobs
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/metrics"
+	"jabberwocky238/console/notify"
+	"jabberwocky238/console/settings"
+)
+
+// 按任务类型统计执行情况（成功率、耗时和排队时间的分位数、重试次数），供 GET /admin/jobs/stats 查看；
+// Prometheus 上对应 console_jobs_total、console_job_duration_seconds、console_job_queue_wait_seconds
+// 和 console_job_retries_total。只在内存里，重启后清空
+
+var (
+	failureAlertRate = settings.NewInt("jobs.failure_alert_rate", 50, 1, 100,
+		"Failure rate (percent) of a job type within jobs.failure_alert_window that raises a failure spike alert")
+	failureAlertMinRuns = settings.NewInt("jobs.failure_alert_min_runs", 10, 1, 10000,
+		"Runs of a job type within jobs.failure_alert_window needed before its failure rate can alert")
+	failureAlertWindow = settings.NewDuration("jobs.failure_alert_window", 15*time.Minute, time.Minute, JobStatsRetention,
+		"Window over which the failure rate of a job type is checked after each run")
+	failureAlertWebhook = settings.NewString("jobs.alert_webhook", "", validWebhookURL,
+		"URL that failure spike alerts are posted to as JSON; empty only logs them")
+)
+
+const (
+	// JobStatsRetention 统计保留的时长，也是 GET /admin/jobs/stats 的 window 上限
+	JobStatsRetention = 24 * time.Hour
+	// maxJobSamples 每个类型最多保留的执行记录数，执行很频繁的类型实际窗口会短于 JobStatsRetention
+	maxJobSamples = 2000
+	// maxFailedJobs 记住的失败任务数，用来识别重试
+	maxFailedJobs = 10000
+)
+
+// jobSample 一次执行
+type jobSample struct {
+	at    time.Time
+	ok    bool
+	retry bool
+	wait  time.Duration
+	took  time.Duration
+}
+
+// jobTypeState 一个类型的执行记录和启动以来的累计
+type jobTypeState struct {
+	samples  []jobSample // 按时间先后
+	runs     int
+	failures int
+	retries  int
+	lastFail time.Time
+	alerting bool
+}
+
+// Percentiles 耗时分位数（秒）
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// JobTypeStats 一个任务类型在窗口内的执行情况；Total* 为本实例启动以来的累计。
+// Alerting 表示失败率仍在 jobs.failure_alert_rate 之上
+type JobTypeStats struct {
+	Type          k8s.JobType `json:"type"`
+	Runs          int         `json:"runs"`
+	Failures      int         `json:"failures"`
+	SuccessRate   float64     `json:"success_rate"` // 百分比，没有执行时为 100
+	Retries       int         `json:"retries"`
+	Duration      Percentiles `json:"duration"`
+	QueueWait     Percentiles `json:"queue_wait"`
+	LastFailureAt *time.Time  `json:"last_failure_at"`
+	TotalRuns     int         `json:"total_runs"`
+	TotalFailures int         `json:"total_failures"`
+	TotalRetries  int         `json:"total_retries"`
+	Alerting      bool        `json:"alerting"`
+}
+
+var (
+	statsMu      sync.Mutex
+	jobTypeStats = map[k8s.JobType]*jobTypeState{}
+	failedJobs   = map[string]bool{} // type/ID，上一次执行失败的任务
+	spikeHooks   []func(JobTypeStats)
+)
+
+// OnFailureSpike 注册失败率告警的回调，某个类型的失败率升到 jobs.failure_alert_rate 以上时调用一次，
+// 回落之后才会再次调用。须在 Processor.Start 之前注册，回调不能阻塞
+func OnFailureSpike(fn func(JobTypeStats)) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	spikeHooks = append(spikeHooks, fn)
+}
+
+// RecordJobRun Processor.OnRun 的回调，记录一次执行并检查失败率
+func RecordJobRun(run k8s.JobRun) {
+	typ := run.Job.Type()
+	key := string(typ) + "/" + run.Job.ID()
+	now := time.Now()
+	ok := run.Err == nil
+
+	statsMu.Lock()
+	retry := failedJobs[key]
+	if ok {
+		delete(failedJobs, key)
+	} else if len(failedJobs) < maxFailedJobs {
+		failedJobs[key] = true
+	}
+	st := jobTypeStats[typ]
+	if st == nil {
+		st = &jobTypeState{}
+		jobTypeStats[typ] = st
+	}
+	st.samples = append(st.samples, jobSample{at: now, ok: ok, retry: retry, wait: run.Wait, took: run.Duration})
+	st.prune(now)
+	st.runs++
+	if !ok {
+		st.failures++
+		st.lastFail = now
+	}
+	if retry {
+		st.retries++
+	}
+	spike, stats := st.checkSpike(typ, now)
+	hooks := spikeHooks
+	statsMu.Unlock()
+
+	if retry {
+		metrics.JobRetries.WithLabelValues(string(typ)).Inc()
+	}
+	if !spike {
+		return
+	}
+	metrics.JobFailureSpikes.WithLabelValues(string(typ)).Inc()
+	jobsLog.Error("job failure rate spike", "job_type", typ, "runs", stats.Runs, "failures", stats.Failures,
+		"success_rate", stats.SuccessRate, "window", failureAlertWindow.Get().String())
+	for _, fn := range hooks {
+		fn(stats)
+	}
+}
+
+// prune 去掉超出保留时长或条数的记录
+func (st *jobTypeState) prune(now time.Time) {
+	cut := 0
+	for cut < len(st.samples) && now.Sub(st.samples[cut].at) > JobStatsRetention {
+		cut++
+	}
+	cut = max(cut, len(st.samples)-maxJobSamples)
+	if cut > 0 {
+		st.samples = slices.Delete(st.samples, 0, cut)
+	}
+}
+
+// checkSpike 失败率刚升到阈值以上时返回 true 和告警窗口内的统计；回落到阈值以下后重新计
+func (st *jobTypeState) checkSpike(typ k8s.JobType, now time.Time) (bool, JobTypeStats) {
+	stats := st.stats(typ, now.Add(-failureAlertWindow.Get()))
+	over := stats.Runs >= failureAlertMinRuns.Get() && 100-stats.SuccessRate >= float64(failureAlertRate.Get())
+	spike := over && !st.alerting
+	st.alerting = over
+	stats.Alerting = over
+	return spike, stats
+}
+
+// stats since 之后的执行情况
+func (st *jobTypeState) stats(typ k8s.JobType, since time.Time) JobTypeStats {
+	s := JobTypeStats{
+		Type: typ, SuccessRate: 100, Alerting: st.alerting,
+		TotalRuns: st.runs, TotalFailures: st.failures, TotalRetries: st.retries,
+	}
+	if !st.lastFail.IsZero() {
+		t := st.lastFail
+		s.LastFailureAt = &t
+	}
+	var took, wait []time.Duration
+	for _, sm := range st.samples {
+		if sm.at.Before(since) {
+			continue
+		}
+		s.Runs++
+		if !sm.ok {
+			s.Failures++
+		}
+		if sm.retry {
+			s.Retries++
+		}
+		took = append(took, sm.took)
+		wait = append(wait, sm.wait)
+	}
+	if s.Runs > 0 {
+		s.SuccessRate = float64(s.Runs-s.Failures) * 100 / float64(s.Runs)
+	}
+	s.Duration = percentiles(took)
+	s.QueueWait = percentiles(wait)
+	return s
+}
+
+// percentiles 按最近秩取分位数
+func percentiles(d []time.Duration) Percentiles {
+	if len(d) == 0 {
+		return Percentiles{}
+	}
+	slices.Sort(d)
+	at := func(p int) float64 {
+		i := (len(d)*p + 99) / 100
+		return d[max(i-1, 0)].Seconds()
+	}
+	return Percentiles{P50: at(50), P95: at(95), P99: at(99), Max: d[len(d)-1].Seconds()}
+}
+
+// JobStats 每个执行过的任务类型在最近 window 内的统计，按类型排序
+func JobStats(window time.Duration) []JobTypeStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	since := time.Now().Add(-window)
+	out := make([]JobTypeStats, 0, len(jobTypeStats))
+	for _, typ := range slices.Sorted(maps.Keys(jobTypeStats)) {
+		out = append(out, jobTypeStats[typ].stats(typ, since))
+	}
+	return out
+}
+
+func validWebhookURL(v string) error {
+	if v == "" {
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", v)
+	}
+	return nil
+}
+
+// failureSpikeAlert jobs.alert_webhook 收到的内容
+type failureSpikeAlert struct {
+	Alert  string       `json:"alert"`
+	Window string       `json:"window"`
+	Stats  JobTypeStats `json:"stats"`
+}
+
+// PostFailureSpike OnFailureSpike 的回调：配置了 jobs.alert_webhook 时把告警 POST 过去
+func PostFailureSpike(stats JobTypeStats) {
+	target := failureAlertWebhook.Get()
+	if target == "" {
+		return
+	}
+	body := failureSpikeAlert{Alert: "job_failure_spike", Window: failureAlertWindow.Get().String(), Stats: stats}
+	go func() {
+		if err := notify.PostWebhook(target, "", body); err != nil {
+			jobsLog.Warn("post job failure alert failed", "job_type", stats.Type, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// syncWorkerTagsJob 把 worker 标签写到 WorkerApp CR，由 controller 同步到 Deployment/Service 的 label
+type syncWorkerTagsJob struct {
+	WorkerID string            `json:"worker_id"`
+	UserUID  string            `json:"user_uid"`
+	Tags     map[string]string `json:"tags"`
+}
+
+func NewSyncWorkerTagsJob(workerID, userUID string, tags map[string]string) k8s.Job {
+	return &syncWorkerTagsJob{
+		WorkerID: workerID,
+		UserUID:  userUID,
+		Tags:     tags,
+	}
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerSyncTags, func() k8s.Job {
+		return &syncWorkerTagsJob{}
+	})
+	RegisterJobType(JobTypeDomainSyncTags, func() k8s.Job {
+		return &syncDomainTagsJob{}
+	})
+}
+
+func (j *syncWorkerTagsJob) OwnerUID() string  { return j.UserUID }
+func (j *syncWorkerTagsJob) Type() k8s.JobType { return JobTypeWorkerSyncTags }
+func (j *syncWorkerTagsJob) ID() string        { return j.WorkerID }
+
+func (j *syncWorkerTagsJob) Do(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceWorker, j.WorkerID); !ok {
+		return err
+	}
+	err := controller.SetWorkerAppTags(ctx, k8s.DynamicClient, controller.WorkerName(j.WorkerID, j.UserUID), j.Tags)
+	// 还没部署过：首次部署时会从库里带上标签
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sync worker tags: %w", err)
+	}
+	return nil
+}
+
+// syncDomainTagsJob 把自定义域名标签同步到 Service/Certificate/IngressRoute 的 label
+type syncDomainTagsJob struct {
+	CDID    string `json:"cdid"`
+	UserUID string `json:"user_uid"`
+}
+
+func NewSyncDomainTagsJob(cdid, userUID string) k8s.Job {
+	return &syncDomainTagsJob{CDID: cdid, UserUID: userUID}
+}
+
+func (j *syncDomainTagsJob) OwnerUID() string  { return j.UserUID }
+func (j *syncDomainTagsJob) Type() k8s.JobType { return JobTypeDomainSyncTags }
+func (j *syncDomainTagsJob) ID() string        { return j.CDID }
+
+func (j *syncDomainTagsJob) Do(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	if ok, err := owned(ctx, j, j.UserUID, dblayer.ResourceDomain, j.CDID); !ok {
+		return err
+	}
+	// 以库里的标签为准，避免多次修改时乱序覆盖
+	cd, err := k8s.GetCustomDomain(ctx, j.CDID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return cd.SyncTagLabels(ctx)
+}
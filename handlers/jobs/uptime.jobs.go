@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/notify"
+)
+
+const (
+	// UptimeProbeTick 拨测调度间隔，也是 check 实际探测时间的误差上限
+	UptimeProbeTick = 15 * time.Second
+
+	uptimeBatchSize   = 200
+	uptimeConcurrency = 16
+	uptimeRetention   = 30 * 24 * time.Hour
+)
+
+// uptimeProbeJob 认领到期的拨测并发探测，记录结果；check 变为 down / 恢复 up 时推送事件
+type uptimeProbeJob struct{}
+
+func NewUptimeProbeJob() k8s.Job {
+	return &uptimeProbeJob{}
+}
+
+// uptimePruneJob 清理超过保留期的探测结果和已结束的事故
+type uptimePruneJob struct{}
+
+func NewUptimePruneJob() k8s.Job {
+	return &uptimePruneJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeUptimeProbe, NewUptimeProbeJob)
+	RegisterJobType(JobTypeUptimePrune, NewUptimePruneJob)
+}
+
+func (j *uptimeProbeJob) Type() k8s.JobType { return JobTypeUptimeProbe }
+func (j *uptimeProbeJob) ID() string        { return "periodic" }
+
+func (j *uptimeProbeJob) Do(ctx context.Context) error {
+	checks, err := dblayer.ClaimDueUptimeChecks(ctx, uptimeBatchSize)
+	if err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, uptimeConcurrency)
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(c *dblayer.UptimeCheck) {
+			defer func() { <-sem; wg.Done() }()
+			recordProbe(ctx, c, probeUptime(c))
+		}(c)
+	}
+	wg.Wait()
+
+	if len(checks) > 0 {
+		jobLog(j).Debug("probed uptime checks", "checks", len(checks))
+	}
+	return nil
+}
+
+func (j *uptimePruneJob) Type() k8s.JobType { return JobTypeUptimePrune }
+func (j *uptimePruneJob) ID() string        { return "periodic" }
+
+func (j *uptimePruneJob) Do(ctx context.Context) error {
+	n, err := dblayer.PruneUptimeResults(ctx, time.Now().Add(-uptimeRetention))
+	if err != nil {
+		return err
+	}
+	jobLog(j).Info("pruned uptime results", "results", n)
+	return nil
+}
+
+var (
+	// worker 地址指向自己的 ingress，直接探测
+	uptimeClient = &http.Client{CheckRedirect: limitRedirects}
+	// 自定义域名的 DNS 由用户控制，和 webhook 一样不允许解析到内网
+	uptimeExternalClient = &http.Client{
+		CheckRedirect: limitRedirects,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Control: notify.DenyPrivateAddress}).DialContext,
+		},
+	}
+)
+
+func limitRedirects(_ *http.Request, via []*http.Request) error {
+	if len(via) >= 5 {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	return nil
+}
+
+// probeUptime GET 一次 check 的地址；ExpectedStatus 为 0 时 2xx/3xx 都算成功
+func probeUptime(c *dblayer.UptimeCheck) *dblayer.UptimeResult {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	client := uptimeClient
+	if c.DomainID != nil {
+		client = uptimeExternalClient
+	}
+
+	r := &dblayer.UptimeResult{CheckID: c.ID}
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	req.Header.Set("User-Agent", "console-uptime")
+	resp, err := client.Do(req)
+	r.LatencyMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		if ctx.Err() != nil {
+			r.Error = fmt.Sprintf("timeout after %dms", c.TimeoutMs)
+		} else {
+			r.Error = err.Error()
+		}
+		return r
+	}
+	resp.Body.Close()
+
+	r.StatusCode = resp.StatusCode
+	if c.ExpectedStatus != 0 {
+		r.OK = resp.StatusCode == c.ExpectedStatus
+	} else {
+		r.OK = resp.StatusCode >= 200 && resp.StatusCode < 400
+	}
+	if !r.OK {
+		r.Error = "HTTP " + strconv.Itoa(resp.StatusCode)
+	}
+	return r
+}
+
+func recordProbe(ctx context.Context, c *dblayer.UptimeCheck, r *dblayer.UptimeResult) {
+	from, to, err := dblayer.RecordUptimeResult(ctx, c, r)
+	if err != nil {
+		jobsLog.Error("save uptime result failed", "job_type", JobTypeUptimeProbe, "check_id", c.ID, "error", err)
+		return
+	}
+	if from == to || to == dblayer.UptimeStatusUnknown {
+		return
+	}
+	// 第一次探测成功（unknown → up）不算状态变化
+	if from == dblayer.UptimeStatusUnknown && to == dblayer.UptimeStatusUp {
+		return
+	}
+
+	msg := c.Name + " is up"
+	if to == dblayer.UptimeStatusDown {
+		msg = c.Name + " is down: " + r.Error
+	}
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      c.UserUID,
+		Kind:         dblayer.EventUptimeStatus,
+		ResourceType: "uptime_check",
+		ResourceID:   strconv.Itoa(c.ID),
+		Status:       to,
+		Message:      msg,
+	})
+}
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+)
+
+// UsageSampleInterval 计量采样间隔，每次采样的用量按距上次采样的时长积分
+const UsageSampleInterval = 5 * time.Minute
+
+// usageSampleJob 采样 worker CPU/内存、RDB/KV 存储、worker 出流量和自定义域名数，写入 usage_samples
+type usageSampleJob struct{}
+
+func NewUsageSampleJob() k8s.Job {
+	return &usageSampleJob{}
+}
+
+func init() {
+	RegisterJobType(JobTypeUsageSample, NewUsageSampleJob)
+}
+
+func (j *usageSampleJob) Type() k8s.JobType { return JobTypeUsageSample }
+func (j *usageSampleJob) ID() string        { return "periodic" }
+
+var (
+	usageMu         sync.Mutex
+	lastUsageSample time.Time
+)
+
+func (j *usageSampleJob) Do(ctx context.Context) error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	// 重启或漏掉采样后，中间那段用量未知，只按一个间隔计
+	end := time.Now()
+	start := lastUsageSample
+	if start.IsZero() || end.Sub(start) > 2*UsageSampleInterval {
+		start = end.Add(-UsageSampleInterval)
+	}
+	seconds := end.Sub(start).Seconds()
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var samples []dblayer.UsageSample
+	add := func(userUID, resourceType, resourceID, metric string, quantity float64) {
+		samples = append(samples, dblayer.UsageSample{
+			UserUID: userUID, ResourceType: resourceType, ResourceID: resourceID, Metric: metric, Quantity: quantity,
+		})
+	}
+
+	if k8s.K8sClient != nil {
+		workers, err := controller.WorkerUsages(ctx)
+		if err != nil {
+			jobLog(j).Warn("worker usage incomplete", "error", err)
+		}
+		for _, w := range workers {
+			add(w.OwnerID, "worker", w.WorkerID, dblayer.UsageCPUSeconds, w.CPUCores*seconds)
+			add(w.OwnerID, "worker", w.WorkerID, dblayer.UsageMemoryByteSeconds, w.MemoryBytes*seconds)
+		}
+	}
+
+	if k8s.Ingress.Name() == k8s.IngressProviderTraefik {
+		egress, err := k8s.WorkerEgressBytes(ctx, end.Sub(start))
+		if err != nil {
+			jobLog(j).Warn("query worker egress failed", "error", err)
+		}
+		if len(egress) > 0 {
+			deployed, err := dblayer.ListDeployedWorkers(ctx)
+			if err != nil {
+				jobLog(j).Warn("list deployed workers failed", "error", err)
+			}
+			for _, w := range deployed {
+				add(w.UserUID, "worker", w.WID, dblayer.UsageEgressBytes, egress[controller.WorkerName(w.WID, w.UserUID)])
+			}
+		}
+	}
+
+	storage, err := dblayer.ListStorageUsage(ctx)
+	if err != nil {
+		jobLog(j).Warn("list storage usage failed", "error", err)
+	}
+	for _, s := range storage {
+		add(s.UserUID, s.ResourceType, s.ResourceID, dblayer.UsageStorageByteSeconds, float64(s.Bytes)*seconds)
+	}
+
+	domains, err := dblayer.ListAllSuccessDomains(ctx)
+	if err != nil {
+		jobLog(j).Warn("list domains failed", "error", err)
+	}
+	for _, d := range domains {
+		add(d.UserUID, "domain", d.CDID, dblayer.UsageDomainSeconds, seconds)
+	}
+
+	if err := dblayer.RecordUsage(ctx, start, end, samples); err != nil {
+		return err
+	}
+	lastUsageSample = end
+	jobLog(j).Debug("usage sampled", "samples", len(samples), "seconds", seconds)
+
+	// 新的用量入库后检查花费上限
+	if err := enforceSpendCaps(ctx, ""); err != nil {
+		jobLog(j).Warn("enforce spend caps incomplete", "error", err)
+	}
+	return nil
+}
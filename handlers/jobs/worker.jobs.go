@@ -2,13 +2,17 @@ package jobs
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"slices"
+	"time"
 
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
 	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/notify"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -34,6 +38,10 @@ func init() {
 	})
 }
 
+func (j *deployWorkerJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *deployWorkerJob) Type() k8s.JobType {
 	return JobTypeWorkerDeployWorker
 }
@@ -42,41 +50,177 @@ func (j *deployWorkerJob) ID() string {
 	return fmt.Sprintf("%s-%s-%d", j.WorkerID, j.UserUID, j.VersionID)
 }
 
-func (j *deployWorkerJob) Do() error {
-	v, w, sk, err := dblayer.GetDeployVersionWithWorker(j.VersionID)
+// Do 部署版本；worker 绑定了 GitHub 仓库且版本带 commit 时，前后把状态写成 commit status。
+// 结束后给用户发一条站内通知，重试时按版本去重
+func (j *deployWorkerJob) Do(ctx context.Context) error {
+	status := deployCommitStatus(ctx, j.WorkerID, j.UserUID, j.VersionID)
+	status.post(ctx, "pending", fmt.Sprintf("deploying version %d", j.VersionID))
+	if err := j.deploy(ctx); err != nil {
+		status.post(ctx, "failure", err.Error())
+		j.notifyDeploy(ctx, dblayer.NotificationDeployFailed, dblayer.NotificationSeverityError,
+			fmt.Sprintf("Deploy of worker %s failed", j.WorkerID), err.Error())
+		return err
+	}
+	status.post(ctx, "success", fmt.Sprintf("version %d applied", j.VersionID))
+	j.notifyDeploy(ctx, dblayer.NotificationDeployFinished, dblayer.NotificationSeverityInfo,
+		fmt.Sprintf("Worker %s deployed", j.WorkerID), fmt.Sprintf("Version %d is live.", j.VersionID))
+	return nil
+}
+
+// Abandon 部署多次卡住或 panic 后放弃：版本和 worker 标为 error，不再停在 deploying
+func (j *deployWorkerJob) Abandon(ctx context.Context, reason string) {
+	dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", reason)
+	dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "error")
+	publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", reason)
+	j.notifyDeploy(ctx, dblayer.NotificationDeployFailed, dblayer.NotificationSeverityError,
+		fmt.Sprintf("Deploy of worker %s failed", j.WorkerID), reason)
+}
+
+func (j *deployWorkerJob) notifyDeploy(ctx context.Context, kind, severity, title, message string) {
+	notify.Send(ctx, &dblayer.Notification{
+		UserUID:      j.UserUID,
+		Kind:         kind,
+		Severity:     severity,
+		Title:        title,
+		Message:      message,
+		ResourceType: "worker",
+		ResourceID:   j.WorkerID,
+		DedupeKey:    fmt.Sprintf("%s:%d", kind, j.VersionID),
+	}, notify.Channels{})
+}
+
+func (j *deployWorkerJob) deploy(ctx context.Context) error {
+	v, w, sk, err := dblayer.GetDeployVersionWithWorker(ctx, j.VersionID)
 	if err != nil {
-		dblayer.UpdateDeployVersionStatus(j.VersionID, "error", err.Error())
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", err.Error())
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", err.Error())
 		return fmt.Errorf("get version %d: %w", j.VersionID, err)
 	}
 
 	name := controller.WorkerName(w.WID, w.UserUID)
 
+	cluster, err := placeWorker(ctx, w)
+	if err != nil {
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", err.Error())
+		dblayer.UpdateWorkerStatus(ctx, w.WID, "error")
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", err.Error())
+		return fmt.Errorf("place worker for version %d: %w", j.VersionID, err)
+	}
+
+	runAsRoot, writableRootFS := securityOptOuts(ctx, w)
+	// 静态站点镜像由 controller 指定运行用户，不需要检查
+	if !runAsRoot && v.Static == nil {
+		// 镜像以 root 运行时 kubelet 会拒绝启动，部署前给出明确的错误
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := k8s.CheckImageNonRoot(ctx, v.Image, w.Arch)
+		cancel()
+		if errors.Is(err, k8s.ErrImageRunsAsRoot) {
+			msg := err.Error() + " (or set run_as_root if your plan allows it)"
+			dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", msg)
+			publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", msg)
+			return fmt.Errorf("check image of version %d: %w", j.VersionID, err)
+		}
+		if err != nil {
+			// 私有仓库等读不到镜像配置时交给 kubelet 检查
+			jobLog(j).Warn("inspect image user failed", "image", v.Image, "error", err)
+		}
+	}
+
+	egress, err := dblayer.GetWorkerEgress(ctx, w.WID)
+	if err != nil {
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", "failed to load egress rules")
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", "failed to load egress rules")
+		return fmt.Errorf("get egress of worker %s: %w", w.WID, err)
+	}
+	routes, err := dblayer.GetWorkerRoutes(ctx, w.WID)
+	if err != nil {
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", "failed to load route rules")
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", "failed to load route rules")
+		return fmt.Errorf("get routes of worker %s: %w", w.WID, err)
+	}
+	edgeLimits, err := dblayer.GetWorkerEdgeLimits(ctx, w.WID)
+	if err != nil {
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", "failed to load edge limits")
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", "failed to load edge limits")
+		return fmt.Errorf("get edge limits of worker %s: %w", w.WID, err)
+	}
+	errorPages, err := dblayer.GetWorkerErrorPages(ctx, w.WID, w.UserUID)
+	if err != nil {
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", "failed to load error pages")
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", "failed to load error pages")
+		return fmt.Errorf("get error pages of worker %s: %w", w.WID, err)
+	}
+	static, err := staticSite(ctx, v, w.UserUID, sk)
+	if err != nil {
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", err.Error())
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", err.Error())
+		return fmt.Errorf("resolve static files of version %d: %w", j.VersionID, err)
+	}
+
 	if w.ActiveVersionID != nil {
 		// Try update first
-		err = controller.UpdateWorkerAppCR(k8s.DynamicClient, name, v.Image, v.Port)
+		err = controller.UpdateWorkerAppCR(ctx, k8s.DynamicClient, name, v.Image, v.Port, static)
 	}
 	if w.ActiveVersionID == nil || err != nil {
 		// First deploy or update failed (CR missing): create with full spec
 		err = controller.CreateWorkerAppCR(
-			k8s.DynamicClient, name,
+			ctx, k8s.DynamicClient, name,
 			w.WID, w.UserUID, v.Image, sk, v.Port,
-			w.AssignedCPU, w.AssignedMemory, w.AssignedDisk, w.MaxReplicas, w.MainRegion,
+			w.AssignedCPU, w.AssignedMemory, w.AssignedDisk, w.MaxReplicas, w.MainRegion, cluster.Name, w.Pool, w.Arch, runAsRoot, writableRootFS, w.Tags, egress, routes.Rules, edgeLimits,
+			k8s.RouteErrorPages{Maintenance: errorPages.Maintenance, Statuses: errorPages.Statuses()}, static,
 		)
 	}
 
 	if err != nil {
-		dblayer.UpdateDeployVersionStatus(j.VersionID, "error", err.Error())
-		dblayer.UpdateWorkerStatus(w.WID, "error")
+		dblayer.UpdateDeployVersionStatus(ctx, j.VersionID, "error", err.Error())
+		dblayer.UpdateWorkerStatus(ctx, w.WID, "error")
+		publishDeployEvent(ctx, j.UserUID, j.WorkerID, "error", err.Error())
 		return fmt.Errorf("deploy CR for version %d: %w", j.VersionID, err)
 	}
 
-	log.Printf("[worker] CR deployed for version %d", j.VersionID)
-	if err := dblayer.DeployVersionSuccess(j.VersionID, w.ID); err != nil {
-		log.Printf("[worker] update deploy status failed: %v", err)
+	jobLog(j).Info("CR deployed", "version_id", j.VersionID, "cluster", cluster.Name)
+	if err := dblayer.DeployVersionSuccess(ctx, j.VersionID, w.ID); err != nil {
+		jobLog(j).Error("update deploy status failed", "version_id", j.VersionID, "error", err)
 	}
+	publishDeployEvent(ctx, j.UserUID, j.WorkerID, "success", fmt.Sprintf("version %d applied", j.VersionID))
 	return nil
 }
 
+// securityOptOuts 返回套餐允许的 securityContext 放宽项，套餐降级后不再允许的项被忽略
+func securityOptOuts(ctx context.Context, w *dblayer.Worker) (runAsRoot, writableRootFS bool) {
+	name, err := dblayer.GetUserPlan(ctx, w.UserUID)
+	if err != nil {
+		jobsLog.Warn("get user plan failed, using default", "user_uid", w.UserUID, "error", err)
+	}
+	plan := k8s.PlanFor(name)
+	return w.RunAsRoot && plan.AllowRunAsRoot, w.WritableRootFS && plan.AllowWritableRootFS
+}
+
+// placeWorker 返回 worker 所在的集群：创建时指定了集群的用指定的，否则调度到负载最低的集群。
+// 结果写回库里，之后不再变化
+func placeWorker(ctx context.Context, w *dblayer.Worker) (*k8s.Cluster, error) {
+	if w.Cluster == "" && w.ActiveVersionID != nil {
+		// 引入多集群之前部署的 worker 都在本集群
+		w.Cluster = k8s.LocalCluster
+	}
+	var placed map[string]int
+	if w.Cluster == "" {
+		var err error
+		if placed, err = dblayer.CountWorkersByCluster(ctx); err != nil {
+			return nil, fmt.Errorf("count workers by cluster: %w", err)
+		}
+	}
+	c, err := k8s.Clusters.Schedule(w.Cluster, w.MainRegion, placed)
+	if err != nil {
+		return nil, err
+	}
+	name, err := dblayer.SetWorkerCluster(ctx, w.WID, w.UserUID, c.Name)
+	if err != nil {
+		return nil, fmt.Errorf("set worker cluster: %w", err)
+	}
+	return k8s.Clusters.Get(name)
+}
+
 type syncEnvJob struct {
 	WorkerID string            `json:"worker_id"`
 	UserUID  string            `json:"user_uid"`
@@ -97,6 +241,10 @@ func init() {
 	})
 }
 
+func (j *syncEnvJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *syncEnvJob) Type() k8s.JobType {
 	return JobTypeWorkerSyncEnv
 }
@@ -105,28 +253,55 @@ func (j *syncEnvJob) ID() string {
 	return j.WorkerID
 }
 
-func (j *syncEnvJob) Do() error {
+func (j *syncEnvJob) Do(ctx context.Context) error {
 	if k8s.K8sClient == nil {
 		return nil
 	}
+	cluster, err := controller.ClusterOf(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return nil // 已删除，或所在集群已注销
+	}
 	name := controller.WorkerName(j.WorkerID, j.UserUID) + "-env"
-	ctx := context.Background()
-	client := k8s.K8sClient.CoreV1().ConfigMaps(k8s.WorkerNamespace)
+	client := cluster.Kube.CoreV1().ConfigMaps(k8s.TenantNamespace(j.UserUID))
 
 	cm, err := client.Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil
 	}
+	// 所在环境的变量垫在最下面，上面是挂的 env 组（后挂的覆盖先挂的），最上面是 worker 自己的 env
+	base, err := dblayer.GetWorkerEnvironmentVars(ctx, j.WorkerID)
+	if err != nil {
+		return fmt.Errorf("get environment vars: %w", err)
+	}
+	groups, err := dblayer.GetWorkerEnvGroups(ctx, j.WorkerID)
+	if err != nil {
+		return fmt.Errorf("get env groups: %w", err)
+	}
+	env := dblayer.MergeEnvGroups(append([]*dblayer.EnvGroup{{Vars: base}}, groups...), j.Data)
+	// secretref:// 的值解密后写进 Secret，不进 ConfigMap
+	plain, resolved, err := resolveSecretRefs(ctx, j.WorkerID, j.UserUID, env)
+	if err != nil {
+		dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "error")
+		return err
+	}
+	secretName := controller.WorkerName(j.WorkerID, j.UserUID) + "-secret"
+	if err := applySecretRefs(ctx, cluster.Kube.CoreV1().Secrets(k8s.TenantNamespace(j.UserUID)), secretName, resolved); err != nil {
+		dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "error")
+		return fmt.Errorf("sync secret refs: %w", err)
+	}
 	// Strip reserved keys from ConfigMap
 	for _, key := range controller.ReservedEnvKeys {
-		delete(j.Data, key)
+		delete(plain, key)
 	}
-	cm.Data = j.Data
+	cm.Data = plain
 	if _, err = client.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
-		dblayer.UpdateWorkerStatus(j.WorkerID, "error")
+		dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "error")
 		return fmt.Errorf("sync env configmap: %w", err)
 	}
-	dblayer.UpdateWorkerStatus(j.WorkerID, "active")
+	if err := restartRemoteWorker(ctx, cluster, j.WorkerID, j.UserUID); err != nil {
+		return err
+	}
+	dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "active")
 	return nil
 }
 
@@ -150,6 +325,10 @@ func init() {
 	})
 }
 
+func (j *syncSecretJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *syncSecretJob) Type() k8s.JobType {
 	return JobTypeWorkerSyncSecret
 }
@@ -158,13 +337,16 @@ func (j *syncSecretJob) ID() string {
 	return j.WorkerID
 }
 
-func (j *syncSecretJob) Do() error {
+func (j *syncSecretJob) Do(ctx context.Context) error {
 	if k8s.K8sClient == nil {
 		return nil
 	}
+	cluster, err := controller.ClusterOf(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return nil // 已删除，或所在集群已注销
+	}
 	name := controller.WorkerName(j.WorkerID, j.UserUID) + "-secret"
-	ctx := context.Background()
-	client := k8s.K8sClient.CoreV1().Secrets(k8s.WorkerNamespace)
+	client := cluster.Kube.CoreV1().Secrets(k8s.TenantNamespace(j.UserUID))
 
 	sec, err := client.Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
@@ -178,11 +360,31 @@ func (j *syncSecretJob) Do() error {
 	for k, v := range j.Data {
 		sec.Data[k] = []byte(v)
 	}
+	// 直接设置的 key 不再由 secretref:// 管理，引用移除时不会被删掉
+	setSecretRefKeys(sec, slices.DeleteFunc(secretRefKeys(sec), func(k string) bool {
+		_, ok := j.Data[k]
+		return ok
+	}))
 	if _, err = client.Update(ctx, sec, metav1.UpdateOptions{}); err != nil {
-		dblayer.UpdateWorkerStatus(j.WorkerID, "error")
+		dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "error")
 		return fmt.Errorf("sync secret: %w", err)
 	}
-	dblayer.UpdateWorkerStatus(j.WorkerID, "active")
+	if err := restartRemoteWorker(ctx, cluster, j.WorkerID, j.UserUID); err != nil {
+		return err
+	}
+	dblayer.UpdateWorkerStatus(ctx, j.WorkerID, "active")
+	return nil
+}
+
+// restartRemoteWorker 远程集群没有 controller 的 informer，ConfigMap/Secret 改动后由任务自己滚动重启
+func restartRemoteWorker(ctx context.Context, cluster *k8s.Cluster, workerID, userUID string) error {
+	if cluster.Local {
+		return nil
+	}
+	err := controller.RestartWorkerDeployment(ctx, cluster.Kube, k8s.TenantNamespace(userUID), controller.WorkerName(workerID, userUID))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("restart worker on %s: %w", cluster.Name, err)
+	}
 	return nil
 }
 
@@ -204,6 +406,10 @@ func NewDeleteWorkerCRJob(workerID, userUID string) *deleteWorkerCRJob {
 	}
 }
 
+func (j *deleteWorkerCRJob) OwnerUID() string {
+	return j.UserUID
+}
+
 func (j *deleteWorkerCRJob) Type() k8s.JobType {
 	return JobTypeWorkerDeleteWorkerCR
 }
@@ -212,7 +418,59 @@ func (j *deleteWorkerCRJob) ID() string {
 	return j.WorkerID
 }
 
-func (j *deleteWorkerCRJob) Do() error {
+func (j *deleteWorkerCRJob) Do(ctx context.Context) error {
+	name := controller.WorkerName(j.WorkerID, j.UserUID)
+	return controller.DeleteWorkerAppCR(ctx, k8s.DynamicClient, name)
+}
+
+// syncSpecJob 把库里的资源配置同步到 WorkerApp CR，未部署过的 worker 在首次部署时带上
+type syncSpecJob struct {
+	WorkerID string `json:"worker_id"`
+	UserUID  string `json:"user_uid"`
+}
+
+func init() {
+	RegisterJobType(JobTypeWorkerSyncSpec, func() k8s.Job {
+		return &syncSpecJob{}
+	})
+}
+
+func NewSyncSpecJob(workerID, userUID string) k8s.Job {
+	return &syncSpecJob{
+		WorkerID: workerID,
+		UserUID:  userUID,
+	}
+}
+
+func (j *syncSpecJob) OwnerUID() string {
+	return j.UserUID
+}
+
+func (j *syncSpecJob) Type() k8s.JobType {
+	return JobTypeWorkerSyncSpec
+}
+
+func (j *syncSpecJob) ID() string {
+	return j.WorkerID
+}
+
+func (j *syncSpecJob) Do(ctx context.Context) error {
+	if k8s.DynamicClient == nil {
+		return nil
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, j.WorkerID, j.UserUID)
+	if err != nil {
+		return nil // 已删除
+	}
 	name := controller.WorkerName(j.WorkerID, j.UserUID)
-	return controller.DeleteWorkerAppCR(k8s.DynamicClient, name)
+	runAsRoot, writableRootFS := securityOptOuts(ctx, w)
+	err = controller.SetWorkerAppResources(ctx, k8s.DynamicClient, name,
+		w.AssignedCPU, w.AssignedMemory, w.AssignedDisk, w.MaxReplicas, w.MainRegion, w.Pool, w.Arch, runAsRoot, writableRootFS)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sync worker spec: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// errPlanLimit 超出套餐或账户限制，返回 403
+var errPlanLimit = errors.New("plan limit exceeded")
+
+func planLimitError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errPlanLimit, fmt.Sprintf(format, args...))
+}
+
+// limitResponse 超限为 403，其他（查库失败）为 500
+func limitResponse(c *gin.Context, err error) {
+	if errors.Is(err, errPlanLimit) {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	RequestLog(c).Error("check plan limits failed", "error", err)
+	c.JSON(500, gin.H{"error": "failed to check plan limits"})
+}
+
+// workerSpec 受套餐限制的 worker 配置
+type workerSpec struct {
+	CPU, Memory    string
+	MaxReplicas    int
+	RunAsRoot      bool
+	WritableRootFS bool
+}
+
+// checkWorkerLimits 创建（creating）或修改 worker 前检查 worker 数、副本数、CPU/内存上限和 securityContext 放宽项。
+// 部署时由 inner 的 controller.ValidateWorker 按同样的限制再查一遍
+func checkWorkerLimits(ctx context.Context, userUID string, creating bool, spec workerSpec) error {
+	limits, err := dblayer.GetUserLimits(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("get user limits: %w", err)
+	}
+	if creating {
+		n, err := dblayer.CountWorkers(ctx, userUID)
+		if err != nil {
+			return fmt.Errorf("count workers: %w", err)
+		}
+		if n >= limits.MaxWorkers {
+			return planLimitError("at most %d workers on plan %s", limits.MaxWorkers, limits.Plan)
+		}
+	}
+	if spec.MaxReplicas > limits.MaxReplicas {
+		return planLimitError("at most %d replicas per worker on plan %s", limits.MaxReplicas, limits.Plan)
+	}
+	if exceeds(spec.CPU, limits.MaxCPU) {
+		return planLimitError("assigned_cpu %s is above the %s allowed on plan %s", spec.CPU, limits.MaxCPU, limits.Plan)
+	}
+	if exceeds(spec.Memory, limits.MaxMemory) {
+		return planLimitError("assigned_memory %s is above the %s allowed on plan %s", spec.Memory, limits.MaxMemory, limits.Plan)
+	}
+	if err := k8s.PlanWithLimits(limits).CheckSecurityOptOuts(spec.RunAsRoot, spec.WritableRootFS); err != nil {
+		return fmt.Errorf("%w: %v", errPlanLimit, err)
+	}
+	return nil
+}
+
+// exceeds value 超过 ceiling；任一为空或无法解析时不算超出（格式由 binding 校验）
+func exceeds(value, ceiling string) bool {
+	if value == "" || ceiling == "" {
+		return false
+	}
+	v, err1 := resource.ParseQuantity(value)
+	c, err2 := resource.ParseQuantity(ceiling)
+	return err1 == nil && err2 == nil && v.Cmp(c) > 0
+}
+
+// checkDomainLimit 添加自定义域名前检查域名数
+func checkDomainLimit(ctx context.Context, userUID string) error {
+	limits, err := dblayer.GetUserLimits(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("get user limits: %w", err)
+	}
+	n, err := dblayer.CountCustomDomains(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("count custom domains: %w", err)
+	}
+	if n >= limits.MaxCustomDomains {
+		return planLimitError("at most %d custom domains on plan %s", limits.MaxCustomDomains, limits.Plan)
+	}
+	return nil
+}
+
+// checkRDBLimit 创建 RDB 前检查用户数据库已用空间，连不上 CockroachDB 时不拦
+func checkRDBLimit(ctx context.Context, userUID string) error {
+	if k8s.RDBManager == nil {
+		return nil
+	}
+	limits, err := dblayer.GetUserLimits(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("get user limits: %w", err)
+	}
+	size, err := k8s.RDBManager.DatabaseSize(userUID)
+	if err != nil {
+		return nil
+	}
+	if size >= limits.MaxRDBBytes {
+		return planLimitError("RDB storage %d bytes has reached the %d bytes of plan %s", size, limits.MaxRDBBytes, limits.Plan)
+	}
+	return nil
+}
+
+// checkBucketLimit 创建 bucket 前检查用户所有 bucket 最近一次测量的大小之和
+func checkBucketLimit(ctx context.Context, userUID string) error {
+	limits, err := dblayer.GetUserLimits(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("get user limits: %w", err)
+	}
+	size, err := dblayer.SumBucketBytes(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("sum bucket size: %w", err)
+	}
+	if size >= limits.MaxBucketBytes {
+		return planLimitError("bucket storage %d bytes has reached the %d bytes of plan %s", size, limits.MaxBucketBytes, limits.Plan)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListQueryParams 列表接口支持的 query 参数（用于 OpenAPI 文档）
+var ListQueryParams = []string{"limit", "cursor", "sort", "order", "status", "type", "tag", "environment"}
+
+// parseListOptions 从 query 解析 limit/cursor/sort/order/status/type/tag/environment，
+// tag 可重复（?tag=env:prod&tag=team），多个条件同时满足
+func parseListOptions(c *gin.Context) (dblayer.ListOptions, error) {
+	opts := dblayer.ListOptions{
+		Cursor:      c.Query("cursor"),
+		Status:      c.Query("status"),
+		Type:        c.Query("type"),
+		Environment: c.Query("environment"),
+	}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > dblayer.MaxListLimit {
+			return opts, fmt.Errorf("limit must be between 1 and %d", dblayer.MaxListLimit)
+		}
+		opts.Limit = n
+	}
+
+	switch v := c.DefaultQuery("sort", dblayer.SortByCreatedAt); v {
+	case dblayer.SortByCreatedAt, dblayer.SortByName:
+		opts.Sort = v
+	default:
+		return opts, fmt.Errorf("sort must be %s or %s", dblayer.SortByCreatedAt, dblayer.SortByName)
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		opts.Asc = true
+	case "desc":
+	default:
+		return opts, fmt.Errorf("order must be asc or desc")
+	}
+
+	for _, v := range c.QueryArray("tag") {
+		f, err := dblayer.ParseTagFilter(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.Tags = append(opts.Tags, f)
+	}
+
+	return opts, nil
+}
+
+// parseUntaggedListOptions 没有 tags、type 和 environment 列的表（告警、拨测等）只接受分页、排序和 status
+func parseUntaggedListOptions(c *gin.Context) (dblayer.ListOptions, error) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return opts, err
+	}
+	if len(opts.Tags) > 0 || opts.Type != "" || opts.Environment != "" {
+		return opts, errors.New("tag, type and environment filters are not supported here")
+	}
+	return opts, nil
+}
+
+// setNextCursor 通过响应头返回下一页游标
+func setNextCursor(c *gin.Context, next string) {
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
+}
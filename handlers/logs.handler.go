@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 日志默认回放的行数与上限
+const (
+	DefaultLogTail = 100
+	MaxLogTail     = 5000
+)
+
+// StreamWorkerLogs (inner) 以 NDJSON 逐行推送 worker 所有 pod 的日志，直到调用方断开。
+// 只在集群内暴露，outer 校验归属后转发给客户端
+func (h *WorkerHandler) StreamWorkerLogs(c *gin.Context) {
+	workerID := c.Query("worker_id")
+	userUID := c.Query("user_uid")
+	if workerID == "" || userUID == "" {
+		c.JSON(400, gin.H{"error": "worker_id and user_uid are required"})
+		return
+	}
+	tail, err := parseLogTail(c.Query("tail"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if k8s.K8sClient == nil {
+		c.JSON(503, gin.H{"error": "k8s client not initialized"})
+		return
+	}
+	cluster, err := controller.ClusterOf(c.Request.Context(), workerID, userUID)
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(200)
+	c.Writer.Flush()
+
+	// 停机时结束日志流，outer 会通知客户端重新订阅
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-Draining():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	enc := json.NewEncoder(c.Writer)
+	err = controller.StreamWorkerLogs(ctx, cluster, k8s.TenantNamespace(userUID), controller.WorkerName(workerID, userUID), tail,
+		func(line controller.LogLine) {
+			enc.Encode(line)
+			c.Writer.Flush()
+		})
+	if err != nil {
+		enc.Encode(controller.LogLine{Line: "[console] " + err.Error()})
+	}
+}
+
+func parseLogTail(v string) (int64, error) {
+	if v == "" {
+		return DefaultLogTail, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || n > MaxLogTail {
+		return 0, fmt.Errorf("tail must be between 0 and %d", MaxLogTail)
+	}
+	return n, nil
+}
+
+// openWorkerLogs (outer) 打开 inner 的日志流，返回 NDJSON body，ctx 取消时断开
+func openWorkerLogs(ctx context.Context, workerID, userUID string, tail int64) (io.ReadCloser, error) {
+	q := url.Values{
+		"worker_id": {workerID},
+		"user_uid":  {userUID},
+		"tail":      {strconv.FormatInt(tail, 10)},
+	}
+	endpoint := fmt.Sprintf("%s%s/worker/logs?%s", k8s.ControlPlaneInnerEndpoint, APIPrefixV1, q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := innerClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var body ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return nil, fmt.Errorf("log stream rejected: %s", body.Error)
+	}
+	return resp.Body, nil
+}
+
+// 历史日志查询的时间窗口与条数限制，窗口上限与日志存储的保留期一致
+const (
+	DefaultLogHistoryWindow = time.Hour
+	MaxLogHistoryWindow     = 30 * 24 * time.Hour
+	DefaultLogHistoryLimit  = 100
+	MaxLogHistoryLimit      = 1000
+	MaxLogHistoryQueryLen   = 256
+)
+
+// WorkerLogHistory 查询日志存储中保留的 worker 日志（pod 删除后仍可查），
+// 以 owner 作为存储租户，只能查到自己的日志
+func (h *WorkerHandler) WorkerLogHistory(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+
+	now := time.Now()
+	until, err := parseLogTime(c.Query("until"), now, now)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid until: " + err.Error()})
+		return
+	}
+	since, err := parseLogTime(c.Query("since"), now, until.Add(-DefaultLogHistoryWindow))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid since: " + err.Error()})
+		return
+	}
+	if !since.Before(until) {
+		c.JSON(400, gin.H{"error": "since must be before until"})
+		return
+	}
+	if until.Sub(since) > MaxLogHistoryWindow {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("time range must not exceed %s", MaxLogHistoryWindow)})
+		return
+	}
+
+	query := c.Query("query")
+	if len(query) > MaxLogHistoryQueryLen {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("query must be at most %d characters", MaxLogHistoryQueryLen)})
+		return
+	}
+
+	limit := DefaultLogHistoryLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLogHistoryLimit {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", MaxLogHistoryLimit)})
+			return
+		}
+		limit = n
+	}
+
+	entries, err := k8s.QueryWorkerLogs(c.Request.Context(), k8s.WorkerLogQuery{
+		OwnerID:  userUID,
+		WorkerID: workerID,
+		Since:    since,
+		Until:    until,
+		Contains: query,
+		Limit:    limit,
+	})
+	if err != nil {
+		RequestLog(c).Error("query worker logs failed", "worker_id", workerID, "error", err)
+		c.JSON(502, gin.H{"error": "log store unavailable"})
+		return
+	}
+	if entries == nil {
+		entries = []k8s.WorkerLogEntry{}
+	}
+
+	c.JSON(200, WorkerLogHistoryResponse{
+		WorkerID: workerID,
+		Since:    since,
+		Until:    until,
+		Entries:  entries,
+	})
+}
+
+// parseLogTime 接受 RFC3339 时间或相对 now 的时长（如 6h 表示 6 小时前），为空时返回 def
+func parseLogTime(v string, now, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("expected RFC3339 time or a duration like 6h")
+	}
+	return now.Add(-d), nil
+}
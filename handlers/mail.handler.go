@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 平台 SMTP 中继：用户在 outer 管理发信域名、SMTP 凭据、退订名单并查看发送日志；
+// 中继（k8s.MailRelay 说明）每封信先调 inner 的 /mail/authorize，投递结果再报给 /mail/events
+
+// 发信的限制
+const (
+	MaxMailDomains     = 20
+	MaxMailCredentials = 20
+	mailQuotaWindow    = 24 * time.Hour
+)
+
+// errMailRelayDisabled 没有配置 MAIL_RELAY_HOST，返回 503
+var errMailRelayDisabled = errors.New("mail relay is not configured")
+
+// normalizeAddress 地址统一小写，退订名单和发送日志按它匹配
+func normalizeAddress(addr string) string {
+	return strings.ToLower(strings.TrimSpace(addr))
+}
+
+// mailPasswordHash SMTP 密码是随机生成的，存 SHA-256 就够了，中继每封信都要校验，不用 bcrypt
+func mailPasswordHash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// mailDomainResponse 发信域名加上要发布的 DNS 记录
+func mailDomainResponse(d *dblayer.MailDomain) MailDomainResponse {
+	resp := MailDomainResponse{MailDomain: d, Records: []k8s.MailDNSRecord{}}
+	if k8s.MailRelay != nil {
+		resp.Records = k8s.MailRelay.MailDomainRecords(d)
+	}
+	return resp
+}
+
+// GetMailOverview GET /api/mail 中继的连接方式和配额用量
+func GetMailOverview(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	limits, err := dblayer.GetUserLimits(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("get user limits failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to read mail quota"})
+		return
+	}
+	sent, err := dblayer.CountMailSent(ctx, userUID, time.Now().UTC().Add(-mailQuotaWindow))
+	if err != nil {
+		RequestLog(c).Error("count sent mail failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to read mail quota"})
+		return
+	}
+	resp := MailOverviewResponse{DailyQuota: limits.MaxEmailsPerDay, SentLast24h: sent}
+	if relay := k8s.MailRelay; relay != nil {
+		resp.Enabled, resp.Host, resp.Port = true, relay.Host, relay.Port
+	}
+	c.JSON(200, resp)
+}
+
+// ListMailDomains GET /api/mail/domains 用户的发信域名
+func ListMailDomains(c *gin.Context) {
+	domains, err := dblayer.ListMailDomains(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		RequestLog(c).Error("list mail domains failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list mail domains"})
+		return
+	}
+	resp := ListMailDomainsResponse{Domains: []MailDomainResponse{}}
+	for _, d := range domains {
+		resp.Domains = append(resp.Domains, mailDomainResponse(d))
+	}
+	c.JSON(200, resp)
+}
+
+// AddMailDomain POST /api/mail/domains 添加发信域名并生成 DKIM 密钥。
+// 用户已有同名自定义域名时沿用它的归属 TXT 记录
+func AddMailDomain(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	var req AddMailDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if k8s.MailRelay == nil {
+		c.JSON(503, gin.H{"error": errMailRelayDisabled.Error()})
+		return
+	}
+	n, err := dblayer.CountMailDomains(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("count mail domains failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to add mail domain"})
+		return
+	}
+	if n >= MaxMailDomains {
+		c.JSON(403, gin.H{"error": fmt.Sprintf("at most %d mail domains", MaxMailDomains)})
+		return
+	}
+
+	domain := strings.ToLower(strings.TrimSuffix(req.Domain, "."))
+	txtName, txtValue, err := dblayer.DomainVerifyRecord(ctx, userUID, domain)
+	if err == dblayer.ErrNotFound {
+		txtName, txtValue = k8s.NewDomainVerifyRecord(domain)
+	} else if err != nil {
+		RequestLog(c).Error("read domain verify record failed", "domain", domain, "error", err)
+		c.JSON(500, gin.H{"error": "failed to add mail domain"})
+		return
+	}
+	privateKey, publicKey, err := k8s.NewDKIMKey()
+	if err != nil {
+		RequestLog(c).Error("generate DKIM key failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to add mail domain"})
+		return
+	}
+	d := &dblayer.MailDomain{
+		MDID:           GenerateResourceUID()[:8],
+		UserUID:        userUID,
+		Domain:         domain,
+		TXTName:        txtName,
+		TXTValue:       txtValue,
+		DKIMSelector:   k8s.MailRelay.DKIMSelector,
+		DKIMPublicKey:  publicKey,
+		DKIMPrivateKey: privateKey,
+	}
+	if err := dblayer.CreateMailDomain(ctx, d); err != nil {
+		if err == dblayer.ErrMailDomainExists {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		RequestLog(c).Error("create mail domain failed", "domain", domain, "error", err)
+		c.JSON(500, gin.H{"error": "failed to add mail domain"})
+		return
+	}
+	c.JSON(201, mailDomainResponse(d))
+}
+
+// GetMailDomain GET /api/mail/domains/:id
+func GetMailDomain(c *gin.Context) {
+	d, ok := ownedMailDomain(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, mailDomainResponse(d))
+}
+
+// VerifyMailDomain POST /api/mail/domains/:id/verify 立即查一遍 DNS，不用等 mail.check_domains
+func VerifyMailDomain(c *gin.Context) {
+	d, ok := ownedMailDomain(c)
+	if !ok {
+		return
+	}
+	if k8s.MailRelay == nil {
+		c.JSON(503, gin.H{"error": errMailRelayDisabled.Error()})
+		return
+	}
+	k8s.MailRelay.CheckMailDomain(d)
+	if err := dblayer.SetMailDomainCheck(c.Request.Context(), d); err != nil {
+		RequestLog(c).Error("save mail domain check failed", "domain", d.Domain, "error", err)
+		c.JSON(500, gin.H{"error": "failed to verify mail domain"})
+		return
+	}
+	c.JSON(200, mailDomainResponse(d))
+}
+
+// DeleteMailDomain DELETE /api/mail/domains/:id 删除后不能再用这个域名发信
+func DeleteMailDomain(c *gin.Context) {
+	err := dblayer.DeleteMailDomain(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "mail domain not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("delete mail domain failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete mail domain"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// ownedMailDomain 按 :id 取当前用户的发信域名，不存在时已写好 404
+func ownedMailDomain(c *gin.Context) (*dblayer.MailDomain, bool) {
+	d, err := dblayer.GetMailDomain(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "mail domain not found"})
+		return nil, false
+	}
+	if err != nil {
+		RequestLog(c).Error("get mail domain failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get mail domain"})
+		return nil, false
+	}
+	return d, true
+}
+
+// ListMailCredentials GET /api/mail/credentials
+func ListMailCredentials(c *gin.Context) {
+	creds, err := dblayer.ListMailCredentials(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		RequestLog(c).Error("list mail credentials failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list mail credentials"})
+		return
+	}
+	c.JSON(200, ListMailCredentialsResponse{Credentials: creds})
+}
+
+// CreateMailCredential POST /api/mail/credentials 生成 SMTP 用户名和密码，密码只在这里返回一次
+func CreateMailCredential(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	var req CreateMailCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	n, err := dblayer.CountMailCredentials(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("count mail credentials failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create mail credential"})
+		return
+	}
+	if n >= MaxMailCredentials {
+		c.JSON(403, gin.H{"error": fmt.Sprintf("at most %d mail credentials", MaxMailCredentials)})
+		return
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	password := base64.RawURLEncoding.EncodeToString(secret)
+	cred, err := dblayer.CreateMailCredential(ctx, userUID, req.Name, "smtp_"+GenerateResourceUID(), mailPasswordHash(password))
+	if err != nil {
+		if err == dblayer.ErrMailCredentialExists {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		RequestLog(c).Error("create mail credential failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create mail credential"})
+		return
+	}
+	c.JSON(201, CreateMailCredentialResponse{MailCredential: cred, Password: password})
+}
+
+// DeleteMailCredential DELETE /api/mail/credentials/:id 凭据立即失效
+func DeleteMailCredential(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err == nil {
+		err = dblayer.DeleteMailCredential(c.Request.Context(), c.GetString("user_id"), id)
+	} else {
+		err = dblayer.ErrNotFound
+	}
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "mail credential not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("delete mail credential failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete mail credential"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// ListMailSuppressions GET /api/mail/suppressions 分页列出退订名单，?reason=bounce|complaint|manual 过滤
+func ListMailSuppressions(c *gin.Context) {
+	opts, err := parseUntaggedListOptions(c)
+	if err == nil && opts.Status != "" {
+		err = fmt.Errorf("status filter is not supported here, use reason")
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	reason := c.Query("reason")
+	switch reason {
+	case "", dblayer.MailSuppressBounce, dblayer.MailSuppressComplaint, dblayer.MailSuppressManual:
+	default:
+		c.JSON(400, gin.H{"error": "reason must be bounce, complaint or manual"})
+		return
+	}
+
+	list, next, err := dblayer.ListMailSuppressions(c.Request.Context(), c.GetString("user_id"), reason, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("list mail suppressions failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list suppressions"})
+		return
+	}
+	if list == nil {
+		list = []*dblayer.MailSuppression{}
+	}
+	setNextCursor(c, next)
+	c.JSON(200, ListMailSuppressionsResponse{Suppressions: list, NextCursor: next})
+}
+
+// AddMailSuppression POST /api/mail/suppressions 手动把地址加入退订名单，已在名单里时不变
+func AddMailSuppression(c *gin.Context) {
+	var req AddMailSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	address := normalizeAddress(req.Address)
+	if _, err := dblayer.AddMailSuppression(c.Request.Context(), c.GetString("user_id"), address, dblayer.MailSuppressManual, req.Detail); err != nil {
+		RequestLog(c).Error("add mail suppression failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to add suppression"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "suppressed"})
+}
+
+// DeleteMailSuppression DELETE /api/mail/suppressions/:address 再次允许发给这个地址
+func DeleteMailSuppression(c *gin.Context) {
+	err := dblayer.DeleteMailSuppression(c.Request.Context(), c.GetString("user_id"), normalizeAddress(c.Param("address")))
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "address is not suppressed"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("delete mail suppression failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete suppression"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// ListMailLogs GET /api/mail/logs 分页列出发送日志，?status= 和 ?recipient= 过滤
+func ListMailLogs(c *gin.Context) {
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	msgs, next, err := dblayer.ListMailMessages(c.Request.Context(), c.GetString("user_id"), normalizeAddress(c.Query("recipient")), opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("list mail messages failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list mail logs"})
+		return
+	}
+	if msgs == nil {
+		msgs = []*dblayer.MailMessage{}
+	}
+	setNextCursor(c, next)
+	c.JSON(200, ListMailMessagesResponse{Messages: msgs, NextCursor: next})
+}
+
+// MailAuthorize POST /api/v1/mail/authorize (inner) 中继接收一封信之前的检查：凭据、发信域名已验证、
+// 收件人不在退订名单、最近 24 小时没超出配额。每个收件人都记进发送日志（被拒绝的记为 rejected）。
+// 配额在并发请求之间不加锁，可能略微超出
+func MailAuthorize(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req MailAuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	userUID, hash, err := dblayer.GetMailCredentialHash(ctx, req.Username)
+	if err != nil && err != dblayer.ErrNotFound {
+		RequestLog(c).Error("read mail credential failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to check credentials"})
+		return
+	}
+	if err == dblayer.ErrNotFound || subtle.ConstantTimeCompare([]byte(hash), []byte(mailPasswordHash(req.Password))) != 1 {
+		c.JSON(401, gin.H{"error": "invalid credentials"})
+		return
+	}
+	if err := dblayer.TouchMailCredential(ctx, req.Username); err != nil {
+		RequestLog(c).Warn("touch mail credential failed", "error", err)
+	}
+	resp := MailAuthorizeResponse{UserUID: userUID, Accepted: []string{}, Rejected: []MailRejection{}}
+	if len(req.Recipients) == 0 {
+		c.JSON(200, resp)
+		return
+	}
+
+	from := normalizeAddress(req.From)
+	_, domain, found := strings.Cut(from, "@")
+	if !found {
+		c.JSON(400, gin.H{"error": "from is required with recipients"})
+		return
+	}
+	d, err := dblayer.GetVerifiedMailDomain(ctx, userUID, domain)
+	if err == dblayer.ErrNotFound {
+		c.JSON(403, gin.H{"error": fmt.Sprintf("sender domain %s is not verified", domain)})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get mail domain failed", "domain", domain, "error", err)
+		c.JSON(500, gin.H{"error": "failed to check sender domain"})
+		return
+	}
+
+	var recipients []string
+	seen := map[string]bool{}
+	for _, r := range req.Recipients {
+		if r = normalizeAddress(r); !seen[r] {
+			seen[r] = true
+			recipients = append(recipients, r)
+		}
+	}
+	suppressed, err := dblayer.SuppressedAddresses(ctx, userUID, recipients)
+	if err != nil {
+		RequestLog(c).Error("read suppressions failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to check recipients"})
+		return
+	}
+	limits, err := dblayer.GetUserLimits(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("get user limits failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to check quota"})
+		return
+	}
+
+	resp.QueueID = GenerateResourceUID()
+	err = dblayer.InsertMailMessages(ctx, userUID, time.Now().UTC().Add(-mailQuotaWindow), func(sent int) []dblayer.MailMessage {
+		remaining := limits.MaxEmailsPerDay - sent
+		msgs := make([]dblayer.MailMessage, 0, len(recipients))
+		for _, r := range recipients {
+			m := dblayer.MailMessage{
+				QueueID:   resp.QueueID,
+				MessageID: req.MessageID,
+				Username:  req.Username,
+				From:      from,
+				Recipient: r,
+				Subject:   strings.ToValidUTF8(clip(req.Subject, 255), ""),
+				Status:    dblayer.MailStatusQueued,
+			}
+			switch {
+			case suppressed[r]:
+				m.Status, m.Reason = dblayer.MailStatusRejected, "recipient is on the suppression list"
+			case remaining <= 0:
+				m.Status, m.Reason = dblayer.MailStatusRejected, fmt.Sprintf("daily quota of %d emails reached", limits.MaxEmailsPerDay)
+			default:
+				remaining--
+			}
+			if m.Status == dblayer.MailStatusRejected {
+				resp.Rejected = append(resp.Rejected, MailRejection{Recipient: r, Reason: m.Reason})
+			} else {
+				resp.Accepted = append(resp.Accepted, r)
+			}
+			msgs = append(msgs, m)
+		}
+		return msgs
+	})
+	if err != nil {
+		RequestLog(c).Error("record mail messages failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to record message"})
+		return
+	}
+	if len(resp.Accepted) > 0 {
+		resp.DKIM = &MailDKIMKey{Domain: d.Domain, Selector: d.DKIMSelector, PrivateKey: d.DKIMPrivateKey}
+	}
+	c.JSON(200, resp)
+}
+
+// MailEvents POST /api/v1/mail/events (inner) 中继上报投递结果。永久退信和投诉的地址加入发信用户的退订名单；
+// 找不到的 queue_id 和已经退信、投诉的收件人跳过
+func MailEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req MailEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	var resp MailEventsResponse
+	for _, ev := range req.Events {
+		recipient := normalizeAddress(ev.Recipient)
+		userUID, err := dblayer.UpdateMailMessageStatus(ctx, ev.QueueID, recipient, ev.Status, strings.ToValidUTF8(clip(ev.Reason, 1024), ""))
+		if err == dblayer.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			RequestLog(c).Error("update mail message failed", "queue_id", ev.QueueID, "error", err)
+			c.JSON(500, gin.H{"error": "failed to store mail events"})
+			return
+		}
+		resp.Updated++
+
+		reason := ""
+		switch {
+		case ev.Status == dblayer.MailStatusBounced && ev.Permanent:
+			reason = dblayer.MailSuppressBounce
+		case ev.Status == dblayer.MailStatusComplained:
+			reason = dblayer.MailSuppressComplaint
+		default:
+			continue
+		}
+		added, err := dblayer.AddMailSuppression(ctx, userUID, recipient, reason, strings.ToValidUTF8(clip(ev.Reason, 1024), ""))
+		if err != nil {
+			RequestLog(c).Error("add mail suppression failed", "queue_id", ev.QueueID, "error", err)
+			c.JSON(500, gin.H{"error": "failed to store mail events"})
+			return
+		}
+		if added {
+			resp.Suppressed++
+		}
+	}
+	c.JSON(200, resp)
+}
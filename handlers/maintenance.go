@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"jabberwocky238/console/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 维护模式：打开后外网 API 的写操作返回 503，读操作照常。开关是运行时配置，
+// PUT /admin/settings/maintenance.enabled 即时生效，不需要重启
+
+var (
+	maintenanceEnabled = settings.NewBool("maintenance.enabled", false,
+		"Reject mutating API requests with 503 while reads keep working")
+	maintenanceMessage = settings.NewString("maintenance.message",
+		"The console is under maintenance, changes are disabled for now", nil,
+		"Message returned with the 503 of maintenance mode")
+)
+
+// maintenanceAllowed 维护模式下仍放行的非 GET 路由（相对 API 前缀）：登录相关和只读的 POST
+var maintenanceAllowed = map[string]bool{
+	"POST /auth/login":                true,
+	"POST /auth/logout":               true,
+	"POST /auth/send-code":            true,
+	"POST /worker/estimate":           true,
+	"POST /worker/:id/env/reveal":     true,
+	"POST /account/secret-key/reveal": true,
+}
+
+func currentMaintenance() MaintenanceStatus {
+	status := MaintenanceStatus{Enabled: maintenanceEnabled.Get()}
+	if status.Enabled {
+		status.Message = maintenanceMessage.Get()
+	}
+	return status
+}
+
+// Maintenance 维护模式打开时拒绝 /api 下的写请求；GET/HEAD/OPTIONS 和 maintenanceAllowed 放行
+func Maintenance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !maintenanceEnabled.Get() {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		if !strings.HasPrefix(route, LegacyAPIPrefix+"/") {
+			c.Next()
+			return
+		}
+		if rest, ok := strings.CutPrefix(route, APIPrefixV1); ok {
+			route = rest
+		} else {
+			route = strings.TrimPrefix(route, LegacyAPIPrefix)
+		}
+		if maintenanceAllowed[c.Request.Method+" "+route] {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", "300")
+		c.AbortWithStatusJSON(503, MaintenanceErrorResponse{Error: maintenanceMessage.Get(), Maintenance: true})
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"sigs.k8s.io/yaml"
+)
+
+// console.yaml：一个 worker 连同 env、域名和挂载的 RDB/KV 的声明式描述。POST /apply 复用 import 的
+// configPlan，按 worker → env → 挂载 → 域名 → 部署的顺序应用，新 worker 首次部署时 env 和挂载已就绪
+
+var errWorkerNotCreated = errors.New("skipped, the worker was not created")
+
+// ApplyManifest 把 console.yaml 与 worker 的现状对比并应用，重复应用同一文件不会产生变更。
+// ?dry_run=true 只返回变更计划；?prune=true 同时删除文件中没有的 env、挂载和指向该 worker 的域名
+func ApplyManifest(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	ctx := c.Request.Context()
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read manifest: " + err.Error()})
+		return
+	}
+	var m WorkerManifest
+	if err := yaml.UnmarshalStrict(body, &m); err != nil {
+		c.JSON(400, gin.H{"error": "invalid manifest: " + err.Error()})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(m); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if m.Image == "" {
+		c.JSON(400, gin.H{"error": "image is required, consolectl apply builds the build context and fills it in"})
+		return
+	}
+	if err := m.check(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, err := loadUserState(dblayer.WithPrimary(ctx), userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load resources: " + err.Error()})
+		return
+	}
+
+	p := &configPlan{
+		ctx:     ctx,
+		userUID: userUID,
+		prune:   c.Query("prune") == "true",
+		resp:    ImportConfigResponse{DryRun: c.Query("dry_run") == "true", Changes: []ConfigChange{}},
+	}
+	a := &manifestApply{configPlan: p, m: &m}
+	a.worker(state.workers)
+	a.env()
+	a.attachments(state)
+	a.domains(state.domains)
+	a.deploy()
+
+	resp := ApplyManifestResponse{ImportConfigResponse: p.resp}
+	if a.w != nil {
+		resp.WorkerID, resp.URL = a.w.WID, workerURL(a.w.WID, userUID)
+	}
+	c.JSON(200, resp)
+}
+
+// check 文件内的重复项和 env 名冲突
+func (m *WorkerManifest) check() error {
+	for k := range m.Env {
+		if k == "" || slices.Contains(controller.ReservedEnvKeys, k) {
+			return fmt.Errorf("env %q is reserved", k)
+		}
+	}
+	domains := map[string]bool{}
+	for _, d := range m.Domains {
+		if domains[d.Domain] {
+			return fmt.Errorf("duplicate domain %q", d.Domain)
+		}
+		domains[d.Domain] = true
+	}
+	resources, envNames := map[string]bool{}, map[string]bool{}
+	for i := range m.Attach {
+		at := &m.Attach[i]
+		if at.Env == "" {
+			at.Env = defaultAttachEnv[at.Type]
+		}
+		if !envNamePattern.MatchString(at.Env) || slices.Contains(controller.ReservedEnvKeys, at.Env) {
+			return fmt.Errorf("invalid env name %s", at.Env)
+		}
+		if _, ok := m.Env[at.Env]; ok || envNames[at.Env] {
+			return fmt.Errorf("env %s is set by more than one entry", at.Env)
+		}
+		if resources[at.Type+"/"+at.Name] {
+			return fmt.Errorf("duplicate attach %s %q", at.Type, at.Name)
+		}
+		envNames[at.Env], resources[at.Type+"/"+at.Name] = true, true
+	}
+	return nil
+}
+
+// manifestApply 应用一个 manifest；w 是对应的 worker，新建时在执行后才有，dry run 时一直为 nil
+type manifestApply struct {
+	*configPlan
+	m       *WorkerManifest
+	w       *dblayer.Worker
+	created bool
+}
+
+func (a *manifestApply) config() WorkerConfig {
+	m := a.m
+	return WorkerConfig{
+		Name:           m.Name,
+		AssignedCPU:    m.Resources.CPU,
+		AssignedMemory: m.Resources.Memory,
+		AssignedDisk:   m.Resources.Disk,
+		MaxReplicas:    m.Resources.MaxReplicas,
+		MainRegion:     m.MainRegion,
+		Pool:           m.Pool,
+		Arch:           m.Arch,
+		Tags:           m.Tags,
+	}
+}
+
+// target 后续步骤的 worker ID；worker 没建成时返回 errWorkerNotCreated
+func (a *manifestApply) target() (string, error) {
+	if a.w == nil {
+		return "", errWorkerNotCreated
+	}
+	return a.w.WID, nil
+}
+
+func (a *manifestApply) worker(have []*dblayer.Worker) {
+	for _, w := range have {
+		if w.WorkerName != a.m.Name {
+			continue
+		}
+		if a.w != nil {
+			a.warn("worker %q: several workers share this name, only %s is managed", a.m.Name, a.w.WID)
+			break
+		}
+		a.w = w
+	}
+	if a.w != nil {
+		a.updateWorker(a.config(), a.w)
+		return
+	}
+
+	a.created = true
+	a.add(ConfigChange{Action: "create", Type: "worker", Name: a.m.Name}, func() (string, error) {
+		workerID, err := a.createWorker(a.config())
+		if err != nil {
+			return "", err
+		}
+		if a.w, err = dblayer.GetWorkerByOwner(a.ctx, workerID, a.userUID); err != nil {
+			return workerID, err
+		}
+		return workerID, nil
+	})
+}
+
+// env 值不同的逐个设置，prune 时删除文件中没有的；改动后同步一次
+func (a *manifestApply) env() {
+	current := map[string]string{}
+	if a.w != nil {
+		json.Unmarshal([]byte(a.w.EnvJSON), &current)
+	}
+	var changes []WorkerKeyValueRequest
+	for _, k := range slices.Sorted(maps.Keys(a.m.Env)) {
+		if v, ok := current[k]; !ok || v != a.m.Env[k] {
+			changes = append(changes, WorkerKeyValueRequest{Key: k, Value: a.m.Env[k]})
+		}
+	}
+	if a.prune {
+		for _, k := range slices.Sorted(maps.Keys(current)) {
+			if _, ok := a.m.Env[k]; !ok && !slices.Contains(controller.ReservedEnvKeys, k) {
+				changes = append(changes, WorkerKeyValueRequest{Key: k, Delete: true})
+			}
+		}
+	}
+
+	var env map[string]string
+	for _, kv := range changes {
+		action := "update"
+		if kv.Delete {
+			action = "delete"
+		}
+		// 值可能是敏感信息，不写进响应
+		a.add(ConfigChange{Action: action, Type: "env", Name: kv.Key}, func() (string, error) {
+			workerID, err := a.target()
+			if err != nil {
+				return "", err
+			}
+			merged, _, err := mergeWorkerEnv(a.ctx, workerID, a.userUID, kv)
+			if err != nil {
+				return "", err
+			}
+			env = merged
+			return "", nil
+		})
+	}
+	if env != nil {
+		if err := SendTask(jobs.NewSyncEnvJob(a.w.WID, a.userUID, env)); err != nil {
+			a.warn("worker %q: env saved but %s", a.m.Name, errEnqueueSync)
+		}
+	}
+}
+
+// attachments 按类型和名字找资源，不存在时创建；已挂载但 env 名不同的重新挂载
+func (a *manifestApply) attachments(state *userState) {
+	var current []*dblayer.WorkerAttachment
+	if a.w != nil {
+		var err error
+		if current, err = dblayer.ListWorkerAttachments(a.ctx, a.w.WID, a.userUID); err != nil {
+			a.warn("worker %q: failed to list attachments: %v", a.m.Name, err)
+			return
+		}
+	}
+	kept := map[*dblayer.WorkerAttachment]bool{}
+
+	for _, at := range a.m.Attach {
+		have := state.rdbs
+		if at.Type == "kv" {
+			have = state.kvs
+		}
+		var r *dblayer.CombinatorResource
+		for _, res := range have {
+			if res.Name == at.Name {
+				r = res
+				break
+			}
+		}
+		resourceID := ""
+		var old *dblayer.WorkerAttachment // 已挂载时执行前先取消
+		if r == nil {
+			a.add(ConfigChange{Action: "create", Type: at.Type, Name: at.Name}, func() (string, error) {
+				// 新建的资源和 worker 在同一个环境
+				environment := ""
+				if a.w != nil {
+					environment = a.w.Environment
+				}
+				id, err := createCombinatorResource(a.ctx, a.userUID, at.Type, at.Name, nil, environment)
+				resourceID = id
+				return id, err
+			})
+		} else {
+			resourceID = r.ResourceID
+			if i := slices.IndexFunc(current, func(c *dblayer.WorkerAttachment) bool {
+				return c.ResourceType == at.Type && c.ResourceID == r.ResourceID
+			}); i >= 0 {
+				old = current[i]
+				kept[old] = true
+				if old.EnvName == at.Env {
+					continue
+				}
+			}
+		}
+
+		ch := ConfigChange{Action: "create", Type: "attachment", Name: at.Type + "/" + at.Name, ID: resourceID, Detail: "env " + at.Env}
+		if old != nil {
+			ch.Action, ch.Detail = "update", fmt.Sprintf("env %s -> %s", old.EnvName, at.Env)
+		}
+		a.add(ch, func() (string, error) {
+			workerID, err := a.target()
+			if err != nil {
+				return "", err
+			}
+			if resourceID == "" {
+				return "", errors.New("skipped, the resource was not created")
+			}
+			if taken, err := workerEnvKeyTaken(a.ctx, workerID, a.userUID, at.Env); err != nil {
+				return "", err
+			} else if taken {
+				return "", fmt.Errorf("worker already has an env or secret named %s", at.Env)
+			}
+			return "", a.syncAttachment(workerID, func(tx *sql.Tx) error {
+				if old != nil {
+					if err := dblayer.DetachWorkerResource(a.ctx, tx, workerID, a.userUID, at.Type, resourceID); err != nil {
+						return err
+					}
+				}
+				return dblayer.AttachWorkerResource(a.ctx, tx, workerID, a.userUID, at.Type, resourceID, at.Env)
+			})
+		})
+	}
+
+	if !a.prune {
+		return
+	}
+	for _, c := range current {
+		if kept[c] {
+			continue
+		}
+		a.add(ConfigChange{Action: "delete", Type: "attachment", Name: c.ResourceType + "/" + c.ResourceID, ID: c.ResourceID, Detail: "env " + c.EnvName}, func() (string, error) {
+			return "", a.syncAttachment(c.WorkerID, func(tx *sql.Tx) error {
+				return dblayer.DetachWorkerResource(a.ctx, tx, c.WorkerID, a.userUID, c.ResourceType, c.ResourceID)
+			})
+		})
+	}
+}
+
+// syncAttachment 在同一事务里修改挂载并写入同步任务
+func (a *manifestApply) syncAttachment(workerID string, change func(tx *sql.Tx) error) error {
+	err := dblayer.WithTx(a.ctx, func(tx *sql.Tx) error {
+		if err := change(tx); err != nil {
+			return err
+		}
+		return enqueueTask(a.ctx, tx, jobs.NewSyncAttachmentsJob(workerID, a.userUID))
+	})
+	if err == dblayer.ErrAttachmentExists {
+		return errors.New("resource is already attached or the env name is used by another attachment")
+	}
+	if err != nil {
+		return err
+	}
+	KickOutbox()
+	return nil
+}
+
+// domains 指向该 worker 的域名：缺少的添加，prune 时删除文件中没有的；
+// 同名域名指向别处时只提示，和 import 一样需要删除后重新添加
+func (a *manifestApply) domains(have []*dblayer.CustomDomain) {
+	host := ""
+	if a.w != nil {
+		host = controller.WorkerHost(a.w.WID, a.userUID)
+	}
+	byDomain := make(map[string]*dblayer.CustomDomain, len(have))
+	for _, d := range have {
+		byDomain[d.Domain] = d
+	}
+
+	for _, md := range a.m.Domains {
+		if d := byDomain[md.Domain]; d != nil {
+			if d.Target != host {
+				a.warn("domain %q: target %q is not this worker, delete and add the domain again to change it", md.Domain, d.Target)
+			}
+			continue
+		}
+		a.add(ConfigChange{Action: "create", Type: "domain", Name: md.Domain, Detail: "target worker " + a.m.Name}, func() (string, error) {
+			workerID, err := a.target()
+			if err != nil {
+				return "", err
+			}
+			return a.createDomain(DomainConfig{Domain: md.Domain, Target: controller.WorkerHost(workerID, a.userUID), Issuer: md.Issuer, Challenge: md.Challenge})
+		})
+	}
+
+	if !a.prune || host == "" {
+		return
+	}
+	for _, d := range have {
+		if d.Target != host || slices.ContainsFunc(a.m.Domains, func(md ManifestDomain) bool { return md.Domain == d.Domain }) {
+			continue
+		}
+		a.add(ConfigChange{Action: "delete", Type: "domain", Name: d.Domain, ID: d.CDID}, func() (string, error) {
+			return "", k8s.DeleteCustomDomain(a.ctx, d.CDID)
+		})
+	}
+}
+
+// deploy 最后部署，新 worker 总是部署
+func (a *manifestApply) deploy() {
+	if !a.created {
+		a.configPlan.deploy(a.m.Name, a.w, a.m.Image, a.m.Port)
+		return
+	}
+	a.add(ConfigChange{Action: "deploy", Type: "worker", Name: a.m.Name, Detail: fmt.Sprintf("%s port %d", a.m.Image, a.m.Port)}, func() (string, error) {
+		workerID, err := a.target()
+		if err != nil {
+			return "", err
+		}
+		_, err = deployWorker(a.ctx, DeployWorkerRequest{UserUID: a.userUID, WorkerID: workerID, Image: a.m.Image, Port: a.m.Port, Initiator: dblayer.DeployInitiatorApply})
+		return workerID, err
+	})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPMetrics 按路由模板记录请求数与延迟，未匹配的路由统一记为 unmatched，避免标签爆炸
+func HTTPMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Metrics /metrics（Prometheus 文本格式）。设置了 METRICS_TOKEN 时要求 Authorization: Bearer <token>，
+// outer 对公网暴露，生产环境应当设置
+func Metrics() gin.HandlerFunc {
+	token := os.Getenv("METRICS_TOKEN")
+	h := metrics.Handler()
+	return func(c *gin.Context) {
+		if token != "" {
+			got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				c.JSON(401, gin.H{"error": "invalid metrics token"})
+				return
+			}
+		}
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
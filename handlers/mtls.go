@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keyPair 从文件加载的证书，文件被 cert-manager 轮换后在下一次握手时重新加载
+type keyPair struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+func loadKeyPair(certFile, keyFile string) (*keyPair, error) {
+	kp := &keyPair{certFile: certFile, keyFile: keyFile}
+	if _, err := kp.get(); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// get 返回当前证书；重新加载失败时继续用旧证书
+func (kp *keyPair) get() (*tls.Certificate, error) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	info, err := os.Stat(kp.certFile)
+	if err == nil && kp.cert != nil && !info.ModTime().After(kp.modTime) {
+		return kp.cert, nil
+	}
+	cert, loadErr := tls.LoadX509KeyPair(kp.certFile, kp.keyFile)
+	if loadErr != nil {
+		if kp.cert != nil {
+			return kp.cert, nil
+		}
+		return nil, loadErr
+	}
+	kp.cert = &cert
+	if err == nil {
+		kp.modTime = info.ModTime()
+	}
+	return kp.cert, nil
+}
+
+// serverTLSConfig 加载 server 证书；caFile 非空时用它校验客户端证书（mTLS）
+func serverTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	kp, err := loadKeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return kp.get() },
+		MinVersion:     tls.VersionTLS12,
+	}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pool, err := loadCAPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClientCAs = pool
+	// token 调用方和 /health 等探针没有证书，证书只在提供时校验
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return cfg, nil
+}
+
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("no certificates found in CA")
+	}
+	return pool, nil
+}
+
+// InnerTLSOptions inner HTTP 的 TLS 配置，CertFile 为空时仍是明文 HTTP
+type InnerTLSOptions struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string // 内部 CA，配置后 API 和 admin 路由只接受持有该 CA 客户端证书的调用方
+}
+
+// Enabled inner 是否走 TLS
+func (o InnerTLSOptions) Enabled() bool {
+	return o.CertFile != ""
+}
+
+// MTLS inner 是否要求客户端证书
+func (o InnerTLSOptions) MTLS() bool {
+	return o.CAFile != ""
+}
+
+// TLSConfig inner HTTP server 的 tls.Config
+func (o InnerTLSOptions) TLSConfig() (*tls.Config, error) {
+	if o.MTLS() && !o.Enabled() {
+		return nil, errors.New("client CA requires a server certificate")
+	}
+	return serverTLSConfig(o.CertFile, o.KeyFile, o.CAFile)
+}
+
+const clientIdentityKey = "client_identity"
+
+// ClientIdentity 取出已验证客户端证书里的身份（k8s.Identity）。
+// required 时（inner 启用 mTLS）没有合法身份的请求直接 401；
+// /health、/ready、/metrics 注册在它之前，探针和 Prometheus 不需要证书
+func ClientIdentity(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tlsState := c.Request.TLS; tlsState != nil && len(tlsState.VerifiedChains) > 0 {
+			if id, ok := k8s.IdentityOf(tlsState.VerifiedChains[0][0]); ok {
+				c.Set(clientIdentityKey, id)
+				c.Next()
+				return
+			}
+		}
+		if required {
+			RequestLog(c).Warn("request without a client identity", "path", c.Request.URL.Path)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "a client certificate of the internal CA is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AllowCallers 只放行列出的组件，workers 为 true 时也放行 worker 证书。
+// 未启用 mTLS（没有身份）时不限制，和之前一样依赖集群网络隔离
+func AllowCallers(workers bool, components ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(clientIdentityKey)
+		if !ok {
+			c.Next()
+			return
+		}
+		id := v.(k8s.Identity)
+		if slices.Contains(components, id.Component) && id.Component != "" || workers && id.Owner != "" {
+			c.Next()
+			return
+		}
+		RequestLog(c).Warn("caller not allowed", "identity", id.String(), "path", c.FullPath())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "caller " + id.String() + " is not allowed"})
+	}
+}
+
+// callerOwner worker 证书所属的 owner UID，其他调用方返回 ""
+func callerOwner(c *gin.Context) string {
+	v, ok := c.Get(clientIdentityKey)
+	if !ok {
+		return ""
+	}
+	return v.(k8s.Identity).Owner
+}
+
+// innerClient outer 调用 inner 的 HTTP client，SetInnerClientTLS 之后带客户端证书走 https
+var innerClient = http.DefaultClient
+
+// SetInnerClientTLS (outer) 用客户端证书和内部 CA 调用 inner，并把
+// k8s.ControlPlaneInnerEndpoint 换成 https
+func SetInnerClientTLS(certFile, keyFile, caFile string) error {
+	kp, err := loadKeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("load client certificate: %w", err)
+	}
+	pool, err := loadCAPool(caFile)
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return kp.get() },
+		RootCAs:              pool,
+		MinVersion:           tls.VersionTLS12,
+	}
+	innerClient = &http.Client{Transport: transport}
+	k8s.ControlPlaneInnerEndpoint = "https://" + strings.TrimPrefix(k8s.ControlPlaneInnerEndpoint, "http://")
+	return nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListNotifications 列出当前用户的站内通知（部署结果、域名验证、证书到期、配额提醒、告警），
+// ?unread=true 只看未读，?unread=false 只看已读；响应里带未读总数，前端显示角标
+func ListNotifications(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Status != "" {
+		c.JSON(400, gin.H{"error": "status filter is not supported here, use unread"})
+		return
+	}
+	var unread *bool
+	if v := c.Query("unread"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "unread must be true or false"})
+			return
+		}
+		unread = &b
+	}
+
+	ctx := c.Request.Context()
+	items, next, err := dblayer.ListNotifications(ctx, userUID, unread, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("list notifications failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list notifications"})
+		return
+	}
+	count, err := dblayer.CountUnreadNotifications(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("count unread notifications failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list notifications"})
+		return
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListNotificationsResponse{Notifications: items, Unread: count, NextCursor: next})
+}
+
+// MarkNotificationsRead 把指定的通知标为已读，ids 为空时全部标为已读
+func MarkNotificationsRead(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	var req MarkNotificationsReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	n, err := dblayer.MarkNotificationsRead(ctx, userUID, req.IDs)
+	if err != nil {
+		RequestLog(c).Error("mark notifications read failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to mark notifications read"})
+		return
+	}
+	count, err := dblayer.CountUnreadNotifications(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("count unread notifications failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to count unread notifications"})
+		return
+	}
+	c.JSON(200, MarkNotificationsReadResponse{Marked: n, Unread: count})
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"sync"
+
+	"jabberwocky238/console/billing"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/handlers/openapi"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion is reported in the generated OpenAPI documents
+const APIVersion = "1.0.0"
+
+// OuterOperations documents every route registered by cmd/outer. Paths use
+// the legacy /api prefix; NewOpenAPIHandler expands them to /api/v1 plus
+// deprecated aliases.
+var OuterOperations = []openapi.Operation{
+	{Method: "GET", Path: "/health", Tag: "health", Summary: "Health check", Response: HealthResponse{}},
+	{Method: "GET", Path: "/ready", Tag: "health", Summary: "Readiness check, 503 while draining for shutdown", Response: HealthResponse{}},
+
+	{Method: "POST", Path: "/api/auth/register", Tag: "auth", Summary: "Register a new user; session=cookie sets an HttpOnly session cookie and returns csrf_token instead of token. locale (en, zh) is the language of the account's mail, from Accept-Language when omitted. invite_code is required when signup.mode is invite (403 without one, or when it is invalid, expired, used up or bound to another email); in waitlist mode a registration without one consumes the verification code and returns 202 with the waitlist status instead of an account", Request: RegisterRequest{}, Response: RegisterResponse{}},
+	{Method: "GET", Path: "/api/auth/signup", Tag: "auth", Summary: "How registration works right now: open, invite (invitation code required) or waitlist", Response: SignupInfoResponse{}},
+	{Method: "POST", Path: "/api/auth/login", Tag: "auth", Summary: "Log in and obtain a JWT; session=cookie sets an HttpOnly session cookie and returns csrf_token, to send as X-CSRF-Token on state-changing requests. A risky login (failed attempts, new device, new country or network) returns 401 with step_up and emails a code to resubmit as code; 429 after too many failures", Request: LoginRequest{}, Response: LoginResponse{}},
+	{Method: "POST", Path: "/api/auth/logout", Tag: "auth", Summary: "Clear the session cookies", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "POST", Path: "/api/auth/send-code", Tag: "auth", Summary: "Send an email verification code, in locale or else the language of the existing account or Accept-Language", Request: SendCodeRequest{}, Response: SendCodeResponse{}},
+	{Method: "POST", Path: "/api/auth/reset-password", Tag: "auth", Summary: "Reset password with a verification code", Request: ResetPasswordRequest{}, Response: MessageResponse{}},
+
+	{Method: "GET", Path: "/api/rdb", Tag: "rdb", Summary: "List RDB resources (status pending_apply until the dedicated combinator has loaded them)", Security: openapi.SecurityBearer, Query: ListQueryParams, Response: ListRDBsResponse{}},
+	{Method: "GET", Path: "/api/rdb/:id", Tag: "rdb", Summary: "Get an RDB resource (sets ETag/Last-Modified, honours If-None-Match)", Security: openapi.SecurityBearer, Response: RDBDetailResponse{}},
+	{Method: "POST", Path: "/api/rdb", Tag: "rdb", Summary: "Create an RDB resource (403 once the RDB storage of the plan is used up)", Security: openapi.SecurityBearer, Request: CreateRDBRequest{}, Response: ResourceCreatedResponse{}},
+	{Method: "DELETE", Path: "/api/rdb/:id", Tag: "rdb", Summary: "Delete an RDB resource (412 if If-Match is stale)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "PUT", Path: "/api/rdb/:id/tags", Tag: "rdb", Summary: "Replace the tags of an RDB resource (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetTagsRequest{}, Response: TagsResponse{}},
+	{Method: "PUT", Path: "/api/rdb/:id/environment", Tag: "rdb", Summary: "Move an RDB resource to another environment (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvironmentRequest{}, Response: SetEnvironmentResponse{}},
+	{Method: "GET", Path: "/api/rdb/:id/tasks", Tag: "rdb", Summary: "List the scheduled SQL tasks of an RDB resource (filter with status=pending|succeeded|failed|timeout, the result of the last run)", Security: openapi.SecurityBearer, Query: []string{"limit", "cursor", "sort", "order", "status"}, Response: ListRDBTasksResponse{}},
+	{Method: "POST", Path: "/api/rdb/:id/tasks", Tag: "rdb", Summary: "Create a scheduled SQL task: the statements run in the RDB's schema as the user's database role on a cron schedule in timezone, stopped after timeout_seconds. The first failure after a success is notified (email unless notify_email is false). 409 if the RDB is not active, the name is taken or the user has 20 tasks; 400 if the schedule fires more than once a minute or never", Security: openapi.SecurityBearer, Request: RDBTaskRequest{}, Response: dblayer.RDBTask{}},
+	{Method: "GET", Path: "/api/rdb/:id/tasks/:task", Tag: "rdb", Summary: "Get a scheduled SQL task with its last result and next run", Security: openapi.SecurityBearer, Response: dblayer.RDBTask{}},
+	{Method: "PUT", Path: "/api/rdb/:id/tasks/:task", Tag: "rdb", Summary: "Replace a scheduled SQL task; the next run follows the new schedule", Security: openapi.SecurityBearer, Request: RDBTaskRequest{}, Response: dblayer.RDBTask{}},
+	{Method: "DELETE", Path: "/api/rdb/:id/tasks/:task", Tag: "rdb", Summary: "Delete a scheduled SQL task and its run history; a run in progress finishes", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "POST", Path: "/api/rdb/:id/tasks/:task/run", Tag: "rdb", Summary: "Run a task once now, even when disabled; it starts within 30 seconds, after a run still in progress", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/rdb/:id/tasks/:task/runs", Tag: "rdb", Summary: "Run history of a task, kept 30 days (filter with status=running|succeeded|failed|timeout)", Security: openapi.SecurityBearer, Query: []string{"limit", "cursor", "sort", "order", "status"}, Response: ListRDBTaskRunsResponse{}},
+
+	{Method: "GET", Path: "/api/kv", Tag: "kv", Summary: "List KV resources (status pending_apply until the dedicated combinator has loaded them)", Security: openapi.SecurityBearer, Query: ListQueryParams, Response: ListKVsResponse{}},
+	{Method: "POST", Path: "/api/kv", Tag: "kv", Summary: "Create a KV resource; the body is optional (environment defaults to production)", Security: openapi.SecurityBearer, Request: CreateKVRequest{}, Response: ResourceCreatedResponse{}},
+	{Method: "DELETE", Path: "/api/kv/:id", Tag: "kv", Summary: "Delete a KV resource (412 if If-Match is stale)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "PUT", Path: "/api/kv/:id/tags", Tag: "kv", Summary: "Replace the tags of a KV resource (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetTagsRequest{}, Response: TagsResponse{}},
+	{Method: "PUT", Path: "/api/kv/:id/environment", Tag: "kv", Summary: "Move a KV resource to another environment (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvironmentRequest{}, Response: SetEnvironmentResponse{}},
+
+	{Method: "GET", Path: "/api/bucket", Tag: "bucket", Summary: "List object storage buckets, with the total measured size", Security: openapi.SecurityBearer, Query: ListQueryParams, Response: ListBucketsResponse{}},
+	{Method: "GET", Path: "/api/bucket/:id", Tag: "bucket", Summary: "Get a bucket: its name on the object store, lifecycle rules, last measured size and object count, and when its credentials expire", Security: openapi.SecurityBearer, Response: BucketDetailResponse{}},
+	{Method: "POST", Path: "/api/bucket", Tag: "bucket", Summary: "Create an object storage bucket; the body is optional. Attach it to a worker to get scoped credentials (403 once the bucket storage of the plan is used up)", Security: openapi.SecurityBearer, Request: CreateBucketRequest{}, Response: ResourceCreatedResponse{}},
+	{Method: "DELETE", Path: "/api/bucket/:id", Tag: "bucket", Summary: "Delete a bucket and every object in it (412 if If-Match is stale)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "PUT", Path: "/api/bucket/:id/tags", Tag: "bucket", Summary: "Replace the tags of a bucket (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetTagsRequest{}, Response: TagsResponse{}},
+	{Method: "PUT", Path: "/api/bucket/:id/environment", Tag: "bucket", Summary: "Move a bucket to another environment (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvironmentRequest{}, Response: SetEnvironmentResponse{}},
+	{Method: "GET", Path: "/api/queue", Tag: "queue", Summary: "List message queue resources (status pending_apply until the dedicated combinator has loaded them)", Security: openapi.SecurityBearer, Query: ListQueryParams, Response: ListQueuesResponse{}},
+	{Method: "GET", Path: "/api/queue/:id", Tag: "queue", Summary: "Get a queue resource with its live depth (ready and unacknowledged messages), consumers and message rates from the broker; stats are omitted until the queue is active or while the broker is unreachable", Security: openapi.SecurityBearer, Response: QueueDetailResponse{}},
+	{Method: "POST", Path: "/api/queue", Tag: "queue", Summary: "Create a durable queue, named after the resource ID, in the user's vhost on the shared RabbitMQ; the body is optional. Attach it to a worker to get AMQP_URL", Security: openapi.SecurityBearer, Request: CreateQueueRequest{}, Response: ResourceCreatedResponse{}},
+	{Method: "DELETE", Path: "/api/queue/:id", Tag: "queue", Summary: "Delete a queue and its messages (412 if If-Match is stale)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "PUT", Path: "/api/queue/:id/tags", Tag: "queue", Summary: "Replace the tags of a queue resource (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetTagsRequest{}, Response: TagsResponse{}},
+	{Method: "PUT", Path: "/api/queue/:id/environment", Tag: "queue", Summary: "Move a queue resource to another environment (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvironmentRequest{}, Response: SetEnvironmentResponse{}},
+	{Method: "PUT", Path: "/api/bucket/:id/lifecycle", Tag: "bucket", Summary: "Replace the lifecycle rules of a bucket: expire objects under a prefix and clean up incomplete uploads after some days; an empty list removes them (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetBucketLifecycleRequest{}, Response: BucketLifecycleResponse{}},
+
+	{Method: "GET", Path: "/api/combinator/app", Tag: "combinator", Summary: "Status of the user's dedicated combinator (phase Absent when the user has none or COMBINATOR_APPS is off)", Security: openapi.SecurityBearer, Response: controller.CombinatorAppStatus{}},
+	{Method: "PUT", Path: "/api/combinator/app/profile", Tag: "combinator", Summary: "Set the sizing profile (small, medium, large; empty for the plan default) of the dedicated combinator and roll its pod; 403 above the plan", Security: openapi.SecurityBearer, Request: SetCombinatorProfileRequest{}, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/combinator/status", Tag: "combinator", Summary: "Pods of the user's dedicated combinator with phase, readiness, restart count and waiting reason (e.g. CrashLoopBackOff), and the latest config revision next to the one the pods have loaded. dedicated is false and pods empty under the shared combinator", Security: openapi.SecurityBearer, Response: controller.CombinatorRuntimeStatus{}},
+	{Method: "GET", Path: "/api/combinator/logs", Tag: "combinator", Summary: "Last tail lines (default 100, at most 5000) of each pod of the dedicated combinator; previous=true reads the container that ran before the last restart", Security: openapi.SecurityBearer, Query: []string{"tail", "previous"}, Response: CombinatorLogsResponse{}},
+	{Method: "POST", Path: "/api/combinator/restart", Tag: "combinator", Summary: "Roll the pods of the dedicated combinator, 202 once the restart is queued; 409 when none is deployed", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+
+	{Method: "GET", Path: "/api/worker", Tag: "worker", Summary: "List workers", Security: openapi.SecurityBearer, Query: ListQueryParams, Response: []WorkerSummary{}},
+	{Method: "GET", Path: "/api/worker/:id", Tag: "worker", Summary: "Get a worker with recent deploy versions (sets ETag/Last-Modified, honours If-None-Match)", Security: openapi.SecurityBearer, Query: []string{"offset"}, Response: WorkerDetailResponse{}},
+	{Method: "GET", Path: "/api/worker/pools", Tag: "worker", Summary: "List the node pools a worker can select (pool), with architectures, capacity and price discovered from node labels", Security: openapi.SecurityBearer, Response: NodePoolsResponse{}},
+	{Method: "GET", Path: "/api/regions", Tag: "worker", Summary: "List the regions main_region can select, discovered from node labels, with free capacity and the largest pod that still fits, plus the account's default region; deploys into an unknown region are rejected", Security: openapi.SecurityBearer, Response: RegionsResponse{}},
+	{Method: "POST", Path: "/api/worker", Tag: "worker", Summary: "Create a worker (403 above the plan limits: worker count, replicas, CPU/memory, run_as_root, writable_root_fs). kind static serves files deployed with POST /worker/:id/static instead of an image", Security: openapi.SecurityBearer, Request: CreateWorkerRequest{}, Response: CreateWorkerResponse{}},
+	{Method: "DELETE", Path: "/api/worker/:id", Tag: "worker", Summary: "Delete a worker (412 if If-Match is stale)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "POST", Path: "/api/worker/:id/clone", Tag: "worker", Summary: "Copy a worker into a new one: spec, env, env groups, egress and route rules, edge limits, error pages (maintenance off) and attachments (sharing the same resources), deployed from the source's active image. main_region or cluster moves the copy, tags are merged over the source's (e.g. env: staging). Worker secret values are only copied with copy_secrets. 403 above the plan limits, 422/402 when the deploy is refused", Security: openapi.SecurityBearer, Request: CloneWorkerRequest{}, Response: CloneWorkerResponse{}},
+	{Method: "POST", Path: "/api/worker/:id/static", Tag: "worker", Summary: "Deploy the files of a static worker, served by the static site image with the same deploy versions as image deploys: a gzipped tarball as the application/gzip request body (up to 64 MiB, dir picks the site root inside it), or a public GitHub repo as JSON (ref defaults to main and is pinned to its commit at deploy). 409 for container workers, 412 if If-Match is stale, 422/402 when the deploy is refused", Security: openapi.SecurityBearer, Query: []string{"dir"}, Request: StaticRepoRequest{}, Response: DeployWorkerResponse{}},
+	{Method: "POST", Path: "/api/worker/:id/promote", Tag: "worker", Summary: "Deploy the image, port and commit of a version of this worker (default its active version) to target_id in another environment, e.g. staging to production; the target keeps its own env and config. Static workers promote their files to static workers. 400 if both are in the same environment or of different kinds, 412 if If-Match on the target is stale, 422/402 when the deploy is refused", Security: openapi.SecurityBearer, Request: PromoteWorkerRequest{}, Response: PromoteWorkerResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/env", Tag: "worker", Summary: "Get worker environment variables; values of sensitive keys and secret-shaped values are masked", Security: openapi.SecurityBearer, Response: map[string]string{}},
+	{Method: "POST", Path: "/api/worker/:id/env", Tag: "worker", Summary: "Set or delete a worker environment variable, returns the masked env (412 if If-Match is stale, 409 if expected_version is stale or an attachment injects the key)", Security: openapi.SecurityBearer, Request: WorkerKeyValueRequest{}, Response: map[string]string{}},
+	{Method: "POST", Path: "/api/worker/:id/env/reveal", Tag: "worker", Summary: "Reveal the unmasked value of one environment variable; recorded as a secret_revealed security event", Security: openapi.SecurityBearer, Request: RevealEnvRequest{}, Response: RevealEnvResponse{}},
+	{Method: "POST", Path: "/api/worker/:id/env/import", Tag: "worker", Summary: "Import a .env file, sent as the raw request body or as content in JSON. Each variable is stored as a worker secret or plain env, guessed from its name and value; secret and plain (repeatable) override the guess and existing worker secrets stay secrets. Saved in one update and synced to the worker (412 if If-Match is stale); dry_run only returns the classification", Security: openapi.SecurityBearer, Query: []string{"secret", "plain", "dry_run"}, Request: ImportEnvRequest{}, Response: ImportEnvResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/secret", Tag: "worker", Summary: "List worker secret keys", Security: openapi.SecurityBearer, Response: []string{}},
+	{Method: "POST", Path: "/api/worker/:id/secret", Tag: "worker", Summary: "Set or delete a worker secret (412 if If-Match is stale, 409 if expected_version is stale or an attachment injects the key)", Security: openapi.SecurityBearer, Request: WorkerKeyValueRequest{}, Response: []string{}},
+	{Method: "PUT", Path: "/api/worker/:id/tags", Tag: "worker", Summary: "Replace the tags of a worker and sync them to its K8s labels (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetTagsRequest{}, Response: TagsResponse{}},
+	{Method: "PUT", Path: "/api/worker/:id/environment", Tag: "worker", Summary: "Move a worker to another environment; its env re-syncs with the new environment's variables and the worker restarts (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvironmentRequest{}, Response: SetEnvironmentResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/egress", Tag: "worker", Summary: "Get the outbound rules of a worker", Security: openapi.SecurityBearer, Response: dblayer.EgressPolicy{}},
+	{Method: "PUT", Path: "/api/worker/:id/egress", Tag: "worker", Summary: "Replace the outbound rules of a worker: allow, or deny all but the listed CIDRs and domains (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEgressRequest{}, Response: dblayer.EgressPolicy{}},
+	{Method: "GET", Path: "/api/worker/:id/routes", Tag: "worker", Summary: "Get the extra route rules of a worker", Security: openapi.SecurityBearer, Response: dblayer.WorkerRoutes{}},
+	{Method: "PUT", Path: "/api/worker/:id/routes", Tag: "worker", Summary: "Replace the extra route rules of a worker: hosts, path prefixes and headers to match, highest priority first (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetRoutesRequest{}, Response: dblayer.WorkerRoutes{}},
+	{Method: "GET", Path: "/api/worker/:id/edge-limits", Tag: "worker", Summary: "Get the request rate limit and body size limit of a worker, 0 when unlimited", Security: openapi.SecurityBearer, Response: dblayer.EdgeLimits{}},
+	{Method: "PUT", Path: "/api/worker/:id/edge-limits", Tag: "worker", Summary: "Replace the request rate limit (req/s and burst, shared by all clients) and body size limit enforced at the ingress (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEdgeLimitsRequest{}, Response: dblayer.EdgeLimits{}},
+	{Method: "GET", Path: "/api/worker/:id/error-pages", Tag: "worker", Summary: "Get the maintenance toggle and custom 502/503 pages of a worker", Security: openapi.SecurityBearer, Response: dblayer.ErrorPages{}},
+	{Method: "PUT", Path: "/api/worker/:id/error-pages", Tag: "worker", Summary: "Replace the maintenance toggle and custom 502/503 HTML pages of a worker, served by the ingress in place of its errors (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetErrorPagesRequest{}, Response: dblayer.ErrorPages{}},
+	{Method: "GET", Path: "/api/worker/:id/requests", Tag: "worker", Summary: "Requests the ingress routed to a worker with status, latency, path (without query) and client IP, newest first, kept 7 days (since: RFC3339 or a duration like 6h, default 24h; status: a class like 5xx or a status code)", Security: openapi.SecurityBearer, Query: []string{"since", "status", "limit", "cursor", "sort", "order"}, Response: WorkerRequestsResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/requests/hourly", Tag: "worker", Summary: "Requests per hour of a worker by status class with average and maximum latency, kept 90 days (since: RFC3339 or a duration like 6h, default 24h)", Security: openapi.SecurityBearer, Query: []string{"since"}, Response: WorkerRequestHoursResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/env-groups", Tag: "worker", Summary: "Env groups attached to a worker, lowest precedence first, and the effective env after merging its environment's variables, the groups and its own env in that order (values masked)", Security: openapi.SecurityBearer, Response: WorkerEnvGroupsResponse{}},
+	{Method: "PUT", Path: "/api/worker/:id/env-groups", Tag: "worker", Summary: "Replace the env groups attached to a worker in precedence order (later groups override earlier ones, the worker env overrides all); the env re-syncs and the worker restarts (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetWorkerEnvGroupsRequest{}, Response: WorkerEnvGroupsResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/attachments", Tag: "worker", Summary: "Managed resources attached to a worker and the env names their connection strings are injected as", Security: openapi.SecurityBearer, Response: []*dblayer.WorkerAttachment{}},
+	{Method: "POST", Path: "/api/worker/:id/attach/:type/:rid", Tag: "worker", Summary: "Attach an rdb, kv, queue or bucket resource: its connection string is injected into the worker Secret as DATABASE_URL, REDIS_URL or AMQP_URL (env renames it) and kept up to date. A bucket's env is a prefix (default S3) for _ENDPOINT, _BUCKET, _REGION, _ACCESS_KEY_ID, _SECRET_ACCESS_KEY and _SESSION_TOKEN, with credentials limited to the bucket and rotated before they expire; 409 if a name is taken", Security: openapi.SecurityBearer, Query: []string{"env"}, Response: []*dblayer.WorkerAttachment{}},
+	{Method: "DELETE", Path: "/api/worker/:id/attach/:type/:rid", Tag: "worker", Summary: "Detach a resource and remove its env from the worker Secret", Security: openapi.SecurityBearer, Response: []*dblayer.WorkerAttachment{}},
+	{Method: "GET", Path: "/api/worker/:id/preview", Tag: "preview", Summary: "Pull request preview config of a worker and its open previews with their URLs; 404 when previews are not configured", Security: openapi.SecurityBearer, Response: PreviewsResponse{}},
+	{Method: "PUT", Path: "/api/worker/:id/preview", Tag: "preview", Summary: "Link a GitHub repository: each open pull request gets a copy of the worker deployed from image_template ({pr}, {sha}, {short_sha}, {branch}), with a fresh RDB as DATABASE_URL when with_db is set. 201 with the webhook secret on first setup, which is not returned again", Security: openapi.SecurityBearer, Request: SetPreviewConfigRequest{}, Response: PreviewConfigResponse{}},
+	{Method: "DELETE", Path: "/api/worker/:id/preview", Tag: "preview", Summary: "Disable previews and delete every preview worker and RDB", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/github", Tag: "github", Summary: "Repository and branch the worker auto-deploys from; 404 when unbound", Security: openapi.SecurityBearer, Response: GitHubBindingResponse{}},
+	{Method: "PUT", Path: "/api/worker/:id/github", Tag: "github", Summary: "Bind a GitHub repository branch: a push to it (or, with workflow, a successful run of that workflow on it) deploys image_template ({sha}, {short_sha}, {branch}) on the current port and records the commit; with github_token the result is posted as a commit status. 201 with the webhook secret on first setup, which is not returned again", Security: openapi.SecurityBearer, Request: SetGitHubBindingRequest{}, Response: GitHubBindingResponse{}},
+	{Method: "DELETE", Path: "/api/worker/:id/github", Tag: "github", Summary: "Unbind the worker from its repository", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/logs/history", Tag: "worker", Summary: "Query retained worker logs beyond pod lifetime, newest first (since/until: RFC3339 or a duration like 6h; query: substring filter)", Security: openapi.SecurityBearer, Query: []string{"since", "until", "query", "limit"}, Response: WorkerLogHistoryResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/status", Tag: "worker", Summary: "Runtime status of a worker (replicas, pods, routing and the latest controller events) served from the control plane's informer cache", Security: openapi.SecurityBearer, Response: controller.WorkerRuntimeStatus{}},
+	{Method: "POST", Path: "/api/worker/:id/unpause", Tag: "worker", Summary: "Resume a free-tier worker the idle reaper paused for lack of traffic and restart its idle timer (409 if it is not paused for inactivity)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/versions", Tag: "worker", Summary: "Deploy history of a worker with image, port, commit, initiator (user, github, preview, import, apply) and status, newest first; filter with status", Security: openapi.SecurityBearer, Query: []string{"limit", "cursor", "sort", "order", "status"}, Response: DeployVersionsResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/versions/:a/diff/:b", Tag: "worker", Summary: "What changed from version a to version b: image, port, commit, env keys (values are compared by digest and never returned), secret keys and resources, from the snapshot taken at deploy time. partial when a version predates snapshots", Security: openapi.SecurityBearer, Response: DeployDiffResponse{}},
+	{Method: "POST", Path: "/api/worker/:id/restart", Tag: "worker", Summary: "Rolling restart of all pods of a worker; the reason is kept in the restart audit log and the returned restart id is the handle to poll for progress (202, 409 if the worker is paused)", Security: openapi.SecurityBearer, Request: RestartWorkerRequest{}, Response: WorkerRestartResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/restarts", Tag: "worker", Summary: "Restart audit log of a worker: who asked, when, why and the outcome, newest first", Security: openapi.SecurityBearer, Response: WorkerRestartsResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/restarts/:rid", Tag: "worker", Summary: "One restart and the progress of its rollout; complete once all replicas run the restarted template", Security: openapi.SecurityBearer, Response: WorkerRestartResponse{}},
+	{Method: "PATCH", Path: "/api/worker/:id/resources", Tag: "worker", Summary: "Change CPU, memory or disk of a worker within the plan limits; the running pods roll without a new deploy version (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: ResizeWorkerRequest{}, Response: ResizeWorkerResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/resources/changes", Tag: "worker", Summary: "Resource changes of a worker and whether they reached the cluster, newest first", Security: openapi.SecurityBearer, Response: WorkerResourceChangesResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/remediation", Tag: "worker", Summary: "Automatic remediation policy of a worker; defaults (all off) when none is set", Security: openapi.SecurityBearer, Response: dblayer.WorkerRemediationPolicy{}},
+	{Method: "PUT", Path: "/api/worker/:id/remediation", Tag: "worker", Summary: "Replace the remediation policy: on repeated OOM kills notify with a suggested resize or bump memory within the plan, on crash looping notify or pause the worker", Security: openapi.SecurityBearer, Request: SetRemediationPolicyRequest{}, Response: dblayer.WorkerRemediationPolicy{}},
+	{Method: "DELETE", Path: "/api/worker/:id/remediation", Tag: "worker", Summary: "Turn automatic remediation off; the history is kept", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/worker/:id/remediations", Tag: "worker", Summary: "What remediation did or suggested for a worker, newest first", Security: openapi.SecurityBearer, Response: WorkerRemediationsResponse{}},
+	{Method: "POST", Path: "/api/worker/:id/remediations/:rid/apply", Tag: "worker", Summary: "Apply a suggested resize and sync it to the cluster (409 if already applied or the worker memory changed since)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+
+	{Method: "GET", Path: "/api/alerts/rules", Tag: "alerts", Summary: "List alert rules (filter with worker_id, status=ok|firing)", Security: openapi.SecurityBearer, Query: []string{"worker_id", "limit", "cursor", "sort", "order", "status"}, Response: ListAlertRulesResponse{}},
+	{Method: "POST", Path: "/api/alerts/rules", Tag: "alerts", Summary: "Create an alert rule on a worker (restarts, oom_kills, crash_loop, http_5xx_rate in %, p95_latency in seconds); evaluated every minute", Security: openapi.SecurityBearer, Request: AlertRuleRequest{}, Response: dblayer.AlertRule{}},
+	{Method: "GET", Path: "/api/alerts/rules/:id", Tag: "alerts", Summary: "Get an alert rule", Security: openapi.SecurityBearer, Response: dblayer.AlertRule{}},
+	{Method: "PUT", Path: "/api/alerts/rules/:id", Tag: "alerts", Summary: "Replace an alert rule", Security: openapi.SecurityBearer, Request: AlertRuleRequest{}, Response: dblayer.AlertRule{}},
+	{Method: "DELETE", Path: "/api/alerts/rules/:id", Tag: "alerts", Summary: "Delete an alert rule (its history is kept)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/alerts/history", Tag: "alerts", Summary: "List alert history: one entry per firing/resolved transition, also pushed as worker.alert events", Security: openapi.SecurityBearer, Query: []string{"rule_id", "worker_id", "limit", "cursor", "sort", "order", "status"}, Response: ListAlertEventsResponse{}},
+
+	{Method: "GET", Path: "/api/uptime/checks", Tag: "uptime", Summary: "List uptime checks (filter with status=up|down|unknown)", Security: openapi.SecurityBearer, Query: []string{"limit", "cursor", "sort", "order", "status"}, Response: ListUptimeChecksResponse{}},
+	{Method: "POST", Path: "/api/uptime/checks", Tag: "uptime", Summary: "Create an HTTP uptime check on a worker or a verified custom domain", Security: openapi.SecurityBearer, Request: UptimeCheckRequest{}, Response: dblayer.UptimeCheck{}},
+	{Method: "GET", Path: "/api/uptime/checks/:id", Tag: "uptime", Summary: "Get an uptime check with 24h and 7d uptime", Security: openapi.SecurityBearer, Response: UptimeCheckDetailResponse{}},
+	{Method: "PUT", Path: "/api/uptime/checks/:id", Tag: "uptime", Summary: "Replace the settings of an uptime check (target and path are fixed)", Security: openapi.SecurityBearer, Request: UpdateUptimeCheckRequest{}, Response: dblayer.UptimeCheck{}},
+	{Method: "DELETE", Path: "/api/uptime/checks/:id", Tag: "uptime", Summary: "Delete an uptime check with its results and incidents", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/uptime/checks/:id/results", Tag: "uptime", Summary: "Probe results and response times, newest first (since: RFC3339 or a duration like 6h)", Security: openapi.SecurityBearer, Query: []string{"since", "limit"}, Response: UptimeResultsResponse{}},
+	{Method: "GET", Path: "/api/uptime/incidents", Tag: "uptime", Summary: "List downtime incidents (filter with check_id, status=open|resolved)", Security: openapi.SecurityBearer, Query: []string{"check_id", "limit", "cursor", "sort", "order", "status"}, Response: ListUptimeIncidentsResponse{}},
+	{Method: "GET", Path: "/api/uptime/status-page", Tag: "uptime", Summary: "Get the status page settings", Security: openapi.SecurityBearer, Response: dblayer.StatusPage{}},
+	{Method: "PUT", Path: "/api/uptime/status-page", Tag: "uptime", Summary: "Create or update the public status page", Security: openapi.SecurityBearer, Request: StatusPageRequest{}, Response: dblayer.StatusPage{}},
+	{Method: "GET", Path: "/api/status/:slug", Tag: "uptime", Summary: "Public status page JSON listing the public uptime checks (no auth, CORS open)", Response: PublicStatusPageResponse{}},
+	{Method: "POST", Path: "/api/worker/estimate", Tag: "worker", Summary: "Projected monthly cost of a worker spec on the price sheet (unset resources use the deploy defaults, 400 for an unpriced region)", Security: openapi.SecurityBearer, Request: EstimateWorkerRequest{}, Response: billing.CostEstimate{}},
+	{Method: "POST", Path: "/api/worker/bulk", Tag: "worker", Summary: "Restart, pause, resume, set env on or delete many workers at once (402 on resume while workers are suspended for an overdue payment; workers paused by the spending cap are not resumed; body up to 8 MiB)", Security: openapi.SecurityBearer, Request: BulkWorkerRequest{}, Response: BulkWorkerResponse{}},
+	{Method: "POST", Path: "/api/worker/deploy", Tag: "worker", Summary: "Deploy a new worker version with its projected monthly cost, 422 with the policy, dry-run and capacity violations when refused, 402 while workers are suspended for an overdue payment, 409 for static workers", Security: openapi.SecuritySignature, Request: DeployWorkerRequest{}, Response: DeployWorkerResponse{}},
+
+	{Method: "GET", Path: "/api/domain", Tag: "customdomain", Summary: "List custom domains", Security: openapi.SecurityBearer, Query: ListQueryParams, Response: ListCustomDomainsResponse{}},
+	{Method: "GET", Path: "/api/domain/:id", Tag: "customdomain", Summary: "Get a custom domain (sets ETag/Last-Modified, honours If-None-Match)", Security: openapi.SecurityBearer, Response: k8s.CustomDomain{}},
+	{Method: "POST", Path: "/api/domain", Tag: "customdomain", Summary: "Add a custom domain and start verification (403 above the custom domain count of the plan). Once DNS verifies, a domain pointing to a worker is routed only when the worker has ready replicas (up to domain.ready_timeout); force routes it right away", Security: openapi.SecurityBearer, Request: AddCustomDomainRequest{}, Response: AddCustomDomainResponse{}},
+	{Method: "DELETE", Path: "/api/domain/:id", Tag: "customdomain", Summary: "Delete a custom domain (412 if If-Match is stale)", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "PUT", Path: "/api/domain/:id/tags", Tag: "customdomain", Summary: "Replace the tags of a custom domain and sync them to its K8s labels (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetTagsRequest{}, Response: TagsResponse{}},
+	{Method: "PUT", Path: "/api/domain/:id/environment", Tag: "customdomain", Summary: "Move a custom domain to another environment (412 if If-Match is stale, 409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvironmentRequest{}, Response: SetEnvironmentResponse{}},
+
+	{Method: "GET", Path: "/api/usage", Tag: "usage", Summary: "Metered usage per metric and per resource for a period (YYYY-MM, the default current month, or YYYY-MM-DD, UTC)", Security: openapi.SecurityBearer, Query: []string{"period"}, Response: UsageResponse{}},
+	{Method: "GET", Path: "/api/billing/subscription", Tag: "billing", Summary: "Current Stripe subscription, plan and dunning state (past_due_since, workers_suspended)", Security: openapi.SecurityBearer, Response: dblayer.Subscription{}},
+	{Method: "POST", Path: "/api/billing/checkout", Tag: "billing", Summary: "Create a Stripe Checkout session for a plan (503 when billing is not configured); redirect the user to url", Security: openapi.SecurityBearer, Request: CheckoutRequest{}, Response: CheckoutResponse{}},
+	{Method: "POST", Path: "/api/billing/webhook", Tag: "billing", Summary: "Stripe webhook (Stripe-Signature required): subscription changes sync the plan, failed payments pause workers after the grace period", Response: map[string]bool{}},
+	{Method: "POST", Path: "/api/preview/github/:id", Tag: "preview", Summary: "GitHub webhook for a worker's previews (X-Hub-Signature-256 required): pull_request opened/reopened/synchronize deploys the preview, closed deletes it; other events are acknowledged", Response: PreviewEnvironmentResponse{}},
+	{Method: "POST", Path: "/api/webhooks/github", Tag: "github", Summary: "GitHub push and workflow_run webhook shared by all bindings (X-Hub-Signature-256 checked against each binding of the repository, 401 if none matches); deploys every matching worker and reports each result", Response: GitHubWebhookResponse{}},
+	{Method: "GET", Path: "/api/spend-cap", Tag: "billing", Summary: "Monthly spending cap with the cost accrued this month (UTC) and whether it is enforced or overridden; 404 when unset", Security: openapi.SecurityBearer, Response: SpendCapResponse{}},
+	{Method: "PUT", Path: "/api/spend-cap", Tag: "billing", Summary: "Set the monthly spending cap; once metered cost reaches it, workers not tagged essential=true are paused and the user is notified", Security: openapi.SecurityBearer, Request: SetSpendCapRequest{}, Response: SpendCapResponse{}},
+	{Method: "DELETE", Path: "/api/spend-cap", Tag: "billing", Summary: "Remove the spending cap and resume the workers it paused", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "POST", Path: "/api/spend-cap/override", Tag: "billing", Summary: "Stop enforcing the cap for the rest of this month and resume the workers it paused", Security: openapi.SecurityBearer, Response: SpendCapResponse{}},
+	{Method: "GET", Path: "/api/spend-cap/actions", Tag: "billing", Summary: "Audit of spending cap changes and enforcement (suspend/resume with the affected workers), newest first", Security: openapi.SecurityBearer, Query: []string{"limit"}, Response: SpendCapActionsResponse{}},
+	{Method: "GET", Path: "/api/secrets", Tag: "secrets", Summary: "Metadata of the user's application secrets; values are never returned", Security: openapi.SecurityBearer, Response: SecretsResponse{}},
+	{Method: "POST", Path: "/api/secrets", Tag: "secrets", Summary: "Create a secret; the value is sealed with the platform key as version 1. Reference it from worker env as secretref://name; 503 when sealing is not configured", Security: openapi.SecurityBearer, Request: CreateSecretRequest{}, Response: SecretResponse{}},
+	{Method: "GET", Path: "/api/secrets/:name", Tag: "secrets", Summary: "Secret metadata, its versions and the workers referencing it", Security: openapi.SecurityBearer, Response: SecretResponse{}},
+	{Method: "DELETE", Path: "/api/secrets/:name", Tag: "secrets", Summary: "Delete a secret and all its versions; 409 while a worker env still references it", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "POST", Path: "/api/secrets/:name/rotate", Tag: "secrets", Summary: "Store a new version and make it current; referencing workers re-sync their env and restart", Security: openapi.SecurityBearer, Request: RotateSecretRequest{}, Response: SecretResponse{}},
+	{Method: "GET", Path: "/api/secrets/:name/audit", Tag: "secrets", Summary: "Access audit of a secret (create, rotate, delete and resolve by a worker), newest first, kept after deletion", Security: openapi.SecurityBearer, Query: []string{"limit"}, Response: SecretAccessResponse{}},
+	{Method: "GET", Path: "/api/env-groups", Tag: "env-groups", Summary: "The user's env groups: named sets of variables shared by several workers (values masked)", Security: openapi.SecurityBearer, Response: EnvGroupsResponse{}},
+	{Method: "POST", Path: "/api/env-groups", Tag: "env-groups", Summary: "Create an env group; values may be secretref://name. 409 if the name is taken", Security: openapi.SecurityBearer, Request: CreateEnvGroupRequest{}, Response: EnvGroupResponse{}},
+	{Method: "GET", Path: "/api/env-groups/:name", Tag: "env-groups", Summary: "An env group (values masked) and the workers it is attached to", Security: openapi.SecurityBearer, Response: EnvGroupResponse{}},
+	{Method: "PUT", Path: "/api/env-groups/:name", Tag: "env-groups", Summary: "Replace the variables of an env group; attached workers re-sync their env and restart (409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvGroupVarsRequest{}, Response: EnvGroupResponse{}},
+	{Method: "DELETE", Path: "/api/env-groups/:name", Tag: "env-groups", Summary: "Delete an env group; it is detached from its workers, which re-sync their env", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/mail", Tag: "mail", Summary: "SMTP relay host and port (enabled is false when the platform has no relay) and the daily quota: recipients accepted in the last 24 hours against max_emails_per_day", Security: openapi.SecurityBearer, Response: MailOverviewResponse{}},
+	{Method: "GET", Path: "/api/mail/domains", Tag: "mail", Summary: "The user's mail domains with the DNS records each needs (ownership TXT, SPF, DKIM) and whether they were found", Security: openapi.SecurityBearer, Response: ListMailDomainsResponse{}},
+	{Method: "POST", Path: "/api/mail/domains", Tag: "mail", Summary: "Add a sending domain and generate its DKIM key; the ownership TXT record of a custom domain with the same name is reused. Mail from it is refused until all three records verify. 409 if added already, 403 above 20 domains, 503 without a relay", Security: openapi.SecurityBearer, Request: AddMailDomainRequest{}, Response: MailDomainResponse{}},
+	{Method: "GET", Path: "/api/mail/domains/:id", Tag: "mail", Summary: "A mail domain and its DNS records", Security: openapi.SecurityBearer, Response: MailDomainResponse{}},
+	{Method: "POST", Path: "/api/mail/domains/:id/verify", Tag: "mail", Summary: "Look the DNS records of a mail domain up now instead of waiting for the periodic check (every 15 minutes)", Security: openapi.SecurityBearer, Response: MailDomainResponse{}},
+	{Method: "DELETE", Path: "/api/mail/domains/:id", Tag: "mail", Summary: "Delete a mail domain; mail from it is refused from then on", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/mail/credentials", Tag: "mail", Summary: "The user's SMTP credentials (without passwords)", Security: openapi.SecurityBearer, Response: ListMailCredentialsResponse{}},
+	{Method: "POST", Path: "/api/mail/credentials", Tag: "mail", Summary: "Create SMTP credentials for the relay; the password is only returned here. 409 if the name is taken, 403 above 20 credentials", Security: openapi.SecurityBearer, Request: CreateMailCredentialRequest{}, Response: CreateMailCredentialResponse{}},
+	{Method: "DELETE", Path: "/api/mail/credentials/:id", Tag: "mail", Summary: "Delete SMTP credentials; the relay refuses them right away", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/mail/suppressions", Tag: "mail", Summary: "List suppressed recipients (filter with reason=bounce|complaint|manual). Permanent bounces and complaints are added automatically; mail to them is rejected", Security: openapi.SecurityBearer, Query: []string{"reason", "limit", "cursor", "sort", "order"}, Response: ListMailSuppressionsResponse{}},
+	{Method: "POST", Path: "/api/mail/suppressions", Tag: "mail", Summary: "Suppress an address by hand; an address already suppressed keeps its reason", Security: openapi.SecurityBearer, Request: AddMailSuppressionRequest{}, Response: MessageResponse{}},
+	{Method: "DELETE", Path: "/api/mail/suppressions/:address", Tag: "mail", Summary: "Remove an address from the suppression list so mail to it is accepted again", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/mail/logs", Tag: "mail", Summary: "Send log, one entry per recipient (status queued, delivered, deferred, bounced, complained or rejected), kept 30 days; filter with status and recipient", Security: openapi.SecurityBearer, Query: []string{"recipient", "limit", "cursor", "sort", "order", "status"}, Response: ListMailMessagesResponse{}},
+	{Method: "GET", Path: "/api/environments", Tag: "environments", Summary: "The user's environments: every name used by a worker, RDB/KV or custom domain or given variables, with resource counts and variables (values masked)", Security: openapi.SecurityBearer, Response: EnvironmentsResponse{}},
+	{Method: "GET", Path: "/api/environments/:name", Tag: "environments", Summary: "An environment (values masked) and its workers", Security: openapi.SecurityBearer, Response: EnvironmentResponse{}},
+	{Method: "PUT", Path: "/api/environments/:name", Tag: "environments", Summary: "Replace the variables of an environment, merged under env groups and each worker's own env; values may be secretref://name. Its workers re-sync their env and restart (409 if expected_version is stale)", Security: openapi.SecurityBearer, Request: SetEnvironmentVarsRequest{}, Response: EnvironmentResponse{}},
+	{Method: "DELETE", Path: "/api/environments/:name", Tag: "environments", Summary: "Delete the variables of an environment; 409 while workers, RDB/KV resources or domains are still in it", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/security/events", Tag: "security", Summary: "List sign-in security events of the account (login_success, login_failed, step_up_required, step_up_failed, throttled, register, device_forgotten, secret_revealed) with their risk score and reasons", Security: openapi.SecurityBearer, Query: []string{"limit", "cursor", "sort", "order", "event"}, Response: ListAuthEventsResponse{}},
+	{Method: "GET", Path: "/api/security/devices", Tag: "security", Summary: "List the devices the account signed in from", Security: openapi.SecurityBearer, Response: []dblayer.UserDevice{}},
+	{Method: "DELETE", Path: "/api/security/devices/:id", Tag: "security", Summary: "Forget a device; the next sign-in from it counts as a new device", Security: openapi.SecurityBearer, Response: MessageResponse{}},
+	{Method: "POST", Path: "/api/account/secret-key/reveal", Tag: "security", Summary: "Reveal the account secret key after re-entering the password; recorded as a secret_revealed security event, wrong passwords count as failed sign-ins", Security: openapi.SecurityBearer, Request: RevealSecretKeyRequest{}, Response: RevealSecretKeyResponse{}},
+	{Method: "GET", Path: "/api/account", Tag: "account", Summary: "Account profile (display name, default region, timezone, mail language) and the email/webhook switches of every notification kind", Security: openapi.SecurityBearer, Response: AccountResponse{}},
+	{Method: "PATCH", Path: "/api/account", Tag: "account", Summary: "Update the fields present in the body; notifications turns email or webhook delivery of a notification kind on or off, the notification center always gets it. default_region applies to new workers without main_region or cluster, timezone to new scheduled SQL tasks without one", Security: openapi.SecurityBearer, Request: UpdateAccountRequest{}, Response: AccountResponse{}},
+	{Method: "GET", Path: "/api/account/preferences", Tag: "account", Summary: "Account preferences: the language of outbound mail and the supported languages", Security: openapi.SecurityBearer, Response: PreferencesResponse{}},
+	{Method: "PUT", Path: "/api/account/preferences", Tag: "account", Summary: "Set the language (en, zh) every mail to the account is written in", Security: openapi.SecurityBearer, Request: SetPreferencesRequest{}, Response: PreferencesResponse{}},
+	{Method: "GET", Path: "/api/notifications", Tag: "notifications", Summary: "List in-app notifications (deploy finished or failed, domain verified or failed, certificate expiring, quota warnings, alerts), newest first; unread=true|false filters, unread in the response counts all unread", Security: openapi.SecurityBearer, Query: []string{"limit", "cursor", "sort", "order", "unread"}, Response: ListNotificationsResponse{}},
+	{Method: "POST", Path: "/api/notifications/read", Tag: "notifications", Summary: "Mark notifications read by id; an empty ids list marks all of them read", Security: openapi.SecurityBearer, Request: MarkNotificationsReadRequest{}, Response: MarkNotificationsReadResponse{}},
+	{Method: "GET", Path: "/api/features", Tag: "features", Summary: "Feature flags evaluated for the current user and the maintenance mode status; in maintenance mode mutating requests return 503 with maintenance=true", Security: openapi.SecurityBearer, Response: FeaturesResponse{}},
+	{Method: "GET", Path: "/api/search", Tag: "search", Summary: "Search workers, domains, RDB/KV resources and deploy versions (type is a comma separated filter)", Security: openapi.SecurityBearer, Query: []string{"q", "type", "limit"}, Response: SearchResponse{}},
+	{Method: "GET", Path: "/api/export", Tag: "config", Summary: "Export all resources as a declarative document (application/yaml, or JSON with format=json)", Security: openapi.SecurityBearer, Query: []string{"format"}, Response: ConfigDocument{}},
+	{Method: "POST", Path: "/api/import", Tag: "config", Summary: "Diff a declarative document (YAML or JSON, up to 8 MiB) against current resources and apply it idempotently", Security: openapi.SecurityBearer, Query: []string{"dry_run", "prune"}, Request: ConfigDocument{}, Response: ImportConfigResponse{}},
+	{Method: "POST", Path: "/api/apply", Tag: "config", Summary: "Apply a console.yaml worker manifest (YAML or JSON): create or update the worker, set its env, attach RDB/KV by name (created if missing), add domains routed to it and deploy the image; idempotent. prune removes env, attachments and domains of the worker missing from the manifest", Security: openapi.SecurityBearer, Query: []string{"dry_run", "prune"}, Request: WorkerManifest{}, Response: ApplyManifestResponse{}},
+
+	{Method: "GET", Path: "/api/events/stream", Tag: "events", Summary: "Server-Sent Events stream of resource state changes (text/event-stream, one Event per message)", Security: openapi.SecurityBearer, Query: []string{"token"}, Response: dblayer.Event{}, Streaming: true},
+	{Method: "GET", Path: "/api/ws", Tag: "events", Summary: "WebSocket (subprotocol console.v1) multiplexing events, jobs, worker:<id> and logs:<id> channels. Request/response bodies describe the JSON frames", Security: openapi.SecurityBearer, Query: []string{"token"}, Request: WSClientFrame{}, Response: WSServerFrame{}, Streaming: true},
+}
+
+// InnerOperations documents every route registered by cmd/inner
+var InnerOperations = []openapi.Operation{
+	{Method: "GET", Path: "/health", Tag: "health", Summary: "Health check", Response: HealthResponse{}},
+	{Method: "GET", Path: "/ready", Tag: "health", Summary: "Readiness check, 503 while draining for shutdown", Response: HealthResponse{}},
+
+	{Method: "POST", Path: "/api/worker/deploy", Tag: "worker", Summary: "Deploy a new worker version with its projected monthly cost, 422 with the policy, dry-run and capacity violations when refused, 402 while workers are suspended for an overdue payment", Request: DeployWorkerRequest{}, Response: DeployWorkerResponse{}},
+	{Method: "GET", Path: "/api/worker/logs", Tag: "worker", Summary: "Follow the logs of all worker pods (application/x-ndjson, one LogLine per line)", Query: []string{"worker_id", "user_uid", "tail"}, Response: controller.LogLine{}},
+	{Method: "GET", Path: "/api/worker/pools", Tag: "worker", Summary: "Node pools of every registered cluster from node labels", Response: NodePoolsResponse{}},
+	{Method: "GET", Path: "/api/regions", Tag: "worker", Summary: "Regions of every registered cluster from the topology.kubernetes.io/region node label, with their capacity", Response: RegionsResponse{}},
+	{Method: "GET", Path: "/api/worker/validate", Tag: "worker", Summary: "Policy checks, a server-side dry run and a capacity check of a deploy, before the WorkerApp is created", Query: []string{"worker_id", "user_uid", "image", "port"}, Response: ValidateWorkerResponse{}},
+	{Method: "GET", Path: "/api/worker/status", Tag: "worker", Summary: "Runtime status of a worker from the informer cache, 503 until the cache has synced", Query: []string{"worker_id", "user_uid"}, Response: controller.WorkerRuntimeStatus{}},
+	{Method: "GET", Path: "/api/combinator/app", Tag: "combinator", Summary: "Status of a user's CombinatorApp CR and its Deployment", Query: []string{"user_uid"}, Response: controller.CombinatorAppStatus{}},
+	{Method: "GET", Path: "/api/combinator/status", Tag: "combinator", Summary: "Deployment, pods and loaded config revision of a user's dedicated combinator, read from the apiserver", Query: []string{"user_uid"}, Response: controller.CombinatorRuntimeStatus{}},
+	{Method: "GET", Path: "/api/combinator/logs", Tag: "combinator", Summary: "Recent logs of each pod of a user's dedicated combinator", Query: []string{"user_uid", "tail", "previous"}, Response: CombinatorLogsResponse{}},
+	{Method: "GET", Path: "/api/queue/stats", Tag: "queue", Summary: "Live depth, consumers and message rates of a user's queue from the broker (404 if the queue does not exist, 503 if the broker is unavailable)", Query: []string{"user_uid", "resource_id"}, Response: k8s.QueueStats{}},
+	{Method: "GET", Path: "/api/combinator/retrieveSecretByID", Tag: "combinator", Summary: "Retrieve active resources and secret key of a user", Query: []string{"user_id"}, Response: RetrieveSecretResponse{}},
+	{Method: "POST", Path: "/api/combinator/reportUsage", Tag: "combinator", Summary: "Report resource usage in batch", Request: []dblayer.CombinatorResourceReport{}, Response: ReportUsageResponse{}},
+	{Method: "POST", Path: "/api/combinator/reportConfig", Tag: "combinator", Summary: "Report the config revision a dedicated combinator has loaded (404 above the stamped revision)", Request: ReportConfigRequest{}, Response: MessageResponse{}},
+	{Method: "GET", Path: "/api/combinator/resolveDSN", Tag: "combinator", Summary: "Live DSNs of the signing worker owner's active resources (repeat resource=rdb:<id>, kv:<id> or queue:<id> to scope, 404 if one is missing). Needs X-Raysail-Uid, X-Raysail-Timestamp, X-Raysail-Nonce and an X-Raysail-Signature HMAC with RAYSAIL_SECRET_KEY; the response is signed with the same key and expires after 5 minutes", Query: []string{"resource"}, Response: ResolveDSNResponse{}},
+	{Method: "POST", Path: "/api/access-logs", Tag: "worker", Summary: "Ingest Traefik JSON access logs, one per line (up to the 1 MiB body limit), from the log agent; lines are attributed to workers by router name, others are skipped", Response: IngestAccessLogsResponse{}},
+	{Method: "POST", Path: "/api/mail/authorize", Tag: "mail", Summary: "Called by the SMTP relay before it accepts a message: checks the SMTP credentials (401), that the sender domain is verified (403), and per recipient the suppression list and the daily quota. Every recipient goes into the send log; the response has the DKIM key to sign with and the queue_id for events. Without recipients only the credentials are checked", Request: MailAuthorizeRequest{}, Response: MailAuthorizeResponse{}},
+	{Method: "POST", Path: "/api/mail/events", Tag: "mail", Summary: "Delivery results from the SMTP relay by queue_id and recipient; permanent bounces and complaints suppress the recipient", Request: MailEventsRequest{}, Response: MailEventsResponse{}},
+	{Method: "POST", Path: "/api/acceptTask", Tag: "jobs", Summary: "Enqueue a background job. With TASK_SIGNING_KEYS the request needs X-Task-Key-Id, X-Task-Timestamp, X-Task-Nonce and an X-Task-Signature HMAC; unsigned, stale and replayed requests get 401", Request: AcceptTaskRequest{}, Response: AcceptTaskResponse{}},
+
+	{Method: "GET", Path: "/admin/drift", Tag: "admin", Summary: "Latest report of objects that drifted from the database, 503 until the first audit has run", Response: jobs.DriftReport{}},
+	{Method: "GET", Path: "/admin/consistency", Tag: "admin", Summary: "Latest report of rows breaking ownership constraints (orphans of deleted users, dangling active versions), 503 until the first check has run", Response: jobs.ConsistencyReport{}},
+	{Method: "POST", Path: "/admin/consistency/repair", Tag: "admin", Summary: "Repair what can be repaired without touching the cluster, re-check and validate the constraints whose orphans are gone; orphans backing cluster objects are reported as manual", Response: jobs.ConsistencyReport{}},
+	{Method: "GET", Path: "/admin/capacity", Tag: "admin", Summary: "Allocatable, requested and free CPU and memory of every cluster, by region and by node", Response: k8s.CapacityReport{}},
+	{Method: "GET", Path: "/admin/overview", Tag: "admin", Summary: "Platform totals by status, what workers reserve on this cluster now (informer cache) and this month's top consumers per usage metric", Response: AdminOverviewResponse{}},
+	{Method: "GET", Path: "/admin/tenants", Tag: "admin", Summary: "Resource counts per tenant, sorted descending by workers, custom_domains, resources or created_at", Query: []string{"sort", "limit", "offset"}, Response: AdminTenantsResponse{}},
+	{Method: "GET", Path: "/admin/failures", Tag: "admin", Summary: "Recent failed jobs (since this instance started), deploys and custom domains, newest first", Query: []string{"limit"}, Response: AdminFailuresResponse{}},
+	{Method: "GET", Path: "/admin/jobs/stats", Tag: "admin", Summary: "Per job type success rate, duration and queue wait percentiles and retries on this instance within window (default 1h, at most 24h); alerting marks types whose failure rate is above jobs.failure_alert_rate", Query: []string{"window"}, Response: AdminJobStatsResponse{}},
+	{Method: "GET", Path: "/admin/jobs/leases", Tag: "admin", Summary: "Running, requeued and abandoned user jobs with their owner instance, attempts and last error; status filters", Query: []string{"status", "limit"}, Response: AdminJobLeasesResponse{}},
+	{Method: "GET", Path: "/admin/accounts/:uid/limits", Tag: "admin", Summary: "Plan, per-account overrides and effective limits of a user", Response: AccountLimitsResponse{}},
+	{Method: "PUT", Path: "/admin/accounts/:uid/plan", Tag: "admin", Summary: "Assign a plan (free, pro, team) to a user, keeping their overrides", Request: SetAccountPlanRequest{}, Response: AccountLimitsResponse{}},
+	{Method: "GET", Path: "/admin/accounts/:uid/features", Tag: "admin", Summary: "Feature flags evaluated for a user and the maintenance mode status", Response: FeaturesResponse{}},
+	{Method: "GET", Path: "/admin/accounts/:uid/job-limits", Tag: "admin", Summary: "Caps on the background jobs of a user that may run at once, and the jobs of the user running on this instance by type", Response: AccountJobLimitsResponse{}},
+	{Method: "PUT", Path: "/admin/accounts/:uid/job-limits/:type", Tag: "admin", Summary: "Cap how many jobs of a type the user may run at once on each inner instance; type * caps all jobs of the user together", Request: SetJobLimitRequest{}, Response: dblayer.TenantJobLimit{}},
+	{Method: "DELETE", Path: "/admin/accounts/:uid/job-limits/:type", Tag: "admin", Summary: "Remove a job cap of the user; 404 if there is none", Response: MessageResponse{}},
+	{Method: "PUT", Path: "/admin/accounts/:uid/limits", Tag: "admin", Summary: "Replace the per-account limit overrides of a user; omitted limits follow the plan, {} clears them", Request: dblayer.LimitOverrides{}, Response: AccountLimitsResponse{}},
+	{Method: "GET", Path: "/admin/settings", Tag: "admin", Summary: "Runtime settings with their defaults and effective values; overrides take effect on every instance without a redeploy", Response: SettingsResponse{}},
+	{Method: "PUT", Path: "/admin/settings/:key", Tag: "admin", Summary: "Override a runtime setting; 400 if the value is invalid, 404 for unknown keys", Request: SetSettingRequest{}, Response: SettingResponse{}},
+	{Method: "DELETE", Path: "/admin/settings/:key", Tag: "admin", Summary: "Remove the override of a runtime setting, returning it to its default; 404 if it is not overridden", Response: SettingResponse{}},
+	{Method: "GET", Path: "/admin/invitations", Tag: "admin", Summary: "Invitation codes with their uses, newest first; active=true only lists codes that can still be used", Query: []string{"active"}, Response: InvitationsResponse{}},
+	{Method: "POST", Path: "/admin/invitations", Tag: "admin", Summary: "Create an invitation code (random XXXX-XXXX-XXXX unless code is given, case-insensitive). max_uses defaults to 1, 0 is unlimited; email restricts it to one address; 409 if the code exists", Request: CreateInvitationRequest{}, Response: dblayer.Invitation{}},
+	{Method: "DELETE", Path: "/admin/invitations/:id", Tag: "admin", Summary: "Revoke an invitation code; users who registered with it keep their accounts", Response: MessageResponse{}},
+	{Method: "GET", Path: "/admin/waitlist", Tag: "admin", Summary: "Waitlisted sign-ups, oldest first; status is pending (default), approved, rejected or all", Query: []string{"status", "limit"}, Response: WaitlistResponse{}},
+	{Method: "POST", Path: "/admin/waitlist/:id/approve", Tag: "admin", Summary: "Approve a pending sign-up: creates a single-use invitation bound to its email (expires_at defaults to 7 days) and emails the code; emailed is false when the mail failed and the code must be passed on by hand. 409 if already decided", Request: ApproveWaitlistRequest{}, Response: ApproveWaitlistResponse{}},
+	{Method: "POST", Path: "/admin/waitlist/:id/reject", Tag: "admin", Summary: "Reject a pending sign-up without notice; registering again puts the email back on the waitlist", Response: dblayer.WaitlistEntry{}},
+	{Method: "GET", Path: "/admin/flags", Tag: "admin", Summary: "Feature flags with their per-user overrides", Response: FeatureFlagsResponse{}},
+	{Method: "PUT", Path: "/admin/flags/:key", Tag: "admin", Summary: "Create or update a feature flag: on for everyone when enabled, otherwise for rollout_percent of the users; user overrides are kept", Request: SetFeatureFlagRequest{}, Response: dblayer.FeatureFlag{}},
+	{Method: "DELETE", Path: "/admin/flags/:key", Tag: "admin", Summary: "Delete a feature flag and its user overrides; it is off for everyone afterwards", Response: MessageResponse{}},
+	{Method: "PUT", Path: "/admin/flags/:key/users/:uid", Tag: "admin", Summary: "Turn a feature flag on or off for one user regardless of enabled and rollout_percent", Request: SetFeatureFlagUserRequest{}, Response: dblayer.FeatureFlag{}},
+	{Method: "DELETE", Path: "/admin/flags/:key/users/:uid", Tag: "admin", Summary: "Remove the override of one user; 404 if there is none", Response: dblayer.FeatureFlag{}},
+	{Method: "GET", Path: "/admin/email-templates", Tag: "admin", Summary: "Email templates with their sample data and the locales that are overridden", Response: EmailTemplatesResponse{}},
+	{Method: "GET", Path: "/admin/email-templates/:name/:locale", Tag: "admin", Summary: "Effective source of a template in a locale (override or built-in) and the built-in source", Response: EmailTemplateResponse{}},
+	{Method: "PUT", Path: "/admin/email-templates/:name/:locale", Tag: "admin", Summary: "Override a template in a locale; 400 if it does not render with the sample data", Request: SetEmailTemplateRequest{}, Response: EmailTemplateResponse{}},
+	{Method: "DELETE", Path: "/admin/email-templates/:name/:locale", Tag: "admin", Summary: "Remove the override, back to the built-in template; 404 if there is none", Response: EmailTemplateResponse{}},
+	{Method: "POST", Path: "/admin/email-templates/:name/:locale/preview", Tag: "admin", Summary: "Render a template without sending it; source defaults to the effective template, data to the sample", Request: PreviewEmailTemplateRequest{}, Response: notify.Message{}},
+	{Method: "POST", Path: "/admin/email-templates/:name/:locale/test", Tag: "admin", Summary: "Render a template like preview and send it to the given address", Request: TestEmailTemplateRequest{}, Response: TestEmailTemplateResponse{}},
+}
+
+// OpenAPIHandler serves the generated spec and a Swagger UI page
+type OpenAPIHandler struct {
+	title string
+	ops   []openapi.Operation
+
+	once sync.Once
+	doc  openapi.Document
+}
+
+func NewOpenAPIHandler(title string, ops []openapi.Operation) *OpenAPIHandler {
+	return &OpenAPIHandler{title: title, ops: openapi.Versioned(ops, LegacyAPIPrefix, APIPrefixV1)}
+}
+
+// Register mounts /openapi.json and /swagger on the router and logs what
+// Verify finds. Call it after all routes have been added.
+func (h *OpenAPIHandler) Register(router *gin.Engine) {
+	router.GET("/openapi.json", h.Spec)
+	router.GET("/swagger", h.SwaggerUI)
+
+	for _, p := range h.Verify(router) {
+		httpLogger.Warn("openapi spec out of sync", "problem", p)
+	}
+}
+
+// Verify checks that every /api, /admin, /health and /ready route registered
+// on the router is documented and every documented one registered
+func (h *OpenAPIHandler) Verify(router *gin.Engine) []string {
+	return openapi.Verify(h.ops, router.Routes(), "/api", "/admin", "/health", "/ready")
+}
+
+// Spec returns the OpenAPI document
+func (h *OpenAPIHandler) Spec(c *gin.Context) {
+	h.once.Do(func() {
+		h.doc = openapi.Build(h.title, APIVersion, h.ops, ErrorResponse{})
+	})
+	c.JSON(200, h.doc)
+}
+
+// SwaggerUI renders Swagger UI pointing at /openapi.json
+func (h *OpenAPIHandler) SwaggerUI(c *gin.Context) {
+	c.Data(200, "text/html; charset=utf-8", []byte(swaggerHTML))
+}
+
+const swaggerHTML = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8" />
+  <title>Console API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
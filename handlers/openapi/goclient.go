@@ -0,0 +1,173 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GoClient renders a Go source file in package pkg with one method on
+// *Client per operation. Streaming operations are skipped. Paths under
+// legacyPrefix are rewritten to prefix, and method names come from the
+// unversioned path so they stay stable across API versions.
+//
+// The generated methods call c.call(ctx, method, path, query, body, out,
+// security), which the hand-written part of the package provides.
+func GoClient(pkg string, ops []Operation, legacyPrefix, prefix string) ([]byte, error) {
+	g := &goGenerator{imports: map[string]string{}, aliases: map[string]string{}}
+	var methods bytes.Buffer
+	seen := map[string]bool{}
+	for _, op := range ops {
+		if op.Streaming {
+			continue
+		}
+		name := OperationID(op.Method, op.Path)
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate client method %s for %s %s", name, op.Method, op.Path)
+		}
+		seen[name] = true
+		g.method(&methods, name, op, legacyPrefix, prefix)
+	}
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by client/gen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\nimport (\n\t\"context\"\n", pkg)
+	if g.usesURL {
+		src.WriteString("\t\"net/url\"\n")
+	}
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		src.WriteString("\n")
+	}
+	for _, p := range paths {
+		if alias := g.imports[p]; alias != path.Base(p) {
+			fmt.Fprintf(&src, "\t%s %q\n", alias, p)
+		} else {
+			fmt.Fprintf(&src, "\t%q\n", p)
+		}
+	}
+	src.WriteString(")\n")
+	src.Write(methods.Bytes())
+	return format.Source(src.Bytes())
+}
+
+type goGenerator struct {
+	imports map[string]string // import path -> alias
+	aliases map[string]string // alias -> import path
+	usesURL bool
+}
+
+func (g *goGenerator) method(w *bytes.Buffer, name string, op Operation, legacyPrefix, prefix string) {
+	urlPath := op.Path
+	if rest, ok := strings.CutPrefix(urlPath, legacyPrefix+"/"); ok {
+		urlPath = prefix + "/" + rest
+	}
+
+	params := []string{"ctx context.Context"}
+	var pathExpr []string
+	literal := ""
+	for _, s := range strings.Split(urlPath, "/")[1:] {
+		literal += "/"
+		if !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "*") {
+			literal += s
+			continue
+		}
+		param := goIdent(s[1:])
+		params = append(params, param+" string")
+		g.usesURL = true
+		pathExpr = append(pathExpr, strconv.Quote(literal), "url.PathEscape("+param+")")
+		literal = ""
+	}
+	if literal != "" || len(pathExpr) == 0 {
+		pathExpr = append(pathExpr, strconv.Quote(literal))
+	}
+
+	body := "nil"
+	if t := typeOf(op.Request); t != nil {
+		params = append(params, "req "+g.typeExpr(t))
+		body = "req"
+	}
+	query := "nil"
+	if len(op.Query) > 0 {
+		params = append(params, "query url.Values")
+		query = "query"
+		g.usesURL = true
+	}
+
+	fmt.Fprintf(w, "\n// %s calls %s %s", name, op.Method, urlPath)
+	if op.Summary != "" {
+		fmt.Fprintf(w, ": %s", op.Summary)
+	}
+	if len(op.Query) > 0 {
+		fmt.Fprintf(w, "\n// (query: %s)", strings.Join(op.Query, ", "))
+	}
+	w.WriteString("\n")
+	if op.Deprecated {
+		w.WriteString("//\n// Deprecated: the route is kept for old clients only.\n")
+	}
+	if t := typeOf(op.Response); t != nil {
+		fmt.Fprintf(w, "func (c *Client) %s(%s) (out %s, err error) {\n", name, strings.Join(params, ", "), g.typeExpr(t))
+		fmt.Fprintf(w, "\terr = c.call(ctx, %q, %s, %s, %s, &out, %q)\n\treturn\n}\n",
+			op.Method, strings.Join(pathExpr, "+"), query, body, op.Security)
+		return
+	}
+	fmt.Fprintf(w, "func (c *Client) %s(%s) error {\n", name, strings.Join(params, ", "))
+	fmt.Fprintf(w, "\treturn c.call(ctx, %q, %s, %s, %s, nil, %q)\n}\n",
+		op.Method, strings.Join(pathExpr, "+"), query, body, op.Security)
+}
+
+// goIdent makes a path parameter usable as a Go parameter name
+func goIdent(name string) string {
+	if token.IsKeyword(name) || name == "ctx" || name == "req" || name == "query" || name == "url" {
+		return name + "_"
+	}
+	return name
+}
+
+// typeExpr spells t as Go source, importing the packages of named types
+func (g *goGenerator) typeExpr(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name()
+		}
+		return g.importAlias(t.PkgPath()) + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + g.typeExpr(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeExpr(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), g.typeExpr(t.Elem()))
+	case reflect.Map:
+		return "map[" + g.typeExpr(t.Key()) + "]" + g.typeExpr(t.Elem())
+	case reflect.Interface:
+		return "any"
+	}
+	return t.String()
+}
+
+func (g *goGenerator) importAlias(pkgPath string) string {
+	if alias, ok := g.imports[pkgPath]; ok {
+		return alias
+	}
+	base := path.Base(pkgPath)
+	alias := base
+	for i := 2; g.aliases[alias] != "" || alias == "context" || alias == "url"; i++ {
+		alias = base + strconv.Itoa(i)
+	}
+	g.imports[pkgPath] = alias
+	g.aliases[alias] = pkgPath
+	return alias
+}
@@ -0,0 +1,336 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Security schemes understood by the generator
+const (
+	SecurityNone      = ""
+	SecurityBearer    = "bearer"
+	SecuritySignature = "signature"
+)
+
+// Operation describes one HTTP route and its typed request/response bodies
+type Operation struct {
+	Method   string
+	Path     string // gin style, e.g. /api/worker/:id
+	Tag      string
+	Summary  string
+	Security string
+	Query    []string // optional query parameter names
+	Request  any      // zero value of the request body type, nil for no body
+	Response any      // zero value of the 200 response body type
+
+	Deprecated bool
+	Streaming  bool // SSE or WebSocket: Response describes one message, no generated client method
+}
+
+// Versioned moves every operation under legacyPrefix to prefix and keeps a
+// deprecated copy at the legacy path. Operations outside legacyPrefix (e.g.
+// /health) are returned unchanged.
+func Versioned(ops []Operation, legacyPrefix, prefix string) []Operation {
+	var current, legacy []Operation
+	for _, op := range ops {
+		rest, ok := strings.CutPrefix(op.Path, legacyPrefix+"/")
+		if !ok {
+			current = append(current, op)
+			continue
+		}
+		v := op
+		v.Path = prefix + "/" + rest
+		current = append(current, v)
+		op.Deprecated = true
+		legacy = append(legacy, op)
+	}
+	return append(current, legacy...)
+}
+
+// Document is a rendered OpenAPI 3 document
+type Document map[string]any
+
+// Build renders an OpenAPI 3.0 document from the operation list.
+// errorType is used as the schema of every non-2xx response.
+func Build(title, version string, ops []Operation, errorType any) Document {
+	g := &generator{schemas: map[string]any{}}
+	errRef := g.schemaOf(reflect.TypeOf(errorType))
+
+	paths := map[string]any{}
+	for _, op := range ops {
+		path, params := convertPath(op.Path)
+		for _, q := range op.Query {
+			params = append(params, map[string]any{
+				"name": q, "in": "query", "required": false,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+
+		item, _ := paths[path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[path] = item
+		}
+
+		operation := map[string]any{
+			"summary":     op.Summary,
+			"operationId": OperationID(op.Method, op.Path),
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OK",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": g.schemaOf(typeOf(op.Response))},
+					},
+				},
+				"default": map[string]any{
+					"description": "Error",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": errRef},
+					},
+				},
+			},
+		}
+		if op.Tag != "" {
+			operation["tags"] = []string{op.Tag}
+		}
+		if op.Deprecated {
+			operation["deprecated"] = true
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": g.schemaOf(reflect.TypeOf(op.Request))},
+				},
+			}
+		}
+		switch op.Security {
+		case SecurityBearer:
+			operation["security"] = []any{map[string]any{"bearerAuth": []string{}}, map[string]any{"sessionCookie": []string{}}}
+		case SecuritySignature:
+			operation["security"] = []any{map[string]any{"signature": []string{}, "signatureUser": []string{}, "signatureTimestamp": []string{}}}
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return Document{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth":         map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"sessionCookie":      map[string]any{"type": "apiKey", "in": "cookie", "name": "console_session", "description": "Browser session; state-changing requests also need the X-CSRF-Token header"},
+				"signature":          map[string]any{"type": "apiKey", "in": "header", "name": "X-Combinator-Signature"},
+				"signatureUser":      map[string]any{"type": "apiKey", "in": "header", "name": "X-Combinator-User-ID"},
+				"signatureTimestamp": map[string]any{"type": "apiKey", "in": "header", "name": "X-Combinator-Timestamp"},
+			},
+		},
+	}
+}
+
+// Verify compares the documented operations against the routes actually
+// registered on a gin engine. Only routes under one of the given prefixes are
+// checked, so static file routes can be ignored. It returns a human readable
+// list of mismatches; an empty result means spec and router agree.
+func Verify(ops []Operation, routes gin.RoutesInfo, prefixes ...string) []string {
+	documented := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	var problems []string
+	registered := map[string]bool{}
+	for _, r := range routes {
+		if !hasPrefix(r.Path, prefixes) {
+			continue
+		}
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if !documented[key] {
+			problems = append(problems, "undocumented route: "+key)
+		}
+	}
+	for _, op := range ops {
+		if !hasPrefix(op.Path, prefixes) {
+			continue
+		}
+		key := op.Method + " " + op.Path
+		if !registered[key] {
+			problems = append(problems, "documented but not registered: "+key)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertPath turns /api/worker/:id into /api/worker/{id} and returns the path params
+func convertPath(path string) (string, []any) {
+	var params []any
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			name := s[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, map[string]any{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// OperationID derives the operationId (and generated client method name) from
+// the method and path, e.g. GET /api/worker/:id is getWorkerId
+func OperationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, s := range strings.Split(path, "/") {
+		s = strings.TrimLeft(s, ":*")
+		if s == "" || s == "api" {
+			continue
+		}
+		for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' }) {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+func typeOf(v any) reflect.Type {
+	if v == nil {
+		return nil
+	}
+	return reflect.TypeOf(v)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type generator struct {
+	schemas map[string]any
+}
+
+// schemaOf returns an inline schema or a $ref to components/schemas
+func (g *generator) schemaOf(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schemaOf(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = map[string]any{} // placeholder for recursive types
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *generator) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	g.collectFields(t, props, &required)
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *generator) collectFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.collectFields(ft, props, required)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schemaOf(f.Type)
+		if strings.Contains(f.Tag.Get("binding"), "required") && !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// schemaName qualifies the type name with its package to avoid collisions
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return fmt.Sprintf("%s.%s", pkg, t.Name())
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/logging"
+)
+
+// 事务里的副作用走 outbox：enqueueTask 和业务写入在同一个事务里写 outbox 表，
+// 事务回滚任务也不存在，提交后 KickOutbox 让 relay 立刻投递；inner 暂时不可用时按退避重试，
+// 直到投递成功。投递至少一次，任务本身要能重复执行。
+
+const (
+	outboxPollInterval = 2 * time.Second
+	outboxBatchSize    = 50
+	outboxRetention    = 7 * 24 * time.Hour // 已发送的任务保留多久
+)
+
+var outboxLog = logging.Component("outbox")
+
+// outboxKick 有新任务提交时唤醒 relay
+var outboxKick = make(chan struct{}, 1)
+
+// enqueueTask 在事务 tx 里写入待投递任务
+func enqueueTask(ctx context.Context, tx dblayer.Querier, job k8s.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	if _, err := dblayer.EnqueueOutbox(ctx, tx, string(job.Type()), data); err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+	return nil
+}
+
+// KickOutbox 事务提交后调用，relay 不用等到下一次轮询
+func KickOutbox() {
+	select {
+	case outboxKick <- struct{}{}:
+	default:
+	}
+}
+
+// RunOutboxRelay 持续投递 outbox 里的任务，直到 ctx 取消。两个网关都运行，行锁保证不重复投递；
+// inner 传入自己的 processor 直接提交，outer 传 nil 通过 acceptTask 发给 inner
+func RunOutboxRelay(ctx context.Context, proc *k8s.Processor) {
+	send := func(t dblayer.OutboxTask) error {
+		return sendTaskData(k8s.JobType(t.TaskType), []byte(t.Payload))
+	}
+	if proc != nil {
+		send = func(t dblayer.OutboxTask) error {
+			job, err := jobs.CreateJob(k8s.JobType(t.TaskType), []byte(t.Payload))
+			if err != nil {
+				return fmt.Errorf("failed to deserialize job: %w", err)
+			}
+			return proc.Submit(job)
+		}
+	}
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+	for {
+		for {
+			sent, failed, err := dblayer.DeliverOutbox(ctx, outboxBatchSize, send)
+			if err != nil {
+				outboxLog.Error("deliver outbox failed", "error", err)
+				break
+			}
+			if failed > 0 {
+				outboxLog.Warn("outbox tasks not delivered, will retry", "sent", sent, "failed", failed)
+			}
+			// 一批取满说明可能还有，接着投递
+			if sent+failed < outboxBatchSize {
+				break
+			}
+		}
+		if time.Since(lastPrune) > time.Hour {
+			if n, err := dblayer.PruneOutbox(ctx, time.Now().Add(-outboxRetention)); err != nil {
+				outboxLog.Warn("prune outbox failed", "error", err)
+			} else if n > 0 {
+				outboxLog.Info("pruned sent outbox tasks", "tasks", n)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-outboxKick:
+		}
+	}
+}
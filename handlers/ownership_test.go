@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownershipDB 只回答 dblayer.AuthorizeResource 的归属查询；其他查询一律报错，
+// 跨租户请求在归属检查之后还碰了数据库就会变成 500 而不是 404
+type ownershipDB struct {
+	owners map[string]string // 资源类型/ID -> 用户
+}
+
+// ownershipDriver sql.Register 只能注册一次，连接到当前测试的假库
+type ownershipDriver struct{}
+
+var ownershipCurrent *ownershipDB
+
+func (ownershipDriver) Open(string) (driver.Conn, error) { return ownershipConn{ownershipCurrent}, nil }
+
+type ownershipConn struct{ db *ownershipDB }
+
+func (c ownershipConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("ownershipDB: prepared statements not supported")
+}
+func (c ownershipConn) Close() error { return nil }
+func (c ownershipConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("ownershipDB: no transactions")
+}
+
+// ownershipTables 查询里的表和条件 -> 资源类型
+var ownershipTables = []struct{ match, resourceType string }{
+	{"FROM workers", dblayer.ResourceWorker},
+	{"FROM custom_domains", dblayer.ResourceDomain},
+	{"resource_type = 'rdb'", dblayer.ResourceRDB},
+	{"resource_type = 'kv'", dblayer.ResourceKV},
+}
+
+func (c ownershipConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.HasPrefix(query, "SELECT EXISTS") || len(args) != 2 {
+		return nil, errors.New("ownershipDB: unexpected query: " + query)
+	}
+	for _, t := range ownershipTables {
+		if strings.Contains(query, t.match) {
+			owner, ok := c.db.owners[t.resourceType+"/"+args[1].Value.(string)]
+			return &boolRows{v: ok && owner == args[0].Value.(string)}, nil
+		}
+	}
+	return nil, errors.New("ownershipDB: unexpected resource query: " + query)
+}
+
+type boolRows struct {
+	v    bool
+	done bool
+}
+
+func (r *boolRows) Columns() []string { return []string{"exists"} }
+func (r *boolRows) Close() error      { return nil }
+func (r *boolRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.v
+	return nil
+}
+
+const (
+	tenantA = "user-a"
+	tenantB = "user-b"
+)
+
+var registerOwnershipDB sync.Once
+
+// useOwnershipDB 把 dblayer.DB 换成只认识 owners 的假库，测试结束后换回
+func useOwnershipDB(t *testing.T, owners map[string]string) {
+	t.Helper()
+	registerOwnershipDB.Do(func() {
+		sql.Register("ownership-test", ownershipDriver{})
+	})
+	ownershipCurrent = &ownershipDB{owners: owners}
+	conn, err := sql.Open("ownership-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := dblayer.DB
+	dblayer.DB = conn
+	t.Cleanup(func() {
+		dblayer.DB = prev
+		conn.Close()
+	})
+}
+
+func tenantRouter(userUID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", userUID) })
+	wh := NewWorkerHandler()
+	ch := NewCombinatorHandler()
+	r.GET("/worker/:id", wh.GetWorker)
+	r.GET("/worker/:id/versions", wh.ListWorkerVersions)
+	r.GET("/worker/:id/requests", wh.ListWorkerRequests)
+	r.POST("/worker/:id/unpause", wh.UnpauseWorker)
+	r.GET("/domain/:id", GetCustomDomain)
+	r.DELETE("/domain/:id", DeleteCustomDomain)
+	r.GET("/rdb/:id", ch.GetRDB)
+	r.DELETE("/rdb/:id", ch.DeleteRDB)
+	r.GET("/rdb/:id/tasks", ch.ListRDBTasks)
+	r.DELETE("/kv/:id", ch.DeleteKV)
+	return r
+}
+
+func TestCrossTenantAccessReturns404(t *testing.T) {
+	useOwnershipDB(t, map[string]string{
+		dblayer.ResourceWorker + "/w-a": tenantA,
+		dblayer.ResourceDomain + "/d-a": tenantA,
+		dblayer.ResourceRDB + "/r-a":    tenantA,
+		dblayer.ResourceKV + "/k-a":     tenantA,
+	})
+	r := tenantRouter(tenantB)
+
+	tests := []struct {
+		method, path, wantError string
+	}{
+		{"GET", "/worker/w-a", "worker not found"},
+		{"GET", "/worker/w-a/versions", "worker not found"},
+		{"GET", "/worker/w-a/requests", "worker not found"},
+		{"POST", "/worker/w-a/unpause", "worker not found"},
+		{"GET", "/domain/d-a", "domain not found"},
+		{"DELETE", "/domain/d-a", "domain not found"},
+		{"GET", "/rdb/r-a", "resource not found"},
+		{"DELETE", "/rdb/r-a", "resource not found"},
+		{"GET", "/rdb/r-a/tasks", "resource not found"},
+		{"DELETE", "/kv/k-a", "resource not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404; body %s", w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want %q", w.Body.String(), tt.wantError)
+			}
+		})
+	}
+}
+
+// 资源不存在和属于别人返回一样的结果，不泄露其他租户的资源是否存在
+func TestCrossTenantIndistinguishableFromMissing(t *testing.T) {
+	useOwnershipDB(t, map[string]string{dblayer.ResourceWorker + "/w-a": tenantA})
+	r := tenantRouter(tenantB)
+
+	other := httptest.NewRecorder()
+	r.ServeHTTP(other, httptest.NewRequest("GET", "/worker/w-a", nil))
+	missing := httptest.NewRecorder()
+	r.ServeHTTP(missing, httptest.NewRequest("GET", "/worker/w-missing", nil))
+
+	if other.Code != missing.Code || other.Body.String() != missing.Body.String() {
+		t.Errorf("other tenant: %d %s; missing: %d %s", other.Code, other.Body.String(), missing.Code, missing.Body.String())
+	}
+}
+
+func TestAuthorizeResource(t *testing.T) {
+	useOwnershipDB(t, map[string]string{
+		dblayer.ResourceWorker + "/w-a": tenantA,
+		dblayer.ResourceDomain + "/d-a": tenantA,
+		dblayer.ResourceRDB + "/r-a":    tenantA,
+		dblayer.ResourceKV + "/k-a":     tenantA,
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		resourceType, id string
+	}{
+		{dblayer.ResourceWorker, "w-a"},
+		{dblayer.ResourceDomain, "d-a"},
+		{dblayer.ResourceRDB, "r-a"},
+		{dblayer.ResourceKV, "k-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resourceType, func(t *testing.T) {
+			if err := dblayer.AuthorizeResource(ctx, tenantA, tt.resourceType, tt.id); err != nil {
+				t.Errorf("owner: err = %v, want nil", err)
+			}
+			if err := dblayer.AuthorizeResource(ctx, tenantB, tt.resourceType, tt.id); err != dblayer.ErrNotFound {
+				t.Errorf("other tenant: err = %v, want ErrNotFound", err)
+			}
+			if err := dblayer.AuthorizeResource(ctx, "", tt.resourceType, tt.id); err != dblayer.ErrNotFound {
+				t.Errorf("no user: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+
+	// rdb 和 kv 在同一张表里，按类型区分：A 的 rdb ID 不能当成 kv 用
+	if err := dblayer.AuthorizeResource(ctx, tenantA, dblayer.ResourceKV, "r-a"); err != dblayer.ErrNotFound {
+		t.Errorf("rdb id as kv: err = %v, want ErrNotFound", err)
+	}
+	if err := dblayer.AuthorizeResource(ctx, tenantA, "cluster", "c-1"); err == nil || err == dblayer.ErrNotFound {
+		t.Errorf("unknown resource type: err = %v, want an error", err)
+	}
+}
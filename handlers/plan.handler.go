@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accountLimits GET 和 PUT 共用的响应
+func accountLimits(c *gin.Context, uid string) {
+	plan, overrides, err := dblayer.GetAccountLimits(c.Request.Context(), uid)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get account limits"})
+		return
+	}
+	c.JSON(200, AccountLimitsResponse{
+		UserUID:   uid,
+		Plan:      plan,
+		Limits:    overrides.Apply(dblayer.PlanLimitsFor(plan)),
+		Overrides: overrides,
+	})
+}
+
+// syncTenantPlan 套餐或限制变化后更新租户 namespace 的配额，失败只记日志，下次 reconcile 时还会更新
+func syncTenantPlan(c *gin.Context, uid string) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	if err := controller.SyncTenantPlan(ctx, uid); err != nil {
+		RequestLog(c).Warn("sync tenant plan failed", "user_id", uid, "error", err)
+	}
+}
+
+// GetAccountLimits GET /admin/accounts/:uid/limits 用户的套餐、账户级覆盖和生效的限制
+func GetAccountLimits(c *gin.Context) {
+	accountLimits(c, c.Param("uid"))
+}
+
+// SetAccountPlan PUT /admin/accounts/:uid/plan 修改用户套餐，账户级覆盖保留
+func SetAccountPlan(c *gin.Context) {
+	uid := c.Param("uid")
+	var req SetAccountPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if err := dblayer.SetUserPlan(c.Request.Context(), uid, req.Plan); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "user not found"})
+		return
+	} else if err != nil {
+		c.JSON(500, gin.H{"error": "failed to set plan"})
+		return
+	}
+	RequestLog(c).Info("account plan changed", "user_id", uid, "plan", req.Plan)
+	syncTenantPlan(c, uid)
+	accountLimits(c, uid)
+}
+
+// SetAccountLimits PUT /admin/accounts/:uid/limits 整体替换账户级覆盖，不写的项沿用套餐，空对象清除全部覆盖
+func SetAccountLimits(c *gin.Context) {
+	uid := c.Param("uid")
+	var req dblayer.LimitOverrides
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if _, _, err := dblayer.GetAccountLimits(c.Request.Context(), uid); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "user not found"})
+		return
+	} else if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get account limits"})
+		return
+	}
+	if err := dblayer.SetLimitOverrides(c.Request.Context(), uid, req); err != nil {
+		c.JSON(500, gin.H{"error": "failed to set account limits"})
+		return
+	}
+	RequestLog(c).Info("account limits changed", "user_id", uid)
+	syncTenantPlan(c, uid)
+	accountLimits(c, uid)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"slices"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLocale 邮件语言：请求里指定的优先，其次 Accept-Language，都没有时用默认语言
+func requestLocale(c *gin.Context, requested string) string {
+	if notify.SupportedLocale(requested) {
+		return requested
+	}
+	return notify.MatchLocale(c.GetHeader("Accept-Language"))
+}
+
+// GetPreferences GET /api/account/preferences 当前用户的邮件语言
+func GetPreferences(c *gin.Context) {
+	_, locale, err := dblayer.GetUserContact(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		RequestLog(c).Error("get preferences failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get preferences"})
+		return
+	}
+	if locale == "" {
+		locale = notify.DefaultLocale
+	}
+	c.JSON(200, PreferencesResponse{Locale: locale, Locales: notify.Locales})
+}
+
+// SetPreferences PUT /api/account/preferences 修改邮件语言，之后所有发给该用户的邮件都用它
+func SetPreferences(c *gin.Context) {
+	var req SetPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if err := dblayer.SetUserLocale(c.Request.Context(), c.GetString("user_id"), req.Locale); err != nil {
+		RequestLog(c).Error("set preferences failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to set preferences"})
+		return
+	}
+	c.JSON(200, PreferencesResponse{Locale: req.Locale, Locales: notify.Locales})
+}
+
+// GetAccount GET /api/account 当前用户的资料、偏好和每种通知的渠道开关
+func GetAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	profile, err := dblayer.GetAccountProfile(ctx, userUID)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "account not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get account failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get account"})
+		return
+	}
+	prefs, err := dblayer.GetNotificationPreferences(ctx, userUID)
+	if err != nil {
+		RequestLog(c).Error("get notification preferences failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get account"})
+		return
+	}
+	if profile.Locale == "" {
+		profile.Locale = notify.DefaultLocale
+	}
+	c.JSON(200, AccountResponse{AccountProfile: *profile, Locales: notify.Locales, Notifications: prefs})
+}
+
+// UpdateAccount PATCH /api/account 修改资料和偏好，只改请求里出现的字段。
+// 关掉某种通知的邮件或 webhook 后站内通知照常保存
+func UpdateAccount(c *gin.Context) {
+	var req UpdateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	prefs := make(map[string]dblayer.NotificationPreferenceUpdate, len(req.Notifications))
+	for kind, p := range req.Notifications {
+		if !slices.Contains(dblayer.NotificationKinds, kind) {
+			c.JSON(400, gin.H{"error": "unknown notification kind " + kind, "kinds": dblayer.NotificationKinds})
+			return
+		}
+		prefs[kind] = dblayer.NotificationPreferenceUpdate{Email: p.Email, Webhook: p.Webhook}
+	}
+	if req.DefaultRegion != nil && *req.DefaultRegion != "" && !knownRegion(c, *req.DefaultRegion) {
+		return
+	}
+	if req.Timezone != nil && *req.Timezone == "" {
+		utc := "UTC"
+		req.Timezone = &utc
+	}
+
+	u := dblayer.AccountProfileUpdate{
+		DisplayName:   req.DisplayName,
+		DefaultRegion: req.DefaultRegion,
+		Timezone:      req.Timezone,
+		Locale:        req.Locale,
+	}
+	if err := dblayer.UpdateAccountProfile(c.Request.Context(), c.GetString("user_id"), u, prefs); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "account not found"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("update account failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to update account"})
+		return
+	}
+	GetAccount(c)
+}
+
+// knownRegion 检查 region 是某个节点所在的区域，不是时已写好 400。
+// 拿不到区域列表时放行，部署前的校验还会再查一次
+func knownRegion(c *gin.Context, region string) bool {
+	resp, err := getInner[RegionsResponse](c.Request.Context(), "/regions", nil)
+	if err != nil {
+		RequestLog(c).Warn("fetch regions failed, default region not checked", "error", err)
+		return true
+	}
+	names := []string{}
+	for _, r := range resp.Regions {
+		if r.Region == region {
+			return true
+		}
+		if !slices.Contains(names, r.Region) {
+			names = append(names, r.Region)
+		}
+	}
+	c.JSON(400, gin.H{"error": "unknown region " + region, "regions": names})
+	return false
+}
@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PR 预览：worker 关联一个 GitHub 仓库后，仓库的 pull_request webhook 为每个打开的 PR
+// 按 image_template 部署一个临时 worker（复制原 worker 的配置和 env，地址是它自己的子域名），
+// with_db 时再新建一个空 RDB 以 DATABASE_URL 挂载（不是原库的数据分支）。
+// 新提交重新部署，PR 关闭时删除临时 worker 和 RDB。配置了 github_token 时把地址评论回 PR
+
+// githubPullRequestEvent pull_request 事件中用到的字段
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func previewWebhookURL(workerID string) string {
+	return githubWebhookURL("/preview/github/" + workerID)
+}
+
+// previewWorkerName 原名加 -pr-N，超长时截断原名
+func previewWorkerName(baseName string, pr int) string {
+	suffix := "-pr-" + strconv.Itoa(pr)
+	if len(baseName)+len(suffix) > 63 {
+		baseName = baseName[:63-len(suffix)]
+	}
+	return baseName + suffix
+}
+
+type PreviewHandler struct{}
+
+func NewPreviewHandler() *PreviewHandler {
+	return &PreviewHandler{}
+}
+
+// SetConfig 创建或更新 worker 的预览配置；首次创建时生成并返回 webhook secret，之后不再返回
+func (h *PreviewHandler) SetConfig(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetPreviewConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !repoPattern.MatchString(req.Repo) {
+		c.JSON(400, gin.H{"error": "repo must be owner/name"})
+		return
+	}
+	if !validImageTemplate(req.ImageTemplate) {
+		c.JSON(400, gin.H{"error": "image_template does not render to a valid image reference"})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	cfg := &dblayer.PreviewConfig{
+		WorkerID:      workerID,
+		UserUID:       userUID,
+		Repo:          req.Repo,
+		ImageTemplate: req.ImageTemplate,
+		WithDB:        req.WithDB,
+		WebhookSecret: "whsec_" + hex.EncodeToString(secret),
+		GitHubToken:   req.GitHubToken,
+	}
+	created, err := dblayer.UpsertPreviewConfig(c.Request.Context(), cfg)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to save preview config"})
+		return
+	}
+
+	resp := PreviewConfigResponse{PreviewConfig: cfg, WebhookURL: previewWebhookURL(workerID)}
+	status := 200
+	if created {
+		resp.WebhookSecret = cfg.WebhookSecret
+		status = 201
+	}
+	c.JSON(status, resp)
+}
+
+// GetPreviews 获取 worker 的预览配置和当前打开的预览
+func (h *PreviewHandler) GetPreviews(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+
+	cfg, err := dblayer.GetPreviewConfigByOwner(ctx, workerID, userUID)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "previews are not configured for this worker"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get preview config"})
+		return
+	}
+	envs, err := dblayer.ListPreviewEnvironments(ctx, workerID, userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list previews"})
+		return
+	}
+	resp := PreviewsResponse{
+		Config:       PreviewConfigResponse{PreviewConfig: cfg, WebhookURL: previewWebhookURL(workerID)},
+		Environments: make([]PreviewEnvironmentResponse, len(envs)),
+	}
+	for i, e := range envs {
+		resp.Environments[i] = PreviewEnvironmentResponse{PreviewEnvironment: e, URL: workerURL(e.PreviewWorkerID, e.UserUID)}
+	}
+	c.JSON(200, resp)
+}
+
+// DeleteConfig 关闭预览：删除全部预览 worker 和 RDB，再删除配置
+func (h *PreviewHandler) DeleteConfig(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+
+	if _, err := dblayer.GetPreviewConfigByOwner(ctx, workerID, userUID); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "previews are not configured for this worker"})
+		return
+	} else if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get preview config"})
+		return
+	}
+	if err := teardownPreviews(ctx, workerID, userUID); err != nil {
+		RequestLog(c).Error("teardown previews failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete previews"})
+		return
+	}
+	if err := dblayer.DeletePreviewConfig(ctx, workerID, userUID); err != nil && err != dblayer.ErrNotFound {
+		c.JSON(500, gin.H{"error": "failed to delete preview config"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "previews disabled"})
+}
+
+// GitHubWebhook 接收 worker 关联仓库的 GitHub webhook（公开路由，按配置的 secret 校验签名）：
+// pull_request 的 opened/reopened/synchronize 创建或重新部署预览，closed 删除预览，其他事件忽略
+func (h *PreviewHandler) GitHubWebhook(c *gin.Context) {
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	cfg, err := dblayer.GetPreviewConfig(ctx, workerID)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "previews are not configured for this worker"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get preview config"})
+		return
+	}
+	if !validGitHubSignature(cfg.WebhookSecret, payload, c.GetHeader(GitHubSignatureHeader)) {
+		RequestLog(c).Warn("github webhook rejected", "worker_id", workerID)
+		c.JSON(401, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	if c.GetHeader("X-GitHub-Event") != "pull_request" {
+		c.JSON(200, gin.H{"received": true}) // ping 等
+		return
+	}
+	var ev githubPullRequestEvent
+	if err := json.Unmarshal(payload, &ev); err != nil || ev.Number <= 0 {
+		c.JSON(400, gin.H{"error": "invalid pull_request payload"})
+		return
+	}
+	if !strings.EqualFold(ev.Repository.FullName, cfg.Repo) {
+		c.JSON(400, gin.H{"error": "repository " + ev.Repository.FullName + " is not linked to this worker"})
+		return
+	}
+
+	switch ev.Action {
+	case "opened", "reopened", "synchronize":
+		env, err := openPreview(ctx, cfg, &ev)
+		var rejected *DeployRejectedError
+		switch {
+		case errors.Is(err, errPlanLimit):
+			limitResponse(c, err)
+		case errors.As(err, &rejected):
+			c.JSON(422, DeployRejectedResponse{Error: "deploy rejected by policy", Violations: rejected.Violations})
+		case err == errNeverDeployed, err == errAccountSuspended:
+			c.JSON(422, gin.H{"error": err.Error()})
+		case err == dblayer.ErrPreviewExists:
+			// 同一个 PR 的另一次投递正在创建预览，GitHub 上可重新投递
+			c.JSON(409, gin.H{"error": err.Error()})
+		case err != nil:
+			RequestLog(c).Error("open preview failed", "worker_id", workerID, "pr", ev.Number, "error", err)
+			c.JSON(500, gin.H{"error": "failed to deploy preview"})
+		default:
+			c.JSON(200, PreviewEnvironmentResponse{PreviewEnvironment: env, URL: workerURL(env.PreviewWorkerID, env.UserUID)})
+		}
+	case "closed":
+		env, err := dblayer.GetPreviewEnvironment(ctx, workerID, ev.Number)
+		if err == dblayer.ErrNotFound {
+			c.JSON(200, gin.H{"received": true})
+			return
+		}
+		if err == nil {
+			err = closePreview(ctx, env)
+		}
+		if err != nil {
+			RequestLog(c).Error("close preview failed", "worker_id", workerID, "pr", ev.Number, "error", err)
+			c.JSON(500, gin.H{"error": "failed to delete preview"})
+			return
+		}
+		c.JSON(200, MessageResponse{Message: "preview deleted"})
+	default:
+		c.JSON(200, gin.H{"received": true})
+	}
+}
+
+// openPreview 按 PR 的最新提交部署预览，没有预览（或预览 worker 已被手动删除）时先创建；
+// 同一提交不重复部署
+func openPreview(ctx context.Context, cfg *dblayer.PreviewConfig, ev *githubPullRequestEvent) (*dblayer.PreviewEnvironment, error) {
+	if err := dblayer.AuthorizeResource(ctx, cfg.UserUID, dblayer.ResourceWorker, cfg.WorkerID); err != nil {
+		return nil, fmt.Errorf("authorize base worker: %w", err)
+	}
+	base, err := dblayer.GetWorkerByOwner(ctx, cfg.WorkerID, cfg.UserUID)
+	if err != nil {
+		return nil, fmt.Errorf("get base worker: %w", err)
+	}
+	version, err := webhookBaseVersion(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := dblayer.GetPreviewEnvironment(ctx, cfg.WorkerID, ev.Number)
+	if err == nil {
+		if err := dblayer.AuthorizeResource(ctx, env.UserUID, dblayer.ResourceWorker, env.PreviewWorkerID); err == dblayer.ErrNotFound {
+			if err := closePreview(ctx, env); err != nil {
+				return nil, err
+			}
+			err = dblayer.ErrNotFound
+		}
+	}
+	created := false
+	switch {
+	case err == dblayer.ErrNotFound:
+		if env, err = createPreview(ctx, cfg, base, ev); err != nil {
+			return nil, err
+		}
+		created = true
+	case err != nil:
+		return nil, fmt.Errorf("get preview: %w", err)
+	case env.HeadSHA == ev.PullRequest.Head.SHA:
+		return env, nil
+	}
+
+	image := renderImageTemplate(cfg.ImageTemplate, ev.Number, ev.PullRequest.Head.SHA, ev.PullRequest.Head.Ref)
+	if _, err := deployWorker(ctx, DeployWorkerRequest{
+		UserUID:   env.UserUID,
+		WorkerID:  env.PreviewWorkerID,
+		Image:     image,
+		Port:      version.Port,
+		CommitSHA: ev.PullRequest.Head.SHA,
+		Initiator: dblayer.DeployInitiatorPreview,
+	}); err != nil {
+		return nil, err
+	}
+	if err := dblayer.SetPreviewHead(ctx, env.ID, ev.PullRequest.Head.SHA); err != nil {
+		serviceLog.Warn("record preview head failed", "worker_id", env.PreviewWorkerID, "error", err)
+	}
+	env.HeadSHA = ev.PullRequest.Head.SHA
+
+	if created {
+		body := fmt.Sprintf("Preview for #%d is deploying at %s", ev.Number, workerURL(env.PreviewWorkerID, env.UserUID))
+		if err := SendTask(jobs.NewPreviewCommentJob(cfg.WorkerID, cfg.UserUID, ev.Number, body)); err != nil {
+			serviceLog.Warn("enqueue preview comment failed", "worker_id", cfg.WorkerID, "pr", ev.Number, "error", err)
+		}
+	}
+	return env, nil
+}
+
+// createPreview 按原 worker 的配置创建预览 worker，复制 env（不含 worker secret），with_db 时新建 RDB 并挂载；
+// 任何一步失败都清理已创建的部分
+func createPreview(ctx context.Context, cfg *dblayer.PreviewConfig, base *dblayer.Worker, ev *githubPullRequestEvent) (*dblayer.PreviewEnvironment, error) {
+	if err := checkWorkerLimits(ctx, cfg.UserUID, true, workerSpec{
+		CPU: base.AssignedCPU, Memory: base.AssignedMemory, MaxReplicas: base.MaxReplicas,
+		RunAsRoot: base.RunAsRoot, WritableRootFS: base.WritableRootFS,
+	}); err != nil {
+		return nil, err
+	}
+	if cfg.WithDB {
+		if err := checkRDBLimit(ctx, cfg.UserUID); err != nil {
+			return nil, err
+		}
+	}
+
+	env := &dblayer.PreviewEnvironment{
+		BaseWorkerID:    cfg.WorkerID,
+		UserUID:         cfg.UserUID,
+		PRNumber:        ev.Number,
+		PreviewWorkerID: uuid.New().String()[:8],
+	}
+	tags := dblayer.Tags{"preview-of": base.WID, "preview-pr": strconv.Itoa(ev.Number)}
+	if err := dblayer.CreateWorker(ctx, dblayer.DB, env.PreviewWorkerID, cfg.UserUID, previewWorkerName(base.WorkerName, ev.Number),
+		base.AssignedCPU, base.AssignedMemory, base.AssignedDisk, base.MaxReplicas, base.MainRegion, base.Cluster, base.Pool, base.Arch,
+		base.RunAsRoot, base.WritableRootFS, tags, base.Environment, dblayer.WorkerKindContainer); err != nil {
+		return nil, fmt.Errorf("create preview worker: %w", err)
+	}
+	if err := setupPreview(ctx, cfg, base, env, tags); err != nil {
+		if cerr := closePreview(ctx, env); cerr != nil {
+			serviceLog.Warn("clean up preview failed", "worker_id", env.PreviewWorkerID, "error", cerr)
+		}
+		return nil, err
+	}
+	return env, nil
+}
+
+func setupPreview(ctx context.Context, cfg *dblayer.PreviewConfig, base *dblayer.Worker, env *dblayer.PreviewEnvironment, tags dblayer.Tags) error {
+	var envMap map[string]string
+	json.Unmarshal([]byte(base.EnvJSON), &envMap)
+	if cfg.WithDB {
+		delete(envMap, defaultAttachEnv["rdb"]) // 由挂载的 RDB 提供
+	}
+	if len(envMap) > 0 {
+		data, _ := json.Marshal(envMap)
+		if _, err := dblayer.SetWorkerEnvByOwner(ctx, dblayer.DB, env.PreviewWorkerID, env.UserUID, string(data), 0); err != nil {
+			return fmt.Errorf("copy env: %w", err)
+		}
+	}
+
+	if cfg.WithDB {
+		rid, err := createCombinatorResource(ctx, env.UserUID, "rdb", previewWorkerName(base.WorkerName, env.PRNumber), tags, base.Environment)
+		if err != nil {
+			return err
+		}
+		env.RDBID = rid
+		// 预览 worker 还没有 Secret，controller 首次创建时写入连接串，不需要同步任务
+		if err := dblayer.AttachWorkerResource(ctx, dblayer.DB, env.PreviewWorkerID, env.UserUID, "rdb", rid, defaultAttachEnv["rdb"]); err != nil {
+			return fmt.Errorf("attach preview rdb: %w", err)
+		}
+	}
+
+	if err := dblayer.CreatePreviewEnvironment(ctx, env); err != nil {
+		return fmt.Errorf("record preview: %w", err)
+	}
+	return nil
+}
+
+// closePreview 删除预览 worker、RDB 和预览记录，已经不存在的部分跳过
+func closePreview(ctx context.Context, env *dblayer.PreviewEnvironment) error {
+	if err := deleteWorker(ctx, env.PreviewWorkerID, env.UserUID); err != nil && err != dblayer.ErrNotFound {
+		return err
+	}
+	if env.RDBID != "" {
+		if err := deleteCombinatorResource(ctx, env.UserUID, "rdb", env.RDBID); err != nil && err != dblayer.ErrNotFound {
+			return err
+		}
+	}
+	if env.ID == 0 {
+		return nil
+	}
+	return dblayer.DeletePreviewEnvironment(ctx, env.ID)
+}
+
+// teardownPreviews 删除 worker 的全部预览（关闭预览、删除原 worker 前调用）
+func teardownPreviews(ctx context.Context, workerID, userUID string) error {
+	envs, err := dblayer.ListPreviewEnvironments(ctx, workerID, userUID)
+	if err != nil {
+		return err
+	}
+	for _, env := range envs {
+		if err := closePreview(ctx, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/url"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 消息队列和 RDB/KV 一样记在 combinator_resources，由 inner 在共享的 RabbitMQ 上创建：
+// 每个用户一个 vhost 和同名的 broker 用户，每个队列资源是 vhost 里以资源 ID 命名的 durable 队列。
+// 连接信息写进 combinator 配置，挂载到 worker 后注入 AMQP_URL；队列深度等指标由 inner 实时从 broker 读取
+
+// CreateQueue creates a queue resource record and submits async job
+func (h *CombinatorHandler) CreateQueue(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	var req CreateQueueRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, bindError(err))
+			return
+		}
+	}
+
+	resourceID, err := createCombinatorResource(c.Request.Context(), userUID, "queue", req.Name, req.Tags, req.Environment)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, ResourceCreatedResponse{ID: resourceID, Status: "loading"})
+}
+
+// ListQueues lists queue resources for user from database, one page at a time
+func (h *CombinatorHandler) ListQueues(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Type = "queue"
+
+	resources, next, err := dblayer.ListCombinatorResources(c.Request.Context(), userUID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list resources: " + err.Error()})
+		return
+	}
+
+	if err := dblayer.MarkPendingApply(c.Request.Context(), userUID, resources); err != nil {
+		RequestLog(c).Warn("read config revision failed", "error", err)
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListQueuesResponse{Queues: resources, NextCursor: next})
+}
+
+// GetQueue returns detail of a single queue resource with its live depth,
+// consumers and message rates from inner
+func (h *CombinatorHandler) GetQueue(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	resourceID := c.Param("id")
+	ctx := c.Request.Context()
+
+	cr, err := dblayer.GetCombinatorResource(ctx, userUID, "queue", resourceID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "resource not found"})
+		return
+	}
+
+	var stats *k8s.QueueStats
+	if cr.Status == "active" {
+		q := url.Values{"user_uid": {userUID}, "resource_id": {cr.ResourceID}}
+		if stats, err = getInner[k8s.QueueStats](ctx, "/queue/stats", q); err != nil {
+			RequestLog(c).Warn("fetch queue stats failed", "resource_id", cr.ResourceID, "error", err)
+		}
+	}
+	if err := dblayer.MarkPendingApply(ctx, userUID, []*dblayer.CombinatorResource{cr}); err != nil {
+		RequestLog(c).Warn("read config revision failed", "error", err)
+	}
+
+	c.JSON(200, QueueDetailResponse{
+		ID:         cr.ID,
+		ResourceID: cr.ResourceID,
+		Name:       cr.Name,
+		Status:     cr.Status,
+		Msg:        cr.Msg,
+		CreatedAt:  cr.CreatedAt,
+		Stats:      stats,
+	})
+}
+
+// DeleteQueue deletes a queue resource record and submits async job, which
+// deletes the queue with its messages
+func (h *CombinatorHandler) DeleteQueue(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	resourceID := c.Param("id")
+
+	if !checkResourcePrecondition(c, userUID, "queue", resourceID) {
+		return
+	}
+	if err := deleteCombinatorResource(c.Request.Context(), userUID, "queue", resourceID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "resource not found"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// SetQueueTags replaces the tags of a queue resource
+func (h *CombinatorHandler) SetQueueTags(c *gin.Context) {
+	h.setTags(c, "queue")
+}
+
+// SetQueueEnvironment moves a queue resource to another environment
+func (h *CombinatorHandler) SetQueueEnvironment(c *gin.Context) {
+	h.setEnvironment(c, "queue")
+}
+
+// QueueStats (inner) reads the state of a queue resource from the broker
+func QueueStats(c *gin.Context) {
+	userUID, resourceID := c.Query("user_uid"), c.Query("resource_id")
+	if userUID == "" || resourceID == "" {
+		c.JSON(400, gin.H{"error": "user_uid and resource_id are required"})
+		return
+	}
+	if k8s.QueueBroker == nil {
+		c.JSON(503, gin.H{"error": "queue broker not available"})
+		return
+	}
+	stats, err := k8s.QueueBroker.QueueStats(c.Request.Context(), userUID, resourceID)
+	if err == k8s.ErrQueueNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, stats)
+}
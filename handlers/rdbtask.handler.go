@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 定时 SQL 任务挂在 RDB 资源下。outer 只写数据库（包括下次执行时间和"立即执行"标记），
+// 由 inner 的 rdb.run_tasks 认领执行
+
+// 定时任务的默认值与限制
+const (
+	MaxRDBTasks            = 20
+	DefaultRDBTaskTimeout  = 300
+	minRDBTaskInterval     = time.Minute
+	defaultRDBTaskTimezone = "UTC"
+)
+
+// ListRDBTasks 分页列出一个 RDB 下的定时任务，?status=pending|succeeded|failed|timeout 过滤最近一次的结果
+func (h *CombinatorHandler) ListRDBTasks(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	if !authorizeResource(c, dblayer.ResourceRDB, c.Param("id")) {
+		return
+	}
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	tasks, next, err := dblayer.ListRDBTasks(c.Request.Context(), userUID, c.Param("id"), opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("list rdb tasks failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list tasks"})
+		return
+	}
+	if tasks == nil {
+		tasks = []*dblayer.RDBTask{}
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListRDBTasksResponse{Tasks: tasks, NextCursor: next})
+}
+
+// CreateRDBTask 在一个已创建好的 RDB 上新建定时任务
+func (h *CombinatorHandler) CreateRDBTask(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	ctx := c.Request.Context()
+	cr, err := dblayer.GetCombinatorResource(ctx, userUID, "rdb", c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "resource not found"})
+		return
+	}
+	if cr.Status != "active" {
+		c.JSON(409, gin.H{"error": "database is not ready"})
+		return
+	}
+	var req RDBTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	task, ok := rdbTaskFromRequest(c, &req)
+	if !ok {
+		return
+	}
+	task.UserUID, task.ResourceID, task.RDBID = userUID, cr.ID, cr.ResourceID
+
+	n, err := dblayer.CountRDBTasks(ctx, userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to count tasks"})
+		return
+	}
+	if n >= MaxRDBTasks {
+		c.JSON(409, gin.H{"error": fmt.Sprintf("at most %d scheduled tasks per user", MaxRDBTasks)})
+		return
+	}
+
+	if err := dblayer.CreateRDBTask(ctx, task); err != nil {
+		if err == dblayer.ErrRDBTaskExists {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		RequestLog(c).Error("create rdb task failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create task"})
+		return
+	}
+
+	c.JSON(200, task)
+}
+
+// GetRDBTask 定时任务详情
+func (h *CombinatorHandler) GetRDBTask(c *gin.Context) {
+	task, ok := ownedRDBTask(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, task)
+}
+
+// UpdateRDBTask 整体替换定时任务配置，下次执行时间按新的计划重新计算
+func (h *CombinatorHandler) UpdateRDBTask(c *gin.Context) {
+	existing, ok := ownedRDBTask(c)
+	if !ok {
+		return
+	}
+	var req RDBTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	task, ok := rdbTaskFromRequest(c, &req)
+	if !ok {
+		return
+	}
+	task.ID, task.UserUID = existing.ID, existing.UserUID
+
+	ctx := c.Request.Context()
+	if err := dblayer.UpdateRDBTask(ctx, task); err != nil {
+		switch err {
+		case dblayer.ErrNotFound:
+			c.JSON(404, gin.H{"error": "task not found"})
+		case dblayer.ErrRDBTaskExists:
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			RequestLog(c).Error("update rdb task failed", "task_id", task.ID, "error", err)
+			c.JSON(500, gin.H{"error": "failed to update task"})
+		}
+		return
+	}
+
+	updated, err := dblayer.GetRDBTask(ctx, existing.UserUID, existing.RDBID, existing.ID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "task not found"})
+		return
+	}
+	c.JSON(200, updated)
+}
+
+// DeleteRDBTask 删除定时任务及其执行记录，正在执行的语句会跑完
+func (h *CombinatorHandler) DeleteRDBTask(c *gin.Context) {
+	task, ok := ownedRDBTask(c)
+	if !ok {
+		return
+	}
+	if err := dblayer.DeleteRDBTask(c.Request.Context(), task.UserUID, task.ID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "task not found"})
+		} else {
+			c.JSON(500, gin.H{"error": "failed to delete task"})
+		}
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// RunRDBTask 让任务立即执行一次，停用的任务也可以；在 inner 下一轮认领时开始（最多 30 秒），
+// 上一次还没执行完时等它结束
+func (h *CombinatorHandler) RunRDBTask(c *gin.Context) {
+	task, ok := ownedRDBTask(c)
+	if !ok {
+		return
+	}
+	if err := dblayer.RequestRDBTaskRun(c.Request.Context(), task.UserUID, task.ID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "task not found"})
+		} else {
+			c.JSON(500, gin.H{"error": "failed to request run"})
+		}
+		return
+	}
+	c.JSON(202, MessageResponse{Message: "run requested"})
+}
+
+// ListRDBTaskRuns 分页列出任务的执行记录（保留 30 天），?status=running|succeeded|failed|timeout 过滤
+func (h *CombinatorHandler) ListRDBTaskRuns(c *gin.Context) {
+	task, ok := ownedRDBTask(c)
+	if !ok {
+		return
+	}
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	runs, next, err := dblayer.ListRDBTaskRuns(c.Request.Context(), task.ID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("list rdb task runs failed", "task_id", task.ID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to list task runs"})
+		return
+	}
+	if runs == nil {
+		runs = []*dblayer.RDBTaskRun{}
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListRDBTaskRunsResponse{Runs: runs, NextCursor: next})
+}
+
+// ownedRDBTask 按 :id（RDB）和 :task 取当前用户的任务，不存在时已写好 404
+func ownedRDBTask(c *gin.Context) (*dblayer.RDBTask, bool) {
+	id, err := strconv.Atoi(c.Param("task"))
+	if err == nil {
+		task, err := dblayer.GetRDBTask(c.Request.Context(), c.GetString("user_id"), c.Param("id"), id)
+		if err == nil {
+			return task, true
+		}
+		if err != dblayer.ErrNotFound {
+			RequestLog(c).Error("get rdb task failed", "task_id", id, "error", err)
+			c.JSON(500, gin.H{"error": "failed to load task"})
+			return nil, false
+		}
+	}
+	c.JSON(404, gin.H{"error": "task not found"})
+	return nil, false
+}
+
+// rdbTaskFromRequest 填上默认值并算出下次执行时间；计划触发太频繁或永远不会触发时已写好 400
+func rdbTaskFromRequest(c *gin.Context, req *RDBTaskRequest) (*dblayer.RDBTask, bool) {
+	task := &dblayer.RDBTask{
+		Name:           req.Name,
+		Schedule:       req.Schedule,
+		Timezone:       req.Timezone,
+		SQL:            req.SQL,
+		TimeoutSeconds: req.TimeoutSeconds,
+		NotifyEmail:    req.NotifyEmail == nil || *req.NotifyEmail,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+	}
+	if task.Timezone == "" {
+		// 默认用账号的时区
+		tz, err := dblayer.GetUserTimezone(c.Request.Context(), c.GetString("user_id"))
+		if err != nil || tz == "" {
+			tz = defaultRDBTaskTimezone
+		}
+		task.Timezone = tz
+	}
+	if task.TimeoutSeconds == 0 {
+		task.TimeoutSeconds = DefaultRDBTaskTimeout
+	}
+
+	// binding 已经校验过语法
+	if sched, err := k8s.ParseCronSchedule(task.Schedule); err == nil && sched.Interval() > 0 && sched.Interval() < minRDBTaskInterval {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("schedule must not run more often than every %s", minRDBTaskInterval)})
+		return nil, false
+	}
+	next := jobs.NextRDBTaskRun(task.Schedule, task.Timezone, time.Now().UTC())
+	if next == nil {
+		c.JSON(400, gin.H{"error": "schedule never fires"})
+		return nil, false
+	}
+	if task.Enabled {
+		task.NextRunAt = next
+	}
+	return task, true
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"jabberwocky238/console/logging"
+	"jabberwocky238/console/secrets"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 密钥只在创建时返回一次（注册返回的 secret_key），之后只能通过显式的 reveal 接口查看，并记入安全事件。
+// 列表和读取接口里的 worker env 按 key 名（logging.SensitiveName）和值的形状打码；
+// 错误响应和日志里形似密钥的内容由 RedactErrors、logging.RedactingHandler 统一打码。
+
+// maskedEnvValue 打码后的 env 值
+const maskedEnvValue = "********"
+
+// maskEnv 返回打码后的 env：敏感 key 的值和形似密钥的值都替换掉，secretref:// 引用原样返回
+func maskEnv(env map[string]string) map[string]string {
+	out := make(map[string]string, len(env))
+	for k, v := range env {
+		switch {
+		case v == "" || strings.HasPrefix(v, secrets.RefPrefix):
+			out[k] = v
+		case logging.SensitiveName(k) || logging.Redact(v) != v:
+			out[k] = maskedEnvValue
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// redactWriter 给 4xx/5xx 响应体打码
+type redactWriter struct {
+	gin.ResponseWriter
+}
+
+func (w redactWriter) Write(b []byte) (int, error) {
+	if w.Status() < 400 {
+		return w.ResponseWriter.Write(b)
+	}
+	if _, err := w.ResponseWriter.Write([]byte(logging.Redact(string(b)))); err != nil {
+		return 0, err
+	}
+	// 按调用方传入的长度返回，打码后长度变化不影响调用方
+	return len(b), nil
+}
+
+func (w redactWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap 让 http.ResponseController 能调整底层连接的 deadline
+func (w redactWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RedactErrors 错误响应（4xx/5xx）里的数据库连接串、token、密钥等打码，
+// 避免 err.Error() 原样返回给客户端时泄露
+func RedactErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = redactWriter{c.Writer}
+		c.Next()
+	}
+}
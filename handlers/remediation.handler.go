@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"cmp"
+	"database/sql"
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 自动处理策略：inner 的 pod 监听看到 OOM kill 或 CrashLoopBackOff 时按策略加内存、建议扩容或暂停 worker，
+// 每次动作都记一条处理记录并通知用户。建议的扩容可以在这里一键采纳
+
+const workerRemediationHistory = 50
+
+// GetWorkerRemediation GET /api/worker/:id/remediation 自动处理策略，没有配置过时返回默认（全部关闭）
+func (h *WorkerHandler) GetWorkerRemediation(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	p, err := dblayer.GetRemediationPolicy(c.Request.Context(), workerID, userUID)
+	if err == dblayer.ErrNotFound {
+		c.JSON(200, dblayer.DefaultRemediationPolicy(workerID, userUID))
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get remediation policy failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get remediation policy"})
+		return
+	}
+	c.JSON(200, p)
+}
+
+// SetWorkerRemediation PUT /api/worker/:id/remediation 整体替换自动处理策略
+func (h *WorkerHandler) SetWorkerRemediation(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetRemediationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	p := &dblayer.WorkerRemediationPolicy{
+		WorkerID: workerID, UserUID: userUID,
+		OOMAction: req.OOMAction, OOMThreshold: req.OOMThreshold, MaxMemory: req.MaxMemory,
+		CrashAction: req.CrashAction, CrashThreshold: req.CrashThreshold, WindowSeconds: req.WindowSeconds,
+	}
+	if err := dblayer.SetRemediationPolicy(c.Request.Context(), p); err != nil {
+		RequestLog(c).Error("set remediation policy failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to save remediation policy"})
+		return
+	}
+	c.JSON(200, p)
+}
+
+// DeleteWorkerRemediation DELETE /api/worker/:id/remediation 关闭自动处理，处理记录保留
+func (h *WorkerHandler) DeleteWorkerRemediation(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	err := dblayer.DeleteRemediationPolicy(c.Request.Context(), workerID, userUID)
+	if err != nil && err != dblayer.ErrNotFound {
+		RequestLog(c).Error("delete remediation policy failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete remediation policy"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "remediation policy deleted"})
+}
+
+// ListWorkerRemediations GET /api/worker/:id/remediations 最近的自动处理记录，新的在前
+func (h *WorkerHandler) ListWorkerRemediations(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	list, err := dblayer.ListRemediations(c.Request.Context(), workerID, userUID, workerRemediationHistory)
+	if err != nil {
+		RequestLog(c).Error("list remediations failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to list remediations"})
+		return
+	}
+	c.JSON(200, WorkerRemediationsResponse{Remediations: list})
+}
+
+// ApplyWorkerRemediation POST /api/worker/:id/remediations/:rid/apply 采纳一条扩容建议：
+// 把 worker 的内存改成建议值并同步到集群。worker 的内存在建议之后被改过时返回 409
+func (h *WorkerHandler) ApplyWorkerRemediation(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("rid"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid remediation id"})
+		return
+	}
+	r, err := dblayer.GetRemediation(ctx, id, workerID, userUID)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "remediation not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get remediation failed", "remediation_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get remediation"})
+		return
+	}
+	if r.Action != dblayer.RemediationResizeSuggested || r.ToMemory == "" {
+		c.JSON(409, gin.H{"error": "remediation has no resize to apply"})
+		return
+	}
+	if r.AppliedAt != nil {
+		c.JSON(409, gin.H{"error": "resize already applied"})
+		return
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, workerID, userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	if cmp.Or(w.AssignedMemory, k8s.DefaultWorkerMemory) != r.FromMemory {
+		c.JSON(409, gin.H{"error": "worker memory changed since the resize was suggested"})
+		return
+	}
+	// 套餐可能在建议之后降级
+	if err := checkWorkerLimits(ctx, userUID, false, workerSpec{
+		CPU: w.AssignedCPU, Memory: r.ToMemory, MaxReplicas: w.MaxReplicas,
+		RunAsRoot: w.RunAsRoot, WritableRootFS: w.WritableRootFS,
+	}); err != nil {
+		limitResponse(c, err)
+		return
+	}
+	// 内存和同步任务一起提交，不会出现改了库但集群不跟着变的情况
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.ApplyRemediationResize(ctx, tx, r, w.AssignedMemory); err != nil {
+			return err
+		}
+		return enqueueTask(ctx, tx, jobs.NewSyncSpecJob(workerID, userUID))
+	})
+	if err == dblayer.ErrNotFound {
+		c.JSON(409, gin.H{"error": "worker memory changed since the resize was suggested"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("apply remediation failed", "remediation_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to apply resize"})
+		return
+	}
+	KickOutbox()
+	c.JSON(200, MessageResponse{Message: "worker memory set to " + r.ToMemory})
+}
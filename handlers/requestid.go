@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"jabberwocky238/console/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求关联 ID，响应里回写，客户端报障时带上即可定位日志
+const RequestIDHeader = "X-Request-ID"
+
+var (
+	httpLogger = logging.Component("http")
+
+	// 上游（ingress、outer）传来的 ID 只接受这种格式，防止日志注入
+	requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+)
+
+// RequestLogger 给每个请求分配 request_id（沿用合法的上游 X-Request-ID），把带 request_id/method/route
+// 的 logger 放进请求 context，请求结束时记一条访问日志，认证过的请求带 user_id
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Set("request_id", id)
+
+		l := httpLogger.With("request_id", id, "method", c.Request.Method, "route", c.FullPath())
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), l))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.Int("status", status),
+			slog.String("path", c.Request.URL.Path),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if uid := c.GetString("user_id"); uid != "" {
+			attrs = append(attrs, slog.String("user_id", uid))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		l.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// RequestLog 当前请求的 logger（request_id、route，认证后带 user_id）
+func RequestLog(c *gin.Context) *slog.Logger {
+	l := logging.FromContext(c.Request.Context())
+	if uid := c.GetString("user_id"); uid != "" {
+		l = l.With("user_id", uid)
+	}
+	return l
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
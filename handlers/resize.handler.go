@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 纵向调整：outer 改 worker 的 CPU/内存/磁盘并记一条调整记录，和同步任务在同一个事务里入队；
+// inner 把新配置写进 WorkerApp CR，controller 更新 Deployment 后滚动替换 pod。不重新部署，不产生部署版本
+
+const workerResizeHistory = 50
+
+// ResizeWorker PATCH /api/worker/:id/resources 调整运行中 worker 的资源，202 返回调整记录
+func (h *WorkerHandler) ResizeWorker(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+
+	var req ResizeWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if req.AssignedCPU == nil && req.AssignedMemory == nil && req.AssignedDisk == nil {
+		c.JSON(400, gin.H{"error": "assigned_cpu, assigned_memory or assigned_disk is required"})
+		return
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, workerID, userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	if preconditionFailed(c, w.UpdatedAt) {
+		return
+	}
+
+	r := &dblayer.WorkerResourceChange{
+		UserUID: userUID, WorkerID: workerID, Actor: "user",
+		FromCPU: w.AssignedCPU, ToCPU: orKeep(req.AssignedCPU, w.AssignedCPU),
+		FromMemory: w.AssignedMemory, ToMemory: orKeep(req.AssignedMemory, w.AssignedMemory),
+		FromDisk: w.AssignedDisk, ToDisk: orKeep(req.AssignedDisk, w.AssignedDisk),
+	}
+	// 空串表示恢复默认
+	if r.ToCPU == r.FromCPU && r.ToMemory == r.FromMemory && r.ToDisk == r.FromDisk {
+		c.JSON(400, gin.H{"error": "resources unchanged"})
+		return
+	}
+	if err := checkWorkerLimits(ctx, userUID, false, workerSpec{
+		CPU: r.ToCPU, Memory: r.ToMemory, MaxReplicas: w.MaxReplicas,
+		RunAsRoot: w.RunAsRoot, WritableRootFS: w.WritableRootFS,
+	}); err != nil {
+		limitResponse(c, err)
+		return
+	}
+
+	var version int
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if version, err = dblayer.ResizeWorker(ctx, tx, r, req.ExpectedVersion); err != nil {
+			return err
+		}
+		return enqueueTask(ctx, tx, jobs.NewResizeWorkerJob(r.ID, workerID, userUID))
+	})
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		RequestLog(c).Error("enqueue worker resize failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to enqueue resize task"})
+		return
+	}
+	KickOutbox()
+	RequestLog(c).Info("worker resize requested", "worker_id", workerID, "change_id", r.ID)
+	c.JSON(202, ResizeWorkerResponse{Change: r, Version: version})
+}
+
+// ListWorkerResourceChanges GET /api/worker/:id/resources/changes 最近的资源调整记录
+func (h *WorkerHandler) ListWorkerResourceChanges(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	list, err := dblayer.ListWorkerResourceChanges(c.Request.Context(), workerID, userUID, workerResizeHistory)
+	if err != nil {
+		RequestLog(c).Error("list worker resource changes failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to list resource changes"})
+		return
+	}
+	c.JSON(200, WorkerResourceChangesResponse{Changes: list})
+}
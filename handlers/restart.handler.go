@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"slices"
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 滚动重启：outer 记录审计（谁、什么时候、为什么）并和重启任务在同一个事务里入队，
+// inner 给 Deployment 打重启注解。返回的重启 ID 用来查询这次滚动的进度
+
+const workerRestartHistory = 50
+
+// RestartWorker POST /api/worker/:id/restart 滚动重启 worker 的所有 pod，202 返回进度句柄
+func (h *WorkerHandler) RestartWorker(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+
+	var req RestartWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	w, err := dblayer.GetWorkerByOwner(ctx, workerID, userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	// 暂停中的 worker 没有 pod，重启没有意义
+	if slices.Contains([]string{"paused", jobs.WorkerStatusIdle, jobs.WorkerStatusCrashPaused, jobs.WorkerStatusCapped, jobs.WorkerStatusSuspended}, w.Status) {
+		c.JSON(409, gin.H{"error": "worker is " + w.Status + ", resume it instead"})
+		return
+	}
+	if preconditionFailed(c, w.UpdatedAt) {
+		return
+	}
+
+	r := &dblayer.WorkerRestart{UserUID: userUID, WorkerID: workerID, Actor: "user", Reason: req.Reason}
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.CreateWorkerRestart(ctx, tx, r); err != nil {
+			return err
+		}
+		return enqueueTask(ctx, tx, jobs.NewRestartWorkerJob(r.ID, workerID, userUID))
+	})
+	if err != nil {
+		RequestLog(c).Error("enqueue worker restart failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to enqueue restart task"})
+		return
+	}
+	KickOutbox()
+	RequestLog(c).Info("worker restart requested", "worker_id", workerID, "restart_id", r.ID)
+	c.JSON(202, WorkerRestartResponse{Restart: r})
+}
+
+// ListWorkerRestarts GET /api/worker/:id/restarts 最近的重启记录
+func (h *WorkerHandler) ListWorkerRestarts(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	list, err := dblayer.ListWorkerRestarts(c.Request.Context(), workerID, userUID, workerRestartHistory)
+	if err != nil {
+		RequestLog(c).Error("list worker restarts failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to list restarts"})
+		return
+	}
+	c.JSON(200, WorkerRestartsResponse{Restarts: list})
+}
+
+// GetWorkerRestart GET /api/worker/:id/restarts/:rid 一次重启及其滚动进度
+func (h *WorkerHandler) GetWorkerRestart(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("rid"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid restart id"})
+		return
+	}
+	r, err := dblayer.GetWorkerRestart(ctx, id)
+	if err == dblayer.ErrNotFound || (err == nil && (r.UserUID != userUID || r.WorkerID != workerID)) {
+		c.JSON(404, gin.H{"error": "restart not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get worker restart failed", "restart_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get restart"})
+		return
+	}
+	resp := WorkerRestartResponse{Restart: r}
+	if r.Status != dblayer.WorkerRestartRestarted {
+		c.JSON(200, resp)
+		return
+	}
+	latest, err := dblayer.ListWorkerRestarts(ctx, workerID, userUID, 1)
+	if err != nil {
+		RequestLog(c).Error("list worker restarts failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get restart"})
+		return
+	}
+	// 之后又重启过时，集群里的滚动已经不属于这一次，这一次视为完成
+	if len(latest) > 0 && latest[0].ID != r.ID {
+		resp.Complete = true
+		c.JSON(200, resp)
+		return
+	}
+	status, err := fetchWorkerStatus(ctx, workerID, userUID)
+	if err != nil {
+		RequestLog(c).Warn("fetch worker status failed", "worker_id", workerID, "error", err)
+		c.JSON(200, resp)
+		return
+	}
+	resp.Rollout = status
+	resp.Complete = status.UpdatedReplicas == status.Replicas && status.AvailableReplicas == status.Replicas
+	c.JSON(200, resp)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API 前缀：/api/v1 是当前版本，旧的 /api 作为 v1 的废弃别名保留
+const (
+	LegacyAPIPrefix = "/api"
+	APIPrefixV1     = "/api/v1"
+)
+
+// APIRoutes 一个 API 版本的路由集合。新增 v2 时再加一个 APIRoutes，
+// 与 v1 各自挂在自己的前缀下互不影响。
+type APIRoutes struct {
+	Prefix   string
+	Register func(api *gin.RouterGroup)
+}
+
+// RegisterAPI 把每个版本挂到各自前缀下；legacy 非空时，其路由再挂一份到 /api 下作为废弃别名
+func RegisterAPI(router *gin.Engine, legacy *APIRoutes, versions ...APIRoutes) {
+	for _, v := range versions {
+		v.Register(router.Group(v.Prefix))
+	}
+	if legacy != nil {
+		legacy.Register(router.Group(LegacyAPIPrefix, DeprecatedAlias(legacy.Prefix)))
+	}
+}
+
+// DeprecatedAlias 给旧路径的响应加上 Deprecation 头，并用 Link 指向新版本路径
+func DeprecatedAlias(successorPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		successor := successorPrefix + strings.TrimPrefix(c.Request.URL.Path, LegacyAPIPrefix)
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
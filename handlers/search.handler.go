@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchQueryLen 关键词最大长度
+const maxSearchQueryLen = 100
+
+// Search 在当前用户的所有资源中搜索：?q=关键词&type=worker,domain&limit=20
+func Search(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	q := strings.TrimSpace(c.Query("q"))
+	if n := utf8.RuneCountInString(q); n < dblayer.MinSearchQueryLen || n > maxSearchQueryLen {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("q must be between %d and %d characters", dblayer.MinSearchQueryLen, maxSearchQueryLen)})
+		return
+	}
+
+	var types []string
+	if v := c.Query("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(dblayer.SearchTypes, t) {
+				c.JSON(400, gin.H{"error": "type must be one of: " + strings.Join(dblayer.SearchTypes, ", ")})
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	limit := dblayer.DefaultSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > dblayer.MaxSearchLimit {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", dblayer.MaxSearchLimit)})
+			return
+		}
+		limit = n
+	}
+
+	results, facets, err := dblayer.Search(c.Request.Context(), userUID, q, types, limit)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "search failed: " + err.Error()})
+		return
+	}
+	c.JSON(200, SearchResponse{Query: q, Results: results, Facets: facets})
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/secrets"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxSecretAccess GET /api/secrets/:name/audit 的 ?limit= 上限和默认值
+const MaxSecretAccess = 500
+
+// 用户应用 secret：值用平台密钥加密后按版本入库，API 只返回元数据。
+// worker env 用 secretref://name 引用，由 inner 同步时解密当前版本写进 worker 的 Secret。
+
+// ListSecrets 用户的所有 secret（元数据）
+func ListSecrets(c *gin.Context) {
+	list, err := dblayer.ListUserSecrets(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		RequestLog(c).Error("list secrets failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list secrets"})
+		return
+	}
+	c.JSON(200, SecretsResponse{Secrets: list})
+}
+
+// CreateSecret 创建 secret，值作为第 1 个版本加密保存
+func CreateSecret(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	var req CreateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	keyID, sealed, err := secrets.Seal([]byte(req.Value), secrets.AAD(userUID, req.Name, 1))
+	if err != nil {
+		sealError(c, err)
+		return
+	}
+	if err := dblayer.CreateUserSecret(c.Request.Context(), userUID, req.Name, keyID, sealed); err != nil {
+		if err == dblayer.ErrSecretExists {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		RequestLog(c).Error("create secret failed", "secret", req.Name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to create secret"})
+		return
+	}
+	recordSecretAccess(c, req.Name, 1, dblayer.SecretActionCreate)
+	writeSecret(c, 201, userUID, req.Name)
+}
+
+// GetSecret secret 的元数据、所有版本和引用它的 worker，不返回值
+func GetSecret(c *gin.Context) {
+	writeSecret(c, 200, c.GetString("user_id"), c.Param("name"))
+}
+
+// writeSecret 返回 secret 的元数据、所有版本和引用它的 worker
+func writeSecret(c *gin.Context, code int, userUID, name string) {
+	s, versions, err := dblayer.GetUserSecret(c.Request.Context(), userUID, name)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "secret not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get secret failed", "secret", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get secret"})
+		return
+	}
+	workers, err := dblayer.ListSecretReferrers(c.Request.Context(), userUID, secrets.RefPrefix+name)
+	if err != nil {
+		RequestLog(c).Error("list secret referrers failed", "secret", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to get secret"})
+		return
+	}
+	c.JSON(code, SecretResponse{UserSecret: *s, Versions: versions, Workers: workers})
+}
+
+// RotateSecret 保存新版本并设为当前版本，引用它的 worker 重新同步 env 后生效
+func RotateSecret(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	name := c.Param("name")
+
+	var req RotateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !secrets.Configured() {
+		sealError(c, secrets.ErrNotConfigured)
+		return
+	}
+	version, err := dblayer.RotateUserSecret(c.Request.Context(), userUID, name, func(version int) (string, []byte, error) {
+		return secrets.Seal([]byte(req.Value), secrets.AAD(userUID, name, version))
+	})
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "secret not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("rotate secret failed", "secret", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to rotate secret"})
+		return
+	}
+	recordSecretAccess(c, name, version, dblayer.SecretActionRotate)
+	resyncSecretReferrers(c, userUID, name)
+	writeSecret(c, 200, userUID, name)
+}
+
+// DeleteSecret 删除 secret 及所有版本；仍被 worker env 引用时 409
+func DeleteSecret(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	name := c.Param("name")
+
+	workers, err := dblayer.ListSecretReferrers(c.Request.Context(), userUID, secrets.RefPrefix+name)
+	if err != nil {
+		RequestLog(c).Error("list secret referrers failed", "secret", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete secret"})
+		return
+	}
+	if len(workers) > 0 {
+		c.JSON(409, gin.H{"error": fmt.Sprintf("secret is referenced by workers %v, remove the references first", workers)})
+		return
+	}
+	if err := dblayer.DeleteUserSecret(c.Request.Context(), userUID, name); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "secret not found"})
+			return
+		}
+		RequestLog(c).Error("delete secret failed", "secret", name, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete secret"})
+		return
+	}
+	recordSecretAccess(c, name, 0, dblayer.SecretActionDelete)
+	c.JSON(200, MessageResponse{Message: "secret deleted"})
+}
+
+// ListSecretAccess secret 的访问记录（创建、轮换、删除和 worker 解密），新的在前；secret 删除后仍可查询
+func ListSecretAccess(c *gin.Context) {
+	limit := MaxSecretAccess
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxSecretAccess {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", MaxSecretAccess)})
+			return
+		}
+		limit = n
+	}
+	access, err := dblayer.ListSecretAccess(c.Request.Context(), c.GetString("user_id"), c.Param("name"), limit)
+	if err != nil {
+		RequestLog(c).Error("list secret access failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list secret access"})
+		return
+	}
+	c.JSON(200, SecretAccessResponse{Access: access})
+}
+
+// sealError 加密失败：未配置平台密钥时 503
+func sealError(c *gin.Context, err error) {
+	if err == secrets.ErrNotConfigured {
+		c.JSON(503, gin.H{"error": "secret storage is not configured"})
+		return
+	}
+	RequestLog(c).Error("seal secret failed", "error", err)
+	c.JSON(500, gin.H{"error": "failed to seal secret"})
+}
+
+// recordSecretAccess 记录用户对 secret 的操作，失败只记日志
+func recordSecretAccess(c *gin.Context, name string, version int, action string) {
+	err := dblayer.RecordSecretAccess(c.Request.Context(), c.GetString("user_id"), dblayer.SecretAccess{
+		Name: name, Version: version, Action: action, Actor: "user",
+	})
+	if err != nil {
+		RequestLog(c).Error("record secret access failed", "secret", name, "action", action, "error", err)
+	}
+}
+
+// resyncSecretReferrers 让引用该 secret 的 worker 重新同步 env，拿到新版本
+func resyncSecretReferrers(c *gin.Context, userUID, name string) {
+	ctx := c.Request.Context()
+	workers, err := dblayer.ListSecretReferrers(ctx, userUID, secrets.RefPrefix+name)
+	if err != nil {
+		RequestLog(c).Error("list secret referrers failed", "secret", name, "error", err)
+		return
+	}
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		return resyncWorkerEnv(ctx, tx, userUID, workers)
+	})
+	if err != nil {
+		RequestLog(c).Warn("enqueue env sync failed", "secret", name, "error", err)
+		return
+	}
+	KickOutbox()
+}
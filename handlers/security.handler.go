@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"time"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuthEvents 列出账号的登录安全事件（失败、二次验证、新设备等），
+// 支持 ?event= 过滤和分页，sort=name 按事件类型排序
+func ListAuthEvents(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Status != "" {
+		c.JSON(400, gin.H{"error": "status filter is not supported here, use event"})
+		return
+	}
+
+	events, next, err := dblayer.ListAuthEvents(c.Request.Context(), userUID, c.Query("event"), opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list security events"})
+		return
+	}
+	if events == nil {
+		events = []*dblayer.AuthEvent{}
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListAuthEventsResponse{Events: events, NextCursor: next})
+}
+
+// ListDevices 列出登录过的设备
+func ListDevices(c *gin.Context) {
+	devices, err := dblayer.ListUserDevices(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list devices"})
+		return
+	}
+	c.JSON(200, devices)
+}
+
+// ForgetDevice 忘记设备，之后从它登录按新设备评估风险并通知
+func ForgetDevice(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	if err := dblayer.DeleteUserDevice(c.Request.Context(), userUID, c.Param("id")); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "device not found"})
+		} else {
+			c.JSON(500, gin.H{"error": "failed to forget device"})
+		}
+		return
+	}
+
+	lc := newLoginContext(c, SessionBearer)
+	email, _ := dblayer.GetUserEmail(c.Request.Context(), userUID)
+	ev := lc.event(&userUID, email, dblayer.AuthEventDeviceForgotten)
+	ev.Subject = c.Param("id")
+	recordAuthEvent(c, ev)
+	c.JSON(200, MessageResponse{Message: "device forgotten"})
+}
+
+// RevealSecretKey 重新输入密码后返回账号的 secret key（HMAC 签名用），记入安全事件；
+// 密码错误计入登录失败次数
+func RevealSecretKey(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	var req RevealSecretKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	email, err := dblayer.GetUserEmail(c.Request.Context(), userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "user not found"})
+		return
+	}
+
+	lc := newLoginContext(c, SessionBearer)
+	byEmail, byIP, err := dblayer.CountAuthFailures(c.Request.Context(), email, lc.IP, time.Now().Add(-authFailureWindow))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to check sign-in attempts"})
+		return
+	}
+	if throttleLogin(c, lc, email, byEmail, byIP) {
+		return
+	}
+	user, err := dblayer.GetUserByEmail(c.Request.Context(), email)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get user"})
+		return
+	}
+	if !CheckPassword(req.Password, user.PasswordHash) {
+		ev := lc.event(&userUID, email, dblayer.AuthEventLoginFailed)
+		ev.Subject = secretKeySubject
+		recordAuthEvent(c, ev)
+		c.JSON(401, gin.H{"error": "invalid credentials"})
+		return
+	}
+
+	recordSecretReveal(c, userUID, secretKeySubject)
+	c.JSON(200, RevealSecretKeyResponse{SecretKey: user.SecretKey})
+}
+
+// secretKeySubject reveal 账号 secret key 的安全事件 subject
+const secretKeySubject = "secret_key"
+
+// recordSecretReveal 记录一次 reveal，失败只打日志
+func recordSecretReveal(c *gin.Context, userUID, subject string) {
+	email, _ := dblayer.GetUserEmail(c.Request.Context(), userUID)
+	ev := newLoginContext(c, SessionBearer).event(&userUID, email, dblayer.AuthEventSecretRevealed)
+	ev.Subject = subject
+	recordAuthEvent(c, ev)
+}
@@ -0,0 +1,684 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/billing"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/k8s/controller"
+	"jabberwocky238/console/logging"
+	"jabberwocky238/console/secrets"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// HTTP handler 与 gRPC server 共用的业务逻辑。
+// 返回 dblayer.ErrNotFound 表示资源不存在或不属于该用户，其余错误的 Error() 可直接返回给调用方。
+
+var serviceLog = logging.Component("service")
+
+var (
+	errCreateDeployVersion = errors.New("failed to create deploy version")
+	errEnqueueDeploy       = errors.New("failed to enqueue deploy task")
+	errGetUserSecret       = errors.New("failed to get user secret")
+	errListResources       = errors.New("failed to list resources")
+	errManagedEnvKey       = errors.New("COMBINATOR_API_ENDPOINT is managed by the system")
+	errUnknownSecret       = errors.New("env value references an unknown secret")
+	errReservedEnvKey      = errors.New("env name is reserved by the system")
+	errSecretEnvKey        = errors.New("env name is a worker secret, delete the secret first")
+	errSetEnv              = errors.New("failed to set env")
+	errSetSecrets          = errors.New("failed to set secrets")
+	errSetTags             = errors.New("failed to set tags")
+	errSetEgress           = errors.New("failed to set egress rules")
+	errSetRoutes           = errors.New("failed to set route rules")
+	errSetEdgeLimits       = errors.New("failed to set edge limits")
+	errSetErrorPages       = errors.New("failed to set error pages")
+	errSetEnvGroups        = errors.New("failed to set env groups")
+	errSetEnvironment      = errors.New("failed to set environment")
+	errDeleteWorker        = errors.New("failed to delete worker")
+	errEnqueueCreate       = errors.New("failed to enqueue create task")
+	errEnqueueDelete       = errors.New("failed to enqueue delete task")
+	errEnqueueSync         = errors.New("failed to enqueue sync task")
+	errAccountSuspended    = errors.New("workers are suspended for an overdue payment")
+)
+
+// DeployRejectedError 部署前校验不通过，带全部违规项
+type DeployRejectedError struct {
+	Violations []k8s.Violation
+}
+
+func (e *DeployRejectedError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "deploy rejected: " + strings.Join(msgs, "; ")
+}
+
+// validateDeploy 在创建 CR 之前做策略检查和 dry run：inner 本地执行，outer 转给 inner。
+// 校验本身无法执行时返回 error，由调用方决定是否放行
+func validateDeploy(ctx context.Context, req DeployWorkerRequest) ([]k8s.Violation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if k8s.DynamicClient != nil {
+		return controller.ValidateWorker(ctx, req.WorkerID, req.UserUID, req.Image, req.Port)
+	}
+	q := url.Values{"worker_id": {req.WorkerID}, "user_uid": {req.UserUID}, "image": {req.Image}, "port": {strconv.Itoa(req.Port)}}
+	resp, err := getInnerTimeout[ValidateWorkerResponse](ctx, "/worker/validate", q, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Violations, nil
+}
+
+// deployWorker 验证归属、创建部署版本并投递部署任务；ctx 用于部署前的校验和写库事务
+func deployWorker(ctx context.Context, req DeployWorkerRequest) (DeployWorkerResponse, error) {
+	if err := dblayer.AuthorizeResource(ctx, req.UserUID, dblayer.ResourceWorker, req.WorkerID); err == dblayer.ErrNotFound {
+		return DeployWorkerResponse{}, err
+	} else if err != nil {
+		serviceLog.Error("authorize worker failed", "worker_id", req.WorkerID, "user_id", req.UserUID, "error", err)
+		return DeployWorkerResponse{}, errCreateDeployVersion
+	}
+	if err := checkDeploy(ctx, req, req.WorkerID); err != nil {
+		return DeployWorkerResponse{}, err
+	}
+
+	// 版本记录、worker 状态和部署任务一起提交，不会出现 loading 却没有任务的 worker
+	var versionID int
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		versionID, err = createDeploy(ctx, tx, req)
+		return err
+	})
+	switch {
+	case err == dblayer.ErrNotFound, err == dblayer.ErrWorkerKind, err == errCreateDeployVersion, err == errEnqueueDeploy:
+		return DeployWorkerResponse{}, err
+	case err != nil:
+		serviceLog.Error("deploy transaction failed", "worker_id", req.WorkerID, "user_id", req.UserUID, "error", err)
+		return DeployWorkerResponse{}, errCreateDeployVersion
+	}
+	KickOutbox()
+
+	resp := DeployWorkerResponse{
+		WorkerID:  req.WorkerID,
+		VersionID: versionID,
+		Status:    "loading",
+	}
+	if w, err := dblayer.GetWorkerByOwner(ctx, req.WorkerID, req.UserUID); err == nil {
+		if est, err := estimateWorker(w.AssignedCPU, w.AssignedMemory, w.AssignedDisk, w.MaxReplicas, w.MainRegion); err == nil {
+			resp.Estimate = est
+		}
+	}
+	return resp, nil
+}
+
+// checkDeploy 部署前的检查：账户是否因欠费暂停、镜像和端口是否符合 validateWorkerID 的配置和套餐
+func checkDeploy(ctx context.Context, req DeployWorkerRequest, validateWorkerID string) error {
+	suspended, err := dblayer.WorkersSuspended(ctx, req.UserUID)
+	if err != nil {
+		serviceLog.Warn("check billing suspension failed", "user_id", req.UserUID, "error", err)
+	} else if suspended {
+		return errAccountSuspended
+	}
+
+	req.WorkerID = validateWorkerID
+	violations, err := validateDeploy(ctx, req)
+	if err != nil {
+		// 校验不可用时照常部署，问题留给部署任务报告
+		serviceLog.Warn("validate deploy failed", "worker_id", req.WorkerID, "user_id", req.UserUID, "error", err)
+	} else if len(violations) > 0 {
+		return &DeployRejectedError{Violations: violations}
+	}
+	return nil
+}
+
+// createDeploy 在 tx 里创建部署版本、把 worker 置为 loading 并提交部署任务，返回版本 ID
+func createDeploy(ctx context.Context, tx *sql.Tx, req DeployWorkerRequest) (int, error) {
+	versionID, err := dblayer.CreateDeployVersionForOwner(ctx, tx, req.WorkerID, req.UserUID, req.Image, req.Port, req.CommitSHA, cmp.Or(req.Initiator, dblayer.DeployInitiatorUser), req.Static)
+	if err == dblayer.ErrNotFound || err == dblayer.ErrWorkerKind {
+		return 0, err
+	}
+	if err != nil {
+		serviceLog.Error("create deploy version failed", "worker_id", req.WorkerID, "user_id", req.UserUID, "error", err)
+		return 0, errCreateDeployVersion
+	}
+	if err := enqueueTask(ctx, tx, jobs.NewDeployWorkerJob(req.WorkerID, req.UserUID, versionID)); err != nil {
+		serviceLog.Error("enqueue deploy task failed", "worker_id", req.WorkerID, "user_id", req.UserUID, "error", err)
+		return 0, errEnqueueDeploy
+	}
+	dblayer.PublishEventTx(ctx, tx, dblayer.Event{
+		UserUID:      req.UserUID,
+		Kind:         dblayer.EventWorkerDeploy,
+		ResourceType: "worker",
+		ResourceID:   req.WorkerID,
+		Status:       "loading",
+	})
+	return versionID, nil
+}
+
+// estimateWorker 按价格表估算 worker 每月费用，未设置的资源按部署时的默认值计
+func estimateWorker(cpu, memory, disk string, replicas int, region string) (*billing.CostEstimate, error) {
+	var qs [3]resource.Quantity
+	for i, v := range []string{cmp.Or(cpu, k8s.DefaultWorkerCPU), cmp.Or(memory, k8s.DefaultWorkerMemory), cmp.Or(disk, k8s.DefaultWorkerDisk)} {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity %q: %w", v, err)
+		}
+		qs[i] = q
+	}
+	return billing.Sheet.Estimate(billing.Resources{
+		CPUCores:    qs[0].AsApproximateFloat64(),
+		MemoryBytes: qs[1].AsApproximateFloat64(),
+		DiskBytes:   qs[2].AsApproximateFloat64(),
+		Replicas:    replicas,
+		Region:      region,
+	})
+}
+
+// getWorkerEnv 获取 worker 环境变量
+func getWorkerEnv(ctx context.Context, workerID, userUID string) (map[string]string, error) {
+	if err := dblayer.AuthorizeResource(ctx, userUID, dblayer.ResourceWorker, workerID); err != nil {
+		return nil, dblayer.ErrNotFound
+	}
+	envJSON, _, err := dblayer.GetWorkerEnvByOwner(ctx, workerID, userUID)
+	if err != nil {
+		return nil, dblayer.ErrNotFound
+	}
+
+	var envMap map[string]string
+	json.Unmarshal([]byte(envJSON), &envMap)
+	return envMap, nil
+}
+
+// retrieveSecret 获取用户的 secret key 和所有 active 的 combinator 资源
+func retrieveSecret(ctx context.Context, userUID string) (RetrieveSecretResponse, error) {
+	secretKey, err := dblayer.GetUserSecretKey(ctx, userUID)
+	if err != nil {
+		serviceLog.Error("get secret key failed", "user_id", userUID, "error", err)
+		return RetrieveSecretResponse{}, fmt.Errorf("%w: %w", errGetUserSecret, err)
+	}
+
+	resources, err := dblayer.ListActiveCombinatorResources(ctx, userUID)
+	if err != nil {
+		serviceLog.Error("list combinator resources failed", "user_id", userUID, "error", err)
+		return RetrieveSecretResponse{}, fmt.Errorf("%w: %w", errListResources, err)
+	}
+
+	var result []ResourceRef
+	for _, res := range resources {
+		result = append(result, ResourceRef{
+			ResourceType: res.ResourceType,
+			ResourceID:   res.ResourceID,
+		})
+	}
+	return RetrieveSecretResponse{Resources: result, SecretKey: secretKey}, nil
+}
+
+// casRetries 没带 expected_version 的读-改-写遇到并发修改时的重试次数
+const casRetries = 3
+
+// mergeWorkerEnv 把单条 env 修改合并进 worker 现有 env 并保存，返回合并后的完整 env 和新版本（不下发到 K8s）。
+// 写入以读到的版本为条件；kv 带 expected_version 时版本不一致直接返回 ErrVersionConflict，否则重新读取后重试
+func mergeWorkerEnv(ctx context.Context, workerID, userUID string, kv WorkerKeyValueRequest) (map[string]string, int, error) {
+	if kv.Key == "COMBINATOR_API_ENDPOINT" {
+		return nil, 0, errManagedEnvKey
+	}
+	if attached, err := attachedEnvKey(ctx, workerID, userUID, kv.Key); err != nil {
+		return nil, 0, errSetEnv
+	} else if attached && !kv.Delete {
+		return nil, 0, errAttachedEnvKey
+	}
+	// secretref://name 必须指向已存在的 secret，值在 inner 同步时才解密
+	if name, ok := secrets.ParseRef(kv.Value); ok && !kv.Delete {
+		if _, _, err := dblayer.GetUserSecret(ctx, userUID, name); err == dblayer.ErrNotFound {
+			return nil, 0, errUnknownSecret
+		} else if err != nil {
+			return nil, 0, errSetEnv
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		envJSON, version, err := dblayer.GetWorkerEnvByOwner(ctx, workerID, userUID)
+		if err != nil {
+			return nil, 0, dblayer.ErrNotFound
+		}
+		if kv.ExpectedVersion != 0 && kv.ExpectedVersion != version {
+			return nil, version, dblayer.ErrVersionConflict
+		}
+		var envMap map[string]string
+		json.Unmarshal([]byte(envJSON), &envMap)
+		if envMap == nil {
+			envMap = map[string]string{}
+		}
+
+		if kv.Delete {
+			delete(envMap, kv.Key)
+		} else {
+			envMap[kv.Key] = kv.Value
+		}
+
+		data, _ := json.Marshal(envMap)
+		version, err = dblayer.SetWorkerEnvByOwner(ctx, dblayer.DB, workerID, userUID, string(data), version)
+		switch {
+		case err == nil:
+			return envMap, version, nil
+		case err == dblayer.ErrVersionConflict && kv.ExpectedVersion == 0 && attempt < casRetries:
+			continue
+		case err == dblayer.ErrVersionConflict, err == dblayer.ErrNotFound:
+			return nil, version, err
+		default:
+			return nil, 0, errSetEnv
+		}
+	}
+}
+
+// mergeWorkerSecrets 把单条 secret key 的增删合并进 worker 的 secrets_json，返回合并后的 key 列表和新版本，
+// 版本处理同 mergeWorkerEnv
+func mergeWorkerSecrets(ctx context.Context, workerID, userUID string, kv WorkerKeyValueRequest) ([]string, int, error) {
+	if attached, err := attachedEnvKey(ctx, workerID, userUID, kv.Key); err != nil {
+		return nil, 0, errSetSecrets
+	} else if attached && !kv.Delete {
+		return nil, 0, errAttachedEnvKey
+	}
+	for attempt := 1; ; attempt++ {
+		secretsJSON, version, err := dblayer.GetWorkerSecretsByOwner(ctx, workerID, userUID)
+		if err != nil {
+			return nil, 0, dblayer.ErrNotFound
+		}
+		if kv.ExpectedVersion != 0 && kv.ExpectedVersion != version {
+			return nil, version, dblayer.ErrVersionConflict
+		}
+		var keys []string
+		json.Unmarshal([]byte(secretsJSON), &keys)
+
+		if kv.Delete {
+			keys = slices.DeleteFunc(keys, func(k string) bool { return k == kv.Key })
+		} else if !slices.Contains(keys, kv.Key) {
+			keys = append(keys, kv.Key)
+		}
+
+		data, _ := json.Marshal(keys)
+		version, err = dblayer.SetWorkerSecretsByOwner(ctx, dblayer.DB, workerID, userUID, string(data), version)
+		switch {
+		case err == nil:
+			return keys, version, nil
+		case err == dblayer.ErrVersionConflict && kv.ExpectedVersion == 0 && attempt < casRetries:
+			continue
+		case err == dblayer.ErrVersionConflict, err == dblayer.ErrNotFound:
+			return nil, version, err
+		default:
+			return nil, 0, errSetSecrets
+		}
+	}
+}
+
+// importWorkerEnv 把导入的变量一次保存：plain 合并进 env_json，secretVals 的 key 合并进 secrets_json 并从 env 里移除。
+// 写库时在同一个事务里投递 secret 和 env 同步任务，返回合并后的完整 env、secret key 列表和新版本，版本处理同 mergeWorkerEnv；
+// 按名字报错时错误带上变量名，用 errors.Is 判断
+func importWorkerEnv(ctx context.Context, workerID, userUID string, plain, secretVals map[string]string, expectedVersion int) (map[string]string, []string, int, error) {
+	attachments, err := dblayer.ListWorkerAttachments(ctx, workerID, userUID)
+	if err != nil {
+		return nil, nil, 0, errSetEnv
+	}
+	for _, kvs := range []map[string]string{plain, secretVals} {
+		for k := range kvs {
+			if slices.Contains(controller.ReservedEnvKeys, k) {
+				return nil, nil, 0, fmt.Errorf("%w: %s", errReservedEnvKey, k)
+			}
+			if slices.ContainsFunc(attachments, func(a *dblayer.WorkerAttachment) bool { return a.EnvName == k }) {
+				return nil, nil, 0, fmt.Errorf("%w: %s", errAttachedEnvKey, k)
+			}
+		}
+	}
+	for k, v := range plain {
+		if name, ok := secrets.ParseRef(v); ok {
+			if _, _, err := dblayer.GetUserSecret(ctx, userUID, name); err == dblayer.ErrNotFound {
+				return nil, nil, 0, fmt.Errorf("%w: %s", errUnknownSecret, k)
+			} else if err != nil {
+				return nil, nil, 0, errSetEnv
+			}
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		envJSON, version, err := dblayer.GetWorkerEnvByOwner(ctx, workerID, userUID)
+		if err != nil {
+			return nil, nil, 0, dblayer.ErrNotFound
+		}
+		secretsJSON, secretsVersion, err := dblayer.GetWorkerSecretsByOwner(ctx, workerID, userUID)
+		if err != nil {
+			return nil, nil, 0, dblayer.ErrNotFound
+		}
+		// 两次读取之间被修改过，重新读
+		if secretsVersion != version {
+			if expectedVersion == 0 && attempt < casRetries {
+				continue
+			}
+			return nil, nil, secretsVersion, dblayer.ErrVersionConflict
+		}
+		if expectedVersion != 0 && expectedVersion != version {
+			return nil, nil, version, dblayer.ErrVersionConflict
+		}
+		var envMap map[string]string
+		json.Unmarshal([]byte(envJSON), &envMap)
+		if envMap == nil {
+			envMap = map[string]string{}
+		}
+		var keys []string
+		json.Unmarshal([]byte(secretsJSON), &keys)
+
+		for k, v := range plain {
+			if slices.Contains(keys, k) {
+				return nil, nil, 0, fmt.Errorf("%w: %s", errSecretEnvKey, k)
+			}
+			envMap[k] = v
+		}
+		for k := range secretVals {
+			delete(envMap, k)
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+
+		envData, _ := json.Marshal(envMap)
+		secretsData, _ := json.Marshal(keys)
+		err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+			version, err = dblayer.SetWorkerEnvAndSecretsByOwner(ctx, tx, workerID, userUID, string(envData), string(secretsData), version)
+			if err != nil {
+				return err
+			}
+			// secret 先下发，env 同步会把移到 secret 的 key 从 ConfigMap 去掉
+			if len(secretVals) > 0 {
+				if err := enqueueTask(ctx, tx, jobs.NewSyncSecretJob(workerID, userUID, secretVals)); err != nil {
+					return errEnqueueSync
+				}
+			}
+			if err := enqueueTask(ctx, tx, jobs.NewSyncEnvJob(workerID, userUID, envMap)); err != nil {
+				return errEnqueueSync
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+			KickOutbox()
+			return envMap, keys, version, nil
+		case err == errEnqueueSync:
+			return nil, nil, 0, err
+		case err == dblayer.ErrVersionConflict && expectedVersion == 0 && attempt < casRetries:
+			continue
+		case err == dblayer.ErrVersionConflict, err == dblayer.ErrNotFound:
+			return nil, nil, version, err
+		default:
+			return nil, nil, 0, errSetEnv
+		}
+	}
+}
+
+// deleteWorker 验证归属并删库，同一个事务里投递 CR 删除任务，由 inner 异步删除（可能不存在）。
+// 预览记录随 worker 级联删除，先删除它的预览 worker 和 RDB
+func deleteWorker(ctx context.Context, workerID, userUID string) error {
+	if err := teardownPreviews(ctx, workerID, userUID); err != nil {
+		serviceLog.Error("teardown previews failed", "worker_id", workerID, "user_id", userUID, "error", err)
+		return errDeleteWorker
+	}
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.DeleteWorkerByOwner(ctx, tx, workerID, userUID); err != nil {
+			return err
+		}
+		if err := enqueueTask(ctx, tx, jobs.NewDeleteWorkerCRJob(workerID, userUID)); err != nil {
+			serviceLog.Error("enqueue delete worker CR task failed", "worker_id", workerID, "user_id", userUID, "error", err)
+			return errDeleteWorker
+		}
+		return nil
+	})
+	if err != nil {
+		if err == dblayer.ErrNotFound {
+			return err
+		}
+		return errDeleteWorker
+	}
+	KickOutbox()
+	return nil
+}
+
+// setWorkerTags 替换 worker 标签并投递 label 同步任务，返回新版本（冲突时为当前版本）
+func setWorkerTags(ctx context.Context, workerID, userUID string, tags dblayer.Tags, expectedVersion int) (int, error) {
+	version, err := dblayer.SetWorkerTags(ctx, workerID, userUID, tags, expectedVersion)
+	if err != nil {
+		if err == dblayer.ErrNotFound || err == dblayer.ErrVersionConflict {
+			return version, err
+		}
+		return 0, errSetTags
+	}
+	if err := SendTask(jobs.NewSyncWorkerTagsJob(workerID, userUID, tags)); err != nil {
+		return version, errEnqueueSync
+	}
+	return version, nil
+}
+
+// setWorkerEgress 替换 worker 出站规则（已经过 k8s.NormalizeEgress）并投递同步任务，返回新版本（冲突时为当前版本）
+func setWorkerEgress(ctx context.Context, workerID, userUID string, p dblayer.EgressPolicy, expectedVersion int) (int, error) {
+	version, err := dblayer.SetWorkerEgress(ctx, workerID, userUID, p, expectedVersion)
+	if err != nil {
+		if err == dblayer.ErrNotFound || err == dblayer.ErrVersionConflict {
+			return version, err
+		}
+		return 0, errSetEgress
+	}
+	if err := SendTask(jobs.NewSyncWorkerEgressJob(workerID, userUID)); err != nil {
+		return version, errEnqueueSync
+	}
+	return version, nil
+}
+
+// updateWorkerAndSync 在一个事务里用 update 写库并投递同步任务 job，提交后唤醒 outbox；
+// 返回 update 的新版本（冲突时为当前版本），update 的错误原样返回
+func updateWorkerAndSync(ctx context.Context, job k8s.Job, update func(tx *sql.Tx) (int, error)) (int, error) {
+	var version int
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if version, err = update(tx); err != nil {
+			return err
+		}
+		if err := enqueueTask(ctx, tx, job); err != nil {
+			return errEnqueueSync
+		}
+		return nil
+	})
+	if err == dblayer.ErrVersionConflict {
+		return version, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	KickOutbox()
+	return version, nil
+}
+
+// setWorkerRoutes 替换 worker 的额外路由规则（已经过 k8s.NormalizeRouteRules）并投递同步任务，返回新版本（冲突时为当前版本）
+func setWorkerRoutes(ctx context.Context, workerID, userUID string, rules []dblayer.RouteRule, expectedVersion int) (int, error) {
+	return updateWorkerAndSync(ctx, jobs.NewSyncWorkerRoutesJob(workerID, userUID), func(tx *sql.Tx) (int, error) {
+		version, err := dblayer.SetWorkerRoutes(ctx, tx, workerID, userUID, rules, expectedVersion)
+		if err != nil && err != dblayer.ErrNotFound && err != dblayer.ErrVersionConflict {
+			return 0, errSetRoutes
+		}
+		return version, err
+	})
+}
+
+// setWorkerEdgeLimits 替换 worker 入口的限制（已经过 k8s.NormalizeEdgeLimits）并投递同步任务，返回新版本（冲突时为当前版本）
+func setWorkerEdgeLimits(ctx context.Context, workerID, userUID string, l dblayer.EdgeLimits, expectedVersion int) (int, error) {
+	return updateWorkerAndSync(ctx, jobs.NewSyncWorkerEdgeLimitsJob(workerID, userUID), func(tx *sql.Tx) (int, error) {
+		version, err := dblayer.SetWorkerEdgeLimits(ctx, tx, workerID, userUID, l, expectedVersion)
+		if err != nil && err != dblayer.ErrNotFound && err != dblayer.ErrVersionConflict {
+			return 0, errSetEdgeLimits
+		}
+		return version, err
+	})
+}
+
+// setWorkerEnvGroups 按顺序替换 worker 挂的 env 组并投递 env 同步任务，返回新版本（冲突时为当前版本）
+func setWorkerEnvGroups(ctx context.Context, workerID, userUID string, names []string, expectedVersion int) (int, error) {
+	// 同步任务带的是 worker 自己的 env，env 组在同步时合并，换组不影响它
+	env, err := getWorkerEnv(ctx, workerID, userUID)
+	if err != nil {
+		return 0, err
+	}
+	return updateWorkerAndSync(ctx, jobs.NewSyncEnvJob(workerID, userUID, env), func(tx *sql.Tx) (int, error) {
+		version, err := dblayer.SetWorkerEnvGroups(ctx, tx, workerID, userUID, names, expectedVersion)
+		var unknown *dblayer.UnknownEnvGroupError
+		if err != nil && !errors.As(err, &unknown) && err != dblayer.ErrNotFound && err != dblayer.ErrVersionConflict {
+			return 0, errSetEnvGroups
+		}
+		return version, err
+	})
+}
+
+// setWorkerEnvironment 把 worker 移到另一个环境并重新同步 env（环境变量跟着换），返回新版本（冲突时为当前版本）
+func setWorkerEnvironment(ctx context.Context, workerID, userUID, environment string, expectedVersion int) (int, error) {
+	// 同步任务带的是 worker 自己的 env，环境变量在同步时合并，换环境不影响它
+	env, err := getWorkerEnv(ctx, workerID, userUID)
+	if err != nil {
+		return 0, err
+	}
+	return updateWorkerAndSync(ctx, jobs.NewSyncEnvJob(workerID, userUID, env), func(tx *sql.Tx) (int, error) {
+		version, err := dblayer.SetWorkerEnvironment(ctx, tx, workerID, userUID, environment, expectedVersion)
+		if err != nil && err != dblayer.ErrNotFound && err != dblayer.ErrVersionConflict {
+			return 0, errSetEnvironment
+		}
+		return version, err
+	})
+}
+
+// setWorkerErrorPages 替换 worker 的维护开关和错误页并投递同步任务，返回新版本（冲突时为当前版本）
+func setWorkerErrorPages(ctx context.Context, workerID, userUID string, p dblayer.ErrorPages, expectedVersion int) (int, error) {
+	return updateWorkerAndSync(ctx, jobs.NewSyncWorkerErrorPagesJob(workerID, userUID), func(tx *sql.Tx) (int, error) {
+		version, err := dblayer.SetWorkerErrorPages(ctx, tx, workerID, userUID, p, expectedVersion)
+		if err != nil && err != dblayer.ErrNotFound && err != dblayer.ErrVersionConflict {
+			return 0, errSetErrorPages
+		}
+		return version, err
+	})
+}
+
+// setCustomDomainTags 替换自定义域名标签并投递 label 同步任务，返回新版本（冲突时为当前版本）
+func setCustomDomainTags(ctx context.Context, cdid, userUID string, tags dblayer.Tags, expectedVersion int) (int, error) {
+	version, err := dblayer.SetCustomDomainTags(ctx, cdid, userUID, tags, expectedVersion)
+	if err != nil {
+		if err == dblayer.ErrNotFound || err == dblayer.ErrVersionConflict {
+			return version, err
+		}
+		return 0, errSetTags
+	}
+	if err := SendTask(jobs.NewSyncDomainTagsJob(cdid, userUID)); err != nil {
+		return version, errEnqueueSync
+	}
+	return version, nil
+}
+
+// createCombinatorResource 写库并投递 RDB/KV/bucket/队列创建任务，返回资源 ID；name 为空时用资源 ID，environment 为空时 production
+func createCombinatorResource(ctx context.Context, userUID, resourceType, name string, tags dblayer.Tags, environment string) (string, error) {
+	resourceID := GenerateResourceUID()
+	if name == "" {
+		name = resourceID
+	}
+
+	var job k8s.Job = jobs.NewCreateKVJob(userUID, resourceID)
+	switch resourceType {
+	case "rdb":
+		job = jobs.NewCreateRDBJob(userUID, name, resourceID)
+	case "bucket":
+		job = jobs.NewCreateBucketJob(userUID, resourceID)
+	case "queue":
+		job = jobs.NewCreateQueueJob(userUID, resourceID)
+	}
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.CreateCombinatorResource(ctx, tx, userUID, resourceType, resourceID, name, tags, environment); err != nil {
+			return fmt.Errorf("failed to create resource: %w", err)
+		}
+		if resourceType == "bucket" {
+			if err := dblayer.CreateObjectBucket(ctx, tx, userUID, resourceID); err != nil {
+				return fmt.Errorf("failed to create resource: %w", err)
+			}
+		}
+		if err := enqueueTask(ctx, tx, job); err != nil {
+			serviceLog.Error("enqueue create task failed", "resource_id", resourceID, "user_id", userUID, "error", err)
+			return errEnqueueCreate
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	KickOutbox()
+	return resourceID, nil
+}
+
+// deleteCombinatorResource 验证归属、删库并投递 RDB/KV/bucket/队列删除任务；挂载了它的 worker 同步 Secret 去掉连接串
+func deleteCombinatorResource(ctx context.Context, userUID, resourceType, resourceID string) error {
+	if err := dblayer.AuthorizeResource(ctx, userUID, resourceType, resourceID); err == dblayer.ErrNotFound {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to check resource ownership: %w", err)
+	}
+	cr, err := dblayer.GetCombinatorResource(ctx, userUID, resourceType, resourceID)
+	if err != nil {
+		return dblayer.ErrNotFound
+	}
+	// 挂载随资源一起删除（ON DELETE CASCADE），先记下受影响的 worker
+	attached, err := dblayer.ListAttachedWorkers(ctx, userUID, resourceType, resourceID)
+	if err != nil {
+		return fmt.Errorf("failed to list attached workers: %w", err)
+	}
+
+	var job k8s.Job = jobs.NewDeleteKVJob(userUID, cr.ResourceID)
+	switch resourceType {
+	case "rdb":
+		job = jobs.NewDeleteRDBJob(userUID, cr.ResourceID)
+	case "bucket":
+		// object_buckets 随资源行删除，先取出存储侧的 bucket 名
+		b, err := dblayer.GetObjectBucket(ctx, userUID, resourceID)
+		if err != nil && err != dblayer.ErrNotFound {
+			return fmt.Errorf("failed to get bucket: %w", err)
+		}
+		var bucketName string
+		if b != nil {
+			bucketName = b.BucketName
+		}
+		job = jobs.NewDeleteBucketJob(userUID, cr.ResourceID, bucketName)
+	case "queue":
+		job = jobs.NewDeleteQueueJob(userUID, cr.ResourceID)
+	}
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.DeleteCombinatorResource(ctx, tx, userUID, resourceType, resourceID); err != nil {
+			return fmt.Errorf("failed to delete resource: %w", err)
+		}
+		if err := enqueueTask(ctx, tx, job); err != nil {
+			serviceLog.Error("enqueue delete task failed", "resource_id", resourceID, "user_id", userUID, "error", err)
+			return errEnqueueDelete
+		}
+		for _, workerID := range attached {
+			if err := enqueueTask(ctx, tx, jobs.NewSyncAttachmentsJob(workerID, userUID)); err != nil {
+				return errEnqueueDelete
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	KickOutbox()
+	return nil
+}
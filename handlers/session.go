@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 浏览器控制面板可以用 cookie 会话代替 Bearer token：登录/注册时 session=cookie，
+// JWT 放在 HttpOnly 的 SessionCookieName 里，不再出现在响应体中，前端脚本读不到。
+// cookie 会浏览器自动携带，所以 cookie 会话的写请求（非 GET/HEAD/OPTIONS）必须带
+// CSRFHeader，值为响应里的 csrf_token（同样写在可读的 CSRFCookieName 里，刷新页面后可取回）。
+// CSRF token 由会话 token 派生（HMAC），不能只靠写入一个 cookie 伪造。
+// 带 Authorization 头的请求仍走 Bearer 模式，不检查 CSRF。
+
+// 会话模式，LoginRequest/RegisterRequest 的 session 字段
+const (
+	SessionBearer = "bearer"
+	SessionCookie = "cookie"
+)
+
+// cookie 和请求头名
+const (
+	SessionCookieName = "console_session"
+	CSRFCookieName    = "console_csrf"
+	CSRFHeader        = "X-CSRF-Token"
+)
+
+// sessionTTL 与 JWT 有效期一致
+const sessionTTL = 24 * time.Hour
+
+const sessionCookieAuthKey = "session_cookie"
+
+// SessionCookieConfig cookie 会话的 cookie 属性
+type SessionCookieConfig struct {
+	Domain   string // 空表示只发给当前 host
+	SameSite http.SameSite
+	Secure   bool
+}
+
+var sessionCookieConfig = SessionCookieConfig{SameSite: http.SameSiteLaxMode, Secure: true}
+
+// SessionCookieConfigFromEnv 在 flag 值基础上读取环境变量：
+// SESSION_COOKIE_DOMAIN、SESSION_COOKIE_SAMESITE（lax/strict/none）、SESSION_COOKIE_SECURE，环境变量优先
+func SessionCookieConfigFromEnv(domain, sameSite string, secure bool) (SessionCookieConfig, error) {
+	if v := os.Getenv("SESSION_COOKIE_DOMAIN"); v != "" {
+		domain = v
+	}
+	if v := os.Getenv("SESSION_COOKIE_SAMESITE"); v != "" {
+		sameSite = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("SESSION_COOKIE_SECURE")); err == nil {
+		secure = v
+	}
+
+	cfg := SessionCookieConfig{Domain: domain, Secure: secure}
+	switch strings.ToLower(sameSite) {
+	case "", "lax":
+		cfg.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cfg.SameSite = http.SameSiteStrictMode
+	case "none":
+		// 跨站发送 cookie 时浏览器要求 Secure
+		if !secure {
+			return cfg, fmt.Errorf("SameSite=None requires a secure cookie")
+		}
+		cfg.SameSite = http.SameSiteNoneMode
+	default:
+		return cfg, fmt.Errorf("unknown SameSite mode %q", sameSite)
+	}
+	return cfg, nil
+}
+
+// SetSessionCookieConfig 设置 cookie 会话的 cookie 属性
+func SetSessionCookieConfig(cfg SessionCookieConfig) {
+	sessionCookieConfig = cfg
+}
+
+// csrfToken 会话 token 对应的 CSRF token
+func csrfToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, JWTSecret)
+	mac.Write([]byte("csrf\n" + sessionToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func setSessionCookie(c *gin.Context, name, value string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   sessionCookieConfig.Domain,
+		MaxAge:   maxAge,
+		Secure:   sessionCookieConfig.Secure,
+		HttpOnly: httpOnly,
+		SameSite: sessionCookieConfig.SameSite,
+	})
+}
+
+// issueSession 按会话模式下发 token：bearer 原样返回 token；
+// cookie 写入会话和 CSRF cookie，返回空 token 和 CSRF token
+func issueSession(c *gin.Context, mode, token string) (respToken, csrf string) {
+	if mode != SessionCookie {
+		return token, ""
+	}
+	csrf = csrfToken(token)
+	maxAge := int(sessionTTL / time.Second)
+	setSessionCookie(c, SessionCookieName, token, maxAge, true)
+	setSessionCookie(c, CSRFCookieName, csrf, maxAge, false)
+	return "", csrf
+}
+
+// Logout 清除 cookie 会话；Bearer token 由客户端自行丢弃
+func Logout(c *gin.Context) {
+	setSessionCookie(c, SessionCookieName, "", -1, true)
+	setSessionCookie(c, CSRFCookieName, "", -1, false)
+	c.JSON(200, MessageResponse{Message: "logged out"})
+}
+
+// CSRFMiddleware 校验 cookie 会话写请求的 CSRFHeader，放在 AuthMiddleware 之后；
+// Bearer 请求和只读请求直接放行
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(sessionCookieAuthKey) {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		session, _ := c.Cookie(SessionCookieName)
+		got := c.GetHeader(CSRFHeader)
+		if got == "" || !hmac.Equal([]byte(got), []byte(csrfToken(session))) {
+			RequestLog(c).Warn("csrf check failed", "path", c.FullPath())
+			c.AbortWithStatusJSON(403, gin.H{"error": "invalid CSRF token"})
+			return
+		}
+		c.Next()
+	}
+}
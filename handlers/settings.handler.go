@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/logging"
+	"jabberwocky238/console/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 运行时配置：各包用 settings.NewXxx 注册，settings 表里的覆盖值在所有实例上热加载。
+// 改动经 LISTEN/NOTIFY 通知其他实例；每次都整表重新加载，错过的通知不会留下旧值
+
+var settingsLog = logging.Component("settings")
+
+// ReloadSettings 从 settings 表加载覆盖值；非法或未知的 key 记日志后跳过（保持默认值）
+func ReloadSettings(ctx context.Context) error {
+	_, err := reloadSettings(ctx)
+	return err
+}
+
+// reloadSettings 同 ReloadSettings，并返回按 key 索引的覆盖行
+func reloadSettings(ctx context.Context) (map[string]dblayer.SettingRow, error) {
+	rows, err := dblayer.ListSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]dblayer.SettingRow, len(rows))
+	overrides := make(map[string]string, len(rows))
+	for _, r := range rows {
+		byKey[r.Key] = r
+		overrides[r.Key] = r.Value
+	}
+	for _, err := range settings.Load(overrides) {
+		settingsLog.Warn("setting ignored", "error", err)
+	}
+	return byKey, nil
+}
+
+// WatchSettings 启动时加载一次覆盖值、功能开关和租户任务上限，之后随变更通知重新加载，直到 ctx 取消
+func WatchSettings(ctx context.Context, dsn string) {
+	if err := ReloadSettings(ctx); err != nil {
+		settingsLog.Warn("load settings failed, using defaults", "error", err)
+	}
+	if _, err := reloadFeatureFlags(ctx); err != nil {
+		settingsLog.Warn("load feature flags failed, all flags off", "error", err)
+	}
+	if _, err := reloadJobLimits(ctx); err != nil {
+		settingsLog.Warn("load job limits failed, jobs are not capped", "error", err)
+	}
+	err := dblayer.ListenSettings(ctx, dsn, func() {
+		if _, err := reloadFeatureFlags(ctx); err != nil {
+			settingsLog.Warn("reload feature flags failed", "error", err)
+		}
+		if _, err := reloadJobLimits(ctx); err != nil {
+			settingsLog.Warn("reload job limits failed", "error", err)
+		}
+		if err := ReloadSettings(ctx); err != nil {
+			settingsLog.Warn("reload settings failed", "error", err)
+			return
+		}
+		settingsLog.Info("settings reloaded")
+	})
+	if err != nil {
+		settingsLog.Warn("settings listener init failed, changes need a restart", "error", err)
+	}
+}
+
+// ListSettings GET /admin/settings 所有注册的运行时配置和当前生效的值（顺带重新加载本实例）
+func ListSettings(c *gin.Context) {
+	byKey, err := reloadSettings(c.Request.Context())
+	if err != nil {
+		RequestLog(c).Error("list settings failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list settings"})
+		return
+	}
+	resp := SettingsResponse{Settings: []SettingResponse{}}
+	for _, info := range settings.List() {
+		resp.Settings = append(resp.Settings, settingResponse(info, byKey))
+	}
+	c.JSON(200, resp)
+}
+
+// SetSetting PUT /admin/settings/:key 写入覆盖值，本实例立即生效，其他实例收到通知后生效
+func SetSetting(c *gin.Context) {
+	key := c.Param("key")
+	var req SetSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if err := settings.Validate(key, *req.Value); errors.Is(err, settings.ErrUnknown) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(400, gin.H{"error": key + ": " + err.Error()})
+		return
+	}
+	if err := dblayer.SetSetting(c.Request.Context(), key, *req.Value, internalCaller(c)); err != nil {
+		RequestLog(c).Error("set setting failed", "key", key, "error", err)
+		c.JSON(500, gin.H{"error": "failed to set setting"})
+		return
+	}
+	RequestLog(c).Info("setting changed", "key", key, "value", *req.Value)
+	respondSetting(c, key)
+}
+
+// DeleteSetting DELETE /admin/settings/:key 删除覆盖值，回到默认值
+func DeleteSetting(c *gin.Context) {
+	key := c.Param("key")
+	if _, ok := settings.Get(key); !ok {
+		c.JSON(404, gin.H{"error": "unknown setting " + key})
+		return
+	}
+	if err := dblayer.DeleteSetting(c.Request.Context(), key); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "setting " + key + " is not overridden"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("delete setting failed", "key", key, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete setting"})
+		return
+	}
+	RequestLog(c).Info("setting reset", "key", key)
+	respondSetting(c, key)
+}
+
+// respondSetting 不等通知，直接重新加载后返回该项的当前值
+func respondSetting(c *gin.Context, key string) {
+	byKey, err := reloadSettings(c.Request.Context())
+	if err != nil {
+		RequestLog(c).Error("reload settings failed", "error", err)
+		c.JSON(500, gin.H{"error": "saved, but failed to reload settings"})
+		return
+	}
+	info, _ := settings.Get(key)
+	c.JSON(200, settingResponse(info, byKey))
+}
+
+func settingResponse(info settings.Info, byKey map[string]dblayer.SettingRow) SettingResponse {
+	resp := SettingResponse{Info: info}
+	if r, ok := byKey[info.Key]; ok {
+		resp.UpdatedBy = r.UpdatedBy
+		resp.UpdatedAt = &r.UpdatedAt
+	}
+	return resp
+}
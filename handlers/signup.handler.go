@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/notify"
+	"jabberwocky238/console/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 注册方式是运行时配置 signup.mode：open 任何人都能注册；invite 必须带管理员生成的邀请码；
+// waitlist 没有邀请码的注册进入候补名单，管理员批准后把绑定该邮箱的一次性邀请码发到邮箱。
+// 邀请码在建用户的同一个事务里计一次使用
+
+const (
+	SignupOpen     = "open"
+	SignupInvite   = "invite"
+	SignupWaitlist = "waitlist"
+)
+
+// 候补名单批准时邀请码的默认有效期，以及一次最多列出的申请数
+const (
+	waitlistInvitationTTL = 7 * 24 * time.Hour
+	maxWaitlistList       = 500
+)
+
+var signupMode = settings.NewString("signup.mode", SignupOpen, func(v string) error {
+	switch v {
+	case SignupOpen, SignupInvite, SignupWaitlist:
+		return nil
+	}
+	return fmt.Errorf("must be %s, %s or %s", SignupOpen, SignupInvite, SignupWaitlist)
+}, "Who may register: open, invite (invitation code required) or waitlist (sign-ups without a code wait for approval)")
+
+var errInvalidInvitation = errors.New("invalid, expired or used up invitation code")
+
+// invitationAlphabet 去掉了容易看错的 0/O、1/I/L
+const invitationAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// generateInvitationCode 生成 XXXX-XXXX-XXXX 形式的随机邀请码
+func generateInvitationCode() string {
+	var b strings.Builder
+	for i := 0; i < 12; i++ {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(invitationAlphabet))))
+		if err != nil {
+			panic(err)
+		}
+		b.WriteByte(invitationAlphabet[n.Int64()])
+	}
+	return b.String()
+}
+
+// normalizeInvitationCode 邀请码不区分大小写，首尾空白忽略
+func normalizeInvitationCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// SignupInfo GET /api/auth/signup 当前的注册方式
+func SignupInfo(c *gin.Context) {
+	c.JSON(200, SignupInfoResponse{Mode: signupMode.Get()})
+}
+
+// joinWaitlist 验证码已经校验过：消费验证码并把邮箱加入候补名单，202
+func joinWaitlist(c *gin.Context, req *RegisterRequest, codeID int) {
+	ctx := c.Request.Context()
+	if _, err := dblayer.GetUserByEmail(ctx, req.Email); err == nil {
+		c.JSON(400, gin.H{"error": errEmailExists.Error()})
+		return
+	}
+	var entry *dblayer.WaitlistEntry
+	err := dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if req.Code != SPECIAL_CODE {
+			if err := dblayer.ConsumeVerificationCode(ctx, tx, codeID); err != nil {
+				return err
+			}
+		}
+		var err error
+		entry, err = dblayer.JoinWaitlist(ctx, tx, req.Email, requestLocale(c, req.Locale))
+		return err
+	})
+	if err == dblayer.ErrNotFound {
+		c.JSON(400, gin.H{"error": "code already used"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("join waitlist failed", "email", req.Email, "error", err)
+		c.JSON(500, gin.H{"error": "failed to join the waitlist"})
+		return
+	}
+	RequestLog(c).Info("sign-up waitlisted", "email", req.Email, "waitlist_id", entry.ID)
+	resp := SignupWaitlistedResponse{
+		Waitlisted: true,
+		Status:     entry.Status,
+		Message:    "you are on the waitlist, we will email you an invitation code once approved",
+	}
+	if entry.Status == dblayer.WaitlistApproved {
+		resp.Message = "your request was approved, register with the invitation code sent to your email"
+	}
+	c.JSON(202, resp)
+}
+
+// ListInvitations GET /admin/invitations 所有邀请码，?active=true 只列出还能用的
+func ListInvitations(c *gin.Context) {
+	list, err := dblayer.ListInvitations(c.Request.Context(), c.Query("active") == "true")
+	if err != nil {
+		RequestLog(c).Error("list invitations failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list invitations"})
+		return
+	}
+	c.JSON(200, InvitationsResponse{Invitations: list})
+}
+
+// CreateInvitation POST /admin/invitations 新建邀请码
+func CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(400, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+	inv := &dblayer.Invitation{
+		Code:      normalizeInvitationCode(req.Code),
+		Email:     req.Email,
+		Note:      req.Note,
+		MaxUses:   1,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: internalCaller(c),
+	}
+	if inv.Code == "" {
+		inv.Code = generateInvitationCode()
+	}
+	if req.MaxUses != nil {
+		inv.MaxUses = *req.MaxUses
+	}
+	if inv.ExpiresAt != nil {
+		t := inv.ExpiresAt.UTC()
+		inv.ExpiresAt = &t
+	}
+
+	if err := dblayer.CreateInvitation(c.Request.Context(), dblayer.DB, inv); err != nil {
+		if err == dblayer.ErrInvitationExists {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		RequestLog(c).Error("create invitation failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create invitation"})
+		return
+	}
+	RequestLog(c).Info("invitation created", "invitation_id", inv.ID, "max_uses", inv.MaxUses)
+	c.JSON(200, inv)
+}
+
+// DeleteInvitation DELETE /admin/invitations/:id 作废邀请码
+func DeleteInvitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "invitation not found"})
+		return
+	}
+	if err := dblayer.DeleteInvitation(c.Request.Context(), id); err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "invitation not found"})
+		return
+	} else if err != nil {
+		RequestLog(c).Error("delete invitation failed", "invitation_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete invitation"})
+		return
+	}
+	RequestLog(c).Info("invitation deleted", "invitation_id", id)
+	c.JSON(200, MessageResponse{Message: "invitation deleted"})
+}
+
+// ListWaitlist GET /admin/waitlist 候补名单，先申请的在前；?status= 默认 pending，all 列出全部
+func ListWaitlist(c *gin.Context) {
+	status := c.DefaultQuery("status", dblayer.WaitlistPending)
+	switch status {
+	case "all":
+		status = ""
+	case dblayer.WaitlistPending, dblayer.WaitlistApproved, dblayer.WaitlistRejected:
+	default:
+		c.JSON(400, gin.H{"error": "status must be pending, approved, rejected or all"})
+		return
+	}
+	limit := maxWaitlistList
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxWaitlistList {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxWaitlistList)})
+			return
+		}
+		limit = n
+	}
+	entries, err := dblayer.ListWaitlist(c.Request.Context(), status, limit)
+	if err != nil {
+		RequestLog(c).Error("list waitlist failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list the waitlist"})
+		return
+	}
+	c.JSON(200, WaitlistResponse{Entries: entries})
+}
+
+// ApproveWaitlist POST /admin/waitlist/:id/approve 批准申请：生成绑定该邮箱的一次性邀请码并发邮件。
+// 邮件发送失败不回滚，响应里带着邀请码
+func ApproveWaitlist(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "waitlist entry not found"})
+		return
+	}
+	// 请求体可以省略
+	var req ApproveWaitlistRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, bindError(err))
+			return
+		}
+	}
+	expires := time.Now().UTC().Add(waitlistInvitationTTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			c.JSON(400, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+		expires = req.ExpiresAt.UTC()
+	}
+
+	ctx := c.Request.Context()
+	pending, err := dblayer.GetWaitlistEntry(ctx, id)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "waitlist entry not found"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get waitlist entry failed", "waitlist_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to approve"})
+		return
+	}
+	inv := &dblayer.Invitation{
+		Code:      generateInvitationCode(),
+		Email:     pending.Email,
+		Note:      "waitlist approval",
+		MaxUses:   1,
+		ExpiresAt: &expires,
+		CreatedBy: internalCaller(c),
+	}
+	var entry *dblayer.WaitlistEntry
+	err = dblayer.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := dblayer.CreateInvitation(ctx, tx, inv); err != nil {
+			return err
+		}
+		var err error
+		entry, err = dblayer.DecideWaitlistEntry(ctx, tx, id, dblayer.WaitlistApproved, &inv.ID, internalCaller(c))
+		return err
+	})
+	if err == dblayer.ErrNotFound {
+		c.JSON(409, gin.H{"error": "waitlist entry was already " + pending.Status})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("approve waitlist entry failed", "waitlist_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to approve"})
+		return
+	}
+
+	resp := ApproveWaitlistResponse{Entry: entry, Invitation: inv, Emailed: true}
+	_, err = notify.SendMailTo(ctx, entry.Email, entry.Locale, notify.Mail{
+		Template: notify.MailSignupInvitation,
+		Data:     notify.InvitationData{Code: inv.Code, ExpiresAt: expires.Format("2006-01-02 15:04 MST")},
+	})
+	if err != nil {
+		RequestLog(c).Error("send invitation failed", "waitlist_id", id, "error", err)
+		resp.Emailed = false
+	}
+	RequestLog(c).Info("waitlist entry approved", "waitlist_id", id, "invitation_id", inv.ID)
+	c.JSON(200, resp)
+}
+
+// RejectWaitlist POST /admin/waitlist/:id/reject 拒绝申请，不通知；同一邮箱再次注册会重新进入候补名单
+func RejectWaitlist(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "waitlist entry not found"})
+		return
+	}
+	entry, err := dblayer.DecideWaitlistEntry(c.Request.Context(), dblayer.DB, id, dblayer.WaitlistRejected, nil, internalCaller(c))
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "no pending waitlist entry with this id"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("reject waitlist entry failed", "waitlist_id", id, "error", err)
+		c.JSON(500, gin.H{"error": "failed to reject"})
+		return
+	}
+	RequestLog(c).Info("waitlist entry rejected", "waitlist_id", id)
+	c.JSON(200, entry)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"jabberwocky238/console/billing"
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/handlers/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxSpendCapActions GET /api/spend-cap/actions 的 ?limit= 上限和默认值
+const MaxSpendCapActions = 500
+
+// GetSpendCap 花费上限、本月累计花费和执行状态，没有设置时 404
+func GetSpendCap(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	sc, err := dblayer.GetSpendCap(c.Request.Context(), userUID)
+	if err == dblayer.ErrNotFound {
+		c.JSON(404, gin.H{"error": "no spending cap set"})
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get spend cap failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get spending cap"})
+		return
+	}
+	period, start := jobs.SpendCapPeriod(time.Now())
+	accrued, err := jobs.AccruedCost(c.Request.Context(), userUID, start, time.Now())
+	if err != nil {
+		RequestLog(c).Error("accrued cost failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get spending cap"})
+		return
+	}
+	c.JSON(200, SpendCapResponse{
+		SpendCap:   *sc,
+		Period:     period,
+		Accrued:    accrued,
+		Enforced:   sc.EnforcedPeriod == period,
+		Overridden: sc.OverridePeriod == period,
+	})
+}
+
+// SetSpendCap 设置或修改花费上限，立即按新上限检查一次
+func SetSpendCap(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	var req SetSpendCapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if err := dblayer.SetSpendCap(c.Request.Context(), userUID, req.MonthlyCap, billing.Sheet.Currency); err != nil {
+		RequestLog(c).Error("set spend cap failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to set spending cap"})
+		return
+	}
+	recordUserSpendCapAction(c, dblayer.SpendCapActionSet, req.MonthlyCap,
+		fmt.Sprintf("monthly cap set to %.2f %s", req.MonthlyCap, billing.Sheet.Currency))
+	enqueueSpendCapCheck(c, userUID)
+	GetSpendCap(c)
+}
+
+// DeleteSpendCap 取消花费上限，恢复被暂停的 worker
+func DeleteSpendCap(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	if err := dblayer.DeleteSpendCap(c.Request.Context(), userUID); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "no spending cap set"})
+			return
+		}
+		RequestLog(c).Error("delete spend cap failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to delete spending cap"})
+		return
+	}
+	recordUserSpendCapAction(c, dblayer.SpendCapActionClear, 0, "monthly cap removed")
+	enqueueSpendCapCheck(c, userUID)
+	c.JSON(200, MessageResponse{Message: "spending cap removed"})
+}
+
+// OverrideSpendCap 本月不再按上限暂停 worker，已暂停的恢复；下个月重新生效
+func OverrideSpendCap(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	period, _ := jobs.SpendCapPeriod(time.Now())
+	if err := dblayer.SetSpendCapOverride(c.Request.Context(), userUID, period); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "no spending cap set"})
+			return
+		}
+		RequestLog(c).Error("override spend cap failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to override spending cap"})
+		return
+	}
+	recordUserSpendCapAction(c, dblayer.SpendCapActionOverride, 0, "cap overridden for "+period)
+	enqueueSpendCapCheck(c, userUID)
+	GetSpendCap(c)
+}
+
+// ListSpendCapActions 花费上限的设置和执行记录，新的在前
+func ListSpendCapActions(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	limit := MaxSpendCapActions
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxSpendCapActions {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", MaxSpendCapActions)})
+			return
+		}
+		limit = n
+	}
+	actions, err := dblayer.ListSpendCapActions(c.Request.Context(), userUID, limit)
+	if err != nil {
+		RequestLog(c).Error("list spend cap actions failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to list spending cap actions"})
+		return
+	}
+	c.JSON(200, SpendCapActionsResponse{Actions: actions})
+}
+
+// recordUserSpendCapAction 记录用户对上限的操作，带当时的累计花费
+func recordUserSpendCapAction(c *gin.Context, action string, monthlyCap float64, msg string) {
+	userUID := c.GetString("user_id")
+	period, start := jobs.SpendCapPeriod(time.Now())
+	accrued, _ := jobs.AccruedCost(c.Request.Context(), userUID, start, time.Now())
+	err := dblayer.RecordSpendCapAction(c.Request.Context(), userUID, dblayer.SpendCapAction{
+		Period: period, Action: action, Actor: "user", Accrued: accrued, Cap: monthlyCap, Message: msg,
+	})
+	if err != nil {
+		RequestLog(c).Error("record spend cap action failed", "action", action, "error", err)
+	}
+}
+
+// enqueueSpendCapCheck 让 inner 立即按新设置暂停或恢复，失败时等下一次计量采样
+func enqueueSpendCapCheck(c *gin.Context, userUID string) {
+	if err := SendTask(jobs.NewSpendCapJob(userUID)); err != nil {
+		RequestLog(c).Warn("enqueue spend cap check failed", "error", err)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gitRefPattern 分支、tag 或提交，原样拼进 GitHub API 的路径
+var gitRefPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// DeployStaticSite POST /api/worker/:id/static 部署 static worker 的文件：
+// 请求体是 gzip 压缩的 tarball（application/gzip，?dir= 指定站点根目录），或者 JSON 的公开 GitHub 仓库。
+// 和镜像部署一样产生部署版本，克隆和提升沿用版本上记录的文件来源
+func (h *WorkerHandler) DeployStaticSite(c *gin.Context) {
+	ctx := c.Request.Context()
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	w, err := dblayer.GetWorkerByOwner(ctx, workerID, userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	if w.Kind != dblayer.WorkerKindStatic {
+		c.JSON(409, gin.H{"error": "worker is not static, deploy an image instead"})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	var src dblayer.StaticSource
+	switch c.ContentType() {
+	case "application/json":
+		var req StaticRepoRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, bindError(err))
+			return
+		}
+		if !repoPattern.MatchString(req.Repo) {
+			c.JSON(400, gin.H{"error": "repo must be owner/name"})
+			return
+		}
+		if req.Ref != "" && (!gitRefPattern.MatchString(req.Ref) || strings.Contains(req.Ref, "..")) {
+			c.JSON(400, gin.H{"error": "ref must be a branch, tag or commit"})
+			return
+		}
+		src = dblayer.StaticSource{Repo: req.Repo, Ref: cmp.Or(req.Ref, "main"), Dir: req.Dir}
+	case "application/gzip", "application/x-gzip", "application/octet-stream":
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(400, bindError(err))
+			return
+		}
+		if err := checkSiteTarball(body); err != nil {
+			c.JSON(400, gin.H{"error": "body is not a gzipped tarball: " + err.Error()})
+			return
+		}
+		sum := sha256.Sum256(body)
+		src = dblayer.StaticSource{Artifact: hex.EncodeToString(sum[:]), Dir: c.Query("dir")}
+		if err := dblayer.PutStaticArtifact(ctx, userUID, src.Artifact, body); err != nil {
+			RequestLog(c).Error("store static artifact failed", "worker_id", workerID, "error", err)
+			c.JSON(500, gin.H{"error": "failed to store the tarball"})
+			return
+		}
+	default:
+		c.JSON(415, gin.H{"error": "send a gzipped tarball as application/gzip or a repo as application/json"})
+		return
+	}
+
+	dir, ok := siteDir(src.Dir)
+	if !ok {
+		c.JSON(400, gin.H{"error": "dir must be a relative path inside the files"})
+		return
+	}
+	src.Dir = dir
+
+	resp, err := deployWorker(ctx, DeployWorkerRequest{
+		UserUID: userUID, WorkerID: workerID, Image: k8s.StaticSiteImage, Port: k8s.StaticSitePort, Static: &src,
+	})
+	if err != nil {
+		writeDeployError(c, err)
+		return
+	}
+	c.JSON(200, resp)
+}
+
+// checkSiteTarball 确认是 gzip 压缩的 tar，并且至少有一个条目
+func checkSiteTarball(data []byte) error {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if _, err := tar.NewReader(zr).Next(); err != nil {
+		if err == io.EOF {
+			return errors.New("empty archive")
+		}
+		return err
+	}
+	return nil
+}
+
+// siteDir 规范化站点根目录，不能是绝对路径或跳出文件所在的目录
+func siteDir(dir string) (string, bool) {
+	if dir == "" {
+		return "", true
+	}
+	if strings.HasPrefix(dir, "/") {
+		return "", false
+	}
+	dir = path.Clean(dir)
+	if dir == ".." || strings.HasPrefix(dir, "../") {
+		return "", false
+	}
+	if dir == "." {
+		dir = ""
+	}
+	return dir, true
+}
+
+// StaticArtifact GET /static-artifacts/:uid/:sha256?sig= 给 static worker 的 init 容器下载上传的 tarball，
+// sig 是用户 secret key 对路径的 HMAC（k8s.StaticArtifactSignature）
+func StaticArtifact(c *gin.Context) {
+	uid, sum := c.Param("uid"), c.Param("sha256")
+	sk, err := dblayer.GetUserSecretKey(c.Request.Context(), uid)
+	if err != nil {
+		c.String(404, "not found")
+		return
+	}
+	sig, err := hex.DecodeString(c.Query("sig"))
+	want, _ := hex.DecodeString(k8s.StaticArtifactSignature(sk, uid, sum))
+	if err != nil || !hmac.Equal(sig, want) {
+		c.String(404, "not found")
+		return
+	}
+	content, err := dblayer.GetStaticArtifact(c.Request.Context(), uid, sum)
+	if err == dblayer.ErrNotFound {
+		c.String(404, "not found")
+		return
+	}
+	if err != nil {
+		RequestLog(c).Error("get static artifact failed", "user_id", uid, "error", err)
+		c.String(500, "")
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	c.Data(http.StatusOK, "application/gzip", content)
+}
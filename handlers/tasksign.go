@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// outer 提交给 inner 的任务（/acceptTask）用 HMAC-SHA256 签名：
+// 签名覆盖 时间戳、nonce、method、path 和 body 的 sha256，
+// inner 拒绝签名不对、时间戳超出 TaskSignatureMaxSkew、nonce 重复的请求。
+// 密钥（TASK_SIGNING_KEYS）两个网关配置相同，第一个用于签名，其余只用于校验，
+// 轮换时先在两边把新密钥加到末尾，再挪到第一个，最后删掉旧密钥。
+
+// 签名相关的请求头
+const (
+	TaskKeyIDHeader     = "X-Task-Key-Id"
+	TaskTimestampHeader = "X-Task-Timestamp"
+	TaskNonceHeader     = "X-Task-Nonce"
+	TaskSignatureHeader = "X-Task-Signature"
+)
+
+// TaskSignatureMaxSkew 请求时间戳与 inner 本地时间允许的最大偏差，nonce 保留两倍时长
+const TaskSignatureMaxSkew = 5 * time.Minute
+
+// maxTaskBodySize 签名校验时读取的请求体上限
+const maxTaskBodySize = 4 << 20
+
+var taskNoncePattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// taskSigningKeys 按 id 的签名密钥，taskSigningKeyID 为签名使用的密钥
+var (
+	taskSigningKeys  map[string][]byte
+	taskSigningKeyID string
+)
+
+// SetTaskSigningKeys 设置任务签名密钥（TASK_SIGNING_KEYS）：逗号分隔的 id=base64 密钥，
+// 每个至少 32 字节，第一个用于签名
+func SetTaskSigningKeys(value string) error {
+	keys := map[string][]byte{}
+	primary := ""
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, "=")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return fmt.Errorf("%q is not id=key", pair)
+		}
+		if _, dup := keys[id]; dup {
+			return fmt.Errorf("duplicate key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) < 32 {
+			return fmt.Errorf("key %q must be at least 32 bytes, base64 encoded", id)
+		}
+		keys[id] = key
+		if primary == "" {
+			primary = id
+		}
+	}
+	if primary == "" {
+		return errors.New("no key")
+	}
+	taskSigningKeys, taskSigningKeyID = keys, primary
+	return nil
+}
+
+// TaskSigningEnabled 是否配置了任务签名密钥
+func TaskSigningEnabled() bool {
+	return taskSigningKeyID != ""
+}
+
+// taskSignature 计算签名（hex）
+func taskSignature(key []byte, timestamp, nonce, method, path string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", timestamp, nonce, method, path, hex.EncodeToString(sum[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signTaskRequest (outer) 给发往 inner 的请求加上签名头，未配置密钥时不签名
+func signTaskRequest(req *http.Request, body []byte) {
+	if !TaskSigningEnabled() {
+		return
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	nonce := hex.EncodeToString(b)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TaskKeyIDHeader, taskSigningKeyID)
+	req.Header.Set(TaskTimestampHeader, timestamp)
+	req.Header.Set(TaskNonceHeader, nonce)
+	req.Header.Set(TaskSignatureHeader, taskSignature(taskSigningKeys[taskSigningKeyID], timestamp, nonce, req.Method, req.URL.Path, body))
+}
+
+// nonceCache 记录时间窗口内见过的 nonce。只在本实例内存中，
+// inner 单副本运行；滚动更新时新旧 Pod 各自记录，窗口内的重放仍受时间戳限制
+type nonceCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // nonce → 过期时间
+}
+
+var taskNonces = &nonceCache{seen: map[string]time.Time{}}
+
+// add 记录 nonce，已见过时返回 false
+func (n *nonceCache) add(nonce string, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if exp, ok := n.seen[nonce]; ok && now.Before(exp) {
+		return false
+	}
+	for k, exp := range n.seen {
+		if !now.Before(exp) {
+			delete(n.seen, k)
+		}
+	}
+	n.seen[nonce] = now.Add(2 * TaskSignatureMaxSkew)
+	return true
+}
+
+// VerifyTaskSignature (inner) 校验 outer 的任务签名并拒绝过期和重放的请求；
+// 未配置 TASK_SIGNING_KEYS 时放行
+func VerifyTaskSignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !TaskSigningEnabled() {
+			c.Next()
+			return
+		}
+		reject := func(reason string) {
+			RequestLog(c).Warn("task signature rejected", "reason", reason, "key_id", c.GetHeader(TaskKeyIDHeader))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": reason})
+		}
+
+		keyID := c.GetHeader(TaskKeyIDHeader)
+		timestamp := c.GetHeader(TaskTimestampHeader)
+		nonce := c.GetHeader(TaskNonceHeader)
+		signature := c.GetHeader(TaskSignatureHeader)
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			reject("missing task signature")
+			return
+		}
+		key, ok := taskSigningKeys[keyID]
+		if !ok {
+			reject("unknown task signing key")
+			return
+		}
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		now := time.Now()
+		if err != nil || now.Sub(time.Unix(ts, 0)).Abs() > TaskSignatureMaxSkew {
+			reject("task timestamp is outside the allowed window")
+			return
+		}
+		if !taskNoncePattern.MatchString(nonce) {
+			reject("invalid task nonce")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxTaskBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "task body is too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read task body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		want := taskSignature(key, timestamp, nonce, c.Request.Method, c.Request.URL.Path, body)
+		if !hmac.Equal([]byte(want), []byte(strings.ToLower(signature))) {
+			reject("invalid task signature")
+			return
+		}
+		// 签名通过后才记录 nonce，伪造的请求占不掉合法的 nonce
+		if !taskNonces.add(nonce, now) {
+			reject("task nonce was already used")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 慢客户端和大请求保护：http.Server 的超时兜住连接（见 cmd 里的 -read-timeout、-write-timeout），
+// RequestTimeout 给请求 context 加 deadline，handler 把 c.Request.Context() 传给 K8s 和 inner 调用；
+// 数据库查询另有 statement_timeout（dblayer.StatementTimeout）。
+// RequestTimeout 后注册的覆盖先注册的：全局用默认值，个别路由再放宽，SSE、WebSocket、日志流用 Streaming 去掉。
+// MaxBodySize 按路由组注册（先注册的上限在 Content-Length 检查时就生效，不能被放宽）。
+
+// 请求体大小上限
+const (
+	AuthBodyLimit    int64 = 16 << 10 // 登录、注册、验证码
+	DefaultBodyLimit int64 = 1 << 20
+	BulkBodyLimit    int64 = 8 << 20  // import、bulk
+	UploadBodyLimit  int64 = 64 << 20 // static worker 的 tarball
+)
+
+// 请求处理时间上限
+const (
+	DefaultRequestTimeout = 30 * time.Second
+	BulkRequestTimeout    = 2 * time.Minute // import 逐个部署，每个都可能做一次 dry run
+)
+
+// writeDeadlineGrace 超时后留给 handler 写错误响应的时间
+const writeDeadlineGrace = 5 * time.Second
+
+// originalContextKey 保存第一次 RequestTimeout 之前的 context，后面的 RequestTimeout 以它为准重新计时
+const originalContextKey = "original_context"
+
+// MaxBodySize 限制请求体大小：Content-Length 超出时直接 413，分块上传读到上限时读取报错
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large, limit is " + strconv.FormatInt(limit, 10) + " bytes"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// RequestTimeout 请求 context 在 d 后取消，连接的写 deadline 相应放宽到 d 之后
+func RequestTimeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := requestBaseContext(c)
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		// 路由放宽了超时时，server 的 WriteTimeout 也要跟着放宽
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(d + writeDeadlineGrace))
+		c.Next()
+	}
+}
+
+// Streaming 长连接（SSE、WebSocket、日志流）：去掉 RequestTimeout 的 deadline 和连接的读写 deadline，
+// 客户端断开或停机时由 handler 自己结束
+func Streaming() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(requestBaseContext(c))
+		rc := http.NewResponseController(c.Writer)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+		c.Next()
+	}
+}
+
+// requestBaseContext 第一次 RequestTimeout 之前的 context
+func requestBaseContext(c *gin.Context) context.Context {
+	if orig, ok := c.Get(originalContextKey); ok {
+		return orig.(context.Context)
+	}
+	ctx := c.Request.Context()
+	c.Set(originalContextKey, ctx)
+	return ctx
+}
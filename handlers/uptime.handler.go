@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 拨测的默认值与限制
+const (
+	MaxUptimeChecks              = 20
+	DefaultUptimeIntervalSeconds = 60
+	DefaultUptimeTimeoutMs       = 10000
+	DefaultUptimeResultsWindow   = 24 * time.Hour
+	MaxUptimeResultsWindow       = 30 * 24 * time.Hour
+	MaxUptimeResults             = 5000
+
+	statusPageIncidentWindow = 7 * 24 * time.Hour
+	statusPageMaxIncidents   = 50
+)
+
+// UptimeHandler 拨测、事故与状态页设置。outer 只写数据库，由 inner 按周期探测
+type UptimeHandler struct{}
+
+func NewUptimeHandler() *UptimeHandler {
+	return &UptimeHandler{}
+}
+
+// ListChecks 分页列出拨测，?status=up|down|unknown 过滤
+func (h *UptimeHandler) ListChecks(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	checks, next, err := dblayer.ListUptimeChecks(c.Request.Context(), userUID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list uptime checks"})
+		return
+	}
+	if checks == nil {
+		checks = []*dblayer.UptimeCheck{}
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListUptimeChecksResponse{Checks: checks, NextCursor: next})
+}
+
+// CreateCheck 为自己的 worker 或已验证的自定义域名创建拨测
+func (h *UptimeHandler) CreateCheck(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	var req UptimeCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	check := &dblayer.UptimeCheck{
+		UserUID:         userUID,
+		Name:            req.Name,
+		IntervalSeconds: req.IntervalSeconds,
+		TimeoutMs:       req.TimeoutMs,
+		ExpectedStatus:  req.ExpectedStatus,
+		Public:          req.Public,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+	}
+	applyUptimeDefaults(check)
+
+	if req.WorkerID != "" {
+		if !authorizeResource(c, dblayer.ResourceWorker, req.WorkerID) {
+			return
+		}
+		check.WorkerID = &req.WorkerID
+		check.URL = workerURL(req.WorkerID, userUID) + req.Path
+	} else {
+		if !authorizeResource(c, dblayer.ResourceDomain, req.DomainID) {
+			return
+		}
+		cd, err := dblayer.GetCustomDomain(c.Request.Context(), req.DomainID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "domain not found"})
+			return
+		}
+		if cd.Status != "success" {
+			c.JSON(409, gin.H{"error": "domain is not verified"})
+			return
+		}
+		check.DomainID = &cd.CDID
+		check.URL = "https://" + cd.Domain + req.Path
+	}
+
+	n, err := dblayer.CountUptimeChecks(c.Request.Context(), userUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to count uptime checks"})
+		return
+	}
+	if n >= MaxUptimeChecks {
+		c.JSON(409, gin.H{"error": fmt.Sprintf("at most %d uptime checks per user", MaxUptimeChecks)})
+		return
+	}
+
+	if err := dblayer.CreateUptimeCheck(c.Request.Context(), check); err != nil {
+		RequestLog(c).Error("create uptime check failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to create uptime check"})
+		return
+	}
+
+	c.JSON(200, check)
+}
+
+// GetCheck 拨测详情，附带最近 24 小时和 7 天的可用率
+func (h *UptimeHandler) GetCheck(c *gin.Context) {
+	check, ok := ownedUptimeCheck(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	day, err := dblayer.UptimeStatsSince(c.Request.Context(), []int{check.ID}, now.Add(-24*time.Hour))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load uptime stats"})
+		return
+	}
+	week, err := dblayer.UptimeStatsSince(c.Request.Context(), []int{check.ID}, now.Add(-7*24*time.Hour))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load uptime stats"})
+		return
+	}
+
+	c.JSON(200, UptimeCheckDetailResponse{Check: check, Stats24h: day[check.ID], Stats7d: week[check.ID]})
+}
+
+// UpdateCheck 整体替换拨测配置，停用时结束进行中的事故
+func (h *UptimeHandler) UpdateCheck(c *gin.Context) {
+	existing, ok := ownedUptimeCheck(c)
+	if !ok {
+		return
+	}
+	var req UpdateUptimeCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	check := &dblayer.UptimeCheck{
+		ID:              existing.ID,
+		UserUID:         existing.UserUID,
+		Name:            req.Name,
+		IntervalSeconds: req.IntervalSeconds,
+		TimeoutMs:       req.TimeoutMs,
+		ExpectedStatus:  req.ExpectedStatus,
+		Public:          req.Public,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+	}
+	applyUptimeDefaults(check)
+
+	if err := dblayer.UpdateUptimeCheckByOwner(c.Request.Context(), check); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "uptime check not found"})
+			return
+		}
+		RequestLog(c).Error("update uptime check failed", "check_id", check.ID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to update uptime check"})
+		return
+	}
+
+	c.JSON(200, check)
+}
+
+// DeleteCheck 删除拨测及其探测结果和事故
+func (h *UptimeHandler) DeleteCheck(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "uptime check not found"})
+		return
+	}
+	if err := dblayer.DeleteUptimeCheckByOwner(c.Request.Context(), id, c.GetString("user_id")); err != nil {
+		if err == dblayer.ErrNotFound {
+			c.JSON(404, gin.H{"error": "uptime check not found"})
+		} else {
+			c.JSON(500, gin.H{"error": "failed to delete uptime check"})
+		}
+		return
+	}
+
+	c.JSON(200, MessageResponse{Message: "deleted"})
+}
+
+// CheckResults 探测结果与响应时间，?since= 为 RFC3339 时间或时长（默认 24h，最多 30 天），?limit= 最多 5000
+func (h *UptimeHandler) CheckResults(c *gin.Context) {
+	check, ok := ownedUptimeCheck(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	since, err := parseLogTime(c.Query("since"), now, now.Add(-DefaultUptimeResultsWindow))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid since: " + err.Error()})
+		return
+	}
+	if now.Sub(since) > MaxUptimeResultsWindow {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("since must be within %s", MaxUptimeResultsWindow)})
+		return
+	}
+	limit := MaxUptimeResults
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxUptimeResults {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", MaxUptimeResults)})
+			return
+		}
+		limit = n
+	}
+
+	results, err := dblayer.ListUptimeResults(c.Request.Context(), check.ID, since, limit)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list uptime results"})
+		return
+	}
+	if results == nil {
+		results = []*dblayer.UptimeResult{}
+	}
+	stats, err := dblayer.UptimeStatsSince(c.Request.Context(), []int{check.ID}, since)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load uptime stats"})
+		return
+	}
+
+	c.JSON(200, UptimeResultsResponse{CheckID: check.ID, Since: since, Stats: stats[check.ID], Results: results})
+}
+
+// ListIncidents 分页列出事故，?check_id= 只看某个拨测，?status=open|resolved
+func (h *UptimeHandler) ListIncidents(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	opts, err := parseUntaggedListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	checkID := 0
+	if v := c.Query("check_id"); v != "" {
+		if checkID, err = strconv.Atoi(v); err != nil || checkID <= 0 {
+			c.JSON(400, gin.H{"error": "check_id must be a positive integer"})
+			return
+		}
+	}
+
+	incidents, next, err := dblayer.ListUptimeIncidents(c.Request.Context(), userUID, checkID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to list incidents"})
+		return
+	}
+	if incidents == nil {
+		incidents = []*dblayer.UptimeIncident{}
+	}
+
+	setNextCursor(c, next)
+	c.JSON(200, ListUptimeIncidentsResponse{Incidents: incidents, NextCursor: next})
+}
+
+// GetStatusPage 当前用户的状态页设置，没有设置过时 404
+func (h *UptimeHandler) GetStatusPage(c *gin.Context) {
+	page, err := dblayer.GetStatusPage(c.Request.Context(), c.GetString("user_id"))
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "status page not configured"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load status page"})
+		return
+	}
+	c.JSON(200, page)
+}
+
+// SetStatusPage 创建或更新状态页（slug 全局唯一），只展示 public 的拨测
+func (h *UptimeHandler) SetStatusPage(c *gin.Context) {
+	var req StatusPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	page := &dblayer.StatusPage{
+		UserUID: c.GetString("user_id"),
+		Slug:    req.Slug,
+		Title:   req.Title,
+		Enabled: req.Enabled == nil || *req.Enabled,
+	}
+	if err := dblayer.UpsertStatusPage(c.Request.Context(), page); err != nil {
+		if errors.Is(err, dblayer.ErrSlugTaken) {
+			c.JSON(409, gin.H{"error": "slug already taken"})
+			return
+		}
+		RequestLog(c).Error("save status page failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to save status page"})
+		return
+	}
+
+	c.JSON(200, page)
+}
+
+// PublicStatusPage 公开状态页 JSON，无需登录，允许任意来源跨域读取
+func PublicStatusPage(c *gin.Context) {
+	c.Header("Access-Control-Allow-Origin", "*")
+
+	page, err := dblayer.GetStatusPageBySlug(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "status page not found"})
+		return
+	}
+
+	checks, err := dblayer.ListPublicUptimeChecks(c.Request.Context(), page.UserUID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load status page"})
+		return
+	}
+	ids := make([]int, len(checks))
+	for i, check := range checks {
+		ids[i] = check.ID
+	}
+	now := time.Now()
+	day, err := dblayer.UptimeStatsSince(c.Request.Context(), ids, now.Add(-24*time.Hour))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load status page"})
+		return
+	}
+	week, err := dblayer.UptimeStatsSince(c.Request.Context(), ids, now.Add(-7*24*time.Hour))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load status page"})
+		return
+	}
+	incidents, err := dblayer.ListPublicUptimeIncidents(c.Request.Context(), page.UserUID, now.Add(-statusPageIncidentWindow), statusPageMaxIncidents)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load status page"})
+		return
+	}
+	if incidents == nil {
+		incidents = []*dblayer.UptimeIncident{}
+	}
+
+	resp := PublicStatusPageResponse{
+		Title:       page.Title,
+		Checks:      make([]PublicStatusCheck, 0, len(checks)),
+		Incidents:   incidents,
+		GeneratedAt: now,
+	}
+	down := 0
+	for _, check := range checks {
+		pc := PublicStatusCheck{Name: check.Name, Status: check.Status, LastCheckedAt: check.LastCheckedAt}
+		if s, ok := day[check.ID]; ok {
+			pc.Uptime24h = &s.UptimePercent
+			pc.AvgLatencyMs24h = &s.AvgLatencyMs
+		}
+		if s, ok := week[check.ID]; ok {
+			pc.Uptime7d = &s.UptimePercent
+		}
+		if check.Status == dblayer.UptimeStatusDown {
+			down++
+		}
+		resp.Checks = append(resp.Checks, pc)
+	}
+	switch {
+	case len(checks) == 0:
+		resp.Status = dblayer.UptimeStatusUnknown
+	case down == len(checks):
+		resp.Status = "major_outage"
+	case down > 0:
+		resp.Status = "partial_outage"
+	default:
+		resp.Status = "operational"
+	}
+
+	c.Header("Cache-Control", "public, max-age=30")
+	c.JSON(200, resp)
+}
+
+// ownedUptimeCheck 按 :id 取当前用户的拨测，不存在时已写好 404
+func ownedUptimeCheck(c *gin.Context) (*dblayer.UptimeCheck, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err == nil {
+		if check, err := dblayer.GetUptimeCheckByOwner(c.Request.Context(), id, c.GetString("user_id")); err == nil {
+			return check, true
+		}
+	}
+	c.JSON(404, gin.H{"error": "uptime check not found"})
+	return nil, false
+}
+
+func applyUptimeDefaults(check *dblayer.UptimeCheck) {
+	if check.IntervalSeconds == 0 {
+		check.IntervalSeconds = DefaultUptimeIntervalSeconds
+	}
+	if check.TimeoutMs == 0 {
+		check.TimeoutMs = DefaultUptimeTimeoutMs
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"time"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseUsagePeriod ?period= 为 YYYY-MM（整月）或 YYYY-MM-DD（一天），UTC；不填为当月
+func parseUsagePeriod(period string, now time.Time) (string, time.Time, time.Time, bool) {
+	if period == "" {
+		period = now.UTC().Format("2006-01")
+	}
+	if t, err := time.Parse("2006-01", period); err == nil {
+		return period, t, t.AddDate(0, 1, 0), true
+	}
+	if t, err := time.Parse("2006-01-02", period); err == nil {
+		return period, t, t.AddDate(0, 0, 1), true
+	}
+	return "", time.Time{}, time.Time{}, false
+}
+
+// GetUsage 用户在一个周期内的计量用量，按指标合计并列出每个资源的明细
+func GetUsage(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	period, from, to, ok := parseUsagePeriod(c.Query("period"), time.Now())
+	if !ok {
+		c.JSON(400, gin.H{"error": "invalid period, expected YYYY-MM or YYYY-MM-DD"})
+		return
+	}
+
+	resources, err := dblayer.UsageTotals(c.Request.Context(), userUID, from, to)
+	if err != nil {
+		RequestLog(c).Error("usage totals failed", "error", err)
+		c.JSON(500, gin.H{"error": "failed to get usage"})
+		return
+	}
+	sums := map[string]float64{}
+	for _, r := range resources {
+		sums[r.Metric] += r.Quantity
+	}
+	totals := make([]UsageMetricTotal, 0, len(dblayer.UsageMetrics))
+	for _, m := range dblayer.UsageMetrics {
+		totals = append(totals, UsageMetricTotal{Metric: m, Quantity: sums[m]})
+	}
+
+	c.JSON(200, UsageResponse{Period: period, From: from, To: to, Totals: totals, Resources: resources})
+}
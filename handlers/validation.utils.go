@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"jabberwocky238/console/k8s"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// 请求校验：自定义 validator + 把 binding 错误转成按字段的提示。
+// 结构体上通过 binding tag 使用：domain、image、quantity、cron、tags、webhook、slug、environment。
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// 错误里用 json 字段名，和请求体保持一致
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterValidation("domain", func(fl validator.FieldLevel) bool { return isDomainName(fl.Field().String()) })
+	v.RegisterValidation("image", func(fl validator.FieldLevel) bool { return isImageReference(fl.Field().String()) })
+	v.RegisterValidation("quantity", func(fl validator.FieldLevel) bool { return isQuantity(fl.Field().String()) })
+	v.RegisterValidation("cron", func(fl validator.FieldLevel) bool { return isCronSpec(fl.Field().String()) })
+	v.RegisterValidation("webhook", func(fl validator.FieldLevel) bool { return isWebhookURL(fl.Field().String()) })
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool { return slugPattern.MatchString(fl.Field().String()) })
+	v.RegisterValidation("environment", func(fl validator.FieldLevel) bool { return environmentPattern.MatchString(fl.Field().String()) })
+	v.RegisterValidation("tags", func(fl validator.FieldLevel) bool {
+		tags, ok := fl.Field().Interface().(map[string]string)
+		return ok && isTagSet(tags)
+	})
+}
+
+// bindError 把 ShouldBind 的错误转成响应体：校验错误按字段给出提示，JSON 格式错误原样返回
+func bindError(err error) ErrorResponse {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make(map[string]string, len(verrs))
+		for _, fe := range verrs {
+			fields[fieldPath(fe)] = fieldMessage(fe)
+		}
+		return ErrorResponse{Error: "invalid request", Fields: fields}
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ErrorResponse{Error: "request body too large, limit is " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes"}
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorResponse{Error: "malformed JSON body"}
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return ErrorResponse{
+			Error:  "invalid request",
+			Fields: map[string]string{typeErr.Field: "must be of type " + typeErr.Type.String()},
+		}
+	}
+	return ErrorResponse{Error: err.Error()}
+}
+
+// String 单行形式，用于没有 JSON 响应体的场景（如 gRPC status）
+func (e ErrorResponse) String() string {
+	if len(e.Fields) == 0 {
+		return e.Error
+	}
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + " " + e.Fields[name]
+	}
+	return e.Error + ": " + strings.Join(parts, "; ")
+}
+
+// fieldPath 去掉最外层结构体名：CreateWorkerRequest.assigned_cpu -> assigned_cpu
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, rest, ok := strings.Cut(ns, "."); ok {
+		return rest
+	}
+	if ns == "" {
+		return fe.Field()
+	}
+	return ns
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		return "is required for this action"
+	case "required_with":
+		return "is required when " + strings.ToLower(fe.Param()) + " is set"
+	case "required_without":
+		return "is required unless " + strings.ToLower(fe.Param()) + " is set"
+	case "excluded_with":
+		return "cannot be combined with " + strings.ToLower(fe.Param())
+	case "startswith":
+		return "must start with " + fe.Param()
+	case "eq":
+		return "must be " + fe.Param()
+	case "email":
+		return "must be a valid email"
+	case "min":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		if isList {
+			return fmt.Sprintf("must contain at least %s items", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		}
+		if isList {
+			return fmt.Sprintf("must contain at most %s items", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "domain":
+		return "must be a valid domain name"
+	case "image":
+		return "must be a valid image reference, e.g. ghcr.io/org/app:1.0"
+	case "quantity":
+		return `must be a resource quantity, e.g. "500m" or "512Mi"`
+	case "cron":
+		return `must be a cron spec, e.g. "*/5 * * * *" or "@daily"`
+	case "timezone":
+		return "must be an IANA time zone, e.g. Europe/Berlin"
+	case "webhook":
+		return "must be an http(s) URL with a public host, at most 2048 characters"
+	case "slug":
+		return "must be 3-40 lowercase letters, digits or '-', not starting or ending with '-'"
+	case "environment":
+		return "must be 1-32 lowercase letters, digits or '-', starting with a letter, e.g. staging"
+	case "tags":
+		return fmt.Sprintf("must have at most %d tags; keys and values up to 63 characters of letters, digits, '-', '_' or '.'", maxTags)
+	default:
+		return "failed " + fe.Tag() + " validation"
+	}
+}
+
+// isWebhookURL 用户回调地址：http/https，带主机名，不能直接写本机或内网 IP（发送时还会按解析结果再拦一次）
+func isWebhookURL(s string) bool {
+	if len(s) > 2048 {
+		return false
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast())
+	}
+	return u.Hostname() != "localhost"
+}
+
+// slugPattern 状态页等公开地址里使用的短名
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,38}[a-z0-9]$`)
+
+// environmentPattern 环境名，如 production、staging、qa-1
+var environmentPattern = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+var domainLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// isDomainName 至少两级的 RFC 1123 域名
+func isDomainName(s string) bool {
+	s = strings.TrimSuffix(strings.ToLower(s), ".")
+	if len(s) == 0 || len(s) > 253 {
+		return false
+	}
+	labels := strings.Split(s, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if !domainLabel.MatchString(l) {
+			return false
+		}
+	}
+	// 顶级域不能是纯数字
+	_, err := strconv.Atoi(labels[len(labels)-1])
+	return err != nil
+}
+
+// imageReference 简化版的 OCI 镜像引用：[registry[:port]/]path[:tag][@digest]
+var imageReference = regexp.MustCompile(`^` +
+	`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[\w][\w.-]{0,127})?` +
+	`(?:@sha256:[a-f0-9]{64})?$`)
+
+func isImageReference(s string) bool {
+	return len(s) <= 512 && imageReference.MatchString(s)
+}
+
+// isQuantity K8s 资源数量，如 500m、1、512Mi、10Gi
+func isQuantity(s string) bool {
+	q, err := resource.ParseQuantity(s)
+	return err == nil && q.Sign() > 0
+}
+
+// isCronSpec 标准 5 段 cron 表达式，或 @daily / @every 1h 这类描述符，语法见 k8s.ParseCronSchedule
+func isCronSpec(s string) bool {
+	_, err := k8s.ParseCronSchedule(s)
+	return err == nil
+}
+
+// maxTags 单个资源最多的标签数
+const maxTags = 32
+
+// tagPattern 与 K8s label 的 name/value 规则一致，标签会原样复制成 label
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+
+// isTagSet key 不能为空，value 可以为空
+func isTagSet(tags map[string]string) bool {
+	if len(tags) > maxTags {
+		return false
+	}
+	for k, v := range tags {
+		if !tagPattern.MatchString(k) || (v != "" && !tagPattern.MatchString(v)) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"net/url"
 	"strconv"
+	"time"
 
 	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/handlers/jobs"
@@ -16,7 +19,7 @@ import (
 )
 
 func workerURL(workerID, userUID string) string {
-	return fmt.Sprintf("https://%s.worker.%s", controller.WorkerName(workerID, userUID), k8s.Domain)
+	return "https://" + controller.WorkerHost(workerID, userUID)
 }
 
 type WorkerHandler struct{}
@@ -29,29 +32,39 @@ func NewWorkerHandler() *WorkerHandler {
 func (h *WorkerHandler) CreateWorker(c *gin.Context) {
 	userUID := c.GetString("user_id")
 
-	var req struct {
-		WorkerName     string `json:"worker_name" binding:"required"`
-		AssignedCPU    string `json:"assigned_cpu"`
-		AssignedMemory string `json:"assigned_memory"`
-		AssignedDisk   string `json:"assigned_disk"`
-		MaxReplicas    int    `json:"max_replicas"`
-		MainRegion     string `json:"main_region"`
-	}
+	var req CreateWorkerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, bindError(err))
+		return
+	}
+
+	if err := checkWorkerLimits(c.Request.Context(), userUID, true, workerSpec{
+		CPU: req.AssignedCPU, Memory: req.AssignedMemory, MaxReplicas: req.MaxReplicas,
+		RunAsRoot: req.RunAsRoot, WritableRootFS: req.WritableRootFS,
+	}); err != nil {
+		limitResponse(c, err)
 		return
 	}
 
+	// 没指定区域和集群时用账号的默认区域
+	if req.MainRegion == "" && req.Cluster == "" {
+		region, err := dblayer.GetUserDefaultRegion(c.Request.Context(), userUID)
+		if err != nil {
+			RequestLog(c).Warn("get default region failed", "error", err)
+		}
+		req.MainRegion = region
+	}
+
 	workerID := uuid.New().String()[:8]
 
-	if err := dblayer.CreateWorker(workerID, userUID, req.WorkerName, req.AssignedCPU, req.AssignedMemory, req.AssignedDisk, req.MaxReplicas, req.MainRegion); err != nil {
+	if err := dblayer.CreateWorker(c.Request.Context(), dblayer.DB, workerID, userUID, req.WorkerName, req.AssignedCPU, req.AssignedMemory, req.AssignedDisk, req.MaxReplicas, req.MainRegion, req.Cluster, req.Pool, req.Arch, req.RunAsRoot, req.WritableRootFS, req.Tags, req.Environment, req.Kind); err != nil {
 		c.JSON(500, gin.H{"error": "failed to create worker"})
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"worker_id":   workerID,
-		"worker_name": req.WorkerName,
+	c.JSON(200, CreateWorkerResponse{
+		WorkerID:   workerID,
+		WorkerName: req.WorkerName,
 	})
 }
 
@@ -60,44 +73,54 @@ func (h *WorkerHandler) DeleteWorker(c *gin.Context) {
 	userUID := c.GetString("user_id")
 	workerID := c.Param("id")
 
-	// 异步删 CR（可能不存在）
-	if err := SendTask(jobs.NewDeleteWorkerCRJob(workerID, userUID)); err != nil {
-		log.Printf("Failed to send delete worker CR task: %v", err)
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
 	}
-
-	// 单次操作：验证归属 + 删除
-	if err := dblayer.DeleteWorkerByOwner(workerID, userUID); err != nil {
+	if err := deleteWorker(c.Request.Context(), workerID, userUID); err != nil {
 		if err == dblayer.ErrNotFound {
 			c.JSON(404, gin.H{"error": "worker not found"})
 		} else {
-			c.JSON(500, gin.H{"error": "failed to delete worker"})
+			c.JSON(500, gin.H{"error": err.Error()})
 		}
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "worker deleted"})
+	c.JSON(200, MessageResponse{Message: "worker deleted"})
 }
 
-// ListWorkers 列出用户所有 worker
+// ListWorkers 分页列出用户的 worker，下一页游标放在 X-Next-Cursor 响应头
 func (h *WorkerHandler) ListWorkers(c *gin.Context) {
 	userUID := c.GetString("user_id")
 
-	workers, err := dblayer.ListWorkersByUser(userUID)
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	workers, next, err := dblayer.ListWorkersByUser(c.Request.Context(), userUID, opts)
+	if err == dblayer.ErrInvalidCursor {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to list workers"})
 		return
 	}
 
-	result := make([]gin.H, len(workers))
+	result := make([]WorkerSummary, len(workers))
 	for i, w := range workers {
-		result[i] = gin.H{
-			"worker_id":         w.WID,
-			"worker_name":       w.WorkerName,
-			"status":            w.Status,
-			"active_version_id": w.ActiveVersionID,
-			"url":               workerURL(w.WID, w.UserUID),
+		result[i] = WorkerSummary{
+			WorkerID:        w.WID,
+			WorkerName:      w.WorkerName,
+			Status:          w.Status,
+			ActiveVersionID: w.ActiveVersionID,
+			Environment:     w.Environment,
+			Kind:            w.Kind,
+			URL:             workerURL(w.WID, w.UserUID),
 		}
 	}
+	setNextCursor(c, next)
 	c.JSON(200, result)
 }
 
@@ -105,12 +128,18 @@ func (h *WorkerHandler) ListWorkers(c *gin.Context) {
 func (h *WorkerHandler) GetWorker(c *gin.Context) {
 	userUID := c.GetString("user_id")
 	workerID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
 
-	w, err := dblayer.GetWorkerByOwner(workerID, userUID)
+	w, err := dblayer.GetWorkerByOwner(c.Request.Context(), workerID, userUID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "worker not found"})
 		return
 	}
+	if notModified(c, w.UpdatedAt) {
+		return
+	}
 
 	offset := 0
 	if v := c.Query("offset"); v != "" {
@@ -119,54 +148,96 @@ func (h *WorkerHandler) GetWorker(c *gin.Context) {
 		}
 	}
 
-	versions, err := dblayer.ListDeployVersions(w.ID, 10, offset)
+	versions, err := dblayer.ListDeployVersions(c.Request.Context(), w.ID, 10, offset)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to list versions"})
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"worker":   w,
-		"versions": versions,
-		"url":      workerURL(w.WID, w.UserUID),
+	c.JSON(200, WorkerDetailResponse{
+		Worker:   w,
+		Versions: versions,
+		URL:      workerURL(w.WID, w.UserUID),
 	})
 }
 
 // DeployWorker 触发 worker 部署，立刻返回 200，异步执行
 func (h *WorkerHandler) DeployWorker(c *gin.Context) {
-	var req struct {
-		UserUID  string `json:"user_uid" binding:"required"`
-		WorkerID string `json:"worker_id" binding:"required"`
-		Image    string `json:"image" binding:"required"`
-		Port     int    `json:"port" binding:"required"`
+	var req DeployWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	// outer 上 user_id 来自签名，请求体里的 user_uid 必须是签名的用户；inner 只接受 outer 转来的请求
+	if signed := c.GetString("user_id"); signed != "" && signed != req.UserUID {
+		c.JSON(404, gin.H{"error": "worker not found"})
 		return
 	}
 
-	// 单次操作：验证归属 + 创建部署版本
-	versionID, err := dblayer.CreateDeployVersionForOwner(req.WorkerID, req.UserUID, req.Image, req.Port)
+	resp, err := deployWorker(c.Request.Context(), req)
 	if err != nil {
-		if err == dblayer.ErrNotFound {
-			c.JSON(404, gin.H{"error": "worker not found"})
-		} else {
-			c.JSON(500, gin.H{"error": "failed to create deploy version"})
-		}
+		writeDeployError(c, err)
 		return
 	}
+	c.JSON(200, resp)
+}
 
-	if err := SendTask(jobs.NewDeployWorkerJob(req.WorkerID, req.UserUID, versionID)); err != nil {
-		c.JSON(500, gin.H{"error": "failed to enqueue deploy task"})
+// writeDeployError deployWorker 的错误响应
+func writeDeployError(c *gin.Context, err error) {
+	var rejected *DeployRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(422, DeployRejectedResponse{Error: "deploy rejected by policy", Violations: rejected.Violations})
+	case err == errAccountSuspended:
+		c.JSON(402, gin.H{"error": err.Error()})
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+	case err == dblayer.ErrWorkerKind:
+		c.JSON(409, gin.H{"error": "static workers are deployed with POST /worker/:id/static, container workers with an image"})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// EstimateWorker 部署前估算一个 worker 配置的每月费用
+func (h *WorkerHandler) EstimateWorker(c *gin.Context) {
+	var req EstimateWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
 		return
 	}
+	if req.MainRegion == "" {
+		req.MainRegion, _ = dblayer.GetUserDefaultRegion(c.Request.Context(), c.GetString("user_id"))
+	}
+	est, err := estimateWorker(req.AssignedCPU, req.AssignedMemory, req.AssignedDisk, req.MaxReplicas, req.MainRegion)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, est)
+}
 
-	c.JSON(200, gin.H{
-		"worker_id":  req.WorkerID,
-		"version_id": versionID,
-		"status":     "loading",
-	})
+// ValidateWorkerDeploy (inner) 部署前校验：策略检查 + 对 Deployment 做 dry run
+func (h *WorkerHandler) ValidateWorkerDeploy(c *gin.Context) {
+	port, err := strconv.Atoi(c.Query("port"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid port"})
+		return
+	}
+	violations, err := controller.ValidateWorker(c.Request.Context(), c.Query("worker_id"), c.Query("user_uid"), c.Query("image"), port)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	if violations == nil {
+		violations = []k8s.Violation{}
+	}
+	c.JSON(200, ValidateWorkerResponse{Violations: violations})
 }
 
 // GetWorkerEnv 获取 worker 环境变量
@@ -174,60 +245,69 @@ func (h *WorkerHandler) GetWorkerEnv(c *gin.Context) {
 	userUID := c.GetString("user_id")
 	workerID := c.Param("id")
 
-	// 单次查询：验证归属 + 获取 env_json
-	envJSON, err := dblayer.GetWorkerEnvByOwner(workerID, userUID)
+	envMap, err := getWorkerEnv(c.Request.Context(), workerID, userUID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "worker not found"})
 		return
 	}
-
-	var envMap map[string]string
-	json.Unmarshal([]byte(envJSON), &envMap)
-	c.JSON(200, envMap)
+	c.JSON(200, maskEnv(envMap))
 }
 
-// SetWorkerEnv 设置单条 worker 环境变量（merge 到现有 env）
-func (h *WorkerHandler) SetWorkerEnv(c *gin.Context) {
+// RevealWorkerEnv 返回一条 env 的原值，记入安全事件
+func (h *WorkerHandler) RevealWorkerEnv(c *gin.Context) {
 	userUID := c.GetString("user_id")
 	workerID := c.Param("id")
 
-	var req struct {
-		Key    string `json:"key" binding:"required"`
-		Value  string `json:"value"`
-		Delete bool   `json:"delete"`
-	}
+	var req RevealEnvRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, bindError(err))
 		return
 	}
-
-	if req.Key == "COMBINATOR_API_ENDPOINT" {
-		c.JSON(400, gin.H{"error": "COMBINATOR_API_ENDPOINT is managed by the system"})
-		return
-	}
-
-	// 读取现有 env
-	envJSON, err := dblayer.GetWorkerEnvByOwner(workerID, userUID)
+	envMap, err := getWorkerEnv(c.Request.Context(), workerID, userUID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "worker not found"})
 		return
 	}
-	var envMap map[string]string
-	json.Unmarshal([]byte(envJSON), &envMap)
-	if envMap == nil {
-		envMap = map[string]string{}
+	value, ok := envMap[req.Key]
+	if !ok {
+		c.JSON(404, gin.H{"error": "env key not found"})
+		return
 	}
 
-	// merge
-	if req.Delete {
-		delete(envMap, req.Key)
-	} else {
-		envMap[req.Key] = req.Value
+	recordSecretReveal(c, userUID, "worker/"+workerID+"/env/"+req.Key)
+	c.JSON(200, RevealEnvResponse{Key: req.Key, Value: value})
+}
+
+// SetWorkerEnv 设置单条 worker 环境变量（merge 到现有 env）
+func (h *WorkerHandler) SetWorkerEnv(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req WorkerKeyValueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
 	}
 
-	data, _ := json.Marshal(envMap)
-	if err := dblayer.SetWorkerEnvByOwner(workerID, userUID, string(data)); err != nil {
-		c.JSON(500, gin.H{"error": "failed to set env"})
+	envMap, version, err := mergeWorkerEnv(c.Request.Context(), workerID, userUID, req)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err == errManagedEnvKey, err == errUnknownSecret:
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	case err == errAttachedEnvKey:
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -236,7 +316,7 @@ func (h *WorkerHandler) SetWorkerEnv(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, envMap)
+	c.JSON(200, maskEnv(envMap))
 }
 
 // GetWorkerSecrets 获取 worker secrets
@@ -245,7 +325,7 @@ func (h *WorkerHandler) GetWorkerSecrets(c *gin.Context) {
 	workerID := c.Param("id")
 
 	// 单次查询：验证归属 + 获取 secrets_json
-	secretsJSON, err := dblayer.GetWorkerSecretsByOwner(workerID, userUID)
+	secretsJSON, _, err := dblayer.GetWorkerSecretsByOwner(c.Request.Context(), workerID, userUID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "worker not found"})
 		return
@@ -261,58 +341,457 @@ func (h *WorkerHandler) SetWorkerSecrets(c *gin.Context) {
 	userUID := c.GetString("user_id")
 	workerID := c.Param("id")
 
-	var req struct {
-		Key    string `json:"key" binding:"required"`
-		Value  string `json:"value"`
-		Delete bool   `json:"delete"`
+	var req WorkerKeyValueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	keys, version, err := mergeWorkerSecrets(c.Request.Context(), workerID, userUID, req)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err == errAttachedEnvKey:
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := SendTask(jobs.NewSyncSecretJob(workerID, userUID, map[string]string{req.Key: req.Value})); err != nil {
+		c.JSON(500, gin.H{"error": "failed to enqueue sync task"})
+		return
+	}
+
+	c.JSON(200, keys)
+}
+
+// SetWorkerTags 替换 worker 标签，label 由 inner 同步到 K8s 资源
+func (h *WorkerHandler) SetWorkerTags(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	version, err := setWorkerTags(c.Request.Context(), workerID, userUID, req.Tags, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, TagsResponse{Tags: req.Tags, Version: version})
+}
+
+// GetWorkerEgress 获取 worker 出站规则，没有设置过的为 allow
+func (h *WorkerHandler) GetWorkerEgress(c *gin.Context) {
+	workerID := c.Param("id")
+
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
 	}
+	p, err := dblayer.GetWorkerEgress(c.Request.Context(), workerID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get egress rules"})
+		return
+	}
+	c.JSON(200, p)
+}
+
+// SetWorkerEgress 替换 worker 出站规则，NetworkPolicy 由 inner 同步到 K8s
+func (h *WorkerHandler) SetWorkerEgress(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetEgressRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	p, err := k8s.NormalizeEgress(dblayer.EgressPolicy{Mode: req.Mode, CIDRs: req.CIDRs, Domains: req.Domains})
+	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	version, err := setWorkerEgress(c.Request.Context(), workerID, userUID, p, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, p)
+}
+
+// GetWorkerRoutes 获取 worker 的额外路由规则
+func (h *WorkerHandler) GetWorkerRoutes(c *gin.Context) {
+	workerID := c.Param("id")
 
-	// 读取现有 secrets key 列表
-	secretsJSON, err := dblayer.GetWorkerSecretsByOwner(workerID, userUID)
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	r, err := dblayer.GetWorkerRoutes(c.Request.Context(), workerID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get route rules"})
+		return
+	}
+	c.JSON(200, r)
+}
+
+// SetWorkerRoutes 替换 worker 的额外路由规则，路由由 inner 同步到 K8s
+func (h *WorkerHandler) SetWorkerRoutes(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetRoutesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	rules, err := k8s.NormalizeRouteRules(req.Rules, controller.WorkerHost(workerID, userUID))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	version, err := setWorkerRoutes(c.Request.Context(), workerID, userUID, rules, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, dblayer.WorkerRoutes{Rules: rules})
+}
+
+// GetWorkerEdgeLimits 获取 worker 入口的限流和请求体大小，没有设置过的为 0（不限制）
+func (h *WorkerHandler) GetWorkerEdgeLimits(c *gin.Context) {
+	workerID := c.Param("id")
+
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+	l, err := dblayer.GetWorkerEdgeLimits(c.Request.Context(), workerID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to get edge limits"})
+		return
+	}
+	c.JSON(200, l)
+}
+
+// SetWorkerEdgeLimits 替换 worker 入口的限制，Middleware 由 inner 同步到 K8s
+func (h *WorkerHandler) SetWorkerEdgeLimits(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	var req SetEdgeLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
+		return
+	}
+	l, err := k8s.NormalizeEdgeLimits(dblayer.EdgeLimits{RateLimit: req.RateLimit, Burst: req.Burst, MaxBodyBytes: req.MaxBodyBytes})
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkWorkerPrecondition(c, workerID, userUID) {
+		return
+	}
+
+	version, err := setWorkerEdgeLimits(c.Request.Context(), workerID, userUID, l, req.ExpectedVersion)
+	switch {
+	case err == dblayer.ErrNotFound:
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	case err == dblayer.ErrVersionConflict:
+		versionConflict(c, version)
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, l)
+}
+
+// checkWorkerPrecondition 写 worker 前校验 If-Match，不满足时已写好响应并返回 false
+func checkWorkerPrecondition(c *gin.Context, workerID, userUID string) bool {
+	if !hasPrecondition(c) {
+		return true
+	}
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return false
+	}
+	w, err := dblayer.GetWorkerByOwner(c.Request.Context(), workerID, userUID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "worker not found"})
+		return false
+	}
+	return !preconditionFailed(c, w.UpdatedAt)
+}
+
+// BulkWorkers 对多个 worker 执行同一操作：先逐个校验归属并写库，再合并成一个批量任务投递，
+// 由 inner 限制并发执行。响应给出每个 worker 是否已入队，执行结果通过事件流推送
+func (h *WorkerHandler) BulkWorkers(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	var req BulkWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, bindError(err))
 		return
 	}
-	var keys []string
-	json.Unmarshal([]byte(secretsJSON), &keys)
+	// 欠费暂停期间不能自己恢复，付清后由 dunning 任务恢复
+	if req.Action == jobs.BulkActionResume {
+		if suspended, err := dblayer.WorkersSuspended(c.Request.Context(), userUID); err == nil && suspended {
+			c.JSON(402, gin.H{"error": errAccountSuspended.Error()})
+			return
+		}
+	}
 
-	if req.Delete {
-		// 删除 key
-		filtered := keys[:0]
-		for _, k := range keys {
-			if k != req.Key {
-				filtered = append(filtered, k)
+	resp := BulkWorkerResponse{Action: req.Action, Results: make([]BulkWorkerResult, 0, len(req.WorkerIDs))}
+	var items []jobs.BulkWorkerItem
+	seen := make(map[string]bool, len(req.WorkerIDs))
+	for _, workerID := range req.WorkerIDs {
+		if seen[workerID] {
+			continue
+		}
+		seen[workerID] = true
+
+		item, err := prepareBulkItem(c.Request.Context(), req, workerID, userUID)
+		if err != nil {
+			msg := err.Error()
+			if err == dblayer.ErrNotFound {
+				msg = "worker not found"
 			}
+			resp.Results = append(resp.Results, BulkWorkerResult{WorkerID: workerID, Status: "error", Error: msg})
+			continue
+		}
+		items = append(items, item)
+		resp.Results = append(resp.Results, BulkWorkerResult{WorkerID: workerID, Status: "queued"})
+	}
+
+	if len(items) > 0 {
+		if err := SendTask(jobs.NewBulkWorkerJob(userUID, req.Action, items)); err != nil {
+			c.JSON(500, gin.H{"error": "failed to enqueue bulk task"})
+			return
+		}
+	}
+	resp.Queued = len(items)
+	c.JSON(200, resp)
+}
+
+// UnpauseWorker 恢复因长期没有流量、或因反复崩溃被自动暂停的 worker，空闲计时从现在重新开始
+func (h *WorkerHandler) UnpauseWorker(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
+		return
+	}
+
+	w, err := dblayer.GetWorkerByOwner(c.Request.Context(), workerID, userUID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "worker not found"})
+		return
+	}
+	if w.Status != jobs.WorkerStatusIdle && w.Status != jobs.WorkerStatusCrashPaused {
+		c.JSON(409, gin.H{"error": "worker is not paused for inactivity or crash looping"})
+		return
+	}
+	if suspended, err := dblayer.WorkersSuspended(c.Request.Context(), userUID); err == nil && suspended {
+		c.JSON(402, gin.H{"error": errAccountSuspended.Error()})
+		return
+	}
+	// 先清掉暂停时间，避免恢复任务执行前被空闲回收删除
+	if err := dblayer.TouchWorkerTraffic(c.Request.Context(), workerID, time.Now()); err != nil {
+		RequestLog(c).Error("reset idle tracking failed", "worker_id", workerID, "error", err)
+		c.JSON(500, gin.H{"error": "failed to unpause worker"})
+		return
+	}
+	items := []jobs.BulkWorkerItem{{WorkerID: workerID}}
+	if err := SendTask(jobs.NewBulkWorkerJob(userUID, jobs.BulkActionResume, items)); err != nil {
+		c.JSON(500, gin.H{"error": "failed to enqueue resume task"})
+		return
+	}
+	c.JSON(200, MessageResponse{Message: "worker unpause queued"})
+}
+
+// prepareBulkItem 用 AuthorizeResource 校验归属并完成库里的修改（set_env 合并 env，delete 删除记录）
+func prepareBulkItem(ctx context.Context, req BulkWorkerRequest, workerID, userUID string) (jobs.BulkWorkerItem, error) {
+	item := jobs.BulkWorkerItem{WorkerID: workerID}
+	if err := dblayer.AuthorizeResource(ctx, userUID, dblayer.ResourceWorker, workerID); err != nil {
+		if err != dblayer.ErrNotFound {
+			err = errors.New("failed to check worker ownership")
+		}
+		return item, err
+	}
+	switch req.Action {
+	case jobs.BulkActionSetEnv:
+		// 各 worker 的版本不同，批量修改不检查 expected_version
+		kv := *req.Env
+		kv.ExpectedVersion = 0
+		env, _, err := mergeWorkerEnv(ctx, workerID, userUID, kv)
+		if err != nil {
+			return item, err
 		}
-		keys = filtered
-	} else {
-		// 去重追加
-		found := false
-		for _, k := range keys {
-			if k == req.Key {
-				found = true
-				break
+		item.Env = env
+	case jobs.BulkActionDelete:
+		if err := dblayer.DeleteWorkerByOwner(ctx, dblayer.DB, workerID, userUID); err != nil {
+			if err != dblayer.ErrNotFound {
+				err = errors.New("failed to delete worker")
 			}
+			return item, err
+		}
+	case jobs.BulkActionResume:
+		w, err := dblayer.GetWorkerByOwner(ctx, workerID, userUID)
+		if err != nil {
+			return item, dblayer.ErrNotFound
 		}
-		if !found {
-			keys = append(keys, req.Key)
+		// 花费上限暂停的 worker 只能通过调高上限或放行恢复
+		if w.Status == jobs.WorkerStatusCapped {
+			return item, errors.New("paused by the spending cap, raise it or override it for this month")
 		}
 	}
+	return item, nil
+}
 
-	keysData, _ := json.Marshal(keys)
-	if err := dblayer.SetWorkerSecretsByOwner(workerID, userUID, string(keysData)); err != nil {
-		c.JSON(500, gin.H{"error": "failed to set secrets"})
+// WorkerStatus (inner) 读取 worker 在所在集群中的运行状态。本集群的 worker 只读 informer 缓存，
+// 不访问 apiserver；远程集群的 worker 直接查询该集群。缓存未同步完成时返回 503
+func (h *WorkerHandler) WorkerStatus(c *gin.Context) {
+	workerID := c.Query("worker_id")
+	userUID := c.Query("user_uid")
+	if workerID == "" || userUID == "" {
+		c.JSON(400, gin.H{"error": "worker_id and user_uid are required"})
 		return
 	}
+	cluster, err := controller.ClusterOf(c.Request.Context(), workerID, userUID)
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	status, err := controller.WorkerStatus(c.Request.Context(), cluster, workerID, userUID)
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, status)
+}
 
-	if err := SendTask(jobs.NewSyncSecretJob(workerID, userUID, map[string]string{req.Key: req.Value})); err != nil {
-		c.JSON(500, gin.H{"error": "failed to enqueue sync task"})
+// GetWorkerStatus (outer) 校验归属后向 inner 查询 worker 的运行状态
+func (h *WorkerHandler) GetWorkerStatus(c *gin.Context) {
+	userUID := c.GetString("user_id")
+	workerID := c.Param("id")
+
+	if !authorizeResource(c, dblayer.ResourceWorker, workerID) {
 		return
 	}
 
-	c.JSON(200, keys)
+	status, err := fetchWorkerStatus(c.Request.Context(), workerID, userUID)
+	if err != nil {
+		RequestLog(c).Warn("fetch worker status failed", "worker_id", workerID, "error", err)
+		c.JSON(503, gin.H{"error": "worker status unavailable"})
+		return
+	}
+	c.JSON(200, status)
+}
+
+// fetchWorkerStatus (outer) 调用 inner 的 /worker/status
+func fetchWorkerStatus(ctx context.Context, workerID, userUID string) (*controller.WorkerRuntimeStatus, error) {
+	q := url.Values{"worker_id": {workerID}, "user_uid": {userUID}}
+	return getInner[controller.WorkerRuntimeStatus](ctx, "/worker/status", q)
+}
+
+// WorkerNodePools (inner) 从各集群的节点标签汇总可选的节点池
+func (h *WorkerHandler) WorkerNodePools(c *gin.Context) {
+	pools, err := k8s.ListNodePools(c.Request.Context())
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, NodePoolsResponse{Pools: pools})
+}
+
+// ListNodePools (outer) 列出创建 worker 时可选的节点池及其容量和价格
+func (h *WorkerHandler) ListNodePools(c *gin.Context) {
+	resp, err := getInner[NodePoolsResponse](c.Request.Context(), "/worker/pools", nil)
+	if err != nil {
+		RequestLog(c).Warn("fetch node pools failed", "error", err)
+		c.JSON(503, gin.H{"error": "node pools unavailable"})
+		return
+	}
+	c.JSON(200, resp)
+}
+
+// WorkerRegions (inner) 从各集群的节点标签汇总可选的区域及其容量
+func (h *WorkerHandler) WorkerRegions(c *gin.Context) {
+	regions, err := k8s.ListRegions(c.Request.Context())
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, RegionsResponse{Regions: regions})
+}
+
+// ListRegions (outer) 列出 main_region 可选的区域、各区域的剩余容量和账号的默认区域
+func (h *WorkerHandler) ListRegions(c *gin.Context) {
+	ctx := c.Request.Context()
+	resp, err := getInner[RegionsResponse](ctx, "/regions", nil)
+	if err != nil {
+		RequestLog(c).Warn("fetch regions failed", "error", err)
+		c.JSON(503, gin.H{"error": "regions unavailable"})
+		return
+	}
+	if resp.DefaultRegion, err = dblayer.GetUserDefaultRegion(ctx, c.GetString("user_id")); err != nil {
+		RequestLog(c).Warn("get default region failed", "error", err)
+	}
+	c.JSON(200, resp)
+}
+
+// CapacityDashboard (inner, admin) 各集群按 region 和节点汇总的可分配、已请求和剩余资源
+func CapacityDashboard(c *gin.Context) {
+	report, err := k8s.GetCapacityReport(c.Request.Context())
+	if err != nil {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, report)
 }
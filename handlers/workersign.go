@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// worker 直接调用 inner（/combinator/resolveDSN）时用 env 里的 RAYSAIL_UID / RAYSAIL_SECRET_KEY 签名：
+// 签名覆盖 时间戳、nonce、method、path 和 query，密钥是 owner 的 secret key，
+// 时间窗口和 nonce 规则与任务签名相同。secret key 本身不经过网络。
+// 响应同样用 secret key 签名并带过期时间，worker 据此确认 DSN 来自控制面，过期后重新获取
+
+// worker 签名相关的请求头
+const (
+	WorkerUIDHeader       = "X-Raysail-Uid"
+	WorkerTimestampHeader = "X-Raysail-Timestamp"
+	WorkerNonceHeader     = "X-Raysail-Nonce"
+	WorkerSignatureHeader = "X-Raysail-Signature"
+)
+
+// DSNResponseTTL resolveDSN 响应的有效期，worker 应在过期前重新获取
+const DSNResponseTTL = 5 * time.Minute
+
+// 签名通过后存入 context 的 owner UID 和 secret key
+const (
+	workerUIDKey       = "worker_uid"
+	workerSecretKeyKey = "worker_secret_key"
+)
+
+var workerNonces = &nonceCache{seen: map[string]time.Time{}}
+
+// workerSignature 计算 worker 请求签名（hex）
+func workerSignature(secretKey, timestamp, nonce, method, path, query string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", timestamp, nonce, method, path, query)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dsnSignature 计算 resolveDSN 响应签名（hex）：user_uid、expires_at（unix 秒），
+// 再按返回顺序每个资源一行 type:id=dsn
+func dsnSignature(secretKey string, resp *ResolveDSNResponse) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	fmt.Fprintf(mac, "%s\n%d\n", resp.UserUID, resp.ExpiresAt.Unix())
+	for _, r := range resp.Resources {
+		fmt.Fprintf(mac, "%s:%s=%s\n", r.ResourceType, r.ResourceID, r.DSN)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWorkerSignature (inner) 校验 worker 用 owner secret key 的签名并拒绝过期和重放的请求，
+// 通过后把 owner UID 存入 context；带 worker 证书时 UID 必须是证书的 owner
+func VerifyWorkerSignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reject := func(reason string) {
+			RequestLog(c).Warn("worker signature rejected", "reason", reason, "uid", c.GetHeader(WorkerUIDHeader))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": reason})
+		}
+
+		uid := c.GetHeader(WorkerUIDHeader)
+		timestamp := c.GetHeader(WorkerTimestampHeader)
+		nonce := c.GetHeader(WorkerNonceHeader)
+		signature := c.GetHeader(WorkerSignatureHeader)
+		if uid == "" || timestamp == "" || nonce == "" || signature == "" {
+			reject("missing worker signature")
+			return
+		}
+		if owner := callerOwner(c); owner != "" && owner != uid {
+			RequestLog(c).Warn("worker signed for another user", "owner", owner, "uid", uid)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "client certificate does not belong to this user"})
+			return
+		}
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		now := time.Now()
+		if err != nil || now.Sub(time.Unix(ts, 0)).Abs() > TaskSignatureMaxSkew {
+			reject("worker timestamp is outside the allowed window")
+			return
+		}
+		if !taskNoncePattern.MatchString(nonce) {
+			reject("invalid worker nonce")
+			return
+		}
+
+		secretKey, err := dblayer.GetUserSecretKey(c.Request.Context(), uid)
+		if errors.Is(err, sql.ErrNoRows) {
+			// 和签名错误不作区分，不暴露 UID 是否存在
+			reject("invalid worker signature")
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get secret key"})
+			return
+		}
+		want := workerSignature(secretKey, timestamp, nonce, c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery)
+		if !hmac.Equal([]byte(want), []byte(strings.ToLower(signature))) {
+			reject("invalid worker signature")
+			return
+		}
+		if !workerNonces.add(nonce, now) {
+			reject("worker nonce was already used")
+			return
+		}
+		c.Set(workerUIDKey, uid)
+		c.Set(workerSecretKeyKey, secretKey)
+		c.Next()
+	}
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s/controller"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket 多路复用：一条连接上订阅多个频道，避免每个面板各开一条连接。
+//
+// 子协议 console.v1，每帧一个 JSON 文本消息。
+// 客户端 -> 服务端：
+//
+//	{"op":"subscribe","channel":"logs:<worker_id>","tail":100}
+//	{"op":"unsubscribe","channel":"logs:<worker_id>"}
+//	{"op":"ping"}
+//
+// 服务端 -> 客户端：
+//
+//	{"type":"subscribed","channel":"..."}
+//	{"type":"unsubscribed","channel":"..."}
+//	{"type":"data","channel":"...","data":{...}}
+//	{"type":"error","channel":"...","error":"..."}
+//	{"type":"pong"}
+//
+// 频道：
+//
+//	events              当前用户的全部事件（与 SSE 相同）
+//	jobs                任务完成事件
+//	worker:<worker_id>  该 worker 的部署/状态事件
+//	logs:<worker_id>    该 worker 所有 pod 的日志，data 为 {"pod","line"}
+const WSSubprotocol = "console.v1"
+
+const (
+	wsChannelEvents = "events"
+	wsChannelJobs   = "jobs"
+	wsPrefixWorker  = "worker:"
+	wsPrefixLogs    = "logs:"
+
+	wsMaxChannels   = 32
+	wsMaxLogStreams = 4
+	wsMaxFrameSize  = 4096
+
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 25 * time.Second
+)
+
+// WSClientFrame 客户端发来的帧
+type WSClientFrame struct {
+	Op      string `json:"op"` // subscribe | unsubscribe | ping
+	Channel string `json:"channel,omitempty"`
+	Tail    *int64 `json:"tail,omitempty"` // 仅 logs:，默认 DefaultLogTail
+}
+
+// WSServerFrame 服务端下发的帧
+type WSServerFrame struct {
+	Type    string `json:"type"` // subscribed | unsubscribed | data | error | pong
+	Channel string `json:"channel,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// WSHandler 处理 /ws 连接，事件来自 EventBus，日志经 inner 转发
+type WSHandler struct {
+	bus      *dblayer.EventBus
+	upgrader websocket.Upgrader
+}
+
+// NewWSHandler 跨域握手按 CORS 白名单校验 Origin，未配置时只允许同源
+func NewWSHandler(bus *dblayer.EventBus, cors CORSConfig) *WSHandler {
+	return &WSHandler{
+		bus: bus,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{WSSubprotocol},
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				if origin == "" {
+					return true
+				}
+				if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+					return true
+				}
+				return cors.allows(origin)
+			},
+		},
+	}
+}
+
+// wsSession 一条连接的状态。所有写操作都经过 out，由 writeLoop 串行写出
+type wsSession struct {
+	userUID string
+	ctx     context.Context
+	out     chan WSServerFrame
+
+	mu     sync.Mutex
+	events map[string]bool         // 已订阅的事件频道
+	logs   map[string]*wsLogStream // 已订阅的日志频道
+}
+
+type wsLogStream struct {
+	cancel context.CancelFunc
+}
+
+// Serve 升级为 WebSocket 并处理订阅，直到连接断开
+func (h *WSHandler) Serve(c *gin.Context) {
+	userUID := c.GetString("user_id")
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 已经写好了错误响应
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &wsSession{
+		userUID: userUID,
+		ctx:     ctx,
+		out:     make(chan WSServerFrame, 64),
+		events:  make(map[string]bool),
+		logs:    make(map[string]*wsLogStream),
+	}
+
+	ch := h.bus.Subscribe(userUID)
+	defer h.bus.Unsubscribe(ch)
+
+	go s.writeLoop(conn, cancel)
+	go s.eventLoop(ch)
+
+	conn.SetReadLimit(wsMaxFrameSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				RequestLog(c).Warn("websocket closed unexpectedly", "error", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		var f WSClientFrame
+		if err := json.Unmarshal(msg, &f); err != nil {
+			s.send(WSServerFrame{Type: "error", Error: "malformed frame"})
+			continue
+		}
+		s.handle(f)
+	}
+}
+
+func (s *wsSession) handle(f WSClientFrame) {
+	switch f.Op {
+	case "ping":
+		s.send(WSServerFrame{Type: "pong"})
+	case "subscribe":
+		if err := s.subscribe(f); err != "" {
+			s.send(WSServerFrame{Type: "error", Channel: f.Channel, Error: err})
+			return
+		}
+		s.send(WSServerFrame{Type: "subscribed", Channel: f.Channel})
+	case "unsubscribe":
+		s.unsubscribe(f.Channel)
+		s.send(WSServerFrame{Type: "unsubscribed", Channel: f.Channel})
+	default:
+		s.send(WSServerFrame{Type: "error", Channel: f.Channel, Error: "op must be subscribe, unsubscribe or ping"})
+	}
+}
+
+// subscribe 返回错误描述，成功时为空
+func (s *wsSession) subscribe(f WSClientFrame) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.events[f.Channel] || s.logs[f.Channel] != nil {
+		return ""
+	}
+	if len(s.events)+len(s.logs) >= wsMaxChannels {
+		return "too many subscriptions"
+	}
+
+	switch {
+	case f.Channel == wsChannelEvents || f.Channel == wsChannelJobs:
+	case strings.HasPrefix(f.Channel, wsPrefixWorker):
+		if err := dblayer.AuthorizeResource(s.ctx, s.userUID, dblayer.ResourceWorker, strings.TrimPrefix(f.Channel, wsPrefixWorker)); err != nil {
+			return "worker not found"
+		}
+	case strings.HasPrefix(f.Channel, wsPrefixLogs):
+		workerID := strings.TrimPrefix(f.Channel, wsPrefixLogs)
+		if len(s.logs) >= wsMaxLogStreams {
+			return "too many log streams"
+		}
+		if err := dblayer.AuthorizeResource(s.ctx, s.userUID, dblayer.ResourceWorker, workerID); err != nil {
+			return "worker not found"
+		}
+		tail := int64(DefaultLogTail)
+		if f.Tail != nil {
+			tail = *f.Tail
+		}
+		if tail < 0 || tail > MaxLogTail {
+			return "tail out of range"
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		stream := &wsLogStream{cancel: cancel}
+		s.logs[f.Channel] = stream
+		go s.logLoop(ctx, stream, f.Channel, workerID, tail)
+		return ""
+	default:
+		return "unknown channel"
+	}
+	s.events[f.Channel] = true
+	return ""
+}
+
+func (s *wsSession) unsubscribe(channel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, channel)
+	if stream, ok := s.logs[channel]; ok {
+		stream.cancel()
+		delete(s.logs, channel)
+	}
+}
+
+// matches 事件会投递到哪些已订阅的频道
+func (s *wsSession) matches(ev dblayer.Event) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	if s.events[wsChannelEvents] {
+		out = append(out, wsChannelEvents)
+	}
+	if ev.Kind == dblayer.EventJobFinished && s.events[wsChannelJobs] {
+		out = append(out, wsChannelJobs)
+	}
+	if ev.ResourceType == "worker" && s.events[wsPrefixWorker+ev.ResourceID] {
+		out = append(out, wsPrefixWorker+ev.ResourceID)
+	}
+	return out
+}
+
+func (s *wsSession) eventLoop(ch chan dblayer.Event) {
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			for _, channel := range s.matches(ev) {
+				s.send(WSServerFrame{Type: "data", Channel: channel, Data: ev})
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// logLoop 把 inner 的 NDJSON 日志流逐行转发；流自行结束（如 pod 重建）时以 unsubscribed 通知客户端
+func (s *wsSession) logLoop(ctx context.Context, stream *wsLogStream, channel, workerID string, tail int64) {
+	defer func() {
+		stream.cancel()
+		s.mu.Lock()
+		// 客户端可能已经取消并重新订阅了同一频道，只清理自己的那一项
+		active := s.logs[channel] == stream
+		if active {
+			delete(s.logs, channel)
+		}
+		s.mu.Unlock()
+		if active {
+			s.send(WSServerFrame{Type: "unsubscribed", Channel: channel})
+		}
+	}()
+
+	body, err := openWorkerLogs(ctx, workerID, s.userUID, tail)
+	if err != nil {
+		s.send(WSServerFrame{Type: "error", Channel: channel, Error: err.Error()})
+		return
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line controller.LogLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		if !s.send(WSServerFrame{Type: "data", Channel: channel, Data: line}) {
+			return
+		}
+	}
+}
+
+// send 投递到写队列，连接已关闭时返回 false
+func (s *wsSession) send(f WSServerFrame) bool {
+	select {
+	case s.out <- f:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// writeLoop 唯一的写端：下发帧并定期 ping，写失败时结束整个会话
+func (s *wsSession) writeLoop(conn *websocket.Conn, cancel context.CancelFunc) {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer cancel()
+	defer conn.Close()
+
+	for {
+		select {
+		case f := <-s.out:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(f); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		case <-s.ctx.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteTimeout))
+			return
+		case <-Draining():
+			// 1001 提示客户端重连（会落到其他实例）
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteTimeout))
+			return
+		}
+	}
+}
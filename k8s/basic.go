@@ -1,6 +1,8 @@
 package k8s
 
 import (
+	"jabberwocky238/console/metrics"
+
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
@@ -9,7 +11,7 @@ import (
 )
 
 var (
-	K8sClient           *kubernetes.Clientset
+	K8sClient           kubernetes.Interface
 	DynamicClient       dynamic.Interface
 	RestConfig          *rest.Config
 	Domain              string
@@ -25,6 +27,8 @@ var (
 
 	ControlPlaneInnerEndpoint = "http://control-plane-inner.console.svc.cluster.local:9901"
 	ControlPlaneOuterEndpoint = "http://control-plane-outer.console.svc.cluster.local:9900"
+	LokiEndpoint              = "http://loki.console.svc.cluster.local:3100"          // worker log store (env LOKI_URL)
+	PrometheusEndpoint        = "http://prometheus.monitoring.svc.cluster.local:9090" // ingress metrics for alerts (env PROMETHEUS_URL)
 
 	RDBManager *RootRDBManager
 )
@@ -58,12 +62,19 @@ func InitK8s(kubeconfig string) error {
 	}
 
 	RestConfig = config
+	metrics.RegisterK8sClient()
 
-	K8sClient, err = kubernetes.NewForConfig(config)
+	// assigned through a typed variable: a nil *Clientset in K8sClient would not compare equal to nil
+	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return err
 	}
+	K8sClient = client
 
 	DynamicClient, err = dynamic.NewForConfig(config)
-	return err
+	if err != nil {
+		return err
+	}
+	RegisterLocalCluster()
+	return nil
 }
@@ -0,0 +1,357 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RegionLabel is the well-known node label MainRegion selects on
+const RegionLabel = "topology.kubernetes.io/region"
+
+// ErrInsufficientCapacity is returned by CheckCapacity when no node fits
+var ErrInsufficientCapacity = errors.New("insufficient capacity")
+
+// ErrUnknownRegion is returned by CheckRegion when no node is in the region
+var ErrUnknownRegion = errors.New("unknown region")
+
+// NodeCapacity is what one node can still take: allocatable minus the
+// requests of the pods that are bound to it and not finished
+type NodeCapacity struct {
+	Cluster           string `json:"cluster"`
+	Name              string `json:"name"`
+	Region            string `json:"region,omitempty"`
+	Pool              string `json:"pool,omitempty"`
+	Arch              string `json:"arch,omitempty"`
+	Schedulable       bool   `json:"schedulable"` // ready and not cordoned
+	AllocatableCPU    string `json:"allocatable_cpu"`
+	AllocatableMemory string `json:"allocatable_memory"`
+	RequestedCPU      string `json:"requested_cpu"`
+	RequestedMemory   string `json:"requested_memory"`
+	FreeCPU           string `json:"free_cpu"`
+	FreeMemory        string `json:"free_memory"`
+
+	node                *corev1.Node
+	requested           corev1.ResourceList
+	freeCPU, freeMemory resource.Quantity
+}
+
+// RegionCapacity sums the schedulable nodes of one region of a cluster
+type RegionCapacity struct {
+	Cluster           string `json:"cluster"`
+	Region            string `json:"region"` // empty for nodes without RegionLabel
+	Nodes             int    `json:"nodes"`
+	SchedulableNodes  int    `json:"schedulable_nodes"`
+	AllocatableCPU    string `json:"allocatable_cpu"`
+	AllocatableMemory string `json:"allocatable_memory"`
+	RequestedCPU      string `json:"requested_cpu"`
+	RequestedMemory   string `json:"requested_memory"`
+	FreeCPU           string `json:"free_cpu"`
+	FreeMemory        string `json:"free_memory"`
+	// The largest single pod that still fits, which is what a deploy needs
+	LargestFreeCPU    string `json:"largest_free_cpu"`
+	LargestFreeMemory string `json:"largest_free_memory"`
+}
+
+// CapacityReport is the capacity of every registered cluster, by region and by node
+type CapacityReport struct {
+	Regions []RegionCapacity `json:"regions"`
+	Nodes   []NodeCapacity   `json:"nodes"`
+}
+
+// ClusterCapacity lists the nodes of c with their free resources
+func ClusterCapacity(ctx context.Context, c *Cluster) ([]NodeCapacity, error) {
+	nodes, err := c.Kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+	pods, err := c.Kube.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	requested := map[string]corev1.ResourceList{}
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if p.Spec.NodeName == "" {
+			continue
+		}
+		sum := requested[p.Spec.NodeName]
+		if sum == nil {
+			sum = corev1.ResourceList{}
+			requested[p.Spec.NodeName] = sum
+		}
+		for name, q := range podRequests(p) {
+			v := sum[name]
+			v.Add(q)
+			sum[name] = v
+		}
+	}
+
+	out := make([]NodeCapacity, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		n := &nodes.Items[i]
+		alloc := n.Status.Allocatable
+		req := requested[n.Name]
+		nc := NodeCapacity{
+			Cluster:     c.Name,
+			Name:        n.Name,
+			Region:      n.Labels[RegionLabel],
+			Pool:        n.Labels[PoolLabel],
+			Arch:        n.Labels[ArchLabel],
+			Schedulable: nodeReady(n) && !n.Spec.Unschedulable,
+			node:        n,
+			requested:   req,
+		}
+		nc.freeCPU = freeOf(alloc[corev1.ResourceCPU], req[corev1.ResourceCPU])
+		nc.freeMemory = freeOf(alloc[corev1.ResourceMemory], req[corev1.ResourceMemory])
+		nc.AllocatableCPU = alloc.Cpu().String()
+		nc.AllocatableMemory = alloc.Memory().String()
+		nc.RequestedCPU = req.Cpu().String()
+		nc.RequestedMemory = req.Memory().String()
+		nc.FreeCPU = nc.freeCPU.String()
+		nc.FreeMemory = nc.freeMemory.String()
+		out = append(out, nc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// GetCapacityReport builds the capacity report of every registered cluster.
+// A cluster that cannot be listed is skipped so the others are still returned.
+func GetCapacityReport(ctx context.Context) (*CapacityReport, error) {
+	clusters := Clusters.List()
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no cluster registered")
+	}
+	report := &CapacityReport{Regions: []RegionCapacity{}, Nodes: []NodeCapacity{}}
+	for _, c := range clusters {
+		nodes, err := ClusterCapacity(ctx, c)
+		if err != nil {
+			clusterLog.Warn("capacity of cluster failed", "cluster", c.Name, "error", err)
+			continue
+		}
+		report.Nodes = append(report.Nodes, nodes...)
+		report.Regions = append(report.Regions, regionCapacity(c.Name, nodes)...)
+	}
+	return report, nil
+}
+
+// regionCapacity groups the nodes of one cluster by region
+func regionCapacity(cluster string, nodes []NodeCapacity) []RegionCapacity {
+	type totals struct {
+		region                            RegionCapacity
+		allocCPU, allocMemory             resource.Quantity
+		reqCPU, reqMemory                 resource.Quantity
+		freeCPU, freeMemory               resource.Quantity
+		largestFreeCPU, largestFreeMemory resource.Quantity
+	}
+	byRegion := map[string]*totals{}
+	for i := range nodes {
+		n := &nodes[i]
+		t := byRegion[n.Region]
+		if t == nil {
+			t = &totals{region: RegionCapacity{Cluster: cluster, Region: n.Region}}
+			byRegion[n.Region] = t
+		}
+		t.region.Nodes++
+		if !n.Schedulable {
+			continue
+		}
+		t.region.SchedulableNodes++
+		t.allocCPU.Add(n.node.Status.Allocatable[corev1.ResourceCPU])
+		t.allocMemory.Add(n.node.Status.Allocatable[corev1.ResourceMemory])
+		t.reqCPU.Add(n.requested[corev1.ResourceCPU])
+		t.reqMemory.Add(n.requested[corev1.ResourceMemory])
+		t.freeCPU.Add(n.freeCPU)
+		t.freeMemory.Add(n.freeMemory)
+		if n.freeCPU.Cmp(t.largestFreeCPU) > 0 {
+			t.largestFreeCPU = n.freeCPU.DeepCopy()
+		}
+		if n.freeMemory.Cmp(t.largestFreeMemory) > 0 {
+			t.largestFreeMemory = n.freeMemory.DeepCopy()
+		}
+	}
+
+	out := make([]RegionCapacity, 0, len(byRegion))
+	for _, t := range byRegion {
+		r := t.region
+		r.AllocatableCPU, r.AllocatableMemory = t.allocCPU.String(), t.allocMemory.String()
+		r.RequestedCPU, r.RequestedMemory = t.reqCPU.String(), t.reqMemory.String()
+		r.FreeCPU, r.FreeMemory = t.freeCPU.String(), t.freeMemory.String()
+		r.LargestFreeCPU, r.LargestFreeMemory = t.largestFreeCPU.String(), t.largestFreeMemory.String()
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Region < out[j].Region })
+	return out
+}
+
+// ListRegions is the capacity of every region of every registered cluster,
+// leaving out nodes without RegionLabel, which no MainRegion selects. A
+// cluster whose capacity cannot be read is skipped.
+func ListRegions(ctx context.Context) ([]RegionCapacity, error) {
+	report, err := GetCapacityReport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	regions := make([]RegionCapacity, 0, len(report.Regions))
+	for _, r := range report.Regions {
+		if r.Region != "" {
+			regions = append(regions, r)
+		}
+	}
+	return regions, nil
+}
+
+// CheckRegion reports whether some node of clusters is labelled with region.
+// Without one the error wraps ErrUnknownRegion and lists the regions there
+// are; a Deployment requiring it would stay Pending forever. Other errors
+// mean the nodes could not be listed.
+func CheckRegion(ctx context.Context, clusters []*Cluster, region string) error {
+	var known []string
+	for _, c := range clusters {
+		nodes, err := c.Kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: RegionLabel})
+		if err != nil {
+			return fmt.Errorf("cluster %s: %w", c.Name, err)
+		}
+		for i := range nodes.Items {
+			r := nodes.Items[i].Labels[RegionLabel]
+			if r == region {
+				return nil
+			}
+			if !slices.Contains(known, r) {
+				known = append(known, r)
+			}
+		}
+	}
+	if len(known) == 0 {
+		return fmt.Errorf("%w %q, no node has a region label", ErrUnknownRegion, region)
+	}
+	sort.Strings(known)
+	return fmt.Errorf("%w %q, available: %s", ErrUnknownRegion, region, strings.Join(known, ", "))
+}
+
+// CheckCapacity reports whether one pod requesting cpu and memory fits on a
+// schedulable node of clusters that matches region, pool and arch (any when
+// empty). Without such a node the error wraps ErrInsufficientCapacity and
+// reads "insufficient capacity in region X, available: ..." with the best the
+// matching nodes still offer; other errors mean capacity could not be read.
+func CheckCapacity(ctx context.Context, clusters []*Cluster, region, pool, arch, cpu, memory string) error {
+	wantCPU, err := resource.ParseQuantity(cpu)
+	if err != nil {
+		return fmt.Errorf("cpu %q: %w", cpu, err)
+	}
+	wantMemory, err := resource.ParseQuantity(memory)
+	if err != nil {
+		return fmt.Errorf("memory %q: %w", memory, err)
+	}
+
+	var matching int
+	var largestCPU, largestMemory resource.Quantity
+	for _, c := range clusters {
+		nodes, err := ClusterCapacity(ctx, c)
+		if err != nil {
+			return fmt.Errorf("cluster %s: %w", c.Name, err)
+		}
+		for i := range nodes {
+			n := &nodes[i]
+			if !n.Schedulable || !nodeMatches(n.node, region, pool, arch) {
+				continue
+			}
+			if n.freeCPU.Cmp(wantCPU) >= 0 && n.freeMemory.Cmp(wantMemory) >= 0 {
+				return nil
+			}
+			matching++
+			if n.freeCPU.Cmp(largestCPU) > 0 {
+				largestCPU = n.freeCPU.DeepCopy()
+			}
+			if n.freeMemory.Cmp(largestMemory) > 0 {
+				largestMemory = n.freeMemory.DeepCopy()
+			}
+		}
+	}
+
+	where := "region " + region
+	if region == "" {
+		where = "any region"
+	}
+	if pool != "" {
+		where += ", pool " + pool
+	}
+	if arch != "" {
+		where += ", arch " + arch
+	}
+	if matching == 0 {
+		return fmt.Errorf("%w in %s, available: no schedulable node", ErrInsufficientCapacity, where)
+	}
+	return fmt.Errorf("%w in %s for cpu %s memory %s, available: at most cpu %s and memory %s free on one of %d nodes",
+		ErrInsufficientCapacity, where, cpu, memory, largestCPU.String(), largestMemory.String(), matching)
+}
+
+// nodeMatches mirrors the scheduling constraints of a worker Deployment:
+// region affinity, the pool and arch nodeSelector and the pool toleration
+func nodeMatches(n *corev1.Node, region, pool, arch string) bool {
+	if region != "" && n.Labels[RegionLabel] != region {
+		return false
+	}
+	if pool != "" && n.Labels[PoolLabel] != pool {
+		return false
+	}
+	if arch != "" && n.Labels[ArchLabel] != arch {
+		return false
+	}
+	for _, t := range n.Spec.Taints {
+		if t.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if pool != "" && t.Key == PoolLabel && t.Value == pool && t.Effect == corev1.TaintEffectNoSchedule {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// podRequests is what the scheduler accounts for a pod: the containers'
+// requests summed, at least the largest init container's, plus overhead
+func podRequests(p *corev1.Pod) corev1.ResourceList {
+	out := corev1.ResourceList{}
+	for _, c := range p.Spec.Containers {
+		for name, q := range c.Resources.Requests {
+			v := out[name]
+			v.Add(q)
+			out[name] = v
+		}
+	}
+	for _, c := range p.Spec.InitContainers {
+		for name, q := range c.Resources.Requests {
+			if v := out[name]; q.Cmp(v) > 0 {
+				out[name] = q.DeepCopy()
+			}
+		}
+	}
+	for name, q := range p.Spec.Overhead {
+		v := out[name]
+		v.Add(q)
+		out[name] = v
+	}
+	return out
+}
+
+// freeOf is allocatable minus requested, never below zero
+func freeOf(allocatable, requested resource.Quantity) resource.Quantity {
+	f := allocatable.DeepCopy()
+	f.Sub(requested)
+	if f.Sign() < 0 {
+		return resource.Quantity{}
+	}
+	return f
+}
@@ -0,0 +1,168 @@
+package k8s
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"jabberwocky238/console/settings"
+)
+
+// Challenge types a custom domain's certificate can be issued with. The
+// challenge itself is solved by the issuer; the type only picks the issuer.
+const (
+	ChallengeHTTP01 = "http01"
+	ChallengeDNS01  = "dns01"
+)
+
+// IssuerRef names a cert-manager issuer. It is written "Kind/name", or just
+// "name" for a ClusterIssuer. An Issuer has to live in IngressNamespace,
+// next to the Certificates.
+type IssuerRef struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+func (r IssuerRef) String() string {
+	return r.Kind + "/" + r.Name
+}
+
+var issuerName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]{0,251}[a-z0-9])?$`)
+
+// ParseIssuerRef parses "Kind/name" or "name"
+func ParseIssuerRef(s string) (IssuerRef, error) {
+	ref := IssuerRef{Kind: "ClusterIssuer", Name: strings.TrimSpace(s)}
+	if kind, name, ok := strings.Cut(ref.Name, "/"); ok {
+		ref.Kind, ref.Name = kind, name
+	}
+	if ref.Kind != "ClusterIssuer" && ref.Kind != "Issuer" {
+		return IssuerRef{}, fmt.Errorf("issuer kind %q must be ClusterIssuer or Issuer", ref.Kind)
+	}
+	if !issuerName.MatchString(ref.Name) {
+		return IssuerRef{}, fmt.Errorf("issuer name %q is not a valid resource name", ref.Name)
+	}
+	return ref, nil
+}
+
+// ACME environments selectable with CERT_ENVIRONMENT, backed by the
+// ClusterIssuers in scripts/letsencrypt-issuer.yaml
+var acmeIssuers = map[string]IssuerRef{
+	"staging":    {Kind: "ClusterIssuer", Name: "letsencrypt-staging"},
+	"production": {Kind: "ClusterIssuer", Name: "letsencrypt-production"},
+}
+
+var (
+	// DefaultIssuer signs certificates unless the challenge type or the
+	// domain picks another (env CERT_ENVIRONMENT or CERT_ISSUER)
+	DefaultIssuer = IssuerRef{Kind: "ClusterIssuer", Name: "zerossl-issuer"}
+	// ChallengeIssuers overrides DefaultIssuer per challenge type
+	// (env CERT_ISSUER_HTTP01, CERT_ISSUER_DNS01)
+	ChallengeIssuers = map[string]IssuerRef{}
+	// AllowedIssuers may be picked per domain by users who bring their own
+	// issuer (env CERT_ISSUERS_ALLOWED, comma separated)
+	AllowedIssuers []IssuerRef
+)
+
+// Runtime overrides of the env configuration above, empty unless set through
+// the admin settings API
+var (
+	defaultIssuerSetting = settings.NewString("cert.issuer", "", validIssuer,
+		`Issuer for certificates without a more specific choice, "Kind/name" or a ClusterIssuer name; empty follows CERT_ENVIRONMENT/CERT_ISSUER`)
+	challengeIssuerSettings = map[string]settings.String{
+		ChallengeHTTP01: settings.NewString("cert.issuer_http01", "", validIssuer, "Issuer for http01 domains; empty follows CERT_ISSUER_HTTP01"),
+		ChallengeDNS01:  settings.NewString("cert.issuer_dns01", "", validIssuer, "Issuer for dns01 domains; empty follows CERT_ISSUER_DNS01"),
+	}
+)
+
+// validIssuer accepts an empty value (no override) or a valid IssuerRef
+func validIssuer(s string) error {
+	if s == "" {
+		return nil
+	}
+	_, err := ParseIssuerRef(s)
+	return err
+}
+
+// CertIssuerEnvs are the environment variables read by SetCertIssuerEnv, in
+// the order they have to be applied (CERT_ISSUER overrides CERT_ENVIRONMENT)
+var CertIssuerEnvs = []string{"CERT_ENVIRONMENT", "CERT_ISSUER", "CERT_ISSUER_HTTP01", "CERT_ISSUER_DNS01", "CERT_ISSUERS_ALLOWED"}
+
+// SetCertIssuerEnv applies one of CertIssuerEnvs
+func SetCertIssuerEnv(env, value string) error {
+	var err error
+	switch env {
+	case "CERT_ENVIRONMENT":
+		ref, ok := acmeIssuers[value]
+		if !ok {
+			return fmt.Errorf("CERT_ENVIRONMENT %q must be staging or production", value)
+		}
+		DefaultIssuer = ref
+	case "CERT_ISSUER":
+		DefaultIssuer, err = ParseIssuerRef(value)
+	case "CERT_ISSUER_HTTP01":
+		ChallengeIssuers[ChallengeHTTP01], err = ParseIssuerRef(value)
+	case "CERT_ISSUER_DNS01":
+		ChallengeIssuers[ChallengeDNS01], err = ParseIssuerRef(value)
+	case "CERT_ISSUERS_ALLOWED":
+		AllowedIssuers = nil
+		for _, s := range strings.Split(value, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			ref, err := ParseIssuerRef(s)
+			if err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+			AllowedIssuers = append(AllowedIssuers, ref)
+		}
+	default:
+		return fmt.Errorf("unknown cert issuer variable %s", env)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", env, err)
+	}
+	return nil
+}
+
+// IssuerOverride checks a per-domain issuer against AllowedIssuers and
+// returns it in "Kind/name" form. An empty override is allowed and stays empty.
+func IssuerOverride(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	ref, err := ParseIssuerRef(s)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(AllowedIssuers, ref) {
+		return "", fmt.Errorf("issuer %s is not allowed", ref)
+	}
+	return ref.String(), nil
+}
+
+// IssuerFor picks the issuer of a domain: its own override, else the one
+// configured for its challenge type, else DefaultIssuer. A runtime setting
+// wins over the env variable at the same level.
+func IssuerFor(override, challenge string) (IssuerRef, error) {
+	if override != "" {
+		ref, err := ParseIssuerRef(override)
+		if err != nil {
+			return IssuerRef{}, err
+		}
+		if !slices.Contains(AllowedIssuers, ref) {
+			return IssuerRef{}, fmt.Errorf("issuer %s is no longer allowed", ref)
+		}
+		return ref, nil
+	}
+	if s, ok := challengeIssuerSettings[challenge]; ok && s.Get() != "" {
+		return ParseIssuerRef(s.Get())
+	}
+	if ref, ok := ChallengeIssuers[challenge]; ok {
+		return ref, nil
+	}
+	if s := defaultIssuerSetting.Get(); s != "" {
+		return ParseIssuerRef(s)
+	}
+	return DefaultIssuer, nil
+}
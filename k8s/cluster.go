@@ -0,0 +1,249 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"jabberwocky238/console/logging"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Target clusters for worker placement. The cluster the control plane runs
+// in is always registered as LocalCluster; more are registered with Secrets
+// in the control plane namespace labelled ClusterLabel=<name>, holding either
+//
+//	kubeconfig               a kubeconfig (its current context is used), or
+//	server, token, ca.crt    a service account token for the API server
+//
+// and optionally annotated with ClusterRegionAnnotation and
+// ClusterCapacityAnnotation. WorkerApp CRs always live in the local cluster;
+// the controller creates the worker's objects in the cluster it is placed on.
+// Remote clusters need the same ingress controller (with the worker-tls Secret) and
+// combinator installations as the local one. Worker hosts do not depend on the
+// cluster, so DNS for a worker placed on a remote cluster has to resolve to
+// that cluster's ingress.
+
+const (
+	LocalCluster              = "local"
+	ClusterLabel              = "console.app238.com/cluster"
+	ClusterRegionAnnotation   = "console.app238.com/region"
+	ClusterCapacityAnnotation = "console.app238.com/capacity" // workers the cluster should hold
+	DefaultClusterCapacity    = 100
+)
+
+var clusterLog = logging.Component("clusters")
+
+// Cluster is a registered target cluster and its clients
+type Cluster struct {
+	Name     string
+	Region   string
+	Capacity int
+	Local    bool
+	Kube     kubernetes.Interface
+	Dynamic  dynamic.Interface
+
+	version string // Secret resourceVersion the clients were built from
+}
+
+// ClusterPool holds the clients of every registered cluster, keyed by name
+type ClusterPool struct {
+	mu       sync.RWMutex
+	clusters map[string]*Cluster
+}
+
+// Clusters is the pool used by the controller and the worker jobs. It only
+// contains the local cluster until RefreshClusters has run.
+var Clusters = &ClusterPool{clusters: map[string]*Cluster{}}
+
+// Get returns the named cluster; an empty name means LocalCluster
+func (p *ClusterPool) Get(name string) (*Cluster, error) {
+	if name == "" {
+		name = LocalCluster
+	}
+	p.mu.RLock()
+	c, ok := p.clusters[name]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("cluster %q is not registered", name)
+	}
+	return c, nil
+}
+
+// List returns the registered clusters sorted by name
+func (p *ClusterPool) List() []*Cluster {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]*Cluster, 0, len(p.clusters))
+	for _, c := range p.clusters {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Schedule picks the cluster for a new worker: the requested one if set,
+// otherwise the least loaded (workers / capacity) of the clusters in region,
+// or of all clusters when none is in region. placed counts the workers
+// already on each cluster.
+func (p *ClusterPool) Schedule(requested, region string, placed map[string]int) (*Cluster, error) {
+	if requested != "" {
+		return p.Get(requested)
+	}
+	all := p.List()
+	candidates := all
+	if region != "" {
+		var inRegion []*Cluster
+		for _, c := range all {
+			if c.Region == region {
+				inRegion = append(inRegion, c)
+			}
+		}
+		if len(inRegion) > 0 {
+			candidates = inRegion
+		}
+	}
+
+	var best *Cluster
+	var bestLoad float64
+	for _, c := range candidates {
+		load := float64(placed[c.Name]) / float64(c.Capacity)
+		if best == nil || load < bestLoad {
+			best, bestLoad = c, load
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no cluster registered")
+	}
+	return best, nil
+}
+
+// RegisterLocalCluster adds the cluster InitK8s connected to as LocalCluster
+func RegisterLocalCluster() {
+	if K8sClient == nil || DynamicClient == nil {
+		return
+	}
+	Clusters.mu.Lock()
+	defer Clusters.mu.Unlock()
+	Clusters.clusters[LocalCluster] = &Cluster{
+		Name:     LocalCluster,
+		Capacity: DefaultClusterCapacity,
+		Local:    true,
+		Kube:     K8sClient,
+		Dynamic:  DynamicClient,
+	}
+}
+
+// RefreshClusters reloads the remote clusters from their Secrets. Clients are
+// only rebuilt for Secrets that changed; clusters whose Secret is gone are
+// dropped, and a Secret that cannot be used keeps the previous clients.
+func RefreshClusters(ctx context.Context) error {
+	if K8sClient == nil {
+		return fmt.Errorf("k8s client not initialized")
+	}
+	secrets, err := K8sClient.CoreV1().Secrets(Namespace).List(ctx, metav1.ListOptions{LabelSelector: ClusterLabel})
+	if err != nil {
+		return fmt.Errorf("list cluster secrets: %w", err)
+	}
+
+	Clusters.mu.RLock()
+	current := make(map[string]*Cluster, len(Clusters.clusters))
+	for name, c := range Clusters.clusters {
+		current[name] = c
+	}
+	Clusters.mu.RUnlock()
+
+	next := map[string]*Cluster{}
+	if local, ok := current[LocalCluster]; ok {
+		next[LocalCluster] = local
+	}
+	for i := range secrets.Items {
+		sec := &secrets.Items[i]
+		name := sec.Labels[ClusterLabel]
+		if name == "" || name == LocalCluster {
+			clusterLog.Warn("ignoring cluster secret with reserved or empty name", "secret", sec.Name)
+			continue
+		}
+		if _, dup := next[name]; dup {
+			clusterLog.Warn("duplicate cluster secret", "cluster", name, "secret", sec.Name)
+			continue
+		}
+		if c, ok := current[name]; ok && c.version == sec.ResourceVersion {
+			next[name] = c
+			continue
+		}
+		c, err := clusterFromSecret(name, sec)
+		if err != nil {
+			clusterLog.Error("cannot use cluster secret", "cluster", name, "secret", sec.Name, "error", err)
+			if old, ok := current[name]; ok {
+				next[name] = old
+			}
+			continue
+		}
+		clusterLog.Info("cluster registered", "cluster", name, "region", c.Region, "capacity", c.Capacity)
+		next[name] = c
+	}
+	for name := range current {
+		if _, ok := next[name]; !ok {
+			clusterLog.Info("cluster removed", "cluster", name)
+		}
+	}
+
+	Clusters.mu.Lock()
+	Clusters.clusters = next
+	Clusters.mu.Unlock()
+	return nil
+}
+
+func clusterFromSecret(name string, sec *corev1.Secret) (*Cluster, error) {
+	var config *rest.Config
+	var err error
+	if kc := sec.Data["kubeconfig"]; len(kc) > 0 {
+		config, err = clientcmd.RESTConfigFromKubeConfig(kc)
+		if err != nil {
+			return nil, fmt.Errorf("parse kubeconfig: %w", err)
+		}
+	} else {
+		server, token := string(sec.Data["server"]), string(sec.Data["token"])
+		if server == "" || token == "" {
+			return nil, fmt.Errorf("secret needs kubeconfig, or server and token")
+		}
+		config = &rest.Config{
+			Host:            server,
+			BearerToken:     token,
+			TLSClientConfig: rest.TLSClientConfig{CAData: sec.Data["ca.crt"]},
+		}
+	}
+
+	capacity := DefaultClusterCapacity
+	if v := sec.Annotations[ClusterCapacityAnnotation]; v != "" {
+		if capacity, err = strconv.Atoi(v); err != nil || capacity <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", ClusterCapacityAnnotation, v)
+		}
+	}
+
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Cluster{
+		Name:     name,
+		Region:   sec.Annotations[ClusterRegionAnnotation],
+		Capacity: capacity,
+		Kube:     kube,
+		Dynamic:  dyn,
+		version:  sec.ResourceVersion,
+	}, nil
+}
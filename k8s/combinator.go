@@ -0,0 +1,127 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// CombinatorProfile sizes the pod of a dedicated combinator (CombinatorApp)
+type CombinatorProfile struct {
+	Name          string `json:"name"`
+	CPURequest    string `json:"cpu_request"`
+	MemoryRequest string `json:"memory_request"`
+	CPULimit      string `json:"cpu_limit"`
+	MemoryLimit   string `json:"memory_limit"`
+}
+
+// CombinatorProfiles from smallest to largest
+var CombinatorProfiles = []CombinatorProfile{
+	{Name: "small", CPURequest: "100m", MemoryRequest: "128Mi", CPULimit: "500m", MemoryLimit: "512Mi"},
+	{Name: "medium", CPURequest: "250m", MemoryRequest: "256Mi", CPULimit: "1", MemoryLimit: "1Gi"},
+	{Name: "large", CPURequest: "500m", MemoryRequest: "512Mi", CPULimit: "2", MemoryLimit: "2Gi"},
+}
+
+// ErrCombinatorProfile is returned for an unknown profile or one above the plan
+var ErrCombinatorProfile = errors.New("combinator profile not available")
+
+// CombinatorProfileFor returns the named profile, the smallest for an unknown name
+func CombinatorProfileFor(name string) CombinatorProfile {
+	if i := combinatorProfileIndex(name); i >= 0 {
+		return CombinatorProfiles[i]
+	}
+	return CombinatorProfiles[0]
+}
+
+func combinatorProfileIndex(name string) int {
+	return slices.IndexFunc(CombinatorProfiles, func(p CombinatorProfile) bool { return p.Name == name })
+}
+
+// ResolveCombinatorProfile returns the profile a user of plan runs with when
+// they asked for requested: the plan's default when empty, an error when
+// unknown or above the plan's maximum.
+func ResolveCombinatorProfile(plan TenantPlan, requested string) (CombinatorProfile, error) {
+	if requested == "" {
+		return CombinatorProfileFor(plan.CombinatorProfile), nil
+	}
+	i := combinatorProfileIndex(requested)
+	if i < 0 {
+		return CombinatorProfile{}, fmt.Errorf("%w: unknown profile %q", ErrCombinatorProfile, requested)
+	}
+	if i > combinatorProfileIndex(plan.MaxCombinatorProfile) {
+		return CombinatorProfile{}, fmt.Errorf("%w: plan %s allows up to %s", ErrCombinatorProfile, plan.Name, plan.MaxCombinatorProfile)
+	}
+	return CombinatorProfiles[i], nil
+}
+
+// ClampCombinatorProfile is ResolveCombinatorProfile that falls back to the
+// largest profile the plan allows, for a profile chosen on a higher plan
+func ClampCombinatorProfile(plan TenantPlan, requested string) CombinatorProfile {
+	p, err := ResolveCombinatorProfile(plan, requested)
+	if err != nil {
+		if combinatorProfileIndex(requested) < 0 {
+			return CombinatorProfileFor(plan.CombinatorProfile)
+		}
+		return CombinatorProfileFor(plan.MaxCombinatorProfile)
+	}
+	return p
+}
+
+// CombinatorRecommendation is the profile the observed usage of a dedicated
+// combinator calls for, VPA style: the smallest profile whose requests cover
+// the p95 CPU and the peak memory of the window plus headroom
+type CombinatorRecommendation struct {
+	Profile     string  `json:"profile"`
+	CPUCores    float64 `json:"cpu_cores"`    // p95 over the window
+	MemoryBytes float64 `json:"memory_bytes"` // peak over the window
+	Window      string  `json:"window"`
+}
+
+// CombinatorRecommendationWindow is the usage window of recommendations
+const CombinatorRecommendationWindow = 24 * time.Hour
+
+// combinatorHeadroom is added to the observed usage before it is compared
+// with the requests of a profile
+const combinatorHeadroom = 1.2
+
+// RecommendCombinatorProfile reads the usage of the pods of the dedicated
+// combinator name from Prometheus (cAdvisor metrics). It returns nil without
+// PROMETHEUS_URL or before the pods have any samples.
+func RecommendCombinatorProfile(ctx context.Context, name string) (*CombinatorRecommendation, error) {
+	if PrometheusEndpoint == "" {
+		return nil, nil
+	}
+	window := int(CombinatorRecommendationWindow.Seconds())
+	// Deployment pods are <name>-<replicaset hash>-<pod hash>
+	selector := fmt.Sprintf(`{namespace=%q,pod=~%q,container="combinator"}`,
+		CombinatorNamespace, regexp.QuoteMeta(name)+"-[a-z0-9]+-[a-z0-9]+")
+	cpu, okCPU, err := QueryPromScalar(ctx, fmt.Sprintf(
+		`max(quantile_over_time(0.95, rate(container_cpu_usage_seconds_total%s[5m])[%ds:5m]))`, selector, window))
+	if err != nil {
+		return nil, err
+	}
+	memory, okMem, err := QueryPromScalar(ctx, fmt.Sprintf(
+		`max(max_over_time(container_memory_working_set_bytes%s[%ds]))`, selector, window))
+	if err != nil {
+		return nil, err
+	}
+	if !okCPU && !okMem {
+		return nil, nil
+	}
+	rec := &CombinatorRecommendation{CPUCores: cpu, MemoryBytes: memory, Window: CombinatorRecommendationWindow.String()}
+	rec.Profile = CombinatorProfiles[len(CombinatorProfiles)-1].Name
+	for _, p := range CombinatorProfiles {
+		cpuReq := resource.MustParse(p.CPURequest)
+		memReq := resource.MustParse(p.MemoryRequest)
+		if cpu*combinatorHeadroom <= cpuReq.AsApproximateFloat64() && memory*combinatorHeadroom <= memReq.AsApproximateFloat64() {
+			rec.Profile = p.Name
+			break
+		}
+	}
+	return rec, nil
+}
@@ -0,0 +1,92 @@
+package k8s
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Config is the cluster layout the gateways run against. Empty fields keep
+// the defaults of the package variables.
+type Config struct {
+	Kubeconfig  string            `json:"kubeconfig,omitempty"` // empty for in-cluster
+	Domain      string            `json:"domain,omitempty"`
+	Namespaces  NamespaceConfig   `json:"namespaces"`
+	CockroachDB CockroachDBConfig `json:"cockroachdb"`
+	Endpoints   EndpointConfig    `json:"endpoints"`
+}
+
+// NamespaceConfig names the namespaces of the control plane and the tenants
+type NamespaceConfig struct {
+	ControlPlane string `json:"control_plane,omitempty"`
+	Combinator   string `json:"combinator,omitempty"`
+	Ingress      string `json:"ingress,omitempty"`
+	Worker       string `json:"worker,omitempty"`
+	RDB          string `json:"rdb,omitempty"`
+}
+
+// CockroachDBConfig locates the RDB cluster. AdminDSN defaults to root on
+// Host:Port.
+type CockroachDBConfig struct {
+	Host     string `json:"host,omitempty"`
+	Port     string `json:"port,omitempty"`
+	AdminDSN string `json:"admin_dsn,omitempty"`
+}
+
+// EndpointConfig holds the URLs the gateways call each other on. They
+// default to the Services in the control plane namespace.
+type EndpointConfig struct {
+	Inner string `json:"inner,omitempty"`
+	Outer string `json:"outer,omitempty"`
+}
+
+// Configure applies cfg to the package variables. It runs once at startup,
+// before InitK8s and InitRDBManager.
+func Configure(cfg Config) error {
+	namespaces := []struct {
+		value string
+		dst   *string
+	}{
+		{cfg.Namespaces.ControlPlane, &Namespace},
+		{cfg.Namespaces.Combinator, &CombinatorNamespace},
+		{cfg.Namespaces.Ingress, &IngressNamespace},
+		{cfg.Namespaces.Worker, &WorkerNamespace},
+		{cfg.Namespaces.RDB, &RDBNamespace},
+	}
+	for _, ns := range namespaces {
+		if ns.value == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(ns.value); len(errs) > 0 {
+			return fmt.Errorf("namespace %q: %s", ns.value, errs[0])
+		}
+		*ns.dst = ns.value
+	}
+	if cfg.Domain != "" {
+		Domain = cfg.Domain
+	}
+
+	if cfg.CockroachDB.Host != "" || cfg.Namespaces.RDB != "" {
+		CockroachDBHost = cfg.CockroachDB.Host
+		if CockroachDBHost == "" {
+			CockroachDBHost = "cockroachdb-public." + RDBNamespace + ".svc.cluster.local"
+		}
+	}
+	if cfg.CockroachDB.Port != "" {
+		CockroachDBPort = cfg.CockroachDB.Port
+	}
+	CockroachDBAdminDSN = cfg.CockroachDB.AdminDSN
+	if CockroachDBAdminDSN == "" {
+		CockroachDBAdminDSN = fmt.Sprintf("postgresql://root@%s:%s?sslmode=disable", CockroachDBHost, CockroachDBPort)
+	}
+
+	ControlPlaneInnerEndpoint = cfg.Endpoints.Inner
+	if ControlPlaneInnerEndpoint == "" {
+		ControlPlaneInnerEndpoint = "http://control-plane-inner." + Namespace + ".svc.cluster.local:9901"
+	}
+	ControlPlaneOuterEndpoint = cfg.Endpoints.Outer
+	if ControlPlaneOuterEndpoint == "" {
+		ControlPlaneOuterEndpoint = "http://control-plane-outer." + Namespace + ".svc.cluster.local:9900"
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ResourceDSN is the connection string of a managed resource: the schema DSN
+// of an RDB; a KV is served by a combinator, so it is the endpoint of the
+// combinator serving the owner; a queue is the AMQP URL of the owner's vhost
+// (k8s.ErrNoQueueAccount before their first queue exists).
+func ResourceDSN(ctx context.Context, ownerID, resourceType, resourceID string) (string, error) {
+	switch resourceType {
+	case "rdb":
+		if k8s.RDBManager == nil {
+			return "", fmt.Errorf("cockroachdb not available")
+		}
+		return k8s.RDBManager.DSNWithSchema(ownerID, resourceID), nil
+	case "kv":
+		if CombinatorApps {
+			return (&CombinatorAppSpec{OwnerID: ownerID}).Endpoint(), nil
+		}
+		return combinatorEndpoint("combinator"), nil
+	case "queue":
+		if k8s.QueueBroker == nil {
+			return "", fmt.Errorf("queue broker not available")
+		}
+		return k8s.QueueBroker.DSN(ctx, ownerID)
+	}
+	return "", fmt.Errorf("unknown resource type %q", resourceType)
+}
+
+// bucketEnvSuffixes are appended to the env name of a bucket attachment,
+// which is a prefix: S3 gives S3_ENDPOINT, S3_BUCKET and so on
+var bucketEnvSuffixes = []string{"_ENDPOINT", "_BUCKET", "_REGION", "_ACCESS_KEY_ID", "_SECRET_ACCESS_KEY", "_SESSION_TOKEN"}
+
+// AttachmentKeys are the env keys an attachment named envName injects
+func AttachmentKeys(resourceType, envName string) []string {
+	if resourceType != "bucket" {
+		return []string{envName}
+	}
+	keys := make([]string, len(bucketEnvSuffixes))
+	for i, s := range bucketEnvSuffixes {
+		keys[i] = envName + s
+	}
+	return keys
+}
+
+// bucketEnv renders the endpoint, name and scoped credentials of a bucket
+// under prefix; nothing until the bucket has been created
+func bucketEnv(ctx context.Context, ownerID, resourceID, prefix string, env map[string]string) error {
+	if k8s.ObjectStore == nil {
+		return fmt.Errorf("object storage not available")
+	}
+	b, err := dblayer.GetObjectBucket(ctx, ownerID, resourceID)
+	if err != nil {
+		return err
+	}
+	if b.BucketName == "" || b.AccessKeyID == "" {
+		return nil
+	}
+	values := []string{k8s.ObjectStore.PublicEndpoint, b.BucketName, k8s.ObjectStore.Region, b.AccessKeyID, b.SecretAccessKey, b.SessionToken}
+	for i, k := range AttachmentKeys("bucket", prefix) {
+		env[k] = values[i]
+	}
+	return nil
+}
+
+// AttachmentEnv renders the env of the resources attached to the worker:
+// env name to connection string, or for a bucket its AttachmentKeys
+func AttachmentEnv(ctx context.Context, workerID, ownerID string) (map[string]string, error) {
+	attachments, err := dblayer.ListWorkerAttachments(ctx, workerID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	env := map[string]string{}
+	for _, a := range attachments {
+		if a.ResourceType == "bucket" {
+			if err := bucketEnv(ctx, ownerID, a.ResourceID, a.EnvName, env); err != nil {
+				return nil, fmt.Errorf("resolve bucket %s: %w", a.ResourceID, err)
+			}
+			continue
+		}
+		dsn, err := ResourceDSN(ctx, ownerID, a.ResourceType, a.ResourceID)
+		if errors.Is(err, k8s.ErrNoQueueAccount) {
+			continue // injected once the queue has been created
+		}
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s %s: %w", a.ResourceType, a.ResourceID, err)
+		}
+		env[a.EnvName] = dsn
+	}
+	return env, nil
+}
+
+// ApplyAttachmentEnv writes env into the worker Secret sec and removes the
+// keys of detached resources, tracked in AttachmentsAnnotation. It reports
+// whether sec changed.
+func ApplyAttachmentEnv(sec *corev1.Secret, env map[string]string) bool {
+	before := maps.Clone(sec.Data)
+	if sec.Data == nil {
+		sec.Data = map[string][]byte{}
+	}
+	if v := sec.Annotations[AttachmentsAnnotation]; v != "" {
+		for _, k := range strings.Split(v, ",") {
+			if _, ok := env[k]; !ok {
+				delete(sec.Data, k)
+			}
+		}
+	}
+	for k, v := range env {
+		if !slices.Contains(ReservedEnvKeys, k) {
+			sec.Data[k] = []byte(v)
+		}
+	}
+	annotation := strings.Join(slices.Sorted(maps.Keys(env)), ",")
+	if maps.EqualFunc(before, sec.Data, slices.Equal) && sec.Annotations[AttachmentsAnnotation] == annotation {
+		return false
+	}
+	if annotation == "" {
+		delete(sec.Annotations, AttachmentsAnnotation)
+	} else {
+		if sec.Annotations == nil {
+			sec.Annotations = map[string]string{}
+		}
+		sec.Annotations[AttachmentsAnnotation] = annotation
+	}
+	return true
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"jabberwocky238/console/k8s"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Read cache for worker resources. The controller's shared informers already
+// watch everything a worker is made of, so read paths look objects up in the
+// informer stores (indexed by the owner-id label) instead of calling the
+// apiserver on every request. Writes still go to the apiserver.
+
+const (
+	ownerIndex       = "owner-id"
+	eventObjectIndex = "involved-object"
+	maxStatusEvents  = 20
+)
+
+// ErrCacheNotSynced is returned by cache-only reads before the informers
+// have completed their initial list.
+var ErrCacheNotSynced = errors.New("worker cache not synced")
+
+type workerCache struct {
+	deployments cache.Indexer
+	pods        cache.Indexer
+	secrets     cache.Indexer
+	routes      cache.Indexer // in k8s.IngressNamespace
+	events      cache.Indexer // Events on WorkerApp CRs
+	synced      atomic.Bool
+}
+
+var readCache = &workerCache{}
+
+func ownerIndexFunc(obj any) ([]string, error) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, nil
+	}
+	if owner := m.GetLabels()["owner-id"]; owner != "" {
+		return []string{owner}, nil
+	}
+	return nil, nil
+}
+
+func eventObjectIndexFunc(obj any) ([]string, error) {
+	ev, ok := obj.(*corev1.Event)
+	if !ok {
+		return nil, nil
+	}
+	return []string{ev.InvolvedObject.Name}, nil
+}
+
+// addOwnerIndex registers ownerIndex (plus any extra indexers) on an informer
+func addOwnerIndex(informer cache.SharedIndexInformer, extra cache.Indexers) cache.Indexer {
+	indexers := cache.Indexers{ownerIndex: ownerIndexFunc}
+	for name, fn := range extra {
+		indexers[name] = fn
+	}
+	if err := informer.AddIndexers(indexers); err != nil {
+		ctrlLog.Error("add informer indexers failed", "error", err)
+	}
+	return informer.GetIndexer()
+}
+
+// ready reports whether the stores are populated; the atomic load also makes
+// the indexers set before the store visible to the reader.
+func (c *workerCache) ready() bool {
+	return c.synced.Load()
+}
+
+func (c *workerCache) byOwner(store cache.Indexer, ownerID string) []any {
+	if ownerID == "" {
+		return store.List()
+	}
+	objs, err := store.ByIndex(ownerIndex, ownerID)
+	if err != nil {
+		return nil
+	}
+	return objs
+}
+
+func getCached[T any](store cache.Indexer, namespace, name string) (T, bool) {
+	var zero T
+	obj, exists, err := store.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return zero, false
+	}
+	v, ok := obj.(T)
+	return v, ok
+}
+
+// cachedWorkerPods returns the pods of the named worker from the cache. ok is
+// false when the cache is not synced and the caller should ask the apiserver.
+func cachedWorkerPods(name string) (pods []*corev1.Pod, ok bool) {
+	if !readCache.ready() {
+		return nil, false
+	}
+	objs, err := readCache.pods.ByIndex(podAppIndex, name)
+	if err != nil {
+		return nil, false
+	}
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, true
+}
+
+// cachedWorkers lists workers from the Deployment cache, filtered like ListWorkers
+func cachedWorkers(workerID, ownerID string) []WorkerAppSpec {
+	var workers []WorkerAppSpec
+	for _, obj := range readCache.byOwner(readCache.deployments, ownerID) {
+		d, ok := obj.(*appsv1.Deployment)
+		if !ok || (workerID != "" && d.Labels["worker-id"] != workerID) {
+			continue
+		}
+		workers = append(workers, workerFromDeployment(d))
+	}
+	return workers
+}
+
+func workerFromDeployment(d *appsv1.Deployment) WorkerAppSpec {
+	w := WorkerAppSpec{
+		WorkerID: d.Labels["worker-id"],
+		OwnerID:  d.Labels["owner-id"],
+	}
+	if cs := d.Spec.Template.Spec.Containers; len(cs) > 0 {
+		w.Image = cs[0].Image
+	}
+	return w
+}
+
+// WorkerPodStatus is the state of one pod of a worker
+type WorkerPodStatus struct {
+	Name     string `json:"name"`
+	Phase    string `json:"phase"`
+	Ready    bool   `json:"ready"`
+	Restarts int32  `json:"restarts"`
+	Reason   string `json:"reason,omitempty"` // waiting reason such as CrashLoopBackOff
+}
+
+// WorkerRuntimeStatus is what the cluster currently runs for a worker
+type WorkerRuntimeStatus struct {
+	Deployed          bool              `json:"deployed"`
+	Image             string            `json:"image,omitempty"`
+	Replicas          int32             `json:"replicas"`
+	ReadyReplicas     int32             `json:"ready_replicas"`
+	UpdatedReplicas   int32             `json:"updated_replicas"`
+	AvailableReplicas int32             `json:"available_replicas"`
+	Routed            bool              `json:"routed"`       // route (k8s.Ingress) exists
+	SecretReady       bool              `json:"secret_ready"` // Secret exists
+	Pods              []WorkerPodStatus `json:"pods"`
+	Events            []WorkerEvent     `json:"events"` // newest first
+}
+
+// WorkerEvent is a Kubernetes Event recorded on the worker's WorkerApp CR
+type WorkerEvent struct {
+	Type     string    `json:"type"` // Normal or Warning
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// WorkerStatus reads the runtime status of a worker placed on cluster c. For
+// the local cluster it reads the informer cache only and returns
+// ErrCacheNotSynced until the initial sync has finished; the informers do
+// not cover remote clusters, whose objects are read from their apiserver.
+func WorkerStatus(ctx context.Context, c *k8s.Cluster, workerID, ownerID string) (*WorkerRuntimeStatus, error) {
+	if !readCache.ready() {
+		return nil, ErrCacheNotSynced
+	}
+	if !c.Local {
+		return remoteWorkerStatus(ctx, c, workerID, ownerID)
+	}
+	name := WorkerName(workerID, ownerID)
+	ns := k8s.TenantNamespace(ownerID)
+	s := &WorkerRuntimeStatus{Pods: []WorkerPodStatus{}, Events: workerEvents(name)}
+
+	if d, ok := getCached[*appsv1.Deployment](readCache.deployments, ns, name); ok {
+		s.setDeployment(d)
+	}
+	_, s.Routed = getCached[any](readCache.routes, k8s.IngressNamespace, name)
+	_, s.SecretReady = getCached[*corev1.Secret](readCache.secrets, ns, name+"-secret")
+
+	pods, _ := cachedWorkerPods(name)
+	for _, pod := range pods {
+		s.addPod(pod)
+	}
+	return s, nil
+}
+
+// remoteWorkerStatus is WorkerStatus for a worker on a remote cluster. Events
+// are still read from the cache since they are recorded on the local CR.
+func remoteWorkerStatus(ctx context.Context, c *k8s.Cluster, workerID, ownerID string) (*WorkerRuntimeStatus, error) {
+	name := WorkerName(workerID, ownerID)
+	ns := k8s.TenantNamespace(ownerID)
+	s := &WorkerRuntimeStatus{Pods: []WorkerPodStatus{}, Events: workerEvents(name)}
+
+	d, err := c.Kube.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		s.setDeployment(d)
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("get deployment on %s: %w", c.Name, err)
+	}
+	if _, err := k8s.Routes(c.Dynamic).Get(ctx, name, metav1.GetOptions{}); err == nil {
+		s.Routed = true
+	}
+	if _, err := c.Kube.CoreV1().Secrets(ns).Get(ctx, name+"-secret", metav1.GetOptions{}); err == nil {
+		s.SecretReady = true
+	}
+	pods, err := c.Kube.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{"app": name}.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods on %s: %w", c.Name, err)
+	}
+	for i := range pods.Items {
+		s.addPod(&pods.Items[i])
+	}
+	return s, nil
+}
+
+func (s *WorkerRuntimeStatus) setDeployment(d *appsv1.Deployment) {
+	s.Deployed = true
+	s.Image = workerFromDeployment(d).Image
+	if d.Spec.Replicas != nil {
+		s.Replicas = *d.Spec.Replicas
+	}
+	s.ReadyReplicas = d.Status.ReadyReplicas
+	s.UpdatedReplicas = d.Status.UpdatedReplicas
+	s.AvailableReplicas = d.Status.AvailableReplicas
+}
+
+func (s *WorkerRuntimeStatus) addPod(pod *corev1.Pod) {
+	s.Pods = append(s.Pods, podStatus(pod))
+}
+
+// podStatus summarizes a pod; restarts are summed over its containers
+func podStatus(pod *corev1.Pod) WorkerPodStatus {
+	ps := WorkerPodStatus{Name: pod.Name, Phase: string(pod.Status.Phase)}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			ps.Ready = cond.Status == corev1.ConditionTrue
+		}
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		ps.Restarts += cs.RestartCount
+		if w := cs.State.Waiting; w != nil && ps.Reason == "" {
+			ps.Reason = w.Reason
+		}
+	}
+	return ps
+}
+
+// workerEvents returns the latest Events recorded on the named WorkerApp CR
+func workerEvents(name string) []WorkerEvent {
+	events := []WorkerEvent{}
+	objs, _ := readCache.events.ByIndex(eventObjectIndex, name)
+	for _, obj := range objs {
+		ev, ok := obj.(*corev1.Event)
+		if !ok {
+			continue
+		}
+		last := ev.LastTimestamp.Time
+		if last.IsZero() {
+			last = ev.EventTime.Time
+		}
+		if last.IsZero() {
+			last = ev.CreationTimestamp.Time
+		}
+		events = append(events, WorkerEvent{
+			Type:     ev.Type,
+			Reason:   ev.Reason,
+			Message:  ev.Message,
+			Count:    max(ev.Count, 1),
+			LastSeen: last,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].LastSeen.After(events[j].LastSeen) })
+	if len(events) > maxStatusEvents {
+		events = events[:maxStatusEvents]
+	}
+	return events
+}
@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"jabberwocky238/console/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The combinator Deployment (scripts/combinator-deployment.yaml) mounts the
+// combinator-config ConfigMap, which kubelet refreshes in place without
+// restarting the pods. The controller stamps a hash of the ConfigMap on the
+// pod template, so every change rolls the pods out, gated by their readiness
+// probe.
+const (
+	CombinatorDeployment = "combinator"
+	CombinatorConfigMap  = "combinator-config"
+	ConfigHashAnnotation = "console.app238.com/config-hash"
+)
+
+// CombinatorController reconciles CombinatorApp CRs, each a user's dedicated
+// combinator, into a ConfigMap, Deployment and Service in CombinatorNamespace
+type CombinatorController struct {
+	ctrl    *Controller
+	crCache cache.Store
+}
+
+func (cc *CombinatorController) onAdd(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	ctrlLog.Info("CombinatorApp added", "name", u.GetName())
+	cc.reconcile(u)
+}
+
+func (cc *CombinatorController) onUpdate(oldObj, newObj any) {
+	oldU, ok1 := oldObj.(*unstructured.Unstructured)
+	newU, ok2 := newObj.(*unstructured.Unstructured)
+	if !ok1 || !ok2 {
+		return
+	}
+	if oldU.GetGeneration() == newU.GetGeneration() {
+		return // status-only update, skip reconcile
+	}
+	ctrlLog.Info("CombinatorApp updated", "name", newU.GetName())
+	cc.reconcile(newU)
+}
+
+func (cc *CombinatorController) onDelete(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	ctrlLog.Info("CombinatorApp deleted", "name", u.GetName())
+	a := combinatorFromUnstructured(u)
+	if a == nil {
+		return
+	}
+	if err := a.DeleteAll(context.Background()); err != nil {
+		ctrlLog.Error("delete combinator sub-resources failed", "name", u.GetName(), "error", err)
+	}
+}
+
+func (cc *CombinatorController) reconcile(u *unstructured.Unstructured) {
+	a := combinatorFromUnstructured(u)
+	if a == nil {
+		return
+	}
+
+	ctx := context.Background()
+	cc.ctrl.updateStatus(u, CombinatorAppGVR, "Deploying", "")
+	for _, step := range a.ensureSteps() {
+		if err := step.ensure(ctx); err != nil {
+			ctrlLog.Error("ensure "+step.kind+" failed", "name", u.GetName(), "error", err)
+			cc.ctrl.updateStatus(u, CombinatorAppGVR, "Failed", err.Error())
+			return
+		}
+	}
+	ctrlLog.Info("reconcile success", "name", u.GetName())
+	cc.ctrl.updateStatus(u, CombinatorAppGVR, "Running", "")
+}
+
+// cachedCR returns the CombinatorApp CR named name from the informer cache, or nil
+func (cc *CombinatorController) cachedCR(name string) *unstructured.Unstructured {
+	if cc.crCache == nil {
+		return nil
+	}
+	item, exists, err := cc.crCache.GetByKey(k8s.CombinatorNamespace + "/" + name)
+	if err != nil || !exists {
+		return nil
+	}
+	u, _ := item.(*unstructured.Unstructured)
+	return u
+}
+
+// startCombinatorAppWatch watches what the CombinatorApps own. The Deployment
+// brings back deleted or evicted pods by itself; a deleted Deployment,
+// Service, ConfigMap or Secret is recreated by reconciling its CR, and the CR
+// is marked while its pod crash loops. The objects carry combinator-owner
+// rather than owner-id, so the worker sub-resource informers never see them.
+func (c *Controller) startCombinatorAppWatch(stopCh <-chan struct{}) {
+	cc := c.combinator
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		c.k8sClient, 30*time.Second,
+		informers.WithNamespace(k8s.CombinatorNamespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = "combinator-owner"
+		}),
+	)
+	deleted := cache.ResourceEventHandlerFuncs{DeleteFunc: cc.onSubResourceDelete}
+	factory.Apps().V1().Deployments().Informer().AddEventHandler(deleted)
+	factory.Core().V1().Services().Informer().AddEventHandler(deleted)
+	factory.Core().V1().ConfigMaps().Informer().AddEventHandler(deleted)
+	factory.Core().V1().Secrets().Informer().AddEventHandler(deleted)
+	factory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    cc.onPod,
+		UpdateFunc: func(_, newObj any) { cc.onPod(newObj) },
+	})
+	go factory.Start(stopCh)
+}
+
+// onSubResourceDelete re-reconciles the CombinatorApp an object belonged to.
+// Objects removed by DeleteAll find no CR, which left the cache first.
+func (cc *CombinatorController) onSubResourceDelete(obj interface{}) {
+	if tomb, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tomb.Obj
+	}
+	o, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	owner := o.GetLabels()["combinator-owner"]
+	if owner == "" {
+		return
+	}
+	u := cc.cachedCR(CombinatorAppName(owner))
+	if u == nil {
+		return
+	}
+	ctrlLog.Info("combinator sub-resource deleted, re-reconciling", "name", u.GetName(), "object", o.GetName())
+	cc.reconcile(u)
+}
+
+// onPod moves the CR to CombinatorPhaseCrashLoop when a container of the pod
+// waits in CrashLoopBackOff, and back to Running once the pod is ready. The
+// phase is compared on the cached CR, so resyncs do not write the status.
+func (cc *CombinatorController) onPod(obj any) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.DeletionTimestamp != nil {
+		return
+	}
+	owner := pod.Labels["combinator-owner"]
+	if owner == "" {
+		return
+	}
+	u := cc.cachedCR(CombinatorAppName(owner))
+	if u == nil {
+		return
+	}
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+
+	ps := podStatus(pod)
+	switch {
+	case ps.Reason == "CrashLoopBackOff" && phase != CombinatorPhaseCrashLoop:
+		msg := fmt.Sprintf("pod %s is crash looping after %d restarts", pod.Name, ps.Restarts)
+		for _, cs := range pod.Status.ContainerStatuses {
+			if t := cs.LastTerminationState.Terminated; t != nil {
+				msg += fmt.Sprintf(", last exit %d (%s)", t.ExitCode, t.Reason)
+				break
+			}
+		}
+		ctrlLog.Warn("combinator crash looping", "name", u.GetName(), "pod", pod.Name, "restarts", ps.Restarts)
+		k8s.RecordWarning(u, k8s.EventReasonCrashLooping, "%s", msg)
+		cc.ctrl.updateStatus(u, CombinatorAppGVR, CombinatorPhaseCrashLoop, msg)
+	case ps.Ready && phase == CombinatorPhaseCrashLoop:
+		ctrlLog.Info("combinator recovered", "name", u.GetName(), "pod", pod.Name)
+		k8s.RecordEvent(u, k8s.EventReasonRecovered, "Pod %s is ready again", pod.Name)
+		cc.ctrl.updateStatus(u, CombinatorAppGVR, "Running", "")
+	}
+}
+
+type combinatorWatcher struct {
+	client kubernetes.Interface
+}
+
+// startCombinatorWatch watches the combinator ConfigMap until stopCh closes
+func (c *Controller) startCombinatorWatch(stopCh <-chan struct{}) {
+	w := &combinatorWatcher{client: c.k8sClient}
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		c.k8sClient, 30*time.Second,
+		informers.WithNamespace(k8s.CombinatorNamespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = "metadata.name=" + CombinatorConfigMap
+		}),
+	)
+	factory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: w.sync,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok1 := oldObj.(*corev1.ConfigMap)
+			cur, ok2 := newObj.(*corev1.ConfigMap)
+			if ok1 && ok2 && configHash(old) == configHash(cur) {
+				return // resync or a metadata-only change
+			}
+			w.sync(newObj)
+		},
+	})
+	go factory.Start(stopCh)
+}
+
+// sync rolls the Deployment out when its config hash differs from the ConfigMap's
+func (w *combinatorWatcher) sync(obj interface{}) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+	hash := configHash(cm)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	deployments := w.client.AppsV1().Deployments(k8s.CombinatorNamespace)
+	d, err := deployments.Get(ctx, CombinatorDeployment, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		ctrlLog.Error("get combinator deployment failed", "error", err)
+		return
+	}
+	if d.Spec.Template.Annotations[ConfigHashAnnotation] == hash {
+		return
+	}
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, ConfigHashAnnotation, hash)
+	if _, err := deployments.Patch(ctx, CombinatorDeployment, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		ctrlLog.Error("roll out combinator config failed", "error", err)
+		return
+	}
+	ctrlLog.Info("combinator config changed, rolling out", "hash", hash)
+}
+
+// configHash is a stable hash of the ConfigMap's data
+func configHash(cm *corev1.ConfigMap) string {
+	h := sha256.New()
+	for _, k := range slices.Sorted(maps.Keys(cm.Data)) {
+		fmt.Fprintf(h, "%s=%d:%s;", k, len(cm.Data[k]), cm.Data[k])
+	}
+	for _, k := range slices.Sorted(maps.Keys(cm.BinaryData)) {
+		fmt.Fprintf(h, "%s=%d:%s;", k, len(cm.BinaryData[k]), cm.BinaryData[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,614 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+)
+
+// CombinatorApps gives every user a dedicated combinator (CombinatorApp,
+// COMBINATOR_APPS); off, all users share the combinator Deployment in
+// CombinatorNamespace
+var CombinatorApps bool
+
+// CombinatorImage is the image of CombinatorApp pods whose spec leaves it empty
+var CombinatorImage = "ghcr.io/jabberwocky238/combinator:latest"
+
+// CombinatorPhaseAbsent is the phase reported for a user without a CombinatorApp
+const CombinatorPhaseAbsent = "Absent"
+
+// CombinatorPhaseCrashLoop marks a CombinatorApp whose pod is in
+// CrashLoopBackOff; it goes back to Running once the pod is ready
+const CombinatorPhaseCrashLoop = "CrashLoop"
+
+const (
+	combinatorPort        = 8899 // API served to workers
+	combinatorWebhookPort = 8890 // health and deletion webhook
+	combinatorMTLSSecret  = "combinator-mtls"
+	combinatorSecretsDir  = "/var/run/console/combinator"
+)
+
+// CombinatorAppName returns the canonical resource name of a user's combinator
+func CombinatorAppName(ownerID string) string {
+	return fmt.Sprintf("c-%s", ownerID)
+}
+
+// Name returns the combinator's resource name; the CR and every object it
+// owns live in CombinatorNamespace
+func (a *CombinatorAppSpec) Name() string {
+	return CombinatorAppName(a.OwnerID)
+}
+
+// Labels deliberately leave out owner-id: objects carrying it are watched by
+// the worker sub-resource informers, which would restart the Deployment on
+// every ConfigMap change on top of the config-hash rollout.
+func (a *CombinatorAppSpec) Labels() map[string]string {
+	return map[string]string{
+		"app":              a.Name(),
+		"combinator-owner": a.OwnerID,
+	}
+}
+
+func (a *CombinatorAppSpec) ConfigMapName() string {
+	return fmt.Sprintf("%s-config", a.Name())
+}
+
+// SecretName is the Secret with the combinator's credentials; they never go
+// into the ConfigMap or the CR, which more roles can read than Secrets
+func (a *CombinatorAppSpec) SecretName() string {
+	return fmt.Sprintf("%s-secret", a.Name())
+}
+
+// Endpoint is the API of the dedicated combinator, the Service EnsureService
+// creates
+func (a *CombinatorAppSpec) Endpoint() string {
+	return combinatorEndpoint(a.Name())
+}
+
+func (a *CombinatorAppSpec) image() string {
+	if a.Image == "" {
+		return CombinatorImage
+	}
+	return a.Image
+}
+
+// config renders config.json, the same shape as the shared combinator-config
+// plus the owner the pod is dedicated to and the revision it reports back
+// once loaded. It holds resource IDs only; the credentials are in secretData.
+func (a *CombinatorAppSpec) config() (string, error) {
+	orEmpty := func(ids []string) []string {
+		if ids == nil {
+			return []string{}
+		}
+		return ids
+	}
+	b, err := json.MarshalIndent(map[string]any{
+		"uid":      a.OwnerID,
+		"revision": a.Revision,
+		"rdb":      orEmpty(a.RDB),
+		"kv":       orEmpty(a.KV),
+		"queue":    orEmpty(a.Queue),
+	}, "", "  ")
+	return string(b), err
+}
+
+// EnsureConfigMap writes the combinator's config.json
+func (a *CombinatorAppSpec) EnsureConfigMap(ctx context.Context) error {
+	config, err := a.config()
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      a.ConfigMapName(),
+			Namespace: k8s.CombinatorNamespace,
+			Labels:    a.Labels(),
+		},
+		Data: map[string]string{"config.json": config},
+	}
+	client := k8s.K8sClient.CoreV1().ConfigMaps(k8s.CombinatorNamespace)
+	existing, err := client.Get(ctx, cm.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels, existing.Data = cm.Labels, cm.Data
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// secretData is the owner's secret key, which the combinator verifies request
+// signatures with, rdb.json mapping each RDB resource to its DSN and
+// queue.json mapping each queue resource to the AMQP URL of the owner's vhost
+func (a *CombinatorAppSpec) secretData(ctx context.Context) (map[string][]byte, error) {
+	secretKey, err := dblayer.GetUserSecretKey(ctx, a.OwnerID)
+	if err != nil {
+		return nil, fmt.Errorf("get secret key: %w", err)
+	}
+	dsns := map[string]string{}
+	if k8s.RDBManager != nil {
+		for _, id := range a.RDB {
+			dsns[id] = k8s.RDBManager.DSNWithSchema(a.OwnerID, id)
+		}
+	}
+	rdb, err := json.MarshalIndent(dsns, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	queueURLs := map[string]string{}
+	if k8s.QueueBroker != nil && len(a.Queue) > 0 {
+		dsn, err := k8s.QueueBroker.DSN(ctx, a.OwnerID)
+		if err != nil {
+			return nil, fmt.Errorf("get queue credentials: %w", err)
+		}
+		for _, id := range a.Queue {
+			queueURLs[id] = dsn
+		}
+	}
+	queue, err := json.MarshalIndent(queueURLs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		"secret_key": []byte(secretKey),
+		"rdb.json":   rdb,
+		"queue.json": queue,
+	}, nil
+}
+
+// EnsureSecret writes the combinator's credentials, replacing whatever the
+// Secret held before
+func (a *CombinatorAppSpec) EnsureSecret(ctx context.Context) error {
+	data, err := a.secretData(ctx)
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      a.SecretName(),
+			Namespace: k8s.CombinatorNamespace,
+			Labels:    a.Labels(),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	client := k8s.K8sClient.CoreV1().Secrets(k8s.CombinatorNamespace)
+	existing, err := client.Get(ctx, secret.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels, existing.Data, existing.StringData = secret.Labels, secret.Data, nil
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// EnsureDeployment runs after EnsureConfigMap and EnsureSecret and stamps the
+// hash of both on the pod template
+func (a *CombinatorAppSpec) EnsureDeployment(ctx context.Context) error {
+	ns := k8s.CombinatorNamespace
+	cm, err := k8s.K8sClient.CoreV1().ConfigMaps(ns).Get(ctx, a.ConfigMapName(), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get configmap: %w", err)
+	}
+	secret, err := k8s.K8sClient.CoreV1().Secrets(ns).Get(ctx, a.SecretName(), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get secret: %w", err)
+	}
+	deployment := a.deployment(configHash(&corev1.ConfigMap{Data: cm.Data, BinaryData: secret.Data}))
+	client := k8s.K8sClient.AppsV1().Deployments(k8s.CombinatorNamespace)
+	_, err = client.Get(ctx, a.Name(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, deployment, metav1.CreateOptions{})
+	} else if err == nil {
+		_, err = client.Update(ctx, deployment, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// deployment mirrors the shared combinator of scripts/combinator-deployment.yaml
+// with a single replica sized by its profile and the credentials Secret mounted at
+// COMBINATOR_SECRETS_DIR; hash is stamped on the pod template so that a
+// config or credentials change rolls the pod out
+func (a *CombinatorAppSpec) deployment(hash string) *appsv1.Deployment {
+	replicas := int32(1)
+	profile := k8s.CombinatorProfileFor(a.Profile)
+	probe := func(initialDelay, period, timeout, failures int32) *corev1.Probe {
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+				Path: "/health",
+				Port: intstr.FromInt32(combinatorWebhookPort),
+			}},
+			InitialDelaySeconds: initialDelay,
+			PeriodSeconds:       period,
+			TimeoutSeconds:      timeout,
+			FailureThreshold:    failures,
+		}
+	}
+	volumes := []corev1.Volume{{
+		Name: "config",
+		VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: a.ConfigMapName()},
+		}},
+	}}
+	mounts := []corev1.VolumeMount{{Name: "config", MountPath: "/config"}}
+	volumes = append(volumes, corev1.Volume{
+		Name:         "credentials",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: a.SecretName()}},
+	})
+	mounts = append(mounts, corev1.VolumeMount{Name: "credentials", MountPath: combinatorSecretsDir, ReadOnly: true})
+	// Same server certificate as the shared combinator (scripts/internal-ca.yaml)
+	if k8s.InternalIssuer != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name:         mtlsVolume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: combinatorMTLSSecret}},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: mtlsVolume, MountPath: k8s.MTLSMountPath, ReadOnly: true})
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      a.Name(),
+			Namespace: k8s.CombinatorNamespace,
+			Labels:    a.Labels(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": a.Name()}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      a.Labels(),
+					Annotations: map[string]string{ConfigHashAnnotation: hash},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "combinator",
+						Image: a.image(),
+						Args:  []string{"start", "-c", "/config/config.json", "-l", fmt.Sprintf("0.0.0.0:%d", combinatorPort)},
+						Env:   []corev1.EnvVar{{Name: "COMBINATOR_SECRETS_DIR", Value: combinatorSecretsDir}},
+						Ports: []corev1.ContainerPort{
+							{Name: "http", ContainerPort: combinatorPort},
+							{Name: "webhook", ContainerPort: combinatorWebhookPort},
+						},
+						VolumeMounts: mounts,
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse(profile.CPURequest),
+								corev1.ResourceMemory: resource.MustParse(profile.MemoryRequest),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse(profile.CPULimit),
+								corev1.ResourceMemory: resource.MustParse(profile.MemoryLimit),
+							},
+						},
+						LivenessProbe:  probe(10, 10, 5, 3),
+						ReadinessProbe: probe(5, 5, 3, 2),
+					}},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
+
+// EnsureService creates the combinator's internal ClusterIP Service
+func (a *CombinatorAppSpec) EnsureService(ctx context.Context) error {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      a.Name(),
+			Namespace: k8s.CombinatorNamespace,
+			Labels:    a.Labels(),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: map[string]string{"app": a.Name()},
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: combinatorPort, Protocol: corev1.ProtocolTCP},
+				{Name: "webhook", Port: combinatorWebhookPort, Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}
+	client := k8s.K8sClient.CoreV1().Services(k8s.CombinatorNamespace)
+	_, err := client.Get(ctx, a.Name(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, service, metav1.CreateOptions{})
+	}
+	return err
+}
+
+// ensureSteps lists the combinator's objects in the order reconcile ensures them
+func (a *CombinatorAppSpec) ensureSteps() []ensureStep {
+	return []ensureStep{
+		{"ConfigMap", a.ConfigMapName(), a.EnsureConfigMap},
+		{"Secret", a.SecretName(), a.EnsureSecret},
+		{"Deployment", a.Name(), a.EnsureDeployment},
+		{"Service", a.Name(), a.EnsureService},
+	}
+}
+
+// DeleteAll deletes the combinator's Deployment, Service, ConfigMap and Secret;
+// already missing objects are not an error
+func (a *CombinatorAppSpec) DeleteAll(ctx context.Context) error {
+	var errs []error
+	del := func(kind string, err error) {
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete %s: %w", kind, err))
+		}
+	}
+	ns := k8s.CombinatorNamespace
+	del("deployment", k8s.K8sClient.AppsV1().Deployments(ns).Delete(ctx, a.Name(), metav1.DeleteOptions{}))
+	del("service", k8s.K8sClient.CoreV1().Services(ns).Delete(ctx, a.Name(), metav1.DeleteOptions{}))
+	del("configmap", k8s.K8sClient.CoreV1().ConfigMaps(ns).Delete(ctx, a.ConfigMapName(), metav1.DeleteOptions{}))
+	del("secret", k8s.K8sClient.CoreV1().Secrets(ns).Delete(ctx, a.SecretName(), metav1.DeleteOptions{}))
+	return stderrors.Join(errs...)
+}
+
+func combinatorFromUnstructured(u *unstructured.Unstructured) *CombinatorAppSpec {
+	spec, _ := u.Object["spec"].(map[string]interface{})
+	if spec == nil {
+		return nil
+	}
+	strs := func(key string) []string {
+		var out []string
+		items, _ := spec[key].([]interface{})
+		for _, v := range items {
+			out = append(out, fmt.Sprintf("%v", v))
+		}
+		return out
+	}
+	revision, _ := spec["revision"].(int64)
+	return &CombinatorAppSpec{
+		OwnerID:  strVal(spec, "ownerID"),
+		Image:    strVal(spec, "image"),
+		RDB:      strs("rdb"),
+		KV:       strs("kv"),
+		Queue:    strs("queue"),
+		Revision: revision,
+		Profile:  strVal(spec, "profile"),
+	}
+}
+
+// --- CR CRUD (used by jobs and handlers) ---
+
+// SyncCombinatorAppCR makes app.OwnerID's CombinatorApp CR match app: its
+// RDB, KV and queue resources at config revision app.Revision, sized by
+// app.Profile. The CR is created on the first resource and deleted with the
+// last one.
+func SyncCombinatorAppCR(ctx context.Context, client dynamic.Interface, app CombinatorAppSpec) error {
+	res := client.Resource(CombinatorAppGVR).Namespace(k8s.CombinatorNamespace)
+	name := app.Name()
+	existing, err := res.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("get CR %s: %w", name, err)
+	}
+	found := err == nil
+
+	if len(app.RDB) == 0 && len(app.KV) == 0 && len(app.Queue) == 0 {
+		if !found {
+			return nil
+		}
+		err := res.Delete(ctx, name, metav1.DeleteOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	rdb, kv := slices.Sorted(slices.Values(app.RDB)), slices.Sorted(slices.Values(app.KV))
+	queue := slices.Sorted(slices.Values(app.Queue))
+	list := func(ids []string) []interface{} {
+		out := make([]interface{}, len(ids))
+		for i, v := range ids {
+			out[i] = v
+		}
+		return out
+	}
+	if !found {
+		spec := map[string]any{
+			"ownerID":  app.OwnerID,
+			"rdb":      list(rdb),
+			"kv":       list(kv),
+			"queue":    list(queue),
+			"revision": app.Revision,
+		}
+		if app.Profile != "" {
+			spec["profile"] = app.Profile
+		}
+		cr := &unstructured.Unstructured{
+			Object: map[string]any{
+				"apiVersion": Group + "/" + Version,
+				"kind":       CombinatorKind,
+				"metadata": map[string]any{
+					"name":      name,
+					"namespace": k8s.CombinatorNamespace,
+				},
+				"spec": spec,
+			},
+		}
+		_, err := res.Create(ctx, cr, metav1.CreateOptions{})
+		return err
+	}
+
+	current := combinatorFromUnstructured(existing)
+	if current != nil && current.Revision >= app.Revision && current.Profile == app.Profile &&
+		slices.Equal(current.RDB, rdb) && slices.Equal(current.KV, kv) && slices.Equal(current.Queue, queue) {
+		return nil
+	}
+	spec, _ := existing.Object["spec"].(map[string]interface{})
+	if spec == nil {
+		spec = map[string]interface{}{"ownerID": app.OwnerID}
+		existing.Object["spec"] = spec
+	}
+	spec["rdb"], spec["kv"], spec["queue"], spec["revision"] = list(rdb), list(kv), list(queue), app.Revision
+	if app.Profile != "" {
+		spec["profile"] = app.Profile
+	} else {
+		delete(spec, "profile")
+	}
+	_, err = res.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// GetCombinatorAppStatus reads the phase of the user's CombinatorApp CR and
+// the replicas of its Deployment
+func GetCombinatorAppStatus(ctx context.Context, client dynamic.Interface, ownerID string) (*CombinatorAppStatus, error) {
+	name := CombinatorAppName(ownerID)
+	s := &CombinatorAppStatus{Name: name, Phase: CombinatorPhaseAbsent, RDB: []string{}, KV: []string{}, Queue: []string{}}
+	u, err := client.Resource(CombinatorAppGVR).Namespace(k8s.CombinatorNamespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get CR %s: %w", name, err)
+	}
+	if spec := combinatorFromUnstructured(u); spec != nil {
+		if spec.RDB != nil {
+			s.RDB = spec.RDB
+		}
+		if spec.KV != nil {
+			s.KV = spec.KV
+		}
+		if spec.Queue != nil {
+			s.Queue = spec.Queue
+		}
+		s.Revision = spec.Revision
+		s.Profile = k8s.CombinatorProfileFor(spec.Profile)
+	}
+	cfg, err := dblayer.GetCombinatorConfig(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("get config revision: %w", err)
+	}
+	s.AppliedRevision = cfg.AppliedRevision
+	s.Phase, _, _ = unstructured.NestedString(u.Object, "status", "phase")
+	s.Message, _, _ = unstructured.NestedString(u.Object, "status", "message")
+	if s.Phase == "" {
+		s.Phase = "Pending" // not reconciled yet
+	}
+	d, err := k8s.K8sClient.AppsV1().Deployments(k8s.CombinatorNamespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		if d.Spec.Replicas != nil {
+			s.Replicas = *d.Spec.Replicas
+		}
+		s.ReadyReplicas = d.Status.ReadyReplicas
+	} else if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("get deployment %s: %w", name, err)
+	}
+	// Prometheus being down does not fail the status
+	if s.Recommendation, err = k8s.RecommendCombinatorProfile(ctx, name); err != nil {
+		ctrlLog.Warn("recommend combinator profile failed", "name", name, "error", err)
+	}
+	return s, nil
+}
+
+// GetCombinatorRuntime reads the pods of the user's dedicated combinator and
+// the config revision they have loaded. The shared combinator serves every
+// user, so its pods are not reported.
+func GetCombinatorRuntime(ctx context.Context, ownerID string) (*CombinatorRuntimeStatus, error) {
+	name := CombinatorAppName(ownerID)
+	s := &CombinatorRuntimeStatus{Name: name, Dedicated: CombinatorApps, Phase: CombinatorPhaseAbsent, Pods: []WorkerPodStatus{}}
+	cfg, err := dblayer.GetCombinatorConfig(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("get config revision: %w", err)
+	}
+	s.Revision, s.AppliedRevision, s.AppliedAt = cfg.Revision, cfg.AppliedRevision, cfg.AppliedAt
+	if !CombinatorApps {
+		return s, nil
+	}
+
+	u, err := k8s.DynamicClient.Resource(CombinatorAppGVR).Namespace(k8s.CombinatorNamespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		s.Phase, _, _ = unstructured.NestedString(u.Object, "status", "phase")
+		s.Message, _, _ = unstructured.NestedString(u.Object, "status", "message")
+		if s.Phase == "" {
+			s.Phase = "Pending" // not reconciled yet
+		}
+	} else if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("get CR %s: %w", name, err)
+	}
+	d, err := k8s.K8sClient.AppsV1().Deployments(k8s.CombinatorNamespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		s.Deployed = true
+		if d.Spec.Replicas != nil {
+			s.Replicas = *d.Spec.Replicas
+		}
+		s.ReadyReplicas = d.Status.ReadyReplicas
+		s.UpdatedReplicas = d.Status.UpdatedReplicas
+	} else if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("get deployment %s: %w", name, err)
+	}
+	pods, err := combinatorPods(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		s.Pods = append(s.Pods, podStatus(&pods[i]))
+	}
+	return s, nil
+}
+
+// CombinatorLogs returns the last tailLines lines of every pod of the user's
+// dedicated combinator, oldest pod first. With previous set it reads the
+// container that ran before the last restart, which is where a crash shows.
+func CombinatorLogs(ctx context.Context, ownerID string, tailLines int64, previous bool) ([]LogLine, error) {
+	pods, err := combinatorPods(ctx, CombinatorAppName(ownerID))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(pods, func(a, b corev1.Pod) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	})
+
+	lines := []LogLine{}
+	for _, pod := range pods {
+		opts := &corev1.PodLogOptions{Container: "combinator", TailLines: &tailLines, Previous: previous}
+		raw, err := k8s.K8sClient.CoreV1().Pods(k8s.CombinatorNamespace).GetLogs(pod.Name, opts).DoRaw(ctx)
+		if err != nil {
+			// not started yet, or no previous container
+			lines = append(lines, LogLine{Pod: pod.Name, Line: fmt.Sprintf("[console] cannot read logs: %v", err)})
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(raw), "\n"), "\n") {
+			if line != "" {
+				lines = append(lines, LogLine{Pod: pod.Name, Line: line})
+			}
+		}
+	}
+	return lines, nil
+}
+
+// RestartCombinatorApp rolls the pods of the user's dedicated combinator with
+// the same annotation a worker restart uses
+func RestartCombinatorApp(ctx context.Context, ownerID string) error {
+	return RestartWorkerDeployment(ctx, k8s.K8sClient, k8s.CombinatorNamespace, CombinatorAppName(ownerID))
+}
+
+func combinatorPods(ctx context.Context, name string) ([]corev1.Pod, error) {
+	pods, err := k8s.K8sClient.CoreV1().Pods(k8s.CombinatorNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=" + name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods for %s: %w", name, err)
+	}
+	return pods.Items, nil
+}
@@ -2,10 +2,11 @@ package controller
 
 import (
 	"context"
-	"log"
 	"time"
 
+	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
+	"jabberwocky238/console/logging"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -17,15 +18,19 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
+var ctrlLog = logging.Component("controller")
+
 type Controller struct {
-	client    dynamic.Interface
-	k8sClient *kubernetes.Clientset
-	worker    *WorkerController
+	client     dynamic.Interface
+	k8sClient  kubernetes.Interface
+	worker     *WorkerController
+	combinator *CombinatorController
 }
 
-func NewController(client dynamic.Interface, k8sClient *kubernetes.Clientset) *Controller {
+func NewController(client dynamic.Interface, k8sClient kubernetes.Interface) *Controller {
 	c := &Controller{client: client, k8sClient: k8sClient}
 	c.worker = &WorkerController{ctrl: c}
+	c.combinator = &CombinatorController{ctrl: c}
 	return c
 }
 
@@ -43,15 +48,28 @@ func (c *Controller) Start(stopCh <-chan struct{}) {
 		DeleteFunc: c.worker.onDelete,
 	})
 
-	// 2. Sub-resource informer: Deployment + Service + ConfigMap + Secret in worker namespace
+	// CombinatorApp in combinator namespace, one per user with a dedicated combinator
+	combinatorDynFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(
+		c.client, 30*time.Second, k8s.CombinatorNamespace, nil,
+	)
+	combinatorInformer := combinatorDynFactory.ForResource(CombinatorAppGVR).Informer()
+	c.combinator.crCache = combinatorInformer.GetStore()
+	combinatorInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.combinator.onAdd,
+		UpdateFunc: c.combinator.onUpdate,
+		DeleteFunc: c.combinator.onDelete,
+	})
+
+	// 2. Sub-resource informer: Deployment + Service + ConfigMap + Secret in worker namespace,
+	// or in every namespace (only objects with an owner-id label) with tenant namespaces
 	k8sFactory := informers.NewSharedInformerFactoryWithOptions(
-		c.k8sClient, 30*time.Second,
-		informers.WithNamespace(k8s.WorkerNamespace),
+		c.k8sClient, 30*time.Second, subResourceScope()...,
 	)
 	subHandler := cache.ResourceEventHandlerFuncs{
 		DeleteFunc: c.worker.onSubResourceDelete,
 	}
-	k8sFactory.Apps().V1().Deployments().Informer().AddEventHandler(subHandler)
+	deployInformer := k8sFactory.Apps().V1().Deployments().Informer()
+	deployInformer.AddEventHandler(subHandler)
 	k8sFactory.Core().V1().Services().Informer().AddEventHandler(subHandler)
 
 	// Watch ConfigMap and Secret updates to trigger Deployment rolling restart
@@ -59,28 +77,72 @@ func (c *Controller) Start(stopCh <-chan struct{}) {
 		UpdateFunc: c.worker.onConfigUpdate,
 	}
 	k8sFactory.Core().V1().ConfigMaps().Informer().AddEventHandler(configHandler)
-	k8sFactory.Core().V1().Secrets().Informer().AddEventHandler(configHandler)
+	secretInformer := k8sFactory.Core().V1().Secrets().Informer()
+	secretInformer.AddEventHandler(configHandler)
+
+	// Watch worker pods for restarts / OOM kills (alert rules)
+	podInformer := k8sFactory.Core().V1().Pods().Informer()
+	podIndexer := addOwnerIndex(podInformer, cache.Indexers{podAppIndex: podAppIndexFunc})
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: podSignals.onPodUpdate,
+	})
+	podSignals.mu.Lock()
+	podSignals.pods = podIndexer
+	podSignals.mu.Unlock()
 
-	// 3. IngressRoute informer: watch IngressRoute in ingress namespace
+	// 3. Route informer: watch the ingress provider's routes in ingress namespace
 	ingressDynFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(
 		c.client, 30*time.Second, k8s.IngressNamespace, nil,
 	)
-	irInformer := ingressDynFactory.ForResource(k8s.IngressRouteGVR).Informer()
+	irInformer := ingressDynFactory.ForResource(k8s.Ingress.GVR()).Informer()
 	irInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		DeleteFunc: c.worker.onSubResourceDelete,
 	})
 
+	// 4. Read cache: the same informers, indexed by owner, serve read paths
+	readCache.deployments = addOwnerIndex(deployInformer, nil)
+	readCache.pods = podIndexer
+	readCache.secrets = addOwnerIndex(secretInformer, nil)
+	readCache.routes = addOwnerIndex(irInformer, nil)
 
-	log.Println("[controller] starting informers")
+	// Events recorded on WorkerApp CRs, shown in the worker status
+	eventFactory := informers.NewSharedInformerFactoryWithOptions(
+		c.k8sClient, 30*time.Second,
+		informers.WithNamespace(k8s.WorkerNamespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = "involvedObject.kind=" + WorkerKind
+		}),
+	)
+	eventInformer := eventFactory.Core().V1().Events().Informer()
+	if err := eventInformer.AddIndexers(cache.Indexers{eventObjectIndex: eventObjectIndexFunc}); err != nil {
+		ctrlLog.Error("add event indexer failed", "error", err)
+	}
+	readCache.events = eventInformer.GetIndexer()
+
+
+	ctrlLog.Info("starting informers")
 	go dynFactory.Start(stopCh)
+	go combinatorDynFactory.Start(stopCh)
 	go k8sFactory.Start(stopCh)
 	go ingressDynFactory.Start(stopCh)
+	go eventFactory.Start(stopCh)
+	c.startCombinatorWatch(stopCh)
+	c.startCombinatorAppWatch(stopCh)
+
+	go func() {
+		if !cache.WaitForCacheSync(stopCh, deployInformer.HasSynced, podInformer.HasSynced, secretInformer.HasSynced, irInformer.HasSynced, eventInformer.HasSynced) {
+			ctrlLog.Error("failed to sync read cache")
+			return
+		}
+		readCache.synced.Store(true)
+		ctrlLog.Info("read cache synced")
+	}()
 
 	if !cache.WaitForCacheSync(stopCh, crInformer.HasSynced) {
-		log.Println("[controller] failed to sync CR informer cache")
+		ctrlLog.Error("failed to sync CR informer cache")
 		return
 	}
-	log.Println("[controller] informer cache synced")
+	ctrlLog.Info("informer cache synced")
 }
 
 func (c *Controller) updateStatus(u *unstructured.Unstructured, gvr schema.GroupVersionResource, phase, message string) {
@@ -88,7 +150,7 @@ func (c *Controller) updateStatus(u *unstructured.Unstructured, gvr schema.Group
 
 	latest, err := client.Get(context.Background(), u.GetName(), metav1.GetOptions{})
 	if err != nil {
-		log.Printf("[controller] get latest %s for status update failed: %v", u.GetName(), err)
+		ctrlLog.Error("get latest object for status update failed", "name", u.GetName(), "error", err)
 		return
 	}
 
@@ -96,11 +158,50 @@ func (c *Controller) updateStatus(u *unstructured.Unstructured, gvr schema.Group
 		latest.Object["status"] = map[string]interface{}{}
 	}
 	status := latest.Object["status"].(map[string]interface{})
+	prevPhase, _ := status["phase"].(string)
 	status["phase"] = phase
 	status["message"] = message
 
 	_, err = client.UpdateStatus(context.Background(), latest, metav1.UpdateOptions{})
 	if err != nil {
-		log.Printf("[controller] update status for %s failed: %v", u.GetName(), err)
+		ctrlLog.Error("update status failed", "name", u.GetName(), "error", err)
+		return
+	}
+
+	if phase != prevPhase && gvr == CombinatorAppGVR {
+		ownerID, _, _ := unstructured.NestedString(latest.Object, "spec", "ownerID")
+		dblayer.PublishEvent(context.Background(), dblayer.Event{
+			UserUID:      ownerID,
+			Kind:         dblayer.EventCombinatorPhase,
+			ResourceType: "combinator",
+			ResourceID:   u.GetName(),
+			Status:       phase,
+			Message:      message,
+		})
+	}
+	if phase != prevPhase && gvr == WorkerAppGVR {
+		ownerID, _, _ := unstructured.NestedString(latest.Object, "spec", "ownerID")
+		workerID, _, _ := unstructured.NestedString(latest.Object, "spec", "workerID")
+		dblayer.PublishEvent(context.Background(), dblayer.Event{
+			UserUID:      ownerID,
+			Kind:         dblayer.EventWorkerPhase,
+			ResourceType: "worker",
+			ResourceID:   workerID,
+			Status:       phase,
+			Message:      message,
+		})
+	}
+}
+
+// subResourceScope limits the sub-resource informers to where worker objects live
+func subResourceScope() []informers.SharedInformerOption {
+	if !k8s.TenantNamespaces {
+		return []informers.SharedInformerOption{informers.WithNamespace(k8s.WorkerNamespace)}
+	}
+	return []informers.SharedInformerOption{
+		informers.WithNamespace(metav1.NamespaceAll),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = "owner-id"
+		}),
 	}
 }
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// workerObjects is what one cluster holds for workers, keyed by
+// "<kind>/<namespace>/<name>". Lists go to the apiserver rather than the read
+// cache so the audit sees what is really there, on every cluster.
+type workerObjects map[string]metav1.Object
+
+func objectKey(kind, ns, name string) string {
+	return kind + "/" + ns + "/" + name
+}
+
+// listWorkerObjects lists every object labelled worker-id on c
+func listWorkerObjects(ctx context.Context, c *k8s.Cluster) (workerObjects, error) {
+	opts := metav1.ListOptions{LabelSelector: "worker-id"}
+	objs := workerObjects{}
+	deployments, err := c.Kube.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		objs[objectKey("Deployment", d.Namespace, d.Name)] = d
+	}
+	services, err := c.Kube.CoreV1().Services(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+	for i := range services.Items {
+		s := &services.Items[i]
+		objs[objectKey("Service", s.Namespace, s.Name)] = s
+	}
+	configMaps, err := c.Kube.CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list configmaps: %w", err)
+	}
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		objs[objectKey("ConfigMap", cm.Namespace, cm.Name)] = cm
+	}
+	secrets, err := c.Kube.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list secrets: %w", err)
+	}
+	for i := range secrets.Items {
+		s := &secrets.Items[i]
+		objs[objectKey("Secret", s.Namespace, s.Name)] = s
+	}
+	routes, err := k8s.Routes(c.Dynamic).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", k8s.Ingress.GVR().Resource, err)
+	}
+	for i := range routes.Items {
+		r := &routes.Items[i]
+		objs[objectKey(k8s.Ingress.Kind(), r.GetNamespace(), r.GetName())] = r
+	}
+	return objs, nil
+}
+
+// WorkerDrift compares the deployed workers in the database with their
+// WorkerApp CRs and with the objects reconcile creates for them on their
+// cluster. Objects of workers that no longer exist are reported as extra;
+// those of workers that exist but have not been deployed yet are skipped.
+// A cluster that cannot be listed is reported in the returned error and its
+// workers are left out.
+func WorkerDrift(ctx context.Context, workers []*dblayer.DeployedWorker) ([]k8s.Drift, error) {
+	if k8s.DynamicClient == nil {
+		return nil, fmt.Errorf("k8s client not initialized")
+	}
+	crs, err := k8s.DynamicClient.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list worker CRs: %w", err)
+	}
+	crByName := map[string]*WorkerAppSpec{}
+	for i := range crs.Items {
+		if w := workerFromUnstructured(&crs.Items[i]); w != nil {
+			crByName[crs.Items[i].GetName()] = w
+		}
+	}
+
+	var errs []error
+	live := map[string]workerObjects{}
+	for _, c := range k8s.Clusters.List() {
+		objs, err := listWorkerObjects(ctx, c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", c.Name, err))
+			continue
+		}
+		live[c.Name] = objs
+	}
+
+	var drift []k8s.Drift
+	seen := map[string]bool{} // "<cluster>/<key>" of expected objects
+	for _, dw := range workers {
+		cluster := dw.Cluster
+		if cluster == "" {
+			cluster = k8s.LocalCluster
+		}
+		w := &WorkerAppSpec{WorkerID: dw.WID, OwnerID: dw.UserUID, Cluster: cluster, Image: dw.Image, Port: dw.Port}
+		add := func(kind k8s.DriftKind, cluster, resource, ns, name, detail string) {
+			drift = append(drift, k8s.Drift{
+				Kind: kind, Source: k8s.DriftSourceWorker, SourceID: w.WorkerID, OwnerID: w.OwnerID,
+				Cluster: cluster, Resource: resource, Namespace: ns, Name: name, Detail: detail,
+			})
+		}
+
+		if cr := crByName[w.Name()]; cr == nil {
+			add(k8s.DriftMissing, k8s.LocalCluster, "WorkerApp", k8s.WorkerNamespace, w.Name(), "")
+		} else {
+			seen[k8s.LocalCluster+"/WorkerApp/"+w.Name()] = true
+			if cr.Image != w.Image {
+				add(k8s.DriftModified, k8s.LocalCluster, "WorkerApp", k8s.WorkerNamespace, w.Name(),
+					fmt.Sprintf("image is %s, active version %d has %s", cr.Image, dw.VersionID, w.Image))
+			}
+			if cr.Cluster != "" && cr.Cluster != cluster {
+				add(k8s.DriftModified, k8s.LocalCluster, "WorkerApp", k8s.WorkerNamespace, w.Name(),
+					fmt.Sprintf("cluster is %s, want %s", cr.Cluster, cluster))
+			}
+		}
+
+		objs, ok := live[cluster]
+		if !ok {
+			if _, err := k8s.Clusters.Get(cluster); err != nil {
+				add(k8s.DriftMissing, cluster, "Cluster", "", cluster, err.Error())
+			}
+			continue
+		}
+		expect := func(resource, kind, ns, name string) (metav1.Object, bool) {
+			key := objectKey(kind, ns, name)
+			seen[cluster+"/"+key] = true
+			obj, ok := objs[key]
+			if !ok {
+				add(k8s.DriftMissing, cluster, resource, ns, name, "")
+			}
+			return obj, ok
+		}
+
+		ns := w.Namespace()
+		if obj, ok := expect("Deployment", "Deployment", ns, w.Name()); ok {
+			if image := deploymentImage(obj); image != w.Image {
+				add(k8s.DriftModified, cluster, "Deployment", ns, w.Name(), fmt.Sprintf("image is %s, want %s", image, w.Image))
+			}
+		}
+		if obj, ok := expect("Service", "Service", ns, w.Name()); ok {
+			if svc := obj.(*corev1.Service); svc.Spec.ClusterIP != corev1.ClusterIPNone {
+				add(k8s.DriftModified, cluster, "Service", ns, w.Name(), "service is not headless")
+			}
+		}
+		expect("ConfigMap", "ConfigMap", ns, w.EnvConfigMapName())
+		if obj, ok := expect("Secret", "Secret", ns, w.SecretName()); ok {
+			sec := obj.(*corev1.Secret)
+			for _, key := range ReservedEnvKeys {
+				if _, ok := sec.Data[key]; !ok {
+					add(k8s.DriftModified, cluster, "Secret", ns, w.SecretName(), "missing system key "+key)
+					break
+				}
+			}
+		}
+		if obj, ok := expect("ExternalName Service", "Service", k8s.IngressNamespace, w.ExternalNameServiceName()); ok {
+			want := fmt.Sprintf("%s.%s.svc.cluster.local", w.Name(), ns)
+			if svc := obj.(*corev1.Service); svc.Spec.ExternalName != want {
+				add(k8s.DriftModified, cluster, "ExternalName Service", k8s.IngressNamespace, w.ExternalNameServiceName(),
+					fmt.Sprintf("externalName is %q, want %q", svc.Spec.ExternalName, want))
+			}
+		}
+		if obj, ok := expect(k8s.Ingress.Kind(), k8s.Ingress.Kind(), k8s.IngressNamespace, w.Name()); ok {
+			want := WorkerHost(w.WorkerID, w.OwnerID)
+			if host := k8s.Ingress.Host(obj.(*unstructured.Unstructured)); host != want {
+				add(k8s.DriftModified, cluster, k8s.Ingress.Kind(), k8s.IngressNamespace, w.Name(), fmt.Sprintf("host is %q, want %q", host, want))
+			}
+		}
+	}
+
+	// Extra objects: labelled for a worker but not expected by any deployed one
+	exists := map[string]bool{}
+	workerExists := func(workerID, ownerID string) bool {
+		key := workerID + "/" + ownerID
+		if e, ok := exists[key]; ok {
+			return e
+		}
+		_, err := dblayer.GetWorkerByOwner(ctx, workerID, ownerID)
+		e := !stderrors.Is(err, sql.ErrNoRows)
+		exists[key] = e
+		return e
+	}
+	for name, cr := range crByName {
+		if seen[k8s.LocalCluster+"/WorkerApp/"+name] || workerExists(cr.WorkerID, cr.OwnerID) {
+			continue
+		}
+		drift = append(drift, k8s.Drift{
+			Kind: k8s.DriftExtra, Source: k8s.DriftSourceWorker, SourceID: cr.WorkerID, OwnerID: cr.OwnerID,
+			Cluster: k8s.LocalCluster, Resource: "WorkerApp", Namespace: k8s.WorkerNamespace, Name: name,
+		})
+	}
+	for cluster, objs := range live {
+		for key, obj := range objs {
+			labels := obj.GetLabels()
+			if seen[cluster+"/"+key] || workerExists(labels["worker-id"], labels["owner-id"]) {
+				continue
+			}
+			kind, _, _ := strings.Cut(key, "/")
+			drift = append(drift, k8s.Drift{
+				Kind: k8s.DriftExtra, Source: k8s.DriftSourceWorker, SourceID: labels["worker-id"], OwnerID: labels["owner-id"],
+				Cluster: cluster, Resource: kind, Namespace: obj.GetNamespace(), Name: obj.GetName(),
+			})
+		}
+	}
+	return drift, stderrors.Join(errs...)
+}
+
+// deploymentImage is the image of the worker container
+func deploymentImage(obj metav1.Object) string {
+	containers := obj.(*appsv1.Deployment).Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return ""
+	}
+	return containers[0].Image
+}
+
+// EnsureWorker runs the reconcile steps for a worker's WorkerApp CR without
+// changing its status, to repair objects that drifted from it.
+func EnsureWorker(ctx context.Context, workerID, ownerID string) error {
+	if k8s.DynamicClient == nil {
+		return fmt.Errorf("k8s client not initialized")
+	}
+	u, err := k8s.DynamicClient.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).Get(ctx, WorkerName(workerID, ownerID), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get worker CR: %w", err)
+	}
+	w := workerFromUnstructured(u)
+	if w == nil {
+		return fmt.Errorf("worker CR %s has no spec", u.GetName())
+	}
+	for _, step := range w.ensureSteps() {
+		if err := step.ensure(ctx); err != nil {
+			k8s.RecordWarning(u, k8s.EventReasonEnsureFailed, "Repair %s %s failed: %v", step.kind, step.name, err)
+			return fmt.Errorf("ensure %s: %w", step.kind, err)
+		}
+	}
+	k8s.RecordEvent(u, k8s.EventReasonEnsured, "Repaired objects that drifted from the spec")
+	return nil
+}
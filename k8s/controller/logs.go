@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sync"
+
+	"jabberwocky238/console/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// LogLine is a single line of container output from one of a worker's pods
+type LogLine struct {
+	Pod  string `json:"pod"`
+	Line string `json:"line"`
+}
+
+// StreamWorkerLogs follows the logs of every pod currently running for the
+// worker name in namespace of cluster c and calls emit for each line, starting with the last tailLines
+// lines of each pod. emit is never called concurrently. It blocks until ctx
+// is cancelled or all pod streams have ended.
+func StreamWorkerLogs(ctx context.Context, c *k8s.Cluster, namespace, name string, tailLines int64, emit func(LogLine)) error {
+	podNames, err := workerPodNames(ctx, c, namespace, name)
+	if err != nil {
+		return err
+	}
+	if len(podNames) == 0 {
+		return fmt.Errorf("no running pods for %s", name)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, podName := range podNames {
+		opts := &corev1.PodLogOptions{Container: name, Follow: true}
+		if tailLines > 0 {
+			opts.TailLines = &tailLines
+		}
+		stream, err := c.Kube.CoreV1().Pods(namespace).GetLogs(podName, opts).Stream(ctx)
+		if err != nil {
+			// pod may still be starting; the others are still worth following
+			mu.Lock()
+			emit(LogLine{Pod: podName, Line: fmt.Sprintf("[console] cannot stream logs: %v", err)})
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(podName string) {
+			defer wg.Done()
+			defer stream.Close()
+			scanner := bufio.NewScanner(stream)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				mu.Lock()
+				emit(LogLine{Pod: podName, Line: scanner.Text()})
+				mu.Unlock()
+			}
+		}(podName)
+	}
+	wg.Wait()
+	return nil
+}
+
+// workerPodNames lists the worker's pods from the read cache, or from the
+// apiserver while the cache is still syncing or for remote clusters.
+func workerPodNames(ctx context.Context, c *k8s.Cluster, namespace, name string) ([]string, error) {
+	var names []string
+	if c.Local {
+		if pods, ok := cachedWorkerPods(name); ok {
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			return names, nil
+		}
+	}
+	pods, err := c.Kube.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{"app": name}.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods for %s: %w", name, err)
+	}
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return names, nil
+}
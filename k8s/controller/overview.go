@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"cmp"
+	"slices"
+
+	"jabberwocky238/console/k8s"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// OwnerConsumption is what one owner's workers reserve
+type OwnerConsumption struct {
+	OwnerID       string  `json:"owner_id"`
+	Workers       int     `json:"workers"`
+	ReadyReplicas int32   `json:"ready_replicas"`
+	CPUCores      float64 `json:"cpu_cores"`
+	MemoryBytes   float64 `json:"memory_bytes"`
+}
+
+// ClusterConsumption is what workers reserve on the local cluster right now:
+// the requests of ready replicas, like metering, plus pods by phase
+type ClusterConsumption struct {
+	Cluster       string             `json:"cluster"`
+	Workers       int                `json:"workers"`
+	ReadyReplicas int32              `json:"ready_replicas"`
+	Pods          map[string]int     `json:"pods"`
+	CPUCores      float64            `json:"cpu_cores"`
+	MemoryBytes   float64            `json:"memory_bytes"`
+	TopOwners     []OwnerConsumption `json:"top_owners"` // by CPU, then memory
+}
+
+// CachedConsumption aggregates the worker Deployments and pods of the local
+// cluster from the informer cache, with the top owners by reserved CPU. It
+// returns ErrCacheNotSynced until the cache has synced.
+func CachedConsumption(top int) (*ClusterConsumption, error) {
+	if !readCache.ready() {
+		return nil, ErrCacheNotSynced
+	}
+	out := &ClusterConsumption{Cluster: k8s.LocalCluster, Pods: map[string]int{}}
+	owners := map[string]*OwnerConsumption{}
+	for _, obj := range readCache.deployments.List() {
+		d, ok := obj.(*appsv1.Deployment)
+		if !ok || d.Labels["worker-id"] == "" {
+			continue
+		}
+		ready := d.Status.ReadyReplicas
+		var cpu, memory float64
+		for _, ctr := range d.Spec.Template.Spec.Containers {
+			cpu += ctr.Resources.Requests.Cpu().AsApproximateFloat64()
+			memory += ctr.Resources.Requests.Memory().AsApproximateFloat64()
+		}
+		cpu, memory = cpu*float64(ready), memory*float64(ready)
+
+		out.Workers++
+		out.ReadyReplicas += ready
+		out.CPUCores += cpu
+		out.MemoryBytes += memory
+
+		ownerID := d.Labels["owner-id"]
+		o := owners[ownerID]
+		if o == nil {
+			o = &OwnerConsumption{OwnerID: ownerID}
+			owners[ownerID] = o
+		}
+		o.Workers++
+		o.ReadyReplicas += ready
+		o.CPUCores += cpu
+		o.MemoryBytes += memory
+	}
+	for _, obj := range readCache.pods.List() {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			out.Pods[string(pod.Status.Phase)]++
+		}
+	}
+
+	out.TopOwners = make([]OwnerConsumption, 0, len(owners))
+	for _, o := range owners {
+		out.TopOwners = append(out.TopOwners, *o)
+	}
+	slices.SortFunc(out.TopOwners, func(a, b OwnerConsumption) int {
+		return cmp.Or(cmp.Compare(b.CPUCores, a.CPUCores), cmp.Compare(b.MemoryBytes, a.MemoryBytes), cmp.Compare(a.OwnerID, b.OwnerID))
+	})
+	if len(out.TopOwners) > top {
+		out.TopOwners = out.TopOwners[:top]
+	}
+	return out, nil
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Worker pod signals for alert rules and remediation policies. The pod informer reports every
+// container restart and OOM kill as it happens; they are kept in memory for
+// podSignalRetention, so after an inner restart the windows start empty.
+
+const (
+	podSignalRetention = time.Hour
+	podAppIndex        = "app"
+)
+
+// PodSignals is what the pods of one worker did within a window
+type PodSignals struct {
+	Restarts     int // container restarts within the window
+	OOMKills     int // restarts caused by OOMKilled within the window
+	CrashLooping int // containers currently waiting in CrashLoopBackOff
+}
+
+type podSignalRecorder struct {
+	mu        sync.Mutex
+	pods      cache.Indexer
+	restarts  map[string][]time.Time // keyed by worker name (pod "app" label)
+	oomKills  map[string][]time.Time
+	onTrouble func(workerID, ownerID string)
+}
+
+// OnWorkerPodTrouble sets fn to be called, from the pod informer, whenever a
+// worker's container is OOM killed or enters CrashLoopBackOff. fn must not
+// block; remediation policies are evaluated from it.
+func OnWorkerPodTrouble(fn func(workerID, ownerID string)) {
+	podSignals.mu.Lock()
+	podSignals.onTrouble = fn
+	podSignals.mu.Unlock()
+}
+
+var podSignals = &podSignalRecorder{
+	restarts: make(map[string][]time.Time),
+	oomKills: make(map[string][]time.Time),
+}
+
+func podAppIndexFunc(obj any) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, nil
+	}
+	if app := pod.Labels["app"]; app != "" {
+		return []string{app}, nil
+	}
+	return nil, nil
+}
+
+func (r *podSignalRecorder) onPodUpdate(oldObj, newObj any) {
+	oldPod, ok1 := oldObj.(*corev1.Pod)
+	pod, ok2 := newObj.(*corev1.Pod)
+	if !ok1 || !ok2 {
+		return
+	}
+	app := pod.Labels["app"]
+	if app == "" {
+		return
+	}
+
+	prev := make(map[string]int32, len(oldPod.Status.ContainerStatuses))
+	wasLooping := make(map[string]bool, len(oldPod.Status.ContainerStatuses))
+	for _, cs := range oldPod.Status.ContainerStatuses {
+		prev[cs.Name] = cs.RestartCount
+		wasLooping[cs.Name] = crashLooping(cs)
+	}
+
+	now := time.Now()
+	trouble := false
+	r.mu.Lock()
+	for _, cs := range pod.Status.ContainerStatuses {
+		if crashLooping(cs) && !wasLooping[cs.Name] {
+			trouble = true
+		}
+		n := cs.RestartCount - prev[cs.Name]
+		if n <= 0 {
+			continue
+		}
+		for i := int32(0); i < n; i++ {
+			r.restarts[app] = append(r.restarts[app], now)
+		}
+		if t := cs.LastTerminationState.Terminated; t != nil && t.Reason == "OOMKilled" {
+			r.oomKills[app] = append(r.oomKills[app], now)
+			trouble = true
+		}
+	}
+	r.pruneLocked(app, now)
+	onTrouble := r.onTrouble
+	r.mu.Unlock()
+
+	if trouble && onTrouble != nil && pod.Labels["worker-id"] != "" {
+		onTrouble(pod.Labels["worker-id"], pod.Labels["owner-id"])
+	}
+}
+
+func crashLooping(cs corev1.ContainerStatus) bool {
+	return cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff"
+}
+
+// pruneLocked drops samples older than the retention
+func (r *podSignalRecorder) pruneLocked(app string, now time.Time) {
+	cutoff := now.Add(-podSignalRetention)
+	for _, m := range []map[string][]time.Time{r.restarts, r.oomKills} {
+		ts := m[app]
+		i := 0
+		for i < len(ts) && ts[i].Before(cutoff) {
+			i++
+		}
+		if i == len(ts) {
+			delete(m, app)
+		} else if i > 0 {
+			m[app] = append([]time.Time(nil), ts[i:]...)
+		}
+	}
+}
+
+func countSince(ts []time.Time, since time.Time) int {
+	n := 0
+	for _, t := range ts {
+		if !t.Before(since) {
+			n++
+		}
+	}
+	return n
+}
+
+// WorkerPodSignals returns the restarts and OOM kills of a worker's pods in
+// the last window (at most one hour) and how many containers are crash looping.
+func WorkerPodSignals(workerID, ownerID string, window time.Duration) (PodSignals, error) {
+	r := podSignals
+	app := WorkerName(workerID, ownerID)
+
+	r.mu.Lock()
+	pods := r.pods
+	since := time.Now().Add(-window)
+	s := PodSignals{
+		Restarts: countSince(r.restarts[app], since),
+		OOMKills: countSince(r.oomKills[app], since),
+	}
+	r.mu.Unlock()
+
+	if pods == nil {
+		return s, fmt.Errorf("pod watcher not running")
+	}
+	objs, err := pods.ByIndex(podAppIndex, app)
+	if err != nil {
+		return s, err
+	}
+	for _, obj := range objs {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if crashLooping(cs) {
+				s.CrashLooping++
+			}
+		}
+	}
+	return s, nil
+}
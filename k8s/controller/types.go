@@ -1,6 +1,11 @@
 package controller
 
 import (
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -19,6 +24,18 @@ var WorkerAppGVR = schema.GroupVersionResource{
 	Resource: WorkerResource,
 }
 
+var CombinatorAppGVR = schema.GroupVersionResource{
+	Group:    Group,
+	Version:  Version,
+	Resource: CombinatorResource,
+}
+
+// ListKinds are the list kinds of the CRDs the controller watches, for k8s.InitFakeK8s
+var ListKinds = map[schema.GroupVersionResource]string{
+	WorkerAppGVR:     WorkerKind + "List",
+	CombinatorAppGVR: CombinatorKind + "List",
+}
+
 type WorkerAppSpec struct {
 	WorkerID    string `json:"workerID"`
 	OwnerID     string `json:"ownerID"`
@@ -30,9 +47,73 @@ type WorkerAppSpec struct {
 	AssignedDisk   string `json:"assignedDisk"`   // e.g. "2Gi"
 	MaxReplicas int    `json:"maxReplicas"` // e.g. 3
 	MainRegion  string `json:"mainRegion"`  // e.g. "us-east-1"
+	Cluster     string `json:"cluster"`     // target cluster, empty = k8s.LocalCluster
+	Pool        string `json:"pool"`        // node pool (k8s.PoolLabel), empty = any
+	Arch        string `json:"arch"`        // e.g. "arm64", empty = any
+	RunAsRoot   bool   `json:"runAsRoot"`   // opt out of runAsNonRoot (plan permitting)
+	WritableRootFS bool `json:"writableRootFS"` // opt out of readOnlyRootFilesystem (plan permitting)
+	Paused      bool   `json:"paused"`      // scaled to zero
+	Tags        map[string]string `json:"tags"` // copied to object labels
+	Egress      dblayer.EgressPolicy `json:"egress"` // outbound rules, rendered as a NetworkPolicy
+	Routes      []dblayer.RouteRule `json:"routes"` // extra route rules, rendered next to the worker host
+	EdgeLimits  dblayer.EdgeLimits `json:"edgeLimits"` // request rate and body size at the ingress
+	ErrorPages  k8s.RouteErrorPages `json:"errorPages"` // custom error pages and maintenance, served by the outer
+	Static      *k8s.StaticSite `json:"static,omitempty"` // files served by a static worker, nil for container workers
 }
 
 type WorkerAppStatus struct {
 	Phase   string `json:"phase"`
 	Message string `json:"message"`
 }
+
+// CombinatorAppSpec is a user's dedicated combinator: the RDB, KV and queue
+// resources it serves, rendered into its config.json
+type CombinatorAppSpec struct {
+	OwnerID string   `json:"ownerID"`
+	Image   string   `json:"image"` // empty = CombinatorImage
+	RDB     []string `json:"rdb"`   // active RDB resource IDs
+	KV      []string `json:"kv"`    // active KV resource IDs
+	Queue   []string `json:"queue"` // active queue resource IDs
+	// Revision is stamped into config.json; the pod reports it back once
+	// loaded (dblayer.SetCombinatorAppliedRevision)
+	Revision int64 `json:"revision"`
+	// Profile names the pod size (k8s.CombinatorProfiles), already checked
+	// against the owner's plan; empty = the smallest
+	Profile string `json:"profile"`
+}
+
+// CombinatorAppStatus is the status of a user's CombinatorApp as served to the
+// handlers; Phase is CombinatorPhaseAbsent when the user has none
+type CombinatorAppStatus struct {
+	Name            string   `json:"name"`
+	Phase           string   `json:"phase"`
+	Message         string   `json:"message"`
+	RDB             []string `json:"rdb"`
+	KV              []string `json:"kv"`
+	Queue           []string `json:"queue"`
+	Replicas        int32    `json:"replicas"`
+	ReadyReplicas   int32    `json:"ready_replicas"`
+	Revision        int64    `json:"revision"`         // config revision in the CR
+	AppliedRevision int64    `json:"applied_revision"` // highest revision a pod reported as loaded
+
+	Profile        k8s.CombinatorProfile         `json:"profile"`
+	Recommendation *k8s.CombinatorRecommendation `json:"recommendation"` // nil without Prometheus data
+}
+
+// CombinatorRuntimeStatus is what the cluster runs for a user's combinator.
+// Dedicated is false when the user's resources are served by the shared
+// combinator; Pods is empty then.
+type CombinatorRuntimeStatus struct {
+	Name            string            `json:"name"`
+	Dedicated       bool              `json:"dedicated"`
+	Deployed        bool              `json:"deployed"`
+	Phase           string            `json:"phase"` // phase of the CombinatorApp CR
+	Message         string            `json:"message"`
+	Replicas        int32             `json:"replicas"`
+	ReadyReplicas   int32             `json:"ready_replicas"`
+	UpdatedReplicas int32             `json:"updated_replicas"`
+	Revision        int64             `json:"revision"`         // latest config revision
+	AppliedRevision int64             `json:"applied_revision"` // highest revision a pod reported as loaded
+	AppliedAt       *time.Time        `json:"applied_at"`
+	Pods            []WorkerPodStatus `json:"pods"`
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/k8s"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkerUsage is what a worker's ready replicas reserve at one instant
+type WorkerUsage struct {
+	WorkerID      string
+	OwnerID       string
+	ReadyReplicas int32
+	CPUCores      float64 // requests of all ready replicas
+	MemoryBytes   float64
+}
+
+// WorkerUsages reads the worker Deployments of every registered cluster and
+// returns the requests of their ready replicas, which is what workers are
+// metered on. A cluster that cannot be listed is reported in the returned
+// error and its workers are left out.
+func WorkerUsages(ctx context.Context) ([]WorkerUsage, error) {
+	var out []WorkerUsage
+	var failed error
+	for _, c := range k8s.Clusters.List() {
+		deployments, err := c.Kube.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: "worker-id,owner-id"})
+		if err != nil {
+			failed = fmt.Errorf("list deployments on %s: %w", c.Name, err)
+			continue
+		}
+		for i := range deployments.Items {
+			d := &deployments.Items[i]
+			ready := d.Status.ReadyReplicas
+			if ready == 0 {
+				continue
+			}
+			var cpu, memory float64
+			for _, ctr := range d.Spec.Template.Spec.Containers {
+				cpu += ctr.Resources.Requests.Cpu().AsApproximateFloat64()
+				memory += ctr.Resources.Requests.Memory().AsApproximateFloat64()
+			}
+			out = append(out, WorkerUsage{
+				WorkerID:      d.Labels["worker-id"],
+				OwnerID:       d.Labels["owner-id"],
+				ReadyReplicas: ready,
+				CPUCores:      cpu * float64(ready),
+				MemoryBytes:   memory * float64(ready),
+			})
+		}
+	}
+	return out, failed
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/k8s"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidateWorker checks a deploy of image:port for a worker before its
+// WorkerApp CR is created: the local policy checks (k8s.CheckWorkerPolicy),
+// the image user when it has to run as non-root, and a server-side dry run
+// of the Deployment reconcile would write, so admission (Pod Security,
+// LimitRange, policy webhooks) rejects it now rather than inside the job, and
+// whether a node still has room for it.
+// The returned error means validation itself could not run.
+func ValidateWorker(ctx context.Context, workerID, ownerID, image string, port int) ([]k8s.Violation, error) {
+	w, err := dblayer.GetWorkerByOwner(ctx, workerID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("get worker %s: %w", workerID, err)
+	}
+	plan, err := k8s.PlanForUser(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := k8s.CheckWorkerPolicy(k8s.WorkerPolicy{
+		Image: image, Port: port,
+		AssignedCPU: w.AssignedCPU, AssignedMemory: w.AssignedMemory, AssignedDisk: w.AssignedDisk,
+		MaxReplicas: w.MaxReplicas, RunAsRoot: w.RunAsRoot, WritableRootFS: w.WritableRootFS,
+	}, plan)
+	if len(violations) > 0 {
+		// The dry run would only repeat these as a less readable admission error
+		return violations, nil
+	}
+
+	if !w.RunAsRoot {
+		imageCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		err := k8s.CheckImageNonRoot(imageCtx, image, w.Arch)
+		cancel()
+		if stderrors.Is(err, k8s.ErrImageRunsAsRoot) {
+			violations = append(violations, k8s.Violation{Field: "image", Rule: "security",
+				Message: err.Error() + " (or set run_as_root if your plan allows it)"})
+		} else if err != nil {
+			ctrlLog.Debug("inspect image user failed, left to the kubelet", "image", image, "error", err)
+		}
+	}
+
+	spec := &WorkerAppSpec{
+		WorkerID: w.WID, OwnerID: w.UserUID, Image: image, Port: port,
+		AssignedCPU: w.AssignedCPU, AssignedMemory: w.AssignedMemory, AssignedDisk: w.AssignedDisk,
+		MaxReplicas: w.MaxReplicas, MainRegion: w.MainRegion, Cluster: w.Cluster, Pool: w.Pool, Arch: w.Arch,
+		RunAsRoot: w.RunAsRoot, WritableRootFS: w.WritableRootFS, Tags: w.Tags,
+	}
+	if v := spec.dryRunDeployment(ctx); v != nil {
+		violations = append(violations, *v)
+	}
+	if v := spec.checkRegion(ctx); v != nil {
+		// Capacity in a region that does not exist says nothing more
+		return append(violations, *v), nil
+	}
+	if v := spec.checkCapacity(ctx); v != nil {
+		violations = append(violations, *v)
+	}
+	return violations, nil
+}
+
+// clusters are the clusters the worker can be placed on: the one it is
+// placed on or, before its first deploy, any of them. false when the cluster
+// it is placed on is not registered.
+func (w *WorkerAppSpec) clusters() ([]*k8s.Cluster, bool) {
+	if w.Cluster == "" {
+		return k8s.Clusters.List(), true
+	}
+	c, err := w.target()
+	if err != nil {
+		ctrlLog.Debug("placement check skipped", "worker", w.Name(), "error", err)
+		return nil, false
+	}
+	return []*k8s.Cluster{c}, true
+}
+
+// checkRegion makes sure MainRegion names a region some node is in; the
+// free-form value is otherwise only noticed as pods that never schedule.
+func (w *WorkerAppSpec) checkRegion(ctx context.Context) *k8s.Violation {
+	if w.MainRegion == "" {
+		return nil
+	}
+	clusters, ok := w.clusters()
+	if !ok {
+		return nil
+	}
+	err := k8s.CheckRegion(ctx, clusters, w.MainRegion)
+	if stderrors.Is(err, k8s.ErrUnknownRegion) {
+		return &k8s.Violation{Field: "main_region", Rule: "region", Message: err.Error()}
+	}
+	if err != nil {
+		ctrlLog.Warn("region check failed", "worker", w.Name(), "error", err)
+	}
+	return nil
+}
+
+// checkCapacity makes sure one replica fits on a node the Deployment can be
+// scheduled on, in the cluster the worker is placed on or, before its first
+// deploy, in any cluster. Pods that stay Pending are otherwise only noticed
+// after the deploy job has reported success.
+func (w *WorkerAppSpec) checkCapacity(ctx context.Context) *k8s.Violation {
+	clusters, ok := w.clusters()
+	if !ok {
+		return nil
+	}
+	err := k8s.CheckCapacity(ctx, clusters, w.MainRegion, w.Pool, w.Arch,
+		cmp.Or(w.AssignedCPU, k8s.DefaultWorkerCPU), cmp.Or(w.AssignedMemory, k8s.DefaultWorkerMemory))
+	if stderrors.Is(err, k8s.ErrInsufficientCapacity) {
+		return &k8s.Violation{Field: "main_region", Rule: "capacity", Message: err.Error()}
+	}
+	if err != nil {
+		ctrlLog.Warn("capacity check failed", "worker", w.Name(), "error", err)
+	}
+	return nil
+}
+
+// dryRunDeployment submits the worker's Deployment with dryRun=All. A
+// rejection is returned as a violation; a namespace that does not exist yet
+// (first deploy into a tenant namespace) or an unreachable cluster skips the check.
+func (w *WorkerAppSpec) dryRunDeployment(ctx context.Context) *k8s.Violation {
+	c, err := w.target()
+	if err != nil {
+		ctrlLog.Debug("dry run skipped", "worker", w.Name(), "error", err)
+		return nil
+	}
+	client := c.Kube.AppsV1().Deployments(w.Namespace())
+	deployment := w.deployment()
+	dryRun := []string{metav1.DryRunAll}
+	existing, err := client.Get(ctx, w.Name(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = client.Create(ctx, deployment, metav1.CreateOptions{DryRun: dryRun})
+	case err == nil:
+		deployment.ResourceVersion = existing.ResourceVersion
+		_, err = client.Update(ctx, deployment, metav1.UpdateOptions{DryRun: dryRun})
+	}
+	if err == nil || errors.IsNotFound(err) {
+		return nil
+	}
+	if errors.IsInvalid(err) || errors.IsForbidden(err) || errors.IsBadRequest(err) {
+		return &k8s.Violation{Field: "deployment", Rule: "admission", Message: err.Error()}
+	}
+	ctrlLog.Warn("dry run failed", "worker", w.Name(), "cluster", c.Name, "error", err)
+	return nil
+}
@@ -2,17 +2,19 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
 	"strconv"
 	"time"
 
+	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -28,7 +30,7 @@ func (wc *WorkerController) onAdd(obj interface{}) {
 	if !ok {
 		return
 	}
-	log.Printf("[controller] WorkerApp added: %s", u.GetName())
+	ctrlLog.Info("WorkerApp added", "name", u.GetName())
 	wc.reconcile(u)
 }
 
@@ -41,7 +43,7 @@ func (wc *WorkerController) onUpdate(oldObj, newObj any) {
 	if oldU.GetGeneration() == newU.GetGeneration() {
 		return // status-only update, skip reconcile
 	}
-	log.Printf("[controller] WorkerApp updated: %s", newU.GetName())
+	ctrlLog.Info("WorkerApp updated", "name", newU.GetName())
 	wc.reconcile(newU)
 }
 
@@ -50,13 +52,18 @@ func (wc *WorkerController) onDelete(obj interface{}) {
 	if !ok {
 		return
 	}
-	log.Printf("[controller] WorkerApp deleted: %s", u.GetName())
+	ctrlLog.Info("WorkerApp deleted", "name", u.GetName())
 
 	w := workerFromUnstructured(u)
 	if w == nil {
 		return
 	}
-	w.DeleteAll(context.Background())
+	if err := w.DeleteAll(context.Background()); err != nil {
+		ctrlLog.Error("delete sub-resources failed", "name", u.GetName(), "error", err)
+		k8s.RecordWarning(u, k8s.EventReasonDeleteFailed, "Delete sub-resources failed: %v", err)
+		return
+	}
+	k8s.RecordEvent(u, k8s.EventReasonDeleted, "Deleted sub-resources of %s", w.Name())
 }
 
 // --- Sub-resource delete handler ---
@@ -66,8 +73,11 @@ func (wc *WorkerController) onSubResourceDelete(obj interface{}) {
 	switch o := obj.(type) {
 	case metav1.Object:
 		appName = o.GetLabels()["app"]
-	case *unstructured.Unstructured:
-		appName = o.GetLabels()["app"]
+		// legacy objects removed from the shared namespace after a migration
+		ns := o.GetNamespace()
+		if ns != k8s.IngressNamespace && ns != k8s.TenantNamespace(o.GetLabels()["owner-id"]) {
+			return
+		}
 	default:
 		return
 	}
@@ -75,16 +85,11 @@ func (wc *WorkerController) onSubResourceDelete(obj interface{}) {
 		return
 	}
 
-	key := k8s.WorkerNamespace + "/" + appName
-	item, exists, err := wc.crCache.GetByKey(key)
-	if err != nil || !exists {
+	u := wc.cachedCR(appName)
+	if u == nil {
 		return
 	}
-	u, ok := item.(*unstructured.Unstructured)
-	if !ok {
-		return
-	}
-	log.Printf("[controller] sub-resource deleted for %s, re-reconciling", appName)
+	ctrlLog.Info("sub-resource deleted, re-reconciling", "name", appName)
 	wc.reconcile(u)
 }
 
@@ -103,25 +108,44 @@ func (wc *WorkerController) onConfigUpdate(oldObj, newObj interface{}) {
 	if appName == "" {
 		return
 	}
-	log.Printf("[controller] config/secret updated for %s, restarting deployment", appName)
-	wc.restartDeployment(appName)
+	ctrlLog.Info("config/secret updated, restarting deployment", "name", appName)
+	err := RestartWorkerDeployment(context.Background(), wc.ctrl.k8sClient, cur.GetNamespace(), appName)
+	if err != nil {
+		ctrlLog.Error("restart deployment failed", "name", appName, "error", err)
+	}
+	cr := wc.cachedCR(appName)
+	if cr == nil {
+		return
+	}
+	if err != nil {
+		k8s.RecordWarning(cr, k8s.EventReasonEnsureFailed, "Restart Deployment %s after %s change failed: %v", appName, cur.GetName(), err)
+	} else {
+		k8s.RecordEvent(cr, k8s.EventReasonRestarted, "Restarted Deployment %s after %s changed", appName, cur.GetName())
+	}
 }
 
-func (wc *WorkerController) restartDeployment(name string) {
-	if k8s.K8sClient == nil {
-		return
+// cachedCR returns the WorkerApp CR named name from the informer cache, or nil
+func (wc *WorkerController) cachedCR(name string) *unstructured.Unstructured {
+	item, exists, err := wc.crCache.GetByKey(k8s.WorkerNamespace + "/" + name)
+	if err != nil || !exists {
+		return nil
 	}
+	u, _ := item.(*unstructured.Unstructured)
+	return u
+}
+
+// RestartWorkerDeployment triggers a rolling restart of a worker's Deployment
+// in namespace of the cluster client talks to.
+func RestartWorkerDeployment(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
 	patch := fmt.Sprintf(
 		`{"spec":{"template":{"metadata":{"annotations":{"console.app238.com/restartedAt":"%s"}}}}}`,
 		strconv.FormatInt(time.Now().Unix(), 10),
 	)
-	_, err := k8s.K8sClient.AppsV1().Deployments(k8s.WorkerNamespace).Patch(
-		context.Background(), name, types.StrategicMergePatchType,
+	_, err := client.AppsV1().Deployments(namespace).Patch(
+		ctx, name, types.StrategicMergePatchType,
 		[]byte(patch), metav1.PatchOptions{},
 	)
-	if err != nil {
-		log.Printf("[controller] restart deployment %s failed: %v", name, err)
-	}
+	return err
 }
 
 // --- Reconcile ---
@@ -135,41 +159,60 @@ func (wc *WorkerController) reconcile(u *unstructured.Unstructured) {
 	ctx := context.Background()
 	wc.ctrl.updateStatus(u, WorkerAppGVR, "Deploying", "")
 
-	if err := w.EnsureConfigMap(ctx); err != nil {
-		log.Printf("[controller] ensure configmap for %s failed: %v", u.GetName(), err)
-		wc.ctrl.updateStatus(u, WorkerAppGVR, "Failed", err.Error())
-		return
-	}
-	if err := w.EnsureSecret(ctx); err != nil {
-		log.Printf("[controller] ensure secret for %s failed: %v", u.GetName(), err)
-		wc.ctrl.updateStatus(u, WorkerAppGVR, "Failed", err.Error())
-		return
-	}
-	if err := w.EnsureDeployment(ctx); err != nil {
-		log.Printf("[controller] ensure deployment for %s failed: %v", u.GetName(), err)
-		wc.ctrl.updateStatus(u, WorkerAppGVR, "Failed", err.Error())
-		return
-	}
-	if err := w.EnsureService(ctx); err != nil {
-		log.Printf("[controller] ensure service for %s failed: %v", u.GetName(), err)
-		wc.ctrl.updateStatus(u, WorkerAppGVR, "Failed", err.Error())
-		return
+	for _, step := range w.ensureSteps() {
+		if err := step.ensure(ctx); err != nil {
+			ctrlLog.Error("ensure "+step.kind+" failed", "name", u.GetName(), "error", err)
+			k8s.RecordWarning(u, k8s.EventReasonEnsureFailed, "Ensure %s %s failed: %v", step.kind, step.name, err)
+			wc.ctrl.updateStatus(u, WorkerAppGVR, "Failed", err.Error())
+			return
+		}
+		k8s.RecordEvent(u, k8s.EventReasonEnsured, "Ensured %s %s", step.kind, step.name)
 	}
-	if err := w.EnsureExternalNameService(ctx); err != nil {
-		log.Printf("[controller] ensure external name service for %s failed: %v", u.GetName(), err)
-		wc.ctrl.updateStatus(u, WorkerAppGVR, "Failed", err.Error())
-		return
+
+	// The route now points at the tenant namespace, so whatever an
+	// earlier version left in the shared namespace can go
+	if err := w.DeleteLegacy(ctx); err != nil {
+		ctrlLog.Error("delete legacy resources failed", "name", u.GetName(), "error", err)
+		k8s.RecordWarning(u, k8s.EventReasonDeleteFailed, "Delete resources left in namespace %s failed: %v", k8s.WorkerNamespace, err)
 	}
-	if err := w.EnsureIngressRoute(ctx); err != nil {
-		log.Printf("[controller] ensure ingress route for %s failed: %v", u.GetName(), err)
-		wc.ctrl.updateStatus(u, WorkerAppGVR, "Failed", err.Error())
+
+	ctrlLog.Info("reconcile success", "name", u.GetName())
+	if w.Paused {
+		k8s.RecordEvent(u, k8s.EventReasonReconciled, "Reconciled image %s, paused", w.Image)
+		wc.ctrl.updateStatus(u, WorkerAppGVR, "Paused", "")
 		return
 	}
-
-	log.Printf("[controller] reconcile %s success", u.GetName())
+	k8s.RecordEvent(u, k8s.EventReasonReconciled, "Reconciled image %s", w.Image)
 	wc.ctrl.updateStatus(u, WorkerAppGVR, "Running", "")
 }
 
+// ensureStep is one object reconcile keeps in place for a worker
+type ensureStep struct {
+	kind   string
+	name   string
+	ensure func(context.Context) error
+}
+
+// ensureSteps lists the worker's objects in the order reconcile ensures them
+func (w *WorkerAppSpec) ensureSteps() []ensureStep {
+	var steps []ensureStep
+	if w.migrating() || w.remote() {
+		steps = append(steps, ensureStep{"Namespace", w.Namespace(), w.EnsureNamespace})
+	}
+	if k8s.InternalIssuer != nil {
+		steps = append(steps, ensureStep{"Certificate", w.MTLSSecretName(), w.EnsureClientCertificate})
+	}
+	return append(steps, []ensureStep{
+		{"ConfigMap", w.EnvConfigMapName(), w.EnsureConfigMap},
+		{"Secret", w.SecretName(), w.EnsureSecret},
+		{"NetworkPolicy", w.EgressPolicyName(), w.EnsureEgress},
+		{"Deployment", w.Name(), w.EnsureDeployment},
+		{"Service", w.Name(), w.EnsureService},
+		{"ExternalName Service", w.ExternalNameServiceName(), w.EnsureExternalNameService},
+		{k8s.Ingress.Kind(), w.Name(), w.EnsureRoute},
+	}...)
+}
+
 // --- Helpers ---
 
 func workerFromUnstructured(u *unstructured.Unstructured) *WorkerAppSpec {
@@ -179,6 +222,15 @@ func workerFromUnstructured(u *unstructured.Unstructured) *WorkerAppSpec {
 	}
 	port, _ := spec["port"].(int64)
 	maxReplicas, _ := spec["maxReplicas"].(int64)
+	paused, _ := spec["paused"].(bool)
+	runAsRoot, _ := spec["runAsRoot"].(bool)
+	writableRootFS, _ := spec["writableRootFS"].(bool)
+	tags := map[string]string{}
+	if m, ok := spec["tags"].(map[string]interface{}); ok {
+		for k, v := range m {
+			tags[k] = fmt.Sprintf("%v", v)
+		}
+	}
 	return &WorkerAppSpec{
 		WorkerID:       fmt.Sprintf("%v", spec["workerID"]),
 		OwnerID:        fmt.Sprintf("%v", spec["ownerID"]),
@@ -190,7 +242,98 @@ func workerFromUnstructured(u *unstructured.Unstructured) *WorkerAppSpec {
 		AssignedDisk:   strVal(spec, "assignedDisk"),
 		MaxReplicas:    int(maxReplicas),
 		MainRegion:     strVal(spec, "mainRegion"),
+		Cluster:        strVal(spec, "cluster"),
+		Pool:           strVal(spec, "pool"),
+		Arch:           strVal(spec, "arch"),
+		RunAsRoot:      runAsRoot,
+		WritableRootFS: writableRootFS,
+		Paused:         paused,
+		Tags:           tags,
+		Egress:         egressFromSpec(spec),
+		Routes:         routesFromSpec(spec),
+		EdgeLimits:     edgeLimitsFromSpec(spec),
+		ErrorPages:     errorPagesFromSpec(spec),
+		Static:         staticFromSpec(spec),
+	}
+}
+
+// staticFromSpec reads spec.static, nil for container workers
+func staticFromSpec(spec map[string]interface{}) *k8s.StaticSite {
+	m, ok := spec["static"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	strip, _ := m["strip"].(bool)
+	return &k8s.StaticSite{URL: strVal(m, "url"), SHA256: strVal(m, "sha256"), Dir: strVal(m, "dir"), Strip: strip}
+}
+
+// errorPagesFromSpec reads spec.errorPages, none when it is missing
+func errorPagesFromSpec(spec map[string]interface{}) k8s.RouteErrorPages {
+	m, _ := spec["errorPages"].(map[string]interface{})
+	maintenance, _ := m["maintenance"].(bool)
+	p := k8s.RouteErrorPages{Maintenance: maintenance}
+	statuses, _ := m["statuses"].([]interface{})
+	for _, s := range statuses {
+		p.Statuses = append(p.Statuses, fmt.Sprintf("%v", s))
 	}
+	return p
+}
+
+// edgeLimitsFromSpec reads spec.edgeLimits, unlimited when it is missing
+func edgeLimitsFromSpec(spec map[string]interface{}) dblayer.EdgeLimits {
+	m, _ := spec["edgeLimits"].(map[string]interface{})
+	rateLimit, _ := m["rateLimit"].(int64)
+	burst, _ := m["burst"].(int64)
+	maxBodyBytes, _ := m["maxBodyBytes"].(int64)
+	return dblayer.EdgeLimits{RateLimit: int(rateLimit), Burst: int(burst), MaxBodyBytes: maxBodyBytes}
+}
+
+// routesFromSpec reads spec.routes, none when it is missing
+func routesFromSpec(spec map[string]interface{}) []dblayer.RouteRule {
+	items, _ := spec["routes"].([]interface{})
+	var rules []dblayer.RouteRule
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		priority, _ := m["priority"].(int64)
+		rule := dblayer.RouteRule{PathPrefix: strVal(m, "pathPrefix"), Priority: int(priority)}
+		hosts, _ := m["hosts"].([]interface{})
+		for _, h := range hosts {
+			rule.Hosts = append(rule.Hosts, fmt.Sprintf("%v", h))
+		}
+		if headers, ok := m["headers"].(map[string]interface{}); ok {
+			rule.Headers = make(map[string]string, len(headers))
+			for k, v := range headers {
+				rule.Headers[k] = fmt.Sprintf("%v", v)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// egressFromSpec reads spec.egress, allow mode when it is missing
+func egressFromSpec(spec map[string]interface{}) dblayer.EgressPolicy {
+	p := dblayer.EgressPolicy{Mode: dblayer.EgressAllow}
+	m, ok := spec["egress"].(map[string]interface{})
+	if !ok {
+		return p
+	}
+	if mode := strVal(m, "mode"); mode != "" {
+		p.Mode = mode
+	}
+	strs := func(key string) []string {
+		var out []string
+		items, _ := m[key].([]interface{})
+		for _, v := range items {
+			out = append(out, fmt.Sprintf("%v", v))
+		}
+		return out
+	}
+	p.CIDRs, p.Domains = strs("cidrs"), strs("domains")
+	return p
 }
 
 func strVal(m map[string]interface{}, key string) string {
@@ -205,12 +348,20 @@ func strVal(m map[string]interface{}, key string) string {
 
 // CreateWorkerAppCR creates a new WorkerApp CR. Fails if it already exists.
 func CreateWorkerAppCR(
+	ctx context.Context,
 	client dynamic.Interface,
 	name, workerID, ownerID, image string, ownerSK string,
 	port int,
 	assignedCPU, assignedMemory, assignedDisk string,
 	maxReplicas int,
-	mainRegion string,
+	mainRegion, cluster, pool, arch string,
+	runAsRoot, writableRootFS bool,
+	tags map[string]string,
+	egress dblayer.EgressPolicy,
+	routes []dblayer.RouteRule,
+	edgeLimits dblayer.EdgeLimits,
+	errorPages k8s.RouteErrorPages,
+	static *k8s.StaticSite,
 ) error {
 	spec := map[string]interface{}{
 		"workerID": workerID,
@@ -234,6 +385,39 @@ func CreateWorkerAppCR(
 	if mainRegion != "" {
 		spec["mainRegion"] = mainRegion
 	}
+	if cluster != "" {
+		spec["cluster"] = cluster
+	}
+	if pool != "" {
+		spec["pool"] = pool
+	}
+	if arch != "" {
+		spec["arch"] = arch
+	}
+	if runAsRoot {
+		spec["runAsRoot"] = true
+	}
+	if writableRootFS {
+		spec["writableRootFS"] = true
+	}
+	if len(tags) > 0 {
+		spec["tags"] = tagsSpec(tags)
+	}
+	if egress.Mode == dblayer.EgressDeny {
+		spec["egress"] = egressSpec(egress)
+	}
+	if len(routes) > 0 {
+		spec["routes"] = routesSpec(routes)
+	}
+	if edgeLimits.RateLimit > 0 || edgeLimits.MaxBodyBytes > 0 {
+		spec["edgeLimits"] = edgeLimitsSpec(edgeLimits)
+	}
+	if errorPages.Maintenance || len(errorPages.Statuses) > 0 {
+		spec["errorPages"] = errorPagesSpec(errorPages)
+	}
+	if static != nil {
+		spec["static"] = staticSpec(static)
+	}
 
 	cr := &unstructured.Unstructured{
 		Object: map[string]any{
@@ -248,17 +432,19 @@ func CreateWorkerAppCR(
 	}
 
 	_, err := client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).
-		Create(context.Background(), cr, metav1.CreateOptions{})
+		Create(ctx, cr, metav1.CreateOptions{})
 	return err
 }
 
-// UpdateWorkerAppCR updates image and port on an existing WorkerApp CR.
+// UpdateWorkerAppCR updates image, port and the static site files on an
+// existing WorkerApp CR.
 func UpdateWorkerAppCR(
+	ctx context.Context,
 	client dynamic.Interface,
 	name, image string,
 	port int,
+	static *k8s.StaticSite,
 ) error {
-	ctx := context.Background()
 	res := client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace)
 
 	existing, err := res.Get(ctx, name, metav1.GetOptions{})
@@ -272,13 +458,212 @@ func UpdateWorkerAppCR(
 	}
 	spec["image"] = image
 	spec["port"] = int64(port)
+	if static != nil {
+		spec["static"] = staticSpec(static)
+	} else {
+		delete(spec, "static")
+	}
 
 	_, err = res.Update(ctx, existing, metav1.UpdateOptions{})
 	return err
 }
 
-func DeleteWorkerAppCR(client dynamic.Interface, name string) error {
+// SetWorkerAppTags replaces spec.tags on an existing WorkerApp CR; the
+// controller relabels the sub-resources on the next reconcile.
+func SetWorkerAppTags(ctx context.Context, client dynamic.Interface, name string, tags map[string]string) error {
+	res := client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace)
+
+	existing, err := res.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get CR %s: %w", name, err)
+	}
+	spec, _ := existing.Object["spec"].(map[string]interface{})
+	if spec == nil {
+		return fmt.Errorf("CR %s has no spec", name)
+	}
+	spec["tags"] = tagsSpec(tags)
+
+	_, err = res.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+func tagsSpec(tags map[string]string) map[string]interface{} {
+	m := make(map[string]interface{}, len(tags))
+	for k, v := range tags {
+		m[k] = v
+	}
+	return m
+}
+
+func egressSpec(p dblayer.EgressPolicy) map[string]interface{} {
+	list := func(items []string) []interface{} {
+		out := make([]interface{}, len(items))
+		for i, v := range items {
+			out[i] = v
+		}
+		return out
+	}
+	return map[string]interface{}{
+		"mode":    p.Mode,
+		"cidrs":   list(p.CIDRs),
+		"domains": list(p.Domains),
+	}
+}
+
+// SetWorkerAppEgress replaces spec.egress on an existing WorkerApp CR; the
+// controller renders the NetworkPolicy on the next reconcile.
+func SetWorkerAppEgress(ctx context.Context, client dynamic.Interface, name string, egress dblayer.EgressPolicy) error {
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"egress": egressSpec(egress)}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).
+		Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func routesSpec(rules []dblayer.RouteRule) []interface{} {
+	out := make([]interface{}, len(rules))
+	for i, r := range rules {
+		m := map[string]interface{}{"priority": int64(r.Priority)}
+		if len(r.Hosts) > 0 {
+			hosts := make([]interface{}, len(r.Hosts))
+			for j, h := range r.Hosts {
+				hosts[j] = h
+			}
+			m["hosts"] = hosts
+		}
+		if r.PathPrefix != "" {
+			m["pathPrefix"] = r.PathPrefix
+		}
+		if len(r.Headers) > 0 {
+			m["headers"] = tagsSpec(r.Headers)
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// SetWorkerAppRoutes replaces spec.routes on an existing WorkerApp CR; the
+// controller renders them into the worker's route on the next reconcile.
+func SetWorkerAppRoutes(ctx context.Context, client dynamic.Interface, name string, routes []dblayer.RouteRule) error {
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"routes": routesSpec(routes)}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).
+		Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func edgeLimitsSpec(l dblayer.EdgeLimits) map[string]interface{} {
+	return map[string]interface{}{
+		"rateLimit":    int64(l.RateLimit),
+		"burst":        int64(l.Burst),
+		"maxBodyBytes": l.MaxBodyBytes,
+	}
+}
+
+// SetWorkerAppEdgeLimits replaces spec.edgeLimits on an existing WorkerApp CR;
+// the controller renders the Middlewares on the next reconcile.
+func SetWorkerAppEdgeLimits(ctx context.Context, client dynamic.Interface, name string, l dblayer.EdgeLimits) error {
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"edgeLimits": edgeLimitsSpec(l)}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).
+		Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func errorPagesSpec(p k8s.RouteErrorPages) map[string]interface{} {
+	statuses := make([]interface{}, len(p.Statuses))
+	for i, s := range p.Statuses {
+		statuses[i] = s
+	}
+	return map[string]interface{}{
+		"maintenance": p.Maintenance,
+		"statuses":    statuses,
+	}
+}
+
+func staticSpec(s *k8s.StaticSite) map[string]interface{} {
+	m := map[string]interface{}{"url": s.URL}
+	if s.SHA256 != "" {
+		m["sha256"] = s.SHA256
+	}
+	if s.Dir != "" {
+		m["dir"] = s.Dir
+	}
+	if s.Strip {
+		m["strip"] = true
+	}
+	return m
+}
+
+// SetWorkerAppErrorPages replaces spec.errorPages on an existing WorkerApp CR;
+// the controller reroutes the worker on the next reconcile.
+func SetWorkerAppErrorPages(ctx context.Context, client dynamic.Interface, name string, p k8s.RouteErrorPages) error {
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"errorPages": errorPagesSpec(p)}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).
+		Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// SetWorkerAppResources updates the resource spec of an existing WorkerApp CR;
+// empty values remove the field so the controller default applies.
+func SetWorkerAppResources(
+	ctx context.Context,
+	client dynamic.Interface,
+	name string,
+	assignedCPU, assignedMemory, assignedDisk string,
+	maxReplicas int,
+	mainRegion, pool, arch string,
+	runAsRoot, writableRootFS bool,
+) error {
+	orNull := func(v string) any {
+		if v == "" {
+			return nil
+		}
+		return v
+	}
+	spec := map[string]any{
+		"assignedCPU":    orNull(assignedCPU),
+		"assignedMemory": orNull(assignedMemory),
+		"assignedDisk":   orNull(assignedDisk),
+		"mainRegion":     orNull(mainRegion),
+		"pool":           orNull(pool),
+		"arch":           orNull(arch),
+		"runAsRoot":      runAsRoot,
+		"writableRootFS": writableRootFS,
+		"maxReplicas":    nil,
+	}
+	if maxReplicas > 0 {
+		spec["maxReplicas"] = int64(maxReplicas)
+	}
+	patch, err := json.Marshal(map[string]any{"spec": spec})
+	if err != nil {
+		return err
+	}
+	_, err = client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).
+		Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// SetWorkerAppPaused sets spec.paused on an existing WorkerApp CR; the
+// controller scales the Deployment to zero (or back) on the next reconcile.
+func SetWorkerAppPaused(ctx context.Context, client dynamic.Interface, name string, paused bool) error {
+	patch := fmt.Sprintf(`{"spec":{"paused":%t}}`, paused)
+	_, err := client.Resource(WorkerAppGVR).Namespace(k8s.WorkerNamespace).
+		Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+func DeleteWorkerAppCR(ctx context.Context, client dynamic.Interface, name string) error {
 	return client.Resource(WorkerAppGVR).
 		Namespace(k8s.WorkerNamespace).
-		Delete(context.Background(), name, metav1.DeleteOptions{})
+		Delete(ctx, name, metav1.DeleteOptions{})
 }
@@ -2,9 +2,11 @@ package controller
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"strings"
 
+	"jabberwocky238/console/dblayer"
 	"jabberwocky238/console/k8s"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -12,24 +14,60 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"maps"
 )
 
 // ReservedEnvKeys are system-managed environment variables injected into worker Secrets.
 // These keys are stripped from ConfigMaps and force-injected into Secrets.
-var ReservedEnvKeys = []string{"COMBINATOR_API_ENDPOINT", "RAYSAIL_UID", "RAYSAIL_SECRET_KEY"}
+var ReservedEnvKeys = []string{"COMBINATOR_API_ENDPOINT", "COMBINATOR_TLS_DIR", "RAYSAIL_UID", "RAYSAIL_SECRET_KEY"}
+
+// mtlsVolume is the worker pod volume of its client certificate
+const mtlsVolume = "mtls"
+
+// SecretRefsAnnotation lists, comma separated, the keys of a worker Secret
+// whose values were resolved from secretref:// env values, so that keys no
+// longer referenced can be removed.
+const SecretRefsAnnotation = "console.app238.com/secret-refs"
+
+// AttachmentsAnnotation lists, comma separated, the keys of a worker Secret
+// holding connection strings of attached resources, so that keys of detached
+// ones can be removed.
+const AttachmentsAnnotation = "console.app238.com/attachments"
 
 // WorkerName returns the canonical resource name for a worker.
 func WorkerName(workerID, ownerID string) string {
 	return fmt.Sprintf("w-%s-%s", workerID, ownerID)
 }
 
+// WorkerHost is the public host name routed to a worker by its route
+func WorkerHost(workerID, ownerID string) string {
+	return fmt.Sprintf("%s-%s.worker.%s", workerID, ownerID, k8s.Domain)
+}
+
 // Name returns the worker's resource name
 func (w *WorkerAppSpec) Name() string {
 	return WorkerName(w.WorkerID, w.OwnerID)
 }
 
+// Namespace is where the worker's Deployment, Service, ConfigMap, Secret and
+// pods live: the owner's tenant namespace, or WorkerNamespace when tenant
+// namespaces are off. The WorkerApp CR itself is always in WorkerNamespace.
+func (w *WorkerAppSpec) Namespace() string {
+	return k8s.TenantNamespace(w.OwnerID)
+}
+
+// remote reports whether the worker is placed on a cluster other than the
+// one the controller runs in
+func (w *WorkerAppSpec) remote() bool {
+	return w.Cluster != "" && w.Cluster != k8s.LocalCluster
+}
+
+// migrating reports whether the worker has moved out of the shared namespace,
+// so objects left there by earlier versions are copied and then removed
+func (w *WorkerAppSpec) migrating() bool {
+	return w.Namespace() != k8s.WorkerNamespace
+}
+
 func (w *WorkerAppSpec) Labels() map[string]string {
 	return map[string]string{
 		"app":       w.Name(),
@@ -38,6 +76,91 @@ func (w *WorkerAppSpec) Labels() map[string]string {
 	}
 }
 
+// podLabels are the pod template labels: Labels, plus k8s.EgressLabel on
+// deny-mode workers so the tenant default-egress policy leaves them out
+func (w *WorkerAppSpec) podLabels() map[string]string {
+	labels := w.Labels()
+	if k8s.EgressRestricted(w.Egress) {
+		labels[k8s.EgressLabel] = dblayer.EgressDeny
+	}
+	return labels
+}
+
+// ObjectLabels are Labels plus the user's tags, used on object metadata but
+// not on the pod template so that retagging does not restart pods.
+func (w *WorkerAppSpec) ObjectLabels() map[string]string {
+	return k8s.WithTagLabels(w.Labels(), w.Tags)
+}
+
+// target returns the cluster the worker's objects are created in
+func (w *WorkerAppSpec) target() (*k8s.Cluster, error) {
+	return k8s.Clusters.Get(w.Cluster)
+}
+
+// ClusterOf returns the cluster a worker has been placed on, LocalCluster
+// when it has not been deployed yet
+func ClusterOf(ctx context.Context, workerID, ownerID string) (*k8s.Cluster, error) {
+	w, err := dblayer.GetWorkerByOwner(ctx, workerID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("get worker %s: %w", workerID, err)
+	}
+	return k8s.Clusters.Get(w.Cluster)
+}
+
+// EnsureNamespace provisions the owner's tenant namespace with the quota,
+// limits and network policies of their plan. Without tenant namespaces it
+// only makes sure the shared namespace exists on a remote cluster.
+func (w *WorkerAppSpec) EnsureNamespace(ctx context.Context) error {
+	if !w.migrating() && !w.remote() {
+		return nil
+	}
+	c, err := w.target()
+	if err != nil {
+		return err
+	}
+	if !w.migrating() {
+		return k8s.EnsureNamespace(ctx, c.Kube, k8s.WorkerNamespace, map[string]string{k8s.ManagedByLabel: k8s.ManagedBy})
+	}
+	plan, err := k8s.PlanForUser(ctx, w.OwnerID)
+	if err != nil {
+		return err
+	}
+	return k8s.EnsureTenantNamespace(ctx, c.Kube, w.OwnerID, plan)
+}
+
+// SyncTenantPlan re-applies the quota and limits of ownerID's plan to their
+// tenant namespace on every cluster one of their workers is deployed to, after
+// the plan or the account limits changed. Without tenant namespaces there is
+// nothing to update.
+func SyncTenantPlan(ctx context.Context, ownerID string) error {
+	if !k8s.TenantNamespaces {
+		return nil
+	}
+	plan, err := k8s.PlanForUser(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	workers, err := dblayer.ListDeployedWorkers(ctx)
+	if err != nil {
+		return fmt.Errorf("list deployed workers: %w", err)
+	}
+	synced := map[string]bool{}
+	for _, w := range workers {
+		if w.UserUID != ownerID || synced[w.Cluster] {
+			continue
+		}
+		synced[w.Cluster] = true
+		c, err := k8s.Clusters.Get(w.Cluster)
+		if err != nil {
+			return err
+		}
+		if err := k8s.EnsureTenantNamespace(ctx, c.Kube, ownerID, plan); err != nil {
+			return fmt.Errorf("cluster %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
 func (w *WorkerAppSpec) EnvConfigMapName() string {
 	return fmt.Sprintf("%s-env", w.Name())
 }
@@ -46,31 +169,136 @@ func (w *WorkerAppSpec) SecretName() string {
 	return fmt.Sprintf("%s-secret", w.Name())
 }
 
+// CombinatorEndpoint is the combinator API the worker calls
 func (w *WorkerAppSpec) CombinatorEndpoint() string {
-	return fmt.Sprintf("http://combinator.%s.svc.cluster.local:8899", k8s.CombinatorNamespace)
+	return combinatorEndpoint("combinator")
+}
+
+// combinatorEndpoint is the API of the combinator Service named service, over
+// mTLS when workers get a client certificate
+func combinatorEndpoint(service string) string {
+	scheme := "http"
+	if k8s.InternalIssuer != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s.%s.svc.cluster.local:%d", scheme, service, k8s.CombinatorNamespace, combinatorPort)
+}
+
+// MTLSSecretName is the Certificate, and its Secret, holding the worker's
+// client certificate
+func (w *WorkerAppSpec) MTLSSecretName() string {
+	return fmt.Sprintf("%s-mtls", w.Name())
+}
+
+// EnsureClientCertificate requests the worker's client certificate from
+// k8s.InternalIssuer; its identity carries the owner UID
+func (w *WorkerAppSpec) EnsureClientCertificate(ctx context.Context) error {
+	c, err := w.target()
+	if err != nil {
+		return err
+	}
+	cert := k8s.ClientCertificate(w.MTLSSecretName(), w.Namespace(), w.ObjectLabels(), k8s.WorkerIdentity(w.OwnerID, w.WorkerID))
+	return k8s.EnsureCertificate(ctx, c.Dynamic, cert)
+}
+
+// EgressPolicyName is the NetworkPolicy, and the egress proxy allowlist
+// ConfigMap, of the worker's outbound rules
+func (w *WorkerAppSpec) EgressPolicyName() string {
+	return fmt.Sprintf("%s-egress", w.Name())
+}
+
+// EnsureEgress applies the worker's egress rules: its NetworkPolicy, and the
+// allowlist ConfigMap read by the egress proxy when it has domain rules.
+// Whichever of the two the rules do not need is deleted.
+func (w *WorkerAppSpec) EnsureEgress(ctx context.Context) error {
+	c, err := w.target()
+	if err != nil {
+		return err
+	}
+	policy := k8s.WorkerEgressPolicy(w.EgressPolicyName(), w.Namespace(), w.ObjectLabels(),
+		map[string]string{"app": w.Name()}, w.Egress)
+	if policy == nil {
+		err = k8s.DeleteNetworkPolicy(ctx, c.Kube, w.Namespace(), w.EgressPolicyName())
+	} else {
+		err = k8s.EnsureNetworkPolicy(ctx, c.Kube, policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	client := c.Kube.CoreV1().ConfigMaps(w.Namespace())
+	if !k8s.EgressProxied(w.Egress) {
+		err = client.Delete(ctx, w.EgressPolicyName(), metav1.DeleteOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	labels := w.ObjectLabels()
+	labels[k8s.EgressAllowlistLabel] = "true"
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.EgressPolicyName(),
+			Namespace: w.Namespace(),
+			Labels:    labels,
+		},
+		Data: map[string]string{
+			"worker":  w.Name(),
+			"domains": strings.Join(w.Egress.Domains, "\n"),
+		},
+	}
+	existing, err := client.Get(ctx, cm.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels, existing.Data = cm.Labels, cm.Data
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
 }
+
 func (w *WorkerAppSpec) EnsureDeployment(ctx context.Context) error {
-	if k8s.K8sClient == nil {
-		return fmt.Errorf("k8s client not initialized")
+	c, err := w.target()
+	if err != nil {
+		return err
 	}
 
+	deployment := w.deployment()
+	client := c.Kube.AppsV1().Deployments(w.Namespace())
+	_, err = client.Get(ctx, w.Name(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, deployment, metav1.CreateOptions{})
+	} else if err == nil {
+		_, err = client.Update(ctx, deployment, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// deployment is the worker's Deployment as reconcile writes it
+func (w *WorkerAppSpec) deployment() *appsv1.Deployment {
 	replicas := int32(1)
 	if w.MaxReplicas > 0 {
 		replicas = int32(w.MaxReplicas)
 	}
+	if w.Paused {
+		replicas = 0
+	}
 
 	// Build resource requirements with defaults
 	cpuVal := w.AssignedCPU
 	if cpuVal == "" {
-		cpuVal = "1"
+		cpuVal = k8s.DefaultWorkerCPU
 	}
 	memVal := w.AssignedMemory
 	if memVal == "" {
-		memVal = "500Mi"
+		memVal = k8s.DefaultWorkerMemory
 	}
 	diskVal := w.AssignedDisk
 	if diskVal == "" {
-		diskVal = "2Gi"
+		diskVal = k8s.DefaultWorkerDisk
 	}
 	resources := corev1.ResourceRequirements{
 		Limits: corev1.ResourceList{
@@ -107,7 +335,7 @@ func (w *WorkerAppSpec) EnsureDeployment(ctx context.Context) error {
 			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
 				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
 					MatchExpressions: []corev1.NodeSelectorRequirement{{
-						Key:      "topology.kubernetes.io/region",
+						Key:      k8s.RegionLabel,
 						Operator: corev1.NodeSelectorOpIn,
 						Values:   []string{w.MainRegion},
 					}},
@@ -116,11 +344,91 @@ func (w *WorkerAppSpec) EnsureDeployment(ctx context.Context) error {
 		}
 	}
 
-	deployment := &appsv1.Deployment{
+	nodeSelector, tolerations := k8s.PoolScheduling(w.Pool, w.Arch)
+
+	// Restricted securityContext; a read-only root filesystem gets a writable /tmp
+	podSecurity, containerSecurity := k8s.WorkerSecurity(w.RunAsRoot, w.WritableRootFS)
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	if !w.WritableRootFS {
+		volumes = []corev1.Volume{{
+			Name:         k8s.WorkerTmpVolume,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}}
+		mounts = []corev1.VolumeMount{{Name: k8s.WorkerTmpVolume, MountPath: "/tmp"}}
+	}
+	// The client certificate is rotated in place; a pod waits for its first issue
+	if k8s.InternalIssuer != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name:         mtlsVolume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: w.MTLSSecretName()}},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: mtlsVolume, MountPath: k8s.MTLSMountPath, ReadOnly: true})
+	}
+
+	// Domain rules are enforced by the egress proxy
+	var env []corev1.EnvVar
+	if k8s.EgressProxied(w.Egress) {
+		env = k8s.EgressProxyEnv()
+	}
+
+	container := corev1.Container{
+		Name:  w.Name(),
+		Image: w.Image,
+		Ports: []corev1.ContainerPort{{
+			ContainerPort: int32(w.Port),
+		}},
+		Resources:       resources,
+		SecurityContext: containerSecurity,
+		VolumeMounts:    mounts,
+		Env:             env,
+		EnvFrom: []corev1.EnvFromSource{
+			{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: w.EnvConfigMapName()},
+				},
+			},
+			{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: w.SecretName()},
+				},
+			},
+		},
+	}
+	// A static worker serves the files an init container unpacked into the site volume
+	var initContainers []corev1.Container
+	if w.Static != nil {
+		// The image runs as root; run it as a fixed user unless the worker may run as root
+		staticSecurity := containerSecurity.DeepCopy()
+		if !w.RunAsRoot {
+			user := k8s.StaticSiteUser
+			staticSecurity.RunAsUser = &user
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name:         k8s.StaticSiteVolume,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		initContainers = []corev1.Container{{
+			Name:            "fetch-site",
+			Image:           w.Image,
+			Command:         w.Static.FetchCommand(),
+			Env:             append(w.Static.FetchEnv(), env...),
+			Resources:       resources,
+			SecurityContext: staticSecurity,
+			VolumeMounts:    []corev1.VolumeMount{{Name: k8s.StaticSiteVolume, MountPath: k8s.StaticSiteRoot}},
+		}}
+		container.Command = k8s.ServeCommand(w.Port)
+		container.Env = append(k8s.ServeEnv(), env...)
+		container.SecurityContext = staticSecurity
+		container.VolumeMounts = append(container.VolumeMounts,
+			corev1.VolumeMount{Name: k8s.StaticSiteVolume, MountPath: k8s.StaticSiteRoot, ReadOnly: true})
+	}
+
+	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      w.Name(),
-			Namespace: k8s.WorkerNamespace,
-			Labels:    w.Labels(),
+			Namespace: w.Namespace(),
+			Labels:    w.ObjectLabels(),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
@@ -128,56 +436,34 @@ func (w *WorkerAppSpec) EnsureDeployment(ctx context.Context) error {
 				MatchLabels: map[string]string{"app": w.Name()},
 			},
 			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{Labels: w.Labels()},
+				ObjectMeta: metav1.ObjectMeta{Labels: w.podLabels()},
 				Spec: corev1.PodSpec{
-					Affinity: affinity,
-					Containers: []corev1.Container{{
-						Name:  w.Name(),
-						Image: w.Image,
-						Ports: []corev1.ContainerPort{{
-							ContainerPort: int32(w.Port),
-						}},
-						Resources: resources,
-						EnvFrom: []corev1.EnvFromSource{
-							{
-								ConfigMapRef: &corev1.ConfigMapEnvSource{
-									LocalObjectReference: corev1.LocalObjectReference{Name: w.EnvConfigMapName()},
-								},
-							},
-							{
-								SecretRef: &corev1.SecretEnvSource{
-									LocalObjectReference: corev1.LocalObjectReference{Name: w.SecretName()},
-								},
-							},
-						},
-					}},
+					Affinity:        affinity,
+					NodeSelector:    nodeSelector,
+					Tolerations:     tolerations,
+					SecurityContext: podSecurity,
+					Volumes:         volumes,
+					InitContainers:  initContainers,
+					Containers:      []corev1.Container{container},
 				},
 			},
 		},
 	}
-
-	client := k8s.K8sClient.AppsV1().Deployments(k8s.WorkerNamespace)
-	_, err := client.Get(ctx, w.Name(), metav1.GetOptions{})
-	if errors.IsNotFound(err) {
-		_, err = client.Create(ctx, deployment, metav1.CreateOptions{})
-	} else if err == nil {
-		_, err = client.Update(ctx, deployment, metav1.UpdateOptions{})
-	}
-	return err
 }
 
 // EnsureService creates a headless Service (clusterIP: None) in the worker namespace,
 // so DNS resolves directly to pod IPs and CoreDNS can control routing.
 func (w *WorkerAppSpec) EnsureService(ctx context.Context) error {
-	if k8s.K8sClient == nil {
-		return fmt.Errorf("k8s client not initialized")
+	c, err := w.target()
+	if err != nil {
+		return err
 	}
 
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      w.Name(),
-			Namespace: k8s.WorkerNamespace,
-			Labels:    w.Labels(),
+			Namespace: w.Namespace(),
+			Labels:    w.ObjectLabels(),
 		},
 		Spec: corev1.ServiceSpec{
 			ClusterIP: corev1.ClusterIPNone,
@@ -189,7 +475,7 @@ func (w *WorkerAppSpec) EnsureService(ctx context.Context) error {
 		},
 	}
 
-	client := k8s.K8sClient.CoreV1().Services(k8s.WorkerNamespace)
+	client := c.Kube.CoreV1().Services(w.Namespace())
 	existing, err := client.Get(ctx, w.Name(), metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		_, err = client.Create(ctx, service, metav1.CreateOptions{})
@@ -214,20 +500,21 @@ func (w *WorkerAppSpec) ExternalNameServiceName() string {
 }
 
 // EnsureExternalNameService creates an ExternalName Service in the ingress namespace
-// pointing to the headless service FQDN in the worker namespace.
-// This lets Traefik (in ingress ns) reach worker pods via CoreDNS resolution.
+// pointing to the headless service FQDN in the worker's namespace.
+// This lets the ingress controller (in ingress ns) reach worker pods via CoreDNS resolution.
 func (w *WorkerAppSpec) EnsureExternalNameService(ctx context.Context) error {
-	if k8s.K8sClient == nil {
-		return fmt.Errorf("k8s client not initialized")
+	c, err := w.target()
+	if err != nil {
+		return err
 	}
 
-	externalName := fmt.Sprintf("%s.%s.svc.cluster.local", w.Name(), k8s.WorkerNamespace)
+	externalName := fmt.Sprintf("%s.%s.svc.cluster.local", w.Name(), w.Namespace())
 
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      w.ExternalNameServiceName(),
 			Namespace: k8s.IngressNamespace,
-			Labels:    w.Labels(),
+			Labels:    w.ObjectLabels(),
 		},
 		Spec: corev1.ServiceSpec{
 			Type:         corev1.ServiceTypeExternalName,
@@ -239,7 +526,7 @@ func (w *WorkerAppSpec) EnsureExternalNameService(ctx context.Context) error {
 		},
 	}
 
-	client := k8s.K8sClient.CoreV1().Services(k8s.IngressNamespace)
+	client := c.Kube.CoreV1().Services(k8s.IngressNamespace)
 	existing, err := client.Get(ctx, w.ExternalNameServiceName(), metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		_, err = client.Create(ctx, service, metav1.CreateOptions{})
@@ -258,19 +545,20 @@ func (w *WorkerAppSpec) EnsureExternalNameService(ctx context.Context) error {
 
 // EnsureConfigMap ensures the worker's env ConfigMap exists, stripping reserved keys.
 func (w *WorkerAppSpec) EnsureConfigMap(ctx context.Context) error {
-	if k8s.K8sClient == nil {
-		return fmt.Errorf("k8s client not initialized")
+	c, err := w.target()
+	if err != nil {
+		return err
 	}
-	client := k8s.K8sClient.CoreV1().ConfigMaps(k8s.WorkerNamespace)
+	client := c.Kube.CoreV1().ConfigMaps(w.Namespace())
 	existing, err := client.Get(ctx, w.EnvConfigMapName(), metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		cm := &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      w.EnvConfigMapName(),
-				Namespace: k8s.WorkerNamespace,
-				Labels:    w.Labels(),
+				Namespace: w.Namespace(),
+				Labels:    w.ObjectLabels(),
 			},
-			Data: map[string]string{},
+			Data: w.legacyConfigMapData(ctx, c),
 		}
 		_, err = client.Create(ctx, cm, metav1.CreateOptions{})
 		return err
@@ -294,30 +582,37 @@ func (w *WorkerAppSpec) EnsureConfigMap(ctx context.Context) error {
 
 // systemSecretData returns the reserved key-value pairs to inject into worker Secrets.
 func (w *WorkerAppSpec) systemSecretData() map[string][]byte {
-	return map[string][]byte{
+	data := map[string][]byte{
 		"COMBINATOR_API_ENDPOINT": []byte(w.CombinatorEndpoint()),
 		"RAYSAIL_UID":             []byte(w.OwnerID),
 		"RAYSAIL_SECRET_KEY":      []byte(w.OwnerSK),
 	}
+	if k8s.InternalIssuer != nil {
+		data["COMBINATOR_TLS_DIR"] = []byte(k8s.MTLSMountPath)
+	}
+	return data
 }
 
 // EnsureSecret ensures the worker's Secret exists with system vars injected.
 func (w *WorkerAppSpec) EnsureSecret(ctx context.Context) error {
-	if k8s.K8sClient == nil {
-		return fmt.Errorf("k8s client not initialized")
+	c, err := w.target()
+	if err != nil {
+		return err
 	}
-	client := k8s.K8sClient.CoreV1().Secrets(k8s.WorkerNamespace)
+	client := c.Kube.CoreV1().Secrets(w.Namespace())
 	existing, err := client.Get(ctx, w.SecretName(), metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		secret := &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      w.SecretName(),
-				Namespace: k8s.WorkerNamespace,
-				Labels:    w.Labels(),
+				Namespace: w.Namespace(),
+				Labels:    w.ObjectLabels(),
 			},
 			Type: corev1.SecretTypeOpaque,
-			Data: w.systemSecretData(),
+			Data: w.legacySecretData(ctx, c),
 		}
+		maps.Copy(secret.Data, w.systemSecretData())
+		w.applyAttachments(ctx, secret)
 		_, err = client.Create(ctx, secret, metav1.CreateOptions{})
 		return err
 	}
@@ -328,80 +623,142 @@ func (w *WorkerAppSpec) EnsureSecret(ctx context.Context) error {
 	if existing.Data == nil {
 		existing.Data = map[string][]byte{}
 	}
+	// COMBINATOR_TLS_DIR is only set while workers get a client certificate
+	delete(existing.Data, "COMBINATOR_TLS_DIR")
 	maps.Copy(existing.Data, w.systemSecretData())
+	w.applyAttachments(ctx, existing)
 	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
 	return err
 }
 
-// EnsureIngressRoute checks and creates/updates the IngressRoute if missing.
-func (w *WorkerAppSpec) EnsureIngressRoute(ctx context.Context) error {
-	if k8s.DynamicClient == nil {
-		return fmt.Errorf("dynamic client not initialized")
+// applyAttachments injects the connection strings of the worker's attached
+// resources. A failure leaves the previous ones for the worker.sync_attachments
+// job to fix rather than failing the Secret.
+func (w *WorkerAppSpec) applyAttachments(ctx context.Context, sec *corev1.Secret) {
+	env, err := AttachmentEnv(ctx, w.WorkerID, w.OwnerID)
+	if err != nil {
+		ctrlLog.Warn("render attachments failed", "worker", w.Name(), "error", err)
+		return
 	}
+	ApplyAttachmentEnv(sec, env)
+}
 
-	host := fmt.Sprintf("%s-%s.worker.%s", w.WorkerID, w.OwnerID, k8s.Domain)
+// EnsureRoute creates or updates the route (IngressRoute, Ingress or
+// HTTPRoute, see k8s.Ingress) for the worker's host and its route rules,
+// with its edge limits and error pages.
+func (w *WorkerAppSpec) EnsureRoute(ctx context.Context) error {
+	c, err := w.target()
+	if err != nil {
+		return err
+	}
+	return k8s.EnsureRoute(ctx, c.Dynamic, k8s.Route{
+		Name:       w.Name(),
+		Host:       WorkerHost(w.WorkerID, w.OwnerID),
+		Service:    w.ExternalNameServiceName(),
+		Port:       w.Port,
+		TLSSecret:  "worker-tls",
+		Labels:     w.Labels(),
+		Rules:      w.Routes,
+		Limits:     w.EdgeLimits,
+		ErrorPages: w.ErrorPages,
+	})
+}
 
-	ingressRoute := &unstructured.Unstructured{
-		Object: map[string]any{
-			"apiVersion": "traefik.io/v1alpha1",
-			"kind":       "IngressRoute",
-			"metadata": map[string]any{
-				"name":      w.Name(),
-				"namespace": k8s.IngressNamespace,
-				"labels": map[string]any{
-					"app":       w.Name(),
-					"worker-id": w.WorkerID,
-					"owner-id":  w.OwnerID,
-				},
-			},
-			"spec": map[string]any{
-				"entryPoints": []any{"websecure"},
-				"routes": []any{
-					map[string]any{
-						"match": fmt.Sprintf("Host(`%s`)", host),
-						"kind":  "Rule",
-						"services": []any{
-							map[string]any{
-								"name": w.ExternalNameServiceName(),
-								"port": w.Port,
-							},
-						},
-					},
-				},
-				"tls": map[string]any{
-					"secretName": "worker-tls",
-				},
-			},
-		},
+// DeleteAll deletes all sub-resources for this worker in its cluster,
+// including any left in the shared namespace before a migration. Already
+// missing objects are not an error; the other failures are joined.
+func (w *WorkerAppSpec) DeleteAll(ctx context.Context) error {
+	c, err := w.target()
+	if err != nil {
+		return err
 	}
+	var errs []error
+	del := func(kind string, err error) {
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete %s: %w", kind, err))
+		}
+	}
+	errs = append(errs, w.deleteNamespaced(ctx, c, w.Namespace()))
+	if w.migrating() {
+		errs = append(errs, w.deleteNamespaced(ctx, c, k8s.WorkerNamespace))
+	}
+	del("external name service", c.Kube.CoreV1().Services(k8s.IngressNamespace).Delete(ctx, w.ExternalNameServiceName(), metav1.DeleteOptions{}))
+	del("route", k8s.Routes(c.Dynamic).Delete(ctx, w.Name(), metav1.DeleteOptions{}))
+	del("edge middlewares", k8s.DeleteEdgeMiddlewares(ctx, c.Dynamic, w.Name()))
+	return stderrors.Join(errs...)
+}
 
-	client := k8s.DynamicClient.Resource(k8s.IngressRouteGVR).Namespace(k8s.IngressNamespace)
-	existing, err := client.Get(ctx, w.Name(), metav1.GetOptions{})
-	if errors.IsNotFound(err) {
-		_, err = client.Create(ctx, ingressRoute, metav1.CreateOptions{})
-	} else if err == nil {
-		ingressRoute.SetResourceVersion(existing.GetResourceVersion())
-		_, err = client.Update(ctx, ingressRoute, metav1.UpdateOptions{})
+// DeleteLegacy removes what an earlier version created for the worker in the
+// shared namespace, once the worker runs in its tenant namespace.
+func (w *WorkerAppSpec) DeleteLegacy(ctx context.Context) error {
+	if !w.migrating() {
+		return nil
 	}
-	return err
+	c, err := w.target()
+	if err != nil {
+		return err
+	}
+	return w.deleteNamespaced(ctx, c, k8s.WorkerNamespace)
 }
 
-// DeleteAll deletes all sub-resources for this worker.
-func (w *WorkerAppSpec) DeleteAll(ctx context.Context) {
-	if k8s.K8sClient != nil {
-		k8s.K8sClient.AppsV1().Deployments(k8s.WorkerNamespace).Delete(ctx, w.Name(), metav1.DeleteOptions{})
-		k8s.K8sClient.CoreV1().Services(k8s.WorkerNamespace).Delete(ctx, w.Name(), metav1.DeleteOptions{})
-		k8s.K8sClient.CoreV1().Services(k8s.IngressNamespace).Delete(ctx, w.ExternalNameServiceName(), metav1.DeleteOptions{})
-		k8s.K8sClient.CoreV1().ConfigMaps(k8s.WorkerNamespace).Delete(ctx, w.EnvConfigMapName(), metav1.DeleteOptions{})
-		k8s.K8sClient.CoreV1().Secrets(k8s.WorkerNamespace).Delete(ctx, w.SecretName(), metav1.DeleteOptions{})
+// deleteNamespaced deletes the worker's Deployment, Service, ConfigMaps, Secret
+// and egress policy in ns
+func (w *WorkerAppSpec) deleteNamespaced(ctx context.Context, c *k8s.Cluster, ns string) error {
+	var errs []error
+	del := func(kind string, err error) {
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete %s in %s: %w", kind, ns, err))
+		}
 	}
-	if k8s.DynamicClient != nil {
-		k8s.DynamicClient.Resource(k8s.IngressRouteGVR).Namespace(k8s.IngressNamespace).Delete(ctx, w.Name(), metav1.DeleteOptions{})
+	del("deployment", c.Kube.AppsV1().Deployments(ns).Delete(ctx, w.Name(), metav1.DeleteOptions{}))
+	del("service", c.Kube.CoreV1().Services(ns).Delete(ctx, w.Name(), metav1.DeleteOptions{}))
+	del("configmap", c.Kube.CoreV1().ConfigMaps(ns).Delete(ctx, w.EnvConfigMapName(), metav1.DeleteOptions{}))
+	del("secret", c.Kube.CoreV1().Secrets(ns).Delete(ctx, w.SecretName(), metav1.DeleteOptions{}))
+	del("client certificate", k8s.DeleteCertificate(ctx, c, ns, w.MTLSSecretName()))
+	del("egress policy", k8s.DeleteNetworkPolicy(ctx, c.Kube, ns, w.EgressPolicyName()))
+	del("egress allowlist", c.Kube.CoreV1().ConfigMaps(ns).Delete(ctx, w.EgressPolicyName(), metav1.DeleteOptions{}))
+	return stderrors.Join(errs...)
+}
+
+// legacyConfigMapData is the env a migrating worker had in the shared
+// namespace, used to seed the ConfigMap in its tenant namespace
+func (w *WorkerAppSpec) legacyConfigMapData(ctx context.Context, c *k8s.Cluster) map[string]string {
+	data := map[string]string{}
+	if !w.migrating() {
+		return data
+	}
+	cm, err := c.Kube.CoreV1().ConfigMaps(k8s.WorkerNamespace).Get(ctx, w.EnvConfigMapName(), metav1.GetOptions{})
+	if err != nil {
+		return data
 	}
+	maps.Copy(data, cm.Data)
+	for _, key := range ReservedEnvKeys {
+		delete(data, key)
+	}
+	return data
+}
+
+// legacySecretData is legacyConfigMapData for the worker's Secret
+func (w *WorkerAppSpec) legacySecretData(ctx context.Context, c *k8s.Cluster) map[string][]byte {
+	data := map[string][]byte{}
+	if !w.migrating() {
+		return data
+	}
+	sec, err := c.Kube.CoreV1().Secrets(k8s.WorkerNamespace).Get(ctx, w.SecretName(), metav1.GetOptions{})
+	if err != nil {
+		return data
+	}
+	maps.Copy(data, sec.Data)
+	return data
 }
 
-// ListWorkers lists all workers by querying Deployments with label selectors.
+// ListWorkers lists the workers of the local cluster from their Deployments,
+// served from the read cache once synced and by label selector from the
+// apiserver before that.
 func ListWorkers(workerId string, ownerId string) ([]WorkerAppSpec, error) {
+	if readCache.ready() {
+		return cachedWorkers(workerId, ownerId), nil
+	}
 	if k8s.K8sClient == nil {
 		return nil, fmt.Errorf("k8s client not initialized")
 	}
@@ -418,18 +775,18 @@ func ListWorkers(workerId string, ownerId string) ([]WorkerAppSpec, error) {
 	}
 	opts.LabelSelector = strings.Join(selectors, ",")
 
-	deployments, err := k8s.K8sClient.AppsV1().Deployments(k8s.WorkerNamespace).List(ctx, opts)
+	ns := k8s.WorkerNamespace
+	if k8s.TenantNamespaces {
+		ns = metav1.NamespaceAll
+	}
+	deployments, err := k8s.K8sClient.AppsV1().Deployments(ns).List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	var workers []WorkerAppSpec
-	for _, d := range deployments.Items {
-		workers = append(workers, WorkerAppSpec{
-			WorkerID: d.Labels["worker-id"],
-			OwnerID:  d.Labels["owner-id"],
-			Image:    d.Spec.Template.Spec.Containers[0].Image,
-		})
+	for i := range deployments.Items {
+		workers = append(workers, workerFromDeployment(&deployments.Items[i]))
 	}
 	return workers, nil
 }
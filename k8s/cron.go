@@ -1,10 +1,13 @@
 package k8s
 
 import (
-	"log"
 	"time"
+
+	"jabberwocky238/console/logging"
 )
 
+var cronLog = logging.Component("cron")
+
 type CronScheduler struct {
 	processor      *Processor
 	durationToCall map[time.Duration][]Job
@@ -34,7 +37,7 @@ func (s *CronScheduler) Start() {
 
 		go s.runTicker(ticker, jobs)
 	}
-	log.Printf("[cron] started %d ticker(s)", len(s.timerMap))
+	cronLog.Info("started", "tickers", len(s.timerMap))
 }
 
 func (s *CronScheduler) runTicker(ticker *time.Ticker, jobs []Job) {
@@ -42,7 +45,9 @@ func (s *CronScheduler) runTicker(ticker *time.Ticker, jobs []Job) {
 		select {
 		case <-ticker.C:
 			for _, job := range jobs {
-				s.processor.Submit(job)
+				if err := s.processor.Submit(job); err != nil {
+					cronLog.Warn("skip job", "job_type", job.Type(), "error", err)
+				}
 			}
 		case <-s.stopCh:
 			ticker.Stop()
@@ -53,6 +58,6 @@ func (s *CronScheduler) runTicker(ticker *time.Ticker, jobs []Job) {
 
 func (s *CronScheduler) Close() error {
 	close(s.stopCh)
-	log.Println("[cron] stopped")
+	cronLog.Info("stopped")
 	return nil
 }
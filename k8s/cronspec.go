@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	// Schedules carry IANA time zones; the alpine images have no zoneinfo
+	_ "time/tzdata"
+)
+
+// CronSchedule is a parsed cron spec: five fields (minute, hour, day of
+// month, month, day of week), a descriptor like @daily, or @every <duration>.
+// The CronScheduler runs jobs at fixed intervals; CronSchedule is for
+// user-defined schedules, whose next run is stored and claimed from the DB.
+type CronSchedule struct {
+	every time.Duration // @every; the fields are unused then
+
+	minute, hour, dom, month, dow uint64 // bit n set when value n matches
+	domAny, dowAny                bool   // field was *, see matchesDay
+}
+
+// cronDescriptors map the named schedules to their five fields
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronFieldRanges bound minute, hour, day of month, month and day of week;
+// day of week 7 is Sunday like 0
+var cronFieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// cronSearchLimit bounds Next for specs that never match, like "0 0 30 2 *"
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseCronSchedule parses a five field spec, a descriptor or @every <duration>.
+// Fields accept *, a, a-b, */n, a-b/n and comma separated lists of those
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid @every duration %q", every)
+		}
+		return &CronSchedule{every: d}, nil
+	}
+	if fields, ok := cronDescriptors[spec]; ok {
+		spec = fields
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.New("cron spec must have 5 fields")
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFieldRanges[i][0], cronFieldRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron field %d (%q): %w", i+1, f, err)
+		}
+		bits[i] = b
+	}
+	s := &CronSchedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseCronField returns the values f matches as a bit set
+func parseCronField(f string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+
+		start, end := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			n, err := strconv.Atoi(a)
+			if err != nil || n < lo || n > hi {
+				return 0, fmt.Errorf("%q is not between %d and %d", a, lo, hi)
+			}
+			start, end = n, n
+			if isRange {
+				m, err := strconv.Atoi(b)
+				if err != nil || m < n || m > hi {
+					return 0, fmt.Errorf("invalid range %q", rng)
+				}
+				end = m
+			} else if hasStep {
+				end = hi
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// Interval is the duration of an @every schedule, 0 for the others
+func (s *CronSchedule) Interval() time.Duration {
+	return s.every
+}
+
+// Next is the first time after t the schedule fires, in t's location, or
+// the zero time when it never does. @every schedules fire every interval
+// from t.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	loc := t.Location()
+	limit := t.Add(cronSearchLimit)
+	// time.Date rather than Truncate, which is off in zones with a non-hour offset
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+
+	for t.Before(limit) {
+		prev := t
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+		// A midnight skipped by a DST change normalizes backwards
+		if !t.After(prev) {
+			t = prev.Add(time.Hour)
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay follows cron: when both day fields are restricted, a day
+// matching either of them fires
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -3,19 +3,27 @@ package k8s
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"jabberwocky238/console/dblayer"
-	"log"
+	"jabberwocky238/console/logging"
+	"jabberwocky238/console/metrics"
+	"jabberwocky238/console/notify"
+	"jabberwocky238/console/settings"
 	"net"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"slices"
 )
 
+var domainLog = logging.Component("customdomain")
+
 type DomainStatus string
 
 const (
@@ -25,15 +33,20 @@ const (
 )
 
 type CustomDomain struct {
-	ID        int          `json:"id"`
-	CDID      string       `json:"cdid"`
-	Domain    string       `json:"domain"`
-	Target    string       `json:"target"`
-	TXTName   string       `json:"txt_name"`
-	TXTValue  string       `json:"txt_value"`
-	Status    DomainStatus `json:"status"`
-	UserUID   string       `json:"user_uid"`
-	CreatedAt time.Time    `json:"created_at"`
+	ID          int          `json:"id"`
+	CDID        string       `json:"cdid"`
+	Domain      string       `json:"domain"`
+	Target      string       `json:"target"`
+	TXTName     string       `json:"txt_name"`
+	TXTValue    string       `json:"txt_value"`
+	Status      DomainStatus `json:"status"`
+	Issuer      string       `json:"issuer,omitempty"` // override from AllowedIssuers, "Kind/name"
+	Challenge   string       `json:"challenge"`        // ChallengeHTTP01 or ChallengeDNS01
+	UserUID     string       `json:"user_uid"`
+	Tags        dblayer.Tags `json:"tags"`
+	Environment string       `json:"environment"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
 // generateVerifyToken generates a random verification token
@@ -43,47 +56,73 @@ func generateVerifyToken() string {
 	return hex.EncodeToString(b)
 }
 
-// NewCustomDomain creates a new custom domain verification request
-func NewCustomDomain(userUID, domain, target string) (*CustomDomain, error) {
+// NewCustomDomain creates a new custom domain verification request. issuer
+// must have been checked with IssuerOverride; challenge defaults to HTTP-01
+// and environment to dblayer.DefaultEnvironment.
+func NewCustomDomain(ctx context.Context, userUID, domain, target, issuer, challenge string, tags dblayer.Tags, environment string) (*CustomDomain, error) {
+	if challenge == "" {
+		challenge = ChallengeHTTP01
+	}
+	if environment == "" {
+		environment = dblayer.DefaultEnvironment
+	}
 	cdid := generateVerifyToken()[:8]
-	token := generateVerifyToken()
-	txtName := fmt.Sprintf("_combinator-verify.%s", domain)
-	txtValue := fmt.Sprintf("combinator-verify=%s", token)
+	txtName, txtValue := NewDomainVerifyRecord(domain)
 
-	err := dblayer.CreateCustomDomain(cdid, userUID, domain, target, txtName, txtValue, string(DomainStatusPending))
+	err := dblayer.CreateCustomDomain(ctx, cdid, userUID, domain, target, txtName, txtValue, string(DomainStatusPending), issuer, challenge, tags, environment)
 	if err != nil {
 		return nil, err
 	}
 
 	cd := &CustomDomain{
-		CDID:      cdid,
-		Domain:    domain,
-		Target:    target,
-		TXTName:   txtName,
-		TXTValue:  txtValue,
-		Status:    DomainStatusPending,
-		UserUID:   userUID,
-		CreatedAt: time.Now(),
+		CDID:        cdid,
+		Domain:      domain,
+		Target:      target,
+		TXTName:     txtName,
+		TXTValue:    txtValue,
+		Status:      DomainStatusPending,
+		Issuer:      issuer,
+		Challenge:   challenge,
+		UserUID:     userUID,
+		Tags:        tags,
+		Environment: environment,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
-	log.Printf("[customdomain] Created custom domain request: %s -> %s (TXT: %s = %s)", domain, target, txtName, txtValue)
+	domainLog.Info("created custom domain request", "domain", domain, "target", target, "txt_name", txtName, "user_id", userUID)
 	return cd, nil
 }
 
+// NewDomainVerifyRecord returns the TXT record that proves control of
+// domain, with a fresh token. Custom domains and mail domains use the same one.
+func NewDomainVerifyRecord(domain string) (name, value string) {
+	return fmt.Sprintf("_combinator-verify.%s", domain), fmt.Sprintf("combinator-verify=%s", generateVerifyToken())
+}
+
+// HasTXTRecord reports whether one of the TXT records of name satisfies match
+func HasTXTRecord(name string, match func(record string) bool) (bool, error) {
+	records, err := net.LookupTXT(name)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(records, match), nil
+}
+
 // VerifyTXT checks if the TXT record is correctly set via DNS lookup
 func (cd *CustomDomain) VerifyTXT() bool {
-	records, err := net.LookupTXT(cd.TXTName)
+	found, err := HasTXTRecord(cd.TXTName, func(r string) bool { return r == cd.TXTValue })
 	if err != nil {
-		log.Printf("[customdomain] TXT lookup failed for %s: %v", cd.TXTName, err)
+		domainLog.Debug("TXT lookup failed", "txt_name", cd.TXTName, "error", err)
 		return false
 	}
 
-	if slices.Contains(records, cd.TXTValue) {
-		log.Printf("[customdomain] TXT record verified: %s = %s", cd.TXTName, cd.TXTValue)
+	if found {
+		domainLog.Info("TXT record verified", "txt_name", cd.TXTName)
 		return true
 	}
 
-	log.Printf("[customdomain] TXT record not found or mismatch for %s (expected: %s, found: %v)", cd.TXTName, cd.TXTValue, records)
+	domainLog.Debug("TXT record not found or mismatch", "txt_name", cd.TXTName, "expected", cd.TXTValue)
 	return false
 }
 
@@ -91,7 +130,7 @@ func (cd *CustomDomain) VerifyTXT() bool {
 func (cd *CustomDomain) VerifyCNAME() bool {
 	cname, err := net.LookupCNAME(cd.Domain)
 	if err != nil {
-		log.Printf("[customdomain] CNAME lookup failed for %s: %v", cd.Domain, err)
+		domainLog.Debug("CNAME lookup failed", "domain", cd.Domain, "error", err)
 		return false
 	}
 
@@ -107,206 +146,453 @@ func (cd *CustomDomain) VerifyCNAME() bool {
 	}
 
 	if cname == targetWithoutDot || cname == cd.Target {
-		log.Printf("[customdomain] CNAME record verified: %s -> %s", cd.Domain, cname)
+		domainLog.Info("CNAME record verified", "domain", cd.Domain, "cname", cname)
 		return true
 	}
 
-	log.Printf("[customdomain] CNAME record mismatch for %s (expected: %s, found: %s)", cd.Domain, cd.Target, cname)
+	domainLog.Debug("CNAME record mismatch", "domain", cd.Domain, "expected", cd.Target, "found", cname)
 	return false
 }
 
-// StartVerification starts the verification loop (5s interval, 12 times max = 60s total)
-func (cd *CustomDomain) StartVerification() {
+// DNS verification schedule of new custom domains, tunable at runtime
+var (
+	domainVerifyAttempts = settings.NewInt("domain.verify_attempts", 12, 1, 720,
+		"DNS checks of a new custom domain before it fails with verification timed out")
+	domainVerifyInterval = settings.NewDuration("domain.verify_interval", 5*time.Second, time.Second, 10*time.Minute,
+		"Wait between the DNS checks of a new custom domain")
+	domainReadyTimeout = settings.NewDuration("domain.ready_timeout", 15*time.Minute, 0, 2*time.Hour,
+		"How long a verified custom domain waits for its target worker to have ready replicas before it is routed anyway; 0 disables the wait")
+)
+
+// WorkerReadiness reports whether target, the target host of a domain of
+// userUID, is a worker of that user (ok) with ready replicas. It is set by the
+// gateway that verifies domains; nil routes domains as soon as DNS verifies.
+var WorkerReadiness func(ctx context.Context, userUID, target string) (ready, ok bool, err error)
+
+// StartVerification starts the verification loop (domain.verify_attempts
+// checks, domain.verify_interval apart; 12 × 5s by default). Once DNS is
+// verified the route waits for the target worker to be ready (see
+// waitForTarget) unless force is set, so the domain does not serve 502s while
+// the first deploy is still pulling its image.
+func (cd *CustomDomain) StartVerification(force bool) {
+	attempts, interval := domainVerifyAttempts.Get(), domainVerifyInterval.Get()
 	go func() {
-		for i := range 12 {
-			time.Sleep(5 * time.Second)
+		// outlives the request that started it
+		ctx := context.Background()
+		for i := range attempts {
+			time.Sleep(interval)
 
 			// Check both TXT and CNAME records
 			txtVerified := cd.VerifyTXT()
 			cnameVerified := cd.VerifyCNAME()
 
 			if txtVerified && cnameVerified {
-				log.Printf("[customdomain] Verification successful for %s (attempt %d/12)", cd.Domain, i+1)
-				cd.Status = DomainStatusSuccess
-				dblayer.UpdateCustomDomainStatus(cd.CDID, string(DomainStatusSuccess))
-
-				// Create IngressRoute and request certificate
-				if err := cd.CreateIngressRoute(); err != nil {
-					log.Printf("[customdomain] Failed to create IngressRoute for %s: %v", cd.Domain, err)
-					cd.Status = DomainStatusError
-					dblayer.UpdateCustomDomainStatus(cd.CDID, string(DomainStatusError))
+				domainLog.Info("verification successful", "domain", cd.Domain, "attempt", i+1)
+				metrics.DomainVerifications.WithLabelValues("success").Inc()
+				if !force && !cd.waitForTarget(ctx) {
+					return
+				}
+				cd.setStatus(ctx, DomainStatusSuccess, "")
+
+				// Create route and request certificate
+				if err := cd.CreateIngressRoute(ctx); err != nil {
+					domainLog.Error("create ingress route failed", "domain", cd.Domain, "error", err)
+					metrics.DomainVerifications.WithLabelValues("error").Inc()
+					cd.setStatus(ctx, DomainStatusError, err.Error())
+					cd.notify(ctx, dblayer.NotificationDomainFailed, dblayer.NotificationSeverityError,
+						fmt.Sprintf("Domain %s could not be set up", cd.Domain), err.Error())
+					return
 				}
+				cd.notify(ctx, dblayer.NotificationDomainVerified, dblayer.NotificationSeverityInfo,
+					fmt.Sprintf("Domain %s verified", cd.Domain),
+					"The route is in place and the TLS certificate is being issued.")
 				return
 			}
 
-			log.Printf("[customdomain] Verification attempt %d/12 for %s (TXT: %v, CNAME: %v)", i+1, cd.Domain, txtVerified, cnameVerified)
+			domainLog.Debug("verification attempt", "domain", cd.Domain, "attempt", i+1, "txt", txtVerified, "cname", cnameVerified)
 		}
 
-		// Failed after 12 attempts
-		cd.Status = DomainStatusError
-		dblayer.UpdateCustomDomainStatus(cd.CDID, string(DomainStatusError))
-		log.Printf("[customdomain] Verification failed for %s after 12 attempts (60s)", cd.Domain)
+		// Failed after all attempts
+		cd.setStatus(ctx, DomainStatusError, "verification timed out")
+		cd.notify(ctx, dblayer.NotificationDomainFailed, dblayer.NotificationSeverityError,
+			fmt.Sprintf("Domain %s verification timed out", cd.Domain),
+			fmt.Sprintf("Check the TXT record %s = %s and the CNAME of %s to %s, then add the domain again.", cd.TXTName, cd.TXTValue, cd.Domain, cd.Target))
+		domainLog.Warn("verification timed out", "domain", cd.Domain, "attempts", attempts)
+		metrics.DomainVerifications.WithLabelValues("timeout").Inc()
 	}()
 }
 
-// CreateIngressRoute creates an ExternalName Service and IngressRoute for the custom domain
-// Uses HTTP-01 challenge for ZeroSSL certificate
-func (cd *CustomDomain) CreateIngressRoute() error {
+// waitForTarget polls WorkerReadiness until the worker the domain points to
+// has ready replicas. The domain stays pending meanwhile, so the drift audit
+// does not create its route either. After domain.ready_timeout the domain is
+// routed anyway and the owner is warned. It returns false when the domain was
+// deleted while waiting.
+func (cd *CustomDomain) waitForTarget(ctx context.Context) bool {
+	timeout := domainReadyTimeout.Get()
+	if WorkerReadiness == nil || timeout <= 0 {
+		return true
+	}
+	deadline := time.Now().Add(timeout)
+	announced := false
+	for {
+		ready, ok, err := WorkerReadiness(ctx, cd.UserUID, cd.Target)
+		switch {
+		case err != nil:
+			domainLog.Warn("check target readiness failed", "domain", cd.Domain, "target", cd.Target, "error", err)
+		case !ok || ready:
+			return true
+		case !announced:
+			announced = true
+			domainLog.Info("waiting for target worker to be ready", "domain", cd.Domain, "target", cd.Target)
+			cd.setStatus(ctx, DomainStatusPending, "DNS verified, waiting for the worker to have ready replicas")
+		}
+		if !time.Now().Before(deadline) {
+			cd.notify(ctx, dblayer.NotificationDomainVerified, dblayer.NotificationSeverityWarning,
+				fmt.Sprintf("Domain %s routed before its worker is ready", cd.Domain),
+				fmt.Sprintf("%s had no ready replicas after %s. The domain is routed now and serves errors until the worker is up.", cd.Target, timeout))
+			return true
+		}
+		time.Sleep(domainVerifyInterval.Get())
+		if _, err := dblayer.GetCustomDomain(ctx, cd.CDID); err == sql.ErrNoRows {
+			domainLog.Info("domain deleted while waiting for its target", "domain", cd.Domain)
+			return false
+		}
+	}
+}
+
+// setStatus persists the status and notifies subscribers of the owner
+func (cd *CustomDomain) setStatus(ctx context.Context, status DomainStatus, msg string) {
+	cd.Status = status
+	dblayer.UpdateCustomDomainStatus(ctx, cd.CDID, string(status))
+	dblayer.PublishEvent(ctx, dblayer.Event{
+		UserUID:      cd.UserUID,
+		Kind:         dblayer.EventDomainVerify,
+		ResourceType: "domain",
+		ResourceID:   cd.CDID,
+		Status:       string(status),
+		Message:      msg,
+	})
+}
+
+// notify sends the owner a notification about the outcome of the verification
+func (cd *CustomDomain) notify(ctx context.Context, kind, severity, title, message string) {
+	notify.Send(ctx, &dblayer.Notification{
+		UserUID:      cd.UserUID,
+		Kind:         kind,
+		Severity:     severity,
+		Title:        title,
+		Message:      message,
+		ResourceType: "domain",
+		ResourceID:   cd.CDID,
+	}, notify.Channels{})
+}
+
+// CreateIngressRoute creates an ExternalName Service and a route (see Ingress) for the custom domain
+// The certificate is requested from the issuer picked by IssuerFor
+func (cd *CustomDomain) CreateIngressRoute(ctx context.Context) error {
 	if DynamicClient == nil || K8sClient == nil {
 		return fmt.Errorf("k8s client not initialized")
 	}
+	cert, err := cd.certificate()
+	if err != nil {
+		return err
+	}
 
-	ctx := context.Background()
-	name := fmt.Sprintf("custom-domain-%s", cd.CDID)
-	tlsSecretName := fmt.Sprintf("custom-domain-tls-%s", cd.CDID)
+	name := cd.resourceName()
 
 	// Create ExternalName Service pointing to target domain
-	svc := &corev1.Service{
+	svc := cd.service()
+	created, err := K8sClient.CoreV1().Services(IngressNamespace).Create(ctx, svc, metav1.CreateOptions{})
+	if err != nil {
+		domainLog.Error("create service failed", "domain", cd.Domain, "error", err)
+		RecordWarning(svc, EventReasonEnsureFailed, "Create ExternalName Service for %s failed: %v", cd.Domain, err)
+		return fmt.Errorf("create service failed: %w", err)
+	}
+	RecordEvent(created, EventReasonVerified, "Domain %s verified, routing to %s", cd.Domain, cd.Target)
+	domainLog.Info("created ExternalName service", "name", name, "target", cd.Target)
+
+	// Create cert-manager Certificate for the custom domain
+	if _, err := DynamicClient.Resource(certificateGVR).Namespace(IngressNamespace).Create(ctx, cert, metav1.CreateOptions{}); err != nil {
+		domainLog.Error("create certificate failed", "domain", cd.Domain, "error", err)
+		RecordWarning(created, EventReasonEnsureFailed, "Create Certificate %s failed: %v", name, err)
+		return fmt.Errorf("create certificate failed: %w", err)
+	}
+	domainLog.Info("created certificate", "domain", cd.Domain, "issuer", issuerOf(cert), "challenge", cd.Challenge)
+	RecordEvent(created, EventReasonEnsured, "Ensured Certificate %s", name)
+
+	// Create the route
+	if _, err := Routes(DynamicClient).Create(ctx, Ingress.Build(cd.route()), metav1.CreateOptions{}); err != nil {
+		domainLog.Error("create route failed", "domain", cd.Domain, "kind", Ingress.Kind(), "error", err)
+		RecordWarning(created, EventReasonEnsureFailed, "Create %s %s failed: %v", Ingress.Kind(), name, err)
+		return fmt.Errorf("create %s failed: %w", Ingress.GVR().Resource, err)
+	}
+	RecordEvent(created, EventReasonEnsured, "Ensured %s %s", Ingress.Kind(), name)
+
+	domainLog.Info("created ingress route", "domain", cd.Domain, "tls_secret", cd.tlsSecretName())
+	return nil
+}
+
+// EnsureIngressRoute is CreateIngressRoute for a domain whose objects may
+// already exist: missing objects are created and existing ones are rewritten
+// from the database record.
+func (cd *CustomDomain) EnsureIngressRoute(ctx context.Context) error {
+	if DynamicClient == nil || K8sClient == nil {
+		return fmt.Errorf("k8s client not initialized")
+	}
+	name := cd.resourceName()
+	cert, err := cd.certificate()
+	if err != nil {
+		return err
+	}
+
+	services := K8sClient.CoreV1().Services(IngressNamespace)
+	svc, err := services.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		svc, err = services.Create(ctx, cd.service(), metav1.CreateOptions{})
+	} else if err == nil && (svc.Spec.Type != corev1.ServiceTypeExternalName || svc.Spec.ExternalName != cd.Target) {
+		svc.Spec.Type = corev1.ServiceTypeExternalName
+		svc.Spec.ExternalName = cd.Target
+		svc, err = services.Update(ctx, svc, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("ensure service failed: %w", err)
+	}
+
+	certs := DynamicClient.Resource(certificateGVR).Namespace(IngressNamespace)
+	existing, err := certs.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = certs.Create(ctx, cert, metav1.CreateOptions{})
+	} else if err == nil {
+		cert.SetResourceVersion(existing.GetResourceVersion())
+		_, err = certs.Update(ctx, cert, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		RecordWarning(svc, EventReasonEnsureFailed, "Ensure Certificate %s failed: %v", name, err)
+		return fmt.Errorf("ensure certificate failed: %w", err)
+	}
+
+	if err := EnsureRoute(ctx, DynamicClient, cd.route()); err != nil {
+		RecordWarning(svc, EventReasonEnsureFailed, "Ensure %s %s failed: %v", Ingress.Kind(), name, err)
+		return fmt.Errorf("ensure %s failed: %w", Ingress.GVR().Resource, err)
+	}
+	RecordEvent(svc, EventReasonEnsured, "Ensured Service, Certificate and %s for %s", Ingress.Kind(), cd.Domain)
+	return nil
+}
+
+// resourceName is the name of the domain's Service, Certificate and route
+func (cd *CustomDomain) resourceName() string {
+	return fmt.Sprintf("custom-domain-%s", cd.CDID)
+}
+
+func (cd *CustomDomain) tlsSecretName() string {
+	return fmt.Sprintf("custom-domain-tls-%s", cd.CDID)
+}
+
+// service is the ExternalName Service pointing to the domain's target
+func (cd *CustomDomain) service() *corev1.Service {
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
+			Name:      cd.resourceName(),
 			Namespace: IngressNamespace,
-			Labels: map[string]string{
-				"app":      "custom-domain",
-				"user-uid": cd.UserUID,
-			},
+			Labels:    cd.labels(),
 		},
 		Spec: corev1.ServiceSpec{
 			Type:         corev1.ServiceTypeExternalName,
 			ExternalName: cd.Target,
 		},
 	}
-	if _, err := K8sClient.CoreV1().Services(IngressNamespace).Create(ctx, svc, metav1.CreateOptions{}); err != nil {
-		log.Printf("[customdomain] Failed to create service for %s: %v", cd.Domain, err)
-		return fmt.Errorf("create service failed: %w", err)
-	}
-	log.Printf("[customdomain] Created ExternalName service: %s -> %s", name, cd.Target)
+}
 
-	// Create cert-manager Certificate for the custom domain (HTTP-01 challenge)
-	cert := &unstructured.Unstructured{
+// certificate is the cert-manager Certificate for the domain, signed by its issuer
+func (cd *CustomDomain) certificate() (*unstructured.Unstructured, error) {
+	issuer, err := IssuerFor(cd.Issuer, cd.Challenge)
+	if err != nil {
+		return nil, fmt.Errorf("certificate issuer of %s: %w", cd.Domain, err)
+	}
+	return &unstructured.Unstructured{
 		Object: map[string]any{
 			"apiVersion": "cert-manager.io/v1",
 			"kind":       "Certificate",
 			"metadata": map[string]any{
-				"name":      name,
+				"name":      cd.resourceName(),
 				"namespace": IngressNamespace,
-				"labels": map[string]any{
-					"app":      "custom-domain",
-					"user-uid": cd.UserUID,
-				},
+				"labels":    cd.unstructuredLabels(),
 			},
 			"spec": map[string]any{
-				"secretName": tlsSecretName,
+				"secretName": cd.tlsSecretName(),
 				"dnsNames":   []any{cd.Domain},
 				"issuerRef": map[string]any{
-					"name": "zerossl-issuer",
-					"kind": "ClusterIssuer",
+					"name": issuer.Name,
+					"kind": issuer.Kind,
 				},
 			},
 		},
+	}, nil
+}
+
+// issuerOf returns the "Kind/name" issuerRef of a Certificate
+func issuerOf(cert *unstructured.Unstructured) string {
+	kind, _, _ := unstructured.NestedString(cert.Object, "spec", "issuerRef", "kind")
+	name, _, _ := unstructured.NestedString(cert.Object, "spec", "issuerRef", "name")
+	return kind + "/" + name
+}
+
+// route sends the domain to its Service, which proxies to the https target
+func (cd *CustomDomain) route() Route {
+	return Route{
+		Name:         cd.resourceName(),
+		Host:         cd.Domain,
+		Service:      cd.resourceName(),
+		Port:         443,
+		TLSSecret:    cd.tlsSecretName(),
+		BackendHTTPS: true,
+		Labels:       cd.labels(),
 	}
-	if _, err := DynamicClient.Resource(certificateGVR).Namespace(IngressNamespace).Create(ctx, cert, metav1.CreateOptions{}); err != nil {
-		log.Printf("[customdomain] Failed to create certificate for %s: %v", cd.Domain, err)
-		return fmt.Errorf("create certificate failed: %w", err)
+}
+
+// labels are set on every object created for the domain, tags included
+func (cd *CustomDomain) labels() map[string]string {
+	return WithTagLabels(map[string]string{
+		"app":      "custom-domain",
+		"user-uid": cd.UserUID,
+	}, cd.Tags)
+}
+
+func (cd *CustomDomain) unstructuredLabels() map[string]any {
+	out := map[string]any{}
+	for k, v := range cd.labels() {
+		out[k] = v
 	}
-	log.Printf("[customdomain] Created Certificate with HTTP-01 challenge: %s", cd.Domain)
+	return out
+}
 
-	// Create IngressRoute
-	ingressRoute := &unstructured.Unstructured{
-		Object: map[string]any{
-			"apiVersion": "traefik.io/v1alpha1",
-			"kind":       "IngressRoute",
-			"metadata": map[string]any{
-				"name":      name,
-				"namespace": IngressNamespace,
-				"labels": map[string]any{
-					"app":      "custom-domain",
-					"user-uid": cd.UserUID,
-				},
-			},
-			"spec": map[string]any{
-				"entryPoints": []any{"websecure"},
-				"routes": []any{
-					map[string]any{
-						"match": fmt.Sprintf("Host(`%s`)", cd.Domain),
-						"kind":  "Rule",
-						"services": []any{
-							map[string]any{
-								"name": name,
-								"port": 443,
-							},
-						},
-					},
-				},
-				"tls": map[string]any{
-					"secretName": tlsSecretName,
-				},
-			},
-		},
+// SyncTagLabels rewrites the tag labels on the domain's Service, Certificate
+// and route. Objects that do not exist yet (domain not verified) are skipped.
+func (cd *CustomDomain) SyncTagLabels(ctx context.Context) error {
+	if DynamicClient == nil || K8sClient == nil {
+		return fmt.Errorf("k8s client not initialized")
 	}
+	name := fmt.Sprintf("custom-domain-%s", cd.CDID)
 
-	if _, err := DynamicClient.Resource(IngressRouteGVR).Namespace(IngressNamespace).Create(ctx, ingressRoute, metav1.CreateOptions{}); err != nil {
-		log.Printf("[customdomain] Failed to create IngressRoute for %s: %v", cd.Domain, err)
-		return fmt.Errorf("create ingressroute failed: %w", err)
+	services := K8sClient.CoreV1().Services(IngressNamespace)
+	svc, err := services.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get service failed: %w", err)
+	}
+	svc.Labels = WithTagLabels(svc.Labels, cd.Tags)
+	if _, err := services.Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+		RecordWarning(svc, EventReasonEnsureFailed, "Update tag labels failed: %v", err)
+		return fmt.Errorf("update service labels failed: %w", err)
 	}
 
-	log.Printf("[customdomain] Created IngressRoute for %s with TLS secret %s", cd.Domain, tlsSecretName)
+	for _, gvr := range []schema.GroupVersionResource{certificateGVR, Ingress.GVR()} {
+		client := DynamicClient.Resource(gvr).Namespace(IngressNamespace)
+		obj, err := client.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get %s failed: %w", gvr.Resource, err)
+		}
+		obj.SetLabels(WithTagLabels(obj.GetLabels(), cd.Tags))
+		if _, err := client.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+			RecordWarning(svc, EventReasonEnsureFailed, "Update tag labels on %s failed: %v", gvr.Resource, err)
+			return fmt.Errorf("update %s labels failed: %w", gvr.Resource, err)
+		}
+	}
+	RecordEvent(svc, EventReasonEnsured, "Ensured tag labels on Service, Certificate and %s", Ingress.Kind())
 	return nil
 }
 
+// RecordDomainWarning records a Warning Event on the domain's ExternalName
+// Service, the object `kubectl describe` shows the domain's history on.
+func RecordDomainWarning(ctx context.Context, cdid, reason, format string, args ...any) {
+	if Recorder == nil || K8sClient == nil {
+		return
+	}
+	svc, err := K8sClient.CoreV1().Services(IngressNamespace).Get(ctx, fmt.Sprintf("custom-domain-%s", cdid), metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+	RecordWarning(svc, reason, format, args...)
+}
+
+// DomainCertificateExpiry returns status.notAfter of the domain's
+// Certificate; ok is false while cert-manager has not issued it yet.
+func DomainCertificateExpiry(ctx context.Context, cdid string) (notAfter time.Time, ok bool, err error) {
+	if DynamicClient == nil {
+		return time.Time{}, false, fmt.Errorf("k8s client not initialized")
+	}
+	cert, err := DynamicClient.Resource(certificateGVR).Namespace(IngressNamespace).Get(ctx, fmt.Sprintf("custom-domain-%s", cdid), metav1.GetOptions{})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	s, found, _ := unstructured.NestedString(cert.Object, "status", "notAfter")
+	if !found || s == "" {
+		return time.Time{}, false, nil
+	}
+	notAfter, err = time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("parse notAfter of certificate: %w", err)
+	}
+	return notAfter, true, nil
+}
+
 // GetCustomDomain returns a custom domain by CDID
-func GetCustomDomain(cdid string) (*CustomDomain, error) {
-	cd, err := dblayer.GetCustomDomain(cdid)
+func GetCustomDomain(ctx context.Context, cdid string) (*CustomDomain, error) {
+	cd, err := dblayer.GetCustomDomain(ctx, cdid)
 	if err != nil {
 		return nil, err
 	}
+	return domainFromDB(cd), nil
+}
+
+func domainFromDB(cd *dblayer.CustomDomain) *CustomDomain {
 	return &CustomDomain{
-		ID:        cd.ID,
-		CDID:      cd.CDID,
-		Domain:    cd.Domain,
-		Target:    cd.Target,
-		TXTName:   cd.TXTName,
-		TXTValue:  cd.TXTValue,
-		Status:    DomainStatus(cd.Status),
-		UserUID:   cd.UserUID,
-		CreatedAt: cd.CreatedAt,
-	}, nil
+		ID:          cd.ID,
+		CDID:        cd.CDID,
+		Domain:      cd.Domain,
+		Target:      cd.Target,
+		TXTName:     cd.TXTName,
+		TXTValue:    cd.TXTValue,
+		Status:      DomainStatus(cd.Status),
+		Issuer:      cd.Issuer,
+		Challenge:   cd.Challenge,
+		UserUID:     cd.UserUID,
+		Tags:        cd.Tags,
+		Environment: cd.Environment,
+		CreatedAt:   cd.CreatedAt,
+		UpdatedAt:   cd.UpdatedAt,
+	}
 }
 
-// ListCustomDomains returns all custom domains for a user
-func ListCustomDomains(userUID string) []*CustomDomain {
-	dbDomains, err := dblayer.ListCustomDomains(userUID)
+// ListCustomDomains returns one page of custom domains for a user and the next page cursor
+func ListCustomDomains(ctx context.Context, userUID string, opts dblayer.ListOptions) ([]*CustomDomain, string, error) {
+	dbDomains, next, err := dblayer.ListCustomDomains(ctx, userUID, opts)
 	if err != nil {
-		return nil
+		return nil, "", err
 	}
 
 	var result []*CustomDomain
 	for _, cd := range dbDomains {
-		result = append(result, &CustomDomain{
-			ID:        cd.ID,
-			CDID:      cd.CDID,
-			Domain:    cd.Domain,
-			Target:    cd.Target,
-			TXTName:   cd.TXTName,
-			TXTValue:  cd.TXTValue,
-			Status:    DomainStatus(cd.Status),
-			UserUID:   cd.UserUID,
-			CreatedAt: cd.CreatedAt,
-		})
-	}
-	return result
+		result = append(result, domainFromDB(cd))
+	}
+	return result, next, nil
 }
 
-// DeleteCustomDomain deletes a custom domain, Service and IngressRoute
-func DeleteCustomDomain(cdid string) error {
+// DeleteCustomDomain deletes a custom domain, Service and route
+func DeleteCustomDomain(ctx context.Context, cdid string) error {
 	// Get domain info before deletion for TXT cleanup
-	_, err := GetCustomDomain(cdid)
+	_, err := GetCustomDomain(ctx, cdid)
 
 	// Delete from database
-	if err = dblayer.DeleteCustomDomain(cdid); err != nil {
+	if err = dblayer.DeleteCustomDomain(ctx, cdid); err != nil {
 		return err
 	}
 
-	ctx := context.Background()
 	name := fmt.Sprintf("custom-domain-%s", cdid)
 
 	// Delete Service
@@ -314,12 +600,12 @@ func DeleteCustomDomain(cdid string) error {
 		K8sClient.CoreV1().Services(IngressNamespace).Delete(ctx, name, metav1.DeleteOptions{})
 	}
 
-	// Delete IngressRoute AND Certificate
+	// Delete route AND Certificate
 	if DynamicClient != nil {
-		DynamicClient.Resource(IngressRouteGVR).Namespace(IngressNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+		Routes(DynamicClient).Delete(ctx, name, metav1.DeleteOptions{})
 		DynamicClient.Resource(certificateGVR).Namespace(IngressNamespace).Delete(ctx, name, metav1.DeleteOptions{})
 	}
 
-	log.Printf("[customdomain] Deleted custom domain resources for %s", cdid)
+	domainLog.Info("deleted custom domain resources", "cdid", cdid)
 	return nil
 }
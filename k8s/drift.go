@@ -0,0 +1,159 @@
+package k8s
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DriftKind classifies how a live object differs from the database
+type DriftKind string
+
+const (
+	DriftMissing  DriftKind = "missing"  // expected but not found
+	DriftExtra    DriftKind = "extra"    // found but nothing in the database owns it
+	DriftModified DriftKind = "modified" // found but different from what was created
+)
+
+// Drift sources: the database record an object belongs to
+const (
+	DriftSourceWorker = "worker"
+	DriftSourceDomain = "domain"
+	DriftSourceRDB    = "rdb"
+)
+
+// Drift is one object whose live state differs from the database's desired state
+type Drift struct {
+	Kind      DriftKind `json:"kind"`
+	Source    string    `json:"source"`    // worker, domain or rdb
+	SourceID  string    `json:"source_id"` // worker ID, domain CDID or RDB resource ID
+	OwnerID   string    `json:"owner_id,omitempty"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Resource  string    `json:"resource"` // object kind, e.g. Deployment or Schema
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Detail    string    `json:"detail,omitempty"`
+	Healed    bool      `json:"healed,omitempty"`
+	HealError string    `json:"heal_error,omitempty"`
+}
+
+// DomainDrift compares verified custom domains with the Service, Certificate
+// and route CreateIngressRoute made for them in IngressNamespace.
+// Objects labelled app=custom-domain whose domain no longer exists are extra.
+func DomainDrift(ctx context.Context, domains []*dblayer.CustomDomain) ([]Drift, error) {
+	if DynamicClient == nil || K8sClient == nil {
+		return nil, fmt.Errorf("k8s client not initialized")
+	}
+	opts := metav1.ListOptions{LabelSelector: "app=custom-domain"}
+	services, err := K8sClient.CoreV1().Services(IngressNamespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+	certs, err := DynamicClient.Resource(certificateGVR).Namespace(IngressNamespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list certificates: %w", err)
+	}
+	routes, err := Routes(DynamicClient).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", Ingress.GVR().Resource, err)
+	}
+
+	svcByName := map[string]*corev1.Service{}
+	for i := range services.Items {
+		svcByName[services.Items[i].Name] = &services.Items[i]
+	}
+	certByName := unstructuredByName(certs.Items)
+	routeByName := unstructuredByName(routes.Items)
+
+	var drift []Drift
+	wanted := map[string]bool{}
+	for _, d := range domains {
+		cd := domainFromDB(d)
+		name := cd.resourceName()
+		wanted[name] = true
+		add := func(kind DriftKind, resource, detail string) {
+			drift = append(drift, Drift{
+				Kind: kind, Source: DriftSourceDomain, SourceID: cd.CDID, OwnerID: cd.UserUID,
+				Cluster: LocalCluster, Resource: resource, Namespace: IngressNamespace, Name: name, Detail: detail,
+			})
+		}
+
+		if svc := svcByName[name]; svc == nil {
+			add(DriftMissing, "Service", "")
+		} else if svc.Spec.ExternalName != cd.Target {
+			add(DriftModified, "Service", fmt.Sprintf("externalName is %q, want %q", svc.Spec.ExternalName, cd.Target))
+		}
+		if cert := certByName[name]; cert == nil {
+			add(DriftMissing, "Certificate", "")
+		} else if want, err := IssuerFor(cd.Issuer, cd.Challenge); err == nil && issuerOf(cert) != want.String() {
+			add(DriftModified, "Certificate", fmt.Sprintf("issuer is %s, want %s", issuerOf(cert), want))
+		}
+		if route := routeByName[name]; route == nil {
+			add(DriftMissing, Ingress.Kind(), "")
+		} else if host := Ingress.Host(route); host != cd.Domain {
+			add(DriftModified, Ingress.Kind(), fmt.Sprintf("host is %q, want %q", host, cd.Domain))
+		}
+	}
+
+	// Objects of pending or failed domains are left alone, only those of
+	// deleted domains are extra
+	known := map[string]bool{}
+	extra := func(resource string, obj metav1.Object) {
+		name := obj.GetName()
+		if wanted[name] {
+			return
+		}
+		cdid := strings.TrimPrefix(name, "custom-domain-")
+		exists, seen := known[cdid]
+		if !seen {
+			_, err := dblayer.GetCustomDomain(ctx, cdid)
+			exists = !errors.Is(err, sql.ErrNoRows)
+			known[cdid] = exists
+		}
+		if exists {
+			return
+		}
+		drift = append(drift, Drift{
+			Kind: DriftExtra, Source: DriftSourceDomain, SourceID: cdid, OwnerID: obj.GetLabels()["user-uid"],
+			Cluster: LocalCluster, Resource: resource, Namespace: IngressNamespace, Name: name,
+		})
+	}
+	for i := range services.Items {
+		extra("Service", &services.Items[i])
+	}
+	for i := range certs.Items {
+		extra("Certificate", &certs.Items[i])
+	}
+	for i := range routes.Items {
+		extra(Ingress.Kind(), &routes.Items[i])
+	}
+	return drift, nil
+}
+
+// EnsureDomain re-creates the objects of a verified custom domain
+func EnsureDomain(ctx context.Context, cdid string) error {
+	cd, err := GetCustomDomain(ctx, cdid)
+	if err != nil {
+		return err
+	}
+	if cd.Status != DomainStatusSuccess {
+		return fmt.Errorf("domain %s is %s, not verified", cd.Domain, cd.Status)
+	}
+	return cd.EnsureIngressRoute(ctx)
+}
+
+func unstructuredByName(items []unstructured.Unstructured) map[string]*unstructured.Unstructured {
+	out := make(map[string]*unstructured.Unstructured, len(items))
+	for i := range items {
+		out[items[i].GetName()] = &items[i]
+	}
+	return out
+}
@@ -0,0 +1,156 @@
+package k8s
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Edge limits protect a worker from traffic spikes before requests reach
+// it. With Traefik they are Middlewares next to the route, named after it:
+// <route>-ratelimit counts requests per host, so all clients share the
+// worker's budget, and <route>-buffering answers 413 to larger bodies. The
+// Ingress provider passes the body size on as the ingress-nginx annotation;
+// rate limits need Traefik, and the Gateway provider applies neither. The
+// error page Middlewares (errorpages.go) are kept in step the same way.
+
+const (
+	// MaxEdgeRateLimit caps EdgeLimits.RateLimit and Burst, in requests
+	MaxEdgeRateLimit = 10000
+	// MaxEdgeBodyBytes caps EdgeLimits.MaxBodyBytes
+	MaxEdgeBodyBytes = 1 << 30
+)
+
+var middlewareGVR = schema.GroupVersionResource{
+	Group:    "traefik.io",
+	Version:  "v1alpha1",
+	Resource: "middlewares",
+}
+
+// NormalizeEdgeLimits validates edge limits, 0 meaning unlimited
+func NormalizeEdgeLimits(l dblayer.EdgeLimits) (dblayer.EdgeLimits, error) {
+	out := dblayer.EdgeLimits{RateLimit: l.RateLimit, Burst: l.Burst, MaxBodyBytes: l.MaxBodyBytes}
+	if l.RateLimit < 0 || l.RateLimit > MaxEdgeRateLimit {
+		return out, fmt.Errorf("rate_limit must be between 0 and %d", MaxEdgeRateLimit)
+	}
+	if l.Burst < 0 || l.Burst > MaxEdgeRateLimit {
+		return out, fmt.Errorf("burst must be between 0 and %d", MaxEdgeRateLimit)
+	}
+	if l.Burst > 0 && l.RateLimit == 0 {
+		return out, fmt.Errorf("burst needs a rate_limit")
+	}
+	if l.MaxBodyBytes < 0 || l.MaxBodyBytes > MaxEdgeBodyBytes {
+		return out, fmt.Errorf("max_body_bytes must be between 0 and %d", MaxEdgeBodyBytes)
+	}
+	return out, nil
+}
+
+// edgeMiddleware is one of the Middlewares of a route, nil when it is off
+type edgeMiddleware struct {
+	name string
+	obj  *unstructured.Unstructured
+}
+
+// newMiddleware builds a Middleware of route r with one spec key
+func newMiddleware(r Route, name, key string, spec map[string]any) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "traefik.io/v1alpha1",
+		"kind":       "Middleware",
+		"spec":       map[string]any{key: spec},
+	}}
+	obj.SetName(name)
+	obj.SetNamespace(IngressNamespace)
+	obj.SetLabels(r.Labels)
+	return obj
+}
+
+// edgeMiddlewares lists every Middleware of route r, in the order its
+// routes go through them: the edge limits, then the error pages
+func edgeMiddlewares(r Route) []edgeMiddleware {
+	rate := edgeMiddleware{name: r.Name + "-ratelimit"}
+	if l := r.Limits; l.RateLimit > 0 {
+		burst := l.Burst
+		if burst == 0 {
+			burst = l.RateLimit
+		}
+		rate.obj = newMiddleware(r, rate.name, "rateLimit", map[string]any{
+			"average":         int64(l.RateLimit),
+			"burst":           int64(burst),
+			"period":          "1s",
+			"sourceCriterion": map[string]any{"requestHost": true},
+		})
+	}
+	buffering := edgeMiddleware{name: r.Name + "-buffering"}
+	if l := r.Limits; l.MaxBodyBytes > 0 {
+		buffering.obj = newMiddleware(r, buffering.name, "buffering", map[string]any{
+			"maxRequestBodyBytes": l.MaxBodyBytes,
+		})
+	}
+	return append([]edgeMiddleware{rate, buffering}, errorPageMiddlewares(r)...)
+}
+
+// traefikMiddlewareRefs are the middlewares the routes of r go through
+func traefikMiddlewareRefs(r Route) []any {
+	var refs []any
+	for _, m := range edgeMiddlewares(r) {
+		if m.obj != nil {
+			refs = append(refs, map[string]any{"name": m.name})
+		}
+	}
+	return refs
+}
+
+// ensureEdgeMiddlewares creates or updates the Middlewares route r uses. They
+// have to exist before the route refers to them, or Traefik drops the route.
+func ensureEdgeMiddlewares(ctx context.Context, client dynamic.Interface, r Route) error {
+	res := client.Resource(middlewareGVR).Namespace(IngressNamespace)
+	for _, m := range edgeMiddlewares(r) {
+		if m.obj == nil {
+			continue
+		}
+		existing, err := res.Get(ctx, m.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = res.Create(ctx, m.obj, metav1.CreateOptions{})
+		} else if err == nil {
+			m.obj.SetResourceVersion(existing.GetResourceVersion())
+			_, err = res.Update(ctx, m.obj, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("ensure middleware %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// pruneEdgeMiddlewares deletes the Middlewares route r no longer uses, once
+// the route stopped referring to them
+func pruneEdgeMiddlewares(ctx context.Context, client dynamic.Interface, r Route) error {
+	res := client.Resource(middlewareGVR).Namespace(IngressNamespace)
+	var errs []error
+	for _, m := range edgeMiddlewares(r) {
+		if m.obj != nil {
+			continue
+		}
+		if err := res.Delete(ctx, m.name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete middleware %s: %w", m.name, err))
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// DeleteEdgeMiddlewares deletes the Middlewares of the route named name, for
+// when the route itself goes away
+func DeleteEdgeMiddlewares(ctx context.Context, client dynamic.Interface, name string) error {
+	if Ingress.Name() != IngressProviderTraefik {
+		return nil
+	}
+	return pruneEdgeMiddlewares(ctx, client, Route{Name: name})
+}
@@ -0,0 +1,258 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Workers allow all outbound traffic unless their owner switches them to
+// deny mode, where only DNS, the combinator, the listed CIDRs and, through
+// the egress proxy, the listed domains are reachable. NetworkPolicies are
+// additive, so deny-mode pods carry EgressLabel and the tenant default-egress
+// policy leaves them out. EgressBlockedCIDRs are excluded in every mode.
+
+// EgressLabel marks the pods of deny-mode workers
+const EgressLabel = "console.app238.com/egress"
+
+// EgressAllowlistLabel marks the ConfigMaps holding the domains a worker may
+// reach through the egress proxy. The proxy maps a client pod (label app) to
+// the ConfigMap of the same worker in the pod's namespace.
+const EgressAllowlistLabel = "console.app238.com/egress-allowlist"
+
+// MaxEgressRules caps the CIDRs and the domains of one worker
+const MaxEgressRules = 50
+
+var (
+	// EgressBlockedCIDRs are unreachable from every worker: the cloud metadata
+	// endpoints by default (env EGRESS_BLOCKED_CIDRS, comma separated)
+	EgressBlockedCIDRs = []string{"169.254.169.254/32", "fd00:ec2::254/128"}
+	// EgressProxy is the forward proxy domain rules go through, e.g.
+	// http://egress-proxy.egress.svc.cluster.local:3128 (env EGRESS_PROXY).
+	// Without it only CIDR rules are accepted.
+	EgressProxy string
+	// egressProxyNamespace is where EgressProxy runs, taken from its host
+	egressProxyNamespace string
+)
+
+// EgressEnvs are the environment variables read by SetEgressEnv
+var EgressEnvs = []string{"EGRESS_BLOCKED_CIDRS", "EGRESS_PROXY"}
+
+// SetEgressEnv applies one of EgressEnvs
+func SetEgressEnv(env, value string) error {
+	switch env {
+	case "EGRESS_BLOCKED_CIDRS":
+		var cidrs []string
+		for _, c := range strings.Split(value, ",") {
+			if c = strings.TrimSpace(c); c == "" {
+				continue
+			}
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				return fmt.Errorf("EGRESS_BLOCKED_CIDRS: %w", err)
+			}
+			cidrs = append(cidrs, n.String())
+		}
+		EgressBlockedCIDRs = cidrs
+	case "EGRESS_PROXY":
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme != "http" || u.Port() == "" {
+			return fmt.Errorf("EGRESS_PROXY %q must be http://<service>.<namespace>.svc...:<port>", value)
+		}
+		labels := strings.Split(u.Hostname(), ".")
+		if len(labels) < 3 || labels[2] != "svc" {
+			return fmt.Errorf("EGRESS_PROXY %q must be http://<service>.<namespace>.svc...:<port>", value)
+		}
+		EgressProxy, egressProxyNamespace = value, labels[1]
+	}
+	return nil
+}
+
+var egressDomain = regexp.MustCompile(`^(\*\.)?([a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// NormalizeEgress validates an egress policy and returns it with CIDRs in
+// canonical form and domains lowercased, without duplicates
+func NormalizeEgress(p dblayer.EgressPolicy) (dblayer.EgressPolicy, error) {
+	out := dblayer.EgressPolicy{Mode: p.Mode, CIDRs: []string{}, Domains: []string{}}
+	switch p.Mode {
+	case dblayer.EgressAllow:
+		if len(p.CIDRs) > 0 || len(p.Domains) > 0 {
+			return out, fmt.Errorf("cidrs and domains only apply in %s mode", dblayer.EgressDeny)
+		}
+		return out, nil
+	case dblayer.EgressDeny:
+	default:
+		return out, fmt.Errorf("mode must be %s or %s", dblayer.EgressAllow, dblayer.EgressDeny)
+	}
+	if len(p.CIDRs) > MaxEgressRules || len(p.Domains) > MaxEgressRules {
+		return out, fmt.Errorf("at most %d cidrs and %d domains", MaxEgressRules, MaxEgressRules)
+	}
+	for _, c := range p.CIDRs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(c))
+		if err != nil {
+			return out, fmt.Errorf("invalid cidr %q", c)
+		}
+		for _, b := range EgressBlockedCIDRs {
+			if _, blocked, _ := net.ParseCIDR(b); cidrContains(blocked, n) {
+				return out, fmt.Errorf("cidr %s is blocked by the platform", n)
+			}
+		}
+		if !slices.Contains(out.CIDRs, n.String()) {
+			out.CIDRs = append(out.CIDRs, n.String())
+		}
+	}
+	for _, d := range p.Domains {
+		d = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d), "."))
+		if !egressDomain.MatchString(d) {
+			return out, fmt.Errorf("invalid domain %q", d)
+		}
+		if !slices.Contains(out.Domains, d) {
+			out.Domains = append(out.Domains, d)
+		}
+	}
+	if len(out.Domains) > 0 && EgressProxy == "" {
+		return out, fmt.Errorf("domain rules need an egress proxy, which is not configured")
+	}
+	return out, nil
+}
+
+// cidrContains reports whether inner lies within outer
+func cidrContains(outer, inner *net.IPNet) bool {
+	outerBits, bits := outer.Mask.Size()
+	innerBits, innerLen := inner.Mask.Size()
+	return bits == innerLen && outerBits <= innerBits && outer.Contains(inner.IP)
+}
+
+// exceptBlocked returns except plus the blocked CIDRs inside cidr that except
+// does not cover already, as an ipBlock's except list
+func exceptBlocked(cidr string, except []string) []string {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return except
+	}
+	out := slices.Clone(except)
+	for _, b := range EgressBlockedCIDRs {
+		_, blocked, err := net.ParseCIDR(b)
+		if err != nil || !cidrContains(n, blocked) || blocked.String() == n.String() {
+			continue
+		}
+		covered := slices.ContainsFunc(out, func(e string) bool {
+			_, en, err := net.ParseCIDR(e)
+			return err == nil && cidrContains(en, blocked)
+		})
+		if !covered {
+			out = append(out, blocked.String())
+		}
+	}
+	return out
+}
+
+// EgressRestricted reports whether p needs a worker NetworkPolicy of its
+// own in a tenant namespace, where the default-egress policy covers allow mode
+func EgressRestricted(p dblayer.EgressPolicy) bool {
+	return p.Mode == dblayer.EgressDeny
+}
+
+// EgressProxied reports whether the worker's traffic goes through EgressProxy
+func EgressProxied(p dblayer.EgressPolicy) bool {
+	return EgressRestricted(p) && len(p.Domains) > 0 && EgressProxy != ""
+}
+
+// EgressProxyEnv are the proxy variables set on proxied workers
+func EgressProxyEnv() []corev1.EnvVar {
+	noProxy := ".svc,.cluster.local,localhost,127.0.0.1"
+	return []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: EgressProxy},
+		{Name: "HTTPS_PROXY", Value: EgressProxy},
+		{Name: "NO_PROXY", Value: noProxy},
+		{Name: "http_proxy", Value: EgressProxy},
+		{Name: "https_proxy", Value: EgressProxy},
+		{Name: "no_proxy", Value: noProxy},
+	}
+}
+
+// WorkerEgressPolicy renders the egress NetworkPolicy of the pods matching
+// podLabels. It returns nil when the worker needs none: allow mode in a
+// tenant namespace, whose default-egress policy already applies.
+func WorkerEgressPolicy(name, ns string, labels, podLabels map[string]string, p dblayer.EgressPolicy) *networkingv1.NetworkPolicy {
+	var rules []networkingv1.NetworkPolicyEgressRule
+	switch {
+	case EgressRestricted(p):
+		rules = append(rules, platformEgressRules()...)
+		var peers []networkingv1.NetworkPolicyPeer
+		for _, c := range p.CIDRs {
+			peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: c, Except: exceptBlocked(c, nil)}})
+		}
+		if EgressProxied(p) {
+			peers = append(peers, networkingv1.NetworkPolicyPeer{NamespaceSelector: namespaceSelector(egressProxyNamespace)})
+		}
+		if len(peers) > 0 {
+			rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: peers})
+		}
+	case ns == WorkerNamespace:
+		// The shared namespace has no default policies: everything stays
+		// reachable except the blocked CIDRs
+		rules = []networkingv1.NetworkPolicyEgressRule{{To: []networkingv1.NetworkPolicyPeer{
+			{NamespaceSelector: &metav1.LabelSelector{}},
+			{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0", Except: exceptBlocked("0.0.0.0/0", nil)}},
+			{IPBlock: &networkingv1.IPBlock{CIDR: "::/0", Except: exceptBlocked("::/0", nil)}},
+		}}}
+	default:
+		return nil
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: podLabels},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      rules,
+		},
+	}
+}
+
+// platformEgressRules are open to every worker: DNS in kube-system and the combinator
+func platformEgressRules() []networkingv1.NetworkPolicyEgressRule {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dnsPort := func(p *corev1.Protocol) networkingv1.NetworkPolicyPort {
+		port := intstr.FromInt32(53)
+		return networkingv1.NetworkPolicyPort{Protocol: p, Port: &port}
+	}
+	return []networkingv1.NetworkPolicyEgressRule{
+		{
+			To:    []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector("kube-system")}},
+			Ports: []networkingv1.NetworkPolicyPort{dnsPort(&udp), dnsPort(&tcp)},
+		},
+		{To: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector(CombinatorNamespace)}}},
+	}
+}
+
+func namespaceSelector(name string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": name}}
+}
+
+// EnsureNetworkPolicy creates want, or updates the labels and spec of the existing policy
+func EnsureNetworkPolicy(ctx context.Context, kube kubernetes.Interface, want *networkingv1.NetworkPolicy) error {
+	return ensureNetworkPolicy(ctx, kube, want)
+}
+
+// DeleteNetworkPolicy deletes a NetworkPolicy; a missing one is not an error
+func DeleteNetworkPolicy(ctx context.Context, kube kubernetes.Interface, ns, name string) error {
+	err := kube.NetworkingV1().NetworkPolicies(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A worker's custom 502/503 pages and maintenance page are served by the
+// outer gateway under ErrorPagePath. With Traefik, <route>-errors swaps the
+// worker's 502/503 responses for those pages, and while the worker is in
+// maintenance its routes go to the error page service instead, through
+// <route>-maintenance which rewrites every path to the maintenance page. The
+// Ingress and Gateway providers serve neither.
+
+// MaxErrorPageBytes caps one error page
+const MaxErrorPageBytes = 64 << 10
+
+// ErrorPageService is "namespace/name:port" of the Service serving
+// ErrorPagePath, the outer gateway by default (env ERROR_PAGE_SERVICE).
+// Remote clusters need a Service of that name reaching the outer gateway.
+var ErrorPageService string
+
+// RouteErrorPages are what the error page service has for a route
+type RouteErrorPages struct {
+	Maintenance bool     `json:"maintenance"`
+	Statuses    []string `json:"statuses"` // statuses with a custom page, e.g. 502
+}
+
+// ErrorPagePath is where the error page service serves the page of route
+// name for status, "maintenance" for the maintenance page
+func ErrorPagePath(name, status string) string {
+	return "/error-pages/" + name + "/" + status
+}
+
+// SetErrorPageService sets ErrorPageService after checking its form
+func SetErrorPageService(value string) error {
+	if _, _, _, err := parseErrorPageService(value); err != nil {
+		return err
+	}
+	ErrorPageService = value
+	return nil
+}
+
+func parseErrorPageService(value string) (ns, name string, port int, err error) {
+	ns, rest, ok := strings.Cut(value, "/")
+	name, p, ok2 := strings.Cut(rest, ":")
+	port, perr := strconv.Atoi(p)
+	if !ok || !ok2 || ns == "" || name == "" || perr != nil || port <= 0 || port > 65535 {
+		return "", "", 0, fmt.Errorf("error page service %q is not namespace/name:port", value)
+	}
+	return ns, name, port, nil
+}
+
+// errorPageServiceRef is the Traefik service reference of ErrorPageService
+func errorPageServiceRef() map[string]any {
+	ns, name, port, err := parseErrorPageService(ErrorPageService)
+	if err != nil {
+		ns, name, port = Namespace, "control-plane-outer", 9900
+	}
+	return map[string]any{"namespace": ns, "name": name, "port": int64(port)}
+}
+
+// errorPageMiddlewares lists the error page Middlewares of route r
+func errorPageMiddlewares(r Route) []edgeMiddleware {
+	errs := edgeMiddleware{name: r.Name + "-errors"}
+	if statuses := r.ErrorPages.Statuses; len(statuses) > 0 && !r.ErrorPages.Maintenance {
+		status := make([]any, len(statuses))
+		for i, s := range statuses {
+			status[i] = s
+		}
+		errs.obj = newMiddleware(r, errs.name, "errors", map[string]any{
+			"status":  status,
+			"service": errorPageServiceRef(),
+			"query":   ErrorPagePath(r.Name, "{status}"),
+		})
+	}
+	maintenance := edgeMiddleware{name: r.Name + "-maintenance"}
+	if r.ErrorPages.Maintenance {
+		maintenance.obj = newMiddleware(r, maintenance.name, "replacePath", map[string]any{
+			"path": ErrorPagePath(r.Name, "maintenance"),
+		})
+	}
+	return []edgeMiddleware{errs, maintenance}
+}
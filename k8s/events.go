@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// EventComponent is the source reported on every Event the console records
+const EventComponent = "console-controller"
+
+// Event reasons recorded for controller actions
+const (
+	EventReasonEnsured      = "Ensured"
+	EventReasonEnsureFailed = "EnsureFailed"
+	EventReasonReconciled   = "Reconciled"
+	EventReasonDeleted      = "Deleted"
+	EventReasonDeleteFailed = "DeleteFailed"
+	EventReasonRestarted    = "Restarted"
+	EventReasonVerified     = "Verified"
+	EventReasonVerifyFailed = "VerifyFailed"
+	EventReasonCrashLooping = "CrashLooping"
+	EventReasonRecovered    = "Recovered"
+)
+
+// Recorder writes Kubernetes Events so that `kubectl describe` shows what the
+// console did to an object. It is nil until InitEventRecorder is called
+// (the outer gateway never does), in which case RecordEvent is a no-op.
+var Recorder record.EventRecorder
+
+// InitEventRecorder starts sending recorded Events to the apiserver until
+// ctx is cancelled. Must be called after InitK8s.
+func InitEventRecorder(ctx context.Context) {
+	broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: K8sClient.CoreV1().Events("")})
+	Recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: EventComponent})
+}
+
+// RecordEvent records a Normal Event on obj
+func RecordEvent(obj runtime.Object, reason, format string, args ...any) {
+	if Recorder != nil && obj != nil {
+		Recorder.Eventf(obj, corev1.EventTypeNormal, reason, format, args...)
+	}
+}
+
+// RecordWarning records a Warning Event on obj
+func RecordWarning(obj runtime.Object, reason, format string, args ...any) {
+	if Recorder != nil && obj != nil {
+		Recorder.Eventf(obj, corev1.EventTypeWarning, reason, format, args...)
+	}
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeListKinds are the list kinds of the custom resources this package
+// reads through the dynamic client; the fake client cannot list a resource
+// it does not know the list kind of.
+var fakeListKinds = map[schema.GroupVersionResource]string{
+	IngressRouteGVR: "IngressRouteList",
+	certificateGVR:  "CertificateList",
+	ingressGVR:      "IngressList",
+	httpRouteGVR:    "HTTPRouteList",
+	middlewareGVR:   "MiddlewareList",
+}
+
+// InitFakeK8s installs in-memory clients instead of a cluster (-dev), so the
+// jobs and the controller run on a laptop. Objects are stored but nothing
+// acts on them: Deployments never get pods and Certificates never become
+// ready. extraListKinds adds the list kinds of resources owned by other
+// packages, e.g. the WorkerApp CRD of the controller.
+func InitFakeK8s(extraListKinds map[schema.GroupVersionResource]string) {
+	listKinds := make(map[schema.GroupVersionResource]string, len(fakeListKinds)+len(extraListKinds))
+	for gvr, kind := range fakeListKinds {
+		listKinds[gvr] = kind
+	}
+	for gvr, kind := range extraListKinds {
+		listKinds[gvr] = kind
+	}
+	K8sClient = fake.NewClientset()
+	DynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	RegisterLocalCluster()
+}
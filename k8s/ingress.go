@@ -0,0 +1,347 @@
+package k8s
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Ingress providers, selected with INGRESS_PROVIDER
+const (
+	IngressProviderTraefik = "traefik" // traefik.io IngressRoute (default)
+	IngressProviderIngress = "ingress" // networking.k8s.io/v1 Ingress
+	IngressProviderGateway = "gateway" // Gateway API HTTPRoute
+)
+
+var (
+	// IngressClassName is spec.ingressClassName of Ingress routes, empty for
+	// the cluster's default class (env INGRESS_CLASS)
+	IngressClassName = ""
+	// GatewayRef is the "namespace/name" of the Gateway HTTPRoutes attach to
+	// (env GATEWAY_REF). TLS is terminated by its listeners, so per-route
+	// certificates are not used with the gateway provider.
+	GatewayRef = "console/console-gateway"
+)
+
+var (
+	ingressGVR   = schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}
+	httpRouteGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+)
+
+// Route is a public host routed to a Service in IngressNamespace
+type Route struct {
+	Name         string
+	Host         string
+	Service      string
+	Port         int
+	TLSSecret    string // certificate for Host in IngressNamespace
+	BackendHTTPS bool   // the Service speaks TLS, e.g. an ExternalName to an https target
+	Labels       map[string]string
+	Rules        []dblayer.RouteRule // extra matches routed to Service, see NormalizeRouteRules
+	Limits       dblayer.EdgeLimits  // request rate and body size, see NormalizeEdgeLimits
+	ErrorPages   RouteErrorPages     // custom error pages and maintenance, Traefik only
+}
+
+// IngressProvider builds the objects that route public hosts to Services.
+// Routes are handled as unstructured objects through the dynamic client, so
+// the controller can watch and the drift audit can list whichever is in use.
+type IngressProvider interface {
+	Name() string
+	Kind() string // object kind, e.g. IngressRoute
+	GVR() schema.GroupVersionResource
+	Build(r Route) *unstructured.Unstructured
+	// Host returns the host a route object serves, "" when it has none
+	Host(obj *unstructured.Unstructured) string
+}
+
+// Ingress is the provider every route is created with
+var Ingress IngressProvider = traefikProvider{}
+
+// SetIngressProvider selects the provider by name
+func SetIngressProvider(name string) error {
+	switch name {
+	case IngressProviderTraefik:
+		Ingress = traefikProvider{}
+	case IngressProviderIngress:
+		Ingress = ingressProvider{}
+	case IngressProviderGateway:
+		if _, _, ok := strings.Cut(GatewayRef, "/"); !ok {
+			return fmt.Errorf("gateway ref %q is not namespace/name", GatewayRef)
+		}
+		Ingress = gatewayProvider{}
+	default:
+		return fmt.Errorf("unknown ingress provider %q, want %s, %s or %s",
+			name, IngressProviderTraefik, IngressProviderIngress, IngressProviderGateway)
+	}
+	return nil
+}
+
+// Routes is the client for route objects of the current provider in IngressNamespace
+func Routes(client dynamic.Interface) dynamic.ResourceInterface {
+	return client.Resource(Ingress.GVR()).Namespace(IngressNamespace)
+}
+
+// EnsureRoute creates the route or replaces the existing one with it. With
+// Traefik it also keeps the Middlewares of the route in step.
+func EnsureRoute(ctx context.Context, client dynamic.Interface, r Route) error {
+	traefik := Ingress.Name() == IngressProviderTraefik
+	if traefik {
+		if err := ensureEdgeMiddlewares(ctx, client, r); err != nil {
+			return err
+		}
+	}
+	routes := Routes(client)
+	obj := Ingress.Build(r)
+	existing, err := routes.Get(ctx, r.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = routes.Create(ctx, obj, metav1.CreateOptions{})
+	} else if err == nil {
+		obj.SetResourceVersion(existing.GetResourceVersion())
+		_, err = routes.Update(ctx, obj, metav1.UpdateOptions{})
+	}
+	if err != nil || !traefik {
+		return err
+	}
+	return pruneEdgeMiddlewares(ctx, client, r)
+}
+
+// newRoute starts a route object with metadata filled in
+func newRoute(apiVersion, kind string, r Route) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+	}}
+	obj.SetName(r.Name)
+	obj.SetNamespace(IngressNamespace)
+	obj.SetLabels(r.Labels)
+	return obj
+}
+
+// --- Traefik ---
+
+type traefikProvider struct{}
+
+func (traefikProvider) Name() string                     { return IngressProviderTraefik }
+func (traefikProvider) Kind() string                     { return "IngressRoute" }
+func (traefikProvider) GVR() schema.GroupVersionResource { return IngressRouteGVR }
+
+// Build routes the host on the websecure entry point, then each of r.Rules
+// at a priority above it, all through the route's Middlewares and, during
+// maintenance, to the error page service. Traefik talks TLS to port 443
+// backends by itself, so BackendHTTPS needs nothing extra.
+func (traefikProvider) Build(r Route) *unstructured.Unstructured {
+	obj := newRoute("traefik.io/v1alpha1", "IngressRoute", r)
+	services := []any{
+		map[string]any{
+			"name": r.Service,
+			"port": int64(r.Port),
+		},
+	}
+	if r.ErrorPages.Maintenance {
+		services = []any{errorPageServiceRef()}
+	}
+	routes := []any{
+		map[string]any{
+			"match":    fmt.Sprintf("Host(`%s`)", r.Host),
+			"kind":     "Rule",
+			"services": services,
+		},
+	}
+	for _, rule := range r.Rules {
+		routes = append(routes, map[string]any{
+			"match":    traefikRuleMatch(r, rule),
+			"kind":     "Rule",
+			"priority": int64(routeRulePriority + rule.Priority),
+			"services": services,
+		})
+	}
+	if refs := traefikMiddlewareRefs(r); len(refs) > 0 {
+		for _, route := range routes {
+			route.(map[string]any)["middlewares"] = refs
+		}
+	}
+	obj.Object["spec"] = map[string]any{
+		"entryPoints": []any{"websecure"},
+		"routes":      routes,
+		"tls": map[string]any{
+			"secretName": r.TLSSecret,
+		},
+	}
+	return obj
+}
+
+func (traefikProvider) Host(obj *unstructured.Unstructured) string {
+	routes, _, _ := unstructured.NestedSlice(obj.Object, "spec", "routes")
+	if len(routes) == 0 {
+		return ""
+	}
+	route, _ := routes[0].(map[string]any)
+	match, _ := route["match"].(string)
+	host, ok := strings.CutPrefix(match, "Host(`")
+	if !ok {
+		return ""
+	}
+	host, _, _ = strings.Cut(host, "`")
+	return host
+}
+
+// --- Ingress ---
+
+type ingressProvider struct{}
+
+func (ingressProvider) Name() string                     { return IngressProviderIngress }
+func (ingressProvider) Kind() string                     { return "Ingress" }
+func (ingressProvider) GVR() schema.GroupVersionResource { return ingressGVR }
+
+// Build routes every path of the host, then the hosts and path prefixes of
+// r.Rules. An Ingress can match neither headers nor "*-" hosts, so rules with
+// headers and wildcard hosts are left out rather than widened; nginx orders
+// paths by length, not by priority. BackendHTTPS and the body size limit
+// are passed on as ingress-nginx annotations; other controllers need their own.
+func (ingressProvider) Build(r Route) *unstructured.Unstructured {
+	obj := newRoute("networking.k8s.io/v1", "Ingress", r)
+	annotations := map[string]string{}
+	if r.BackendHTTPS {
+		annotations["nginx.ingress.kubernetes.io/backend-protocol"] = "HTTPS"
+	}
+	if r.Limits.MaxBodyBytes > 0 {
+		annotations["nginx.ingress.kubernetes.io/proxy-body-size"] = strconv.FormatInt(r.Limits.MaxBodyBytes, 10)
+	}
+	if len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
+	}
+	backend := map[string]any{
+		"service": map[string]any{
+			"name": r.Service,
+			"port": map[string]any{"number": int64(r.Port)},
+		},
+	}
+	rule := func(host, path string) map[string]any {
+		return map[string]any{
+			"host": host,
+			"http": map[string]any{
+				"paths": []any{
+					map[string]any{
+						"path":     path,
+						"pathType": "Prefix",
+						"backend":  backend,
+					},
+				},
+			},
+		}
+	}
+	rules := []any{rule(r.Host, "/")}
+	tlsHosts := []any{r.Host}
+	for _, rr := range r.Rules {
+		if len(rr.Headers) > 0 {
+			continue
+		}
+		for _, h := range ruleHosts(r, rr) {
+			if strings.HasPrefix(h, "*") {
+				continue
+			}
+			rules = append(rules, rule(h, cmp.Or(rr.PathPrefix, "/")))
+			if !slices.Contains(tlsHosts, any(h)) {
+				tlsHosts = append(tlsHosts, h)
+			}
+		}
+	}
+	spec := map[string]any{
+		"rules": rules,
+		"tls": []any{
+			map[string]any{
+				"hosts":      tlsHosts,
+				"secretName": r.TLSSecret,
+			},
+		},
+	}
+	if IngressClassName != "" {
+		spec["ingressClassName"] = IngressClassName
+	}
+	obj.Object["spec"] = spec
+	return obj
+}
+
+func (ingressProvider) Host(obj *unstructured.Unstructured) string {
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	if len(rules) == 0 {
+		return ""
+	}
+	rule, _ := rules[0].(map[string]any)
+	host, _ := rule["host"].(string)
+	return host
+}
+
+// --- Gateway API ---
+
+type gatewayProvider struct{}
+
+func (gatewayProvider) Name() string                     { return IngressProviderGateway }
+func (gatewayProvider) Kind() string                     { return "HTTPRoute" }
+func (gatewayProvider) GVR() schema.GroupVersionResource { return httpRouteGVR }
+
+// Build attaches the host to GatewayRef. The hosts of r.Rules join the
+// route's hostnames, as an HTTPRoute cannot match hosts per rule, and their
+// path prefixes and headers become matches; "*-" hosts are left out and the
+// Gateway orders matches by specificity, not by priority. TLSSecret and
+// BackendHTTPS are left to the Gateway's listeners and a BackendTLSPolicy
+// respectively.
+func (gatewayProvider) Build(r Route) *unstructured.Unstructured {
+	ns, name, _ := strings.Cut(GatewayRef, "/")
+	obj := newRoute("gateway.networking.k8s.io/v1", "HTTPRoute", r)
+	backendRefs := []any{
+		map[string]any{
+			"name": r.Service,
+			"port": int64(r.Port),
+		},
+	}
+	rules := []any{map[string]any{"backendRefs": backendRefs}}
+	for _, rr := range r.Rules {
+		if rr.PathPrefix == "" && len(rr.Headers) == 0 {
+			continue
+		}
+		match := map[string]any{}
+		if rr.PathPrefix != "" {
+			match["path"] = map[string]any{"type": "PathPrefix", "value": rr.PathPrefix}
+		}
+		if len(rr.Headers) > 0 {
+			var headers []any
+			for _, k := range headerNames(rr) {
+				headers = append(headers, map[string]any{"type": "Exact", "name": k, "value": rr.Headers[k]})
+			}
+			match["headers"] = headers
+		}
+		rules = append(rules, map[string]any{"matches": []any{match}, "backendRefs": backendRefs})
+	}
+	var hostnames []any
+	for _, h := range exactRuleHosts(r) {
+		hostnames = append(hostnames, h)
+	}
+	obj.Object["spec"] = map[string]any{
+		"parentRefs": []any{
+			map[string]any{"namespace": ns, "name": name},
+		},
+		"hostnames": hostnames,
+		"rules":     rules,
+	}
+	return obj
+}
+
+func (gatewayProvider) Host(obj *unstructured.Unstructured) string {
+	hosts, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "hostnames")
+	if len(hosts) == 0 {
+		return ""
+	}
+	return hosts[0]
+}
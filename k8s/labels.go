@@ -0,0 +1,22 @@
+package k8s
+
+import "strings"
+
+// TagLabelPrefix prefixes user resource tags when they are copied to K8s labels
+const TagLabelPrefix = "tag.console.app238.com/"
+
+// WithTagLabels returns a copy of labels with every tag added as a
+// TagLabelPrefix label. Tags already present under the prefix are replaced,
+// so the result reflects exactly the given tags.
+func WithTagLabels(labels map[string]string, tags map[string]string) map[string]string {
+	out := make(map[string]string, len(labels)+len(tags))
+	for k, v := range labels {
+		if !strings.HasPrefix(k, TagLabelPrefix) {
+			out[k] = v
+		}
+	}
+	for k, v := range tags {
+		out[TagLabelPrefix+k] = v
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Worker pod logs are shipped to Loki by the log agent (scripts/log-agent.yaml)
+// with the pod's owner-id label as the Loki tenant (X-Scope-OrgID), so every
+// query is confined to one user's streams even if the selector were wrong.
+
+var lokiClient = &http.Client{Timeout: 15 * time.Second}
+
+// WorkerLogEntry is one retained log line of a worker pod
+type WorkerLogEntry struct {
+	Time time.Time `json:"time"`
+	Pod  string    `json:"pod"`
+	Line string    `json:"line"`
+}
+
+// WorkerLogQuery selects retained log lines of one worker
+type WorkerLogQuery struct {
+	OwnerID  string
+	WorkerID string
+	Since    time.Time
+	Until    time.Time
+	Contains string // optional case-sensitive substring filter
+	Limit    int
+}
+
+// LogQL builds the stream selector and line filter for q. Both the shared
+// and the owner's tenant namespace match, so history survives a migration.
+func (q WorkerLogQuery) LogQL() string {
+	namespaces := WorkerNamespace + "|" + regexp.QuoteMeta(TenantNamespacePrefix+q.OwnerID)
+	sel := fmt.Sprintf(`{namespace=~%s, owner_id=%s, worker_id=%s}`,
+		strconv.Quote(namespaces), strconv.Quote(q.OwnerID), strconv.Quote(q.WorkerID))
+	if q.Contains != "" {
+		sel += " |= " + strconv.Quote(q.Contains)
+	}
+	return sel
+}
+
+// QueryWorkerLogs returns the newest matching lines first, at most q.Limit
+func QueryWorkerLogs(ctx context.Context, q WorkerLogQuery) ([]WorkerLogEntry, error) {
+	params := url.Values{
+		"query":     {q.LogQL()},
+		"start":     {strconv.FormatInt(q.Since.UnixNano(), 10)},
+		"end":       {strconv.FormatInt(q.Until.UnixNano(), 10)},
+		"limit":     {strconv.Itoa(q.Limit)},
+		"direction": {"backward"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimRight(LokiEndpoint, "/")+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Scope-OrgID", q.OwnerID)
+
+	resp, err := lokiClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query log store: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("log store returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var out struct {
+		Data struct {
+			Result []struct {
+				Stream map[string]string `json:"stream"`
+				Values [][2]string       `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode log store response: %w", err)
+	}
+
+	var entries []WorkerLogEntry
+	for _, stream := range out.Data.Result {
+		for _, v := range stream.Values {
+			ns, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, WorkerLogEntry{Time: time.Unix(0, ns).UTC(), Pod: stream.Stream["pod"], Line: v[1]})
+		}
+	}
+	// results are per stream; merge them newest first
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, nil
+}
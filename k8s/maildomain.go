@@ -0,0 +1,157 @@
+package k8s
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+)
+
+// Outbound mail of user applications goes through a platform SMTP relay
+// (env MAIL_RELAY_*). The relay runs outside the console: before accepting a
+// message it asks inner /api/v1/mail/authorize with the SMTP credentials and
+// the envelope, signs with the DKIM key it gets back, and reports delivery
+// results to /api/v1/mail/events. This file has the relay settings shown to
+// users and the DNS records a mail domain needs, checked the same way as the
+// TXT record of a custom domain.
+
+// MailRelay is nil until MAIL_RELAY_HOST is set
+var MailRelay *MailRelayConfig
+
+// MailRelayConfig is where users point their SMTP clients
+type MailRelayConfig struct {
+	Host         string // SMTP submission host
+	Port         int    // submission port, STARTTLS; 587 by default
+	SPFInclude   string // the SPF record of a mail domain must include it; Host by default
+	DKIMSelector string // selector of the DKIM keys of new mail domains; "console" by default
+}
+
+// MailRelayEnvs are the environment variables read by SetMailRelayEnv
+var MailRelayEnvs = []string{"MAIL_RELAY_HOST", "MAIL_RELAY_PORT", "MAIL_RELAY_SPF_INCLUDE", "MAIL_RELAY_DKIM_SELECTOR"}
+
+// mailRelayConfig collects MailRelayEnvs in any order
+var mailRelayConfig = MailRelayConfig{Port: 587, DKIMSelector: "console"}
+
+// SetMailRelayEnv applies one of MailRelayEnvs; MailRelay is set with the host
+func SetMailRelayEnv(env, value string) error {
+	cfg := &mailRelayConfig
+	switch env {
+	case "MAIL_RELAY_HOST":
+		cfg.Host = strings.ToLower(strings.TrimSuffix(value, "."))
+		MailRelay = cfg
+	case "MAIL_RELAY_PORT":
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("MAIL_RELAY_PORT %q is not a port", value)
+		}
+		cfg.Port = port
+	case "MAIL_RELAY_SPF_INCLUDE":
+		cfg.SPFInclude = strings.ToLower(strings.TrimSuffix(value, "."))
+	case "MAIL_RELAY_DKIM_SELECTOR":
+		for _, r := range value {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("MAIL_RELAY_DKIM_SELECTOR %q must be lowercase letters, digits and -", value)
+			}
+		}
+		cfg.DKIMSelector = value
+	}
+	return nil
+}
+
+// spfInclude is the domain mail domains include in their SPF record
+func (r *MailRelayConfig) spfInclude() string {
+	if r.SPFInclude != "" {
+		return r.SPFInclude
+	}
+	return r.Host
+}
+
+// Mail DNS record purposes
+const (
+	MailRecordOwnership = "ownership"
+	MailRecordSPF       = "spf"
+	MailRecordDKIM      = "dkim"
+)
+
+// MailDNSRecord is a DNS record the owner of a mail domain has to publish
+type MailDNSRecord struct {
+	Purpose  string `json:"purpose"` // ownership, spf or dkim
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Verified bool   `json:"verified"`
+}
+
+// NewDKIMKey generates the DKIM key of a mail domain: the private key as
+// PEM, and the public key as base64 DER, the p= of the DKIM record
+func NewDKIMKey() (privatePEM, publicKey string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	priv := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return string(priv), base64.StdEncoding.EncodeToString(der), nil
+}
+
+// dkimName is where the DKIM record of d is published
+func dkimName(d *dblayer.MailDomain) string {
+	return d.DKIMSelector + "._domainkey." + d.Domain
+}
+
+// MailDomainRecords lists the records d needs, with the result of its last check
+func (r *MailRelayConfig) MailDomainRecords(d *dblayer.MailDomain) []MailDNSRecord {
+	return []MailDNSRecord{
+		{Purpose: MailRecordOwnership, Type: "TXT", Name: d.TXTName, Value: d.TXTValue, Verified: d.OwnershipVerified},
+		{Purpose: MailRecordSPF, Type: "TXT", Name: d.Domain, Value: "v=spf1 include:" + r.spfInclude() + " ~all", Verified: d.SPFVerified},
+		{Purpose: MailRecordDKIM, Type: "TXT", Name: dkimName(d), Value: "v=DKIM1; k=rsa; p=" + d.DKIMPublicKey, Verified: d.DKIMVerified},
+	}
+}
+
+// CheckMailDomain looks the records of d up and updates its flags, status
+// and msg. A domain is verified once all three records are in place; a
+// verified domain that loses one turns to error, and back once it is fixed.
+// An SPF record that has other mechanisms besides the include passes.
+func (r *MailRelayConfig) CheckMailDomain(d *dblayer.MailDomain) {
+	var missing []string
+	check := func(name string, match func(string) bool) bool {
+		ok, err := HasTXTRecord(name, match)
+		if !ok {
+			msg := "TXT record missing at " + name
+			if err != nil {
+				msg = fmt.Sprintf("TXT lookup of %s failed: %v", name, err)
+			}
+			missing = append(missing, msg)
+		}
+		return ok
+	}
+
+	d.OwnershipVerified = check(d.TXTName, func(v string) bool { return v == d.TXTValue })
+	include := "include:" + r.spfInclude()
+	d.SPFVerified = check(d.Domain, func(v string) bool {
+		fields := strings.Fields(strings.ToLower(v))
+		return len(fields) > 0 && fields[0] == "v=spf1" && slices.Contains(fields, include)
+	})
+	d.DKIMVerified = check(dkimName(d), func(v string) bool {
+		return strings.Contains(strings.ReplaceAll(v, " ", ""), "p="+d.DKIMPublicKey)
+	})
+
+	switch {
+	case len(missing) == 0:
+		d.Status, d.Msg = dblayer.MailDomainVerified, ""
+	case d.Status == dblayer.MailDomainVerified || d.Status == dblayer.MailDomainError:
+		d.Status, d.Msg = dblayer.MailDomainError, strings.Join(missing, "; ")
+	default:
+		d.Status, d.Msg = dblayer.MailDomainPending, strings.Join(missing, "; ")
+	}
+}
@@ -0,0 +1,169 @@
+package k8s
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// Traffic inside the cluster is mutually authenticated with certificates of
+// an internal CA, a cert-manager ClusterIssuer (scripts/internal-ca.yaml).
+// The inner gateway serves over TLS and only accepts clients that present a
+// certificate of the CA; the outer gateway, the combinator and every worker
+// get one. Who a client is comes from the URI SAN of its certificate:
+//
+//	spiffe://console/component/<name>          a control plane component
+//	spiffe://console/owner/<uid>/worker/<wid>  a worker of the owner uid
+
+// Control plane components that hold a client certificate
+const (
+	ComponentOuter      = "outer"
+	ComponentCombinator = "combinator"
+	ComponentAdmin      = "admin"      // operators calling the inner admin routes
+	ComponentLogAgent   = "log-agent"  // ships the ingress access logs to inner
+	ComponentMailRelay  = "mail-relay" // the SMTP relay, authorizes messages and reports deliveries
+)
+
+// identityTrustDomain is the host of every identity URI
+const identityTrustDomain = "console"
+
+// MTLSMountPath is where a worker's client certificate is mounted: tls.crt,
+// tls.key and ca.crt, the internal CA the combinator is verified with
+const MTLSMountPath = "/var/run/console/mtls"
+
+// InternalIssuer signs the client certificates of workers. Nil leaves
+// workers without a certificate and COMBINATOR_API_ENDPOINT on plain HTTP
+// (env INTERNAL_CA_ISSUER).
+var InternalIssuer *IssuerRef
+
+// SetInternalIssuer applies INTERNAL_CA_ISSUER. It has to be a
+// ClusterIssuer, since workers live in one namespace per tenant.
+func SetInternalIssuer(value string) error {
+	ref, err := ParseIssuerRef(value)
+	if err != nil {
+		return fmt.Errorf("INTERNAL_CA_ISSUER: %w", err)
+	}
+	if ref.Kind != "ClusterIssuer" {
+		return fmt.Errorf("INTERNAL_CA_ISSUER %s must be a ClusterIssuer", ref)
+	}
+	InternalIssuer = &ref
+	return nil
+}
+
+// Identity is who a client certificate was issued to: a Component, or the
+// Worker of an Owner
+type Identity struct {
+	Component string
+	Owner     string
+	Worker    string
+}
+
+func (id Identity) String() string {
+	if id.Component != "" {
+		return ComponentIdentity(id.Component)
+	}
+	return WorkerIdentity(id.Owner, id.Worker)
+}
+
+// ComponentIdentity is the URI SAN of a control plane component
+func ComponentIdentity(name string) string {
+	return fmt.Sprintf("spiffe://%s/component/%s", identityTrustDomain, name)
+}
+
+// WorkerIdentity is the URI SAN of a worker
+func WorkerIdentity(ownerUID, workerID string) string {
+	return fmt.Sprintf("spiffe://%s/owner/%s/worker/%s", identityTrustDomain, ownerUID, workerID)
+}
+
+// ParseIdentity parses a URI written by ComponentIdentity or WorkerIdentity
+func ParseIdentity(u *url.URL) (Identity, bool) {
+	if u.Scheme != "spiffe" || u.Host != identityTrustDomain {
+		return Identity{}, false
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "component" && parts[1] != "":
+		return Identity{Component: parts[1]}, true
+	case len(parts) == 4 && parts[0] == "owner" && parts[1] != "" && parts[2] == "worker" && parts[3] != "":
+		return Identity{Owner: parts[1], Worker: parts[3]}, true
+	}
+	return Identity{}, false
+}
+
+// IdentityOf returns the identity in the URI SANs of a verified certificate
+func IdentityOf(cert *x509.Certificate) (Identity, bool) {
+	for _, u := range cert.URIs {
+		if id, ok := ParseIdentity(u); ok {
+			return id, true
+		}
+	}
+	return Identity{}, false
+}
+
+// ClientCertificate is a cert-manager Certificate for a client identity,
+// signed by InternalIssuer into the Secret of the same name
+func ClientCertificate(name, namespace string, labels map[string]string, identity string) *unstructured.Unstructured {
+	l := map[string]any{}
+	for k, v := range labels {
+		l[k] = v
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]any{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata": map[string]any{
+				"name":      name,
+				"namespace": namespace,
+				"labels":    l,
+			},
+			"spec": map[string]any{
+				"secretName": name,
+				"uris":       []any{identity},
+				"usages":     []any{"client auth", "digital signature", "key encipherment"},
+				"privateKey": map[string]any{"rotationPolicy": "Always"},
+				"issuerRef": map[string]any{
+					"name": InternalIssuer.Name,
+					"kind": InternalIssuer.Kind,
+				},
+			},
+		},
+	}
+}
+
+// EnsureCertificate creates cert, or updates the spec of the existing one
+func EnsureCertificate(ctx context.Context, dyn dynamic.Interface, cert *unstructured.Unstructured) error {
+	client := dyn.Resource(certificateGVR).Namespace(cert.GetNamespace())
+	existing, err := client.Get(ctx, cert.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, cert, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Object["spec"] = cert.Object["spec"]
+	existing.SetLabels(cert.GetLabels())
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// DeleteCertificate deletes a Certificate and the Secret cert-manager wrote
+// for it, which cert-manager leaves behind. Missing objects are not an error.
+func DeleteCertificate(ctx context.Context, c *Cluster, namespace, name string) error {
+	err := c.Dynamic.Resource(certificateGVR).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	err = c.Kube.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
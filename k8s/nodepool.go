@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Node pools are groups of nodes labelled PoolLabel=<name>. A worker that
+// selects a pool gets a nodeSelector on that label and tolerates the taint
+// PoolLabel=<name>:NoSchedule, so pools of expensive nodes can be kept free of
+// other workloads. Workers that select no pool run on any untainted node.
+const (
+	PoolLabel         = "console.app238.com/pool"
+	PoolPriceLabel    = "console.app238.com/price-per-hour" // per node, e.g. "0.12"
+	ArchLabel         = "kubernetes.io/arch"
+	InstanceTypeLabel = "node.kubernetes.io/instance-type"
+)
+
+// NodePool is a pool of nodes in one cluster, as discovered from node labels
+type NodePool struct {
+	Cluster       string   `json:"cluster"`
+	Name          string   `json:"name"`
+	Archs         []string `json:"archs"`
+	InstanceTypes []string `json:"instance_types"`
+	Nodes         int      `json:"nodes"`
+	ReadyNodes    int      `json:"ready_nodes"`
+	CPU           string   `json:"cpu"`    // allocatable, summed over ready nodes
+	Memory        string   `json:"memory"` // allocatable, summed over ready nodes
+	PricePerHour  string   `json:"price_per_hour,omitempty"`
+}
+
+// ListNodePools lists the node pools of every registered cluster. A cluster
+// whose nodes cannot be listed is skipped so the others are still returned.
+func ListNodePools(ctx context.Context) ([]NodePool, error) {
+	clusters := Clusters.List()
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no cluster registered")
+	}
+	var pools []NodePool
+	for _, c := range clusters {
+		nodes, err := c.Kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: PoolLabel})
+		if err != nil {
+			clusterLog.Warn("list nodes failed", "cluster", c.Name, "error", err)
+			continue
+		}
+		pools = append(pools, nodePools(c.Name, nodes.Items)...)
+	}
+	return pools, nil
+}
+
+// nodePools groups nodes by PoolLabel
+func nodePools(cluster string, nodes []corev1.Node) []NodePool {
+	type totals struct {
+		pool        NodePool
+		cpu, memory resource.Quantity
+	}
+	byName := map[string]*totals{}
+	for i := range nodes {
+		n := &nodes[i]
+		name := n.Labels[PoolLabel]
+		if name == "" {
+			continue
+		}
+		t := byName[name]
+		if t == nil {
+			t = &totals{pool: NodePool{Cluster: cluster, Name: name, Archs: []string{}, InstanceTypes: []string{}}}
+			byName[name] = t
+		}
+		p := &t.pool
+		p.Nodes++
+		if arch := n.Labels[ArchLabel]; arch != "" && !slices.Contains(p.Archs, arch) {
+			p.Archs = append(p.Archs, arch)
+		}
+		if it := n.Labels[InstanceTypeLabel]; it != "" && !slices.Contains(p.InstanceTypes, it) {
+			p.InstanceTypes = append(p.InstanceTypes, it)
+		}
+		if price := n.Labels[PoolPriceLabel]; price != "" && p.PricePerHour == "" {
+			p.PricePerHour = price
+		}
+		if !nodeReady(n) || n.Spec.Unschedulable {
+			continue
+		}
+		p.ReadyNodes++
+		t.cpu.Add(n.Status.Allocatable[corev1.ResourceCPU])
+		t.memory.Add(n.Status.Allocatable[corev1.ResourceMemory])
+	}
+
+	pools := make([]NodePool, 0, len(byName))
+	for _, t := range byName {
+		t.pool.CPU = t.cpu.String()
+		t.pool.Memory = t.memory.String()
+		sort.Strings(t.pool.Archs)
+		sort.Strings(t.pool.InstanceTypes)
+		pools = append(pools, t.pool)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools
+}
+
+func nodeReady(n *corev1.Node) bool {
+	for _, cond := range n.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PoolScheduling returns the nodeSelector and tolerations that place pods on
+// pool (any pool when empty) and arch (any arch when empty)
+func PoolScheduling(pool, arch string) (map[string]string, []corev1.Toleration) {
+	selector := map[string]string{}
+	var tolerations []corev1.Toleration
+	if pool != "" {
+		selector[PoolLabel] = pool
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:      PoolLabel,
+			Operator: corev1.TolerationOpEqual,
+			Value:    pool,
+			Effect:   corev1.TaintEffectNoSchedule,
+		})
+	}
+	if arch != "" {
+		selector[ArchLabel] = arch
+	}
+	if len(selector) == 0 {
+		selector = nil
+	}
+	return selector, tolerations
+}
@@ -0,0 +1,477 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"jabberwocky238/console/dblayer"
+)
+
+// Object storage buckets live on an S3-compatible store (MinIO, or a cloud
+// provider's S3) that the inner gateway administers with one set of keys
+// (env OBJECT_STORE_*). Workers never get those: each bucket has temporary
+// credentials from the store's STS AssumeRole, with a session policy that
+// only allows object access to that bucket, rotated before they expire.
+
+// ObjectStore is nil until OBJECT_STORE_ENDPOINT and the admin keys are set
+var ObjectStore *ObjectStoreClient
+
+// ObjectStoreClient talks to the store with path-style requests signed with
+// AWS Signature Version 4
+type ObjectStoreClient struct {
+	Endpoint       string // e.g. http://minio.storage.svc:9000
+	PublicEndpoint string // injected into workers, defaults to Endpoint
+	Region         string // signing region, also the location of new buckets
+	AccessKey      string // admin keys, allowed to create buckets and assume roles
+	SecretKey      string
+	RoleARN        string        // role assumed for bucket credentials; required by AWS STS, ignored by MinIO
+	BucketPrefix   string        // bucket names are the prefix plus the resource ID
+	KeyTTL         time.Duration // lifetime of bucket credentials
+
+	http *http.Client
+}
+
+// ObjectStoreEnvs are the environment variables read by SetObjectStoreEnv
+var ObjectStoreEnvs = []string{
+	"OBJECT_STORE_ENDPOINT", "OBJECT_STORE_PUBLIC_ENDPOINT", "OBJECT_STORE_REGION",
+	"OBJECT_STORE_ACCESS_KEY", "OBJECT_STORE_SECRET_KEY", "OBJECT_STORE_ROLE_ARN",
+	"OBJECT_STORE_BUCKET_PREFIX", "OBJECT_STORE_KEY_TTL",
+}
+
+// objectStoreConfig collects ObjectStoreEnvs in any order
+var objectStoreConfig = ObjectStoreClient{
+	Region:       "us-east-1",
+	BucketPrefix: "console-",
+	KeyTTL:       7 * 24 * time.Hour,
+}
+
+// SetObjectStoreEnv applies one of ObjectStoreEnvs; ObjectStore is set once
+// the endpoint and both admin keys are known
+func SetObjectStoreEnv(env, value string) error {
+	cfg := &objectStoreConfig
+	switch env {
+	case "OBJECT_STORE_ENDPOINT", "OBJECT_STORE_PUBLIC_ENDPOINT":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return fmt.Errorf("%s %q must be http(s)://host[:port]", env, value)
+		}
+		value = u.Scheme + "://" + u.Host
+		if env == "OBJECT_STORE_ENDPOINT" {
+			cfg.Endpoint = value
+		} else {
+			cfg.PublicEndpoint = value
+		}
+	case "OBJECT_STORE_REGION":
+		cfg.Region = value
+	case "OBJECT_STORE_ACCESS_KEY":
+		cfg.AccessKey = value
+	case "OBJECT_STORE_SECRET_KEY":
+		cfg.SecretKey = value
+	case "OBJECT_STORE_ROLE_ARN":
+		cfg.RoleARN = value
+	case "OBJECT_STORE_BUCKET_PREFIX":
+		if !validBucketName(value + "0000000000000000") {
+			return fmt.Errorf("OBJECT_STORE_BUCKET_PREFIX %q must be lowercase letters, digits and hyphens", value)
+		}
+		cfg.BucketPrefix = value
+	case "OBJECT_STORE_KEY_TTL":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 15*time.Minute {
+			return fmt.Errorf("OBJECT_STORE_KEY_TTL %q must be a duration of at least 15m", value)
+		}
+		cfg.KeyTTL = d
+	}
+	if cfg.Endpoint != "" && cfg.AccessKey != "" && cfg.SecretKey != "" {
+		c := *cfg
+		if c.PublicEndpoint == "" {
+			c.PublicEndpoint = c.Endpoint
+		}
+		c.http = &http.Client{Timeout: 30 * time.Second}
+		ObjectStore = &c
+	}
+	return nil
+}
+
+// validBucketName reports whether name is a valid S3 bucket name without dots
+func validBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 || name[0] == '-' || name[len(name)-1] == '-' {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// BucketName is the name of the bucket of a resource
+func (s *ObjectStoreClient) BucketName(resourceID string) string {
+	return s.BucketPrefix + strings.ToLower(resourceID)
+}
+
+// BucketURL is the path-style URL of a bucket as workers reach it
+func (s *ObjectStoreClient) BucketURL(bucket string) string {
+	return s.PublicEndpoint + "/" + bucket
+}
+
+// ObjectStoreError is an error response of the store
+type ObjectStoreError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *ObjectStoreError) Error() string {
+	return fmt.Sprintf("object store: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// isStoreError reports whether err is an error response with one of codes
+func isStoreError(err error, codes ...string) bool {
+	e, ok := err.(*ObjectStoreError)
+	return ok && slices.Contains(codes, e.Code)
+}
+
+// CreateBucket creates the bucket, succeeding if it already exists
+func (s *ObjectStoreClient) CreateBucket(ctx context.Context, bucket string) error {
+	var body []byte
+	if s.Region != "us-east-1" {
+		body, _ = xml.Marshal(struct {
+			XMLName            xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CreateBucketConfiguration"`
+			LocationConstraint string
+		}{LocationConstraint: s.Region})
+	}
+	_, err := s.do(ctx, "PUT", "s3", "/"+bucket, nil, body, nil)
+	if isStoreError(err, "BucketAlreadyOwnedByYou") {
+		return nil
+	}
+	return err
+}
+
+// DeleteBucket deletes every object of the bucket, then the bucket itself.
+// A missing bucket is not an error.
+func (s *ObjectStoreClient) DeleteBucket(ctx context.Context, bucket string) error {
+	for {
+		page, err := s.listObjects(ctx, bucket, "")
+		if isStoreError(err, "NoSuchBucket") {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(page.Contents) == 0 {
+			break
+		}
+		if err := s.deleteObjects(ctx, bucket, page.Contents); err != nil {
+			return err
+		}
+	}
+	_, err := s.do(ctx, "DELETE", "s3", "/"+bucket, nil, nil, nil)
+	if isStoreError(err, "NoSuchBucket") {
+		return nil
+	}
+	return err
+}
+
+// BucketUsage lists the bucket and returns its total size and object count
+func (s *ObjectStoreClient) BucketUsage(ctx context.Context, bucket string) (size, objects int64, err error) {
+	token := ""
+	for {
+		page, err := s.listObjects(ctx, bucket, token)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, o := range page.Contents {
+			size += o.Size
+		}
+		objects += int64(len(page.Contents))
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return size, objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+type s3Object struct {
+	Key  string
+	Size int64
+}
+
+type listObjectsResult struct {
+	Contents              []s3Object
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *ObjectStoreClient) listObjects(ctx context.Context, bucket, token string) (*listObjectsResult, error) {
+	q := url.Values{"list-type": {"2"}, "max-keys": {"1000"}}
+	if token != "" {
+		q.Set("continuation-token", token)
+	}
+	data, err := s.do(ctx, "GET", "s3", "/"+bucket, q, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res listObjectsResult
+	if err := xml.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("object store: decode list: %w", err)
+	}
+	return &res, nil
+}
+
+// deleteObjects deletes up to 1000 objects in one request
+func (s *ObjectStoreClient) deleteObjects(ctx context.Context, bucket string, objects []s3Object) error {
+	type key struct{ Key string }
+	req := struct {
+		XMLName xml.Name `xml:"Delete"`
+		Quiet   bool
+		Object  []key
+	}{Quiet: true}
+	for _, o := range objects {
+		req.Object = append(req.Object, key{o.Key})
+	}
+	body, _ := xml.Marshal(req)
+	data, err := s.do(ctx, "POST", "s3", "/"+bucket, url.Values{"delete": {""}}, body, contentMD5(body))
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Error []struct{ Key, Code, Message string }
+	}
+	if err := xml.Unmarshal(data, &res); err == nil && len(res.Error) > 0 {
+		e := res.Error[0]
+		return &ObjectStoreError{Status: 200, Code: e.Code, Message: e.Key + ": " + e.Message}
+	}
+	return nil
+}
+
+type lifecycleRule struct {
+	ID     string
+	Filter struct {
+		Prefix string
+	}
+	Status     string
+	Expiration *struct {
+		Days int
+	} `xml:",omitempty"`
+	AbortIncompleteMultipartUpload *struct {
+		DaysAfterInitiation int
+	} `xml:",omitempty"`
+}
+
+// PutLifecycle replaces the lifecycle configuration of the bucket, removing
+// it when rules is empty
+func (s *ObjectStoreClient) PutLifecycle(ctx context.Context, bucket string, rules []dblayer.BucketLifecycleRule) error {
+	q := url.Values{"lifecycle": {""}}
+	if len(rules) == 0 {
+		_, err := s.do(ctx, "DELETE", "s3", "/"+bucket, q, nil, nil)
+		if isStoreError(err, "NoSuchLifecycleConfiguration") {
+			return nil
+		}
+		return err
+	}
+	cfg := struct {
+		XMLName xml.Name `xml:"LifecycleConfiguration"`
+		Rule    []lifecycleRule
+	}{}
+	for _, r := range rules {
+		lr := lifecycleRule{ID: r.ID, Status: "Enabled"}
+		lr.Filter.Prefix = r.Prefix
+		if r.ExpirationDays > 0 {
+			lr.Expiration = &struct{ Days int }{r.ExpirationDays}
+		}
+		if r.AbortIncompleteUploadDays > 0 {
+			lr.AbortIncompleteMultipartUpload = &struct{ DaysAfterInitiation int }{r.AbortIncompleteUploadDays}
+		}
+		cfg.Rule = append(cfg.Rule, lr)
+	}
+	body, _ := xml.Marshal(cfg)
+	_, err := s.do(ctx, "PUT", "s3", "/"+bucket, q, body, contentMD5(body))
+	return err
+}
+
+// BucketCredentials are temporary keys limited to one bucket
+type BucketCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+// IssueBucketCredentials assumes a role for KeyTTL with a session policy
+// that only allows listing the bucket and reading and writing its objects
+func (s *ObjectStoreClient) IssueBucketCredentials(ctx context.Context, bucket string) (*BucketCredentials, error) {
+	arn := "arn:aws:s3:::" + bucket
+	policy, _ := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:ListBucket", "s3:GetBucketLocation", "s3:ListBucketMultipartUploads"},
+				"Resource": []string{arn},
+			},
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+				"Resource": []string{arn + "/*"},
+			},
+		},
+	})
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"DurationSeconds": {strconv.Itoa(int(s.KeyTTL.Seconds()))},
+		"Policy":          {string(policy)},
+		"RoleSessionName": {bucket},
+	}
+	if s.RoleARN != "" {
+		form.Set("RoleArn", s.RoleARN)
+	}
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	data, err := s.do(ctx, "POST", "sts", "/", nil, []byte(form.Encode()), header)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Result struct {
+			Credentials BucketCredentials
+		} `xml:"AssumeRoleResult"`
+	}
+	if err := xml.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("object store: decode credentials: %w", err)
+	}
+	creds := res.Result.Credentials
+	if creds.AccessKeyID == "" {
+		return nil, fmt.Errorf("object store: AssumeRole returned no credentials")
+	}
+	return &creds, nil
+}
+
+// contentMD5 is the Content-MD5 header S3 requires on lifecycle and batch delete requests
+func contentMD5(body []byte) http.Header {
+	sum := md5.Sum(body)
+	return http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}}
+}
+
+// do sends a signed request and returns the response body, or an
+// *ObjectStoreError for error statuses
+func (s *ObjectStoreClient) do(ctx context.Context, method, service, path string, query url.Values, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = canonicalQuery(query)
+	maps.Copy(req.Header, header)
+	s.sign(req, body, service, time.Now().UTC())
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("object store: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("object store: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		// S3 answers <Error>, STS <ErrorResponse><Error>
+		var e struct {
+			Code, Message string
+			Error         struct{ Code, Message string }
+		}
+		xml.Unmarshal(data, &e)
+		if e.Code == "" {
+			e.Code, e.Message = e.Error.Code, e.Error.Message
+		}
+		return nil, &ObjectStoreError{Status: resp.StatusCode, Code: e.Code, Message: e.Message}
+	}
+	return data, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to req
+func (s *ObjectStoreClient) sign(req *http.Request, body []byte, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-md5" || lk == "content-type" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, uri, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key with %20 for spaces, as
+// Signature Version 4 expects
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	escape := func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "+", "%20") }
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,138 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Violation is one reason a worker spec is refused before its WorkerApp CR
+// is created
+type Violation struct {
+	Field   string `json:"field"`   // request field, e.g. image or assigned_cpu
+	Rule    string `json:"rule"`    // registry, plan, port, security, admission or capacity
+	Message string `json:"message"` // what is wrong and how to fix it
+}
+
+func (v Violation) String() string {
+	return v.Field + ": " + v.Message
+}
+
+var (
+	// ImageRegistries are the registries (or "registry/namespace" prefixes)
+	// worker images may come from; empty allows any (env IMAGE_REGISTRIES,
+	// comma separated). Docker Hub images match "docker.io".
+	ImageRegistries []string
+	// WorkerPortMin and WorkerPortMax bound the container port of workers
+	// (env WORKER_PORT_RANGE, e.g. "1024-65535")
+	WorkerPortMin = 1
+	WorkerPortMax = 65535
+)
+
+// Defaults the worker Deployment uses for unset resources
+const (
+	DefaultWorkerCPU    = "1"
+	DefaultWorkerMemory = "500Mi"
+	DefaultWorkerDisk   = "2Gi"
+)
+
+// ParsePortRange parses "min-max"
+func ParsePortRange(s string) (int, int, error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("port range %q is not min-max", s)
+	}
+	lo, err1 := strconv.Atoi(strings.TrimSpace(a))
+	hi, err2 := strconv.Atoi(strings.TrimSpace(b))
+	if err1 != nil || err2 != nil || lo < 1 || hi > 65535 || lo > hi {
+		return 0, 0, fmt.Errorf("port range %q must be two ports between 1 and 65535, min first", s)
+	}
+	return lo, hi, nil
+}
+
+// WorkerPolicy is what the local policy checks look at
+type WorkerPolicy struct {
+	Image          string
+	Port           int
+	AssignedCPU    string
+	AssignedMemory string
+	AssignedDisk   string
+	MaxReplicas    int
+	RunAsRoot      bool
+	WritableRootFS bool
+}
+
+// CheckWorkerPolicy runs the local policy checks: image registry allowlist,
+// port range, resources within the plan and security opt-outs allowed by it.
+// Every violation is returned, not just the first.
+func CheckWorkerPolicy(p WorkerPolicy, plan TenantPlan) []Violation {
+	var out []Violation
+	add := func(field, rule, format string, args ...any) {
+		out = append(out, Violation{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !imageAllowed(p.Image) {
+		add("image", "registry", "%s is not from an allowed registry (%s)", p.Image, strings.Join(ImageRegistries, ", "))
+	}
+	if p.Port < WorkerPortMin || p.Port > WorkerPortMax {
+		add("port", "port", "port %d is outside the allowed range %d-%d", p.Port, WorkerPortMin, WorkerPortMax)
+	}
+
+	replicas := int64(max(p.MaxReplicas, 1))
+	check := func(field, value, def, perContainer, quota string) {
+		if value == "" {
+			value = def
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			add(field, "plan", "%q is not a quantity", value)
+			return
+		}
+		if perContainer != "" && q.Cmp(resource.MustParse(perContainer)) > 0 {
+			add(field, "plan", "%s is above the %s allowed per worker by plan %s", value, perContainer, plan.Name)
+		}
+		total := q.DeepCopy()
+		total.Mul(replicas)
+		if total.Cmp(resource.MustParse(quota)) > 0 {
+			add(field, "plan", "%d replicas of %s exceed the %s quota of plan %s", replicas, value, quota, plan.Name)
+		}
+	}
+	check("assigned_cpu", p.AssignedCPU, DefaultWorkerCPU, plan.MaxCPU, plan.CPU)
+	check("assigned_memory", p.AssignedMemory, DefaultWorkerMemory, plan.MaxMemory, plan.Memory)
+	check("assigned_disk", p.AssignedDisk, DefaultWorkerDisk, "", plan.Storage)
+	if plan.MaxReplicas > 0 && replicas > int64(plan.MaxReplicas) {
+		add("max_replicas", "plan", "%d replicas exceed the %d allowed per worker by plan %s", replicas, plan.MaxReplicas, plan.Name)
+	}
+	if replicas > int64(plan.Pods) {
+		add("max_replicas", "plan", "%d replicas exceed the %d pods of plan %s", replicas, plan.Pods, plan.Name)
+	}
+
+	if p.RunAsRoot && !plan.AllowRunAsRoot {
+		add("run_as_root", "security", "plan %s does not allow run_as_root", plan.Name)
+	}
+	if p.WritableRootFS && !plan.AllowWritableRootFS {
+		add("writable_root_fs", "security", "plan %s does not allow writable_root_fs", plan.Name)
+	}
+	return out
+}
+
+// imageAllowed matches the image's registry, or registry/namespace prefix, against ImageRegistries
+func imageAllowed(image string) bool {
+	if len(ImageRegistries) == 0 {
+		return true
+	}
+	host, repo, _ := parseImageRef(image)
+	if host == "registry-1.docker.io" {
+		host = "docker.io"
+	}
+	name := host + "/" + repo
+	for _, r := range ImageRegistries {
+		r = strings.TrimSuffix(r, "/")
+		if name == r || strings.HasPrefix(name, r+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,48 +1,376 @@
 package k8s
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"jabberwocky238/console/logging"
+	"jabberwocky238/console/metrics"
 )
 
+var procLog = logging.Component("processor")
+
+// ErrProcessorClosed is returned by Submit once Shutdown has started
+var ErrProcessorClosed = errors.New("processor is shutting down")
+
+// ErrJobTimedOut is the result of a job whose lease refused a renewal (it ran
+// past its maximum run time) and that did not return within abandonGrace
+// after its context was cancelled. The job goroutine is left behind.
+var ErrJobTimedOut = errors.New("job ran past its maximum run time")
+
+// abandonGrace is how long a job gets to return once its lease is lost
+const abandonGrace = 30 * time.Second
+
+// PanicError is the result of a job whose Do panicked
+type PanicError struct {
+	Value any
+	Stack string
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("job panicked: %v", e.Value) }
+
+// Lease marks a job as running so that another instance can requeue it when
+// the heartbeats stop, because this instance died or the job hung.
+type Lease interface {
+	// Renew extends the lease; an error stops the heartbeats and cancels the job
+	Renew(ctx context.Context) error
+	// Release ends the lease with the result of the run
+	Release(ctx context.Context, err error)
+}
+
+// Leaser hands out leases; Acquire returns nil for jobs that are not leased
+type Leaser interface {
+	Acquire(ctx context.Context, job Job) Lease
+}
+
+// Gate caps how many jobs may run at once, e.g. per tenant. Admit reports
+// whether job may start now and takes a slot if so; Done gives the slot back.
+// Both are called with the processor's park lock held and must not block.
+type Gate interface {
+	Admit(job Job) bool
+	Done(job Job)
+}
+
 type Processor struct {
-	JobQueue chan Job
 	PoolSize int
+
+	queue     chan queuedJob
+	hooks     []func(Job, error)
+	runHooks  []func(JobRun)
+	leaser    Leaser
+	heartbeat time.Duration
+	gate      Gate
+
+	// parkMu guards parked, the jobs the gate turned away, oldest first
+	parkMu sync.Mutex
+	parked []queuedJob
+
+	// mu guards closed; Submit holds it shared so Shutdown can wait out in-flight sends
+	mu       sync.RWMutex
+	closed   bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	// ctx is passed to every job; Shutdown cancels it once the drain deadline passes
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 type JobType string
 
+// queuedJob is a job waiting in the queue since at
+type queuedJob struct {
+	job Job
+	at  time.Time
+}
+
+// JobRun is one finished job run: Wait is the time it spent in the queue,
+// Duration the time Do took
+type JobRun struct {
+	Job      Job
+	Err      error
+	Wait     time.Duration
+	Duration time.Duration
+}
+
 type Job interface {
 	Type() JobType
 	ID() string
-	Do() error
+	// Do runs the job; ctx is cancelled when the processor gives up on running jobs at shutdown
+	Do(ctx context.Context) error
 }
 
 func NewProcessor(queueSize int, poolSize int) *Processor {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Processor{
-		JobQueue: make(chan Job, queueSize),
+		queue:    make(chan queuedJob, queueSize),
 		PoolSize: poolSize,
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
+// Close stops the processor without waiting; queued jobs that have not
+// started are dropped. Use Shutdown to drain and re-queue them instead.
 func (p *Processor) Close() error {
-	close(p.JobQueue)
-	log.Println("[processor] stopped")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Shutdown(ctx, nil)
 	return nil
 }
 
-func (p *Processor) Submit(job Job) {
-	p.JobQueue <- job
+// Shutdown stops accepting jobs, lets running jobs finish until ctx is done
+// and hands every queued job that never started to requeue (may be nil).
+// It returns ctx.Err() if running jobs were still in progress at the deadline.
+func (p *Processor) Shutdown(ctx context.Context, requeue func(Job)) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		procLog.Warn("drain deadline reached, running jobs abandoned", "error", err)
+	}
+	p.cancel()
+
+	// parked jobs were dequeued first, so they go before the rest of the queue
+	p.parkMu.Lock()
+	left := p.parked
+	p.parked = nil
+	p.parkMu.Unlock()
+	metrics.JobsParked.Set(0)
+	for drained := false; !drained; {
+		select {
+		case q := <-p.queue:
+			left = append(left, q)
+		default:
+			drained = true
+		}
+	}
+
+	requeued := 0
+	for _, q := range left {
+		if requeue == nil {
+			procLog.Warn("dropped queued job", "job_type", q.job.Type(), "job_id", q.job.ID())
+			continue
+		}
+		requeue(q.job)
+		requeued++
+	}
+	procLog.Info("stopped", "requeued", requeued)
+	return err
+}
+
+// OnFinish registers a hook called after every job run with its result.
+// Hooks must be registered before Start.
+func (p *Processor) OnFinish(hook func(Job, error)) {
+	p.hooks = append(p.hooks, hook)
+}
+
+// SetLeaser leases every job run from l and renews the lease every heartbeat.
+// Must be called before Start.
+func (p *Processor) SetLeaser(l Leaser, heartbeat time.Duration) {
+	p.leaser, p.heartbeat = l, heartbeat
+}
+
+// SetGate caps concurrent jobs with g. Jobs it turns away are parked and
+// started by the next worker that finishes a job once g admits them.
+// Must be called before Start.
+func (p *Processor) SetGate(g Gate) {
+	p.gate = g
+}
+
+// OnRun registers a hook called after every job run with its timings.
+// Hooks must be registered before Start.
+func (p *Processor) OnRun(hook func(JobRun)) {
+	p.runHooks = append(p.runHooks, hook)
+}
+
+// Submit queues a job, blocking while the queue is full. It fails with
+// ErrProcessorClosed once Shutdown has started.
+func (p *Processor) Submit(job Job) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		metrics.JobsRejected.WithLabelValues(string(job.Type())).Inc()
+		return ErrProcessorClosed
+	}
+	select {
+	case p.queue <- queuedJob{job: job, at: time.Now()}:
+		metrics.JobQueueDepth.Set(float64(len(p.queue)))
+		return nil
+	case <-p.stop:
+		metrics.JobsRejected.WithLabelValues(string(job.Type())).Inc()
+		return ErrProcessorClosed
+	}
 }
 
 func (p *Processor) Start() {
 	for range p.PoolSize {
+		p.wg.Add(1)
 		go func() {
-			for job := range p.JobQueue {
-				if err := job.Do(); err != nil {
-					log.Printf("[processor] job failed (type=%s, id=%s): %v", job.Type(), job.ID(), err)
+			defer p.wg.Done()
+			for {
+				// stop wins over a non-empty queue so leftover jobs get re-queued
+				select {
+				case <-p.stop:
+					return
+				default:
+				}
+				select {
+				case <-p.stop:
+					return
+				case q := <-p.queue:
+					for ok := p.admit(q); ok; q, ok = p.release(q.job) {
+						p.run(q)
+					}
 				}
 			}
 		}()
 	}
-	log.Println("[processor] started")
+	procLog.Info("started", "workers", p.PoolSize)
+}
+
+// admit reports whether q may start now and parks it otherwise
+func (p *Processor) admit(q queuedJob) bool {
+	if p.gate == nil {
+		return true
+	}
+	p.parkMu.Lock()
+	defer p.parkMu.Unlock()
+	if p.gate.Admit(q.job) {
+		return true
+	}
+	p.parked = append(p.parked, q)
+	metrics.JobsParked.Set(float64(len(p.parked)))
+	return false
+}
+
+// release gives the slot of a finished job back to the gate and returns the
+// oldest parked job that may start now. Admit and park happen under the same
+// lock, so a job is never parked after the slot it waits for was released.
+func (p *Processor) release(job Job) (queuedJob, bool) {
+	if p.gate == nil {
+		return queuedJob{}, false
+	}
+	p.parkMu.Lock()
+	defer p.parkMu.Unlock()
+	p.gate.Done(job)
+	select {
+	case <-p.stop:
+		// Shutdown hands the parked jobs to requeue
+		return queuedJob{}, false
+	default:
+	}
+	for i, q := range p.parked {
+		if p.gate.Admit(q.job) {
+			p.parked = slices.Delete(p.parked, i, i+1)
+			metrics.JobsParked.Set(float64(len(p.parked)))
+			return q, true
+		}
+	}
+	return queuedJob{}, false
+}
+
+func (p *Processor) run(q queuedJob) {
+	job := q.job
+	metrics.JobQueueDepth.Set(float64(len(p.queue)))
+	metrics.JobsRunning.Inc()
+	start := time.Now()
+	wait := start.Sub(q.at)
+	metrics.JobQueueWait.WithLabelValues(string(job.Type())).Observe(wait.Seconds())
+	var lease Lease
+	if p.leaser != nil {
+		lease = p.leaser.Acquire(p.ctx, job)
+	}
+	err := p.do(job, lease)
+	if lease != nil {
+		// the processor ctx may be cancelled at shutdown, the result still counts
+		lease.Release(context.Background(), err)
+	}
+	took := time.Since(start)
+	metrics.JobsRunning.Dec()
+	metrics.JobDuration.WithLabelValues(string(job.Type())).Observe(took.Seconds())
+
+	result := "success"
+	var panicked *PanicError
+	if errors.As(err, &panicked) {
+		metrics.JobPanics.WithLabelValues(string(job.Type())).Inc()
+		procLog.Error("job panicked", "job_type", job.Type(), "job_id", job.ID(), "panic", panicked.Value, "stack", panicked.Stack)
+	}
+	if err != nil {
+		result = "error"
+		procLog.Error("job failed", "job_type", job.Type(), "job_id", job.ID(), "error", err)
+	}
+	metrics.Jobs.WithLabelValues(string(job.Type()), result).Inc()
+	for _, hook := range p.hooks {
+		hook(job, err)
+	}
+	run := JobRun{Job: job, Err: err, Wait: wait, Duration: took}
+	for _, hook := range p.runHooks {
+		hook(run)
+	}
+}
+
+// do runs job with panics recovered. With a lease it heartbeats while the job
+// runs; once a renewal fails the job's ctx is cancelled and the job is
+// abandoned if it has not returned within abandonGrace.
+func (p *Processor) do(job Job, lease Lease) error {
+	ctx, cancel := context.WithCancelCause(p.ctx)
+	defer cancel(nil)
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				result <- &PanicError{Value: v, Stack: string(debug.Stack())}
+			}
+		}()
+		result <- job.Do(ctx)
+	}()
+	if lease == nil {
+		return <-result
+	}
+
+	ticker := time.NewTicker(p.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-result:
+			return err
+		case <-ticker.C:
+			err := lease.Renew(p.ctx)
+			if err == nil {
+				continue
+			}
+			procLog.Warn("job lease lost, cancelling", "job_type", job.Type(), "job_id", job.ID(), "error", err)
+			cancel(err)
+			select {
+			case err := <-result:
+				if err == nil {
+					return nil
+				}
+				return fmt.Errorf("%w: %w", ErrJobTimedOut, err)
+			case <-time.After(abandonGrace):
+				procLog.Error("job abandoned", "job_type", job.Type(), "job_id", job.ID())
+				return ErrJobTimedOut
+			}
+		}
+	}
 }
@@ -0,0 +1,126 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var promClient = &http.Client{Timeout: 10 * time.Second}
+
+// QueryPromScalar runs an instant PromQL query that yields a single value.
+// ok is false when the query has no data (e.g. no traffic in the window).
+func QueryPromScalar(ctx context.Context, query string) (value float64, ok bool, err error) {
+	result, err := queryProm(ctx, query)
+	if err != nil || len(result) == 0 {
+		return 0, false, err
+	}
+	v, ok := promValue(result[0].Value)
+	return v, ok, nil
+}
+
+// QueryPromVector runs an instant PromQL query and returns its samples keyed
+// by the value of label. Samples without a value (NaN) are left out.
+func QueryPromVector(ctx context.Context, query, label string) (map[string]float64, error) {
+	result, err := queryProm(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64, len(result))
+	for _, r := range result {
+		if v, ok := promValue(r.Value); ok {
+			out[r.Metric[label]] = v
+		}
+	}
+	return out, nil
+}
+
+type promSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]any            `json:"value"`
+}
+
+func queryProm(ctx context.Context, query string) ([]promSample, error) {
+	params := url.Values{"query": {query}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimRight(PrometheusEndpoint, "/")+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := promClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("prometheus returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var out struct {
+		Data struct {
+			Result []promSample `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode prometheus response: %w", err)
+	}
+	return out.Data.Result, nil
+}
+
+func promValue(value [2]any) (float64, bool) {
+	s, _ := value[1].(string)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) { // NaN: 0/0 when there were no requests
+		return 0, false
+	}
+	return v, true
+}
+
+// WorkerTraefikService matches the Traefik service of a worker's IngressRoute
+// in Traefik's Prometheus metrics (needs addServicesLabels, on by default).
+// Other ingress providers export no such series, so error-rate and latency
+// alerts only fire with the traefik provider.
+func WorkerTraefikService(workerName string) string {
+	return fmt.Sprintf(`service=~"%s-%s-.*@kubernetescrd"`, IngressNamespace, workerName)
+}
+
+// WorkerEgressBytes returns the response bytes Traefik sent for each worker
+// over the last window, keyed by worker name. Like WorkerTraefikService it
+// only has data with the traefik ingress provider.
+func WorkerEgressBytes(ctx context.Context, window time.Duration) (map[string]float64, error) {
+	return sumByWorker(ctx, "traefik_service_responses_bytes_total", window)
+}
+
+// WorkerRequests returns the requests Traefik routed to each worker over the
+// last window, keyed by worker name. Workers without traffic may be missing.
+func WorkerRequests(ctx context.Context, window time.Duration) (map[string]float64, error) {
+	return sumByWorker(ctx, "traefik_service_requests_total", window)
+}
+
+// sumByWorker sums the increase of a Traefik service counter per worker
+func sumByWorker(ctx context.Context, metric string, window time.Duration) (map[string]float64, error) {
+	prefix := IngressNamespace + "-"
+	q := fmt.Sprintf(`sum by (service) (increase(%s{service=~"%s.*@kubernetescrd"}[%ds]))`,
+		metric, prefix, int(window.Seconds()))
+	byService, err := QueryPromVector(ctx, q, "service")
+	if err != nil {
+		return nil, err
+	}
+	// service is <namespace>-<route service>-<port>@kubernetescrd, the route service is the worker name
+	out := map[string]float64{}
+	for service, v := range byService {
+		name := strings.TrimSuffix(strings.TrimPrefix(service, prefix), "@kubernetescrd")
+		if i := strings.LastIndexByte(name, '-'); i > 0 {
+			out[name[:i]] += v
+		}
+	}
+	return out, nil
+}
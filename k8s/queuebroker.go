@@ -0,0 +1,340 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Queue resources live on a shared RabbitMQ cluster that the inner gateway
+// administers through the management HTTP API (env QUEUE_BROKER_*). Every
+// user gets a vhost and a RabbitMQ user of the same name, created with their
+// first queue and only allowed into that vhost; the user's password is kept
+// in the Secret queue-<uid> in CombinatorNamespace. A queue resource is a
+// durable queue named after the resource ID in its owner's vhost.
+
+// QueueBroker is nil until the management API, AMQP URL and admin
+// credentials are set
+var QueueBroker *QueueBrokerClient
+
+// QueueBrokerClient talks to the RabbitMQ management API
+type QueueBrokerClient struct {
+	APIURL      string // management API, e.g. http://rabbitmq.queue.svc:15672
+	AMQPURL     string // amqp(s)://host[:port] that workers and combinators connect to
+	User        string // administrator of the management API
+	Password    string
+	VhostPrefix string // vhost and user names are the prefix plus the owner ID
+
+	http *http.Client
+}
+
+// QueueBrokerEnvs are the environment variables read by SetQueueBrokerEnv
+var QueueBrokerEnvs = []string{
+	"QUEUE_BROKER_API_URL", "QUEUE_BROKER_AMQP_URL", "QUEUE_BROKER_USER",
+	"QUEUE_BROKER_PASSWORD", "QUEUE_BROKER_VHOST_PREFIX",
+}
+
+// queueBrokerConfig collects QueueBrokerEnvs in any order
+var queueBrokerConfig = QueueBrokerClient{VhostPrefix: "console-"}
+
+// SetQueueBrokerEnv applies one of QueueBrokerEnvs; QueueBroker is set once
+// both URLs and the admin credentials are known
+func SetQueueBrokerEnv(env, value string) error {
+	cfg := &queueBrokerConfig
+	switch env {
+	case "QUEUE_BROKER_API_URL":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return fmt.Errorf("QUEUE_BROKER_API_URL %q must be http(s)://host[:port]", value)
+		}
+		cfg.APIURL = u.Scheme + "://" + u.Host
+	case "QUEUE_BROKER_AMQP_URL":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" || u.User != nil || strings.Trim(u.Path, "/") != "" {
+			return fmt.Errorf("QUEUE_BROKER_AMQP_URL %q must be amqp(s)://host[:port] without credentials", value)
+		}
+		cfg.AMQPURL = u.Scheme + "://" + u.Host
+	case "QUEUE_BROKER_USER":
+		cfg.User = value
+	case "QUEUE_BROKER_PASSWORD":
+		cfg.Password = value
+	case "QUEUE_BROKER_VHOST_PREFIX":
+		for _, r := range value {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return fmt.Errorf("QUEUE_BROKER_VHOST_PREFIX %q must be lowercase letters, digits, - and _", value)
+			}
+		}
+		cfg.VhostPrefix = value
+	}
+	if cfg.APIURL != "" && cfg.AMQPURL != "" && cfg.User != "" && cfg.Password != "" {
+		c := *cfg
+		c.http = &http.Client{Timeout: 15 * time.Second}
+		QueueBroker = &c
+	}
+	return nil
+}
+
+// ErrNoQueueAccount is returned for an owner whose vhost has not been
+// created yet, that is before their first queue
+var ErrNoQueueAccount = errors.New("queue account not created yet")
+
+// ErrQueueNotFound is returned by QueueStats for a queue the broker does not have
+var ErrQueueNotFound = errors.New("queue not found on the broker")
+
+// errBrokerNotFound is a 404 of the management API
+var errBrokerNotFound = errors.New("queue broker: not found")
+
+// QueueBrokerError is an error response of the management API
+type QueueBrokerError struct {
+	Status int
+	Err    string
+	Reason string
+}
+
+func (e *QueueBrokerError) Error() string {
+	return fmt.Sprintf("queue broker: %d %s: %s", e.Status, e.Err, e.Reason)
+}
+
+// Vhost is the vhost, and the name of the broker user, of an owner
+func (b *QueueBrokerClient) Vhost(ownerID string) string {
+	return b.VhostPrefix + ownerID
+}
+
+// QueueName is the name of the queue of a resource in its owner's vhost
+func (b *QueueBrokerClient) QueueName(resourceID string) string {
+	return resourceID
+}
+
+func queueSecretName(ownerID string) string {
+	return "queue-" + ownerID
+}
+
+// queuePassword reads the owner's broker password from their Secret; with
+// create it generates and stores one when the Secret does not exist
+func queuePassword(ctx context.Context, ownerID string, create bool) (string, error) {
+	if K8sClient == nil {
+		return "", fmt.Errorf("k8s client not available")
+	}
+	secrets := K8sClient.CoreV1().Secrets(CombinatorNamespace)
+	sec, err := secrets.Get(ctx, queueSecretName(ownerID), metav1.GetOptions{})
+	if err == nil {
+		return string(sec.Data["password"]), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	if !create {
+		return "", ErrNoQueueAccount
+	}
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	password := hex.EncodeToString(raw)
+	sec = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      queueSecretName(ownerID),
+			Namespace: CombinatorNamespace,
+			Labels:    map[string]string{"app": "queue", "queue-owner": ownerID},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{"password": []byte(password)},
+	}
+	if _, err := secrets.Create(ctx, sec, metav1.CreateOptions{}); apierrors.IsAlreadyExists(err) {
+		return queuePassword(ctx, ownerID, false) // created concurrently
+	} else if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// ensureAccount creates the owner's vhost and user, allowed to configure,
+// write and read anything in the vhost; the admin gets the same rights so
+// it can declare queues there. Every call is idempotent.
+func (b *QueueBrokerClient) ensureAccount(ctx context.Context, ownerID string) error {
+	password, err := queuePassword(ctx, ownerID, true)
+	if err != nil {
+		return fmt.Errorf("get password: %w", err)
+	}
+	name := url.PathEscape(b.Vhost(ownerID)) // the vhost and the user
+	all := map[string]string{"configure": ".*", "write": ".*", "read": ".*"}
+	if err := b.do(ctx, "PUT", "/api/vhosts/"+name, map[string]string{"description": "console user " + ownerID}, nil); err != nil {
+		return err
+	}
+	if err := b.do(ctx, "PUT", "/api/users/"+name, map[string]string{"password": password, "tags": ""}, nil); err != nil {
+		return err
+	}
+	if err := b.do(ctx, "PUT", "/api/permissions/"+name+"/"+name, all, nil); err != nil {
+		return err
+	}
+	return b.do(ctx, "PUT", "/api/permissions/"+name+"/"+url.PathEscape(b.User), all, nil)
+}
+
+// CreateQueue declares the durable queue of a resource, creating the
+// owner's account first; declaring an existing queue succeeds
+func (b *QueueBrokerClient) CreateQueue(ctx context.Context, ownerID, resourceID string) error {
+	if err := b.ensureAccount(ctx, ownerID); err != nil {
+		return fmt.Errorf("ensure account: %w", err)
+	}
+	return b.do(ctx, "PUT", b.queuePath(ownerID, resourceID), map[string]any{"durable": true, "auto_delete": false}, nil)
+}
+
+// DeleteQueue deletes the queue of a resource with its messages,
+// succeeding if it is already gone
+func (b *QueueBrokerClient) DeleteQueue(ctx context.Context, ownerID, resourceID string) error {
+	err := b.do(ctx, "DELETE", b.queuePath(ownerID, resourceID), nil, nil)
+	if err == errBrokerNotFound {
+		return nil
+	}
+	return err
+}
+
+// QueueStats is the state of a queue as the broker reports it
+type QueueStats struct {
+	Vhost           string  `json:"vhost"`
+	Queue           string  `json:"queue"`
+	State           string  `json:"state"`
+	Messages        int64   `json:"messages"` // queue depth: ready plus unacknowledged
+	MessagesReady   int64   `json:"messages_ready"`
+	MessagesUnacked int64   `json:"messages_unacknowledged"`
+	Consumers       int     `json:"consumers"`
+	PublishRate     float64 `json:"publish_rate"` // messages per second, averaged by the broker
+	DeliverRate     float64 `json:"deliver_rate"`
+}
+
+// QueueStats reads the depth, consumers and message rates of the queue of a resource
+func (b *QueueBrokerClient) QueueStats(ctx context.Context, ownerID, resourceID string) (*QueueStats, error) {
+	var q struct {
+		State                  string `json:"state"`
+		Messages               int64  `json:"messages"`
+		MessagesReady          int64  `json:"messages_ready"`
+		MessagesUnacknowledged int64  `json:"messages_unacknowledged"`
+		Consumers              int    `json:"consumers"`
+		MessageStats           struct {
+			PublishDetails    struct{ Rate float64 } `json:"publish_details"`
+			DeliverGetDetails struct{ Rate float64 } `json:"deliver_get_details"`
+		} `json:"message_stats"`
+	}
+	err := b.do(ctx, "GET", b.queuePath(ownerID, resourceID), nil, &q)
+	if err == errBrokerNotFound {
+		return nil, ErrQueueNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &QueueStats{
+		Vhost:           b.Vhost(ownerID),
+		Queue:           b.QueueName(resourceID),
+		State:           q.State,
+		Messages:        q.Messages,
+		MessagesReady:   q.MessagesReady,
+		MessagesUnacked: q.MessagesUnacknowledged,
+		Consumers:       q.Consumers,
+		PublishRate:     q.MessageStats.PublishDetails.Rate,
+		DeliverRate:     q.MessageStats.DeliverGetDetails.Rate,
+	}, nil
+}
+
+// DSN is the AMQP URL of the owner's vhost with their credentials;
+// ErrNoQueueAccount before their first queue
+func (b *QueueBrokerClient) DSN(ctx context.Context, ownerID string) (string, error) {
+	password, err := queuePassword(ctx, ownerID, false)
+	if err != nil {
+		return "", err
+	}
+	u, _ := url.Parse(b.AMQPURL)
+	u.User = url.UserPassword(b.Vhost(ownerID), password)
+	u.Path = "/" + b.Vhost(ownerID)
+	return u.String(), nil
+}
+
+// ListAccountOwners returns the owner IDs of every vhost with VhostPrefix
+func (b *QueueBrokerClient) ListAccountOwners(ctx context.Context) ([]string, error) {
+	var vhosts []struct {
+		Name string `json:"name"`
+	}
+	if err := b.do(ctx, "GET", "/api/vhosts?columns=name", nil, &vhosts); err != nil {
+		return nil, err
+	}
+	var owners []string
+	for _, v := range vhosts {
+		if owner, ok := strings.CutPrefix(v.Name, b.VhostPrefix); ok && owner != "" {
+			owners = append(owners, owner)
+		}
+	}
+	return owners, nil
+}
+
+// DeleteAccount deletes the owner's vhost with all its queues, their user
+// and the Secret with its password
+func (b *QueueBrokerClient) DeleteAccount(ctx context.Context, ownerID string) error {
+	name := url.PathEscape(b.Vhost(ownerID))
+	for _, path := range []string{"/api/vhosts/" + name, "/api/users/" + name} {
+		if err := b.do(ctx, "DELETE", path, nil, nil); err != nil && err != errBrokerNotFound {
+			return err
+		}
+	}
+	if K8sClient == nil {
+		return nil
+	}
+	err := K8sClient.CoreV1().Secrets(CombinatorNamespace).Delete(ctx, queueSecretName(ownerID), metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *QueueBrokerClient) queuePath(ownerID, resourceID string) string {
+	return "/api/queues/" + url.PathEscape(b.Vhost(ownerID)) + "/" + url.PathEscape(b.QueueName(resourceID))
+}
+
+// do sends a request as the admin user and decodes the response into out.
+// 404 is errBrokerNotFound, other error statuses a *QueueBrokerError.
+func (b *QueueBrokerClient) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.APIURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(b.User, b.Password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("queue broker: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errBrokerNotFound
+	}
+	if resp.StatusCode >= 300 {
+		var e struct{ Error, Reason string }
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&e)
+		return &QueueBrokerError{Status: resp.StatusCode, Err: e.Error, Reason: e.Reason}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(out)
+}
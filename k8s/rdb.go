@@ -4,15 +4,19 @@ import (
 	"container/list"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
+	"jabberwocky238/console/logging"
+
+	"github.com/lib/pq"
 )
 
+var rdbLog = logging.Component("rdb")
+
 // RootRDBManager holds persistent connections to CockroachDB
 type RootRDBManager struct {
 	rootmu  sync.RWMutex
@@ -81,7 +85,7 @@ func (mgr *userDBManager) getOrCreateUserDB(userUID string) (*sql.DB, *userRDB,
 				return db, userRDB, nil
 			}
 			// Connection exists but unhealthy
-			log.Printf("[rdb] user %s connection lost, reconnecting...", userUID)
+			rdbLog.Warn("user connection lost, reconnecting", "user_id", userUID)
 		}
 		// db == nil means connection is being closed or not initialized, fall through to slow path
 	}
@@ -118,7 +122,7 @@ func (mgr *userDBManager) getOrCreateUserDB(userUID string) (*sql.DB, *userRDB,
 				delete(mgr.userDBs, oldUID)
 			}
 			mgr.lruList.Remove(oldest)
-			log.Printf("[rdb] evicted LRU user connection: %s", oldUID)
+			rdbLog.Debug("evicted LRU user connection", "user_id", oldUID)
 		}
 	}
 
@@ -137,12 +141,12 @@ func (mgr *userDBManager) getOrCreateUserDB(userUID string) (*sql.DB, *userRDB,
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		if err := db.PingContext(ctx); err != nil {
 			cancel()
-			log.Printf("[rdb] user %s ping attempt %d/3 failed: %v", userUID, i+1, err)
+			rdbLog.Warn("user ping failed", "user_id", userUID, "attempt", i+1, "error", err)
 			db.Close()
 			continue
 		}
 		cancel()
-		log.Printf("[rdb] user %s connected on attempt %d/3", userUID, i+1)
+		rdbLog.Debug("user connected", "user_id", userUID, "attempt", i+1)
 
 		// Add to LRU cache
 		element := mgr.lruList.PushFront(userUID)
@@ -205,7 +209,7 @@ func (m *RootRDBManager) tryGetRootDB() (*sql.DB, error) {
 		if err == nil {
 			return m.rootDB, nil
 		}
-		log.Println("[rdb] root connection lost, reconnecting...")
+		rdbLog.Warn("root connection lost, reconnecting")
 		m.rootDB.Close()
 		m.rootDB = nil
 	}
@@ -224,12 +228,12 @@ func (m *RootRDBManager) tryGetRootDB() (*sql.DB, error) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		if err := db.PingContext(ctx); err != nil {
 			cancel()
-			log.Printf("[rdb] root ping attempt %d/3 failed: %v", i+1, err)
+			rdbLog.Warn("root ping failed", "attempt", i+1, "error", err)
 			db.Close()
 			continue
 		}
 		cancel()
-		log.Printf("[rdb] root reconnected on attempt %d/3", i+1)
+		rdbLog.Info("root reconnected", "attempt", i+1)
 		m.rootDB = db
 		return db, nil
 	}
@@ -324,6 +328,44 @@ func (m *RootRDBManager) DeleteSchema(userUID, schemaID string) error {
 	return err
 }
 
+// ErrStatementTimeout is returned by ExecAsUser when the statements ran past their timeout
+var ErrStatementTimeout = errors.New("statement timeout")
+
+// ExecAsUser runs statements (one or more, separated by semicolons) in
+// schema schemaID of the user's database. It connects as the user's own role
+// like their workers do, so it reaches nothing they cannot. The server stops
+// a statement at timeout (statement_timeout) and the whole batch is cancelled
+// once timeout has passed. It returns the rows affected by the last statement.
+func (m *RootRDBManager) ExecAsUser(ctx context.Context, userUID, schemaID, statements string, timeout time.Duration) (int64, error) {
+	// A connection of its own: the pooled ones are shared and have no search_path
+	db, err := sql.Open("postgres", newUserRDB(userUID).dsnWithSchema(schemaID)+"&application_name=console-task")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		return 0, err
+	}
+
+	res, err := conn.ExecContext(ctx, statements)
+	if err != nil {
+		var pqErr *pq.Error
+		if ctx.Err() == context.DeadlineExceeded || errors.As(err, &pqErr) && pqErr.Code == "57014" && ctx.Err() == nil {
+			return 0, ErrStatementTimeout
+		}
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // ListSchemas lists all schemas in user's database
 func (m *RootRDBManager) ListSchemas(userUID string) ([]string, error) {
 	db, _, err := m.tryGetUserDB(userUID)
@@ -574,9 +616,9 @@ func (m *RootRDBManager) ForceAnalyze(userUID string) error {
 	}
 	for _, r := range results {
 		fullTableName := fmt.Sprintf("%s.%s.%s", rdb.database(), r.SchemaName, r.TableName)
-		log.Printf("[rdb] running ANALYZE on %s", fullTableName)
+		rdbLog.Info("running ANALYZE", "table", fullTableName)
 		if _, err := userdb.Exec(fmt.Sprintf("ANALYZE %s", fullTableName)); err != nil {
-			log.Printf("[rdb] ANALYZE failed on %s: %v", fullTableName, err)
+			rdbLog.Error("ANALYZE failed", "table", fullTableName, "error", err)
 			return err
 		}
 	}
@@ -0,0 +1,154 @@
+package k8s
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"jabberwocky238/console/dblayer"
+)
+
+// Workers are served on their own host, <wid>-<uid>.worker.<Domain>. Route
+// rules add matches on top of it: more hosts, a path prefix, request headers.
+// Extra hosts stay next to the worker's own, "<label>-<host>", or "*-<host>"
+// for any label, so the *.worker.<Domain> certificate and DNS record already
+// cover them and no worker can claim another's hosts. Every rule routes to
+// the worker; Priority decides which rule matches first where they overlap.
+
+const (
+	// MaxRouteRules caps the extra rules of one worker
+	MaxRouteRules = 20
+	// MaxRoutePriority bounds RouteRule.Priority, 0 to MaxRoutePriority
+	MaxRoutePriority = 1000
+	// maxRouteHeaders caps the header matches of one rule
+	maxRouteHeaders = 10
+	// routeRulePriority lifts the Traefik priority of rules above the
+	// worker's own route, whose default priority is the length of its match
+	routeRulePriority = 10000
+)
+
+var (
+	routeHostLabel  = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	routePathPrefix = regexp.MustCompile(`^/[A-Za-z0-9._~!$&'()*+,;=:@%/-]{0,255}$`)
+	routeHeaderName = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+)
+
+// NormalizeRouteRules validates the route rules of the worker serving host
+// and returns them with hosts lowercased and without duplicates, header
+// names canonical, highest priority first
+func NormalizeRouteRules(rules []dblayer.RouteRule, host string) ([]dblayer.RouteRule, error) {
+	out := make([]dblayer.RouteRule, 0, len(rules))
+	if len(rules) > MaxRouteRules {
+		return out, fmt.Errorf("at most %d route rules", MaxRouteRules)
+	}
+	for i, r := range rules {
+		n := dblayer.RouteRule{PathPrefix: r.PathPrefix, Priority: r.Priority}
+		if len(r.Hosts) == 0 && r.PathPrefix == "" && len(r.Headers) == 0 {
+			return out, fmt.Errorf("rule %d: needs hosts, path_prefix or headers", i)
+		}
+		if r.Priority < 0 || r.Priority > MaxRoutePriority {
+			return out, fmt.Errorf("rule %d: priority must be between 0 and %d", i, MaxRoutePriority)
+		}
+		for _, h := range r.Hosts {
+			h = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(h), "."))
+			if !routeHostAllowed(h, host) {
+				return out, fmt.Errorf("rule %d: host %q must be %s, <label>-%s or *-%s", i, h, host, host, host)
+			}
+			if !slices.Contains(n.Hosts, h) {
+				n.Hosts = append(n.Hosts, h)
+			}
+		}
+		if r.PathPrefix != "" && !routePathPrefix.MatchString(r.PathPrefix) {
+			return out, fmt.Errorf("rule %d: invalid path_prefix %q", i, r.PathPrefix)
+		}
+		if len(r.Headers) > maxRouteHeaders {
+			return out, fmt.Errorf("rule %d: at most %d headers", i, maxRouteHeaders)
+		}
+		for k, v := range r.Headers {
+			if !routeHeaderName.MatchString(k) {
+				return out, fmt.Errorf("rule %d: invalid header name %q", i, k)
+			}
+			if len(v) > 256 || strings.ContainsFunc(v, func(c rune) bool { return c < 0x20 || c == 0x7f || c == '`' }) {
+				return out, fmt.Errorf("rule %d: invalid value for header %s", i, k)
+			}
+			if n.Headers == nil {
+				n.Headers = map[string]string{}
+			}
+			n.Headers[http.CanonicalHeaderKey(k)] = v
+		}
+		out = append(out, n)
+	}
+	slices.SortStableFunc(out, func(a, b dblayer.RouteRule) int { return cmp.Compare(b.Priority, a.Priority) })
+	return out, nil
+}
+
+// routeHostAllowed reports whether h is host or one of its sibling hosts
+func routeHostAllowed(h, host string) bool {
+	if h == host {
+		return true
+	}
+	label, ok := strings.CutSuffix(h, "-"+host)
+	if !ok {
+		return false
+	}
+	first, _, _ := strings.Cut(host, ".")
+	return label == "*" || (routeHostLabel.MatchString(label) && len(label)+1+len(first) <= 63)
+}
+
+// ruleHosts are the hosts a rule of r matches, r.Host when it names none
+func ruleHosts(r Route, rule dblayer.RouteRule) []string {
+	if len(rule.Hosts) == 0 {
+		return []string{r.Host}
+	}
+	return rule.Hosts
+}
+
+// exactRuleHosts are the hosts of r's rules that are not wildcards, r.Host first
+func exactRuleHosts(r Route) []string {
+	hosts := []string{r.Host}
+	for _, rule := range r.Rules {
+		for _, h := range rule.Hosts {
+			if !strings.HasPrefix(h, "*") && !slices.Contains(hosts, h) {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return hosts
+}
+
+// traefikRuleMatch renders a rule as a Traefik v3 match expression
+func traefikRuleMatch(r Route, rule dblayer.RouteRule) string {
+	var hosts []string
+	for _, h := range ruleHosts(r, rule) {
+		if rest, ok := strings.CutPrefix(h, "*"); ok {
+			hosts = append(hosts, fmt.Sprintf("HostRegexp(`^[a-z0-9-]+%s$`)", regexp.QuoteMeta(rest)))
+		} else {
+			hosts = append(hosts, fmt.Sprintf("Host(`%s`)", h))
+		}
+	}
+	match := strings.Join(hosts, " || ")
+	if len(hosts) > 1 {
+		match = "(" + match + ")"
+	}
+	if rule.PathPrefix != "" {
+		match += fmt.Sprintf(" && PathPrefix(`%s`)", rule.PathPrefix)
+	}
+	for _, k := range headerNames(rule) {
+		match += fmt.Sprintf(" && Header(`%s`, `%s`)", k, rule.Headers[k])
+	}
+	return match
+}
+
+// headerNames are the header names a rule matches, sorted so routes render
+// the same every time
+func headerNames(rule dblayer.RouteRule) []string {
+	keys := make([]string, 0, len(rule.Headers))
+	for k := range rule.Headers {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
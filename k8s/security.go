@@ -0,0 +1,277 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Worker pods run with the Pod Security "restricted" profile: non-root,
+// no privilege escalation, all capabilities dropped, RuntimeDefault seccomp
+// and a read-only root filesystem with a writable /tmp. Plans may let a
+// worker opt out of running as non-root and of the read-only root filesystem.
+
+// Pod Security Admission label enforced on tenant namespaces
+const PodSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// WorkerTmpVolume is the emptyDir mounted at /tmp while the root filesystem is read-only
+const WorkerTmpVolume = "tmp"
+
+// ErrImageRunsAsRoot is returned by CheckImageNonRoot for images whose user
+// is root or cannot be verified as non-root by the kubelet
+var ErrImageRunsAsRoot = errors.New("image runs as root")
+
+// WorkerSecurity returns the pod and container securityContext of a worker
+func WorkerSecurity(runAsRoot, writableRootFS bool) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	nonRoot := !runAsRoot
+	readOnly := !writableRootFS
+	noEscalation := false
+	seccomp := &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	pod := &corev1.PodSecurityContext{
+		RunAsNonRoot:   &nonRoot,
+		SeccompProfile: seccomp,
+	}
+	container := &corev1.SecurityContext{
+		RunAsNonRoot:             &nonRoot,
+		ReadOnlyRootFilesystem:   &readOnly,
+		AllowPrivilegeEscalation: &noEscalation,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		SeccompProfile:           seccomp,
+	}
+	return pod, container
+}
+
+// PodSecurityLevel is the Pod Security Admission level enforced on the
+// tenant namespaces of a plan: restricted unless its workers may run as root
+func (p TenantPlan) PodSecurityLevel() string {
+	if p.AllowRunAsRoot {
+		return "baseline"
+	}
+	return "restricted"
+}
+
+// CheckSecurityOptOuts returns an error naming the first opt-out plan does not allow
+func (p TenantPlan) CheckSecurityOptOuts(runAsRoot, writableRootFS bool) error {
+	if runAsRoot && !p.AllowRunAsRoot {
+		return fmt.Errorf("plan %s does not allow run_as_root", p.Name)
+	}
+	if writableRootFS && !p.AllowWritableRootFS {
+		return fmt.Errorf("plan %s does not allow writable_root_fs", p.Name)
+	}
+	return nil
+}
+
+// --- Image user inspection ---
+
+var registryClient = &http.Client{Timeout: 15 * time.Second}
+
+const manifestAccept = "application/vnd.oci.image.index.v1+json, " +
+	"application/vnd.docker.distribution.manifest.list.v2+json, " +
+	"application/vnd.oci.image.manifest.v1+json, " +
+	"application/vnd.docker.distribution.manifest.v2+json"
+
+// CheckImageNonRoot reads the image config from its registry and returns an
+// error wrapping ErrImageRunsAsRoot when the image would be refused by
+// runAsNonRoot: its USER is root, unset, or a name the kubelet cannot verify.
+// Registry errors (private registries, rate limits) are returned as is so
+// the caller can let the kubelet decide.
+func CheckImageNonRoot(ctx context.Context, image, arch string) error {
+	user, err := ImageUser(ctx, image, arch)
+	if err != nil {
+		return err
+	}
+	uid, _, _ := strings.Cut(user, ":")
+	switch n, err := strconv.Atoi(uid); {
+	case uid == "" || uid == "root":
+		return fmt.Errorf("%w: %s has no non-root USER, rebuild it with e.g. USER 10001", ErrImageRunsAsRoot, image)
+	case err != nil:
+		return fmt.Errorf("%w: %s runs as user %q, which cannot be verified as non-root; use a numeric USER", ErrImageRunsAsRoot, image, user)
+	case n == 0:
+		return fmt.Errorf("%w: %s runs as UID 0, rebuild it with e.g. USER 10001", ErrImageRunsAsRoot, image)
+	}
+	return nil
+}
+
+// ImageUser returns the USER of an image's config for linux/arch (amd64 when empty)
+func ImageUser(ctx context.Context, image, arch string) (string, error) {
+	if arch == "" {
+		arch = "amd64"
+	}
+	reg, repo, ref := parseImageRef(image)
+	r := &registryRepo{host: reg, repo: repo}
+
+	body, mediaType, err := r.get(ctx, "manifests/"+ref, manifestAccept)
+	if err != nil {
+		return "", err
+	}
+	var manifest struct {
+		Config    struct{ Digest string } `json:"config"`
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return "", fmt.Errorf("decode manifest of %s: %w", image, err)
+	}
+	if strings.Contains(mediaType, "index") || strings.Contains(mediaType, "list") || len(manifest.Manifests) > 0 {
+		digest := ""
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == arch {
+				digest = m.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return "", fmt.Errorf("image %s has no linux/%s variant", image, arch)
+		}
+		if body, _, err = r.get(ctx, "manifests/"+digest, manifestAccept); err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(body, &manifest); err != nil {
+			return "", fmt.Errorf("decode manifest of %s: %w", image, err)
+		}
+	}
+	if manifest.Config.Digest == "" {
+		return "", fmt.Errorf("manifest of %s has no config", image)
+	}
+
+	body, _, err = r.get(ctx, "blobs/"+manifest.Config.Digest, "")
+	if err != nil {
+		return "", err
+	}
+	var config struct {
+		Config struct {
+			User string `json:"User"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(body, &config); err != nil {
+		return "", fmt.Errorf("decode config of %s: %w", image, err)
+	}
+	return config.Config.User, nil
+}
+
+// parseImageRef splits an image reference into registry host, repository
+// and tag or digest, applying Docker Hub defaults
+func parseImageRef(image string) (host, repo, ref string) {
+	host = "registry-1.docker.io"
+	repo = image
+	if first, rest, ok := strings.Cut(image, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		host, repo = first, rest
+		if host == "docker.io" {
+			host = "registry-1.docker.io"
+		}
+	}
+	ref = "latest"
+	if name, digest, ok := strings.Cut(repo, "@"); ok {
+		repo, ref = name, digest
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, ref = repo[:i], repo[i+1:]
+	}
+	if host == "registry-1.docker.io" && !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+	return host, repo, ref
+}
+
+// registryRepo reads from one repository of a registry with anonymous pull
+// tokens, which is what public images need
+type registryRepo struct {
+	host  string
+	repo  string
+	token string
+}
+
+func (r *registryRepo) get(ctx context.Context, path, accept string) ([]byte, string, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/%s/%s", r.host, r.repo, path), nil)
+		if err != nil {
+			return nil, "", err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		resp, err := registryClient.Do(req)
+		if err != nil {
+			return nil, "", fmt.Errorf("query registry %s: %w", r.host, err)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if err := r.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("registry %s returned %s for %s/%s", r.host, resp.Status, r.repo, path)
+		}
+		return body, resp.Header.Get("Content-Type"), nil
+	}
+}
+
+// authenticate fetches an anonymous pull token from the realm of a Bearer challenge
+func (r *registryRepo) authenticate(ctx context.Context, challenge string) error {
+	params, ok := strings.CutPrefix(challenge, "Bearer ")
+	if !ok {
+		return fmt.Errorf("registry %s requires credentials", r.host)
+	}
+	q := url.Values{}
+	realm := ""
+	for _, kv := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		v = strings.Trim(v, `"`)
+		switch k {
+		case "realm":
+			realm = v
+		case "service":
+			q.Set("service", v)
+		}
+	}
+	if realm == "" {
+		return fmt.Errorf("registry %s sent no token realm", r.host)
+	}
+	q.Set("scope", "repository:"+r.repo+":pull")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := registryClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("get registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry %s refused an anonymous token: %s", r.host, resp.Status)
+	}
+	var out struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("decode registry token: %w", err)
+	}
+	r.token = out.Token
+	if r.token == "" {
+		r.token = out.AccessToken
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// A static worker runs StaticSiteImage as a file server over the site
+// volume. Before it starts, an init container of the same image downloads
+// the gzipped tarball of the deploy version into the volume: an uploaded
+// artifact from the outer gateway under StaticArtifactPath, signed with the
+// owner's secret key, or a GitHub archive of the resolved commit. Pods
+// fetch the files on every start, so a worker with deny egress must allow
+// the outer gateway or GitHub.
+
+// StaticSiteImage serves static workers (env STATIC_SITE_IMAGE on the outer
+// gateway, recorded on each deploy version). It needs caddy plus busybox
+// wget, sha256sum and tar, as the official alpine image has.
+var StaticSiteImage = "caddy:2-alpine"
+
+// StaticSitePort is the port the file server listens on
+const StaticSitePort = 8080
+
+// StaticSiteUser runs the static site containers, the image itself runs as root
+const StaticSiteUser int64 = 65532
+
+// StaticSiteVolume is the emptyDir the site files are unpacked into
+const (
+	StaticSiteVolume = "site"
+	StaticSiteRoot   = "/site"
+)
+
+// StaticArtifactURL is the base URL pods reach the outer gateway at for
+// uploaded artifacts (env STATIC_ARTIFACT_URL on the inner gateway).
+// Remote clusters need it to point at an address reaching the outer gateway.
+var StaticArtifactURL = "http://control-plane-outer." + Namespace + ".svc:9900"
+
+// StaticSite is where a static worker's files come from
+type StaticSite struct {
+	URL    string `json:"url"`              // gzipped tarball
+	SHA256 string `json:"sha256,omitempty"` // checked before unpacking when set
+	Dir    string `json:"dir,omitempty"`    // subdirectory served as the site root
+	Strip  bool   `json:"strip,omitempty"`  // the tarball has one top-level directory (GitHub archives)
+}
+
+// staticSiteScript unpacks the tarball into the site volume
+const staticSiteScript = `set -eu
+cd ` + StaticSiteRoot + `
+mkdir .src
+wget -qO .src.tar.gz "$STATIC_URL"
+if [ -n "$STATIC_SHA256" ]; then echo "$STATIC_SHA256  .src.tar.gz" | sha256sum -c >/dev/null; fi
+tar -xzf .src.tar.gz -C .src
+root=.src
+if [ "$STATIC_STRIP" = true ]; then root=$(echo .src/*); fi
+cp -a "$root/$STATIC_DIR/." .
+rm -rf .src .src.tar.gz
+`
+
+// FetchCommand is the init container command unpacking s, configured by FetchEnv
+func (s *StaticSite) FetchCommand() []string {
+	return []string{"sh", "-c", staticSiteScript}
+}
+
+// FetchEnv is the environment of FetchCommand
+func (s *StaticSite) FetchEnv() []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "STATIC_URL", Value: s.URL},
+		{Name: "STATIC_SHA256", Value: s.SHA256},
+		{Name: "STATIC_DIR", Value: s.Dir},
+		{Name: "STATIC_STRIP", Value: strconv.FormatBool(s.Strip)},
+	}
+}
+
+// ServeCommand runs the file server over the site volume
+func ServeCommand(port int) []string {
+	return []string{"caddy", "file-server", "--root", StaticSiteRoot, "--listen", ":" + strconv.Itoa(port)}
+}
+
+// ServeEnv is the environment of ServeCommand: caddy keeps its state under
+// /tmp since the root filesystem is read-only
+func ServeEnv() []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "XDG_CONFIG_HOME", Value: "/tmp/caddy/config"},
+		{Name: "XDG_DATA_HOME", Value: "/tmp/caddy/data"},
+	}
+}
+
+// StaticArtifactPath is where the outer gateway serves the artifact of user
+// uid whose sha256 is sum
+func StaticArtifactPath(uid, sum string) string {
+	return "/static-artifacts/" + uid + "/" + sum
+}
+
+// StaticArtifactSignature signs the artifact path with the owner's secret key
+func StaticArtifactSignature(ownerSK, uid, sum string) string {
+	mac := hmac.New(sha256.New, []byte(ownerSK))
+	mac.Write([]byte(StaticArtifactPath(uid, sum)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// StaticArtifactFetchURL is the signed URL pods download an uploaded artifact from
+func StaticArtifactFetchURL(ownerSK, uid, sum string) string {
+	return StaticArtifactURL + StaticArtifactPath(uid, sum) + "?sig=" + StaticArtifactSignature(ownerSK, uid, sum)
+}
@@ -0,0 +1,315 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TenantNamespaces places the sub-resources of each user's workers
+// (Deployment, Services, ConfigMap, Secret, pods) in a namespace of their own
+// instead of the shared WorkerNamespace (env TENANT_NAMESPACES=true on inner).
+// WorkerApp CRs always stay in WorkerNamespace. Turning it on migrates every
+// worker on its next reconcile, which the controller does for all CRs at start.
+var TenantNamespaces bool
+
+// TenantNamespacePrefix prefixes the user UID in tenant namespace names
+const TenantNamespacePrefix = "worker-"
+
+// Labels set on tenant namespaces
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedBy      = "console"
+	PlanLabel      = "console.app238.com/plan"
+)
+
+// Names of the objects provisioned in every tenant namespace
+const (
+	tenantQuotaName         = "tenant-quota"
+	tenantLimitRangeName    = "tenant-limits"
+	tenantIngressPolicyName = "default-ingress"
+	tenantEgressPolicyName  = "default-egress"
+)
+
+// TenantNamespace returns the namespace holding ownerID's worker resources
+func TenantNamespace(ownerID string) string {
+	if !TenantNamespaces {
+		return WorkerNamespace
+	}
+	return TenantNamespacePrefix + ownerID
+}
+
+// TenantPlan is what a plan allows a tenant namespace to use. Quota values
+// are namespace totals; Max* and Default* apply per container, except
+// MaxReplicas which is per worker. Max* come from the plan's limits
+// (dblayer.Plans) and the account's overrides. Allow* are the
+// securityContext opt-outs its workers may set (see WorkerSecurity).
+// CombinatorProfile and MaxCombinatorProfile size the user's dedicated
+// combinator (CombinatorProfiles).
+type TenantPlan struct {
+	Name           string
+	CPU            string // requests.cpu and limits.cpu
+	Memory         string // requests.memory and limits.memory
+	Storage        string // requests/limits.ephemeral-storage
+	Pods           int
+	MaxCPU         string
+	MaxMemory      string
+	MaxReplicas    int
+	DefaultCPU     string
+	DefaultMemory  string
+	DefaultStorage string
+
+	AllowRunAsRoot      bool
+	AllowWritableRootFS bool
+
+	CombinatorProfile    string
+	MaxCombinatorProfile string
+}
+
+// DefaultPlan applies to users without a plan and to unknown plan names
+const DefaultPlan = dblayer.DefaultPlan
+
+// TenantPlans are the plans users.plan may name. Defaults match the worker
+// Deployment defaults so a worker without explicit resources always fits.
+var TenantPlans = map[string]TenantPlan{
+	"free": {
+		Name: "free", CPU: "4", Memory: "4Gi", Storage: "20Gi", Pods: 10,
+		DefaultCPU: "1", DefaultMemory: "500Mi", DefaultStorage: "2Gi",
+		CombinatorProfile: "small", MaxCombinatorProfile: "small",
+	},
+	"pro": {
+		Name: "pro", CPU: "16", Memory: "32Gi", Storage: "100Gi", Pods: 50,
+		DefaultCPU: "1", DefaultMemory: "500Mi", DefaultStorage: "2Gi",
+		CombinatorProfile: "small", MaxCombinatorProfile: "medium",
+		AllowWritableRootFS: true,
+	},
+	"team": {
+		Name: "team", CPU: "64", Memory: "128Gi", Storage: "500Gi", Pods: 200,
+		DefaultCPU: "1", DefaultMemory: "500Mi", DefaultStorage: "2Gi",
+		AllowRunAsRoot: true, AllowWritableRootFS: true,
+		CombinatorProfile: "medium", MaxCombinatorProfile: "large",
+	},
+}
+
+// PlanFor returns the named plan with its default limits, falling back to DefaultPlan
+func PlanFor(name string) TenantPlan {
+	return PlanWithLimits(dblayer.PlanLimitsFor(name))
+}
+
+// PlanWithLimits returns the plan named by limits with its Max* taken from
+// limits, which may carry account overrides
+func PlanWithLimits(limits dblayer.PlanLimits) TenantPlan {
+	p, ok := TenantPlans[limits.Plan]
+	if !ok {
+		p = TenantPlans[DefaultPlan]
+	}
+	p.MaxCPU, p.MaxMemory, p.MaxReplicas = limits.MaxCPU, limits.MaxMemory, limits.MaxReplicas
+	return p
+}
+
+// PlanForUser returns the plan of a user with their account overrides applied
+func PlanForUser(ctx context.Context, uid string) (TenantPlan, error) {
+	limits, err := dblayer.GetUserLimits(ctx, uid)
+	if err != nil {
+		return TenantPlan{}, fmt.Errorf("get limits of %s: %w", uid, err)
+	}
+	return PlanWithLimits(limits), nil
+}
+
+// EnsureTenantNamespace creates or updates ownerID's namespace in the cluster
+// of client together with its ResourceQuota, LimitRange and default
+// NetworkPolicies for the plan, and enforces the plan's Pod Security level.
+func EnsureTenantNamespace(ctx context.Context, client kubernetes.Interface, ownerID string, plan TenantPlan) error {
+	ns := TenantNamespace(ownerID)
+	labels := map[string]string{
+		ManagedByLabel: ManagedBy,
+		"owner-id":     ownerID,
+		PlanLabel:      plan.Name,
+	}
+	nsLabels := map[string]string{PodSecurityEnforceLabel: plan.PodSecurityLevel()}
+	for k, v := range labels {
+		nsLabels[k] = v
+	}
+
+	if err := EnsureNamespace(ctx, client, ns, nsLabels); err != nil {
+		return fmt.Errorf("ensure namespace %s: %w", ns, err)
+	}
+	if err := ensureResourceQuota(ctx, client, tenantResourceQuota(ns, labels, plan)); err != nil {
+		return fmt.Errorf("ensure resource quota: %w", err)
+	}
+	if err := ensureLimitRange(ctx, client, tenantLimitRange(ns, labels, plan)); err != nil {
+		return fmt.Errorf("ensure limit range: %w", err)
+	}
+	for _, np := range tenantNetworkPolicies(ns, labels) {
+		if err := ensureNetworkPolicy(ctx, client, np); err != nil {
+			return fmt.Errorf("ensure network policy %s: %w", np.Name, err)
+		}
+	}
+	return nil
+}
+
+// EnsureNamespace creates the namespace name with labels, or adds the labels
+// to it when it already exists
+func EnsureNamespace(ctx context.Context, kube kubernetes.Interface, name string, labels map[string]string) error {
+	client := kube.CoreV1().Namespaces()
+	existing, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	dirty := false
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		if existing.Labels[k] != v {
+			existing.Labels[k] = v
+			dirty = true
+		}
+	}
+	if dirty {
+		_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+func tenantResourceQuota(ns string, labels map[string]string, plan TenantPlan) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: tenantQuotaName, Namespace: ns, Labels: labels},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{
+				corev1.ResourceRequestsCPU:              resource.MustParse(plan.CPU),
+				corev1.ResourceLimitsCPU:                resource.MustParse(plan.CPU),
+				corev1.ResourceRequestsMemory:           resource.MustParse(plan.Memory),
+				corev1.ResourceLimitsMemory:             resource.MustParse(plan.Memory),
+				corev1.ResourceRequestsEphemeralStorage: resource.MustParse(plan.Storage),
+				corev1.ResourceLimitsEphemeralStorage:   resource.MustParse(plan.Storage),
+				corev1.ResourcePods:                     *resource.NewQuantity(int64(plan.Pods), resource.DecimalSI),
+			},
+		},
+	}
+}
+
+func tenantLimitRange(ns string, labels map[string]string, plan TenantPlan) *corev1.LimitRange {
+	defaults := corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse(plan.DefaultCPU),
+		corev1.ResourceMemory:           resource.MustParse(plan.DefaultMemory),
+		corev1.ResourceEphemeralStorage: resource.MustParse(plan.DefaultStorage),
+	}
+	return &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: tenantLimitRangeName, Namespace: ns, Labels: labels},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Default:        defaults,
+				DefaultRequest: defaults,
+				Max: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(plan.MaxCPU),
+					corev1.ResourceMemory: resource.MustParse(plan.MaxMemory),
+				},
+			}},
+		},
+	}
+}
+
+// tenantNetworkPolicies: ingress only from the ingress controller and the
+// tenant's own pods; egress to DNS, the combinator, the tenant's own pods and
+// public addresses. Private ranges and EgressBlockedCIDRs are excluded so
+// tenants cannot reach each other or the metadata endpoint. Pods of deny-mode
+// workers are left out of egress; their own policy applies (WorkerEgressPolicy).
+func tenantNetworkPolicies(ns string, labels map[string]string) []*networkingv1.NetworkPolicy {
+	internet := networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{
+		CIDR:   "0.0.0.0/0",
+		Except: exceptBlocked("0.0.0.0/0", []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16"}),
+	}}
+
+	ingress := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: tenantIngressPolicyName, Namespace: ns, Labels: labels},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{
+					{NamespaceSelector: namespaceSelector(IngressNamespace)},
+					{PodSelector: &metav1.LabelSelector{}},
+				},
+			}},
+		},
+	}
+	egress := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: tenantEgressPolicyName, Namespace: ns, Labels: labels},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      EgressLabel,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   []string{dblayer.EgressDeny},
+			}}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress: append(platformEgressRules(),
+				networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}, internet}},
+			),
+		},
+	}
+	return []*networkingv1.NetworkPolicy{ingress, egress}
+}
+
+func ensureResourceQuota(ctx context.Context, kube kubernetes.Interface, want *corev1.ResourceQuota) error {
+	client := kube.CoreV1().ResourceQuotas(want.Namespace)
+	existing, err := client.Get(ctx, want.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, want, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels = want.Labels
+	existing.Spec = want.Spec
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+func ensureLimitRange(ctx context.Context, kube kubernetes.Interface, want *corev1.LimitRange) error {
+	client := kube.CoreV1().LimitRanges(want.Namespace)
+	existing, err := client.Get(ctx, want.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, want, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels = want.Labels
+	existing.Spec = want.Spec
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+func ensureNetworkPolicy(ctx context.Context, kube kubernetes.Interface, want *networkingv1.NetworkPolicy) error {
+	client := kube.NetworkingV1().NetworkPolicies(want.Namespace)
+	existing, err := client.Get(ctx, want.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, want, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels = want.Labels
+	existing.Spec = want.Spec
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
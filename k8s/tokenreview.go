@@ -0,0 +1,40 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const serviceAccountUserPrefix = "system:serviceaccount:"
+
+// ReviewServiceAccountToken validates a bearer token with the TokenReview API
+// and returns the service account it belongs to as namespace/name. Tokens of
+// users other than service accounts are rejected. With audiences the token
+// must be issued for one of them.
+func ReviewServiceAccountToken(ctx context.Context, token string, audiences []string) (string, error) {
+	if K8sClient == nil {
+		return "", fmt.Errorf("k8s client not initialized")
+	}
+	review, err := K8sClient.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("token review failed: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return "", fmt.Errorf("token not authenticated: %s", review.Status.Error)
+		}
+		return "", fmt.Errorf("token not authenticated")
+	}
+	sa, ok := strings.CutPrefix(review.Status.User.Username, serviceAccountUserPrefix)
+	if !ok {
+		return "", fmt.Errorf("%s is not a service account", review.Status.User.Username)
+	}
+	// system:serviceaccount:<namespace>:<name>
+	return strings.Replace(sa, ":", "/", 1), nil
+}
@@ -0,0 +1,120 @@
+// Package logging configures the process-wide structured logger (log/slog)
+// and carries request-scoped loggers through context.
+//
+// Packages keep a component logger at package level:
+//
+//	var logger = logging.Component("processor")
+//
+// Component loggers resolve slog.Default() on every record, so they can be
+// declared before Init runs and still pick up the configured level/format.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Init installs the default logger from LOG_LEVEL (debug|info|warn|error,
+// default info) and LOG_FORMAT (json|text, default json, text when ENV=test).
+// The standard log package is redirected to it as well. Records go through
+// RedactingHandler, so secrets passed in messages or errors are masked.
+func Init() {
+	level := ParseLevel(os.Getenv("LOG_LEVEL"))
+
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	if format == "" {
+		format = "json"
+		if os.Getenv("ENV") == "test" {
+			format = "text"
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	if format == "text" {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(RedactingHandler(h)))
+}
+
+// ParseLevel maps a LOG_LEVEL value to a slog level, unknown values mean info
+func ParseLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Fatal logs at error level and exits, for startup failures in main
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Component returns a logger tagged with component=name
+func Component(name string) *slog.Logger {
+	return slog.New(defaultHandler{}).With("component", name)
+}
+
+type ctxKey struct{}
+
+// WithLogger returns a context carrying l
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the request-scoped logger, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// defaultHandler forwards to slog.Default() at log time, replaying any
+// attrs and groups added through With/WithGroup.
+type defaultHandler struct {
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h defaultHandler) resolve() slog.Handler {
+	out := slog.Default().Handler()
+	for _, op := range h.ops {
+		out = op(out)
+	}
+	return out
+}
+
+func (h defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h defaultHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h defaultHandler) with(op func(slog.Handler) slog.Handler) defaultHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return defaultHandler{ops: append(ops, op)}
+}
@@ -0,0 +1,208 @@
+// Package metrics defines the Prometheus collectors of the control plane.
+// Everything is registered on the default registry, which also carries the
+// Go runtime and process collectors; Handler serves it on /metrics.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	k8smetrics "k8s.io/client-go/tools/metrics"
+)
+
+const namespace = "console"
+
+var (
+	// HTTP (outer and inner gin routers); route is the route template, "unmatched" for 404s
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"method", "route", "code"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// Processor jobs
+	JobQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_queue_depth",
+		Help:      "Jobs waiting in the processor queue.",
+	})
+	JobsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs_running",
+		Help:      "Jobs currently executing.",
+	})
+	JobsParked = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs_parked",
+		Help:      "Jobs held back by a per-tenant concurrency cap until another job of the tenant finishes.",
+	})
+	Jobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_total",
+		Help:      "Finished jobs by type and result (success, error).",
+	}, []string{"type", "result"})
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Job execution time by type.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"type"})
+	JobsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_rejected_total",
+		Help:      "Jobs refused because the processor was shutting down.",
+	}, []string{"type"})
+	JobPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_panics_total",
+		Help:      "Job runs that panicked, by type; the panic is recovered and the run counts as an error.",
+	}, []string{"type"})
+	JobsRequeued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_requeued_total",
+		Help:      "Jobs resubmitted after their lease expired (hung, panicked or lost their instance), by type.",
+	}, []string{"type"})
+	JobsAbandoned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_abandoned_total",
+		Help:      "Jobs given up after jobs.max_attempts expired leases, by type.",
+	}, []string{"type"})
+	JobQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_queue_wait_seconds",
+		Help:      "Time jobs spent in the processor queue before running, by type.",
+		Buckets:   []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"type"})
+	JobRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_retries_total",
+		Help:      "Job runs that repeat a failed run of the same job (same type and ID), by type.",
+	}, []string{"type"})
+	JobFailureSpikes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_failure_spikes_total",
+		Help:      "Times a job type's failure rate crossed jobs.failure_alert_rate, by type.",
+	}, []string{"type"})
+
+	// Custom domains
+	DomainVerifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "domain_verifications_total",
+		Help:      "Custom domain verification outcomes (success, timeout, error).",
+	}, []string{"result"})
+	DomainChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "domain_checks_total",
+		Help:      "Periodic re-checks of verified domains by result (ok, failed).",
+	}, []string{"result"})
+
+	// Drift between the database and the cluster, as of the last audit
+	DriftObjects = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "drift_objects",
+		Help:      "Objects that differ from the database by source (worker, domain, rdb) and kind (missing, extra, modified).",
+	}, []string{"source", "kind"})
+
+	// Rows breaking ownership constraints, as of the last consistency check
+	DBInconsistencies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_inconsistencies",
+		Help:      "Rows left to repair by check (orphan_worker, orphan_domain, orphan_combinator_resource, orphan_resource_report, dangling_active_version).",
+	}, []string{"check"})
+
+	// Postgres read replicas, as of the last health check
+	DBReplicaHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_healthy",
+		Help:      "1 when the read replica takes reads, 0 when reads fall back to the primary.",
+	}, []string{"replica"})
+	DBReplicaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_lag_seconds",
+		Help:      "Replication lag of the read replica.",
+	}, []string{"replica"})
+	DBReplicaFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_replica_fallbacks_total",
+		Help:      "Reads retried on the primary after a replica failed.",
+	})
+
+	// Postgres primary: per-call latency and connection pool saturation
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of database calls by dblayer function, including the wait for a pooled connection.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"function"})
+	DBPoolWaitAlerts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_pool_wait_alerts_total",
+		Help:      "Pool checks whose average wait for a connection exceeded db.pool_wait_alert.",
+	})
+
+	// Kubernetes API calls, reported through the client-go metrics hooks
+	K8sRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "k8s_requests_total",
+		Help:      "Kubernetes API requests by method and status code (\"<error>\" for transport failures).",
+	}, []string{"method", "code"})
+	K8sErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "k8s_request_errors_total",
+		Help:      "Kubernetes API requests that failed or returned a non-2xx status.",
+	}, []string{"method", "code"})
+	K8sDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "k8s_request_duration_seconds",
+		Help:      "Kubernetes API request latency by verb.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"verb"})
+)
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB exports connection pool stats (open, in use, idle, waits) of db
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterK8sClient hooks client-go request metrics; client-go only accepts
+// the first registration, so call it once before creating clients.
+func RegisterK8sClient() {
+	k8smetrics.Register(k8smetrics.RegisterOpts{
+		RequestResult:  k8sResult{},
+		RequestLatency: k8sLatency{},
+	})
+}
+
+type k8sResult struct{}
+
+func (k8sResult) Increment(_ context.Context, code, method, _ string) {
+	K8sRequests.WithLabelValues(method, code).Inc()
+	if !strings.HasPrefix(code, "2") {
+		K8sErrors.WithLabelValues(method, code).Inc()
+	}
+}
+
+type k8sLatency struct{}
+
+func (k8sLatency) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	K8sDuration.WithLabelValues(verb).Observe(latency.Seconds())
+}
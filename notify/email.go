@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"jabberwocky238/console/dblayer"
+
+	"github.com/resend/resend-go/v3"
+)
+
+// ResendClient sends all outbound mail; nil until RESEND_API_KEY is set
+var ResendClient *resend.Client
+
+const mailFrom = "Combinator <combinator@enzyme.cloud>"
+
+// Mail is a template (see Templates) and the data to execute it with
+type Mail struct {
+	Template string
+	Data     any
+}
+
+// SendMail renders m in the user's locale and sends it to the user
+func SendMail(ctx context.Context, userUID string, m Mail) error {
+	email, locale, err := dblayer.GetUserContact(ctx, userUID)
+	if err != nil {
+		return fmt.Errorf("lookup user email: %w", err)
+	}
+	_, err = SendMailTo(ctx, email, locale, m)
+	return err
+}
+
+// SendMailTo renders m in locale and sends it to an address that may not
+// belong to a user yet; it returns the id of the sent message
+func SendMailTo(ctx context.Context, to, locale string, m Mail) (string, error) {
+	msg, err := Render(ctx, m.Template, locale, m.Data)
+	if err != nil {
+		return "", fmt.Errorf("render %s: %w", m.Template, err)
+	}
+	return Deliver(to, msg)
+}
+
+// Deliver sends a rendered message
+func Deliver(to string, msg Message) (string, error) {
+	if ResendClient == nil {
+		return "", errors.New("email not configured")
+	}
+	sent, err := ResendClient.Emails.Send(&resend.SendEmailRequest{
+		From:    mailFrom,
+		To:      []string{to},
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+	})
+	if err != nil {
+		return "", err
+	}
+	return sent.Id, nil
+}
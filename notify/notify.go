@@ -0,0 +1,78 @@
+// Package notify delivers notifications to users. Every notification is
+// stored in the notifications table (the in-app notification center) and
+// pushed on the event stream; email and webhook delivery are optional
+// channels on top of that, shared by every job that notifies a user.
+package notify
+
+import (
+	"context"
+
+	"jabberwocky238/console/dblayer"
+	"jabberwocky238/console/logging"
+)
+
+var log = logging.Component("notify")
+
+// Channels selects where a notification goes besides the notification center
+type Channels struct {
+	Email   *Mail
+	Webhook *Webhook
+}
+
+// Webhook is a user-configured endpoint; Body is posted as JSON, signed with
+// Secret when set
+type Webhook struct {
+	URL    string
+	Secret string
+	Body   any
+}
+
+// Send stores n, publishes it on the event stream and delivers it on ch.
+// A notification whose DedupeKey was already stored for the user is dropped
+// without delivery, so periodic checks can call Send on every run. When the
+// row cannot be stored, email and webhook still go out. The user's
+// notification preferences can turn off either channel for n.Kind; when they
+// cannot be read, both go out. Failures are logged; Send reports whether n
+// was new.
+func Send(ctx context.Context, n *dblayer.Notification, ch Channels) bool {
+	created, err := dblayer.CreateNotification(ctx, n)
+	if err != nil {
+		log.Error("save notification failed", "user_id", n.UserUID, "kind", n.Kind, "error", err)
+	} else if !created {
+		return false
+	} else {
+		dblayer.PublishEvent(ctx, dblayer.Event{
+			UserUID:      n.UserUID,
+			Kind:         dblayer.EventNotification,
+			ResourceType: n.ResourceType,
+			ResourceID:   n.ResourceID,
+			Status:       n.Kind,
+			Message:      n.Title,
+		})
+	}
+
+	if ch.Email != nil || ch.Webhook != nil {
+		pref, perr := dblayer.GetNotificationPreference(ctx, n.UserUID, n.Kind)
+		if perr != nil {
+			log.Warn("read notification preferences failed", "user_id", n.UserUID, "kind", n.Kind, "error", perr)
+		}
+		if !pref.Email {
+			ch.Email = nil
+		}
+		if !pref.Webhook {
+			ch.Webhook = nil
+		}
+	}
+
+	if ch.Email != nil {
+		if err := SendMail(ctx, n.UserUID, *ch.Email); err != nil {
+			log.Warn("notification email failed", "user_id", n.UserUID, "kind", n.Kind, "error", err)
+		}
+	}
+	if ch.Webhook != nil && ch.Webhook.URL != "" {
+		if err := PostWebhook(ch.Webhook.URL, ch.Webhook.Secret, ch.Webhook.Body); err != nil {
+			log.Warn("notification webhook failed", "user_id", n.UserUID, "kind", n.Kind, "error", err)
+		}
+	}
+	return err == nil
+}
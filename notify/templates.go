@@ -0,0 +1,262 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+
+	"jabberwocky238/console/dblayer"
+)
+
+// Every outbound mail is rendered from a template. The built-in templates are
+// embedded from templates/<name>.<locale>.tmpl; a row in email_templates
+// overrides one of them in one locale. A template defines "subject" (plain
+// text) and "body" (HTML, escaped as such):
+//
+//	{{define "subject"}}{{.Code}} is your code{{end}}
+//	{{define "body"}}<p>Your code is {{.Code}}</p>{{end}}
+
+// Locales mail can be written in; DefaultLocale is used for users without a
+// preference and for templates missing a translation
+var Locales = []string{"en", "zh"}
+
+const DefaultLocale = "en"
+
+// Template names, with the data each one is executed with
+const (
+	MailVerificationCode     = "verification_code"      // CodeData
+	MailStepUpCode           = "step_up_code"           // CodeData
+	MailSecurityNewDevice    = "security_new_device"    // SecurityNoticeData
+	MailSecurityFailedLogins = "security_failed_logins" // SecurityNoticeData
+	MailWorkerIdle           = "worker_idle"            // WorkerIdleData
+	MailSpendCapReached      = "spend_cap_reached"      // SpendCapData
+	MailAlert                = "alert"                  // AlertData
+	MailDomainCheckFailed    = "domain_check_failed"    // DomainCheckData
+	MailCertificateExpiring  = "certificate_expiring"   // CertificateData
+	MailQuotaWarning         = "quota_warning"          // QuotaData
+	MailRDBTaskFailed        = "rdb_task_failed"        // RDBTaskData
+	MailSignupInvitation     = "signup_invitation"      // InvitationData
+	MailWorkerRemediation    = "worker_remediation"     // RemediationData
+)
+
+type CodeData struct {
+	Code string
+}
+
+type SecurityNoticeData struct {
+	When      string
+	From      string
+	UserAgent string
+}
+
+// WorkerIdleData Status is "warned", "idle" or "deleted"
+type WorkerIdleData struct {
+	Status   string
+	WorkerID string
+	Idle     string
+	Date     string
+}
+
+type SpendCapData struct {
+	Accrued  string
+	Cap      string
+	Currency string
+	Paused   int
+}
+
+// AlertData Status is "firing" or "resolved"
+type AlertData struct {
+	Status   string
+	Rule     string
+	WorkerID string
+	Message  string
+}
+
+type DomainCheckData struct {
+	Domain string
+	Reason string
+}
+
+type CertificateData struct {
+	Domain   string
+	NotAfter string
+	Expired  bool
+}
+
+// QuotaData Resource is "workers", "custom domains" or "RDB storage"
+type QuotaData struct {
+	Resource string
+	Percent  int64
+	Used     string
+	Limit    string
+	Plan     string
+	Reached  bool
+}
+
+type RDBTaskData struct {
+	Task     string
+	Database string
+	Error    string
+}
+
+// InvitationData ExpiresAt is empty for a code that does not expire
+type InvitationData struct {
+	Code      string
+	ExpiresAt string
+}
+
+// RemediationData Action is "memory_bumped", "resize_suggested", "paused"
+// or "crash_looping"; ToMemory is set for the first two
+type RemediationData struct {
+	WorkerID   string
+	Action     string
+	Message    string
+	FromMemory string
+	ToMemory   string
+}
+
+// TemplateInfo describes a template for the admin API; Sample is the data
+// previews are rendered with
+type TemplateInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Sample      any    `json:"sample"`
+}
+
+var templates = []TemplateInfo{
+	{MailVerificationCode, "Code for sign-up and password reset", CodeData{Code: "123456"}},
+	{MailStepUpCode, "Code for a risky sign-in", CodeData{Code: "123456"}},
+	{MailSecurityNewDevice, "Sign-in from a new device", SecurityNoticeData{When: "Mon, 02 Jan 2006 15:04:05 UTC", From: "203.0.113.7 (DE)", UserAgent: "Mozilla/5.0"}},
+	{MailSecurityFailedLogins, "Repeated failed sign-ins", SecurityNoticeData{When: "Mon, 02 Jan 2006 15:04:05 UTC", From: "203.0.113.7 (DE)"}},
+	{MailWorkerIdle, "Idle free-tier worker warned, paused or deleted", WorkerIdleData{Status: "warned", WorkerID: "w-abc123", Idle: "168h0m0s", Date: "2006-01-09"}},
+	{MailSpendCapReached, "Monthly spending cap reached", SpendCapData{Accrued: "50.12", Cap: "50.00", Currency: "USD", Paused: 3}},
+	{MailAlert, "Alert rule firing or resolved", AlertData{Status: "firing", Rule: "high-5xx", WorkerID: "w-abc123", Message: "high-5xx: 12.5% of responses were 5xx, above 5 over the last 5m0s"}},
+	{MailDomainCheckFailed, "Custom domain failed its periodic check", DomainCheckData{Domain: "app.example.com", Reason: "TXT record missing"}},
+	{MailCertificateExpiring, "Custom domain certificate not renewed", CertificateData{Domain: "app.example.com", NotAfter: "2006-01-16"}},
+	{MailQuotaWarning, "Usage close to a plan limit", QuotaData{Resource: "workers", Percent: 80, Used: "16", Limit: "20", Plan: "pro"}},
+	{MailRDBTaskFailed, "Scheduled SQL task started failing", RDBTaskData{Task: "nightly-rollup", Database: "r-abc123", Error: `pq: relation "events" does not exist`}},
+	{MailSignupInvitation, "Waitlisted sign-up approved, with an invitation code", InvitationData{Code: "K7QF-2M9X-PL4D", ExpiresAt: "2006-01-09"}},
+	{MailWorkerRemediation, "Worker remediation: memory raised or suggested after OOM kills, paused or crash looping", RemediationData{WorkerID: "w-abc123", Action: "resize_suggested", Message: "3 OOM kills in the last 15m0s", FromMemory: "500Mi", ToMemory: "1000Mi"}},
+}
+
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+// ErrUnknownTemplate is returned for names not in Templates
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Templates lists every template
+func Templates() []TemplateInfo {
+	return templates
+}
+
+// Lookup returns the template called name
+func Lookup(name string) (TemplateInfo, bool) {
+	i := slices.IndexFunc(templates, func(t TemplateInfo) bool { return t.Name == name })
+	if i < 0 {
+		return TemplateInfo{}, false
+	}
+	return templates[i], true
+}
+
+// SupportedLocale reports whether mail can be written in locale
+func SupportedLocale(locale string) bool {
+	return slices.Contains(Locales, locale)
+}
+
+// MatchLocale picks the first supported language of an Accept-Language
+// header, DefaultLocale if there is none
+func MatchLocale(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if SupportedLocale(base) {
+			return base
+		}
+	}
+	return DefaultLocale
+}
+
+// Builtin returns the embedded source of name in locale
+func Builtin(name, locale string) (string, bool) {
+	src, err := builtinFS.ReadFile("templates/" + name + "." + locale + ".tmpl")
+	if err != nil {
+		return "", false
+	}
+	return string(src), true
+}
+
+// Source returns the source mail of name in locale is rendered from: the
+// override if there is one, else the built-in translation, else the built-in
+// DefaultLocale version
+func Source(ctx context.Context, name, locale string) (src string, overridden bool, err error) {
+	if _, ok := Lookup(name); !ok {
+		return "", false, ErrUnknownTemplate
+	}
+	if !SupportedLocale(locale) {
+		locale = DefaultLocale
+	}
+	t, err := dblayer.GetEmailTemplate(ctx, name, locale)
+	if err == nil {
+		return t.Source, true, nil
+	}
+	if err != dblayer.ErrNotFound {
+		// a broken database must not stop sign-in codes
+		log.Warn("load email template override failed", "template", name, "locale", locale, "error", err)
+	}
+	if src, ok := Builtin(name, locale); ok {
+		return src, false, nil
+	}
+	src, _ = Builtin(name, DefaultLocale)
+	return src, false, nil
+}
+
+// Message is a rendered mail
+type Message struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+}
+
+// Render executes the source of name in locale (see Source) with data
+func Render(ctx context.Context, name, locale string, data any) (Message, error) {
+	src, _, err := Source(ctx, name, locale)
+	if err != nil {
+		return Message{}, err
+	}
+	return RenderSource(src, data)
+}
+
+// RenderSource executes a template source with data; previews and
+// validation of overrides use it directly
+func RenderSource(src string, data any) (Message, error) {
+	subject, err := texttemplate.New("").Option("missingkey=error").Parse(src)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := htmltemplate.New("").Option("missingkey=error").Parse(src)
+	if err != nil {
+		return Message{}, err
+	}
+	if subject.Lookup("subject") == nil || body.Lookup("body") == nil {
+		return Message{}, errors.New(`template must define "subject" and "body"`)
+	}
+
+	var s, b bytes.Buffer
+	if err := subject.ExecuteTemplate(&s, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("subject: %w", err)
+	}
+	if err := body.ExecuteTemplate(&b, "body", data); err != nil {
+		return Message{}, fmt.Errorf("body: %w", err)
+	}
+	m := Message{Subject: strings.Join(strings.Fields(s.String()), " "), HTML: strings.TrimSpace(b.String())}
+	if m.Subject == "" {
+		return Message{}, errors.New("subject is empty")
+	}
+	return m, nil
+}
@@ -0,0 +1,73 @@
+// Package consolev1 contains the generated gRPC stubs for the inner gateway
+// API and a small client helper.
+package consolev1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative console/v1/console.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// tokenCredentials sends the shared token as "authorization: Bearer <token>"
+type tokenCredentials struct {
+	token      string
+	requireTLS bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.requireTLS
+}
+
+// WithToken authenticates every call with the shared token. Set requireTLS
+// when the server is configured with a certificate.
+func WithToken(token string, requireTLS bool) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(tokenCredentials{token: token, requireTLS: requireTLS})
+}
+
+// Dial connects to the inner gateway gRPC endpoint, e.g.
+//
+//	client, conn, err := consolev1.Dial("console-inner:9902",
+//		grpc.WithTransportCredentials(insecure.NewCredentials()),
+//		consolev1.WithToken(os.Getenv("GRPC_TOKEN"), false))
+func Dial(target string, opts ...grpc.DialOption) (ConsoleInternalClient, *grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewConsoleInternalClient(conn), conn, nil
+}
+
+// TLSCredentials returns transport credentials that verify the server with
+// caFile and, when certFile is set, present a client certificate for mTLS.
+func TLSCredentials(certFile, keyFile, caFile string) (credentials.TransportCredentials, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificates found in " + caFile)
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
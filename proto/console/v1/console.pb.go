@@ -0,0 +1,640 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.28.3
+// source: console/v1/console.proto
+
+package consolev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetWorkerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserUid       string                 `protobuf:"bytes,1,opt,name=user_uid,json=userUid,proto3" json:"user_uid,omitempty"`
+	WorkerId      string                 `protobuf:"bytes,2,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkerRequest) Reset() {
+	*x = GetWorkerRequest{}
+	mi := &file_console_v1_console_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkerRequest) ProtoMessage() {}
+
+func (x *GetWorkerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkerRequest.ProtoReflect.Descriptor instead.
+func (*GetWorkerRequest) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetWorkerRequest) GetUserUid() string {
+	if x != nil {
+		return x.UserUid
+	}
+	return ""
+}
+
+func (x *GetWorkerRequest) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+type Worker struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	WorkerId   string                 `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	WorkerName string                 `protobuf:"bytes,2,opt,name=worker_name,json=workerName,proto3" json:"worker_name,omitempty"`
+	Status     string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	// 0 when the worker has never been deployed.
+	ActiveVersionId int64  `protobuf:"varint,4,opt,name=active_version_id,json=activeVersionId,proto3" json:"active_version_id,omitempty"`
+	Url             string `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Worker) Reset() {
+	*x = Worker{}
+	mi := &file_console_v1_console_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Worker) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Worker) ProtoMessage() {}
+
+func (x *Worker) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Worker.ProtoReflect.Descriptor instead.
+func (*Worker) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Worker) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *Worker) GetWorkerName() string {
+	if x != nil {
+		return x.WorkerName
+	}
+	return ""
+}
+
+func (x *Worker) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Worker) GetActiveVersionId() int64 {
+	if x != nil {
+		return x.ActiveVersionId
+	}
+	return 0
+}
+
+func (x *Worker) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type DeployWorkerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserUid       string                 `protobuf:"bytes,1,opt,name=user_uid,json=userUid,proto3" json:"user_uid,omitempty"`
+	WorkerId      string                 `protobuf:"bytes,2,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	Image         string                 `protobuf:"bytes,3,opt,name=image,proto3" json:"image,omitempty"`
+	Port          int32                  `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeployWorkerRequest) Reset() {
+	*x = DeployWorkerRequest{}
+	mi := &file_console_v1_console_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeployWorkerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeployWorkerRequest) ProtoMessage() {}
+
+func (x *DeployWorkerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeployWorkerRequest.ProtoReflect.Descriptor instead.
+func (*DeployWorkerRequest) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DeployWorkerRequest) GetUserUid() string {
+	if x != nil {
+		return x.UserUid
+	}
+	return ""
+}
+
+func (x *DeployWorkerRequest) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *DeployWorkerRequest) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *DeployWorkerRequest) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+type DeployWorkerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkerId      string                 `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	VersionId     int64                  `protobuf:"varint,2,opt,name=version_id,json=versionId,proto3" json:"version_id,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeployWorkerResponse) Reset() {
+	*x = DeployWorkerResponse{}
+	mi := &file_console_v1_console_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeployWorkerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeployWorkerResponse) ProtoMessage() {}
+
+func (x *DeployWorkerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeployWorkerResponse.ProtoReflect.Descriptor instead.
+func (*DeployWorkerResponse) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DeployWorkerResponse) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *DeployWorkerResponse) GetVersionId() int64 {
+	if x != nil {
+		return x.VersionId
+	}
+	return 0
+}
+
+func (x *DeployWorkerResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type GetWorkerEnvRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserUid       string                 `protobuf:"bytes,1,opt,name=user_uid,json=userUid,proto3" json:"user_uid,omitempty"`
+	WorkerId      string                 `protobuf:"bytes,2,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkerEnvRequest) Reset() {
+	*x = GetWorkerEnvRequest{}
+	mi := &file_console_v1_console_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkerEnvRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkerEnvRequest) ProtoMessage() {}
+
+func (x *GetWorkerEnvRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkerEnvRequest.ProtoReflect.Descriptor instead.
+func (*GetWorkerEnvRequest) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetWorkerEnvRequest) GetUserUid() string {
+	if x != nil {
+		return x.UserUid
+	}
+	return ""
+}
+
+func (x *GetWorkerEnvRequest) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+type WorkerEnv struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Env           map[string]string      `protobuf:"bytes,1,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerEnv) Reset() {
+	*x = WorkerEnv{}
+	mi := &file_console_v1_console_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerEnv) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerEnv) ProtoMessage() {}
+
+func (x *WorkerEnv) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerEnv.ProtoReflect.Descriptor instead.
+func (*WorkerEnv) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WorkerEnv) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+type RetrieveSecretRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserUid       string                 `protobuf:"bytes,1,opt,name=user_uid,json=userUid,proto3" json:"user_uid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetrieveSecretRequest) Reset() {
+	*x = RetrieveSecretRequest{}
+	mi := &file_console_v1_console_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrieveSecretRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrieveSecretRequest) ProtoMessage() {}
+
+func (x *RetrieveSecretRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrieveSecretRequest.ProtoReflect.Descriptor instead.
+func (*RetrieveSecretRequest) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RetrieveSecretRequest) GetUserUid() string {
+	if x != nil {
+		return x.UserUid
+	}
+	return ""
+}
+
+type ResourceRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ResourceType  string                 `protobuf:"bytes,1,opt,name=resource_type,json=resourceType,proto3" json:"resource_type,omitempty"`
+	ResourceId    string                 `protobuf:"bytes,2,opt,name=resource_id,json=resourceId,proto3" json:"resource_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResourceRef) Reset() {
+	*x = ResourceRef{}
+	mi := &file_console_v1_console_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResourceRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceRef) ProtoMessage() {}
+
+func (x *ResourceRef) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceRef.ProtoReflect.Descriptor instead.
+func (*ResourceRef) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ResourceRef) GetResourceType() string {
+	if x != nil {
+		return x.ResourceType
+	}
+	return ""
+}
+
+func (x *ResourceRef) GetResourceId() string {
+	if x != nil {
+		return x.ResourceId
+	}
+	return ""
+}
+
+type RetrieveSecretResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Resources     []*ResourceRef         `protobuf:"bytes,1,rep,name=resources,proto3" json:"resources,omitempty"`
+	SecretKey     string                 `protobuf:"bytes,2,opt,name=secret_key,json=secretKey,proto3" json:"secret_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetrieveSecretResponse) Reset() {
+	*x = RetrieveSecretResponse{}
+	mi := &file_console_v1_console_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrieveSecretResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrieveSecretResponse) ProtoMessage() {}
+
+func (x *RetrieveSecretResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_console_v1_console_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrieveSecretResponse.ProtoReflect.Descriptor instead.
+func (*RetrieveSecretResponse) Descriptor() ([]byte, []int) {
+	return file_console_v1_console_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RetrieveSecretResponse) GetResources() []*ResourceRef {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *RetrieveSecretResponse) GetSecretKey() string {
+	if x != nil {
+		return x.SecretKey
+	}
+	return ""
+}
+
+var File_console_v1_console_proto protoreflect.FileDescriptor
+
+const file_console_v1_console_proto_rawDesc = "" +
+	"\n" +
+	"\x18console/v1/console.proto\x12\n" +
+	"console.v1\"J\n" +
+	"\x10GetWorkerRequest\x12\x19\n" +
+	"\buser_uid\x18\x01 \x01(\tR\auserUid\x12\x1b\n" +
+	"\tworker_id\x18\x02 \x01(\tR\bworkerId\"\x9c\x01\n" +
+	"\x06Worker\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12\x1f\n" +
+	"\vworker_name\x18\x02 \x01(\tR\n" +
+	"workerName\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12*\n" +
+	"\x11active_version_id\x18\x04 \x01(\x03R\x0factiveVersionId\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\"w\n" +
+	"\x13DeployWorkerRequest\x12\x19\n" +
+	"\buser_uid\x18\x01 \x01(\tR\auserUid\x12\x1b\n" +
+	"\tworker_id\x18\x02 \x01(\tR\bworkerId\x12\x14\n" +
+	"\x05image\x18\x03 \x01(\tR\x05image\x12\x12\n" +
+	"\x04port\x18\x04 \x01(\x05R\x04port\"j\n" +
+	"\x14DeployWorkerResponse\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12\x1d\n" +
+	"\n" +
+	"version_id\x18\x02 \x01(\x03R\tversionId\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\"M\n" +
+	"\x13GetWorkerEnvRequest\x12\x19\n" +
+	"\buser_uid\x18\x01 \x01(\tR\auserUid\x12\x1b\n" +
+	"\tworker_id\x18\x02 \x01(\tR\bworkerId\"u\n" +
+	"\tWorkerEnv\x120\n" +
+	"\x03env\x18\x01 \x03(\v2\x1e.console.v1.WorkerEnv.EnvEntryR\x03env\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"2\n" +
+	"\x15RetrieveSecretRequest\x12\x19\n" +
+	"\buser_uid\x18\x01 \x01(\tR\auserUid\"S\n" +
+	"\vResourceRef\x12#\n" +
+	"\rresource_type\x18\x01 \x01(\tR\fresourceType\x12\x1f\n" +
+	"\vresource_id\x18\x02 \x01(\tR\n" +
+	"resourceId\"n\n" +
+	"\x16RetrieveSecretResponse\x125\n" +
+	"\tresources\x18\x01 \x03(\v2\x17.console.v1.ResourceRefR\tresources\x12\x1d\n" +
+	"\n" +
+	"secret_key\x18\x02 \x01(\tR\tsecretKey2\xc4\x02\n" +
+	"\x0fConsoleInternal\x12=\n" +
+	"\tGetWorker\x12\x1c.console.v1.GetWorkerRequest\x1a\x12.console.v1.Worker\x12Q\n" +
+	"\fDeployWorker\x12\x1f.console.v1.DeployWorkerRequest\x1a .console.v1.DeployWorkerResponse\x12F\n" +
+	"\fGetWorkerEnv\x12\x1f.console.v1.GetWorkerEnvRequest\x1a\x15.console.v1.WorkerEnv\x12W\n" +
+	"\x0eRetrieveSecret\x12!.console.v1.RetrieveSecretRequest\x1a\".console.v1.RetrieveSecretResponseB3Z1jabberwocky238/console/proto/console/v1;consolev1b\x06proto3"
+
+var (
+	file_console_v1_console_proto_rawDescOnce sync.Once
+	file_console_v1_console_proto_rawDescData []byte
+)
+
+func file_console_v1_console_proto_rawDescGZIP() []byte {
+	file_console_v1_console_proto_rawDescOnce.Do(func() {
+		file_console_v1_console_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_console_v1_console_proto_rawDesc), len(file_console_v1_console_proto_rawDesc)))
+	})
+	return file_console_v1_console_proto_rawDescData
+}
+
+var file_console_v1_console_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_console_v1_console_proto_goTypes = []any{
+	(*GetWorkerRequest)(nil),       // 0: console.v1.GetWorkerRequest
+	(*Worker)(nil),                 // 1: console.v1.Worker
+	(*DeployWorkerRequest)(nil),    // 2: console.v1.DeployWorkerRequest
+	(*DeployWorkerResponse)(nil),   // 3: console.v1.DeployWorkerResponse
+	(*GetWorkerEnvRequest)(nil),    // 4: console.v1.GetWorkerEnvRequest
+	(*WorkerEnv)(nil),              // 5: console.v1.WorkerEnv
+	(*RetrieveSecretRequest)(nil),  // 6: console.v1.RetrieveSecretRequest
+	(*ResourceRef)(nil),            // 7: console.v1.ResourceRef
+	(*RetrieveSecretResponse)(nil), // 8: console.v1.RetrieveSecretResponse
+	nil,                            // 9: console.v1.WorkerEnv.EnvEntry
+}
+var file_console_v1_console_proto_depIdxs = []int32{
+	9, // 0: console.v1.WorkerEnv.env:type_name -> console.v1.WorkerEnv.EnvEntry
+	7, // 1: console.v1.RetrieveSecretResponse.resources:type_name -> console.v1.ResourceRef
+	0, // 2: console.v1.ConsoleInternal.GetWorker:input_type -> console.v1.GetWorkerRequest
+	2, // 3: console.v1.ConsoleInternal.DeployWorker:input_type -> console.v1.DeployWorkerRequest
+	4, // 4: console.v1.ConsoleInternal.GetWorkerEnv:input_type -> console.v1.GetWorkerEnvRequest
+	6, // 5: console.v1.ConsoleInternal.RetrieveSecret:input_type -> console.v1.RetrieveSecretRequest
+	1, // 6: console.v1.ConsoleInternal.GetWorker:output_type -> console.v1.Worker
+	3, // 7: console.v1.ConsoleInternal.DeployWorker:output_type -> console.v1.DeployWorkerResponse
+	5, // 8: console.v1.ConsoleInternal.GetWorkerEnv:output_type -> console.v1.WorkerEnv
+	8, // 9: console.v1.ConsoleInternal.RetrieveSecret:output_type -> console.v1.RetrieveSecretResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_console_v1_console_proto_init() }
+func file_console_v1_console_proto_init() {
+	if File_console_v1_console_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_console_v1_console_proto_rawDesc), len(file_console_v1_console_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_console_v1_console_proto_goTypes,
+		DependencyIndexes: file_console_v1_console_proto_depIdxs,
+		MessageInfos:      file_console_v1_console_proto_msgTypes,
+	}.Build()
+	File_console_v1_console_proto = out.File
+	file_console_v1_console_proto_goTypes = nil
+	file_console_v1_console_proto_depIdxs = nil
+}
@@ -0,0 +1,253 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: console/v1/console.proto
+
+package consolev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ConsoleInternal_GetWorker_FullMethodName      = "/console.v1.ConsoleInternal/GetWorker"
+	ConsoleInternal_DeployWorker_FullMethodName   = "/console.v1.ConsoleInternal/DeployWorker"
+	ConsoleInternal_GetWorkerEnv_FullMethodName   = "/console.v1.ConsoleInternal/GetWorkerEnv"
+	ConsoleInternal_RetrieveSecret_FullMethodName = "/console.v1.ConsoleInternal/RetrieveSecret"
+)
+
+// ConsoleInternalClient is the client API for ConsoleInternal service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ConsoleInternal is served by the inner gateway for in-cluster callers
+// (combinator pods, workers). Every call must carry a bearer token in the
+// "authorization" metadata, or come over mTLS with a client certificate
+// signed by the configured CA.
+type ConsoleInternalClient interface {
+	// GetWorker returns a worker owned by user_uid.
+	GetWorker(ctx context.Context, in *GetWorkerRequest, opts ...grpc.CallOption) (*Worker, error)
+	// DeployWorker creates a deploy version and enqueues the deploy job.
+	DeployWorker(ctx context.Context, in *DeployWorkerRequest, opts ...grpc.CallOption) (*DeployWorkerResponse, error)
+	// GetWorkerEnv returns the environment variables of a worker.
+	GetWorkerEnv(ctx context.Context, in *GetWorkerEnvRequest, opts ...grpc.CallOption) (*WorkerEnv, error)
+	// RetrieveSecret returns the active combinator resources and secret key of a user.
+	RetrieveSecret(ctx context.Context, in *RetrieveSecretRequest, opts ...grpc.CallOption) (*RetrieveSecretResponse, error)
+}
+
+type consoleInternalClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConsoleInternalClient(cc grpc.ClientConnInterface) ConsoleInternalClient {
+	return &consoleInternalClient{cc}
+}
+
+func (c *consoleInternalClient) GetWorker(ctx context.Context, in *GetWorkerRequest, opts ...grpc.CallOption) (*Worker, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Worker)
+	err := c.cc.Invoke(ctx, ConsoleInternal_GetWorker_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consoleInternalClient) DeployWorker(ctx context.Context, in *DeployWorkerRequest, opts ...grpc.CallOption) (*DeployWorkerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeployWorkerResponse)
+	err := c.cc.Invoke(ctx, ConsoleInternal_DeployWorker_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consoleInternalClient) GetWorkerEnv(ctx context.Context, in *GetWorkerEnvRequest, opts ...grpc.CallOption) (*WorkerEnv, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WorkerEnv)
+	err := c.cc.Invoke(ctx, ConsoleInternal_GetWorkerEnv_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consoleInternalClient) RetrieveSecret(ctx context.Context, in *RetrieveSecretRequest, opts ...grpc.CallOption) (*RetrieveSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RetrieveSecretResponse)
+	err := c.cc.Invoke(ctx, ConsoleInternal_RetrieveSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ConsoleInternalServer is the server API for ConsoleInternal service.
+// All implementations must embed UnimplementedConsoleInternalServer
+// for forward compatibility.
+//
+// ConsoleInternal is served by the inner gateway for in-cluster callers
+// (combinator pods, workers). Every call must carry a bearer token in the
+// "authorization" metadata, or come over mTLS with a client certificate
+// signed by the configured CA.
+type ConsoleInternalServer interface {
+	// GetWorker returns a worker owned by user_uid.
+	GetWorker(context.Context, *GetWorkerRequest) (*Worker, error)
+	// DeployWorker creates a deploy version and enqueues the deploy job.
+	DeployWorker(context.Context, *DeployWorkerRequest) (*DeployWorkerResponse, error)
+	// GetWorkerEnv returns the environment variables of a worker.
+	GetWorkerEnv(context.Context, *GetWorkerEnvRequest) (*WorkerEnv, error)
+	// RetrieveSecret returns the active combinator resources and secret key of a user.
+	RetrieveSecret(context.Context, *RetrieveSecretRequest) (*RetrieveSecretResponse, error)
+	mustEmbedUnimplementedConsoleInternalServer()
+}
+
+// UnimplementedConsoleInternalServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedConsoleInternalServer struct{}
+
+func (UnimplementedConsoleInternalServer) GetWorker(context.Context, *GetWorkerRequest) (*Worker, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorker not implemented")
+}
+func (UnimplementedConsoleInternalServer) DeployWorker(context.Context, *DeployWorkerRequest) (*DeployWorkerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeployWorker not implemented")
+}
+func (UnimplementedConsoleInternalServer) GetWorkerEnv(context.Context, *GetWorkerEnvRequest) (*WorkerEnv, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorkerEnv not implemented")
+}
+func (UnimplementedConsoleInternalServer) RetrieveSecret(context.Context, *RetrieveSecretRequest) (*RetrieveSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetrieveSecret not implemented")
+}
+func (UnimplementedConsoleInternalServer) mustEmbedUnimplementedConsoleInternalServer() {}
+func (UnimplementedConsoleInternalServer) testEmbeddedByValue()                         {}
+
+// UnsafeConsoleInternalServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConsoleInternalServer will
+// result in compilation errors.
+type UnsafeConsoleInternalServer interface {
+	mustEmbedUnimplementedConsoleInternalServer()
+}
+
+func RegisterConsoleInternalServer(s grpc.ServiceRegistrar, srv ConsoleInternalServer) {
+	// If the following call pancis, it indicates UnimplementedConsoleInternalServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ConsoleInternal_ServiceDesc, srv)
+}
+
+func _ConsoleInternal_GetWorker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWorkerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsoleInternalServer).GetWorker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsoleInternal_GetWorker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsoleInternalServer).GetWorker(ctx, req.(*GetWorkerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConsoleInternal_DeployWorker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeployWorkerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsoleInternalServer).DeployWorker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsoleInternal_DeployWorker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsoleInternalServer).DeployWorker(ctx, req.(*DeployWorkerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConsoleInternal_GetWorkerEnv_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWorkerEnvRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsoleInternalServer).GetWorkerEnv(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsoleInternal_GetWorkerEnv_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsoleInternalServer).GetWorkerEnv(ctx, req.(*GetWorkerEnvRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConsoleInternal_RetrieveSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetrieveSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsoleInternalServer).RetrieveSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsoleInternal_RetrieveSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsoleInternalServer).RetrieveSecret(ctx, req.(*RetrieveSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ConsoleInternal_ServiceDesc is the grpc.ServiceDesc for ConsoleInternal service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ConsoleInternal_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "console.v1.ConsoleInternal",
+	HandlerType: (*ConsoleInternalServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetWorker",
+			Handler:    _ConsoleInternal_GetWorker_Handler,
+		},
+		{
+			MethodName: "DeployWorker",
+			Handler:    _ConsoleInternal_DeployWorker_Handler,
+		},
+		{
+			MethodName: "GetWorkerEnv",
+			Handler:    _ConsoleInternal_GetWorkerEnv_Handler,
+		},
+		{
+			MethodName: "RetrieveSecret",
+			Handler:    _ConsoleInternal_RetrieveSecret_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "console/v1/console.proto",
+}
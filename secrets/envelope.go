@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Envelope encryption: every value gets a fresh data key that encrypts it,
+// and the data key is stored wrapped with a keyring key. Rotating the keyring
+// only re-wraps data keys (Rewrap) instead of re-encrypting the values.
+//
+// An envelope is the string "enc1:<key id>:<wrapped data key>:<ciphertext>",
+// base64 encoded, so it fits in the text columns it replaces.
+
+const envelopePrefix = "enc1:"
+
+var errMalformedEnvelope = errors.New("malformed envelope")
+
+// IsEnvelope reports whether s was produced by SealEnvelope
+func IsEnvelope(s string) bool {
+	return strings.HasPrefix(s, envelopePrefix)
+}
+
+// SealEnvelope encrypts plaintext with a new data key wrapped by the primary key
+func (k *Keyring) SealEnvelope(plaintext, aad []byte) (string, error) {
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	keyID, wrapped, err := k.seal(dataKey, nil)
+	if err != nil {
+		return "", err
+	}
+	return formatEnvelope(keyID, wrapped, sealWith(aead, plaintext, aad)), nil
+}
+
+// OpenEnvelope decrypts an envelope sealed with aad
+func (k *Keyring) OpenEnvelope(s string, aad []byte) ([]byte, error) {
+	keyID, wrapped, ciphertext, err := parseEnvelope(s)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.open(keyID, wrapped, nil)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return openWith(aead, ciphertext, aad)
+}
+
+// Rewrap re-wraps the data key of an envelope with the primary key. It
+// returns s unchanged and false when s is already wrapped by the primary key.
+func (k *Keyring) Rewrap(s string) (string, bool, error) {
+	keyID, wrapped, ciphertext, err := parseEnvelope(s)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == k.Primary() {
+		return s, false, nil
+	}
+	dataKey, err := k.open(keyID, wrapped, nil)
+	if err != nil {
+		return "", false, err
+	}
+	keyID, wrapped, err = k.seal(dataKey, nil)
+	if err != nil {
+		return "", false, err
+	}
+	return formatEnvelope(keyID, wrapped, ciphertext), true, nil
+}
+
+func formatEnvelope(keyID string, wrapped, ciphertext []byte) string {
+	return envelopePrefix + keyID + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(ciphertext)
+}
+
+func parseEnvelope(s string) (keyID string, wrapped, ciphertext []byte, err error) {
+	rest, ok := strings.CutPrefix(s, envelopePrefix)
+	if !ok {
+		return "", nil, nil, errMalformedEnvelope
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", nil, nil, errMalformedEnvelope
+	}
+	if wrapped, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, errMalformedEnvelope
+	}
+	if ciphertext, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, errMalformedEnvelope
+	}
+	return parts[0], wrapped, ciphertext, nil
+}
@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Keyring is a set of AES-256-GCM keys by id: the primary key seals, the
+// others only open what was sealed before a key rotation. A nil Keyring is
+// not configured.
+type Keyring struct {
+	aeads   map[string]cipher.AEAD
+	primary string
+}
+
+// ParseKeyring parses a comma separated list of id=base64 32-byte keys; the
+// first one is the primary key
+func ParseKeyring(value string) (*Keyring, error) {
+	k := &Keyring{aeads: map[string]cipher.AEAD{}}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, "=")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("%q is not id=key", pair)
+		}
+		if _, dup := k.aeads[id]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, base64 encoded", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.aeads[id] = aead
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+	if k.primary == "" {
+		return nil, errors.New("no key")
+	}
+	return k, nil
+}
+
+// Primary is the id of the key new values are sealed with
+func (k *Keyring) Primary() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with the primary key into a nonce-prefixed ciphertext
+func (k *Keyring) seal(plaintext, aad []byte) (keyID string, sealed []byte, err error) {
+	if k == nil {
+		return "", nil, ErrNotConfigured
+	}
+	return k.primary, sealWith(k.aeads[k.primary], plaintext, aad), nil
+}
+
+// open decrypts what seal returned with the key keyID
+func (k *Keyring) open(keyID string, sealed, aad []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNotConfigured
+	}
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return openWith(aead, sealed, aad)
+}
+
+func sealWith(aead cipher.AEAD, plaintext, aad []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	rand.Read(nonce) // never fails, see crypto/rand
+	return aead.Seal(nonce, nonce, plaintext, aad)
+}
+
+func openWith(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,70 @@
+// Package secrets seals user application secrets with a platform key before
+// they are stored, so that the database and its backups never hold plain
+// values. Values are opened only on the inner gateway, when a worker env
+// references them with secretref://name.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RefPrefix marks a worker env value that is resolved from a secret:
+// secretref://name
+const RefPrefix = "secretref://"
+
+var (
+	// ErrNotConfigured is returned while no key has been set
+	ErrNotConfigured = errors.New("encryption keys are not configured")
+	// ErrUnknownKey is returned for values sealed with a key that is no longer configured
+	ErrUnknownKey = errors.New("value was sealed with an unknown key")
+)
+
+// values seals user secrets (SECRETS_KEYS)
+var values *Keyring
+
+// Envs are the environment variables read by SetEnv
+var Envs = []string{"SECRETS_KEYS"}
+
+// SetEnv applies one of Envs. SECRETS_KEYS is a comma separated list of
+// id=base64 32-byte keys; the first one seals new versions.
+func SetEnv(env, value string) error {
+	switch env {
+	case "SECRETS_KEYS":
+		k, err := ParseKeyring(value)
+		if err != nil {
+			return fmt.Errorf("SECRETS_KEYS: %w", err)
+		}
+		values = k
+	}
+	return nil
+}
+
+// Configured reports whether values can be sealed
+func Configured() bool {
+	return values != nil
+}
+
+// Seal encrypts plaintext with the primary key. aad binds the result to where
+// it is stored (see AAD) so a sealed value copied to another row fails to open.
+// The returned nonce-prefixed ciphertext is opened with Open and the key id.
+func Seal(plaintext, aad []byte) (keyID string, sealed []byte, err error) {
+	return values.seal(plaintext, aad)
+}
+
+// Open decrypts a value returned by Seal
+func Open(keyID string, sealed, aad []byte) ([]byte, error) {
+	return values.open(keyID, sealed, aad)
+}
+
+// AAD is the additional data a version of a user's secret is sealed with
+func AAD(userUID, name string, version int) []byte {
+	return fmt.Appendf(nil, "%s/%s/%d", userUID, name, version)
+}
+
+// ParseRef returns the secret name referenced by an env value
+func ParseRef(value string) (name string, ok bool) {
+	name, ok = strings.CutPrefix(value, RefPrefix)
+	return name, ok && name != ""
+}
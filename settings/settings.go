@@ -0,0 +1,245 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Runtime settings are tuned without a redeploy. The package that reads a
+// setting registers it with its built-in (or env/flag) value as the default;
+// an override stored in the settings table replaces the default until it is
+// deleted again. Load applies a full snapshot of the overrides, so every
+// instance converges on the table no matter which change notifications it
+// missed.
+
+// ErrUnknown is returned for keys that no package registered
+var ErrUnknown = errors.New("unknown setting")
+
+// Setting is one registered runtime setting
+type Setting struct {
+	Key         string
+	Description string
+
+	parse  func(string) (any, error)
+	format func(any) string
+	def    atomic.Value
+	cur    atomic.Value
+	set    atomic.Bool // an override is applied
+}
+
+// Info describes a setting and its effective value
+type Info struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+	Value       string `json:"value"`
+	Overridden  bool   `json:"overridden"`
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]*Setting{}
+)
+
+func register(key, description string, def any, parse func(string) (any, error), format func(any) string) *Setting {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[key]; ok {
+		panic("settings: duplicate key " + key)
+	}
+	s := &Setting{Key: key, Description: description, parse: parse, format: format}
+	s.def.Store(def)
+	s.cur.Store(def)
+	registry[key] = s
+	return s
+}
+
+func (s *Setting) value() any {
+	return s.cur.Load()
+}
+
+// setDefault replaces the default, e.g. from an env variable at startup; the
+// effective value only changes when no override is applied
+func (s *Setting) setDefault(v any) {
+	mu.Lock()
+	defer mu.Unlock()
+	s.def.Store(v)
+	if !s.set.Load() {
+		s.cur.Store(v)
+	}
+}
+
+func (s *Setting) info() Info {
+	return Info{
+		Key:         s.Key,
+		Description: s.Description,
+		Default:     s.format(s.def.Load()),
+		Value:       s.format(s.cur.Load()),
+		Overridden:  s.set.Load(),
+	}
+}
+
+// Validate checks that value is acceptable for key
+func Validate(key, value string) error {
+	mu.Lock()
+	s, ok := registry[key]
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknown, key)
+	}
+	_, err := s.parse(value)
+	return err
+}
+
+// Load applies overrides (key → value from the settings table); registered
+// settings missing from it go back to their default. Unknown keys and
+// invalid values are skipped and reported, the setting keeps its default.
+func Load(overrides map[string]string) []error {
+	mu.Lock()
+	defer mu.Unlock()
+	var errs []error
+	for key, s := range registry {
+		raw, ok := overrides[key]
+		if !ok {
+			s.set.Store(false)
+			s.cur.Store(s.def.Load())
+			continue
+		}
+		v, err := s.parse(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			s.set.Store(false)
+			s.cur.Store(s.def.Load())
+			continue
+		}
+		s.cur.Store(v)
+		s.set.Store(true)
+	}
+	for key := range overrides {
+		if _, ok := registry[key]; !ok {
+			errs = append(errs, fmt.Errorf("%w %q", ErrUnknown, key))
+		}
+	}
+	return errs
+}
+
+// List describes every registered setting, sorted by key
+func List() []Info {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Info, 0, len(registry))
+	for _, key := range slices.Sorted(maps.Keys(registry)) {
+		out = append(out, registry[key].info())
+	}
+	return out
+}
+
+// Get describes one setting
+func Get(key string) (Info, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := registry[key]
+	if !ok {
+		return Info{}, false
+	}
+	return s.info(), true
+}
+
+// ========== Typed settings ==========
+
+// Int is an integer setting within [min, max]
+type Int struct{ s *Setting }
+
+// NewInt registers an integer setting
+func NewInt(key string, def, min, max int, description string) Int {
+	parse := func(raw string) (any, error) {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		if n < min || n > max {
+			return nil, fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return n, nil
+	}
+	return Int{register(key, description, def, parse, func(v any) string { return strconv.Itoa(v.(int)) })}
+}
+
+// Get returns the effective value
+func (i Int) Get() int { return i.s.value().(int) }
+
+// SetDefault replaces the default (env or flag at startup)
+func (i Int) SetDefault(n int) { i.s.setDefault(n) }
+
+// Duration is a duration setting within [min, max]
+type Duration struct{ s *Setting }
+
+// NewDuration registers a duration setting
+func NewDuration(key string, def, min, max time.Duration, description string) Duration {
+	parse := func(raw string) (any, error) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a duration", raw)
+		}
+		if d < min || d > max {
+			return nil, fmt.Errorf("must be between %s and %s", min, max)
+		}
+		return d, nil
+	}
+	return Duration{register(key, description, def, parse, func(v any) string { return v.(time.Duration).String() })}
+}
+
+// Get returns the effective value
+func (d Duration) Get() time.Duration { return d.s.value().(time.Duration) }
+
+// SetDefault replaces the default (env or flag at startup)
+func (d Duration) SetDefault(v time.Duration) { d.s.setDefault(v) }
+
+// String is a free-form setting checked by validate (nil accepts anything)
+type String struct{ s *Setting }
+
+// NewString registers a string setting
+func NewString(key, def string, validate func(string) error, description string) String {
+	parse := func(raw string) (any, error) {
+		if validate != nil {
+			if err := validate(raw); err != nil {
+				return nil, err
+			}
+		}
+		return raw, nil
+	}
+	return String{register(key, description, def, parse, func(v any) string { return v.(string) })}
+}
+
+// Get returns the effective value
+func (s String) Get() string { return s.s.value().(string) }
+
+// SetDefault replaces the default (env or flag at startup)
+func (s String) SetDefault(v string) { s.s.setDefault(v) }
+
+// Bool is an on/off setting
+type Bool struct{ s *Setting }
+
+// NewBool registers a boolean setting
+func NewBool(key string, def bool, description string) Bool {
+	parse := func(raw string) (any, error) {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return b, nil
+	}
+	return Bool{register(key, description, def, parse, func(v any) string { return strconv.FormatBool(v.(bool)) })}
+}
+
+// Get returns the effective value
+func (b Bool) Get() bool { return b.s.value().(bool) }
+
+// SetDefault replaces the default (env or flag at startup)
+func (b Bool) SetDefault(v bool) { b.s.setDefault(v) }
@@ -70,39 +70,39 @@ async function apiCall(endpoint: string, method = 'GET', data: unknown = null, r
 }
 
 export const authAPI = {
-  sendCode: (email: string) => apiCall('/api/auth/send-code', 'POST', { email }),
-  register: (email: string, code: string, password: string) => apiCall('/api/auth/register', 'POST', { email, code, password }),
-  login: (email: string, password: string) => apiCall('/api/auth/login', 'POST', { email, password }),
+  sendCode: (email: string) => apiCall('/api/v1/auth/send-code', 'POST', { email }),
+  register: (email: string, code: string, password: string) => apiCall('/api/v1/auth/register', 'POST', { email, code, password }),
+  login: (email: string, password: string) => apiCall('/api/v1/auth/login', 'POST', { email, password }),
 };
 
 export const rdbAPI = {
-  list: () => apiCall('/api/rdb', 'GET'),
-  get: (id: string) => apiCall(`/api/rdb/${id}`, 'GET'),
-  create: (name: string) => apiCall('/api/rdb', 'POST', { name }, true),
-  delete: (id: string) => apiCall(`/api/rdb/${id}`, 'DELETE', {}, true),
+  list: () => apiCall('/api/v1/rdb', 'GET'),
+  get: (id: string) => apiCall(`/api/v1/rdb/${id}`, 'GET'),
+  create: (name: string) => apiCall('/api/v1/rdb', 'POST', { name }, true),
+  delete: (id: string) => apiCall(`/api/v1/rdb/${id}`, 'DELETE', {}, true),
 };
 
 export const kvAPI = {
-  list: () => apiCall('/api/kv', 'GET'),
-  create: (kv_type: string, url: string) => apiCall('/api/kv', 'POST', { kv_type, url }, true),
-  delete: (id: string) => apiCall(`/api/kv/${id}`, 'DELETE', {}, true),
+  list: () => apiCall('/api/v1/kv', 'GET'),
+  create: (kv_type: string, url: string) => apiCall('/api/v1/kv', 'POST', { kv_type, url }, true),
+  delete: (id: string) => apiCall(`/api/v1/kv/${id}`, 'DELETE', {}, true),
 };
 
 export const workerAPI = {
-  list: () => apiCall('/api/worker', 'GET'),
-  get: (id: string, offset?: number) => apiCall(`/api/worker/${id}${offset ? `?offset=${offset}` : ''}`, 'GET'),
-  create: (worker_name: string) => apiCall('/api/worker', 'POST', { worker_name }),
-  delete: (id: string) => apiCall(`/api/worker/${id}`, 'DELETE'),
-  getEnv: (id: string) => apiCall(`/api/worker/${id}/env`, 'GET'),
-  setEnv: (id: string, key: string, value: string, del = false) => apiCall(`/api/worker/${id}/env`, 'POST', { key, value, delete: del }),
-  getSecrets: (id: string) => apiCall(`/api/worker/${id}/secret`, 'GET'),
-  setSecrets: (id: string, key: string, value: string) => apiCall(`/api/worker/${id}/secret`, 'POST', { key, value }),
-  deleteSecret: (id: string, key: string) => apiCall(`/api/worker/${id}/secret`, 'POST', { key, delete: true }),
+  list: () => apiCall('/api/v1/worker', 'GET'),
+  get: (id: string, offset?: number) => apiCall(`/api/v1/worker/${id}${offset ? `?offset=${offset}` : ''}`, 'GET'),
+  create: (worker_name: string) => apiCall('/api/v1/worker', 'POST', { worker_name }),
+  delete: (id: string) => apiCall(`/api/v1/worker/${id}`, 'DELETE'),
+  getEnv: (id: string) => apiCall(`/api/v1/worker/${id}/env`, 'GET'),
+  setEnv: (id: string, key: string, value: string, del = false) => apiCall(`/api/v1/worker/${id}/env`, 'POST', { key, value, delete: del }),
+  getSecrets: (id: string) => apiCall(`/api/v1/worker/${id}/secret`, 'GET'),
+  setSecrets: (id: string, key: string, value: string) => apiCall(`/api/v1/worker/${id}/secret`, 'POST', { key, value }),
+  deleteSecret: (id: string, key: string) => apiCall(`/api/v1/worker/${id}/secret`, 'POST', { key, delete: true }),
 };
 
 export const domainAPI = {
-  list: () => apiCall('/api/domain', 'GET'),
-  get: (id: string) => apiCall(`/api/domain/${id}`, 'GET'),
-  create: (domain: string, target: string) => apiCall('/api/domain', 'POST', { domain, target }, true),
-  delete: (id: string) => apiCall(`/api/domain/${id}`, 'DELETE', {}, true),
+  list: () => apiCall('/api/v1/domain', 'GET'),
+  get: (id: string) => apiCall(`/api/v1/domain/${id}`, 'GET'),
+  create: (domain: string, target: string) => apiCall('/api/v1/domain', 'POST', { domain, target }, true),
+  delete: (id: string) => apiCall(`/api/v1/domain/${id}`, 'DELETE', {}, true),
 };